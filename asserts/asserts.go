@@ -138,6 +138,8 @@ var (
 	ValidationSetType   = &AssertionType{"validation-set", []string{"series", "account-id", "name", "sequence"}, nil, assembleValidationSet, sequenceForming}
 	StoreType           = &AssertionType{"store", []string{"store"}, nil, assembleStore, 0}
 	PreseedType         = &AssertionType{"preseed", []string{"series", "brand-id", "model", "system-label"}, nil, assemblePreseed, 0}
+	SeccompDenyType     = &AssertionType{"seccomp-deny", []string{"brand-id", "snap-id"}, nil, assembleSeccompDeny, 0}
+	ImageManifestType   = &AssertionType{"image-manifest", []string{"series", "brand-id", "model", "build-id"}, nil, assembleImageManifest, 0}
 
 // ...
 )
@@ -169,6 +171,8 @@ var typeRegistry = map[string]*AssertionType{
 	SerialRequestType.Name:        SerialRequestType,
 	AccountKeyRequestType.Name:    AccountKeyRequestType,
 	PreseedType.Name:              PreseedType,
+	SeccompDenyType.Name:          SeccompDenyType,
+	ImageManifestType.Name:        ImageManifestType,
 }
 
 // Type returns the AssertionType with name or nil
@@ -54,9 +54,11 @@ func (as *assertsSuite) TestTypeNames(c *C) {
 		// XXX "authority-delegation",
 		"base-declaration",
 		"device-session-request",
+		"image-manifest",
 		"model",
 		"preseed",
 		"repair",
+		"seccomp-deny",
 		"serial",
 		"serial-request",
 		"snap-build",
@@ -1194,6 +1196,8 @@ func (as *assertsSuite) TestWithAuthority(c *C) {
 		"snap-developer",
 		"model",
 		"preseed",
+		"seccomp-deny",
+		"image-manifest",
 		"serial",
 		"system-user",
 		"validation",
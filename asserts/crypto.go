@@ -290,6 +290,21 @@ func RSAPrivateKey(privk *rsa.PrivateKey) PrivateKey {
 	return openpgpPrivateKey{intPrivk}
 }
 
+// CryptoSigner returns a crypto.Signer for the given private key, so
+// that it can be used beyond signing assertions, e.g. to authenticate
+// TLS connections.
+func CryptoSigner(privKey PrivateKey) (crypto.Signer, error) {
+	opgPrivK, ok := privKey.(openpgpPrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("cannot use private key of type %T as a crypto signer", privKey)
+	}
+	signer, ok := opgPrivK.privk.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("cannot use private key of type %T as a crypto signer", opgPrivK.privk.PrivateKey)
+	}
+	return signer, nil
+}
+
 // GenerateKey generates a private/public key pair.
 func GenerateKey() (PrivateKey, error) {
 	priv, err := rsa.GenerateKey(rand.Reader, 4096)
@@ -204,6 +204,15 @@ func (dbs *databaseSuite) TestPublicKeyNotFound(c *C) {
 	c.Check(err, ErrorMatches, "cannot find key pair")
 }
 
+func (dbs *databaseSuite) TestCryptoSigner(c *C) {
+	signer, err := asserts.CryptoSigner(testPrivKey1)
+	c.Assert(err, IsNil)
+	c.Check(signer, Equals, crypto.Signer(testPrivKey1RSA))
+
+	_, err = asserts.CryptoSigner(struct{ asserts.PrivateKey }{testPrivKey1})
+	c.Check(err, ErrorMatches, `cannot use private key of type struct .* as a crypto signer`)
+}
+
 func (dbs *databaseSuite) TestNotFoundErrorIs(c *C) {
 	this := &asserts.NotFoundError{
 		Headers: map[string]string{"a": "a"},
@@ -0,0 +1,97 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"crypto"
+	"time"
+)
+
+// ImageManifest holds an image-manifest assertion, which is a statement by
+// the brand about the build manifest of an image of one of its models. The
+// manifest itself, listing the snaps, assertions and boot assets that went
+// into the image, is kept alongside and referred to by its digest.
+type ImageManifest struct {
+	assertionBase
+	timestamp time.Time
+}
+
+// Series returns the series that this assertion is valid for.
+func (m *ImageManifest) Series() string {
+	return m.HeaderString("series")
+}
+
+// BrandID returns the brand identifier. Same as the authority id.
+func (m *ImageManifest) BrandID() string {
+	return m.HeaderString("brand-id")
+}
+
+// Model returns the model name identifier.
+func (m *ImageManifest) Model() string {
+	return m.HeaderString("model")
+}
+
+// BuildID returns the identifier of the image build.
+func (m *ImageManifest) BuildID() string {
+	return m.HeaderString("build-id")
+}
+
+// ManifestSHA3_384 returns the checksum of the build manifest.
+func (m *ImageManifest) ManifestSHA3_384() string {
+	return m.HeaderString("manifest-sha3-384")
+}
+
+// Timestamp returns the time when the image-manifest assertion was issued.
+func (m *ImageManifest) Timestamp() time.Time {
+	return m.timestamp
+}
+
+func assembleImageManifest(assert assertionBase) (Assertion, error) {
+	// authority must match the brand (signer is the brand)
+	err := checkAuthorityMatchesBrand(&assert)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = checkModel(assert.headers)
+	if err != nil {
+		return nil, err
+	}
+
+	// build ids follow the same rules as system labels
+	_, err = checkStringMatches(assert.headers, "build-id", validSystemLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = checkDigest(assert.headers, "manifest-sha3-384", crypto.SHA3_384)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+	return &ImageManifest{
+		assertionBase: assert,
+		timestamp:     timestamp,
+	}, nil
+}
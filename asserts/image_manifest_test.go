@@ -0,0 +1,96 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+type imageManifestSuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+var _ = Suite(&imageManifestSuite{})
+
+func (ims *imageManifestSuite) SetUpSuite(c *C) {
+	ims.ts = time.Now().Truncate(time.Second).UTC()
+	ims.tsLine = "timestamp: " + ims.ts.Format(time.RFC3339) + "\n"
+}
+
+const imageManifestExample = `type: image-manifest
+authority-id: brand-id1
+series: 16
+brand-id: brand-id1
+model: baz-3000
+build-id: 20241017
+manifest-sha3-384: KPIl7M4vQ9d4AUjkoU41TGAwtOMLc_bWUCeW8AvdRWD4_xcP60Oo4ABsFNo6BtXj
+` + "TSLINE" +
+	"body-length: 0\n" +
+	"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij" +
+	"\n\n" +
+	"AXNpZw=="
+
+func (ims *imageManifestSuite) TestDecodeOK(c *C) {
+	encoded := strings.Replace(imageManifestExample, "TSLINE", ims.tsLine, 1)
+
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.ImageManifestType)
+	m := a.(*asserts.ImageManifest)
+	c.Check(m.AuthorityID(), Equals, "brand-id1")
+	c.Check(m.Timestamp(), Equals, ims.ts)
+	c.Check(m.Series(), Equals, "16")
+	c.Check(m.BrandID(), Equals, "brand-id1")
+	c.Check(m.Model(), Equals, "baz-3000")
+	c.Check(m.BuildID(), Equals, "20241017")
+	c.Check(m.ManifestSHA3_384(), Equals, "KPIl7M4vQ9d4AUjkoU41TGAwtOMLc_bWUCeW8AvdRWD4_xcP60Oo4ABsFNo6BtXj")
+}
+
+func (ims *imageManifestSuite) TestDecodeInvalid(c *C) {
+	const errPrefix = "assertion image-manifest: "
+
+	encoded := strings.Replace(imageManifestExample, "TSLINE", ims.tsLine, 1)
+
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"series: 16\n", "", `"series" header is mandatory`},
+		{"brand-id: brand-id1\n", "", `"brand-id" header is mandatory`},
+		{"brand-id: brand-id1\n", "brand-id: brand-id2\n", `authority-id and brand-id must match, image-manifest assertions are expected to be signed by the brand: "brand-id1" != "brand-id2"`},
+		{"model: baz-3000\n", "", `"model" header is mandatory`},
+		{"model: baz-3000\n", "model: Baz-3000\n", `"model" header cannot contain uppercase letters`},
+		{"build-id: 20241017\n", "", `"build-id" header is mandatory`},
+		{"build-id: 20241017\n", "build-id: -x\n", `"build-id" header contains invalid characters: "-x"`},
+		{"build-id: 20241017\n", "build-id: 2024_10\n", `"build-id" header contains invalid characters: "2024_10"`},
+		{"manifest-sha3-384: KPIl7M4vQ9d4AUjkoU41TGAwtOMLc_bWUCeW8AvdRWD4_xcP60Oo4ABsFNo6BtXj\n", "", `"manifest-sha3-384" header is mandatory`},
+		{"manifest-sha3-384: KPIl7M4vQ9d4AUjkoU41TGAwtOMLc_bWUCeW8AvdRWD4_xcP60Oo4ABsFNo6BtXj\n", "manifest-sha3-384: abc\n", `"manifest-sha3-384" header does not have the expected bit length: 16`},
+		{ims.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(encoded, test.original, test.invalid, 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, errPrefix+test.expectedErr, Commentf("%s", test.invalid))
+	}
+}
@@ -1018,6 +1018,30 @@ func (p *Pool) ClearGroups() error {
 	return nil
 }
 
+// ClearResolved clears the pool like ClearGroups and also drops the
+// resolved assertions it holds, remembering the ones of groups not in
+// error as unchanged. It is meant to be used after CommitTo has added
+// them to the ground database of the pool, to bound the memory used
+// when resolving assertions for many groups over several rounds.
+// Group errors must be queried before calling it otherwise they are
+// lost.
+func (p *Pool) ClearResolved() error {
+	if len(p.unresolved) != 0 || len(p.prerequisites) != 0 {
+		return fmt.Errorf("internal error: trying to clear resolved assertions of asserts.Pool with pending unresolved or prerequisites")
+	}
+
+	for _, gRec := range p.groups {
+		if gRec.hasErr() {
+			continue
+		}
+		for i := range gRec.resolved {
+			p.unchanged[gRec.resolved[i].Unique()] = true
+		}
+	}
+	p.bs = NewMemoryBackstore()
+	return p.ClearGroups()
+}
+
 // Backstore returns the memory backstore of this pool.
 func (p *Pool) Backstore() Backstore {
 	return p.bs
@@ -1717,6 +1717,81 @@ func (s *poolSuite) TestPoolReuseWithClearGroupsAndUnchanged(c *C) {
 	c.Check(toResolveSeq, HasLen, 0)
 }
 
+func (s *poolSuite) TestPoolReuseWithClearResolved(c *C) {
+	assertstest.AddMany(s.db, s.hub.StoreAccountKey(""))
+	assertstest.AddMany(s.db, s.dev1Acct, s.decl1)
+	assertstest.AddMany(s.db, s.dev2Acct, s.decl2)
+
+	pool := asserts.NewPool(s.db, 64)
+
+	err := pool.AddToUpdate(s.decl1.Ref(), "for_one") // group num: 0
+	c.Assert(err, IsNil)
+
+	_, _, err = pool.ToResolve()
+	c.Assert(err, IsNil)
+
+	ok, err := pool.Add(s.decl1_1, asserts.MakePoolGrouping(0))
+	c.Assert(err, IsNil)
+	c.Assert(ok, Equals, true)
+
+	toResolve, toResolveSeq, err := pool.ToResolve()
+	c.Assert(err, IsNil)
+	c.Check(toResolve, HasLen, 0)
+	c.Check(toResolveSeq, HasLen, 0)
+
+	err = pool.CommitTo(s.db)
+	c.Assert(err, IsNil)
+	c.Assert(pool.Err("for_one"), IsNil)
+
+	err = pool.ClearResolved()
+	c.Assert(err, IsNil)
+
+	// the resolved assertions are not held anymore
+	_, err = pool.Backstore().Get(s.decl1.Type(), s.decl1.Ref().PrimaryKey, s.decl1.Type().MaxSupportedFormat())
+	c.Check(asserts.IsNotFound(err), Equals, true)
+
+	// but are remembered as unchanged, as is the store key
+	err = pool.AddToUpdate(s.decl1.Ref(), "for_one_again") // group num: 0 again
+	c.Assert(err, IsNil)
+	err = pool.AddToUpdate(s.decl2.Ref(), "for_two") // group num: 1
+	c.Assert(err, IsNil)
+
+	toResolve, toResolveSeq, err = pool.ToResolve()
+	c.Assert(err, IsNil)
+	sortToResolve(toResolve)
+	c.Check(toResolve, DeepEquals, map[asserts.Grouping][]*asserts.AtRevision{
+		asserts.MakePoolGrouping(1): {s.dev2Acct.At(), s.decl2.At()},
+	})
+	c.Check(toResolveSeq, HasLen, 0)
+
+	toResolve, _, err = pool.ToResolve()
+	c.Assert(err, IsNil)
+	c.Check(toResolve, HasLen, 0)
+
+	err = pool.CommitTo(s.db)
+	c.Assert(err, IsNil)
+	c.Check(pool.Err("for_one_again"), IsNil)
+	c.Check(pool.Err("for_two"), IsNil)
+
+	a, err := s.decl1.Ref().Resolve(s.db.Find)
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, s.decl1_1.Revision())
+}
+
+func (s *poolSuite) TestClearResolvedPendingUnresolved(c *C) {
+	assertstest.AddMany(s.db, s.hub.StoreAccountKey(""), s.dev1Acct, s.decl1)
+
+	pool := asserts.NewPool(s.db, 64)
+
+	err := pool.AddToUpdate(s.decl1.Ref(), "for_one")
+	c.Assert(err, IsNil)
+	_, _, err = pool.ToResolve()
+	c.Assert(err, IsNil)
+
+	err = pool.ClearResolved()
+	c.Check(err, ErrorMatches, `internal error: trying to clear resolved assertions of asserts.Pool with pending unresolved or prerequisites`)
+}
+
 func (s *poolSuite) TestBackstore(c *C) {
 	assertstest.AddMany(s.db, s.hub.StoreAccountKey(""), s.dev1Acct)
 	pool := asserts.NewPool(s.db, 64)
@@ -21,6 +21,7 @@ package asserts
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
@@ -30,7 +31,9 @@ import (
 
 // Repair holds an repair assertion which allows running repair
 // code to fixup broken systems. It can be limited by series and models, as well
-// as by bases and modes.
+// as by bases and modes, and to cohorts of devices by serials and model
+// grades. It can be rolled out to a growing share of the devices, which
+// can report the results of running it.
 type Repair struct {
 	assertionBase
 
@@ -41,6 +44,13 @@ type Repair struct {
 	modes []string
 	bases []string
 
+	serials []string
+	grades  []string
+
+	rolloutPercentage int
+
+	reportURL string
+
 	id int
 
 	disabled  bool
@@ -101,6 +111,32 @@ func (r *Repair) Models() []string {
 	return r.models
 }
 
+// Serials returns the device serials this assertion is valid for. Each
+// element is either a serial, a serial prefix ending with "*" or an
+// inclusive range of serials written as "first..last", where shorter
+// serials sort before longer ones.
+func (r *Repair) Serials() []string {
+	return r.serials
+}
+
+// Grades returns the model grades this assertion is valid for.
+func (r *Repair) Grades() []string {
+	return r.grades
+}
+
+// RolloutPercentage returns the share of the otherwise targeted devices the
+// repair is rolled out to, between 0 and 100. A repair is rolled out in
+// stages by issuing new revisions of it with a growing percentage.
+func (r *Repair) RolloutPercentage() int {
+	return r.rolloutPercentage
+}
+
+// ReportURL returns the URL the results of running the repair are reported
+// to, if any.
+func (r *Repair) ReportURL() string {
+	return r.reportURL
+}
+
 // Disabled returns true if the repair has been disabled.
 func (r *Repair) Disabled() bool {
 	return r.disabled
@@ -194,6 +230,45 @@ func assembleRepair(assert assertionBase) (Assertion, error) {
 		}
 	}
 
+	serials, err := checkStringList(assert.headers, "serials")
+	if err != nil {
+		return nil, err
+	}
+	for _, serial := range serials {
+		if err := validateRepairSerialPattern(serial); err != nil {
+			return nil, err
+		}
+	}
+
+	grades, err := checkStringList(assert.headers, "grades")
+	if err != nil {
+		return nil, err
+	}
+	for _, grade := range grades {
+		if !strutil.ListContains(validModelGrades, grade) {
+			return nil, fmt.Errorf("header \"grades\" contains an invalid element: %q (valid values are %s)", grade, strings.Join(validModelGrades, ", "))
+		}
+	}
+
+	rolloutPercentage, err := checkIntWithDefault(assert.headers, "rollout-percentage", 100)
+	if err != nil {
+		return nil, err
+	}
+	if rolloutPercentage < 0 || rolloutPercentage > 100 {
+		return nil, fmt.Errorf(`"rollout-percentage" header must be between 0 and 100: %d`, rolloutPercentage)
+	}
+
+	reportURL, err := checkOptionalString(assert.headers, "report-url")
+	if err != nil {
+		return nil, err
+	}
+	if reportURL != "" {
+		u, err := url.Parse(reportURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf(`"report-url" header must be an http or https URL: %q`, reportURL)
+		}
+	}
+
 	disabled, err := checkOptionalBool(assert.headers, "disabled")
 	if err != nil {
 		return nil, err
@@ -211,8 +286,26 @@ func assembleRepair(assert assertionBase) (Assertion, error) {
 		models:        models,
 		modes:         modes,
 		bases:         bases,
+		serials:       serials,
+		grades:        grades,
 		id:            repairID,
 		disabled:      disabled,
 		timestamp:     timestamp,
+
+		rolloutPercentage: rolloutPercentage,
+		reportURL:         reportURL,
 	}, nil
 }
+
+func validateRepairSerialPattern(serial string) error {
+	if first, last := strings.Index(serial, ".."), strings.LastIndex(serial, ".."); first >= 0 {
+		if first != last || first == 0 || last == len(serial)-2 || strings.Contains(serial, "*") {
+			return fmt.Errorf("header \"serials\" contains an invalid range: %q", serial)
+		}
+		return nil
+	}
+	if serial == "" || serial == "*" || strings.Contains(strings.TrimSuffix(serial, "*"), "*") {
+		return fmt.Errorf("header \"serials\" contains an invalid element: %q", serial)
+	}
+	return nil
+}
@@ -320,6 +320,57 @@ func (s *repairSuite) TestDecodeModesAndBases(c *C) {
 	}
 }
 
+func (s *repairSuite) TestDecodeCohortsOK(c *C) {
+	cohortLines := "serials:\n  - 1000..1999\n  - abc*\n  - 42\n" +
+		"grades:\n  - dangerous\n  - signed\n" +
+		"rollout-percentage: 25\n" +
+		"report-url: https://fleet.example.com/repairs\n"
+	repairStr := strings.Replace(s.repairStr, s.modelsLine, s.modelsLine+cohortLines, 1)
+
+	a, err := asserts.Decode([]byte(repairStr))
+	c.Assert(err, IsNil)
+	repair := a.(*asserts.Repair)
+	c.Check(repair.Serials(), DeepEquals, []string{"1000..1999", "abc*", "42"})
+	c.Check(repair.Grades(), DeepEquals, []string{"dangerous", "signed"})
+	c.Check(repair.RolloutPercentage(), Equals, 25)
+	c.Check(repair.ReportURL(), Equals, "https://fleet.example.com/repairs")
+}
+
+func (s *repairSuite) TestDecodeCohortsDefaults(c *C) {
+	a, err := asserts.Decode([]byte(s.repairStr))
+	c.Assert(err, IsNil)
+	repair := a.(*asserts.Repair)
+	c.Check(repair.Serials(), HasLen, 0)
+	c.Check(repair.Grades(), HasLen, 0)
+	c.Check(repair.RolloutPercentage(), Equals, 100)
+	c.Check(repair.ReportURL(), Equals, "")
+}
+
+func (s *repairSuite) TestDecodeInvalidCohorts(c *C) {
+	invalidTests := []struct{ cohortLines, expectedErr string }{
+		{"serials: foo\n", `"serials" header must be a list of strings`},
+		{"serials:\n  - *\n", `header "serials" contains an invalid element: "\*"`},
+		{"serials:\n  - a*b*\n", `header "serials" contains an invalid element: "a\*b\*"`},
+		{"serials:\n  - 10..\n", `header "serials" contains an invalid range: "10.."`},
+		{"serials:\n  - ..10\n", `header "serials" contains an invalid range: "..10"`},
+		{"serials:\n  - 1..2..3\n", `header "serials" contains an invalid range: "1..2..3"`},
+		{"serials:\n  - 1*..2\n", `header "serials" contains an invalid range: "1\*..2"`},
+		{"grades: foo\n", `"grades" header must be a list of strings`},
+		{"grades:\n  - foo\n", `header "grades" contains an invalid element: "foo" \(valid values are .*\)`},
+		{"rollout-percentage: foo\n", `"rollout-percentage" header is not an integer: foo`},
+		{"rollout-percentage: 101\n", `"rollout-percentage" header must be between 0 and 100: 101`},
+		{"report-url:\n  - foo\n", `"report-url" header must be a string`},
+		{"report-url: fleet.example.com\n", `"report-url" header must be an http or https URL: "fleet.example.com"`},
+		{"report-url: ftp://fleet.example.com\n", `"report-url" header must be an http or https URL: "ftp://fleet.example.com"`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(s.repairStr, s.modelsLine, s.modelsLine+test.cohortLines, 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, repairErrPrefix+test.expectedErr)
+	}
+}
+
 func (s *repairSuite) TestDecodeInvalid(c *C) {
 	invalidTests := []struct{ original, invalid, expectedErr string }{
 		{"series:\n  - 16\n", "series: \n", `"series" header must be a list of strings`},
@@ -0,0 +1,115 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var validSyscallName = regexp.MustCompile("^[a-z_][a-z0-9_]*$")
+
+// SeccompDeny holds a seccomp-deny assertion, which is a statement by a
+// brand denying a set of system calls to a snap on the devices of the brand,
+// optionally restricted to a set of models. The system calls are removed
+// from the seccomp profile of the snap, thus the assertion can only tighten
+// its confinement.
+type SeccompDeny struct {
+	assertionBase
+	models    []string
+	syscalls  []string
+	timestamp time.Time
+}
+
+// BrandID returns the brand identifier. Same as the authority id.
+func (sd *SeccompDeny) BrandID() string {
+	return sd.HeaderString("brand-id")
+}
+
+// SnapID returns the snap id of the snap the assertion applies to.
+func (sd *SeccompDeny) SnapID() string {
+	return sd.HeaderString("snap-id")
+}
+
+// Models returns the models of the brand the assertion is restricted to.
+// It returns nil if the assertion applies to all the models of the brand.
+func (sd *SeccompDeny) Models() []string {
+	return sd.models
+}
+
+// Syscalls returns the system calls denied to the snap.
+func (sd *SeccompDeny) Syscalls() []string {
+	return sd.syscalls
+}
+
+// Timestamp returns the time when the seccomp-deny assertion was issued.
+func (sd *SeccompDeny) Timestamp() time.Time {
+	return sd.timestamp
+}
+
+// AppliesTo returns whether the assertion applies to a device of the given
+// model.
+func (sd *SeccompDeny) AppliesTo(model *Model) bool {
+	if model.BrandID() != sd.BrandID() {
+		return false
+	}
+	return len(sd.models) == 0 || strutil.ListContains(sd.models, model.Model())
+}
+
+func assembleSeccompDeny(assert assertionBase) (Assertion, error) {
+	// authority must match the brand (signer is the brand)
+	err := checkAuthorityMatchesBrand(&assert)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = checkStringMatches(assert.headers, "snap-id", naming.ValidSnapID)
+	if err != nil {
+		return nil, err
+	}
+
+	models, err := checkStringListMatches(assert.headers, "models", validModel)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := assert.headers["syscalls"]; !ok {
+		return nil, fmt.Errorf(`"syscalls" header is mandatory`)
+	}
+	syscalls, err := checkStringListMatches(assert.headers, "syscalls", validSyscallName)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+	return &SeccompDeny{
+		assertionBase: assert,
+		models:        models,
+		syscalls:      syscalls,
+		timestamp:     timestamp,
+	}, nil
+}
@@ -0,0 +1,143 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+)
+
+type seccompDenySuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+var _ = Suite(&seccompDenySuite{})
+
+func (sds *seccompDenySuite) SetUpSuite(c *C) {
+	sds.ts = time.Now().Truncate(time.Second).UTC()
+	sds.tsLine = "timestamp: " + sds.ts.Format(time.RFC3339) + "\n"
+}
+
+const seccompDenyExample = `type: seccomp-deny
+authority-id: brand-id1
+brand-id: brand-id1
+snap-id: bazlinuxidididididididididididid
+models:
+  - baz-3000
+  - baz-3100
+syscalls:
+  - io_uring_setup
+  - io_uring_enter
+  - io_uring_register
+` + "TSLINE" +
+	"body-length: 0\n" +
+	"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij" +
+	"\n\n" +
+	"AXNpZw=="
+
+func (sds *seccompDenySuite) TestDecodeOK(c *C) {
+	encoded := strings.Replace(seccompDenyExample, "TSLINE", sds.tsLine, 1)
+
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.SeccompDenyType)
+	sd := a.(*asserts.SeccompDeny)
+	c.Check(sd.AuthorityID(), Equals, "brand-id1")
+	c.Check(sd.Timestamp(), Equals, sds.ts)
+	c.Check(sd.BrandID(), Equals, "brand-id1")
+	c.Check(sd.SnapID(), Equals, "bazlinuxidididididididididididid")
+	c.Check(sd.Models(), DeepEquals, []string{"baz-3000", "baz-3100"})
+	c.Check(sd.Syscalls(), DeepEquals, []string{"io_uring_setup", "io_uring_enter", "io_uring_register"})
+}
+
+func (sds *seccompDenySuite) TestDecodeAllModels(c *C) {
+	encoded := strings.Replace(seccompDenyExample, "TSLINE", sds.tsLine, 1)
+	encoded = strings.Replace(encoded, "models:\n  - baz-3000\n  - baz-3100\n", "", 1)
+
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SeccompDeny).Models(), IsNil)
+}
+
+func (sds *seccompDenySuite) TestDecodeInvalid(c *C) {
+	const errPrefix = "assertion seccomp-deny: "
+
+	encoded := strings.Replace(seccompDenyExample, "TSLINE", sds.tsLine, 1)
+
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"brand-id: brand-id1\n", "", `"brand-id" header is mandatory`},
+		{"brand-id: brand-id1\n", "brand-id: \n", `"brand-id" header should not be empty`},
+		{"brand-id: brand-id1\n", "brand-id: brand-id2\n", `authority-id and brand-id must match, seccomp-deny assertions are expected to be signed by the brand: "brand-id1" != "brand-id2"`},
+		{"snap-id: bazlinuxidididididididididididid\n", "", `"snap-id" header is mandatory`},
+		{"snap-id: bazlinuxidididididididididididid\n", "snap-id: 2\n", `"snap-id" header contains invalid characters: "2"`},
+		{"models:\n  - baz-3000\n  - baz-3100\n", "models: baz-3000\n", `"models" header must be a list of strings`},
+		{"  - baz-3100\n", "  - -\n", `"models" header contains an invalid element: "-"`},
+		{"syscalls:\n  - io_uring_setup\n  - io_uring_enter\n  - io_uring_register\n", "", `"syscalls" header is mandatory`},
+		{"syscalls:\n  - io_uring_setup\n  - io_uring_enter\n  - io_uring_register\n", "syscalls: io_uring_setup\n", `"syscalls" header must be a list of strings`},
+		{"  - io_uring_enter\n", "  - io_uring_enter 1\n", `"syscalls" header contains an invalid element: "io_uring_enter 1"`},
+		{"  - io_uring_enter\n", "  - @complain\n", `"syscalls" header contains an invalid element: "@complain"`},
+		{sds.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(encoded, test.original, test.invalid, 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, errPrefix+test.expectedErr)
+	}
+}
+
+func (sds *seccompDenySuite) TestAppliesTo(c *C) {
+	encoded := strings.Replace(seccompDenyExample, "TSLINE", sds.tsLine, 1)
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	sd := a.(*asserts.SeccompDeny)
+
+	mockModel := func(brandID, model string) *asserts.Model {
+		return assertstest.FakeAssertion(map[string]interface{}{
+			"type":         "model",
+			"authority-id": brandID,
+			"series":       "16",
+			"brand-id":     brandID,
+			"model":        model,
+			"architecture": "amd64",
+			"gadget":       "gadget",
+			"kernel":       "kernel",
+			"timestamp":    "2022-01-01T00:00:00Z",
+		}).(*asserts.Model)
+	}
+
+	c.Check(sd.AppliesTo(mockModel("brand-id1", "baz-3000")), Equals, true)
+	c.Check(sd.AppliesTo(mockModel("brand-id1", "baz-3100")), Equals, true)
+	c.Check(sd.AppliesTo(mockModel("brand-id1", "baz-4000")), Equals, false)
+	c.Check(sd.AppliesTo(mockModel("brand-id2", "baz-3000")), Equals, false)
+
+	encoded = strings.Replace(encoded, "models:\n  - baz-3000\n  - baz-3100\n", "", 1)
+	a, err = asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	sd = a.(*asserts.SeccompDeny)
+	c.Check(sd.AppliesTo(mockModel("brand-id1", "baz-4000")), Equals, true)
+	c.Check(sd.AppliesTo(mockModel("brand-id2", "baz-3000")), Equals, false)
+}
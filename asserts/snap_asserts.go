@@ -45,6 +45,7 @@ type SnapDeclaration struct {
 	autoAliases         []string
 	aliases             map[string]string
 	revisionAuthorities []*RevisionAuthority
+	sandboxPolicy       bool
 	timestamp           time.Time
 }
 
@@ -99,6 +100,13 @@ func (snapdcl *SnapDeclaration) Aliases() map[string]string {
 	return snapdcl.aliases
 }
 
+// SandboxPolicy returns whether the snap, a base, is allowed to ship
+// sandbox policy fragments adjusting the default templates of the snaps
+// using it.
+func (snapdcl *SnapDeclaration) SandboxPolicy() bool {
+	return snapdcl.sandboxPolicy
+}
+
 // RevisionAuthority return any revision authority entries matching the given
 // provenance.
 func (snapdcl *SnapDeclaration) RevisionAuthority(provenance string) []*RevisionAuthority {
@@ -331,6 +339,11 @@ func assembleSnapDeclaration(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	sandboxPolicy, err := checkOptionalBool(assert.headers, "sandbox-policy")
+	if err != nil {
+		return nil, err
+	}
+
 	var ras []*RevisionAuthority
 
 	ra, ok := assert.headers["revision-authority"]
@@ -402,6 +415,7 @@ func assembleSnapDeclaration(assert assertionBase) (Assertion, error) {
 		autoAliases:         autoAliases,
 		aliases:             aliases,
 		revisionAuthorities: ras,
+		sandboxPolicy:       sandboxPolicy,
 		timestamp:           timestamp,
 	}, nil
 }
@@ -107,6 +107,29 @@ func (sds *snapDeclSuite) TestDecodeOK(c *C) {
 		"CMD.4": "cmd-4",
 	})
 	c.Check(snapDecl.RevisionAuthority(""), IsNil)
+	c.Check(snapDecl.SandboxPolicy(), Equals, false)
+}
+
+func (sds *snapDeclSuite) TestDecodeOKWithSandboxPolicy(c *C) {
+	encoded := "type: snap-declaration\n" +
+		"authority-id: canonical\n" +
+		"series: 16\n" +
+		"snap-id: snap-id-1\n" +
+		"snap-name: first\n" +
+		"publisher-id: dev-id1\n" +
+		"sandbox-policy: true\n" +
+		sds.tsLine +
+		"body-length: 0\n" +
+		"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij" +
+		"\n\n" +
+		"AXNpZw=="
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	snapDecl := a.(*asserts.SnapDeclaration)
+	c.Check(snapDecl.SandboxPolicy(), Equals, true)
+
+	_, err = asserts.Decode([]byte(strings.Replace(encoded, "sandbox-policy: true\n", "sandbox-policy: yes\n", 1)))
+	c.Check(err, ErrorMatches, snapDeclErrPrefix+`"sandbox-policy" header must be 'true' or 'false'`)
 }
 
 func (sds *snapDeclSuite) TestDecodeOKWithRevisionAuthority(c *C) {
@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// System banks are complete, redundant copies of a UC16/18 system laid out
+// by prepare-image for devices requiring full A/B system redundancy. Switching
+// between them follows the same protocol as the one used for the kernel and
+// base snaps:
+//
+// - snap_bank names the bank known to boot, snap_banks lists all of them
+// - SetTrySystemBank sets snap_try_bank and snap_bank_mode=try
+// - the bootloader boots snap_try_bank and sets snap_bank_mode=trying
+// - on a successful boot MarkSystemBankSuccessful makes snap_try_bank the
+//   current bank and clears the other variables
+// - on a failing boot the bootloader sees snap_bank_mode=trying, resets it
+//   and boots snap_bank again
+//
+// The variables are kept in a single bootloader environment, the one of the
+// boot partition shared by the banks, while the environment of each bank
+// only tracks the kernel and base snaps of the bank.
+
+var errNoSystemBanks = errors.New("system is not using system banks")
+
+func systemBanksBootloader(dev snap.Device) (bootloader.Bootloader, error) {
+	if dev.HasModeenv() {
+		return nil, fmt.Errorf("system banks are only supported on UC16/18")
+	}
+	return bootloader.Find("", nil)
+}
+
+func bankVars(bl bootloader.Bootloader) (map[string]string, error) {
+	m, err := bl.GetBootVars("snap_banks", "snap_bank", "snap_try_bank", "snap_bank_mode")
+	if err != nil {
+		return nil, err
+	}
+	if m["snap_banks"] == "" || m["snap_bank"] == "" {
+		return nil, errNoSystemBanks
+	}
+	return m, nil
+}
+
+// MakeSystemBankSelector sets up the boot partition shared by the system
+// banks of a dual-bank UC16/18 image at rootdir, with the bootloader
+// configuration from the gadget and the variables selecting the given bank
+// to boot.
+func MakeSystemBankSelector(rootdir, unpackedGadgetDir string, banks []string, bank string) error {
+	if !strutil.ListContains(banks, bank) {
+		return fmt.Errorf("internal error: unknown system bank %q", bank)
+	}
+	opts := &bootloader.Options{
+		PrepareImageTime: true,
+	}
+	if err := bootloader.InstallBootConfig(unpackedGadgetDir, rootdir, opts); err != nil {
+		return err
+	}
+	bl, err := bootloader.Find(rootdir, opts)
+	if err != nil {
+		return fmt.Errorf("cannot set system bank boot variables: %v", err)
+	}
+	return bl.SetBootVars(map[string]string{
+		"snap_banks":     strings.Join(banks, ","),
+		"snap_bank":      bank,
+		"snap_try_bank":  "",
+		"snap_bank_mode": DefaultStatus,
+	})
+}
+
+// SystemBank returns the name of the system bank known to boot.
+func SystemBank(dev snap.Device) (string, error) {
+	bl, err := systemBanksBootloader(dev)
+	if err != nil {
+		return "", err
+	}
+	m, err := bankVars(bl)
+	if err != nil {
+		return "", fmt.Errorf("cannot get system bank: %v", err)
+	}
+	return m["snap_bank"], nil
+}
+
+// SetTrySystemBank requests the next boot to try the given system bank.
+func SetTrySystemBank(dev snap.Device, bank string) error {
+	bl, err := systemBanksBootloader(dev)
+	if err != nil {
+		return err
+	}
+	m, err := bankVars(bl)
+	if err != nil {
+		return fmt.Errorf("cannot set try system bank: %v", err)
+	}
+	if !strutil.ListContains(strings.Split(m["snap_banks"], ","), bank) {
+		return fmt.Errorf("cannot set try system bank: unknown bank %q", bank)
+	}
+	if bank == m["snap_bank"] {
+		return fmt.Errorf("cannot set try system bank: bank %q is already the current one", bank)
+	}
+	return bl.SetBootVars(map[string]string{
+		"snap_try_bank":  bank,
+		"snap_bank_mode": TryStatus,
+	})
+}
+
+// MarkSystemBankSuccessful makes the system bank being tried the current one
+// if it booted successfully. A try bank that was not picked up by the
+// bootloader is dropped.
+func MarkSystemBankSuccessful(dev snap.Device) error {
+	bl, err := systemBanksBootloader(dev)
+	if err != nil {
+		return err
+	}
+	m, err := bankVars(bl)
+	if err == errNoSystemBanks {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot mark system bank successful: %v", err)
+	}
+	switch m["snap_bank_mode"] {
+	case DefaultStatus:
+		if m["snap_try_bank"] == "" {
+			return nil
+		}
+		// the bootloader went back to the current bank
+	case TryingStatus:
+		if m["snap_try_bank"] == "" {
+			return fmt.Errorf("cannot mark system bank successful: try bank is unset")
+		}
+		m["snap_bank"] = m["snap_try_bank"]
+	}
+	return bl.SetBootVars(map[string]string{
+		"snap_bank":      m["snap_bank"],
+		"snap_try_bank":  "",
+		"snap_bank_mode": DefaultStatus,
+	})
+}
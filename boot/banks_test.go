@@ -0,0 +1,191 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/boot/boottest"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+	"github.com/snapcore/snapd/bootloader/grubenv"
+)
+
+type systemBanksSuite struct {
+	baseBootenvSuite
+
+	bootloader *bootloadertest.MockBootloader
+}
+
+var _ = Suite(&systemBanksSuite{})
+
+func (s *systemBanksSuite) SetUpTest(c *C) {
+	s.baseBootenvSuite.SetUpTest(c)
+
+	s.bootloader = bootloadertest.Mock("mock", c.MkDir())
+	s.forceBootloader(s.bootloader)
+}
+
+func (s *systemBanksSuite) setBanks(c *C) {
+	err := s.bootloader.SetBootVars(map[string]string{
+		"snap_banks": "a,b",
+		"snap_bank":  "a",
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *systemBanksSuite) TestSystemBank(c *C) {
+	dev := boottest.MockDevice("core")
+
+	_, err := boot.SystemBank(dev)
+	c.Assert(err, ErrorMatches, "cannot get system bank: system is not using system banks")
+
+	s.setBanks(c)
+	bank, err := boot.SystemBank(dev)
+	c.Assert(err, IsNil)
+	c.Check(bank, Equals, "a")
+}
+
+func (s *systemBanksSuite) TestSystemBanksUC20Unsupported(c *C) {
+	dev := boottest.MockUC20Device("", nil)
+
+	_, err := boot.SystemBank(dev)
+	c.Assert(err, ErrorMatches, "system banks are only supported on UC16/18")
+	err = boot.SetTrySystemBank(dev, "b")
+	c.Assert(err, ErrorMatches, "system banks are only supported on UC16/18")
+	err = boot.MarkSystemBankSuccessful(dev)
+	c.Assert(err, ErrorMatches, "system banks are only supported on UC16/18")
+}
+
+func (s *systemBanksSuite) TestSetTrySystemBankErrors(c *C) {
+	dev := boottest.MockDevice("core")
+
+	err := boot.SetTrySystemBank(dev, "b")
+	c.Assert(err, ErrorMatches, "cannot set try system bank: system is not using system banks")
+
+	s.setBanks(c)
+	err = boot.SetTrySystemBank(dev, "c")
+	c.Assert(err, ErrorMatches, `cannot set try system bank: unknown bank "c"`)
+	err = boot.SetTrySystemBank(dev, "a")
+	c.Assert(err, ErrorMatches, `cannot set try system bank: bank "a" is already the current one`)
+}
+
+func (s *systemBanksSuite) TestSwitchSystemBankHappy(c *C) {
+	dev := boottest.MockDevice("core")
+	s.setBanks(c)
+
+	err := boot.SetTrySystemBank(dev, "b")
+	c.Assert(err, IsNil)
+	m, err := s.bootloader.GetBootVars("snap_bank", "snap_try_bank", "snap_bank_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_bank":      "a",
+		"snap_try_bank":  "b",
+		"snap_bank_mode": boot.TryStatus,
+	})
+
+	// the bootloader picks up the try bank
+	s.bootloader.SetBootVars(map[string]string{"snap_bank_mode": boot.TryingStatus})
+
+	err = boot.MarkSystemBankSuccessful(dev)
+	c.Assert(err, IsNil)
+	m, err = s.bootloader.GetBootVars("snap_bank", "snap_try_bank", "snap_bank_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_bank":      "b",
+		"snap_try_bank":  "",
+		"snap_bank_mode": boot.DefaultStatus,
+	})
+
+	bank, err := boot.SystemBank(dev)
+	c.Assert(err, IsNil)
+	c.Check(bank, Equals, "b")
+}
+
+func (s *systemBanksSuite) TestMarkSystemBankSuccessfulTryNotPickedUp(c *C) {
+	dev := boottest.MockDevice("core")
+	s.setBanks(c)
+
+	err := boot.SetTrySystemBank(dev, "b")
+	c.Assert(err, IsNil)
+
+	// the try bank was not booted, keep the current one
+	err = boot.MarkSystemBankSuccessful(dev)
+	c.Assert(err, IsNil)
+	m, err := s.bootloader.GetBootVars("snap_bank", "snap_try_bank", "snap_bank_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_bank":      "a",
+		"snap_try_bank":  "",
+		"snap_bank_mode": boot.DefaultStatus,
+	})
+}
+
+func (s *systemBanksSuite) TestMarkSystemBankSuccessfulNoBanks(c *C) {
+	dev := boottest.MockDevice("core")
+
+	err := boot.MarkSystemBankSuccessful(dev)
+	c.Assert(err, IsNil)
+	c.Check(s.bootloader.SetBootVarsCalls, Equals, 0)
+}
+
+func (s *systemBanksSuite) TestMarkBootSuccessfulMarksSystemBank(c *C) {
+	dev := boottest.MockDevice("some-snap")
+	s.setBanks(c)
+	s.bootloader.SetBootVars(map[string]string{
+		"snap_try_bank":  "b",
+		"snap_bank_mode": boot.TryingStatus,
+	})
+
+	err := boot.MarkBootSuccessful(dev)
+	c.Assert(err, IsNil)
+	bank, err := boot.SystemBank(dev)
+	c.Assert(err, IsNil)
+	c.Check(bank, Equals, "b")
+}
+
+func (s *systemBanksSuite) TestMakeSystemBankSelector(c *C) {
+	bootloader.Force(nil)
+
+	unpackedGadgetDir := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(unpackedGadgetDir, "grub.conf"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	rootdir := c.MkDir()
+	err = boot.MakeSystemBankSelector(rootdir, unpackedGadgetDir, []string{"a", "b"}, "a")
+	c.Assert(err, IsNil)
+
+	genv := grubenv.NewEnv(filepath.Join(rootdir, "boot/grub/grubenv"))
+	c.Assert(genv.Load(), IsNil)
+	c.Check(genv.Get("snap_banks"), Equals, "a,b")
+	c.Check(genv.Get("snap_bank"), Equals, "a")
+	c.Check(genv.Get("snap_try_bank"), Equals, "")
+	c.Check(genv.Get("snap_bank_mode"), Equals, "")
+	// the selector does not boot any kernel or base itself
+	c.Check(genv.Get("snap_kernel"), Equals, "")
+	c.Check(genv.Get("snap_core"), Equals, "")
+
+	err = boot.MakeSystemBankSelector(rootdir, unpackedGadgetDir, []string{"a", "b"}, "c")
+	c.Assert(err, ErrorMatches, `internal error: unknown system bank "c"`)
+}
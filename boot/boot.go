@@ -22,9 +22,13 @@ package boot
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 
 	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapfile"
 )
 
 const (
@@ -141,6 +145,22 @@ func Kernel(s snap.PlaceInfo, t snap.Type, dev snap.Device) BootKernel {
 	return trivial{}
 }
 
+func ephemeralDataMarker() string {
+	return filepath.Join(dirs.SnapBootstrapRunDir, "ephemeral-data")
+}
+
+// WritableDataIsEphemeral returns whether the writable data of the run
+// system is ephemeral for the current boot, that is nothing written to it,
+// including the modeenv, is persisted. Changes to the boot state, which is
+// persisted, must be refused then.
+func WritableDataIsEphemeral() bool {
+	return osutil.FileExists(ephemeralDataMarker())
+}
+
+// ErrEphemeralData is returned when the boot state cannot be changed as the
+// writable data is ephemeral for the current boot.
+var ErrEphemeralData = errors.New("writable data is ephemeral for this boot")
+
 // SnapTypeParticipatesInBoot returns whether a snap type participates in the
 // boot for a given device.
 func SnapTypeParticipatesInBoot(t snap.Type, dev snap.Device) bool {
@@ -394,6 +414,12 @@ func MarkBootSuccessful(dev snap.Device) error {
 			return fmt.Errorf(errPrefix, err)
 		}
 	}
+
+	if !dev.HasModeenv() {
+		if err := MarkSystemBankSuccessful(dev); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -469,7 +495,16 @@ func updateManagedBootConfigForBootloader(dev snap.Device, mode, gadgetSnapOrDir
 	if err != nil {
 		return false, err
 	}
-	return tbl.UpdateBootConfig()
+	var gadget snap.Container
+	if gadgetSnapOrDir != "" {
+		// the gadget may carry boot config assets superseding the
+		// built-in ones
+		gadget, err = snapfile.Open(gadgetSnapOrDir)
+		if err != nil {
+			return false, fmt.Errorf("cannot open gadget snap: %v", err)
+		}
+	}
+	return tbl.UpdateBootConfig(gadget)
 }
 
 // UpdateCommandLineForGadgetComponent handles the update of a gadget that
@@ -319,6 +319,12 @@ func setupUC20Bootenv(c *C, bl bootloader.Bootloader, opts *bootenv20Setup) (res
 		cleanups = append(cleanups, r)
 		// don't count any calls to SetBootVars made thus far
 		vbl.SetBootVarsCalls = 0
+	case *bootloadertest.MockBootCountingBootloader:
+		// for non-extracted, we need to use the bootenv to set the current kernels
+		r := setupUC20MockBootloaderEnv(c, bl, opts)
+		cleanups = append(cleanups, r)
+		// don't count any calls to SetBootVars made thus far
+		vbl.SetBootVarsCalls = 0
 	default:
 		c.Fatalf("unsupported bootloader %T", bl)
 	}
@@ -932,6 +938,40 @@ func (s *bootenv20Suite) TestCoreParticipant20SetNextSameKernelSnap(c *C) {
 	c.Assert(s.bootloader.SetBootVarsCalls, Equals, 0)
 }
 
+func (s *bootenv20Suite) TestCoreParticipant20SetNextEphemeralData(c *C) {
+	coreDev := boottest.MockUC20Device("", nil)
+	c.Assert(coreDev.HasModeenv(), Equals, true)
+
+	r := setupUC20Bootenv(
+		c,
+		s.bootloader,
+		s.normalDefaultState,
+	)
+	defer r()
+
+	c.Assert(boot.WritableDataIsEphemeral(), Equals, false)
+	c.Assert(boot.InitramfsRunModeMarkEphemeralData(), IsNil)
+	c.Assert(boot.WritableDataIsEphemeral(), Equals, true)
+
+	bootKern := boot.Participant(s.kern2, snap.TypeKernel, coreDev)
+	c.Assert(bootKern.IsTrivial(), Equals, false)
+
+	rebootRequired, err := bootKern.SetNextBoot(boot.NextBootContext{BootWithoutTry: false})
+	c.Assert(err, ErrorMatches, "cannot set next boot: writable data is ephemeral for this boot")
+	c.Assert(rebootRequired, Equals, boot.RebootInfo{RebootRequired: false})
+
+	// nothing was changed
+	_, enableKernelCalls := s.bootloader.GetRunKernelImageFunctionSnapCalls("EnableTryKernel")
+	c.Assert(enableKernelCalls, Equals, 0)
+	c.Assert(s.bootloader.SetBootVarsCalls, Equals, 0)
+	m2, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Assert(m2.CurrentKernels, DeepEquals, []string{s.kern1.Filename()})
+
+	err = boot.Kernel(s.kern2, snap.TypeKernel, coreDev).ExtractKernelAssets(nil)
+	c.Assert(err, ErrorMatches, "cannot extract kernel assets: writable data is ephemeral for this boot")
+}
+
 func (s *bootenv20EnvRefKernelSuite) TestCoreParticipant20SetNextSameKernelSnap(c *C) {
 	coreDev := boottest.MockUC20Device("", nil)
 	c.Assert(coreDev.HasModeenv(), Equals, true)
@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"github.com/snapcore/snapd/bootloader"
+)
+
+// armBootCounting adds to vars the variables arming the native boot
+// counting of the bootloader loading the kernel, if it supports it, such that
+// it falls back to the previous boot configuration when an update does not
+// boot successfully within the boot limit.
+func armBootCounting(bl bootloader.Bootloader, vars map[string]string) error {
+	bcbl, ok := bootloader.KernelLoader(bl).(bootloader.BootCountingBootloader)
+	if !ok {
+		return nil
+	}
+	m, err := bcbl.ArmBootCountingVars()
+	if err != nil {
+		return err
+	}
+	for k, v := range m {
+		vars[k] = v
+	}
+	return nil
+}
+
+// clearBootCounting adds to vars the variables stopping the native boot
+// counting of the bootloader loading the kernel, if it is armed. It returns
+// whether any variables were added.
+func clearBootCounting(bl bootloader.Bootloader, vars map[string]string) (bool, error) {
+	bcbl, ok := bootloader.KernelLoader(bl).(bootloader.BootCountingBootloader)
+	if !ok {
+		return false, nil
+	}
+	m, err := bcbl.ClearBootCountingVars()
+	if err != nil {
+		return false, err
+	}
+	for k, v := range m {
+		vars[k] = v
+	}
+	return len(m) > 0, nil
+}
@@ -0,0 +1,277 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/boot/boottest"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+	"github.com/snapcore/snapd/snap"
+)
+
+type bootCounting16Suite struct {
+	baseBootenvSuite
+
+	bootloader *bootloadertest.MockBootCountingBootloader
+}
+
+var _ = Suite(&bootCounting16Suite{})
+
+func (s *bootCounting16Suite) SetUpTest(c *C) {
+	s.baseBootenvSuite.SetUpTest(c)
+
+	s.bootloader = bootloadertest.Mock("mock", c.MkDir()).WithBootCounting()
+	s.forceBootloader(s.bootloader)
+}
+
+func (s *bootCounting16Suite) TestSetNextBootArmsBootCounting(c *C) {
+	coreDev := boottest.MockDevice("krnl")
+
+	info := &snap.Info{}
+	info.SnapType = snap.TypeKernel
+	info.RealName = "krnl"
+	info.Revision = snap.R(42)
+
+	bp := boot.NewCoreBootParticipant(info, snap.TypeKernel, coreDev)
+	_, err := bp.SetNextBoot(boot.NextBootContext{BootWithoutTry: false})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snap_kernel":       "",
+		"snap_try_kernel":   "krnl_42.snap",
+		"snap_mode":         boot.TryStatus,
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "1",
+	})
+}
+
+func (s *bootCounting16Suite) TestSetNextBootArmBootCountingError(c *C) {
+	coreDev := boottest.MockDevice("krnl")
+
+	info := &snap.Info{}
+	info.SnapType = snap.TypeKernel
+	info.RealName = "krnl"
+	info.Revision = snap.R(42)
+
+	s.bootloader.BootCountingErr = errors.New("boom")
+	bp := boot.NewCoreBootParticipant(info, snap.TypeKernel, coreDev)
+	_, err := bp.SetNextBoot(boot.NextBootContext{BootWithoutTry: false})
+	c.Assert(err, ErrorMatches, "cannot set next boot: boom")
+	c.Check(s.bootloader.SetBootVarsCalls, Equals, 0)
+}
+
+func (s *bootCounting16Suite) TestMarkBootSuccessfulClearsBootCounting(c *C) {
+	coreDev := boottest.MockDevice("some-snap")
+
+	s.bootloader.BootVars["snap_mode"] = boot.TryingStatus
+	s.bootloader.BootVars["snap_core"] = "os1"
+	s.bootloader.BootVars["snap_kernel"] = "k1"
+	s.bootloader.BootVars["snap_try_core"] = ""
+	s.bootloader.BootVars["snap_try_kernel"] = "k2"
+	s.bootloader.BootVars["bootcount"] = "1"
+	s.bootloader.BootVars["bootlimit"] = "3"
+	s.bootloader.BootVars["upgrade_available"] = "1"
+	err := boot.MarkBootSuccessful(coreDev)
+	c.Assert(err, IsNil)
+	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snap_mode":         boot.DefaultStatus,
+		"snap_try_kernel":   "",
+		"snap_try_core":     "",
+		"snap_core":         "os1",
+		"snap_kernel":       "k2",
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "0",
+	})
+}
+
+func (s *bootCounting16Suite) TestMarkBootSuccessfulAfterRollbackClearsBootCounting(c *C) {
+	coreDev := boottest.MockDevice("some-snap")
+
+	// the bootloader exceeded the boot limit and booted the previous
+	// kernel
+	s.bootloader.BootVars["snap_mode"] = boot.DefaultStatus
+	s.bootloader.BootVars["snap_core"] = "os1"
+	s.bootloader.BootVars["snap_kernel"] = "k1"
+	s.bootloader.BootVars["snap_try_core"] = ""
+	s.bootloader.BootVars["snap_try_kernel"] = "k2"
+	s.bootloader.BootVars["bootcount"] = "4"
+	s.bootloader.BootVars["bootlimit"] = "3"
+	s.bootloader.BootVars["upgrade_available"] = "1"
+	err := boot.MarkBootSuccessful(coreDev)
+	c.Assert(err, IsNil)
+	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snap_mode":         boot.DefaultStatus,
+		"snap_try_kernel":   "",
+		"snap_try_core":     "",
+		"snap_core":         "os1",
+		"snap_kernel":       "k1",
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "0",
+	})
+}
+
+type bootCounting20Suite struct {
+	baseBootenv20Suite
+
+	bootloader *bootloadertest.MockBootCountingBootloader
+}
+
+var _ = Suite(&bootCounting20Suite{})
+
+func (s *bootCounting20Suite) SetUpTest(c *C) {
+	s.baseBootenv20Suite.SetUpTest(c)
+
+	s.bootloader = bootloadertest.Mock("mock", c.MkDir()).WithBootCounting()
+	s.forceBootloader(s.bootloader)
+}
+
+func (s *bootCounting20Suite) TestSetNextBoot20ArmsBootCounting(c *C) {
+	coreDev := boottest.MockUC20Device("", nil)
+	c.Assert(coreDev.HasModeenv(), Equals, true)
+
+	r := setupUC20Bootenv(
+		c,
+		s.bootloader,
+		s.normalDefaultState,
+	)
+	defer r()
+
+	bs := boot.NewCoreBootParticipant(s.kern2, snap.TypeKernel, coreDev)
+	_, err := bs.SetNextBoot(boot.NextBootContext{BootWithoutTry: false})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"kernel_status":     boot.TryStatus,
+		"snap_try_kernel":   s.kern2.Filename(),
+		"snap_kernel":       s.kern1.Filename(),
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "1",
+	})
+}
+
+func (s *bootCounting20Suite) TestSetNextBoot20SameKernelDoesNotArmBootCounting(c *C) {
+	coreDev := boottest.MockUC20Device("", nil)
+	c.Assert(coreDev.HasModeenv(), Equals, true)
+
+	r := setupUC20Bootenv(
+		c,
+		s.bootloader,
+		s.normalDefaultState,
+	)
+	defer r()
+
+	bs := boot.NewCoreBootParticipant(s.kern1, snap.TypeKernel, coreDev)
+	_, err := bs.SetNextBoot(boot.NextBootContext{BootWithoutTry: false})
+	c.Assert(err, IsNil)
+
+	c.Check(s.bootloader.SetBootVarsCalls, Equals, 0)
+	c.Check(s.bootloader.BootVars["upgrade_available"], Equals, "")
+}
+
+func (s *bootCounting20Suite) TestMarkBootSuccessful20ClearsBootCounting(c *C) {
+	m := &boot.Modeenv{
+		Mode:           "run",
+		Base:           s.base1.Filename(),
+		CurrentKernels: []string{s.kern1.Filename(), s.kern2.Filename()},
+	}
+	r := setupUC20Bootenv(
+		c,
+		s.bootloader,
+		&bootenv20Setup{
+			modeenv:    m,
+			kern:       s.kern1,
+			tryKern:    s.kern2,
+			kernStatus: boot.TryingStatus,
+		},
+	)
+	defer r()
+	s.bootloader.BootVars["bootcount"] = "1"
+	s.bootloader.BootVars["bootlimit"] = "3"
+	s.bootloader.BootVars["upgrade_available"] = "1"
+
+	coreDev := boottest.MockUC20Device("", nil)
+	c.Assert(coreDev.HasModeenv(), Equals, true)
+
+	err := boot.MarkBootSuccessful(coreDev)
+	c.Assert(err, IsNil)
+
+	expected := map[string]string{
+		"kernel_status":     boot.DefaultStatus,
+		"snap_kernel":       s.kern2.Filename(),
+		"snap_try_kernel":   "",
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "0",
+	}
+	c.Assert(s.bootloader.BootVars, DeepEquals, expected)
+
+	// do it again, nothing to clear anymore
+	s.bootloader.SetBootVarsCalls = 0
+	err = boot.MarkBootSuccessful(coreDev)
+	c.Assert(err, IsNil)
+	c.Assert(s.bootloader.BootVars, DeepEquals, expected)
+	c.Check(s.bootloader.SetBootVarsCalls, Equals, 0)
+}
+
+func (s *bootCounting20Suite) TestMarkBootSuccessful20AfterRollbackClearsBootCounting(c *C) {
+	// the bootloader exceeded the boot limit and booted the previous kernel,
+	// which is otherwise marked successful already
+	m := &boot.Modeenv{
+		Mode:           "run",
+		Base:           s.base1.Filename(),
+		CurrentKernels: []string{s.kern1.Filename()},
+	}
+	r := setupUC20Bootenv(
+		c,
+		s.bootloader,
+		&bootenv20Setup{
+			modeenv:    m,
+			kern:       s.kern1,
+			kernStatus: boot.DefaultStatus,
+		},
+	)
+	defer r()
+	s.bootloader.BootVars["bootcount"] = "4"
+	s.bootloader.BootVars["bootlimit"] = "3"
+	s.bootloader.BootVars["upgrade_available"] = "1"
+
+	coreDev := boottest.MockUC20Device("", nil)
+	c.Assert(coreDev.HasModeenv(), Equals, true)
+
+	err := boot.MarkBootSuccessful(coreDev)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"kernel_status":     boot.DefaultStatus,
+		"snap_kernel":       s.kern1.Filename(),
+		"snap_try_kernel":   "",
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "0",
+	})
+}
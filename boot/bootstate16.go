@@ -125,6 +125,19 @@ func (u16 *bootStateUpdate16) commit() error {
 	for k, v := range u16.toCommit {
 		env[k] = v
 	}
+	// count the boots of the updated snaps natively if the bootloader
+	// supports it, and stop counting them once they booted successfully or
+	// were rolled back
+	switch env["snap_mode"] {
+	case TryStatus:
+		if err := armBootCounting(u16.bl, env); err != nil {
+			return err
+		}
+	case DefaultStatus:
+		if _, err := clearBootCounting(u16.bl, env); err != nil {
+			return err
+		}
+	}
 	return u16.bl.SetBootVars(env)
 }
 
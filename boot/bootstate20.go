@@ -242,7 +242,7 @@ func (ks20 *bootState20Kernel) loadBootenv() error {
 	if err != nil {
 		return err
 	}
-	ebl, ok := bl.(bootloader.ExtractedRunKernelImageBootloader)
+	ebl, ok := bootloader.KernelLoader(bl).(bootloader.ExtractedRunKernelImageBootloader)
 	if ok {
 		// use the new 20-style ExtractedRunKernelImage implementation
 		ks20.bks = &extractedRunKernelImageBootloaderKernelState{ebl: ebl}
@@ -251,7 +251,7 @@ func (ks20 *bootState20Kernel) loadBootenv() error {
 		ks20.bks = &envRefExtractedKernelBootloaderKernelState{bl: bl}
 	}
 
-	rbl, ok := bl.(bootloader.RebootBootloader)
+	rbl, ok := bootloader.KernelLoader(bl).(bootloader.RebootBootloader)
 	if ok {
 		ks20.rbl = rbl
 	}
@@ -296,6 +296,14 @@ func (envbks *envRefExtractedKernelBootloaderKernelState) markSuccessfulKernel(s
 		envbks.toCommit["snap_try_kernel"] = ""
 	}
 
+	// stop counting boots natively, the kernel booted successfully or was
+	// rolled back by the bootloader
+	cleared, err := clearBootCounting(envbks.bl, envbks.toCommit)
+	if err != nil {
+		return err
+	}
+	envChanged = envChanged || cleared
+
 	if envChanged {
 		return envbks.bl.SetBootVars(envbks.toCommit)
 	}
@@ -307,6 +315,14 @@ func (envbks *envRefExtractedKernelBootloaderKernelState) setNextKernel(sn snap.
 	envbks.toCommit["kernel_status"] = status
 	bootenvChanged := envbks.commonStateCommitUpdate(sn, "snap_try_kernel")
 
+	// count the boots of the try kernel natively if the bootloader
+	// supports it
+	if bootenvChanged && status == TryStatus {
+		if err := armBootCounting(envbks.bl, envbks.toCommit); err != nil {
+			return err
+		}
+	}
+
 	if bootenvChanged {
 		return envbks.bl.SetBootVars(envbks.toCommit)
 	}
@@ -318,6 +334,12 @@ func (envbks *envRefExtractedKernelBootloaderKernelState) setNextKernelNoTry(sn
 	envbks.toCommit["kernel_status"] = ""
 	bootenvChanged := envbks.commonStateCommitUpdate(sn, "snap_kernel")
 
+	cleared, err := clearBootCounting(envbks.bl, envbks.toCommit)
+	if err != nil {
+		return err
+	}
+	bootenvChanged = bootenvChanged || cleared
+
 	if bootenvChanged {
 		return envbks.bl.SetBootVars(envbks.toCommit)
 	}
@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -177,6 +178,12 @@ func composeCommandLine(currentOrCandidate int, mode, system, gadgetDirOrSnapPat
 		return "", err
 	}
 	if gadgetDirOrSnapPath != "" {
+		// the gadget may carry boot config assets superseding the
+		// built-in ones
+		components.Gadget, err = snapfile.Open(gadgetDirOrSnapPath)
+		if err != nil {
+			return "", fmt.Errorf("cannot open gadget snap: %v", err)
+		}
 		extraOrFull, full, err := gadget.KernelCommandLineFromGadget(gadgetDirOrSnapPath)
 		if err != nil && err != gadget.ErrNoKernelCommandline {
 			return "", fmt.Errorf("cannot use kernel command line from gadget: %v", err)
@@ -69,6 +69,8 @@ var (
 	RecoveryBootChainsForSystems    = recoveryBootChainsForSystems
 	SealKeyModelParams              = sealKeyModelParams
 
+	ResealKeyToModeenvForSignatureDbUpdate = resealKeyToModeenvForSignatureDbUpdate
+
 	BootVarsForTrustedCommandLineFromGadget = bootVarsForTrustedCommandLineFromGadget
 
 	WriteModelToUbuntuBoot = writeModelToUbuntuBoot
@@ -248,6 +250,12 @@ func MockResealKeyToModeenvUsingFDESetupHook(f func(string, *Modeenv, bool) erro
 	}
 }
 
+func MockResealKeyToModeenvForSignatureDbUpdate(f func(string, *Modeenv) error) (restore func()) {
+	restore = testutil.Backup(&resealKeyToModeenvForSignatureDbUpdate)
+	resealKeyToModeenvForSignatureDbUpdate = f
+	return restore
+}
+
 func MockAdditionalBootFlags(bootFlags []string) (restore func()) {
 	old := understoodBootFlags
 	understoodBootFlags = append(understoodBootFlags, bootFlags...)
@@ -285,6 +285,76 @@ func setNextBootFlags(dev snap.Device, rootDir string, flags []string) error {
 	return m.Write()
 }
 
+// removeBootFlag returns flags without the given flag.
+func removeBootFlag(flags []string, flag string) []string {
+	var res []string
+	for _, f := range flags {
+		if f != flag {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// InitramfsConsumeFactoryBoot accounts for the current boot into run mode in
+// the number of boots for which the factory boot flag is kept, see
+// Modeenv.FactoryBoots, and clears the flag for the next boots once that
+// number is exhausted. It is meant to be used only from the initramfs, after
+// the boot flags for the current boot were exposed.
+// Only to be used on UC20+ systems with recovery systems.
+func InitramfsConsumeFactoryBoot(rootfsDir string) error {
+	m, err := ReadModeenv(rootfsDir)
+	if err != nil {
+		return err
+	}
+	if m.FactoryBoots <= 0 {
+		// the flag, if set, is not limited to a number of boots
+		return nil
+	}
+
+	m.FactoryBoots--
+	if m.FactoryBoots == 0 {
+		m.BootFlags = removeBootFlag(m.BootFlags, "factory")
+	}
+	return m.Write()
+}
+
+// FactoryBoots returns the number of boots into run mode following the
+// current one for which the factory boot flag is still set.
+// Only to be used on UC20+ systems with recovery systems.
+func FactoryBoots(dev snap.Device) (int, error) {
+	if !dev.HasModeenv() {
+		return 0, errNotUC20
+	}
+
+	m, err := ReadModeenv("")
+	if err != nil {
+		return 0, err
+	}
+	if !strutil.ListContains(m.BootFlags, "factory") {
+		return 0, nil
+	}
+	return m.FactoryBoots, nil
+}
+
+// ClearFactoryBootFlag clears the factory boot flag, if set, for the next
+// boots of the run system whose modeenv is under rootdir. The boot flags of
+// the current boot are not affected.
+// Only to be used on UC20+ systems with recovery systems.
+func ClearFactoryBootFlag(rootdir string) error {
+	m, err := ReadModeenv(rootdir)
+	if err != nil {
+		return err
+	}
+	if !strutil.ListContains(m.BootFlags, "factory") && m.FactoryBoots == 0 {
+		return nil
+	}
+
+	m.BootFlags = removeBootFlag(m.BootFlags, "factory")
+	m.FactoryBoots = 0
+	return m.Write()
+}
+
 // HostUbuntuDataForMode returns a list of locations where the run
 // mode root filesystem is mounted for the given mode.
 // For run mode, it's "/run/mnt/data" and "/".
@@ -365,6 +365,87 @@ func (s *bootFlagsSuite) TestUserspaceBootFlagsUC20(c *C) {
 	}
 }
 
+func (s *bootFlagsSuite) TestInitramfsConsumeFactoryBoot(c *C) {
+	rootfsDir := filepath.Join(dirs.GlobalRootDir, "/run/mnt/data/system-data")
+
+	m := boot.Modeenv{
+		Mode:         boot.ModeRun,
+		BootFlags:    []string{"factory", "other-flag"},
+		FactoryBoots: 2,
+	}
+	err := m.WriteTo(rootfsDir)
+	c.Assert(err, IsNil)
+
+	err = boot.InitramfsConsumeFactoryBoot(rootfsDir)
+	c.Assert(err, IsNil)
+	m2, err := boot.ReadModeenv(rootfsDir)
+	c.Assert(err, IsNil)
+	c.Check(m2.BootFlags, DeepEquals, []string{"factory", "other-flag"})
+	c.Check(m2.FactoryBoots, Equals, 1)
+
+	// the factory flag is cleared for the following boots
+	err = boot.InitramfsConsumeFactoryBoot(rootfsDir)
+	c.Assert(err, IsNil)
+	m2, err = boot.ReadModeenv(rootfsDir)
+	c.Assert(err, IsNil)
+	c.Check(m2.BootFlags, DeepEquals, []string{"other-flag"})
+	c.Check(m2.FactoryBoots, Equals, 0)
+}
+
+func (s *bootFlagsSuite) TestInitramfsConsumeFactoryBootUnlimited(c *C) {
+	rootfsDir := filepath.Join(dirs.GlobalRootDir, "/run/mnt/data/system-data")
+
+	m := boot.Modeenv{
+		Mode:      boot.ModeRun,
+		BootFlags: []string{"factory"},
+	}
+	err := m.WriteTo(rootfsDir)
+	c.Assert(err, IsNil)
+
+	err = boot.InitramfsConsumeFactoryBoot(rootfsDir)
+	c.Assert(err, IsNil)
+	m2, err := boot.ReadModeenv(rootfsDir)
+	c.Assert(err, IsNil)
+	c.Check(m2.BootFlags, DeepEquals, []string{"factory"})
+}
+
+func (s *bootFlagsSuite) TestFactoryBootsAndClearFactoryBootFlag(c *C) {
+	uc20Dev := boottest.MockUC20Device("run", nil)
+
+	m := boot.Modeenv{
+		Mode:         boot.ModeRun,
+		BootFlags:    []string{"factory"},
+		FactoryBoots: 3,
+	}
+	err := m.WriteTo("")
+	c.Assert(err, IsNil)
+
+	n, err := boot.FactoryBoots(uc20Dev)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 3)
+
+	err = boot.ClearFactoryBootFlag("")
+	c.Assert(err, IsNil)
+
+	m2, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(m2.BootFlags, HasLen, 0)
+	c.Check(m2.FactoryBoots, Equals, 0)
+
+	n, err = boot.FactoryBoots(uc20Dev)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 0)
+
+	// clearing again is fine
+	err = boot.ClearFactoryBootFlag("")
+	c.Assert(err, IsNil)
+}
+
+func (s *bootFlagsSuite) TestFactoryBootsNotUC20(c *C) {
+	_, err := boot.FactoryBoots(boottest.MockDevice(""))
+	c.Assert(err, ErrorMatches, "cannot get boot flags on pre-UC20 device")
+}
+
 func (s *bootFlagsSuite) TestRunModeRootfs(c *C) {
 	uc20Dev := boottest.MockUC20Device("run", nil)
 	classicModesDev := boottest.MockClassicWithModesDevice("run", nil)
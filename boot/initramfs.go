@@ -20,10 +20,13 @@
 package boot
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
 	"time"
 
 	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap"
@@ -99,6 +102,33 @@ func EnsureNextBootToRunMode(systemLabel string) error {
 	return bl.SetBootVars(m)
 }
 
+// EnsureNextBootToRecoverMode will mark the bootenv of the recovery bootloader
+// such that the next boot is into recover mode of the recovery system that
+// was used last. It is meant to be used from the initramfs in run mode, once
+// ubuntu-seed is mounted, when the run system cannot be booted.
+func EnsureNextBootToRecoverMode() error {
+	opts := &bootloader.Options{
+		// setup the recovery bootloader
+		Role: bootloader.RoleRecovery,
+	}
+
+	bl, err := bootloader.Find(InitramfsUbuntuSeedDir, opts)
+	if err != nil {
+		return err
+	}
+
+	m, err := bl.GetBootVars("snapd_recovery_system")
+	if err != nil {
+		return err
+	}
+	if m["snapd_recovery_system"] == "" {
+		return fmt.Errorf("cannot determine the recovery system to use")
+	}
+	return bl.SetBootVars(map[string]string{
+		"snapd_recovery_mode": "recover",
+	})
+}
+
 // initramfsReboot triggers a reboot from the initramfs immediately
 var initramfsReboot = func() error {
 	if osutil.IsTestBinary() {
@@ -181,7 +211,7 @@ func InitramfsRunModeUpdateBootloaderVars() error {
 
 	bl, err := bootloader.Find(InitramfsUbuntuBootDir, blOpts)
 	if err == nil {
-		if nsb, ok := bl.(bootloader.NotScriptableBootloader); ok {
+		if nsb, ok := bootloader.KernelLoader(bl).(bootloader.NotScriptableBootloader); ok {
 			if err := updateNotScriptableBootloaderStatus(nsb); err != nil {
 				logger.Noticef("cannot update %s kernel status: %v", bl.Name(), err)
 				return err
@@ -191,3 +221,51 @@ func InitramfsRunModeUpdateBootloaderVars() error {
 
 	return nil
 }
+
+// InitramfsRunModeEphemeralData returns whether the writable data of the run
+// system should be kept in memory for the current boot, on top of the
+// ubuntu-data content, and not be persisted. This is requested by setting
+// snapd_ephemeral_data=1 in the run mode bootloader environment and reverted
+// by unsetting it or setting it to 0. It is meant to be used only from the
+// initramfs, once ubuntu-boot is mounted.
+func InitramfsRunModeEphemeralData() (bool, error) {
+	blOpts := &bootloader.Options{
+		Role:        bootloader.RoleRunMode,
+		NoSlashBoot: true,
+	}
+
+	bl, err := bootloader.Find(InitramfsUbuntuBootDir, blOpts)
+	if err != nil {
+		if err == bootloader.ErrBootloader {
+			// no bootloader we know how to read the environment of
+			return false, nil
+		}
+		return false, err
+	}
+	m, err := bl.GetBootVars("snapd_ephemeral_data")
+	if err != nil {
+		return false, err
+	}
+	switch v := m["snapd_ephemeral_data"]; v {
+	case "", "0":
+		return false, nil
+	case "1":
+		return true, nil
+	default:
+		// booting with persistent data is always safe
+		logger.Noticef("ignoring invalid snapd_ephemeral_data value %q", v)
+		return false, nil
+	}
+}
+
+// InitramfsRunModeMarkEphemeralData records that the writable data of the
+// run system is ephemeral for the current boot, so that snapd does not
+// attempt changes to the boot state which would outlive the boot while the
+// data they depend on does not. It is meant to be used only from the
+// initramfs.
+func InitramfsRunModeMarkEphemeralData() error {
+	if err := os.MkdirAll(dirs.SnapBootstrapRunDir, 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(ephemeralDataMarker(), nil, 0644, 0)
+}
@@ -98,6 +98,37 @@ func (s *initramfsSuite) TestEnsureNextBootToRunModeRealBootloader(c *C) {
 	})
 }
 
+func (s *initramfsSuite) TestEnsureNextBootToRecoverMode(c *C) {
+	// with no bootloader available we can't switch modes
+	err := boot.EnsureNextBootToRecoverMode()
+	c.Assert(err, ErrorMatches, "cannot determine bootloader")
+
+	bloader := bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+
+	// no recovery system was ever used
+	err = boot.EnsureNextBootToRecoverMode()
+	c.Assert(err, ErrorMatches, "cannot determine the recovery system to use")
+
+	err = bloader.SetBootVars(map[string]string{
+		"snapd_recovery_mode":   "run",
+		"snapd_recovery_system": "label",
+	})
+	c.Assert(err, IsNil)
+
+	err = boot.EnsureNextBootToRecoverMode()
+	c.Assert(err, IsNil)
+
+	// the recovery system is kept
+	m, err := bloader.GetBootVars("snapd_recovery_mode", "snapd_recovery_system")
+	c.Assert(err, IsNil)
+	c.Assert(m, DeepEquals, map[string]string{
+		"snapd_recovery_mode":   "recover",
+		"snapd_recovery_system": "label",
+	})
+}
+
 func makeSnapFilesOnInitramfsUbuntuData(c *C, rootfsDir string, comment CommentInterface, snaps ...snap.PlaceInfo) (restore func()) {
 	// also make sure the snaps also exist on ubuntu-data
 	snapDir := dirs.SnapBlobDirUnder(rootfsDir)
@@ -814,6 +845,44 @@ func (s *initramfsSuite) TestInitramfsRunModeUpdateBootloaderVarsNoBootloaderHap
 	c.Assert(err, IsNil)
 }
 
+func (s *initramfsSuite) TestInitramfsRunModeEphemeralData(c *C) {
+	bloader := bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+
+	for _, t := range []struct {
+		value     string
+		ephemeral bool
+	}{
+		{"", false},
+		{"0", false},
+		{"1", true},
+		{"yes", false},
+	} {
+		bloader.SetBootVars(map[string]string{"snapd_ephemeral_data": t.value})
+		ephemeral, err := boot.InitramfsRunModeEphemeralData()
+		c.Assert(err, IsNil)
+		c.Check(ephemeral, Equals, t.ephemeral, Commentf("value %q", t.value))
+	}
+}
+
+func (s *initramfsSuite) TestInitramfsRunModeEphemeralDataErrOnGetBootVars(c *C) {
+	bloader := bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+
+	bloader.GetErr = fmt.Errorf("cannot get boot environment")
+
+	_, err := boot.InitramfsRunModeEphemeralData()
+	c.Assert(err, ErrorMatches, "cannot get boot environment")
+}
+
+func (s *initramfsSuite) TestInitramfsRunModeEphemeralDataNoBootloader(c *C) {
+	ephemeral, err := boot.InitramfsRunModeEphemeralData()
+	c.Assert(err, IsNil)
+	c.Check(ephemeral, Equals, false)
+}
+
 var classicModel = &gadgettest.ModelCharacteristics{
 	IsClassic: true,
 	HasModes:  true,
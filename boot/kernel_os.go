@@ -39,6 +39,10 @@ func (*coreBootParticipant) IsTrivial() bool { return false }
 func (bp *coreBootParticipant) SetNextBoot(bootCtx NextBootContext) (rebootInfo RebootInfo, err error) {
 	const errPrefix = "cannot set next boot: %s"
 
+	if WritableDataIsEphemeral() {
+		return RebootInfo{RebootRequired: false}, fmt.Errorf(errPrefix, ErrEphemeralData)
+	}
+
 	rebootInfo, u, err := bp.bs.setNext(bp.s, bootCtx)
 	if err != nil {
 		return RebootInfo{RebootRequired: false}, fmt.Errorf(errPrefix, err)
@@ -75,6 +79,9 @@ func (k *coreKernel) RemoveKernelAssets() error {
 }
 
 func (k *coreKernel) ExtractKernelAssets(snapf snap.Container) error {
+	if WritableDataIsEphemeral() {
+		return fmt.Errorf("cannot extract kernel assets: %v", ErrEphemeralData)
+	}
 	bootloader, err := bootloader.Find("", k.bopts)
 	if err != nil {
 		return fmt.Errorf("cannot extract kernel assets: %s", err)
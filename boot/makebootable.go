@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/bootloader"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget/device"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapfile"
@@ -51,6 +52,10 @@ type BootableSet struct {
 
 	// Recovery is set when making the recovery partition bootable.
 	Recovery bool
+
+	// FactoryBoots is the number of boots into run mode during which the
+	// factory boot flag is kept set, only used by MakeRunnableSystem.
+	FactoryBoots int
 }
 
 // MakeBootableImage sets up the given bootable set and target filesystem
@@ -265,7 +270,7 @@ func MakeRecoverySystemBootable(rootdir string, relativeRecoverySystemDir string
 	// on e.g. ARM we need to extract the kernel assets on the recovery
 	// system as well, but the bootloader does not load any environment from
 	// the recovery system
-	erkbl, ok := bl.(bootloader.ExtractedRecoveryKernelImageBootloader)
+	erkbl, ok := bootloader.KernelLoader(bl).(bootloader.ExtractedRecoveryKernelImageBootloader)
 	if ok {
 		kernelf, err := snapfile.Open(bootWith.KernelPath)
 		if err != nil {
@@ -284,7 +289,7 @@ func MakeRecoverySystemBootable(rootdir string, relativeRecoverySystemDir string
 		return nil
 	}
 
-	rbl, ok := bl.(bootloader.RecoveryAwareBootloader)
+	rbl, ok := bootloader.KernelLoader(bl).(bootloader.RecoveryAwareBootloader)
 	if !ok {
 		return fmt.Errorf("cannot use %s bootloader: does not support recovery systems", bl.Name())
 	}
@@ -405,6 +410,12 @@ func makeRunnableSystem(model *asserts.Model, bootWith *BootableSet, sealer *Tru
 		Grade:          string(model.Grade()),
 		ModelSignKeyID: model.SignKeyID(),
 	}
+	if bootWith.FactoryBoots > 0 {
+		// carry the factory boot flag over to the first boots into
+		// run mode
+		modeenv.BootFlags = []string{"factory"}
+		modeenv.FactoryBoots = bootWith.FactoryBoots
+	}
 
 	// get the ubuntu-boot bootloader and extract the kernel there
 	opts := &bootloader.Options{
@@ -438,7 +449,7 @@ func makeRunnableSystem(model *asserts.Model, bootWith *BootableSet, sealer *Tru
 		"kernel_status": "",
 	}
 
-	ebl, ok := bl.(bootloader.ExtractedRunKernelImageBootloader)
+	ebl, ok := bootloader.KernelLoader(bl).(bootloader.ExtractedRunKernelImageBootloader)
 	if ok {
 		// the bootloader supports additional extracted kernel handling
 
@@ -496,7 +507,13 @@ func makeRunnableSystem(model *asserts.Model, bootWith *BootableSet, sealer *Tru
 		return fmt.Errorf("cannot write modeenv: %v", err)
 	}
 
-	if sealer != nil {
+	if sealer != nil && sealer.passphraseAuth {
+		// the passphrase is an alternative to sealing the keys,
+		// record it so that the initramfs prompts for it instead
+		if err := device.WritePassphraseAuthMarkers(InitramfsBootEncryptionKeyDir, InitramfsSeedEncryptionKeyDir); err != nil {
+			return fmt.Errorf("cannot record passphrase authentication: %v", err)
+		}
+	} else if sealer != nil {
 		hasHook, err := HasFDESetupHook(bootWith.Kernel)
 		if err != nil {
 			return fmt.Errorf("cannot check for fde-setup hook: %v", err)
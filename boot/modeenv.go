@@ -86,6 +86,11 @@ type Modeenv struct {
 	// key. When setting boot flags for the next boot, then this key will be
 	// written to and used by the initramfs after rebooting.
 	BootFlags []string `key:"boot_flags"`
+	// FactoryBoots is the number of boots into run mode, including the
+	// next one, for which the factory boot flag is kept in BootFlags. The
+	// initramfs decrements it on every boot into run mode and clears the
+	// flag once it drops to zero.
+	FactoryBoots int `key:"factory_boots"`
 	// CurrentTrustedBootAssets is a map of a run bootloader's asset names to
 	// a list of hashes of the asset contents. Typically the first entry in
 	// the list is a hash of an asset the system currently boots with (or is
@@ -180,6 +185,7 @@ func ReadModeenv(rootdir string) (*Modeenv, error) {
 	unmarshalModeenvValueFromCfg(cfg, "current_recovery_systems", &m.CurrentRecoverySystems)
 	unmarshalModeenvValueFromCfg(cfg, "good_recovery_systems", &m.GoodRecoverySystems)
 	unmarshalModeenvValueFromCfg(cfg, "boot_flags", &m.BootFlags)
+	unmarshalModeenvValueFromCfg(cfg, "factory_boots", &m.FactoryBoots)
 
 	unmarshalModeenvValueFromCfg(cfg, "mode", &m.Mode)
 	if m.Mode == "" {
@@ -295,6 +301,7 @@ func (m *Modeenv) WriteTo(rootdir string) error {
 	marshalModeenvEntryTo(buf, "current_recovery_systems", m.CurrentRecoverySystems)
 	marshalModeenvEntryTo(buf, "good_recovery_systems", m.GoodRecoverySystems)
 	marshalModeenvEntryTo(buf, "boot_flags", m.BootFlags)
+	marshalModeenvEntryTo(buf, "factory_boots", m.FactoryBoots)
 	marshalModeenvEntryTo(buf, "base", m.Base)
 	marshalModeenvEntryTo(buf, "try_base", m.TryBase)
 	marshalModeenvEntryTo(buf, "base_status", m.BaseStatus)
@@ -445,6 +452,11 @@ func marshalModeenvEntryTo(out io.Writer, key string, what interface{}) error {
 		asString = asModeenvStringList(v)
 	case bool:
 		asString = strconv.FormatBool(v)
+	case int:
+		if v == 0 {
+			return nil
+		}
+		asString = strconv.Itoa(v)
 	default:
 		if vm, ok := what.(modeenvValueMarshaller); ok {
 			marshalled, err := vm.MarshalModeenvValue()
@@ -494,6 +506,16 @@ func unmarshalModeenvValueFromCfg(cfg *goconfigparser.ConfigParser, key string,
 		if err != nil {
 			return fmt.Errorf("cannot parse modeenv value %q to bool: %v", kv, err)
 		}
+	case *int:
+		if kv == "" {
+			*v = 0
+			return nil
+		}
+		var err error
+		*v, err = strconv.Atoi(kv)
+		if err != nil {
+			return fmt.Errorf("cannot parse modeenv value %q to int: %v", kv, err)
+		}
 	default:
 		if vm, ok := v.(modeenvValueUnmarshaller); ok {
 			if err := vm.UnmarshalModeenvValue(kv); err != nil {
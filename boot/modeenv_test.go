@@ -61,6 +61,7 @@ func (s *modeenvSuite) TestKnownKnown(c *C) {
 		"current_recovery_systems": true,
 		"good_recovery_systems":    true,
 		"boot_flags":               true,
+		"factory_boots":            true,
 		// keep this comment to make old go fmt happy
 		"base":                  true,
 		"gadget":                true,
@@ -436,6 +437,32 @@ base_status=try
 	c.Check(modeenv.BaseStatus, Equals, boot.TryStatus)
 }
 
+func (s *modeenvSuite) TestReadWriteFactoryBoots(c *C) {
+	s.makeMockModeenvFile(c, `mode=run
+boot_flags=factory
+factory_boots=3
+`)
+
+	modeenv, err := boot.ReadModeenv(s.tmpdir)
+	c.Assert(err, IsNil)
+	c.Check(modeenv.BootFlags, DeepEquals, []string{"factory"})
+	c.Check(modeenv.FactoryBoots, Equals, 3)
+
+	modeenv.FactoryBoots = 0
+	err = modeenv.Write()
+	c.Assert(err, IsNil)
+	c.Assert(s.mockModeenvPath, testutil.FileEquals, `mode=run
+boot_flags=factory
+`)
+
+	s.makeMockModeenvFile(c, `mode=run
+factory_boots=many
+`)
+	modeenv, err = boot.ReadModeenv(s.tmpdir)
+	c.Assert(err, IsNil)
+	c.Check(modeenv.FactoryBoots, Equals, 0)
+}
+
 func (s *modeenvSuite) TestReadModeWithGrade(c *C) {
 	s.makeMockModeenvFile(c, `mode=run
 grade=dangerous
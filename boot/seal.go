@@ -98,6 +98,27 @@ func recoveryBootChainsFileUnder(rootdir string) string {
 	return filepath.Join(dirs.SnapFDEDirUnder(rootdir), "recovery-boot-chains")
 }
 
+// signatureDbUpdateKeystoreUnder returns the directory where pending EFI
+// signature database updates are staged, using the layout expected by
+// sbkeysync, ie. <keystore>/dbx/<update>.auth
+func signatureDbUpdateKeystoreUnder(rootdir string) string {
+	return filepath.Join(dirs.SnapFDEDirUnder(rootdir), "dbx-update")
+}
+
+// pendingSignatureDbUpdateKeystores returns the list of keystores which
+// contain pending EFI signature database updates.
+func pendingSignatureDbUpdateKeystores(rootdir string) ([]string, error) {
+	keystore := signatureDbUpdateKeystoreUnder(rootdir)
+	updates, err := filepath.Glob(filepath.Join(keystore, "dbx", "*.auth"))
+	if err != nil {
+		return nil, err
+	}
+	if len(updates) == 0 {
+		return nil, nil
+	}
+	return []string{keystore}, nil
+}
+
 type sealKeyToModeenvFlags struct {
 	// HasFDESetupHook is true if the kernel has a fde-setup hook to use
 	HasFDESetupHook bool
@@ -405,11 +426,48 @@ func resealKeyToModeenvImpl(rootdir string, modeenv *Modeenv, expectReseal bool)
 	if err != nil {
 		return err
 	}
+	if WritableDataIsEphemeral() {
+		// the modeenv the keys would be resealed to is not persisted
+		return fmt.Errorf("cannot reseal the encryption key: %v", ErrEphemeralData)
+	}
 	switch method {
 	case device.SealingMethodFDESetupHook:
 		return resealKeyToModeenvUsingFDESetupHook(rootdir, modeenv, expectReseal)
 	case device.SealingMethodTPM, device.SealingMethodLegacyTPM:
-		return resealKeyToModeenvSecboot(rootdir, modeenv, expectReseal)
+		const forceReseal = false
+		return resealKeyToModeenvSecboot(rootdir, modeenv, expectReseal, forceReseal)
+	default:
+		return fmt.Errorf("unknown key sealing method: %q", method)
+	}
+}
+
+var resealKeyToModeenvForSignatureDbUpdate = resealKeyToModeenvForSignatureDbUpdateImpl
+
+// resealKeyToModeenvForSignatureDbUpdate reseals the existing encryption
+// keys to the parameters specified in modeenv, even if the boot chains
+// did not change, such that the set of pending EFI signature database
+// updates is taken into account.
+func resealKeyToModeenvForSignatureDbUpdateImpl(rootdir string, modeenv *Modeenv) error {
+	method, err := device.SealedKeysMethod(rootdir)
+	if err == device.ErrNoSealedKeys {
+		// nothing to do
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if WritableDataIsEphemeral() {
+		// the modeenv the keys would be resealed to is not persisted
+		return fmt.Errorf("cannot reseal the encryption key: %v", ErrEphemeralData)
+	}
+	switch method {
+	case device.SealingMethodFDESetupHook:
+		// the keys are not bound to the secure boot policy
+		return nil
+	case device.SealingMethodTPM, device.SealingMethodLegacyTPM:
+		const expectReseal = false
+		const forceReseal = true
+		return resealKeyToModeenvSecboot(rootdir, modeenv, expectReseal, forceReseal)
 	default:
 		return fmt.Errorf("unknown key sealing method: %q", method)
 	}
@@ -439,7 +497,7 @@ func resealKeyToModeenvUsingFDESetupHookImpl(rootdir string, modeenv *Modeenv, e
 }
 
 // TODO:UC20: allow more than one model to accommodate the remodel scenario
-func resealKeyToModeenvSecboot(rootdir string, modeenv *Modeenv, expectReseal bool) error {
+func resealKeyToModeenvSecboot(rootdir string, modeenv *Modeenv, expectReseal, forceReseal bool) error {
 	// build the recovery mode boot chain
 	rbl, err := bootloader.Find(InitramfsUbuntuSeedDir, &bootloader.Options{
 		Role: bootloader.RoleRecovery,
@@ -508,6 +566,13 @@ func resealKeyToModeenvSecboot(rootdir string, modeenv *Modeenv, expectReseal bo
 	saveFDEDir := dirs.SnapFDEDirUnderSave(dirs.SnapSaveDirUnder(rootdir))
 	authKeyFile := filepath.Join(saveFDEDir, "tpm-policy-auth-key")
 
+	// the keys must remain usable once the pending signature database
+	// updates get applied
+	keystores, err := pendingSignatureDbUpdateKeystores(rootdir)
+	if err != nil {
+		return fmt.Errorf("cannot list pending signature database updates: %v", err)
+	}
+
 	// reseal the run object
 	pbc := toPredictableBootChains(append(runModeBootChains, recoveryBootChainsForRunKey...))
 
@@ -515,11 +580,11 @@ func resealKeyToModeenvSecboot(rootdir string, modeenv *Modeenv, expectReseal bo
 	if err != nil {
 		return err
 	}
-	if needed {
+	if needed || forceReseal {
 		pbcJSON, _ := json.Marshal(pbc)
 		logger.Debugf("resealing (%d) to boot chains: %s", nextCount, pbcJSON)
 
-		if err := resealRunObjectKeys(pbc, authKeyFile, roleToBlName); err != nil {
+		if err := resealRunObjectKeys(pbc, authKeyFile, roleToBlName, keystores); err != nil {
 			return err
 		}
 		logger.Debugf("resealing (%d) succeeded", nextCount)
@@ -540,11 +605,11 @@ func resealKeyToModeenvSecboot(rootdir string, modeenv *Modeenv, expectReseal bo
 	if err != nil {
 		return err
 	}
-	if needed {
+	if needed || forceReseal {
 		rpbcJSON, _ := json.Marshal(rpbc)
 		logger.Debugf("resealing (%d) to recovery boot chains: %s", nextFallbackCount, rpbcJSON)
 
-		if err := resealFallbackObjectKeys(rpbc, authKeyFile, roleToBlName); err != nil {
+		if err := resealFallbackObjectKeys(rpbc, authKeyFile, roleToBlName, keystores); err != nil {
 			return err
 		}
 		logger.Debugf("fallback resealing (%d) succeeded", nextFallbackCount)
@@ -560,7 +625,7 @@ func resealKeyToModeenvSecboot(rootdir string, modeenv *Modeenv, expectReseal bo
 	return nil
 }
 
-func resealRunObjectKeys(pbc predictableBootChains, authKeyFile string, roleToBlName map[bootloader.Role]string) error {
+func resealRunObjectKeys(pbc predictableBootChains, authKeyFile string, roleToBlName map[bootloader.Role]string, keystores []string) error {
 	// get model parameters from bootchains
 	modelParams, err := sealKeyModelParams(pbc, roleToBlName)
 	if err != nil {
@@ -571,9 +636,10 @@ func resealRunObjectKeys(pbc predictableBootChains, authKeyFile string, roleToBl
 	keyFiles := []string{device.DataSealedKeyUnder(InitramfsBootEncryptionKeyDir)}
 
 	resealKeyParams := &secboot.ResealKeysParams{
-		ModelParams:          modelParams,
-		KeyFiles:             keyFiles,
-		TPMPolicyAuthKeyFile: authKeyFile,
+		ModelParams:                modelParams,
+		KeyFiles:                   keyFiles,
+		TPMPolicyAuthKeyFile:       authKeyFile,
+		SignatureDbUpdateKeystores: keystores,
 	}
 	if err := secbootResealKeys(resealKeyParams); err != nil {
 		return fmt.Errorf("cannot reseal the encryption key: %v", err)
@@ -582,7 +648,7 @@ func resealRunObjectKeys(pbc predictableBootChains, authKeyFile string, roleToBl
 	return nil
 }
 
-func resealFallbackObjectKeys(pbc predictableBootChains, authKeyFile string, roleToBlName map[bootloader.Role]string) error {
+func resealFallbackObjectKeys(pbc predictableBootChains, authKeyFile string, roleToBlName map[bootloader.Role]string, keystores []string) error {
 	// get model parameters from bootchains
 	modelParams, err := sealKeyModelParams(pbc, roleToBlName)
 	if err != nil {
@@ -596,9 +662,10 @@ func resealFallbackObjectKeys(pbc predictableBootChains, authKeyFile string, rol
 	}
 
 	resealKeyParams := &secboot.ResealKeysParams{
-		ModelParams:          modelParams,
-		KeyFiles:             keyFiles,
-		TPMPolicyAuthKeyFile: authKeyFile,
+		ModelParams:                modelParams,
+		KeyFiles:                   keyFiles,
+		TPMPolicyAuthKeyFile:       authKeyFile,
+		SignatureDbUpdateKeystores: keystores,
 	}
 	if err := secbootResealKeys(resealKeyParams); err != nil {
 		return fmt.Errorf("cannot reseal the fallback encryption keys: %v", err)
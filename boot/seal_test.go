@@ -1751,6 +1751,32 @@ func (s *sealSuite) TestResealKeyToModeenvWithFdeHookCalled(c *C) {
 	c.Check(resealKeyToModeenvUsingFDESetupHookCalled, Equals, 1)
 }
 
+func (s *sealSuite) TestResealKeyToModeenvEphemeralData(c *C) {
+	rootdir := c.MkDir()
+	dirs.SetRootDir(rootdir)
+	defer dirs.SetRootDir("")
+
+	restore := boot.MockResealKeyToModeenvUsingFDESetupHook(func(string, *boot.Modeenv, bool) error {
+		c.Fatalf("unexpected reseal")
+		return nil
+	})
+	defer restore()
+
+	modeenv := &boot.Modeenv{RecoverySystem: "20200825"}
+
+	// no sealed keys, nothing to refuse
+	c.Assert(boot.InitramfsRunModeMarkEphemeralData(), IsNil)
+	err := boot.ResealKeyToModeenv(rootdir, modeenv, false)
+	c.Assert(err, IsNil)
+
+	marker := filepath.Join(dirs.SnapFDEDirUnder(rootdir), "sealed-keys")
+	c.Assert(os.MkdirAll(filepath.Dir(marker), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(marker, []byte("fde-setup-hook"), 0644), IsNil)
+
+	err = boot.ResealKeyToModeenv(rootdir, modeenv, false)
+	c.Assert(err, ErrorMatches, "cannot reseal the encryption key: writable data is ephemeral for this boot")
+}
+
 func (s *sealSuite) TestResealKeyToModeenvWithFdeHookVerySad(c *C) {
 	rootdir := c.MkDir()
 	dirs.SetRootDir(rootdir)
@@ -2207,3 +2233,203 @@ func (s *sealSuite) TestMarkFactoryResetComplete(c *C) {
 	}
 
 }
+
+func (s *sealSuite) TestResealKeyToModeenvForSignatureDbUpdate(c *C) {
+	rootdir := c.MkDir()
+	dirs.SetRootDir(rootdir)
+	defer dirs.SetRootDir("")
+
+	c.Assert(os.MkdirAll(dirs.SnapFDEDir, 0755), IsNil)
+	err := ioutil.WriteFile(filepath.Join(dirs.SnapFDEDir, "sealed-keys"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	err = createMockGrubCfg(filepath.Join(rootdir, "run/mnt/ubuntu-seed"))
+	c.Assert(err, IsNil)
+
+	err = createMockGrubCfg(filepath.Join(rootdir, "run/mnt/ubuntu-boot"))
+	c.Assert(err, IsNil)
+
+	model := boottest.MakeMockUC20Model()
+
+	modeenv := &boot.Modeenv{
+		CurrentRecoverySystems: []string{"20200825"},
+		GoodRecoverySystems:    []string{"20200825"},
+		CurrentTrustedRecoveryBootAssets: boot.BootAssetsMap{
+			"grubx64.efi": []string{"grub-hash"},
+			"bootx64.efi": []string{"shim-hash"},
+		},
+
+		CurrentTrustedBootAssets: boot.BootAssetsMap{
+			"grubx64.efi": []string{"run-grub-hash"},
+		},
+
+		CurrentKernels: []string{"pc-kernel_500.snap"},
+
+		CurrentKernelCommandLines: boot.BootCommandLines{
+			"snapd_recovery_mode=run console=ttyS0 console=tty1 panic=-1",
+		},
+		Model:          model.Model(),
+		BrandID:        model.BrandID(),
+		Grade:          string(model.Grade()),
+		ModelSignKeyID: model.SignKeyID(),
+	}
+
+	// mock asset cache
+	mockAssetsCache(c, rootdir, "grub", []string{
+		"bootx64.efi-shim-hash",
+		"grubx64.efi-grub-hash",
+		"grubx64.efi-run-grub-hash",
+	})
+
+	restore := boot.MockSeedReadSystemEssential(func(seedDir, label string, essentialTypes []snap.Type, tm timings.Measurer) (*asserts.Model, []*seed.Snap, error) {
+		return model, []*seed.Snap{mockKernelSeedSnap(snap.R(1)), mockGadgetSeedSnap(c, nil)}, nil
+	})
+	defer restore()
+
+	var keystores [][]string
+	restore = boot.MockSecbootResealKeys(func(params *secboot.ResealKeysParams) error {
+		keystores = append(keystores, params.SignatureDbUpdateKeystores)
+		return nil
+	})
+	defer restore()
+
+	// initial reseal, no updates are pending
+	const expectReseal = false
+	err = boot.ResealKeyToModeenv(rootdir, modeenv, expectReseal)
+	c.Assert(err, IsNil)
+	c.Check(keystores, DeepEquals, [][]string{nil, nil})
+
+	// the boot chains are unchanged, so a reseal is not needed
+	keystores = nil
+	err = boot.ResealKeyToModeenv(rootdir, modeenv, expectReseal)
+	c.Assert(err, IsNil)
+	c.Check(keystores, HasLen, 0)
+
+	// no updates are staged, but the reseal is forced anyway
+	err = boot.ResealKeyToModeenvForSignatureDbUpdate(rootdir, modeenv)
+	c.Assert(err, IsNil)
+	c.Check(keystores, DeepEquals, [][]string{nil, nil})
+
+	// stage an update
+	keystore := filepath.Join(dirs.SnapFDEDir, "dbx-update")
+	c.Assert(os.MkdirAll(filepath.Join(keystore, "dbx"), 0700), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(keystore, "dbx", "update.auth"), []byte("dbx"), 0600), IsNil)
+
+	keystores = nil
+	err = boot.ResealKeyToModeenvForSignatureDbUpdate(rootdir, modeenv)
+	c.Assert(err, IsNil)
+	c.Check(keystores, DeepEquals, [][]string{{keystore}, {keystore}})
+
+	// boot chains are still the same
+	_, cnt, err := boot.ReadBootChains(filepath.Join(dirs.SnapFDEDir, "boot-chains"))
+	c.Assert(err, IsNil)
+	c.Check(cnt, Equals, 3)
+}
+
+func (s *sealSuite) TestResealKeyToModeenvForSignatureDbUpdateFDEHook(c *C) {
+	rootdir := c.MkDir()
+	dirs.SetRootDir(rootdir)
+	defer dirs.SetRootDir("")
+
+	c.Assert(os.MkdirAll(dirs.SnapFDEDir, 0755), IsNil)
+	err := ioutil.WriteFile(filepath.Join(dirs.SnapFDEDir, "sealed-keys"), []byte("fde-setup-hook"), 0644)
+	c.Assert(err, IsNil)
+
+	restore := boot.MockSecbootResealKeys(func(params *secboot.ResealKeysParams) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})
+	defer restore()
+
+	err = boot.ResealKeyToModeenvForSignatureDbUpdate(rootdir, &boot.Modeenv{})
+	c.Assert(err, IsNil)
+}
+
+func (s *sealSuite) TestPrepareSecureBootDbxUpdate(c *C) {
+	rootdir := c.MkDir()
+	dirs.SetRootDir(rootdir)
+	defer dirs.SetRootDir("")
+
+	modeenv := &boot.Modeenv{
+		Mode:           "run",
+		CurrentKernels: []string{"pc-kernel_500.snap"},
+	}
+	c.Assert(modeenv.WriteTo(""), IsNil)
+
+	updateFile := filepath.Join(dirs.SnapFDEDir, "dbx-update/dbx/update.auth")
+
+	resealCalls := 0
+	var resealErr error
+	restore := boot.MockResealKeyToModeenvForSignatureDbUpdate(func(root string, m *boot.Modeenv) error {
+		resealCalls++
+		c.Check(root, Equals, rootdir)
+		c.Check(m.CurrentKernels, DeepEquals, []string{"pc-kernel_500.snap"})
+		// the update is staged before resealing
+		c.Check(updateFile, testutil.FileEquals, "dbx-update")
+		return resealErr
+	})
+	defer restore()
+
+	err := boot.PrepareSecureBootDbxUpdate(nil)
+	c.Assert(err, ErrorMatches, "cannot prepare for an empty DBX update")
+	c.Check(resealCalls, Equals, 0)
+
+	err = boot.PrepareSecureBootDbxUpdate([]byte("dbx-update"))
+	c.Assert(err, IsNil)
+	c.Check(resealCalls, Equals, 1)
+	c.Check(updateFile, testutil.FileEquals, "dbx-update")
+
+	// the update is dropped when resealing fails
+	resealErr = errors.New("reseal fail")
+	err = boot.PrepareSecureBootDbxUpdate([]byte("dbx-update"))
+	c.Assert(err, ErrorMatches, "cannot reseal keys for DBX update: reseal fail")
+	c.Check(resealCalls, Equals, 2)
+	c.Check(updateFile, testutil.FileAbsent)
+}
+
+func (s *sealSuite) TestCompleteSecureBootDbxUpdate(c *C) {
+	rootdir := c.MkDir()
+	dirs.SetRootDir(rootdir)
+	defer dirs.SetRootDir("")
+
+	modeenv := &boot.Modeenv{
+		Mode:           "run",
+		CurrentKernels: []string{"pc-kernel_500.snap"},
+	}
+	c.Assert(modeenv.WriteTo(""), IsNil)
+
+	updateFile := filepath.Join(dirs.SnapFDEDir, "dbx-update/dbx/update.auth")
+
+	resealCalls := 0
+	var resealErr error
+	restore := boot.MockResealKeyToModeenvForSignatureDbUpdate(func(root string, m *boot.Modeenv) error {
+		resealCalls++
+		c.Check(root, Equals, rootdir)
+		c.Check(m.CurrentKernels, DeepEquals, []string{"pc-kernel_500.snap"})
+		// the update is gone before resealing
+		c.Check(updateFile, testutil.FileAbsent)
+		return resealErr
+	})
+	defer restore()
+
+	// nothing staged, nothing to do
+	err := boot.CompleteSecureBootDbxUpdate()
+	c.Assert(err, IsNil)
+	c.Check(resealCalls, Equals, 0)
+
+	c.Assert(os.MkdirAll(filepath.Dir(updateFile), 0700), IsNil)
+	c.Assert(ioutil.WriteFile(updateFile, []byte("dbx-update"), 0600), IsNil)
+
+	err = boot.CompleteSecureBootDbxUpdate()
+	c.Assert(err, IsNil)
+	c.Check(resealCalls, Equals, 1)
+	c.Check(filepath.Join(dirs.SnapFDEDir, "dbx-update"), testutil.FileAbsent)
+
+	c.Assert(os.MkdirAll(filepath.Dir(updateFile), 0700), IsNil)
+	c.Assert(ioutil.WriteFile(updateFile, []byte("dbx-update"), 0600), IsNil)
+
+	resealErr = errors.New("reseal fail")
+	err = boot.CompleteSecureBootDbxUpdate()
+	c.Assert(err, ErrorMatches, "cannot reseal keys after DBX update: reseal fail")
+	c.Check(resealCalls, Equals, 2)
+}
@@ -0,0 +1,96 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+func dbxUpdateFileUnder(rootdir string) string {
+	return filepath.Join(signatureDbUpdateKeystoreUnder(rootdir), "dbx", "update.auth")
+}
+
+// PrepareSecureBootDbxUpdate stages the given EFI DBX update and reseals
+// the encryption keys such that they can be unsealed both before and after
+// the update is applied to the firmware. The update is expected to be an
+// authenticated variable update, as consumed by sbkeysync.
+func PrepareSecureBootDbxUpdate(update []byte) error {
+	if len(update) == 0 {
+		return fmt.Errorf("cannot prepare for an empty DBX update")
+	}
+
+	modeenv, err := ReadModeenv("")
+	if err != nil {
+		return err
+	}
+
+	updateFile := dbxUpdateFileUnder(dirs.GlobalRootDir)
+	if err := os.MkdirAll(filepath.Dir(updateFile), 0700); err != nil {
+		return fmt.Errorf("cannot stage DBX update: %v", err)
+	}
+	if err := osutil.AtomicWriteFile(updateFile, update, 0600, 0); err != nil {
+		return fmt.Errorf("cannot stage DBX update: %v", err)
+	}
+
+	if err := resealKeyToModeenvForSignatureDbUpdate(dirs.GlobalRootDir, modeenv); err != nil {
+		// the keys may not be usable after the update, do not keep
+		// it around
+		if rmErr := os.Remove(updateFile); rmErr != nil {
+			logger.Noticef("cannot remove staged DBX update: %v", rmErr)
+		}
+		return fmt.Errorf("cannot reseal keys for DBX update: %v", err)
+	}
+	return nil
+}
+
+// CompleteSecureBootDbxUpdate drops a previously staged EFI DBX update, once
+// it has been applied to the firmware, and reseals the encryption keys to
+// the current secure boot policy only. It does nothing if no update has
+// been staged.
+func CompleteSecureBootDbxUpdate() error {
+	keystores, err := pendingSignatureDbUpdateKeystores(dirs.GlobalRootDir)
+	if err != nil {
+		return fmt.Errorf("cannot list pending signature database updates: %v", err)
+	}
+	if len(keystores) == 0 {
+		// nothing to do
+		return nil
+	}
+
+	modeenv, err := ReadModeenv("")
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(signatureDbUpdateKeystoreUnder(dirs.GlobalRootDir)); err != nil {
+		return fmt.Errorf("cannot remove staged DBX update: %v", err)
+	}
+
+	if err := resealKeyToModeenvForSignatureDbUpdate(dirs.GlobalRootDir, modeenv); err != nil {
+		return fmt.Errorf("cannot reseal keys after DBX update: %v", err)
+	}
+	return nil
+}
@@ -461,7 +461,7 @@ func MarkRecoveryCapableSystem(systemLabel string) error {
 	if err != nil {
 		return err
 	}
-	rbl, ok := bl.(bootloader.RecoveryAwareBootloader)
+	rbl, ok := bootloader.KernelLoader(bl).(bootloader.RecoveryAwareBootloader)
 	if !ok {
 		return nil
 	}
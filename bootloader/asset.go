@@ -22,14 +22,21 @@ package bootloader
 import (
 	"bufio"
 	"bytes"
+	"crypto"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
+	_ "golang.org/x/crypto/sha3"
+
 	"github.com/snapcore/snapd/bootloader/assets"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
 )
 
 var errNoEdition = errors.New("no edition")
@@ -111,3 +118,92 @@ func configAssetFrom(data []byte) (*configAsset, error) {
 	}
 	return gbs, nil
 }
+
+// gadgetBootAssetsDir is the directory inside the gadget snap carrying boot
+// config assets that extend the ones built into snapd.
+const gadgetBootAssetsDir = "boot-assets"
+
+// gadgetConfigAsset loads a named boot config asset carried by the gadget
+// snap. Returns nil when the gadget does not carry such asset. Only assets
+// that are managed by snapd, ie. carry the edition header, are accepted.
+func gadgetConfigAsset(gadget snap.Container, assetName string) (*configAsset, error) {
+	if gadget == nil {
+		return nil, nil
+	}
+	data, err := gadget.ReadFile(filepath.Join(gadgetBootAssetsDir, assetName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read boot config asset %q from gadget: %v", assetName, err)
+	}
+	edition, err := editionFromConfigAsset(bytes.NewReader(data))
+	if err != nil {
+		if err == errNoEdition {
+			return nil, fmt.Errorf("cannot use boot config asset %q from gadget: missing edition header", assetName)
+		}
+		return nil, fmt.Errorf("cannot use boot config asset %q from gadget: %v", assetName, err)
+	}
+	return &configAsset{
+		body:          data,
+		parsedEdition: edition,
+	}, nil
+}
+
+// candidateConfigAsset returns the boot config asset with the given name
+// that should be used for an update. The asset carried by the gadget snap is
+// picked over the built-in one only when its edition is higher, such that
+// fixes shipped with snapd are not masked by an older gadget asset.
+func candidateConfigAsset(assetName string, gadget snap.Container) (*configAsset, error) {
+	internal := assets.Internal(assetName)
+	if len(internal) == 0 {
+		return nil, fmt.Errorf("no boot config asset with name %q", assetName)
+	}
+	bc, err := configAssetFrom(internal)
+	if err != nil {
+		return nil, err
+	}
+	fromGadget, err := gadgetConfigAsset(gadget, assetName)
+	if err != nil {
+		return nil, err
+	}
+	if fromGadget != nil && fromGadget.Edition() > bc.Edition() {
+		return fromGadget, nil
+	}
+	return bc, nil
+}
+
+// verifyConfigAssetFromGadget verifies that the boot config at systemFile,
+// when it has the same edition as the asset carried by the gadget snap, is
+// that asset or the built-in one of the same edition, which is installed
+// in favor of the gadget asset in that case. The boot partition is not
+// trusted, and the edition of the on-disk boot config decides the kernel
+// command line.
+func verifyConfigAssetFromGadget(systemFile string, edition uint, assetName string, gadget snap.Container) error {
+	fromGadget, err := gadgetConfigAsset(gadget, assetName)
+	if err != nil {
+		return err
+	}
+	if fromGadget == nil || fromGadget.Edition() != edition {
+		return nil
+	}
+	digest, _, err := osutil.FileDigest(systemFile, crypto.SHA3_384)
+	if err != nil {
+		return fmt.Errorf("cannot calculate boot config digest: %v", err)
+	}
+	h := crypto.SHA3_384.New()
+	h.Write(fromGadget.Raw())
+	expected := h.Sum(nil)
+	if bytes.Equal(digest, expected) {
+		return nil
+	}
+	if internal, err := configAssetFrom(assets.Internal(assetName)); err == nil && internal.Edition() == edition {
+		h.Reset()
+		h.Write(internal.Raw())
+		if bytes.Equal(digest, h.Sum(nil)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("boot config with edition %v has hash %s, expected %s from the gadget asset",
+		edition, hex.EncodeToString(digest), hex.EncodeToString(expected))
+}
@@ -179,6 +179,9 @@ type CommandLineComponents struct {
 	// set and ExtraArgs. Note that, it is an error if extra and full
 	// arguments are non-empty.
 	FullArgs string
+	// Gadget snap, which may carry boot config assets superseding the
+	// built-in ones.
+	Gadget snap.Container
 }
 
 func (c *CommandLineComponents) Validate() error {
@@ -198,8 +201,10 @@ type TrustedAssetsBootloader interface {
 	// in the boot filesystem. Does not require rootdir to be set.
 	ManagedAssets() []string
 	// UpdateBootConfig attempts to update the boot config assets used by
-	// the bootloader. Returns true when assets were updated.
-	UpdateBootConfig() (bool, error)
+	// the bootloader. Boot config assets carried by the gadget snap, if
+	// provided, are used in place of built-in ones when their edition is
+	// higher. Returns true when assets were updated.
+	UpdateBootConfig(gadget snap.Container) (bool, error)
 	// CommandLine returns the kernel command line composed of mode and
 	// system arguments, followed by either a built-in bootloader specific
 	// static arguments corresponding to the on-disk boot asset edition, and
@@ -207,8 +212,9 @@ type TrustedAssetsBootloader interface {
 	// components. The command line may be different when using a recovery
 	// bootloader.
 	CommandLine(pieces CommandLineComponents) (string, error)
-	// CandidateCommandLine is similar to CommandLine, but uses the current
-	// edition of managed built-in boot assets as reference.
+	// CandidateCommandLine is similar to CommandLine, but uses the edition
+	// of the boot assets that would be installed by UpdateBootConfig as
+	// reference, including those carried by the gadget snap.
 	CandidateCommandLine(pieces CommandLineComponents) (string, error)
 
 	// TrustedAssets returns the list of relative paths to assets inside the
@@ -247,6 +253,25 @@ type RebootBootloader interface {
 	GetRebootArguments() (string, error)
 }
 
+// BootCountingBootloader is a Bootloader that natively counts the boots
+// attempted while an update is being tried, and falls back to the previous
+// boot configuration once a limit of boots is exceeded, as with the bootcount
+// feature of u-boot.
+type BootCountingBootloader interface {
+	Bootloader
+
+	// ArmBootCountingVars returns the boot variables to set along with
+	// those selecting an update to try, such that boots are counted
+	// until the update is marked successful. It returns no variables if
+	// the bootloader cannot count boots in its current setup.
+	ArmBootCountingVars() (map[string]string, error)
+	// ClearBootCountingVars returns the boot variables to set along with
+	// those marking a boot successful, or selecting the previous boot
+	// configuration again, such that boots are no longer counted. It
+	// returns no variables if boot counting is not armed.
+	ClearBootCountingVars() (map[string]string, error)
+}
+
 func genericInstallBootConfig(gadgetFile, systemFile string) error {
 	if err := os.MkdirAll(filepath.Dir(systemFile), 0755); err != nil {
 		return err
@@ -265,7 +290,7 @@ func genericSetBootConfigFromAsset(systemFile, assetName string) error {
 	return osutil.AtomicWriteFile(systemFile, bootConfig, 0644, 0)
 }
 
-func genericUpdateBootConfigFromAssets(systemFile string, assetName string) (updated bool, err error) {
+func genericUpdateBootConfigFromAssets(systemFile string, assetName string, gadget snap.Container) (updated bool, err error) {
 	currentBootConfigEdition, err := editionFromDiskConfigAsset(systemFile)
 	if err != nil && err != errNoEdition {
 		return false, err
@@ -273,11 +298,7 @@ func genericUpdateBootConfigFromAssets(systemFile string, assetName string) (upd
 	if err == errNoEdition {
 		return false, nil
 	}
-	newBootConfig := assets.Internal(assetName)
-	if len(newBootConfig) == 0 {
-		return false, fmt.Errorf("no boot config asset with name %q", assetName)
-	}
-	bc, err := configAssetFrom(newBootConfig)
+	bc, err := candidateConfigAsset(assetName, gadget)
 	if err != nil {
 		return false, err
 	}
@@ -345,6 +366,27 @@ func Find(rootdir string, opts *Options) (Bootloader, error) {
 		opts = &Options{}
 	}
 
+	// the emulated bootloader is used for integration testing
+	if bl := newEmulated(rootdir, opts); bl != nil {
+		return bl, nil
+	}
+
+	// a chain of bootloaders takes precedence
+	if declPath := filepath.Join(rootdir, chainFile); osutil.FileExists(declPath) {
+		bl, err := newChainFromDeclaration(declPath, rootdir, opts)
+		if err != nil {
+			return nil, err
+		}
+		present, err := bl.Present()
+		if err != nil {
+			return nil, err
+		}
+		if !present {
+			return nil, fmt.Errorf("bootloader chain declared but not all bootloaders are present")
+		}
+		return bl, nil
+	}
+
 	// note that the order of this is not deterministic
 	for _, blNew := range bootloaders {
 		bl := blNew(rootdir, opts)
@@ -416,6 +458,12 @@ func ForGadget(gadgetDir, rootDir string, opts *Options) (Bootloader, error) {
 	if forcedBootloader != nil || forcedError != nil {
 		return forcedBootloader, forcedError
 	}
+	if bl := newEmulated(rootDir, opts); bl != nil {
+		return bl, nil
+	}
+	if declPath := filepath.Join(gadgetDir, chainFile); osutil.FileExists(declPath) {
+		return newChainFromDeclaration(declPath, rootDir, opts)
+	}
 	for _, blNew := range bootloaders {
 		bl := blNew(rootDir, opts)
 		markerConf := filepath.Join(gadgetDir, bl.Name()+".conf")
@@ -359,6 +359,29 @@ func (s *bootenvTestSuite) TestBootloaderForGadget(c *C) {
 	}
 }
 
+func (s *bootenvTestSuite) TestBootloaderForGadgetNoRootDir(c *C) {
+	// the bootloader of a gadget can be looked up without a rootdir, e.g.
+	// to find out about its trusted assets
+	for _, tc := range []struct {
+		gadgetFile string
+		expName    string
+	}{
+		{gadgetFile: "grub.conf", expName: "grub"},
+		{gadgetFile: "uboot.conf", expName: "uboot"},
+		{gadgetFile: "androidboot.conf", expName: "androidboot"},
+		{gadgetFile: "lk.conf", expName: "lk"},
+	} {
+		c.Logf("tc: %v", tc.gadgetFile)
+		gadgetDir := c.MkDir()
+		err := ioutil.WriteFile(filepath.Join(gadgetDir, tc.gadgetFile), nil, 0644)
+		c.Assert(err, IsNil)
+		bl, err := bootloader.ForGadget(gadgetDir, "", &bootloader.Options{Role: bootloader.RoleRunMode})
+		c.Assert(err, IsNil)
+		c.Assert(bl, NotNil)
+		c.Check(bl.Name(), Equals, tc.expName)
+	}
+}
+
 func (s *bootenvTestSuite) TestBootFileWithPath(c *C) {
 	a := bootloader.NewBootFile("", "some/path", bootloader.RoleRunMode)
 	b := a.WithPath("other/path")
@@ -66,6 +66,7 @@ var _ bootloader.NotScriptableBootloader = (*MockNotScriptableBootloader)(nil)
 var _ bootloader.NotScriptableBootloader = (*MockExtractedRecoveryKernelNotScriptableBootloader)(nil)
 var _ bootloader.ExtractedRecoveryKernelImageBootloader = (*MockExtractedRecoveryKernelNotScriptableBootloader)(nil)
 var _ bootloader.RebootBootloader = (*MockRebootBootloader)(nil)
+var _ bootloader.BootCountingBootloader = (*MockBootCountingBootloader)(nil)
 
 func Mock(name, bootdir string) *MockBootloader {
 	return &MockBootloader{
@@ -453,7 +454,7 @@ func (b *MockTrustedAssetsMixin) ManagedAssets() []string {
 	return b.ManagedAssetsList
 }
 
-func (b *MockTrustedAssetsMixin) UpdateBootConfig() (bool, error) {
+func (b *MockTrustedAssetsMixin) UpdateBootConfig(gadget snap.Container) (bool, error) {
 	b.UpdateCalls++
 	return b.Updated, b.UpdateErr
 }
@@ -597,3 +598,42 @@ func (b *MockBootloader) WithRebootBootloader() *MockRebootBootloader {
 		MockBootloader: b,
 	}
 }
+
+// MockBootCountingBootloader mocks a bootloader implementing the
+// bootloader.BootCountingBootloader interface, using the same variables as
+// the bootcount feature of u-boot.
+type MockBootCountingBootloader struct {
+	*MockBootloader
+
+	BootCountingErr error
+}
+
+func (b *MockBootCountingBootloader) ArmBootCountingVars() (map[string]string, error) {
+	if b.BootCountingErr != nil {
+		return nil, b.BootCountingErr
+	}
+	return map[string]string{
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "1",
+	}, nil
+}
+
+func (b *MockBootCountingBootloader) ClearBootCountingVars() (map[string]string, error) {
+	if b.BootCountingErr != nil {
+		return nil, b.BootCountingErr
+	}
+	if b.BootVars["upgrade_available"] != "1" {
+		return nil, nil
+	}
+	return map[string]string{
+		"bootcount":         "0",
+		"upgrade_available": "0",
+	}, nil
+}
+
+func (b *MockBootloader) WithBootCounting() *MockBootCountingBootloader {
+	return &MockBootCountingBootloader{
+		MockBootloader: b,
+	}
+}
@@ -0,0 +1,370 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// chainFile is the name of the file declaring a chain of bootloaders. It is
+// carried at the top level of the gadget snap and is installed at the root of
+// the bootloader partition.
+//
+// Each non empty line of the file, other than comments starting with '#',
+// names one bootloader of the chain, in the order they are loaded during boot,
+// optionally followed by the names of boot variables that are kept in the
+// environment of that bootloader, eg:
+//
+//	uboot snapd_spl_status
+//	grub
+//
+// Variables not listed explicitly are kept in the environment of the last
+// bootloader of the chain, the one loading the kernel.
+const chainFile = "bootloader-chain"
+
+// ChainedBootloader is a Bootloader composed of a chain of bootloaders loading
+// one another during boot, eg. u-boot SPL chaining into grub. Boot variables
+// are routed to the element of the chain that keeps them, while kernel
+// related operations are carried out by the last element of the chain.
+type ChainedBootloader interface {
+	Bootloader
+
+	// Elements returns the bootloaders of the chain in the order in which
+	// they are loaded during boot.
+	Elements() []Bootloader
+}
+
+// KernelLoader returns the bootloader that loads the kernel. For a chain of
+// bootloaders this is the last element of the chain, otherwise it is the
+// bootloader itself.
+func KernelLoader(bl Bootloader) Bootloader {
+	if cbl, ok := bl.(ChainedBootloader); ok {
+		elems := cbl.Elements()
+		return elems[len(elems)-1]
+	}
+	return bl
+}
+
+type chainElement struct {
+	name string
+	vars []string
+}
+
+func readChainDeclaration(path string) ([]chainElement, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var elems []chainElement
+	seenNames := make(map[string]bool)
+	seenVars := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		elem := chainElement{name: fields[0], vars: fields[1:]}
+		if seenNames[elem.name] {
+			return nil, fmt.Errorf("bootloader %q listed more than once", elem.name)
+		}
+		seenNames[elem.name] = true
+		for _, v := range elem.vars {
+			if seenVars[v] {
+				return nil, fmt.Errorf("variable %q assigned to more than one bootloader", v)
+			}
+			seenVars[v] = true
+		}
+		elems = append(elems, elem)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(elems) < 2 {
+		return nil, fmt.Errorf("chain must list at least 2 bootloaders")
+	}
+	return elems, nil
+}
+
+// newChainFromDeclaration creates a chain of bootloaders with the given
+// rootdir and options, as declared in the chain file at the given path.
+func newChainFromDeclaration(declPath, rootdir string, opts *Options) (Bootloader, error) {
+	decl, err := readChainDeclaration(declPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read bootloader chain declaration: %v", err)
+	}
+	c := &chain{
+		rootdir: rootdir,
+		owners:  make(map[string]Bootloader),
+	}
+	if opts != nil {
+		c.role = opts.Role
+	}
+	for _, elem := range decl {
+		var bl Bootloader
+		for _, blNew := range bootloaders {
+			if candidate := blNew(rootdir, opts); candidate != nil && candidate.Name() == elem.name {
+				bl = candidate
+				break
+			}
+		}
+		if bl == nil {
+			return nil, fmt.Errorf("cannot use bootloader chain: unknown bootloader %q", elem.name)
+		}
+		if _, ok := bl.(ChainedBootloader); ok {
+			return nil, fmt.Errorf("internal error: bootloader chains cannot be nested")
+		}
+		for _, v := range elem.vars {
+			c.owners[v] = bl
+		}
+		c.elems = append(c.elems, bl)
+	}
+	if tbl, ok := KernelLoader(c).(TrustedAssetsBootloader); ok {
+		return &trustedAssetsChain{chain: c, tbl: tbl}, nil
+	}
+	return c, nil
+}
+
+// chain implements a ChainedBootloader.
+type chain struct {
+	rootdir string
+	role    Role
+	elems   []Bootloader
+	// owners maps boot variables to bootloaders keeping them, other than
+	// the kernel loader
+	owners map[string]Bootloader
+}
+
+func (c *chain) Elements() []Bootloader {
+	return c.elems
+}
+
+func (c *chain) kernelLoader() Bootloader {
+	return c.elems[len(c.elems)-1]
+}
+
+func (c *chain) owner(name string) Bootloader {
+	if bl, ok := c.owners[name]; ok {
+		return bl
+	}
+	return c.kernelLoader()
+}
+
+// Name returns the name of the bootloader loading the kernel.
+func (c *chain) Name() string {
+	return c.kernelLoader().Name()
+}
+
+// Present returns true only when all bootloaders of the chain are present.
+func (c *chain) Present() (bool, error) {
+	for _, bl := range c.elems {
+		present, err := bl.Present()
+		if err != nil {
+			return false, fmt.Errorf("bootloader %q found but not usable: %v", bl.Name(), err)
+		}
+		if !present {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (c *chain) GetBootVars(names ...string) (map[string]string, error) {
+	out := make(map[string]string, len(names))
+	for _, bl := range c.elems {
+		var blNames []string
+		for _, name := range names {
+			if c.owner(name) == bl {
+				blNames = append(blNames, name)
+			}
+		}
+		if len(blNames) == 0 {
+			continue
+		}
+		values, err := bl.GetBootVars(blNames...)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func (c *chain) SetBootVars(values map[string]string) error {
+	for _, bl := range c.elems {
+		blValues := make(map[string]string)
+		for k, v := range values {
+			if c.owner(k) == bl {
+				blValues[k] = v
+			}
+		}
+		if len(blValues) == 0 {
+			continue
+		}
+		if err := bl.SetBootVars(blValues); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstallBootConfig installs the boot config of all bootloaders of the chain,
+// followed by the chain declaration.
+func (c *chain) InstallBootConfig(gadgetDir string, opts *Options) error {
+	for _, bl := range c.elems {
+		if err := bl.InstallBootConfig(gadgetDir, opts); err != nil {
+			return fmt.Errorf("cannot install boot config of %q: %v", bl.Name(), err)
+		}
+	}
+	if err := os.MkdirAll(c.rootdir, 0755); err != nil {
+		return err
+	}
+	return osutil.CopyFile(filepath.Join(gadgetDir, chainFile), filepath.Join(c.rootdir, chainFile), osutil.CopyFlagOverwrite)
+}
+
+func (c *chain) ExtractKernelAssets(s snap.PlaceInfo, snapf snap.Container) error {
+	return c.kernelLoader().ExtractKernelAssets(s, snapf)
+}
+
+func (c *chain) RemoveKernelAssets(s snap.PlaceInfo) error {
+	return c.kernelLoader().RemoveKernelAssets(s)
+}
+
+// trustedAssetsChain is a chain of bootloaders in which the kernel loader has
+// trusted assets. Trusted and managed assets of all bootloaders of the chain
+// are tracked.
+type trustedAssetsChain struct {
+	*chain
+	tbl TrustedAssetsBootloader
+}
+
+func (c *trustedAssetsChain) trustedElements() []TrustedAssetsBootloader {
+	var tbls []TrustedAssetsBootloader
+	for _, bl := range c.elems {
+		if tbl, ok := bl.(TrustedAssetsBootloader); ok {
+			tbls = append(tbls, tbl)
+		}
+	}
+	return tbls
+}
+
+func (c *trustedAssetsChain) ManagedAssets() []string {
+	var managed []string
+	for _, tbl := range c.trustedElements() {
+		managed = append(managed, tbl.ManagedAssets()...)
+	}
+	return managed
+}
+
+func (c *trustedAssetsChain) UpdateBootConfig(gadget snap.Container) (bool, error) {
+	updated := false
+	for _, tbl := range c.trustedElements() {
+		blUpdated, err := tbl.UpdateBootConfig(gadget)
+		if err != nil {
+			return false, fmt.Errorf("cannot update boot config of %q: %v", tbl.Name(), err)
+		}
+		updated = updated || blUpdated
+	}
+	return updated, nil
+}
+
+func (c *trustedAssetsChain) CommandLine(pieces CommandLineComponents) (string, error) {
+	return c.tbl.CommandLine(pieces)
+}
+
+func (c *trustedAssetsChain) CandidateCommandLine(pieces CommandLineComponents) (string, error) {
+	return c.tbl.CandidateCommandLine(pieces)
+}
+
+// TrustedAssets returns the trusted assets of all bootloaders of the chain, in
+// the order in which they are loaded.
+func (c *trustedAssetsChain) TrustedAssets() ([]string, error) {
+	var trusted []string
+	for _, tbl := range c.trustedElements() {
+		assets, err := tbl.TrustedAssets()
+		if err != nil {
+			return nil, err
+		}
+		trusted = append(trusted, assets...)
+	}
+	return trusted, nil
+}
+
+// chainPrefix returns the boot files of the bootloaders of the chain that are
+// loaded before the kernel loader.
+func (c *trustedAssetsChain) chainPrefix() ([]BootFile, error) {
+	var prefix []BootFile
+	for _, bl := range c.elems[:len(c.elems)-1] {
+		tbl, ok := bl.(TrustedAssetsBootloader)
+		if !ok {
+			continue
+		}
+		assets, err := tbl.TrustedAssets()
+		if err != nil {
+			return nil, err
+		}
+		for _, ta := range assets {
+			prefix = append(prefix, NewBootFile("", ta, c.role))
+		}
+	}
+	return prefix, nil
+}
+
+// RecoveryBootChain returns the load chain for recovery modes, starting with
+// the trusted assets of the bootloaders preceding the kernel loader.
+func (c *trustedAssetsChain) RecoveryBootChain(kernelPath string) ([]BootFile, error) {
+	prefix, err := c.chainPrefix()
+	if err != nil {
+		return nil, err
+	}
+	bootChain, err := c.tbl.RecoveryBootChain(kernelPath)
+	if err != nil {
+		return nil, err
+	}
+	return append(prefix, bootChain...), nil
+}
+
+// BootChain returns the load chain for run mode. The bootloaders preceding the
+// kernel loader are expected to be loaded only once from the recovery
+// bootloader partition, thus their trusted assets are placed at the beginning
+// of the chain.
+func (c *trustedAssetsChain) BootChain(runBl Bootloader, kernelPath string) ([]BootFile, error) {
+	prefix, err := c.chainPrefix()
+	if err != nil {
+		return nil, err
+	}
+	bootChain, err := c.tbl.BootChain(KernelLoader(runBl), kernelPath)
+	if err != nil {
+		return nil, err
+	}
+	return append(prefix, bootChain...), nil
+}
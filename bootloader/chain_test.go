@@ -0,0 +1,201 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type chainTestSuite struct {
+	baseBootenvTestSuite
+}
+
+var _ = Suite(&chainTestSuite{})
+
+func (s *chainTestSuite) mockChainDeclaration(c *C, dir, decl string) {
+	err := ioutil.WriteFile(filepath.Join(dir, "bootloader-chain"), []byte(decl), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *chainTestSuite) TestFindChainRoutesBootVars(c *C) {
+	bootloader.MockUbootFiles(c, s.rootdir, nil)
+	bootloader.MockGrubFiles(c, s.rootdir)
+	s.mockChainDeclaration(c, s.rootdir, `# u-boot SPL chaining into grub
+uboot snapd_spl_status
+
+grub
+`)
+
+	bl, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, IsNil)
+	cbl, ok := bl.(bootloader.ChainedBootloader)
+	c.Assert(ok, Equals, true)
+	c.Check(bl.Name(), Equals, "grub")
+	elems := cbl.Elements()
+	c.Assert(elems, HasLen, 2)
+	c.Check(elems[0].Name(), Equals, "uboot")
+	c.Check(elems[1].Name(), Equals, "grub")
+	c.Check(bootloader.KernelLoader(bl), Equals, elems[1])
+
+	err = bl.SetBootVars(map[string]string{
+		"snapd_spl_status": "ok",
+		"snap_mode":        "try",
+	})
+	c.Assert(err, IsNil)
+
+	// variables were written to the environment of the right element
+	splVars, err := bootloader.NewUboot(s.rootdir, nil).GetBootVars("snapd_spl_status", "snap_mode")
+	c.Assert(err, IsNil)
+	c.Check(splVars, DeepEquals, map[string]string{
+		"snapd_spl_status": "ok",
+		"snap_mode":        "",
+	})
+	grubVars, err := bootloader.NewGrub(s.rootdir, nil).GetBootVars("snapd_spl_status", "snap_mode")
+	c.Assert(err, IsNil)
+	c.Check(grubVars, DeepEquals, map[string]string{
+		"snapd_spl_status": "",
+		"snap_mode":        "try",
+	})
+
+	vars, err := bl.GetBootVars("snapd_spl_status", "snap_mode")
+	c.Assert(err, IsNil)
+	c.Check(vars, DeepEquals, map[string]string{
+		"snapd_spl_status": "ok",
+		"snap_mode":        "try",
+	})
+}
+
+func (s *chainTestSuite) TestFindChainErrors(c *C) {
+	bootloader.MockGrubFiles(c, s.rootdir)
+
+	for _, tc := range []struct {
+		decl string
+		err  string
+	}{{
+		decl: "grub\n",
+		err:  "cannot read bootloader chain declaration: chain must list at least 2 bootloaders",
+	}, {
+		decl: "grub\ngrub\n",
+		err:  `cannot read bootloader chain declaration: bootloader "grub" listed more than once`,
+	}, {
+		decl: "uboot foo\ngrub foo\n",
+		err:  `cannot read bootloader chain declaration: variable "foo" assigned to more than one bootloader`,
+	}, {
+		decl: "spl\ngrub\n",
+		err:  `cannot use bootloader chain: unknown bootloader "spl"`,
+	}, {
+		// uboot files are missing
+		decl: "uboot\ngrub\n",
+		err:  "bootloader chain declared but not all bootloaders are present",
+	}} {
+		s.mockChainDeclaration(c, s.rootdir, tc.decl)
+		bl, err := bootloader.Find(s.rootdir, nil)
+		c.Check(err, ErrorMatches, tc.err, Commentf("decl: %q", tc.decl))
+		c.Check(bl, IsNil)
+	}
+}
+
+func (s *chainTestSuite) TestForGadgetChainInstallBootConfig(c *C) {
+	gadgetDir := c.MkDir()
+	for _, name := range []string{"uboot.conf", "grub.conf"} {
+		err := ioutil.WriteFile(filepath.Join(gadgetDir, name), nil, 0644)
+		c.Assert(err, IsNil)
+	}
+	s.mockChainDeclaration(c, gadgetDir, "uboot\ngrub\n")
+
+	err := bootloader.InstallBootConfig(gadgetDir, s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(s.rootdir, "bootloader-chain"), testutil.FileEquals, "uboot\ngrub\n")
+	c.Check(filepath.Join(s.rootdir, "boot/uboot/uboot.env"), testutil.FilePresent)
+	c.Check(filepath.Join(s.rootdir, "boot/grub/grub.cfg"), testutil.FilePresent)
+
+	// and the chain can be found now
+	bl, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Check(bootloader.KernelLoader(bl).Name(), Equals, "grub")
+}
+
+func (s *chainTestSuite) TestChainTrustedAssets(c *C) {
+	spl := bootloadertest.Mock("spl", c.MkDir()).WithTrustedAssets()
+	spl.MockedPresent = true
+	spl.TrustedAssetsList = []string{"spl.bin"}
+	spl.ManagedAssetsList = []string{"spl.cfg"}
+	loader := bootloadertest.Mock("loader", c.MkDir()).WithTrustedAssets()
+	loader.MockedPresent = true
+	loader.TrustedAssetsList = []string{"loader.efi"}
+	loader.ManagedAssetsList = []string{"loader.cfg"}
+	loader.Updated = true
+	loader.RecoveryBootChainList = []bootloader.BootFile{
+		bootloader.NewBootFile("", "loader.efi", bootloader.RoleRecovery),
+		bootloader.NewBootFile("/snaps/pc-kernel_1.snap", "kernel.efi", bootloader.RoleRecovery),
+	}
+	loader.BootChainList = []bootloader.BootFile{
+		bootloader.NewBootFile("", "loader.efi", bootloader.RoleRecovery),
+		bootloader.NewBootFile("/snaps/pc-kernel_1.snap", "kernel.efi", bootloader.RoleRunMode),
+	}
+	s.AddCleanup(bootloader.MockAddBootloaderToFind(func(string, *bootloader.Options) bootloader.Bootloader {
+		return spl
+	}))
+	s.AddCleanup(bootloader.MockAddBootloaderToFind(func(string, *bootloader.Options) bootloader.Bootloader {
+		return loader
+	}))
+	s.mockChainDeclaration(c, s.rootdir, "spl\nloader\n")
+
+	bl, err := bootloader.Find(s.rootdir, &bootloader.Options{Role: bootloader.RoleRecovery})
+	c.Assert(err, IsNil)
+	tbl, ok := bl.(bootloader.TrustedAssetsBootloader)
+	c.Assert(ok, Equals, true)
+
+	c.Check(tbl.ManagedAssets(), DeepEquals, []string{"spl.cfg", "loader.cfg"})
+	trusted, err := tbl.TrustedAssets()
+	c.Assert(err, IsNil)
+	c.Check(trusted, DeepEquals, []string{"spl.bin", "loader.efi"})
+
+	updated, err := tbl.UpdateBootConfig(nil)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, true)
+	c.Check(spl.UpdateCalls, Equals, 1)
+	c.Check(loader.UpdateCalls, Equals, 1)
+
+	recoveryChain, err := tbl.RecoveryBootChain("/snaps/pc-kernel_1.snap")
+	c.Assert(err, IsNil)
+	c.Check(recoveryChain, DeepEquals, []bootloader.BootFile{
+		bootloader.NewBootFile("", "spl.bin", bootloader.RoleRecovery),
+		bootloader.NewBootFile("", "loader.efi", bootloader.RoleRecovery),
+		bootloader.NewBootFile("/snaps/pc-kernel_1.snap", "kernel.efi", bootloader.RoleRecovery),
+	})
+
+	bootChain, err := tbl.BootChain(bl, "/snaps/pc-kernel_1.snap")
+	c.Assert(err, IsNil)
+	c.Check(bootChain, DeepEquals, []bootloader.BootFile{
+		bootloader.NewBootFile("", "spl.bin", bootloader.RoleRecovery),
+		bootloader.NewBootFile("", "loader.efi", bootloader.RoleRecovery),
+		bootloader.NewBootFile("/snaps/pc-kernel_1.snap", "kernel.efi", bootloader.RoleRunMode),
+	})
+	// the kernel loader of the run mode chain was passed
+	c.Check(loader.BootChainRunBl, DeepEquals, []bootloader.Bootloader{loader})
+}
@@ -0,0 +1,261 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snapdenv"
+)
+
+// EmulatedJournalEnvVar is the environment variable which, when set to the
+// path of a journal file, makes Find and ForGadget return the emulated
+// bootloader. The emulated bootloader carries out no actual boot operation,
+// it records them in the journal instead, so that integration tests can
+// check the boot behavior of snapd on machines without a real bootloader.
+// It is only honoured when snapd runs in testing mode, see snapdenv.Testing.
+const EmulatedJournalEnvVar = "SNAPD_EMULATED_BOOTLOADER_JOURNAL"
+
+// Operations recorded in the journal of the emulated bootloader.
+const (
+	EmulatedOpSetBootVars                 = "set-boot-vars"
+	EmulatedOpSetRecoverySystemEnv        = "set-recovery-system-env"
+	EmulatedOpInstallBootConfig           = "install-boot-config"
+	EmulatedOpExtractKernelAssets         = "extract-kernel-assets"
+	EmulatedOpExtractRecoveryKernelAssets = "extract-recovery-kernel-assets"
+	EmulatedOpRemoveKernelAssets          = "remove-kernel-assets"
+)
+
+// EmulatedJournalEntry is an operation recorded in the journal of the
+// emulated bootloader. The journal holds one JSON encoded entry per line.
+type EmulatedJournalEntry struct {
+	Time time.Time `json:"time"`
+	Op   string    `json:"op"`
+	// RootDir and Role identify the bootloader which carried out the
+	// operation, as a system has distinct run mode and recovery
+	// bootloaders.
+	RootDir string `json:"root-dir"`
+	Role    Role   `json:"role,omitempty"`
+	// Values are the variables that were set, an empty value unsets a
+	// variable.
+	Values map[string]string `json:"values,omitempty"`
+	// RecoverySystemDir is the recovery system the operation applies
+	// to, if any.
+	RecoverySystemDir string `json:"recovery-system-dir,omitempty"`
+	// Snap is the file name of the kernel snap whose assets were
+	// extracted or removed.
+	Snap string `json:"snap,omitempty"`
+	// GadgetDir is the gadget from which the boot config was installed.
+	GadgetDir string `json:"gadget-dir,omitempty"`
+}
+
+var (
+	_ RecoveryAwareBootloader                = (*emulated)(nil)
+	_ ExtractedRecoveryKernelImageBootloader = (*emulated)(nil)
+)
+
+type emulated struct {
+	journal string
+	rootdir string
+	role    Role
+}
+
+// newEmulated returns the emulated bootloader if it was requested through
+// the environment of a testing snapd, or nil otherwise.
+func newEmulated(rootdir string, opts *Options) Bootloader {
+	if !snapdenv.Testing() {
+		return nil
+	}
+	journal := os.Getenv(EmulatedJournalEnvVar)
+	if journal == "" {
+		return nil
+	}
+	e := &emulated{
+		journal: journal,
+		rootdir: rootdir,
+	}
+	if opts != nil {
+		e.role = opts.Role
+	}
+	return e
+}
+
+// ReadEmulatedJournal returns the entries of the journal of the emulated
+// bootloader at the given path, oldest first.
+func ReadEmulatedJournal(path string) ([]EmulatedJournalEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []EmulatedJournalEntry
+	scanner := bufio.NewScanner(f)
+	// values of boot variables can be rather long
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry EmulatedJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("cannot decode emulated bootloader journal entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (e *emulated) record(entry EmulatedJournalEntry) error {
+	entry.Time = time.Now()
+	entry.RootDir = e.rootdir
+	entry.Role = e.role
+	b, err := json.Marshal(&entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.journal), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(e.journal, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// a single write so that entries of different processes do not
+	// interleave
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// env returns the variables of the bootloader, or of the given recovery
+// system, by replaying the journal.
+func (e *emulated) env(op, recoverySystemDir string) (map[string]string, error) {
+	entries, err := ReadEmulatedJournal(e.journal)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for _, entry := range entries {
+		if entry.Op != op || entry.RootDir != e.rootdir || entry.Role != e.role || entry.RecoverySystemDir != recoverySystemDir {
+			continue
+		}
+		for k, v := range entry.Values {
+			if v == "" {
+				delete(env, k)
+				continue
+			}
+			env[k] = v
+		}
+	}
+	return env, nil
+}
+
+func (e *emulated) Name() string {
+	return "emulated"
+}
+
+func (e *emulated) Present() (bool, error) {
+	return true, nil
+}
+
+func (e *emulated) GetBootVars(names ...string) (map[string]string, error) {
+	env, err := e.env(EmulatedOpSetBootVars, "")
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		out[name] = env[name]
+	}
+	return out, nil
+}
+
+func (e *emulated) SetBootVars(values map[string]string) error {
+	return e.record(EmulatedJournalEntry{
+		Op:     EmulatedOpSetBootVars,
+		Values: values,
+	})
+}
+
+func (e *emulated) InstallBootConfig(gadgetDir string, opts *Options) error {
+	return e.record(EmulatedJournalEntry{
+		Op:        EmulatedOpInstallBootConfig,
+		GadgetDir: gadgetDir,
+	})
+}
+
+func (e *emulated) ExtractKernelAssets(s snap.PlaceInfo, snapf snap.Container) error {
+	return e.record(EmulatedJournalEntry{
+		Op:   EmulatedOpExtractKernelAssets,
+		Snap: s.Filename(),
+	})
+}
+
+func (e *emulated) RemoveKernelAssets(s snap.PlaceInfo) error {
+	return e.record(EmulatedJournalEntry{
+		Op:   EmulatedOpRemoveKernelAssets,
+		Snap: s.Filename(),
+	})
+}
+
+func (e *emulated) SetRecoverySystemEnv(recoverySystemDir string, values map[string]string) error {
+	if recoverySystemDir == "" {
+		return fmt.Errorf("internal error: recoverySystemDir unset")
+	}
+	return e.record(EmulatedJournalEntry{
+		Op:                EmulatedOpSetRecoverySystemEnv,
+		RecoverySystemDir: recoverySystemDir,
+		Values:            values,
+	})
+}
+
+func (e *emulated) GetRecoverySystemEnv(recoverySystemDir string, key string) (string, error) {
+	if recoverySystemDir == "" {
+		return "", fmt.Errorf("internal error: recoverySystemDir unset")
+	}
+	env, err := e.env(EmulatedOpSetRecoverySystemEnv, recoverySystemDir)
+	if err != nil {
+		return "", err
+	}
+	return env[key], nil
+}
+
+func (e *emulated) ExtractRecoveryKernelAssets(recoverySystemDir string, s snap.PlaceInfo, snapf snap.Container) error {
+	if recoverySystemDir == "" {
+		return fmt.Errorf("internal error: recoverySystemDir unset")
+	}
+	return e.record(EmulatedJournalEntry{
+		Op:                EmulatedOpExtractRecoveryKernelAssets,
+		RecoverySystemDir: recoverySystemDir,
+		Snap:              s.Filename(),
+	})
+}
@@ -0,0 +1,204 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snapdenv"
+)
+
+type emulatedTestSuite struct {
+	baseBootenvTestSuite
+
+	journal string
+}
+
+var _ = Suite(&emulatedTestSuite{})
+
+func (s *emulatedTestSuite) SetUpTest(c *C) {
+	s.baseBootenvTestSuite.SetUpTest(c)
+
+	s.journal = filepath.Join(c.MkDir(), "journal", "boot.json")
+	os.Setenv(bootloader.EmulatedJournalEnvVar, s.journal)
+	s.AddCleanup(func() { os.Unsetenv(bootloader.EmulatedJournalEnvVar) })
+	s.AddCleanup(snapdenv.MockTesting(true))
+}
+
+func (s *emulatedTestSuite) readJournal(c *C) []bootloader.EmulatedJournalEntry {
+	entries, err := bootloader.ReadEmulatedJournal(s.journal)
+	c.Assert(err, IsNil)
+	for i := range entries {
+		c.Check(entries[i].Time.IsZero(), Equals, false)
+		entries[i].Time = time.Time{}
+	}
+	return entries
+}
+
+func (s *emulatedTestSuite) TestFindNotRequested(c *C) {
+	os.Unsetenv(bootloader.EmulatedJournalEnvVar)
+
+	_, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, Equals, bootloader.ErrBootloader)
+}
+
+func (s *emulatedTestSuite) TestFindNotTesting(c *C) {
+	restore := snapdenv.MockTesting(false)
+	defer restore()
+
+	// the journal is ignored outside of testing mode
+	bootloader.MockGrubFiles(c, s.rootdir)
+
+	bl, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Check(bl.Name(), Equals, "grub")
+
+	_, err = bootloader.ForGadget(c.MkDir(), s.rootdir, nil)
+	c.Assert(err, Equals, bootloader.ErrBootloader)
+}
+
+func (s *emulatedTestSuite) TestFindAndForGadget(c *C) {
+	// the emulated bootloader takes precedence over real ones
+	bootloader.MockGrubFiles(c, s.rootdir)
+
+	bl, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Check(bl.Name(), Equals, "emulated")
+	present, err := bl.Present()
+	c.Assert(err, IsNil)
+	c.Check(present, Equals, true)
+
+	bl, err = bootloader.ForGadget(c.MkDir(), s.rootdir, nil)
+	c.Assert(err, IsNil)
+	c.Check(bl.Name(), Equals, "emulated")
+}
+
+func (s *emulatedTestSuite) TestBootVarsRecorded(c *C) {
+	bl, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, IsNil)
+
+	err = bl.SetBootVars(map[string]string{
+		"snap_mode":       "try",
+		"snap_try_kernel": "pc-kernel_2.snap",
+	})
+	c.Assert(err, IsNil)
+	err = bl.SetBootVars(map[string]string{
+		"snap_mode":       "",
+		"snap_try_kernel": "",
+		"snap_kernel":     "pc-kernel_2.snap",
+	})
+	c.Assert(err, IsNil)
+
+	vars, err := bl.GetBootVars("snap_mode", "snap_try_kernel", "snap_kernel")
+	c.Assert(err, IsNil)
+	c.Check(vars, DeepEquals, map[string]string{
+		"snap_mode":       "",
+		"snap_try_kernel": "",
+		"snap_kernel":     "pc-kernel_2.snap",
+	})
+
+	// the variables of another bootloader are separate
+	recoveryBl, err := bootloader.Find(s.rootdir, &bootloader.Options{Role: bootloader.RoleRecovery})
+	c.Assert(err, IsNil)
+	vars, err = recoveryBl.GetBootVars("snap_kernel")
+	c.Assert(err, IsNil)
+	c.Check(vars, DeepEquals, map[string]string{"snap_kernel": ""})
+
+	entries := s.readJournal(c)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].Op, Equals, bootloader.EmulatedOpSetBootVars)
+	c.Check(entries[0].RootDir, Equals, s.rootdir)
+	c.Check(entries[0].Values, DeepEquals, map[string]string{
+		"snap_mode":       "try",
+		"snap_try_kernel": "pc-kernel_2.snap",
+	})
+	c.Check(entries[1].Values, DeepEquals, map[string]string{
+		"snap_mode":       "",
+		"snap_try_kernel": "",
+		"snap_kernel":     "pc-kernel_2.snap",
+	})
+}
+
+func (s *emulatedTestSuite) TestKernelAndBootConfigRecorded(c *C) {
+	gadgetDir := c.MkDir()
+	opts := &bootloader.Options{Role: bootloader.RoleRecovery}
+	bl, err := bootloader.ForGadget(gadgetDir, s.rootdir, opts)
+	c.Assert(err, IsNil)
+	rbl, ok := bl.(bootloader.ExtractedRecoveryKernelImageBootloader)
+	c.Assert(ok, Equals, true)
+
+	kernel := snap.MinimalPlaceInfo("pc-kernel", snap.R(1))
+	c.Assert(bl.InstallBootConfig(gadgetDir, opts), IsNil)
+	c.Assert(bl.ExtractKernelAssets(kernel, nil), IsNil)
+	c.Assert(rbl.ExtractRecoveryKernelAssets("systems/20230101", kernel, nil), IsNil)
+	c.Assert(bl.RemoveKernelAssets(kernel), IsNil)
+
+	c.Check(s.readJournal(c), DeepEquals, []bootloader.EmulatedJournalEntry{
+		{Op: bootloader.EmulatedOpInstallBootConfig, RootDir: s.rootdir, Role: bootloader.RoleRecovery, GadgetDir: gadgetDir},
+		{Op: bootloader.EmulatedOpExtractKernelAssets, RootDir: s.rootdir, Role: bootloader.RoleRecovery, Snap: "pc-kernel_1.snap"},
+		{Op: bootloader.EmulatedOpExtractRecoveryKernelAssets, RootDir: s.rootdir, Role: bootloader.RoleRecovery, RecoverySystemDir: "systems/20230101", Snap: "pc-kernel_1.snap"},
+		{Op: bootloader.EmulatedOpRemoveKernelAssets, RootDir: s.rootdir, Role: bootloader.RoleRecovery, Snap: "pc-kernel_1.snap"},
+	})
+}
+
+func (s *emulatedTestSuite) TestRecoverySystemEnv(c *C) {
+	bl, err := bootloader.Find(s.rootdir, &bootloader.Options{Role: bootloader.RoleRecovery})
+	c.Assert(err, IsNil)
+	rbl, ok := bl.(bootloader.RecoveryAwareBootloader)
+	c.Assert(ok, Equals, true)
+
+	err = rbl.SetRecoverySystemEnv("systems/20230101", map[string]string{"snapd_recovery_kernel": "/snaps/pc-kernel_1.snap"})
+	c.Assert(err, IsNil)
+	err = rbl.SetRecoverySystemEnv("systems/20230202", map[string]string{"snapd_recovery_kernel": "/snaps/pc-kernel_2.snap"})
+	c.Assert(err, IsNil)
+
+	v, err := rbl.GetRecoverySystemEnv("systems/20230101", "snapd_recovery_kernel")
+	c.Assert(err, IsNil)
+	c.Check(v, Equals, "/snaps/pc-kernel_1.snap")
+	v, err = rbl.GetRecoverySystemEnv("systems/20230202", "snapd_recovery_kernel")
+	c.Assert(err, IsNil)
+	c.Check(v, Equals, "/snaps/pc-kernel_2.snap")
+
+	// not mixed with the bootloader variables
+	vars, err := bl.GetBootVars("snapd_recovery_kernel")
+	c.Assert(err, IsNil)
+	c.Check(vars, DeepEquals, map[string]string{"snapd_recovery_kernel": ""})
+
+	err = rbl.SetRecoverySystemEnv("", nil)
+	c.Assert(err, ErrorMatches, "internal error: recoverySystemDir unset")
+}
+
+func (s *emulatedTestSuite) TestReadJournalErrors(c *C) {
+	entries, err := bootloader.ReadEmulatedJournal(filepath.Join(c.MkDir(), "missing"))
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+
+	c.Assert(os.MkdirAll(filepath.Dir(s.journal), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.journal, []byte("{not json\n"), 0644), IsNil)
+	_, err = bootloader.ReadEmulatedJournal(s.journal)
+	c.Assert(err, ErrorMatches, "cannot decode emulated bootloader journal entry: .*")
+}
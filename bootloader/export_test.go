@@ -128,40 +128,49 @@ func MockLkFiles(c *C, rootdir string, opts *Options) (restore func()) {
 				{
 					PartitionLabel: "snapbootsel",
 					PartitionUUID:  "snapbootsel-partuuid",
+					DiskIndex:      1,
 				},
 				{
 					PartitionLabel: "snapbootselbak",
 					PartitionUUID:  "snapbootselbak-partuuid",
+					DiskIndex:      2,
 				},
 				{
 					PartitionLabel: "snaprecoverysel",
 					PartitionUUID:  "snaprecoverysel-partuuid",
+					DiskIndex:      3,
 				},
 				{
 					PartitionLabel: "snaprecoveryselbak",
 					PartitionUUID:  "snaprecoveryselbak-partuuid",
+					DiskIndex:      4,
 				},
 				// for run mode kernel snaps
 				{
 					PartitionLabel: "boot_a",
 					PartitionUUID:  "boot-a-partuuid",
+					DiskIndex:      5,
 				},
 				{
 					PartitionLabel: "boot_b",
 					PartitionUUID:  "boot-b-partuuid",
+					DiskIndex:      6,
 				},
 				// for recovery system kernel snaps
 				{
 					PartitionLabel: "boot_ra",
 					PartitionUUID:  "boot-ra-partuuid",
+					DiskIndex:      7,
 				},
 				{
 					PartitionLabel: "boot_rb",
 					PartitionUUID:  "boot-rb-partuuid",
+					DiskIndex:      8,
 				},
 			},
 			DiskHasPartitions: true,
 			DevNum:            "lk-boot-disk-dev-num",
+			DevNode:           "/dev/lk-boot-disk",
 		}
 
 		m := map[string]*disks.MockDiskMapping{
@@ -339,10 +339,11 @@ func (g *grub) TryKernel() (snap.PlaceInfo, error) {
 }
 
 // UpdateBootConfig updates the grub boot config only if it is already managed
-// and has a lower edition.
+// and has a lower edition. The boot config may come from the gadget snap
+// when it carries one with an edition higher than the built-in asset.
 //
 // Implements TrustedAssetsBootloader for the grub bootloader.
-func (g *grub) UpdateBootConfig() (bool, error) {
+func (g *grub) UpdateBootConfig(gadget snap.Container) (bool, error) {
 	// XXX: do we need to take opts here?
 	bootScriptName := "grub.cfg"
 	currentBootConfig := filepath.Join(g.dir(), "grub.cfg")
@@ -350,7 +351,7 @@ func (g *grub) UpdateBootConfig() (bool, error) {
 		// use the recovery asset when asked to do so
 		bootScriptName = "grub-recovery.cfg"
 	}
-	return genericUpdateBootConfigFromAssets(currentBootConfig, bootScriptName)
+	return genericUpdateBootConfigFromAssets(currentBootConfig, bootScriptName, gadget)
 }
 
 // ManagedAssets returns a list relative paths to boot assets inside the root
@@ -403,10 +404,15 @@ func (g *grub) commandLineForEdition(edition uint, pieces CommandLineComponents)
 // static arguments corresponding to the on-disk boot asset edition, and
 // any extra arguments or a separate set of arguments provided in the
 // components. The command line may be different when using a recovery
-// bootloader.
+// bootloader. An on-disk boot asset with the same edition as the one carried
+// by the gadget snap is verified to be that asset.
 //
 // Implements TrustedAssetsBootloader for the grub bootloader.
 func (g *grub) CommandLine(pieces CommandLineComponents) (string, error) {
+	assetName := "grub.cfg"
+	if g.recovery {
+		assetName = "grub-recovery.cfg"
+	}
 	currentBootConfig := filepath.Join(g.dir(), "grub.cfg")
 	edition, err := editionFromDiskConfigAsset(currentBootConfig)
 	if err != nil {
@@ -419,12 +425,16 @@ func (g *grub) CommandLine(pieces CommandLineComponents) (string, error) {
 		// the internal boot asset which is compatible with grub.cfg
 		// used before we started writing out the files ourselves
 		edition = 1
+	} else if err := verifyConfigAssetFromGadget(currentBootConfig, edition, assetName, pieces.Gadget); err != nil {
+		return "", fmt.Errorf("cannot use current boot config: %v", err)
 	}
 	return g.commandLineForEdition(edition, pieces)
 }
 
-// CandidateCommandLine is similar to CommandLine, but uses the current
-// edition of managed built-in boot assets as reference.
+// CandidateCommandLine is similar to CommandLine, but uses the edition of
+// the boot asset that would be installed by an update as reference, that
+// is the built-in one or the one carried by the gadget snap when it has a
+// higher edition.
 //
 // Implements TrustedAssetsBootloader for the grub bootloader.
 func (g *grub) CandidateCommandLine(pieces CommandLineComponents) (string, error) {
@@ -432,11 +442,11 @@ func (g *grub) CandidateCommandLine(pieces CommandLineComponents) (string, error
 	if g.recovery {
 		assetName = "grub-recovery.cfg"
 	}
-	edition, err := editionFromInternalConfigAsset(assetName)
+	bc, err := candidateConfigAsset(assetName, pieces.Gadget)
 	if err != nil {
 		return "", err
 	}
-	return g.commandLineForEdition(edition, pieces)
+	return g.commandLineForEdition(bc.Edition(), pieces)
 }
 
 // staticCommandLineForGrubAssetEdition fetches a static command line for given
@@ -36,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdir"
 	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -666,7 +667,7 @@ this is mocked grub-recovery.conf
 	tg, ok := g.(bootloader.TrustedAssetsBootloader)
 	c.Assert(ok, Equals, true)
 	// install the recovery boot script
-	updated, err := tg.UpdateBootConfig()
+	updated, err := tg.UpdateBootConfig(nil)
 	c.Assert(err, IsNil)
 	c.Assert(updated, Equals, false)
 	c.Assert(filepath.Join(s.grubEFINativeDir(), "grub.cfg"), testutil.FileEquals, `recovery boot script`)
@@ -692,7 +693,7 @@ this is mocked grub.conf
 	tg, ok := g.(bootloader.TrustedAssetsBootloader)
 	c.Assert(ok, Equals, true)
 	// install the recovery boot script
-	updated, err := tg.UpdateBootConfig()
+	updated, err := tg.UpdateBootConfig(nil)
 	c.Assert(err, IsNil)
 	c.Assert(updated, Equals, true)
 	// the recovery boot asset was picked
@@ -714,7 +715,7 @@ func (s *grubTestSuite) testBootUpdateBootConfigUpdates(c *C, oldConfig, newConf
 
 	tg, ok := g.(bootloader.TrustedAssetsBootloader)
 	c.Assert(ok, Equals, true)
-	updated, err := tg.UpdateBootConfig()
+	updated, err := tg.UpdateBootConfig(nil)
 	c.Assert(err, IsNil)
 	c.Assert(updated, Equals, update)
 	if update {
@@ -770,6 +771,105 @@ this is updated grub.cfg
 	s.testBootUpdateBootConfigUpdates(c, oldConfig, newConfig, updateApplied)
 }
 
+func (s *grubTestSuite) testBootUpdateBootConfigFromGadget(c *C, internalConfig, gadgetConfig, expectedConfig string) {
+	oldConfig := `# Snapd-Boot-Config-Edition: 2
+boot script
+`
+	s.makeFakeGrubEFINativeEnv(c, []byte(oldConfig))
+	restore := assets.MockInternal("grub.cfg", []byte(internalConfig))
+	defer restore()
+
+	gadgetDir := c.MkDir()
+	err := os.MkdirAll(filepath.Join(gadgetDir, "boot-assets"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(gadgetDir, "boot-assets/grub.cfg"), []byte(gadgetConfig), 0644)
+	c.Assert(err, IsNil)
+
+	opts := &bootloader.Options{NoSlashBoot: true}
+	tg, ok := bootloader.NewGrub(s.rootdir, opts).(bootloader.TrustedAssetsBootloader)
+	c.Assert(ok, Equals, true)
+	updated, err := tg.UpdateBootConfig(snapdir.New(gadgetDir))
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, expectedConfig != oldConfig)
+	c.Check(filepath.Join(s.grubEFINativeDir(), "grub.cfg"), testutil.FileEquals, expectedConfig)
+}
+
+func (s *grubTestSuite) TestBootUpdateBootConfigFromGadgetHigherEdition(c *C) {
+	gadgetConfig := `# Snapd-Boot-Config-Edition: 5
+gadget boot script
+`
+	internalConfig := `# Snapd-Boot-Config-Edition: 3
+internal boot script
+`
+	// the gadget asset has a higher edition and is used
+	s.testBootUpdateBootConfigFromGadget(c, internalConfig, gadgetConfig, gadgetConfig)
+}
+
+func (s *grubTestSuite) TestBootUpdateBootConfigFromGadgetLowerEdition(c *C) {
+	gadgetConfig := `# Snapd-Boot-Config-Edition: 3
+gadget boot script
+`
+	internalConfig := `# Snapd-Boot-Config-Edition: 4
+internal boot script
+`
+	// the built-in asset wins
+	s.testBootUpdateBootConfigFromGadget(c, internalConfig, gadgetConfig, internalConfig)
+}
+
+func (s *grubTestSuite) TestBootUpdateBootConfigFromGadgetNotNewerThanCurrent(c *C) {
+	oldConfig := `# Snapd-Boot-Config-Edition: 2
+boot script
+`
+	gadgetConfig := `# Snapd-Boot-Config-Edition: 2
+gadget boot script
+`
+	internalConfig := `# Snapd-Boot-Config-Edition: 1
+internal boot script
+`
+	// neither asset is newer than the one on disk
+	s.testBootUpdateBootConfigFromGadget(c, internalConfig, gadgetConfig, oldConfig)
+}
+
+func (s *grubTestSuite) TestBootUpdateBootConfigFromGadgetErrors(c *C) {
+	oldConfig := `# Snapd-Boot-Config-Edition: 2
+boot script
+`
+	s.makeFakeGrubEFINativeEnv(c, []byte(oldConfig))
+	restore := assets.MockInternal("grub.cfg", []byte(`# Snapd-Boot-Config-Edition: 3
+internal boot script
+`))
+	defer restore()
+
+	opts := &bootloader.Options{NoSlashBoot: true}
+	tg, ok := bootloader.NewGrub(s.rootdir, opts).(bootloader.TrustedAssetsBootloader)
+	c.Assert(ok, Equals, true)
+
+	for _, tc := range []struct {
+		gadgetConfig string
+		err          string
+	}{{
+		gadgetConfig: "unmanaged boot script\n",
+		err:          `cannot use boot config asset "grub.cfg" from gadget: missing edition header`,
+	}, {
+		gadgetConfig: "# Snapd-Boot-Config-Edition: foo\n",
+		err:          `cannot use boot config asset "grub.cfg" from gadget: cannot parse asset edition: .*`,
+	}, {
+		gadgetConfig: "",
+		err:          `cannot use boot config asset "grub.cfg" from gadget: cannot read config asset: unexpected EOF`,
+	}} {
+		gadgetDir := c.MkDir()
+		err := os.MkdirAll(filepath.Join(gadgetDir, "boot-assets"), 0755)
+		c.Assert(err, IsNil)
+		err = ioutil.WriteFile(filepath.Join(gadgetDir, "boot-assets/grub.cfg"), []byte(tc.gadgetConfig), 0644)
+		c.Assert(err, IsNil)
+
+		updated, err := tg.UpdateBootConfig(snapdir.New(gadgetDir))
+		c.Check(err, ErrorMatches, tc.err)
+		c.Check(updated, Equals, false)
+		c.Check(filepath.Join(s.grubEFINativeDir(), "grub.cfg"), testutil.FileEquals, oldConfig)
+	}
+}
+
 func (s *grubTestSuite) TestBootUpdateBootConfigTrivialErr(c *C) {
 	if os.Geteuid() == 0 {
 		c.Skip("the test cannot be run by the root user")
@@ -797,7 +897,7 @@ this is updated grub.cfg
 	c.Assert(err, IsNil)
 	defer os.Chmod(s.grubEFINativeDir(), 0755)
 
-	updated, err := tg.UpdateBootConfig()
+	updated, err := tg.UpdateBootConfig(nil)
 	c.Assert(err, ErrorMatches, "cannot load existing config asset: .*/EFI/ubuntu/grub.cfg: permission denied")
 	c.Assert(updated, Equals, false)
 	err = os.Chmod(s.grubEFINativeDir(), 0555)
@@ -808,7 +908,7 @@ this is updated grub.cfg
 	// writing out new config fails
 	err = os.Chmod(s.grubEFINativeDir(), 0111)
 	c.Assert(err, IsNil)
-	updated, err = tg.UpdateBootConfig()
+	updated, err = tg.UpdateBootConfig(nil)
 	c.Assert(err, ErrorMatches, `open .*/EFI/ubuntu/grub.cfg\..+: permission denied`)
 	c.Assert(updated, Equals, false)
 	c.Assert(filepath.Join(s.grubEFINativeDir(), "grub.cfg"), testutil.FileEquals, oldConfig)
@@ -1046,6 +1146,119 @@ boot script
 	c.Check(args, Equals, `snapd_recovery_mode=recover snapd_recovery_system=20200202 full args set`)
 }
 
+func (s *grubTestSuite) TestCommandLineWithGadgetAsset(c *C) {
+	gadgetConfig := `# Snapd-Boot-Config-Edition: 5
+gadget boot script
+`
+	s.makeFakeGrubEFINativeEnv(c, []byte(`# Snapd-Boot-Config-Edition: 2
+boot script
+`))
+	restore := assets.MockInternal("grub.cfg", []byte(`# Snapd-Boot-Config-Edition: 3
+internal boot script
+`))
+	defer restore()
+	restore = assets.MockSnippetsForEdition("grub.cfg:static-cmdline", []assets.ForEditions{
+		{FirstEdition: 1, Snippet: []byte(`edition=1`)},
+		{FirstEdition: 3, Snippet: []byte(`edition=3`)},
+		{FirstEdition: 5, Snippet: []byte(`edition=5`)},
+	})
+	defer restore()
+
+	gadgetDir := c.MkDir()
+	err := os.MkdirAll(filepath.Join(gadgetDir, "boot-assets"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(gadgetDir, "boot-assets/grub.cfg"), []byte(gadgetConfig), 0644)
+	c.Assert(err, IsNil)
+	gadget := snapdir.New(gadgetDir)
+
+	tg := bootloader.NewGrub(s.rootdir, &bootloader.Options{NoSlashBoot: true}).(bootloader.TrustedAssetsBootloader)
+
+	// the candidate is the asset from the gadget
+	args, err := tg.CandidateCommandLine(bootloader.CommandLineComponents{
+		ModeArg: "snapd_recovery_mode=run",
+		Gadget:  gadget,
+	})
+	c.Assert(err, IsNil)
+	c.Check(args, Equals, `snapd_recovery_mode=run edition=5`)
+	// which is then installed
+	updated, err := tg.UpdateBootConfig(gadget)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, true)
+	// and is current now
+	args, err = tg.CommandLine(bootloader.CommandLineComponents{
+		ModeArg: "snapd_recovery_mode=run",
+		Gadget:  gadget,
+	})
+	c.Assert(err, IsNil)
+	c.Check(args, Equals, `snapd_recovery_mode=run edition=5`)
+
+	// the boot config on disk is verified against the gadget asset
+	s.makeFakeGrubEFINativeEnv(c, []byte(`# Snapd-Boot-Config-Edition: 5
+tampered boot script
+`))
+	_, err = tg.CommandLine(bootloader.CommandLineComponents{
+		ModeArg: "snapd_recovery_mode=run",
+		Gadget:  gadget,
+	})
+	c.Assert(err, ErrorMatches, `cannot use current boot config: boot config with edition 5 has hash [0-9a-f]+, expected [0-9a-f]+ from the gadget asset`)
+}
+
+func (s *grubTestSuite) TestCommandLineWithGadgetAssetSameEdition(c *C) {
+	internalConfig := `# Snapd-Boot-Config-Edition: 3
+internal boot script
+`
+	s.makeFakeGrubEFINativeEnv(c, []byte(internalConfig))
+	restore := assets.MockInternal("grub.cfg", []byte(internalConfig))
+	defer restore()
+	restore = assets.MockSnippetsForEdition("grub.cfg:static-cmdline", []assets.ForEditions{
+		{FirstEdition: 1, Snippet: []byte(`edition=1`)},
+		{FirstEdition: 3, Snippet: []byte(`edition=3`)},
+	})
+	defer restore()
+
+	gadgetDir := c.MkDir()
+	err := os.MkdirAll(filepath.Join(gadgetDir, "boot-assets"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(gadgetDir, "boot-assets/grub.cfg"), []byte(`# Snapd-Boot-Config-Edition: 3
+gadget boot script
+`), 0644)
+	c.Assert(err, IsNil)
+	gadget := snapdir.New(gadgetDir)
+
+	tg := bootloader.NewGrub(s.rootdir, &bootloader.Options{NoSlashBoot: true}).(bootloader.TrustedAssetsBootloader)
+
+	// the gadget asset does not have a higher edition, the built-in one
+	// is kept
+	updated, err := tg.UpdateBootConfig(gadget)
+	c.Assert(err, IsNil)
+	c.Check(updated, Equals, false)
+	c.Check(filepath.Join(s.grubEFINativeDir(), "grub.cfg"), testutil.FileEquals, internalConfig)
+
+	// and is accepted as the current boot config
+	args, err := tg.CommandLine(bootloader.CommandLineComponents{
+		ModeArg: "snapd_recovery_mode=run",
+		Gadget:  gadget,
+	})
+	c.Assert(err, IsNil)
+	c.Check(args, Equals, `snapd_recovery_mode=run edition=3`)
+	args, err = tg.CandidateCommandLine(bootloader.CommandLineComponents{
+		ModeArg: "snapd_recovery_mode=run",
+		Gadget:  gadget,
+	})
+	c.Assert(err, IsNil)
+	c.Check(args, Equals, `snapd_recovery_mode=run edition=3`)
+
+	// a boot config of that edition which is neither asset is rejected
+	s.makeFakeGrubEFINativeEnv(c, []byte(`# Snapd-Boot-Config-Edition: 3
+tampered boot script
+`))
+	_, err = tg.CommandLine(bootloader.CommandLineComponents{
+		ModeArg: "snapd_recovery_mode=run",
+		Gadget:  gadget,
+	})
+	c.Assert(err, ErrorMatches, `cannot use current boot config: boot config with edition 3 has hash [0-9a-f]+, expected [0-9a-f]+ from the gadget asset`)
+}
+
 func (s *grubTestSuite) TestCommandLineReal(c *C) {
 	grubCfg := `# Snapd-Boot-Config-Edition: 1
 boot script
@@ -351,7 +351,19 @@ func (l *lk) SetBootVars(values map[string]string) error {
 	}
 
 	if dirty {
-		return env.Save()
+		if err := env.Save(); err != nil {
+			return err
+		}
+	}
+
+	// the slot attributes are checked even if the environment did not
+	// change, as they may have been left behind by an earlier update
+	useSlots, err := l.usesSlotAttributes()
+	if err != nil {
+		return err
+	}
+	if useSlots {
+		return l.updateSlotAttributes(env)
 	}
 
 	return nil
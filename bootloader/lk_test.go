@@ -37,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type lkTestSuite struct {
@@ -533,3 +534,163 @@ func (s *lkTestSuite) TestExtractRecoveryKernelAssetsAtRuntime(c *C) {
 
 // TODO:UC20: when runtime addition (and deletion) of recovery systems is
 //            implemented, add tests for that here with lkenv
+
+func (s *lkTestSuite) mockSlotAttributes(c *C, attrs map[uint64]string) (sfdisk *testutil.MockCmd, attrsDir string) {
+	attrsDir = c.MkDir()
+	for index, attr := range attrs {
+		c.Assert(ioutil.WriteFile(filepath.Join(attrsDir, fmt.Sprintf("attrs-%d", index)), []byte(attr+"\n"), 0644), IsNil)
+	}
+	sfdisk = testutil.MockCommand(c, "sfdisk", fmt.Sprintf(`
+if [ "$1" = "--part-attrs" ]; then
+	cat %[1]s/attrs-"$3"
+else
+	echo "$5" > %[1]s/attrs-"$4"
+fi
+`, attrsDir))
+	return sfdisk, attrsDir
+}
+
+func (s *lkTestSuite) setupSlotKernels(c *C, l bootloader.Bootloader) {
+	f, err := bootloader.LkConfigFile(l)
+	c.Assert(err, IsNil)
+	env := lkenv.NewEnv(f, "", lkenv.V2Run)
+	c.Assert(env.Load(), IsNil)
+	c.Assert(env.SetBootPartitionKernel("boot_a", "kernel_1.snap"), IsNil)
+	c.Assert(env.SetBootPartitionKernel("boot_b", "kernel_2.snap"), IsNil)
+	env.Set("snap_kernel", "kernel_1.snap")
+	c.Assert(env.Save(), IsNil)
+}
+
+func (s *lkTestSuite) TestSetBootVarsUpdatesSlotAttributes(c *C) {
+	opts := &bootloader.Options{
+		Role: bootloader.RoleRunMode,
+	}
+	r := bootloader.MockLkFiles(c, s.rootdir, opts)
+	defer r()
+	cmdline := filepath.Join(c.MkDir(), "cmdline")
+	c.Assert(ioutil.WriteFile(cmdline, []byte("snapd_lk_boot_disk=lk-boot-disk androidboot.slot_suffix=_a"), 0644), IsNil)
+	defer osutil.MockProcCmdline(cmdline)()
+
+	l := bootloader.NewLk(s.rootdir, opts)
+	s.setupSlotKernels(c, l)
+
+	// boot_a is partition 5 and the active, successful slot, boot_b is
+	// partition 6
+	sfdisk, attrsDir := s.mockSlotAttributes(c, map[uint64]string{
+		5: "GUID:48,49,50,51,52,53,54",
+		6: "RequiredPartition GUID:48",
+	})
+	defer sfdisk.Restore()
+	checkAttrs := func(bootA, bootB string) {
+		c.Check(filepath.Join(attrsDir, "attrs-5"), testutil.FileEquals, bootA+"\n")
+		c.Check(filepath.Join(attrsDir, "attrs-6"), testutil.FileEquals, bootB+"\n")
+	}
+	readCalls := [][]string{
+		{"sfdisk", "--part-attrs", "/dev/lk-boot-disk", "6"},
+		{"sfdisk", "--part-attrs", "/dev/lk-boot-disk", "5"},
+	}
+
+	// nothing to change
+	c.Assert(l.SetBootVars(map[string]string{"kernel_status": ""}), IsNil)
+	c.Check(sfdisk.Calls(), DeepEquals, readCalls)
+	sfdisk.ForgetCalls()
+
+	// trying a kernel activates its slot for a single boot attempt
+	c.Assert(l.SetBootVars(map[string]string{
+		"snap_try_kernel": "kernel_2.snap",
+		"kernel_status":   boot.TryStatus,
+	}), IsNil)
+	// the currently active slot is deactivated first
+	c.Check(sfdisk.Calls(), DeepEquals, [][]string{
+		{"sfdisk", "--part-attrs", "/dev/lk-boot-disk", "5"},
+		{"sfdisk", "--no-reread", "--part-attrs", "/dev/lk-boot-disk", "5", "GUID:48,51,52,53,54"},
+		{"sfdisk", "--part-attrs", "/dev/lk-boot-disk", "6"},
+		{"sfdisk", "--no-reread", "--part-attrs", "/dev/lk-boot-disk", "6", "RequiredPartition GUID:48,49,50,51"},
+	})
+	checkAttrs("GUID:48,51,52,53,54", "RequiredPartition GUID:48,49,50,51")
+	sfdisk.ForgetCalls()
+
+	// the boot loader handles the slots while trying
+	c.Assert(l.SetBootVars(map[string]string{"kernel_status": boot.TryingStatus}), IsNil)
+	c.Check(sfdisk.Calls(), HasLen, 0)
+
+	// the new kernel is marked as successful once it booted
+	c.Assert(l.SetBootVars(map[string]string{
+		"snap_kernel":     "kernel_2.snap",
+		"snap_try_kernel": "",
+		"kernel_status":   "",
+	}), IsNil)
+	checkAttrs("GUID:48,51,52,53,54", "RequiredPartition GUID:48,49,50,51,52,53,54")
+}
+
+func (s *lkTestSuite) TestSetBootVarsSlotAttributesRollback(c *C) {
+	opts := &bootloader.Options{
+		Role: bootloader.RoleRunMode,
+	}
+	r := bootloader.MockLkFiles(c, s.rootdir, opts)
+	defer r()
+	cmdline := filepath.Join(c.MkDir(), "cmdline")
+	c.Assert(ioutil.WriteFile(cmdline, []byte("snapd_lk_boot_disk=lk-boot-disk androidboot.slot_suffix=_b"), 0644), IsNil)
+	defer osutil.MockProcCmdline(cmdline)()
+
+	l := bootloader.NewLk(s.rootdir, opts)
+	s.setupSlotKernels(c, l)
+
+	// the try of boot_b failed, the boot loader marked it unbootable and
+	// fell back to boot_a
+	sfdisk, attrsDir := s.mockSlotAttributes(c, map[uint64]string{
+		5: "GUID:48,51,52,53,54",
+		6: "GUID:48,49,50,55",
+	})
+	defer sfdisk.Restore()
+
+	c.Assert(l.SetBootVars(map[string]string{
+		"snap_try_kernel": "",
+		"kernel_status":   "",
+	}), IsNil)
+	c.Check(filepath.Join(attrsDir, "attrs-5"), testutil.FileEquals, "GUID:48,49,50,51,52,53,54\n")
+	c.Check(filepath.Join(attrsDir, "attrs-6"), testutil.FileEquals, "GUID:48,55\n")
+}
+
+func (s *lkTestSuite) TestSetBootVarsNoSlotAttributesWithoutSlotSuffix(c *C) {
+	opts := &bootloader.Options{
+		Role: bootloader.RoleRunMode,
+	}
+	r := bootloader.MockLkFiles(c, s.rootdir, opts)
+	defer r()
+
+	l := bootloader.NewLk(s.rootdir, opts)
+	s.setupSlotKernels(c, l)
+
+	sfdisk, _ := s.mockSlotAttributes(c, nil)
+	defer sfdisk.Restore()
+
+	c.Assert(l.SetBootVars(map[string]string{
+		"snap_try_kernel": "kernel_2.snap",
+		"kernel_status":   boot.TryStatus,
+	}), IsNil)
+	c.Check(sfdisk.Calls(), HasLen, 0)
+}
+
+func (s *lkTestSuite) TestSetBootVarsSlotAttributesError(c *C) {
+	opts := &bootloader.Options{
+		Role: bootloader.RoleRunMode,
+	}
+	r := bootloader.MockLkFiles(c, s.rootdir, opts)
+	defer r()
+	cmdline := filepath.Join(c.MkDir(), "cmdline")
+	c.Assert(ioutil.WriteFile(cmdline, []byte("snapd_lk_boot_disk=lk-boot-disk androidboot.slot_suffix=_a"), 0644), IsNil)
+	defer osutil.MockProcCmdline(cmdline)()
+
+	l := bootloader.NewLk(s.rootdir, opts)
+	s.setupSlotKernels(c, l)
+
+	sfdisk := testutil.MockCommand(c, "sfdisk", "echo 'permission denied'; exit 1")
+	defer sfdisk.Restore()
+
+	err := l.SetBootVars(map[string]string{
+		"snap_try_kernel": "kernel_2.snap",
+		"kernel_status":   boot.TryStatus,
+	})
+	c.Check(err, ErrorMatches, "cannot read attributes of partition 5 of /dev/lk-boot-disk: permission denied")
+}
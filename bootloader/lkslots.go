@@ -0,0 +1,241 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package bootloader
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/bootloader/lkenv"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Qualcomm boot loaders (XBL/ABL) do not use the lk boot environment to
+// pick which of the A/B boot partitions to boot, instead they track the
+// state of each slot in the GPT attributes of its boot partition, using
+// the bit layout of the Qualcomm boot control HAL (gpt-utils.h).
+const (
+	lkSlotPriorityShift = 48
+	lkSlotPriorityMask  = uint64(0x3) << lkSlotPriorityShift
+	lkSlotActive        = uint64(1) << 50
+	lkSlotRetryShift    = 51
+	lkSlotRetryMask     = uint64(0x7) << lkSlotRetryShift
+	lkSlotSuccessful    = uint64(1) << 54
+	lkSlotUnbootable    = uint64(1) << 55
+
+	lkSlotMaxPriority    = 3
+	lkSlotFallbackPrio   = 1
+	lkSlotMaxRetries     = 7
+	lkSlotTryKernelTries = 1
+)
+
+// names used by sfdisk for the generic GPT attribute bits
+var gptAttrNames = []string{"RequiredPartition", "NoBlockIOProtocol", "LegacyBIOSBootable"}
+
+// parseGptAttrs parses GPT partition attributes in the format used by
+// sfdisk --part-attrs, e.g. "RequiredPartition GUID:48,50".
+func parseGptAttrs(s string) (uint64, error) {
+	var attrs uint64
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '\n' }) {
+		if strings.HasPrefix(field, "GUID:") {
+			for _, bitStr := range strings.Split(strings.TrimPrefix(field, "GUID:"), ",") {
+				bit, err := strconv.ParseUint(bitStr, 10, 8)
+				if err != nil || bit < 48 || bit > 63 {
+					return 0, fmt.Errorf("cannot parse GPT partition attributes %q: invalid bit %q", s, bitStr)
+				}
+				attrs |= uint64(1) << bit
+			}
+			continue
+		}
+		found := false
+		for bit, name := range gptAttrNames {
+			if field == name {
+				attrs |= uint64(1) << uint(bit)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("cannot parse GPT partition attributes %q: unknown attribute %q", s, field)
+		}
+	}
+	return attrs, nil
+}
+
+// formatGptAttrs formats GPT partition attributes for sfdisk --part-attrs.
+func formatGptAttrs(attrs uint64) string {
+	var fields []string
+	for bit, name := range gptAttrNames {
+		if attrs&(uint64(1)<<uint(bit)) != 0 {
+			fields = append(fields, name)
+		}
+	}
+	var guidBits []string
+	for bit := uint(48); bit < 64; bit++ {
+		if attrs&(uint64(1)<<bit) != 0 {
+			guidBits = append(guidBits, strconv.Itoa(int(bit)))
+		}
+	}
+	if len(guidBits) > 0 {
+		fields = append(fields, "GUID:"+strings.Join(guidBits, ","))
+	}
+	return strings.Join(fields, " ")
+}
+
+var lkGetPartitionAttrs = func(device string, index uint64) (uint64, error) {
+	output, err := exec.Command("sfdisk", "--part-attrs", device, strconv.FormatUint(index, 10)).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("cannot read attributes of partition %d of %s: %v", index, device, osutil.OutputErr(output, err))
+	}
+	return parseGptAttrs(strings.TrimSpace(string(output)))
+}
+
+var lkSetPartitionAttrs = func(device string, index uint64, attrs uint64) error {
+	output, err := exec.Command("sfdisk", "--no-reread", "--part-attrs", device, strconv.FormatUint(index, 10), formatGptAttrs(attrs)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot set attributes of partition %d of %s: %v", index, device, osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// activeSlotAttrs returns the attributes for the slot that should be
+// booted next, either for a single try of a new kernel or as the known
+// good slot.
+func activeSlotAttrs(attrs uint64, trying bool) uint64 {
+	attrs &^= lkSlotPriorityMask | lkSlotRetryMask | lkSlotSuccessful | lkSlotUnbootable
+	attrs |= lkSlotActive | lkSlotMaxPriority<<lkSlotPriorityShift
+	if trying {
+		attrs |= lkSlotTryKernelTries << lkSlotRetryShift
+	} else {
+		attrs |= lkSlotSuccessful | lkSlotMaxRetries<<lkSlotRetryShift
+	}
+	return attrs
+}
+
+// inactiveSlotAttrs returns the attributes for the slot that is not
+// booted next, it is kept as is so that the boot loader can fall back to
+// it, but with a lower priority.
+func inactiveSlotAttrs(attrs uint64) uint64 {
+	attrs &^= lkSlotActive | lkSlotPriorityMask
+	return attrs | lkSlotFallbackPrio<<lkSlotPriorityShift
+}
+
+// otherSlot returns the boot partition of the other slot for an A/B
+// boot partition label like "boot_a".
+func otherSlot(bootPart string) (string, error) {
+	switch {
+	case strings.HasSuffix(bootPart, "_a"):
+		return strings.TrimSuffix(bootPart, "_a") + "_b", nil
+	case strings.HasSuffix(bootPart, "_b"):
+		return strings.TrimSuffix(bootPart, "_b") + "_a", nil
+	}
+	return "", fmt.Errorf("cannot use A/B slot attributes for boot partition %q without a slot suffix", bootPart)
+}
+
+// usesSlotAttributes returns whether the boot loader picks the boot
+// partition from the GPT slot attributes, which is the case for
+// Qualcomm boot loaders that pass the slot suffix on the kernel command
+// line.
+func (l *lk) usesSlotAttributes() (bool, error) {
+	if l.prepareImageTime || l.role != RoleRunMode {
+		return false, nil
+	}
+	m, err := osutil.KernelCommandLineKeyValues("androidboot.slot_suffix")
+	if err != nil {
+		return false, err
+	}
+	if _, ok := m["androidboot.slot_suffix"]; !ok {
+		return false, nil
+	}
+	// sfdisk is not available in the initramfs, the attributes are
+	// brought in sync by the next update from the run system instead
+	if _, err := exec.LookPath("sfdisk"); err != nil {
+		logger.Debugf("cannot update boot partition slot attributes: %v", err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// updateSlotAttributes updates the GPT slot attributes of the boot
+// partitions to match the kernel state of the boot environment: a kernel
+// being tried gets its slot activated for a single boot attempt,
+// otherwise the slot of the current kernel is marked as active and
+// successful.
+func (l *lk) updateSlotAttributes(env *lkenv.Env) error {
+	kernel := env.Get("snap_kernel")
+	trying := false
+	switch env.Get("kernel_status") {
+	case "try":
+		kernel = env.Get("snap_try_kernel")
+		trying = true
+	case "":
+	default:
+		// the try boot is in progress, the boot loader already did its
+		// part of the bookkeeping in the slot attributes
+		return nil
+	}
+	if kernel == "" {
+		return nil
+	}
+
+	bootPart, err := env.GetKernelBootPartition(kernel)
+	if err != nil {
+		return err
+	}
+	otherPart, err := otherSlot(bootPart)
+	if err != nil {
+		return err
+	}
+
+	// the other slot is updated first, so that the boot loader never
+	// sees both slots as active
+	device := l.blDisk.KernelDeviceNode()
+	for _, slot := range []struct {
+		part   string
+		active bool
+	}{
+		{otherPart, false},
+		{bootPart, true},
+	} {
+		part, err := l.blDisk.FindMatchingPartitionWithPartLabel(slot.part)
+		if err != nil {
+			return err
+		}
+		attrs, err := lkGetPartitionAttrs(device, part.DiskIndex)
+		if err != nil {
+			return err
+		}
+		newAttrs := inactiveSlotAttrs(attrs)
+		if slot.active {
+			newAttrs = activeSlotAttrs(attrs, trying)
+		}
+		if newAttrs == attrs {
+			continue
+		}
+		logger.Debugf("updating slot attributes of boot partition %s from %q to %q", slot.part, formatGptAttrs(attrs), formatGptAttrs(newAttrs))
+		if err := lkSetPartitionAttrs(device, part.DiskIndex, newAttrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -20,9 +20,12 @@
 package bootloader
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/snapcore/snapd/bootloader/ubootenv"
 	"github.com/snapcore/snapd/osutil"
@@ -33,6 +36,26 @@ import (
 var (
 	_ Bootloader                             = (*uboot)(nil)
 	_ ExtractedRecoveryKernelImageBootloader = (*uboot)(nil)
+	_ BootCountingBootloader                 = (*uboot)(nil)
+)
+
+const (
+	// the variables of the bootcount feature of u-boot, with the
+	// environment as the storage of the boot counter
+	ubootBootCountVar        = "bootcount"
+	ubootBootLimitVar        = "bootlimit"
+	ubootUpgradeAvailableVar = "upgrade_available"
+
+	// defaultUbootBootLimit is the number of boots of an update that
+	// are attempted before u-boot runs altbootcmd, unless the
+	// environment sets a limit already
+	defaultUbootBootLimit = 3
+
+	// ubootEnvConfigFileName is the file shipped by the gadget next to
+	// uboot.conf, and installed next to the environment, that lists the
+	// two copies of a redundant environment kept in different files, in
+	// the two-entry form of fw_env.config
+	ubootEnvConfigFileName = "fw_env.config"
 )
 
 type uboot struct {
@@ -40,6 +63,12 @@ type uboot struct {
 	basedir string
 
 	ubootEnvFileName string
+	// redundantEnvFiles are the files of the two copies of a redundant
+	// environment, relative to rootdir, if one is used
+	redundantEnvFiles []string
+	// envConfigLoaded is set once the configuration of the environment
+	// was read, see loadEnvConfig
+	envConfigLoaded bool
 }
 
 func (u *uboot) setDefaults() {
@@ -76,6 +105,73 @@ func newUboot(rootdir string, blOpts *Options) Bootloader {
 	return u
 }
 
+// loadEnvConfig reads the configuration of a redundant environment installed
+// next to the environment, if it was not read yet. Nothing is read without a
+// rootdir, as is the case for bootloaders only used to look at the gadget.
+func (u *uboot) loadEnvConfig() error {
+	if u.envConfigLoaded || u.rootdir == "" {
+		return nil
+	}
+	envFiles, err := readUbootEnvConfig(filepath.Join(u.dir(), ubootEnvConfigFileName))
+	if err != nil {
+		return err
+	}
+	u.redundantEnvFiles = envFiles
+	u.envConfigLoaded = true
+	return nil
+}
+
+// readUbootEnvConfig returns the files of the two copies of a redundant
+// environment listed in the given fw_env.config file, if there is one.
+func readUbootEnvConfig(configFile string) ([]string, error) {
+	f, err := os.Open(configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var envFiles []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// each entry is: device offset size [sector-size sectors]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if !filepath.IsAbs(fields[0]) {
+			return nil, fmt.Errorf("cannot use U-Boot environment configuration %q: environment file %q is not absolute", configFile, fields[0])
+		}
+		envFiles = append(envFiles, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(envFiles) != 2 {
+		return nil, fmt.Errorf("cannot use U-Boot environment configuration %q: expected 2 environment files, got %d", configFile, len(envFiles))
+	}
+	return envFiles, nil
+}
+
+// installEnvConfig sets up a redundant environment if the gadget describes
+// one, and installs its configuration next to the environment, for the
+// bootloader to find it at runtime.
+func (u *uboot) installEnvConfig(gadgetDir string) error {
+	gadgetConfig := filepath.Join(gadgetDir, ubootEnvConfigFileName)
+	envFiles, err := readUbootEnvConfig(gadgetConfig)
+	if err != nil {
+		return err
+	}
+	u.redundantEnvFiles = envFiles
+	u.envConfigLoaded = true
+	if envFiles == nil {
+		return nil
+	}
+	return genericInstallBootConfig(gadgetConfig, filepath.Join(u.dir(), ubootEnvConfigFileName))
+}
+
 func (u *uboot) Name() string {
 	return "uboot"
 }
@@ -105,14 +201,25 @@ func (u *uboot) InstallBootConfig(gadgetDir string, blOpts *Options) error {
 		// gadget, but nothing to copy in this case and instead just install our
 		// own boot.sel file
 		u.processBlOpts(blOpts)
-
-		err := os.MkdirAll(filepath.Dir(u.envFile()), 0755)
-		if err != nil {
+		if err := u.installEnvConfig(gadgetDir); err != nil {
 			return err
 		}
 
+		for _, envFile := range u.envFiles() {
+			if err := os.MkdirAll(filepath.Dir(envFile), 0755); err != nil {
+				return err
+			}
+		}
+
 		// TODO:UC20: what's a reasonable size for this file?
-		env, err := ubootenv.Create(u.envFile(), 4096)
+		var env *ubootenv.Env
+		var err error
+		if u.redundantEnvFiles != nil {
+			envFiles := u.envFiles()
+			env, err = ubootenv.CreateRedundant(envFiles[0], envFiles[1], 4096)
+		} else {
+			env, err = ubootenv.Create(u.envFile(), 4096)
+		}
 		if err != nil {
 			return err
 		}
@@ -133,21 +240,63 @@ func (u *uboot) InstallBootConfig(gadgetDir string, blOpts *Options) error {
 		// TODO:UC20: support this use-case
 		return fmt.Errorf("non-empty uboot.env not supported on UC20+ yet")
 	}
+	if err := u.installEnvConfig(gadgetDir); err != nil {
+		return err
+	}
 
-	systemFile := u.envFile()
-	return genericInstallBootConfig(gadgetFile, systemFile)
+	// both copies of a redundant environment start from the gadget one
+	for _, systemFile := range u.envFiles() {
+		if err := genericInstallBootConfig(gadgetFile, systemFile); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (u *uboot) Present() (bool, error) {
-	return osutil.FileExists(u.envFile()), nil
+	if err := u.loadEnvConfig(); err != nil {
+		return false, err
+	}
+	for _, envFile := range u.envFiles() {
+		if osutil.FileExists(envFile) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func (u *uboot) envFile() string {
-	return filepath.Join(u.dir(), u.ubootEnvFileName)
+	return u.envFiles()[0]
+}
+
+// envFiles returns the files of the environment, that is the files of both
+// copies for a redundant environment.
+func (u *uboot) envFiles() []string {
+	if u.redundantEnvFiles == nil {
+		return []string{filepath.Join(u.dir(), u.ubootEnvFileName)}
+	}
+	if u.rootdir == "" {
+		panic("internal error: unset rootdir")
+	}
+	return []string{
+		filepath.Join(u.rootdir, u.redundantEnvFiles[0]),
+		filepath.Join(u.rootdir, u.redundantEnvFiles[1]),
+	}
+}
+
+func (u *uboot) openEnv() (*ubootenv.Env, error) {
+	if err := u.loadEnvConfig(); err != nil {
+		return nil, err
+	}
+	if u.redundantEnvFiles != nil {
+		envFiles := u.envFiles()
+		return ubootenv.OpenRedundant(envFiles[0], envFiles[1], ubootenv.OpenBestEffort)
+	}
+	return ubootenv.OpenWithFlags(u.envFile(), ubootenv.OpenBestEffort)
 }
 
 func (u *uboot) SetBootVars(values map[string]string) error {
-	env, err := ubootenv.OpenWithFlags(u.envFile(), ubootenv.OpenBestEffort)
+	env, err := u.openEnv()
 	if err != nil {
 		return err
 	}
@@ -172,7 +321,7 @@ func (u *uboot) SetBootVars(values map[string]string) error {
 func (u *uboot) GetBootVars(names ...string) (map[string]string, error) {
 	out := map[string]string{}
 
-	env, err := ubootenv.OpenWithFlags(u.envFile(), ubootenv.OpenBestEffort)
+	env, err := u.openEnv()
 	if err != nil {
 		return nil, err
 	}
@@ -203,3 +352,52 @@ func (u *uboot) ExtractRecoveryKernelAssets(recoverySystemDir string, s snap.Pla
 func (u *uboot) RemoveKernelAssets(s snap.PlaceInfo) error {
 	return removeKernelAssetsFromBootDir(u.dir(), s)
 }
+
+// countsBoots returns whether u-boot itself loads the environment, which is
+// where it keeps the boot counter, as opposed to the boot.sel file imported
+// by the boot script.
+func (u *uboot) countsBoots() bool {
+	return u.redundantEnvFiles != nil || u.ubootEnvFileName == "uboot.env"
+}
+
+func (u *uboot) ArmBootCountingVars() (map[string]string, error) {
+	if err := u.loadEnvConfig(); err != nil {
+		return nil, err
+	}
+	if !u.countsBoots() {
+		return nil, nil
+	}
+	env, err := u.openEnv()
+	if err != nil {
+		return nil, err
+	}
+	limit := env.Get(ubootBootLimitVar)
+	if n, err := strconv.Atoi(limit); err != nil || n <= 0 {
+		limit = strconv.Itoa(defaultUbootBootLimit)
+	}
+	return map[string]string{
+		ubootBootCountVar:        "0",
+		ubootBootLimitVar:        limit,
+		ubootUpgradeAvailableVar: "1",
+	}, nil
+}
+
+func (u *uboot) ClearBootCountingVars() (map[string]string, error) {
+	if err := u.loadEnvConfig(); err != nil {
+		return nil, err
+	}
+	if !u.countsBoots() {
+		return nil, nil
+	}
+	env, err := u.openEnv()
+	if err != nil {
+		return nil, err
+	}
+	if env.Get(ubootUpgradeAvailableVar) != "1" {
+		return nil, nil
+	}
+	return map[string]string{
+		ubootBootCountVar:        "0",
+		ubootUpgradeAvailableVar: "0",
+	}, nil
+}
@@ -20,6 +20,7 @@
 package bootloader_test
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
@@ -270,3 +271,193 @@ func (s *ubootTestSuite) TestUbootUC20OptsPlacement(c *C) {
 		c.Assert(env.Get("hello"), Equals, "there")
 	}
 }
+
+const ubootRedundantEnvConfig = `# device offset size
+/boot/uboot/boot.sel 0x0000 0x1000
+/boot2/uboot/boot.sel 0x0000 0x1000
+`
+
+func (s *ubootTestSuite) TestUbootRedundantEnvFiles(c *C) {
+	blOpts := &bootloader.Options{Role: bootloader.RoleRunMode}
+	envFile1 := filepath.Join(s.rootdir, "/boot/uboot/boot.sel")
+	envFile2 := filepath.Join(s.rootdir, "/boot2/uboot/boot.sel")
+
+	// an empty uboot.conf in the gadget means snapd sets up the env, the
+	// gadget describes a redundant one
+	gadgetDir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "uboot.conf"), nil, 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "fw_env.config"), []byte(ubootRedundantEnvConfig), 0644), IsNil)
+	c.Assert(bootloader.InstallBootConfig(gadgetDir, s.rootdir, blOpts), IsNil)
+	c.Check(envFile1, testutil.FilePresent)
+	c.Check(envFile2, testutil.FilePresent)
+	// the configuration is installed next to the environment
+	c.Check(filepath.Join(s.rootdir, "/boot/uboot/fw_env.config"), testutil.FileEquals, ubootRedundantEnvConfig)
+
+	u, err := bootloader.Find(s.rootdir, blOpts)
+	c.Assert(err, IsNil)
+	c.Check(u.Name(), Equals, "uboot")
+
+	c.Assert(u.SetBootVars(map[string]string{"snap_kernel": "pc-kernel_1.snap"}), IsNil)
+	c.Assert(u.SetBootVars(map[string]string{"snap_kernel": "pc-kernel_2.snap"}), IsNil)
+
+	m, err := u.GetBootVars("snap_kernel")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"snap_kernel": "pc-kernel_2.snap"})
+
+	// the writes alternate between both copies, the initial empty env was
+	// written to the second one
+	env, err := ubootenv.Open(envFile1)
+	c.Assert(err, IsNil)
+	c.Check(env.Get("snap_kernel"), Equals, "pc-kernel_1.snap")
+	env, err = ubootenv.Open(envFile2)
+	c.Assert(err, IsNil)
+	c.Check(env.Get("snap_kernel"), Equals, "pc-kernel_2.snap")
+}
+
+func (s *ubootTestSuite) TestUbootRedundantEnvFilesFromGadgetEnv(c *C) {
+	// the gadget ships its own environment
+	gadgetDir := c.MkDir()
+	env, err := ubootenv.Create(filepath.Join(gadgetDir, "uboot.conf"), 4096)
+	c.Assert(err, IsNil)
+	env.Set("from", "gadget")
+	c.Assert(env.Save(), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "fw_env.config"), []byte(ubootRedundantEnvConfig), 0644), IsNil)
+	c.Assert(bootloader.InstallBootConfig(gadgetDir, s.rootdir, nil), IsNil)
+
+	// both copies start from the gadget environment
+	for _, envFile := range []string{"/boot/uboot/boot.sel", "/boot2/uboot/boot.sel"} {
+		env, err := ubootenv.Open(filepath.Join(s.rootdir, envFile))
+		c.Assert(err, IsNil)
+		c.Check(env.Get("from"), Equals, "gadget")
+	}
+	c.Check(filepath.Join(s.rootdir, "/boot/uboot/uboot.env"), testutil.FileAbsent)
+
+	u, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, IsNil)
+	m, err := u.GetBootVars("from")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{"from": "gadget"})
+}
+
+func (s *ubootTestSuite) TestUbootRedundantEnvFilesInvalid(c *C) {
+	gadgetDir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "uboot.conf"), nil, 0644), IsNil)
+
+	for _, t := range []struct {
+		config string
+		err    string
+	}{
+		{"/boot/uboot/uboot.env 0x0000 0x4000\n", `expected 2 environment files, got 1`},
+		{"/a 0 1\n/b 0 1\n/c 0 1\n", `expected 2 environment files, got 3`},
+		{"/a 0 1\nb 0 1\n", `environment file "b" is not absolute`},
+	} {
+		c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "fw_env.config"), []byte(t.config), 0644), IsNil)
+		err := bootloader.InstallBootConfig(gadgetDir, s.rootdir, nil)
+		c.Check(err, ErrorMatches, `cannot use U-Boot environment configuration ".*/fw_env.config": `+t.err)
+	}
+
+	// a broken configuration next to the environment makes it unusable
+	bootloader.MockUbootFiles(c, s.rootdir, nil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.rootdir, "/boot/uboot/fw_env.config"), []byte("/a 0 1\n"), 0644), IsNil)
+	_, err := bootloader.Find(s.rootdir, nil)
+	c.Assert(err, ErrorMatches, `bootloader "uboot" found but not usable: cannot use U-Boot environment configuration ".*/fw_env.config": expected 2 environment files, got 1`)
+	u := bootloader.NewUboot(s.rootdir, nil)
+	_, err = u.GetBootVars("snap_mode")
+	c.Check(err, ErrorMatches, `cannot use U-Boot environment configuration ".*/fw_env.config": expected 2 environment files, got 1`)
+	err = u.SetBootVars(map[string]string{"snap_mode": ""})
+	c.Check(err, ErrorMatches, `cannot use U-Boot environment configuration ".*/fw_env.config": expected 2 environment files, got 1`)
+}
+
+func (s *ubootTestSuite) TestUbootBootCountingVars(c *C) {
+	bootloader.MockUbootFiles(c, s.rootdir, nil)
+	u := bootloader.NewUboot(s.rootdir, nil)
+	bc, ok := u.(bootloader.BootCountingBootloader)
+	c.Assert(ok, Equals, true)
+
+	// not armed, nothing to clear
+	m, err := bc.ClearBootCountingVars()
+	c.Assert(err, IsNil)
+	c.Check(m, HasLen, 0)
+
+	m, err = bc.ArmBootCountingVars()
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "1",
+	})
+	c.Assert(u.SetBootVars(m), IsNil)
+
+	// u-boot counted a boot
+	c.Assert(u.SetBootVars(map[string]string{"bootcount": "1"}), IsNil)
+
+	m, err = bc.ClearBootCountingVars()
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"bootcount":         "0",
+		"upgrade_available": "0",
+	})
+	c.Assert(u.SetBootVars(m), IsNil)
+
+	m, err = bc.ClearBootCountingVars()
+	c.Assert(err, IsNil)
+	c.Check(m, HasLen, 0)
+}
+
+func (s *ubootTestSuite) TestUbootBootCountingKeepsBootLimit(c *C) {
+	bootloader.MockUbootFiles(c, s.rootdir, nil)
+	u := bootloader.NewUboot(s.rootdir, nil)
+	bc := u.(bootloader.BootCountingBootloader)
+
+	for _, t := range []struct {
+		limit, exp string
+	}{
+		{"5", "5"},
+		{"0", "3"},
+		{"-1", "3"},
+		{"many", "3"},
+	} {
+		c.Assert(u.SetBootVars(map[string]string{"bootlimit": t.limit}), IsNil)
+		m, err := bc.ArmBootCountingVars()
+		c.Assert(err, IsNil)
+		c.Check(m["bootlimit"], Equals, t.exp, Commentf("bootlimit %q", t.limit))
+	}
+}
+
+func (s *ubootTestSuite) TestUbootBootCountingNotNativeEnv(c *C) {
+	blOpts := &bootloader.Options{Role: bootloader.RoleRunMode}
+	bootloader.MockUbootFiles(c, s.rootdir, blOpts)
+	u := bootloader.NewUboot(s.rootdir, blOpts)
+	bc := u.(bootloader.BootCountingBootloader)
+
+	// the boot.sel file is imported by the boot script, u-boot does not
+	// count boots in it
+	m, err := bc.ArmBootCountingVars()
+	c.Assert(err, IsNil)
+	c.Check(m, HasLen, 0)
+
+	c.Assert(u.SetBootVars(map[string]string{"upgrade_available": "1"}), IsNil)
+	m, err = bc.ClearBootCountingVars()
+	c.Assert(err, IsNil)
+	c.Check(m, HasLen, 0)
+}
+
+func (s *ubootTestSuite) TestUbootBootCountingRedundantEnvFiles(c *C) {
+	blOpts := &bootloader.Options{Role: bootloader.RoleRunMode}
+	gadgetDir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "uboot.conf"), nil, 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "fw_env.config"), []byte(ubootRedundantEnvConfig), 0644), IsNil)
+	c.Assert(bootloader.InstallBootConfig(gadgetDir, s.rootdir, blOpts), IsNil)
+
+	u := bootloader.NewUboot(s.rootdir, blOpts)
+	bc := u.(bootloader.BootCountingBootloader)
+
+	// the redundant environment is loaded by u-boot itself
+	m, err := bc.ArmBootCountingVars()
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"bootcount":         "0",
+		"bootlimit":         "3",
+		"upgrade_available": "1",
+	})
+}
@@ -42,6 +42,16 @@ type Env struct {
 	fname string
 	size  int
 	data  map[string]string
+
+	// redundantFname is the file of the other copy of a redundant
+	// environment, it is empty for a non-redundant environment. Saving a
+	// redundant environment writes the other copy, which then becomes
+	// the current one.
+	redundantFname string
+	// flag is the flags byte of the current copy of a redundant
+	// environment, it is incremented with each save so that the most
+	// recently written copy can be told apart
+	flag byte
 }
 
 // little endian helpers
@@ -75,6 +85,28 @@ func Create(fname string, size int) (*Env, error) {
 	return env, nil
 }
 
+// CreateRedundant creates a new empty redundant uboot env with the given size,
+// whose two copies are kept in the given files, which can be on different
+// partitions, as with the two-entry form of fw_env.config.
+func CreateRedundant(fname, redundantFname string, size int) (*Env, error) {
+	for _, name := range []string{fname, redundantFname} {
+		f, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+	}
+
+	env := &Env{
+		fname:          fname,
+		redundantFname: redundantFname,
+		size:           size,
+		data:           make(map[string]string),
+	}
+
+	return env, nil
+}
+
 // OpenFlags instructs open how to alter its behavior.
 type OpenFlags int
 
@@ -83,6 +115,28 @@ const (
 	OpenBestEffort OpenFlags = 1 << iota
 )
 
+const (
+	// MaxVariables is the maximum number of variables accepted in an
+	// environment.
+	MaxVariables = 1024
+	// MaxNameLength is the maximum length of a variable name.
+	MaxNameLength = 256
+	// MaxValueLength is the maximum length of a variable value.
+	MaxValueLength = 64 * 1024
+)
+
+// CorruptionError is returned when the environment data is malformed. It
+// carries the offset of the malformed data relative to the beginning of the
+// environment file.
+type CorruptionError struct {
+	Offset int
+	Reason string
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("corrupted environment at offset %d: %s", e.Offset, e.Reason)
+}
+
 // Open opens a existing uboot env file
 func Open(fname string) (*Env, error) {
 	return OpenWithFlags(fname, OpenFlags(0))
@@ -90,6 +144,96 @@ func Open(fname string) (*Env, error) {
 
 // OpenWithFlags opens a existing uboot env file, passing additional flags.
 func OpenWithFlags(fname string, flags OpenFlags) (*Env, error) {
+	contentWithHeader, err := readEnv(fname)
+	if err != nil {
+		return nil, err
+	}
+	data, err := parseData(payloadOf(contentWithHeader), flags)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &Env{
+		fname: fname,
+		size:  len(contentWithHeader),
+		data:  data,
+	}
+
+	return env, nil
+}
+
+// newerFlag returns whether a copy of a redundant environment with the flags
+// byte flag was written after one with the flags byte other, accounting for
+// the counter wrapping around like U-Boot does.
+func newerFlag(flag, other byte) bool {
+	switch {
+	case flag == 0 && other == 255:
+		return true
+	case flag == 255 && other == 0:
+		return false
+	}
+	return flag > other
+}
+
+// OpenRedundant opens an existing redundant uboot env whose two copies are
+// kept in the given files. The copy with a valid checksum which was written
+// last is used.
+func OpenRedundant(fname, redundantFname string, flags OpenFlags) (*Env, error) {
+	content, err := readEnv(fname)
+	redundantContent, redundantErr := readEnv(redundantFname)
+	switch {
+	case err != nil && redundantErr != nil:
+		return nil, fmt.Errorf("cannot open redundant environment: %v, %v", err, redundantErr)
+	case err == nil && redundantErr == nil:
+		if len(content) != len(redundantContent) {
+			return nil, fmt.Errorf("cannot open redundant environment: %q and %q have different sizes", fname, redundantFname)
+		}
+		if newerFlag(redundantContent[headerSize-1], content[headerSize-1]) {
+			fname, redundantFname = redundantFname, fname
+			content = redundantContent
+		}
+	case err != nil:
+		// only the other copy is usable
+		fname, redundantFname = redundantFname, fname
+		content = redundantContent
+	}
+
+	data, err := parseData(payloadOf(content), flags)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &Env{
+		fname:          fname,
+		redundantFname: redundantFname,
+		flag:           content[headerSize-1],
+		size:           len(content),
+		data:           data,
+	}
+
+	return env, nil
+}
+
+// Validate checks that the uboot env file is well formed, that is its
+// checksum matches and all variables are valid and within the limits. When the
+// data is malformed a *CorruptionError is returned.
+func Validate(fname string) error {
+	contentWithHeader, err := readEnv(fname)
+	if err != nil {
+		return err
+	}
+	payload := contentWithHeader[headerSize:]
+	if !bytes.Contains(payload, []byte{0, 0}) && !bytes.Contains(payload, []byte{0, 0xff}) {
+		// the environment must be terminated with a double \0, or
+		// be followed by padding
+		return &CorruptionError{Offset: len(contentWithHeader), Reason: "missing end of environment marker"}
+	}
+	_, err = parseData(payloadOf(contentWithHeader), OpenFlags(0))
+	return err
+}
+
+// readEnv reads the uboot env file and verifies its header.
+func readEnv(fname string) ([]byte, error) {
 	f, err := os.Open(fname)
 	if err != nil {
 		return nil, err
@@ -112,41 +256,78 @@ func OpenWithFlags(fname string, flags OpenFlags) (*Env, error) {
 	if crc != actualCRC {
 		return nil, fmt.Errorf("cannot open %q: bad CRC %v != %v", fname, crc, actualCRC)
 	}
+	return contentWithHeader, nil
+}
 
+// payloadOf returns the environment data up to the end of environment marker.
+func payloadOf(contentWithHeader []byte) []byte {
+	payload := contentWithHeader[headerSize:]
 	if eof := bytes.Index(payload, []byte{0, 0}); eof >= 0 {
 		payload = payload[:eof]
 	}
+	return payload
+}
 
-	data, err := parseData(payload, flags)
-	if err != nil {
-		return nil, err
+func validateVariable(key, value string) string {
+	if len(key) > MaxNameLength {
+		return fmt.Sprintf("variable name longer than %d bytes", MaxNameLength)
 	}
-
-	env := &Env{
-		fname: fname,
-		size:  len(contentWithHeader),
-		data:  data,
+	for _, c := range []byte(key) {
+		if c < 0x20 || c == 0x7f {
+			return fmt.Sprintf("variable name %q contains control characters", key)
+		}
 	}
-
-	return env, nil
+	if len(value) > MaxValueLength {
+		return fmt.Sprintf("value of variable %q longer than %d bytes", key, MaxValueLength)
+	}
+	return ""
 }
 
 func parseData(data []byte, flags OpenFlags) (map[string]string, error) {
 	out := make(map[string]string)
+	bestEffort := flags&OpenBestEffort == OpenBestEffort
 
+	// offset of the current entry relative to the beginning of the file
+	offset := headerSize
+	padding := false
 	for _, envStr := range bytes.Split(data, []byte{0}) {
-		if len(envStr) == 0 || envStr[0] == 0 || envStr[0] == 255 {
+		entryOffset := offset
+		offset += len(envStr) + 1
+
+		if len(envStr) == 0 || envStr[0] == 255 {
+			// padding, variables are not expected past this point
+			padding = true
 			continue
 		}
+		if padding {
+			// data hidden behind a stray \0 or padding, this is
+			// not something that fw_setenv or u-boot would write
+			if bestEffort {
+				continue
+			}
+			return nil, &CorruptionError{Offset: entryOffset, Reason: "unexpected data after padding"}
+		}
 		l := strings.SplitN(string(envStr), "=", 2)
 		if len(l) != 2 || l[0] == "" {
-			if flags&OpenBestEffort == OpenBestEffort {
+			if bestEffort {
 				continue
 			}
 			return nil, fmt.Errorf("cannot parse line %q as key=value pair", envStr)
 		}
 		key := l[0]
 		value := l[1]
+		if reason := validateVariable(key, value); reason != "" {
+			if bestEffort {
+				continue
+			}
+			return nil, &CorruptionError{Offset: entryOffset, Reason: reason}
+		}
+		if _, ok := out[key]; !ok && len(out) == MaxVariables {
+			if bestEffort {
+				break
+			}
+			return nil, &CorruptionError{Offset: entryOffset, Reason: fmt.Sprintf("more than %d variables", MaxVariables)}
+		}
 		out[key] = value
 	}
 
@@ -233,8 +414,18 @@ func (env *Env) Save() error {
 	// checksum
 	crc := crc32.ChecksumIEEE(w.Bytes())
 
+	// the flags byte is only used by redundant environments, where the
+	// other copy is written so that the current one stays intact should
+	// the write be interrupted
+	fname := env.fname
+	var flag byte
+	if env.redundantFname != "" {
+		fname = env.redundantFname
+		flag = env.flag + 1
+	}
+
 	// ensure dir sync
-	dir, err := os.Open(filepath.Dir(env.fname))
+	dir, err := os.Open(filepath.Dir(fname))
 	if err != nil {
 		return err
 	}
@@ -252,7 +443,7 @@ func (env *Env) Save() error {
 	//
 	// We also do not O_TRUNC to avoid reallocations on the FS
 	// to minimize risk of fs corruption.
-	f, err := os.OpenFile(env.fname, os.O_WRONLY, 0666)
+	f, err := os.OpenFile(fname, os.O_WRONLY, 0666)
 	if err != nil {
 		return err
 	}
@@ -261,8 +452,10 @@ func (env *Env) Save() error {
 	if _, err := f.Write(writeUint32(crc)); err != nil {
 		return err
 	}
-	// padding bytes (e.g. for redundant header)
+	// padding bytes, the last one is the flags byte of the redundant
+	// header
 	pad := make([]byte, headerSize-binary.Size(crc))
+	pad[len(pad)-1] = flag
 	if _, err := f.Write(pad); err != nil {
 		return err
 	}
@@ -274,7 +467,16 @@ func (env *Env) Save() error {
 		return err
 	}
 
-	return dir.Sync()
+	if err := dir.Sync(); err != nil {
+		return err
+	}
+
+	if env.redundantFname != "" {
+		// the copy just written is now the current one
+		env.fname, env.redundantFname = env.redundantFname, env.fname
+		env.flag = flag
+	}
+	return nil
 }
 
 // Import is a helper that imports a given text file that contains
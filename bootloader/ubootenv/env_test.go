@@ -21,6 +21,7 @@ package ubootenv_test
 
 import (
 	"bytes"
+	"fmt"
 	"hash/crc32"
 	"io/ioutil"
 	"os"
@@ -228,6 +229,87 @@ func (u *uenvTestSuite) TestErrorOnMissingKeyInKeyValuePair(c *C) {
 	c.Assert(env, IsNil)
 }
 
+func (u *uenvTestSuite) TestOpenLimits(c *C) {
+	for _, tc := range []struct {
+		data []byte
+		err  string
+	}{{
+		data: []byte(strings.Repeat("k", ubootenv.MaxNameLength+1) + "=v\x00\x00"),
+		err:  `corrupted environment at offset 5: variable name longer than 256 bytes`,
+	}, {
+		data: []byte("foo=bar\x00k\x01ey=v\x00\x00"),
+		err:  `corrupted environment at offset 13: variable name "k\\x01ey" contains control characters`,
+	}, {
+		data: []byte("foo=bar\x00key=" + strings.Repeat("v", ubootenv.MaxValueLength+1) + "\x00\x00"),
+		err:  `corrupted environment at offset 13: value of variable "key" longer than 65536 bytes`,
+	}, {
+		// a stray \0 hiding variables past the apparent end
+		data: []byte("\x00foo=bar\x00\x00"),
+		err:  `corrupted environment at offset 6: unexpected data after padding`,
+	}, {
+		data: []byte("foo=bar\x00\xff\xff\x00key=value\x00\x00"),
+		err:  `corrupted environment at offset 16: unexpected data after padding`,
+	}} {
+		u.makeUbootEnvFromData(c, tc.data)
+
+		env, err := ubootenv.Open(u.envFile)
+		c.Check(err, ErrorMatches, tc.err)
+		c.Check(err, FitsTypeOf, &ubootenv.CorruptionError{})
+		c.Check(env, IsNil)
+		c.Check(ubootenv.Validate(u.envFile), ErrorMatches, tc.err)
+
+		// best effort skips the offending data
+		env, err = ubootenv.OpenWithFlags(u.envFile, ubootenv.OpenBestEffort)
+		c.Assert(err, IsNil)
+		c.Check(env.Get("key"), Equals, "")
+	}
+}
+
+func (u *uenvTestSuite) TestOpenTooManyVariables(c *C) {
+	var data []byte
+	for i := 0; i < ubootenv.MaxVariables+1; i++ {
+		data = append(data, []byte(fmt.Sprintf("key%d=value\x00", i))...)
+	}
+	data = append(data, 0)
+	u.makeUbootEnvFromData(c, data)
+
+	_, err := ubootenv.Open(u.envFile)
+	c.Check(err, ErrorMatches, `corrupted environment at offset 13231: more than 1024 variables`)
+
+	env, err := ubootenv.OpenWithFlags(u.envFile, ubootenv.OpenBestEffort)
+	c.Assert(err, IsNil)
+	c.Check(env.Get("key1023"), Equals, "value")
+	c.Check(env.Get("key1024"), Equals, "")
+}
+
+func (u *uenvTestSuite) TestValidate(c *C) {
+	env, err := ubootenv.Create(u.envFile, 4096)
+	c.Assert(err, IsNil)
+	env.Set("foo", "bar")
+	c.Assert(env.Save(), IsNil)
+	c.Check(ubootenv.Validate(u.envFile), IsNil)
+
+	// junk after the end marker is fine
+	u.makeUbootEnvFromData(c, []byte("foo=bar\x00\x00=b\xff\xff"))
+	c.Check(ubootenv.Validate(u.envFile), IsNil)
+
+	// padding in place of the end marker is fine too
+	u.makeUbootEnvFromData(c, []byte("foo=bar\x00\xff\xff"))
+	c.Check(ubootenv.Validate(u.envFile), IsNil)
+
+	u.makeUbootEnvFromData(c, []byte("foo=bar\x00baz=1\x00"))
+	err = ubootenv.Validate(u.envFile)
+	c.Check(err, ErrorMatches, `corrupted environment at offset 19: missing end of environment marker`)
+	c.Check(err, FitsTypeOf, &ubootenv.CorruptionError{})
+
+	u.makeUbootEnvFromData(c, []byte("foo\x00\x00"))
+	c.Check(ubootenv.Validate(u.envFile), ErrorMatches, `cannot parse line "foo" as key=value pair`)
+
+	err = ioutil.WriteFile(u.envFile, []byte("\x00\x00\x00\x00\x00foo=bar\x00\x00"), 0644)
+	c.Assert(err, IsNil)
+	c.Check(ubootenv.Validate(u.envFile), ErrorMatches, `cannot open ".*": bad CRC 0 != .*`)
+}
+
 func (u *uenvTestSuite) TestReadEmptyFile(c *C) {
 	mockData := []byte{
 		// eof
@@ -306,3 +388,128 @@ func (u *uenvTestSuite) TestWritesContentCorrectly(c *C) {
 	c.Assert(env.String(), Equals, "a=b\nc=d\n")
 	c.Assert(env.Size(), Equals, totalSize)
 }
+
+func (u *uenvTestSuite) TestRedundantSaveAlternates(c *C) {
+	envFile2 := filepath.Join(c.MkDir(), "uboot-redundant.env")
+
+	env, err := ubootenv.CreateRedundant(u.envFile, envFile2, 4096)
+	c.Assert(err, IsNil)
+	env.Set("foo", "bar")
+	c.Assert(env.Save(), IsNil)
+
+	// the first save goes to the redundant copy, with flag 1
+	c.Assert(ubootenv.Validate(envFile2), IsNil)
+	content, err := ioutil.ReadFile(envFile2)
+	c.Assert(err, IsNil)
+	c.Check(content, HasLen, 4096)
+	c.Check(content[4], Equals, byte(1))
+	content, err = ioutil.ReadFile(u.envFile)
+	c.Assert(err, IsNil)
+	c.Check(content, HasLen, 0)
+
+	env2, err := ubootenv.OpenRedundant(u.envFile, envFile2, 0)
+	c.Assert(err, IsNil)
+	c.Check(env2.String(), Equals, "foo=bar\n")
+	env2.Set("foo", "baz")
+	c.Assert(env2.Save(), IsNil)
+
+	// the second save goes to the other copy, with flag 2
+	content, err = ioutil.ReadFile(u.envFile)
+	c.Assert(err, IsNil)
+	c.Check(content[4], Equals, byte(2))
+	env3, err := ubootenv.Open(u.envFile)
+	c.Assert(err, IsNil)
+	c.Check(env3.String(), Equals, "foo=baz\n")
+	// while the previous copy is kept intact
+	env3, err = ubootenv.Open(envFile2)
+	c.Assert(err, IsNil)
+	c.Check(env3.String(), Equals, "foo=bar\n")
+
+	// saving again from the same env alternates again
+	env2.Set("foo", "qux")
+	c.Assert(env2.Save(), IsNil)
+	content, err = ioutil.ReadFile(envFile2)
+	c.Assert(err, IsNil)
+	c.Check(content[4], Equals, byte(3))
+
+	env4, err := ubootenv.OpenRedundant(u.envFile, envFile2, 0)
+	c.Assert(err, IsNil)
+	c.Check(env4.String(), Equals, "foo=qux\n")
+}
+
+func (u *uenvTestSuite) writeRedundantCopy(c *C, fname string, flag byte, data string) {
+	env, err := ubootenv.Create(fname, 4096)
+	c.Assert(err, IsNil)
+	c.Assert(env.Import(strings.NewReader(data)), IsNil)
+	c.Assert(env.Save(), IsNil)
+
+	// set the flags byte, it is not covered by the checksum
+	content, err := ioutil.ReadFile(fname)
+	c.Assert(err, IsNil)
+	content[4] = flag
+	c.Assert(ioutil.WriteFile(fname, content, 0644), IsNil)
+}
+
+func (u *uenvTestSuite) TestOpenRedundantPicksNewest(c *C) {
+	envFile2 := filepath.Join(c.MkDir(), "uboot-redundant.env")
+
+	for _, tc := range []struct {
+		flag1, flag2 byte
+		expected     string
+	}{
+		{0, 1, "copy=2\n"},
+		{1, 0, "copy=1\n"},
+		{7, 7, "copy=1\n"},
+		// the counter wraps around
+		{255, 0, "copy=2\n"},
+		{0, 255, "copy=1\n"},
+	} {
+		u.writeRedundantCopy(c, u.envFile, tc.flag1, "copy=1\n")
+		u.writeRedundantCopy(c, envFile2, tc.flag2, "copy=2\n")
+
+		env, err := ubootenv.OpenRedundant(u.envFile, envFile2, 0)
+		c.Assert(err, IsNil)
+		c.Check(env.String(), Equals, tc.expected, Commentf("flags %v %v", tc.flag1, tc.flag2))
+	}
+}
+
+func (u *uenvTestSuite) TestOpenRedundantCorruptedCopy(c *C) {
+	envFile2 := filepath.Join(c.MkDir(), "uboot-redundant.env")
+
+	u.writeRedundantCopy(c, u.envFile, 1, "copy=1\n")
+	u.writeRedundantCopy(c, envFile2, 2, "copy=2\n")
+	// corrupt the most recent copy
+	content, err := ioutil.ReadFile(envFile2)
+	c.Assert(err, IsNil)
+	content[10] ^= 0xff
+	c.Assert(ioutil.WriteFile(envFile2, content, 0644), IsNil)
+
+	env, err := ubootenv.OpenRedundant(u.envFile, envFile2, 0)
+	c.Assert(err, IsNil)
+	c.Check(env.String(), Equals, "copy=1\n")
+
+	// which gets overwritten on the next save
+	c.Assert(env.Save(), IsNil)
+	content, err = ioutil.ReadFile(envFile2)
+	c.Assert(err, IsNil)
+	c.Check(content[4], Equals, byte(2))
+	c.Check(ubootenv.Validate(envFile2), IsNil)
+
+	// with both copies corrupted there is nothing to use
+	c.Assert(ioutil.WriteFile(u.envFile, nil, 0644), IsNil)
+	c.Assert(ioutil.WriteFile(envFile2, nil, 0644), IsNil)
+	_, err = ubootenv.OpenRedundant(u.envFile, envFile2, 0)
+	c.Check(err, ErrorMatches, `cannot open redundant environment: cannot open ".*/uboot.env": smaller than expected header, cannot open ".*/uboot-redundant.env": smaller than expected header`)
+}
+
+func (u *uenvTestSuite) TestOpenRedundantDifferentSizes(c *C) {
+	envFile2 := filepath.Join(c.MkDir(), "uboot-redundant.env")
+
+	u.writeRedundantCopy(c, u.envFile, 1, "copy=1\n")
+	env, err := ubootenv.Create(envFile2, 8192)
+	c.Assert(err, IsNil)
+	c.Assert(env.Save(), IsNil)
+
+	_, err = ubootenv.OpenRedundant(u.envFile, envFile2, 0)
+	c.Check(err, ErrorMatches, `cannot open redundant environment: ".*/uboot.env" and ".*/uboot-redundant.env" have different sizes`)
+}
@@ -22,6 +22,8 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"net/url"
 )
 
 // aliasAction represents an action performed on aliases.
@@ -32,6 +34,8 @@ type aliasAction struct {
 	Snap   string `json:"snap,omitempty"`
 	App    string `json:"app,omitempty"`
 	Alias  string `json:"alias,omitempty"`
+
+	AliasSet string `json:"alias-set,omitempty"`
 }
 
 // performAliasAction performs a single action on aliases.
@@ -87,12 +91,32 @@ func (client *Client) Prefer(snapName string) (changeID string, err error) {
 	})
 }
 
+// EnableAliasSet enables all the aliases in an alias set of a snap.
+func (client *Client) EnableAliasSet(snapName, aliasSet string) (changeID string, err error) {
+	return client.performAliasAction(&aliasAction{
+		Action:   "enable-alias-set",
+		Snap:     snapName,
+		AliasSet: aliasSet,
+	})
+}
+
+// DisableAliasSet disables all the aliases in an alias set of a snap,
+// manual ones included.
+func (client *Client) DisableAliasSet(snapName, aliasSet string) (changeID string, err error) {
+	return client.performAliasAction(&aliasAction{
+		Action:   "disable-alias-set",
+		Snap:     snapName,
+		AliasSet: aliasSet,
+	})
+}
+
 // AliasStatus represents the status of an alias.
 type AliasStatus struct {
-	Command string `json:"command"`
-	Status  string `json:"status"`
-	Manual  string `json:"manual,omitempty"`
-	Auto    string `json:"auto,omitempty"`
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	Manual   string `json:"manual,omitempty"`
+	Auto     string `json:"auto,omitempty"`
+	AliasSet string `json:"alias-set,omitempty"`
 }
 
 // Aliases returns a map snap -> alias -> AliasStatus for all snaps and aliases in the system.
@@ -100,3 +124,41 @@ func (client *Client) Aliases() (allStatuses map[string]map[string]AliasStatus,
 	_, err = client.doSync("GET", "/v2/aliases", nil, nil, nil, &allStatuses)
 	return
 }
+
+// AliasSetStatus represents an alias set declared by a snap.
+type AliasSetStatus struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+	Enabled bool     `json:"enabled"`
+}
+
+// AliasConflict represents an alias of a snap held by another snap,
+// either as one of its enabled aliases (Kind "alias") or as its command
+// namespace (Kind "command-namespace").
+type AliasConflict struct {
+	Alias string `json:"alias"`
+	Snap  string `json:"snap"`
+	Kind  string `json:"kind"`
+}
+
+// SnapAliases represents the commands exported by a snap.
+type SnapAliases struct {
+	Snap string `json:"snap"`
+	// Commands are the effective command names of the snap, from its
+	// applications and its enabled aliases.
+	Commands  []string               `json:"commands"`
+	Aliases   map[string]AliasStatus `json:"aliases,omitempty"`
+	AliasSets []AliasSetStatus       `json:"alias-sets,omitempty"`
+	Conflicts []AliasConflict        `json:"conflicts,omitempty"`
+}
+
+// SnapAliases returns the commands, aliases, alias sets and alias
+// conflicts of the given snap.
+func (client *Client) SnapAliases(snapName string) (*SnapAliases, error) {
+	var res SnapAliases
+	path := fmt.Sprintf("/v2/snaps/%s/aliases", url.PathEscape(snapName))
+	if _, err := client.doSync("GET", path, nil, nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
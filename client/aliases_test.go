@@ -198,3 +198,69 @@ func (cs *clientSuite) TestClientAliases(c *check.C) {
 		},
 	})
 }
+
+func (cs *clientSuite) TestClientEnableDisableAliasSet(c *check.C) {
+	for _, tc := range []struct {
+		action string
+		call   func(snapName, aliasSet string) (string, error)
+	}{
+		{"enable-alias-set", cs.cli.EnableAliasSet},
+		{"disable-alias-set", cs.cli.DisableAliasSet},
+	} {
+		cs.status = 202
+		cs.rsp = `{
+		"type": "async",
+                "status-code": 202,
+		"result": { },
+                "change": "chgid"
+	}`
+		id, err := tc.call("some-snap", "tools")
+		c.Assert(err, check.IsNil)
+		c.Check(id, check.Equals, "chgid")
+		c.Check(cs.req.Method, check.Equals, "POST")
+		c.Check(cs.req.URL.Path, check.Equals, "/v2/aliases")
+		var body map[string]interface{}
+		decoder := json.NewDecoder(cs.req.Body)
+		err = decoder.Decode(&body)
+		c.Check(err, check.IsNil)
+		c.Check(body, check.DeepEquals, map[string]interface{}{
+			"action":    tc.action,
+			"snap":      "some-snap",
+			"alias-set": "tools",
+		})
+	}
+}
+
+func (cs *clientSuite) TestClientSnapAliases(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+                    "snap": "foo",
+                    "commands": ["foo", "foo.reset", "fr"],
+                    "aliases": {
+                        "fr": {"command": "foo.reset", "status": "auto", "auto": "reset", "alias-set": "tools"},
+                        "fd": {"command": "foo.dump", "status": "disabled", "auto": "dump"}
+                    },
+                    "alias-sets": [{"name": "tools", "aliases": ["fr"], "enabled": true}],
+                    "conflicts": [{"alias": "fd", "snap": "bar", "kind": "alias"}]
+		}
+	}`
+	res, err := cs.cli.SnapAliases("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/foo/aliases")
+	c.Check(res, check.DeepEquals, &client.SnapAliases{
+		Snap:     "foo",
+		Commands: []string{"foo", "foo.reset", "fr"},
+		Aliases: map[string]client.AliasStatus{
+			"fr": {Command: "foo.reset", Status: "auto", Auto: "reset", AliasSet: "tools"},
+			"fd": {Command: "foo.dump", Status: "disabled", Auto: "dump"},
+		},
+		AliasSets: []client.AliasSetStatus{
+			{Name: "tools", Aliases: []string{"fr"}, Enabled: true},
+		},
+		Conflicts: []client.AliasConflict{
+			{Alias: "fd", Snap: "bar", Kind: "alias"},
+		},
+	})
+}
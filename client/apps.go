@@ -55,6 +55,36 @@ type AppInfo struct {
 	Active      bool             `json:"active,omitempty"`
 	CommonID    string           `json:"common-id,omitempty"`
 	Activators  []AppActivator   `json:"activators,omitempty"`
+	// Reloadable is set for services with a reload command, which
+	// are reloaded instead of restarted by Restart with Reload set.
+	Reloadable bool `json:"reloadable,omitempty"`
+
+	// Restarts is the number of automatic restarts of the service
+	// performed by systemd since it was last started explicitly.
+	Restarts int `json:"restarts,omitempty"`
+	// LastExitCode is the exit code of the last exit of the main process
+	// of the service, if it exited on its own.
+	LastExitCode int `json:"last-exit-code,omitempty"`
+	// LastExitSignal is the name of the signal that terminated the main
+	// process of the service the last time it exited, if any.
+	LastExitSignal string `json:"last-exit-signal,omitempty"`
+	// StateChanged is the time at which the service last changed state.
+	StateChanged time.Time `json:"state-changed,omitempty"`
+}
+
+func (a AppInfo) MarshalJSON() ([]byte, error) {
+	type auxAppInfo AppInfo // use auxiliary type so that Go does not call AppInfo.MarshalJSON()
+	// separate type just for marshalling
+	m := struct {
+		auxAppInfo
+		StateChanged *time.Time `json:"state-changed,omitempty"`
+	}{
+		auxAppInfo: auxAppInfo(a),
+	}
+	if !a.StateChanged.IsZero() {
+		m.StateChanged = &a.StateChanged
+	}
+	return json.Marshal(&m)
 }
 
 // IsService returns true if the application is a background daemon.
@@ -253,8 +283,9 @@ func (client *Client) Stop(names []string, opts StopOptions) (changeID string, e
 
 // RestartOptions represent the different options of the Restart call.
 type RestartOptions struct {
-	// Reload the services, if possible (i.e. if the App has a
-	// ReloadCommand, invoque it), instead of restarting.
+	// Reload the services that have a reload command, by invoking
+	// it, instead of restarting them. The other services are
+	// restarted.
 	Reload bool `json:"reload,omitempty"`
 }
 
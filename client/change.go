@@ -62,15 +62,51 @@ type Task struct {
 	Status   string       `json:"status"`
 	Log      []string     `json:"log,omitempty"`
 	Progress TaskProgress `json:"progress"`
+	// Abortable is set if aborting the change takes effect on the task
+	// promptly
+	Abortable bool `json:"abortable,omitempty"`
+	// Error classifies why the task failed, if known
+	Error *TaskError `json:"error,omitempty"`
 
 	SpawnTime time.Time `json:"spawn-time,omitempty"`
 	ReadyTime time.Time `json:"ready-time,omitempty"`
 }
 
+// TaskErrorKind classifies why a task failed.
+type TaskErrorKind string
+
+const (
+	// TaskErrorKindNoSpace: there was not enough disk space. The value
+	// may hold the "path" that ran out of space and the affected
+	// "snap-names".
+	TaskErrorKindNoSpace TaskErrorKind = "no-space"
+	// TaskErrorKindBadAssertion: the assertions needed to verify a snap
+	// are missing or invalid. The value may hold the "snap-name" or the
+	// missing "assertion-type".
+	TaskErrorKindBadAssertion TaskErrorKind = "bad-assertion"
+	// TaskErrorKindStoreOffline: the store could not be reached as the
+	// network is down.
+	TaskErrorKindStoreOffline TaskErrorKind = "store-offline"
+	// TaskErrorKindHookFailed: a hook of a snap failed. The value holds
+	// the "snap-name", the "hook" and, if the hook exited, its
+	// "exit-code".
+	TaskErrorKindHookFailed TaskErrorKind = "hook-failed"
+)
+
+// TaskError describes in a machine-parseable way why a task failed.
+type TaskError struct {
+	Kind  TaskErrorKind          `json:"kind"`
+	Value map[string]interface{} `json:"value,omitempty"`
+}
+
 type TaskProgress struct {
 	Label string `json:"label"`
 	Done  int    `json:"done"`
 	Total int    `json:"total"`
+	// Unit is the unit of Done and Total, e.g. "bytes", empty for steps
+	Unit string `json:"unit,omitempty"`
+	// ETA is the estimated number of seconds until the task is done
+	ETA float64 `json:"eta,omitempty"`
 }
 
 type changeAndData struct {
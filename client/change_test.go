@@ -20,6 +20,7 @@
 package client_test
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"time"
 
@@ -37,7 +38,7 @@ func (cs *clientSuite) TestClientChange(c *check.C) {
   "ready": false,
   "spawn-time": "2016-04-21T01:02:03Z",
   "ready-time": "2016-04-21T01:02:04Z",
-  "tasks": [{"kind": "bar", "summary": "...", "status": "Do", "progress": {"done": 0, "total": 1}, "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z"}]
+  "tasks": [{"kind": "bar", "summary": "...", "status": "Do", "progress": {"done": 0, "total": 1}, "abortable": true, "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z"}]
 }}`
 
 	chg, err := cs.cli.Change("uno")
@@ -52,6 +53,7 @@ func (cs *clientSuite) TestClientChange(c *check.C) {
 			Summary:   "...",
 			Status:    "Do",
 			Progress:  client.TaskProgress{Done: 0, Total: 1},
+			Abortable: true,
 			SpawnTime: time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC),
 			ReadyTime: time.Date(2016, 04, 21, 1, 2, 4, 0, time.UTC),
 		}},
@@ -61,6 +63,30 @@ func (cs *clientSuite) TestClientChange(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientChangeTaskError(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Error",
+  "ready": true,
+  "err": "cannot perform the following tasks: ...",
+  "tasks": [{"kind": "run-hook", "summary": "...", "status": "Error", "progress": {"done": 1, "total": 1}, "error": {"kind": "hook-failed", "value": {"snap-name": "foo", "hook": "configure", "exit-code": 1}}}]
+}}`
+
+	chg, err := cs.cli.Change("uno")
+	c.Assert(err, check.IsNil)
+	c.Assert(chg.Tasks, check.HasLen, 1)
+	c.Check(chg.Tasks[0].Error, check.DeepEquals, &client.TaskError{
+		Kind: client.TaskErrorKindHookFailed,
+		Value: map[string]interface{}{
+			"snap-name": "foo",
+			"hook":      "configure",
+			"exit-code": json.Number("1"),
+		},
+	})
+}
+
 func (cs *clientSuite) TestClientChangeData(c *check.C) {
 	cs.rsp = `{"type": "sync", "result": {
   "id":   "uno",
@@ -657,6 +657,34 @@ type RefreshInfo struct {
 	Next     string `json:"next,omitempty"`
 }
 
+// PlannedMaintenance describes a restart of snapd or of the system planned by
+// snapd.
+type PlannedMaintenance struct {
+	// Kind is either "daemon-restart" or "system-restart".
+	Kind string `json:"kind"`
+	// Action is one of "reboot", "halt" or "poweroff" for system restarts.
+	Action string `json:"action,omitempty"`
+	// Reason is the cause of the restart, e.g. "kernel-refresh".
+	Reason   string `json:"reason"`
+	Snap     string `json:"snap,omitempty"`
+	ChangeID string `json:"change-id,omitempty"`
+	// PlannedTime is when the restart is planned to happen.
+	PlannedTime time.Time `json:"planned-time"`
+}
+
+// Readiness tells how far snapd got in starting up.
+type Readiness struct {
+	// State is one of "initializing" while the initialization of snapd,
+	// e.g. the regeneration of the security profiles of snaps, is still
+	// being done in the background, "ready" or "degraded" if it could
+	// not be completed.
+	State string `json:"state"`
+	// Since is when the current state was entered.
+	Since time.Time `json:"since"`
+	// Message explains why snapd is degraded.
+	Message string `json:"message,omitempty"`
+}
+
 // SysInfo holds system information
 type SysInfo struct {
 	Series    string    `json:"series,omitempty"`
@@ -673,6 +701,12 @@ type SysInfo struct {
 	Refresh         RefreshInfo         `json:"refresh,omitempty"`
 	Confinement     string              `json:"confinement"`
 	SandboxFeatures map[string][]string `json:"sandbox-features,omitempty"`
+
+	// Maintenance is the next maintenance planned by snapd, if any.
+	Maintenance *PlannedMaintenance `json:"maintenance,omitempty"`
+
+	// Readiness is unset with snapd versions not reporting it.
+	Readiness *Readiness `json:"readiness,omitempty"`
 }
 
 func (rsp *response) err(cli *Client, statusCode int) error {
@@ -379,6 +379,25 @@ func (cs *clientSuite) TestClientSysInfo(c *C) {
 	})
 }
 
+func (cs *clientSuite) TestClientSysInfoMaintenance(c *C) {
+	cs.rsp = `{"type": "sync", "result":
+                     {"series": "16",
+                      "version": "2",
+                      "build-id": "1234",
+                      "confinement": "strict",
+                      "maintenance": {"kind": "system-restart", "action": "reboot", "reason": "kernel-refresh", "snap": "pc-kernel", "change-id": "42", "planned-time": "2030-01-02T03:04:05Z"}}}`
+	sysInfo, err := cs.cli.SysInfo()
+	c.Assert(err, IsNil)
+	c.Check(sysInfo.Maintenance, DeepEquals, &client.PlannedMaintenance{
+		Kind:        "system-restart",
+		Action:      "reboot",
+		Reason:      "kernel-refresh",
+		Snap:        "pc-kernel",
+		ChangeID:    "42",
+		PlannedTime: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+}
+
 func (cs *clientSuite) TestServerVersion(c *C) {
 	cs.rsp = `{"type": "sync", "result":
                      {"series": "16",
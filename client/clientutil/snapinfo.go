@@ -78,6 +78,7 @@ func ClientSnapFromSnapInfo(snapInfo *snap.Info, decorator StatusDecorator) (*cl
 		License:     snapInfo.License,
 		Media:       snapInfo.Media,
 		Prices:      snapInfo.Prices,
+		Security:    snapInfo.Security,
 		Channels:    snapInfo.Channels,
 		Tracks:      snapInfo.Tracks,
 		CommonIDs:   snapInfo.CommonIDs,
@@ -119,6 +120,9 @@ func ClientAppInfoNotes(app *client.AppInfo) string {
 	if seenDbus {
 		notes = append(notes, "dbus-activated")
 	}
+	if app.Reloadable {
+		notes = append(notes, "reloadable")
+	}
 	if len(notes) == 0 {
 		return "-"
 	}
@@ -144,6 +148,7 @@ func ClientAppInfosFromSnapAppInfos(apps []*snap.AppInfo, decorator StatusDecora
 
 		appInfo.Daemon = app.Daemon
 		appInfo.DaemonScope = app.DaemonScope
+		appInfo.Reloadable = app.IsService() && app.ReloadCommand != ""
 		if !app.IsService() || decorator == nil || !app.Snap.IsActive() {
 			out = append(out, appInfo)
 			continue
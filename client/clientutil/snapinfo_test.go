@@ -87,6 +87,10 @@ func (*cmdSuite) TestClientSnapFromSnapInfo(c *C) {
 			{Type: "screenshot", URL: "https://dashboard.snapcraft.io/site_media/appmedia/2018/01/Thingy_01.png"},
 			{Type: "screenshot", URL: "https://dashboard.snapcraft.io/site_media/appmedia/2018/01/Thingy_02.png", Width: 600, Height: 200},
 		},
+		Security: &snap.SecurityInfo{
+			Severity: "high",
+			CVEs:     []string{"CVE-2023-1234"},
+		},
 		CommonIDs: []string{"org.thingy"},
 		StoreURL:  "https://snapcraft.io/thingy",
 		Broken:    "broken",
@@ -156,6 +160,7 @@ func (*cmdSuite) TestClientSnapFromSnapInfo(c *C) {
 	c.Check(ci.StoreURL, Equals, si.StoreURL)
 	c.Check(ci.Developer, Equals, "thingyinc")
 	c.Check(ci.Publisher, DeepEquals, &si.Publisher)
+	c.Check(ci.Security, DeepEquals, si.Security)
 }
 
 type testStatusDecorator struct {
@@ -318,4 +323,10 @@ func (*cmdSuite) TestAppStatusNotes(c *C) {
 		},
 	}
 	c.Check(clientutil.ClientAppInfoNotes(&ai), Equals, "user,timer-activated,socket-activated,dbus-activated")
+
+	ai = client.AppInfo{
+		Daemon:     "simple",
+		Reloadable: true,
+	}
+	c.Check(clientutil.ClientAppInfoNotes(&ai), Equals, "reloadable")
 }
@@ -20,6 +20,8 @@
 package client
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/url"
 )
 
@@ -36,6 +38,17 @@ type Connection struct {
 	SlotAttrs map[string]interface{} `json:"slot-attrs,omitempty"`
 	// PlugAttrs is the list of attributes of the plug side of the connection.
 	PlugAttrs map[string]interface{} `json:"plug-attrs,omitempty"`
+	// DeprecatedBy is set to the replacement of the interface of the
+	// connection when the interface is deprecated.
+	DeprecatedBy string `json:"deprecated-by,omitempty"`
+}
+
+// PendingConnection describes a plug that could not be auto-connected.
+type PendingConnection struct {
+	Plug      PlugRef `json:"plug"`
+	Interface string  `json:"interface"`
+	// Reason explains why the plug could not be auto-connected.
+	Reason string `json:"reason"`
 }
 
 // Connections contains information about connections, as well as related plugs
@@ -45,8 +58,11 @@ type Connections struct {
 	Established []Connection `json:"established"`
 	// Undersired is a list of connections that are manually denied.
 	Undesired []Connection `json:"undesired"`
-	Plugs     []Plug       `json:"plugs"`
-	Slots     []Slot       `json:"slots"`
+	// Pending is a list of plugs that could not be auto-connected yet,
+	// only listed when All is set in the options.
+	Pending []PendingConnection `json:"pending"`
+	Plugs   []Plug              `json:"plugs"`
+	Slots   []Slot              `json:"slots"`
 }
 
 // ConnectionOptions contains criteria for selecting matching connections, plugs
@@ -79,3 +95,119 @@ func (client *Client) Connections(opts *ConnectionOptions) (Connections, error)
 	_, err := client.doSync("GET", "/v2/connections", query, nil, nil, &conns)
 	return conns, err
 }
+
+// ConnectionIntent is a manual connection, or a manual disconnection of
+// an automatic connection, as recorded in a connections backup.
+type ConnectionIntent struct {
+	Plug      PlugRef `json:"plug"`
+	Slot      SlotRef `json:"slot"`
+	Interface string  `json:"interface"`
+	// Undesired is set for automatic connections that were manually
+	// disconnected.
+	Undesired bool `json:"undesired,omitempty"`
+	// Reason is set for the connection intents that were skipped when
+	// restoring them.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ConnectionsBackup is a portable document of the manual connections and
+// disconnections of the system, that can be restored after a reinstall of
+// the snaps.
+type ConnectionsBackup struct {
+	Format      int                `json:"format"`
+	Connections []ConnectionIntent `json:"connections"`
+}
+
+// ExportConnections returns the manual connections and disconnections
+// involving the given snap, or all of them if snapName is empty.
+func (client *Client) ExportConnections(snapName string) (*ConnectionsBackup, error) {
+	var backup ConnectionsBackup
+	query := url.Values{}
+	if snapName != "" {
+		query.Set("snap", snapName)
+	}
+	_, err := client.doSync("GET", "/v2/connections/backup", query, nil, nil, &backup)
+	if err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+// RestoreConnections re-applies the manual connections and disconnections
+// of the given backup. Connections are subject to the policy of the system
+// as any manual connection. The connection intents that were skipped are
+// listed under "skipped" in the data of the change.
+func (client *Client) RestoreConnections(backup *ConnectionsBackup) (changeID string, err error) {
+	b, err := json.Marshal(backup)
+	if err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/connections/backup", nil, nil, bytes.NewReader(b))
+}
+
+// ConstraintsOutcome tells whether any of the alternative constraints of
+// a declaration rule matched a candidate connection.
+type ConstraintsOutcome struct {
+	Matched bool `json:"matched"`
+	// Mismatches are the reasons why the alternatives did not match.
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+// ConnectionRuleExplanation explains how the rule of a declaration for an
+// interface was evaluated for a candidate connection.
+type ConnectionRuleExplanation struct {
+	// Declaration is either "snap-declaration" or "base-declaration".
+	Declaration string `json:"declaration"`
+	// Side is either "plug" or "slot".
+	Side string `json:"side"`
+	// Snap is the snap the snap-declaration is for.
+	Snap  string             `json:"snap,omitempty"`
+	Deny  ConstraintsOutcome `json:"deny"`
+	Allow ConstraintsOutcome `json:"allow"`
+	// Decisive is set for the rule that decided the outcome.
+	Decisive bool `json:"decisive,omitempty"`
+}
+
+// ConnectionPolicyExplanation explains the outcome of checking a
+// candidate connection against the declarations.
+type ConnectionPolicyExplanation struct {
+	// Kind is either "connection" or "auto-connection".
+	Kind    string `json:"kind"`
+	Allowed bool   `json:"allowed"`
+	// Error is why the candidate connection is not allowed.
+	Error string `json:"error,omitempty"`
+	// Rules are the rules for the interface, in order of precedence.
+	Rules []ConnectionRuleExplanation `json:"rules,omitempty"`
+	// Hint tells what would need to change for the candidate
+	// connection to be allowed.
+	Hint string `json:"hint,omitempty"`
+}
+
+// ConnectionExplanation explains whether a plug can be connected to a
+// slot, manually and automatically.
+type ConnectionExplanation struct {
+	Plug      PlugRef `json:"plug"`
+	Slot      SlotRef `json:"slot"`
+	Interface string  `json:"interface"`
+	// Connected is set if the plug is connected to the slot.
+	Connected bool `json:"connected,omitempty"`
+	// Unasserted is set if either snap has no snap-declaration, manual
+	// connections are then not checked.
+	Unasserted     bool                         `json:"unasserted,omitempty"`
+	Connection     *ConnectionPolicyExplanation `json:"connection"`
+	AutoConnection *ConnectionPolicyExplanation `json:"auto-connection"`
+}
+
+// ExplainConnections explains whether the plugs and slots of the given
+// snap, or only its plug or slot with the given name if not empty, can be
+// connected to the slots and plugs of the same interface in the system.
+func (client *Client) ExplainConnections(snapName, name string) ([]ConnectionExplanation, error) {
+	var expls []ConnectionExplanation
+	query := url.Values{}
+	query.Set("snap", snapName)
+	if name != "" {
+		query.Set("name", name)
+	}
+	_, err := client.doSync("GET", "/v2/connections/explain", query, nil, nil, &expls)
+	return expls, err
+}
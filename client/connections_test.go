@@ -20,6 +20,7 @@
 package client_test
 
 import (
+	"encoding/json"
 	"net/url"
 
 	"gopkg.in/check.v1"
@@ -133,6 +134,13 @@ func (cs *clientSuite) TestClientConnectionsAll(c *check.C) {
 					"manual": true
                                 }
 			],
+			"pending": [
+				{
+					"plug": {"snap": "canonical-pi2", "plug": "pin-15"},
+					"interface": "bool-file",
+					"reason": "multiple candidate slots: keyboard-lights:capslock-led, keyboard-lights:numlock-led"
+				}
+			],
 			"plugs": [
 				{
 					"snap": "canonical-pi2",
@@ -191,6 +199,13 @@ func (cs *clientSuite) TestClientConnectionsAll(c *check.C) {
 				Manual:    true,
 			},
 		},
+		Pending: []client.PendingConnection{
+			{
+				Plug:      client.PlugRef{Snap: "canonical-pi2", Name: "pin-15"},
+				Interface: "bool-file",
+				Reason:    "multiple candidate slots: keyboard-lights:capslock-led, keyboard-lights:numlock-led",
+			},
+		},
 		Plugs: []client.Plug{
 			{
 				Snap:      "canonical-pi2",
@@ -268,3 +283,123 @@ func (cs *clientSuite) TestClientConnectionsFilter(c *check.C) {
 		"snap":      []string{"foo"},
 	})
 }
+
+func (cs *clientSuite) TestClientExplainConnections(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [
+			{
+				"plug": {"snap": "consumer", "plug": "plug"},
+				"slot": {"snap": "producer", "slot": "slot"},
+				"interface": "test",
+				"connection": {"kind": "connection", "allowed": true},
+				"auto-connection": {
+					"kind": "auto-connection",
+					"error": "auto-connection denied by slot rule of interface \"test\"",
+					"rules": [
+						{
+							"declaration": "base-declaration",
+							"side": "slot",
+							"deny": {"matched": true},
+							"allow": {"matched": false, "mismatches": ["publisher id does not match"]},
+							"decisive": true
+						}
+					],
+					"hint": "some hint"
+				}
+			}
+		]
+	}`
+	expls, err := cs.cli.ExplainConnections("producer", "slot")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections/explain")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"snap": []string{"producer"},
+		"name": []string{"slot"},
+	})
+	c.Check(expls, check.DeepEquals, []client.ConnectionExplanation{{
+		Plug:       client.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:       client.SlotRef{Snap: "producer", Name: "slot"},
+		Interface:  "test",
+		Connection: &client.ConnectionPolicyExplanation{Kind: "connection", Allowed: true},
+		AutoConnection: &client.ConnectionPolicyExplanation{
+			Kind:  "auto-connection",
+			Error: `auto-connection denied by slot rule of interface "test"`,
+			Rules: []client.ConnectionRuleExplanation{{
+				Declaration: "base-declaration",
+				Side:        "slot",
+				Deny:        client.ConstraintsOutcome{Matched: true},
+				Allow:       client.ConstraintsOutcome{Mismatches: []string{"publisher id does not match"}},
+				Decisive:    true,
+			}},
+			Hint: "some hint",
+		},
+	}})
+}
+
+func (cs *clientSuite) TestClientExportConnections(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"format": 1,
+			"connections": [
+				{
+					"plug": {"snap": "consumer", "plug": "plug"},
+					"slot": {"snap": "system", "slot": "slot"},
+					"interface": "test",
+					"undesired": true
+				}
+			]
+		}
+	}`
+	backup, err := cs.cli.ExportConnections("consumer")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections/backup")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"snap": []string{"consumer"},
+	})
+	c.Check(backup, check.DeepEquals, &client.ConnectionsBackup{
+		Format: 1,
+		Connections: []client.ConnectionIntent{{
+			Plug:      client.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:      client.SlotRef{Snap: "system", Name: "slot"},
+			Interface: "test",
+			Undesired: true,
+		}},
+	})
+}
+
+func (cs *clientSuite) TestClientRestoreConnections(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+	changeID, err := cs.cli.RestoreConnections(&client.ConnectionsBackup{
+		Format: 1,
+		Connections: []client.ConnectionIntent{{
+			Plug:      client.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:      client.SlotRef{Snap: "producer", Name: "slot"},
+			Interface: "test",
+		}},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/connections/backup")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"format": float64(1),
+		"connections": []interface{}{
+			map[string]interface{}{
+				"plug":      map[string]interface{}{"snap": "consumer", "plug": "plug"},
+				"slot":      map[string]interface{}{"snap": "producer", "slot": "slot"},
+				"interface": "test",
+			},
+		},
+	})
+}
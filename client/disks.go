@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"github.com/snapcore/snapd/gadget/quantity"
+)
+
+// Disk describes a physical disk of the system and its partitions.
+type Disk struct {
+	Device     string        `json:"device"`
+	ID         string        `json:"id,omitempty"`
+	Schema     string        `json:"schema"`
+	Size       quantity.Size `json:"size"`
+	SectorSize quantity.Size `json:"sector-size"`
+	// UnallocatedSize is the usable space of the disk not covered by
+	// any partition.
+	UnallocatedSize quantity.Size `json:"unallocated-size"`
+	// GadgetVolume is the name of the volume of the gadget that the
+	// disk was matched with on install, if any.
+	GadgetVolume string          `json:"gadget-volume,omitempty"`
+	Partitions   []DiskPartition `json:"partitions"`
+}
+
+// DiskPartition describes a partition of a disk.
+type DiskPartition struct {
+	Node            string          `json:"node"`
+	Index           int             `json:"index"`
+	Offset          quantity.Offset `json:"offset"`
+	Size            quantity.Size   `json:"size"`
+	PartitionLabel  string          `json:"partition-label,omitempty"`
+	PartitionUUID   string          `json:"partition-uuid,omitempty"`
+	PartitionType   string          `json:"partition-type,omitempty"`
+	FilesystemType  string          `json:"filesystem-type,omitempty"`
+	FilesystemLabel string          `json:"filesystem-label,omitempty"`
+	FilesystemUUID  string          `json:"filesystem-uuid,omitempty"`
+	// Role is the gadget role of the partition, one of system-seed,
+	// system-boot, system-data or system-save, if known.
+	Role      string `json:"role,omitempty"`
+	Encrypted bool   `json:"encrypted"`
+	// MountPoints are where the filesystem of the partition, or of its
+	// encrypted container, is mounted.
+	MountPoints []string `json:"mount-points,omitempty"`
+	// FreeSize is the space available in the filesystem, only known
+	// when it is mounted.
+	FreeSize *quantity.Size `json:"free-size,omitempty"`
+}
+
+// Disks returns the physical disks of the system, their partitions and
+// how they map to the roles of the gadget.
+func (client *Client) Disks() ([]Disk, error) {
+	var disks []Disk
+	if _, err := client.doSync("GET", "/v2/disks", nil, nil, nil, &disks); err != nil {
+		return nil, err
+	}
+	return disks, nil
+}
@@ -0,0 +1,83 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/gadget/quantity"
+)
+
+func (cs *clientSuite) TestClientDisks(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"device": "/dev/vda",
+			"id": "disk-id",
+			"schema": "gpt",
+			"size": 10737418240,
+			"sector-size": 512,
+			"unallocated-size": 1048576,
+			"gadget-volume": "pc",
+			"partitions": [{
+				"node": "/dev/vda4",
+				"index": 4,
+				"offset": 2097152,
+				"size": 1073741824,
+				"partition-label": "ubuntu-data-enc",
+				"filesystem-type": "crypto_LUKS",
+				"filesystem-label": "ubuntu-data-enc",
+				"role": "system-data",
+				"encrypted": true,
+				"mount-points": ["/run/mnt/data"],
+				"free-size": 4096
+			}]
+		}]
+	}`
+	disks, err := cs.cli.Disks()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/disks")
+	free := quantity.Size(4096)
+	c.Check(disks, check.DeepEquals, []client.Disk{{
+		Device:          "/dev/vda",
+		ID:              "disk-id",
+		Schema:          "gpt",
+		Size:            10 * quantity.SizeGiB,
+		SectorSize:      512,
+		UnallocatedSize: quantity.SizeMiB,
+		GadgetVolume:    "pc",
+		Partitions: []client.DiskPartition{{
+			Node:            "/dev/vda4",
+			Index:           4,
+			Offset:          2 * quantity.OffsetMiB,
+			Size:            quantity.SizeGiB,
+			PartitionLabel:  "ubuntu-data-enc",
+			FilesystemType:  "crypto_LUKS",
+			FilesystemLabel: "ubuntu-data-enc",
+			Role:            "system-data",
+			Encrypted:       true,
+			MountPoints:     []string{"/run/mnt/data"},
+			FreeSize:        &free,
+		}},
+	}})
+}
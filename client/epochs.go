@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// EpochMigrationStep is a revision installed while migrating a snap
+// across epochs.
+type EpochMigrationStep struct {
+	Revision snap.Revision `json:"revision"`
+	Version  string        `json:"version"`
+	Epoch    snap.Epoch    `json:"epoch"`
+	Channel  string        `json:"channel"`
+}
+
+// EpochMigration explains whether the revision of a snap in a channel
+// can be reached from the installed revision, given their epochs.
+type EpochMigration struct {
+	Snap            string             `json:"snap"`
+	Channel         string             `json:"channel"`
+	CurrentRevision snap.Revision      `json:"current-revision"`
+	CurrentEpoch    snap.Epoch         `json:"current-epoch"`
+	Target          EpochMigrationStep `json:"target"`
+	Reachable       bool               `json:"reachable"`
+	// Steps are the revisions to install in order, ending with the
+	// target one.
+	Steps []EpochMigrationStep `json:"steps,omitempty"`
+}
+
+// EpochMigration explains how the given snap can be refreshed to the
+// revision in the given channel, or in the tracked one if empty.
+func (client *Client) EpochMigration(snapName, channel string) (*EpochMigration, error) {
+	query := url.Values{}
+	if channel != "" {
+		query.Set("channel", channel)
+	}
+
+	var mig EpochMigration
+	if _, err := client.doSync("GET", "/v2/snaps/"+snapName+"/epoch-migration", query, nil, nil, &mig); err != nil {
+		return nil, err
+	}
+	return &mig, nil
+}
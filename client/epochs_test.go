@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (cs *clientSuite) TestClientEpochMigration(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"snap": "some-snap",
+			"channel": "2/stable",
+			"current-revision": "7",
+			"current-epoch": "1",
+			"target": {"revision": "11", "version": "2.0", "epoch": "3*", "channel": "2/stable"},
+			"reachable": true,
+			"steps": [
+				{"revision": "10", "version": "1.5", "epoch": "2*", "channel": "latest/beta"},
+				{"revision": "11", "version": "2.0", "epoch": "3*", "channel": "2/stable"}
+			]
+		}
+	}`
+	mig, err := cs.cli.EpochMigration("some-snap", "2/stable")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/some-snap/epoch-migration")
+	c.Check(cs.req.URL.Query().Get("channel"), check.Equals, "2/stable")
+	target := client.EpochMigrationStep{Revision: snap.R(11), Version: "2.0", Epoch: snap.E("3*"), Channel: "2/stable"}
+	c.Check(mig, check.DeepEquals, &client.EpochMigration{
+		Snap:            "some-snap",
+		Channel:         "2/stable",
+		CurrentRevision: snap.R(7),
+		CurrentEpoch:    snap.E("1"),
+		Target:          target,
+		Reachable:       true,
+		Steps: []client.EpochMigrationStep{
+			{Revision: snap.R(10), Version: "1.5", Epoch: snap.E("2*"), Channel: "latest/beta"},
+			target,
+		},
+	})
+}
+
+func (cs *clientSuite) TestClientEpochMigrationTrackedChannel(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": {"snap": "some-snap", "reachable": false}}`
+	mig, err := cs.cli.EpochMigration("some-snap", "")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+	c.Check(mig.Reachable, check.Equals, false)
+}
@@ -140,6 +140,10 @@ const (
 	// ErrorKindInsufficientDiskSpace: not enough disk space to perform the request.
 	ErrorKindInsufficientDiskSpace ErrorKind = "insufficient-disk-space"
 
+	// ErrorKindSnapHasDependents: the snap cannot be removed
+	// because other installed snaps need it.
+	ErrorKindSnapHasDependents ErrorKind = "snap-has-dependents"
+
 	// ErrorKindValidationSetNotFound: validation set cannot be found.
 	ErrorKindValidationSetNotFound ErrorKind = "validation-set-not-found"
 )
@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// InterfaceDenialNotice is the type of the notices recording the denials
+// of operations of snaps by their sandbox. The key of such notices is made
+// of the snap name and of the interface the denials were attributed to.
+const InterfaceDenialNotice = "interface-denial"
+
+// LaunchDenialNotice is the type of the notices recording the refusals of
+// the session launcher to open URLs or files on behalf of a snap, as
+// mandated by the launch policy. The key of such notices is the snap name.
+const LaunchDenialNotice = "launch-denial"
+
+// SnapNotice is the type of the notices emitted by snaps themselves with
+// snapctl notify. The key of such notices is made of the snap name and of
+// the key chosen by the snap, separated by a slash.
+const SnapNotice = "snap"
+
+// DiskHealthNotice is the type of the notices recording that the health of
+// a disk backing the system crossed one of the configured warning
+// thresholds. The key of such notices is the kernel name of the disk.
+const DiskHealthNotice = "disk-health"
+
+// A Notice records an occurrence of an event of interest. There is only
+// one Notice with the same type and key, recurring events update it.
+type Notice struct {
+	ID            string            `json:"id"`
+	Type          string            `json:"type"`
+	Key           string            `json:"key"`
+	FirstOccurred time.Time         `json:"first-occurred"`
+	LastOccurred  time.Time         `json:"last-occurred"`
+	LastRepeated  time.Time         `json:"last-repeated"`
+	Occurrences   int               `json:"occurrences"`
+	LastData      map[string]string `json:"last-data,omitempty"`
+	LastPayload   json.RawMessage   `json:"last-payload,omitempty"`
+}
+
+// NoticesOptions contains options for querying snapd for notices.
+type NoticesOptions struct {
+	// Types, if set, only returns notices of one of these types.
+	Types []string
+	// Keys, if set, only returns notices with one of these keys.
+	Keys []string
+	// After, if set, only returns notices repeated after this time.
+	After time.Time
+}
+
+// Notices returns the notices matching the options, sorted by the time
+// they were last repeated.
+func (client *Client) Notices(opts *NoticesOptions) ([]*Notice, error) {
+	q := make(url.Values)
+	if opts != nil {
+		if len(opts.Types) > 0 {
+			q.Set("types", strings.Join(opts.Types, ","))
+		}
+		if len(opts.Keys) > 0 {
+			q.Set("keys", strings.Join(opts.Keys, ","))
+		}
+		if !opts.After.IsZero() {
+			q.Set("after", opts.After.Format(time.RFC3339Nano))
+		}
+	}
+	var notices []*Notice
+	_, err := client.doSync("GET", "/v2/notices", q, nil, nil, &notices)
+	return notices, err
+}
+
+// AddNoticeOptions describes an occurrence of an event to record as a notice.
+type AddNoticeOptions struct {
+	// Type is the type of the notice, only LaunchDenialNotice is
+	// currently supported.
+	Type string `json:"type"`
+	// Key identifies the notice within the type.
+	Key string `json:"key"`
+	// Data is the data of the occurrence.
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// AddNotice records an occurrence of the event described by the options
+// and returns the identifier of the notice.
+func (client *Client) AddNotice(opts *AddNoticeOptions) (string, error) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(struct {
+		Action string `json:"action"`
+		*AddNoticeOptions
+	}{
+		Action:           "add",
+		AddNoticeOptions: opts,
+	}); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if _, err := client.doSync("POST", "/v2/notices", nil, nil, &body, &result); err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestNotices(c *check.C) {
+	t1 := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	t2 := time.Date(2023, 1, 10, 13, 0, 0, 0, time.UTC)
+	cs.rsp = `{
+		"result": [
+		    {
+			"id": "1",
+			"type": "interface-denial",
+			"key": "foo/camera",
+			"first-occurred": "2023-01-10T12:00:00Z",
+			"last-occurred": "2023-01-10T13:00:00Z",
+			"last-repeated": "2023-01-10T12:00:00Z",
+			"occurrences": 2,
+			"last-data": {"snap": "foo", "interface": "camera"},
+			"repeat-after": "1h0m0s",
+			"expire-after": "168h0m0s"
+		    }
+		],
+		"status": "OK",
+		"status-code": 200,
+		"type": "sync"
+	}`
+
+	notices, err := cs.cli.Notices(&client.NoticesOptions{
+		Types: []string{client.InterfaceDenialNotice},
+		Keys:  []string{"foo/camera", "foo/home"},
+		After: t1,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(notices, check.DeepEquals, []*client.Notice{{
+		ID:            "1",
+		Type:          "interface-denial",
+		Key:           "foo/camera",
+		FirstOccurred: t1,
+		LastOccurred:  t2,
+		LastRepeated:  t1,
+		Occurrences:   2,
+		LastData:      map[string]string{"snap": "foo", "interface": "camera"},
+	}})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/notices")
+	query := cs.req.URL.Query()
+	c.Check(query, check.HasLen, 3)
+	c.Check(query.Get("types"), check.Equals, "interface-denial")
+	c.Check(query.Get("keys"), check.Equals, "foo/camera,foo/home")
+	c.Check(query.Get("after"), check.Equals, "2023-01-10T12:00:00Z")
+}
+
+func (cs *clientSuite) TestNoticesSnapPayload(c *check.C) {
+	cs.rsp = `{
+		"result": [
+		    {
+			"id": "2",
+			"type": "snap",
+			"key": "foo/door.opened",
+			"first-occurred": "2023-01-10T12:00:00Z",
+			"last-occurred": "2023-01-10T12:00:00Z",
+			"last-repeated": "2023-01-10T12:00:00Z",
+			"occurrences": 1,
+			"last-payload": {"door": "front"}
+		    }
+		],
+		"status": "OK",
+		"status-code": 200,
+		"type": "sync"
+	}`
+
+	notices, err := cs.cli.Notices(&client.NoticesOptions{
+		Types: []string{client.SnapNotice},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0].Key, check.Equals, "foo/door.opened")
+	var payload map[string]string
+	c.Assert(json.Unmarshal(notices[0].LastPayload, &payload), check.IsNil)
+	c.Check(payload, check.DeepEquals, map[string]string{"door": "front"})
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"types": {"snap"}})
+}
+
+func (cs *clientSuite) TestNoticesNoOptions(c *check.C) {
+	cs.rsp = `{"result": [], "status": "OK", "status-code": 200, "type": "sync"}`
+
+	notices, err := cs.cli.Notices(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(notices, check.HasLen, 0)
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+}
+
+func (cs *clientSuite) TestAddNotice(c *check.C) {
+	cs.rsp = `{"result": {"id": "7"}, "status": "OK", "status-code": 200, "type": "sync"}`
+
+	id, err := cs.cli.AddNotice(&client.AddNoticeOptions{
+		Type: client.LaunchDenialNotice,
+		Key:  "foo",
+		Data: map[string]string{"operation": "open-url", "scheme": "zoommtg"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "7")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/notices")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "add",
+		"type":   "launch-denial",
+		"key":    "foo",
+		"data":   map[string]interface{}{"operation": "open-url", "scheme": "zoommtg"},
+	})
+}
+
+func (cs *clientSuite) TestAddNoticeError(c *check.C) {
+	cs.status = 404
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "snap not installed", "kind": "snap-not-found"}}`
+
+	_, err := cs.cli.AddNotice(&client.AddNoticeOptions{
+		Type: client.LaunchDenialNotice,
+		Key:  "foo",
+	})
+	c.Assert(err, check.ErrorMatches, "snap not installed")
+}
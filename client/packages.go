@@ -77,6 +77,9 @@ type Snap struct {
 	Screenshots []snap.ScreenshotInfo `json:"screenshots,omitempty"`
 	Media       snap.MediaInfos       `json:"media,omitempty"`
 
+	// Security describes the security fixes carried by a refresh candidate
+	Security *snap.SecurityInfo `json:"security,omitempty"`
+
 	// The flattended channel map with $track/$risk
 	Channels map[string]*snap.ChannelSnapInfo `json:"channels,omitempty"`
 
@@ -128,7 +131,10 @@ const (
 )
 
 type ResultInfo struct {
-	SuggestedCurrency string `json:"suggested-currency"`
+	// Sources lists where the results came from, e.g. "store" or
+	// "offline" when they were answered from the offline catalog.
+	Sources           []string `json:"sources"`
+	SuggestedCurrency string   `json:"suggested-currency"`
 }
 
 // FindOptions supports exactly one of the following options:
@@ -147,6 +153,11 @@ type FindOptions struct {
 	Scope   string
 
 	Refresh bool
+
+	// Offline controls the use of the local offline catalog: empty
+	// to only search the store, "fallback" to search the catalog if
+	// the store is unreachable, or "only" to never contact the store.
+	Offline string
 }
 
 var ErrNoSnapsInstalled = errors.New("no snaps installed")
@@ -226,6 +237,9 @@ func (client *Client) Find(opts *FindOptions) ([]*Snap, *ResultInfo, error) {
 	if opts.Scope != "" {
 		q.Set("scope", opts.Scope)
 	}
+	if opts.Offline != "" {
+		q.Set("offline", opts.Offline)
+	}
 
 	return client.snapsFromPath("/v2/find", q)
 }
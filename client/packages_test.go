@@ -97,6 +97,32 @@ func (cs *clientSuite) TestClientFindWithScopeSetsQuery(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientFindWithOfflineSetsQuery(c *check.C) {
+	_, _, _ = cs.cli.Find(&client.FindOptions{
+		Query:   "foo",
+		Offline: "fallback",
+	})
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/find")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"q":       []string{"foo"},
+		"offline": []string{"fallback"},
+	})
+}
+
+func (cs *clientSuite) TestClientFindSources(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [{"name": "hello-world"}],
+		"sources": ["offline"]
+	}`
+	snaps, ri, err := cs.cli.Find(&client.FindOptions{Query: "hello", Offline: "only"})
+	c.Assert(err, check.IsNil)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0].Name, check.Equals, "hello-world")
+	c.Check(ri.Sources, check.DeepEquals, []string{"offline"})
+}
+
 func (cs *clientSuite) TestClientSnapsInvalidSnapsJSON(c *check.C) {
 	cs.rsp = `{
 		"type": "sync",
@@ -52,6 +52,8 @@ type QuotaCPUValues struct {
 
 type QuotaCPUSetValues struct {
 	CPUs []int `json:"cpus,omitempty"`
+	// MemoryNodes are the NUMA nodes the group may allocate memory on.
+	MemoryNodes []int `json:"memory-nodes,omitempty"`
 }
 
 type QuotaJournalRate struct {
@@ -62,6 +64,10 @@ type QuotaJournalRate struct {
 type QuotaJournalValues struct {
 	Size quantity.Size `json:"size,omitempty"`
 	*QuotaJournalRate
+	// RetentionPeriod is how long journal entries are kept at most.
+	RetentionPeriod time.Duration `json:"retention-period,omitempty"`
+	// Storage is one of "auto", "volatile" and "persistent".
+	Storage string `json:"storage,omitempty"`
 }
 
 type QuotaValues struct {
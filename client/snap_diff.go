@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"net/url"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// DiffEntries lists the names of the things added, removed and modified
+// between two revisions of a snap.
+type DiffEntries struct {
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+}
+
+// Empty returns whether there are no differences.
+func (d *DiffEntries) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// MetadataChange is a snap.yaml field whose value differs between two
+// revisions of a snap.
+type MetadataChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// SnapDiff describes what changed between two revisions of a snap.
+type SnapDiff struct {
+	Snap string        `json:"snap"`
+	From snap.Revision `json:"from"`
+	To   snap.Revision `json:"to"`
+	// Files are the paths, relative to the top of the snap, of the
+	// files and symlinks that differ.
+	Files    DiffEntries      `json:"files"`
+	Metadata []MetadataChange `json:"metadata,omitempty"`
+	Apps     DiffEntries      `json:"apps"`
+	Plugs    DiffEntries      `json:"plugs"`
+	Slots    DiffEntries      `json:"slots"`
+	Hooks    DiffEntries      `json:"hooks"`
+}
+
+// SnapDiff compares two revisions of the given snap that are available
+// on the system.
+func (client *Client) SnapDiff(snapName string, from, to snap.Revision) (*SnapDiff, error) {
+	query := url.Values{}
+	query.Set("from", from.String())
+	query.Set("to", to.String())
+
+	var diff SnapDiff
+	if _, err := client.doSync("GET", "/v2/snaps/"+snapName+"/diff", query, nil, nil, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
@@ -0,0 +1,68 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (cs *clientSuite) TestClientSnapDiff(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"snap": "some-snap",
+			"from": "1",
+			"to": "2",
+			"files": {"added": ["bin/tool"], "modified": ["meta/snap.yaml"]},
+			"metadata": [{"field": "version", "from": "1.0", "to": "2.0"}],
+			"apps": {"added": ["tool"]},
+			"plugs": {"removed": ["home"]},
+			"slots": {},
+			"hooks": {"modified": ["configure"]}
+		}
+	}`
+	diff, err := cs.cli.SnapDiff("some-snap", snap.R(1), snap.R(2))
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/some-snap/diff")
+	c.Check(cs.req.URL.Query().Get("from"), check.Equals, "1")
+	c.Check(cs.req.URL.Query().Get("to"), check.Equals, "2")
+	c.Check(diff, check.DeepEquals, &client.SnapDiff{
+		Snap:     "some-snap",
+		From:     snap.R(1),
+		To:       snap.R(2),
+		Files:    client.DiffEntries{Added: []string{"bin/tool"}, Modified: []string{"meta/snap.yaml"}},
+		Metadata: []client.MetadataChange{{Field: "version", From: "1.0", To: "2.0"}},
+		Apps:     client.DiffEntries{Added: []string{"tool"}},
+		Plugs:    client.DiffEntries{Removed: []string{"home"}},
+		Hooks:    client.DiffEntries{Modified: []string{"configure"}},
+	})
+	c.Check(diff.Slots.Empty(), check.Equals, true)
+}
+
+func (cs *clientSuite) TestClientSnapDiffError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 400, "result": {"message": "revision 3 of snap \"some-snap\" is not installed", "kind": "snap-not-installed"}}`
+	_, err := cs.cli.SnapDiff("some-snap", snap.R(1), snap.R(3))
+	c.Assert(err, check.ErrorMatches, `revision 3 of snap "some-snap" is not installed`)
+}
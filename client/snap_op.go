@@ -55,12 +55,16 @@ type SnapOptions struct {
 	IgnoreRunning    bool            `json:"ignore-running,omitempty"`
 	Unaliased        bool            `json:"unaliased,omitempty"`
 	Purge            bool            `json:"purge,omitempty"`
+	KeepCache        bool            `json:"keep-cache,omitempty"`
+	Cascade          bool            `json:"cascade,omitempty"`
 	Amend            bool            `json:"amend,omitempty"`
 	Transaction      TransactionType `json:"transaction,omitempty"`
 	QuotaGroupName   string          `json:"quota-group,omitempty"`
 	ValidationSets   []string        `json:"validation-sets,omitempty"`
 	Time             string          `json:"time,omitempty"`
 	HoldLevel        string          `json:"hold-level,omitempty"`
+	EpochMigration   bool            `json:"epoch-migration,omitempty"`
+	Watch            bool            `json:"watch,omitempty"`
 
 	Users []string `json:"users,omitempty"`
 }
@@ -120,6 +124,10 @@ type actionData struct {
 	Name     string `json:"name,omitempty"`
 	SnapPath string `json:"snap-path,omitempty"`
 	*SnapOptions
+
+	// only used when sideloading bundles
+	Assertions  [][]byte        `json:"-"`
+	Connections []ConnectionRef `json:"-"`
 }
 
 type multiActionData struct {
@@ -129,6 +137,9 @@ type multiActionData struct {
 	Transaction    TransactionType `json:"transaction,omitempty"`
 	IgnoreRunning  bool            `json:"ignore-running,omitempty"`
 	Purge          bool            `json:"purge,omitempty"`
+	KeepCache      bool            `json:"keep-cache,omitempty"`
+	Cascade        bool            `json:"cascade,omitempty"`
+	DryRun         bool            `json:"dry-run,omitempty"`
 	ValidationSets []string        `json:"validation-sets,omitempty"`
 	Time           string          `json:"time,omitempty"`
 	HoldLevel      string          `json:"hold-level,omitempty"`
@@ -153,6 +164,36 @@ func (client *Client) RemoveMany(names []string, options *SnapOptions) (changeID
 	return client.doMultiSnapAction("remove", names, options)
 }
 
+// RemovalPlan returns the names of the snaps that removing the given snaps
+// with the given options would remove, in removal order, without removing
+// anything.
+func (client *Client) RemovalPlan(names []string, options *SnapOptions) ([]string, error) {
+	action := multiActionData{
+		Action: "remove",
+		Snaps:  names,
+		DryRun: true,
+	}
+	if options != nil {
+		action.Cascade = options.Cascade
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal multi-snap action: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var result struct {
+		SnapNames []string `json:"snap-names"`
+	}
+	if _, err := client.doSync("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data), &result); err != nil {
+		return nil, err
+	}
+	return result.SnapNames, nil
+}
+
 // Refresh refreshes the snap with the given name (switching it to track
 // the given channel if given).
 func (client *Client) Refresh(name string, options *SnapOptions) (changeID string, err error) {
@@ -255,6 +296,8 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, o
 		action.Transaction = options.Transaction
 		action.IgnoreRunning = options.IgnoreRunning
 		action.Purge = options.Purge
+		action.KeepCache = options.KeepCache
+		action.Cascade = options.Cascade
 		action.ValidationSets = options.ValidationSets
 		action.Time = options.Time
 		action.HoldLevel = options.HoldLevel
@@ -314,6 +357,47 @@ func (client *Client) InstallPathMany(paths []string, options *SnapOptions) (cha
 	return client.sendLocalSnaps(paths, files, action)
 }
 
+// ConnectionRef identifies a plug and the slot to connect it to. The slot
+// snap and name can be left empty, they are then resolved as with Connect.
+type ConnectionRef struct {
+	Plug PlugRef `json:"plug"`
+	Slot SlotRef `json:"slot"`
+}
+
+// InstallBundle sideloads the snaps with the given paths in a single
+// transaction, after adding the given assertions, and makes the given
+// connections once the snaps are installed, all as part of one change. It
+// returns the UUID of the background operation upon success.
+func (client *Client) InstallBundle(paths []string, assertions [][]byte, conns []ConnectionRef, options *SnapOptions) (changeID string, err error) {
+	opts := &SnapOptions{}
+	if options != nil {
+		*opts = *options
+	}
+	opts.Transaction = TransactionAllSnaps
+
+	action := actionData{
+		Action:      "install",
+		SnapOptions: opts,
+		Assertions:  assertions,
+		Connections: conns,
+	}
+
+	var files []*os.File
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			for _, openFile := range files {
+				openFile.Close()
+			}
+			return "", fmt.Errorf("cannot open %q: %w", path, err)
+		}
+
+		files = append(files, f)
+	}
+
+	return client.sendLocalSnaps(paths, files, action)
+}
+
 func (client *Client) sendLocalSnaps(paths []string, files []*os.File, action actionData) (string, error) {
 	pr, pw := io.Pipe()
 	mw := multipart.NewWriter(pw)
@@ -341,6 +425,7 @@ func (client *Client) Try(path string, options *SnapOptions) (changeID string, e
 	mw.WriteField("action", "try")
 	mw.WriteField("snap-path", path)
 	options.writeModeFields(mw)
+	writeFieldBool(mw, "watch", options.Watch)
 	mw.Close()
 
 	headers := map[string]string{
@@ -394,6 +479,25 @@ func sendSnapFiles(paths []string, files []*os.File, pw *io.PipeWriter, mw *mult
 		return
 	}
 
+	for _, a := range action.Assertions {
+		if err := mw.WriteField("assertion", string(a)); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+
+	if len(action.Connections) > 0 {
+		data, err := json.Marshal(action.Connections)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if err := mw.WriteField("connections", string(data)); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+	}
+
 	for i, file := range files {
 		path := paths[i]
 		fw, err := mw.CreateFormFile("snap", filepath.Base(path))
@@ -253,6 +253,51 @@ func (cs *clientSuite) TestClientMultiOpSnapIgnoreRunning(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientRemoveManyCascade(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.RemoveMany([]string{pkgName}, &client.SnapOptions{Cascade: true})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":  "remove",
+		"snaps":   []interface{}{pkgName},
+		"cascade": true,
+	})
+}
+
+func (cs *clientSuite) TestClientRemovalPlan(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"snap-names": ["consumer", "provider"]}
+	}`
+	plan, err := cs.cli.RemovalPlan([]string{"provider"}, &client.SnapOptions{Cascade: true})
+	c.Assert(err, check.IsNil)
+	c.Check(plan, check.DeepEquals, []string{"consumer", "provider"})
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var jsonBody map[string]interface{}
+	c.Assert(json.Unmarshal(body, &jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":  "remove",
+		"snaps":   []interface{}{"provider"},
+		"cascade": true,
+		"dry-run": true,
+	})
+}
+
 func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	// Note body is essentially the same as TestClientMultiOpSnap; keep in sync
 	cs.status = 202
@@ -444,6 +489,49 @@ func (cs *clientSuite) TestClientOpInstallPathManyTransactionally(c *check.C) {
 	c.Check(id, check.Equals, "66b3")
 }
 
+func (cs *clientSuite) TestClientOpInstallBundle(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+
+	var paths []string
+	names := []string{"foo.snap", "bar.snap"}
+	for _, name := range names {
+		path := filepath.Join(c.MkDir(), name)
+		paths = append(paths, path)
+		c.Assert(ioutil.WriteFile(path, []byte("snap-data"), 0644), check.IsNil)
+	}
+
+	assertions := [][]byte{[]byte("foo-assert"), []byte("bar-assert")}
+	conns := []client.ConnectionRef{{
+		Plug: client.PlugRef{Snap: "foo", Name: "data"},
+		Slot: client.SlotRef{Snap: "bar", Name: "data"},
+	}, {
+		Plug: client.PlugRef{Snap: "foo", Name: "network"},
+	}}
+	id, err := cs.cli.InstallBundle(paths, assertions, conns, &client.SnapOptions{Dangerous: true})
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+
+	for _, name := range names {
+		c.Assert(string(body), check.Matches, fmt.Sprintf(`(?s).*Content-Disposition: form-data; name="snap"; filename="%s"\r\nContent-Type: application/octet-stream\r\n\r\nsnap-data\r\n.*`, name))
+	}
+	c.Assert(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="action"\r\n\r\ninstall\r\n.*`)
+	c.Assert(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="transaction"\r\n\r\nall-snaps\r\n.*`)
+	c.Assert(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="dangerous"\r\n\r\ntrue\r\n.*`)
+	c.Assert(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="assertion"\r\n\r\nfoo-assert\r\n.*Content-Disposition: form-data; name="assertion"\r\n\r\nbar-assert\r\n.*`)
+	c.Assert(string(body), check.Matches, `(?s).*Content-Disposition: form-data; name="connections"\r\n\r\n\[{"plug":{"snap":"foo","plug":"data"},"slot":{"snap":"bar","slot":"data"}},{"plug":{"snap":"foo","plug":"network"},"slot":{"snap":"","slot":""}}\]\r\n.*`)
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(id, check.Equals, "66b3")
+}
+
 func (cs *clientSuite) TestClientOpInstallPathManyWithOptions(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
@@ -689,6 +777,30 @@ func (cs *clientSuite) TestClientOpTryModeDangerous(c *check.C) {
 	c.Assert(err, check.Equals, client.ErrDangerousNotApplicable)
 }
 
+func (cs *clientSuite) TestClientOpTryWatch(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"change": "66b3",
+		"status-code": 202,
+		"type": "async"
+	}`
+	snapdir := filepath.Join(c.MkDir(), "/some/path")
+
+	id, err := cs.cli.Try(snapdir, &client.SnapOptions{DevMode: true, Watch: true})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "66b3")
+
+	_, params, err := mime.ParseMediaType(cs.req.Header.Get("Content-Type"))
+	c.Assert(err, check.IsNil)
+	mr := multipart.NewReader(cs.req.Body, params["boundary"])
+	c.Check(formToMap(c, mr), check.DeepEquals, map[string]string{
+		"action":    "try",
+		"snap-path": snapdir,
+		"devmode":   "true",
+		"watch":     "true",
+	})
+}
+
 func (cs *clientSuite) TestSnapOptionsSerialises(c *check.C) {
 	tests := map[string]client.SnapOptions{
 		"{}":                         {},
@@ -703,7 +815,10 @@ func (cs *clientSuite) TestSnapOptionsSerialises(c *check.C) {
 		`{"ignore-validation":true}`: {IgnoreValidation: true},
 		`{"unaliased":true}`:         {Unaliased: true},
 		`{"purge":true}`:             {Purge: true},
+		`{"keep-cache":true}`:        {KeepCache: true},
+		`{"cascade":true}`:           {Cascade: true},
 		`{"amend":true}`:             {Amend: true},
+		`{"watch":true}`:             {Watch: true},
 	}
 	for expected, opts := range tests {
 		buf, err := json.Marshal(&opts)
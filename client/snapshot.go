@@ -39,6 +39,19 @@ import (
 // SnapshotExportMediaType is the media type used to identify snapshot exports in the API.
 const SnapshotExportMediaType = "application/x.snapd.snapshot"
 
+// SnapshotPassphraseHeader is the header carrying the passphrase of
+// snapshot exports encrypted with one.
+const SnapshotPassphraseHeader = "X-Snapd-Snapshot-Passphrase"
+
+const (
+	// SnapshotEncryptionDevice encrypts a snapshot export with a key bound
+	// to the device, it can only be imported on the same device.
+	SnapshotEncryptionDevice = "device"
+	// SnapshotEncryptionPassphrase encrypts a snapshot export with a key
+	// derived from a passphrase, it can be imported on any device.
+	SnapshotEncryptionPassphrase = "passphrase"
+)
+
 var (
 	ErrSnapshotSetNotFound   = errors.New("no snapshot set with the given ID")
 	ErrSnapshotSnapsNotFound = errors.New("no snapshot for the requested snaps found in the set with the given ID")
@@ -226,11 +239,31 @@ func (client *Client) snapshotAction(action *snapshotAction) (changeID string, e
 	return client.doAsync("POST", "/v2/snapshots", nil, headers, bytes.NewBuffer(data))
 }
 
+// SnapshotExportOptions holds the options of a snapshot export.
+type SnapshotExportOptions struct {
+	// Encryption is one of SnapshotEncryptionDevice or
+	// SnapshotEncryptionPassphrase, the export is not encrypted if empty.
+	Encryption string
+	// Passphrase is used with SnapshotEncryptionPassphrase.
+	Passphrase string
+}
+
 // SnapshotExport streams the requested snapshot set.
 //
 // The return value includes the length of the returned stream.
-func (client *Client) SnapshotExport(setID uint64) (stream io.ReadCloser, contentLength int64, err error) {
-	rsp, err := client.raw(context.Background(), "GET", fmt.Sprintf("/v2/snapshots/%v/export", setID), nil, nil, nil)
+func (client *Client) SnapshotExport(setID uint64, opts *SnapshotExportOptions) (stream io.ReadCloser, contentLength int64, err error) {
+	if opts == nil {
+		opts = &SnapshotExportOptions{}
+	}
+	var query url.Values
+	var headers map[string]string
+	if opts.Encryption != "" {
+		query = url.Values{"encryption": []string{opts.Encryption}}
+	}
+	if opts.Passphrase != "" {
+		headers = map[string]string{SnapshotPassphraseHeader: opts.Passphrase}
+	}
+	rsp, err := client.raw(context.Background(), "GET", fmt.Sprintf("/v2/snapshots/%v/export", setID), query, headers, nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -258,12 +291,21 @@ type SnapshotImportSet struct {
 	Snaps []string `json:"snaps"`
 }
 
+// SnapshotImportOptions holds the options of a snapshot import.
+type SnapshotImportOptions struct {
+	// Passphrase is needed to import exports encrypted with a passphrase.
+	Passphrase string
+}
+
 // SnapshotImport imports an exported snapshot set.
-func (client *Client) SnapshotImport(exportStream io.Reader, size int64) (SnapshotImportSet, error) {
+func (client *Client) SnapshotImport(exportStream io.Reader, size int64, opts *SnapshotImportOptions) (SnapshotImportSet, error) {
 	headers := map[string]string{
 		"Content-Type":   SnapshotExportMediaType,
 		"Content-Length": strconv.FormatInt(size, 10),
 	}
+	if opts != nil && opts.Passphrase != "" {
+		headers[SnapshotPassphraseHeader] = opts.Passphrase
+	}
 
 	var importSet SnapshotImportSet
 	if _, err := client.doSync("POST", "/v2/snapshots", nil, headers, exportStream, &importSet); err != nil {
@@ -164,7 +164,7 @@ func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 		cs.rsp = t.content
 		cs.status = t.status
 
-		r, size, err := cs.cli.SnapshotExport(42)
+		r, size, err := cs.cli.SnapshotExport(42, nil)
 		if t.status == 200 {
 			c.Assert(err, check.IsNil, comm)
 			c.Assert(cs.countingCloser.closeCalled, check.Equals, 0)
@@ -202,7 +202,7 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 
 		fakeSnapshotData := "fake"
 		r := strings.NewReader(fakeSnapshotData)
-		importSet, err := cs.cli.SnapshotImport(r, int64(len(fakeSnapshotData)))
+		importSet, err := cs.cli.SnapshotImport(r, int64(len(fakeSnapshotData)), nil)
 		if t.error != "" {
 			c.Assert(err, check.NotNil, comm)
 			c.Check(err.Error(), check.Equals, t.error, comm)
@@ -219,6 +219,36 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientExportSnapshotEncrypted(c *check.C) {
+	cs.contentLength = 4
+	cs.header = http.Header{"Content-Type": []string{client.SnapshotExportMediaType}}
+	cs.rsp = "data"
+	cs.status = 200
+
+	_, _, err := cs.cli.SnapshotExport(42, &client.SnapshotExportOptions{Encryption: client.SnapshotEncryptionDevice})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots/42/export")
+	c.Check(cs.req.URL.Query().Get("encryption"), check.Equals, "device")
+	c.Check(cs.req.Header.Get(client.SnapshotPassphraseHeader), check.Equals, "")
+
+	_, _, err = cs.cli.SnapshotExport(42, &client.SnapshotExportOptions{
+		Encryption: client.SnapshotEncryptionPassphrase,
+		Passphrase: "sekrit",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query().Get("encryption"), check.Equals, "passphrase")
+	c.Check(cs.req.Header.Get(client.SnapshotPassphraseHeader), check.Equals, "sekrit")
+}
+
+func (cs *clientSuite) TestClientSnapshotImportPassphrase(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"set-id": 42, "snaps": ["foo"]}}`
+	cs.status = 200
+
+	_, err := cs.cli.SnapshotImport(strings.NewReader("fake"), 4, &client.SnapshotImportOptions{Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Header.Get(client.SnapshotPassphraseHeader), check.Equals, "sekrit")
+}
+
 func (cs *clientSuite) TestClientSnapshotContentHash(c *check.C) {
 	now := time.Now()
 	revno := snap.R(1)
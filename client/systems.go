@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"golang.org/x/xerrors"
 
@@ -140,6 +141,49 @@ func (client *Client) RebootToSystem(systemLabel, mode string) error {
 	return nil
 }
 
+// SystemActionSchedule describes when a reboot, shutdown or system action is
+// carried out. Only one of Time or Window can be set.
+type SystemActionSchedule struct {
+	// Time is the time at which the action is carried out.
+	Time *time.Time `json:"time,omitempty"`
+	// Window is a schedule in the format of refresh.timer, the action is
+	// carried out at the beginning of the next matching window.
+	Window string `json:"window,omitempty"`
+}
+
+// ScheduleSystemAction schedules a "reboot", "shutdown" or "do" action for
+// the system with the given label at a later time. The returned change can be
+// aborted to cancel the action until it is carried out.
+func (client *Client) ScheduleSystemAction(systemLabel, action string, sysAction *SystemAction, sched *SystemActionSchedule) (changeID string, err error) {
+	if sched == nil {
+		return "", fmt.Errorf("cannot schedule an action without a schedule")
+	}
+	// verification is done by the backend
+
+	if sysAction == nil {
+		sysAction = &SystemAction{}
+	}
+	req := struct {
+		Action string `json:"action"`
+		*SystemAction
+		*SystemActionSchedule
+	}{
+		Action:               action,
+		SystemAction:         sysAction,
+		SystemActionSchedule: sched,
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(&req); err != nil {
+		return "", err
+	}
+	chgID, err := client.doAsync("POST", "/v2/systems/"+systemLabel, nil, nil, &body)
+	if err != nil {
+		return "", xerrors.Errorf("cannot schedule system %s: %v", action, err)
+	}
+	return chgID, nil
+}
+
 type StorageEncryptionSupport string
 
 const (
@@ -169,6 +213,30 @@ type StorageEncryption struct {
 	// encryption is required or not this should be presented to
 	// the user as either an error or as information.
 	UnavailableReason string `json:"unavailable-reason,omitempty"`
+
+	// VolumesAuthModes are the ways the encrypted volumes can be
+	// set up to be unlocked, when encryption is available.
+	VolumesAuthModes []VolumesAuthMode `json:"volumes-auth-modes,omitempty"`
+}
+
+type VolumesAuthMode string
+
+const (
+	// Only the key sealed to the TPM or handled by the kernel
+	// fde-setup hook unlocks the volumes.
+	VolumesAuthModeTPM VolumesAuthMode = "tpm"
+	// A passphrase chosen on install unlocks the volumes instead of
+	// a key sealed to the TPM.
+	VolumesAuthModePassphrase VolumesAuthMode = "passphrase"
+)
+
+// VolumesAuthOptions describes how the encrypted volumes of the installed
+// system can be unlocked.
+type VolumesAuthOptions struct {
+	// Mode is one of "tpm", the default, or "passphrase".
+	Mode VolumesAuthMode `json:"mode"`
+	// Passphrase is the passphrase for the "passphrase" mode.
+	Passphrase string `json:"passphrase,omitempty"`
 }
 
 type SystemDetails struct {
@@ -218,6 +286,15 @@ type InstallSystemOptions struct {
 	// OnVolumes is the volume description of the volumes that the
 	// given step should operate on.
 	OnVolumes map[string]*gadget.Volume `json:"on-volumes,omitempty"`
+
+	// EncryptionType is the type of encryption to set up with the
+	// "setup-storage-encryption" step. It must be the one reported
+	// as available in the system details, which is used if unset.
+	EncryptionType string `json:"encryption-type,omitempty"`
+
+	// VolumesAuth is how the volumes encrypted by the
+	// "setup-storage-encryption" step can be unlocked.
+	VolumesAuth *VolumesAuthOptions `json:"volumes-auth,omitempty"`
 }
 
 // InstallSystem will perform the given install step for the given volumes
@@ -22,6 +22,7 @@ package client_test
 import (
 	"encoding/json"
 	"io/ioutil"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -257,7 +258,8 @@ func (cs *clientSuite) TestSystemDetailsHappy(c *check.C) {
                 "storage-encryption": {
                     "support":"available",
                     "storage-safety":"prefer-encrypted",
-                    "encryption-type":"cryptsetup"
+                    "encryption-type":"cryptsetup",
+                    "volumes-auth-modes": ["tpm", "passphrase"]
                 },
                 "volumes": {
                     "pc": {
@@ -291,9 +293,10 @@ func (cs *clientSuite) TestSystemDetailsHappy(c *check.C) {
 			{Title: "reinstall", Mode: "install"},
 		},
 		StorageEncryption: &client.StorageEncryption{
-			Support:       "available",
-			StorageSafety: "prefer-encrypted",
-			Type:          "cryptsetup",
+			Support:          "available",
+			StorageSafety:    "prefer-encrypted",
+			Type:             "cryptsetup",
+			VolumesAuthModes: []client.VolumesAuthMode{client.VolumesAuthModeTPM, client.VolumesAuthModePassphrase},
 		},
 		Volumes: map[string]*gadget.Volume{
 			"pc": {
@@ -411,3 +414,94 @@ func (cs *clientSuite) TestRequestSystemInstallHappy(c *check.C) {
 		},
 	})
 }
+
+func (cs *clientSuite) TestRequestSystemInstallSetupStorageEncryptionWithOptions(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+	opts := &client.InstallSystemOptions{
+		Step: client.InstallStepSetupStorageEncryption,
+		OnVolumes: map[string]*gadget.Volume{
+			"pc": {Bootloader: "grub"},
+		},
+		EncryptionType: "cryptsetup",
+		VolumesAuth: &client.VolumesAuthOptions{
+			Mode:       client.VolumesAuthModePassphrase,
+			Passphrase: "secret",
+		},
+	}
+	chgID, err := cs.cli.InstallSystem("1234", opts)
+	c.Assert(err, check.IsNil)
+	c.Assert(chgID, check.Equals, "42")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Check(req["step"], check.Equals, "setup-storage-encryption")
+	c.Check(req["encryption-type"], check.Equals, "cryptsetup")
+	c.Check(req["volumes-auth"], check.DeepEquals, map[string]interface{}{
+		"mode":       "passphrase",
+		"passphrase": "secret",
+	})
+}
+
+func (cs *clientSuite) TestScheduleSystemActionHappy(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	chgID, err := cs.cli.ScheduleSystemAction("1234", "reboot", &client.SystemAction{Mode: "recover"}, &client.SystemActionSchedule{Time: &at})
+	c.Assert(err, check.IsNil)
+	c.Check(chgID, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems/1234")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]interface{}
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Check(req, check.DeepEquals, map[string]interface{}{
+		"action": "reboot",
+		"mode":   "recover",
+		"time":   "2030-01-02T03:04:05Z",
+	})
+
+	chgID, err = cs.cli.ScheduleSystemAction("", "shutdown", nil, &client.SystemActionSchedule{Window: "sat,02:00-04:00"})
+	c.Assert(err, check.IsNil)
+	c.Check(chgID, check.Equals, "42")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems")
+
+	body, err = ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	req = nil
+	err = json.Unmarshal(body, &req)
+	c.Assert(err, check.IsNil)
+	c.Check(req, check.DeepEquals, map[string]interface{}{
+		"action": "shutdown",
+		"window": "sat,02:00-04:00",
+	})
+}
+
+func (cs *clientSuite) TestScheduleSystemActionError(c *check.C) {
+	cs.rsp = `{
+	    "type": "error",
+	    "status-code": 400,
+	    "result": {"message": "failed"}
+	}`
+	_, err := cs.cli.ScheduleSystemAction("1234", "reboot", nil, &client.SystemActionSchedule{Window: "mon"})
+	c.Assert(err, check.ErrorMatches, `cannot schedule system reboot: failed`)
+
+	cs.req = nil
+	_, err = cs.cli.ScheduleSystemAction("1234", "reboot", nil, nil)
+	c.Assert(err, check.ErrorMatches, `cannot schedule an action without a schedule`)
+	c.Check(cs.req, check.IsNil)
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// TryWatch describes a snap tried from a directory that is watched for
+// changes.
+type TryWatch struct {
+	Snap     string `json:"snap"`
+	Path     string `json:"path"`
+	DevMode  bool   `json:"devmode,omitempty"`
+	JailMode bool   `json:"jailmode,omitempty"`
+	Classic  bool   `json:"classic,omitempty"`
+	// Since is when the directory started being watched.
+	Since time.Time `json:"since"`
+	// LastRetry is when the snap was last tried again because the
+	// contents of the directory changed.
+	LastRetry  *time.Time `json:"last-retry,omitempty"`
+	LastChange string     `json:"last-change,omitempty"`
+}
+
+// TryWatches lists the snaps tried from watched directories.
+func (client *Client) TryWatches() ([]*TryWatch, error) {
+	var watches []*TryWatch
+	if _, err := client.doSync("GET", "/v2/try-watches", nil, nil, nil, &watches); err != nil {
+		return nil, err
+	}
+	return watches, nil
+}
+
+// StopTryWatch stops watching the directory the given snap is tried from.
+// The snap itself is left installed.
+func (client *Client) StopTryWatch(snapName string) error {
+	data, err := json.Marshal(map[string]string{
+		"action": "stop",
+		"snap":   snapName,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.doSync("POST", "/v2/try-watches", nil, nil, bytes.NewReader(data), nil)
+	return err
+}
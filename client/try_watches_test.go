@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientTryWatches(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{
+			"snap": "foo",
+			"path": "/home/user/foo/prime",
+			"devmode": true,
+			"since": "2026-10-17T10:00:00Z",
+			"last-retry": "2026-10-17T10:05:00Z",
+			"last-change": "42"
+		}]
+	}`
+	watches, err := cs.cli.TryWatches()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/try-watches")
+	lastRetry := time.Date(2026, 10, 17, 10, 5, 0, 0, time.UTC)
+	c.Check(watches, check.DeepEquals, []*client.TryWatch{{
+		Snap:       "foo",
+		Path:       "/home/user/foo/prime",
+		DevMode:    true,
+		Since:      time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+		LastRetry:  &lastRetry,
+		LastChange: "42",
+	}})
+}
+
+func (cs *clientSuite) TestClientStopTryWatch(c *check.C) {
+	cs.rsp = `{"type": "sync", "status-code": 200, "result": null}`
+	err := cs.cli.StopTryWatch("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/try-watches")
+	var body map[string]string
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]string{
+		"action": "stop",
+		"snap":   "foo",
+	})
+}
+
+func (cs *clientSuite) TestClientStopTryWatchError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "snap \"foo\" is not tried from a watched directory"}}`
+	err := cs.cli.StopTryWatch("foo")
+	c.Assert(err, check.ErrorMatches, `snap "foo" is not tried from a watched directory`)
+}
@@ -87,6 +87,7 @@ var (
 	secbootMeasureSnapModelWhenPossible          func(findModel func() (*asserts.Model, error)) error
 	secbootUnlockVolumeUsingSealedKeyIfEncrypted func(disk disks.Disk, name string, encryptionKeyFile string, opts *secboot.UnlockVolumeUsingSealedKeyOptions) (secboot.UnlockResult, error)
 	secbootUnlockEncryptedVolumeUsingKey         func(disk disks.Disk, name string, key []byte) (secboot.UnlockResult, error)
+	secbootDiskUnlockKeyFromKernel               func(devicePath string) ([]byte, error)
 
 	secbootLockSealedKeys func() error
 
@@ -194,6 +195,13 @@ func generateInitramfsMounts() (err error) {
 			// problems if we can't write to /run
 			return err
 		}
+		if isRunMode {
+			// the factory boot flag may be set only for a number
+			// of boots after installation, account for this one
+			if err := boot.InitramfsConsumeFactoryBoot(rootfsDir); err != nil {
+				logger.Noticef("cannot update the number of factory boots: %v", err)
+			}
+		}
 	}
 
 	return nil
@@ -308,6 +316,26 @@ func copyUbuntuDataAuth(src, dst string) error {
 	return nil
 }
 
+// provideStateEncryptionKey derives the key the sensitive parts of the snapd
+// state are encrypted with from the unlock key of ubuntu-data, so that it is
+// bound to the TPM or the fde-setup hook, and leaves it in memory for snapd.
+// snapd refuses to run with encrypted state but without the key, so failing
+// here is not fatal for the boot.
+func provideStateEncryptionKey(unlockRes secboot.UnlockResult) {
+	if unlockRes.UnlockMethod != secboot.UnlockedWithSealedKey {
+		// the recovery key and the passphrase are not bound to
+		// anything
+		return
+	}
+	secret, err := secbootDiskUnlockKeyFromKernel(unlockRes.PartDevice)
+	if err == nil {
+		err = state.ProvideEncryptionKey(secret)
+	}
+	if err != nil {
+		logger.Noticef("cannot provide the state encryption key: %v", err)
+	}
+}
+
 // drop a marker file that disables console-conf
 func disableConsoleConf(dst string) error {
 	consoleConfCompleteFile := filepath.Join(dst, "system-data/var/lib/console-conf/complete")
@@ -375,9 +403,10 @@ const (
 	partitionUnlocked     = "unlocked"
 	partitionErrUnlocking = "error-unlocking"
 	// keys used to unlock for UnlockKey
-	keyRun      = "run"
-	keyFallback = "fallback"
-	keyRecovery = "recovery"
+	keyRun        = "run"
+	keyFallback   = "fallback"
+	keyRecovery   = "recovery"
+	keyPassphrase = "passphrase"
 )
 
 // partitionState is the state of a partition after recover mode has completed
@@ -398,7 +427,7 @@ type partitionState struct {
 	// UnlockState was whether the partition was unlocked successfully or not.
 	UnlockState string `json:"unlock-state,omitempty"`
 	// UnlockKey was what key the partition was unlocked with, either "run",
-	// "fallback" or "recovery".
+	// "fallback", "recovery" or "passphrase".
 	UnlockKey string `json:"unlock-key,omitempty"`
 
 	// unexported internal fields for tracking the device, these are used during
@@ -483,6 +512,10 @@ type recoverModeStateMachine struct {
 	// when true, the fallback unlock paths will not be tried
 	noFallback bool
 
+	// when true, the encrypted partitions are unlocked with the
+	// passphrase chosen on install as no keys were sealed
+	passphraseAuth bool
+
 	// TODO:UC20: for clarity turn this into into tristate:
 	// unknown|encrypted|unencrypted
 	isEncryptedDev bool
@@ -507,8 +540,10 @@ func (m *recoverModeStateMachine) degraded() bool {
 			return true
 		}
 
-		// we also should have all the unlock keys as run keys
-		if r.UbuntuData.UnlockKey != keyRun {
+		// we also should have all the unlock keys as run keys, or
+		// ubuntu-data unlocked with the passphrase used in place of
+		// the run key
+		if r.UbuntuData.UnlockKey != keyRun && r.UbuntuData.UnlockKey != keyPassphrase {
 			return true
 		}
 
@@ -639,6 +674,9 @@ func (m *recoverModeStateMachine) setUnlockStateWithRunKey(partName string, unlo
 		// unlocked successfully
 		part.UnlockState = partitionUnlocked
 		part.UnlockKey = keyRun
+		if unlockRes.UnlockMethod == secboot.UnlockedWithPassphrase {
+			part.UnlockKey = keyPassphrase
+		}
 	}
 
 	return nil
@@ -729,6 +767,8 @@ func (m *recoverModeStateMachine) setUnlockStateWithFallbackKey(partName string,
 			part.UnlockKey = keyFallback
 		case secboot.UnlockedWithRecoveryKey:
 			part.UnlockKey = keyRecovery
+		case secboot.UnlockedWithPassphrase:
+			part.UnlockKey = keyPassphrase
 
 			// TODO: should we fail with internal error for default case here?
 		}
@@ -745,6 +785,8 @@ func newRecoverModeStateMachine(model *asserts.Model, disk disks.Disk, allowFall
 			ErrorLog: []string{},
 		},
 		noFallback: !allowFallback,
+		// ubuntu-seed is always there, unlike ubuntu-boot
+		passphraseAuth: device.HasPassphraseAuthMarkerUnder(boot.InitramfsSeedEncryptionKeyDir),
 	}
 	// first step is to mount ubuntu-boot to check for run mode keys to unlock
 	// ubuntu-data
@@ -879,6 +921,7 @@ func (m *recoverModeStateMachine) unlockDataRunKey() (stateFunc, error) {
 		// recovery key after we first try the fallback object
 		AllowRecoveryKey: false,
 		WhichModel:       m.whichModel,
+		UsePassphrase:    m.passphraseAuth,
 	}
 	unlockRes, unlockErr := secbootUnlockVolumeUsingSealedKeyIfEncrypted(m.disk, "ubuntu-data", runModeKey, unlockOpts)
 	if err := m.setUnlockStateWithRunKey("ubuntu-data", unlockRes, unlockErr); err != nil {
@@ -903,6 +946,7 @@ func (m *recoverModeStateMachine) unlockDataRunKey() (stateFunc, error) {
 
 	// otherwise successfully unlocked it (or just found it if it was unencrypted)
 	// so just mount it
+	provideStateEncryptionKey(unlockRes)
 	return m.mountData, nil
 }
 
@@ -919,6 +963,9 @@ func (m *recoverModeStateMachine) unlockDataFallbackKey() (stateFunc, error) {
 		// to unlock data
 		AllowRecoveryKey: true,
 		WhichModel:       m.whichModel,
+		// without a fallback object, the passphrase is asked for again
+		// before the recovery key
+		UsePassphrase: m.passphraseAuth,
 	}
 	// TODO: this prompts for a recovery key
 	// TODO: we should somehow customize the prompt to mention what key we need
@@ -936,6 +983,7 @@ func (m *recoverModeStateMachine) unlockDataFallbackKey() (stateFunc, error) {
 	}
 
 	// unlocked it, now go mount it
+	provideStateEncryptionKey(unlockRes)
 	return m.mountData, nil
 }
 
@@ -1053,6 +1101,7 @@ func (m *recoverModeStateMachine) unlockEncryptedSaveFallbackKey() (stateFunc, e
 		// to unlock save
 		AllowRecoveryKey: true,
 		WhichModel:       m.whichModel,
+		UsePassphrase:    m.passphraseAuth,
 	}
 	saveFallbackKey := device.FallbackSaveSealedKeyUnder(boot.InitramfsSeedEncryptionKeyDir)
 	// TODO: this prompts again for a recover key, but really this is the
@@ -1177,6 +1226,14 @@ func generateMountsModeRecover(mst *initramfsMountsState) error {
 		}
 	}
 
+	// 3.2 expose why run mode could not be booted if we got here because
+	// checking or repairing its filesystems failed
+	if machine.degradedState.partition("ubuntu-boot").MountState == partitionMounted {
+		if err := exposeFsckFailure(); err != nil {
+			return err
+		}
+	}
+
 	// 4. final step: copy the auth data and network config from
 	//    the real ubuntu-data dir to the ephemeral ubuntu-data
 	//    dir, write the modeenv to the tmpfs data, and disable
@@ -1459,7 +1516,7 @@ func generateMountsCommonInstallRecover(mst *initramfsMountsState) (model *asser
 	return model, systemSnaps, nil
 }
 
-func maybeMountSave(disk disks.Disk, rootdir string, encrypted bool, mountOpts *systemdMountOptions) (haveSave bool, err error) {
+func maybeMountSave(disk disks.Disk, rootdir string, encrypted bool, mountOpts *systemdMountOptions, fsck *fsckPolicy) (haveSave bool, err error) {
 	var saveDevice string
 	if encrypted {
 		saveKey := device.SaveKeyUnder(dirs.SnapFDEDirUnder(rootdir))
@@ -1491,6 +1548,15 @@ func maybeMountSave(disk disks.Disk, rootdir string, encrypted bool, mountOpts *
 		}
 		saveDevice = filepath.Join("/dev/disk/by-partuuid", partUUID)
 	}
+	if err := fsck.checkFilesystem("ubuntu-save", saveDevice); err != nil {
+		return true, err
+	}
+	if !fsck.systemdFsck() {
+		// already checked above
+		saveOpts := *mountOpts
+		saveOpts.NeedsFsck = false
+		mountOpts = &saveOpts
+	}
 	if err := doSystemdMount(saveDevice, boot.InitramfsUbuntuSaveDir, mountOpts); err != nil {
 		return true, err
 	}
@@ -1643,6 +1709,12 @@ func generateMountsModeRun(mst *initramfsMountsState) error {
 		return err
 	}
 
+	// 2.2 check whether the writable data is ephemeral for this boot
+	ephemeralData, err := boot.InitramfsRunModeEphemeralData()
+	if err != nil {
+		return err
+	}
+
 	// at this point on a system with TPM-based encryption
 	// data can be open only if the measured model matches the actual
 	// run model.
@@ -1655,16 +1727,30 @@ func generateMountsModeRun(mst *initramfsMountsState) error {
 	opts := &secboot.UnlockVolumeUsingSealedKeyOptions{
 		AllowRecoveryKey: true,
 		WhichModel:       mst.UnverifiedBootModel,
+		// no keys were sealed if the passphrase was chosen on install
+		UsePassphrase: device.HasPassphraseAuthMarkerUnder(boot.InitramfsBootEncryptionKeyDir),
 	}
 	unlockRes, err := secbootUnlockVolumeUsingSealedKeyIfEncrypted(disk, "ubuntu-data", runModeKey, opts)
 	if err != nil {
 		return err
 	}
+	provideStateEncryptionKey(unlockRes)
+
+	// 3.1.1 check and repair the filesystems of ubuntu-data (and below
+	// ubuntu-save) as configured on the kernel command line, if that fails
+	// fall back to recover mode rather than to the initramfs shell
+	fsck, err := fsckPolicyFromKernelCommandLine()
+	if err != nil {
+		return err
+	}
+	if err := fsck.checkFilesystem("ubuntu-data", unlockRes.FsDevice); err != nil {
+		return fallbackToRecoverModeIfFsckFailure(err)
+	}
 
 	// TODO: do we actually need fsck if we are mounting a mapper device?
 	// probably not?
 	dataMountOpts := &systemdMountOptions{
-		NeedsFsck: true,
+		NeedsFsck: fsck.systemdFsck(),
 	}
 	if !isClassic {
 		// fsck and mount with nosuid to prevent snaps from being able to bypass
@@ -1674,9 +1760,26 @@ func generateMountsModeRun(mst *initramfsMountsState) error {
 		// Note that on classic the default is to allow mount propagation
 		dataMountOpts.Private = true
 	}
-	if err := doSystemdMount(unlockRes.FsDevice, boot.InitramfsDataDir, dataMountOpts); err != nil {
+	dataMountDir := boot.InitramfsDataDir
+	if ephemeralData {
+		// the actual ubuntu-data is only the read-only lower layer of
+		// the overlay mounted as data, so nothing gets persisted
+		logger.Noticef("writable data is ephemeral for this boot")
+		dataMountDir = boot.InitramfsHostUbuntuDataDir
+		dataMountOpts.ReadOnly = true
+	}
+	if err := doSystemdMount(unlockRes.FsDevice, dataMountDir, dataMountOpts); err != nil {
 		return err
 	}
+	if ephemeralData {
+		if err := mountEphemeralDataOverlay(isClassic); err != nil {
+			return err
+		}
+		// let snapd know, it must not change the boot state then
+		if err := boot.InitramfsRunModeMarkEphemeralData(); err != nil {
+			return err
+		}
+	}
 	isEncryptedDev := unlockRes.IsEncrypted
 
 	// at this point data was opened so we can consider the model okay
@@ -1684,9 +1787,9 @@ func generateMountsModeRun(mst *initramfsMountsState) error {
 	rootfsDir := boot.InitramfsWritableDir(model, isRunMode)
 
 	// 3.2. mount ubuntu-save (if present)
-	haveSave, err := maybeMountSave(disk, rootfsDir, isEncryptedDev, systemdOpts)
+	haveSave, err := maybeMountSave(disk, rootfsDir, isEncryptedDev, systemdOpts, fsck)
 	if err != nil {
-		return err
+		return fallbackToRecoverModeIfFsckFailure(err)
 	}
 
 	// 4.1 verify that ubuntu-data comes from where we expect it to
@@ -1697,7 +1800,7 @@ func generateMountsModeRun(mst *initramfsMountsState) error {
 		diskOpts.IsDecryptedDevice = true
 	}
 
-	matches, err := disk.MountPointIsFromDisk(boot.InitramfsDataDir, diskOpts)
+	matches, err := disk.MountPointIsFromDisk(dataMountDir, diskOpts)
 	if err != nil {
 		return err
 	}
@@ -1810,6 +1913,42 @@ func generateMountsModeRun(mst *initramfsMountsState) error {
 	return nil
 }
 
+// mountEphemeralDataOverlay mounts as data an overlay of the host
+// ubuntu-data, which must be mounted already, with a tmpfs so that all the
+// writes of the run system are lost on reboot.
+func mountEphemeralDataOverlay(isClassic bool) error {
+	ephemeralDir := filepath.Join(boot.InitramfsRunMntDir, "ephemeral-data")
+	tmpfsOpts := &systemdMountOptions{
+		Tmpfs: true,
+	}
+	if !isClassic {
+		tmpfsOpts.NoSuid = true
+		tmpfsOpts.Private = true
+	}
+	if err := doSystemdMount("tmpfs", ephemeralDir, tmpfsOpts); err != nil {
+		return err
+	}
+
+	// the upper and work directories must be on the same filesystem
+	upperDir := filepath.Join(ephemeralDir, "upper")
+	workDir := filepath.Join(ephemeralDir, "work")
+	for _, dir := range []string{upperDir, workDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	overlayOpts := &systemdMountOptions{
+		Overlayfs: true,
+		LowerDirs: []string{boot.InitramfsHostUbuntuDataDir},
+		UpperDir:  upperDir,
+		WorkDir:   workDir,
+		NoSuid:    tmpfsOpts.NoSuid,
+		Private:   tmpfsOpts.Private,
+	}
+	return doSystemdMount("overlay", boot.InitramfsDataDir, overlayOpts)
+}
+
 var tryRecoverySystemHealthCheck = func(model gadget.Model) error {
 	// check that writable is accessible by checking whether the
 	// state file exists
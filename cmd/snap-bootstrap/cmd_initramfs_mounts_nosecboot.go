@@ -49,6 +49,9 @@ func init() {
 	secbootUnlockEncryptedVolumeUsingKey = func(disk disks.Disk, name string, key []byte) (secboot.UnlockResult, error) {
 		return secboot.UnlockResult{}, errNotImplemented
 	}
+	secbootDiskUnlockKeyFromKernel = func(devicePath string) ([]byte, error) {
+		return nil, errNotImplemented
+	}
 
 	secbootLockSealedKeys = func() error {
 		return errNotImplemented
@@ -31,5 +31,6 @@ func init() {
 	secbootMeasureSnapModelWhenPossible = secboot.MeasureSnapModelWhenPossible
 	secbootUnlockVolumeUsingSealedKeyIfEncrypted = secboot.UnlockVolumeUsingSealedKeyIfEncrypted
 	secbootUnlockEncryptedVolumeUsingKey = secboot.UnlockEncryptedVolumeUsingKey
+	secbootDiskUnlockKeyFromKernel = secboot.DiskUnlockKeyFromKernel
 	secbootLockSealedKeys = secboot.LockSealedKeys
 }
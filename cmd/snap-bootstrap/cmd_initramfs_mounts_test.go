@@ -40,9 +40,11 @@ import (
 	main "github.com/snapcore/snapd/cmd/snap-bootstrap"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/device"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/secboot"
 	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/seed/seedtest"
@@ -345,6 +347,9 @@ func (s *baseInitramfsMountsSuite) SetUpTest(c *C) {
 	s.AddCleanup(main.MockSecbootUnlockVolumeUsingSealedKeyIfEncrypted(func(disk disks.Disk, name string, sealedEncryptionKeyFile string, opts *secboot.UnlockVolumeUsingSealedKeyOptions) (secboot.UnlockResult, error) {
 		return foundUnencrypted(name), nil
 	}))
+	s.AddCleanup(main.MockSecbootDiskUnlockKeyFromKernel(func(devicePath string) ([]byte, error) {
+		return []byte("unlock-key-of-" + devicePath), nil
+	}))
 	s.AddCleanup(main.MockSecbootLockSealedKeys(func() error {
 		return nil
 	}))
@@ -750,23 +755,43 @@ func (s *initramfsMountsSuite) TestInitramfsMountsRunModeBootFlagsSet(c *C) {
 
 	tt := []struct {
 		bootFlags        []string
+		factoryBoots     int
 		expBootFlagsFile string
+		expBootFlags     []string
+		expFactoryBoots  int
 	}{
 		{
-			[]string{"factory"},
-			"factory",
+			bootFlags:        []string{"factory"},
+			expBootFlagsFile: "factory",
+			expBootFlags:     []string{"factory"},
 		},
 		{
-			[]string{"factory", ""},
-			"factory",
+			bootFlags:        []string{"factory", ""},
+			expBootFlagsFile: "factory",
+			expBootFlags:     []string{"factory"},
 		},
 		{
-			[]string{"factory", "unknown-new-flag"},
-			"factory,unknown-new-flag",
+			bootFlags:        []string{"factory", "unknown-new-flag"},
+			expBootFlagsFile: "factory,unknown-new-flag",
+			expBootFlags:     []string{"factory", "unknown-new-flag"},
 		},
 		{
-			[]string{},
-			"",
+			bootFlags:        []string{},
+			expBootFlagsFile: "",
+		},
+		{
+			bootFlags:        []string{"factory"},
+			factoryBoots:     2,
+			expBootFlagsFile: "factory",
+			expBootFlags:     []string{"factory"},
+			expFactoryBoots:  1,
+		},
+		{
+			// last boot in factory mode
+			bootFlags:        []string{"factory", "unknown-new-flag"},
+			factoryBoots:     1,
+			expBootFlagsFile: "factory,unknown-new-flag",
+			expBootFlags:     []string{"unknown-new-flag"},
 		},
 	}
 
@@ -809,6 +834,7 @@ func (s *initramfsMountsSuite) TestInitramfsMountsRunModeBootFlagsSet(c *C) {
 			Gadget:         s.gadget.Filename(),
 			CurrentKernels: []string{s.kernel.Filename()},
 			BootFlags:      t.bootFlags,
+			FactoryBoots:   t.factoryBoots,
 		}
 		err := modeEnv.WriteTo(filepath.Join(dirs.GlobalRootDir, "/run/mnt/data/system-data"))
 		c.Assert(err, IsNil)
@@ -818,6 +844,12 @@ func (s *initramfsMountsSuite) TestInitramfsMountsRunModeBootFlagsSet(c *C) {
 
 		// check that we wrote the /run file with the boot flags in it
 		c.Assert(filepath.Join(dirs.SnapRunDir, "boot-flags"), testutil.FileEquals, t.expBootFlagsFile)
+
+		// and that the number of factory boots was accounted for
+		m, err := boot.ReadModeenv(filepath.Join(dirs.GlobalRootDir, "/run/mnt/data/system-data"))
+		c.Assert(err, IsNil)
+		c.Check(m.BootFlags, DeepEquals, t.expBootFlags)
+		c.Check(m.FactoryBoots, Equals, t.expFactoryBoots)
 	}
 }
 
@@ -1035,6 +1067,91 @@ func (s *initramfsMountsSuite) TestInitramfsMountsRunModeUnencryptedWithSaveHapp
 	c.Assert(err, IsNil)
 }
 
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeEphemeralDataHappy(c *C) {
+	s.mockProcCmdlineContent(c, "snapd_recovery_mode=run")
+
+	restore := disks.MockMountPointDisksToPartitionMapping(
+		map[disks.Mountpoint]*disks.MockDiskMapping{
+			{Mountpoint: boot.InitramfsUbuntuBootDir}:     defaultBootWithSaveDisk,
+			{Mountpoint: boot.InitramfsHostUbuntuDataDir}: defaultBootWithSaveDisk,
+			{Mountpoint: boot.InitramfsUbuntuSaveDir}:     defaultBootWithSaveDisk,
+		},
+	)
+	defer restore()
+
+	ephemeralDir := filepath.Join(boot.InitramfsRunMntDir, "ephemeral-data")
+	restore = s.mockSystemdMountSequence(c, []systemdMount{
+		s.ubuntuLabelMount("ubuntu-boot", "run"),
+		s.ubuntuPartUUIDMount("ubuntu-seed-partuuid", "run"),
+		{
+			"/dev/disk/by-partuuid/ubuntu-data-partuuid",
+			boot.InitramfsHostUbuntuDataDir,
+			&main.SystemdMountOptions{
+				NeedsFsck: true,
+				NoSuid:    true,
+				Private:   true,
+				ReadOnly:  true,
+			},
+			nil,
+		},
+		{
+			"tmpfs",
+			ephemeralDir,
+			tmpfsMountOpts,
+			nil,
+		},
+		{
+			"overlay",
+			boot.InitramfsDataDir,
+			&main.SystemdMountOptions{
+				Overlayfs: true,
+				LowerDirs: []string{boot.InitramfsHostUbuntuDataDir},
+				UpperDir:  filepath.Join(ephemeralDir, "upper"),
+				WorkDir:   filepath.Join(ephemeralDir, "work"),
+				NoSuid:    true,
+				Private:   true,
+			},
+			nil,
+		},
+		s.ubuntuPartUUIDMount("ubuntu-save-partuuid", "run"),
+		s.makeRunSnapSystemdMount(snap.TypeBase, s.core20),
+		s.makeRunSnapSystemdMount(snap.TypeGadget, s.gadget),
+		s.makeRunSnapSystemdMount(snap.TypeKernel, s.kernel),
+	}, nil)
+	defer restore()
+
+	// mock a bootloader
+	bloader := boottest.MockUC20RunBootenv(bootloadertest.Mock("mock", c.MkDir()))
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+	bloader.SetBootVars(map[string]string{"snapd_ephemeral_data": "1"})
+
+	// set the current kernel
+	restore = bloader.SetEnabledKernel(s.kernel)
+	defer restore()
+
+	s.makeSnapFilesOnEarlyBootUbuntuData(c, s.kernel, s.core20, s.gadget)
+
+	// write modeenv, as it would be seen through the overlay
+	modeEnv := boot.Modeenv{
+		Mode:           "run",
+		Base:           s.core20.Filename(),
+		Gadget:         s.gadget.Filename(),
+		CurrentKernels: []string{s.kernel.Filename()},
+	}
+	err := modeEnv.WriteTo(filepath.Join(dirs.GlobalRootDir, "/run/mnt/data/system-data"))
+	c.Assert(err, IsNil)
+
+	_, err = main.Parser().ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+
+	c.Check(filepath.Join(ephemeralDir, "upper"), testutil.FilePresent)
+	c.Check(filepath.Join(ephemeralDir, "work"), testutil.FilePresent)
+	// snapd is told about it
+	c.Check(filepath.Join(dirs.SnapBootstrapRunDir, "ephemeral-data"), testutil.FilePresent)
+	c.Check(boot.WritableDataIsEphemeral(), Equals, true)
+}
+
 func (s *initramfsMountsSuite) TestInitramfsMountsRunModeHappyNoGadgetMount(c *C) {
 	// M
 	s.mockProcCmdlineContent(c, "snapd_recovery_mode=run")
@@ -2374,6 +2491,125 @@ func (s *initramfsMountsSuite) TestInitramfsMountsRunModeEncryptedDataHappy(c *C
 
 	c.Assert(filepath.Join(dirs.SnapBootstrapRunDir, "secboot-epoch-measured"), testutil.FilePresent)
 	c.Assert(filepath.Join(dirs.SnapBootstrapRunDir, "run-model-measured"), testutil.FilePresent)
+
+	// the state encryption key is derived from the unlock key of
+	// ubuntu-data
+	key, err := state.EncryptionKey()
+	c.Assert(err, IsNil)
+	c.Assert(state.ProvideEncryptionKey([]byte("unlock-key-of-/dev/disk/by-partuuid/ubuntu-data-enc-partuuid")), IsNil)
+	expectedKey, err := state.EncryptionKey()
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, expectedKey)
+}
+
+func (s *initramfsMountsSuite) testInitramfsMountsRunModeEncryptedPassphrase(c *C, disk *disks.MockDiskMapping) {
+	s.mockProcCmdlineContent(c, "snapd_recovery_mode=run")
+
+	defer main.MockSecbootLockSealedKeys(func() error { return nil })()
+
+	restore := disks.MockMountPointDisksToPartitionMapping(
+		map[disks.Mountpoint]*disks.MockDiskMapping{
+			{Mountpoint: boot.InitramfsUbuntuBootDir}:                          disk,
+			{Mountpoint: boot.InitramfsDataDir, IsDecryptedDevice: true}:       disk,
+			{Mountpoint: boot.InitramfsUbuntuSaveDir, IsDecryptedDevice: true}: disk,
+		},
+	)
+	defer restore()
+
+	restore = s.mockSystemdMountSequence(c, []systemdMount{
+		s.ubuntuLabelMount("ubuntu-boot", "run"),
+		s.ubuntuPartUUIDMount("ubuntu-seed-partuuid", "run"),
+		{
+			"/dev/mapper/ubuntu-data-random",
+			boot.InitramfsDataDir,
+			needsFsckAndNoSuidDiskMountOpts,
+			nil,
+		},
+		{
+			"/dev/mapper/ubuntu-save-random",
+			boot.InitramfsUbuntuSaveDir,
+			needsFsckDiskMountOpts,
+			nil,
+		},
+		s.makeRunSnapSystemdMount(snap.TypeBase, s.core20),
+		s.makeRunSnapSystemdMount(snap.TypeGadget, s.gadget),
+		s.makeRunSnapSystemdMount(snap.TypeKernel, s.kernel),
+	}, nil)
+	defer restore()
+
+	// no keys were sealed on install, only the marker was written
+	c.Assert(device.WritePassphraseAuthMarkers(boot.InitramfsBootEncryptionKeyDir), IsNil)
+
+	dataActivated := false
+	restore = main.MockSecbootUnlockVolumeUsingSealedKeyIfEncrypted(func(disk disks.Disk, name string, sealedEncryptionKeyFile string, opts *secboot.UnlockVolumeUsingSealedKeyOptions) (secboot.UnlockResult, error) {
+		c.Assert(name, Equals, "ubuntu-data")
+		c.Check(opts.UsePassphrase, Equals, true)
+		c.Check(opts.AllowRecoveryKey, Equals, true)
+		dataActivated = true
+		return happyUnlocked("ubuntu-data", secboot.UnlockedWithPassphrase), nil
+	})
+	defer restore()
+
+	s.mockUbuntuSaveKeyAndMarker(c, filepath.Join(dirs.GlobalRootDir, "/run/mnt/data/system-data"), "foo", "marker")
+	s.mockUbuntuSaveMarker(c, boot.InitramfsUbuntuSaveDir, "marker")
+
+	saveActivated := false
+	restore = main.MockSecbootUnlockEncryptedVolumeUsingKey(func(disk disks.Disk, name string, key []byte) (secboot.UnlockResult, error) {
+		c.Check(dataActivated, Equals, true, Commentf("ubuntu-data not activated yet"))
+		saveActivated = true
+		c.Assert(name, Equals, "ubuntu-save")
+		c.Assert(key, DeepEquals, []byte("foo"))
+		return happyUnlocked("ubuntu-save", secboot.UnlockedWithKey), nil
+	})
+	defer restore()
+
+	restore = main.MockSecbootMeasureSnapSystemEpochWhenPossible(func() error { return nil })
+	defer restore()
+	restore = main.MockSecbootMeasureSnapModelWhenPossible(func(findModel func() (*asserts.Model, error)) error {
+		return nil
+	})
+	defer restore()
+
+	// mock a bootloader
+	bloader := boottest.MockUC20RunBootenv(bootloadertest.Mock("mock", c.MkDir()))
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+
+	// set the current kernel
+	restore = bloader.SetEnabledKernel(s.kernel)
+	defer restore()
+
+	s.makeSnapFilesOnEarlyBootUbuntuData(c, s.kernel, s.core20, s.gadget)
+
+	// write modeenv
+	modeEnv := boot.Modeenv{
+		Mode:           "run",
+		Base:           s.core20.Filename(),
+		Gadget:         s.gadget.Filename(),
+		CurrentKernels: []string{s.kernel.Filename()},
+	}
+	err := modeEnv.WriteTo(filepath.Join(dirs.GlobalRootDir, "/run/mnt/data/system-data"))
+	c.Assert(err, IsNil)
+
+	_, err = main.Parser().ParseArgs([]string{"initramfs-mounts"})
+	c.Assert(err, IsNil)
+	c.Check(dataActivated, Equals, true)
+	c.Check(saveActivated, Equals, true)
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeEncryptedPassphraseHappy(c *C) {
+	s.testInitramfsMountsRunModeEncryptedPassphrase(c, defaultEncBootDisk)
+}
+
+func (s *initramfsMountsSuite) TestInitramfsMountsRunModeEncryptedPassphraseRAIDHappy(c *C) {
+	// the partitions are on a software RAID device
+	raidDisk := &disks.MockDiskMapping{
+		Structure:         defaultEncBootDisk.Structure,
+		DiskHasPartitions: true,
+		DevNum:            "9:127",
+		DevNode:           "/dev/md127",
+	}
+	s.testInitramfsMountsRunModeEncryptedPassphrase(c, raidDisk)
 }
 
 func (s *initramfsMountsSuite) TestInitramfsMountsRunCVMModeHappy(c *C) {
@@ -3707,6 +3943,107 @@ func (s *initramfsMountsSuite) TestInitramfsMountsRecoverModeHappyEncrypted(c *C
 	c.Assert(filepath.Join(dirs.SnapBootstrapRunDir, fmt.Sprintf("%s-model-measured", s.sysLabel)), testutil.FilePresent)
 }
 
+func (s *initramfsMountsSuite) TestInitramfsMountsRecoverModeEncryptedPassphraseHappy(c *C) {
+	s.mockProcCmdlineContent(c, "snapd_recovery_mode=recover snapd_recovery_system="+s.sysLabel)
+
+	restore := main.MockPartitionUUIDForBootedKernelDisk("")
+	defer restore()
+
+	// setup a bootloader for setting the bootenv after we are done
+	bloader := bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(bloader)
+	defer bootloader.Force(nil)
+
+	restore = disks.MockMountPointDisksToPartitionMapping(
+		map[disks.Mountpoint]*disks.MockDiskMapping{
+			{Mountpoint: boot.InitramfsUbuntuSeedDir}: defaultEncBootDisk,
+			{Mountpoint: boot.InitramfsUbuntuBootDir}: defaultEncBootDisk,
+			{
+				Mountpoint:        boot.InitramfsHostUbuntuDataDir,
+				IsDecryptedDevice: true,
+			}: defaultEncBootDisk,
+			{
+				Mountpoint:        boot.InitramfsUbuntuSaveDir,
+				IsDecryptedDevice: true,
+			}: defaultEncBootDisk,
+		},
+	)
+	defer restore()
+
+	// no keys were sealed on install, only the marker was written
+	c.Assert(device.WritePassphraseAuthMarkers(boot.InitramfsSeedEncryptionKeyDir), IsNil)
+
+	dataActivated := false
+	restore = main.MockSecbootUnlockVolumeUsingSealedKeyIfEncrypted(func(disk disks.Disk, name string, sealedEncryptionKeyFile string, opts *secboot.UnlockVolumeUsingSealedKeyOptions) (secboot.UnlockResult, error) {
+		c.Assert(name, Equals, "ubuntu-data")
+		c.Check(opts.UsePassphrase, Equals, true)
+		c.Check(opts.AllowRecoveryKey, Equals, false)
+		dataActivated = true
+		return happyUnlocked("ubuntu-data", secboot.UnlockedWithPassphrase), nil
+	})
+	defer restore()
+
+	s.mockUbuntuSaveKeyAndMarker(c, filepath.Join(dirs.GlobalRootDir, "/run/mnt/host/ubuntu-data/system-data"), "foo", "marker")
+	s.mockUbuntuSaveMarker(c, boot.InitramfsUbuntuSaveDir, "marker")
+
+	saveActivated := false
+	restore = main.MockSecbootUnlockEncryptedVolumeUsingKey(func(disk disks.Disk, name string, key []byte) (secboot.UnlockResult, error) {
+		c.Check(dataActivated, Equals, true, Commentf("ubuntu-data not activated yet"))
+		c.Assert(key, DeepEquals, []byte("foo"))
+		saveActivated = true
+		return happyUnlocked("ubuntu-save", secboot.UnlockedWithKey), nil
+	})
+	defer restore()
+
+	restore = main.MockSecbootMeasureSnapSystemEpochWhenPossible(func() error { return nil })
+	defer restore()
+	restore = main.MockSecbootMeasureSnapModelWhenPossible(func(findModel func() (*asserts.Model, error)) error {
+		return nil
+	})
+	defer restore()
+
+	restore = s.mockSystemdMountSequence(c, []systemdMount{
+		s.ubuntuLabelMount("ubuntu-seed", "recover"),
+		s.makeSeedSnapSystemdMount(snap.TypeSnapd),
+		s.makeSeedSnapSystemdMount(snap.TypeKernel),
+		s.makeSeedSnapSystemdMount(snap.TypeBase),
+		s.makeSeedSnapSystemdMount(snap.TypeGadget),
+		{
+			"tmpfs",
+			boot.InitramfsDataDir,
+			tmpfsMountOpts,
+			nil,
+		},
+		{
+			"/dev/disk/by-partuuid/ubuntu-boot-partuuid",
+			boot.InitramfsUbuntuBootDir,
+			needsFsckDiskMountOpts,
+			nil,
+		},
+		{
+			"/dev/mapper/ubuntu-data-random",
+			boot.InitramfsHostUbuntuDataDir,
+			needsNoSuidDiskMountOpts,
+			nil,
+		},
+		{
+			"/dev/mapper/ubuntu-save-random",
+			boot.InitramfsUbuntuSaveDir,
+			mountOpts,
+			nil,
+		},
+	}, nil)
+	defer restore()
+
+	s.testRecoverModeHappy(c)
+
+	// unlocking with the passphrase is not degraded
+	c.Assert(filepath.Join(dirs.SnapBootstrapRunDir, "degraded.json"), testutil.FileAbsent)
+
+	c.Check(dataActivated, Equals, true)
+	c.Check(saveActivated, Equals, true)
+}
+
 func checkDegradedJSON(c *C, name string, exp map[string]interface{}) {
 	b, err := ioutil.ReadFile(filepath.Join(dirs.SnapBootstrapRunDir, name))
 	c.Assert(err, IsNil)
@@ -102,6 +102,14 @@ func MockDefaultMarkerFile(p string) (restore func()) {
 	}
 }
 
+func MockSecbootDiskUnlockKeyFromKernel(f func(devicePath string) ([]byte, error)) (restore func()) {
+	old := secbootDiskUnlockKeyFromKernel
+	secbootDiskUnlockKeyFromKernel = f
+	return func() {
+		secbootDiskUnlockKeyFromKernel = old
+	}
+}
+
 func MockSecbootUnlockVolumeUsingSealedKeyIfEncrypted(f func(disk disks.Disk, name string, sealedEncryptionKeyFile string, opts *secboot.UnlockVolumeUsingSealedKeyOptions) (secboot.UnlockResult, error)) (restore func()) {
 	old := secbootUnlockVolumeUsingSealedKeyIfEncrypted
 	secbootUnlockVolumeUsingSealedKeyIfEncrypted = f
@@ -182,3 +190,18 @@ func MockWaitFile(f func(string, time.Duration, int) error) (restore func()) {
 }
 
 var WaitFile = waitFile
+
+type FsckFailure = fsckFailure
+
+func CheckFilesystem(partName, device string) error {
+	policy, err := fsckPolicyFromKernelCommandLine()
+	if err != nil {
+		return err
+	}
+	return policy.checkFilesystem(partName, device)
+}
+
+var (
+	FallbackToRecoverModeIfFsckFailure = fallbackToRecoverModeIfFsckFailure
+	ExposeFsckFailure                  = exposeFsckFailure
+)
@@ -0,0 +1,283 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+const (
+	// fsckModeAuto leaves checking the filesystem to systemd-fsck when
+	// mounting it, a failure drops to the initramfs shell
+	fsckModeAuto = "auto"
+	// fsckModeCheck checks the filesystem without repairing it, falling
+	// back to recover mode when errors are found
+	fsckModeCheck = "check"
+	// fsckModeRepair checks the filesystem and tries to repair it a bounded
+	// number of times, falling back to recover mode when that fails
+	fsckModeRepair = "repair"
+
+	defaultFsckRepairAttempts = 2
+
+	// fsckFailureFile is the name of the file describing why run mode
+	// could not be booted, it is left on ubuntu-boot for recover mode to
+	// pick up and expose next to degraded.json
+	fsckFailureFile = "fsck-failure.json"
+)
+
+// fsckPolicy is the policy for checking and repairing ubuntu-data and
+// ubuntu-save before mounting them in run mode. It is set with
+// snapd_fsck=auto|check|repair and snapd_fsck_attempts=<n> on the kernel
+// command line, usually through the gadget. Repairing btrfs is considered
+// unsafe by its own tools and additionally requires
+// snapd_fsck_btrfs_repair=1.
+type fsckPolicy struct {
+	Mode           string
+	RepairAttempts int
+	RepairBtrfs    bool
+}
+
+func fsckPolicyFromKernelCommandLine() (*fsckPolicy, error) {
+	m, err := osutil.KernelCommandLineKeyValues("snapd_fsck", "snapd_fsck_attempts", "snapd_fsck_btrfs_repair")
+	if err != nil {
+		return nil, err
+	}
+	policy := &fsckPolicy{
+		Mode:           fsckModeAuto,
+		RepairAttempts: defaultFsckRepairAttempts,
+	}
+	switch mode := m["snapd_fsck"]; mode {
+	case "":
+	case fsckModeAuto, fsckModeCheck, fsckModeRepair:
+		policy.Mode = mode
+	default:
+		logger.Noticef("ignoring invalid snapd_fsck value %q", mode)
+	}
+	if attempts, ok := m["snapd_fsck_attempts"]; ok {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 1 {
+			logger.Noticef("ignoring invalid snapd_fsck_attempts value %q", attempts)
+		} else {
+			policy.RepairAttempts = n
+		}
+	}
+	policy.RepairBtrfs = m["snapd_fsck_btrfs_repair"] == "1"
+	return policy, nil
+}
+
+// systemdFsck returns whether systemd-fsck should still check the filesystem
+// when mounting it.
+func (p *fsckPolicy) systemdFsck() bool {
+	return p.Mode == fsckModeAuto
+}
+
+// fsckFailure describes a filesystem that could not be checked or repaired.
+type fsckFailure struct {
+	Partition  string `json:"partition"`
+	Device     string `json:"device"`
+	Filesystem string `json:"filesystem,omitempty"`
+	// Reason is one of "check-failed", when errors were found and repairs
+	// are not allowed, "repair-failed", when the errors remain after all
+	// the repair attempts, or "error", when the tools could not be run.
+	Reason         string `json:"reason"`
+	RepairAttempts int    `json:"repair-attempts,omitempty"`
+	Message        string `json:"message"`
+}
+
+func (f *fsckFailure) Error() string {
+	return fmt.Sprintf("cannot use %s (device %s): %s", f.Partition, f.Device, f.Message)
+}
+
+// fsckCommands returns the commands checking and repairing the given
+// filesystem type. The check command must exit with 0 only when the
+// filesystem is clean and must not modify it. The repair command is nil
+// when the filesystem cannot be repaired according to the policy.
+func (p *fsckPolicy) fsckCommands(fstype, device string) (check, repair []string) {
+	switch fstype {
+	case "ext2", "ext3", "ext4":
+		return []string{"e2fsck", "-f", "-n", device}, []string{"e2fsck", "-f", "-y", device}
+	case "vfat":
+		return []string{"fsck.vfat", "-n", device}, []string{"fsck.vfat", "-a", device}
+	case "xfs":
+		return []string{"xfs_repair", "-n", device}, []string{"xfs_repair", device}
+	case "f2fs":
+		return []string{"fsck.f2fs", "-f", "--dry-run", device}, []string{"fsck.f2fs", "-f", "-y", device}
+	case "btrfs":
+		check = []string{"btrfs", "check", "--readonly", device}
+		if p.RepairBtrfs {
+			repair = []string{"btrfs", "check", "--repair", device}
+		}
+		return check, repair
+	}
+	return nil, nil
+}
+
+func filesystemType(device string) (string, error) {
+	out, err := exec.Command("blkid", "-p", "-s", "TYPE", "-o", "value", device).CombinedOutput()
+	if err != nil {
+		return "", osutil.OutputErr(out, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// runFsckCommand runs the given check or repair command. When the command
+// exits with a non 0 status the problems it reported are returned, err is
+// only set when the command could not be run at all.
+func runFsckCommand(cmd []string) (problems, err error) {
+	out, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput()
+	if _, ok := err.(*exec.ExitError); ok {
+		return osutil.OutputErr(out, err), nil
+	}
+	return nil, err
+}
+
+// checkFilesystem checks and if allowed repairs the filesystem of the given
+// partition according to the policy. A *fsckFailure is returned when the
+// filesystem cannot be used.
+func (p *fsckPolicy) checkFilesystem(partName, device string) error {
+	if p.Mode == fsckModeAuto {
+		return nil
+	}
+	failure := &fsckFailure{
+		Partition: partName,
+		Device:    device,
+		Reason:    "error",
+	}
+	fstype, err := filesystemType(device)
+	if err != nil {
+		failure.Message = fmt.Sprintf("cannot determine filesystem type: %v", err)
+		return failure
+	}
+	failure.Filesystem = fstype
+	check, repair := p.fsckCommands(fstype, device)
+	if check == nil {
+		logger.Noticef("not checking %s: unsupported filesystem %q", partName, fstype)
+		return nil
+	}
+
+	problems, err := runFsckCommand(check)
+	if err != nil {
+		failure.Message = fmt.Sprintf("cannot run %s: %v", check[0], err)
+		return failure
+	}
+	if problems == nil {
+		return nil
+	}
+	logger.Noticef("errors found on %s: %v", partName, problems)
+	if p.Mode == fsckModeCheck || repair == nil {
+		failure.Reason = "check-failed"
+		failure.Message = fmt.Sprintf("filesystem errors found: %v", problems)
+		if repair == nil {
+			failure.Message += fmt.Sprintf(" (repairing %s is not enabled)", fstype)
+		}
+		return failure
+	}
+
+	for attempt := 1; attempt <= p.RepairAttempts; attempt++ {
+		logger.Noticef("repairing %s, attempt %d of %d", partName, attempt, p.RepairAttempts)
+		repaired, err := runFsckCommand(repair)
+		if err != nil {
+			failure.Message = fmt.Sprintf("cannot run %s: %v", repair[0], err)
+			return failure
+		}
+		if repaired != nil {
+			// the repair tools report fixed errors with a non 0 exit
+			// status too, the check that follows decides
+			logger.Noticef("repair of %s reported: %v", partName, repaired)
+		}
+		problems, err = runFsckCommand(check)
+		if err != nil {
+			failure.Message = fmt.Sprintf("cannot run %s: %v", check[0], err)
+			return failure
+		}
+		if problems == nil {
+			logger.Noticef("repaired %s", partName)
+			return nil
+		}
+	}
+	failure.Reason = "repair-failed"
+	failure.RepairAttempts = p.RepairAttempts
+	failure.Message = fmt.Sprintf("filesystem errors remain after repairing: %v", problems)
+	return failure
+}
+
+// fallbackToRecoverModeIfFsckFailure returns err as is unless it is a
+// *fsckFailure, in which case the failure is recorded on ubuntu-boot, the
+// next boot is switched to recover mode and the system is rebooted.
+func fallbackToRecoverModeIfFsckFailure(err error) error {
+	failure, ok := err.(*fsckFailure)
+	if !ok {
+		return err
+	}
+	logger.Noticef("%v, falling back to recover mode", failure)
+	if err := writeFsckFailure(failure, filepath.Join(boot.InitramfsUbuntuBootDir, "device", fsckFailureFile)); err != nil {
+		// not fatal, recover mode is still more useful than a shell
+		logger.Noticef("cannot record the failure: %v", err)
+	}
+	if err := boot.EnsureNextBootToRecoverMode(); err != nil {
+		return fmt.Errorf("%v (cannot fall back to recover mode: %v)", failure, err)
+	}
+	if err := boot.InitramfsReboot(); err != nil {
+		return fmt.Errorf("%v (cannot reboot into recover mode: %v)", failure, err)
+	}
+	// not reached, unless in tests
+	return fmt.Errorf("%v, rebooting into recover mode", failure)
+}
+
+func writeFsckFailure(failure *fsckFailure, fn string) error {
+	b, err := json.Marshal(failure)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(fn, b, 0644, 0)
+}
+
+// exposeFsckFailure moves the record of a run mode failure left on
+// ubuntu-boot to the snap-bootstrap run directory, next to degraded.json, so
+// that it is visible in the recover mode system. It is meant to be used in
+// recover mode once ubuntu-boot is mounted.
+func exposeFsckFailure() error {
+	src := filepath.Join(boot.InitramfsUbuntuBootDir, "device", fsckFailureFile)
+	if !osutil.FileExists(src) {
+		return nil
+	}
+	if err := os.MkdirAll(dirs.SnapBootstrapRunDir, 0755); err != nil {
+		return err
+	}
+	if err := osutil.CopyFile(src, filepath.Join(dirs.SnapBootstrapRunDir, fsckFailureFile), 0); err != nil {
+		return err
+	}
+	// the next run mode boot checks the filesystems anew
+	return os.Remove(src)
+}
@@ -0,0 +1,295 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/bootloader/bootloadertest"
+	main "github.com/snapcore/snapd/cmd/snap-bootstrap"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type fsckSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&fsckSuite{})
+
+func (s *fsckSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.mockCmdline(c, "snapd_recovery_mode=run")
+	s.mockBlkid(c, "ext4")
+}
+
+func (s *fsckSuite) mockCmdline(c *C, cmdline string) {
+	mockProcCmdline := filepath.Join(c.MkDir(), "proc-cmdline")
+	c.Assert(ioutil.WriteFile(mockProcCmdline, []byte(cmdline), 0644), IsNil)
+	s.AddCleanup(osutil.MockProcCmdline(mockProcCmdline))
+}
+
+func (s *fsckSuite) mockBlkid(c *C, fstype string) *testutil.MockCmd {
+	cmd := testutil.MockCommand(c, "blkid", "echo "+fstype)
+	s.AddCleanup(cmd.Restore)
+	return cmd
+}
+
+// mockE2fsck mocks e2fsck such that the filesystem stays dirty until it was
+// repaired the given number of times
+func (s *fsckSuite) mockE2fsck(c *C, repairsNeeded int) *testutil.MockCmd {
+	counter := filepath.Join(c.MkDir(), "repairs")
+	cmd := testutil.MockCommand(c, "e2fsck", `
+if [ "$2" = "-y" ]; then
+    echo repaired >> `+counter+`
+    exit 1
+fi
+if [ "$(cat `+counter+` 2>/dev/null | wc -l)" -ge `+strconv.Itoa(repairsNeeded)+` ]; then
+    exit 0
+fi
+echo "inode errors"
+exit 4
+`)
+	s.AddCleanup(cmd.Restore)
+	return cmd
+}
+
+func (s *fsckSuite) TestCheckFilesystemAutoDoesNothing(c *C) {
+	e2fsck := s.mockE2fsck(c, 1)
+
+	c.Assert(main.CheckFilesystem("ubuntu-data", "/dev/mapper/ubuntu-data"), IsNil)
+	c.Check(e2fsck.Calls(), HasLen, 0)
+}
+
+func (s *fsckSuite) TestCheckFilesystemClean(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=check")
+	e2fsck := s.mockE2fsck(c, 0)
+
+	c.Assert(main.CheckFilesystem("ubuntu-data", "/dev/mapper/ubuntu-data"), IsNil)
+	c.Check(e2fsck.Calls(), DeepEquals, [][]string{
+		{"e2fsck", "-f", "-n", "/dev/mapper/ubuntu-data"},
+	})
+}
+
+func (s *fsckSuite) TestCheckFilesystemCheckOnlyFails(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=check")
+	e2fsck := s.mockE2fsck(c, 1)
+
+	err := main.CheckFilesystem("ubuntu-data", "/dev/mapper/ubuntu-data")
+	c.Assert(err, ErrorMatches, `cannot use ubuntu-data \(device /dev/mapper/ubuntu-data\): filesystem errors found: inode errors`)
+	failure, ok := err.(*main.FsckFailure)
+	c.Assert(ok, Equals, true)
+	c.Check(failure.Reason, Equals, "check-failed")
+	c.Check(failure.Filesystem, Equals, "ext4")
+	c.Check(e2fsck.Calls(), HasLen, 1)
+}
+
+func (s *fsckSuite) TestCheckFilesystemRepaired(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=repair snapd_fsck_attempts=3")
+	e2fsck := s.mockE2fsck(c, 2)
+
+	c.Assert(main.CheckFilesystem("ubuntu-save", "/dev/disk/by-partuuid/save"), IsNil)
+	c.Check(e2fsck.Calls(), DeepEquals, [][]string{
+		{"e2fsck", "-f", "-n", "/dev/disk/by-partuuid/save"},
+		{"e2fsck", "-f", "-y", "/dev/disk/by-partuuid/save"},
+		{"e2fsck", "-f", "-n", "/dev/disk/by-partuuid/save"},
+		{"e2fsck", "-f", "-y", "/dev/disk/by-partuuid/save"},
+		{"e2fsck", "-f", "-n", "/dev/disk/by-partuuid/save"},
+	})
+}
+
+func (s *fsckSuite) TestCheckFilesystemRepairAttemptsBounded(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=repair")
+	e2fsck := s.mockE2fsck(c, 5)
+
+	err := main.CheckFilesystem("ubuntu-data", "/dev/mapper/ubuntu-data")
+	c.Assert(err, ErrorMatches, `cannot use ubuntu-data \(device /dev/mapper/ubuntu-data\): filesystem errors remain after repairing: inode errors`)
+	failure := err.(*main.FsckFailure)
+	c.Check(failure.Reason, Equals, "repair-failed")
+	c.Check(failure.RepairAttempts, Equals, 2)
+	// one check and two rounds of repair and check
+	c.Check(e2fsck.Calls(), HasLen, 5)
+}
+
+// mockBtrfs mocks btrfs such that the filesystem stays dirty until it was
+// repaired once
+func (s *fsckSuite) mockBtrfs(c *C) *testutil.MockCmd {
+	counter := filepath.Join(c.MkDir(), "repairs")
+	cmd := testutil.MockCommand(c, "btrfs", `
+if [ "$2" = "--repair" ]; then
+    echo repaired >> `+counter+`
+    exit 0
+fi
+if [ -e `+counter+` ]; then
+    exit 0
+fi
+echo "csum errors"
+exit 1
+`)
+	s.AddCleanup(cmd.Restore)
+	return cmd
+}
+
+func (s *fsckSuite) TestCheckFilesystemBtrfsRepairNotEnabled(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=repair")
+	s.mockBlkid(c, "btrfs")
+	btrfs := s.mockBtrfs(c)
+
+	err := main.CheckFilesystem("ubuntu-data", "/dev/mapper/ubuntu-data")
+	c.Assert(err, ErrorMatches, `cannot use ubuntu-data \(device /dev/mapper/ubuntu-data\): filesystem errors found: csum errors \(repairing btrfs is not enabled\)`)
+	c.Check(err.(*main.FsckFailure).Reason, Equals, "check-failed")
+	// only checked, read-only
+	c.Check(btrfs.Calls(), DeepEquals, [][]string{
+		{"btrfs", "check", "--readonly", "/dev/mapper/ubuntu-data"},
+	})
+}
+
+func (s *fsckSuite) TestCheckFilesystemBtrfsRepairEnabled(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=repair snapd_fsck_btrfs_repair=1")
+	s.mockBlkid(c, "btrfs")
+	btrfs := s.mockBtrfs(c)
+
+	c.Assert(main.CheckFilesystem("ubuntu-data", "/dev/mapper/ubuntu-data"), IsNil)
+	c.Check(btrfs.Calls(), DeepEquals, [][]string{
+		{"btrfs", "check", "--readonly", "/dev/mapper/ubuntu-data"},
+		{"btrfs", "check", "--repair", "/dev/mapper/ubuntu-data"},
+		{"btrfs", "check", "--readonly", "/dev/mapper/ubuntu-data"},
+	})
+}
+
+func (s *fsckSuite) TestCheckFilesystemF2fs(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=repair")
+	s.mockBlkid(c, "f2fs")
+	counter := filepath.Join(c.MkDir(), "repairs")
+	f2fs := testutil.MockCommand(c, "fsck.f2fs", `
+if [ "$2" = "-y" ]; then
+    echo repaired >> `+counter+`
+    exit 0
+fi
+[ -e `+counter+` ] && exit 0
+echo "invalid nat entries"
+exit 255
+`)
+	defer f2fs.Restore()
+
+	c.Assert(main.CheckFilesystem("ubuntu-save", "/dev/disk/by-partuuid/save"), IsNil)
+	c.Check(f2fs.Calls(), DeepEquals, [][]string{
+		{"fsck.f2fs", "-f", "--dry-run", "/dev/disk/by-partuuid/save"},
+		{"fsck.f2fs", "-f", "-y", "/dev/disk/by-partuuid/save"},
+		{"fsck.f2fs", "-f", "--dry-run", "/dev/disk/by-partuuid/save"},
+	})
+}
+
+func (s *fsckSuite) TestCheckFilesystemUnsupportedFilesystem(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=repair")
+	s.mockBlkid(c, "squashfs")
+	e2fsck := s.mockE2fsck(c, 1)
+
+	c.Assert(main.CheckFilesystem("ubuntu-data", "/dev/mapper/ubuntu-data"), IsNil)
+	c.Check(e2fsck.Calls(), HasLen, 0)
+}
+
+func (s *fsckSuite) TestCheckFilesystemBlkidError(c *C) {
+	s.mockCmdline(c, "snapd_recovery_mode=run snapd_fsck=check")
+	cmd := testutil.MockCommand(c, "blkid", "echo cannot open device; exit 2")
+	defer cmd.Restore()
+
+	err := main.CheckFilesystem("ubuntu-data", "/dev/mapper/ubuntu-data")
+	c.Assert(err, ErrorMatches, `cannot use ubuntu-data \(device /dev/mapper/ubuntu-data\): cannot determine filesystem type: cannot open device`)
+	c.Check(err.(*main.FsckFailure).Reason, Equals, "error")
+}
+
+func (s *fsckSuite) TestFallbackToRecoverModeIfFsckFailure(c *C) {
+	bl := bootloadertest.Mock("mock", c.MkDir())
+	bootloader.Force(bl)
+	defer bootloader.Force(nil)
+	c.Assert(bl.SetBootVars(map[string]string{
+		"snapd_recovery_mode":   "run",
+		"snapd_recovery_system": "20241017",
+	}), IsNil)
+
+	rebooted := false
+	defer boot.MockInitramfsReboot(func() error {
+		rebooted = true
+		return nil
+	})()
+
+	failure := &main.FsckFailure{
+		Partition:      "ubuntu-data",
+		Device:         "/dev/mapper/ubuntu-data",
+		Filesystem:     "ext4",
+		Reason:         "repair-failed",
+		RepairAttempts: 2,
+		Message:        "filesystem errors remain after repairing: inode errors",
+	}
+	err := main.FallbackToRecoverModeIfFsckFailure(failure)
+	c.Assert(err, ErrorMatches, `cannot use ubuntu-data .*: filesystem errors remain after repairing: inode errors, rebooting into recover mode`)
+	c.Check(rebooted, Equals, true)
+
+	vars, err := bl.GetBootVars("snapd_recovery_mode", "snapd_recovery_system")
+	c.Assert(err, IsNil)
+	c.Check(vars, DeepEquals, map[string]string{
+		"snapd_recovery_mode":   "recover",
+		"snapd_recovery_system": "20241017",
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(boot.InitramfsUbuntuBootDir, "device/fsck-failure.json"))
+	c.Assert(err, IsNil)
+	var recorded map[string]interface{}
+	c.Assert(json.Unmarshal(data, &recorded), IsNil)
+	c.Check(recorded, DeepEquals, map[string]interface{}{
+		"partition":       "ubuntu-data",
+		"device":          "/dev/mapper/ubuntu-data",
+		"filesystem":      "ext4",
+		"reason":          "repair-failed",
+		"repair-attempts": 2.0,
+		"message":         "filesystem errors remain after repairing: inode errors",
+	})
+
+	// other errors are returned as is
+	otherErr := errors.New("other error")
+	c.Check(main.FallbackToRecoverModeIfFsckFailure(otherErr), Equals, otherErr)
+}
+
+func (s *fsckSuite) TestExposeFsckFailure(c *C) {
+	// nothing to do
+	c.Assert(main.ExposeFsckFailure(), IsNil)
+	c.Check(filepath.Join(dirs.SnapBootstrapRunDir, "fsck-failure.json"), testutil.FileAbsent)
+
+	src := filepath.Join(boot.InitramfsUbuntuBootDir, "device/fsck-failure.json")
+	c.Assert(os.MkdirAll(filepath.Dir(src), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(src, []byte(`{"partition":"ubuntu-data"}`), 0644), IsNil)
+
+	c.Assert(main.ExposeFsckFailure(), IsNil)
+	c.Check(filepath.Join(dirs.SnapBootstrapRunDir, "fsck-failure.json"), testutil.FileEquals, `{"partition":"ubuntu-data"}`)
+	c.Check(src, testutil.FileAbsent)
+}
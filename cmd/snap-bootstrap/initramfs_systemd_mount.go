@@ -53,6 +53,18 @@ type systemdMountOptions struct {
 	// Tmpfs indicates that "what" should be ignored and a new tmpfs should be
 	// mounted at the location.
 	Tmpfs bool
+	// Overlayfs indicates that "what" should be ignored and a new overlay
+	// filesystem, made of LowerDirs, UpperDir and WorkDir, should be mounted
+	// at the location.
+	Overlayfs bool
+	// LowerDirs are the read-only layers of an overlay filesystem, from the
+	// top one to the bottom one.
+	LowerDirs []string
+	// UpperDir is the writable layer of an overlay filesystem.
+	UpperDir string
+	// WorkDir is the work directory of an overlay filesystem, it must be on
+	// the same filesystem as UpperDir.
+	WorkDir string
 	// Ephemeral indicates that the mount should not persist from the initramfs
 	// to after the pivot_root to normal userspace. The default value, false,
 	// means that the mount will persist across the transition, this is done by
@@ -93,6 +105,17 @@ func doSystemdMountImpl(what, where string, opts *systemdMountOptions) error {
 	if opts.NeedsFsck && opts.Tmpfs {
 		return fmt.Errorf("cannot mount %q at %q: impossible to fsck a tmpfs", what, where)
 	}
+	if opts.Overlayfs {
+		if opts.Tmpfs {
+			return fmt.Errorf("cannot mount %q at %q: cannot be both a tmpfs and an overlay", what, where)
+		}
+		if opts.NeedsFsck {
+			return fmt.Errorf("cannot mount %q at %q: impossible to fsck an overlay", what, where)
+		}
+		if len(opts.LowerDirs) == 0 || opts.UpperDir == "" || opts.WorkDir == "" {
+			return fmt.Errorf("cannot mount %q at %q: overlay needs lower, upper and work directories", what, where)
+		}
+	}
 
 	whereEscaped := systemd.EscapeUnitNamePath(where)
 	unitName := whereEscaped + ".mount"
@@ -107,6 +130,10 @@ func doSystemdMountImpl(what, where string, opts *systemdMountOptions) error {
 		args = append(args, "--type=tmpfs")
 	}
 
+	if opts.Overlayfs {
+		args = append(args, "--type=overlay")
+	}
+
 	if opts.NeedsFsck {
 		// note that with the --fsck=yes argument, systemd will block starting
 		// the mount unit on a new systemd-fsck@<what> unit that will run the
@@ -139,6 +166,12 @@ func doSystemdMountImpl(what, where string, opts *systemdMountOptions) error {
 	}
 
 	var options []string
+	if opts.Overlayfs {
+		options = append(options,
+			"lowerdir="+strings.Join(opts.LowerDirs, ":"),
+			"upperdir="+opts.UpperDir,
+			"workdir="+opts.WorkDir)
+	}
 	if opts.NoSuid {
 		options = append(options, "nosuid")
 	}
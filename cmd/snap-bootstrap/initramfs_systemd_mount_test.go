@@ -182,6 +182,43 @@ func (s *doSystemdMountSuite) TestDoSystemdMount(c *C) {
 			isMountedReturns: []bool{true},
 			comment:          "happy ro",
 		},
+		{
+			what:  "overlay",
+			where: "/run/mnt/data",
+			opts: &main.SystemdMountOptions{
+				Overlayfs: true,
+				LowerDirs: []string{"/run/mnt/host/ubuntu-data"},
+				UpperDir:  "/run/mnt/ephemeral-data/upper",
+				WorkDir:   "/run/mnt/ephemeral-data/work",
+				NoSuid:    true,
+			},
+			timeNowTimes:     []time.Time{testStart, testStart},
+			isMountedReturns: []bool{true},
+			comment:          "happy overlay",
+		},
+		{
+			what:  "what",
+			where: "where",
+			opts: &main.SystemdMountOptions{
+				Overlayfs: true,
+				LowerDirs: []string{"/lower"},
+				UpperDir:  "/upper",
+				WorkDir:   "/work",
+				NeedsFsck: true,
+			},
+			expErr:  "cannot mount \"what\" at \"where\": impossible to fsck an overlay",
+			comment: "invalid overlay + fsck",
+		},
+		{
+			what:  "what",
+			where: "where",
+			opts: &main.SystemdMountOptions{
+				Overlayfs: true,
+				LowerDirs: []string{"/lower"},
+			},
+			expErr:  "cannot mount \"what\" at \"where\": overlay needs lower, upper and work directories",
+			comment: "invalid overlay without upper dir",
+		},
 	}
 
 	for _, t := range tt {
@@ -255,6 +292,7 @@ func (s *doSystemdMountSuite) TestDoSystemdMount(c *C) {
 			c.Assert(call[:len(args)], DeepEquals, args)
 
 			foundTypeTmpfs := false
+			foundTypeOverlay := false
 			foundFsckYes := false
 			foundFsckNo := false
 			foundNoBlock := false
@@ -263,11 +301,14 @@ func (s *doSystemdMountSuite) TestDoSystemdMount(c *C) {
 			foundBind := false
 			foundReadOnly := false
 			foundPrivate := false
+			var foundOverlayOpts []string
 
 			for _, arg := range call[len(args):] {
 				switch {
 				case arg == "--type=tmpfs":
 					foundTypeTmpfs = true
+				case arg == "--type=overlay":
+					foundTypeOverlay = true
 				case arg == "--fsck=yes":
 					foundFsckYes = true
 				case arg == "--fsck=no":
@@ -288,6 +329,10 @@ func (s *doSystemdMountSuite) TestDoSystemdMount(c *C) {
 						case "private":
 							foundPrivate = true
 						default:
+							if strings.HasPrefix(opt, "lowerdir=") || strings.HasPrefix(opt, "upperdir=") || strings.HasPrefix(opt, "workdir=") {
+								foundOverlayOpts = append(foundOverlayOpts, opt)
+								continue
+							}
 							c.Logf("Option '%s' unexpected", opt)
 							c.Fail()
 						}
@@ -298,6 +343,16 @@ func (s *doSystemdMountSuite) TestDoSystemdMount(c *C) {
 				}
 			}
 			c.Assert(foundTypeTmpfs, Equals, opts.Tmpfs)
+			c.Assert(foundTypeOverlay, Equals, opts.Overlayfs)
+			if opts.Overlayfs {
+				c.Assert(foundOverlayOpts, DeepEquals, []string{
+					"lowerdir=" + strings.Join(opts.LowerDirs, ":"),
+					"upperdir=" + opts.UpperDir,
+					"workdir=" + opts.WorkDir,
+				})
+			} else {
+				c.Assert(foundOverlayOpts, HasLen, 0)
+			}
 			c.Assert(foundFsckYes, Equals, opts.NeedsFsck)
 			c.Assert(foundFsckNo, Equals, !opts.NeedsFsck)
 			c.Assert(foundNoBlock, Equals, opts.NoWait)
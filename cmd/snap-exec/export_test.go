@@ -20,8 +20,10 @@
 package main
 
 import (
+	"os/exec"
 	"syscall"
 
+	"github.com/snapcore/snapd/sandbox/landlock"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -70,3 +72,36 @@ func MockSyscallStat(f func(string, *syscall.Stat_t) (err error)) func() {
 	syscallStat = f
 	return r
 }
+
+func MockLandlockRestrictSelf(f func(rules []landlock.Rule) error) func() {
+	r := testutil.Backup(&landlockRestrictSelf)
+	landlockRestrictSelf = f
+	return r
+}
+
+func SetOptsInUserNamespace(b bool) {
+	opts.InUserNamespace = b
+}
+
+func MockSandboxHybridConfinement(hybrid bool) func() {
+	r := testutil.Backup(&sandboxHybridConfinement)
+	sandboxHybridConfinement = func() bool { return hybrid }
+	return r
+}
+
+func MockCmdStartWait(start, wait func(cmd *exec.Cmd) error) func() {
+	r1 := testutil.Backup(&cmdStart)
+	r2 := testutil.Backup(&cmdWait)
+	cmdStart = start
+	cmdWait = wait
+	return func() {
+		r1()
+		r2()
+	}
+}
+
+func MockOsExit(f func(code int)) func() {
+	r := testutil.Backup(&osExit)
+	osExit = f
+	return r
+}
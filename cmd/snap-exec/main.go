@@ -22,7 +22,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"syscall"
 
@@ -31,6 +34,8 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sandbox"
+	"github.com/snapcore/snapd/sandbox/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapenv"
 )
@@ -39,11 +44,18 @@ import (
 var syscallExec = syscall.Exec
 var syscallStat = syscall.Stat
 var osReadlink = os.Readlink
+var landlockRestrictSelf = landlock.RestrictSelf
+var sandboxHybridConfinement = sandbox.HybridConfinement
+var cmdStart = (*exec.Cmd).Start
+var cmdWait = (*exec.Cmd).Wait
+var osExit = os.Exit
 
 // commandline args
 var opts struct {
 	Command string `long:"command" description:"use a different command like {stop,post-stop} from the app"`
 	Hook    string `long:"hook" description:"hook to run" hidden:"yes"`
+
+	InUserNamespace bool `long:"in-user-namespace" description:"already running in the user namespace of the application" hidden:"yes"`
 }
 
 func init() {
@@ -91,6 +103,19 @@ func run() error {
 	// confinement and (generally) can not talk to snapd
 	revision := os.Getenv("SNAP_REVISION")
 
+	// With hybrid confinement strictly confined applications run in their
+	// own user namespace. snap-exec is multi-threaded and cannot unshare
+	// it for itself, it runs again in a new user namespace instead.
+	if !opts.InUserNamespace && sandboxHybridConfinement() {
+		securityTag := snap.AppSecurityTag(snap.SplitSnapApp(snapApp))
+		if opts.Hook != "" {
+			securityTag = snap.HookSecurityTag(snapApp, opts.Hook)
+		}
+		if osutil.FileExists(filepath.Join(dirs.SnapLandlockDir, securityTag)) {
+			return runInUserNamespace(os.Args)
+		}
+	}
+
 	// Now actually handle the dispatching
 	if opts.Hook != "" {
 		return execHook(snapApp, revision, opts.Hook)
@@ -162,6 +187,94 @@ func completionHelper() (string, error) {
 	return filepath.Join(filepath.Dir(exe), "etelpmoc.sh"), nil
 }
 
+// restrictFileSystemAccess applies the landlock profile of the given security
+// tag, if there is one. Profiles are only written by snapd in the experimental
+// hybrid confinement mode, in which case failing to apply them is fatal.
+// $HOME in the profile refers to the home directory of the user and not to
+// the one of the snap.
+func restrictFileSystemAccess(securityTag string, env osutil.Environment) error {
+	f, err := os.Open(filepath.Join(dirs.SnapLandlockDir, securityTag))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot open landlock profile: %v", err)
+	}
+	defer f.Close()
+
+	rules, err := landlock.ReadProfile(f, func(name string) string {
+		if name == "HOME" {
+			return env["SNAP_REAL_HOME"]
+		}
+		return env[name]
+	})
+	if err != nil {
+		return fmt.Errorf("cannot read landlock profile %q: %v", f.Name(), err)
+	}
+	// landlock restricts the calling thread only, which must be the thread
+	// executing the application
+	runtime.LockOSThread()
+	return landlockRestrictSelf(rules)
+}
+
+// runInUserNamespace runs snap-exec again with the given arguments in a new
+// user namespace, in which the user and the group are mapped to themselves.
+// The application keeps its identity but has no capabilities over the
+// resources of the host. Once snap-exec in the user namespace is done, the
+// process exits with the same status.
+func runInUserNamespace(args []string) error {
+	uid := os.Getuid()
+	gid := os.Getgid()
+
+	cmd := exec.Command("/proc/self/exe", append([]string{"--in-user-namespace"}, args[1:]...)...)
+	cmd.Args[0] = args[0]
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}},
+		// only root may map groups without denying setgroups
+		GidMappingsEnableSetgroups: uid == 0,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+	// the parent death signal is tied to the thread starting the process
+	runtime.LockOSThread()
+	if err := cmdStart(cmd); err != nil {
+		return fmt.Errorf("cannot run in a new user namespace: %v", err)
+	}
+
+	// signals generated by the terminal reach the application directly,
+	// others, eg. from systemd, are forwarded
+	signal.Ignore(syscall.SIGINT, syscall.SIGQUIT)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range sigs {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmdWait(cmd)
+	signal.Stop(sigs)
+	close(sigs)
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		status := exitErr.ExitCode()
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			status = 128 + int(ws.Signal())
+		}
+		osExit(status)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	osExit(0)
+	// this is never reached except in tests
+	return nil
+}
+
 func execApp(snapApp, revision, command string, args []string) error {
 	rev, err := snap.ParseRevision(revision)
 	if err != nil {
@@ -247,6 +360,10 @@ func execApp(snapApp, revision, command string, args []string) error {
 
 	fullCmd = append(absoluteCommandChain(app.Snap, app.CommandChain), fullCmd...)
 
+	if err := restrictFileSystemAccess(app.SecurityTag(), env); err != nil {
+		return err
+	}
+
 	logger.StartupStageTimestamp("snap-exec to app")
 	if err := syscallExec(fullCmd[0], fullCmd, env.ForExec()); err != nil {
 		return fmt.Errorf("cannot exec %q: %s", fullCmd[0], err)
@@ -285,6 +402,10 @@ func execHook(snapName, revision, hookName string) error {
 		env.ExtendWithExpanded(eenv)
 	}
 
+	if err := restrictFileSystemAccess(hook.SecurityTag(), env); err != nil {
+		return err
+	}
+
 	// run the hook
 	cmd := append(absoluteCommandChain(hook.Snap, hook.CommandChain), filepath.Join(hook.Snap.HooksDir(), hook.Name))
 	return syscallExec(cmd[0], cmd, env.ForExec())
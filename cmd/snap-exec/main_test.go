@@ -35,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/sandbox/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -51,6 +52,7 @@ func (s *snapExecSuite) SetUpTest(c *C) {
 	// clean previous parse runs
 	snapExec.SetOptsCommand("")
 	snapExec.SetOptsHook("")
+	snapExec.SetOptsInUserNamespace(false)
 }
 
 func (s *snapExecSuite) TearDown(c *C) {
@@ -294,6 +296,234 @@ func (s *snapExecSuite) TestSnapExecHookIntegration(c *C) {
 	c.Check(execArgs, DeepEquals, []string{execArgv0})
 }
 
+func (s *snapExecSuite) TestSnapExecAppLandlockProfile(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	snaptest.MockSnap(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+	c.Assert(os.MkdirAll(dirs.SnapLandlockDir, 0755), IsNil)
+	profile := filepath.Join(dirs.SnapLandlockDir, "snap.snapname.app")
+	c.Assert(ioutil.WriteFile(profile, []byte("ro /\nrw $TEST_PATH\nrw $UNSET\n"), 0644), IsNil)
+
+	var calls []string
+	restore := snapExec.MockLandlockRestrictSelf(func(rules []landlock.Rule) error {
+		calls = append(calls, "restrict")
+		c.Check(rules, DeepEquals, []landlock.Rule{
+			{Path: "/", Access: landlock.ReadOnly},
+			{Path: "/custom", Access: landlock.ReadWrite},
+		})
+		return nil
+	})
+	defer restore()
+	restore = snapExec.MockSyscallExec(func(argv0 string, argv []string, env []string) error {
+		calls = append(calls, "exec")
+		return nil
+	})
+	defer restore()
+
+	err := snapExec.ExecApp("snapname.app", "42", "", nil)
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"restrict", "exec"})
+
+	// failing to apply the profile is fatal
+	calls = nil
+	restore = snapExec.MockLandlockRestrictSelf(func(rules []landlock.Rule) error {
+		return fmt.Errorf("boom")
+	})
+	defer restore()
+	err = snapExec.ExecApp("snapname.app", "42", "", nil)
+	c.Assert(err, ErrorMatches, "boom")
+	c.Check(calls, HasLen, 0)
+
+	// and so is a broken profile
+	c.Assert(ioutil.WriteFile(profile, []byte("rw relative\n"), 0644), IsNil)
+	err = snapExec.ExecApp("snapname.app", "42", "", nil)
+	c.Assert(err, ErrorMatches, `cannot read landlock profile ".*/snap.snapname.app": .*`)
+	c.Check(calls, HasLen, 0)
+}
+
+func (s *snapExecSuite) TestSnapExecAppLandlockProfileRealHome(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	snaptest.MockSnap(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+	c.Assert(os.MkdirAll(dirs.SnapLandlockDir, 0755), IsNil)
+	profile := filepath.Join(dirs.SnapLandlockDir, "snap.snapname.app")
+	c.Assert(ioutil.WriteFile(profile, []byte("rw $SNAP_USER_DATA\nrw $HOME\n"), 0644), IsNil)
+
+	for k, v := range map[string]string{
+		"HOME":           "/home/user/snap/snapname/42",
+		"SNAP_USER_DATA": "/home/user/snap/snapname/42",
+		"SNAP_REAL_HOME": "/home/user",
+	} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+
+	restore := snapExec.MockLandlockRestrictSelf(func(rules []landlock.Rule) error {
+		c.Check(rules, DeepEquals, []landlock.Rule{
+			{Path: "/home/user/snap/snapname/42", Access: landlock.ReadWrite},
+			{Path: "/home/user", Access: landlock.ReadWrite},
+		})
+		return nil
+	})
+	defer restore()
+	restore = snapExec.MockSyscallExec(func(argv0 string, argv []string, env []string) error {
+		return nil
+	})
+	defer restore()
+
+	err := snapExec.ExecApp("snapname.app", "42", "", nil)
+	c.Assert(err, IsNil)
+
+	// without the real home the rule is dropped
+	os.Unsetenv("SNAP_REAL_HOME")
+	restore = snapExec.MockLandlockRestrictSelf(func(rules []landlock.Rule) error {
+		c.Check(rules, DeepEquals, []landlock.Rule{
+			{Path: "/home/user/snap/snapname/42", Access: landlock.ReadWrite},
+		})
+		return nil
+	})
+	defer restore()
+	err = snapExec.ExecApp("snapname.app", "42", "", nil)
+	c.Assert(err, IsNil)
+}
+
+func (s *snapExecSuite) TestSnapExecHookLandlockProfile(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	snaptest.MockSnap(c, string(mockHookYaml), &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+	c.Assert(os.MkdirAll(dirs.SnapLandlockDir, 0755), IsNil)
+	profile := filepath.Join(dirs.SnapLandlockDir, "snap.snapname.hook.configure")
+	c.Assert(ioutil.WriteFile(profile, []byte("ro /\n"), 0644), IsNil)
+
+	var calls []string
+	restore := snapExec.MockLandlockRestrictSelf(func(rules []landlock.Rule) error {
+		calls = append(calls, "restrict")
+		c.Check(rules, DeepEquals, []landlock.Rule{{Path: "/", Access: landlock.ReadOnly}})
+		return nil
+	})
+	defer restore()
+	restore = snapExec.MockSyscallExec(func(argv0 string, argv []string, env []string) error {
+		calls = append(calls, "exec")
+		return nil
+	})
+	defer restore()
+
+	err := snapExec.ExecHook("snapname", "42", "configure")
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"restrict", "exec"})
+}
+
+func (s *snapExecSuite) TestSnapExecRunsInUserNamespaceWithHybridConfinement(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	oldOsArgs := os.Args
+	defer func() { os.Args = oldOsArgs }()
+	os.Setenv("SNAP_REVISION", "42")
+	defer os.Unsetenv("SNAP_REVISION")
+
+	snaptest.MockSnap(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+	c.Assert(os.MkdirAll(dirs.SnapLandlockDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapLandlockDir, "snap.snapname.app"), []byte("ro /usr\n"), 0644), IsNil)
+
+	restore := snapExec.MockSandboxHybridConfinement(true)
+	defer restore()
+	restore = snapExec.MockLandlockRestrictSelf(func(rules []landlock.Rule) error {
+		return nil
+	})
+	defer restore()
+	var calls []string
+	restore = snapExec.MockSyscallExec(func(argv0 string, argv []string, env []string) error {
+		calls = append(calls, "exec "+argv0)
+		return nil
+	})
+	defer restore()
+	var started *exec.Cmd
+	restore = snapExec.MockCmdStartWait(func(cmd *exec.Cmd) error {
+		started = cmd
+		calls = append(calls, "start")
+		return nil
+	}, func(cmd *exec.Cmd) error {
+		calls = append(calls, "wait")
+		return nil
+	})
+	defer restore()
+	restore = snapExec.MockOsExit(func(code int) {
+		calls = append(calls, fmt.Sprintf("exit %d", code))
+	})
+	defer restore()
+
+	os.Args = []string{"snap-exec", "snapname.app", "foo"}
+	err := snapExec.Run()
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"start", "wait", "exit 0"})
+	c.Assert(started, NotNil)
+	c.Check(started.Path, Equals, "/proc/self/exe")
+	c.Check(started.Args, DeepEquals, []string{"snap-exec", "--in-user-namespace", "snapname.app", "foo"})
+	c.Check(started.SysProcAttr.Cloneflags, Equals, uintptr(syscall.CLONE_NEWUSER))
+	uid, gid := os.Getuid(), os.Getgid()
+	c.Check(started.SysProcAttr.UidMappings, DeepEquals, []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}})
+	c.Check(started.SysProcAttr.GidMappings, DeepEquals, []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}})
+
+	// in the user namespace the application is executed
+	calls = nil
+	os.Args = []string{"snap-exec", "--in-user-namespace", "snapname.app", "foo"}
+	err = snapExec.Run()
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{fmt.Sprintf("exec %s/snapname/42/run-app", dirs.SnapMountDir)})
+
+	// failing to create the user namespace is fatal
+	snapExec.SetOptsInUserNamespace(false)
+	calls = nil
+	restore = snapExec.MockCmdStartWait(func(cmd *exec.Cmd) error {
+		return fmt.Errorf("operation not permitted")
+	}, nil)
+	defer restore()
+	os.Args = []string{"snap-exec", "snapname.app"}
+	err = snapExec.Run()
+	c.Assert(err, ErrorMatches, "cannot run in a new user namespace: operation not permitted")
+	c.Check(calls, HasLen, 0)
+}
+
+func (s *snapExecSuite) TestSnapExecNoUserNamespaceWithoutProfile(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	oldOsArgs := os.Args
+	defer func() { os.Args = oldOsArgs }()
+	os.Setenv("SNAP_REVISION", "42")
+	defer os.Unsetenv("SNAP_REVISION")
+
+	snaptest.MockSnap(c, string(mockHookYaml), &snap.SideInfo{
+		Revision: snap.R("42"),
+	})
+
+	restore := snapExec.MockSandboxHybridConfinement(true)
+	defer restore()
+	restore = snapExec.MockCmdStartWait(func(cmd *exec.Cmd) error {
+		c.Fatalf("unexpected user namespace")
+		return nil
+	}, nil)
+	defer restore()
+	var calls []string
+	restore = snapExec.MockSyscallExec(func(argv0 string, argv []string, env []string) error {
+		calls = append(calls, "exec")
+		return nil
+	})
+	defer restore()
+
+	// devmode and classic snaps have no landlock profiles
+	os.Args = []string{"snap-exec", "--hook=configure", "snapname"}
+	err := snapExec.Run()
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"exec"})
+}
+
 func (s *snapExecSuite) TestSnapExecHookCommandChainIntegration(c *C) {
 	dirs.SetRootDir(c.MkDir())
 	snaptest.MockSnap(c, string(mockHookCommandChainYaml), &snap.SideInfo{
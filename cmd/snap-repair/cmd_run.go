@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snapdenv"
+	"github.com/snapcore/snapd/strutil"
 )
 
 func init() {
@@ -92,9 +93,16 @@ func (c *cmdRun) Execute(args []string) error {
 		return err
 	}
 
-	for _, rootRepairBrandID := range rootBrandIDs {
+	// besides the root brands repairs, the brand of the device can
+	// issue its own ones
+	brandIDs := rootBrandIDs
+	if deviceBrandID := run.state.Device.Brand; deviceBrandID != "" && !strutil.ListContains(rootBrandIDs, deviceBrandID) {
+		brandIDs = append(brandIDs[:len(brandIDs):len(brandIDs)], deviceBrandID)
+	}
+
+	for _, repairBrandID := range brandIDs {
 		for {
-			repair, err := run.Next(rootRepairBrandID)
+			repair, err := run.Next(repairBrandID)
 			if err == ErrRepairNotFound {
 				// no more repairs
 				break
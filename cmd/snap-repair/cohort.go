@@ -0,0 +1,153 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// findSerial returns the serial of the device from the system assertion
+// database, or the empty string if the device has no serial yet.
+func findSerial(brandID, model string) (string, error) {
+	if brandID == "" || !osutil.IsDirectory(dirs.SnapAssertsDBDir) {
+		return "", nil
+	}
+	bs, err := asserts.OpenFSBackstore(dirs.SnapAssertsDBDir)
+	if err != nil {
+		return "", err
+	}
+	var serial *asserts.Serial
+	err = bs.Search(asserts.SerialType, map[string]string{
+		"brand-id": brandID,
+		"model":    model,
+	}, func(a asserts.Assertion) {
+		s := a.(*asserts.Serial)
+		if serial == nil || s.Timestamp().After(serial.Timestamp()) {
+			serial = s
+		}
+	}, asserts.SerialType.MaxSupportedFormat())
+	if err != nil {
+		return "", err
+	}
+	if serial == nil {
+		return "", nil
+	}
+	return serial.Serial(), nil
+}
+
+// compareSerials orders serials sorting shorter ones first, which orders
+// numeric serials by their value.
+func compareSerials(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// serialMatches returns whether the serial matches any of the given
+// serials, serial prefixes or ranges of serials.
+func serialMatches(patterns []string, serial string) bool {
+	for _, patt := range patterns {
+		switch {
+		case strings.Contains(patt, ".."):
+			l := strings.SplitN(patt, "..", 2)
+			if compareSerials(l[0], serial) <= 0 && compareSerials(serial, l[1]) <= 0 {
+				return true
+			}
+		case strings.HasSuffix(patt, "*"):
+			if strings.HasPrefix(serial, strings.TrimSuffix(patt, "*")) {
+				return true
+			}
+		case patt == serial:
+			return true
+		}
+	}
+	return false
+}
+
+// deviceRolloutID returns what identifies the device for placing it in
+// the rollout of repairs, the serial if the device has one or its machine
+// id.
+func (run *Runner) deviceRolloutID() string {
+	if run.serial != "" {
+		return run.serial
+	}
+	machineID, err := ioutil.ReadFile(filepath.Join(dirs.GlobalRootDir, "/etc/machine-id"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Noticef("cannot read machine id: %v", err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(machineID))
+}
+
+// inRollout returns whether the device is part of the share of devices the
+// repair with the given headers is currently rolled out to. Devices are
+// placed stably in the rollout of each repair, such that they stay part of
+// it as the rollout percentage grows.
+func (run *Runner) inRollout(brandID string, repairID int, headers map[string]interface{}) bool {
+	percentage := 100
+	if v, ok := headers["rollout-percentage"].(string); ok {
+		p, err := strconv.Atoi(v)
+		if err != nil {
+			return false
+		}
+		percentage = p
+	}
+	if percentage >= 100 {
+		return true
+	}
+	if percentage <= 0 {
+		return false
+	}
+	id := run.deviceRolloutID()
+	if id == "" {
+		return false
+	}
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%s", brandID, repairID, id)))
+	return int(binary.BigEndian.Uint32(h[:4])%100) < percentage
+}
+
+// pending returns whether a repair applicable to the device cannot run yet,
+// because it is not rolled out to the device yet or it targets serials
+// and the device got no serial yet. A pending repair is considered again on
+// the next runs.
+func (run *Runner) pending(brandID string, repairID int, headers map[string]interface{}) bool {
+	if _, ok := headers["serials"]; ok && run.serial == "" {
+		return true
+	}
+	return !run.inRollout(brandID, repairID, headers)
+}
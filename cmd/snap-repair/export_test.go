@@ -99,6 +99,20 @@ func (run *Runner) SetBrandModel(brand, model string) {
 	run.state.Device.Model = model
 }
 
+func (run *Runner) SetGrade(grade string) {
+	run.state.Device.Grade = grade
+}
+
+func (run *Runner) Serial() string {
+	return run.serial
+}
+
+func (run *Runner) SetSerial(serial string) {
+	run.serial = serial
+}
+
+var SerialMatches = serialMatches
+
 func (run *Runner) TimeLowerBound() time.Time {
 	return run.state.TimeLowerBound
 }
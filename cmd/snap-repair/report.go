@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/snapcore/snapd/snapdenv"
+)
+
+// repairResult is what is reported about running a repair.
+type repairResult struct {
+	BrandID   string    `json:"brand-id"`
+	RepairID  int       `json:"repair-id"`
+	Revision  int       `json:"revision"`
+	Status    string    `json:"status"`
+	Model     string    `json:"model"`
+	Serial    string    `json:"serial,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// reportResult reports the result of running the given repair to the
+// endpoint set in its report-url header, if any. This allows e.g. the
+// management service of a fleet of devices to follow the rollout of the
+// repairs of its brand.
+func (run *Runner) reportResult(r *Repair, status RepairStatus) error {
+	reportURL := r.ReportURL()
+	if reportURL == "" {
+		return nil
+	}
+	result := repairResult{
+		BrandID:   r.BrandID(),
+		RepairID:  r.RepairID(),
+		Revision:  r.Revision(),
+		Status:    status.String(),
+		Model:     fmt.Sprintf("%s/%s", run.state.Device.Brand, run.state.Device.Model),
+		Serial:    run.serial,
+		Timestamp: run.now(),
+	}
+	body, err := json.Marshal(&result)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", reportURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", snapdenv.UserAgent())
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := run.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
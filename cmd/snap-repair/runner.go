@@ -231,6 +231,10 @@ func (r *Repair) Run() error {
 	}
 	r.SetStatus(status)
 
+	if err := r.run.reportResult(r, status); err != nil {
+		logger.Noticef("cannot report result of repair %s: %v", r, err)
+	}
+
 	return nil
 }
 
@@ -282,6 +286,9 @@ type Runner struct {
 	state         state
 	stateModified bool
 
+	// serial is the serial of the device, if it has one already.
+	serial string
+
 	// sequenceNext keeps track of the next integer id in a brand sequence to considered in this run, see Next.
 	sequenceNext map[string]int
 }
@@ -503,6 +510,7 @@ type deviceInfo struct {
 	Model string `json:"model"`
 	Base  string `json:"base"`
 	Mode  string `json:"mode"`
+	Grade string `json:"grade,omitempty"`
 }
 
 // RepairStatus represents the possible statuses of a repair.
@@ -733,11 +741,15 @@ func findDevInfo20() (*deviceInfo, error) {
 		return nil, err
 	}
 
+	// the grade is missing in the modeenv of older systems
+	grade, _ := cfg.Get("", "grade")
+
 	return &deviceInfo{
 		Brand: l[0],
 		Model: l[1],
 		Base:  baseSn.SnapName(),
 		Mode:  mode,
+		Grade: grade,
 	}, nil
 }
 
@@ -803,10 +815,16 @@ func findDevInfo16() (*deviceInfo, error) {
 		base = "core"
 	}
 
+	var grade string
+	if modelAs.Grade() != asserts.ModelGradeUnset {
+		grade = string(modelAs.Grade())
+	}
+
 	return &deviceInfo{
 		Brand: modelAs.BrandID(),
 		Model: modelAs.Model(),
 		Base:  base,
+		Grade: grade,
 		// Mode is unset on uc16/uc18
 	}, nil
 }
@@ -824,14 +842,21 @@ func (run *Runner) initDeviceInfo() error {
 // LoadState loads the repairs' state from disk, and (re)initializes it if it's missing or corrupted.
 func (run *Runner) LoadState() error {
 	err := run.readState()
-	if err == nil {
-		return nil
+	if err != nil {
+		// error => initialize from scratch
+		if !os.IsNotExist(err) {
+			logger.Noticef("cannor read repair state: %v", err)
+		}
+		if err := run.initState(); err != nil {
+			return err
+		}
 	}
-	// error => initialize from scratch
-	if !os.IsNotExist(err) {
-		logger.Noticef("cannor read repair state: %v", err)
+	// the device may have got its serial since the last run
+	run.serial, err = findSerial(run.state.Device.Brand, run.state.Device.Model)
+	if err != nil {
+		logger.Noticef("cannot find device serial: %v", err)
 	}
-	return run.initState()
+	return nil
 }
 
 // SaveState saves the repairs' state to disk.
@@ -911,6 +936,23 @@ func (run *Runner) Applicable(headers map[string]interface{}) bool {
 		}
 	}
 
+	// filter by cohorts of devices, a device that has no serial yet
+	// can only be excluded later
+	grades, err := stringList(headers, "grades")
+	if err != nil {
+		return false
+	}
+	if len(grades) != 0 && !strutil.ListContains(grades, run.state.Device.Grade) {
+		return false
+	}
+	serials, err := stringList(headers, "serials")
+	if err != nil {
+		return false
+	}
+	if len(serials) != 0 && run.serial != "" && !serialMatches(serials, run.serial) {
+		return false
+	}
+
 	// also filter by base snaps and modes
 	bases, err := stringList(headers, "bases")
 	if err != nil {
@@ -1069,6 +1111,12 @@ func (run *Runner) makeReady(brandID string, sequenceNext int) (repair *asserts.
 		run.setRepairState(brandID, state)
 		return nil, errSkip
 	}
+	if run.pending(brandID, state.Sequence, repair.Headers()) {
+		// consider it again on the next runs
+		state.Status = RetryStatus
+		run.setRepairState(brandID, state)
+		return nil, errSkip
+	}
 	run.setRepairState(brandID, state)
 	return repair, nil
 }
@@ -1138,12 +1186,16 @@ func (run *Runner) Verify(repair *asserts.Repair, aux []asserts.Assertion) error
 	for _, t := range trustedRepairRootKeys {
 		trustedBS.Put(asserts.AccountKeyType, t)
 	}
+	// the repairs of brands other than the root ones are signed by
+	// the brand, with keys ultimately signed by the default sysdb
+	// trusted account keys
+	brandRepair := !strutil.ListContains(rootBrandIDs, repair.BrandID())
 	for _, t := range sysdb.Trusted() {
 		// we do *not* add the defalt sysdb trusted account
-		// keys here because the repair assertions have their
-		// own *dedicated* root of trust
-		if t.Type() == asserts.AccountType {
-			trustedBS.Put(asserts.AccountType, t)
+		// keys here for the root brands because their repair
+		// assertions have their own *dedicated* root of trust
+		if t.Type() == asserts.AccountType || brandRepair {
+			trustedBS.Put(t.Type(), t)
 		}
 	}
 
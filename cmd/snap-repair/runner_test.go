@@ -783,6 +783,259 @@ func (s *runnerSuite) TestApplicable(c *C) {
 	}
 }
 
+func (s *runnerSuite) TestApplicableCohorts(c *C) {
+	scenarios := []struct {
+		grade, serial string
+		headers       map[string]interface{}
+		applicable    bool
+	}{
+		{"", "", map[string]interface{}{"grades": []interface{}{"dangerous"}}, false},
+		{"signed", "", map[string]interface{}{"grades": []interface{}{"dangerous"}}, false},
+		{"signed", "", map[string]interface{}{"grades": []interface{}{"dangerous", "signed"}}, true},
+		{"signed", "", map[string]interface{}{"grades": "signed"}, false},
+		// without a serial the device can only be excluded later
+		{"", "", map[string]interface{}{"serials": []interface{}{"1000"}}, true},
+		{"", "1000", map[string]interface{}{"serials": []interface{}{"1000"}}, true},
+		{"", "1001", map[string]interface{}{"serials": []interface{}{"1000"}}, false},
+		{"", "1001", map[string]interface{}{"serials": []interface{}{"1000", "1000..1999"}}, true},
+		{"", "1001", map[string]interface{}{"serials": "1001"}, false},
+		{"secured", "abc-1", map[string]interface{}{"serials": []interface{}{"abc*"}, "grades": []interface{}{"secured"}}, true},
+		{"signed", "abc-1", map[string]interface{}{"serials": []interface{}{"abc*"}, "grades": []interface{}{"secured"}}, false},
+	}
+
+	for _, scen := range scenarios {
+		s.freshState(c)
+
+		runner := repair.NewRunner()
+		err := runner.LoadState()
+		c.Assert(err, IsNil)
+		runner.SetGrade(scen.grade)
+		runner.SetSerial(scen.serial)
+
+		ok := runner.Applicable(scen.headers)
+		c.Check(ok, Equals, scen.applicable, Commentf("%v", scen))
+	}
+}
+
+func (s *runnerSuite) TestSerialMatches(c *C) {
+	tests := []struct {
+		patterns []string
+		serial   string
+		matches  bool
+	}{
+		{nil, "1000", false},
+		{[]string{"1000"}, "1000", true},
+		{[]string{"1000"}, "10000", false},
+		{[]string{"10*"}, "10000", true},
+		{[]string{"10*"}, "2000", false},
+		{[]string{"900..1100"}, "900", true},
+		{[]string{"900..1100"}, "1000", true},
+		{[]string{"900..1100"}, "1100", true},
+		{[]string{"900..1100"}, "899", false},
+		{[]string{"900..1100"}, "1101", false},
+		// shorter serials sort first
+		{[]string{"900..1100"}, "95", false},
+		{[]string{"900..1100"}, "10000", false},
+		{[]string{"aa..az"}, "am", true},
+		{[]string{"aa..az", "b*"}, "bz", true},
+	}
+	for _, t := range tests {
+		c.Check(repair.SerialMatches(t.patterns, t.serial), Equals, t.matches, Commentf("%v", t))
+	}
+}
+
+func (s *runnerSuite) TestNextPendingRetried(c *C) {
+	seqRepairs := []string{`type: repair
+authority-id: canonical
+brand-id: canonical
+repair-id: 1
+summary: repair one not rolled out yet
+rollout-percentage: 0
+timestamp: 2017-07-02T12:00:00Z
+body-length: 8
+sign-key-sha3-384: KPIl7M4vQ9d4AUjkoU41TGAwtOMLc_bWUCeW8AvdRWD4_xcP60Oo4ABsFNo6BtXj
+
+scriptA
+
+
+AXNpZw==`, `type: repair
+authority-id: canonical
+brand-id: canonical
+repair-id: 2
+summary: repair two for serials
+serials:
+  - 1000..1999
+timestamp: 2017-07-02T12:00:00Z
+body-length: 8
+sign-key-sha3-384: KPIl7M4vQ9d4AUjkoU41TGAwtOMLc_bWUCeW8AvdRWD4_xcP60Oo4ABsFNo6BtXj
+
+scriptB
+
+
+AXNpZw==`}
+
+	r1 := sysdb.InjectTrusted(s.storeSigning.Trusted)
+	defer r1()
+	r2 := repair.MockTrustedRepairRootKeys([]*asserts.AccountKey{s.repairRootAcctKey})
+	defer r2()
+
+	seqRepairs = s.signSeqRepairs(c, seqRepairs)
+
+	mockServer := makeMockServer(c, &seqRepairs, false)
+	defer mockServer.Close()
+
+	runner := repair.NewRunner()
+	runner.BaseURL = mustParseURL(mockServer.URL)
+	runner.LoadState()
+	c.Check(runner.Serial(), Equals, "")
+
+	// pending => not returned but kept for the next runs
+	_, err := runner.Next("canonical")
+	c.Check(err, Equals, repair.ErrRepairNotFound)
+
+	expectedSeq := []*repair.RepairState{
+		{Sequence: 1, Revision: 0, Status: repair.RetryStatus},
+		{Sequence: 2, Revision: 0, Status: repair.RetryStatus},
+	}
+	c.Check(runner.Sequence("canonical"), DeepEquals, expectedSeq)
+	// on disk
+	seqs := s.loadSequences(c)
+	c.Check(seqs["canonical"], DeepEquals, expectedSeq)
+
+	// the device got a serial meanwhile
+	runner = repair.NewRunner()
+	runner.BaseURL = mustParseURL(mockServer.URL)
+	runner.LoadState()
+	runner.SetSerial("1234")
+
+	rpr, err := runner.Next("canonical")
+	c.Assert(err, IsNil)
+	c.Check(rpr.RepairID(), Equals, 2)
+
+	_, err = runner.Next("canonical")
+	c.Check(err, Equals, repair.ErrRepairNotFound)
+}
+
+func (s *runnerSuite) TestRepairRunReportsResult(c *C) {
+	seqRepairs := []string{`type: repair
+authority-id: canonical
+brand-id: canonical
+repair-id: 1
+summary: repair one
+report-url: REPORT-URL
+timestamp: 2017-07-02T12:00:00Z
+body-length: 17
+sign-key-sha3-384: KPIl7M4vQ9d4AUjkoU41TGAwtOMLc_bWUCeW8AvdRWD4_xcP60Oo4ABsFNo6BtXj
+
+#!/bin/sh
+exit 0
+
+
+AXNpZw==`}
+
+	r1 := sysdb.InjectTrusted(s.storeSigning.Trusted)
+	defer r1()
+	r2 := repair.MockTrustedRepairRootKeys([]*asserts.AccountKey{s.repairRootAcctKey})
+	defer r2()
+
+	n := 0
+	reportServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/report")
+		c.Check(r.Header.Get("Content-Type"), Equals, "application/json")
+		c.Check(strings.Contains(r.Header.Get("User-Agent"), "snap-repair"), Equals, true)
+
+		var result map[string]interface{}
+		c.Assert(json.NewDecoder(r.Body).Decode(&result), IsNil)
+		c.Check(result["timestamp"], NotNil)
+		delete(result, "timestamp")
+		c.Check(result, DeepEquals, map[string]interface{}{
+			"brand-id":  "canonical",
+			"repair-id": float64(1),
+			"revision":  float64(0),
+			"status":    "retry",
+			"model":     "my-brand/my-model",
+			"serial":    "1234",
+		})
+		w.WriteHeader(202)
+	}))
+	defer reportServer.Close()
+
+	seqRepairs[0] = strings.Replace(seqRepairs[0], "REPORT-URL", reportServer.URL+"/report", 1)
+	seqRepairs = s.signSeqRepairs(c, seqRepairs)
+
+	mockServer := makeMockServer(c, &seqRepairs, false)
+	defer mockServer.Close()
+
+	s.freshState(c)
+
+	runner := repair.NewRunner()
+	runner.BaseURL = mustParseURL(mockServer.URL)
+	runner.LoadState()
+	runner.SetSerial("1234")
+
+	rpr, err := runner.Next("canonical")
+	c.Assert(err, IsNil)
+
+	err = rpr.Run()
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 1)
+}
+
+func (s *runnerSuite) TestRepairRunReportFailureIgnored(c *C) {
+	seqRepairs := []string{`type: repair
+authority-id: canonical
+brand-id: canonical
+repair-id: 1
+summary: repair one
+report-url: REPORT-URL
+timestamp: 2017-07-02T12:00:00Z
+body-length: 17
+sign-key-sha3-384: KPIl7M4vQ9d4AUjkoU41TGAwtOMLc_bWUCeW8AvdRWD4_xcP60Oo4ABsFNo6BtXj
+
+#!/bin/sh
+exit 0
+
+
+AXNpZw==`}
+
+	r1 := sysdb.InjectTrusted(s.storeSigning.Trusted)
+	defer r1()
+	r2 := repair.MockTrustedRepairRootKeys([]*asserts.AccountKey{s.repairRootAcctKey})
+	defer r2()
+
+	reportServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer reportServer.Close()
+
+	seqRepairs[0] = strings.Replace(seqRepairs[0], "REPORT-URL", reportServer.URL, 1)
+	seqRepairs = s.signSeqRepairs(c, seqRepairs)
+
+	mockServer := makeMockServer(c, &seqRepairs, false)
+	defer mockServer.Close()
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	s.freshState(c)
+
+	runner := repair.NewRunner()
+	runner.BaseURL = mustParseURL(mockServer.URL)
+	runner.LoadState()
+
+	rpr, err := runner.Next("canonical")
+	c.Assert(err, IsNil)
+
+	err = rpr.Run()
+	c.Assert(err, IsNil)
+	c.Check(logbuf.String(), Matches, `(?s).*cannot report result of repair canonical-1: unexpected status code 500.*`)
+	c.Check(runner.Sequence("canonical"), DeepEquals, []*repair.RepairState{
+		{Sequence: 1, Status: repair.RetryStatus},
+	})
+}
+
 var (
 	nextRepairs = []string{`type: repair
 authority-id: canonical
@@ -882,7 +1135,12 @@ func makeMockServer(c *C, seqRepairs *[]string, redirectFirst bool) *httptest.Se
 			urlPath = strings.TrimPrefix(urlPath, "/final")
 		}
 
-		c.Check(strings.HasPrefix(urlPath, "/repairs/canonical/"), Equals, true)
+		c.Check(strings.HasPrefix(urlPath, "/repairs/"), Equals, true)
+		if !strings.HasPrefix(urlPath, "/repairs/canonical/") {
+			// the brand of the device has no repairs
+			w.WriteHeader(404)
+			return
+		}
 
 		seq, err := strconv.Atoi(strings.TrimPrefix(urlPath, "/repairs/canonical/"))
 		c.Assert(err, IsNil)
@@ -0,0 +1,182 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+// bundleManifestName is the name of the manifest describing the content of
+// a snap bundle.
+const bundleManifestName = "bundle.yaml"
+
+// bundleManifest describes the content of a snap bundle, a tar archive
+// carrying several snaps together with their assertions, to be installed
+// in one go.
+type bundleManifest struct {
+	// Assertions lists the assertion files of the bundle, in the order
+	// they must be acknowledged.
+	Assertions []string `yaml:"assertions,omitempty"`
+	// Snaps lists the snap files of the bundle, in install order.
+	Snaps []string `yaml:"snaps"`
+	// Connections lists the connections to make once the snaps are
+	// installed.
+	Connections []bundleConnection `yaml:"connections,omitempty"`
+}
+
+// bundleConnection describes a connection to make after installing the
+// snaps of a bundle. The plug is given as <snap>:<plug>, the slot as
+// <snap>:<slot> or <snap>, or it can be left empty to connect to the
+// system slot with the same name as the plug.
+type bundleConnection struct {
+	Plug string `yaml:"plug"`
+	Slot string `yaml:"slot,omitempty"`
+}
+
+// bundle is a snap bundle unpacked into a directory.
+type bundle struct {
+	dir         string
+	manifest    bundleManifest
+	plugs       []SnapAndName
+	slots       []SnapAndName
+	snapPaths   []string
+	assertPaths []string
+}
+
+// unpackBundle unpacks the snap bundle at the given path into dir and
+// validates its manifest.
+func unpackBundle(path, dir string) (*bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot open bundle: %v"), err)
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf(i18n.G("cannot read bundle: %v"), err)
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if hdr.Typeflag == tar.TypeDir && (name == "" || name == ".") {
+			continue
+		}
+		// bundles are flat, only regular files at the top level are
+		// expected
+		if hdr.Typeflag != tar.TypeReg || name == "" || strings.Contains(name, "/") || name == ".." {
+			return nil, fmt.Errorf(i18n.G("cannot read bundle: unexpected entry %q"), hdr.Name)
+		}
+		if err := extractBundleFile(tr, filepath.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf(i18n.G("cannot read bundle: %v"), err)
+		}
+	}
+
+	b := &bundle{dir: dir}
+	data, err := ioutil.ReadFile(filepath.Join(dir, bundleManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf(i18n.G("cannot read bundle: missing %s"), bundleManifestName)
+		}
+		return nil, fmt.Errorf(i18n.G("cannot read bundle manifest: %v"), err)
+	}
+	if err := yaml.UnmarshalStrict(data, &b.manifest); err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot read bundle manifest: %v"), err)
+	}
+	if err := b.validate(); err != nil {
+		return nil, fmt.Errorf(i18n.G("invalid bundle manifest: %v"), err)
+	}
+	return b, nil
+}
+
+func extractBundleFile(r io.Reader, target string) error {
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (b *bundle) validate() error {
+	if len(b.manifest.Snaps) == 0 {
+		return fmt.Errorf(i18n.G("no snaps listed"))
+	}
+	seen := make(map[string]bool)
+	bundlePath := func(name string) (string, error) {
+		if name == "" || name == bundleManifestName || strings.Contains(name, "/") {
+			return "", fmt.Errorf(i18n.G("invalid file name %q"), name)
+		}
+		if seen[name] {
+			return "", fmt.Errorf(i18n.G("file %q listed more than once"), name)
+		}
+		seen[name] = true
+		path := filepath.Join(b.dir, name)
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf(i18n.G("file %q not found in bundle"), name)
+		}
+		return path, nil
+	}
+	for _, name := range b.manifest.Assertions {
+		path, err := bundlePath(name)
+		if err != nil {
+			return err
+		}
+		b.assertPaths = append(b.assertPaths, path)
+	}
+	for _, name := range b.manifest.Snaps {
+		path, err := bundlePath(name)
+		if err != nil {
+			return err
+		}
+		b.snapPaths = append(b.snapPaths, path)
+	}
+	for _, conn := range b.manifest.Connections {
+		var plug SnapAndNameStrict
+		if err := plug.UnmarshalFlag(conn.Plug); err != nil || plug.Snap == "" {
+			return fmt.Errorf(i18n.G("invalid plug %q in connection (want snap:plug)"), conn.Plug)
+		}
+		var slot SnapAndName
+		if conn.Slot != "" {
+			if err := slot.UnmarshalFlag(conn.Slot); err != nil {
+				return fmt.Errorf(i18n.G("invalid slot %q in connection (want snap:slot or snap)"), conn.Slot)
+			}
+		}
+		b.plugs = append(b.plugs, plug.SnapAndName)
+		b.slots = append(b.slots, slot)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdAliasSet struct {
+	waitMixin
+	enable      bool
+	Positionals struct {
+		AliasSet string `required:"yes"`
+	} `positional-args:"true"`
+}
+
+var shortEnableAliasSetHelp = i18n.G("Enable the aliases of an alias set of a snap")
+var longEnableAliasSetHelp = i18n.G(`
+The enable-alias-set command enables all the aliases that the given snap
+groups in the given alias set.
+`)
+
+var shortDisableAliasSetHelp = i18n.G("Disable the aliases of an alias set of a snap")
+var longDisableAliasSetHelp = i18n.G(`
+The disable-alias-set command disables all the aliases, including manual
+ones, that the given snap groups in the given alias set, until the set is
+enabled again.
+`)
+
+func init() {
+	argDescs := []argDesc{
+		// TRANSLATORS: This needs to begin with < and end with >
+		{name: i18n.G("<snap>:<alias-set>")},
+	}
+	addCommand("enable-alias-set", shortEnableAliasSetHelp, longEnableAliasSetHelp, func() flags.Commander {
+		return &cmdAliasSet{enable: true}
+	}, waitDescs, argDescs)
+	addCommand("disable-alias-set", shortDisableAliasSetHelp, longDisableAliasSetHelp, func() flags.Commander {
+		return &cmdAliasSet{}
+	}, waitDescs, argDescs)
+}
+
+func (x *cmdAliasSet) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	parts := strings.Split(x.Positionals.AliasSet, ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf(i18n.G("invalid alias set %q (want snap:alias-set)"), x.Positionals.AliasSet)
+	}
+	snapName, aliasSet := parts[0], parts[1]
+
+	var id string
+	var err error
+	if x.enable {
+		id, err = x.client.EnableAliasSet(snapName, aliasSet)
+	} else {
+		id, err = x.client.DisableAliasSet(snapName, aliasSet)
+	}
+	if err != nil {
+		return err
+	}
+	chg, err := x.wait(id)
+	if err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	return showAliasChanges(chg)
+}
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDisableAliasSetHelp(c *C) {
+	msg := `Usage:
+  snap.test disable-alias-set [disable-alias-set-OPTIONS] <snap>:<alias-set>
+
+The disable-alias-set command disables all the aliases, including manual
+ones, that the given snap groups in the given alias set, until the set is
+enabled again.
+
+[disable-alias-set command options]
+      --no-wait               Do not wait for the operation to finish but just
+                              print the change id.
+`
+	s.testSubCommandHelp(c, "disable-alias-set", msg)
+}
+
+func (s *SnapSuite) TestEnableDisableAliasSet(c *C) {
+	for _, tc := range []struct {
+		cmd    string
+		action string
+		data   string
+		out    string
+	}{
+		{"enable-alias-set", "enable-alias-set", "aliases-added", "Added:\n  - some-snap.cmd1 as alias1\n"},
+		{"disable-alias-set", "disable-alias-set", "aliases-removed", "Removed:\n  - some-snap.cmd1 as alias1\n"},
+	} {
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/aliases":
+				c.Check(r.Method, Equals, "POST")
+				c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+					"action":    tc.action,
+					"snap":      "some-snap",
+					"alias-set": "tools",
+				})
+				w.WriteHeader(202)
+				fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+			case "/v2/changes/zzz":
+				c.Check(r.Method, Equals, "GET")
+				fmt.Fprintf(w, `{"type":"sync", "result":{"ready": true, "status": "Done", "data": {%q: [{"alias": "alias1", "snap": "some-snap", "app": "cmd1"}]}}}`+"\n", tc.data)
+			default:
+				c.Fatalf("unexpected path %q", r.URL.Path)
+			}
+		})
+		s.ResetStdStreams()
+		rest, err := Parser(Client()).ParseArgs([]string{tc.cmd, "some-snap:tools"})
+		c.Assert(err, IsNil)
+		c.Assert(rest, DeepEquals, []string{})
+		c.Check(s.Stdout(), Equals, tc.out)
+		c.Check(s.Stderr(), Equals, "")
+	}
+}
+
+func (s *SnapSuite) TestAliasSetInvalid(c *C) {
+	for _, arg := range []string{"some-snap", "some-snap:", ":tools", "a:b:c"} {
+		_, err := Parser(Client()).ParseArgs([]string{"enable-alias-set", arg})
+		c.Check(err, ErrorMatches, fmt.Sprintf(`invalid alias set %q \(want snap:alias-set\)`, arg))
+	}
+}
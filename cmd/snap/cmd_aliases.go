@@ -20,6 +20,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -32,6 +33,7 @@ import (
 
 type cmdAliases struct {
 	clientMixin
+	JSON        bool `long:"json"`
 	Positionals struct {
 		Snap installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"true"`
@@ -48,20 +50,28 @@ Lists only the aliases defined by the specified snap.
 An alias noted as undefined means it was explicitly enabled or disabled but is
 not defined in the current revision of the snap, possibly temporarily (e.g.
 because of a revert). This can cleared with 'snap alias --reset'.
+
+An alias that belongs to an alias set of its snap is noted with the name of
+the set. The aliases of a set are enabled and disabled together with
+'snap enable-alias-set' and 'snap disable-alias-set'.
 `)
 
 func init() {
 	addCommand("aliases", shortAliasesHelp, longAliasesHelp, func() flags.Commander {
 		return &cmdAliases{}
-	}, nil, nil)
+	}, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"json": i18n.G("Output results in JSON format"),
+	}, nil)
 }
 
 type aliasInfo struct {
-	Snap    string
-	Command string
-	Alias   string
-	Status  string
-	Auto    string
+	Snap     string `json:"snap"`
+	Command  string `json:"command"`
+	Alias    string `json:"alias"`
+	Status   string `json:"status"`
+	Auto     string `json:"auto,omitempty"`
+	AliasSet string `json:"alias-set,omitempty"`
 }
 
 type aliasInfos []*aliasInfo
@@ -105,15 +115,29 @@ func (x *cmdAliases) Execute(args []string) error {
 	for snapName, aliasStatuses := range allStatuses {
 		for alias, aliasStatus := range aliasStatuses {
 			infos = append(infos, &aliasInfo{
-				Snap:    snapName,
-				Command: aliasStatus.Command,
-				Alias:   alias,
-				Status:  aliasStatus.Status,
-				Auto:    aliasStatus.Auto,
+				Snap:     snapName,
+				Command:  aliasStatus.Command,
+				Alias:    alias,
+				Status:   aliasStatus.Status,
+				Auto:     aliasStatus.Auto,
+				AliasSet: aliasStatus.AliasSet,
 			})
 		}
 	}
 
+	if x.JSON {
+		if infos == nil {
+			infos = aliasInfos{}
+		}
+		sort.Sort(infos)
+		obj, err := json.Marshal(infos)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "%s\n", obj)
+		return nil
+	}
+
 	if len(infos) > 0 {
 		w := tabWriter()
 		fmt.Fprintln(w, i18n.G("Command\tAlias\tNotes"))
@@ -129,6 +153,9 @@ func (x *cmdAliases) Execute(args []string) error {
 					notes = append(notes, "override")
 				}
 			}
+			if info.AliasSet != "" {
+				notes = append(notes, "set="+info.AliasSet)
+			}
 			notesStr := strings.Join(notes, ",")
 			if notesStr == "" {
 				notesStr = "-"
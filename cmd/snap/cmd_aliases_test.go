@@ -31,7 +31,7 @@ import (
 
 func (s *SnapSuite) TestAliasesHelp(c *C) {
 	msg := `Usage:
-  snap.test aliases [<snap>]
+  snap.test aliases [aliases-OPTIONS] [<snap>]
 
 The aliases command lists all aliases available in the system and their status.
 
@@ -42,6 +42,13 @@ Lists only the aliases defined by the specified snap.
 An alias noted as undefined means it was explicitly enabled or disabled but is
 not defined in the current revision of the snap, possibly temporarily (e.g.
 because of a revert). This can cleared with 'snap alias --reset'.
+
+An alias that belongs to an alias set of its snap is noted with the name of
+the set. The aliases of a set are enabled and disabled together with
+'snap enable-alias-set' and 'snap disable-alias-set'.
+
+[aliases command options]
+      --json      Output results in JSON format
 `
 	s.testSubCommandHelp(c, "aliases", msg)
 }
@@ -177,3 +184,54 @@ func (s *SnapSuite) TestAliasesSorting(c *C) {
 	}
 
 }
+
+func (s *SnapSuite) TestAliasesAliasSetsJSON(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/aliases")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type": "sync",
+			"result": map[string]map[string]client.AliasStatus{
+				"foo": {
+					"foo0": {Command: "foo", Status: "auto", Auto: "foo", AliasSet: "tools"},
+					"fr":   {Command: "foo.reset", Manual: "reset", Status: "disabled", AliasSet: "tools"},
+				},
+				"bar": {
+					"bar_dump": {Command: "bar.dump", Status: "manual", Manual: "dump"},
+				},
+			},
+		})
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"aliases"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"Command    Alias     Notes\n"+
+		"bar.dump   bar_dump  manual\n"+
+		"foo        foo0      set=tools\n"+
+		"foo.reset  fr        disabled,set=tools\n")
+	c.Check(s.Stderr(), Equals, "")
+
+	s.ResetStdStreams()
+
+	rest, err = Parser(Client()).ParseArgs([]string{"aliases", "--json"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `[{"snap":"bar","command":"bar.dump","alias":"bar_dump","status":"manual"},`+
+		`{"snap":"foo","command":"foo","alias":"foo0","status":"auto","auto":"foo","alias-set":"tools"},`+
+		`{"snap":"foo","command":"foo.reset","alias":"fr","status":"disabled","alias-set":"tools"}]`+"\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestAliasesJSONNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": map[string]map[string]client.AliasStatus{},
+		})
+	})
+	_, err := Parser(Client()).ParseArgs([]string{"aliases", "--json"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "[]\n")
+	c.Check(s.Stderr(), Equals, "")
+}
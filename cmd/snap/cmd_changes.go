@@ -179,7 +179,8 @@ func (c *cmdTasks) showChange(chid string) error {
 	w.Flush()
 
 	for _, t := range chg.Tasks {
-		if len(t.Log) == 0 {
+		hint := taskErrorHint(t.Error)
+		if len(t.Log) == 0 && hint == "" {
 			continue
 		}
 		fmt.Fprintln(Stdout)
@@ -189,6 +190,10 @@ func (c *cmdTasks) showChange(chid string) error {
 		for _, line := range t.Log {
 			fmt.Fprintln(Stdout, line)
 		}
+		if hint != "" {
+			fmt.Fprintln(Stdout)
+			fmt.Fprintf(Stdout, "%s: %s\n", i18n.G("Hint"), hint)
+		}
 	}
 
 	fmt.Fprintln(Stdout)
@@ -198,6 +203,39 @@ func (c *cmdTasks) showChange(chid string) error {
 
 const line = "......................................................................"
 
+// taskErrorHint returns how to remediate the given classified task error,
+// if known.
+func taskErrorHint(taskErr *client.TaskError) string {
+	if taskErr == nil {
+		return ""
+	}
+	str := func(key string) string {
+		s, _ := taskErr.Value[key].(string)
+		return s
+	}
+
+	switch taskErr.Kind {
+	case client.TaskErrorKindNoSpace:
+		if path := str("path"); path != "" {
+			return fmt.Sprintf(i18n.G("free up disk space in %q and try again"), path)
+		}
+		return i18n.G("free up disk space and try again")
+	case client.TaskErrorKindBadAssertion:
+		if snapName := str("snap-name"); snapName != "" {
+			return fmt.Sprintf(i18n.G("snap %q could not be verified with its assertions; make sure it comes from a trusted source, or acknowledge its assertions with 'snap ack'"), snapName)
+		}
+		return i18n.G("required assertions are missing or invalid; acknowledge them with 'snap ack' or check the system can reach the store")
+	case client.TaskErrorKindStoreOffline:
+		return i18n.G("the store cannot be reached; check the network connection and try again")
+	case client.TaskErrorKindHookFailed:
+		if exitCode, ok := taskErr.Value["exit-code"]; ok {
+			return fmt.Sprintf(i18n.G("the %q hook of snap %q failed with exit code %v; check its output above and the system journal, or report the problem to the snap publisher"), str("hook"), str("snap-name"), exitCode)
+		}
+		return fmt.Sprintf(i18n.G("the %q hook of snap %q failed; check its output above and the system journal, or report the problem to the snap publisher"), str("hook"), str("snap-name"))
+	}
+	return ""
+}
+
 func warnMaintenance(cli *client.Client) error {
 	if maintErr := cli.Maintenance(); maintErr != nil {
 		msg, err := errorToCmdMessage("", "", maintErr, nil)
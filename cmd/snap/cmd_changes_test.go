@@ -26,6 +26,7 @@ import (
 
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/client"
 	snap "github.com/snapcore/snapd/cmd/snap"
 )
 
@@ -69,6 +70,77 @@ Do +2016-04-21T01:02:03Z +2016-04-21T01:02:04Z +some summary
 	c.Check(s.Stderr(), check.Equals, "")
 }
 
+var mockChangeErrorJSON = `{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "install-snap",
+  "summary": "...",
+  "status": "Error",
+  "ready": true,
+  "spawn-time": "2016-04-21T01:02:03Z",
+  "ready-time": "2016-04-21T01:02:04Z",
+  "tasks": [
+    {"kind": "download-snap", "summary": "Download snap", "status": "Undone", "progress": {"done": 1, "total": 1}, "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z"},
+    {"kind": "run-hook", "summary": "Run install hook", "status": "Error", "log": ["2016-04-21T01:02:04Z ERROR run hook \"install\": boom"], "progress": {"done": 1, "total": 1}, "spawn-time": "2016-04-21T01:02:03Z", "ready-time": "2016-04-21T01:02:04Z",
+     "error": {"kind": "hook-failed", "value": {"snap-name": "foo", "hook": "install", "exit-code": 3}}}
+  ]
+}}`
+
+func (s *SnapSuite) TestChangeErrorHint(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+		fmt.Fprintln(w, mockChangeErrorJSON)
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"change", "--abs-time", "42"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `Status  Spawn                 Ready                 Summary
+Undone  2016-04-21T01:02:03Z  2016-04-21T01:02:04Z  Download snap
+Error   2016-04-21T01:02:03Z  2016-04-21T01:02:04Z  Run install hook
+
+......................................................................
+Run install hook
+
+2016-04-21T01:02:04Z ERROR run hook "install": boom
+
+Hint: the "install" hook of snap "foo" failed with exit code 3; check its output above and the system journal, or report the problem to the snap publisher
+
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestTaskErrorHint(c *check.C) {
+	for _, t := range []struct {
+		taskErr *client.TaskError
+		hint    string
+	}{
+		{nil, ""},
+		{&client.TaskError{Kind: "unknown"}, ""},
+		{
+			&client.TaskError{Kind: client.TaskErrorKindNoSpace, Value: map[string]interface{}{"path": "/var/lib/snapd"}},
+			`free up disk space in "/var/lib/snapd" and try again`,
+		}, {
+			&client.TaskError{Kind: client.TaskErrorKindNoSpace},
+			`free up disk space and try again`,
+		}, {
+			&client.TaskError{Kind: client.TaskErrorKindBadAssertion, Value: map[string]interface{}{"snap-name": "foo"}},
+			`snap "foo" could not be verified with its assertions; make sure it comes from a trusted source, or acknowledge its assertions with 'snap ack'`,
+		}, {
+			&client.TaskError{Kind: client.TaskErrorKindBadAssertion, Value: map[string]interface{}{"assertion-type": "snap-declaration"}},
+			`required assertions are missing or invalid; acknowledge them with 'snap ack' or check the system can reach the store`,
+		}, {
+			&client.TaskError{Kind: client.TaskErrorKindStoreOffline},
+			`the store cannot be reached; check the network connection and try again`,
+		}, {
+			&client.TaskError{Kind: client.TaskErrorKindHookFailed, Value: map[string]interface{}{"snap-name": "foo", "hook": "configure"}},
+			`the "configure" hook of snap "foo" failed; check its output above and the system journal, or report the problem to the snap publisher`,
+		},
+	} {
+		c.Check(snap.TaskErrorHint(t.taskErr), check.Equals, t.hint)
+	}
+}
+
 func (s *SnapSuite) TestChangeSimpleRebooting(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -20,7 +20,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"sort"
 	"strings"
 
@@ -31,8 +33,12 @@ import (
 )
 
 type cmdConnections struct {
-	clientMixin
-	All         bool `long:"all"`
+	waitMixin
+	All         bool           `long:"all"`
+	Pending     bool           `long:"pending"`
+	Why         bool           `long:"why"`
+	Export      bool           `long:"export"`
+	Restore     flags.Filename `long:"restore" value-name:"<file>"`
 	Positionals struct {
 		Snap installedSnapName
 	} `positional-args:"true"`
@@ -47,6 +53,21 @@ Unless <snap> is provided, the listing is for connected plugs and
 slots for all snaps in the system. In this mode, pass --all to also
 list unconnected plugs and slots.
 
+Pass --pending to list plugs that could not be automatically connected yet,
+along with the reason. Their automatic connection is retried when other snaps
+are installed or refreshed.
+
+Pass --why along with <snap>, or <snap>:<plug or slot>, to explain for each
+candidate connection whether it is allowed, manually and automatically, by
+evaluating the rules of the base-declaration and of the snap-declarations,
+and what would need to change otherwise.
+
+Pass --export to print the manual connections and disconnections, of all
+snaps or of <snap>, as a document that can be passed to --restore after the
+snaps were installed again, for instance after a factory reset. Restored
+connections are checked against the policy of the system, as any manual
+connection. Pass - as the file to read the document from standard input.
+
 $ snap connections <snap>
 
 Lists connected and unconnected plugs and slots for the specified
@@ -56,9 +77,17 @@ snap.
 func init() {
 	addCommand("connections", shortConnectionsHelp, longConnectionsHelp, func() flags.Commander {
 		return &cmdConnections{}
-	}, map[string]string{
+	}, waitDescs.also(map[string]string{
 		"all": i18n.G("Show connected and unconnected plugs and slots"),
-	}, []argDesc{{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"pending": i18n.G("Show plugs that could not be automatically connected yet"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"why": i18n.G("Explain whether candidate connections are allowed by the declarations"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"export": i18n.G("Print the manual connections and disconnections as a document"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"restore": i18n.G("Restore the manual connections and disconnections of the given document"),
+	}), []argDesc{{
 		// TRANSLATORS: This needs to be wrapped in <>s.
 		name: "<snap>",
 		// TRANSLATORS: This should not start with a lowercase letter.
@@ -84,6 +113,7 @@ type connection struct {
 	interfaceDeterminant string
 	manual               bool
 	gadget               bool
+	deprecated           bool
 }
 
 func (cn connection) String() string {
@@ -94,6 +124,9 @@ func (cn connection) String() string {
 	if cn.gadget {
 		opts = append(opts, "gadget")
 	}
+	if cn.deprecated {
+		opts = append(opts, "deprecated")
+	}
 	if len(opts) == 0 {
 		return "-"
 	}
@@ -140,6 +173,36 @@ func (x *cmdConnections) Execute(args []string) error {
 		All: x.All,
 	}
 	wanted := string(x.Positionals.Snap)
+	if x.Export || x.Restore != "" {
+		if x.All || x.Pending || x.Why || (x.Export && x.Restore != "") {
+			return fmt.Errorf(i18n.G("cannot use --export or --restore with other options"))
+		}
+		if x.Restore != "" {
+			if wanted != "" {
+				return fmt.Errorf(i18n.G("cannot use --restore with snap name"))
+			}
+			return x.restore(string(x.Restore))
+		}
+		return x.export(wanted)
+	}
+	if x.Why {
+		if x.All || x.Pending {
+			return fmt.Errorf(i18n.G("cannot use --why with --all or --pending"))
+		}
+		if wanted == "" {
+			return fmt.Errorf(i18n.G("--why needs a snap name"))
+		}
+		snapName, name, _ := strings.Cut(wanted, ":")
+		return x.showWhy(snapName, name)
+	}
+	if x.Pending {
+		if x.All {
+			return fmt.Errorf(i18n.G("cannot use --all with --pending"))
+		}
+		opts.Snap = wanted
+		opts.All = true
+		return x.showPending(&opts)
+	}
 	if wanted != "" {
 		if x.All {
 			// passing a snap name already implies --all, error out
@@ -169,6 +232,7 @@ func (x *cmdConnections) Execute(args []string) error {
 			slot:                 endpoint(conn.Slot.Snap, conn.Slot.Name),
 			manual:               conn.Manual,
 			gadget:               conn.Gadget,
+			deprecated:           conn.DeprecatedBy != "",
 			interfaceName:        conn.Interface,
 			interfaceDeterminant: interfaceDeterminant(&conn),
 		})
@@ -212,3 +276,148 @@ func (x *cmdConnections) Execute(args []string) error {
 	}
 	return nil
 }
+
+func (x *cmdConnections) showPending(opts *client.ConnectionOptions) error {
+	connections, err := x.client.Connections(opts)
+	if err != nil {
+		return err
+	}
+	if len(connections.Pending) == 0 {
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Interface\tPlug\tReason"))
+	for _, p := range connections.Pending {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Interface, endpoint(p.Plug.Snap, p.Plug.Name), p.Reason)
+	}
+	w.Flush()
+	return nil
+}
+
+func (x *cmdConnections) export(snapName string) error {
+	backup, err := x.client.ExportConnections(snapName)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "%s\n", out)
+	return nil
+}
+
+func (x *cmdConnections) restore(fname string) error {
+	var data []byte
+	var err error
+	if fname == "-" {
+		data, err = ioutil.ReadAll(Stdin)
+	} else {
+		data, err = ioutil.ReadFile(fname)
+	}
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read connections document: %v"), err)
+	}
+	var backup client.ConnectionsBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return fmt.Errorf(i18n.G("cannot decode connections document: %v"), err)
+	}
+
+	id, err := x.client.RestoreConnections(&backup)
+	if err != nil {
+		return err
+	}
+	chg, err := x.wait(id)
+	if err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	var skipped []client.ConnectionIntent
+	if err := chg.Get("skipped", &skipped); err != nil && err != client.ErrNoData {
+		return err
+	}
+	if len(skipped) == 0 {
+		return nil
+	}
+	fmt.Fprintln(Stderr, i18n.G("Some connections were not restored:"))
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Interface\tPlug\tSlot\tReason"))
+	for _, sk := range skipped {
+		slot := endpoint(sk.Slot.Snap, sk.Slot.Name)
+		if sk.Undesired {
+			slot = fmt.Sprintf(i18n.G("%s (disconnected)"), slot)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", sk.Interface, endpoint(sk.Plug.Snap, sk.Plug.Name), slot, sk.Reason)
+	}
+	w.Flush()
+	return nil
+}
+
+func (x *cmdConnections) showWhy(snapName, name string) error {
+	expls, err := x.client.ExplainConnections(snapName, name)
+	if err != nil {
+		return err
+	}
+	if len(expls) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No candidate connections."))
+		return nil
+	}
+
+	for i, expl := range expls {
+		if i > 0 {
+			fmt.Fprintln(Stdout)
+		}
+		plug := endpoint(expl.Plug.Snap, expl.Plug.Name)
+		slot := endpoint(expl.Slot.Snap, expl.Slot.Name)
+		status := i18n.G("not connected")
+		if expl.Connected {
+			status = i18n.G("connected")
+		}
+		fmt.Fprintf(Stdout, "%s -> %s (%s, %s)\n", plug, slot, expl.Interface, status)
+		if expl.Unasserted {
+			fmt.Fprintln(Stdout, i18n.G("  note: a snap has no snap-declaration, manual connections are not checked"))
+		}
+		printPolicyExplanation(expl.Connection)
+		printPolicyExplanation(expl.AutoConnection)
+		if !expl.Connected && expl.Connection.Allowed && !expl.AutoConnection.Allowed {
+			fmt.Fprintf(Stdout, i18n.G("  it can be connected manually with: snap connect %s %s\n"), plug, slot)
+		}
+	}
+	return nil
+}
+
+func printPolicyExplanation(expl *client.ConnectionPolicyExplanation) {
+	if expl.Allowed {
+		fmt.Fprintf(Stdout, "  %s: %s\n", expl.Kind, i18n.G("allowed"))
+	} else {
+		fmt.Fprintf(Stdout, "  %s: %s: %s\n", expl.Kind, i18n.G("not allowed"), expl.Error)
+	}
+	for _, rule := range expl.Rules {
+		desc := fmt.Sprintf("%s %s rule", rule.Declaration, rule.Side)
+		if rule.Snap != "" {
+			desc += fmt.Sprintf(" for %q", rule.Snap)
+		}
+		if rule.Decisive {
+			desc += " (decisive)"
+		}
+		switch {
+		case rule.Deny.Matched:
+			fmt.Fprintf(Stdout, "    %s: %s\n", desc, i18n.G("denied"))
+			fmt.Fprintf(Stdout, "      deny-%s matched\n", expl.Kind)
+		case rule.Allow.Matched:
+			fmt.Fprintf(Stdout, "    %s: %s\n", desc, i18n.G("allowed"))
+		default:
+			fmt.Fprintf(Stdout, "    %s: %s\n", desc, i18n.G("not allowed"))
+			for _, mismatch := range rule.Allow.Mismatches {
+				fmt.Fprintf(Stdout, "      allow-%s did not match: %s\n", expl.Kind, mismatch)
+			}
+		}
+	}
+	if expl.Hint != "" {
+		fmt.Fprintf(Stdout, "    %s: %s\n", i18n.G("hint"), expl.Hint)
+	}
+}
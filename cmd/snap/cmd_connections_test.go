@@ -20,10 +20,12 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"path/filepath"
 
 	. "gopkg.in/check.v1"
 
@@ -277,6 +279,60 @@ func (s *SnapSuite) TestConnectionsSomeConnected(c *C) {
 	c.Assert(s.Stderr(), Equals, "")
 }
 
+func (s *SnapSuite) TestConnectionsDeprecated(c *C) {
+	result := client.Connections{
+		Established: []client.Connection{
+			{
+				Plug:         client.PlugRef{Snap: "keyboard-lights", Name: "capslock"},
+				Slot:         client.SlotRef{Snap: "core", Name: "capslock-led"},
+				Interface:    "old-leds",
+				Manual:       true,
+				DeprecatedBy: "leds",
+			}, {
+				Plug:      client.PlugRef{Snap: "keyboard-lights", Name: "numlock"},
+				Slot:      client.SlotRef{Snap: "core", Name: "numlock-led"},
+				Interface: "leds",
+			},
+		},
+		Plugs: []client.Plug{
+			{
+				Snap:      "keyboard-lights",
+				Name:      "capslock",
+				Interface: "old-leds",
+				Connections: []client.SlotRef{{
+					Snap: "core",
+					Name: "capslock-led",
+				}},
+			}, {
+				Snap:      "keyboard-lights",
+				Name:      "numlock",
+				Interface: "leds",
+				Connections: []client.SlotRef{{
+					Snap: "core",
+					Name: "numlock-led",
+				}},
+			},
+		},
+	}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": result,
+		})
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connections"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"Interface  Plug                      Slot           Notes\n" +
+		"leds       keyboard-lights:numlock   :numlock-led   -\n" +
+		"old-leds   keyboard-lights:capslock  :capslock-led  manual,deprecated\n"
+	c.Assert(s.Stdout(), Equals, expectedStdout)
+	c.Assert(s.Stderr(), Equals, "")
+}
+
 func (s *SnapSuite) TestConnectionsSomeDisconnected(c *C) {
 	result := client.Connections{
 		Established: []client.Connection{
@@ -851,3 +907,245 @@ func (s *SnapSuite) TestConnectionsDefiningAttribute(c *C) {
 	c.Assert(s.Stdout(), Equals, expectedStdout)
 	c.Assert(s.Stderr(), Equals, "")
 }
+
+func (s *SnapSuite) TestConnectionsPending(c *C) {
+	result := client.Connections{
+		Pending: []client.PendingConnection{
+			{
+				Plug:      client.PlugRef{Snap: "consumer", Name: "content"},
+				Interface: "content",
+				Reason:    `default provider "producer" is not installed`,
+			}, {
+				Plug:      client.PlugRef{Snap: "consumer", Name: "serial"},
+				Interface: "serial-port",
+				Reason:    "multiple candidate slots: gadget:one, gadget:two",
+			},
+		},
+	}
+	query := url.Values{
+		"select": []string{"all"},
+	}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections")
+		c.Check(r.URL.Query(), DeepEquals, query)
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": result,
+		})
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connections", "--pending"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"Interface    Plug              Reason\n" +
+		"content      consumer:content  default provider \"producer\" is not installed\n" +
+		"serial-port  consumer:serial   multiple candidate slots: gadget:one, gadget:two\n"
+	c.Assert(s.Stdout(), Equals, expectedStdout)
+	c.Assert(s.Stderr(), Equals, "")
+
+	s.ResetStdStreams()
+
+	query = url.Values{
+		"select": []string{"all"},
+		"snap":   []string{"consumer"},
+	}
+	_, err = Parser(Client()).ParseArgs([]string{"connections", "--pending", "consumer"})
+	c.Assert(err, IsNil)
+	c.Assert(s.Stdout(), Equals, expectedStdout)
+
+	_, err = Parser(Client()).ParseArgs([]string{"connections", "--pending", "--all"})
+	c.Assert(err, ErrorMatches, "cannot use --all with --pending")
+}
+
+func (s *SnapSuite) TestConnectionsWhy(c *C) {
+	result := []client.ConnectionExplanation{
+		{
+			Plug:       client.PlugRef{Snap: "consumer", Name: "plug"},
+			Slot:       client.SlotRef{Snap: "producer", Name: "slot"},
+			Interface:  "test",
+			Connection: &client.ConnectionPolicyExplanation{Kind: "connection", Allowed: true},
+			AutoConnection: &client.ConnectionPolicyExplanation{
+				Kind:  "auto-connection",
+				Error: `auto-connection not allowed by slot rule of interface "test"`,
+				Rules: []client.ConnectionRuleExplanation{{
+					Declaration: "snap-declaration",
+					Side:        "slot",
+					Snap:        "producer",
+					Allow: client.ConstraintsOutcome{
+						Mismatches: []string{"publisher id does not match", "on-classic mismatch"},
+					},
+					Decisive: true,
+				}, {
+					Declaration: "base-declaration",
+					Side:        "slot",
+					Deny:        client.ConstraintsOutcome{Matched: true},
+				}},
+				Hint: `the slot rule in the snap-declaration of "producer" would need to allow the auto-connection`,
+			},
+		}, {
+			Plug:           client.PlugRef{Snap: "consumer", Name: "network"},
+			Slot:           client.SlotRef{Snap: "core", Name: "network"},
+			Interface:      "network",
+			Connected:      true,
+			Unasserted:     true,
+			Connection:     &client.ConnectionPolicyExplanation{Kind: "connection", Allowed: true},
+			AutoConnection: &client.ConnectionPolicyExplanation{Kind: "auto-connection", Allowed: true},
+		},
+	}
+	query := url.Values{
+		"snap": []string{"consumer"},
+	}
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections/explain")
+		c.Check(r.URL.Query(), DeepEquals, query)
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": result,
+		})
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connections", "--why", "consumer"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"consumer:plug -> producer:slot (test, not connected)\n" +
+		"  connection: allowed\n" +
+		"  auto-connection: not allowed: auto-connection not allowed by slot rule of interface \"test\"\n" +
+		"    snap-declaration slot rule for \"producer\" (decisive): not allowed\n" +
+		"      allow-auto-connection did not match: publisher id does not match\n" +
+		"      allow-auto-connection did not match: on-classic mismatch\n" +
+		"    base-declaration slot rule: denied\n" +
+		"      deny-auto-connection matched\n" +
+		"    hint: the slot rule in the snap-declaration of \"producer\" would need to allow the auto-connection\n" +
+		"  it can be connected manually with: snap connect consumer:plug producer:slot\n" +
+		"\n" +
+		"consumer:network -> :network (network, connected)\n" +
+		"  note: a snap has no snap-declaration, manual connections are not checked\n" +
+		"  connection: allowed\n" +
+		"  auto-connection: allowed\n"
+	c.Check(s.Stdout(), Equals, expectedStdout)
+	c.Check(s.Stderr(), Equals, "")
+
+	s.ResetStdStreams()
+
+	query = url.Values{
+		"snap": []string{"consumer"},
+		"name": []string{"plug"},
+	}
+	_, err = Parser(Client()).ParseArgs([]string{"connections", "--why", "consumer:plug"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, expectedStdout)
+}
+
+func (s *SnapSuite) TestConnectionsWhyNoCandidates(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type":   "sync",
+			"result": []interface{}{},
+		})
+	})
+	_, err := Parser(Client()).ParseArgs([]string{"connections", "--why", "consumer"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No candidate connections.\n")
+}
+
+func (s *SnapSuite) TestConnectionsWhyErrors(c *C) {
+	_, err := Parser(Client()).ParseArgs([]string{"connections", "--why"})
+	c.Assert(err, ErrorMatches, "--why needs a snap name")
+	_, err = Parser(Client()).ParseArgs([]string{"connections", "--why", "--all", "consumer"})
+	c.Assert(err, ErrorMatches, "cannot use --why with --all or --pending")
+	_, err = Parser(Client()).ParseArgs([]string{"connections", "--why", "--pending", "consumer"})
+	c.Assert(err, ErrorMatches, "cannot use --why with --all or --pending")
+}
+
+func (s *SnapSuite) TestConnectionsExport(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/connections/backup")
+		c.Check(r.URL.Query(), DeepEquals, url.Values{"snap": []string{"consumer"}})
+		fmt.Fprintln(w, `{"type": "sync", "result": {"format": 1, "connections": [
+{"plug": {"snap": "consumer", "plug": "plug"}, "slot": {"snap": "system", "slot": "slot"}, "interface": "test", "undesired": true}
+]}}`)
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connections", "--export", "consumer"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `{
+  "format": 1,
+  "connections": [
+    {
+      "plug": {
+        "snap": "consumer",
+        "plug": "plug"
+      },
+      "slot": {
+        "snap": "system",
+        "slot": "slot"
+      },
+      "interface": "test",
+      "undesired": true
+    }
+  ]
+}
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestConnectionsRestore(c *C) {
+	doc := `{"format": 1, "connections": [
+{"plug": {"snap": "consumer", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}, "interface": "test"},
+{"plug": {"snap": "consumer", "plug": "other"}, "slot": {"snap": "system", "slot": "other"}, "interface": "test", "undesired": true}
+]}`
+	fname := filepath.Join(c.MkDir(), "connections.json")
+	c.Assert(ioutil.WriteFile(fname, []byte(doc), 0644), IsNil)
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/connections/backup":
+			c.Check(r.Method, Equals, "POST")
+			var backup client.ConnectionsBackup
+			c.Assert(json.NewDecoder(r.Body).Decode(&backup), IsNil)
+			c.Check(backup.Format, Equals, 1)
+			c.Check(backup.Connections, HasLen, 2)
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done", "data": {"skipped": [
+{"plug": {"snap": "consumer", "plug": "other"}, "slot": {"snap": "system", "slot": "other"}, "interface": "test", "undesired": true, "reason": "already disconnected"}
+]}}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	rest, err := Parser(Client()).ParseArgs([]string{"connections", "--restore", fname})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"Interface  Plug            Slot                   Reason\n"+
+		"test       consumer:other  :other (disconnected)  already disconnected\n")
+	c.Check(s.Stderr(), Equals, "Some connections were not restored:\n")
+}
+
+func (s *SnapSuite) TestConnectionsExportRestoreErrors(c *C) {
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"connections", "--export", "--all"}, "cannot use --export or --restore with other options"},
+		{[]string{"connections", "--restore", "foo", "--why"}, "cannot use --export or --restore with other options"},
+		{[]string{"connections", "--export", "--restore", "foo"}, "cannot use --export or --restore with other options"},
+		{[]string{"connections", "--restore", "foo", "consumer"}, "cannot use --restore with snap name"},
+		{[]string{"connections", "--restore", "/does/not/exist"}, "cannot read connections document: .*"},
+	} {
+		_, err := Parser(Client()).ParseArgs(t.args)
+		c.Check(err, ErrorMatches, t.err)
+	}
+
+	fname := filepath.Join(c.MkDir(), "connections.json")
+	c.Assert(ioutil.WriteFile(fname, []byte("{"), 0644), IsNil)
+	_, err := Parser(Client()).ParseArgs([]string{"connections", "--restore", fname})
+	c.Check(err, ErrorMatches, "cannot decode connections document: .*")
+}
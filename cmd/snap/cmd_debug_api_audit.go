@@ -0,0 +1,106 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugAPIAudit struct {
+	clientMixin
+	timeMixin
+	unicodeMixin
+
+	ID     string `long:"id"`
+	Change string `long:"change"`
+}
+
+func init() {
+	addDebugCommand("api-audit",
+		"(internal) list the state-changing API requests received by snapd",
+		"(internal) list the state-changing API requests received by snapd, with who issued them and the changes they created",
+		func() flags.Commander {
+			return &cmdDebugAPIAudit{}
+		}, timeDescs.also(unicodeDescs).also(map[string]string{
+			"id":     "Only show the request with the given correlation id",
+			"change": "Only show the request that created the given change",
+		}), nil)
+}
+
+type apiAuditRecord struct {
+	ID       string    `json:"id"`
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	UID      *uint32   `json:"uid,omitempty"`
+	PID      int32     `json:"pid,omitempty"`
+	Snap     string    `json:"snap,omitempty"`
+	Status   int       `json:"status-code"`
+	ChangeID string    `json:"change-id,omitempty"`
+}
+
+func (x *cmdDebugAPIAudit) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	params := map[string]string{}
+	if x.ID != "" {
+		params["id"] = x.ID
+	}
+	if x.Change != "" {
+		params["change-id"] = x.Change
+	}
+	var records []apiAuditRecord
+	if err := x.client.DebugGet("api-audit", &records, params); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No API requests were audited."))
+		return nil
+	}
+
+	esc := x.getEscapes()
+	orDash := func(s string) string {
+		if s == "" {
+			return esc.dash
+		}
+		return s
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("ID\tTime\tMethod\tPath\tStatus\tUID\tPID\tSnap\tChange"))
+	for _, rec := range records {
+		uid, pid := esc.dash, esc.dash
+		if rec.UID != nil {
+			uid = strconv.FormatUint(uint64(*rec.UID), 10)
+			pid = strconv.Itoa(int(rec.PID))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", rec.ID, x.fmtTime(rec.Time),
+			rec.Method, rec.Path, rec.Status, uid, pid, orDash(rec.Snap), orDash(rec.ChangeID))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDebugAPIAudit(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/debug")
+			c.Check(r.URL.RawQuery, check.Equals, "aspect=api-audit")
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+{"id": "BCD123", "time": "2024-01-02T03:04:05Z", "method": "POST", "path": "/v2/snaps/foo", "uid": 1000, "pid": 123, "snap": "some-snap", "status-code": 202, "change-id": "12"},
+{"id": "XYZ789", "time": "2024-01-02T03:05:05Z", "method": "PUT", "path": "/v2/snaps/foo/conf", "status-code": 403}
+]}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "api-audit", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `
+ID      Time                  Method  Path                Status  UID   PID  Snap       Change
+BCD123  2024-01-02T03:04:05Z  POST    /v2/snaps/foo       202     1000  123  some-snap  12
+XYZ789  2024-01-02T03:05:05Z  PUT     /v2/snaps/foo/conf  403     --    --   --         --
+`[1:])
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestDebugAPIAuditFilters(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("aspect"), check.Equals, "api-audit")
+		c.Check(r.URL.Query().Get("id"), check.Equals, "BCD123")
+		c.Check(r.URL.Query().Get("change-id"), check.Equals, "12")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "api-audit", "--id=BCD123", "--change=12"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No API requests were audited.\n")
+}
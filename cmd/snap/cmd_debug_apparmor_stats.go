@@ -0,0 +1,81 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugApparmorStats struct {
+	clientMixin
+	timeMixin
+}
+
+func init() {
+	addDebugCommand("apparmor-stats",
+		"(internal) show apparmor profile compilation statistics",
+		"(internal) show apparmor profile compilation statistics",
+		func() flags.Commander {
+			return &cmdDebugApparmorStats{}
+		}, timeDescs, nil)
+}
+
+type apparmorCompileStats struct {
+	Loads       int           `json:"loads"`
+	Invocations int           `json:"invocations"`
+	Failures    int           `json:"failures"`
+	Compiled    int           `json:"compiled"`
+	Cacheable   int           `json:"cacheable"`
+	TotalTime   time.Duration `json:"total-time"`
+	LongestTime time.Duration `json:"longest-time"`
+	LastLoad    time.Time     `json:"last-load"`
+}
+
+func (x *cmdDebugApparmorStats) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	var stats apparmorCompileStats
+	if err := x.client.DebugGet("apparmor-stats", &stats, nil); err != nil {
+		return err
+	}
+	if stats.Loads == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No apparmor profiles were loaded yet."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, "loads:\t%d\n", stats.Loads)
+	fmt.Fprintf(w, "parser-invocations:\t%d\n", stats.Invocations)
+	fmt.Fprintf(w, "failures:\t%d\n", stats.Failures)
+	fmt.Fprintf(w, "profiles-compiled:\t%d\n", stats.Compiled)
+	fmt.Fprintf(w, "profiles-cacheable:\t%d\n", stats.Cacheable)
+	fmt.Fprintf(w, "total-time:\t%s\n", stats.TotalTime.Round(time.Millisecond))
+	fmt.Fprintf(w, "longest-time:\t%s\n", stats.LongestTime.Round(time.Millisecond))
+	fmt.Fprintf(w, "last-load:\t%s\n", x.fmtTime(stats.LastLoad))
+	return nil
+}
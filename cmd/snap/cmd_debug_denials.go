@@ -0,0 +1,94 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugDenials struct {
+	clientMixin
+	timeMixin
+	unicodeMixin
+
+	Positional struct {
+		Snap installedSnapName `positional-arg-name:"<snap>" required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addDebugCommand("denials",
+		"(internal) list the operations of a snap denied by its sandbox",
+		"(internal) list the operations of a snap denied by its sandbox, with the interfaces likely to allow them",
+		func() flags.Commander {
+			return &cmdDebugDenials{}
+		}, timeDescs.also(unicodeDescs), nil)
+}
+
+func (x *cmdDebugDenials) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	snapName := string(x.Positional.Snap)
+	notices, err := x.client.Notices(&client.NoticesOptions{
+		Types: []string{client.InterfaceDenialNotice},
+	})
+	if err != nil {
+		return err
+	}
+	var denials []*client.Notice
+	for _, n := range notices {
+		if n.LastData["snap"] == snapName {
+			denials = append(denials, n)
+		}
+	}
+	if len(denials) == 0 {
+		fmt.Fprintf(Stderr, i18n.G("No denials recorded for snap %q.\n"), snapName)
+		return nil
+	}
+
+	esc := x.getEscapes()
+	orDash := func(s string) string {
+		if s == "" {
+			return esc.dash
+		}
+		return s
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Last\tCount\tApp\tInterface\tOperation\tName"))
+	for _, n := range denials {
+		data := n.LastData
+		iface := data["interface"]
+		if iface == "unknown" {
+			iface = esc.dash
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", x.fmtTime(n.LastOccurred), n.Occurrences,
+			orDash(data["app"]), iface, orDash(data["operation"]), orDash(data["name"]))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDebugDenials(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/notices")
+			c.Check(r.URL.Query().Get("types"), check.Equals, "interface-denial")
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+{"id": "1", "type": "interface-denial", "key": "foo/camera", "last-occurred": "2023-01-10T12:00:00Z", "occurrences": 3, "last-data": {"snap": "foo", "app": "app", "interface": "camera", "operation": "open", "name": "/dev/video0"}},
+{"id": "2", "type": "interface-denial", "key": "bar/home", "last-occurred": "2023-01-10T12:30:00Z", "occurrences": 1, "last-data": {"snap": "bar", "app": "app", "interface": "home", "operation": "open", "name": "/home/user/foo"}},
+{"id": "3", "type": "interface-denial", "key": "foo/unknown", "last-occurred": "2023-01-10T13:00:00Z", "occurrences": 1, "last-data": {"snap": "foo", "app": "hook.configure", "interface": "unknown", "operation": "capable", "name": "sys_admin"}}
+]}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "denials", "--abs-time", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `
+Last                  Count  App             Interface  Operation  Name
+2023-01-10T12:00:00Z  3      app             camera     open       /dev/video0
+2023-01-10T13:00:00Z  1      hook.configure  --         capable    sys_admin
+`[1:])
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestDebugDenialsNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "denials", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No denials recorded for snap \"foo\".\n")
+}
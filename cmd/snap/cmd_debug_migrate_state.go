@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type cmdDebugMigrateState struct {
+	DryRun   bool `long:"dry-run"`
+	Rollback bool `long:"rollback"`
+}
+
+var cmdDebugMigrateStateShortHelp = i18n.G("Inspect or undo the migration of the snapd state")
+var cmdDebugMigrateStateLongHelp = i18n.G(`
+The migrate-state command reports the patch level of the snapd state, as
+migrated by snapd when it starts, against the one of this version of snapd.
+
+With --dry-run, the migration of the state to the current patch level is
+tried on a copy of the state, without changing it.
+
+With --rollback, the state is put back as it was before snapd last migrated
+it. snapd must be stopped, and should be reverted to the version that
+matched the state, before doing so.
+`)
+
+func init() {
+	addDebugCommand("migrate-state", cmdDebugMigrateStateShortHelp, cmdDebugMigrateStateLongHelp, func() flags.Commander {
+		return &cmdDebugMigrateState{}
+	}, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"dry-run": i18n.G("Check that the state can be migrated without changing it"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"rollback": i18n.G("Restore the state from before it was last migrated"),
+	}, nil)
+}
+
+func statePatchLevel(st *state.State) (level, sublevel int, err error) {
+	st.Lock()
+	defer st.Unlock()
+	if err := st.Get("patch-level", &level); err != nil && !errors.Is(err, state.ErrNoState) {
+		return 0, 0, err
+	}
+	if err := st.Get("patch-sublevel", &sublevel); err != nil && !errors.Is(err, state.ErrNoState) {
+		return 0, 0, err
+	}
+	return level, sublevel, nil
+}
+
+func (x *cmdDebugMigrateState) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if x.DryRun && x.Rollback {
+		return errors.New(i18n.G("cannot use --dry-run and --rollback together"))
+	}
+	if x.Rollback {
+		return x.rollback()
+	}
+
+	st, err := loadState(dirs.SnapStateFile)
+	if err != nil {
+		return err
+	}
+	level, sublevel, err := statePatchLevel(st)
+	if err != nil {
+		return err
+	}
+
+	if !x.DryRun {
+		fmt.Fprintf(Stdout, "state patch level: %d.%d\n", level, sublevel)
+		fmt.Fprintf(Stdout, "snapd patch level: %d.%d\n", patch.Level, patch.Sublevel)
+		rollback := "no"
+		if osutil.FileExists(patch.BackupFile(dirs.SnapStateFile)) {
+			rollback = "yes"
+		}
+		fmt.Fprintf(Stdout, "rollback available: %s\n", rollback)
+		return nil
+	}
+
+	pending, err := patch.DryRun(st)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot migrate state from patch level %d.%d: %v"), level, sublevel, err)
+	}
+	if !pending {
+		fmt.Fprintf(Stdout, i18n.G("State is at patch level %d.%d, nothing to migrate.\n"), level, sublevel)
+		return nil
+	}
+	fmt.Fprintf(Stdout, i18n.G("State can be migrated from patch level %d.%d to %d.%d.\n"), level, sublevel, patch.Level, patch.Sublevel)
+	return nil
+}
+
+func (x *cmdDebugMigrateState) rollback() error {
+	// snapd holds the state lock for as long as it runs and would write
+	// its own state back over the restored one
+	flock, err := osutil.NewFileLock(dirs.SnapStateLockFile)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot open the state lock file: %v"), err)
+	}
+	defer flock.Close()
+	if err := flock.TryLock(); err != nil {
+		if err == osutil.ErrAlreadyLocked {
+			return errors.New(i18n.G("cannot roll back the state while snapd is running"))
+		}
+		return err
+	}
+	defer flock.Unlock()
+
+	if err := patch.Rollback(dirs.SnapStateFile); err != nil {
+		return err
+	}
+	fmt.Fprintln(Stdout, i18n.G("State restored to before it was last migrated."))
+	return nil
+}
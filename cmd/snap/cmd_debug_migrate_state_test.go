@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *SnapSuite) mockStateAtPatchLevel(c *C, level, sublevel int) []byte {
+	data := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"patch-sublevel":%d}}`, level, sublevel))
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, data, 0600), IsNil)
+	return data
+}
+
+func (s *SnapSuite) TestDebugMigrateState(c *C) {
+	restore := patch.Mock(2, 1, nil)
+	defer restore()
+	s.mockStateAtPatchLevel(c, 2, 0)
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `state patch level: 2.0
+snapd patch level: 2.1
+rollback available: no
+`)
+	c.Check(s.Stderr(), Equals, "")
+
+	s.ResetStdStreams()
+	c.Assert(ioutil.WriteFile(patch.BackupFile(dirs.SnapStateFile), nil, 0600), IsNil)
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, `(?s).*rollback available: yes\n`)
+}
+
+func (s *SnapSuite) TestDebugMigrateStateDryRun(c *C) {
+	patched := 0
+	restore := patch.Mock(2, 1, map[int][]patch.PatchFunc{
+		2: {
+			func(st *state.State) error { return nil },
+			func(st *state.State) error {
+				patched++
+				st.Set("patched", true)
+				return nil
+			},
+		},
+	})
+	defer restore()
+	data := s.mockStateAtPatchLevel(c, 2, 0)
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state", "--dry-run"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "State can be migrated from patch level 2.0 to 2.1.\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(patched, Equals, 1)
+	// the state file is untouched
+	c.Check(dirs.SnapStateFile, testutil.FileEquals, data)
+}
+
+func (s *SnapSuite) TestDebugMigrateStateDryRunNothingToDo(c *C) {
+	restore := patch.Mock(2, 1, nil)
+	defer restore()
+	s.mockStateAtPatchLevel(c, 2, 1)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state", "--dry-run"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "State is at patch level 2.1, nothing to migrate.\n")
+}
+
+func (s *SnapSuite) TestDebugMigrateStateDryRunError(c *C) {
+	restore := patch.Mock(2, 1, map[int][]patch.PatchFunc{
+		2: {
+			func(st *state.State) error { return nil },
+			func(st *state.State) error { return fmt.Errorf("boom") },
+		},
+	})
+	defer restore()
+	s.mockStateAtPatchLevel(c, 2, 0)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state", "--dry-run"})
+	c.Assert(err, ErrorMatches, `cannot migrate state from patch level 2.0: cannot patch system state to level 2, sublevel 1: boom`)
+}
+
+func (s *SnapSuite) TestDebugMigrateStateRollback(c *C) {
+	before := s.mockStateAtPatchLevel(c, 2, 0)
+	c.Assert(patch.Backup(dirs.SnapStateFile), IsNil)
+	s.mockStateAtPatchLevel(c, 2, 1)
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state", "--rollback"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "State restored to before it was last migrated.\n")
+	c.Check(dirs.SnapStateFile, testutil.FileEquals, before)
+	c.Check(patch.BackupFile(dirs.SnapStateFile), testutil.FileAbsent)
+}
+
+func (s *SnapSuite) TestDebugMigrateStateRollbackNoBackup(c *C) {
+	s.mockStateAtPatchLevel(c, 2, 1)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state", "--rollback"})
+	c.Assert(err, ErrorMatches, "cannot roll back: no backup of the state from before patching")
+}
+
+func (s *SnapSuite) TestDebugMigrateStateRollbackSnapdRunning(c *C) {
+	s.mockStateAtPatchLevel(c, 2, 0)
+	c.Assert(patch.Backup(dirs.SnapStateFile), IsNil)
+	after := s.mockStateAtPatchLevel(c, 2, 1)
+
+	// snapd holds the state lock
+	flock, err := osutil.NewFileLock(dirs.SnapStateLockFile)
+	c.Assert(err, IsNil)
+	defer flock.Close()
+	c.Assert(flock.Lock(), IsNil)
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state", "--rollback"})
+	c.Assert(err, ErrorMatches, "cannot roll back the state while snapd is running")
+	c.Check(dirs.SnapStateFile, testutil.FileEquals, after)
+}
+
+func (s *SnapSuite) TestDebugMigrateStateDryRunAndRollback(c *C) {
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "migrate-state", "--dry-run", "--rollback"})
+	c.Assert(err, ErrorMatches, "cannot use --dry-run and --rollback together")
+}
@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdDebugReboots struct {
+	clientMixin
+	timeMixin
+	unicodeMixin
+}
+
+func init() {
+	addDebugCommand("reboots",
+		"(internal) list the reboots requested by snapd",
+		"(internal) list the reboots requested by snapd, with their reasons",
+		func() flags.Commander {
+			return &cmdDebugReboots{}
+		}, timeDescs.also(unicodeDescs), nil)
+}
+
+type rebootRecord struct {
+	Code        string     `json:"code"`
+	ChangeID    string     `json:"change-id,omitempty"`
+	TaskID      string     `json:"task-id,omitempty"`
+	Snap        string     `json:"snap,omitempty"`
+	Action      string     `json:"action"`
+	RequestTime time.Time  `json:"request-time"`
+	RebootTime  *time.Time `json:"reboot-time,omitempty"`
+}
+
+func (x *cmdDebugReboots) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	var records []rebootRecord
+	if err := x.client.DebugGet("reboots", &records, nil); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No reboots were requested."))
+		return nil
+	}
+
+	esc := x.getEscapes()
+	orDash := func(s string) string {
+		if s == "" {
+			return esc.dash
+		}
+		return s
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Requested\tRebooted\tAction\tReason\tSnap\tChange\tTask"))
+	for _, rec := range records {
+		rebooted := i18n.G("pending")
+		if rec.RebootTime != nil {
+			rebooted = x.fmtTime(*rec.RebootTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", x.fmtTime(rec.RequestTime), rebooted,
+			rec.Action, rec.Code, orDash(rec.Snap), orDash(rec.ChangeID), orDash(rec.TaskID))
+	}
+	return nil
+}
@@ -20,6 +20,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -53,7 +54,8 @@ type cmdDebugState struct {
 	IsSeeded bool `long:"is-seeded"`
 
 	// flags for --change=N output
-	DotOutput bool `long:"dot"` // XXX: mildly useful (too crowded in many cases), but let's have it just in case
+	DotOutput  bool `long:"dot"` // XXX: mildly useful (too crowded in many cases), but let's have it just in case
+	JSONOutput bool `long:"json"`
 	// When inspecting errors/undone tasks, those in Hold state are usually irrelevant, make it possible to ignore them
 	NoHoldState bool `long:"no-hold"`
 
@@ -81,7 +83,8 @@ func loadState(path string) (*state.State, error) {
 	}
 	defer r.Close()
 
-	return state.ReadState(nil, r)
+	// the sensitive parts of the state may be encrypted at rest
+	return state.ReadEncryptedState(nil, r)
 }
 
 func init() {
@@ -91,7 +94,8 @@ func init() {
 		// TRANSLATORS: This should not start with a lowercase letter.
 		"change":      i18n.G("ID of the change to inspect"),
 		"task":        i18n.G("ID of the task to inspect"),
-		"dot":         i18n.G("Dot (graphviz) output"),
+		"dot":         i18n.G("Dot (graphviz) output of the task dependencies"),
+		"json":        i18n.G("JSON output of the task dependencies"),
 		"no-hold":     i18n.G("Omit tasks in 'Hold' state in the change output"),
 		"changes":     i18n.G("List all changes"),
 		"connections": i18n.G("List all connections"),
@@ -134,27 +138,110 @@ func (t *byLaneAndWaitTaskChain) waitChainSearch(startT, searchT *state.Task, se
 	return false
 }
 
+// dotStatusColors maps task statuses to the fill colors used in the dot
+// output.
+var dotStatusColors = map[string]string{
+	"Doing":   "yellow",
+	"Done":    "palegreen",
+	"Undoing": "orange",
+	"Undone":  "orange",
+	"Error":   "salmon",
+	"Wait":    "lightblue",
+	"Hold":    "lightgrey",
+}
+
+func (c *cmdDebugState) changeGraph(st *state.State, changeID string) (*state.ChangeGraph, error) {
+	chg := st.Change(changeID)
+	if chg == nil {
+		return nil, fmt.Errorf("no such change: %s", changeID)
+	}
+	g := chg.Graph()
+	if !c.NoHoldState {
+		return g, nil
+	}
+	held := make(map[string]bool)
+	for _, t := range g.Tasks {
+		if t.Status == state.HoldStatus.String() {
+			held[t.ID] = true
+		}
+	}
+	tasks := g.Tasks[:0]
+	for _, t := range g.Tasks {
+		if held[t.ID] {
+			continue
+		}
+		var waitTasks []string
+		for _, id := range t.WaitTasks {
+			if !held[id] {
+				waitTasks = append(waitTasks, id)
+			}
+		}
+		t.WaitTasks = waitTasks
+		tasks = append(tasks, t)
+	}
+	g.Tasks = tasks
+	return g, nil
+}
+
 func (c *cmdDebugState) writeDotOutput(st *state.State, changeID string) error {
 	st.Lock()
 	defer st.Unlock()
 
-	chg := st.Change(changeID)
-	if chg == nil {
-		return fmt.Errorf("no such change: %s", changeID)
+	g, err := c.changeGraph(st, changeID)
+	if err != nil {
+		return err
+	}
+
+	inChange := make(map[string]bool, len(g.Tasks))
+	// group the tasks by their lanes, tasks without lanes are not
+	// clustered
+	var laneKeys []string
+	byLanes := make(map[string][]state.ChangeGraphTask)
+	for _, t := range g.Tasks {
+		inChange[t.ID] = true
+		key := strutil.IntsToCommaSeparated(t.Lanes)
+		if _, ok := byLanes[key]; !ok {
+			laneKeys = append(laneKeys, key)
+		}
+		byLanes[key] = append(byLanes[key], t)
+	}
+
+	writeNode := func(indent string, t state.ChangeGraphTask) {
+		attrs := fmt.Sprintf("label=%q", fmt.Sprintf("%s %s\n%s", t.ID, t.Kind, t.Status))
+		if color, ok := dotStatusColors[t.Status]; ok {
+			attrs += fmt.Sprintf(", style=filled, fillcolor=%s", color)
+		}
+		fmt.Fprintf(Stdout, "%s%s [%s];\n", indent, t.ID, attrs)
 	}
 
 	fmt.Fprintf(Stdout, "digraph D{\n")
-	tasks := chg.Tasks()
-	for _, t := range tasks {
-		if c.NoHoldState && t.Status() == state.HoldStatus {
+	fmt.Fprintf(Stdout, "  label=%q;\n", fmt.Sprintf("%s %s (%s)", g.ID, g.Kind, g.Status))
+	for _, key := range laneKeys {
+		if key == "0" {
+			for _, t := range byLanes[key] {
+				writeNode("  ", t)
+			}
 			continue
 		}
-		fmt.Fprintf(Stdout, "  %s [label=%q];\n", t.ID(), t.Kind())
-		for _, wt := range t.WaitTasks() {
-			if c.NoHoldState && wt.Status() == state.HoldStatus {
-				continue
+		fmt.Fprintf(Stdout, "  subgraph \"cluster_lanes_%s\" {\n", key)
+		fmt.Fprintf(Stdout, "    label=%q;\n", "lanes "+key)
+		for _, t := range byLanes[key] {
+			writeNode("    ", t)
+		}
+		fmt.Fprintf(Stdout, "  }\n")
+	}
+	for _, t := range g.Tasks {
+		for _, id := range t.WaitTasks {
+			if !inChange[id] {
+				// the task waits for a task of another change
+				label := id
+				if wt := st.Task(id); wt != nil && wt.Change() != nil {
+					label = fmt.Sprintf("%s (change %s)", id, wt.Change().ID())
+				}
+				fmt.Fprintf(Stdout, "  %s [label=%q, style=dashed];\n", id, label)
+				inChange[id] = true
 			}
-			fmt.Fprintf(Stdout, "  %s -> %s;\n", t.ID(), wt.ID())
+			fmt.Fprintf(Stdout, "  %s -> %s;\n", t.ID, id)
 		}
 	}
 	fmt.Fprintf(Stdout, "}\n")
@@ -162,6 +249,19 @@ func (c *cmdDebugState) writeDotOutput(st *state.State, changeID string) error {
 	return nil
 }
 
+func (c *cmdDebugState) writeJSONOutput(st *state.State, changeID string) error {
+	st.Lock()
+	defer st.Unlock()
+
+	g, err := c.changeGraph(st, changeID)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
 func (c *cmdDebugState) showTasks(st *state.State, changeID string) error {
 	st.Lock()
 	defer st.Unlock()
@@ -531,6 +631,12 @@ func (c *cmdDebugState) Execute(args []string) error {
 	if c.DotOutput && c.ChangeID == "" {
 		return fmt.Errorf("--dot can only be used with --change=")
 	}
+	if c.JSONOutput && c.ChangeID == "" {
+		return fmt.Errorf("--json can only be used with --change=")
+	}
+	if c.DotOutput && c.JSONOutput {
+		return fmt.Errorf("cannot use --dot and --json together")
+	}
 	if c.NoHoldState && c.ChangeID == "" {
 		return fmt.Errorf("--no-hold can only be used with --change=")
 	}
@@ -550,6 +656,9 @@ func (c *cmdDebugState) Execute(args []string) error {
 		if c.DotOutput {
 			return c.writeDotOutput(st, c.ChangeID)
 		}
+		if c.JSONOutput {
+			return c.writeJSONOutput(st, c.ChangeID)
+		}
 		if c.Check {
 			return c.checkTasks(st, c.ChangeID)
 		}
@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -342,6 +343,139 @@ func (s *SnapSuite) TestDebugTasksWithCycles(c *C) {
 	c.Check(s.Stderr(), Equals, "")
 }
 
+var stateGraphJSON = []byte(`
+{
+	"last-task-id": 15,
+	"last-change-id": 2,
+
+	"data": {},
+	"changes": {
+		"1": {
+			"id": "1",
+			"kind": "install-snap",
+			"summary": "install a snap",
+			"status": 0,
+			"task-ids": ["11","12","13","15"]
+		},
+		"2": {
+			"id": "2",
+			"kind": "other",
+			"summary": "other change",
+			"status": 0,
+			"task-ids": ["14"]
+		}
+	},
+	"tasks": {
+		"11": {
+			"id": "11",
+			"change": "1",
+			"kind": "foo",
+			"summary": "Foo task",
+			"status": 4,
+			"halt-tasks": ["12","13"],
+			"lanes": [1,2]
+		},
+		"12": {
+			"id": "12",
+			"change": "1",
+			"kind": "bar",
+			"summary": "Bar task",
+			"wait-tasks": ["11","14"],
+			"lanes": [1]
+		},
+		"13": {
+			"id": "13",
+			"change": "1",
+			"kind": "baz",
+			"summary": "Baz task",
+			"status": 9,
+			"wait-tasks": ["11"],
+			"halt-tasks": ["15"]
+		},
+		"14": {
+			"id": "14",
+			"change": "2",
+			"kind": "qux",
+			"summary": "Qux task",
+			"halt-tasks": ["12"]
+		},
+		"15": {
+			"id": "15",
+			"change": "1",
+			"kind": "held",
+			"summary": "Held task",
+			"status": 1,
+			"wait-tasks": ["13"]
+		}
+	}
+}
+`)
+
+func (s *SnapSuite) TestDebugTasksDot(c *C) {
+	dir := c.MkDir()
+	stateFile := filepath.Join(dir, "test-state.json")
+	c.Assert(ioutil.WriteFile(stateFile, stateGraphJSON, 0644), IsNil)
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--change=1", "--dot", "--no-hold", stateFile})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, `digraph D{
+  label="1 install-snap (Do)";
+  subgraph "cluster_lanes_1,2" {
+    label="lanes 1,2";
+    11 [label="11 foo\nDone", style=filled, fillcolor=palegreen];
+  }
+  subgraph "cluster_lanes_1" {
+    label="lanes 1";
+    12 [label="12 bar\nDo"];
+  }
+  13 [label="13 baz\nError", style=filled, fillcolor=salmon];
+  12 -> 11;
+  14 [label="14 (change 2)", style=dashed];
+  12 -> 14;
+  13 -> 11;
+}
+`)
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestDebugTasksJSON(c *C) {
+	dir := c.MkDir()
+	stateFile := filepath.Join(dir, "test-state.json")
+	c.Assert(ioutil.WriteFile(stateFile, stateGraphJSON, 0644), IsNil)
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--change=1", "--json", stateFile})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	var g map[string]interface{}
+	c.Assert(json.Unmarshal(s.stdout.Bytes(), &g), IsNil)
+	c.Check(g["id"], Equals, "1")
+	c.Check(g["kind"], Equals, "install-snap")
+	tasks := g["tasks"].([]interface{})
+	c.Assert(tasks, HasLen, 4)
+	c.Check(tasks[1], DeepEquals, map[string]interface{}{
+		"id":         "12",
+		"kind":       "bar",
+		"summary":    "Bar task",
+		"status":     "Do",
+		"lanes":      []interface{}{1.0},
+		"wait-tasks": []interface{}{"11", "14"},
+	})
+	c.Check(tasks[3].(map[string]interface{})["status"], Equals, "Hold")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestDebugTasksGraphErrors(c *C) {
+	dir := c.MkDir()
+	stateFile := filepath.Join(dir, "test-state.json")
+	c.Assert(ioutil.WriteFile(stateFile, stateGraphJSON, 0644), IsNil)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--json", stateFile})
+	c.Check(err, ErrorMatches, "--json can only be used with --change=")
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--change=1", "--json", "--dot", stateFile})
+	c.Check(err, ErrorMatches, "cannot use --dot and --json together")
+}
+
 func (s *SnapSuite) TestDebugCheckForCycles(c *C) {
 	// we use local time when printing times in a human-friendly format, which can
 	// break the comparison below
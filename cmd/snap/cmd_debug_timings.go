@@ -37,6 +37,7 @@ type cmdChangeTimings struct {
 	All        bool   `long:"all"`
 	StartupTag string `long:"startup" choice:"load-state" choice:"ifacemgr"`
 	Verbose    bool   `long:"verbose"`
+	Aggregate  string `long:"aggregate"`
 }
 
 func init() {
@@ -46,9 +47,10 @@ func init() {
 		func() flags.Commander {
 			return &cmdChangeTimings{}
 		}, changeIDMixinOptDesc.also(map[string]string{
-			"ensure":  i18n.G("Show timings for a change related to the given Ensure activity (one of: auto-refresh, become-operational, refresh-catalogs, refresh-hints, seed)"),
-			"all":     i18n.G("Show timings for all executions of the given Ensure or startup activity, not just the latest"),
-			"startup": i18n.G("Show timings for the startup of given subsystem (one of: load-state, ifacemgr)"),
+			"ensure":    i18n.G("Show timings for a change related to the given Ensure activity (one of: auto-refresh, become-operational, refresh-catalogs, refresh-hints, seed)"),
+			"all":       i18n.G("Show timings for all executions of the given Ensure or startup activity, not just the latest"),
+			"startup":   i18n.G("Show timings for the startup of given subsystem (one of: load-state, ifacemgr)"),
+			"aggregate": i18n.G("Show statistics of the timings of the given kind of activity (e.g. refresh or seed) recorded over the lifetime of the device"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"verbose": i18n.G("Show more information"),
 		}), changeIDMixinArgDesc)
@@ -214,11 +216,11 @@ type timingsData struct {
 
 func (x *cmdChangeTimings) checkConflictingFlags() error {
 	var i int
-	for _, opt := range []string{string(x.Positional.ID), x.StartupTag, x.EnsureTag} {
+	for _, opt := range []string{string(x.Positional.ID), x.StartupTag, x.EnsureTag, x.Aggregate} {
 		if opt != "" {
 			i++
 			if i > 1 {
-				return fmt.Errorf("cannot use change id, 'startup' or 'ensure' together")
+				return fmt.Errorf("cannot use change id, 'startup', 'ensure' or 'aggregate' together")
 			}
 		}
 	}
@@ -226,6 +228,9 @@ func (x *cmdChangeTimings) checkConflictingFlags() error {
 	if x.All && (x.Positional.ID != "" || x.LastChangeType != "") {
 		return fmt.Errorf("cannot use 'all' with change id or 'last'")
 	}
+	if x.Aggregate != "" && (x.All || x.LastChangeType != "") {
+		return fmt.Errorf("cannot use 'aggregate' with 'all' or 'last'")
+	}
 	return nil
 }
 
@@ -238,6 +243,10 @@ func (x *cmdChangeTimings) Execute(args []string) error {
 		return err
 	}
 
+	if x.Aggregate != "" {
+		return x.printAggregate()
+	}
+
 	var chgid string
 	var err error
 
@@ -292,3 +301,41 @@ func (x *cmdChangeTimings) Execute(args []string) error {
 
 	return nil
 }
+
+type timingsAggregate struct {
+	Kind     string        `json:"kind"`
+	Count    int           `json:"count"`
+	Boots    int           `json:"boots"`
+	Min      time.Duration `json:"min"`
+	Average  time.Duration `json:"average"`
+	Max      time.Duration `json:"max"`
+	Last     time.Duration `json:"last"`
+	LastTime time.Time     `json:"last-time"`
+}
+
+func (x *cmdChangeTimings) printAggregate() error {
+	var aggregates []*timingsAggregate
+	if err := x.client.DebugGet("timings-aggregate", &aggregates, map[string]string{"kind": x.Aggregate}); err != nil {
+		return err
+	}
+	if len(aggregates) == 0 {
+		fmt.Fprintf(Stderr, i18n.G("No timings of %q recorded.\n"), x.Aggregate)
+		return nil
+	}
+
+	w := tabWriter()
+	if x.Verbose {
+		fmt.Fprintf(w, "Kind\tCount\tBoots\t%11s\t%11s\t%11s\t%11s\tLast-Time\n", "Min", "Average", "Max", "Last")
+	} else {
+		fmt.Fprintf(w, "Kind\tCount\tBoots\t%11s\t%11s\t%11s\t%11s\n", "Min", "Average", "Max", "Last")
+	}
+	for _, agg := range aggregates {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%11s\t%11s\t%11s\t%11s", agg.Kind, agg.Count, agg.Boots, formatDuration(agg.Min), formatDuration(agg.Average), formatDuration(agg.Max), formatDuration(agg.Last))
+		if x.Verbose {
+			fmt.Fprintf(w, "\t%s", agg.LastTime.UTC().Format(time.RFC3339))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	return nil
+}
@@ -39,19 +39,25 @@ var timingsTests = []timingsCmdArgs{{
 	error: "please provide change ID or type with --last=<type>, or query for --ensure=<name> or --startup=<name>",
 }, {
 	args:  "debug timings --ensure=seed 9",
-	error: "cannot use change id, 'startup' or 'ensure' together",
+	error: "cannot use change id, 'startup', 'ensure' or 'aggregate' together",
 }, {
 	args:  "debug timings --ensure=seed --startup=ifacemgr",
-	error: "cannot use change id, 'startup' or 'ensure' together",
+	error: "cannot use change id, 'startup', 'ensure' or 'aggregate' together",
 }, {
 	args:  "debug timings --last=install --all",
 	error: "cannot use 'all' with change id or 'last'",
+}, {
+	args:  "debug timings --aggregate=refresh --ensure=seed",
+	error: "cannot use change id, 'startup', 'ensure' or 'aggregate' together",
+}, {
+	args:  "debug timings --aggregate=refresh --all",
+	error: "cannot use 'aggregate' with 'all' or 'last'",
 }, {
 	args:  "debug timings --last=remove",
 	error: `no changes of type "remove" found`,
 }, {
 	args:  "debug timings --startup=load-state 9",
-	error: "cannot use change id, 'startup' or 'ensure' together",
+	error: "cannot use change id, 'startup', 'ensure' or 'aggregate' together",
 }, {
 	args:  "debug timings --all 9",
 	error: "cannot use 'all' with change id or 'last'",
@@ -132,6 +138,19 @@ var timingsTests = []timingsCmdArgs{{
 		" ^                        8ms            -    baz summary\n" +
 		"ifacemgr                  9ms            -  \n" +
 		" ^                        9ms            -    baz summary\n\n",
+}, {
+	args: "debug timings --aggregate=refresh",
+	stdout: "Kind                 Count  Boots          Min      Average          Max         Last\n" +
+		"change:refresh-snap  3      2           2000ms       4000ms       6000ms       6000ms\n" +
+		"ensure:auto-refresh  1      1            100ms        100ms        100ms        100ms\n",
+}, {
+	args: "debug timings --aggregate=refresh --verbose",
+	stdout: "Kind                 Count  Boots          Min      Average          Max         Last  Last-Time\n" +
+		"change:refresh-snap  3      2           2000ms       4000ms       6000ms       6000ms  2024-01-02T05:04:05Z\n" +
+		"ensure:auto-refresh  1      1            100ms        100ms        100ms        100ms  2024-01-02T03:04:05Z\n",
+}, {
+	args:   "debug timings --aggregate=remove",
+	stderr: "No timings of \"remove\" recorded.\n",
 }}
 
 func (s *SnapSuite) TestGetDebugTimings(c *C) {
@@ -164,6 +183,18 @@ func (s *SnapSuite) mockCmdTimingsAPI(c *C) {
 		if r.URL.Path == "/v2/debug" {
 			q := r.URL.Query()
 			aspect := q.Get("aspect")
+			if aspect == "timings-aggregate" {
+				switch q.Get("kind") {
+				case "refresh":
+					fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[
+						{"kind":"change:refresh-snap","count":3,"boots":2,"min":2000000000,"average":4000000000,"max":6000000000,"last":6000000000,"last-time":"2024-01-02T05:04:05Z"},
+						{"kind":"ensure:auto-refresh","count":1,"boots":1,"min":100000000,"average":100000000,"max":100000000,"last":100000000,"last-time":"2024-01-02T03:04:05Z"}
+					]}`)
+				default:
+					fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[]}`)
+				}
+				return
+			}
 			c.Assert(aspect, Equals, "change-timings")
 
 			changeID := q.Get("change-id")
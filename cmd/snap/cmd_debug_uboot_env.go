@@ -0,0 +1,74 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/bootloader/ubootenv"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/release"
+)
+
+type cmdDebugUbootEnv struct {
+	Positional struct {
+		Action string         `positional-arg-name:"<action>" required:"1"`
+		File   flags.Filename `positional-arg-name:"<file>" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	cmd := addDebugCommand("uboot-env",
+		"(internal) inspect a U-Boot environment file",
+		"(internal) inspect a U-Boot environment file\n\nThe check action verifies that the environment is well formed.",
+		func() flags.Commander {
+			return &cmdDebugUbootEnv{}
+		}, nil, []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<action>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("Action to perform, only check is supported"),
+		}, {
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<file>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("Path to the U-Boot environment file"),
+		}})
+	if release.OnClassic {
+		cmd.hidden = true
+	}
+}
+
+func (x *cmdDebugUbootEnv) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if x.Positional.Action != "check" {
+		return fmt.Errorf(i18n.G("unsupported action %q"), x.Positional.Action)
+	}
+	fname := string(x.Positional.File)
+	if err := ubootenv.Validate(fname); err != nil {
+		return fmt.Errorf(i18n.G("invalid U-Boot environment %q: %v"), fname, err)
+	}
+	fmt.Fprintf(Stdout, i18n.G("U-Boot environment %q is valid\n"), fname)
+	return nil
+}
@@ -0,0 +1,59 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/bootloader/ubootenv"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDebugUbootEnvCheck(c *check.C) {
+	envFile := filepath.Join(c.MkDir(), "uboot.env")
+	env, err := ubootenv.Create(envFile, 4096)
+	c.Assert(err, check.IsNil)
+	env.Set("snap_mode", "try")
+	c.Assert(env.Save(), check.IsNil)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "uboot-env", "check", envFile})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `U-Boot environment "`+envFile+`" is valid`+"\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestDebugUbootEnvCheckCorrupted(c *check.C) {
+	envFile := filepath.Join(c.MkDir(), "uboot.env")
+	err := ioutil.WriteFile(envFile, []byte("\x00\x00\x00\x00\x00foo=bar\x00\x00"), 0644)
+	c.Assert(err, check.IsNil)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"debug", "uboot-env", "check", envFile})
+	c.Assert(err, check.ErrorMatches, `invalid U-Boot environment ".*/uboot.env": cannot open ".*": bad CRC 0 != .*`)
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestDebugUbootEnvUnsupportedAction(c *check.C) {
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"debug", "uboot-env", "fix", "/some/file"})
+	c.Assert(err, check.ErrorMatches, `unsupported action "fix"`)
+}
@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap"
+)
+
+type cmdDiff struct {
+	clientMixin
+	Positionals struct {
+		Snap installedSnapName `required:"yes"`
+		From string            `positional-arg-name:"<rev1>" required:"yes"`
+		To   string            `positional-arg-name:"<rev2>" required:"yes"`
+	} `positional-args:"true"`
+}
+
+var shortDiffHelp = i18n.G("Compare two revisions of a snap")
+var longDiffHelp = i18n.G(`
+The diff command compares two revisions of a snap that are available on
+the system: the files added, removed or modified between them, the
+changes to their snap.yaml metadata and apps, and the plugs, slots and
+hooks they define.
+`)
+
+func init() {
+	addCommand("diff", shortDiffHelp, longDiffHelp, func() flags.Commander {
+		return &cmdDiff{}
+	}, nil, []argDesc{
+		// TRANSLATORS: This needs to begin with < and end with >
+		{name: i18n.G("<snap>")},
+		// TRANSLATORS: This needs to begin with < and end with >
+		{name: i18n.G("<rev1>"), desc: i18n.G("The revision to compare from")},
+		// TRANSLATORS: This needs to begin with < and end with >
+		{name: i18n.G("<rev2>"), desc: i18n.G("The revision to compare to")},
+	})
+}
+
+func (x *cmdDiff) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapName := string(x.Positionals.Snap)
+	from, err := snap.ParseRevision(x.Positionals.From)
+	if err != nil {
+		return err
+	}
+	to, err := snap.ParseRevision(x.Positionals.To)
+	if err != nil {
+		return err
+	}
+
+	diff, err := x.client.SnapDiff(snapName, from, to)
+	if err != nil {
+		return err
+	}
+
+	if diff.Files.Empty() && len(diff.Metadata) == 0 && diff.Apps.Empty() &&
+		diff.Plugs.Empty() && diff.Slots.Empty() && diff.Hooks.Empty() {
+		fmt.Fprintf(Stdout, i18n.G("Revisions %s and %s of snap %q are identical.\n"), from, to, snapName)
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	if len(diff.Metadata) > 0 {
+		fmt.Fprintln(w, i18n.G("Metadata:"))
+		for _, change := range diff.Metadata {
+			fmt.Fprintf(w, "  %s:\t%q -> %q\n", change.Field, change.From, change.To)
+		}
+	}
+	showDiffEntries(w, i18n.G("Apps:"), &diff.Apps)
+	showDiffEntries(w, i18n.G("Plugs:"), &diff.Plugs)
+	showDiffEntries(w, i18n.G("Slots:"), &diff.Slots)
+	showDiffEntries(w, i18n.G("Hooks:"), &diff.Hooks)
+	showDiffEntries(w, i18n.G("Files:"), &diff.Files)
+
+	return nil
+}
+
+// showDiffEntries writes a section listing the added, removed and
+// modified entries, marked like in a diff, if there are any.
+func showDiffEntries(w io.Writer, header string, entries *client.DiffEntries) {
+	if entries.Empty() {
+		return
+	}
+	fmt.Fprintln(w, header)
+	for _, name := range entries.Added {
+		fmt.Fprintf(w, "  + %s\n", name)
+	}
+	for _, name := range entries.Removed {
+		fmt.Fprintf(w, "  - %s\n", name)
+	}
+	for _, name := range entries.Modified {
+		fmt.Fprintf(w, "  M %s\n", name)
+	}
+}
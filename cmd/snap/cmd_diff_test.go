@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestDiff(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo/diff")
+			c.Check(r.URL.Query().Get("from"), check.Equals, "1")
+			c.Check(r.URL.Query().Get("to"), check.Equals, "2")
+			fmt.Fprintln(w, `{"type": "sync", "result": {
+"snap": "foo", "from": "1", "to": "2",
+"files": {"added": ["bin/tool"], "removed": ["bin/svc"], "modified": ["bin/app", "meta/snap.yaml"]},
+"metadata": [{"field": "version", "from": "1.0", "to": "2.0"}, {"field": "base", "from": "", "to": "core20"}],
+"apps": {"added": ["tool"], "removed": ["svc"]},
+"plugs": {"modified": ["data"]},
+"slots": {},
+"hooks": {"added": ["post-refresh"]}
+}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"diff", "foo", "1", "2"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `
+Metadata:
+  version:  "1.0" -> "2.0"
+  base:     "" -> "core20"
+Apps:
+  + tool
+  - svc
+Plugs:
+  M data
+Hooks:
+  + post-refresh
+Files:
+  + bin/tool
+  - bin/svc
+  M bin/app
+  M meta/snap.yaml
+`[1:])
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestDiffIdentical(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {"snap": "foo", "from": "1", "to": "x1"}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"diff", "foo", "1", "x1"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Revisions 1 and x1 of snap \"foo\" are identical.\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestDiffErrors(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "revision 3 of snap \"foo\" is not installed", "kind": "snap-not-installed"}}`)
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"diff", "foo", "bar", "2"})
+	c.Check(err, check.ErrorMatches, `invalid snap revision: "bar"`)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"diff", "foo", "1", "3"})
+	c.Check(err, check.ErrorMatches, `revision 3 of snap "foo" is not installed`)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"diff", "foo", "1"})
+	c.Check(err, check.ErrorMatches, `the required argument .<rev2>. was not provided`)
+}
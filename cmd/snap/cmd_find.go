@@ -45,6 +45,10 @@ With the --private flag, which requires the user to be logged-in to the store
 has developer access to, either directly or through the store's collaboration
 feature.
 
+With the --offline flag the store is not contacted, the search is instead
+answered from the search index snapd refreshes daily, or from an offline
+catalog if one is provided. Only the details kept in the index are shown.
+
 A green check mark (given color and unicode support) after a publisher name
 indicates that the publisher has been verified.
 `)
@@ -154,6 +158,8 @@ type cmdFind struct {
 	Private    bool        `long:"private"`
 	Narrow     bool        `long:"narrow"`
 	Section    SectionName `long:"section" optional:"true" optional-value:"show-all-sections-please" default:"no-section-specified" default-mask:"-"`
+	Category   SectionName `long:"category" optional:"true" optional-value:"show-all-sections-please" default:"no-section-specified" default-mask:"-"`
+	Offline    bool        `long:"offline"`
 	Positional struct {
 		Query []string
 	} `positional-args:"yes"`
@@ -170,6 +176,10 @@ func init() {
 		"narrow": i18n.G("Only search for snaps in “stable”."),
 		// TRANSLATORS: This should not start with a lowercase letter.
 		"section": i18n.G("Restrict the search to a given section."),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"category": i18n.G("Restrict the search to a given category (same as --section)."),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"offline": i18n.G("Search the local search index instead of the store."),
 	}), []argDesc{{
 		// TRANSLATORS: This needs to begin with < and end with >
 		name: i18n.G("<query>"),
@@ -188,6 +198,13 @@ func (x *cmdFind) Execute(args []string) error {
 		query = ""
 	}
 
+	if x.Category != "no-section-specified" {
+		if x.Section != "no-section-specified" {
+			return errors.New(i18n.G("cannot use --section and --category together"))
+		}
+		x.Section = x.Category
+	}
+
 	// section will be:
 	// - "show-all-sections-please" if the user specified --section
 	//   without any argument
@@ -211,7 +228,14 @@ func (x *cmdFind) Execute(args []string) error {
 		if err != nil {
 			return err
 		}
-		if !strutil.ListContains(sections, string(x.Section)) {
+		if x.Offline {
+			// do not ask the store, without cached sections
+			// leave it to the search
+			if sections != nil && !strutil.ListContains(sections, string(x.Section)) {
+				// TRANSLATORS: the %q is the (quoted) name of the section the user entered
+				return fmt.Errorf(i18n.G("No matching section %q, use --section to list existing sections"), x.Section)
+			}
+		} else if !strutil.ListContains(sections, string(x.Section)) {
 			// try the store just in case it was added in the last 24 hours
 			sections, err = x.client.Sections()
 			if err != nil {
@@ -233,6 +257,9 @@ func (x *cmdFind) Execute(args []string) error {
 	if !x.Narrow {
 		opts.Scope = "wide"
 	}
+	if x.Offline {
+		opts.Offline = "only"
+	}
 
 	snaps, resInfo, err := x.client.Find(opts)
 	if e, ok := err.(*client.Error); ok && (e.Kind == client.ErrorKindNetworkTimeout || e.Kind == client.ErrorKindDNSFailure) {
@@ -699,3 +699,42 @@ Please try 'snap find --section=<selected section>'
 	s.ResetStdStreams()
 	c.Check(numHits, check.Equals, 1)
 }
+
+func (s *SnapSuite) TestFindOfflineCategory(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			q := r.URL.Query()
+			c.Check(q, check.HasLen, 3)
+			c.Check(q.Get("section"), check.Equals, "sec2")
+			c.Check(q.Get("scope"), check.Equals, "wide")
+			c.Check(q.Get("offline"), check.Equals, "only")
+			fmt.Fprint(w, findHelloJSON)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+		n++
+	})
+
+	os.MkdirAll(path.Dir(dirs.SnapSectionsFile), 0755)
+	ioutil.WriteFile(dirs.SnapSectionsFile, []byte("sec1\nsec2\nsec3"), 0644)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"find", "--category=sec2", "--offline"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?s)Name +Version +Publisher +Notes +Summary
+hello +2.10 .*`)
+	c.Check(n, check.Equals, 1)
+	s.ResetStdStreams()
+
+	// the store is not asked about unknown sections
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"find", "--category=foobar", "--offline"})
+	c.Assert(err, check.ErrorMatches, `No matching section "foobar", use --section to list existing sections`)
+	c.Check(n, check.Equals, 1)
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"find", "--category=sec1", "--section=sec2"})
+	c.Assert(err, check.ErrorMatches, `cannot use --section and --category together`)
+}
@@ -205,13 +205,14 @@ var helpCategories = []helpCategory{
 		Description: i18n.G("basic snap management"),
 		Commands:    []string{"find", "info", "install", "remove", "list"},
 	}, {
-		Label:       i18n.G("...more"),
-		Description: i18n.G("slightly more advanced snap management"),
-		Commands:    []string{"refresh", "revert", "switch", "disable", "enable", "create-cohort"},
+		Label:           i18n.G("...more"),
+		Description:     i18n.G("slightly more advanced snap management"),
+		Commands:        []string{"refresh", "revert", "switch", "disable", "enable", "create-cohort"},
+		AllOnlyCommands: []string{"diff"},
 	}, {
 		Label:       i18n.G("History"),
 		Description: i18n.G("manage system change transactions"),
-		Commands:    []string{"changes", "tasks", "abort", "watch"},
+		Commands:    []string{"changes", "tasks", "abort", "watch", "wait-change"},
 	}, {
 		Label:       i18n.G("Daemons"),
 		Description: i18n.G("manage services"),
@@ -227,7 +228,7 @@ var helpCategories = []helpCategory{
 	}, {
 		Label:       i18n.G("App Aliases"),
 		Description: i18n.G("manage aliases"),
-		Commands:    []string{"alias", "aliases", "unalias", "prefer"},
+		Commands:    []string{"alias", "aliases", "unalias", "prefer", "enable-alias-set", "disable-alias-set"},
 	}, {
 		Label:       i18n.G("Account"),
 		Description: i18n.G("authentication to snapd and the snap store"),
@@ -238,9 +239,10 @@ var helpCategories = []helpCategory{
 		Commands:        []string{"saved", "save", "check-snapshot", "restore", "forget"},
 		AllOnlyCommands: []string{"export-snapshot", "import-snapshot"},
 	}, {
-		Label:       i18n.G("Device"),
-		Description: i18n.G("manage device"),
-		Commands:    []string{"model", "reboot", "recovery"},
+		Label:           i18n.G("Device"),
+		Description:     i18n.G("manage device"),
+		Commands:        []string{"model", "reboot", "recovery"},
+		AllOnlyCommands: []string{"verify-image-manifest"},
 	}, {
 		Label:       i18n.G("Warnings"),
 		Other:       true,
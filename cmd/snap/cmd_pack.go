@@ -21,7 +21,10 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 	"golang.org/x/xerrors"
@@ -38,6 +41,7 @@ type packCmd struct {
 	CheckSkeleton bool   `long:"check-skeleton"`
 	Filename      string `long:"filename"`
 	Compression   string `long:"compression"`
+	Reproducible  bool   `long:"reproducible"`
 	Positional    struct {
 		SnapDir   string `positional-arg-name:"<snap-dir>"`
 		TargetDir string `positional-arg-name:"<target-dir>"`
@@ -61,6 +65,12 @@ valid snap metadata and raises an error otherwise. Application commands listed
 in snap metadata file, but appearing with incorrect permission bits result in an
 error. Commands that are missing from snap-dir are listed in diagnostic
 messages.
+
+When used with --reproducible, pack produces the same snap file, bit for bit,
+when packing the same content. The timestamps of all files are set to the
+value of the SOURCE_DATE_EPOCH environment variable, or to the Unix epoch if
+it is unset, and the parameters of the build are recorded in
+meta/build-info.yaml inside the snap.
 `)
 
 func init() {
@@ -76,6 +86,8 @@ func init() {
 			"filename": i18n.G("Output to this filename"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"compression": i18n.G("Compression to use (e.g. xz or lzo)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"reproducible": i18n.G("Produce a bit-identical snap for identical content"),
 		}, nil)
 	cmd.extra = func(cmd *flags.Command) {
 		// TRANSLATORS: this describes the default filename for a snap, e.g. core_16-2.35.2_amd64.snap
@@ -107,10 +119,23 @@ func (x *packCmd) Execute([]string) error {
 		return err
 	}
 
+	var sourceDateEpoch time.Time
+	if x.Reproducible {
+		if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+			secs, err := strconv.ParseInt(epoch, 10, 64)
+			if err != nil || secs < 0 {
+				return fmt.Errorf(i18n.G("cannot use SOURCE_DATE_EPOCH %q: not a valid timestamp"), epoch)
+			}
+			sourceDateEpoch = time.Unix(secs, 0)
+		}
+	}
+
 	snapPath, err := pack.Snap(x.Positional.SnapDir, &pack.Options{
-		TargetDir:   x.Positional.TargetDir,
-		SnapName:    x.Filename,
-		Compression: x.Compression,
+		TargetDir:       x.Positional.TargetDir,
+		SnapName:        x.Filename,
+		Compression:     x.Compression,
+		Reproducible:    x.Reproducible,
+		SourceDateEpoch: sourceDateEpoch,
 	})
 	if err != nil {
 		// TRANSLATORS: the %q is the snap-dir (the first positional
@@ -5,11 +5,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"gopkg.in/check.v1"
 
 	snaprun "github.com/snapcore/snapd/cmd/snap"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/testutil"
 )
 
 const packSnapYaml = `name: hello
@@ -140,3 +142,27 @@ func (s *SnapSuite) TestPackPacksASnapWithCompressionUnhappy(c *check.C) {
 		c.Assert(err, check.ErrorMatches, fmt.Sprintf(`cannot pack "/.*": cannot use compression %q`, comp))
 	}
 }
+
+func (s *SnapSuite) TestPackReproducible(c *check.C) {
+	snapDir := makeSnapDirForPack(c, "name: hello\nversion: 1.0")
+	mksq := testutil.MockCommand(c, "mksquashfs", "")
+	defer mksq.Restore()
+
+	os.Setenv("SOURCE_DATE_EPOCH", "1600000000")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	_, err := snaprun.Parser(snaprun.Client()).ParseArgs([]string{"pack", "--reproducible", snapDir, snapDir})
+	c.Assert(err, check.IsNil)
+	c.Assert(mksq.Calls(), check.HasLen, 1)
+	c.Check(strings.Join(mksq.Calls()[0], " "), testutil.Contains, " -all-time 1600000000 -mkfs-time 1600000000 -p meta/build-info.yaml ")
+}
+
+func (s *SnapSuite) TestPackReproducibleBadSourceDateEpoch(c *check.C) {
+	snapDir := makeSnapDirForPack(c, "name: hello\nversion: 1.0")
+
+	os.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	_, err := snaprun.Parser(snaprun.Client()).ParseArgs([]string{"pack", "--reproducible", snapDir, snapDir})
+	c.Assert(err, check.ErrorMatches, `cannot use SOURCE_DATE_EPOCH "yesterday": not a valid timestamp`)
+}
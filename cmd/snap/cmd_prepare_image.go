@@ -40,6 +40,10 @@ type cmdPrepareImage struct {
 	// optional sysfs overlay
 	SysfsOverlay string `long:"sysfs-overlay"`
 	Architecture string `long:"arch"`
+	DualBank     bool   `long:"dual-bank"`
+
+	Manifest        string `long:"manifest" value-name:"<manifest-file>"`
+	ManifestSignKey string `long:"manifest-sign-key"`
 
 	Positional struct {
 		ModelAssertionFn string
@@ -53,6 +57,8 @@ type cmdPrepareImage struct {
 	// TODO: introduce SnapWithChannel?
 	Snaps      []string `long:"snap" value-name:"<snap>[=<channel>]"`
 	ExtraSnaps []string `long:"extra-snaps" hidden:"yes"` // DEPRECATED
+
+	Assertions []string `long:"assertion" value-name:"<assertion-file>"`
 }
 
 func init() {
@@ -81,10 +87,18 @@ For preparing classic images it supports a --classic mode`),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"arch": i18n.G("Specify an architecture for snaps for --classic when the model does not"),
 			// TRANSLATORS: This should not start with a lowercase letter.
+			"dual-bank": i18n.G("Lay out and seed both system banks declared by the gadget (UC16/18 only)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"manifest": i18n.G("Write a build manifest of the image to the given file, signed into <manifest-file>.assert"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"manifest-sign-key": i18n.G("Name of the key to use to sign the image manifest assertion, otherwise use the default key"),
+			// TRANSLATORS: This should not start with a lowercase letter.
 			"snap": i18n.G("Include the given snap from the store or a local file and/or specify the channel to track for the given snap"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"extra-snaps": i18n.G("Extra snaps to be installed (DEPRECATED)"),
 			// TRANSLATORS: This should not start with a lowercase letter.
+			"assertion": i18n.G("Include the validation-set or seccomp-deny assertions in the given file in the seed"),
+			// TRANSLATORS: This should not start with a lowercase letter.
 			"channel": i18n.G("The channel to use"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"customize": i18n.G("Image customizations specified as JSON file."),
@@ -139,12 +153,14 @@ func (x *cmdPrepareImage) Execute(args []string) error {
 	if len(snapChannels) != 0 {
 		opts.SnapChannels = snapChannels
 	}
+	opts.ExtraAssertions = x.Assertions
 
 	// store-wide cohort key via env, see image/options.go
 	opts.WideCohortKey = os.Getenv("UBUNTU_STORE_COHORT_KEY")
 
 	opts.PrepareDir = x.Positional.TargetDir
 	opts.Classic = x.Classic
+	opts.DualBank = x.DualBank
 
 	if x.PreseedSignKey != "" && !x.Preseed {
 		return fmt.Errorf("--preseed-sign-key cannot be used without --preseed")
@@ -154,6 +170,12 @@ func (x *cmdPrepareImage) Execute(args []string) error {
 		return fmt.Errorf("--sysfs-overlay cannot be used without --preseed")
 	}
 
+	if x.ManifestSignKey != "" && x.Manifest == "" {
+		return fmt.Errorf("--manifest-sign-key cannot be used without --manifest")
+	}
+
+	opts.Manifest = x.Manifest
+	opts.ManifestSignKey = x.ManifestSignKey
 	opts.Preseed = x.Preseed
 	opts.PreseedSignKey = x.PreseedSignKey
 	opts.AppArmorKernelFeaturesDir = x.AppArmorKernelFeaturesDir
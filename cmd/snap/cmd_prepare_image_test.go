@@ -55,6 +55,52 @@ func (s *SnapPrepareImageSuite) TestPrepareImageCore(c *C) {
 	})
 }
 
+func (s *SnapPrepareImageSuite) TestPrepareImageDualBank(c *C) {
+	var opts *image.Options
+	prep := func(o *image.Options) error {
+		opts = o
+		return nil
+	}
+	r := snap.MockImagePrepare(prep)
+	defer r()
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"prepare-image", "--dual-bank", "model", "prepare-dir"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+
+	c.Check(opts, DeepEquals, &image.Options{
+		ModelFile:  "model",
+		PrepareDir: "prepare-dir",
+		DualBank:   true,
+	})
+}
+
+func (s *SnapPrepareImageSuite) TestPrepareImageManifest(c *C) {
+	var opts *image.Options
+	prep := func(o *image.Options) error {
+		opts = o
+		return nil
+	}
+	r := snap.MockImagePrepare(prep)
+	defer r()
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"prepare-image", "--manifest", "manifest.json", "--manifest-sign-key", "key", "model", "prepare-dir"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+
+	c.Check(opts, DeepEquals, &image.Options{
+		ModelFile:       "model",
+		PrepareDir:      "prepare-dir",
+		Manifest:        "manifest.json",
+		ManifestSignKey: "key",
+	})
+}
+
+func (s *SnapPrepareImageSuite) TestPrepareImageManifestArgError(c *C) {
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"prepare-image", "--manifest-sign-key", "key", "model", "prepare-dir"})
+	c.Assert(err, ErrorMatches, `--manifest-sign-key cannot be used without --manifest`)
+}
+
 func (s *SnapPrepareImageSuite) TestPrepareImageClassic(c *C) {
 	var opts *image.Options
 	prep := func(o *image.Options) error {
@@ -143,6 +189,26 @@ func (s *SnapPrepareImageSuite) TestPrepareImageExtraSnaps(c *C) {
 	})
 }
 
+func (s *SnapPrepareImageSuite) TestPrepareImageAssertions(c *C) {
+	var opts *image.Options
+	prep := func(o *image.Options) error {
+		opts = o
+		return nil
+	}
+	r := snap.MockImagePrepare(prep)
+	defer r()
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"prepare-image", "model", "prepare-dir", "--assertion", "vsets.assert", "--assertion", "seccomp.assert"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+
+	c.Check(opts, DeepEquals, &image.Options{
+		ModelFile:       "model",
+		PrepareDir:      "prepare-dir",
+		ExtraAssertions: []string{"vsets.assert", "seccomp.assert"},
+	})
+}
+
 func (s *SnapPrepareImageSuite) TestPrepareImageCustomize(c *C) {
 	var opts *image.Options
 	prep := func(o *image.Options) error {
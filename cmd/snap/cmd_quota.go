@@ -85,7 +85,9 @@ in the allowed CPU set. Setting the percentage to 2x100% means that the quota gr
 is allowed up to 100% on two cpu cores.
 
 The CPU set limit for a quota group can be modified to include new cpus, or to remove
-existing cpus from the quota already set.
+existing cpus from the quota already set. CPUs are given as a list of CPU numbers
+and ranges, for example 0-3,6. The NUMA memory nodes the snaps in the quota group
+may allocate memory on are set in the same way with the memory-nodes limit.
 
 The threads limit for a quota group can be increased but not decreased. To
 decrease the threads limit for a quota group, the entire group must be removed
@@ -95,6 +97,11 @@ The journal limits can be increased and decreased after being set on a group.
 Setting a journal limit will cause the snaps in the group to be put into the same
 journal namespace. This will affect the behaviour of the log command.
 
+The journal retention limit is how long the entries of the journal namespace
+are kept at most, for example 7d or 12h. The journal storage decides whether
+the journal namespace is kept in memory only (volatile), on disk (persistent)
+or follows the journal.persistent system option (auto).
+
 New quotas can be set on existing quota groups, but existing quotas cannot be removed
 from a quota group, without removing and recreating the entire group.
 
@@ -111,10 +118,13 @@ func init() {
 		waitDescs.also(map[string]string{
 			"memory":             i18n.G("Memory quota"),
 			"cpu":                i18n.G("CPU quota"),
-			"cpu-set":            i18n.G("CPU set quota"),
+			"cpu-set":            i18n.G("CPU set quota, as a list of CPUs and CPU ranges (e.g. 0-3,6)"),
+			"memory-nodes":       i18n.G("NUMA memory nodes quota, as a list of nodes and node ranges"),
 			"threads":            i18n.G("Threads quota"),
 			"journal-size":       i18n.G("Journal size quota"),
 			"journal-rate-limit": i18n.G("Journal rate limit as <message count>/<message period>"),
+			"journal-retention":  i18n.G("Journal retention period"),
+			"journal-storage":    i18n.G("Journal storage, one of auto, volatile or persistent"),
 			"parent":             i18n.G("Parent quota group"),
 		}), nil)
 	cmd.hidden = true
@@ -135,9 +145,12 @@ type cmdSetQuota struct {
 	MemoryMax        string `long:"memory" optional:"true"`
 	CPUMax           string `long:"cpu" optional:"true"`
 	CPUSet           string `long:"cpu-set" optional:"true"`
+	MemoryNodes      string `long:"memory-nodes" optional:"true"`
 	ThreadsMax       string `long:"threads" optional:"true"`
 	JournalSizeMax   string `long:"journal-size" optional:"true"`
 	JournalRateLimit string `long:"journal-rate-limit" optional:"true"`
+	JournalRetention string `long:"journal-retention" optional:"true"`
+	JournalStorage   string `long:"journal-storage" optional:"true" choice:"auto" choice:"volatile" choice:"persistent"`
 	Parent           string `long:"parent" optional:"true"`
 	Positional       struct {
 		GroupName string              `positional-arg-name:"<group-name>" required:"true"`
@@ -174,6 +187,42 @@ func parseCpuQuota(cpuMax string) (count int, percentage int, err error) {
 	return count, percentage, nil
 }
 
+// The largest CPU and memory node indices accepted, these match the highest
+// number of CPUs and NUMA nodes the kernel can be built with and keep ranges
+// from expanding into huge lists.
+const (
+	maxCPUSetIndex     = 8191
+	maxMemoryNodeIndex = 1023
+)
+
+// parseIndexList parses a list of indices as used for CPU sets and memory
+// nodes, e.g. "0-3,6", into the list of the indices, none of which may be
+// larger than maxIndex. The kind of list is used in the error message.
+func parseIndexList(kind, list string, maxIndex uint64) ([]int, error) {
+	var indices []int
+	for _, token := range strutil.CommaSeparatedList(list) {
+		first, last := token, token
+		if idx := strings.IndexRune(token, '-'); idx >= 0 {
+			first, last = token[:idx], token[idx+1:]
+		}
+		start, err := strconv.ParseUint(first, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s value %q", kind, token)
+		}
+		end, err := strconv.ParseUint(last, 10, 32)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("cannot parse %s value %q", kind, token)
+		}
+		if end > maxIndex {
+			return nil, fmt.Errorf("cannot use %s value %q: index larger than %d", kind, token, maxIndex)
+		}
+		for i := start; i <= end; i++ {
+			indices = append(indices, int(i))
+		}
+	}
+	return indices, nil
+}
+
 func parseJournalRateQuota(journalRateLimit string) (count int, period time.Duration, err error) {
 	// the rate limit is a string of the form N/P, where N is the number of
 	// messages and P is the period as a time string (e.g 5s)
@@ -194,6 +243,19 @@ func parseJournalRateQuota(journalRateLimit string) (count int, period time.Dura
 	return count, period, nil
 }
 
+// parseJournalRetention parses a duration as understood by
+// time.ParseDuration, additionally allowing a number of days (e.g 7d).
+func parseJournalRetention(retention string) (time.Duration, error) {
+	if strings.HasSuffix(retention, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(retention, "d"), 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse number of days")
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(retention)
+}
+
 func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 	var quotaValues client.QuotaValues
 
@@ -220,19 +282,22 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		}
 	}
 
-	if x.CPUSet != "" {
-		var cpus []int
-		cpuTokens := strutil.CommaSeparatedList(x.CPUSet)
-		for _, cpuToken := range cpuTokens {
-			cpu, err := strconv.ParseUint(cpuToken, 10, 32)
+	if x.CPUSet != "" || x.MemoryNodes != "" {
+		quotaValues.CPUSet = &client.QuotaCPUSetValues{}
+		if x.CPUSet != "" {
+			cpus, err := parseIndexList("CPU set", x.CPUSet, maxCPUSetIndex)
 			if err != nil {
-				return nil, fmt.Errorf("cannot parse CPU set value %q", cpuToken)
+				return nil, err
 			}
-			cpus = append(cpus, int(cpu))
+			quotaValues.CPUSet.CPUs = cpus
 		}
 
-		quotaValues.CPUSet = &client.QuotaCPUSetValues{
-			CPUs: cpus,
+		if x.MemoryNodes != "" {
+			nodes, err := parseIndexList("memory nodes", x.MemoryNodes, maxMemoryNodeIndex)
+			if err != nil {
+				return nil, err
+			}
+			quotaValues.CPUSet.MemoryNodes = nodes
 		}
 	}
 
@@ -244,7 +309,7 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		quotaValues.Threads = int(value)
 	}
 
-	if x.JournalSizeMax != "" || x.JournalRateLimit != "" {
+	if x.JournalSizeMax != "" || x.JournalRateLimit != "" || x.JournalRetention != "" || x.JournalStorage != "" {
 		quotaValues.Journal = &client.QuotaJournalValues{}
 		if x.JournalSizeMax != "" {
 			value, err := strutil.ParseByteSize(x.JournalSizeMax)
@@ -264,14 +329,25 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 				RatePeriod: period,
 			}
 		}
+
+		if x.JournalRetention != "" {
+			period, err := parseJournalRetention(x.JournalRetention)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse journal retention %q: %v", x.JournalRetention, err)
+			}
+			quotaValues.Journal.RetentionPeriod = period
+		}
+
+		quotaValues.Journal.Storage = x.JournalStorage
 	}
 
 	return &quotaValues, nil
 }
 
 func (x *cmdSetQuota) hasQuotaSet() bool {
-	return x.MemoryMax != "" || x.CPUMax != "" || x.CPUSet != "" ||
-		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != ""
+	return x.MemoryMax != "" || x.CPUMax != "" || x.CPUSet != "" || x.MemoryNodes != "" ||
+		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != "" ||
+		x.JournalRetention != "" || x.JournalStorage != ""
 }
 
 func (x *cmdSetQuota) Execute(args []string) (err error) {
@@ -405,6 +481,10 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 		cpus := strutil.IntsToCommaSeparated(group.Constraints.CPUSet.CPUs)
 		fmt.Fprintf(w, "  cpu-set:\t%s\n", cpus)
 	}
+	if group.Constraints.CPUSet != nil && len(group.Constraints.CPUSet.MemoryNodes) > 0 {
+		nodes := strutil.IntsToCommaSeparated(group.Constraints.CPUSet.MemoryNodes)
+		fmt.Fprintf(w, "  memory-nodes:\t%s\n", nodes)
+	}
 	if group.Constraints.Threads != 0 {
 		fmt.Fprintf(w, "  threads:\t%d\n", group.Constraints.Threads)
 	}
@@ -418,6 +498,12 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 				group.Constraints.Journal.RateCount,
 				group.Constraints.Journal.RatePeriod)
 		}
+		if group.Constraints.Journal.RetentionPeriod != 0 {
+			fmt.Fprintf(w, "  journal-retention:\t%s\n", group.Constraints.Journal.RetentionPeriod)
+		}
+		if group.Constraints.Journal.Storage != "" {
+			fmt.Fprintf(w, "  journal-storage:\t%s\n", group.Constraints.Journal.Storage)
+		}
 	}
 
 	memoryUsage := "0B"
@@ -517,12 +603,18 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			grpConstraints = append(grpConstraints, "cpu-set="+cpus)
 		}
 
+		if q.Constraints.CPUSet != nil && len(q.Constraints.CPUSet.MemoryNodes) > 0 {
+			nodes := strutil.IntsToCommaSeparated(q.Constraints.CPUSet.MemoryNodes)
+			grpConstraints = append(grpConstraints, "memory-nodes="+nodes)
+		}
+
 		// format threads constraint as threads=N
 		if q.Constraints.Threads != 0 {
 			grpConstraints = append(grpConstraints, "threads="+strconv.Itoa(q.Constraints.Threads))
 		}
 
-		// format journal constraint as journal-size=xMB,journal-rate=x/y
+		// format journal constraint as
+		// journal-size=xMB,journal-rate=x/y,journal-retention=t,journal-storage=s
 		if q.Constraints.Journal != nil {
 			if q.Constraints.Journal.Size != 0 {
 				grpConstraints = append(grpConstraints, "journal-size="+strings.TrimSpace(fmtSize(int64(q.Constraints.Journal.Size))))
@@ -533,6 +625,15 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 					fmt.Sprintf("journal-rate=%d/%s",
 						q.Constraints.Journal.RateCount, q.Constraints.Journal.RatePeriod))
 			}
+
+			if q.Constraints.Journal.RetentionPeriod != 0 {
+				grpConstraints = append(grpConstraints,
+					fmt.Sprintf("journal-retention=%s", q.Constraints.Journal.RetentionPeriod))
+			}
+
+			if q.Constraints.Journal.Storage != "" {
+				grpConstraints = append(grpConstraints, "journal-storage="+q.Constraints.Journal.Storage)
+			}
 		}
 
 		// format current resource values as memory=N,threads=N
@@ -206,9 +206,12 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 		maxMemory        string
 		cpuMax           string
 		cpuSet           string
+		memoryNodes      string
 		threadsMax       string
 		journalSizeMax   string
 		journalRateLimit string
+		journalRetention string
+		journalStorage   string
 
 		// Use the JSON representation of the quota, as it's easier to handle in the test data
 		quotas string
@@ -218,12 +221,21 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 		{cpuMax: "12x40%", quotas: `{"cpu":{"count":12,"percentage":40}}`},
 		{cpuMax: "40%", quotas: `{"cpu":{"percentage":40}}`},
 		{cpuSet: "1,3", quotas: `{"cpu-set":{"cpus":[1,3]}}`},
+		{cpuSet: "0-3,6", quotas: `{"cpu-set":{"cpus":[0,1,2,3,6]}}`},
+		{cpuSet: "2-2", quotas: `{"cpu-set":{"cpus":[2]}}`},
+		{cpuSet: "8191", quotas: `{"cpu-set":{"cpus":[8191]}}`},
+		{memoryNodes: "0", quotas: `{"cpu-set":{"memory-nodes":[0]}}`},
+		{cpuSet: "0-1", memoryNodes: "0,2-3", quotas: `{"cpu-set":{"cpus":[0,1],"memory-nodes":[0,2,3]}}`},
 		{threadsMax: "2", quotas: `{"threads":2}`},
 		{journalSizeMax: "16MB", quotas: `{"journal":{"size":16000000}}`},
 		{journalRateLimit: "10/15s", quotas: `{"journal":{"rate-count":10,"rate-period":15000000000}}`},
 		{journalRateLimit: "1500/15ms", quotas: `{"journal":{"rate-count":1500,"rate-period":15000000}}`},
 		{journalRateLimit: "1/15us", quotas: `{"journal":{"rate-count":1,"rate-period":15000}}`},
 		{journalRateLimit: "0/0s", quotas: `{"journal":{"rate-count":0,"rate-period":0}}`},
+		{journalRetention: "12h", quotas: `{"journal":{"retention-period":43200000000000}}`},
+		{journalRetention: "7d", quotas: `{"journal":{"retention-period":604800000000000}}`},
+		{journalStorage: "volatile", quotas: `{"journal":{"storage":"volatile"}}`},
+		{journalSizeMax: "16MB", journalStorage: "persistent", quotas: `{"journal":{"size":16000000,"storage":"persistent"}}`},
 
 		// Error cases
 		{cpuMax: "ASD", err: `cannot parse cpu quota string "ASD"`},
@@ -235,14 +247,25 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 		{cpuSet: "x", err: `cannot parse CPU set value "x"`},
 		{cpuSet: "1:2", err: `cannot parse CPU set value "1:2"`},
 		{cpuSet: "0,-2", err: `cannot parse CPU set value "-2"`},
+		{cpuSet: "3-1", err: `cannot parse CPU set value "3-1"`},
+		{cpuSet: "0-x", err: `cannot parse CPU set value "0-x"`},
+		{cpuSet: "0-1-2", err: `cannot parse CPU set value "0-1-2"`},
+		{memoryNodes: "y", err: `cannot parse memory nodes value "y"`},
+		{memoryNodes: "1-", err: `cannot parse memory nodes value "1-"`},
+		{cpuSet: "0-4294967295", err: `cannot use CPU set value "0-4294967295": index larger than 8191`},
+		{cpuSet: "8192", err: `cannot use CPU set value "8192": index larger than 8191`},
+		{memoryNodes: "0-1024", err: `cannot use memory nodes value "0-1024": index larger than 1023`},
 		{threadsMax: "xxx", err: `cannot use threads value "xxx"`},
 		{threadsMax: "-3", err: `cannot use threads value "-3"`},
 		{journalRateLimit: "0", err: `cannot parse journal rate limit "0": rate limit must be of the form <number of messages>/<period duration>`},
 		{journalRateLimit: "x/5m", err: `cannot parse journal rate limit "x/5m": cannot parse message count: strconv.Atoi: parsing "x": invalid syntax`},
 		{journalRateLimit: "1/wow", err: `cannot parse journal rate limit "1/wow": cannot parse period: time: invalid duration ["]?wow["]?`},
+		{journalRetention: "wow", err: `cannot parse journal retention "wow": time: invalid duration ["]?wow["]?`},
+		{journalRetention: "xd", err: `cannot parse journal retention "xd": cannot parse number of days`},
 	} {
 		quotas, err := main.ParseQuotaValues(testData.maxMemory, testData.cpuMax,
-			testData.cpuSet, testData.threadsMax, testData.journalSizeMax, testData.journalRateLimit)
+			testData.cpuSet, testData.memoryNodes, testData.threadsMax, testData.journalSizeMax, testData.journalRateLimit,
+			testData.journalRetention, testData.journalStorage)
 		testLabel := check.Commentf("%v", testData)
 		if testData.err == "" {
 			c.Check(err, check.IsNil, testLabel)
@@ -438,13 +461,40 @@ current:
 	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 2)
 }
 
+func (s *quotaSuite) TestGetCpuSetQuotaGroupMemoryNodes(c *check.C) {
+	const json = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"cpu-set":{"cpus":[0,1,2,3],"memory-nodes":[0,2]}},
+			"current": {}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, json))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+name:  foo
+constraints:
+  cpu-set:       0,1,2,3
+  memory-nodes:  0,2
+current:
+`[1:])
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) TestJournalQuotaGroupSimple(c *check.C) {
 	const jsonTemplate = `{
 		"type": "sync",
 		"status-code": 200,
 		"result": {
 			"group-name": "foo",
-			"constraints": {"journal":{"size":1048576,"rate-count":50,"rate-period":60000000000}}
+			"constraints": {"journal":{"size":1048576,"rate-count":50,"rate-period":60000000000,"retention-period":86400000000000,"storage":"volatile"}}
 		}
 	}`
 
@@ -453,8 +503,10 @@ func (s *quotaSuite) TestJournalQuotaGroupSimple(c *check.C) {
 	outputTemplate := `
 name:  foo
 constraints:
-  journal-size:  1.05MB
-  journal-rate:  50/1m0s
+  journal-size:       1.05MB
+  journal-rate:       50/1m0s
+  journal-retention:  24h0m0s
+  journal-storage:    volatile
 current:
 `[1:]
 
@@ -661,12 +713,13 @@ func (s *quotaSuite) TestGetAllQuotaGroups(c *check.C) {
 			{"group-name":"fff","parent":"aaa","constraints":{"memory":1000},"current":{"memory":0}},
 			{"group-name":"xxx","constraints":{"memory":9900},"current":{"memory":10000}},
 			{"group-name":"cp0","constraints":{"memory":9900, "cpu":{"percentage":90}},"current":{"memory":10000}},
-			{"group-name":"cp1","subgroups":["cps0","js0","js1"],"constraints":{"cpu":{"count":2, "percentage":90}}},
+			{"group-name":"cp1","subgroups":["cps0","js0","js1","js2"],"constraints":{"cpu":{"count":2, "percentage":90}}},
 			{"group-name":"cps0","parent":"cp1","constraints":{"cpu":{"percentage":40}}},
 			{"group-name":"cp2","subgroups":["cps1"],"constraints":{"cpu":{"count":2,"percentage":100},"cpu-set":{"cpus":[0,1]}}},
 			{"group-name":"cps1","parent":"cp2","constraints":{"memory":9900,"cpu":{"percentage":50},"cpu-set":{"cpus":[1]}},"current":{"memory":10000}},
 			{"group-name":"js0","parent":"cp1","constraints":{"journal":{"size":1048576,"rate-count":50,"rate-period":60000000000}}},
-			{"group-name":"js1","parent":"cp1","constraints":{"journal":{"rate-count":0,"rate-period":0}}}
+			{"group-name":"js1","parent":"cp1","constraints":{"journal":{"rate-count":0,"rate-period":0}}},
+			{"group-name":"js2","parent":"cp1","constraints":{"journal":{"retention-period":3600000000000,"storage":"persistent"}}}
 			]}`))
 
 	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quotas"})
@@ -674,24 +727,25 @@ func (s *quotaSuite) TestGetAllQuotaGroups(c *check.C) {
 	c.Check(rest, check.HasLen, 0)
 	c.Check(s.Stderr(), check.Equals, "")
 	c.Check(s.Stdout(), check.Equals, `
-Quota    Parent  Constraints                               Current
-cp0              memory=9.9kB,cpu=90%                      memory=10.0kB
-cp1              cpu=2x90%                                 
-cps0     cp1     cpu=40%                                   
-js0      cp1     journal-size=1.05MB,journal-rate=50/1m0s  
-js1      cp1     journal-rate=0/0s                         
-cp2              cpu=2x100%,cpu-set=0,1                    
-cps1     cp2     memory=9.9kB,cpu=50%,cpu-set=1            memory=10.0kB
-ggg              memory=1000B,threads=100                  memory=3000B
-hhh              threads=100                               
-xxx              memory=9.9kB                              memory=10.0kB
-yyyyyyy          memory=1000B                              
-zzz              memory=5000B                              
-aaa      zzz     memory=1000B                              
-ccc      aaa     memory=400B                               
-ddd      aaa     memory=400B                               
-fff      aaa     memory=1000B                              
-bbb      zzz     memory=1000B                              memory=400B
+Quota    Parent  Constraints                                          Current
+cp0              memory=9.9kB,cpu=90%                                 memory=10.0kB
+cp1              cpu=2x90%                                            
+cps0     cp1     cpu=40%                                              
+js0      cp1     journal-size=1.05MB,journal-rate=50/1m0s             
+js1      cp1     journal-rate=0/0s                                    
+js2      cp1     journal-retention=1h0m0s,journal-storage=persistent  
+cp2              cpu=2x100%,cpu-set=0,1                               
+cps1     cp2     memory=9.9kB,cpu=50%,cpu-set=1                       memory=10.0kB
+ggg              memory=1000B,threads=100                             memory=3000B
+hhh              threads=100                                          
+xxx              memory=9.9kB                                         memory=10.0kB
+yyyyyyy          memory=1000B                                         
+zzz              memory=5000B                                         
+aaa      zzz     memory=1000B                                         
+ccc      aaa     memory=400B                                          
+ddd      aaa     memory=400B                                          
+fff      aaa     memory=1000B                                         
+bbb      zzz     memory=1000B                                         memory=400B
 `[1:])
 	c.Check(s.quotaGetGroupsHandlerCalls, check.Equals, 1)
 }
@@ -89,6 +89,10 @@ type cmdRun struct {
 	// the parser
 	ParserRan int    `long:"parser-ran" default:"1" hidden:"yes"`
 	Timer     string `long:"timer" hidden:"yes"`
+
+	// resource limits of hooks, set by snapd
+	HookMemoryMax uint64 `long:"hook-memory-max" hidden:"yes"`
+	HookCPUQuota  int    `long:"hook-cpu-quota" hidden:"yes"`
 }
 
 func init() {
@@ -121,8 +125,10 @@ and environment.
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"trace-exec": i18n.G("Display exec calls timing data"),
 			// TRANSLATORS: This should not start with a lowercase letter.
-			"debug-log":  i18n.G("Enable debug logging during early snap startup phases"),
-			"parser-ran": "",
+			"debug-log":       i18n.G("Enable debug logging during early snap startup phases"),
+			"parser-ran":      "",
+			"hook-memory-max": "",
+			"hook-cpu-quota":  "",
 		}, nil)
 }
 
@@ -197,7 +203,10 @@ func maybeWaitForSecurityProfileRegeneration(cli *client.Client) error {
 		// TODO: we could also check cli.Maintenance() here too in case snapd is
 		// down semi-permanently for a refresh, but what message do we show to
 		// the user or what do we do if we know snapd is down for maintenance?
-		if _, err := cli.SysInfo(); err == nil {
+		sysInfo, err := cli.SysInfo()
+		// snapd serves the API before it finishes regenerating the
+		// security profiles, wait for that too
+		if err == nil && (sysInfo.Readiness == nil || sysInfo.Readiness.State != "initializing") {
 			return nil
 		}
 		// sleep a little bit for good measure
@@ -232,6 +241,9 @@ func (x *cmdRun) Execute(args []string) error {
 	if x.Revision != "unset" && x.Revision != "" && x.HookName == "" {
 		return fmt.Errorf(i18n.G("-r can only be used with --hook"))
 	}
+	if (x.HookMemoryMax != 0 || x.HookCPUQuota != 0) && x.HookName == "" {
+		return fmt.Errorf(i18n.G("--hook-memory-max and --hook-cpu-quota can only be used with --hook"))
+	}
 	if x.HookName != "" && len(args) > 0 {
 		// TRANSLATORS: %q is the hook name; %s a space-separated list of extra arguments
 		return fmt.Errorf(i18n.G("too many arguments for hook %q: %s"), x.HookName, strings.Join(args, " "))
@@ -1214,10 +1226,20 @@ func (x *cmdRun) runSnapConfine(info *snap.Info, securityTag, snapApp, hook stri
 	// Track, or confirm existing tracking from systemd.
 	if needsTracking {
 		opts := &cgroup.TrackingOptions{AllowSessionBus: allowSessionBus}
+		if hook != "" {
+			opts.Limits = cgroup.ResourceLimits{
+				MemoryMax: x.HookMemoryMax,
+				CPUQuota:  x.HookCPUQuota,
+			}
+		}
 		if err = cgroupCreateTransientScopeForTracking(securityTag, opts); err != nil {
 			if err != cgroup.ErrCannotTrackProcess {
 				return err
 			}
+			if opts.Limits != (cgroup.ResourceLimits{}) {
+				// the limits are enforced by the scope
+				return fmt.Errorf(i18n.G("cannot apply resource limits to hook %q: %v"), hook, err)
+			}
 			// If we cannot track the process then log a debug message.
 			// TODO: if we could, create a warning. Currently this is not possible
 			// because only snapd can create warnings, internally.
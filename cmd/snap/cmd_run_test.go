@@ -564,6 +564,69 @@ func (s *RunSuite) TestSnapRunHookIntegration(c *check.C) {
 	c.Check(execEnv, testutil.Contains, "SNAP_REVISION=42")
 }
 
+func (s *RunSuite) TestSnapRunHookResourceLimits(c *check.C) {
+	defer mockSnapConfine(dirs.DistroLibExecDir)()
+
+	// mock installed snap
+	snaptest.MockSnapCurrent(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R(42),
+	})
+
+	var createTransientScopeOpts *cgroup.TrackingOptions
+	restore := snaprun.MockCreateTransientScopeForTracking(func(securityTag string, opts *cgroup.TrackingOptions) error {
+		c.Check(securityTag, check.Equals, "snap.snapname.hook.configure")
+		createTransientScopeOpts = opts
+		return nil
+	})
+	defer restore()
+
+	execCalled := false
+	restore = snaprun.MockSyscallExec(func(arg0 string, args []string, envv []string) error {
+		execCalled = true
+		return nil
+	})
+	defer restore()
+
+	_, err := snaprun.Parser(snaprun.Client()).ParseArgs([]string{"run", "--hook=configure", "--hook-memory-max=1048576", "--hook-cpu-quota=50", "--", "snapname"})
+	c.Assert(err, check.IsNil)
+	c.Check(execCalled, check.Equals, true)
+	c.Check(createTransientScopeOpts, check.DeepEquals, &cgroup.TrackingOptions{
+		AllowSessionBus: false,
+		Limits: cgroup.ResourceLimits{
+			MemoryMax: 1048576,
+			CPUQuota:  50,
+		},
+	})
+}
+
+func (s *RunSuite) TestSnapRunHookResourceLimitsNoTracking(c *check.C) {
+	defer mockSnapConfine(dirs.DistroLibExecDir)()
+
+	// mock installed snap
+	snaptest.MockSnapCurrent(c, string(mockYaml), &snap.SideInfo{
+		Revision: snap.R(42),
+	})
+
+	restore := snaprun.MockCreateTransientScopeForTracking(func(securityTag string, opts *cgroup.TrackingOptions) error {
+		return cgroup.ErrCannotTrackProcess
+	})
+	defer restore()
+
+	restore = snaprun.MockSyscallExec(func(arg0 string, args []string, envv []string) error {
+		c.Fatalf("unexpected exec")
+		return nil
+	})
+	defer restore()
+
+	_, err := snaprun.Parser(snaprun.Client()).ParseArgs([]string{"run", "--hook=configure", "--hook-memory-max=1048576", "--", "snapname"})
+	c.Assert(err, check.ErrorMatches, `cannot apply resource limits to hook "configure": cannot track application process`)
+}
+
+func (s *RunSuite) TestSnapRunResourceLimitsOnlyForHooks(c *check.C) {
+	_, err := snaprun.Parser(snaprun.Client()).ParseArgs([]string{"run", "--hook-cpu-quota=50", "--", "snapname.app"})
+	c.Assert(err, check.ErrorMatches, "--hook-memory-max and --hook-cpu-quota can only be used with --hook")
+}
+
 func (s *RunSuite) TestSnapRunHookUnsetRevisionIntegration(c *check.C) {
 	defer mockSnapConfine(dirs.DistroLibExecDir)()
 
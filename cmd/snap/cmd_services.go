@@ -33,6 +33,8 @@ import (
 
 type svcStatus struct {
 	clientMixin
+	timeMixin
+	Verbose    bool `long:"verbose"`
 	Positional struct {
 		ServiceNames []serviceName
 	} `positional-args:"yes"`
@@ -53,6 +55,10 @@ var (
 	longServicesHelp  = i18n.G(`
 The services command lists information about the services specified, or about
 the services in all currently installed snaps.
+
+If the --verbose option is given, the number of automatic restarts of each
+service, how its main process last exited, and when its state last changed
+are displayed as well.
 `)
 	shortLogsHelp = i18n.G("Retrieve logs for services")
 	longLogsHelp  = i18n.G(`
@@ -83,7 +89,11 @@ func init() {
 		// TRANSLATORS: This should not start with a lowercase letter.
 		desc: i18n.G("A service specification, which can be just a snap name (for all services in the snap), or <snap>.<app> for a single service."),
 	}}
-	addCommand("services", shortServicesHelp, longServicesHelp, func() flags.Commander { return &svcStatus{} }, nil, argdescs)
+	addCommand("services", shortServicesHelp, longServicesHelp, func() flags.Commander { return &svcStatus{} },
+		timeDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"verbose": i18n.G("Show restart count, last exit status and last state change of services."),
+		}), argdescs)
 	addCommand("logs", shortLogsHelp, longLogsHelp, func() flags.Commander { return &svcLogs{} },
 		timeDescs.also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -135,7 +145,11 @@ func (s *svcStatus) Execute(args []string) error {
 	w := tabWriter()
 	defer w.Flush()
 
-	fmt.Fprintln(w, i18n.G("Service\tStartup\tCurrent\tNotes"))
+	if s.Verbose {
+		fmt.Fprintln(w, i18n.G("Service\tStartup\tCurrent\tRestarts\tLast-exit\tChanged\tNotes"))
+	} else {
+		fmt.Fprintln(w, i18n.G("Service\tStartup\tCurrent\tNotes"))
+	}
 
 	for _, svc := range services {
 		startup := i18n.G("disabled")
@@ -148,12 +162,40 @@ func (s *svcStatus) Execute(args []string) error {
 		} else if svc.Active {
 			current = i18n.G("active")
 		}
+		if s.Verbose {
+			restarts, lastExit, changed := s.runtimeStatus(svc)
+			fmt.Fprintf(w, "%s.%s\t%s\t%s\t%s\t%s\t%s\t%s\n", svc.Snap, svc.Name, startup, current, restarts, lastExit, changed, clientutil.ClientAppInfoNotes(svc))
+			continue
+		}
 		fmt.Fprintf(w, "%s.%s\t%s\t%s\t%s\n", svc.Snap, svc.Name, startup, current, clientutil.ClientAppInfoNotes(svc))
 	}
 
 	return nil
 }
 
+// runtimeStatus returns the restart count, last exit status and last state
+// change time of the given service, formatted for display.
+func (s *svcStatus) runtimeStatus(svc *client.AppInfo) (restarts, lastExit, changed string) {
+	if svc.DaemonScope == snap.UserDaemon {
+		// there is one instance of the service per user
+		return "-", "-", "-"
+	}
+	restarts = strconv.Itoa(svc.Restarts)
+	switch {
+	case svc.LastExitSignal != "":
+		lastExit = svc.LastExitSignal
+	case svc.LastExitCode != 0:
+		lastExit = strconv.Itoa(svc.LastExitCode)
+	default:
+		lastExit = "-"
+	}
+	changed = "-"
+	if !svc.StateChanged.IsZero() {
+		changed = s.fmtTime(svc.StateChanged)
+	}
+	return restarts, lastExit, changed
+}
+
 func (s *svcLogs) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
@@ -240,6 +240,77 @@ foo.zed  enabled  active    -
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *appOpSuite) TestAppStatusVerbose(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/apps")
+			c.Check(r.URL.Query().Get("select"), check.Equals, "service")
+			c.Check(r.Method, check.Equals, "GET")
+			w.WriteHeader(200)
+			enc := json.NewEncoder(w)
+			enc.Encode(map[string]interface{}{
+				"type": "sync",
+				"result": []map[string]interface{}{
+					{
+						"snap":          "foo",
+						"name":          "bar",
+						"daemon":        "simple",
+						"daemon-scope":  "system",
+						"active":        true,
+						"enabled":       true,
+						"restarts":      3,
+						"state-changed": "2022-10-14T12:34:56Z",
+					}, {
+						"snap":             "foo",
+						"name":             "baz",
+						"daemon":           "simple",
+						"daemon-scope":     "system",
+						"active":           false,
+						"enabled":          true,
+						"restarts":         12,
+						"last-exit-signal": "SIGSEGV",
+						"state-changed":    "2022-10-14T12:35:00Z",
+					}, {
+						"snap":           "foo",
+						"name":           "qux",
+						"daemon":         "oneshot",
+						"daemon-scope":   "system",
+						"active":         false,
+						"enabled":        false,
+						"last-exit-code": 2,
+					}, {
+						"snap":         "foo",
+						"name":         "zed",
+						"daemon":       "simple",
+						"daemon-scope": "user",
+						"enabled":      true,
+					},
+				},
+				"status":      "OK",
+				"status-code": 200,
+			})
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"services", "--verbose", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `Service  Startup   Current   Restarts  Last-exit  Changed               Notes
+foo.bar  enabled   active    3         -          2022-10-14T12:34:56Z  -
+foo.baz  enabled   inactive  12        SIGSEGV    2022-10-14T12:35:00Z  -
+foo.qux  disabled  inactive  0         2          -                     -
+foo.zed  enabled   -         -         -          -                     user
+`)
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 1)
+}
+
 func (s *appOpSuite) TestServiceCompletion(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -22,6 +22,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -67,6 +68,12 @@ Note that a later refresh will typically undo a revision override, taking the sn
 back to the current revision of the channel it's tracking.
 
 Use --name to set the instance name when installing from snap file.
+
+With --bundle, the single argument is a tar archive carrying several snap files
+together with their assertions and a bundle.yaml manifest listing, in order,
+the assertions to acknowledge, the snaps to install and the interface
+connections to make afterwards. The snaps are installed and connected in a
+single change, which is undone entirely if any part of it fails.
 `)
 
 var longRemoveHelp = i18n.G(`
@@ -79,6 +86,15 @@ revision is removed.
 Unless automatic snapshots are disabled, a snapshot of all data for the snap is 
 saved upon removal, which is then available for future restoration with snap
 restore. The --purge option disables automatically creating snapshots.
+
+The --keep-cache option keeps the snap file of the removed revision in the
+download cache, so that reinstalling the same revision with --revision does
+not need to contact the store, for as long as it is kept by the cache.
+
+Snaps that other installed snaps need, as their base or as the default
+provider of their content, are not removed. The --cascade option removes those
+other snaps as well, before the snaps they need. The --dry-run option shows
+which snaps would be removed, in order, without removing anything.
 `)
 
 var longRefreshHelp = i18n.G(`
@@ -105,6 +121,12 @@ requests from 'snap refresh target-snap'.
 When snaps are specified --hold is effective on both their auto-refreshes
 and general refresh requests from 'snap refresh'. However, specific snap
 requests from 'snap refresh target-snap' remain unblocked and will proceed.
+
+A snap revision can only be refreshed to a revision whose epoch can read its
+data. The --explain option shows whether the revision in the tracked channel,
+or the one given with --channel, is reachable from the installed revision, and
+which intermediate revisions need to be installed first if so. Those revisions
+are installed in order, as one change, with --epoch-migration.
 `)
 
 var longTryHelp = i18n.G(`
@@ -116,6 +138,10 @@ performed in snap.yaml will require reinstallation to go live.
 If snap-dir argument is omitted, the try command will attempt to infer it if
 either snapcraft.yaml file and prime directory or meta/snap.yaml file can be
 found relative to current working directory.
+
+With --watch, the directory is watched for changes and, once they settle, the
+snap is tried again from it such that metadata changes go live as well. This
+requires the experimental try-watch feature to be enabled.
 `)
 
 var longEnableHelp = i18n.G(`
@@ -130,9 +156,13 @@ and the snap can easily be enabled again.
 
 type cmdRemove struct {
 	waitMixin
+	progressMixin
 
 	Revision   string `long:"revision"`
 	Purge      bool   `long:"purge"`
+	KeepCache  bool   `long:"keep-cache"`
+	Cascade    bool   `long:"cascade"`
+	DryRun     bool   `long:"dry-run"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>" required:"1"`
 	} `positional-args:"yes" required:"yes"`
@@ -215,9 +245,59 @@ func (x *cmdRemove) removeMany(opts *client.SnapOptions) error {
 
 }
 
+func (x *cmdRemove) showPlan(opts *client.SnapOptions) error {
+	names := installedSnapNames(x.Positional.Snaps)
+	plan, err := x.client.RemovalPlan(names, opts)
+	if err != nil {
+		var name string
+		if cerr, ok := err.(*client.Error); ok {
+			if snapName, ok := cerr.Value.(string); ok {
+				name = snapName
+			}
+		}
+
+		msg, err := errorToCmdMessage(name, "remove", err, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(Stderr, msg)
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range plan {
+		fmt.Fprintf(Stdout, i18n.G("%s would be removed\n"), name)
+		seen[name] = true
+	}
+	for _, name := range names {
+		if !seen[name] {
+			fmt.Fprintf(Stdout, i18n.G("%s not installed\n"), name)
+		}
+	}
+	return nil
+}
+
 func (x *cmdRemove) Execute([]string) error {
-	opts := &client.SnapOptions{Revision: x.Revision, Purge: x.Purge}
-	if len(x.Positional.Snaps) == 1 {
+	restoreStdout, err := x.setupProgress(&x.waitMixin)
+	if err != nil {
+		return err
+	}
+	defer restoreStdout()
+
+	opts := &client.SnapOptions{Revision: x.Revision, Purge: x.Purge, KeepCache: x.KeepCache, Cascade: x.Cascade}
+	if x.Revision != "" {
+		if x.Cascade {
+			return errors.New(i18n.G("cannot use --revision with --cascade"))
+		}
+		if x.DryRun {
+			return errors.New(i18n.G("cannot use --revision with --dry-run"))
+		}
+	}
+	if x.DryRun {
+		return x.showPlan(opts)
+	}
+	// with --cascade other snaps may be removed too
+	if len(x.Positional.Snaps) == 1 && !x.Cascade {
 		return x.removeOne(opts)
 	}
 
@@ -485,6 +565,7 @@ func (mx modeMixin) setModes(opts *client.SnapOptions) {
 type cmdInstall struct {
 	colorMixin
 	waitMixin
+	progressMixin
 
 	channelMixin
 	modeMixin
@@ -504,11 +585,74 @@ type cmdInstall struct {
 	IgnoreRunning    bool                   `long:"ignore-running" hidden:"yes"`
 	Transaction      client.TransactionType `long:"transaction" default:"per-snap" choice:"all-snaps" choice:"per-snap"`
 	QuotaGroupName   string                 `long:"quota-group"`
+	Bundle           bool                   `long:"bundle"`
 	Positional       struct {
 		Snaps []remoteSnapName `positional-arg-name:"<snap>" required:"1"`
 	} `positional-args:"yes" required:"yes"`
 }
 
+func (x *cmdInstall) installBundle(path string, opts *client.SnapOptions) error {
+	if x.NoWait {
+		return errors.New(i18n.G("cannot use --no-wait when installing a bundle"))
+	}
+
+	dir, err := ioutil.TempDir("", "snap-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := unpackBundle(path, dir)
+	if err != nil {
+		return err
+	}
+
+	var assertions [][]byte
+	for _, assertPath := range b.assertPaths {
+		assertData, err := ioutil.ReadFile(assertPath)
+		if err != nil {
+			return err
+		}
+		assertions = append(assertions, assertData)
+	}
+	conns := make([]client.ConnectionRef, len(b.plugs))
+	for i, plug := range b.plugs {
+		slot := b.slots[i]
+		conns[i] = client.ConnectionRef{
+			Plug: client.PlugRef{Snap: plug.Snap, Name: plug.Name},
+			Slot: client.SlotRef{Snap: slot.Snap, Name: slot.Name},
+		}
+	}
+
+	// the bundle is installed or not as a whole, in a single change
+	changeID, err := x.client.InstallBundle(b.snapPaths, assertions, conns, opts)
+	if err != nil {
+		var snapName string
+		if err, ok := err.(*client.Error); ok {
+			snapName, _ = err.Value.(string)
+		}
+		msg, err := errorToCmdMessage(snapName, "install", err, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(Stderr, msg)
+		return nil
+	}
+	chg, err := x.wait(changeID)
+	if err != nil {
+		return err
+	}
+	var installed []string
+	if err := chg.Get("snap-names", &installed); err != nil && err != client.ErrNoData {
+		return err
+	}
+	if len(installed) > 0 {
+		return showDone(x.client, installed, "install", opts, x.getEscapes())
+	}
+
+	return nil
+}
+
 func (x *cmdInstall) installOne(nameOrPath, desiredName string, opts *client.SnapOptions) error {
 	var err error
 	var changeID string
@@ -632,6 +776,12 @@ func (x *cmdInstall) installMany(names []string, opts *client.SnapOptions) error
 }
 
 func (x *cmdInstall) Execute([]string) error {
+	restoreStdout, err := x.setupProgress(&x.waitMixin)
+	if err != nil {
+		return err
+	}
+	defer restoreStdout()
+
 	if err := x.setChannelFromCommandline(); err != nil {
 		return err
 	}
@@ -660,6 +810,16 @@ func (x *cmdInstall) Execute([]string) error {
 		}
 	}
 
+	if x.Bundle {
+		if len(names) != 1 {
+			return errors.New(i18n.G("a single bundle file must be specified with --bundle"))
+		}
+		if x.asksForChannel() || x.Revision != "" || x.Cohort != "" || x.Name != "" {
+			return errors.New(i18n.G("cannot use channel, revision, cohort or name flags when installing a bundle"))
+		}
+		return x.installBundle(names[0], opts)
+	}
+
 	if len(names) == 1 {
 		return x.installOne(names[0], x.Name, opts)
 	}
@@ -681,6 +841,7 @@ type cmdRefresh struct {
 	colorMixin
 	timeMixin
 	waitMixin
+	progressMixin
 	channelMixin
 	modeMixin
 
@@ -695,6 +856,8 @@ type cmdRefresh struct {
 	Transaction      client.TransactionType `long:"transaction" default:"per-snap" choice:"all-snaps" choice:"per-snap"`
 	Hold             string                 `long:"hold" optional:"yes" optional-value:"forever"`
 	Unhold           bool                   `long:"unhold"`
+	Explain          bool                   `long:"explain"`
+	EpochMigration   bool                   `long:"epoch-migration"`
 	Positional       struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -827,6 +990,12 @@ func (x *cmdRefresh) listRefresh() error {
 }
 
 func (x *cmdRefresh) Execute([]string) error {
+	restoreStdout, err := x.setupProgress(&x.waitMixin)
+	if err != nil {
+		return err
+	}
+	defer restoreStdout()
+
 	if err := x.setChannelFromCommandline(); err != nil {
 		return err
 	}
@@ -856,7 +1025,7 @@ func (x *cmdRefresh) Execute([]string) error {
 
 	otherFlags := x.Amend || x.Revision != "" || x.Cohort != "" ||
 		x.LeaveCohort || x.List || x.Time || x.IgnoreValidation || x.IgnoreRunning ||
-		x.Transaction != client.TransactionPerSnap
+		x.Transaction != client.TransactionPerSnap || x.Explain || x.EpochMigration
 
 	if x.Hold != "" && (x.Unhold || otherFlags) {
 		return errors.New(i18n.G("cannot use --hold with other flags"))
@@ -869,8 +1038,23 @@ func (x *cmdRefresh) Execute([]string) error {
 	}
 
 	names := installedSnapNames(x.Positional.Snaps)
+	if x.Explain || x.EpochMigration {
+		if len(names) != 1 {
+			return errors.New(i18n.G("--explain and --epoch-migration need a single snap name"))
+		}
+		if x.Explain && x.EpochMigration {
+			return errors.New(i18n.G("cannot use --explain and --epoch-migration together"))
+		}
+		if x.Revision != "" || x.Cohort != "" || x.LeaveCohort || x.Amend {
+			return errors.New(i18n.G("--explain and --epoch-migration do not take revision, cohort or amend flags"))
+		}
+		if x.Explain {
+			return x.explainEpochMigration(names[0])
+		}
+	}
 	if len(names) == 1 {
 		opts := &client.SnapOptions{
+			EpochMigration:   x.EpochMigration,
 			Amend:            x.Amend,
 			Channel:          x.Channel,
 			IgnoreValidation: x.IgnoreValidation,
@@ -901,6 +1085,43 @@ func (x *cmdRefresh) Execute([]string) error {
 	return x.refreshMany(names, opts)
 }
 
+func (x *cmdRefresh) explainEpochMigration(name string) error {
+	mig, err := x.client.EpochMigration(name, x.Channel)
+	if err != nil {
+		return err
+	}
+
+	target := fmt.Sprintf(i18n.G("revision %s (%s) of %q in channel %s"), mig.Target.Revision, mig.Target.Version, mig.Snap, mig.Channel)
+	current := fmt.Sprintf(i18n.G("installed revision %s"), mig.CurrentRevision)
+	switch {
+	case !mig.Reachable:
+		// TRANSLATORS: the first %s is a revision of a snap in a channel, the second one is the installed revision
+		fmt.Fprintf(Stdout, i18n.G("Cannot refresh to %s: no revision in the store can migrate the data of the %s from epoch %s to epoch %s.\n"),
+			target, current, mig.CurrentEpoch, mig.Target.Epoch)
+		return nil
+	case len(mig.Steps) == 1:
+		// TRANSLATORS: the first %s is a revision of a snap in a channel, the second one is the installed revision
+		fmt.Fprintf(Stdout, i18n.G("Can refresh directly to %s: its epoch %s can read the data of the %s (epoch %s).\n"),
+			target, mig.Target.Epoch, current, mig.CurrentEpoch)
+		return nil
+	}
+
+	// TRANSLATORS: the first %s is a revision of a snap in a channel, the second one is the installed revision
+	fmt.Fprintf(Stdout, i18n.G("Can refresh to %s through %d revisions: its epoch %s cannot read the data of the %s (epoch %s) directly.\n"),
+		target, len(mig.Steps), mig.Target.Epoch, current, mig.CurrentEpoch)
+	fmt.Fprintln(Stdout, i18n.G("The following revisions would be installed in order:"))
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Rev\tVersion\tEpoch\tChannel"))
+	for _, step := range mig.Steps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", step.Revision, step.Version, step.Epoch, step.Channel)
+	}
+	w.Flush()
+
+	fmt.Fprintf(Stdout, i18n.G("Use \"snap refresh --epoch-migration --channel=%s %s\" to install them as one change.\n"), mig.Channel, mig.Snap)
+	return nil
+}
+
 func (x *cmdRefresh) holdRefreshes() (err error) {
 	var opts client.SnapOptions
 
@@ -988,6 +1209,7 @@ type cmdTry struct {
 	waitMixin
 
 	modeMixin
+	Watch      bool `long:"watch"`
 	Positional struct {
 		SnapDir string `positional-arg-name:"<snap-dir>"`
 	} `positional-args:"yes"`
@@ -1012,7 +1234,7 @@ func (x *cmdTry) Execute([]string) error {
 		return err
 	}
 	name := x.Positional.SnapDir
-	opts := &client.SnapOptions{}
+	opts := &client.SnapOptions{Watch: x.Watch}
 	x.setModes(opts)
 
 	if name == "" {
@@ -1243,14 +1465,20 @@ func (x cmdSwitch) Execute(args []string) error {
 
 func init() {
 	addCommand("remove", shortRemoveHelp, longRemoveHelp, func() flags.Commander { return &cmdRemove{} },
-		waitDescs.also(map[string]string{
+		waitDescs.also(progressDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"revision": i18n.G("Remove only the given revision"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"purge": i18n.G("Remove the snap without saving a snapshot of its data"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"keep-cache": i18n.G("Keep the snap file in the cache for a later reinstall"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"cascade": i18n.G("Also remove the snaps that need the removed snaps"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"dry-run": i18n.G("Show the snaps that would be removed without removing them"),
 		}), nil)
 	addCommand("install", shortInstallHelp, longInstallHelp, func() flags.Commander { return &cmdInstall{} },
-		colorDescs.also(waitDescs).also(channelDescs).also(modeDescs).also(map[string]string{
+		colorDescs.also(waitDescs).also(progressDescs).also(channelDescs).also(modeDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"revision": i18n.G("Install the given revision of a snap, to which you must have developer access"),
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -1271,9 +1499,11 @@ func init() {
 			"transaction": i18n.G("Have one transaction per-snap or one for all the specified snaps"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"quota-group": i18n.G("Add the snap to a quota group on install"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"bundle": i18n.G("Install all the snaps, assertions and connections of the given bundle file"),
 		}), nil)
 	addCommand("refresh", shortRefreshHelp, longRefreshHelp, func() flags.Commander { return &cmdRefresh{} },
-		colorDescs.also(waitDescs).also(channelDescs).also(modeDescs).also(timeDescs).also(map[string]string{
+		colorDescs.also(waitDescs).also(progressDescs).also(channelDescs).also(modeDescs).also(timeDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"amend": i18n.G("Allow refresh attempt on snap unknown to the store"),
 			// TRANSLATORS: This should not start with a lowercase letter.
@@ -1296,8 +1526,15 @@ func init() {
 			"hold": i18n.G("Hold refreshes for a specified duration (or indefinitely, if none is specified)"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"unhold": i18n.G("Remove refresh hold"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"explain": i18n.G("Explain whether the target revision can be reached given the epochs, and through which revisions"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"epoch-migration": i18n.G("Refresh through the intermediate revisions needed to migrate the data across epochs, as one change"),
 		}), nil)
-	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
+	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs).also(map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"watch": i18n.G("Try the snap again when the contents of the directory change"),
+	}), nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, waitDescs, nil)
 	addCommand("disable", shortDisableHelp, longDisableHelp, func() flags.Commander { return &cmdDisable{} }, waitDescs, nil)
 	addCommand("revert", shortRevertHelp, longRevertHelp, func() flags.Commander { return &cmdRevert{} }, waitDescs.also(modeDescs).also(map[string]string{
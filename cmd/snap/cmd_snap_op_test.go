@@ -20,6 +20,8 @@
 package main_test
 
 import (
+	"archive/tar"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"mime"
@@ -29,6 +31,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -1053,6 +1056,165 @@ func (s *SnapOpSuite) TestInstallPathManyTransactional(c *check.C) {
 	c.Check(n, check.Equals, total)
 }
 
+func makeSnapBundle(c *check.C, files map[string]string) string {
+	path := filepath.Join(c.MkDir(), "bundle.tar")
+	f, err := os.Create(path)
+	c.Assert(err, check.IsNil)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	// sort for a stable order of entries
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(files[name])),
+		})
+		c.Assert(err, check.IsNil)
+		_, err = tw.Write([]byte(files[name]))
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(tw.Close(), check.IsNil)
+	return path
+}
+
+func (s *SnapOpSuite) TestInstallBundle(c *check.C) {
+	bundlePath := makeSnapBundle(c, map[string]string{
+		"bundle.yaml": `
+assertions: [bar.assert, foo.assert]
+snaps: [bar.snap, foo.snap]
+connections:
+  - plug: foo:data
+    slot: bar:data
+  - plug: foo:network
+`,
+		"foo.snap":   "foo-snap-data",
+		"bar.snap":   "bar-snap-data",
+		"foo.assert": "foo-assert-data",
+		"bar.assert": "bar-assert-data",
+	})
+
+	snaps := []string{"bar.snap", "foo.snap"}
+	total := 3
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(r.Method, check.Equals, "POST")
+
+			form := testForm(r, c)
+			defer form.RemoveAll()
+			c.Check(form.Value["action"], check.DeepEquals, []string{"install"})
+			c.Check(form.Value["transaction"], check.DeepEquals, []string{string(client.TransactionAllSnaps)})
+			c.Check(form.Value["assertion"], check.DeepEquals, []string{"bar-assert-data", "foo-assert-data"})
+			c.Assert(form.Value["connections"], check.HasLen, 1)
+			var conns []interface{}
+			c.Assert(json.Unmarshal([]byte(form.Value["connections"][0]), &conns), check.IsNil)
+			c.Check(conns, check.DeepEquals, []interface{}{
+				map[string]interface{}{
+					"plug": map[string]interface{}{"snap": "foo", "plug": "data"},
+					"slot": map[string]interface{}{"snap": "bar", "slot": "data"},
+				},
+				map[string]interface{}{
+					"plug": map[string]interface{}{"snap": "foo", "plug": "network"},
+					"slot": map[string]interface{}{"snap": "", "slot": ""},
+				},
+			})
+			names, filenames, bodies := formFiles(form, c)
+			c.Check(names, check.DeepEquals, []string{"snap"})
+			c.Check(filenames, check.DeepEquals, snaps)
+			c.Assert(bodies, check.HasLen, 2)
+			c.Check(string(bodies[0]), check.Equals, "bar-snap-data")
+			c.Check(string(bodies[1]), check.Equals, "foo-snap-data")
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"snap-names": ["bar","foo"]}}}`)
+		case 2:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			fmt.Fprintf(w, `{"type": "sync", "result": [{"name": "bar", "version": "1.0", "developer": "bar", "publisher": {"id": "bar-id", "username": "bar", "display-name": "Bar"}},{"name": "foo", "version": "2.0", "developer": "baz", "publisher": {"id": "baz-id", "username": "baz", "display-name": "Baz"}}]}\n`)
+		default:
+			c.Fatalf("expected to get %d requests, now on %d", total, n+1)
+		}
+
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--bundle", bundlePath})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+
+	c.Check(s.Stdout(), check.Matches, `(?sm).*bar 1.0 from Bar installed`)
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo 2.0 from Baz installed`)
+	c.Check(s.Stderr(), check.Equals, "")
+
+	c.Check(n, check.Equals, total)
+}
+
+func (s *SnapOpSuite) TestInstallBundleErrors(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request to %q", r.URL.Path)
+	})
+
+	for _, tc := range []struct {
+		files map[string]string
+		args  []string
+		err   string
+	}{{
+		files: map[string]string{"foo.snap": "data"},
+		err:   `cannot read bundle: missing bundle.yaml`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: []"},
+		err:   `invalid bundle manifest: no snaps listed`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [foo.snap]\nfoo: bar\n"},
+		err:   `(?s)cannot read bundle manifest: .*field foo not found.*`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [foo.snap]"},
+		err:   `invalid bundle manifest: file "foo.snap" not found in bundle`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [foo.snap, foo.snap]", "foo.snap": "data"},
+		err:   `invalid bundle manifest: file "foo.snap" listed more than once`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [../foo.snap]"},
+		err:   `invalid bundle manifest: invalid file name "../foo.snap"`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [foo.snap]", "foo.snap": "data", "dir/bar.snap": "data"},
+		err:   `cannot read bundle: unexpected entry "dir/bar.snap"`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [foo.snap]\nconnections: [{plug: foo}]", "foo.snap": "data"},
+		err:   `invalid bundle manifest: invalid plug "foo" in connection \(want snap:plug\)`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [foo.snap]\nconnections: [{plug: 'foo:bar', slot: ':'}]", "foo.snap": "data"},
+		err:   `invalid bundle manifest: invalid slot ":" in connection \(want snap:slot or snap\)`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [foo.snap]", "foo.snap": "data"},
+		args:  []string{"--no-wait"},
+		err:   `cannot use --no-wait when installing a bundle`,
+	}, {
+		files: map[string]string{"bundle.yaml": "snaps: [foo.snap]", "foo.snap": "data"},
+		args:  []string{"--channel=edge"},
+		err:   `cannot use channel, revision, cohort or name flags when installing a bundle`,
+	}} {
+		bundlePath := makeSnapBundle(c, tc.files)
+		args := append([]string{"install", "--bundle", bundlePath}, tc.args...)
+		_, err := snap.Parser(snap.Client()).ParseArgs(args)
+		c.Check(err, check.ErrorMatches, tc.err, check.Commentf("%v", tc.files))
+	}
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"install", "--bundle", "one.tar", "two.tar"})
+	c.Check(err, check.ErrorMatches, `a single bundle file must be specified with --bundle`)
+}
+
 func (s *SnapOpSuite) TestInstallPathInstance(c *check.C) {
 	s.srv.checker = func(r *http.Request) {
 		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
@@ -1324,6 +1486,31 @@ foo +4.2update1 +17 +436MB +bar +-.*
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshListSecurity(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			c.Check(r.URL.Query().Get("select"), check.Equals, "refresh")
+			fmt.Fprintln(w, `{"type": "sync", "result": [{"name": "foo", "status": "active", "version": "4.2update1", "developer": "bar", "download-size": 436375552, "publisher": {"id": "bar-id", "username": "bar", "display-name": "Bar", "validation": "unproven"}, "revision":17,"summary":"some summary","security":{"severity":"critical","cves":["CVE-2023-1234"]}}]}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--list"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `Name +Version +Rev +Size +Publisher +Notes
+foo +4.2update1 +17 +436MB +bar +security-critical
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
 func (s *SnapSuite) TestRefreshLegacyTime(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -1719,6 +1906,101 @@ func (s *SnapOpSuite) TestRefreshOne(c *check.C) {
 
 }
 
+func (s *SnapOpSuite) TestRefreshOneEpochMigration(c *check.C) {
+	s.RedirectClientToTestServer(s.srv.handle)
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":          "refresh",
+			"channel":         "2/stable",
+			"epoch-migration": true,
+			"transaction":     string(client.TransactionPerSnap),
+		})
+		s.srv.channel = "2/stable"
+	}
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--epoch-migration", "--channel=2/stable", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo \(2/stable\) 1.0 from Bar refreshed`)
+}
+
+func (s *SnapOpSuite) TestRefreshExplainMultiStep(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo/epoch-migration")
+		c.Check(r.URL.Query().Get("channel"), check.Equals, "2/stable")
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+"snap": "foo", "channel": "2/stable", "current-revision": "7", "current-epoch": "1",
+"target": {"revision": "11", "version": "2.0", "epoch": "3*", "channel": "2/stable"},
+"reachable": true,
+"steps": [
+  {"revision": "10", "version": "1.5", "epoch": "2*", "channel": "latest/beta"},
+  {"revision": "11", "version": "2.0", "epoch": "3*", "channel": "2/stable"}
+]}}`)
+		n++
+	})
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--explain", "--channel=2/stable", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `Can refresh to revision 11 (2.0) of "foo" in channel 2/stable through 2 revisions: its epoch 3* cannot read the data of the installed revision 7 (epoch 1) directly.
+The following revisions would be installed in order:
+Rev  Version  Epoch  Channel
+10   1.5      2*     latest/beta
+11   2.0      3*     2/stable
+Use "snap refresh --epoch-migration --channel=2/stable foo" to install them as one change.
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapOpSuite) TestRefreshExplainDirectAndUnreachable(c *check.C) {
+	var rsp string
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo/epoch-migration")
+		c.Check(r.URL.RawQuery, check.Equals, "")
+		fmt.Fprintln(w, rsp)
+	})
+
+	rsp = `{"type": "sync", "result": {
+"snap": "foo", "channel": "latest/stable", "current-revision": "7", "current-epoch": "1",
+"target": {"revision": "11", "version": "2.0", "epoch": "2*", "channel": "latest/stable"},
+"reachable": true,
+"steps": [{"revision": "11", "version": "2.0", "epoch": "2*", "channel": "latest/stable"}]}}`
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--explain", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Can refresh directly to revision 11 (2.0) of "foo" in channel latest/stable: its epoch 2* can read the data of the installed revision 7 (epoch 1).
+`)
+	s.ResetStdStreams()
+
+	rsp = `{"type": "sync", "result": {
+"snap": "foo", "channel": "latest/stable", "current-revision": "7", "current-epoch": "1",
+"target": {"revision": "11", "version": "2.0", "epoch": "5", "channel": "latest/stable"},
+"reachable": false}}`
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--explain", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Cannot refresh to revision 11 (2.0) of "foo" in channel latest/stable: no revision in the store can migrate the data of the installed revision 7 from epoch 1 to epoch 5.
+`)
+}
+
+func (s *SnapOpSuite) TestRefreshExplainErrors(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"refresh", "--explain"}, "--explain and --epoch-migration need a single snap name"},
+		{[]string{"refresh", "--epoch-migration", "foo", "bar"}, "--explain and --epoch-migration need a single snap name"},
+		{[]string{"refresh", "--explain", "--epoch-migration", "foo"}, "cannot use --explain and --epoch-migration together"},
+		{[]string{"refresh", "--explain", "--revision=2", "foo"}, "--explain and --epoch-migration do not take revision, cohort or amend flags"},
+		{[]string{"refresh", "--epoch-migration", "--hold", "foo"}, "cannot use --hold with other flags"},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(t.args)
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("%v", t.args))
+	}
+}
+
 func (s *SnapOpSuite) TestRefreshOneSwitchChannel(c *check.C) {
 	s.RedirectClientToTestServer(s.srv.handle)
 	s.srv.checker = func(r *http.Request) {
@@ -2018,6 +2300,7 @@ func (s *SnapOpSuite) runTryTest(c *check.C, opts *client.SnapOptions) {
 		{opts.DevMode, "devmode"},
 		{opts.JailMode, "jailmode"},
 		{opts.Classic, "classic"},
+		{opts.Watch, "watch"},
 	}
 
 	s.srv.checker = func(r *http.Request) {
@@ -2079,6 +2362,10 @@ func (s *SnapOpSuite) TestTryClassic(c *check.C) {
 	s.runTryTest(c, &client.SnapOptions{Classic: true})
 }
 
+func (s *SnapOpSuite) TestTryWatch(c *check.C) {
+	s.runTryTest(c, &client.SnapOptions{DevMode: true, Watch: true})
+}
+
 func (s *SnapOpSuite) TestTryNoSnapDirErrors(c *check.C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		c.Check(r.Method, check.Equals, "POST")
@@ -2330,6 +2617,73 @@ func (s *SnapOpSuite) TestRemoveWithPurge(c *check.C) {
 	c.Check(s.srv.n, check.Equals, s.srv.total)
 }
 
+func (s *SnapOpSuite) TestRemoveWithKeepCache(c *check.C) {
+	s.srv.total = 3
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":     "remove",
+			"keep-cache": true,
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", "--keep-cache", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?sm).*foo removed`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestRemoveProgressJSON(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1, 2:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "kind": "remove-snap", "status": "Doing", "tasks": [
+  {"id": "1", "kind": "remove-data", "summary": "Remove data", "status": "Doing", "progress": {"label": "foo", "done": 512, "total": 1024, "unit": "bytes", "eta": 1.5}, "log": ["2024-10-17T12:00:00Z INFO removing"]},
+  {"id": "2", "kind": "discard-snap", "summary": "Discard", "status": "Do", "progress": {"done": 0, "total": 1}}
+]}}`)
+		case 3:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "kind": "remove-snap", "summary": "Remove foo", "status": "Done", "ready": true, "data": {"snap-name": "foo"}, "tasks": [
+  {"id": "1", "kind": "remove-data", "summary": "Remove data", "status": "Done", "progress": {"label": "foo", "done": 1024, "total": 1024, "unit": "bytes"}, "log": ["2024-10-17T12:00:00Z INFO removing"]},
+  {"id": "2", "kind": "discard-snap", "summary": "Discard", "status": "Done", "progress": {"done": 1, "total": 1}}
+]}}`)
+		default:
+			c.Fatalf("expected to get 4 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", "--progress=json", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(n, check.Equals, 4)
+	c.Check(s.Stdout(), check.Equals, `{"type":"task","change":"42","task":"1","kind":"remove-data","summary":"Remove data","status":"Doing","progress":{"label":"foo","done":512,"total":1024,"unit":"bytes","percent":50,"eta":1.5}}
+{"type":"log","change":"42","task":"1","message":"2024-10-17T12:00:00Z INFO removing"}
+{"type":"task","change":"42","task":"1","kind":"remove-data","summary":"Remove data","status":"Done","progress":{"label":"foo","done":1024,"total":1024,"unit":"bytes","percent":100}}
+{"type":"task","change":"42","task":"2","kind":"discard-snap","summary":"Discard","status":"Done","progress":{"done":1,"total":1,"percent":100}}
+{"type":"change","change":"42","kind":"remove-snap","summary":"Remove foo","status":"Done"}
+`)
+	// the usual output goes to stderr
+	c.Check(s.Stderr(), check.Equals, "foo removed\n")
+}
+
+func (s *SnapOpSuite) TestRemoveProgressJSONNoWait(c *check.C) {
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", "--progress=json", "--no-wait", "foo"})
+	c.Assert(err, check.ErrorMatches, `cannot use --no-wait and --progress=json together`)
+}
+
 func (s *SnapOpSuite) TestRemoveInsufficientDiskSpace(c *check.C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, `{
@@ -2372,6 +2726,31 @@ func (s *SnapOpSuite) TestInstallInsufficientDiskSpace(c *check.C) {
 	c.Check(s.Stderr(), check.Equals, "")
 }
 
+func (s *SnapOpSuite) TestRefreshInsufficientDiskSpaceShortfalls(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{
+			"type": "error",
+			"result": {
+				"message": "disk space error",
+				"kind": "insufficient-disk-space",
+				"value": {
+					"snap-names": ["foo"],
+					"change-kind": "refresh",
+					"shortfalls": [
+						{"path": "/var/lib/snapd", "required": 20000000, "missing": 12000000},
+						{"path": "/var/snap", "required": 6000000, "missing": 1000}
+					]
+				},
+				"status-code": 507
+				}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "foo"})
+	c.Check(err, check.ErrorMatches, `(?s)cannot refresh "foo" due to low disk space: at least 12MB more\s+is required in "/var/lib/snapd", at least 1kB more is required in\s+"/var/snap"`)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
 func (s *SnapOpSuite) TestRefreshInsufficientDiskSpace(c *check.C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, `{
@@ -2459,6 +2838,87 @@ func (s *SnapOpSuite) TestRemoveMany(c *check.C) {
 	c.Check(n, check.Equals, total)
 }
 
+func (s *SnapOpSuite) TestRemoveCascade(c *check.C) {
+	total := 2
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":  "remove",
+				"snaps":   []interface{}{"provider"},
+				"cascade": true,
+			})
+
+			c.Check(r.Method, check.Equals, "POST")
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"snap-names": ["consumer","provider"]}}}`)
+		default:
+			c.Fatalf("expected to get %d requests, now on %d", total, n+1)
+		}
+
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", "--cascade", "provider"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "consumer removed\nprovider removed\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, total)
+}
+
+func (s *SnapOpSuite) TestRemoveDryRun(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action":  "remove",
+				"snaps":   []interface{}{"provider", "other"},
+				"cascade": true,
+				"dry-run": true,
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": {"snap-names": ["consumer", "provider"]}}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", "--cascade", "--dry-run", "provider", "other"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "consumer would be removed\nprovider would be removed\nother not installed\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapOpSuite) TestRemoveDryRunDependents(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "snap \"provider\" is not removable: snap is needed by snap \"consumer\"", "kind": "snap-has-dependents", "value": "provider"}, "status-code": 400}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", "--dry-run", "provider"})
+	c.Assert(err, check.ErrorMatches, `(?s)snap "provider" is not removable: snap is needed by snap "consumer".*Use --cascade to remove the snaps that need it as well.`)
+}
+
+func (s *SnapOpSuite) TestRemoveCascadeRevision(c *check.C) {
+	for _, flag := range []string{"--cascade", "--dry-run"} {
+		_, err := snap.Parser(snap.Client()).ParseArgs([]string{"remove", flag, "--revision=2", "foo"})
+		c.Check(err, check.ErrorMatches, "cannot use --revision with "+flag)
+	}
+}
+
 func (s *SnapOpSuite) TestRemoveManyPurge(c *check.C) {
 	n := 0
 	errMsg := "stopping test after creating change successfully"
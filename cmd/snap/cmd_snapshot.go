@@ -28,6 +28,7 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
@@ -104,11 +105,19 @@ restriction may be lifted in the future.
 
 var longExportSnapshotHelp = i18n.G(`
 Export a snapshot to the given filename.
+
+With --encrypt=device the export is encrypted with a key bound to this
+device, it can then only be imported on this device. With
+--encrypt=passphrase the export is encrypted with a key derived from a
+passphrase that is asked for, it can then be imported on any device
+given the passphrase.
 `)
 
 var longImportSnapshotHelp = i18n.G(`
 Import an exported snapshot set to the system. The snapshot is imported
 with a new snapshot ID and can be restored using the restore command.
+
+Exports encrypted with a passphrase need the --passphrase option.
 `)
 
 type savedCmd struct {
@@ -404,7 +413,10 @@ func init() {
 		longExportSnapshotHelp,
 		func() flags.Commander {
 			return &exportSnapshotCmd{}
-		}, nil, []argDesc{
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"encrypt": i18n.G("Encrypt the export with a key bound to the device or derived from a passphrase"),
+		}, []argDesc{
 			{
 				name: "<id>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -423,7 +435,10 @@ func init() {
 		longImportSnapshotHelp,
 		func() flags.Commander {
 			return &importSnapshotCmd{}
-		}, nil, []argDesc{
+		}, durationDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"passphrase": i18n.G("Ask for the passphrase the export is encrypted with"),
+		}), []argDesc{
 			{
 				name: "<filename>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -432,8 +447,19 @@ func init() {
 		})
 }
 
+func readSnapshotPassphrase(prompt string) (string, error) {
+	fmt.Fprint(Stdout, prompt)
+	passphrase, err := ReadPassword(0)
+	fmt.Fprint(Stdout, "\n")
+	if err != nil {
+		return "", err
+	}
+	return string(passphrase), nil
+}
+
 type exportSnapshotCmd struct {
 	clientMixin
+	Encrypt    string `long:"encrypt" choice:"device" choice:"passphrase"`
 	Positional struct {
 		ID       snapshotID `positional-arg-name:"<id>"`
 		Filename string     `long:"filename"`
@@ -446,7 +472,26 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 		return err
 	}
 
-	r, expectedSize, err := x.client.SnapshotExport(setID)
+	opts := &client.SnapshotExportOptions{Encryption: x.Encrypt}
+	if x.Encrypt == client.SnapshotEncryptionPassphrase {
+		passphrase, err := readSnapshotPassphrase(i18n.G("Passphrase: "))
+		if err != nil {
+			return err
+		}
+		if passphrase == "" {
+			return fmt.Errorf(i18n.G("cannot encrypt snapshot with an empty passphrase"))
+		}
+		again, err := readSnapshotPassphrase(i18n.G("Repeat passphrase: "))
+		if err != nil {
+			return err
+		}
+		if again != passphrase {
+			return fmt.Errorf(i18n.G("passphrases do not match"))
+		}
+		opts.Passphrase = passphrase
+	}
+
+	r, expectedSize, err := x.client.SnapshotExport(setID, opts)
 	if err != nil {
 		return err
 	}
@@ -488,6 +533,7 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 type importSnapshotCmd struct {
 	clientMixin
 	durationMixin
+	Passphrase bool `long:"passphrase"`
 	Positional struct {
 		Filename string `long:"filename"`
 	} `positional-args:"yes" required:"yes"`
@@ -505,7 +551,15 @@ func (x *importSnapshotCmd) Execute([]string) error {
 		return fmt.Errorf("cannot stat file: %v", err)
 	}
 
-	importSet, err := x.client.SnapshotImport(f, st.Size())
+	var opts client.SnapshotImportOptions
+	if x.Passphrase {
+		opts.Passphrase, err = readSnapshotPassphrase(i18n.G("Passphrase: "))
+		if err != nil {
+			return err
+		}
+	}
+
+	importSet, err := x.client.SnapshotImport(f, st.Size(), &opts)
 	if err != nil {
 		return err
 	}
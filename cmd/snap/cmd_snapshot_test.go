@@ -113,6 +113,59 @@ func (s *SnapSuite) TestSnapshotExportHappy(c *C) {
 	c.Check(exportedSnapshotPath+".part", testutil.FileAbsent)
 }
 
+func (s *SnapSuite) mockEncryptedSnapshotExportServer(c *C, encryption, passphrase string) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/snapshots/1/export")
+		c.Check(r.URL.Query().Get("encryption"), Equals, encryption)
+		c.Check(r.Header.Get(client.SnapshotPassphraseHeader), Equals, passphrase)
+		w.Header().Set("Content-Type", client.SnapshotExportMediaType)
+		fmt.Fprint(w, "Encrypted!")
+	})
+}
+
+func (s *SnapSuite) TestSnapshotExportEncryptedDevice(c *C) {
+	s.mockEncryptedSnapshotExportServer(c, "device", "")
+
+	exportedSnapshotPath := filepath.Join(c.MkDir(), "export-snapshot.snapshot")
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--encrypt=device", "1", exportedSnapshotPath})
+	c.Check(err, IsNil)
+	c.Check(s.Stdout(), testutil.MatchesWrapped, `Exported snapshot #1 into ".*/export-snapshot.snapshot"`)
+	c.Check(exportedSnapshotPath, testutil.FileEquals, "Encrypted!")
+}
+
+func (s *SnapSuite) TestSnapshotExportEncryptedPassphrase(c *C) {
+	s.mockEncryptedSnapshotExportServer(c, "passphrase", "sekrit")
+	s.password = "sekrit"
+
+	exportedSnapshotPath := filepath.Join(c.MkDir(), "export-snapshot.snapshot")
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--encrypt=passphrase", "1", exportedSnapshotPath})
+	c.Check(err, IsNil)
+	c.Check(s.Stdout(), testutil.MatchesWrapped, `Passphrase: \nRepeat passphrase: \nExported snapshot #1 into ".*/export-snapshot.snapshot"`)
+	c.Check(exportedSnapshotPath, testutil.FileEquals, "Encrypted!")
+}
+
+func (s *SnapSuite) TestSnapshotExportEncryptedPassphraseMismatch(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request to %q", r.URL.Path)
+	})
+	passphrases := []string{"sekrit", "secret"}
+	main.ReadPassword = func(int) ([]byte, error) {
+		p := passphrases[0]
+		passphrases = passphrases[1:]
+		return []byte(p), nil
+	}
+
+	exportedSnapshotPath := filepath.Join(c.MkDir(), "export-snapshot.snapshot")
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--encrypt=passphrase", "1", exportedSnapshotPath})
+	c.Check(err, ErrorMatches, "passphrases do not match")
+	c.Check(exportedSnapshotPath, testutil.FileAbsent)
+
+	s.password = ""
+	main.ReadPassword = s.readPassword
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--encrypt=passphrase", "1", exportedSnapshotPath})
+	c.Check(err, ErrorMatches, "cannot encrypt snapshot with an empty passphrase")
+}
+
 func (s *SnapSuite) mockSnapshotsServer(c *C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -167,3 +220,27 @@ Set  Snap  Age    Version  Rev   Size    Notes
 1    htop  %-6s 2        1168      1B  -
 `, ageStr))
 }
+
+func (s *SnapSuite) TestSnapshotImportPassphrase(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			if r.Method == "POST" {
+				c.Check(r.Header.Get(client.SnapshotPassphraseHeader), Equals, "sekrit")
+				fmt.Fprintln(w, `{"type": "sync", "result": {"set-id": 42, "snaps": ["htop"]}}`)
+				return
+			}
+			fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[]}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+	s.password = "sekrit"
+
+	exportedSnapshotPath := filepath.Join(c.MkDir(), "mocked-snapshot.snapshot")
+	ioutil.WriteFile(exportedSnapshotPath, []byte("this is really encrypted snapshot data"), 0644)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"import-snapshot", "--passphrase", exportedSnapshotPath})
+	c.Check(err, IsNil)
+	c.Check(s.Stdout(), testutil.Contains, "Passphrase: \nImported snapshot as #42\n")
+}
@@ -0,0 +1,347 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/image"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+type cmdVerifyImageManifest struct {
+	clientMixin
+	Assertion  flags.Filename `long:"assertion" value-name:"<assertion-file>"`
+	Positional struct {
+		ManifestFile flags.Filename
+	} `positional-args:"true" required:"true"`
+}
+
+var shortVerifyImageManifestHelp = i18n.G("Verify the device against an image build manifest")
+var longVerifyImageManifestHelp = i18n.G(`
+The verify-image-manifest command checks that the model, snaps, assertions
+and boot assets of the device match those recorded in the given build
+manifest, as written by 'snap prepare-image --manifest'. The boot assets are
+checked as deployed on the boot partitions and on the disk.
+
+With --assertion the signature of the image-manifest assertion for the
+manifest is checked, without adding it to the system, and the manifest is
+checked to be the one it refers to.
+`)
+
+func init() {
+	addCommand("verify-image-manifest", shortVerifyImageManifestHelp, longVerifyImageManifestHelp, func() flags.Commander {
+		return &cmdVerifyImageManifest{}
+	}, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"assertion": i18n.G("File with the signed image-manifest assertion for the manifest"),
+	}, []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<manifest file>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Image build manifest file"),
+	}})
+}
+
+var errImageManifestMismatch = errors.New(i18n.G("device does not match the image manifest"))
+
+func (x *cmdVerifyImageManifest) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	manifestFn := string(x.Positional.ManifestFile)
+	m, err := image.ReadManifest(manifestFn)
+	if err != nil {
+		return err
+	}
+
+	var mismatches []string
+	mismatch := func(format string, a ...interface{}) {
+		mismatches = append(mismatches, fmt.Sprintf(format, a...))
+	}
+
+	if x.Assertion != "" {
+		ima, err := x.checkImageManifestAssertion(string(x.Assertion))
+		if err != nil {
+			return err
+		}
+		digest, err := fileSHA3_384(manifestFn)
+		if err != nil {
+			return err
+		}
+		if ima.ManifestSHA3_384() != digest {
+			mismatch(i18n.G("manifest does not match the digest in the image-manifest assertion"))
+		}
+		if ima.BrandID() != m.BrandID || ima.Model() != m.Model || ima.BuildID() != m.BuildID {
+			mismatch(i18n.G("manifest is for %s/%s build %s but the image-manifest assertion is for %s/%s build %s"),
+				m.BrandID, m.Model, m.BuildID, ima.BrandID(), ima.Model(), ima.BuildID())
+		}
+	}
+
+	model, err := x.client.CurrentModelAssertion()
+	if err != nil {
+		return err
+	}
+	if model.BrandID() != m.BrandID || model.Model() != m.Model {
+		mismatch(i18n.G("device model is %s/%s, not %s/%s"), model.BrandID(), model.Model(), m.BrandID, m.Model)
+	}
+
+	installed, err := x.client.List(nil, nil)
+	if err != nil {
+		return err
+	}
+	installedByName := make(map[string]*client.Snap, len(installed))
+	for _, sn := range installed {
+		installedByName[sn.Name] = sn
+	}
+	for _, ms := range m.Snaps {
+		sn := installedByName[ms.Name]
+		if sn == nil {
+			mismatch(i18n.G("snap %q is not installed"), ms.Name)
+			continue
+		}
+		if sn.Revision != ms.Revision {
+			mismatch(i18n.G("snap %q is at revision %s, not %s"), ms.Name, sn.Revision, ms.Revision)
+			continue
+		}
+		if err := x.checkSnapDigest(ms); err != nil {
+			mismatch("%v", err)
+		}
+	}
+
+	for _, ma := range m.Assertions {
+		as, err := x.client.Known(ma.Type, ma.PrimaryKey, nil)
+		if err != nil {
+			return err
+		}
+		if len(as) == 0 {
+			mismatch(i18n.G("%s assertion %v is not known"), ma.Type, ma.PrimaryKey)
+			continue
+		}
+		if as[0].Revision() < ma.Revision {
+			mismatch(i18n.G("%s assertion %v is at revision %d, older than %d"), ma.Type, ma.PrimaryKey, as[0].Revision(), ma.Revision)
+		}
+	}
+
+	x.checkAssets(m.Assets, mismatch)
+
+	if len(mismatches) != 0 {
+		for _, msg := range mismatches {
+			fmt.Fprintf(Stdout, "- %s\n", msg)
+		}
+		return errImageManifestMismatch
+	}
+
+	// TRANSLATORS: the first %s is the brand, then the model and the build id
+	fmt.Fprintf(Stdout, i18n.G("Device matches the image manifest of %s/%s build %s\n"), m.BrandID, m.Model, m.BuildID)
+	return nil
+}
+
+// checkImageManifestAssertion checks the signature of the image-manifest
+// assertion in the given file and returns it. The check is done with a
+// temporary assertion database trusting the same root keys as the system,
+// the prerequisites of the assertion missing from the file are taken from
+// the ones known to the system, which is left unchanged.
+func (x *cmdVerifyImageManifest) checkImageManifestAssertion(fn string) (*asserts.ImageManifest, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	inFile := make(map[string]asserts.Assertion)
+	var imaRef *asserts.Ref
+	dec := asserts.NewDecoder(f)
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf(i18n.G("cannot read image manifest assertion: %v"), err)
+		}
+		inFile[a.Ref().Unique()] = a
+		if a.Type() == asserts.ImageManifestType {
+			imaRef = a.Ref()
+		}
+	}
+	if imaRef == nil {
+		return nil, fmt.Errorf(i18n.G("no image-manifest assertion in %q"), fn)
+	}
+
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   sysdb.Trusted(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
+		if a, ok := inFile[ref.Unique()]; ok {
+			return a, nil
+		}
+		return ref.Resolve(func(assertType *asserts.AssertionType, headers map[string]string) (asserts.Assertion, error) {
+			as, err := x.client.Known(assertType.Name, headers, nil)
+			if err != nil {
+				return nil, err
+			}
+			if len(as) == 0 {
+				return nil, &asserts.NotFoundError{Type: assertType, Headers: headers}
+			}
+			return as[0], nil
+		})
+	}
+	batch := asserts.NewBatch(nil)
+	err = batch.Fetch(db, retrieve, func(f asserts.Fetcher) error {
+		return f.Fetch(imaRef)
+	})
+	if err == nil {
+		err = batch.CommitTo(db, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot verify image manifest assertion: %v"), err)
+	}
+	a, err := imaRef.Resolve(db.Find)
+	if err != nil {
+		return nil, err
+	}
+	return a.(*asserts.ImageManifest), nil
+}
+
+// structureMountDirs are where the filesystems of the structures with
+// the given roles are mounted in run mode.
+var structureMountDirs = map[string]*string{
+	gadget.SystemSeed: &boot.InitramfsUbuntuSeedDir,
+	gadget.SystemBoot: &boot.InitramfsUbuntuBootDir,
+}
+
+// checkAssets checks the boot assets as they are deployed on the boot
+// partitions, and for raw content, on the disk.
+func (x *cmdVerifyImageManifest) checkAssets(assets []*image.ManifestAsset, mismatch func(format string, a ...interface{})) {
+	var volumes map[string]gadget.DiskVolumeDeviceTraits
+	var volumesErr error
+	for _, asset := range assets {
+		var digest string
+		var err error
+		switch {
+		case asset.Offset != nil:
+			if volumes == nil && volumesErr == nil {
+				volumesErr = x.client.DebugGet("gadget-disk-mapping", &volumes, nil)
+			}
+			if volumesErr != nil {
+				err = volumesErr
+				break
+			}
+			vol, ok := volumes[asset.Volume]
+			if !ok {
+				err = fmt.Errorf(i18n.G("cannot find disk of volume %q"), asset.Volume)
+				break
+			}
+			digest, err = regionSHA3_384(vol.OriginalKernelPath, int64(*asset.Offset), int64(asset.Size))
+		case asset.Target != "":
+			mountDir := structureMountDirs[asset.Role]
+			if mountDir == nil {
+				fmt.Fprintf(Stderr, i18n.G("WARNING: cannot check asset %q of snap %q written to structure %q\n"), asset.Path, asset.Snap, asset.Structure)
+				continue
+			}
+			digest, err = fileSHA3_384(filepath.Join(*mountDir, asset.Target))
+		default:
+			err = fmt.Errorf(i18n.G("unknown location"))
+		}
+		if err != nil {
+			mismatch(i18n.G("cannot check asset %q of snap %q: %v"), asset.Path, asset.Snap, err)
+			continue
+		}
+		if digest != asset.SHA3_384 {
+			mismatch(i18n.G("asset %q of snap %q has been modified"), asset.Path, asset.Snap)
+		}
+	}
+}
+
+// checkSnapDigest checks that the installed revision of the snap is the one
+// built into the image. For asserted snaps this is vouched by the
+// snap-revision assertion known to the system, unasserted ones need to be
+// checked directly.
+func (x *cmdVerifyImageManifest) checkSnapDigest(ms *image.ManifestSnap) error {
+	if ms.SnapID == "" {
+		blob := snap.MinimalPlaceInfo(ms.Name, ms.Revision).MountFile()
+		digest, _, err := asserts.SnapFileSHA3_384(blob)
+		if err != nil {
+			return fmt.Errorf(i18n.G("cannot check snap %q: %v"), ms.Name, err)
+		}
+		if digest != ms.SHA3_384 {
+			return fmt.Errorf(i18n.G("snap %q has been modified"), ms.Name)
+		}
+		return nil
+	}
+
+	as, err := x.client.Known("snap-revision", map[string]string{"snap-sha3-384": ms.SHA3_384}, nil)
+	if err != nil {
+		return err
+	}
+	if len(as) == 0 {
+		return fmt.Errorf(i18n.G("snap %q revision %s from the image is not known"), ms.Name, ms.Revision)
+	}
+	snapRev := as[0].(*asserts.SnapRevision)
+	if snapRev.SnapID() != ms.SnapID || snapRev.SnapRevision() != ms.Revision.N {
+		return fmt.Errorf(i18n.G("snap %q from the image does not match its snap-revision assertion"), ms.Name)
+	}
+	return nil
+}
+
+func fileSHA3_384(fn string) (string, error) {
+	digest, _, err := osutil.FileDigest(fn, crypto.SHA3_384)
+	if err != nil {
+		return "", err
+	}
+	return asserts.EncodeDigest(crypto.SHA3_384, digest)
+}
+
+func regionSHA3_384(device string, offset, size int64) (string, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := crypto.SHA3_384.New()
+	n, err := io.Copy(h, io.NewSectionReader(f, offset, size))
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", fmt.Errorf(i18n.G("cannot read %d bytes at offset %d of %s"), size, offset, device)
+	}
+	return asserts.EncodeDigest(crypto.SHA3_384, h.Sum(nil))
+}
@@ -0,0 +1,284 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/boot"
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/image"
+	snaplib "github.com/snapcore/snapd/snap"
+)
+
+type verifyImageManifestSuite struct {
+	BaseSnapSuite
+
+	storeStack *assertstest.StoreStack
+	brands     *assertstest.SigningAccounts
+
+	model   *asserts.Model
+	snapRev *asserts.SnapRevision
+
+	manifestFn string
+	diskFn     string
+	snapRevN   int
+}
+
+var _ = check.Suite(&verifyImageManifestSuite{})
+
+func sha3_384Of(c *check.C, content string) string {
+	h := crypto.SHA3_384.New()
+	h.Write([]byte(content))
+	digest, err := asserts.EncodeDigest(crypto.SHA3_384, h.Sum(nil))
+	c.Assert(err, check.IsNil)
+	return digest
+}
+
+func (s *verifyImageManifestSuite) SetUpTest(c *check.C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	s.storeStack = assertstest.NewStoreStack("can0nical", nil)
+	s.AddCleanup(sysdb.InjectTrusted(s.storeStack.Trusted))
+	s.brands = assertstest.NewSigningAccounts(s.storeStack)
+	brandKey, _ := assertstest.GenerateKey(752)
+	s.brands.Register("my-brand", brandKey, nil)
+	s.model = s.brands.Model("my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "pc-kernel",
+	})
+
+	snapDigest := sha3_384Of(c, "pc snap")
+	a, err := s.storeStack.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": snapDigest,
+		"snap-size":     "7",
+		"snap-id":       "pcididididididididididididididid",
+		"snap-revision": "5",
+		"developer-id":  "canonical",
+		"timestamp":     time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	s.snapRev = a.(*asserts.SnapRevision)
+	s.snapRevN = 5
+
+	// the assets as deployed on ubuntu-seed and on the disk
+	assetFn := filepath.Join(boot.InitramfsUbuntuSeedDir, "EFI/boot/grubx64.efi")
+	c.Assert(os.MkdirAll(filepath.Dir(assetFn), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(assetFn, []byte("grub efi binary"), 0644), check.IsNil)
+	s.diskFn = filepath.Join(c.MkDir(), "vda")
+	c.Assert(ioutil.WriteFile(s.diskFn, []byte("0123boot img89"), 0644), check.IsNil)
+
+	rawOffset := quantity.Offset(4)
+	m := &image.Manifest{
+		Series:  "16",
+		BrandID: "my-brand",
+		Model:   "my-model",
+		BuildID: "20241017",
+		Snaps: []*image.ManifestSnap{{
+			Name:     "pc",
+			SnapID:   "pcididididididididididididididid",
+			Revision: snaplib.R(5),
+			SHA3_384: snapDigest,
+			Size:     7,
+		}},
+		Assertions: []*image.ManifestAssertion{{
+			Type:       "model",
+			PrimaryKey: map[string]string{"series": "16", "brand-id": "my-brand", "model": "my-model"},
+		}},
+		Assets: []*image.ManifestAsset{{
+			Snap:      "pc",
+			Path:      "grubx64.efi",
+			SHA3_384:  sha3_384Of(c, "grub efi binary"),
+			Size:      15,
+			Volume:    "pc",
+			Structure: "ubuntu-seed",
+			Role:      "system-seed",
+			Target:    "EFI/boot/grubx64.efi",
+		}, {
+			Snap:      "pc",
+			Path:      "pc-boot.img",
+			SHA3_384:  sha3_384Of(c, "boot img"),
+			Size:      8,
+			Volume:    "pc",
+			Structure: "mbr",
+			Role:      "mbr",
+			Offset:    &rawOffset,
+		}},
+	}
+	data, err := json.Marshal(m)
+	c.Assert(err, check.IsNil)
+	s.manifestFn = filepath.Join(c.MkDir(), "manifest.json")
+	c.Assert(ioutil.WriteFile(s.manifestFn, data, 0644), check.IsNil)
+}
+
+func (s *verifyImageManifestSuite) mockServer(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		// nothing is added to the system
+		c.Check(r.Method, check.Equals, "GET")
+		switch r.URL.Path {
+		case "/v2/model":
+			w.Write(asserts.Encode(s.model))
+		case "/v2/snaps":
+			fmt.Fprintf(w, `{"type": "sync", "result": [{"name": "pc", "revision": "%d"}]}`, s.snapRevN)
+		case "/v2/assertions/model":
+			c.Check(r.URL.Query().Get("brand-id"), check.Equals, "my-brand")
+			w.Header().Set("X-Ubuntu-Assertions-Count", "1")
+			w.Write(asserts.Encode(s.model))
+		case "/v2/assertions/snap-revision":
+			c.Check(r.URL.Query().Get("snap-sha3-384"), check.Equals, s.snapRev.SnapSHA3_384())
+			w.Header().Set("X-Ubuntu-Assertions-Count", "1")
+			w.Write(asserts.Encode(s.snapRev))
+		case "/v2/assertions/account":
+			c.Check(r.URL.Query().Get("account-id"), check.Equals, "my-brand")
+			w.Header().Set("X-Ubuntu-Assertions-Count", "1")
+			w.Write(asserts.Encode(s.brands.Account("my-brand")))
+		case "/v2/assertions/account-key":
+			var key *asserts.AccountKey
+			switch r.URL.Query().Get("public-key-sha3-384") {
+			case s.brands.AccountKey("my-brand").PublicKeyID():
+				key = s.brands.AccountKey("my-brand")
+			case s.storeStack.KeyID:
+				key = s.storeStack.StoreAccountKey("")
+			default:
+				w.Header().Set("X-Ubuntu-Assertions-Count", "0")
+				return
+			}
+			w.Header().Set("X-Ubuntu-Assertions-Count", "1")
+			w.Write(asserts.Encode(key))
+		case "/v2/debug":
+			c.Check(r.URL.Query().Get("aspect"), check.Equals, "gadget-disk-mapping")
+			fmt.Fprintf(w, `{"type": "sync", "result": {"pc": {"kernel-path": %q}}}`, s.diskFn)
+		default:
+			c.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	})
+}
+
+func (s *verifyImageManifestSuite) TestVerifyImageManifestHappy(c *check.C) {
+	s.mockServer(c)
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-image-manifest", s.manifestFn})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "Device matches the image manifest of my-brand/my-model build 20241017\n")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *verifyImageManifestSuite) TestVerifyImageManifestMismatch(c *check.C) {
+	s.snapRevN = 6
+	assetFn := filepath.Join(boot.InitramfsUbuntuSeedDir, "EFI/boot/grubx64.efi")
+	c.Assert(ioutil.WriteFile(assetFn, []byte("tampered"), 0644), check.IsNil)
+	c.Assert(ioutil.WriteFile(s.diskFn, []byte("0123BOOT IMG89"), 0644), check.IsNil)
+	s.mockServer(c)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-image-manifest", s.manifestFn})
+	c.Assert(err, check.ErrorMatches, "device does not match the image manifest")
+	c.Check(strings.Split(s.Stdout(), "\n"), check.DeepEquals, []string{
+		`- snap "pc" is at revision 6, not 5`,
+		`- asset "grubx64.efi" of snap "pc" has been modified`,
+		`- asset "pc-boot.img" of snap "pc" has been modified`,
+		"",
+	})
+}
+
+func (s *verifyImageManifestSuite) TestVerifyImageManifestAssetsNotDeployed(c *check.C) {
+	c.Assert(os.Remove(filepath.Join(boot.InitramfsUbuntuSeedDir, "EFI/boot/grubx64.efi")), check.IsNil)
+	c.Assert(ioutil.WriteFile(s.diskFn, []byte("0123"), 0644), check.IsNil)
+	s.mockServer(c)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-image-manifest", s.manifestFn})
+	c.Assert(err, check.ErrorMatches, "device does not match the image manifest")
+	c.Check(strings.Split(s.Stdout(), "\n"), check.DeepEquals, []string{
+		`- cannot check asset "grubx64.efi" of snap "pc": open ` + filepath.Join(boot.InitramfsUbuntuSeedDir, "EFI/boot/grubx64.efi") + `: no such file or directory`,
+		`- cannot check asset "pc-boot.img" of snap "pc": cannot read 8 bytes at offset 4 of ` + s.diskFn,
+		"",
+	})
+}
+
+func (s *verifyImageManifestSuite) signImageManifest(c *check.C, signer *assertstest.SigningDB) asserts.Assertion {
+	data, err := ioutil.ReadFile(s.manifestFn)
+	c.Assert(err, check.IsNil)
+	a, err := signer.Sign(asserts.ImageManifestType, map[string]interface{}{
+		"series":            "16",
+		"brand-id":          "my-brand",
+		"model":             "my-model",
+		"build-id":          "20241017",
+		"manifest-sha3-384": sha3_384Of(c, string(data)),
+		"timestamp":         time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	return a
+}
+
+func (s *verifyImageManifestSuite) TestVerifyImageManifestAssertion(c *check.C) {
+	s.mockServer(c)
+
+	ima := s.signImageManifest(c, s.brands.Signing("my-brand"))
+	// the account-key comes with the assertion, the account is taken
+	// from the system
+	assertFn := s.manifestFn + ".assert"
+	data := append(asserts.Encode(s.brands.AccountKey("my-brand")), '\n')
+	data = append(data, asserts.Encode(ima)...)
+	c.Assert(ioutil.WriteFile(assertFn, data, 0644), check.IsNil)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-image-manifest", "--assertion", assertFn, s.manifestFn})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Device matches the image manifest of my-brand/my-model build 20241017\n")
+}
+
+func (s *verifyImageManifestSuite) TestVerifyImageManifestAssertionPrerequisitesFromSystem(c *check.C) {
+	s.mockServer(c)
+
+	assertFn := s.manifestFn + ".assert"
+	ima := s.signImageManifest(c, s.brands.Signing("my-brand"))
+	c.Assert(ioutil.WriteFile(assertFn, asserts.Encode(ima), 0644), check.IsNil)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-image-manifest", "--assertion", assertFn, s.manifestFn})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Device matches the image manifest of my-brand/my-model build 20241017\n")
+}
+
+func (s *verifyImageManifestSuite) TestVerifyImageManifestAssertionUnknownKey(c *check.C) {
+	s.mockServer(c)
+
+	// signed by a key of the brand unknown to the system
+	otherKey, _ := assertstest.GenerateKey(752)
+	ima := s.signImageManifest(c, assertstest.NewSigningDB("my-brand", otherKey))
+	assertFn := s.manifestFn + ".assert"
+	c.Assert(ioutil.WriteFile(assertFn, asserts.Encode(ima), 0644), check.IsNil)
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"verify-image-manifest", "--assertion", assertFn, s.manifestFn})
+	c.Assert(err, check.ErrorMatches, `cannot verify image manifest assertion: account-key .* not found`)
+}
@@ -0,0 +1,218 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdWaitChange struct {
+	clientMixin
+	Kind       string        `long:"kind"`
+	Snap       string        `long:"snap"`
+	Timeout    time.Duration `long:"timeout"`
+	JSON       bool          `long:"json"`
+	Positional struct {
+		ID changeID `positional-arg-name:"<change-id>"`
+	} `positional-args:"yes"`
+}
+
+var shortWaitChangeHelp = i18n.G("Wait for a change to finish")
+var longWaitChangeHelp = i18n.G(`
+The wait-change command waits for the given change to finish, or, without a
+change ID, for the next change matching the given --kind and --snap. The next
+change is the oldest matching change in progress, or the first matching change
+started afterwards.
+
+The command fails if the change does not finish successfully, or if it does
+not finish within the given --timeout. With --json the final state of the
+change is printed in JSON format.
+`)
+
+func init() {
+	addCommand("wait-change", shortWaitChangeHelp, longWaitChangeHelp, func() flags.Commander {
+		return &cmdWaitChange{}
+	}, map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"kind": i18n.G("Wait for the next change of the given type (install, refresh, remove, auto-refresh, etc.)"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"snap": i18n.G("Wait for the next change affecting the given snap"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"timeout": i18n.G("Maximum time to wait for (e.g. 90s or 10m), no limit by default"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"json": i18n.G("Output the change in JSON format"),
+	}, []argDesc{{
+		// TRANSLATORS: This needs to begin with < and end with >
+		name: i18n.G("<change-id>"),
+		// TRANSLATORS: This should not start with a lowercase letter.
+		desc: i18n.G("Change ID"),
+	}})
+}
+
+func (x *cmdWaitChange) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if x.Positional.ID != "" && (x.Kind != "" || x.Snap != "") {
+		return errors.New(i18n.G("cannot use change ID together with --kind or --snap"))
+	}
+	if x.Positional.ID == "" && x.Kind == "" && x.Snap == "" {
+		return errors.New(i18n.G("please provide change ID or --kind and/or --snap"))
+	}
+	if x.Timeout < 0 {
+		return errors.New(i18n.G("timeout cannot be negative"))
+	}
+
+	start := time.Now()
+	id := string(x.Positional.ID)
+	if id == "" {
+		var err error
+		id, err = x.nextChangeID(expandChangeKind(x.Kind), x.Snap)
+		if err == errWaitTimeout {
+			return fmt.Errorf(i18n.G("change did not finish within %v"), x.Timeout)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	wmx := waitMixin{
+		clientMixin: x.clientMixin,
+		// the change is only observed, it is not ours to abort
+		skipAbort: true,
+	}
+	if x.Timeout > 0 {
+		wmx.timeout = x.Timeout - time.Since(start)
+		if wmx.timeout <= 0 {
+			return fmt.Errorf(i18n.G("change did not finish within %v"), x.Timeout)
+		}
+	}
+	if x.JSON {
+		// only the final state of the change is output
+		wmx.jsonProgress = ioutil.Discard
+	}
+	chg, err := wmx.wait(id)
+	if err == errWaitTimeout {
+		return fmt.Errorf(i18n.G("change did not finish within %v"), x.Timeout)
+	}
+	if chg == nil {
+		return err
+	}
+
+	if x.JSON {
+		out, err := json.Marshal(chg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "%s\n", out)
+	} else {
+		// TRANSLATORS: the first %s is the change ID, the second its status
+		fmt.Fprintf(Stdout, i18n.G("Change %s finished with status %s\n"), chg.ID, chg.Status)
+	}
+	// the error of a change that did not succeed
+	return err
+}
+
+func changeIDLess(id1, id2 string) bool {
+	n1, err1 := strconv.Atoi(id1)
+	n2, err2 := strconv.Atoi(id2)
+	if err1 != nil || err2 != nil {
+		return id1 < id2
+	}
+	return n1 < n2
+}
+
+// nextChangeID returns the ID of the next change of the given kind
+// affecting the given snap, that is the oldest such change in progress or,
+// if there is none, the first one appearing afterwards. Like when waiting
+// for a change, the server is given some time to come back if it goes
+// away.
+func (x *cmdWaitChange) nextChangeID(kind, snapName string) (string, error) {
+	opts := &client.ChangesOptions{
+		SnapName: snapName,
+		Selector: client.ChangesAll,
+	}
+	var deadline time.Time
+	if x.Timeout > 0 {
+		deadline = time.Now().Add(x.Timeout)
+	}
+
+	tMax := time.Time{}
+	var seen map[string]bool
+	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return "", errWaitTimeout
+		}
+
+		chgs, err := x.client.Changes(opts)
+		if err != nil {
+			if e, ok := err.(*client.Error); ok {
+				return "", e
+			}
+			// the server most likely went away
+			now := time.Now()
+			if tMax.IsZero() {
+				tMax = now.Add(maxGoneTime)
+			}
+			if now.After(tMax) {
+				return "", err
+			}
+			time.Sleep(pollTime)
+			continue
+		}
+		tMax = time.Time{}
+
+		sort.Slice(chgs, func(i, j int) bool {
+			return changeIDLess(chgs[i].ID, chgs[j].ID)
+		})
+		first := seen == nil
+		if first {
+			seen = make(map[string]bool, len(chgs))
+		}
+		for _, chg := range chgs {
+			if seen[chg.ID] {
+				continue
+			}
+			seen[chg.ID] = true
+			if kind != "" && chg.Kind != kind {
+				continue
+			}
+			// changes which were already ready when we started
+			// are not the next one
+			if first && chg.Ready {
+				continue
+			}
+			return chg.ID, nil
+		}
+
+		time.Sleep(pollTime)
+	}
+}
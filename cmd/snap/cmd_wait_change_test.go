@@ -0,0 +1,147 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestWaitChangeByID(c *C) {
+	defer snap.MockPollTime(time.Millisecond)()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/changes/42")
+		switch n {
+		case 1:
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "kind": "install-snap", "status": "Doing", "ready": false}}`)
+		case 2:
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "kind": "install-snap", "status": "Done", "ready": true}}`)
+		default:
+			c.Errorf("expected 2 queries, currently on %d", n)
+		}
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"wait-change", "42"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, HasLen, 0)
+	c.Check(n, Equals, 2)
+	c.Check(s.Stdout(), Equals, "Change 42 finished with status Done\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestWaitChangeNextJSONError(c *C) {
+	defer snap.MockPollTime(time.Millisecond)()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.Method, Equals, "GET")
+		switch n {
+		case 1:
+			c.Check(r.URL.Path, Equals, "/v2/changes")
+			c.Check(r.URL.Query().Get("for"), Equals, "foo")
+			c.Check(r.URL.Query().Get("select"), Equals, "all")
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+  {"id": "41", "kind": "refresh-snap", "status": "Doing", "ready": false},
+  {"id": "42", "kind": "install-snap", "status": "Doing", "ready": false}
+]}`)
+		case 2:
+			c.Check(r.URL.Path, Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "kind": "install-snap", "summary": "Install \"foo\" snap", "status": "Error", "ready": true, "err": "cannot install foo"}}`)
+		default:
+			c.Errorf("expected 2 queries, currently on %d", n)
+		}
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"wait-change", "--kind=install", "--snap=foo", "--json"})
+	c.Assert(err, ErrorMatches, "cannot install foo")
+	c.Check(n, Equals, 2)
+	c.Check(s.Stdout(), Equals, `{"id":"42","kind":"install-snap","summary":"Install \"foo\" snap","status":"Error","ready":true,"err":"cannot install foo","spawn-time":"0001-01-01T00:00:00Z","ready-time":"0001-01-01T00:00:00Z"}`+"\n")
+}
+
+func (s *SnapSuite) TestWaitChangeServerRestarts(c *C) {
+	defer snap.MockPollTime(time.Millisecond)()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch n {
+		case 1:
+			c.Check(r.URL.Path, Equals, "/v2/changes")
+			fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+		case 2, 4:
+			// snapd is restarting, no answer
+		case 3:
+			c.Check(r.URL.Path, Equals, "/v2/changes")
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+  {"id": "42", "kind": "refresh-snap", "status": "Doing", "ready": false}
+]}`)
+		case 5:
+			c.Check(r.URL.Path, Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "kind": "refresh-snap", "status": "Done", "ready": true}}`)
+		default:
+			c.Errorf("expected 5 queries, currently on %d", n)
+		}
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"wait-change", "--snap=snapd"})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 5)
+	c.Check(s.Stdout(), Equals, "Change 42 finished with status Done\n")
+}
+
+func (s *SnapSuite) TestWaitChangeTimeout(c *C) {
+	defer snap.MockPollTime(time.Millisecond)()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/changes/42")
+		fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "kind": "install-snap", "status": "Doing", "ready": false}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"wait-change", "--timeout=10ms", "42"})
+	c.Assert(err, ErrorMatches, "change did not finish within 10ms")
+}
+
+func (s *SnapSuite) TestWaitChangeErrors(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"wait-change"}, "please provide change ID or --kind and/or --snap"},
+		{[]string{"wait-change", "--kind=install", "42"}, "cannot use change ID together with --kind or --snap"},
+		{[]string{"wait-change", "--timeout=-1s", "42"}, "timeout cannot be negative"},
+	} {
+		_, err := snap.Parser(snap.Client()).ParseArgs(t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+}
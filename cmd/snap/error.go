@@ -242,6 +242,9 @@ If you understand and want to proceed repeat the command including --classic.
 				}
 			}
 		}
+	case client.ErrorKindSnapHasDependents:
+		usesSnapName = false
+		msg = err.Message + "\n\n" + i18n.G("Use --cascade to remove the snaps that need it as well.")
 	case client.ErrorKindInsufficientDiskSpace:
 		// this error carries multiple snap names
 		usesSnapName = false
@@ -259,8 +262,10 @@ If you understand and want to proceed repeat the command including --classic.
 				msg = fmt.Sprintf(i18n.G("cannot remove %s due to low disk space for automatic snapshot, use --purge to avoid creating a snapshot"), names)
 			case "install":
 				msg = fmt.Sprintf(i18n.G("cannot install %s due to low disk space"), names)
+				msg += insufficientSpaceShortfalls(values)
 			case "refresh":
 				msg = fmt.Sprintf(i18n.G("cannot refresh %s due to low disk space"), names)
+				msg += insufficientSpaceShortfalls(values)
 			default:
 				msg = err.Error()
 			}
@@ -446,3 +451,25 @@ func archsForChannels(cs []*channel.Channel) []string {
 	}
 	return archs
 }
+
+// insufficientSpaceShortfalls describes the space missing per filesystem as
+// reported with an insufficient-disk-space error, as a suffix for the error
+// message.
+func insufficientSpaceShortfalls(values map[string]interface{}) string {
+	shortfalls, _ := values["shortfalls"].([]interface{})
+	var details []string
+	for _, v := range shortfalls {
+		sf, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path, _ := sf["path"].(string)
+		missing, _ := sf["missing"].(float64)
+		// TRANSLATORS: the first %s is a size, the second is a path
+		details = append(details, fmt.Sprintf(i18n.G("at least %s more is required in %q"), strutil.SizeToStr(int64(missing)), path))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return ": " + strings.Join(details, ", ")
+}
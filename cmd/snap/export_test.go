@@ -95,6 +95,8 @@ var (
 	IsStopping = isStopping
 
 	GetSnapDirOptions = getSnapDirOptions
+
+	TaskErrorHint = taskErrorHint
 )
 
 func HiddenCmd(descr string, completeHidden bool) *cmdInfo {
@@ -458,15 +460,18 @@ func MockAutostartSessionApps(f func(string) error) func() {
 	}
 }
 
-func ParseQuotaValues(maxMemory, cpuMax, cpuSet, threadsMax, journalSizeMax, journalRateLimit string) (*client.QuotaValues, error) {
+func ParseQuotaValues(maxMemory, cpuMax, cpuSet, memoryNodes, threadsMax, journalSizeMax, journalRateLimit, journalRetention, journalStorage string) (*client.QuotaValues, error) {
 	var quotas cmdSetQuota
 
 	quotas.MemoryMax = maxMemory
 	quotas.CPUMax = cpuMax
 	quotas.CPUSet = cpuSet
+	quotas.MemoryNodes = memoryNodes
 	quotas.ThreadsMax = threadsMax
 	quotas.JournalSizeMax = journalSizeMax
 	quotas.JournalRateLimit = journalRateLimit
+	quotas.JournalRetention = journalRetention
+	quotas.JournalStorage = journalStorage
 
 	return quotas.parseQuotas()
 }
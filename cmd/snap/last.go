@@ -72,20 +72,7 @@ func (l *changeIDMixin) GetChangeID() (string, error) {
 		optional = true
 		kind = kind[:l]
 	}
-	// our internal change types use "-snap" postfix but let user skip it and use short form.
-	shortForms := []string{
-		// see api_snaps.go:snapInstructionDispTable
-		"install", "refresh", "remove", "revert", "enable", "disable", "switch",
-		// see api_interfaces.go:changeInterfaces
-		"connect", "disconnect",
-		// see api_snap_conf.go:setSnapConf
-		"configure",
-		// see api_sideload_n_try.go:trySnap
-		"try",
-	}
-	if strutil.ListContains(shortForms, kind) {
-		kind += "-snap"
-	}
+	kind = expandChangeKind(kind)
 	changes, err := queryChanges(cli, &client.ChangesOptions{Selector: client.ChangesAll})
 	if err != nil {
 		return "", err
@@ -107,6 +94,26 @@ func (l *changeIDMixin) GetChangeID() (string, error) {
 	return chg.ID, nil
 }
 
+// expandChangeKind returns the internal change kind for the given short
+// form, or the kind itself if it is not a short form.
+func expandChangeKind(kind string) string {
+	// our internal change types use "-snap" postfix but let user skip it and use short form.
+	shortForms := []string{
+		// see api_snaps.go:snapInstructionDispTable
+		"install", "refresh", "remove", "revert", "enable", "disable", "switch",
+		// see api_interfaces.go:changeInterfaces
+		"connect", "disconnect",
+		// see api_snap_conf.go:setSnapConf
+		"configure",
+		// see api_sideload_n_try.go:trySnap
+		"try",
+	}
+	if strutil.ListContains(shortForms, kind) {
+		kind += "-snap"
+	}
+	return kind
+}
+
 func findLatestChangeByKind(changes []*client.Change, kind string) (latest *client.Change) {
 	for _, chg := range changes {
 		if chg.Kind == kind && (latest == nil || latest.SpawnTime.Before(chg.SpawnTime)) {
@@ -63,6 +63,9 @@ type Notes struct {
 	InCohort         bool
 	Health           string
 	Price            string
+	// Security is set for revisions carrying security fixes, including
+	// their severity when known, e.g. "security-high"
+	Security string
 }
 
 func NotesFromChannelSnapInfo(ref *snap.ChannelSnapInfo) *Notes {
@@ -82,6 +85,12 @@ func NotesFromRemote(snp *client.Snap, resInfo *client.ResultInfo) *Notes {
 	if resInfo != nil {
 		notes.Price = getPriceString(snp.Prices, resInfo.SuggestedCurrency, snp.Status)
 	}
+	if snp.Security != nil {
+		notes.Security = "security"
+		if snp.Security.Severity != "" {
+			notes.Security = "security-" + snp.Security.Severity
+		}
+	}
 
 	return notes
 }
@@ -166,6 +175,10 @@ func (n *Notes) String() string {
 		ns = append(ns, n.Health)
 	}
 
+	if n.Security != "" {
+		ns = append(ns, n.Security)
+	}
+
 	if len(ns) == 0 {
 		return "-"
 	}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	snap "github.com/snapcore/snapd/cmd/snap"
+	snaplib "github.com/snapcore/snapd/snap"
 )
 
 type notesSuite struct{}
@@ -115,3 +116,9 @@ func (notesSuite) TestNotesFromLocal(c *check.C) {
 	c.Check(snap.NotesFromLocal(&client.Snap{CohortKey: "123"}).InCohort, check.Equals, true)
 	c.Check(snap.NotesFromLocal(&client.Snap{Health: &client.SnapHealth{Status: "blocked"}}).Health, check.Equals, "blocked")
 }
+
+func (notesSuite) TestNotesFromRemoteSecurity(c *check.C) {
+	c.Check(snap.NotesFromRemote(&client.Snap{}, nil).Security, check.Equals, "")
+	c.Check(snap.NotesFromRemote(&client.Snap{Security: &snaplib.SecurityInfo{CVEs: []string{"CVE-2023-1234"}}}, nil).String(), check.Equals, "security")
+	c.Check(snap.NotesFromRemote(&client.Snap{Security: &snaplib.SecurityInfo{Severity: "high"}}, nil).String(), check.Equals, "security-high")
+}
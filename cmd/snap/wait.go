@@ -22,6 +22,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"time"
@@ -40,6 +41,12 @@ type waitMixin struct {
 	clientMixin
 	NoWait    bool `long:"no-wait"`
 	skipAbort bool
+
+	// jsonProgress, if set, gets the progress of the change as JSON
+	// events instead of showing a progress bar, see progressMixin
+	jsonProgress io.Writer
+	// timeout, if set, is the maximum time to wait for the change
+	timeout time.Duration
 }
 
 var waitDescs = mixinDescs{
@@ -49,6 +56,8 @@ var waitDescs = mixinDescs{
 
 var noWait = errors.New("no wait for op")
 
+var errWaitTimeout = errors.New("timeout waiting for change")
+
 func (wmx waitMixin) wait(id string) (*client.Change, error) {
 	if wmx.NoWait {
 		fmt.Fprintf(Stdout, "%s\n", id)
@@ -70,9 +79,14 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 		}
 	}()
 
-	pb := progress.MakeProgressBar(Stdout)
+	var rep changeReporter
+	if wmx.jsonProgress != nil {
+		rep = newJSONReporter(wmx.jsonProgress)
+	} else {
+		rep = newBarReporter(progress.MakeProgressBar(Stdout))
+	}
 	defer func() {
-		pb.Finished()
+		rep.finished()
 		// next two not strictly needed for CLI, but without
 		// them the tests will leak goroutines.
 		signal.Stop(c)
@@ -80,10 +94,15 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 	}()
 
 	tMax := time.Time{}
+	var deadline time.Time
+	if wmx.timeout > 0 {
+		deadline = time.Now().Add(wmx.timeout)
+	}
 
-	var lastID string
-	lastLog := map[string]string{}
 	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, errWaitTimeout
+		}
 		var rebootingErr error
 		chg, err := cli.Change(id)
 		if err != nil {
@@ -103,7 +122,7 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 			if now.After(tMax) {
 				return nil, err
 			}
-			pb.Spin(i18n.G("Waiting for server to restart"))
+			rep.waiting(i18n.G("Waiting for server to restart"))
 			time.Sleep(pollTime)
 			continue
 		}
@@ -111,29 +130,11 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 			rebootingErr = maintErr
 		}
 		if !tMax.IsZero() {
-			pb.Finished()
+			rep.reachable()
 			tMax = time.Time{}
 		}
 
-		for _, t := range chg.Tasks {
-			switch {
-			case t.Status != "Doing":
-				continue
-			case t.Progress.Total == 1:
-				pb.Spin(t.Summary)
-				nowLog := lastLogStr(t.Log)
-				if lastLog[t.ID] != nowLog {
-					pb.Notify(nowLog)
-					lastLog[t.ID] = nowLog
-				}
-			case t.ID == lastID:
-				pb.Set(float64(t.Progress.Done))
-			default:
-				pb.Start(t.Summary, float64(t.Progress.Total))
-				lastID = t.ID
-			}
-			break
-		}
+		rep.update(chg)
 
 		if chg.Ready {
 			if chg.Status == "Done" {
@@ -158,6 +159,63 @@ func (wmx waitMixin) wait(id string) (*client.Change, error) {
 	}
 }
 
+// changeReporter shows the progress of a change while waiting for it.
+type changeReporter interface {
+	// waiting is called while the server cannot be reached
+	waiting(msg string)
+	// reachable is called when the server can be reached again
+	reachable()
+	// update is called with the current state of the change
+	update(chg *client.Change)
+	finished()
+}
+
+// barReporter shows the progress of the first task being done with a
+// progress bar, or a spinner for tasks without progress.
+type barReporter struct {
+	pb      progress.Meter
+	lastID  string
+	lastLog map[string]string
+}
+
+func newBarReporter(pb progress.Meter) *barReporter {
+	return &barReporter{pb: pb, lastLog: make(map[string]string)}
+}
+
+func (r *barReporter) waiting(msg string) {
+	r.pb.Spin(msg)
+}
+
+func (r *barReporter) reachable() {
+	r.pb.Finished()
+}
+
+func (r *barReporter) update(chg *client.Change) {
+	for _, t := range chg.Tasks {
+		switch {
+		case t.Status != "Doing":
+			continue
+		case t.Progress.Total == 1:
+			r.pb.Spin(t.Summary)
+			nowLog := lastLogStr(t.Log)
+			if r.lastLog[t.ID] != nowLog {
+				r.pb.Notify(nowLog)
+				r.lastLog[t.ID] = nowLog
+			}
+		case t.ID == r.lastID:
+			r.pb.Set(float64(t.Progress.Done))
+		default:
+			r.pb.Start(t.Summary, float64(t.Progress.Total))
+			r.lastID = t.ID
+		}
+		break
+	}
+}
+
+func (r *barReporter) finished() {
+	r.pb.Finished()
+}
+
 func lastLogStr(logs []string) string {
 	if len(logs) == 0 {
 		return ""
@@ -0,0 +1,178 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type progressMixin struct {
+	Progress string `long:"progress" default:"bar" choice:"bar" choice:"json"`
+}
+
+var progressDescs = mixinDescs{
+	// TRANSLATORS: This should not start with a lowercase letter.
+	"progress": i18n.G("How to report progress: a progress bar, or newline-delimited JSON events on stdout."),
+}
+
+// setupProgress sets up wmx to report progress as requested. With JSON
+// progress stdout is reserved for the events and the usual output goes to
+// stderr until the returned function is called.
+func (pmx progressMixin) setupProgress(wmx *waitMixin) (restore func(), err error) {
+	if pmx.Progress != "json" {
+		return func() {}, nil
+	}
+	if wmx.NoWait {
+		return nil, errors.New(i18n.G("cannot use --no-wait and --progress=json together"))
+	}
+	wmx.jsonProgress = Stdout
+	oldStdout := Stdout
+	Stdout = Stderr
+	return func() { Stdout = oldStdout }, nil
+}
+
+// progressEvent is a single line of JSON progress reported while waiting
+// for a change. Type is one of:
+//   - "task" when a task changes status or makes progress
+//   - "log" when a task logs a message
+//   - "change" when the change is ready
+//   - "waiting" while the server cannot be reached
+type progressEvent struct {
+	Type     string                 `json:"type"`
+	Change   string                 `json:"change,omitempty"`
+	Task     string                 `json:"task,omitempty"`
+	Kind     string                 `json:"kind,omitempty"`
+	Summary  string                 `json:"summary,omitempty"`
+	Status   string                 `json:"status,omitempty"`
+	Progress *progressEventProgress `json:"progress,omitempty"`
+	Message  string                 `json:"message,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+type progressEventProgress struct {
+	Label string `json:"label,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	// Unit is the unit of Done and Total, e.g. "bytes", empty for steps
+	Unit    string  `json:"unit,omitempty"`
+	Percent float64 `json:"percent"`
+	// ETA is the estimated number of seconds until the task is done
+	ETA float64 `json:"eta,omitempty"`
+}
+
+// jsonReporter reports the progress of a change as newline-delimited JSON
+// events, only emitting an event when something changed.
+type jsonReporter struct {
+	enc *json.Encoder
+
+	lastTask   map[string]client.Task
+	lastLog    map[string]string
+	serverGone bool
+	ready      bool
+}
+
+func newJSONReporter(w io.Writer) *jsonReporter {
+	return &jsonReporter{
+		enc:      json.NewEncoder(w),
+		lastTask: make(map[string]client.Task),
+		lastLog:  make(map[string]string),
+	}
+}
+
+func (r *jsonReporter) emit(ev *progressEvent) {
+	// nothing useful can be done on failure, the waiting goes on
+	r.enc.Encode(ev)
+}
+
+func (r *jsonReporter) waiting(msg string) {
+	if r.serverGone {
+		return
+	}
+	r.serverGone = true
+	r.emit(&progressEvent{Type: "waiting", Message: msg})
+}
+
+func (r *jsonReporter) reachable() {
+	r.serverGone = false
+}
+
+func taskProgressChanged(old, t *client.Task) bool {
+	return old.Status != t.Status || old.Progress.Label != t.Progress.Label ||
+		old.Progress.Done != t.Progress.Done || old.Progress.Total != t.Progress.Total
+}
+
+func (r *jsonReporter) update(chg *client.Change) {
+	for _, t := range chg.Tasks {
+		old, seen := r.lastTask[t.ID]
+		if (seen || t.Status != "Do") && (!seen || taskProgressChanged(&old, t)) {
+			percent := 0.0
+			if t.Progress.Total > 0 {
+				percent = math.Floor(float64(t.Progress.Done)*1000/float64(t.Progress.Total)) / 10
+			}
+			r.emit(&progressEvent{
+				Type:    "task",
+				Change:  chg.ID,
+				Task:    t.ID,
+				Kind:    t.Kind,
+				Summary: t.Summary,
+				Status:  t.Status,
+				Progress: &progressEventProgress{
+					Label:   t.Progress.Label,
+					Done:    t.Progress.Done,
+					Total:   t.Progress.Total,
+					Unit:    t.Progress.Unit,
+					Percent: percent,
+					ETA:     t.Progress.ETA,
+				},
+			})
+		}
+		r.lastTask[t.ID] = *t
+
+		if nowLog := lastLogStr(t.Log); nowLog != r.lastLog[t.ID] {
+			r.lastLog[t.ID] = nowLog
+			r.emit(&progressEvent{
+				Type:    "log",
+				Change:  chg.ID,
+				Task:    t.ID,
+				Message: nowLog,
+			})
+		}
+	}
+
+	if chg.Ready && !r.ready {
+		r.ready = true
+		r.emit(&progressEvent{
+			Type:    "change",
+			Change:  chg.ID,
+			Kind:    chg.Kind,
+			Summary: chg.Summary,
+			Status:  chg.Status,
+			Error:   chg.Err,
+		})
+	}
+}
+
+func (r *jsonReporter) finished() {}
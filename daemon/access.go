@@ -20,7 +20,10 @@
 package daemon
 
 import (
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/snapcore/snapd/client"
@@ -244,3 +247,52 @@ func (ac themesAuthenticatedAccess) CheckAccess(d *Daemon, r *http.Request, ucre
 
 	return Unauthorized("access denied")
 }
+
+var peerExecutable = func(pid int32) (string, error) {
+	return os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+}
+
+// isSnapdSnapCommand returns whether exe is the snap command shipped with
+// snapd, either from the host or from the snapd or core snaps.
+func isSnapdSnapCommand(exe string) bool {
+	if exe == filepath.Join(dirs.GlobalRootDir, "/usr/bin/snap") {
+		return true
+	}
+	for _, snapName := range []string{"snapd", "core"} {
+		pattern := filepath.Join(dirs.SnapMountDir, snapName, "*", "/usr/bin/snap")
+		if matched, _ := filepath.Match(pattern, exe); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// snapdInternalAccess allows requests from the root uid, or from
+// processes of snapd running on behalf of users, like the session
+// launcher of snap userd, provided they were not received on
+// snapd-snap.socket and do not come from a snap.
+type snapdInternalAccess struct{}
+
+func (ac snapdInternalAccess) CheckAccess(d *Daemon, r *http.Request, ucred *ucrednet, user *auth.UserState) *apiError {
+	if rspe := requireSnapdSocket(ucred); rspe != nil {
+		return rspe
+	}
+
+	if ucred.Uid == 0 {
+		return nil
+	}
+
+	// snaps can run the snap command too
+	if snapName, err := cgroupSnapNameFromPid(int(ucred.Pid)); err == nil && snapName != "" {
+		return Forbidden("access denied")
+	}
+	exe, err := peerExecutable(ucred.Pid)
+	if err != nil {
+		logger.Debugf("cannot determine executable of pid %d: %v", ucred.Pid, err)
+		return Forbidden("access denied")
+	}
+	if isSnapdSnapCommand(exe) {
+		return nil
+	}
+	return Forbidden("access denied")
+}
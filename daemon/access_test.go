@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 
 	. "gopkg.in/check.v1"
 
@@ -214,6 +215,53 @@ func (s *accessSuite) TestRootAccess(c *C) {
 	c.Check(ac.CheckAccess(nil, nil, ucred, nil), IsNil)
 }
 
+func (s *accessSuite) TestSnapdInternalAccess(c *C) {
+	var ac daemon.AccessChecker = daemon.SnapdInternalAccess{}
+
+	exe := filepath.Join(dirs.GlobalRootDir, "/usr/bin/snap")
+	restore := daemon.MockPeerExecutable(func(pid int32) (string, error) {
+		c.Check(pid, Equals, int32(100))
+		return exe, nil
+	})
+	defer restore()
+	snapName := ""
+	restore = daemon.MockCgroupSnapNameFromPid(func(pid int) (string, error) {
+		if snapName == "" {
+			return "", fmt.Errorf("not a snap")
+		}
+		return snapName, nil
+	})
+	defer restore()
+
+	// snapdInternalAccess denies access without ucred or from
+	// snapd-snap.socket
+	c.Check(ac.CheckAccess(nil, nil, nil, nil), DeepEquals, errForbidden)
+	ucred := &daemon.Ucrednet{Uid: 0, Pid: 100, Socket: dirs.SnapSocket}
+	c.Check(ac.CheckAccess(nil, nil, ucred, nil), DeepEquals, errForbidden)
+
+	// root is granted access
+	ucred = &daemon.Ucrednet{Uid: 0, Pid: 100, Socket: dirs.SnapdSocket}
+	c.Check(ac.CheckAccess(nil, nil, ucred, nil), IsNil)
+
+	// non-root users are granted access when running the snap command
+	ucred = &daemon.Ucrednet{Uid: 42, Pid: 100, Socket: dirs.SnapdSocket}
+	c.Check(ac.CheckAccess(nil, nil, ucred, nil), IsNil)
+	exe = dirs.SnapMountDir + "/snapd/123/usr/bin/snap"
+	c.Check(ac.CheckAccess(nil, nil, ucred, nil), IsNil)
+
+	// but not from a snap
+	snapName = "some-snap"
+	c.Check(ac.CheckAccess(nil, nil, ucred, nil), DeepEquals, errForbidden)
+	snapName = ""
+
+	// nor running anything else, even with macaroon auth
+	exe = "/usr/bin/curl"
+	c.Check(ac.CheckAccess(nil, nil, ucred, nil), DeepEquals, errForbidden)
+	c.Check(ac.CheckAccess(nil, nil, ucred, &auth.UserState{}), DeepEquals, errForbidden)
+	exe = dirs.SnapMountDir + "/other-snap/1/usr/bin/snap"
+	c.Check(ac.CheckAccess(nil, nil, ucred, nil), DeepEquals, errForbidden)
+}
+
 func (s *accessSuite) TestSnapAccess(c *C) {
 	var ac daemon.AccessChecker = daemon.SnapAccess{}
 
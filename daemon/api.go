@@ -47,6 +47,11 @@ var api = []*Command{
 	snapFileCmd,
 	snapDownloadCmd,
 	snapConfCmd,
+	snapEpochMigrationCmd,
+	snapDiffCmd,
+	tryWatchesCmd,
+	disksCmd,
+	diskHealthCmd,
 	interfacesCmd,
 	assertsCmd,
 	assertsFindManyCmd,
@@ -59,14 +64,18 @@ var api = []*Command{
 	usersCmd,
 	sectionsCmd,
 	aliasesCmd,
+	snapAliasesCmd,
 	appsCmd,
 	logsCmd,
 	warningsCmd,
+	noticesCmd,
 	debugPprofCmd,
 	debugCmd,
 	snapshotCmd,
 	snapshotExportCmd,
 	connectionsCmd,
+	connectionsExplainCmd,
+	connectionsBackupCmd,
 	modelCmd,
 	cohortsCmd,
 	serialModelCmd,
@@ -78,6 +87,7 @@ var api = []*Command{
 	validationSetsCmd,
 	routineConsoleConfStartCmd,
 	systemRecoveryKeysCmd,
+	systemSecurebootCmd,
 	quotaGroupsCmd,
 	quotaGroupInfoCmd,
 }
@@ -139,8 +149,12 @@ var (
 	snapstateTryPath                        = snapstate.TryPath
 	snapstateUpdate                         = snapstate.Update
 	snapstateUpdateMany                     = snapstate.UpdateMany
+	snapstateExplainEpochMigration          = snapstate.ExplainEpochMigration
+	snapstateUpdateEpochMigration           = snapstate.UpdateEpochMigration
 	snapstateInstallMany                    = snapstate.InstallMany
 	snapstateRemoveMany                     = snapstate.RemoveMany
+	snapstateRemovalPlan                    = snapstate.RemovalPlan
+	snapstateDiffRevisions                  = snapstate.DiffRevisions
 	snapstateResolveValSetsEnforcementError = snapstate.ResolveValidationSetsEnforcementError
 	snapstateRevert                         = snapstate.Revert
 	snapstateRevertToRevision               = snapstate.RevertToRevision
@@ -50,5 +50,5 @@ func getAccessoriesChange(c *Command, r *http.Request, user *auth.UserState) Res
 		return NotFound("cannot find change with id %q", chID)
 	}
 
-	return SyncResponse(change2changeInfo(chg))
+	return SyncResponse(change2changeInfo(chg, c.d.overlord.TaskRunner()))
 }
@@ -24,12 +24,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var (
@@ -40,6 +43,12 @@ var (
 		ReadAccess:  openAccess{},
 		WriteAccess: authenticatedAccess{},
 	}
+
+	snapAliasesCmd = &Command{
+		Path:       "/v2/snaps/{name}/aliases",
+		GET:        getSnapAliases,
+		ReadAccess: openAccess{},
+	}
 )
 
 // aliasAction is an action performed on aliases
@@ -48,6 +57,9 @@ type aliasAction struct {
 	Snap   string `json:"snap"`
 	App    string `json:"app"`
 	Alias  string `json:"alias"`
+	// AliasSet is the alias set of the snap for the
+	// enable-alias-set and disable-alias-set actions
+	AliasSet string `json:"alias-set"`
 	// old now unsupported api
 	Aliases []string `json:"aliases"`
 }
@@ -96,6 +108,10 @@ func changeAliases(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 	case "prefer":
 		taskset, err = snapstate.Prefer(st, a.Snap)
+	case "enable-alias-set":
+		taskset, err = snapstate.EnableAliasSet(st, a.Snap, a.AliasSet)
+	case "disable-alias-set":
+		taskset, err = snapstate.DisableAliasSet(st, a.Snap, a.AliasSet)
 	}
 	if err != nil {
 		return errToResponse(err, nil, BadRequest, "%v")
@@ -113,6 +129,10 @@ func changeAliases(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 	case "prefer":
 		summary = fmt.Sprintf(i18n.G("Prefer aliases of snap %q"), a.Snap)
+	case "enable-alias-set":
+		summary = fmt.Sprintf(i18n.G("Enable alias set %q of snap %q"), a.AliasSet, a.Snap)
+	case "disable-alias-set":
+		summary = fmt.Sprintf(i18n.G("Disable alias set %q of snap %q"), a.AliasSet, a.Snap)
 	}
 
 	change := newChange(st, a.Action, summary, []*state.TaskSet{taskset}, []string{a.Snap})
@@ -122,10 +142,36 @@ func changeAliases(c *Command, r *http.Request, user *auth.UserState) Response {
 }
 
 type aliasStatus struct {
-	Command string `json:"command"`
-	Status  string `json:"status"`
-	Manual  string `json:"manual,omitempty"`
-	Auto    string `json:"auto,omitempty"`
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	Manual   string `json:"manual,omitempty"`
+	Auto     string `json:"auto,omitempty"`
+	AliasSet string `json:"alias-set,omitempty"`
+}
+
+func newAliasStatus(snapName string, info *snap.Info, autoDisabled bool, alias string, aliasTarget *snapstate.AliasTarget) aliasStatus {
+	aliasStatus := aliasStatus{
+		Manual: aliasTarget.Manual,
+		Auto:   aliasTarget.Auto,
+	}
+	if info != nil {
+		aliasStatus.AliasSet = info.AliasSetOf(alias)
+	}
+	status := "auto"
+	tgt := aliasTarget.Effective(autoDisabled)
+	if tgt == "" {
+		status = "disabled"
+		tgt = aliasTarget.Auto
+		if tgt == "" {
+			// manual alias disabled with its alias set
+			tgt = aliasTarget.Manual
+		}
+	} else if aliasTarget.Manual != "" {
+		status = "manual"
+	}
+	aliasStatus.Status = status
+	aliasStatus.Command = snap.JoinSnapApp(snapName, tgt)
+	return aliasStatus
 }
 
 // getAliases produces a response with a map snap -> alias -> aliasStatus
@@ -148,26 +194,126 @@ func getAliases(c *Command, r *http.Request, user *auth.UserState) Response {
 		if len(snapst.Aliases) != 0 {
 			snapAliases := make(map[string]aliasStatus)
 			res[snapName] = snapAliases
-			autoDisabled := snapst.AutoAliasesDisabled
+			// the alias sets are informational, do not fail
+			// if the snap cannot be read
+			info, _ := snapst.CurrentInfo()
 			for alias, aliasTarget := range snapst.Aliases {
-				aliasStatus := aliasStatus{
-					Manual: aliasTarget.Manual,
-					Auto:   aliasTarget.Auto,
-				}
-				status := "auto"
-				tgt := aliasTarget.Effective(autoDisabled)
-				if tgt == "" {
-					status = "disabled"
-					tgt = aliasTarget.Auto
-				} else if aliasTarget.Manual != "" {
-					status = "manual"
-				}
-				aliasStatus.Status = status
-				aliasStatus.Command = snap.JoinSnapApp(snapName, tgt)
-				snapAliases[alias] = aliasStatus
+				snapAliases[alias] = newAliasStatus(snapName, info, snapst.AutoAliasesDisabled, alias, aliasTarget)
 			}
 		}
 	}
 
 	return SyncResponse(res)
 }
+
+// aliasSetStatus describes an alias set declared by a snap.
+type aliasSetStatus struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+	Enabled bool     `json:"enabled"`
+}
+
+// aliasConflict describes an alias of a snap that is held by another
+// snap, either as one of its enabled aliases or as its command
+// namespace.
+type aliasConflict struct {
+	Alias string `json:"alias"`
+	Snap  string `json:"snap"`
+	// Kind is either "alias" or "command-namespace".
+	Kind string `json:"kind"`
+}
+
+// snapAliases describes the commands exported by a snap.
+type snapAliases struct {
+	Snap string `json:"snap"`
+	// Commands are the effective command names of the snap, from its
+	// applications and its enabled aliases.
+	Commands  []string               `json:"commands"`
+	Aliases   map[string]aliasStatus `json:"aliases,omitempty"`
+	AliasSets []aliasSetStatus       `json:"alias-sets,omitempty"`
+	Conflicts []aliasConflict        `json:"conflicts,omitempty"`
+}
+
+// getSnapAliases produces a response listing the commands exported by a
+// snap, its aliases and alias sets, and the aliases that conflict with
+// other snaps.
+func getSnapAliases(c *Command, r *http.Request, user *auth.UserState) Response {
+	name := muxVars(r)["name"]
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	allStates, err := snapstate.All(st)
+	if err != nil {
+		return InternalError("cannot list local snaps: %v", err)
+	}
+	snapst, ok := allStates[name]
+	if !ok {
+		return SnapNotFound(name, &snap.NotInstalledError{Snap: name})
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return InternalError("cannot retrieve info for snap %q: %v", name, err)
+	}
+
+	res := &snapAliases{
+		Snap:     name,
+		Commands: []string{},
+	}
+	for _, app := range info.Apps {
+		if app.IsService() {
+			continue
+		}
+		res.Commands = append(res.Commands, snap.JoinSnapApp(name, app.Name))
+	}
+
+	autoDisabled := snapst.AutoAliasesDisabled
+	if len(snapst.Aliases) != 0 {
+		res.Aliases = make(map[string]aliasStatus, len(snapst.Aliases))
+	}
+	for alias, aliasTarget := range snapst.Aliases {
+		res.Aliases[alias] = newAliasStatus(name, info, autoDisabled, alias, aliasTarget)
+		if aliasTarget.Effective(autoDisabled) != "" {
+			res.Commands = append(res.Commands, alias)
+			continue
+		}
+		if conflict := findAliasConflict(allStates, name, alias); conflict != nil {
+			res.Conflicts = append(res.Conflicts, *conflict)
+		}
+	}
+	sort.Strings(res.Commands)
+	sort.Slice(res.Conflicts, func(i, j int) bool { return res.Conflicts[i].Alias < res.Conflicts[j].Alias })
+
+	for setName, aliases := range info.AliasSets {
+		res.AliasSets = append(res.AliasSets, aliasSetStatus{
+			Name:    setName,
+			Aliases: aliases,
+			Enabled: !strutil.ListContains(snapst.DisabledAliasSets, setName),
+		})
+	}
+	sort.Slice(res.AliasSets, func(i, j int) bool { return res.AliasSets[i].Name < res.AliasSets[j].Name })
+
+	return SyncResponse(res)
+}
+
+// findAliasConflict returns the conflict, if any, of the given alias of a
+// snap with the other installed snaps.
+func findAliasConflict(allStates map[string]*snapstate.SnapState, snapName, alias string) *aliasConflict {
+	namespace := alias
+	if i := strings.IndexRune(alias, '.'); i != -1 {
+		namespace = alias[:i]
+	}
+	if _, ok := allStates[namespace]; ok && namespace != snapName {
+		return &aliasConflict{Alias: alias, Snap: namespace, Kind: "command-namespace"}
+	}
+	for otherSnap, otherSnapst := range allStates {
+		if otherSnap == snapName {
+			continue
+		}
+		if otherSnapst.Aliases[alias].Effective(otherSnapst.AutoAliasesDisabled) != "" {
+			return &aliasConflict{Alias: alias, Snap: otherSnap, Kind: "alias"}
+		}
+	}
+	return nil
+}
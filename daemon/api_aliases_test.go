@@ -514,6 +514,204 @@ func (s *aliasesSuite) TestPreferSuccess(c *check.C) {
 	c.Check(snapst.AutoAliasesDisabled, check.Equals, false)
 }
 
+const aliasSetsYaml = `
+name: alias-snap
+version: 1
+apps:
+ app:
+ app2:
+ svc:
+  daemon: simple
+alias-sets:
+ tools: [alias1, alias2]
+ extra: [alias3]
+`
+
+func (s *aliasesSuite) TestDisableAliasSetSuccess(c *check.C) {
+	err := os.MkdirAll(dirs.SnapBinariesDir, 0755)
+	c.Assert(err, check.IsNil)
+	d := s.daemon(c)
+
+	s.mockSnap(c, aliasSetsYaml)
+
+	st := d.Overlord().State()
+	st.Lock()
+	var snapst snapstate.SnapState
+	err = snapstate.Get(st, "alias-snap", &snapst)
+	c.Assert(err, check.IsNil)
+	snapst.Aliases = map[string]*snapstate.AliasTarget{
+		"alias1": {Auto: "app"},
+		"alias3": {Manual: "app2"},
+	}
+	snapstate.Set(st, "alias-snap", &snapst)
+	st.Unlock()
+
+	d.Overlord().Loop()
+	defer d.Overlord().Stop()
+
+	action := &daemon.AliasAction{
+		Action:   "disable-alias-set",
+		Snap:     "alias-snap",
+		AliasSet: "tools",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/v2/aliases", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	s.req(c, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, 202)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	id := body["change"].(string)
+
+	st.Lock()
+	chg := st.Change(id)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, `Disable alias set "tools" of snap "alias-snap"`)
+	st.Unlock()
+
+	<-chg.Ready()
+
+	st.Lock()
+	defer st.Unlock()
+	err = chg.Err()
+	c.Assert(err, check.IsNil)
+
+	err = snapstate.Get(st, "alias-snap", &snapst)
+	c.Assert(err, check.IsNil)
+	c.Check(snapst.DisabledAliasSets, check.DeepEquals, []string{"tools"})
+	c.Check(snapst.Aliases["alias1"].Effective(snapst.AutoAliasesDisabled), check.Equals, "")
+	c.Check(snapst.Aliases["alias3"].Effective(snapst.AutoAliasesDisabled), check.Equals, "app2")
+}
+
+func (s *aliasesSuite) TestEnableAliasSetError(c *check.C) {
+	s.daemon(c)
+
+	s.mockSnap(c, aliasSetsYaml)
+
+	action := &daemon.AliasAction{
+		Action:   "enable-alias-set",
+		Snap:     "alias-snap",
+		AliasSet: "other",
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/aliases", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `snap "alias-snap" has no alias set "other"`)
+}
+
+func (s *aliasesSuite) TestSnapAliases(c *check.C) {
+	d := s.daemon(c)
+
+	s.mockSnap(c, aliasSetsYaml)
+
+	st := d.Overlord().State()
+	st.Lock()
+	var snapst snapstate.SnapState
+	err := snapstate.Get(st, "alias-snap", &snapst)
+	c.Assert(err, check.IsNil)
+	snapst.DisabledAliasSets = []string{"extra"}
+	snapst.Aliases = map[string]*snapstate.AliasTarget{
+		"alias1": {Auto: "app"},
+		"alias2": {Manual: "app2", Auto: "app"},
+		"alias3": {Manual: "app2", SetDisabled: true},
+	}
+	snapstate.Set(st, "alias-snap", &snapst)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/alias-snap/aliases", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, &daemon.SnapAliases{
+		Snap:     "alias-snap",
+		Commands: []string{"alias-snap.app", "alias-snap.app2", "alias1", "alias2"},
+		Aliases: map[string]daemon.AliasStatus{
+			"alias1": {
+				Command:  "alias-snap.app",
+				Status:   "auto",
+				Auto:     "app",
+				AliasSet: "tools",
+			},
+			"alias2": {
+				Command:  "alias-snap.app2",
+				Status:   "manual",
+				Manual:   "app2",
+				Auto:     "app",
+				AliasSet: "tools",
+			},
+			"alias3": {
+				Command:  "alias-snap.app2",
+				Status:   "disabled",
+				Manual:   "app2",
+				AliasSet: "extra",
+			},
+		},
+		AliasSets: []daemon.AliasSetStatus{
+			{Name: "extra", Aliases: []string{"alias3"}, Enabled: false},
+			{Name: "tools", Aliases: []string{"alias1", "alias2"}, Enabled: true},
+		},
+	})
+}
+
+func (s *aliasesSuite) TestSnapAliasesConflicts(c *check.C) {
+	d := s.daemon(c)
+
+	s.mockSnap(c, aliasYaml)
+	s.mockSnap(c, "name: other-snap\nversion: 1\napps:\n cmd:\n")
+
+	st := d.Overlord().State()
+	st.Lock()
+	var snapst snapstate.SnapState
+	err := snapstate.Get(st, "alias-snap", &snapst)
+	c.Assert(err, check.IsNil)
+	snapst.AutoAliasesDisabled = true
+	snapst.Aliases = map[string]*snapstate.AliasTarget{
+		"alias1":         {Auto: "app"},
+		"alias2":         {Auto: "app2"},
+		"other-snap.foo": {Auto: "app"},
+	}
+	snapstate.Set(st, "alias-snap", &snapst)
+	err = snapstate.Get(st, "other-snap", &snapst)
+	c.Assert(err, check.IsNil)
+	snapst.Aliases = map[string]*snapstate.AliasTarget{
+		"alias1": {Manual: "cmd"},
+	}
+	snapstate.Set(st, "other-snap", &snapst)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/alias-snap/aliases", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	res := rsp.Result.(*daemon.SnapAliases)
+	c.Check(res.Commands, check.DeepEquals, []string{"alias-snap.app", "alias-snap.app2"})
+	c.Check(res.AliasSets, check.HasLen, 0)
+	c.Check(res.Conflicts, check.DeepEquals, []daemon.AliasConflict{
+		{Alias: "alias1", Snap: "other-snap", Kind: "alias"},
+		{Alias: "other-snap.foo", Snap: "other-snap", Kind: "command-namespace"},
+	})
+}
+
+func (s *aliasesSuite) TestSnapAliasesNotInstalled(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/snaps/no-snap/aliases", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+}
+
 func (s *aliasesSuite) TestAliases(c *check.C) {
 	d := s.daemon(c)
 
@@ -225,6 +225,10 @@ NeedDaemonReload=no
 			needle.DaemonScope = snap.UserDaemon
 			needle.Active = false
 		}
+		if name == "snap-a.svc2" {
+			// svc2 has a reload command
+			needle.Reloadable = true
+		}
 		c.Check(apps, testutil.DeepContains, needle)
 	}
 
@@ -315,6 +319,10 @@ NeedDaemonReload=no
 			needle.DaemonScope = snap.UserDaemon
 			needle.Active = false
 		}
+		if name == "snap-a.svc2" {
+			// svc2 has a reload command
+			needle.Reloadable = true
+		}
 		c.Check(svcs, testutil.DeepContains, needle)
 	}
 
@@ -27,8 +27,12 @@ import (
 	"strconv"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var (
@@ -101,21 +105,41 @@ func getAssertTypeNames(c *Command, r *http.Request, user *auth.UserState) Respo
 
 func doAssert(c *Command, r *http.Request, user *auth.UserState) Response {
 	batch := asserts.NewBatch(nil)
-	_, err := batch.AddStream(r.Body)
+	refs, err := batch.AddStream(r.Body)
 	if err != nil {
 		return BadRequest("cannot decode request body into assertions: %v", err)
 	}
 
-	state := c.d.overlord.State()
-	state.Lock()
-	defer state.Unlock()
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
 
-	if err := assertstate.AddBatch(state, batch, &asserts.CommitOptions{
+	if err := assertstate.AddBatch(st, batch, &asserts.CommitOptions{
 		Precheck: true,
 	}); err != nil {
 		return BadRequest("assert failed: %v", err)
 	}
 
+	// the security profiles of the snaps must be set up again for
+	// seccomp-deny assertions to take effect
+	var snapIDs []string
+	for _, ref := range refs {
+		if ref.Type == asserts.SeccompDenyType {
+			snapIDs = append(snapIDs, ref.PrimaryKey[1])
+		}
+	}
+	if len(snapIDs) > 0 {
+		names, ts, err := ifacestate.SetupProfiles(st, snapIDs)
+		if err != nil {
+			return errToResponse(err, nil, InternalError, "cannot set up security profiles: %v")
+		}
+		if len(names) > 0 {
+			msg := fmt.Sprintf(i18n.G("Setup security profiles of snaps %s"), strutil.Quoted(names))
+			newChange(st, "setup-profiles", msg, []*state.TaskSet{ts}, names)
+			ensureStateSoon(st)
+		}
+	}
+
 	return SyncResponse(nil)
 }
 
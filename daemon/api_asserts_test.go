@@ -27,6 +27,7 @@ import (
 	"net/http/httptest"
 	"sort"
 	"strconv"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -36,6 +37,9 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -120,6 +124,59 @@ func (s *assertsSuite) TestAssertStreamOK(c *check.C) {
 	c.Check(err, check.IsNil)
 }
 
+func (s *assertsSuite) TestAssertSeccompDenySetsUpProfiles(c *check.C) {
+	s.addAsserts(s.Brands.AccountsAndKeys("my-brand")...)
+	s.mkInstalledInState(c, s.d, "foo", "", "v1", snap.R(10), true, "")
+	s.mkInstalledInState(c, s.d, "bar", "", "v1", snap.R(10), true, "")
+
+	st := s.d.Overlord().State()
+	fooID := "foosnapididididididididididididi"
+	st.Lock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(st, "foo", &snapst), check.IsNil)
+	snapst.Sequence[0].SnapID = fooID
+	snapstate.Set(st, "foo", &snapst)
+	st.Unlock()
+
+	seccompDeny, err := s.Brands.Signing("my-brand").Sign(asserts.SeccompDenyType, map[string]interface{}{
+		"brand-id":  "my-brand",
+		"snap-id":   fooID,
+		"syscalls":  []interface{}{"io_uring_setup"},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	soon := 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		soon++
+	})
+	defer restore()
+
+	buf := bytes.NewBuffer(asserts.Encode(seccompDeny))
+	req, err := http.NewRequest("POST", "/v2/assertions", buf)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(soon, check.Equals, 1)
+
+	st.Lock()
+	defer st.Unlock()
+	// the profiles of the affected snap are set up again
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "setup-profiles")
+	c.Check(chg.Summary(), check.Equals, `Setup security profiles of snaps "foo"`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "setup-profiles")
+	snapsup, err := snapstate.TaskSnapSetup(tasks[0])
+	c.Assert(err, check.IsNil)
+	c.Check(snapsup.InstanceName(), check.Equals, "foo")
+	c.Check(snapsup.SideInfo.SnapID, check.Equals, fooID)
+	c.Check(snapsup.Revision(), check.Equals, snap.R(10))
+}
+
 func (s *assertsSuite) TestAssertInvalid(c *check.C) {
 	// Setup
 	buf := bytes.NewBufferString("blargh")
@@ -20,9 +20,12 @@
 package daemon
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"sort"
+	"strings"
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -37,6 +40,24 @@ var connectionsCmd = &Command{
 	ReadAccess: openAccess{},
 }
 
+var connectionsExplainCmd = &Command{
+	Path:       "/v2/connections/explain",
+	GET:        getConnectionsExplain,
+	ReadAccess: openAccess{},
+}
+
+var connectionsBackupCmd = &Command{
+	Path:        "/v2/connections/backup",
+	GET:         getConnectionsBackup,
+	POST:        restoreConnectionsBackup,
+	ReadAccess:  openAccess{},
+	WriteAccess: authenticatedAccess{Polkit: polkitActionManageInterfaces},
+}
+
+// connectionsBackupFormat is the version of the format of the documents
+// holding the manual connections and disconnections.
+const connectionsBackupFormat = 1
+
 type collectFilter struct {
 	snapName  string
 	ifaceName string
@@ -167,6 +188,9 @@ func collectConnections(ifaceMgr *ifacestate.InterfaceManager, filter collectFil
 			PlugAttrs: mergeAttrs(cstate.StaticPlugAttrs, cstate.DynamicPlugAttrs),
 			SlotAttrs: mergeAttrs(cstate.StaticSlotAttrs, cstate.DynamicSlotAttrs),
 		}
+		if iface := repo.Interface(cstate.Interface); iface != nil {
+			cj.DeprecatedBy = interfaces.StaticInfoOf(iface).DeprecatedBy
+		}
 		if cstate.Undesired {
 			// explicitly disconnected are always manual
 			cj.Manual = true
@@ -229,6 +253,31 @@ func collectConnections(ifaceMgr *ifacestate.InterfaceManager, filter collectFil
 		}
 		connsjson.Slots = append(connsjson.Slots, sj)
 	}
+
+	if !filter.connected {
+		pending, err := ifaceMgr.PendingAutoConnections()
+		if err != nil {
+			return nil, err
+		}
+		for plugID, p := range pending {
+			parts := strings.SplitN(plugID, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			plugRef := interfaces.PlugRef{Snap: parts[0], Name: parts[1]}
+			if !filter.ifaceMatches(p.Interface) || !filter.plugOrConnectedSlotMatches(&plugRef, nil) {
+				continue
+			}
+			connsjson.Pending = append(connsjson.Pending, pendingConnectionJSON{
+				Plug:      plugRef,
+				Interface: p.Interface,
+				Reason:    p.Reason,
+			})
+		}
+		sort.Slice(connsjson.Pending, func(i, j int) bool {
+			return connsjson.Pending[i].Plug.SortsBefore(connsjson.Pending[j].Plug)
+		})
+	}
 	return &connsjson, nil
 }
 
@@ -286,3 +335,135 @@ func getConnections(c *Command, r *http.Request, user *auth.UserState) Response
 
 	return SyncResponse(connsjson)
 }
+
+func getConnectionsExplain(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+	snapName := ifacestate.RemapSnapFromRequest(query.Get("snap"))
+	name := query.Get("name")
+	if snapName == "" {
+		return BadRequest("snap name is required")
+	}
+	if err := checkSnapInstalled(c.d.overlord.State(), snapName); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return SnapNotFound(snapName, err)
+		}
+		return InternalError("cannot access snap state: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	expls, err := c.d.overlord.InterfaceManager().ExplainConnections(snapName, name)
+	if err != nil {
+		return InternalError("cannot explain connections: %v", err)
+	}
+	explsJSON := make([]connectionExplanationJSON, 0, len(expls))
+	for _, expl := range expls {
+		explsJSON = append(explsJSON, connectionExplanationJSON{
+			Plug:           expl.Plug,
+			Slot:           expl.Slot,
+			Interface:      expl.Interface,
+			Connected:      expl.Connected,
+			Unasserted:     expl.Unasserted,
+			Connection:     expl.Connection,
+			AutoConnection: expl.AutoConnection,
+		})
+	}
+	sort.Slice(explsJSON, func(i, j int) bool {
+		if explsJSON[i].Plug != explsJSON[j].Plug {
+			return explsJSON[i].Plug.SortsBefore(explsJSON[j].Plug)
+		}
+		return explsJSON[i].Slot.SortsBefore(explsJSON[j].Slot)
+	})
+	return SyncResponse(explsJSON)
+}
+
+func getConnectionsBackup(c *Command, r *http.Request, user *auth.UserState) Response {
+	snapName := ifacestate.RemapSnapFromRequest(r.URL.Query().Get("snap"))
+	if snapName != "" {
+		if err := checkSnapInstalled(c.d.overlord.State(), snapName); err != nil {
+			if errors.Is(err, state.ErrNoState) {
+				return SnapNotFound(snapName, err)
+			}
+			return InternalError("cannot access snap state: %v", err)
+		}
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	intents, err := ifacestate.ExportConnections(st, snapName)
+	if err != nil {
+		return InternalError("cannot export connections: %v", err)
+	}
+	backup := connectionsBackupJSON{
+		Format:      connectionsBackupFormat,
+		Connections: make([]connectionIntentJSON, 0, len(intents)),
+	}
+	for _, intent := range intents {
+		backup.Connections = append(backup.Connections, connectionIntentJSON{
+			Plug:      intent.Plug,
+			Slot:      intent.Slot,
+			Interface: intent.Interface,
+			Undesired: intent.Undesired,
+		})
+	}
+	return SyncResponse(backup)
+}
+
+func restoreConnectionsBackup(c *Command, r *http.Request, user *auth.UserState) Response {
+	var backup connectionsBackupJSON
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&backup); err != nil {
+		return BadRequest("cannot decode request body into connections backup: %v", err)
+	}
+	if backup.Format != connectionsBackupFormat {
+		return BadRequest("unsupported connections backup format: %d", backup.Format)
+	}
+	intents := make([]*ifacestate.ConnectionIntent, 0, len(backup.Connections))
+	for _, cj := range backup.Connections {
+		if cj.Plug.Snap == "" || cj.Plug.Name == "" || cj.Slot.Snap == "" || cj.Slot.Name == "" || cj.Interface == "" {
+			return BadRequest("incomplete connection in connections backup: %s:%s %s:%s", cj.Plug.Snap, cj.Plug.Name, cj.Slot.Snap, cj.Slot.Name)
+		}
+		intents = append(intents, &ifacestate.ConnectionIntent{
+			Plug:      cj.Plug,
+			Slot:      cj.Slot,
+			Interface: cj.Interface,
+			Undesired: cj.Undesired,
+		})
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	repo := c.d.overlord.InterfaceManager().Repository()
+	tasksets, skipped, err := ifacestate.RestoreConnections(st, repo, intents)
+	if err != nil {
+		return errToResponse(err, nil, BadRequest, "cannot restore connections: %v")
+	}
+
+	skippedJSON := make([]connectionIntentJSON, 0, len(skipped))
+	for _, sk := range skipped {
+		skippedJSON = append(skippedJSON, connectionIntentJSON{
+			Plug:      sk.Plug,
+			Slot:      sk.Slot,
+			Interface: sk.Interface,
+			Undesired: sk.Undesired,
+			Reason:    sk.Reason,
+		})
+	}
+
+	summary := fmt.Sprintf("Restore %d connections", len(intents))
+	change := newChange(st, "restore-connections", summary, tasksets, nil)
+	change.Set("api-data", map[string]interface{}{"skipped": skippedJSON})
+	if len(tasksets) == 0 {
+		change.SetStatus(state.DoneStatus)
+	} else {
+		st.EnsureBefore(0)
+	}
+
+	return AsyncResponse(nil, change.ID())
+}
@@ -20,6 +20,7 @@
 package daemon_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -31,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/builtin"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -104,6 +106,75 @@ func (s *interfacesSuite) TestConnectionsUnhappy(c *check.C) {
 	})
 }
 
+func (s *interfacesSuite) TestConnectionsPending(c *check.C) {
+	d := s.daemon(c)
+
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("pending-auto-connections", map[string]interface{}{
+		"consumer:plug": map[string]interface{}{
+			"interface": "content",
+			"reason":    `default provider "producer" is not installed`,
+		},
+		"other:plug": map[string]interface{}{
+			"interface": "test",
+			"reason":    "multiple candidate slots: a:slot, b:slot",
+		},
+	})
+	st.Unlock()
+
+	// pending auto-connections are only listed with select=all
+	s.testConnections(c, "/v2/connections", map[string]interface{}{
+		"result": map[string]interface{}{
+			"established": []interface{}{},
+			"plugs":       []interface{}{},
+			"slots":       []interface{}{},
+		},
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+	s.testConnections(c, "/v2/connections?select=all", map[string]interface{}{
+		"result": map[string]interface{}{
+			"established": []interface{}{},
+			"pending": []interface{}{
+				map[string]interface{}{
+					"plug":      map[string]interface{}{"snap": "consumer", "plug": "plug"},
+					"interface": "content",
+					"reason":    `default provider "producer" is not installed`,
+				},
+				map[string]interface{}{
+					"plug":      map[string]interface{}{"snap": "other", "plug": "plug"},
+					"interface": "test",
+					"reason":    "multiple candidate slots: a:slot, b:slot",
+				},
+			},
+			"plugs": []interface{}{},
+			"slots": []interface{}{},
+		},
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+	s.testConnections(c, "/v2/connections?select=all&interface=test", map[string]interface{}{
+		"result": map[string]interface{}{
+			"established": []interface{}{},
+			"pending": []interface{}{
+				map[string]interface{}{
+					"plug":      map[string]interface{}{"snap": "other", "plug": "plug"},
+					"interface": "test",
+					"reason":    "multiple candidate slots: a:slot, b:slot",
+				},
+			},
+			"plugs": []interface{}{},
+			"slots": []interface{}{},
+		},
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+}
+
 func (s *interfacesSuite) TestConnectionsEmpty(c *check.C) {
 	s.daemon(c)
 	s.testConnections(c, "/v2/connections", map[string]interface{}{
@@ -646,6 +717,66 @@ func (s *interfacesSuite) TestConnectionsDefaultManual(c *check.C) {
 	})
 }
 
+func (s *interfacesSuite) TestConnectionsDeprecatedInterface(c *check.C) {
+	restore := builtin.MockInterface(&ifacetest.TestInterface{
+		InterfaceName:       "test",
+		InterfaceStaticInfo: interfaces.StaticInfo{DeprecatedBy: "test-ng"},
+	})
+	defer restore()
+
+	d := s.daemon(c)
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.testConnectionsConnected(c, d, "/v2/connections", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test",
+			"auto":      true,
+		},
+	}, nil, map[string]interface{}{
+		"result": map[string]interface{}{
+			"plugs": []interface{}{
+				map[string]interface{}{
+					"snap":      "consumer",
+					"plug":      "plug",
+					"interface": "test",
+					"attrs":     map[string]interface{}{"key": "value"},
+					"apps":      []interface{}{"app"},
+					"label":     "label",
+					"connections": []interface{}{
+						map[string]interface{}{"snap": "producer", "slot": "slot"},
+					},
+				},
+			},
+			"slots": []interface{}{
+				map[string]interface{}{
+					"snap":      "producer",
+					"slot":      "slot",
+					"interface": "test",
+					"attrs":     map[string]interface{}{"key": "value"},
+					"apps":      []interface{}{"app"},
+					"label":     "label",
+					"connections": []interface{}{
+						map[string]interface{}{"snap": "consumer", "plug": "plug"},
+					},
+				},
+			},
+			"established": []interface{}{
+				map[string]interface{}{
+					"plug":          map[string]interface{}{"snap": "consumer", "plug": "plug"},
+					"slot":          map[string]interface{}{"snap": "producer", "slot": "slot"},
+					"interface":     "test",
+					"deprecated-by": "test-ng",
+				},
+			},
+		},
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+}
+
 func (s *interfacesSuite) TestConnectionsDefaultAuto(c *check.C) {
 	restore := builtin.MockInterface(&ifacetest.TestInterface{InterfaceName: "test"})
 	defer restore()
@@ -1042,3 +1173,223 @@ slots:
 		"type":        "sync",
 	})
 }
+
+// Tests for GET /v2/connections/explain
+
+func (s *interfacesSuite) TestConnectionsExplain(c *check.C) {
+	restore := builtin.MockInterface(&ifacetest.TestInterface{InterfaceName: "test"})
+	defer restore()
+
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	s.mockModel(st, nil)
+	st.Unlock()
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	explained := []interface{}{
+		map[string]interface{}{
+			"plug":       map[string]interface{}{"snap": "consumer", "plug": "plug"},
+			"slot":       map[string]interface{}{"snap": "producer", "slot": "slot"},
+			"interface":  "test",
+			"unasserted": true,
+			"connection": map[string]interface{}{
+				"kind":    "connection",
+				"allowed": true,
+			},
+			"auto-connection": map[string]interface{}{
+				"kind":    "auto-connection",
+				"allowed": true,
+			},
+		},
+	}
+	s.testConnections(c, "/v2/connections/explain?snap=consumer", map[string]interface{}{
+		"result":      explained,
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+	s.testConnections(c, "/v2/connections/explain?snap=producer&name=slot", map[string]interface{}{
+		"result":      explained,
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+	s.testConnections(c, "/v2/connections/explain?snap=producer&name=other", map[string]interface{}{
+		"result":      []interface{}{},
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+}
+
+func (s *interfacesSuite) TestConnectionsExplainErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		query   string
+		status  int
+		message string
+	}{
+		{"/v2/connections/explain", 400, "snap name is required"},
+		{"/v2/connections/explain?snap=not-installed", 404, `no state entry for key "snaps"`},
+	} {
+		req, err := http.NewRequest("GET", t.query, nil)
+		c.Assert(err, check.IsNil)
+		rsp := s.errorReq(c, req, nil)
+		c.Check(rsp.Status, check.Equals, t.status)
+		c.Check(rsp.Message, check.Equals, t.message)
+	}
+}
+
+func (s *interfacesSuite) TestConnectionsBackupExport(c *check.C) {
+	restore := builtin.MockInterface(&ifacetest.TestInterface{InterfaceName: "test"})
+	defer restore()
+
+	d := s.daemon(c)
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test",
+		},
+		"consumer:plug core:slot": map[string]interface{}{
+			"interface": "test", "auto": true, "undesired": true,
+		},
+		"other:plug producer:slot": map[string]interface{}{
+			"interface": "test", "auto": true,
+		},
+	})
+	st.Unlock()
+
+	backup := map[string]interface{}{
+		"format": 1.0,
+		"connections": []interface{}{
+			map[string]interface{}{
+				"plug":      map[string]interface{}{"snap": "consumer", "plug": "plug"},
+				"slot":      map[string]interface{}{"snap": "producer", "slot": "slot"},
+				"interface": "test",
+			},
+			map[string]interface{}{
+				"plug":      map[string]interface{}{"snap": "consumer", "plug": "plug"},
+				"slot":      map[string]interface{}{"snap": "system", "slot": "slot"},
+				"interface": "test",
+				"undesired": true,
+			},
+		},
+	}
+	s.testConnections(c, "/v2/connections/backup", map[string]interface{}{
+		"result":      backup,
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+	s.testConnections(c, "/v2/connections/backup?snap=producer", map[string]interface{}{
+		"result": map[string]interface{}{
+			"format":      1.0,
+			"connections": backup["connections"].([]interface{})[:1],
+		},
+		"status":      "OK",
+		"status-code": 200.0,
+		"type":        "sync",
+	})
+
+	req, err := http.NewRequest("GET", "/v2/connections/backup?snap=not-installed", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.errorReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 404)
+}
+
+func (s *interfacesSuite) TestConnectionsBackupRestore(c *check.C) {
+	restore := builtin.MockInterface(&ifacetest.TestInterface{InterfaceName: "test"})
+	defer restore()
+
+	d := s.daemon(c)
+
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	d.Overlord().Loop()
+	defer d.Overlord().Stop()
+
+	buf := bytes.NewBufferString(`{"format": 1, "connections": [
+{"plug": {"snap": "consumer", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}, "interface": "test"},
+{"plug": {"snap": "missing", "plug": "plug"}, "slot": {"snap": "producer", "slot": "slot"}, "interface": "test"}
+]}`)
+	req, err := http.NewRequest("POST", "/v2/connections/backup", buf)
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+
+	st := d.Overlord().State()
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	c.Check(chg.Kind(), check.Equals, "restore-connections")
+	c.Check(chg.Summary(), check.Equals, "Restore 2 connections")
+	c.Assert(chg.Err(), check.IsNil)
+	var data map[string]interface{}
+	c.Assert(chg.Get("api-data", &data), check.IsNil)
+	st.Unlock()
+	c.Check(data, check.DeepEquals, map[string]interface{}{
+		"skipped": []interface{}{
+			map[string]interface{}{
+				"plug":      map[string]interface{}{"snap": "missing", "plug": "plug"},
+				"slot":      map[string]interface{}{"snap": "producer", "slot": "slot"},
+				"interface": "test",
+				"reason":    `snap "missing" has no plug named "plug"`,
+			},
+		},
+	})
+
+	repo := d.Overlord().InterfaceManager().Repository()
+	c.Check(repo.Interfaces().Connections, check.DeepEquals, []*interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}})
+}
+
+func (s *interfacesSuite) TestConnectionsBackupRestoreNothingToDo(c *check.C) {
+	d := s.daemon(c)
+
+	buf := bytes.NewBufferString(`{"format": 1, "connections": []}`)
+	req, err := http.NewRequest("POST", "/v2/connections/backup", buf)
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+}
+
+func (s *interfacesSuite) TestConnectionsBackupRestoreErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		body    string
+		message string
+	}{
+		{`}`, `cannot decode request body into connections backup: .*`},
+		{`{"format": 2}`, `unsupported connections backup format: 2`},
+		{`{"format": 1, "connections": [{"plug": {"snap": "consumer", "plug": "plug"}, "interface": "test"}]}`, `incomplete connection in connections backup: consumer:plug :`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/connections/backup", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		rsp := s.errorReq(c, req, nil)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Message, check.Matches, t.message)
+	}
+}
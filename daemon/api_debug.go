@@ -27,16 +27,21 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/timings"
 )
 
+var apparmorCompileStatistics = apparmor.CompileStatistics
+
 var debugCmd = &Command{
 	Path:        "/v2/debug",
 	GET:         getDebug,
@@ -329,6 +334,54 @@ func getDisks(st *state.State) Response {
 	return SyncResponse(vols)
 }
 
+func getReboots(st *state.State) Response {
+	records, err := restart.RebootRecords(st)
+	if err != nil {
+		return InternalError("cannot get reboots: %v", err)
+	}
+	if records == nil {
+		records = []*restart.RebootRecord{}
+	}
+	return SyncResponse(records)
+}
+
+func getTimingsAggregate(kind string) Response {
+	records, err := timings.ReadRecords(dirs.SnapTimingsDBFile)
+	if err != nil {
+		return InternalError("cannot get timings: %v", err)
+	}
+	return SyncResponse(timings.Aggregate(records, kind))
+}
+
+func getAPIAudit(st *state.State, requestID, changeID string) Response {
+	records, err := auditRecords(st)
+	if err != nil {
+		return InternalError("cannot get API audit records: %v", err)
+	}
+	filtered := make([]*auditRecord, 0, len(records))
+	for _, rec := range records {
+		if requestID != "" && rec.ID != requestID {
+			continue
+		}
+		if changeID != "" && rec.ChangeID != changeID {
+			continue
+		}
+		filtered = append(filtered, rec)
+	}
+	return SyncResponse(filtered)
+}
+
+func getChangeGraph(st *state.State, changeID string) Response {
+	if changeID == "" {
+		return BadRequest("change-id is required")
+	}
+	chg := st.Change(changeID)
+	if chg == nil {
+		return NotFound("cannot find change with id %q", changeID)
+	}
+	return SyncResponse(chg.Graph())
+}
+
 func createRecovery(st *state.State, label string) Response {
 	if label == "" {
 		return BadRequest("cannot create a recovery system with no label")
@@ -367,12 +420,27 @@ func getDebug(c *Command, r *http.Request, user *auth.UserState) Response {
 		startupTag := query.Get("startup")
 		all := query.Get("all")
 		return getChangeTimings(st, chgID, ensureTag, startupTag, all == "true")
+	case "timings-aggregate":
+		return getTimingsAggregate(query.Get("kind"))
 	case "seeding":
 		return getSeedingInfo(st)
+	case "apparmor-stats":
+		return SyncResponse(apparmorCompileStatistics())
 	case "gadget-disk-mapping":
 		return getGadgetDiskMapping(st)
 	case "disks":
 		return getDisks(st)
+	case "reboots":
+		return getReboots(st)
+	case "api-audit":
+		// the records identify who did what on the system
+		ucred, err := ucrednetGet(r.RemoteAddr)
+		if err != nil || ucred.Uid != 0 {
+			return Forbidden("access denied")
+		}
+		return getAPIAudit(st, query.Get("id"), query.Get("change-id"))
+	case "change-graph":
+		return getChangeGraph(st, query.Get("change-id"))
 	default:
 		return BadRequest("unknown debug aspect %q", aspect)
 	}
@@ -24,13 +24,19 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 	"github.com/snapcore/snapd/timings"
@@ -223,6 +229,177 @@ func (s *postDebugSuite) TestGetDebugTimingsError(c *check.C) {
 	c.Check(rsp.Status, check.Equals, 400)
 }
 
+func (s *postDebugSuite) TestGetDebugReboots(c *check.C) {
+	d := s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=reboots", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*restart.RebootRecord{})
+
+	requestTime := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("system-reboots", []*restart.RebootRecord{{
+		RebootReason: restart.RebootReason{
+			Code:     restart.RebootReasonKernelRefresh,
+			ChangeID: "1",
+			TaskID:   "2",
+			Snap:     "pc-kernel",
+		},
+		Action:      "reboot",
+		RequestTime: requestTime,
+		FromBootID:  "boot-id-1",
+	}})
+	st.Unlock()
+
+	rsp = s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*restart.RebootRecord{{
+		RebootReason: restart.RebootReason{
+			Code:     restart.RebootReasonKernelRefresh,
+			ChangeID: "1",
+			TaskID:   "2",
+			Snap:     "pc-kernel",
+		},
+		Action:      "reboot",
+		RequestTime: requestTime,
+		FromBootID:  "boot-id-1",
+	}})
+}
+
+func (s *postDebugSuite) TestGetDebugApparmorStats(c *check.C) {
+	s.daemon(c)
+
+	stats := apparmor.CompileStats{
+		Loads:       3,
+		Invocations: 5,
+		Compiled:    4,
+		Cacheable:   10,
+		TotalTime:   2 * time.Second,
+		LongestTime: time.Second,
+		LastLoad:    time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+	restore := daemon.MockApparmorCompileStatistics(func() apparmor.CompileStats {
+		return stats
+	})
+	defer restore()
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=apparmor-stats", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, stats)
+}
+
+func (s *postDebugSuite) TestGetDebugAPIAudit(c *check.C) {
+	d := s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=api-audit", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=0;socket=;"
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*daemon.AuditRecord{})
+
+	uid := uint32(1000)
+	records := []*daemon.AuditRecord{{
+		ID:       "req-1",
+		Time:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Method:   "POST",
+		Path:     "/v2/snaps/foo",
+		UID:      &uid,
+		PID:      123,
+		Status:   202,
+		ChangeID: "1",
+	}, {
+		ID:     "req-2",
+		Time:   time.Date(2024, 1, 2, 3, 5, 5, 0, time.UTC),
+		Method: "POST",
+		Path:   "/v2/snaps/bar",
+		Status: 403,
+	}}
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("api-audit", records)
+	st.Unlock()
+
+	rsp = s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, records)
+
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=api-audit&change-id=1", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=0;socket=;"
+	rsp = s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, records[:1])
+
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=api-audit&id=req-2", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=0;socket=;"
+	rsp = s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, records[1:])
+}
+
+func (s *postDebugSuite) TestGetDebugAPIAuditNotRoot(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=api-audit", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 403)
+
+	// no peer credentials
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=api-audit", nil)
+	c.Assert(err, check.IsNil)
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 403)
+}
+
+func (s *postDebugSuite) TestGetDebugChangeGraph(c *check.C) {
+	d := s.daemon(c)
+
+	st := d.Overlord().State()
+	st.Lock()
+	chg := st.NewChange("foo", "foo summary")
+	t1 := st.NewTask("bar", "bar summary")
+	t2 := st.NewTask("baz", "baz summary")
+	t2.WaitFor(t1)
+	t2.JoinLane(st.NewLane())
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	t1.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=change-graph&change-id="+chg.ID(), nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, &state.ChangeGraph{
+		ID:      chg.ID(),
+		Kind:    "foo",
+		Summary: "foo summary",
+		Status:  "Do",
+		Tasks: []state.ChangeGraphTask{
+			{ID: t1.ID(), Kind: "bar", Summary: "bar summary", Status: "Done", Lanes: []int{0}},
+			{ID: t2.ID(), Kind: "baz", Summary: "baz summary", Status: "Do", Lanes: []int{1}, WaitTasks: []string{t1.ID()}},
+		},
+	})
+}
+
+func (s *postDebugSuite) TestGetDebugChangeGraphErrors(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=change-graph", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "change-id is required")
+
+	req, err = http.NewRequest("GET", "/v2/debug?aspect=change-graph&change-id=42", nil)
+	c.Assert(err, check.IsNil)
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, `cannot find change with id "42"`)
+}
+
 func (s *postDebugSuite) TestMinLane(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
@@ -336,3 +513,40 @@ func (s *postDebugSuite) TestMigrateHomeInternalError(c *check.C) {
 	c.Check(apiErr.Status, check.Equals, 500)
 	c.Check(apiErr.Message, check.Equals, `boom`)
 }
+
+func (s *postDebugSuite) TestGetDebugTimingsAggregate(c *check.C) {
+	s.daemonWithOverlordMock()
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapTimingsDBFile), 0755), check.IsNil)
+	t0 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := timings.AppendRecords(dirs.SnapTimingsDBFile, []*timings.Record{
+		{Kind: "change:refresh-snap", StartTime: t0, Duration: 2 * time.Second},
+		{Kind: "change:refresh-snap", StartTime: t0.Add(time.Hour), Duration: 4 * time.Second},
+		{Kind: "ensure:seed", StartTime: t0, Duration: time.Second},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=timings-aggregate&kind=refresh", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*timings.AggregateInfo{{
+		Kind:      "change:refresh-snap",
+		Count:     2,
+		Boots:     1,
+		Min:       2 * time.Second,
+		Average:   3 * time.Second,
+		Max:       4 * time.Second,
+		Last:      4 * time.Second,
+		FirstTime: t0.Local(),
+		LastTime:  t0.Add(time.Hour).Local(),
+	}})
+}
+
+func (s *postDebugSuite) TestGetDebugTimingsAggregateNoDB(c *check.C) {
+	s.daemonWithOverlordMock()
+
+	req, err := http.NewRequest("GET", "/v2/debug?aspect=timings-aggregate&kind=refresh", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, []*timings.AggregateInfo{})
+}
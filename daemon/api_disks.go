@@ -0,0 +1,243 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var disksCmd = &Command{
+	Path:       "/v2/disks",
+	GET:        getDisksTopology,
+	ReadAccess: openAccess{},
+}
+
+var diskHealthCmd = &Command{
+	Path:       "/v2/disks/health",
+	GET:        getDiskHealth,
+	ReadAccess: openAccess{},
+}
+
+var (
+	disksAllPhysicalDisks = disks.AllPhysicalDisks
+	syscallStatfs         = syscall.Statfs
+)
+
+// diskInfo describes a physical disk and its partitions.
+type diskInfo struct {
+	Device     string        `json:"device"`
+	ID         string        `json:"id,omitempty"`
+	Schema     string        `json:"schema"`
+	Size       quantity.Size `json:"size"`
+	SectorSize quantity.Size `json:"sector-size"`
+	// UnallocatedSize is the usable space of the disk not covered by
+	// any partition.
+	UnallocatedSize quantity.Size `json:"unallocated-size"`
+	// GadgetVolume is the name of the volume of the gadget that the
+	// disk was matched with on install, if any.
+	GadgetVolume string          `json:"gadget-volume,omitempty"`
+	Partitions   []partitionInfo `json:"partitions"`
+}
+
+// partitionInfo describes a partition of a disk.
+type partitionInfo struct {
+	Node            string          `json:"node"`
+	Index           int             `json:"index"`
+	Offset          quantity.Offset `json:"offset"`
+	Size            quantity.Size   `json:"size"`
+	PartitionLabel  string          `json:"partition-label,omitempty"`
+	PartitionUUID   string          `json:"partition-uuid,omitempty"`
+	PartitionType   string          `json:"partition-type,omitempty"`
+	FilesystemType  string          `json:"filesystem-type,omitempty"`
+	FilesystemLabel string          `json:"filesystem-label,omitempty"`
+	FilesystemUUID  string          `json:"filesystem-uuid,omitempty"`
+	// Role is the gadget role of the partition, as recognized by its
+	// filesystem label.
+	Role      string `json:"role,omitempty"`
+	Encrypted bool   `json:"encrypted"`
+	// MountPoints are where the filesystem of the partition, or of its
+	// encrypted container, is mounted.
+	MountPoints []string `json:"mount-points,omitempty"`
+	// FreeSize is the space available in the mounted filesystem.
+	FreeSize *quantity.Size `json:"free-size,omitempty"`
+}
+
+func getDisksTopology(c *Command, r *http.Request, user *auth.UserState) Response {
+	physicalDisks, err := disksAllPhysicalDisks()
+	if err != nil {
+		return InternalError("cannot get all physical disks: %v", err)
+	}
+	mounts, err := osutil.LoadMountInfo()
+	if err != nil {
+		return InternalError("cannot get mounted filesystems: %v", err)
+	}
+	// the disk traits are only saved on UC20+ installs
+	traits, err := gadget.LoadDiskVolumesDeviceTraits(dirs.SnapDeviceDir)
+	if err != nil {
+		logger.Noticef("cannot load disk volumes device traits: %v", err)
+	}
+
+	infos := make([]*diskInfo, 0, len(physicalDisks))
+	for _, d := range physicalDisks {
+		vol, err := gadget.OnDiskVolumeFromDisk(d)
+		if err != nil {
+			return InternalError("cannot get on disk volume for device %s: %v", d.KernelDeviceNode(), err)
+		}
+		parts, err := d.Partitions()
+		if err != nil {
+			return InternalError("cannot get partitions of device %s: %v", d.KernelDeviceNode(), err)
+		}
+		infos = append(infos, newDiskInfo(vol, parts, traits, mounts))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Device < infos[j].Device })
+
+	return SyncResponse(infos)
+}
+
+// getDiskHealth returns the outcome of the last check of the health of the
+// disks backing the system.
+func getDiskHealth(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	report, err := devicestate.LastDiskHealthReport(st)
+	if errors.Is(err, state.ErrNoState) {
+		return NotFound("disk health was not checked yet")
+	}
+	if err != nil {
+		return InternalError("cannot get disk health: %v", err)
+	}
+	return SyncResponse(report)
+}
+
+func newDiskInfo(vol *gadget.OnDiskVolume, parts []disks.Partition, traits map[string]gadget.DiskVolumeDeviceTraits, mounts []*osutil.MountInfoEntry) *diskInfo {
+	info := &diskInfo{
+		Device:     vol.Device,
+		ID:         vol.ID,
+		Schema:     vol.Schema,
+		Size:       vol.Size,
+		SectorSize: vol.SectorSize,
+		Partitions: make([]partitionInfo, 0, len(vol.Structure)),
+	}
+	for name, t := range traits {
+		if (t.DiskID != "" && t.DiskID == vol.ID) || t.OriginalKernelPath == vol.Device {
+			info.GadgetVolume = name
+			break
+		}
+	}
+
+	byNode := make(map[string]disks.Partition, len(parts))
+	for _, p := range parts {
+		byNode[p.KernelDeviceNode] = p
+	}
+
+	for _, s := range vol.Structure {
+		p := byNode[s.Node]
+		pi := partitionInfo{
+			Node:            s.Node,
+			Index:           s.DiskIndex,
+			Offset:          s.StartOffset,
+			Size:            s.Size,
+			PartitionLabel:  s.Name,
+			PartitionUUID:   p.PartitionUUID,
+			PartitionType:   s.Type,
+			FilesystemType:  s.Filesystem,
+			FilesystemLabel: s.Label,
+			FilesystemUUID:  p.FilesystemUUID,
+		}
+		pi.Role, pi.Encrypted = gadget.RoleFromFilesystemLabel(s.Label)
+		if s.Filesystem == "crypto_LUKS" {
+			pi.Encrypted = true
+		}
+		pi.MountPoints = partitionMountPoints(s.Node, s.Label, pi.Encrypted, mounts)
+		if len(pi.MountPoints) > 0 {
+			var st syscall.Statfs_t
+			if err := syscallStatfs(pi.MountPoints[0], &st); err == nil {
+				free := quantity.Size(st.Bavail * uint64(st.Bsize))
+				pi.FreeSize = &free
+			}
+		}
+		info.Partitions = append(info.Partitions, pi)
+	}
+	info.UnallocatedSize = unallocatedSize(vol)
+	return info
+}
+
+// partitionMountPoints returns where the filesystem of the partition with
+// the given device node is mounted. The mapper device of an encrypted
+// partition is named after its label, without the -enc suffix, followed by
+// a random suffix.
+func partitionMountPoints(node, label string, encrypted bool, mounts []*osutil.MountInfoEntry) []string {
+	var mapperPrefix string
+	if encrypted && strings.HasSuffix(label, "-enc") {
+		mapperPrefix = "/dev/mapper/" + strings.TrimSuffix(label, "-enc") + "-"
+	}
+	var mountPoints []string
+	for _, m := range mounts {
+		if m.MountSource == node || (mapperPrefix != "" && strings.HasPrefix(m.MountSource, mapperPrefix)) {
+			mountPoints = append(mountPoints, m.MountDir)
+		}
+	}
+	return mountPoints
+}
+
+// unallocatedSize returns the size of the gaps between the partitions of
+// the volume, and after the last one, within the usable sectors of the
+// disk. The space before the first MiB is reserved for the partition table
+// and boot loaders and not accounted for.
+func unallocatedSize(vol *gadget.OnDiskVolume) quantity.Size {
+	structs := make([]gadget.OnDiskStructure, len(vol.Structure))
+	copy(structs, vol.Structure)
+	sort.Slice(structs, func(i, j int) bool { return structs[i].StartOffset < structs[j].StartOffset })
+
+	end := quantity.Offset(vol.UsableSectorsEnd) * quantity.Offset(vol.SectorSize)
+	if end == 0 {
+		end = quantity.Offset(vol.Size)
+	}
+	pos := quantity.Offset(quantity.OffsetMiB)
+	var unallocated quantity.Size
+	for _, s := range structs {
+		if s.StartOffset > pos {
+			unallocated += quantity.Size(s.StartOffset - pos)
+		}
+		if sEnd := s.StartOffset + quantity.Offset(s.Size); sEnd > pos {
+			pos = sEnd
+		}
+	}
+	if end > pos {
+		unallocated += quantity.Size(end - pos)
+	}
+	return unallocated
+}
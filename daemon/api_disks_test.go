@@ -0,0 +1,329 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"syscall"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/overlord/devicestate"
+)
+
+var _ = check.Suite(&disksSuite{})
+
+type disksSuite struct {
+	apiBaseSuite
+}
+
+func (s *disksSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectOpenAccess()
+}
+
+var mockUC20EncryptedDisk = &disks.MockDiskMapping{
+	DevNode:             "/dev/vda",
+	DevPath:             "/devices/virtual/block/vda",
+	DevNum:              "252:0",
+	ID:                  "disk-id",
+	DiskSchema:          "gpt",
+	DiskHasPartitions:   true,
+	SectorSizeBytes:     512,
+	DiskSizeInBytes:     8*1024*1024*1024 + 33*512,
+	DiskUsableSectorEnd: 8 * 1024 * 1024 * 2,
+	// partitions are listed last seen first
+	Structure: []disks.Partition{
+		{
+			KernelDeviceNode: "/dev/vda4",
+			DiskIndex:        4,
+			StartInBytes:     1966 * 1024 * 1024,
+			SizeInBytes:      4 * 1024 * 1024 * 1024,
+			PartitionLabel:   "ubuntu-data",
+			PartitionUUID:    "data-part-uuid",
+			PartitionType:    "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+			FilesystemLabel:  "ubuntu-data-enc",
+			FilesystemUUID:   "data-fs-uuid",
+			FilesystemType:   "crypto_LUKS",
+		},
+		{
+			KernelDeviceNode: "/dev/vda3",
+			DiskIndex:        3,
+			StartInBytes:     1950 * 1024 * 1024,
+			SizeInBytes:      16 * 1024 * 1024,
+			PartitionLabel:   "ubuntu-save",
+			PartitionType:    "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+			FilesystemLabel:  "ubuntu-save-enc",
+			FilesystemType:   "crypto_LUKS",
+		},
+		{
+			KernelDeviceNode: "/dev/vda2",
+			DiskIndex:        2,
+			StartInBytes:     1200 * 1024 * 1024,
+			SizeInBytes:      750 * 1024 * 1024,
+			PartitionLabel:   "ubuntu-boot",
+			PartitionType:    "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+			FilesystemLabel:  "ubuntu-boot",
+			FilesystemType:   "ext4",
+		},
+		{
+			KernelDeviceNode: "/dev/vda1",
+			DiskIndex:        1,
+			StartInBytes:     1024 * 1024,
+			SizeInBytes:      1199 * 1024 * 1024,
+			PartitionLabel:   "ubuntu-seed",
+			PartitionType:    "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+			FilesystemLabel:  "ubuntu-seed",
+			FilesystemType:   "vfat",
+		},
+	},
+}
+
+var mockOtherDisk = &disks.MockDiskMapping{
+	DevNode:             "/dev/sda",
+	DevPath:             "/devices/pci0000:00/0000:00:01.1/ata1/host0/target0:0:0/0:0:0:0/block/sda",
+	DevNum:              "8:0",
+	ID:                  "0x1234",
+	DiskSchema:          "dos",
+	DiskHasPartitions:   true,
+	SectorSizeBytes:     512,
+	DiskSizeInBytes:     1024 * 1024 * 1024,
+	DiskUsableSectorEnd: 1024 * 1024 * 2,
+	Structure: []disks.Partition{
+		{
+			KernelDeviceNode: "/dev/sda1",
+			DiskIndex:        1,
+			StartInBytes:     1024 * 1024,
+			SizeInBytes:      512 * 1024 * 1024,
+			PartitionType:    "83",
+			FilesystemLabel:  "backup",
+			FilesystemType:   "ext4",
+		},
+	},
+}
+
+const mockDisksMountInfo = `26 1 0:24 / /run/mnt/ubuntu-seed rw,relatime - vfat /dev/vda1 rw
+27 1 0:25 / /run/mnt/ubuntu-boot rw,relatime - ext4 /dev/vda2 rw
+28 1 0:26 / /run/mnt/data rw,relatime - ext4 /dev/mapper/ubuntu-data-3776bab4-8bcc-46b7-9da2-6a84ce7f93b4 rw
+29 1 0:26 / /writable rw,relatime - ext4 /dev/mapper/ubuntu-data-3776bab4-8bcc-46b7-9da2-6a84ce7f93b4 rw`
+
+func (s *disksSuite) TestGetDisks(c *check.C) {
+	s.daemon(c)
+
+	defer daemon.MockDisksAllPhysicalDisks(func() ([]disks.Disk, error) {
+		return []disks.Disk{mockUC20EncryptedDisk, mockOtherDisk}, nil
+	})()
+	defer osutil.MockMountInfo(mockDisksMountInfo)()
+	var statted []string
+	defer daemon.MockSyscallStatfs(func(path string, st *syscall.Statfs_t) error {
+		statted = append(statted, path)
+		st.Bavail = 10
+		st.Bsize = 4096
+		return nil
+	})()
+
+	err := gadget.SaveDiskVolumesDeviceTraits(dirs.SnapDeviceDir, map[string]gadget.DiskVolumeDeviceTraits{
+		"pc": {
+			OriginalDevicePath: "/sys/devices/virtual/block/vda",
+			OriginalKernelPath: "/dev/vda",
+			DiskID:             "disk-id",
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/disks", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+
+	// go through the json representation, as the client would
+	b, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var res []client.Disk
+	c.Assert(json.Unmarshal(b, &res), check.IsNil)
+
+	free := 10 * quantity.Size(4096)
+	c.Check(res, check.DeepEquals, []client.Disk{
+		{
+			Device:          "/dev/sda",
+			ID:              "0x1234",
+			Schema:          "dos",
+			Size:            quantity.SizeGiB,
+			SectorSize:      512,
+			UnallocatedSize: 511 * quantity.SizeMiB,
+			Partitions: []client.DiskPartition{{
+				Node:            "/dev/sda1",
+				Index:           1,
+				Offset:          quantity.OffsetMiB,
+				Size:            512 * quantity.SizeMiB,
+				PartitionType:   "83",
+				FilesystemType:  "ext4",
+				FilesystemLabel: "backup",
+			}},
+		},
+		{
+			Device:          "/dev/vda",
+			ID:              "disk-id",
+			Schema:          "gpt",
+			Size:            8*quantity.SizeGiB + 33*512,
+			SectorSize:      512,
+			UnallocatedSize: (8192 - 6062) * quantity.SizeMiB,
+			GadgetVolume:    "pc",
+			Partitions: []client.DiskPartition{
+				{
+					Node:            "/dev/vda1",
+					Index:           1,
+					Offset:          quantity.OffsetMiB,
+					Size:            1199 * quantity.SizeMiB,
+					PartitionLabel:  "ubuntu-seed",
+					PartitionType:   "C12A7328-F81F-11D2-BA4B-00A0C93EC93B",
+					FilesystemType:  "vfat",
+					FilesystemLabel: "ubuntu-seed",
+					Role:            gadget.SystemSeed,
+					MountPoints:     []string{"/run/mnt/ubuntu-seed"},
+					FreeSize:        &free,
+				},
+				{
+					Node:            "/dev/vda2",
+					Index:           2,
+					Offset:          1200 * quantity.OffsetMiB,
+					Size:            750 * quantity.SizeMiB,
+					PartitionLabel:  "ubuntu-boot",
+					PartitionType:   "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+					FilesystemType:  "ext4",
+					FilesystemLabel: "ubuntu-boot",
+					Role:            gadget.SystemBoot,
+					MountPoints:     []string{"/run/mnt/ubuntu-boot"},
+					FreeSize:        &free,
+				},
+				{
+					Node:            "/dev/vda3",
+					Index:           3,
+					Offset:          1950 * quantity.OffsetMiB,
+					Size:            16 * quantity.SizeMiB,
+					PartitionLabel:  "ubuntu-save",
+					PartitionType:   "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+					FilesystemType:  "crypto_LUKS",
+					FilesystemLabel: "ubuntu-save-enc",
+					Role:            gadget.SystemSave,
+					Encrypted:       true,
+				},
+				{
+					Node:            "/dev/vda4",
+					Index:           4,
+					Offset:          1966 * quantity.OffsetMiB,
+					Size:            4 * quantity.SizeGiB,
+					PartitionLabel:  "ubuntu-data",
+					PartitionUUID:   "data-part-uuid",
+					PartitionType:   "0FC63DAF-8483-4772-8E79-3D69D8477DE4",
+					FilesystemType:  "crypto_LUKS",
+					FilesystemLabel: "ubuntu-data-enc",
+					FilesystemUUID:  "data-fs-uuid",
+					Role:            gadget.SystemData,
+					Encrypted:       true,
+					MountPoints:     []string{"/run/mnt/data", "/writable"},
+					FreeSize:        &free,
+				},
+			},
+		},
+	})
+	c.Check(statted, check.DeepEquals, []string{"/run/mnt/ubuntu-seed", "/run/mnt/ubuntu-boot", "/run/mnt/data"})
+}
+
+func (s *disksSuite) TestGetDisksError(c *check.C) {
+	s.daemon(c)
+
+	defer daemon.MockDisksAllPhysicalDisks(func() ([]disks.Disk, error) {
+		return nil, errors.New("boom")
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/disks", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, "cannot get all physical disks: boom")
+}
+
+func (s *disksSuite) TestGetDiskHealth(c *check.C) {
+	d := s.daemon(c)
+
+	checkTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lifeUsed := 90
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("disk-health", &devicestate.DiskHealthReport{
+		CheckTime: checkTime,
+		Disks: []*devicestate.DiskHealth{{
+			Device:     "/dev/mmcblk0",
+			Roles:      []string{"ubuntu-boot", "ubuntu-data"},
+			Source:     "emmc",
+			LifeUsed:   &lifeUsed,
+			PreEOL:     "normal",
+			Attributes: map[string]string{"life-time-est-typ-a": "0x09"},
+			Warnings:   []string{"an estimated 90% of its rated life time is used"},
+		}},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/disks/health", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+
+	// check the JSON as seen by clients
+	b, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var m map[string]interface{}
+	c.Assert(json.Unmarshal(b, &m), check.IsNil)
+	c.Check(m, check.DeepEquals, map[string]interface{}{
+		"check-time": "2024-06-01T12:00:00Z",
+		"disks": []interface{}{
+			map[string]interface{}{
+				"device":     "/dev/mmcblk0",
+				"roles":      []interface{}{"ubuntu-boot", "ubuntu-data"},
+				"source":     "emmc",
+				"life-used":  90.0,
+				"pre-eol":    "normal",
+				"attributes": map[string]interface{}{"life-time-est-typ-a": "0x09"},
+				"warnings":   []interface{}{"an estimated 90% of its rated life time is used"},
+			},
+		},
+	})
+}
+
+func (s *disksSuite) TestGetDiskHealthNotChecked(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/disks/health", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, "disk health was not checked yet")
+}
@@ -0,0 +1,75 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var snapEpochMigrationCmd = &Command{
+	Path:       "/v2/snaps/{name}/epoch-migration",
+	GET:        getSnapEpochMigration,
+	ReadAccess: openAccess{},
+}
+
+// getSnapEpochMigration explains whether the revision in the requested
+// channel can be reached from the installed one given their epochs.
+func getSnapEpochMigration(c *Command, r *http.Request, user *auth.UserState) Response {
+	name := muxVars(r)["name"]
+	channel := r.URL.Query().Get("channel")
+
+	userID := 0
+	if user != nil {
+		userID = user.ID
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	mig, err := snapstateExplainEpochMigration(r.Context(), st, name, channel, userID)
+	if err != nil {
+		return errToResponse(err, []string{name}, InternalError, "cannot explain epoch migration of %q: %v", name)
+	}
+	return SyncResponse(mig)
+}
+
+// snapUpdateEpochMigration refreshes a snap to the revision in the
+// requested channel, through the intermediate revisions its epoch
+// requires, as a single change.
+func snapUpdateEpochMigration(inst *snapInstruction, st *state.State, flags snapstate.Flags) (string, []*state.TaskSet, error) {
+	mig, err := snapstateExplainEpochMigration(inst.ctx, st, inst.Snaps[0], inst.Channel, inst.userID)
+	if err != nil {
+		return "", nil, err
+	}
+	tss, err := snapstateUpdateEpochMigration(st, mig, inst.userID, flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	msg := fmt.Sprintf(i18n.G("Refresh %q snap to revision %s through %d epoch migration steps"), inst.Snaps[0], mig.Target.Revision, len(mig.Steps))
+	return msg, tss, nil
+}
@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+)
+
+var _ = check.Suite(&epochsSuite{})
+
+type epochsSuite struct {
+	apiBaseSuite
+}
+
+func (s *epochsSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectOpenAccess()
+}
+
+var fakeEpochMigration = &snapstate.EpochMigration{
+	Snap:            "some-snap",
+	Channel:         "2/stable",
+	CurrentRevision: snap.R(7),
+	CurrentEpoch:    snap.E("1"),
+	Target: snapstate.EpochMigrationStep{
+		Revision: snap.R(11),
+		Version:  "2.0",
+		Epoch:    snap.E("3*"),
+		Channel:  "2/stable",
+	},
+	Reachable: true,
+	Steps: []snapstate.EpochMigrationStep{{
+		Revision: snap.R(10),
+		Version:  "1.5",
+		Epoch:    snap.E("2*"),
+		Channel:  "latest/beta",
+	}, {
+		Revision: snap.R(11),
+		Version:  "2.0",
+		Epoch:    snap.E("3*"),
+		Channel:  "2/stable",
+	}},
+}
+
+func (s *epochsSuite) TestGetEpochMigration(c *check.C) {
+	s.daemon(c)
+
+	defer daemon.MockSnapstateExplainEpochMigration(func(ctx context.Context, st *state.State, name, channel string, userID int) (*snapstate.EpochMigration, error) {
+		c.Check(name, check.Equals, "some-snap")
+		c.Check(channel, check.Equals, "2/stable")
+		return fakeEpochMigration, nil
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/some-snap/epoch-migration?channel=2/stable", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.Equals, fakeEpochMigration)
+}
+
+func (s *epochsSuite) TestGetEpochMigrationErrors(c *check.C) {
+	s.daemon(c)
+
+	var explainErr error
+	defer daemon.MockSnapstateExplainEpochMigration(func(ctx context.Context, st *state.State, name, channel string, userID int) (*snapstate.EpochMigration, error) {
+		return nil, explainErr
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/some-snap/epoch-migration", nil)
+	c.Assert(err, check.IsNil)
+
+	explainErr = &snap.NotInstalledError{Snap: "some-snap"}
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapNotInstalled)
+
+	explainErr = store.ErrSnapNotFound
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+
+	explainErr = errors.New("boom")
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, `cannot explain epoch migration of "some-snap": boom`)
+}
+
+func (s *epochsSuite) TestRefreshEpochMigration(c *check.C) {
+	d := s.daemon(c)
+
+	defer daemon.MockAssertstateRefreshSnapAssertions(func(*state.State, int, *assertstate.RefreshAssertionsOptions) error {
+		return nil
+	})()
+	defer daemon.MockSnapstateUpdate(func(*state.State, string, *snapstate.RevisionOptions, int, snapstate.Flags) (*state.TaskSet, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})()
+	defer daemon.MockSnapstateExplainEpochMigration(func(ctx context.Context, st *state.State, name, channel string, userID int) (*snapstate.EpochMigration, error) {
+		c.Check(name, check.Equals, "some-snap")
+		c.Check(channel, check.Equals, "2/stable")
+		c.Check(userID, check.Equals, 17)
+		return fakeEpochMigration, nil
+	})()
+	defer daemon.MockSnapstateUpdateEpochMigration(func(st *state.State, mig *snapstate.EpochMigration, userID int, flags snapstate.Flags) ([]*state.TaskSet, error) {
+		c.Check(mig, check.Equals, fakeEpochMigration)
+		c.Check(userID, check.Equals, 17)
+		var tss []*state.TaskSet
+		for range mig.Steps {
+			tss = append(tss, state.NewTaskSet(st.NewTask("fake-refresh-snap", "Doing a fake refresh")))
+		}
+		return tss, nil
+	})()
+
+	inst := &daemon.SnapInstruction{
+		Action:         "refresh",
+		EpochMigration: true,
+		Snaps:          []string{"some-snap"},
+	}
+	inst.Channel = "2/stable"
+	inst.SetUserID(17)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	summary, tss, err := inst.Dispatch()(inst, st)
+	c.Assert(err, check.IsNil)
+	c.Check(tss, check.HasLen, 2)
+	c.Check(summary, check.Equals, `Refresh "some-snap" snap to revision 11 through 2 epoch migration steps`)
+}
+
+func (s *epochsSuite) TestPostSnapEpochMigrationWrongOptions(c *check.C) {
+	s.daemonWithOverlordMock()
+	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "install", "epoch-migration": true}`, "epoch-migration can only be specified on refresh"},
+		{`{"action": "refresh", "epoch-migration": true, "revision": "42"}`, "epoch-migration cannot be specified with a revision or a cohort"},
+		{`{"action": "refresh", "epoch-migration": true, "cohort-key": "32"}`, "epoch-migration cannot be specified with a revision or a cohort"},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps/some-snap", strings.NewReader(t.body))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%s", t.body))
+		c.Check(rspe.Message, check.Equals, t.err, check.Commentf("%s", t.body))
+	}
+}
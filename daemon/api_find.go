@@ -24,11 +24,13 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 
 	"github.com/gorilla/mux"
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/client/clientutil"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -56,12 +58,23 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 	section := query.Get("section")
 	name := query.Get("name")
 	scope := query.Get("scope")
+	offline := query.Get("offline")
 	private := false
 	prefix := false
 
+	switch offline {
+	case "", "fallback", "only":
+		// valid
+	default:
+		return BadRequest("invalid value for 'offline': %q", offline)
+	}
+
 	if sel := query.Get("select"); sel != "" {
 		switch sel {
 		case "refresh":
+			if offline != "" {
+				return BadRequest("cannot use 'offline' with 'select=refresh'")
+			}
 			if commonID != "" {
 				return BadRequest("cannot use 'common-id' with 'select=refresh'")
 			}
@@ -86,7 +99,7 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 
 		if name[len(name)-1] != '*' {
-			return findOne(c, r, user, name)
+			return findOne(c, r, user, name, offline)
 		}
 
 		prefix = true
@@ -97,16 +110,21 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("cannot use 'common-id' and 'q' together")
 	}
 
-	theStore := storeFrom(c.d)
-	ctx := store.WithClientUserAgent(r.Context(), r)
-	found, err := theStore.Find(ctx, &store.Search{
+	search := &store.Search{
 		Query:    q,
 		Prefix:   prefix,
 		CommonID: commonID,
 		Category: section,
 		Private:  private,
 		Scope:    scope,
-	}, user)
+	}
+	if offline == "only" {
+		return searchOffline(route, search, nil)
+	}
+
+	theStore := storeFrom(c.d)
+	ctx := store.WithClientUserAgent(r.Context(), r)
+	found, err := theStore.Find(ctx, search, user)
 	switch err {
 	case nil:
 		// pass
@@ -115,31 +133,11 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 	case store.ErrUnauthenticated, store.ErrInvalidCredentials:
 		return Unauthorized(err.Error())
 	default:
-		// XXX should these return 503 actually?
-		if e, ok := err.(*url.Error); ok {
-			if neterr, ok := e.Err.(*net.OpError); ok {
-				if dnserr, ok := neterr.Err.(*net.DNSError); ok {
-					return &apiError{
-						Status:  400,
-						Message: dnserr.Error(),
-						Kind:    client.ErrorKindDNSFailure,
-					}
-				}
-			}
-		}
-		if e, ok := err.(net.Error); ok && e.Timeout() {
-			return &apiError{
-				Status:  400,
-				Message: err.Error(),
-				Kind:    client.ErrorKindNetworkTimeout,
-			}
-		}
-		if e, ok := err.(*httputil.PersistentNetworkError); ok {
-			return &apiError{
-				Status:  400,
-				Message: e.Error(),
-				Kind:    client.ErrorKindDNSFailure,
+		if rsp := storeUnreachableError(err); rsp != nil {
+			if offline == "fallback" {
+				return searchOffline(route, search, rsp)
 			}
+			return rsp
 		}
 
 		return InternalError("%v", err)
@@ -153,11 +151,69 @@ func searchStore(c *Command, r *http.Request, user *auth.UserState) Response {
 	return sendStorePackages(route, found, fresp)
 }
 
-func findOne(c *Command, r *http.Request, user *auth.UserState, name string) Response {
+// storeUnreachableError returns the error response for err if it is due
+// to the store being unreachable, nil otherwise.
+func storeUnreachableError(err error) *apiError {
+	// XXX should these return 503 actually?
+	if e, ok := err.(*url.Error); ok {
+		if neterr, ok := e.Err.(*net.OpError); ok {
+			if dnserr, ok := neterr.Err.(*net.DNSError); ok {
+				return &apiError{
+					Status:  400,
+					Message: dnserr.Error(),
+					Kind:    client.ErrorKindDNSFailure,
+				}
+			}
+		}
+	}
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		return &apiError{
+			Status:  400,
+			Message: err.Error(),
+			Kind:    client.ErrorKindNetworkTimeout,
+		}
+	}
+	if e, ok := err.(*httputil.PersistentNetworkError); ok {
+		return &apiError{
+			Status:  400,
+			Message: e.Error(),
+			Kind:    client.ErrorKindDNSFailure,
+		}
+	}
+	return nil
+}
+
+// searchOffline answers the search from the offline catalog or, without
+// one, from the search index kept up to date by the catalog refresh. The
+// results are marked as such with "offline" as their source. If there is
+// neither unreachable is returned if set.
+func searchOffline(route *mux.Route, search *store.Search, unreachable *apiError) Response {
+	found, err := store.FindOffline(dirs.SnapOfflineCatalogFile, search)
+	if os.IsNotExist(err) {
+		found, err = store.FindInSearchIndex(dirs.SnapSearchIndexFile, search)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			if unreachable != nil {
+				return unreachable
+			}
+			return NotFound("no offline catalog available")
+		}
+		return InternalError("%v", err)
+	}
+
+	return sendStorePackages(route, found, &findResponse{Sources: []string{"offline"}})
+}
+
+func findOne(c *Command, r *http.Request, user *auth.UserState, name, offline string) Response {
 	if err := snap.ValidateName(name); err != nil {
 		return BadRequest(err.Error())
 	}
 
+	if offline == "only" {
+		return findOneOffline(r, name, nil)
+	}
+
 	theStore := storeFrom(c.d)
 	spec := store.SnapSpec{
 		Name: name,
@@ -172,6 +228,9 @@ func findOne(c *Command, r *http.Request, user *auth.UserState, name string) Res
 	case store.ErrSnapNotFound:
 		return SnapNotFound(name, err)
 	default:
+		if rsp := storeUnreachableError(err); rsp != nil && offline == "fallback" {
+			return findOneOffline(r, name, rsp)
+		}
 		return InternalError("%v", err)
 	}
 
@@ -188,6 +247,37 @@ func findOne(c *Command, r *http.Request, user *auth.UserState, name string) Res
 	}
 }
 
+// findOneOffline looks up the snap in the offline catalog or the search
+// index, see searchOffline.
+func findOneOffline(r *http.Request, name string, unreachable *apiError) Response {
+	snapInfo, err := store.SnapInfoOffline(dirs.SnapOfflineCatalogFile, name)
+	if os.IsNotExist(err) {
+		snapInfo, err = store.SnapInfoInSearchIndex(dirs.SnapSearchIndexFile, name)
+	}
+	switch {
+	case err == nil:
+		// pass
+	case err == store.ErrSnapNotFound:
+		return SnapNotFound(name, err)
+	case os.IsNotExist(err):
+		if unreachable != nil {
+			return unreachable
+		}
+		return NotFound("no offline catalog available")
+	default:
+		return InternalError("%v", err)
+	}
+
+	data, err := json.Marshal(webify(mapRemote(snapInfo), r.URL.String()))
+	if err != nil {
+		return InternalError(err.Error())
+	}
+	return &findResponse{
+		Results: []*json.RawMessage{(*json.RawMessage)(&data)},
+		Sources: []string{"offline"},
+	}
+}
+
 func storeUpdates(c *Command, r *http.Request, user *auth.UserState) Response {
 	route := c.d.router.Get(snapCmd.Path)
 	if route == nil {
@@ -25,10 +25,13 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -105,6 +108,39 @@ func (s *findSuite) TestFindRefreshes(c *check.C) {
 	c.Check(s.actions, check.HasLen, 1)
 }
 
+func (s *findSuite) TestFindRefreshesSecurity(c *check.C) {
+	s.daemon(c)
+
+	s.rsnaps = []*snap.Info{{
+		SideInfo: snap.SideInfo{
+			RealName: "store",
+		},
+		Publisher: snap.StoreAccount{
+			ID:          "foo-id",
+			Username:    "foo",
+			DisplayName: "Foo",
+			Validation:  "unproven",
+		},
+		Security: &snap.SecurityInfo{
+			Severity: "high",
+			CVEs:     []string{"CVE-2023-1234"},
+		},
+	}}
+	s.mockSnap(c, "name: store\nversion: 1.0")
+
+	req, err := http.NewRequest("GET", "/v2/find?select=refresh", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["security"], check.DeepEquals, map[string]interface{}{
+		"severity": "high",
+		"cves":     []interface{}{"CVE-2023-1234"},
+	})
+}
+
 func (s *findSuite) TestFindRefreshSideloaded(c *check.C) {
 	d := s.daemon(c)
 
@@ -606,3 +642,181 @@ func (s *findSuite) TestSnapsStoreConfinement(c *check.C) {
 		c.Check(snaps[i]["confinement"], check.Equals, mode, check.Commentf(name))
 	}
 }
+
+const mockOfflineCatalog = `{
+  "results": [
+    {
+      "name": "kiosk-browser",
+      "snap-id": "kioskbrowserid0000000000000000000",
+      "revision": {"channel": "stable", "revision": 3, "version": "1.0"},
+      "snap": {
+        "summary": "A browser for kiosks",
+        "publisher": {"id": "kiosk-dev", "username": "kiosk-dev", "display-name": "Kiosk Dev"}
+      }
+    }
+  ]
+}`
+
+func (s *findSuite) mockOfflineCatalog(c *check.C) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapOfflineCatalogFile), 0755), check.IsNil)
+	c.Assert(os.WriteFile(dirs.SnapOfflineCatalogFile, []byte(mockOfflineCatalog), 0644), check.IsNil)
+}
+
+func (s *findSuite) TestFindOfflineFallback(c *check.C) {
+	s.daemon(c)
+	s.mockOfflineCatalog(c)
+
+	s.err = &httputil.PersistentNetworkError{Err: errors.New("problem")}
+
+	req, err := http.NewRequest("GET", "/v2/find?q=kiosk&offline=fallback", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Sources, check.DeepEquals, []string{"offline"})
+	c.Check(s.storeSearch, check.DeepEquals, store.Search{Query: "kiosk"})
+
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "kiosk-browser")
+	c.Check(snaps[0]["summary"], check.Equals, "A browser for kiosks")
+
+	// the store results are used when the store is reachable
+	s.err = nil
+	s.rsnaps = []*snap.Info{{
+		SideInfo: snap.SideInfo{
+			RealName: "store",
+		},
+	}}
+	rsp = s.syncReq(c, req, nil)
+	c.Check(rsp.Sources, check.DeepEquals, []string{"store"})
+	snaps = snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "store")
+}
+
+func (s *findSuite) TestFindOfflineFallbackNoCatalog(c *check.C) {
+	s.daemon(c)
+
+	s.err = &httputil.PersistentNetworkError{Err: errors.New("problem")}
+
+	req, err := http.NewRequest("GET", "/v2/find?q=kiosk&offline=fallback", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "persistent network error: problem")
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindDNSFailure)
+}
+
+func (s *findSuite) TestFindOfflineOnly(c *check.C) {
+	s.daemon(c)
+	s.mockOfflineCatalog(c)
+
+	req, err := http.NewRequest("GET", "/v2/find?name=kiosk*&offline=only", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Sources, check.DeepEquals, []string{"offline"})
+	// the store was not searched
+	c.Check(s.storeSearch, check.DeepEquals, store.Search{})
+
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "kiosk-browser")
+}
+
+func (s *findSuite) TestFindOfflineOnlyNoCatalog(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/find?q=kiosk&offline=only", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, "no offline catalog available")
+}
+
+func (s *findSuite) TestFindOfflineOnlySearchIndex(c *check.C) {
+	s.daemon(c)
+
+	idx := store.NewSearchIndex()
+	idx.Add("iot", []*snap.Info{{
+		SideInfo: snap.SideInfo{RealName: "edge-gw", SnapID: "edgegwid", EditedSummary: "An IoT gateway"},
+		Version:  "2.1",
+	}})
+	idx.Add("productivity", []*snap.Info{{
+		SideInfo: snap.SideInfo{RealName: "editor", SnapID: "editorid", EditedSummary: "A text editor"},
+	}})
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapSearchIndexFile), 0755), check.IsNil)
+	f, err := os.Create(dirs.SnapSearchIndexFile)
+	c.Assert(err, check.IsNil)
+	c.Assert(idx.Write(f), check.IsNil)
+	c.Assert(f.Close(), check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/find?section=iot&offline=only", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Sources, check.DeepEquals, []string{"offline"})
+	c.Check(s.storeSearch, check.DeepEquals, store.Search{})
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "edge-gw")
+	c.Check(snaps[0]["summary"], check.Equals, "An IoT gateway")
+	c.Check(snaps[0]["version"], check.Equals, "2.1")
+
+	req, err = http.NewRequest("GET", "/v2/find?name=ed*&offline=only", nil)
+	c.Assert(err, check.IsNil)
+	rsp = s.syncReq(c, req, nil)
+	snaps = snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 2)
+	c.Check(snaps[0]["name"], check.Equals, "edge-gw")
+	c.Check(snaps[1]["name"], check.Equals, "editor")
+
+	// the offline catalog takes precedence
+	s.mockOfflineCatalog(c)
+	req, err = http.NewRequest("GET", "/v2/find?q=kiosk&offline=only", nil)
+	c.Assert(err, check.IsNil)
+	rsp = s.syncReq(c, req, nil)
+	snaps = snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "kiosk-browser")
+}
+
+func (s *findSuite) TestFindOneOffline(c *check.C) {
+	s.daemon(c)
+	s.mockOfflineCatalog(c)
+
+	req, err := http.NewRequest("GET", "/v2/find?name=kiosk-browser&offline=only", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Sources, check.DeepEquals, []string{"offline"})
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "kiosk-browser")
+
+	req, err = http.NewRequest("GET", "/v2/find?name=other&offline=only", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 404)
+}
+
+func (s *findSuite) TestFindOfflineInvalid(c *check.C) {
+	s.daemon(c)
+
+	for _, t := range []struct {
+		query    string
+		expected string
+	}{
+		{"offline=always", `invalid value for 'offline': "always"`},
+		{"offline=only&select=refresh", `cannot use 'offline' with 'select=refresh'`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/find?"+t.query, nil)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Equals, t.expected)
+	}
+}
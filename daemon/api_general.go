@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
 	"os/exec"
 	"sort"
@@ -36,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
@@ -148,15 +150,36 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	if systemdVirt != "" {
 		m["virtualization"] = systemdVirt
 	}
+	maint, err := plannedMaintenance(st)
+	if err != nil {
+		return InternalError("cannot get planned maintenance: %s", err)
+	}
+	if maint != nil {
+		m["maintenance"] = maint
+	}
+
+	readiness := c.d.overlord.Readiness()
+	rd := &client.Readiness{
+		State: string(readiness.State),
+		Since: readiness.Since,
+	}
+	if readiness.Err != nil {
+		rd.Message = readiness.Err.Error()
+	}
+	m["readiness"] = rd
 
 	// NOTE: Right now we don't have a good way to differentiate if we
 	// only have partial confinement (ala AppArmor disabled and Seccomp
 	// enabled) or no confinement at all. Once we have a better system
 	// in place how we can dynamically retrieve these information from
 	// snapd we will use this here.
-	if sandbox.ForceDevMode() {
+	switch {
+	case sandbox.ForceDevMode():
 		m["confinement"] = "partial"
-	} else {
+	case sandbox.HybridConfinement():
+		// strict confinement is approximated without AppArmor
+		m["confinement"] = "hybrid"
+	default:
 		m["confinement"] = "strict"
 	}
 
@@ -168,6 +191,27 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(m)
 }
 
+// plannedMaintenance returns the next maintenance planned by snapd, a
+// pending restart comes before any scheduled system action.
+func plannedMaintenance(st *state.State) (*client.PlannedMaintenance, error) {
+	maint := restart.Maintenance(st)
+	if maint == nil {
+		var err error
+		maint, err = devicestate.ScheduledMaintenance(st)
+		if err != nil || maint == nil {
+			return nil, err
+		}
+	}
+	return &client.PlannedMaintenance{
+		Kind:        maint.Kind,
+		Action:      maint.Action,
+		Reason:      string(maint.Code),
+		Snap:        maint.Snap,
+		ChangeID:    maint.ChangeID,
+		PlannedTime: maint.Time,
+	}, nil
+}
+
 func formatRefreshTime(t time.Time) string {
 	if t.IsZero() {
 		return ""
@@ -210,7 +254,7 @@ func getChange(c *Command, r *http.Request, user *auth.UserState) Response {
 		return NotFound("cannot find change with id %q", chID)
 	}
 
-	return SyncResponse(change2changeInfo(chg))
+	return SyncResponse(change2changeInfo(chg, c.d.overlord.TaskRunner()))
 }
 
 func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -267,7 +311,7 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 		if !filter(chg) {
 			continue
 		}
-		chgInfos = append(chgInfos, change2changeInfo(chg))
+		chgInfos = append(chgInfos, change2changeInfo(chg, c.d.overlord.TaskRunner()))
 	}
 	return SyncResponse(chgInfos)
 }
@@ -305,7 +349,7 @@ func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	// actually ask to proceed with the abort
 	ensureStateSoon(state)
 
-	return SyncResponse(change2changeInfo(chg))
+	return SyncResponse(change2changeInfo(chg, c.d.overlord.TaskRunner()))
 }
 
 type changeInfo struct {
@@ -330,6 +374,11 @@ type taskInfo struct {
 	Status   string           `json:"status"`
 	Log      []string         `json:"log,omitempty"`
 	Progress taskInfoProgress `json:"progress"`
+	// Abortable is set if aborting the change takes effect on the
+	// task promptly
+	Abortable bool `json:"abortable,omitempty"`
+	// Error classifies why the task failed, if known
+	Error *state.ErrorDetails `json:"error,omitempty"`
 
 	SpawnTime time.Time  `json:"spawn-time,omitempty"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
@@ -339,9 +388,29 @@ type taskInfoProgress struct {
 	Label string `json:"label"`
 	Done  int    `json:"done"`
 	Total int    `json:"total"`
+	// Unit is the unit of Done and Total, e.g. "bytes", empty for steps
+	Unit string `json:"unit,omitempty"`
+	// ETA is the estimated number of seconds until the task is done,
+	// based on the progress made so far
+	ETA float64 `json:"eta,omitempty"`
+}
+
+// progressETA estimates the seconds left for progress started at start to
+// go from done to total.
+func progressETA(start time.Time, done, total int) float64 {
+	if start.IsZero() || done <= 0 || done >= total {
+		return 0
+	}
+	elapsed := timeNow().Sub(start)
+	if elapsed <= 0 {
+		return 0
+	}
+	eta := elapsed.Seconds() * float64(total-done) / float64(done)
+	// a tenth of a second is precise enough
+	return math.Ceil(eta*10) / 10
 }
 
-func change2changeInfo(chg *state.Change) *changeInfo {
+func change2changeInfo(chg *state.Change, runner *state.TaskRunner) *changeInfo {
 	status := chg.Status()
 	chgInfo := &changeInfo{
 		ID:      chg.ID(),
@@ -364,6 +433,7 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	taskInfos := make([]*taskInfo, len(tasks))
 	for j, t := range tasks {
 		label, done, total := t.Progress()
+		unit, start := t.ProgressDetails()
 
 		taskInfo := &taskInfo{
 			ID:      t.ID(),
@@ -375,7 +445,11 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 				Label: label,
 				Done:  done,
 				Total: total,
+				Unit:  unit,
+				ETA:   progressETA(start, done, total),
 			},
+			Abortable: runner.Abortable(t),
+			Error:     t.ErrorDetails(),
 			SpawnTime: t.SpawnTime(),
 		}
 		readyTime := t.ReadyTime()
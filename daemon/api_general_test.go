@@ -24,23 +24,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"time"
 
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
+	"github.com/snapcore/snapd/sandbox/apparmor"
+	"github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/sandbox/seccomp"
+	"github.com/snapcore/snapd/testutil"
 )
 
 var _ = check.Suite(&generalSuite{})
@@ -141,9 +151,60 @@ func (s *generalSuite) TestSysInfo(c *check.C) {
 	const kernelVersionKey = "kernel-version"
 	c.Check(rsp.Result.(map[string]interface{})[kernelVersionKey], check.Not(check.Equals), "")
 	delete(rsp.Result.(map[string]interface{}), kernelVersionKey)
+	readiness := rsp.Result.(map[string]interface{})["readiness"].(map[string]interface{})
+	c.Check(readiness["state"], check.Equals, "ready")
+	delete(rsp.Result.(map[string]interface{}), "readiness")
 	c.Check(rsp.Result, check.DeepEquals, expected)
 }
 
+func (s *generalSuite) TestSysInfoScheduledMaintenance(c *check.C) {
+	d := s.daemon(c)
+
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	st := d.Overlord().State()
+	st.Lock()
+	chg, err := devicestate.ScheduleSystemAction(st, &devicestate.ScheduledSystemAction{Action: "reboot"}, devicestate.SystemActionSchedule{At: at})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result.(map[string]interface{})["maintenance"], check.DeepEquals, &client.PlannedMaintenance{
+		Kind:        "system-restart",
+		Action:      "reboot",
+		Reason:      "system-action",
+		ChangeID:    chg.ID(),
+		PlannedTime: at,
+	})
+}
+
+func (s *generalSuite) TestSysInfoReadinessInitializing(c *check.C) {
+	d, err := daemon.NewAndAddRoutes()
+	c.Assert(err, check.IsNil)
+	s.d = d
+
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("seeded", true)
+	st.Unlock()
+	// the deferred initialization is left to the overlord loop
+	c.Assert(d.Overlord().LazyStartUp(), check.IsNil)
+	st.Lock()
+	snapstate.ReplaceStore(st, s)
+	s.mockModel(st, nil)
+	st.Unlock()
+	snapstate.CanAutoRefresh = nil
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	readiness := rsp.Result.(map[string]interface{})["readiness"].(*client.Readiness)
+	c.Check(readiness.State, check.Equals, "initializing")
+	c.Check(readiness.Since.IsZero(), check.Equals, false)
+	c.Check(readiness.Message, check.Equals, "")
+}
+
 func (s *generalSuite) TestSysInfoLegacyRefresh(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/system-info", nil)
 	c.Assert(err, check.IsNil)
@@ -220,9 +281,34 @@ func (s *generalSuite) TestSysInfoLegacyRefresh(c *check.C) {
 	c.Check(rsp.Type, check.Equals, daemon.ResponseTypeSync)
 	const kernelVersionKey = "kernel-version"
 	delete(rsp.Result.(map[string]interface{}), kernelVersionKey)
+	readiness := rsp.Result.(map[string]interface{})["readiness"].(map[string]interface{})
+	c.Check(readiness["state"], check.Equals, "ready")
+	delete(rsp.Result.(map[string]interface{}), "readiness")
 	c.Check(rsp.Result, check.DeepEquals, expected)
 }
 
+func (s *generalSuite) TestSysInfoHybridConfinement(c *check.C) {
+	restore := apparmor.MockLevel(apparmor.Unsupported)
+	defer restore()
+	restore = landlock.MockABI(1)
+	defer restore()
+	restore = seccomp.MockActions([]string{"allow", "errno"})
+	defer restore()
+	restore = sandbox.MockForceDevMode(false)
+	defer restore()
+
+	s.daemon(c)
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(features.HybridConfinement.ControlFile(), nil, 0644), check.IsNil)
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	result := rsp.Result.(map[string]interface{})
+	c.Check(result["confinement"], check.Equals, "hybrid")
+	c.Check(result["sandbox-features"].(map[string][]string)["confinement-options"], testutil.Contains, "strict")
+}
+
 func (s *generalSuite) testSysInfoSystemMode(c *check.C, mode string) {
 	req, err := http.NewRequest("GET", "/v2/system-info", nil)
 	c.Assert(err, check.IsNil)
@@ -298,6 +384,9 @@ func (s *generalSuite) testSysInfoSystemMode(c *check.C, mode string) {
 	c.Check(rsp.Type, check.Equals, daemon.ResponseTypeSync)
 	const kernelVersionKey = "kernel-version"
 	delete(rsp.Result.(map[string]interface{}), kernelVersionKey)
+	readiness := rsp.Result.(map[string]interface{})["readiness"].(map[string]interface{})
+	c.Check(readiness["state"], check.Equals, "ready")
+	delete(rsp.Result.(map[string]interface{}), "readiness")
 	c.Check(rsp.Result, check.DeepEquals, expected)
 }
 
@@ -387,7 +476,7 @@ func (s *generalSuite) TestStateChangesDefaultToInProgress(c *check.C) {
 	c.Assert(rec.Code, check.Equals, 200)
 	res := rec.Body.Bytes()
 
-	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*`)
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"abortable":true,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
 }
 
 func (s *generalSuite) TestStateChangesInProgress(c *check.C) {
@@ -415,7 +504,7 @@ func (s *generalSuite) TestStateChangesInProgress(c *check.C) {
 	c.Assert(rec.Code, check.Equals, 200)
 	res := rec.Body.Bytes()
 
-	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*],"ready":false,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"abortable":true,"spawn-time":"2016-04-21T01:02:03Z"}.*],"ready":false,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
 }
 
 func (s *generalSuite) TestStateChangesAll(c *check.C) {
@@ -443,7 +532,7 @@ func (s *generalSuite) TestStateChangesAll(c *check.C) {
 	c.Assert(rec.Code, check.Equals, 200)
 	res := rec.Body.Bytes()
 
-	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"spawn-time":"2016-04-21T01:02:03Z"}.*],"ready":false,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
+	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"install","summary":"install...","status":"Do","tasks":\[{"id":"\w+","kind":"download","summary":"1...","status":"Do","log":\["2016-04-21T01:02:03Z INFO l11","2016-04-21T01:02:03Z INFO l12"],"progress":{"label":"","done":0,"total":1},"abortable":true,"spawn-time":"2016-04-21T01:02:03Z"}.*],"ready":false,"spawn-time":"2016-04-21T01:02:03Z"}.*`)
 	c.Check(string(res), check.Matches, `.*{"id":"\w+","kind":"remove","summary":"remove..","status":"Error","tasks":\[{"id":"\w+","kind":"unlink","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR rm failed"],"progress":{"label":"","done":1,"total":1},"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*],"ready":true,"err":"[^"]+".*`)
 }
 
@@ -539,6 +628,46 @@ func (s *generalSuite) TestStateChangesForSnapNameWithApp(c *check.C) {
 	c.Assert(rec.Code, check.Equals, 200)
 }
 
+func (s *generalSuite) TestStateChangeProgressUnitAndETA(c *check.C) {
+	t0 := time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC)
+	restore := state.MockTime(t0)
+	defer restore()
+	// 40s in, a quarter done
+	restore = daemon.MockTimeNow(func() time.Time { return t0.Add(40 * time.Second) })
+	defer restore()
+
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Task(ids[2]).SetProgressInUnits("foo", 250, 1000, "bytes")
+	st.Task(ids[3]).SetProgress("bar", 1, 2)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[0], nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var body struct {
+		Result struct {
+			Tasks []struct {
+				Progress map[string]interface{} `json:"progress"`
+			} `json:"tasks"`
+		} `json:"result"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &body), check.IsNil)
+	c.Assert(body.Result.Tasks, check.HasLen, 2)
+	c.Check(body.Result.Tasks[0].Progress, check.DeepEquals, map[string]interface{}{
+		"label": "foo", "done": 250., "total": 1000., "unit": "bytes", "eta": 120.,
+	})
+	c.Check(body.Result.Tasks[1].Progress, check.DeepEquals, map[string]interface{}{
+		"label": "bar", "done": 1., "total": 2., "eta": 40.,
+	})
+}
+
 func (s *generalSuite) TestStateChange(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
@@ -582,6 +711,7 @@ func (s *generalSuite) TestStateChange(c *check.C) {
 				"status":     "Do",
 				"log":        []interface{}{"2016-04-21T01:02:03Z INFO l11", "2016-04-21T01:02:03Z INFO l12"},
 				"progress":   map[string]interface{}{"label": "", "done": 0., "total": 1.},
+				"abortable":  true,
 				"spawn-time": "2016-04-21T01:02:03Z",
 			},
 			map[string]interface{}{
@@ -590,6 +720,7 @@ func (s *generalSuite) TestStateChange(c *check.C) {
 				"summary":    "2...",
 				"status":     "Do",
 				"progress":   map[string]interface{}{"label": "", "done": 0., "total": 1.},
+				"abortable":  true,
 				"spawn-time": "2016-04-21T01:02:03Z",
 			},
 		},
@@ -599,6 +730,69 @@ func (s *generalSuite) TestStateChange(c *check.C) {
 	})
 }
 
+func (s *generalSuite) TestStateChangeErrorDetails(c *check.C) {
+	// Setup
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	t := st.Task(ids[2])
+	t.SetStatus(state.ErrorStatus)
+	t.Errorf("cannot download: network is unreachable")
+	// as recorded by the task runner
+	t.Set("error-details", &state.ErrorDetails{Kind: "store-offline"})
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[0], nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, req)
+
+	// Verify
+	c.Check(rec.Code, check.Equals, 200)
+	var body struct {
+		Result struct {
+			Tasks []map[string]interface{} `json:"tasks"`
+		} `json:"result"`
+	}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &body), check.IsNil)
+	c.Assert(body.Result.Tasks, check.HasLen, 2)
+	c.Check(body.Result.Tasks[0]["error"], check.DeepEquals, map[string]interface{}{
+		"kind": "store-offline",
+	})
+	c.Check(body.Result.Tasks[1]["error"], check.IsNil)
+}
+
+func (s *generalSuite) TestStateChangeAbortableTasks(c *check.C) {
+	// Setup
+	d := s.daemon(c)
+	d.Overlord().TaskRunner().AddAbortable("download")
+	st := d.Overlord().State()
+	st.Lock()
+	ids := setupChanges(st)
+	// both tasks are running but only download checks for abort
+	st.Task(ids[2]).SetStatus(state.DoingStatus)
+	st.Task(ids[3]).SetStatus(state.DoingStatus)
+	st.Unlock()
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[0], nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+
+	// Verify
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.FitsTypeOf, &daemon.ChangeInfo{})
+	tasks := rsp.Result.(*daemon.ChangeInfo).Tasks
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(tasks[0].Kind, check.Equals, "download")
+	c.Check(tasks[0].Abortable, check.Equals, true)
+	c.Check(tasks[1].Kind, check.Equals, "activate")
+	c.Check(tasks[1].Abortable, check.Equals, false)
+}
+
 func (s *generalSuite) expectManageAccess() {
 	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
 }
@@ -21,6 +21,7 @@ package daemon
 
 import (
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/policy"
 )
 
 // plugJSON aids in marshaling snap.PlugInfo into JSON.
@@ -74,6 +75,48 @@ type connectionJSON struct {
 	Gadget    bool                   `json:"gadget,omitempty"`
 	SlotAttrs map[string]interface{} `json:"slot-attrs,omitempty"`
 	PlugAttrs map[string]interface{} `json:"plug-attrs,omitempty"`
+	// DeprecatedBy is the replacement of the interface of the
+	// connection if it is deprecated.
+	DeprecatedBy string `json:"deprecated-by,omitempty"`
+}
+
+// pendingConnectionJSON aids in marshalling information about a plug that
+// could not be auto-connected into JSON
+type pendingConnectionJSON struct {
+	Plug      interfaces.PlugRef `json:"plug"`
+	Interface string             `json:"interface"`
+	Reason    string             `json:"reason"`
+}
+
+// connectionExplanationJSON aids in marshalling the explanation of
+// whether a plug can be connected to a slot into JSON
+type connectionExplanationJSON struct {
+	Plug           interfaces.PlugRef  `json:"plug"`
+	Slot           interfaces.SlotRef  `json:"slot"`
+	Interface      string              `json:"interface"`
+	Connected      bool                `json:"connected,omitempty"`
+	Unasserted     bool                `json:"unasserted,omitempty"`
+	Connection     *policy.Explanation `json:"connection"`
+	AutoConnection *policy.Explanation `json:"auto-connection"`
+}
+
+// connectionIntentJSON aids in marshalling a manual connection or
+// disconnection into JSON.
+type connectionIntentJSON struct {
+	Plug      interfaces.PlugRef `json:"plug"`
+	Slot      interfaces.SlotRef `json:"slot"`
+	Interface string             `json:"interface"`
+	Undesired bool               `json:"undesired,omitempty"`
+	// Reason is set for the connection intents that were skipped
+	// when restoring them.
+	Reason string `json:"reason,omitempty"`
+}
+
+// connectionsBackupJSON aids in marshalling the portable document of the
+// manual connections and disconnections into JSON.
+type connectionsBackupJSON struct {
+	Format      int                    `json:"format"`
+	Connections []connectionIntentJSON `json:"connections"`
 }
 
 // legacyConnectionsJSON aids in marshaling legacy connections into JSON.
@@ -84,8 +127,9 @@ type legacyConnectionsJSON struct {
 
 // connectionsJSON aids in marshaling connections into JSON.
 type connectionsJSON struct {
-	Established []connectionJSON `json:"established"`
-	Undesired   []connectionJSON `json:"undesired,omitempty"`
-	Plugs       []*plugJSON      `json:"plugs"`
-	Slots       []*slotJSON      `json:"slots"`
+	Established []connectionJSON        `json:"established"`
+	Undesired   []connectionJSON        `json:"undesired,omitempty"`
+	Pending     []pendingConnectionJSON `json:"pending,omitempty"`
+	Plugs       []*plugJSON             `json:"plugs"`
+	Slots       []*slotJSON             `json:"slots"`
 }
@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var noticesCmd = &Command{
+	Path:        "/v2/notices",
+	GET:         getNotices,
+	POST:        postNotices,
+	ReadAccess:  authenticatedAccess{},
+	WriteAccess: snapdInternalAccess{},
+}
+
+const (
+	// launch denials of the same snap repeat the notice at most once
+	// per launchDenialNoticeRepeatAfter
+	launchDenialNoticeRepeatAfter = time.Hour
+
+	maxNoticeDataEntries   = 8
+	maxNoticeDataValueSize = 256
+)
+
+func getNotices(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+
+	filter := &state.NoticeFilter{
+		Keys: strutil.CommaSeparatedList(query.Get("keys")),
+	}
+	for _, typ := range strutil.CommaSeparatedList(query.Get("types")) {
+		filter.Types = append(filter.Types, state.NoticeType(typ))
+	}
+	if after := query.Get("after"); after != "" {
+		t, err := time.Parse(time.RFC3339Nano, after)
+		if err != nil {
+			return BadRequest("invalid after timestamp %q: %v", after, err)
+		}
+		filter.After = t
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	notices := st.Notices(filter)
+	if ucred, err := ucrednetGet(r.RemoteAddr); err != nil || ucred.Uid != 0 {
+		// the payloads of the notices emitted by snaps are meant
+		// for the management agents, which run as root
+		notices = withoutSnapNotices(notices)
+	}
+	if len(notices) == 0 {
+		// no need to confuse the issue
+		return SyncResponse([]*state.Notice{})
+	}
+	return SyncResponse(notices)
+}
+
+func withoutSnapNotices(notices []*state.Notice) []*state.Notice {
+	filtered := make([]*state.Notice, 0, len(notices))
+	for _, n := range notices {
+		if n.Type() == state.SnapNotice {
+			continue
+		}
+		filtered = append(filtered, n)
+	}
+	return filtered
+}
+
+type postNoticeRequest struct {
+	Action string            `json:"action"`
+	Type   string            `json:"type"`
+	Key    string            `json:"key"`
+	Data   map[string]string `json:"data,omitempty"`
+}
+
+func postNotices(c *Command, r *http.Request, user *auth.UserState) Response {
+	ucred, err := ucrednetGet(r.RemoteAddr)
+	if err != nil {
+		return Forbidden("cannot get remote user: %s", err)
+	}
+
+	var req postNoticeRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		return BadRequest("cannot decode request body into notice: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found in request body")
+	}
+
+	if req.Action != "add" {
+		return BadRequest("invalid notice action %q", req.Action)
+	}
+	// only the session launcher reports events to snapd for now
+	if state.NoticeType(req.Type) != state.LaunchDenialNotice {
+		return BadRequest("cannot add notice of type %q", req.Type)
+	}
+	if len(req.Data) > maxNoticeDataEntries {
+		return BadRequest("cannot add notice with more than %d data entries", maxNoticeDataEntries)
+	}
+	data := make(map[string]string, len(req.Data)+1)
+	for k, v := range req.Data {
+		if len(v) > maxNoticeDataValueSize {
+			return BadRequest("cannot add notice with data %q longer than %d bytes", k, maxNoticeDataValueSize)
+		}
+		data[k] = v
+	}
+	data["uid"] = strconv.FormatUint(uint64(ucred.Uid), 10)
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	// the key is the name of the snap
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, req.Key, &snapst); err != nil && err != state.ErrNoState {
+		return InternalError("cannot check snap %q: %v", req.Key, err)
+	}
+	if !snapst.IsInstalled() {
+		return SnapNotFound(req.Key, errNoSnap)
+	}
+
+	id, err := st.AddNotice(state.LaunchDenialNotice, req.Key, &state.AddNoticeOptions{
+		Data:        data,
+		RepeatAfter: launchDenialNoticeRepeatAfter,
+	})
+	if err != nil {
+		return InternalError("cannot add notice: %v", err)
+	}
+	return SyncResponse(map[string]string{"id": id})
+}
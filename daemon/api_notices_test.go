@@ -0,0 +1,237 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = check.Suite(&noticesSuite{})
+
+type noticesSuite struct {
+	apiBaseSuite
+}
+
+func (s *noticesSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	// the notices may carry details about the snaps of other users
+	s.expectReadAccess(daemon.AuthenticatedAccess{})
+}
+
+func (s *noticesSuite) addNotices(c *check.C, t0 time.Time) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	for i, key := range []string{"foo/camera", "bar/home", "foo/home"} {
+		_, err := st.AddNotice(state.InterfaceDenialNotice, key, &state.AddNoticeOptions{
+			Data: map[string]string{"snap": key[:3]},
+			Time: t0.Add(time.Duration(i) * time.Minute),
+		})
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *noticesSuite) getNotices(c *check.C, q url.Values) []map[string]interface{} {
+	return s.getNoticesAs(c, q, 0)
+}
+
+func (s *noticesSuite) getNoticesAs(c *check.C, q url.Values, uid uint32) []map[string]interface{} {
+	req, err := http.NewRequest("GET", "/v2/notices?"+q.Encode(), nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=%d;socket=;", uid)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+
+	// round trip the result as the client sees it
+	buf, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var notices []map[string]interface{}
+	c.Assert(json.Unmarshal(buf, &notices), check.IsNil)
+	return notices
+}
+
+func noticeKeys(notices []map[string]interface{}) []string {
+	keys := []string{}
+	for _, n := range notices {
+		keys = append(keys, n["key"].(string))
+	}
+	return keys
+}
+
+func (s *noticesSuite) TestNotices(c *check.C) {
+	s.daemon(c)
+	t0 := time.Now().UTC().Add(-time.Hour)
+	s.addNotices(c, t0)
+
+	notices := s.getNotices(c, nil)
+	c.Check(noticeKeys(notices), check.DeepEquals, []string{"foo/camera", "bar/home", "foo/home"})
+	c.Check(notices[0]["id"], check.Equals, "1")
+	c.Check(notices[0]["type"], check.Equals, "interface-denial")
+	c.Check(notices[0]["occurrences"], check.Equals, 1.0)
+	c.Check(notices[0]["last-data"], check.DeepEquals, map[string]interface{}{"snap": "foo"})
+
+	notices = s.getNotices(c, url.Values{
+		"types": {"interface-denial"},
+		"keys":  {"foo/home,foo/camera"},
+	})
+	c.Check(noticeKeys(notices), check.DeepEquals, []string{"foo/camera", "foo/home"})
+
+	notices = s.getNotices(c, url.Values{
+		"after": {t0.Add(time.Minute).Format(time.RFC3339Nano)},
+	})
+	c.Check(noticeKeys(notices), check.DeepEquals, []string{"foo/home"})
+
+	notices = s.getNotices(c, url.Values{"types": {"other"}})
+	c.Check(notices, check.HasLen, 0)
+}
+
+func (s *noticesSuite) TestSnapNotices(c *check.C) {
+	s.daemon(c)
+	t0 := time.Now().UTC().Add(-time.Hour)
+	s.addNotices(c, t0)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	_, err := st.AddNotice(state.SnapNotice, "foo/door.opened", &state.AddNoticeOptions{
+		Payload: json.RawMessage(`{"door":"front","count":2}`),
+	})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	notices := s.getNotices(c, url.Values{
+		"types": {"snap"},
+		"keys":  {"foo/door.opened"},
+	})
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0]["type"], check.Equals, "snap")
+	c.Check(notices[0]["key"], check.Equals, "foo/door.opened")
+	c.Check(notices[0]["last-payload"], check.DeepEquals, map[string]interface{}{"door": "front", "count": 2.0})
+	c.Check(notices[0]["last-data"], check.IsNil)
+}
+
+func (s *noticesSuite) TestSnapNoticesNotRoot(c *check.C) {
+	s.daemon(c)
+	t0 := time.Now().UTC().Add(-time.Hour)
+	s.addNotices(c, t0)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	_, err := st.AddNotice(state.SnapNotice, "foo/door.opened", &state.AddNoticeOptions{
+		Payload: json.RawMessage(`{"door":"front"}`),
+	})
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	notices := s.getNoticesAs(c, url.Values{"types": {"snap"}}, 1000)
+	c.Check(notices, check.HasLen, 0)
+
+	// other notices are still there
+	notices = s.getNoticesAs(c, nil, 1000)
+	c.Check(notices, check.Not(check.HasLen), 0)
+	for _, n := range notices {
+		c.Check(n["type"], check.Not(check.Equals), "snap")
+	}
+}
+
+func (s *noticesSuite) TestNoticesBadAfter(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/notices?after=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, `invalid after timestamp "yesterday": .*`)
+}
+
+func (s *noticesSuite) postNotice(c *check.C, body string) *http.Request {
+	req, err := http.NewRequest("POST", "/v2/notices", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "pid=100;uid=1000;socket=;"
+	return req
+}
+
+func (s *noticesSuite) TestAddLaunchDenialNotice(c *check.C) {
+	s.daemon(c)
+	s.expectWriteAccess(daemon.SnapdInternalAccess{})
+	s.mockSnap(c, "name: foo\nversion: 1")
+
+	req := s.postNotice(c, `{"action":"add","type":"launch-denial","key":"foo","data":{"operation":"open-url","scheme":"zoommtg"}}`)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]string{"id": "1"})
+
+	// repeated denials update the same notice
+	req = s.postNotice(c, `{"action":"add","type":"launch-denial","key":"foo","data":{"operation":"open-file"}}`)
+	rsp = s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.DeepEquals, map[string]string{"id": "1"})
+
+	notices := s.getNotices(c, url.Values{"types": {"launch-denial"}})
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(notices[0]["key"], check.Equals, "foo")
+	c.Check(notices[0]["occurrences"], check.Equals, 2.0)
+	c.Check(notices[0]["last-data"], check.DeepEquals, map[string]interface{}{
+		"operation": "open-file",
+		"uid":       "1000",
+	})
+}
+
+func (s *noticesSuite) TestAddNoticeErrors(c *check.C) {
+	s.daemon(c)
+	s.expectWriteAccess(daemon.SnapdInternalAccess{})
+	s.mockSnap(c, "name: foo\nversion: 1")
+
+	tooMany := make(map[string]string)
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"} {
+		tooMany[k] = "v"
+	}
+	tooManyJSON, err := json.Marshal(tooMany)
+	c.Assert(err, check.IsNil)
+
+	for _, t := range []struct {
+		body   string
+		status int
+		err    string
+	}{
+		{`{`, 400, `cannot decode request body into notice: .*`},
+		{`{"action":"remove","type":"launch-denial","key":"foo"}`, 400, `invalid notice action "remove"`},
+		{`{"action":"add","type":"interface-denial","key":"foo/home"}`, 400, `cannot add notice of type "interface-denial"`},
+		{`{"action":"add","type":"launch-denial","key":"foo","data":` + string(tooManyJSON) + `}`, 400, `cannot add notice with more than 8 data entries`},
+		{`{"action":"add","type":"launch-denial","key":"foo","data":{"scheme":"` + strings.Repeat("x", 257) + `"}}`, 400, `cannot add notice with data "scheme" longer than 256 bytes`},
+		{`{"action":"add","type":"launch-denial","key":"bar"}`, 404, `snap not installed`},
+	} {
+		rspe := s.errorReq(c, s.postNotice(c, t.body), nil)
+		c.Check(rspe.Status, check.Equals, t.status, check.Commentf("body: %s", t.body))
+		c.Check(rspe.Message, check.Matches, t.err, check.Commentf("body: %s", t.body))
+	}
+
+	c.Check(s.getNotices(c, nil), check.HasLen, 0)
+}
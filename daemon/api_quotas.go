@@ -95,7 +95,8 @@ func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 			Percentage: grp.CPULimit.Percentage,
 		}
 		constraints.CPUSet = &client.QuotaCPUSetValues{
-			CPUs: grp.CPULimit.CPUSet,
+			CPUs:        grp.CPULimit.CPUSet,
+			MemoryNodes: grp.CPULimit.MemoryNodes,
 		}
 	}
 	if grp.JournalLimit != nil {
@@ -108,6 +109,8 @@ func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 				RatePeriod: grp.JournalLimit.RatePeriod,
 			}
 		}
+		constraints.Journal.RetentionPeriod = grp.JournalLimit.RetentionPeriod
+		constraints.Journal.Storage = grp.JournalLimit.Storage
 	}
 	return &constraints
 }
@@ -201,8 +204,13 @@ func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 			resourcesBuilder.WithCPUPercentage(values.CPU.Percentage)
 		}
 	}
-	if values.CPUSet != nil && len(values.CPUSet.CPUs) != 0 {
-		resourcesBuilder.WithCPUSet(values.CPUSet.CPUs)
+	if values.CPUSet != nil {
+		if len(values.CPUSet.CPUs) != 0 {
+			resourcesBuilder.WithCPUSet(values.CPUSet.CPUs)
+		}
+		if len(values.CPUSet.MemoryNodes) != 0 {
+			resourcesBuilder.WithMemoryNodes(values.CPUSet.MemoryNodes)
+		}
 	}
 	if values.Threads != 0 {
 		resourcesBuilder.WithThreadLimit(values.Threads)
@@ -215,6 +223,12 @@ func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 		if values.Journal.QuotaJournalRate != nil {
 			resourcesBuilder.WithJournalRate(values.Journal.RateCount, values.Journal.RatePeriod)
 		}
+		if values.Journal.RetentionPeriod != 0 {
+			resourcesBuilder.WithJournalRetention(values.Journal.RetentionPeriod)
+		}
+		if values.Journal.Storage != "" {
+			resourcesBuilder.WithJournalStorage(values.Journal.Storage)
+		}
 	}
 	return resourcesBuilder.Build()
 }
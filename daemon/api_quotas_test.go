@@ -124,6 +124,26 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 	})
 }
 
+func (s *apiQuotaSuite) TestCreateQuotaValuesMemoryNodes(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil,
+		quota.NewResourcesBuilder().
+			WithCPUSet([]int{0, 1}).
+			WithMemoryNodes([]int{0}).
+			Build())
+	allGroups, err2 := servicestate.AllQuotas(st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Assert(err2, check.IsNil)
+
+	quotaValues := daemon.CreateQuotaValues(allGroups["ginger-ale"])
+	c.Check(quotaValues.CPUSet, check.DeepEquals, &client.QuotaCPUSetValues{
+		CPUs:        []int{0, 1},
+		MemoryNodes: []int{0},
+	})
+}
+
 func (s *apiQuotaSuite) TestPostQuotaUnknownAction(c *check.C) {
 	data, err := json.Marshal(daemon.PostQuotaGroupData{Action: "foo", GroupName: "bar"})
 	c.Assert(err, check.IsNil)
@@ -408,6 +428,54 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateCpu2Happy(c *check.C) {
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateMemoryNodesHappy(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil,
+		quota.NewResourcesBuilder().
+			WithCPUSet([]int{0, 1}).
+			Build())
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		c.Errorf("should not have called create quota")
+		return nil, fmt.Errorf("broken test")
+	})
+	defer r()
+
+	updateCalled := 0
+	r = daemon.MockServicestateUpdateQuota(func(st *state.State, name string, opts servicestate.UpdateQuotaOptions) (*state.TaskSet, error) {
+		updateCalled++
+		c.Assert(name, check.Equals, "ginger-ale")
+		c.Assert(opts, check.DeepEquals, servicestate.UpdateQuotaOptions{
+			NewResourceLimits: quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).WithMemoryNodes([]int{0}).Build(),
+		})
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "ginger-ale",
+		Constraints: client.QuotaValues{
+			CPUSet: &client.QuotaCPUSetValues{
+				CPUs:        []int{0, 1},
+				MemoryNodes: []int{0},
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(updateCalled, check.Equals, 1)
+	c.Assert(s.ensureSoonCalled, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateMemoryHappy(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -716,6 +784,68 @@ func (s *apiQuotaSuite) TestListQuotas(c *check.C) {
 	c.Check(s.ensureSoonCalled, check.Equals, 0)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateJournalRetentionStorageHappy(c *check.C) {
+	var createCalled int
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(createOpts.ResourceLimits, check.DeepEquals, quota.NewResourcesBuilder().
+			WithJournalNamespace().
+			WithJournalRetention(24*time.Hour).
+			WithJournalStorage("volatile").
+			Build())
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		Snaps:     []string{"some-snap"},
+		Constraints: client.QuotaValues{
+			Journal: &client.QuotaJournalValues{
+				RetentionPeriod: 24 * time.Hour,
+				Storage:         "volatile",
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(createCalled, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestGetJournalRetentionStorageQuota(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "foo", "", nil, quota.NewResourcesBuilder().
+		WithJournalRetention(time.Hour).
+		WithJournalStorage("persistent").
+		Build())
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	r := daemon.MockGetQuotaUsage(func(grp *quota.Group) (*client.QuotaValues, error) {
+		return &client.QuotaValues{}, nil
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/quotas/foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.FitsTypeOf, client.QuotaGroupResult{})
+	res := rsp.Result.(client.QuotaGroupResult)
+	c.Check(res.Constraints, check.DeepEquals, &client.QuotaValues{Journal: &client.QuotaJournalValues{
+		RetentionPeriod: time.Hour,
+		Storage:         "persistent",
+	}})
+}
+
 func (s *apiQuotaSuite) TestListJournalQuotas(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -22,6 +22,7 @@ package daemon
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -29,16 +30,19 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -151,7 +155,7 @@ func sideloadOrTrySnap(c *Command, body io.ReadCloser, boundary string, user *au
 		if len(form.Values["snap-path"]) == 0 {
 			return BadRequest("need 'snap-path' value in form")
 		}
-		return trySnap(c.d.overlord.State(), form.Values["snap-path"][0], flags)
+		return trySnap(c.d.overlord.State(), form.Values["snap-path"][0], flags, isTrue(form, "watch"))
 	}
 
 	if len(form.Values["quota-group"]) > 0 {
@@ -186,10 +190,49 @@ func sideloadOrTrySnap(c *Command, body io.ReadCloser, boundary string, user *au
 		return errRsp
 	}
 
+	var batch *asserts.Batch
+	if len(form.Values["assertion"]) > 0 {
+		batch = asserts.NewBatch(nil)
+		for _, a := range form.Values["assertion"] {
+			if _, err := batch.AddStream(strings.NewReader(a)); err != nil {
+				return BadRequest("cannot decode assertions: %v", err)
+			}
+		}
+	}
+
+	var conns []*interfaces.ConnRef
+	if len(form.Values["connections"]) > 0 {
+		if len(form.Values["connections"]) != 1 {
+			return BadRequest("too many values provided for 'connections' option")
+		}
+		var connsData []struct {
+			Plug interfaces.PlugRef `json:"plug"`
+			Slot interfaces.SlotRef `json:"slot"`
+		}
+		if err := json.Unmarshal([]byte(form.Values["connections"][0]), &connsData); err != nil {
+			return BadRequest("cannot decode connections: %v", err)
+		}
+		for _, conn := range connsData {
+			if conn.Plug.Snap == "" || conn.Plug.Name == "" {
+				return BadRequest("cannot connect: plug snap and name must be provided")
+			}
+			conns = append(conns, &interfaces.ConnRef{PlugRef: conn.Plug, SlotRef: conn.Slot})
+		}
+	}
+
 	st := c.d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
 
+	// the assertions are needed to verify the snaps
+	if batch != nil {
+		if err := assertstate.AddBatch(st, batch, &asserts.CommitOptions{
+			Precheck: true,
+		}); err != nil {
+			return BadRequest("cannot add assertions: %v", err)
+		}
+	}
+
 	var chg *state.Change
 	if len(snapFiles) > 1 {
 		chg, errRsp = sideloadManySnaps(st, snapFiles, sideloadFlags, user)
@@ -200,6 +243,23 @@ func sideloadOrTrySnap(c *Command, body io.ReadCloser, boundary string, user *au
 		return errRsp
 	}
 
+	if len(conns) > 0 {
+		// connect once all the snaps are installed, as part of the same
+		// transaction
+		connectTask := ifacestate.ConnectInstalled(st, conns)
+		lanes := make(map[int]bool)
+		for _, t := range chg.Tasks() {
+			for _, lane := range t.Lanes() {
+				if !lanes[lane] {
+					lanes[lane] = true
+					connectTask.JoinLane(lane)
+				}
+			}
+		}
+		connectTask.WaitAll(state.NewTaskSet(chg.Tasks()...))
+		chg.AddTask(connectTask)
+	}
+
 	chg.Set("system-restart-immediate", isTrue(form, "system-restart-immediate"))
 
 	ensureStateSoon(st)
@@ -442,7 +502,7 @@ func writeToTempFile(reader io.Reader) (path string, err error) {
 	return tmpf.Name(), nil
 }
 
-func trySnap(st *state.State, trydir string, flags snapstate.Flags) Response {
+func trySnap(st *state.State, trydir string, flags snapstate.Flags, watch bool) Response {
 	st.Lock()
 	defer st.Unlock()
 
@@ -452,6 +512,11 @@ func trySnap(st *state.State, trydir string, flags snapstate.Flags) Response {
 	if !osutil.IsDirectory(trydir) {
 		return BadRequest("cannot try %q: not a snap directory", trydir)
 	}
+	if watch {
+		if err := snapstate.CheckTryWatchEnabled(st); err != nil {
+			return BadRequest("cannot watch %q: %v", trydir, err)
+		}
+	}
 
 	// the developer asked us to do this with a trusted snap dir
 	info, err := unsafeReadSnapInfo(trydir)
@@ -475,6 +540,12 @@ func trySnap(st *state.State, trydir string, flags snapstate.Flags) Response {
 	chg := newChange(st, "try-snap", msg, []*state.TaskSet{tset}, []string{info.InstanceName()})
 	chg.Set("api-data", map[string]string{"snap-name": info.InstanceName()})
 
+	if watch {
+		if err := snapstate.WatchTry(st, info.InstanceName(), trydir, flags, chg.ID()); err != nil {
+			return InternalError("cannot watch %q: %v", trydir, err)
+		}
+	}
+
 	ensureStateSoon(st)
 
 	return AsyncResponse(nil, chg.ID())
@@ -39,7 +39,9 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/assertstate/assertstatetest"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
@@ -950,7 +952,104 @@ version: 1`, nil)
 	c.Check(rsp.Message, check.Matches, "cannot find signatures with metadata for snap \"file-two\"")
 }
 
-func (s *sideloadSuite) mockAssertions(c *check.C, st *state.State, snaps []string) (snapData [][]byte) {
+func (s *sideloadSuite) TestSideloadManySnapsWithAssertionsAndConnections(c *check.C) {
+	d := s.daemonWithOverlordMockAndStore()
+	s.markSeeded(d)
+	st := d.Overlord().State()
+	snaps := []string{"one", "two"}
+	snapData, assertions := s.makeAssertedSnaps(c, snaps)
+
+	expectedFlags := snapstate.Flags{RemoveSnapPath: true, Transaction: client.TransactionAllSnaps}
+
+	var lane int
+	restore := daemon.MockSnapstateInstallPathMany(func(_ context.Context, s *state.State, infos []*snap.SideInfo, paths []string, userID int, flags *snapstate.Flags) ([]*state.TaskSet, error) {
+		c.Check(*flags, check.DeepEquals, expectedFlags)
+
+		lane = s.NewLane()
+		var tss []*state.TaskSet
+		for _, si := range infos {
+			// the assertions were added before verifying the snaps
+			c.Check(si.SnapID, check.Equals, si.RealName+"-id")
+			ts := state.NewTaskSet(s.NewTask("fake-install-snap", fmt.Sprintf("Doing a fake install of %q", si.RealName)))
+			ts.JoinLane(lane)
+			tss = append(tss, ts)
+		}
+
+		return tss, nil
+	})
+	defer restore()
+
+	bodyBuf := bytes.NewBufferString("----hello--\r\n")
+	bodyBuf.WriteString("Content-Disposition: form-data; name=\"transaction\"\r\n\r\nall-snaps\r\n----hello--\r\n")
+	for _, a := range assertions {
+		bodyBuf.WriteString("Content-Disposition: form-data; name=\"assertion\"\r\n\r\n")
+		bodyBuf.Write(asserts.Encode(a))
+		bodyBuf.WriteString("\r\n----hello--\r\n")
+	}
+	bodyBuf.WriteString("Content-Disposition: form-data; name=\"connections\"\r\n\r\n")
+	bodyBuf.WriteString(`[{"plug":{"snap":"one","plug":"data"},"slot":{"snap":"two","slot":"data"}},{"plug":{"snap":"one","plug":"network"}}]`)
+	bodyBuf.WriteString("\r\n----hello--\r\n")
+	for i, snap := range snaps {
+		bodyBuf.WriteString("Content-Disposition: form-data; name=\"snap\"; filename=\"file-" + snap + "\"\r\n\r\n")
+		bodyBuf.Write(snapData[i])
+		bodyBuf.WriteString("\r\n----hello--\r\n")
+	}
+
+	req, err := http.NewRequest("POST", "/v2/snaps", bodyBuf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "multipart/thing; boundary=--hello--")
+	rsp := s.asyncReq(c, req, nil)
+
+	c.Check(rsp.Status, check.Equals, 202)
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 3)
+	connectTask := tasks[2]
+	c.Check(connectTask.Kind(), check.Equals, "connect-installed")
+	c.Check(connectTask.WaitTasks(), check.DeepEquals, tasks[:2])
+	// the connections are part of the transaction
+	c.Check(connectTask.Lanes(), check.DeepEquals, []int{lane})
+	var conns []*interfaces.ConnRef
+	c.Assert(connectTask.Get("connections", &conns), check.IsNil)
+	c.Check(conns, check.DeepEquals, []*interfaces.ConnRef{
+		{PlugRef: interfaces.PlugRef{Snap: "one", Name: "data"}, SlotRef: interfaces.SlotRef{Snap: "two", Name: "data"}},
+		{PlugRef: interfaces.PlugRef{Snap: "one", Name: "network"}},
+	})
+}
+
+func (s *sideloadSuite) TestSideloadInvalidConnections(c *check.C) {
+	s.daemonWithOverlordMockAndStore()
+
+	for _, tc := range []struct {
+		conns string
+		err   string
+	}{
+		{`{}`, `cannot decode connections: .*`},
+		{`[{"plug":{"snap":"one"}}]`, `cannot connect: plug snap and name must be provided`},
+	} {
+		body := "----hello--\r\n" +
+			"Content-Disposition: form-data; name=\"connections\"\r\n\r\n" +
+			tc.conns + "\r\n" +
+			"----hello--\r\n" +
+			"Content-Disposition: form-data; name=\"snap\"; filename=\"x\"\r\n\r\n" +
+			"xyzzy\r\n" +
+			"----hello--\r\n"
+		req, err := http.NewRequest("POST", "/v2/snaps", bytes.NewBufferString(body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "multipart/thing; boundary=--hello--")
+		rsp := s.errorReq(c, req, nil)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Message, check.Matches, tc.err)
+	}
+}
+
+// makeAssertedSnaps returns the data of test snaps with the given names and
+// the assertions needed to install them.
+func (s *sideloadSuite) makeAssertedSnaps(c *check.C, snaps []string) (snapData [][]byte, assertions []asserts.Assertion) {
+	assertions = append(assertions, s.StoreSigning.StoreAccountKey(""))
 	for _, snap := range snaps {
 		thisSnap := snaptest.MakeTestSnapWithFiles(c, fmt.Sprintf(`name: %s
 version: 1`, snap), nil)
@@ -979,11 +1078,19 @@ version: 1`, snap), nil)
 		}, nil, "")
 		c.Assert(err, check.IsNil)
 
-		st.Lock()
-		assertstatetest.AddMany(st, s.StoreSigning.StoreAccountKey(""), dev1Acct, snapDecl, snapRev)
-		st.Unlock()
+		assertions = append(assertions, dev1Acct, snapDecl, snapRev)
 	}
 
+	return snapData, assertions
+}
+
+func (s *sideloadSuite) mockAssertions(c *check.C, st *state.State, snaps []string) (snapData [][]byte) {
+	snapData, assertions := s.makeAssertedSnaps(c, snaps)
+
+	st.Lock()
+	assertstatetest.AddMany(st, assertions...)
+	st.Unlock()
+
 	return snapData
 }
 
@@ -1121,7 +1228,7 @@ func (s *trySuite) TestTrySnapRelative(c *check.C) {
 	d := s.daemon(c)
 	st := d.Overlord().State()
 
-	rspe := daemon.TrySnap(st, "relative-path", snapstate.Flags{}).(*daemon.APIError)
+	rspe := daemon.TrySnap(st, "relative-path", snapstate.Flags{}, false).(*daemon.APIError)
 	c.Check(rspe.Message, testutil.Contains, "need an absolute path")
 }
 
@@ -1129,7 +1236,7 @@ func (s *trySuite) TestTrySnapNotDir(c *check.C) {
 	d := s.daemon(c)
 	st := d.Overlord().State()
 
-	rspe := daemon.TrySnap(st, "/does/not/exist", snapstate.Flags{}).(*daemon.APIError)
+	rspe := daemon.TrySnap(st, "/does/not/exist", snapstate.Flags{}, false).(*daemon.APIError)
 	c.Check(rspe.Message, testutil.Contains, "not a snap directory")
 }
 
@@ -1148,10 +1255,51 @@ func (s *trySuite) TestTryChangeConflict(c *check.C) {
 		return nil, &snapstate.ChangeConflictError{Snap: "foo"}
 	})()
 
-	rspe := daemon.TrySnap(st, tryDir, snapstate.Flags{}).(*daemon.APIError)
+	rspe := daemon.TrySnap(st, tryDir, snapstate.Flags{}, false).(*daemon.APIError)
 	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapChangeConflict)
 }
 
+func (s *trySuite) TestTrySnapWatch(c *check.C) {
+	d := s.daemonWithOverlordMockAndStore()
+	st := d.Overlord().State()
+
+	tryDir := c.MkDir()
+
+	defer daemon.MockUnsafeReadSnapInfo(func(path string) (*snap.Info, error) {
+		return &snap.Info{SuggestedName: "foo"}, nil
+	})()
+	defer daemon.MockSnapstateTryPath(func(s *state.State, name, path string, flags snapstate.Flags) (*state.TaskSet, error) {
+		t := s.NewTask("fake-install-snap", "Doing a fake try")
+		return state.NewTaskSet(t), nil
+	})()
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {})
+	defer restore()
+
+	// the feature needs to be enabled
+	rspe := daemon.TrySnap(st, tryDir, snapstate.Flags{}, true).(*daemon.APIError)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, fmt.Sprintf(`cannot watch %q: experimental feature disabled - test it by setting 'experimental.try-watch' to true`, tryDir))
+
+	st.Lock()
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "experimental.try-watch", true), check.IsNil)
+	tr.Commit()
+	st.Unlock()
+
+	rsp := daemon.TrySnap(st, tryDir, snapstate.Flags{DevMode: true}, true).(*daemon.RespJSON)
+	c.Check(rsp.Type, check.Equals, daemon.ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	watches, err := snapstate.TryWatches(st)
+	c.Assert(err, check.IsNil)
+	c.Assert(watches, check.HasLen, 1)
+	c.Check(watches[0].Snap, check.Equals, "foo")
+	c.Check(watches[0].Path, check.Equals, tryDir)
+	c.Check(watches[0].Flags, check.DeepEquals, snapstate.Flags{DevMode: true})
+	c.Check(watches[0].LastChange, check.Equals, rsp.Change)
+}
+
 func (s *sideloadSuite) TestSideloadSnapInvalidTransaction(c *check.C) {
 	s.daemon(c)
 
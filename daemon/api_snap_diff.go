@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"net/http"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/snap"
+)
+
+var snapDiffCmd = &Command{
+	Path:       "/v2/snaps/{name}/diff",
+	GET:        getSnapDiff,
+	ReadAccess: authenticatedAccess{},
+}
+
+// getSnapDiff compares two locally available revisions of a snap.
+func getSnapDiff(c *Command, r *http.Request, user *auth.UserState) Response {
+	name := muxVars(r)["name"]
+	query := r.URL.Query()
+
+	var revs [2]snap.Revision
+	for i, param := range []string{"from", "to"} {
+		value := query.Get(param)
+		if value == "" {
+			return BadRequest("missing %q parameter", param)
+		}
+		rev, err := snap.ParseRevision(value)
+		if err != nil {
+			return BadRequest("invalid %q parameter: %v", param, err)
+		}
+		revs[i] = rev
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	diff, err := snapstateDiffRevisions(st, name, revs[0], revs[1])
+	if err != nil {
+		return errToResponse(err, []string{name}, InternalError, "cannot compare revisions of %q: %v", name)
+	}
+	return SyncResponse(diff)
+}
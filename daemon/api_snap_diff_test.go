@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"errors"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var _ = check.Suite(&snapDiffSuite{})
+
+type snapDiffSuite struct {
+	apiBaseSuite
+}
+
+func (s *snapDiffSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectReadAccess(daemon.AuthenticatedAccess{})
+}
+
+func (s *snapDiffSuite) TestGetSnapDiff(c *check.C) {
+	s.daemon(c)
+
+	fakeDiff := &snapstate.RevisionDiff{
+		Snap:  "some-snap",
+		From:  snap.R(1),
+		To:    snap.R(2),
+		Files: snapstate.DiffEntries{Modified: []string{"bin/app"}},
+	}
+	defer daemon.MockSnapstateDiffRevisions(func(st *state.State, name string, from, to snap.Revision) (*snapstate.RevisionDiff, error) {
+		c.Check(name, check.Equals, "some-snap")
+		c.Check(from, check.Equals, snap.R(1))
+		c.Check(to, check.Equals, snap.R(2))
+		return fakeDiff, nil
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/some-snap/diff?from=1&to=2", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Result, check.Equals, fakeDiff)
+}
+
+func (s *snapDiffSuite) TestGetSnapDiffBadRequest(c *check.C) {
+	s.daemon(c)
+
+	defer daemon.MockSnapstateDiffRevisions(func(*state.State, string, snap.Revision, snap.Revision) (*snapstate.RevisionDiff, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})()
+
+	for _, t := range []struct {
+		query string
+		err   string
+	}{
+		{"", `missing "from" parameter`},
+		{"?from=1", `missing "to" parameter`},
+		{"?from=foo&to=2", `invalid "from" parameter: invalid snap revision: "foo"`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/snaps/some-snap/diff"+t.query, nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%s", t.query))
+		c.Check(rspe.Message, check.Equals, t.err, check.Commentf("%s", t.query))
+	}
+}
+
+func (s *snapDiffSuite) TestGetSnapDiffErrors(c *check.C) {
+	s.daemon(c)
+
+	var diffErr error
+	defer daemon.MockSnapstateDiffRevisions(func(*state.State, string, snap.Revision, snap.Revision) (*snapstate.RevisionDiff, error) {
+		return nil, diffErr
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/some-snap/diff?from=1&to=2", nil)
+	c.Assert(err, check.IsNil)
+
+	diffErr = &snap.NotInstalledError{Snap: "some-snap", Rev: snap.R(2)}
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapNotInstalled)
+
+	diffErr = errors.New("boom")
+	rspe = s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, `cannot compare revisions of "some-snap": boom`)
+}
@@ -139,6 +139,10 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("%s", err)
 	}
 
+	if inst.DryRun {
+		return snapRemovalPlan(&inst, st)
+	}
+
 	impl := inst.dispatch()
 	if impl == nil {
 		return BadRequest("unknown action %s", inst.Action)
@@ -203,6 +207,9 @@ type snapInstruction struct {
 	IgnoreRunning          bool                   `json:"ignore-running"`
 	Unaliased              bool                   `json:"unaliased"`
 	Purge                  bool                   `json:"purge,omitempty"`
+	KeepCache              bool                   `json:"keep-cache,omitempty"`
+	Cascade                bool                   `json:"cascade,omitempty"`
+	DryRun                 bool                   `json:"dry-run,omitempty"`
 	SystemRestartImmediate bool                   `json:"system-restart-immediate"`
 	Transaction            client.TransactionType `json:"transaction"`
 	Snaps                  []string               `json:"snaps"`
@@ -211,6 +218,7 @@ type snapInstruction struct {
 	QuotaGroupName         string                 `json:"quota-group"`
 	Time                   string                 `json:"time"`
 	HoldLevel              string                 `json:"hold-level"`
+	EpochMigration         bool                   `json:"epoch-migration"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
@@ -292,6 +300,33 @@ func (inst *snapInstruction) validate() error {
 	if inst.QuotaGroupName != "" && inst.Action != "install" {
 		return fmt.Errorf("quota-group can only be specified on install")
 	}
+	if inst.KeepCache && inst.Action != "remove" {
+		return fmt.Errorf("keep-cache can only be specified on remove")
+	}
+	if inst.Cascade {
+		if inst.Action != "remove" {
+			return fmt.Errorf("cascade can only be specified on remove")
+		}
+		if !inst.Revision.Unset() {
+			return fmt.Errorf("cascade cannot be specified with a revision")
+		}
+	}
+	if inst.DryRun {
+		if inst.Action != "remove" {
+			return fmt.Errorf("dry-run can only be specified on remove")
+		}
+		if !inst.Revision.Unset() {
+			return fmt.Errorf("dry-run cannot be specified with a revision")
+		}
+	}
+	if inst.EpochMigration {
+		if inst.Action != "refresh" {
+			return fmt.Errorf("epoch-migration can only be specified on refresh")
+		}
+		if !inst.Revision.Unset() || inst.CohortKey != "" || inst.LeaveCohort {
+			return fmt.Errorf("epoch-migration cannot be specified with a revision or a cohort")
+		}
+	}
 
 	if inst.Action == "hold" {
 		if inst.Time == "" {
@@ -404,6 +439,10 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 		return "", nil, err
 	}
 
+	if inst.EpochMigration {
+		return snapUpdateEpochMigration(inst, st, flags)
+	}
+
 	ts, err := snapstateUpdate(st, inst.Snaps[0], inst.revnoOpts(), inst.userID, flags)
 	if err != nil {
 		return "", nil, err
@@ -418,15 +457,36 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 }
 
 func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
-	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision, &snapstate.RemoveFlags{Purge: inst.Purge})
+	msg := fmt.Sprintf(i18n.G("Remove %q snap"), inst.Snaps[0])
+	flags := &snapstate.RemoveFlags{Purge: inst.Purge, KeepCache: inst.KeepCache}
+	if inst.Cascade {
+		// the snaps needing this one are removed in the same change
+		flags.Cascade = true
+		_, tss, err := snapstateRemoveMany(st, inst.Snaps, flags)
+		if err != nil {
+			return "", nil, err
+		}
+		return msg, tss, nil
+	}
+
+	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision, flags)
 	if err != nil {
 		return "", nil, err
 	}
 
-	msg := fmt.Sprintf(i18n.G("Remove %q snap"), inst.Snaps[0])
 	return msg, []*state.TaskSet{ts}, nil
 }
 
+// snapRemovalPlan returns the snaps that removing inst.Snaps would
+// remove, in order, without removing anything.
+func snapRemovalPlan(inst *snapInstruction, st *state.State) Response {
+	plan, err := snapstateRemovalPlan(st, inst.Snaps, inst.Cascade)
+	if err != nil {
+		return inst.errToResponse(err)
+	}
+	return SyncResponse(map[string]interface{}{"snap-names": plan})
+}
+
 func snapRevert(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
 	var ts *state.TaskSet
 
@@ -599,6 +659,10 @@ func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 		inst.userID = user.ID
 	}
 
+	if inst.DryRun {
+		return snapRemovalPlan(&inst, st)
+	}
+
 	op := inst.dispatchForMany()
 	if op == nil {
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
@@ -797,7 +861,7 @@ func meetSnapConstraintsForEnforce(inst *snapInstruction, st *state.State, vErr
 }
 
 func snapRemoveMany(inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	flags := &snapstate.RemoveFlags{Purge: inst.Purge}
+	flags := &snapstate.RemoveFlags{Purge: inst.Purge, KeepCache: inst.KeepCache, Cascade: inst.Cascade}
 	removed, tasksets, err := snapstateRemoveMany(st, inst.Snaps, flags)
 	if err != nil {
 		return nil, err
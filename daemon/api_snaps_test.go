@@ -855,6 +855,120 @@ func (s *snapsSuite) TestRemoveManyWithPurge(c *check.C) {
 	c.Check(res.Summary, check.Equals, `Remove snaps "foo", "bar"`)
 	c.Check(res.Affected, check.DeepEquals, inst.Snaps)
 }
+func (s *snapsSuite) TestRemoveManyWithKeepCache(c *check.C) {
+	defer daemon.MockSnapstateRemoveMany(func(s *state.State, names []string, opts *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.HasLen, 2)
+		c.Check(opts.KeepCache, check.Equals, true)
+		t := s.NewTask("fake-remove-2", "Remove two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	})()
+
+	d := s.daemon(c)
+	inst := &daemon.SnapInstruction{Action: "remove", KeepCache: true, Snaps: []string{"foo", "bar"}}
+	st := d.Overlord().State()
+	st.Lock()
+	res, err := inst.DispatchForMany()(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(res.Summary, check.Equals, `Remove snaps "foo", "bar"`)
+	c.Check(res.Affected, check.DeepEquals, inst.Snaps)
+}
+
+func (s *snapsSuite) TestRemoveManyWithCascade(c *check.C) {
+	defer daemon.MockSnapstateRemoveMany(func(s *state.State, names []string, opts *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.DeepEquals, []string{"foo"})
+		c.Check(opts.Cascade, check.Equals, true)
+		t := s.NewTask("fake-remove-2", "Remove two")
+		return []string{"bar", "foo"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	})()
+
+	d := s.daemon(c)
+	inst := &daemon.SnapInstruction{Action: "remove", Cascade: true, Snaps: []string{"foo"}}
+	st := d.Overlord().State()
+	st.Lock()
+	res, err := inst.DispatchForMany()(inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(res.Summary, check.Equals, `Remove snap "foo"`)
+	c.Check(res.Affected, check.DeepEquals, []string{"bar", "foo"})
+}
+
+func (s *snapsSuite) TestPostSnapRemoveCascade(c *check.C) {
+	defer daemon.MockSnapstateRemoveMany(func(s *state.State, names []string, opts *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.DeepEquals, []string{"foo"})
+		c.Check(opts.Cascade, check.Equals, true)
+		t1 := s.NewTask("fake-remove-bar", "Remove bar")
+		t2 := s.NewTask("fake-remove-foo", "Remove foo")
+		return []string{"bar", "foo"}, []*state.TaskSet{state.NewTaskSet(t1), state.NewTaskSet(t2)}, nil
+	})()
+
+	d := s.daemonWithOverlordMock()
+
+	buf := strings.NewReader(`{"action": "remove", "cascade": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Check(chg.Summary(), check.Equals, `Remove "foo" snap`)
+	c.Check(chg.Tasks(), check.HasLen, 2)
+}
+
+func (s *snapsSuite) TestPostSnapsRemoveDryRun(c *check.C) {
+	n := 0
+	defer daemon.MockSnapstateRemovalPlan(func(st *state.State, names []string, cascade bool) ([]string, error) {
+		c.Check(names, check.DeepEquals, []string{"foo"})
+		c.Check(cascade, check.Equals, true)
+		n++
+		return []string{"bar", "foo"}, nil
+	})()
+	defer daemon.MockSnapstateRemoveMany(func(s *state.State, names []string, opts *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error) {
+		c.Fatalf("unexpected removal")
+		return nil, nil, nil
+	})()
+
+	d := s.daemonWithOverlordMock()
+
+	for _, path := range []string{"/v2/snaps", "/v2/snaps/foo"} {
+		buf := strings.NewReader(`{"action": "remove", "snaps": ["foo"], "cascade": true, "dry-run": true}`)
+		req, err := http.NewRequest("POST", path, buf)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rsp := s.syncReq(c, req, nil)
+		c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"snap-names": []string{"bar", "foo"}})
+	}
+	c.Check(n, check.Equals, 2)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (s *snapsSuite) TestPostSnapsRemoveDryRunDependents(c *check.C) {
+	defer daemon.MockSnapstateRemovalPlan(func(st *state.State, names []string, cascade bool) ([]string, error) {
+		c.Check(cascade, check.Equals, false)
+		return nil, &snapstate.DependentSnapsError{Snap: "foo", Dependents: []string{"bar"}}
+	})()
+
+	s.daemonWithOverlordMock()
+
+	buf := strings.NewReader(`{"action": "remove", "snaps": ["foo"], "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Kind, check.Equals, client.ErrorKindSnapHasDependents)
+	c.Check(rspe.Message, check.Equals, `snap "foo" is not removable: snap is needed by snap "bar"`)
+}
+
 func (s *snapsSuite) TestSnapInfoOneIntegration(c *check.C) {
 	d := s.daemon(c)
 
@@ -1428,6 +1542,44 @@ func (s *snapsSuite) TestPostSnapQuotaGroupWrongAction(c *check.C) {
 	}
 }
 
+func (s *snapsSuite) TestPostSnapKeepCacheWrongAction(c *check.C) {
+	s.daemonWithOverlordMock()
+	const expectedErr = "keep-cache can only be specified on remove"
+
+	for _, action := range []string{"install", "refresh", "revert", "enable", "disable", "xyzzy"} {
+		buf := strings.NewReader(fmt.Sprintf(`{"action": "%s", "keep-cache": true}`, action))
+		req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%q", action))
+		c.Check(rspe.Message, check.Equals, expectedErr, check.Commentf("%q", action))
+	}
+}
+
+func (s *snapsSuite) TestPostSnapCascadeAndDryRunWrongAction(c *check.C) {
+	s.daemonWithOverlordMock()
+
+	for _, opt := range []string{"cascade", "dry-run"} {
+		expectedErr := fmt.Sprintf("%s can only be specified on remove", opt)
+		for _, action := range []string{"install", "refresh", "revert", "enable", "disable", "xyzzy"} {
+			buf := strings.NewReader(fmt.Sprintf(`{"action": "%s", "%s": true}`, action, opt))
+			req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+			c.Assert(err, check.IsNil)
+
+			rspe := s.errorReq(c, req, nil)
+			c.Check(rspe.Status, check.Equals, 400, check.Commentf("%q", action))
+			c.Check(rspe.Message, check.Equals, expectedErr, check.Commentf("%q", action))
+		}
+
+		buf := strings.NewReader(fmt.Sprintf(`{"action": "remove", "revision": "1", "%s": true}`, opt))
+		req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Message, check.Equals, fmt.Sprintf("%s cannot be specified with a revision", opt))
+	}
+}
+
 func (s *snapsSuite) TestPostSnapLeaveCohortUnsupportedAction(c *check.C) {
 	s.daemonWithOverlordMock()
 	const expectedErr = "leave-cohort can only be specified for refresh or switch"
@@ -181,7 +181,20 @@ func getSnapshotExport(c *Command, r *http.Request, user *auth.UserState) Respon
 		return BadRequest("'id' must be a positive base 10 number; got %q", sid)
 	}
 
-	export, err := snapshotExport(context.TODO(), st, setID)
+	var enc *snapshotstate.ExportEncryption
+	if keySource := r.URL.Query().Get("encryption"); keySource != "" {
+		switch keySource {
+		case client.SnapshotEncryptionDevice, client.SnapshotEncryptionPassphrase:
+		default:
+			return BadRequest("unknown snapshot encryption %q", keySource)
+		}
+		enc = &snapshotstate.ExportEncryption{
+			KeySource:  keySource,
+			Passphrase: r.Header.Get(client.SnapshotPassphraseHeader),
+		}
+	}
+
+	export, err := snapshotExport(context.TODO(), st, setID, enc)
 	if err != nil {
 		return BadRequest("cannot export %v: %v", setID, err)
 	}
@@ -208,7 +221,8 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 
 	// XXX: check that we have enough space to import the compressed snapshots
 	st := c.d.overlord.State()
-	setID, snapNames, err := snapshotImport(context.TODO(), st, limitedBodyReader)
+	passphrase := r.Header.Get(client.SnapshotPassphraseHeader)
+	setID, snapNames, err := snapshotImport(context.TODO(), st, limitedBodyReader, passphrase)
 	if err != nil {
 		return BadRequest(err.Error())
 	}
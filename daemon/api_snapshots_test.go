@@ -311,9 +311,10 @@ func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	var snapshotExportCalled int
 
-	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64) (*snapshotstate.SnapshotExport, error) {
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, enc *snapshotstate.ExportEncryption) (*snapshotstate.SnapshotExport, error) {
 		snapshotExportCalled++
 		c.Check(setID, check.Equals, uint64(1))
+		c.Check(enc, check.IsNil)
 		return &snapshotstate.SnapshotExport{}, nil
 	})()
 
@@ -325,6 +326,40 @@ func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	c.Check(snapshotExportCalled, check.Equals, 1)
 }
 
+func (s *snapshotSuite) TestExportSnapshotsEncrypted(c *check.C) {
+	var encs []*snapshotstate.ExportEncryption
+
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, enc *snapshotstate.ExportEncryption) (*snapshotstate.SnapshotExport, error) {
+		encs = append(encs, enc)
+		return &snapshotstate.SnapshotExport{}, nil
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snapshots/1/export?encryption=device", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.req(c, req, nil)
+	c.Check(rsp, check.FitsTypeOf, &daemon.SnapshotExportResponse{})
+
+	req, err = http.NewRequest("GET", "/v2/snapshots/1/export?encryption=passphrase", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set(client.SnapshotPassphraseHeader, "sekrit")
+	rsp = s.req(c, req, nil)
+	c.Check(rsp, check.FitsTypeOf, &daemon.SnapshotExportResponse{})
+
+	c.Check(encs, check.DeepEquals, []*snapshotstate.ExportEncryption{
+		{KeySource: "device"},
+		{KeySource: "passphrase", Passphrase: "sekrit"},
+	})
+}
+
+func (s *snapshotSuite) TestExportSnapshotsUnknownEncryption(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/snapshots/1/export?encryption=rot13", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `unknown snapshot encryption "rot13"`)
+}
+
 func (s *snapshotSuite) TestExportSnapshotsBadRequestOnNonNumericID(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/snapshots/xxx/export", nil)
 	c.Assert(err, check.IsNil)
@@ -337,7 +372,7 @@ func (s *snapshotSuite) TestExportSnapshotsBadRequestOnNonNumericID(c *check.C)
 func (s *snapshotSuite) TestExportSnapshotsBadRequestOnError(c *check.C) {
 	var snapshotExportCalled int
 
-	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64) (*snapshotstate.SnapshotExport, error) {
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, enc *snapshotstate.ExportEncryption) (*snapshotstate.SnapshotExport, error) {
 		snapshotExportCalled++
 		return nil, fmt.Errorf("boom")
 	})()
@@ -356,7 +391,8 @@ func (s *snapshotSuite) TestImportSnapshot(c *check.C) {
 
 	setID := uint64(3)
 	snapNames := []string{"baz", "bar", "foo"}
-	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader, passphrase string) (uint64, []string, error) {
+		c.Check(passphrase, check.Equals, "")
 		return setID, snapNames, nil
 	})()
 
@@ -370,8 +406,26 @@ func (s *snapshotSuite) TestImportSnapshot(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"set-id": setID, "snaps": snapNames})
 }
 
+func (s *snapshotSuite) TestImportSnapshotPassphrase(c *check.C) {
+	data := []byte("mocked encrypted snapshot export data file")
+
+	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader, passphrase string) (uint64, []string, error) {
+		c.Check(passphrase, check.Equals, "sekrit")
+		return uint64(3), []string{"foo"}, nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", bytes.NewReader(data))
+	req.Header.Add("Content-Length", strconv.Itoa(len(data)))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", client.SnapshotExportMediaType)
+	req.Header.Set(client.SnapshotPassphraseHeader, "sekrit")
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, check.Equals, 200)
+}
+
 func (s *snapshotSuite) TestImportSnapshotError(c *check.C) {
-	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader, string) (uint64, []string, error) {
 		return uint64(0), nil, errors.New("no")
 	})()
 
@@ -400,7 +454,7 @@ func (s *snapshotSuite) TestImportSnapshotNoContentLengthError(c *check.C) {
 func (s *snapshotSuite) TestImportSnapshotLimits(c *check.C) {
 	var dataRead int
 
-	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader, passphrase string) (uint64, []string, error) {
 		data, err := ioutil.ReadAll(r)
 		c.Assert(err, check.IsNil)
 		dataRead = len(data)
@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/devicestate"
+)
+
+var systemSecurebootCmd = &Command{
+	Path:        "/v2/system-secureboot",
+	POST:        postSystemSecurebootAction,
+	WriteAccess: rootAccess{},
+}
+
+var (
+	deviceManagerPrepareSecureBootDbxUpdate  = (*devicestate.DeviceManager).PrepareSecureBootDbxUpdate
+	deviceManagerCompleteSecureBootDbxUpdate = (*devicestate.DeviceManager).CompleteSecureBootDbxUpdate
+)
+
+type securebootRequest struct {
+	Action string `json:"action,omitempty"`
+
+	// KeyDatabase is the EFI signature database the update applies to,
+	// only "DBX" is supported
+	KeyDatabase string `json:"key-database,omitempty"`
+	// Payload is the authenticated update of the signature database
+	Payload []byte `json:"payload,omitempty"`
+}
+
+func postSystemSecurebootAction(c *Command, r *http.Request, user *auth.UserState) Response {
+	var req securebootRequest
+
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		return BadRequest("cannot decode request body: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found in request body")
+	}
+
+	switch req.Action {
+	case "efi-secureboot-db-prepare":
+		return postSystemSecurebootActionDbPrepare(c, &req)
+	case "efi-secureboot-db-cleanup":
+		return postSystemSecurebootActionDbCleanup(c, &req)
+	case "":
+		return BadRequest("missing secure boot action")
+	default:
+		return BadRequest("unsupported secure boot action %q", req.Action)
+	}
+}
+
+func postSystemSecurebootActionDbPrepare(c *Command, req *securebootRequest) Response {
+	if req.KeyDatabase != "DBX" {
+		return BadRequest("unsupported key database %q", req.KeyDatabase)
+	}
+	if len(req.Payload) == 0 {
+		return BadRequest("update payload not provided")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := deviceManagerPrepareSecureBootDbxUpdate(c.d.overlord.DeviceManager(), req.Payload); err != nil {
+		return InternalError(err.Error())
+	}
+	return SyncResponse(nil)
+}
+
+func postSystemSecurebootActionDbCleanup(c *Command, req *securebootRequest) Response {
+	if req.KeyDatabase != "" || len(req.Payload) != 0 {
+		return BadRequest("unexpected key database or payload for cleanup action")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := deviceManagerCompleteSecureBootDbxUpdate(c.d.overlord.DeviceManager()); err != nil {
+		return InternalError(err.Error())
+	}
+	return SyncResponse(nil)
+}
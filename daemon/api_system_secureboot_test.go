@@ -0,0 +1,167 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+)
+
+var _ = Suite(&securebootSuite{})
+
+type securebootSuite struct {
+	apiBaseSuite
+}
+
+func (s *securebootSuite) SetUpTest(c *C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectRootAccess()
+}
+
+func (s *securebootSuite) TestPostSecurebootDbPrepare(c *C) {
+	s.daemon(c)
+
+	var updates [][]byte
+	defer daemon.MockDeviceManagerPrepareSecureBootDbxUpdate(func(update []byte) error {
+		updates = append(updates, update)
+		return nil
+	})()
+	defer daemon.MockDeviceManagerCompleteSecureBootDbxUpdate(func() error {
+		c.Fatalf("unexpected call")
+		return nil
+	})()
+
+	body := fmt.Sprintf(`{"action":"efi-secureboot-db-prepare","key-database":"DBX","payload":%q}`,
+		base64.StdEncoding.EncodeToString([]byte("dbx-update")))
+	req, err := http.NewRequest("POST", "/v2/system-secureboot", bytes.NewBufferString(body))
+	c.Assert(err, IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(updates, DeepEquals, [][]byte{[]byte("dbx-update")})
+}
+
+func (s *securebootSuite) TestPostSecurebootDbPrepareError(c *C) {
+	s.daemon(c)
+
+	defer daemon.MockDeviceManagerPrepareSecureBootDbxUpdate(func(update []byte) error {
+		return errors.New("boom")
+	})()
+
+	body := fmt.Sprintf(`{"action":"efi-secureboot-db-prepare","key-database":"DBX","payload":%q}`,
+		base64.StdEncoding.EncodeToString([]byte("dbx-update")))
+	req, err := http.NewRequest("POST", "/v2/system-secureboot", bytes.NewBufferString(body))
+	c.Assert(err, IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe, DeepEquals, daemon.InternalError("boom"))
+}
+
+func (s *securebootSuite) TestPostSecurebootDbCleanup(c *C) {
+	s.daemon(c)
+
+	called := 0
+	defer daemon.MockDeviceManagerCompleteSecureBootDbxUpdate(func() error {
+		called++
+		return nil
+	})()
+	defer daemon.MockDeviceManagerPrepareSecureBootDbxUpdate(func(update []byte) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/system-secureboot", bytes.NewBufferString(`{"action":"efi-secureboot-db-cleanup"}`))
+	c.Assert(err, IsNil)
+
+	rsp := s.syncReq(c, req, nil)
+	c.Check(rsp.Status, Equals, 200)
+	c.Check(called, Equals, 1)
+}
+
+func (s *securebootSuite) TestPostSecurebootDbCleanupError(c *C) {
+	s.daemon(c)
+
+	defer daemon.MockDeviceManagerCompleteSecureBootDbxUpdate(func() error {
+		return errors.New("boom")
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/system-secureboot", bytes.NewBufferString(`{"action":"efi-secureboot-db-cleanup"}`))
+	c.Assert(err, IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe, DeepEquals, daemon.InternalError("boom"))
+}
+
+func (s *securebootSuite) TestPostSecurebootBadRequest(c *C) {
+	s.daemon(c)
+
+	defer daemon.MockDeviceManagerPrepareSecureBootDbxUpdate(func(update []byte) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})()
+	defer daemon.MockDeviceManagerCompleteSecureBootDbxUpdate(func() error {
+		c.Fatalf("unexpected call")
+		return nil
+	})()
+
+	payload := base64.StdEncoding.EncodeToString([]byte("dbx-update"))
+	for _, tc := range []struct {
+		body string
+		err  string
+	}{
+		{`{`, `cannot decode request body: unexpected EOF`},
+		{`{}{}`, `extra content found in request body`},
+		{`{}`, `missing secure boot action`},
+		{`{"action":"foo"}`, `unsupported secure boot action "foo"`},
+		{fmt.Sprintf(`{"action":"efi-secureboot-db-prepare","payload":%q}`, payload), `unsupported key database ""`},
+		{fmt.Sprintf(`{"action":"efi-secureboot-db-prepare","key-database":"PK","payload":%q}`, payload), `unsupported key database "PK"`},
+		{`{"action":"efi-secureboot-db-prepare","key-database":"DBX"}`, `update payload not provided`},
+		{`{"action":"efi-secureboot-db-cleanup","key-database":"DBX"}`, `unexpected key database or payload for cleanup action`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/system-secureboot", bytes.NewBufferString(tc.body))
+		c.Assert(err, IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, Equals, 400, Commentf("body: %s", tc.body))
+		c.Check(rspe.Message, Equals, tc.err, Commentf("body: %s", tc.body))
+	}
+}
+
+func (s *securebootSuite) TestPostSecurebootAsUser(c *C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("POST", "/v2/system-secureboot", bytes.NewBufferString(`{"action":"efi-secureboot-db-cleanup"}`))
+	c.Assert(err, IsNil)
+
+	// being properly authorized as user is not enough, needs root
+	s.asUserAuth(c, req)
+	rec := httptest.NewRecorder()
+	s.serveHTTP(c, rec, req)
+	c.Assert(rec.Code, Equals, 403)
+}
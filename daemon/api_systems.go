@@ -21,6 +21,7 @@ package daemon
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
 
@@ -29,6 +30,8 @@ import (
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/secboot"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -130,6 +133,13 @@ func storageEncryption(encInfo *devicestate.EncryptionSupportInfo) *client.Stora
 		storageEnc.Support = client.StorageEncryptionSupportUnavailable
 		storageEnc.UnavailableReason = encInfo.UnavailableWarning
 	}
+	if encInfo.Available {
+		storageEnc.VolumesAuthModes = []client.VolumesAuthMode{client.VolumesAuthModeTPM}
+		// only LUKS key slots can be added for a passphrase
+		if encInfo.Type == secboot.EncryptionTypeLUKS {
+			storageEnc.VolumesAuthModes = append(storageEnc.VolumesAuthModes, client.VolumesAuthModePassphrase)
+		}
+	}
 
 	return storageEnc
 }
@@ -137,6 +147,7 @@ func storageEncryption(encInfo *devicestate.EncryptionSupportInfo) *client.Stora
 var (
 	devicestateInstallFinish                 = devicestate.InstallFinish
 	devicestateInstallSetupStorageEncryption = devicestate.InstallSetupStorageEncryption
+	devicestateScheduleSystemAction          = devicestate.ScheduleSystemAction
 )
 
 func getSystemDetails(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -178,6 +189,7 @@ type systemActionRequest struct {
 
 	client.SystemAction
 	client.InstallSystemOptions
+	client.SystemActionSchedule
 }
 
 func postSystemsAction(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -191,11 +203,21 @@ func postSystemsAction(c *Command, r *http.Request, user *auth.UserState) Respon
 	if decoder.More() {
 		return BadRequest("extra content found in request body")
 	}
+	if req.Time != nil || req.Window != "" {
+		switch req.Action {
+		case "do", "reboot", "shutdown":
+			return postSystemActionSchedule(c, systemLabel, &req)
+		default:
+			return BadRequest("action %q cannot be scheduled", req.Action)
+		}
+	}
 	switch req.Action {
 	case "do":
 		return postSystemActionDo(c, systemLabel, &req)
 	case "reboot":
 		return postSystemActionReboot(c, systemLabel, &req)
+	case "shutdown":
+		return postSystemActionShutdown(c, systemLabel, &req)
 	case "install":
 		return postSystemActionInstall(c, systemLabel, &req)
 	default:
@@ -229,6 +251,55 @@ func postSystemActionReboot(c *Command, systemLabel string, req *systemActionReq
 	return SyncResponse(nil)
 }
 
+// wrapped for unit tests
+var deviceManagerShutdown = func(dm *devicestate.DeviceManager, systemLabel, mode string) error {
+	return dm.Shutdown(systemLabel, mode)
+}
+
+func postSystemActionShutdown(c *Command, systemLabel string, req *systemActionRequest) Response {
+	dm := c.d.overlord.DeviceManager()
+	if err := deviceManagerShutdown(dm, systemLabel, req.Mode); err != nil {
+		return handleSystemActionErr(err, systemLabel)
+	}
+	return SyncResponse(nil)
+}
+
+func postSystemActionSchedule(c *Command, systemLabel string, req *systemActionRequest) Response {
+	if req.Time != nil && req.Window != "" {
+		return BadRequest("cannot use both time and window to schedule a system action")
+	}
+	var sched devicestate.SystemActionSchedule
+	if req.Time != nil {
+		sched.At = *req.Time
+	} else {
+		sched.Window = req.Window
+	}
+	action := &devicestate.ScheduledSystemAction{
+		Action:      req.Action,
+		SystemLabel: systemLabel,
+		Mode:        req.Mode,
+		Title:       req.Title,
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg, err := devicestateScheduleSystemAction(st, action, sched)
+	if err != nil {
+		var cce *snapstate.ChangeConflictError
+		if errors.As(err, &cce) {
+			return SnapChangeConflict(cce)
+		}
+		if os.IsNotExist(err) || err == devicestate.ErrUnsupportedAction {
+			return handleSystemActionErr(err, systemLabel)
+		}
+		return BadRequest("cannot schedule system action: %v", err)
+	}
+	ensureStateSoon(st)
+	return AsyncResponse(nil, chg.ID())
+}
+
 func postSystemActionDo(c *Command, systemLabel string, req *systemActionRequest) Response {
 	if systemLabel == "" {
 		return BadRequest("system action requires the system label to be provided")
@@ -254,13 +325,24 @@ func postSystemActionInstall(c *Command, systemLabel string, req *systemActionRe
 
 	switch req.Step {
 	case client.InstallStepSetupStorageEncryption:
-		chg, err := devicestateInstallSetupStorageEncryption(st, systemLabel, req.OnVolumes)
+		opts := &devicestate.InstallEncryptionOptions{
+			Type: secboot.EncryptionType(req.EncryptionType),
+		}
+		if req.VolumesAuth != nil {
+			opts.VolumesAuthMode = devicestate.VolumesAuthMode(req.VolumesAuth.Mode)
+			opts.Passphrase = req.VolumesAuth.Passphrase
+		}
+		chg, err := devicestateInstallSetupStorageEncryption(st, systemLabel, req.OnVolumes, opts)
 		if err != nil {
 			return BadRequest("cannot setup storage encryption for install from %q: %v", systemLabel, err)
 		}
 		ensureStateSoon(st)
 		return AsyncResponse(nil, chg.ID())
 	case client.InstallStepFinish:
+		// the choices about encryption are made when setting it up
+		if req.EncryptionType != "" || req.VolumesAuth != nil {
+			return BadRequest("encryption options can only be used with the %q install step", client.InstallStepSetupStorageEncryption)
+		}
 		chg, err := devicestateInstallFinish(st, systemLabel, req.OnVolumes)
 		if err != nil {
 			return BadRequest("cannot finish install for %q: %v", systemLabel, err)
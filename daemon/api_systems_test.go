@@ -48,6 +48,7 @@ import (
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/secboot"
@@ -582,11 +583,21 @@ func (s *systemsSuite) TestSystemActionRequestWithSeeded(c *check.C) {
 				"type":        "sync",
 			}
 			if tc.expRestart {
+				// the planned time of the restart is not
+				// predictable, check it separately
+				maint, _ := rspBody["maintenance"].(map[string]interface{})
+				value, _ := maint["value"].(map[string]interface{})
+				plannedTime, _ := value["planned-time"].(string)
+				_, err := time.Parse(time.RFC3339, plannedTime)
+				c.Check(err, check.IsNil, check.Commentf(tc.comment))
+				delete(value, "planned-time")
+
 				expResp["maintenance"] = map[string]interface{}{
 					"kind":    "system-restart",
 					"message": "system is restarting",
 					"value": map[string]interface{}{
-						"op": "reboot",
+						"op":     "reboot",
+						"reason": "system-action",
 					},
 				}
 
@@ -810,36 +821,41 @@ func (s *systemsSuite) TestSystemsGetSystemDetailsForLabel(c *check.C) {
 
 		expectedSupport                                  client.StorageEncryptionSupport
 		expectedStorageSafety, expectedUnavailableReason string
+		expectedVolumesAuthModes                         []client.VolumesAuthMode
 	}{
 		{
 			true, false, asserts.StorageSafetyPreferEncrypted, "", "", "",
-			client.StorageEncryptionSupportDisabled, "", "",
+			client.StorageEncryptionSupportDisabled, "", "", nil,
 		},
 		{
 			false, false, asserts.StorageSafetyPreferEncrypted, "", "", "unavailable-warn",
-			client.StorageEncryptionSupportUnavailable, "prefer-encrypted", "unavailable-warn",
+			client.StorageEncryptionSupportUnavailable, "prefer-encrypted", "unavailable-warn", nil,
 		},
 		{
 			false, true, asserts.StorageSafetyPreferEncrypted, "cryptsetup", "", "",
 			client.StorageEncryptionSupportAvailable, "prefer-encrypted", "",
+			[]client.VolumesAuthMode{"tpm", "passphrase"},
 		},
 		{
 			false, true, asserts.StorageSafetyPreferUnencrypted, "cryptsetup", "", "",
 			client.StorageEncryptionSupportAvailable, "prefer-unencrypted", "",
+			[]client.VolumesAuthMode{"tpm", "passphrase"},
 		},
 		{
 			false, false, asserts.StorageSafetyEncrypted, "", "unavailable-err", "",
-			client.StorageEncryptionSupportDefective, "encrypted", "unavailable-err",
+			client.StorageEncryptionSupportDefective, "encrypted", "unavailable-err", nil,
 		},
 		{
-			false, true, asserts.StorageSafetyEncrypted, "", "", "",
+			false, true, asserts.StorageSafetyEncrypted, "device-setup-hook", "", "",
 			client.StorageEncryptionSupportAvailable, "encrypted", "",
+			[]client.VolumesAuthMode{"tpm"},
 		},
 	} {
 		mockEncryptionSupportInfo := &devicestate.EncryptionSupportInfo{
 			Available:          tc.available,
 			Disabled:           tc.disabled,
 			StorageSafety:      tc.storageSafety,
+			Type:               tc.typ,
 			UnavailableErr:     errors.New(tc.unavailableErr),
 			UnavailableWarning: tc.unavailableWarning,
 		}
@@ -873,7 +889,9 @@ func (s *systemsSuite) TestSystemsGetSystemDetailsForLabel(c *check.C) {
 			StorageEncryption: &client.StorageEncryption{
 				Support:           tc.expectedSupport,
 				StorageSafety:     tc.expectedStorageSafety,
+				Type:              string(tc.typ),
 				UnavailableReason: tc.expectedUnavailableReason,
+				VolumesAuthModes:  tc.expectedVolumesAuthModes,
 			},
 			Volumes: mockGadgetInfo.Volumes,
 		}, check.Commentf("%v", tc))
@@ -1047,7 +1065,108 @@ func (s *systemsSuite) TestSystemsGetSpecificLabelIntegration(c *check.C) {
 }
 
 func (s *systemsSuite) TestSystemInstallActionSetupStorageEncryptionCallsDevicestate(c *check.C) {
-	s.testSystemInstallActionCallsDevicestate(c, "setup-storage-encryption", daemon.MockDevicestateInstallSetupStorageEncryption)
+	mocker := func(f func(st *state.State, label string, onVolumes map[string]*gadget.Volume) (*state.Change, error)) (restore func()) {
+		return daemon.MockDevicestateInstallSetupStorageEncryption(func(st *state.State, label string, onVolumes map[string]*gadget.Volume, opts *devicestate.InstallEncryptionOptions) (*state.Change, error) {
+			c.Check(opts, check.DeepEquals, &devicestate.InstallEncryptionOptions{})
+			return f(st, label, onVolumes)
+		})
+	}
+	s.testSystemInstallActionCallsDevicestate(c, "setup-storage-encryption", mocker)
+}
+
+func (s *systemsSuite) TestSystemInstallActionSetupStorageEncryptionWithOptions(c *check.C) {
+	s.daemon(c)
+
+	nCalls := 0
+	r := daemon.MockDevicestateInstallSetupStorageEncryption(func(st *state.State, label string, onVolumes map[string]*gadget.Volume, opts *devicestate.InstallEncryptionOptions) (*state.Change, error) {
+		nCalls++
+		c.Check(label, check.Equals, "20191119")
+		c.Check(opts, check.DeepEquals, &devicestate.InstallEncryptionOptions{
+			Type:            secboot.EncryptionTypeLUKS,
+			VolumesAuthMode: devicestate.VolumesAuthModePassphrase,
+			Passphrase:      "secret",
+		})
+		return st.NewChange("foo", "..."), nil
+	})
+	defer r()
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {})
+	defer restore()
+
+	body := map[string]interface{}{
+		"action": "install",
+		"step":   "setup-storage-encryption",
+		"on-volumes": map[string]interface{}{
+			"pc": map[string]interface{}{
+				"bootloader": "grub",
+			},
+		},
+		"encryption-type": "cryptsetup",
+		"volumes-auth": map[string]interface{}{
+			"mode":       "passphrase",
+			"passphrase": "secret",
+		},
+	}
+	b, err := json.Marshal(body)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/systems/20191119", bytes.NewBuffer(b))
+	c.Assert(err, check.IsNil)
+
+	s.asyncReq(c, req, nil)
+	c.Check(nCalls, check.Equals, 1)
+}
+
+func (s *systemsSuite) TestSystemInstallActionSetupStorageEncryptionInvalidOptions(c *check.C) {
+	s.daemon(c)
+
+	body := map[string]interface{}{
+		"action": "install",
+		"step":   "setup-storage-encryption",
+		"on-volumes": map[string]interface{}{
+			"pc": map[string]interface{}{
+				"bootloader": "grub",
+			},
+		},
+		"volumes-auth": map[string]interface{}{
+			"mode": "passphrase",
+		},
+	}
+	b, err := json.Marshal(body)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/systems/20191119", bytes.NewBuffer(b))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `cannot setup storage encryption for install from "20191119": cannot setup storage encryption: "passphrase" volumes authentication mode requires a passphrase`)
+}
+
+func (s *systemsSuite) TestSystemInstallActionFinishEncryptionOptionsError(c *check.C) {
+	s.daemon(c)
+
+	r := daemon.MockDevicestateInstallFinish(func(*state.State, string, map[string]*gadget.Volume) (*state.Change, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer r()
+
+	body := map[string]interface{}{
+		"action": "install",
+		"step":   "finish",
+		"on-volumes": map[string]interface{}{
+			"pc": map[string]interface{}{
+				"bootloader": "grub",
+			},
+		},
+		"encryption-type": "cryptsetup",
+	}
+	b, err := json.Marshal(body)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/systems/20191119", bytes.NewBuffer(b))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, `encryption options can only be used with the "setup-storage-encryption" install step`)
 }
 
 func (s *systemsSuite) TestSystemInstallActionFinishCallsDevicestate(c *check.C) {
@@ -1197,3 +1316,158 @@ func (s *systemsSuite) TestSystemInstallActionError(c *check.C) {
 	rspe := s.errorReq(c, req, nil)
 	c.Check(rspe.Error(), check.Equals, `unsupported install step "unknown-install-step" (api)`)
 }
+
+func (s *systemsSuite) TestSystemShutdownHappy(c *check.C) {
+	s.daemon(c)
+
+	called := 0
+	restore := daemon.MockDeviceManagerShutdown(func(dm *devicestate.DeviceManager, systemLabel, mode string) error {
+		called++
+		c.Check(dm, check.NotNil)
+		c.Check(systemLabel, check.Equals, "20200101")
+		c.Check(mode, check.Equals, "recover")
+		return nil
+	})
+	defer restore()
+
+	body := `{"action":"shutdown", "mode":"recover"}`
+	req, err := http.NewRequest("POST", "/v2/systems/20200101", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	s.asRootAuth(req)
+
+	rec := httptest.NewRecorder()
+	s.serveHTTP(c, rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(called, check.Equals, 1)
+}
+
+func (s *systemsSuite) TestSystemActionScheduleCallsDevicestate(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+
+	soon := 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		soon++
+	})
+	defer restore()
+
+	restore = daemon.MockDeviceManagerReboot(func(dm *devicestate.DeviceManager, systemLabel, mode string) error {
+		c.Fatalf("reboot should not get called")
+		return nil
+	})
+	defer restore()
+
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		body          string
+		label         string
+		expectedSched devicestate.SystemActionSchedule
+		expected      devicestate.ScheduledSystemAction
+	}{{
+		body:          `{"action":"reboot", "mode":"recover", "time":"2030-01-02T03:04:05Z"}`,
+		expectedSched: devicestate.SystemActionSchedule{At: at},
+		expected:      devicestate.ScheduledSystemAction{Action: "reboot", Mode: "recover"},
+	}, {
+		body:          `{"action":"shutdown", "mode":"factory-reset", "window":"sat,02:00-04:00"}`,
+		label:         "20200101",
+		expectedSched: devicestate.SystemActionSchedule{Window: "sat,02:00-04:00"},
+		expected:      devicestate.ScheduledSystemAction{Action: "shutdown", SystemLabel: "20200101", Mode: "factory-reset"},
+	}, {
+		body:          `{"action":"do", "title":"reinstall", "mode":"install", "time":"2030-01-02T03:04:05Z"}`,
+		label:         "20200101",
+		expectedSched: devicestate.SystemActionSchedule{At: at},
+		expected:      devicestate.ScheduledSystemAction{Action: "do", SystemLabel: "20200101", Mode: "install", Title: "reinstall"},
+	}} {
+		nCalls := 0
+		r := daemon.MockDevicestateScheduleSystemAction(func(st *state.State, action *devicestate.ScheduledSystemAction, sched devicestate.SystemActionSchedule) (*state.Change, error) {
+			nCalls++
+			c.Check(*action, check.DeepEquals, tc.expected)
+			c.Check(sched.At.Equal(tc.expectedSched.At), check.Equals, true)
+			c.Check(sched.Window, check.Equals, tc.expectedSched.Window)
+			return st.NewChange("scheduled-system-action", "..."), nil
+		})
+		defer r()
+
+		url := "/v2/systems"
+		if tc.label != "" {
+			url += "/" + tc.label
+		}
+		req, err := http.NewRequest("POST", url, strings.NewReader(tc.body))
+		c.Assert(err, check.IsNil)
+
+		rsp := s.asyncReq(c, req, nil)
+
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		st.Unlock()
+		c.Check(chg, check.NotNil)
+		c.Check(nCalls, check.Equals, 1)
+	}
+	c.Check(soon, check.Equals, 3)
+}
+
+func (s *systemsSuite) TestSystemActionScheduleErrors(c *check.C) {
+	s.daemon(c)
+
+	r := daemon.MockDevicestateScheduleSystemAction(func(st *state.State, action *devicestate.ScheduledSystemAction, sched devicestate.SystemActionSchedule) (*state.Change, error) {
+		if action.Mode == "conflict" {
+			return nil, &snapstate.ChangeConflictError{
+				ChangeKind: "scheduled-system-action",
+				Message:    "cannot schedule system action, another one is already scheduled",
+				ChangeID:   "42",
+			}
+		}
+		switch action.SystemLabel {
+		case "unknown-system":
+			return nil, os.ErrNotExist
+		case "1234":
+			return nil, devicestate.ErrUnsupportedAction
+		}
+		return nil, fmt.Errorf("boom")
+	})
+	defer r()
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		err    string
+		label  string
+	}{{
+		body:   `{"action":"install", "window":"sat,02:00-04:00"}`,
+		status: 400,
+		err:    `action "install" cannot be scheduled`,
+	}, {
+		body:   `{"action":"reboot", "time":"2030-01-02T03:04:05Z", "window":"sat,02:00-04:00"}`,
+		status: 400,
+		err:    `cannot use both time and window to schedule a system action`,
+	}, {
+		body:   `{"action":"reboot", "time":"2030-01-02T03:04:05Z"}`,
+		status: 400,
+		err:    `cannot schedule system action: boom`,
+	}, {
+		body:   `{"action":"reboot", "mode":"conflict", "time":"2030-01-02T03:04:05Z"}`,
+		status: 409,
+		err:    `cannot schedule system action, another one is already scheduled`,
+	}, {
+		body:   `{"action":"reboot", "mode":"run", "time":"2030-01-02T03:04:05Z"}`,
+		status: 404,
+		err:    `requested seed system "unknown-system" does not exist`,
+		label:  "unknown-system",
+	}, {
+		body:   `{"action":"reboot", "mode":"foo", "time":"2030-01-02T03:04:05Z"}`,
+		status: 400,
+		err:    `requested action is not supported by system "1234"`,
+		label:  "1234",
+	}} {
+		url := "/v2/systems"
+		if tc.label != "" {
+			url += "/" + tc.label
+		}
+		req, err := http.NewRequest("POST", url, strings.NewReader(tc.body))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, tc.status)
+		c.Check(rspe.Message, check.Equals, tc.err)
+	}
+}
@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+)
+
+var tryWatchesCmd = &Command{
+	Path:        "/v2/try-watches",
+	GET:         getTryWatches,
+	POST:        postTryWatches,
+	ReadAccess:  openAccess{},
+	WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
+}
+
+// tryWatchJSON describes a snap tried from a watched directory.
+type tryWatchJSON struct {
+	Snap     string `json:"snap"`
+	Path     string `json:"path"`
+	DevMode  bool   `json:"devmode,omitempty"`
+	JailMode bool   `json:"jailmode,omitempty"`
+	Classic  bool   `json:"classic,omitempty"`
+	// Since is when the directory started being watched.
+	Since time.Time `json:"since"`
+	// LastRetry is when the snap was last tried again because the
+	// contents of the directory changed.
+	LastRetry  *time.Time `json:"last-retry,omitempty"`
+	LastChange string     `json:"last-change,omitempty"`
+}
+
+func getTryWatches(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	watches, err := snapstate.TryWatches(st)
+	if err != nil {
+		return InternalError("cannot get watched tried snaps: %v", err)
+	}
+	watchesJSON := make([]tryWatchJSON, 0, len(watches))
+	for _, w := range watches {
+		wj := tryWatchJSON{
+			Snap:       w.Snap,
+			Path:       w.Path,
+			DevMode:    w.Flags.DevMode,
+			JailMode:   w.Flags.JailMode,
+			Classic:    w.Flags.Classic,
+			Since:      w.Since,
+			LastChange: w.LastChange,
+		}
+		if !w.LastRetry.IsZero() {
+			lastRetry := w.LastRetry
+			wj.LastRetry = &lastRetry
+		}
+		watchesJSON = append(watchesJSON, wj)
+	}
+	return SyncResponse(watchesJSON)
+}
+
+type tryWatchesAction struct {
+	Action string `json:"action"`
+	Snap   string `json:"snap"`
+}
+
+func postTryWatches(c *Command, r *http.Request, user *auth.UserState) Response {
+	var action tryWatchesAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&action); err != nil {
+		return BadRequest("cannot decode request body into try watches action: %v", err)
+	}
+	if action.Action != "stop" {
+		return BadRequest("unsupported try watches action: %q", action.Action)
+	}
+	if action.Snap == "" {
+		return BadRequest("snap name is required")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if err := snapstate.UnwatchTry(st, action.Snap); err != nil {
+		if errors.Is(err, snapstate.ErrTryNotWatched) {
+			return NotFound("snap %q is not tried from a watched directory", action.Snap)
+		}
+		return InternalError("cannot stop watching the directory of snap %q: %v", action.Snap, err)
+	}
+	ensureStateSoon(st)
+	return SyncResponse(nil)
+}
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = check.Suite(&tryWatchesSuite{})
+
+type tryWatchesSuite struct {
+	apiBaseSuite
+}
+
+func (s *tryWatchesSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	s.expectReadAccess(daemon.OpenAccess{})
+	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
+}
+
+func (s *tryWatchesSuite) mockTryWatches(c *check.C, st *state.State) {
+	st.Lock()
+	defer st.Unlock()
+	st.Set("try-watches", map[string]*snapstate.TryWatch{
+		"foo": {
+			Snap:       "foo",
+			Path:       "/home/user/foo/prime",
+			Flags:      snapstate.Flags{DevMode: true},
+			Since:      time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+			LastRetry:  time.Date(2022, 6, 1, 12, 30, 0, 0, time.UTC),
+			LastChange: "42",
+		},
+		"bar": {
+			Snap:  "bar",
+			Path:  "/home/user/bar/prime",
+			Since: time.Date(2022, 6, 1, 13, 0, 0, 0, time.UTC),
+		},
+	})
+}
+
+func (s *tryWatchesSuite) TestGetTryWatches(c *check.C) {
+	d := s.daemon(c)
+	s.mockTryWatches(c, d.Overlord().State())
+
+	req, err := http.NewRequest("GET", "/v2/try-watches", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+
+	// check the JSON as seen by clients
+	b, err := json.Marshal(rsp.Result)
+	c.Assert(err, check.IsNil)
+	var l []interface{}
+	c.Assert(json.Unmarshal(b, &l), check.IsNil)
+	c.Check(l, check.DeepEquals, []interface{}{
+		map[string]interface{}{
+			"snap":  "bar",
+			"path":  "/home/user/bar/prime",
+			"since": "2022-06-01T13:00:00Z",
+		},
+		map[string]interface{}{
+			"snap":        "foo",
+			"path":        "/home/user/foo/prime",
+			"devmode":     true,
+			"since":       "2022-06-01T12:00:00Z",
+			"last-retry":  "2022-06-01T12:30:00Z",
+			"last-change": "42",
+		},
+	})
+}
+
+func (s *tryWatchesSuite) TestGetTryWatchesNone(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/try-watches", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.HasLen, 0)
+}
+
+func (s *tryWatchesSuite) TestStopTryWatch(c *check.C) {
+	d := s.daemon(c)
+	st := d.Overlord().State()
+	s.mockTryWatches(c, st)
+
+	soon := 0
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {
+		soon++
+	})
+	defer restore()
+
+	req, err := http.NewRequest("POST", "/v2/try-watches", bytes.NewBufferString(`{"action":"stop","snap":"foo"}`))
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(soon, check.Equals, 1)
+
+	st.Lock()
+	defer st.Unlock()
+	watches, err := snapstate.TryWatches(st)
+	c.Assert(err, check.IsNil)
+	c.Assert(watches, check.HasLen, 1)
+	c.Check(watches[0].Snap, check.Equals, "bar")
+}
+
+func (s *tryWatchesSuite) TestStopTryWatchErrors(c *check.C) {
+	d := s.daemon(c)
+	s.mockTryWatches(c, d.Overlord().State())
+
+	for _, t := range []struct {
+		body   string
+		status int
+		msg    string
+	}{
+		{`{"action":"stop","snap":"baz"}`, 404, `snap "baz" is not tried from a watched directory`},
+		{`{"action":"start","snap":"foo"}`, 400, `unsupported try watches action: "start"`},
+		{`{"action":"stop"}`, 400, `snap name is required`},
+		{`}`, 400, `cannot decode request body into try watches action: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/try-watches", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil)
+		c.Check(rspe.Status, check.Equals, t.status, check.Commentf(t.body))
+		c.Check(rspe.Message, check.Matches, t.msg, check.Commentf(t.body))
+	}
+}
@@ -0,0 +1,148 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/randutil"
+)
+
+// requestIDHeader is the response header carrying the correlation id
+// of an API request.
+const requestIDHeader = "X-Snapd-Request-Id"
+
+const (
+	// maxAuditRecords is the number of API audit records kept in the
+	// state.
+	maxAuditRecords = 500
+	// maxAuditRecordAge is how long API audit records are kept in the
+	// state.
+	maxAuditRecordAge = 7 * 24 * time.Hour
+)
+
+var timeNow = time.Now
+
+type requestIDKey struct{}
+
+func newRequestID() string {
+	return randutil.RandomString(16)
+}
+
+// withRequestID returns a copy of the request whose context carries a
+// new correlation id, and sets the id on the response headers.
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := newRequestID()
+	w.Header().Set(requestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// requestID returns the correlation id of the request, if any.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// auditRecord tracks who issued a state-changing API request and what
+// came out of it.
+type auditRecord struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	// UID and PID identify the requester, when known from the
+	// peer credentials.
+	UID *uint32 `json:"uid,omitempty"`
+	PID int32   `json:"pid,omitempty"`
+	// Snap is the snap the requester runs as, if any.
+	Snap   string `json:"snap,omitempty"`
+	Status int    `json:"status-code"`
+	// ChangeID is the change created by the request, if any.
+	ChangeID string `json:"change-id,omitempty"`
+}
+
+// isAudited returns whether the request to the given command should be
+// audited. Only state-changing requests are, but not those done by
+// snaps through snapctl, which are frequent and act on the snap itself.
+func isAudited(c *Command, r *http.Request) bool {
+	return r.Method != "GET" && c.Path != snapctlCmd.Path
+}
+
+func newAuditRecord(r *http.Request, ucred *ucrednet) *auditRecord {
+	rec := &auditRecord{
+		ID:     requestID(r),
+		Time:   timeNow(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+	}
+	if ucred != nil {
+		uid := ucred.Uid
+		rec.UID = &uid
+		rec.PID = ucred.Pid
+		// requests not coming from a snap are the common case
+		if snapName, err := cgroupSnapNameFromPid(int(ucred.Pid)); err == nil {
+			rec.Snap = snapName
+		}
+	}
+	return rec
+}
+
+// auditRecords returns the most recent audit records, oldest first.
+func auditRecords(st *state.State) ([]*auditRecord, error) {
+	var records []*auditRecord
+	if err := st.Get("api-audit", &records); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	return records, nil
+}
+
+// recordAudit stores the audit record in the state, and links the
+// change created by the request, if any, back to it.
+func recordAudit(st *state.State, rec *auditRecord) {
+	st.Lock()
+	defer st.Unlock()
+
+	if rec.ChangeID != "" {
+		if chg := st.Change(rec.ChangeID); chg != nil {
+			chg.Set("api-request-id", rec.ID)
+		}
+	}
+
+	records, err := auditRecords(st)
+	if err != nil {
+		logger.Noticef("cannot record audit of API request %s: %v", rec.ID, err)
+		return
+	}
+	// drop the records which expired, they are kept oldest first
+	cutoff := rec.Time.Add(-maxAuditRecordAge)
+	for len(records) > 0 && records[0].Time.Before(cutoff) {
+		records = records[1:]
+	}
+	records = append(records, rec)
+	if len(records) > maxAuditRecords {
+		records = records[len(records)-maxAuditRecords:]
+	}
+	st.Set("api-audit", records)
+}
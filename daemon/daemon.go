@@ -55,6 +55,8 @@ var ErrRestartSocket = fmt.Errorf("daemon stop requested to wait for socket acti
 
 var systemdSdNotify = systemd.SdNotify
 
+var netutilWatchMeteredConnection = netutil.WatchMeteredConnection
+
 const (
 	daemonRestartMsg  = "daemon is restarting"
 	systemRestartMsg  = "system is restarting"
@@ -88,6 +90,10 @@ type Daemon struct {
 
 	expectedRebootDidNotHappen bool
 
+	// stopMeteredWatch stops following the metered state of the
+	// network connection
+	stopMeteredWatch func()
+
 	mu sync.Mutex
 }
 
@@ -130,9 +136,28 @@ func (c *Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if requestID(r) == "" {
+		r = withRequestID(w, r)
+	}
 	ctx := store.WithClientUserAgent(r.Context(), r)
 	r = r.WithContext(ctx)
 
+	// state-changing requests are audited, together with the change
+	// they create, if any
+	var audit *auditRecord
+	if isAudited(c, r) {
+		audit = newAuditRecord(r, ucred)
+		aw := &wrappedWriter{w: w}
+		w = aw
+		defer func() {
+			audit.Status = aw.s
+			if audit.Status == 0 {
+				audit.Status = http.StatusOK
+			}
+			recordAudit(st, audit)
+		}()
+	}
+
 	var rspf ResponseFunc
 	var access accessChecker
 
@@ -162,11 +187,15 @@ func (c *Command) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if srsp, ok := rsp.(StructuredResponse); ok {
 		rjson := srsp.JSON()
+		if audit != nil {
+			audit.ChangeID = rjson.Change
+		}
 
 		st.Lock()
 		_, rst := restart.Pending(st)
+		maint := restart.Maintenance(st)
 		st.Unlock()
-		rjson.addMaintenanceFromRestartType(rst)
+		rjson.addMaintenanceFromRestartType(rst, maint)
 
 		if rjson.Type != ResponseTypeError {
 			st.Lock()
@@ -209,12 +238,13 @@ func (w *wrappedWriter) Flush() {
 func logit(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ww := &wrappedWriter{w: w}
+		r = withRequestID(ww, r)
 		t0 := time.Now()
 		handler.ServeHTTP(ww, r)
 		t := time.Since(t0)
 		url := r.URL.String()
 		if !strings.Contains(url, "/changes/") {
-			logger.Debugf("%s %s %s %s %d", r.RemoteAddr, r.Method, r.URL, t, ww.s)
+			logger.Debugf("%s %s %s %s %s %d", requestID(r), r.RemoteAddr, r.Method, r.URL, t, ww.s)
 		}
 	})
 }
@@ -330,6 +360,11 @@ func (d *Daemon) Start() error {
 		panic("internal error: no Overlord")
 	}
 
+	canary, err := d.snapdOnProbation()
+	if err != nil {
+		return err
+	}
+
 	to, reasoning, err := d.overlord.StartupTimeout()
 	if err != nil {
 		return err
@@ -340,11 +375,20 @@ func (d *Daemon) Start() error {
 		logger.Noticef("adjusting startup timeout by %v (%s)", to, reasoning)
 		systemdSdNotify(fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d", us))
 	}
-	// now perform expensive overlord/manages initiliazation
-	if err := d.overlord.StartUp(); err != nil {
+	// now perform expensive overlord/manages initiliazation, what
+	// can be deferred is done by the overlord loop while serving
+	if err := d.overlord.LazyStartUp(); err != nil {
 		return err
 	}
 
+	// follow the metered state of the network connection rather than
+	// querying it whenever an auto-refresh is considered
+	if stop, err := netutilWatchMeteredConnection(); err != nil {
+		logger.Debugf("cannot watch the metered state of the network connection: %v", err)
+	} else {
+		d.stopMeteredWatch = stop
+	}
+
 	d.connTracker = &connTracker{conns: make(map[net.Conn]struct{})}
 	d.serve = &http.Server{
 		Handler:   logit(d.router),
@@ -357,7 +401,7 @@ func (d *Daemon) Start() error {
 	// before serving actual connections remove the maintenance.json file as we
 	// are no longer down for maintenance, this state most closely corresponds
 	// to restart.RestartUnset
-	if err := d.updateMaintenanceFile(restart.RestartUnset); err != nil {
+	if err := d.updateMaintenanceFile(restart.RestartUnset, nil); err != nil {
 		return err
 	}
 
@@ -382,6 +426,11 @@ func (d *Daemon) Start() error {
 		return nil
 	})
 
+	// a refreshed snapd needs to test itself before it is trusted
+	if canary != nil {
+		d.startSelfTest(canary)
+	}
+
 	// notify systemd that we are ready
 	systemdSdNotify("READY=1")
 	return nil
@@ -440,7 +489,7 @@ var (
 	rebootMaxTentatives    = 3
 )
 
-func (d *Daemon) updateMaintenanceFile(rst restart.RestartType) error {
+func (d *Daemon) updateMaintenanceFile(rst restart.RestartType, maint *restart.PlannedMaintenance) error {
 	// for unset restart, just remove the maintenance.json file
 	if rst == restart.RestartUnset {
 		err := os.Remove(dirs.SnapdMaintenanceFile)
@@ -453,7 +502,7 @@ func (d *Daemon) updateMaintenanceFile(rst restart.RestartType) error {
 	}
 
 	// otherwise marshal and write it out appropriately
-	b, err := json.Marshal(maintenanceForRestartType(rst))
+	b, err := json.Marshal(maintenanceForRestartType(rst, maint))
 	if err != nil {
 		return err
 	}
@@ -500,10 +549,14 @@ func (d *Daemon) Stop(sigCh chan<- os.Signal) error {
 	rebootInfo := d.rebootInfo
 	d.mu.Unlock()
 
+	d.state.Lock()
+	maint := restart.Maintenance(d.state)
+	d.state.Unlock()
+
 	// before not accepting any new client connections we need to write the
 	// maintenance.json file for potential clients to see after the daemon stops
 	// responding so they can read it correctly and handle the maintenance
-	if err := d.updateMaintenanceFile(d.requestedRestart); err != nil {
+	if err := d.updateMaintenanceFile(d.requestedRestart, maint); err != nil {
 		logger.Noticef("error writing maintenance file: %v", err)
 	}
 
@@ -558,6 +611,11 @@ func (d *Daemon) Stop(sigCh chan<- os.Signal) error {
 	}
 	d.overlord.Stop()
 
+	if d.stopMeteredWatch != nil {
+		d.stopMeteredWatch()
+		d.stopMeteredWatch = nil
+	}
+
 	if err := d.tomb.Wait(); err != nil {
 		if err == context.DeadlineExceeded {
 			logger.Noticef("WARNING: cannot gracefully shut down in-flight snapd API activity within: %v", shutdownTimeout)
@@ -598,20 +656,13 @@ func (d *Daemon) rebootDelay(immediate bool) (time.Duration, error) {
 	if err != nil && !errors.Is(err, state.ErrNoState) {
 		return 0, err
 	}
-	rebootDelay := 1 * time.Minute
+	rebootDelay := restart.SystemRestartDelay()
 	if immediate {
 		rebootDelay = 0
 	}
 	if err == nil {
 		rebootDelay = rebootAt.Sub(now)
 	} else {
-		ovr := os.Getenv("SNAPD_REBOOT_DELAY") // for tests
-		if ovr != "" && !immediate {
-			d, err := time.ParseDuration(ovr)
-			if err == nil {
-				rebootDelay = d
-			}
-		}
 		rebootAt = now.Add(rebootDelay)
 		d.state.Set("daemon-system-restart-at", rebootAt)
 	}
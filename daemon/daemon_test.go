@@ -39,6 +39,7 @@ import (
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/netutil"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -83,10 +84,14 @@ func (s *daemonSuite) SetUpTest(c *check.C) {
 	}
 	s.notified = nil
 	s.AddCleanup(ifacestate.MockSecurityBackends(nil))
+	netutilWatchMeteredConnection = func() (func(), error) {
+		return nil, fmt.Errorf("no network manager")
+	}
 }
 
 func (s *daemonSuite) TearDownTest(c *check.C) {
 	systemdSdNotify = systemd.SdNotify
+	netutilWatchMeteredConnection = netutil.WatchMeteredConnection
 	dirs.SetRootDir("")
 	s.authorized = false
 	s.err = nil
@@ -217,6 +222,163 @@ func (s *daemonSuite) TestCommandMethodDispatchRoot(c *check.C) {
 	c.Check(rec.Code, check.Equals, 405)
 }
 
+func (s *daemonSuite) TestCommandAuditsStateChangingRequests(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.Overlord().State()
+
+	restore := MockCgroupSnapNameFromPid(func(pid int) (string, error) {
+		c.Check(pid, check.Equals, 100)
+		return "some-snap", nil
+	})
+	defer restore()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	oldTimeNow := timeNow
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = oldTimeNow }()
+
+	var chgID string
+	cmd := &Command{d: d}
+	cmd.GET = func(*Command, *http.Request, *auth.UserState) Response {
+		return SyncResponse(nil)
+	}
+	cmd.POST = func(_ *Command, r *http.Request, _ *auth.UserState) Response {
+		c.Check(requestID(r), check.Not(check.Equals), "")
+		st.Lock()
+		defer st.Unlock()
+		chg := st.NewChange("foo", "...")
+		chgID = chg.ID()
+		return AsyncResponse(nil, chgID)
+	}
+	cmd.ReadAccess = openAccess{}
+	cmd.WriteAccess = openAccess{}
+
+	// reads are not audited
+	req, err := http.NewRequest("GET", "/v2/foo", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=42;socket=%s;", dirs.SnapdSocket)
+	rec := httptest.NewRecorder()
+	cmd.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rec.Header().Get("X-Snapd-Request-Id"), check.Not(check.Equals), "")
+
+	req, err = http.NewRequest("POST", "/v2/foo", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=42;socket=%s;", dirs.SnapdSocket)
+	rec = httptest.NewRecorder()
+	cmd.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 202)
+	id := rec.Header().Get("X-Snapd-Request-Id")
+	c.Check(id, check.HasLen, 16)
+
+	st.Lock()
+	defer st.Unlock()
+	records, err := auditRecords(st)
+	c.Assert(err, check.IsNil)
+	uid := uint32(42)
+	c.Check(records, check.DeepEquals, []*auditRecord{{
+		ID:       id,
+		Time:     now,
+		Method:   "POST",
+		Path:     "/v2/foo",
+		UID:      &uid,
+		PID:      100,
+		Snap:     "some-snap",
+		Status:   202,
+		ChangeID: chgID,
+	}})
+
+	var reqID string
+	c.Assert(st.Change(chgID).Get("api-request-id", &reqID), check.IsNil)
+	c.Check(reqID, check.Equals, id)
+}
+
+func (s *daemonSuite) TestCommandAuditsDeniedRequests(c *check.C) {
+	d := newTestDaemon(c)
+
+	cmd := &Command{d: d}
+	cmd.POST = func(*Command, *http.Request, *auth.UserState) Response {
+		c.Fatalf("unexpected call")
+		return nil
+	}
+	cmd.WriteAccess = rootAccess{}
+
+	req, err := http.NewRequest("POST", "/v2/foo", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	// no ucred => forbidden
+	cmd.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 403)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	records, err := auditRecords(st)
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.HasLen, 1)
+	c.Check(records[0].ID, check.Equals, rec.Header().Get("X-Snapd-Request-Id"))
+	c.Check(records[0].UID, check.IsNil)
+	c.Check(records[0].Status, check.Equals, 403)
+	c.Check(records[0].ChangeID, check.Equals, "")
+}
+
+func (s *daemonSuite) TestCommandDoesNotAuditSnapctl(c *check.C) {
+	d := newTestDaemon(c)
+
+	cmd := &Command{d: d, Path: "/v2/snapctl"}
+	cmd.POST = func(*Command, *http.Request, *auth.UserState) Response {
+		return SyncResponse(nil)
+	}
+	cmd.WriteAccess = openAccess{}
+
+	req, err := http.NewRequest("POST", "/v2/snapctl", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = fmt.Sprintf("pid=100;uid=42;socket=%s;", dirs.SnapdSocket)
+	rec := httptest.NewRecorder()
+	cmd.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	records, err := auditRecords(st)
+	c.Assert(err, check.IsNil)
+	c.Check(records, check.HasLen, 0)
+}
+
+func (s *daemonSuite) TestRecordAuditRotates(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.Overlord().State()
+
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	old := &auditRecord{ID: "old", Time: now.Add(-8 * 24 * time.Hour)}
+	recent := &auditRecord{ID: "recent", Time: now.Add(-time.Hour)}
+	st.Lock()
+	st.Set("api-audit", []*auditRecord{old, recent})
+	st.Unlock()
+
+	// expired records are dropped
+	recordAudit(st, &auditRecord{ID: "new", Time: now})
+	st.Lock()
+	records, err := auditRecords(st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.HasLen, 2)
+	c.Check(records[0].ID, check.Equals, "recent")
+	c.Check(records[1].ID, check.Equals, "new")
+
+	// and only the most recent ones are kept
+	for i := 0; i < maxAuditRecords; i++ {
+		recordAudit(st, &auditRecord{ID: fmt.Sprintf("r%d", i), Time: now})
+	}
+	st.Lock()
+	records, err = auditRecords(st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Assert(records, check.HasLen, maxAuditRecords)
+	c.Check(records[0].ID, check.Equals, "r0")
+	c.Check(records[maxAuditRecords-1].ID, check.Equals, fmt.Sprintf("r%d", maxAuditRecords-1))
+}
+
 func (s *daemonSuite) TestCommandRestartingState(c *check.C) {
 	d := newTestDaemon(c)
 
@@ -303,6 +465,65 @@ func (s *daemonSuite) TestCommandRestartingState(c *check.C) {
 	}
 }
 
+func (s *daemonSuite) TestMaintenanceForRestartTypePlanned(c *check.C) {
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	maint := &restart.PlannedMaintenance{
+		Kind:   restart.MaintenanceSystemRestart,
+		Action: "reboot",
+		RebootReason: restart.RebootReason{
+			Code:     restart.RebootReasonKernelRefresh,
+			ChangeID: "42",
+			Snap:     "pc-kernel",
+		},
+		Time: at,
+	}
+	c.Check(maintenanceForRestartType(restart.RestartSystem, maint), check.DeepEquals, &errorResult{
+		Kind:    client.ErrorKindSystemRestart,
+		Message: "system is restarting",
+		Value: map[string]interface{}{
+			"op":           "reboot",
+			"planned-time": "2030-01-02T03:04:05Z",
+			"reason":       "kernel-refresh",
+			"snap":         "pc-kernel",
+			"change-id":    "42",
+		},
+	})
+
+	maint = &restart.PlannedMaintenance{
+		Kind:         restart.MaintenanceDaemonRestart,
+		RebootReason: restart.RebootReason{Code: restart.RebootReasonSnapdRefresh},
+		Time:         at,
+	}
+	c.Check(maintenanceForRestartType(restart.RestartDaemon, maint), check.DeepEquals, &errorResult{
+		Kind:    client.ErrorKindDaemonRestart,
+		Message: "daemon is restarting",
+		Value: map[string]interface{}{
+			"planned-time": "2030-01-02T03:04:05Z",
+			"reason":       "snapd-refresh",
+		},
+	})
+
+	// unset details are omitted
+	maint = &restart.PlannedMaintenance{
+		Kind:   restart.MaintenanceSystemRestart,
+		Action: "reboot",
+	}
+	c.Check(maintenanceForRestartType(restart.RestartSystem, maint), check.DeepEquals, &errorResult{
+		Kind:    client.ErrorKindSystemRestart,
+		Message: "system is restarting",
+		Value: map[string]interface{}{
+			"op": "reboot",
+		},
+	})
+	maint = &restart.PlannedMaintenance{
+		Kind: restart.MaintenanceDaemonRestart,
+	}
+	c.Check(maintenanceForRestartType(restart.RestartDaemon, maint), check.DeepEquals, &errorResult{
+		Kind:    client.ErrorKindDaemonRestart,
+		Message: "daemon is restarting",
+	})
+}
+
 func (s *daemonSuite) TestMaintenanceJsonDeletedOnStart(c *check.C) {
 	// write a maintenance.json file that has that the system is restarting
 	maintErr := &errorResult{
@@ -663,6 +884,28 @@ version: 1`, si)
 	c.Check(s.notified, check.DeepEquals, []string{extendedTimeoutUSec, "READY=1", "STOPPING=1"})
 }
 
+func (s *daemonSuite) TestStartStopWatchesMeteredConnection(c *check.C) {
+	watching := false
+	netutilWatchMeteredConnection = func() (func(), error) {
+		watching = true
+		return func() { watching = false }, nil
+	}
+
+	d := newTestDaemon(c)
+	// mark as already seeded
+	s.markSeeded(d)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+
+	c.Assert(d.Start(), check.IsNil)
+	c.Check(watching, check.Equals, true)
+
+	c.Assert(d.Stop(nil), check.IsNil)
+	c.Check(watching, check.Equals, false)
+}
+
 func (s *daemonSuite) TestRestartWiring(c *check.C) {
 	d := newTestDaemon(c)
 	// mark as already seeded
@@ -1045,11 +1288,15 @@ func (s *daemonSuite) testRestartSystemWiring(c *check.C, prep func(d *Daemon),
 	maintErr := &errorResult{}
 	c.Assert(json.Unmarshal(b, maintErr), check.IsNil)
 	c.Check(maintErr.Kind, check.Equals, client.ErrorKindSystemRestart)
+	maint := restart.Maintenance(st)
+	c.Assert(maint, check.NotNil)
 	c.Check(maintErr.Value, check.DeepEquals, map[string]interface{}{
-		"op": expectedOp,
+		"op":           expectedOp,
+		"planned-time": maint.Time.Format(time.RFC3339),
+		"reason":       "unknown",
 	})
 
-	exp := maintenanceForRestartType(restartKind)
+	exp := maintenanceForRestartType(restartKind, maint)
 	c.Assert(maintErr, check.DeepEquals, exp)
 }
 
@@ -237,6 +237,17 @@ func InsufficientSpace(dserr *snapstate.InsufficientSpaceError) *apiError {
 	if dserr.ChangeKind != "" {
 		value["change-kind"] = dserr.ChangeKind
 	}
+	if len(dserr.Shortfalls) > 0 {
+		shortfalls := make([]map[string]interface{}, len(dserr.Shortfalls))
+		for i, sf := range dserr.Shortfalls {
+			shortfalls[i] = map[string]interface{}{
+				"path":     sf.Path,
+				"required": sf.Required,
+				"missing":  sf.Missing,
+			}
+		}
+		value["shortfalls"] = shortfalls
+	}
 	return &apiError{
 		Status:  507,
 		Message: dserr.Error(),
@@ -331,6 +342,9 @@ func errToResponse(err error, snaps []string, fallback errorResponder, format st
 			snapName = err.Snap
 		case *snapstate.InsufficientSpaceError:
 			return InsufficientSpace(err)
+		case *snapstate.DependentSnapsError:
+			kind = client.ErrorKindSnapHasDependents
+			snapName = err.Snap
 		case net.Error:
 			if err.Timeout() {
 				kind = client.ErrorKindNetworkTimeout
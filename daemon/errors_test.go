@@ -127,6 +127,7 @@ func (s *errorsSuite) TestErrToResponse(c *C) {
 	nc := &snapstate.SnapNotClassicError{Snap: "foo"}
 	nce := &snapstate.SnapNeedsClassicError{Snap: "foo"}
 	ncse := &snapstate.SnapNeedsClassicSystemError{Snap: "foo"}
+	dse := &snapstate.DependentSnapsError{Snap: "foo", Dependents: []string{"bar"}}
 	netoe := fakeNetError{message: "other"}
 	nettoute := fakeNetError{message: "timeout", timeout: true}
 	nettmpe := fakeNetError{message: "temp", temporary: true}
@@ -164,6 +165,7 @@ func (s *errorsSuite) TestErrToResponse(c *C) {
 		{nc, makeErrorRsp(client.ErrorKindSnapNotClassic, nc, "foo")},
 		{nce, makeErrorRsp(client.ErrorKindSnapNeedsClassic, nce, "foo")},
 		{ncse, makeErrorRsp(client.ErrorKindSnapNeedsClassicSystem, ncse, "foo")},
+		{dse, makeErrorRsp(client.ErrorKindSnapHasDependents, dse, "foo")},
 		{cce, daemon.SnapChangeConflict(cce)},
 		{nettoute, makeErrorRsp(client.ErrorKindNetworkTimeout, nettoute, "")},
 		{netoe, daemon.BadRequest("ERR: %v", netoe)},
@@ -234,6 +236,30 @@ func (s *errorsSuite) TestErrToResponseInsufficentSpace(c *C) {
 	})
 }
 
+func (s *errorsSuite) TestErrToResponseInsufficentSpaceShortfalls(c *C) {
+	err := &snapstate.InsufficientSpaceError{
+		Snaps:      []string{"foo"},
+		ChangeKind: "refresh",
+		Path:       "/var/snap",
+		Shortfalls: []snapstate.SpaceShortfall{
+			{Path: "/var/snap", Required: 2000, Missing: 1000},
+		},
+	}
+	rspe := daemon.ErrToResponse(err, nil, daemon.BadRequest, "%s: %v", "ERR")
+	c.Check(rspe, DeepEquals, &daemon.APIError{
+		Status:  507,
+		Message: `insufficient space to perform "refresh" change for the following snaps: foo (at least 1kB more is required in "/var/snap")`,
+		Kind:    client.ErrorKindInsufficientDiskSpace,
+		Value: map[string]interface{}{
+			"snap-names":  []string{"foo"},
+			"change-kind": "refresh",
+			"shortfalls": []map[string]interface{}{
+				{"path": "/var/snap", "required": uint64(2000), "missing": uint64(1000)},
+			},
+		},
+	})
+}
+
 func (s *errorsSuite) TestAuthCancelled(c *C) {
 	c.Check(daemon.AuthCancelled("auth cancelled"), DeepEquals, &daemon.APIError{
 		Status:  403,
@@ -34,6 +34,7 @@ type (
 	SnapAccess                = snapAccess
 	ThemesOpenAccess          = themesOpenAccess
 	ThemesAuthenticatedAccess = themesAuthenticatedAccess
+	SnapdInternalAccess       = snapdInternalAccess
 )
 
 var CheckPolkitActionImpl = checkPolkitActionImpl
@@ -71,3 +72,11 @@ func MockRequireThemeApiAccess(new func(d *Daemon, ucred *ucrednet) *apiError) (
 		requireThemeApiAccess = old
 	}
 }
+
+func MockPeerExecutable(new func(pid int32) (string, error)) (restore func()) {
+	old := peerExecutable
+	peerExecutable = new
+	return func() {
+		peerExecutable = old
+	}
+}
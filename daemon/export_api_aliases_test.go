@@ -20,6 +20,9 @@
 package daemon
 
 type (
-	AliasAction = aliasAction
-	AliasStatus = aliasStatus
+	AliasAction    = aliasAction
+	AliasStatus    = aliasStatus
+	AliasSetStatus = aliasSetStatus
+	AliasConflict  = aliasConflict
+	SnapAliases    = snapAliases
 )
@@ -47,7 +47,7 @@ func MockSnapshotList(newList func(context.Context, *state.State, uint64, []stri
 	}
 }
 
-func MockSnapshotExport(newExport func(context.Context, *state.State, uint64) (*snapshotstate.SnapshotExport, error)) (restore func()) {
+func MockSnapshotExport(newExport func(context.Context, *state.State, uint64, *snapshotstate.ExportEncryption) (*snapshotstate.SnapshotExport, error)) (restore func()) {
 	oldExport := snapshotExport
 	snapshotExport = newExport
 	return func() {
@@ -79,7 +79,7 @@ func MockSnapshotForget(newForget func(*state.State, uint64, []string) ([]string
 	}
 }
 
-func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader) (uint64, []string, error)) (restore func()) {
+func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader, string) (uint64, []string, error)) (restore func()) {
 	oldImport := snapshotImport
 	snapshotImport = newImport
 	return func() {
@@ -50,8 +50,20 @@ func MockDevicestateInstallFinish(f func(*state.State, string, map[string]*gadge
 	return restore
 }
 
-func MockDevicestateInstallSetupStorageEncryption(f func(*state.State, string, map[string]*gadget.Volume) (*state.Change, error)) (restore func()) {
+func MockDevicestateInstallSetupStorageEncryption(f func(*state.State, string, map[string]*gadget.Volume, *devicestate.InstallEncryptionOptions) (*state.Change, error)) (restore func()) {
 	restore = testutil.Backup(&devicestateInstallSetupStorageEncryption)
 	devicestateInstallSetupStorageEncryption = f
 	return restore
 }
+
+func MockDeviceManagerShutdown(f func(*devicestate.DeviceManager, string, string) error) (restore func()) {
+	restore = testutil.Backup(&deviceManagerShutdown)
+	deviceManagerShutdown = f
+	return restore
+}
+
+func MockDevicestateScheduleSystemAction(f func(*state.State, *devicestate.ScheduledSystemAction, devicestate.SystemActionSchedule) (*state.Change, error)) (restore func()) {
+	restore = testutil.Backup(&devicestateScheduleSystemAction)
+	devicestateScheduleSystemAction = f
+	return restore
+}
@@ -22,17 +22,20 @@ package daemon
 import (
 	"context"
 	"net/http"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/osutil/disks"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -72,6 +75,8 @@ func (d *Daemon) RequestedRestart() restart.RestartType {
 
 type Ucrednet = ucrednet
 
+type AuditRecord = auditRecord
+
 func MockUcrednetGet(mock func(remoteAddr string) (ucred *Ucrednet, err error)) (restore func()) {
 	oldUcrednetGet := ucrednetGet
 	ucrednetGet = mock
@@ -150,6 +155,22 @@ func MockSnapstateUpdate(mock func(*state.State, string, *snapstate.RevisionOpti
 	}
 }
 
+func MockSnapstateExplainEpochMigration(mock func(context.Context, *state.State, string, string, int) (*snapstate.EpochMigration, error)) (restore func()) {
+	oldSnapstateExplainEpochMigration := snapstateExplainEpochMigration
+	snapstateExplainEpochMigration = mock
+	return func() {
+		snapstateExplainEpochMigration = oldSnapstateExplainEpochMigration
+	}
+}
+
+func MockSnapstateUpdateEpochMigration(mock func(*state.State, *snapstate.EpochMigration, int, snapstate.Flags) ([]*state.TaskSet, error)) (restore func()) {
+	oldSnapstateUpdateEpochMigration := snapstateUpdateEpochMigration
+	snapstateUpdateEpochMigration = mock
+	return func() {
+		snapstateUpdateEpochMigration = oldSnapstateUpdateEpochMigration
+	}
+}
+
 func MockSnapstateTryPath(mock func(*state.State, string, string, snapstate.Flags) (*state.TaskSet, error)) (restore func()) {
 	oldSnapstateTryPath := snapstateTryPath
 	snapstateTryPath = mock
@@ -198,6 +219,14 @@ func MockSnapstateUpdateMany(mock func(context.Context, *state.State, []string,
 	}
 }
 
+func MockSnapstateRemovalPlan(mock func(*state.State, []string, bool) ([]string, error)) (restore func()) {
+	oldSnapstateRemovalPlan := snapstateRemovalPlan
+	snapstateRemovalPlan = mock
+	return func() {
+		snapstateRemovalPlan = oldSnapstateRemovalPlan
+	}
+}
+
 func MockSnapstateRemoveMany(mock func(*state.State, []string, *snapstate.RemoveFlags) ([]string, []*state.TaskSet, error)) (restore func()) {
 	oldSnapstateRemoveMany := snapstateRemoveMany
 	snapstateRemoveMany = mock
@@ -222,6 +251,14 @@ func MockSnapstateResolveValSetEnforcementError(f func(context.Context, *state.S
 	}
 }
 
+func MockApparmorCompileStatistics(mock func() apparmor.CompileStats) (restore func()) {
+	old := apparmorCompileStatistics
+	apparmorCompileStatistics = mock
+	return func() {
+		apparmorCompileStatistics = old
+	}
+}
+
 func MockSnapstateMigrate(mock func(*state.State, []string) ([]*state.TaskSet, error)) (restore func()) {
 	oldSnapstateMigrate := snapstateMigrateHome
 	snapstateMigrateHome = mock
@@ -296,3 +333,33 @@ var (
 
 	MaxReadBuflen = maxReadBuflen
 )
+
+func MockSnapstateDiffRevisions(mock func(*state.State, string, snap.Revision, snap.Revision) (*snapstate.RevisionDiff, error)) (restore func()) {
+	oldSnapstateDiffRevisions := snapstateDiffRevisions
+	snapstateDiffRevisions = mock
+	return func() {
+		snapstateDiffRevisions = oldSnapstateDiffRevisions
+	}
+}
+
+func MockDisksAllPhysicalDisks(mock func() ([]disks.Disk, error)) (restore func()) {
+	old := disksAllPhysicalDisks
+	disksAllPhysicalDisks = mock
+	return func() {
+		disksAllPhysicalDisks = old
+	}
+}
+
+func MockSyscallStatfs(mock func(path string, st *syscall.Statfs_t) error) (restore func()) {
+	old := syscallStatfs
+	syscallStatfs = mock
+	return func() {
+		syscallStatfs = old
+	}
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() { timeNow = old }
+}
@@ -89,7 +89,10 @@ func (r *respJSON) JSON() *respJSON {
 	return r
 }
 
-func maintenanceForRestartType(rst restart.RestartType) *errorResult {
+// maintenanceForRestartType returns the maintenance reported to clients for
+// the given restart type. When known, the planned maintenance adds when and
+// why the restart happens.
+func maintenanceForRestartType(rst restart.RestartType, maint *restart.PlannedMaintenance) *errorResult {
 	e := &errorResult{}
 	switch rst {
 	case restart.RestartSystem, restart.RestartSystemNow:
@@ -120,15 +123,36 @@ func maintenanceForRestartType(rst restart.RestartType) *errorResult {
 		// shouldn't happen, maintenance for unset type should just be nil
 		panic("internal error: cannot marshal maintenance for RestartUnset")
 	}
+	if maint != nil {
+		value, _ := e.Value.(map[string]interface{})
+		if value == nil {
+			value = make(map[string]interface{})
+		}
+		if !maint.Time.IsZero() {
+			value["planned-time"] = maint.Time.Format(time.RFC3339)
+		}
+		if maint.Code != "" {
+			value["reason"] = string(maint.Code)
+		}
+		if maint.Snap != "" {
+			value["snap"] = maint.Snap
+		}
+		if maint.ChangeID != "" {
+			value["change-id"] = maint.ChangeID
+		}
+		if len(value) > 0 {
+			e.Value = value
+		}
+	}
 	return e
 }
 
-func (r *respJSON) addMaintenanceFromRestartType(rst restart.RestartType) {
+func (r *respJSON) addMaintenanceFromRestartType(rst restart.RestartType, maint *restart.PlannedMaintenance) {
 	if rst == restart.RestartUnset {
 		// nothing to do
 		return
 	}
-	r.Maintenance = maintenanceForRestartType(rst)
+	r.Maintenance = maintenanceForRestartType(rst, maint)
 }
 
 func (r *respJSON) addWarningCount(count int, stamp time.Time) {
@@ -0,0 +1,181 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	// snapdSelfTestTimeout is how long a snapd revision on probation
+	// is given to pass its self-test.
+	snapdSelfTestTimeout = 5 * time.Minute
+	// snapdSelfTestPollInterval is how often the readiness of the
+	// overlord is checked during the self-test.
+	snapdSelfTestPollInterval = 500 * time.Millisecond
+	// snapdSelfTestRequestTimeout is how long the API is given to
+	// respond during the self-test.
+	snapdSelfTestRequestTimeout = 30 * time.Second
+)
+
+// snapdOnProbation returns the record of the self-test of the running
+// snapd, if it is a revision of the snapd snap on probation. A revision
+// that failed its self-test already keeps failing to start, until the snapd
+// failure handling rolls it back.
+func (d *Daemon) snapdOnProbation() (*snapstate.SnapdCanary, error) {
+	if os.Getenv("SNAPD_REVERT_TO_REV") != "" {
+		// this is the previous revision, started by the snapd
+		// failure handling to roll back the one on probation
+		return nil, nil
+	}
+
+	d.state.Lock()
+	defer d.state.Unlock()
+	canary, err := snapstate.SnapdCanaryInfo(d.state)
+	if err != nil || canary == nil || canary.Status == snapstate.SnapdCanaryGood {
+		return nil, err
+	}
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(d.state, "snapd", &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if snapst.Current != canary.Revision {
+		// rolled back or refreshed again meanwhile
+		return nil, nil
+	}
+	if canary.Status == snapstate.SnapdCanaryFailed {
+		if canary.OldRevision.Unset() {
+			// first install of the snapd snap, there is no
+			// revision to roll back to so refusing to start would
+			// leave the system without snapd for good
+			logger.Noticef("WARNING: snapd revision %s failed its self-test but there is no revision to revert to: %s", canary.Revision, canary.Reason)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("snapd revision %s failed its self-test: %s", canary.Revision, canary.Reason)
+	}
+	return canary, nil
+}
+
+// startSelfTest runs the self-test of the given snapd revision on
+// probation once the API is served. A revision that passes it is marked as
+// good, whereas failing it stops the daemon with an error such that the
+// snapd failure handling rolls back to the previous revision.
+func (d *Daemon) startSelfTest(canary *snapstate.SnapdCanary) {
+	logger.Noticef("running self-test of snapd revision %s", canary.Revision)
+	d.tomb.Go(func() error {
+		err := d.selfTest()
+		select {
+		case <-d.tomb.Dying():
+			// stopping meanwhile, the test is run again on the
+			// next start
+			return nil
+		default:
+		}
+
+		d.state.Lock()
+		defer d.state.Unlock()
+		if err != nil {
+			logger.Noticef("snapd failed its self-test: %v", err)
+			if err := snapstate.MarkSnapdRevisionFailed(d.state, err); err != nil {
+				logger.Noticef("cannot record snapd self-test failure: %v", err)
+			}
+			return fmt.Errorf("snapd failed its self-test: %v", err)
+		}
+		logger.Noticef("snapd passed its self-test")
+		return snapstate.MarkSnapdRevisionGood(d.state)
+	})
+}
+
+// selfTest checks that the backends were initialized, that the state can be
+// used and that the API responds.
+func (d *Daemon) selfTest() error {
+	deadline := time.Now().Add(snapdSelfTestTimeout)
+	for {
+		rd := d.overlord.Readiness()
+		if rd.State == overlord.ReadinessDegraded {
+			return fmt.Errorf("cannot initialize: %v", rd.Err)
+		}
+		if rd.State == overlord.ReadinessReady {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cannot initialize within %v", snapdSelfTestTimeout)
+		}
+		select {
+		case <-time.After(snapdSelfTestPollInterval):
+		case <-d.tomb.Dying():
+			return tomb.ErrDying
+		}
+	}
+
+	d.state.Lock()
+	_, err := snapstate.CurrentInfo(d.state, "snapd")
+	d.state.Unlock()
+	if err != nil {
+		return fmt.Errorf("cannot use state: %v", err)
+	}
+
+	return d.selfTestAPI()
+}
+
+func (d *Daemon) selfTestAPI() error {
+	addr := d.snapdListener.Addr()
+	cli := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, addr.Network(), addr.String())
+			},
+		},
+		Timeout: snapdSelfTestRequestTimeout,
+	}
+	defer cli.CloseIdleConnections()
+
+	resp, err := cli.Get("http://localhost/v2/system-info")
+	if err != nil {
+		return fmt.Errorf("cannot query API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var rsp struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+		return fmt.Errorf("cannot decode API response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || rsp.Type != "sync" {
+		return fmt.Errorf("unexpected API response: %v", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *daemonSuite) mockSnapdOnProbation(c *check.C, d *Daemon, status snapstate.SnapdCanaryStatus) {
+	s.mockSnapdOnProbationFrom(c, d, status, snap.R(1))
+}
+
+func (s *daemonSuite) mockSnapdOnProbationFrom(c *check.C, d *Daemon, status snapstate.SnapdCanaryStatus, oldRev snap.Revision) {
+	// with the snapd snap installed, starting the interface manager
+	// switches to the snapd system mapper
+	s.AddCleanup(ifacestate.MockSnapMapper(&ifacestate.CoreCoreSystemMapper{}))
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	si := &snap.SideInfo{RealName: "snapd", Revision: snap.R(2), SnapID: "snapd-snap-id"}
+	snapstate.Set(st, "snapd", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(2),
+		SnapType: "snapd",
+	})
+	snaptest.MockSnap(c, "name: snapd\ntype: snapd\nversion: 1", si)
+	st.Set("snapd-canary", &snapstate.SnapdCanary{
+		Revision:    snap.R(2),
+		OldRevision: oldRev,
+		ChangeID:    "1",
+		Status:      status,
+		Reason:      "boom",
+	})
+}
+
+func (s *daemonSuite) snapdCanaryStatus(c *check.C, d *Daemon) snapstate.SnapdCanaryStatus {
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	canary, err := snapstate.SnapdCanaryInfo(st)
+	c.Assert(err, check.IsNil)
+	c.Assert(canary, check.NotNil)
+	return canary.Status
+}
+
+func (s *daemonSuite) TestStartSelfTestMarksSnapdGood(c *check.C) {
+	d := newTestDaemon(c)
+	s.markSeeded(d)
+	s.mockSnapdOnProbation(c, d, snapstate.SnapdCanaryTesting)
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapdSocket), 0755), check.IsNil)
+	l, err := net.Listen("unix", dirs.SnapdSocket)
+	c.Assert(err, check.IsNil)
+	d.snapdListener = &ucrednetListener{Listener: l}
+
+	c.Assert(d.Start(), check.IsNil)
+
+	for i := 0; i < 100; i++ {
+		if s.snapdCanaryStatus(c, d) != snapstate.SnapdCanaryTesting {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Check(s.snapdCanaryStatus(c, d), check.Equals, snapstate.SnapdCanaryGood)
+
+	c.Check(d.Stop(nil), check.IsNil)
+}
+
+func (s *daemonSuite) TestStartSelfTestFailureStopsDaemon(c *check.C) {
+	d := newTestDaemon(c)
+	s.markSeeded(d)
+	s.mockSnapdOnProbation(c, d, snapstate.SnapdCanaryTesting)
+
+	// requests without peer credentials are forbidden
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+
+	c.Assert(d.Start(), check.IsNil)
+
+	select {
+	case <-d.Dying():
+	case <-time.After(5 * time.Second):
+		c.Fatal("daemon was not stopped")
+	}
+	c.Check(s.snapdCanaryStatus(c, d), check.Equals, snapstate.SnapdCanaryFailed)
+
+	c.Check(d.Stop(nil), check.ErrorMatches, `snapd failed its self-test: unexpected API response: 403 Forbidden`)
+}
+
+func (s *daemonSuite) TestStartSnapdFailedSelfTestAlready(c *check.C) {
+	d := newTestDaemon(c)
+	s.markSeeded(d)
+	s.mockSnapdOnProbation(c, d, snapstate.SnapdCanaryFailed)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+
+	c.Check(d.Start(), check.ErrorMatches, `snapd revision 2 failed its self-test: boom`)
+}
+
+func (s *daemonSuite) TestStartSnapdFailedSelfTestNoRevert(c *check.C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	d := newTestDaemon(c)
+	s.markSeeded(d)
+	// the snapd snap was installed for the first time
+	s.mockSnapdOnProbationFrom(c, d, snapstate.SnapdCanaryFailed, snap.R(0))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+
+	c.Assert(d.Start(), check.IsNil)
+	c.Check(logbuf.String(), testutil.Contains, "WARNING: snapd revision 2 failed its self-test but there is no revision to revert to: boom")
+	c.Check(d.Stop(nil), check.IsNil)
+}
+
+func (s *daemonSuite) TestStartNoSelfTestWhenRollingBack(c *check.C) {
+	d := newTestDaemon(c)
+	s.markSeeded(d)
+	s.mockSnapdOnProbation(c, d, snapstate.SnapdCanaryFailed)
+
+	os.Setenv("SNAPD_REVERT_TO_REV", "1")
+	defer os.Unsetenv("SNAPD_REVERT_TO_REV")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	d.snapdListener = l
+
+	c.Assert(d.Start(), check.IsNil)
+	c.Check(d.Stop(nil), check.IsNil)
+}
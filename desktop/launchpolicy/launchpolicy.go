@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package launchpolicy describes the restrictions applied by the session
+// launcher of snap userd when opening URLs and files on behalf of snaps.
+package launchpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// Policy is the launch policy configured by the system administrator.
+type Policy struct {
+	// Restricted, when set, only allows opening URLs with the schemes
+	// explicitly allowed by the policy, instead of the schemes known to
+	// the launcher and those with a handler in the session. Opening files
+	// is then only allowed for the snaps the policy allows it for.
+	Restricted bool `json:"restricted,omitempty"`
+	// AllowedSchemes are URL schemes that all snaps can open.
+	AllowedSchemes []string `json:"allowed-schemes,omitempty"`
+	// Snaps holds the policy specific to each snap.
+	Snaps map[string]*SnapPolicy `json:"snaps,omitempty"`
+}
+
+// SnapPolicy is the launch policy of a single snap.
+type SnapPolicy struct {
+	// AllowedSchemes are URL schemes the snap can open, in addition to
+	// those allowed for all snaps.
+	AllowedSchemes []string `json:"allowed-schemes,omitempty"`
+	// AllowedHandlers, if set, are the desktop files of the only
+	// applications allowed to handle URLs opened by the snap.
+	AllowedHandlers []string `json:"allowed-handlers,omitempty"`
+	// AllowFiles allows the snap to open files in restricted mode.
+	AllowFiles bool `json:"allow-files,omitempty"`
+}
+
+func (p *Policy) snap(snapName string) *SnapPolicy {
+	if sp := p.Snaps[snapName]; sp != nil {
+		return sp
+	}
+	return &SnapPolicy{}
+}
+
+// SchemeAllowed returns whether the policy explicitly allows the snap to
+// open URLs with the given scheme.
+func (p *Policy) SchemeAllowed(snapName, scheme string) bool {
+	return strutil.ListContains(p.AllowedSchemes, scheme) || strutil.ListContains(p.snap(snapName).AllowedSchemes, scheme)
+}
+
+// HandlerAllowed returns whether the application with the given desktop
+// file can handle URLs opened by the snap.
+func (p *Policy) HandlerAllowed(snapName, handler string) bool {
+	handlers := p.snap(snapName).AllowedHandlers
+	return len(handlers) == 0 || strutil.ListContains(handlers, handler)
+}
+
+// RestrictsHandlers returns whether only some applications can handle the
+// URLs opened by the snap.
+func (p *Policy) RestrictsHandlers(snapName string) bool {
+	return len(p.snap(snapName).AllowedHandlers) > 0
+}
+
+// FilesAllowed returns whether the snap can open files.
+func (p *Policy) FilesAllowed(snapName string) bool {
+	return !p.Restricted || p.snap(snapName).AllowFiles
+}
+
+// Write writes the given policy to fname. A nil policy removes the file so
+// that no restrictions apply.
+func Write(fname string, p *Policy) error {
+	if p == nil {
+		if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(fname, data, 0644, 0)
+}
+
+// Read reads the policy from fname. It returns a nil policy if no policy
+// has been written.
+func Read(fname string) (*Policy, error) {
+	data, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read launch policy: %v", err)
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("cannot decode launch policy: %v", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package launchpolicy_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/desktop/launchpolicy"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type launchPolicySuite struct{}
+
+var _ = Suite(&launchPolicySuite{})
+
+func (s *launchPolicySuite) TestReadWrite(c *C) {
+	fname := filepath.Join(c.MkDir(), "desktop/launch-policy.json")
+
+	p, err := launchpolicy.Read(fname)
+	c.Assert(err, IsNil)
+	c.Check(p, IsNil)
+
+	policy := &launchpolicy.Policy{
+		Restricted:     true,
+		AllowedSchemes: []string{"https"},
+		Snaps: map[string]*launchpolicy.SnapPolicy{
+			"kiosk": {
+				AllowedSchemes:  []string{"mailto"},
+				AllowedHandlers: []string{"thunderbird_thunderbird.desktop"},
+				AllowFiles:      true,
+			},
+		},
+	}
+	c.Assert(launchpolicy.Write(fname, policy), IsNil)
+	c.Check(fname, testutil.FileEquals, `{"restricted":true,"allowed-schemes":["https"],"snaps":{"kiosk":{"allowed-schemes":["mailto"],"allowed-handlers":["thunderbird_thunderbird.desktop"],"allow-files":true}}}`)
+
+	p, err = launchpolicy.Read(fname)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, policy)
+
+	// a nil policy removes the file
+	c.Assert(launchpolicy.Write(fname, nil), IsNil)
+	c.Check(fname, testutil.FileAbsent)
+	c.Assert(launchpolicy.Write(fname, nil), IsNil)
+}
+
+func (s *launchPolicySuite) TestReadInvalid(c *C) {
+	fname := filepath.Join(c.MkDir(), "launch-policy.json")
+	c.Assert(ioutil.WriteFile(fname, []byte("{"), 0644), IsNil)
+
+	_, err := launchpolicy.Read(fname)
+	c.Assert(err, ErrorMatches, "cannot decode launch policy: .*")
+}
+
+func (s *launchPolicySuite) TestChecks(c *C) {
+	p := &launchpolicy.Policy{
+		AllowedSchemes: []string{"https"},
+		Snaps: map[string]*launchpolicy.SnapPolicy{
+			"kiosk": {
+				AllowedSchemes:  []string{"mailto"},
+				AllowedHandlers: []string{"thunderbird_thunderbird.desktop"},
+				AllowFiles:      true,
+			},
+		},
+	}
+
+	c.Check(p.SchemeAllowed("kiosk", "https"), Equals, true)
+	c.Check(p.SchemeAllowed("kiosk", "mailto"), Equals, true)
+	c.Check(p.SchemeAllowed("kiosk", "zoommtg"), Equals, false)
+	c.Check(p.SchemeAllowed("other", "https"), Equals, true)
+	c.Check(p.SchemeAllowed("other", "mailto"), Equals, false)
+
+	c.Check(p.RestrictsHandlers("kiosk"), Equals, true)
+	c.Check(p.HandlerAllowed("kiosk", "thunderbird_thunderbird.desktop"), Equals, true)
+	c.Check(p.HandlerAllowed("kiosk", "evolution.desktop"), Equals, false)
+	c.Check(p.RestrictsHandlers("other"), Equals, false)
+	c.Check(p.HandlerAllowed("other", "evolution.desktop"), Equals, true)
+
+	// files are only restricted in restricted mode
+	c.Check(p.FilesAllowed("kiosk"), Equals, true)
+	c.Check(p.FilesAllowed("other"), Equals, true)
+	p.Restricted = true
+	c.Check(p.FilesAllowed("kiosk"), Equals, true)
+	c.Check(p.FilesAllowed("other"), Equals, false)
+}
@@ -49,6 +49,8 @@ var (
 	SnapConfineAppArmorDir string
 	SnapSeccompBase        string
 	SnapSeccompDir         string
+	SnapSeccompCacheDir    string
+	SnapLandlockDir        string
 	SnapMountPolicyDir     string
 	SnapUdevRulesDir       string
 	SnapKModModulesDir     string
@@ -90,9 +92,16 @@ var (
 	SnapCacheDir        string
 	SnapNamesFile       string
 	SnapSectionsFile    string
+	SnapSearchIndexFile string
 	SnapCommandsDB      string
 	SnapAuxStoreInfoDir string
 
+	SnapOfflineCatalogFile string
+
+	SnapTimingsDBFile string
+
+	SnapAppArmorCompileStatsFile string
+
 	SnapBinariesDir        string
 	SnapServicesDir        string
 	SnapRuntimeServicesDir string
@@ -100,6 +109,7 @@ var (
 	SnapSystemdConfDir     string
 	SnapDesktopFilesDir    string
 	SnapDesktopIconsDir    string
+	SnapLaunchPolicyFile   string
 	SnapPolkitPolicyDir    string
 	SnapSystemdDir         string
 	SnapSystemdRunDir      string
@@ -135,8 +145,9 @@ var (
 
 	SnapshotsDir string
 
-	ErrtrackerDbDir string
-	SysfsDir        string
+	ErrtrackerDbDir    string
+	ErrtrackerSinkFile string
+	SysfsDir           string
 
 	FeaturesDir string
 )
@@ -283,6 +294,18 @@ func FeaturesDirUnder(rootdir string) string {
 	return filepath.Join(rootdir, snappyDir, "features")
 }
 
+// SnapLaunchPolicyFileUnder returns the path to the policy applied by the
+// session launcher when opening URLs and files for snaps, under rootdir.
+func SnapLaunchPolicyFileUnder(rootdir string) string {
+	return filepath.Join(rootdir, snappyDir, "desktop", "launch-policy.json")
+}
+
+// ErrtrackerSinkFileUnder returns the path to the error report sink
+// configuration under rootdir.
+func ErrtrackerSinkFileUnder(rootdir string) string {
+	return filepath.Join(rootdir, snappyDir, "errtracker-sink.json")
+}
+
 // SnapSystemdConfDirUnder returns the path to the systemd conf dir under
 // rootdir.
 func SnapSystemdConfDirUnder(rootdir string) string {
@@ -364,6 +387,8 @@ func SetRootDir(rootdir string) {
 	SnapDownloadCacheDir = filepath.Join(rootdir, snappyDir, "cache")
 	SnapSeccompBase = filepath.Join(rootdir, snappyDir, "seccomp")
 	SnapSeccompDir = filepath.Join(SnapSeccompBase, "bpf")
+	SnapSeccompCacheDir = filepath.Join(SnapSeccompBase, "cache")
+	SnapLandlockDir = filepath.Join(rootdir, snappyDir, "landlock", "profiles")
 	SnapMountPolicyDir = filepath.Join(rootdir, snappyDir, "mount")
 	SnapdMaintenanceFile = filepath.Join(rootdir, snappyDir, "maintenance.json")
 	SnapBlobDir = SnapBlobDirUnder(rootdir)
@@ -373,6 +398,7 @@ func SetRootDir(rootdir string) {
 	// freedesktop.org specifications
 	SnapDesktopFilesDir = filepath.Join(rootdir, snappyDir, "desktop", "applications")
 	SnapDesktopIconsDir = filepath.Join(rootdir, snappyDir, "desktop", "icons")
+	SnapLaunchPolicyFile = SnapLaunchPolicyFileUnder(rootdir)
 	RunDir = filepath.Join(rootdir, "/run")
 	SnapRunDir = filepath.Join(rootdir, "/run/snapd")
 	SnapRunNsDir = filepath.Join(SnapRunDir, "/ns")
@@ -398,9 +424,16 @@ func SetRootDir(rootdir string) {
 	SnapCacheDir = filepath.Join(rootdir, "/var/cache/snapd")
 	SnapNamesFile = filepath.Join(SnapCacheDir, "names")
 	SnapSectionsFile = filepath.Join(SnapCacheDir, "sections")
+	SnapSearchIndexFile = filepath.Join(SnapCacheDir, "search-index.json")
 	SnapCommandsDB = filepath.Join(SnapCacheDir, "commands.db")
 	SnapAuxStoreInfoDir = filepath.Join(SnapCacheDir, "aux")
 
+	SnapOfflineCatalogFile = filepath.Join(rootdir, snappyDir, "offline-catalog.json")
+
+	SnapTimingsDBFile = filepath.Join(rootdir, snappyDir, "timings.db")
+
+	SnapAppArmorCompileStatsFile = filepath.Join(rootdir, snappyDir, "apparmor", "compile-stats.json")
+
 	SnapSeedDir = SnapSeedDirUnder(rootdir)
 	SnapDeviceDir = SnapDeviceDirUnder(rootdir)
 
@@ -504,6 +537,7 @@ func SetRootDir(rootdir string) {
 	SnapshotsDir = filepath.Join(rootdir, snappyDir, "snapshots")
 
 	ErrtrackerDbDir = filepath.Join(rootdir, snappyDir, "errtracker.db")
+	ErrtrackerSinkFile = ErrtrackerSinkFileUnder(rootdir)
 	SysfsDir = filepath.Join(rootdir, "/sys")
 
 	FeaturesDir = FeaturesDirUnder(rootdir)
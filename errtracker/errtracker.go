@@ -26,7 +26,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -34,7 +33,6 @@ import (
 	"time"
 
 	"github.com/snapcore/bolt"
-	"gopkg.in/mgo.v2/bson"
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/dirs"
@@ -388,17 +386,17 @@ func environ() string {
 }
 
 func report(errMsg, dupSig string, extra map[string]string) (string, error) {
-	if CrashDbURLBase == "" {
+	sink, err := currentSink()
+	if err != nil {
+		return "", err
+	}
+	if !sink.Enabled() {
 		return "", nil
 	}
 	if extra == nil || extra["ProblemType"] == "" {
 		return "", fmt.Errorf(`key "ProblemType" not set in %v`, extra)
 	}
 
-	if !whoopsieEnabled() {
-		return "", nil
-	}
-
 	machineID, err := readMachineID()
 	if err != nil {
 		return "", err
@@ -406,8 +404,6 @@ func report(errMsg, dupSig string, extra map[string]string) (string, error) {
 
 	identifier := fmt.Sprintf("%x", sha512.Sum512(machineID))
 
-	crashDbUrl := fmt.Sprintf("%s/%s", CrashDbURLBase, identifier)
-
 	hostSnapdPath := filepath.Join(dirs.DistroLibExecDir, "snapd")
 	coreSnapdPath := filepath.Join(dirs.SnapMountDir, "core/current/usr/lib/snapd/snapd")
 	if mockedHostSnapd != "" {
@@ -484,30 +480,5 @@ func report(errMsg, dupSig string, extra map[string]string) (string, error) {
 		return "oops-not-sent", nil
 	}
 
-	// send it for real
-	reportBson, err := bson.Marshal(report)
-	if err != nil {
-		return "", err
-	}
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", crashDbUrl, bytes.NewBuffer(reportBson))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Add("Content-Type", "application/octet-stream")
-	req.Header.Add("X-Whoopsie-Version", snapdenv.UserAgent())
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("cannot upload error report, return code: %d", resp.StatusCode)
-	}
-	oopsID, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	return string(oopsID), nil
+	return sink.Send(identifier, report)
 }
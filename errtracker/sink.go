@@ -0,0 +1,266 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package errtracker
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snapdenv"
+)
+
+// Sink is a destination for error reports.
+type Sink interface {
+	// Enabled returns whether reports should be sent to the sink at all.
+	Enabled() bool
+	// Send delivers the report of the machine with the given identifier
+	// and returns an identifier of the report as assigned by the sink.
+	Send(identifier string, report map[string]string) (string, error)
+}
+
+const (
+	// SinkDefault sends reports to the Ubuntu error tracker via whoopsie/daisy.
+	SinkDefault = "default"
+	// SinkHTTP posts reports as JSON to a custom HTTPS endpoint.
+	SinkHTTP = "http"
+	// SinkSpool writes reports as JSON files into a local directory.
+	SinkSpool = "spool"
+)
+
+// SinkConfig describes the configured error report sink.
+type SinkConfig struct {
+	Type string `json:"type"`
+	// URL is the HTTPS endpoint of a http sink.
+	URL string `json:"url,omitempty"`
+	// Certificate is the path to a PEM encoded certificate that the
+	// endpoint of a http sink is pinned to.
+	Certificate string `json:"certificate,omitempty"`
+	// Dir is the directory of a spool sink.
+	Dir string `json:"dir,omitempty"`
+}
+
+// Sink returns the sink described by the configuration.
+func (cfg *SinkConfig) Sink() (Sink, error) {
+	switch cfg.Type {
+	case "", SinkDefault:
+		return daisySink{}, nil
+	case SinkHTTP:
+		var certPEM []byte
+		if cfg.Certificate != "" {
+			var err error
+			certPEM, err = ioutil.ReadFile(cfg.Certificate)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read error report sink certificate: %v", err)
+			}
+		}
+		return NewHTTPSink(cfg.URL, certPEM)
+	case SinkSpool:
+		return NewSpoolSink(cfg.Dir)
+	default:
+		return nil, fmt.Errorf("unknown error report sink type %q", cfg.Type)
+	}
+}
+
+// WriteSinkConfig writes the given sink configuration to fname. A nil
+// configuration removes the file so that the default sink is used.
+func WriteSinkConfig(fname string, cfg *SinkConfig) error {
+	if cfg == nil {
+		if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(fname, data, 0644, 0)
+}
+
+// currentSink returns the sink configured on the system, falling back to
+// the default one when none is configured.
+func currentSink() (Sink, error) {
+	data, err := ioutil.ReadFile(dirs.ErrtrackerSinkFile)
+	if os.IsNotExist(err) {
+		return daisySink{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read error report sink configuration: %v", err)
+	}
+	var cfg SinkConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("cannot decode error report sink configuration: %v", err)
+	}
+	return cfg.Sink()
+}
+
+// daisySink sends reports to CrashDbURLBase, as done by whoopsie.
+type daisySink struct{}
+
+func (daisySink) Enabled() bool {
+	return CrashDbURLBase != "" && whoopsieEnabled()
+}
+
+func (daisySink) Send(identifier string, report map[string]string) (string, error) {
+	crashDbUrl := fmt.Sprintf("%s/%s", CrashDbURLBase, identifier)
+
+	reportBson, err := bson.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{}
+	req, err := http.NewRequest("POST", crashDbUrl, bytes.NewBuffer(reportBson))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Content-Type", "application/octet-stream")
+	req.Header.Add("X-Whoopsie-Version", snapdenv.UserAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("cannot upload error report, return code: %d", resp.StatusCode)
+	}
+	oopsID, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(oopsID), nil
+}
+
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink returns a sink that posts reports as JSON to the given HTTPS
+// URL. If certPEM is not empty the server certificate must be signed by (or
+// be) one of the certificates it contains, instead of by the system roots.
+func NewHTTPSink(url string, certPEM []byte) (Sink, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("cannot use error report endpoint %q: not a https URL", url)
+	}
+	tlsConfig := &tls.Config{}
+	if len(certPEM) != 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(certPEM) {
+			return nil, fmt.Errorf("cannot use error report endpoint certificate: no PEM certificate found")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &httpSink{
+		url: url,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}, nil
+}
+
+func (*httpSink) Enabled() bool {
+	return true
+}
+
+type httpSinkReport struct {
+	Identifier string            `json:"identifier"`
+	Report     map[string]string `json:"report"`
+}
+
+func (s *httpSink) Send(identifier string, report map[string]string) (string, error) {
+	body, err := json.Marshal(&httpSinkReport{Identifier: identifier, Report: report})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("User-Agent", snapdenv.UserAgent())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("cannot upload error report, return code: %d", resp.StatusCode)
+	}
+	reportID, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(reportID)), nil
+}
+
+type spoolSink struct {
+	dir string
+}
+
+// NewSpoolSink returns a sink that writes every report as a JSON file into
+// the given directory, for collection by other tools.
+func NewSpoolSink(dir string) (Sink, error) {
+	if !filepath.IsAbs(dir) {
+		return nil, fmt.Errorf("cannot use error report spool directory %q: not an absolute path", dir)
+	}
+	return &spoolSink{dir: dir}, nil
+}
+
+func (*spoolSink) Enabled() bool {
+	return true
+}
+
+func (s *spoolSink) Send(identifier string, report map[string]string) (string, error) {
+	data, err := json.Marshal(&httpSinkReport{Identifier: identifier, Report: report})
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return "", err
+	}
+	shortID := identifier
+	if len(shortID) > 16 {
+		shortID = shortID[:16]
+	}
+	reportID := fmt.Sprintf("%s-%s", timeNow().UTC().Format("20060102T150405.000000000Z"), shortID)
+	if err := osutil.AtomicWriteFile(filepath.Join(s.dir, reportID+".json"), data, 0600, 0); err != nil {
+		return "", err
+	}
+	return reportID, nil
+}
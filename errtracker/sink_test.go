@@ -0,0 +1,217 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package errtracker_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errtracker"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type sinkReport struct {
+	Identifier string            `json:"identifier"`
+	Report     map[string]string `json:"report"`
+}
+
+func (s *ErrtrackerTestSuite) mockUnreachableCrashDb(c *C) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("the default error tracker should not be hit")
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	s.AddCleanup(server.Close)
+	s.AddCleanup(errtracker.MockCrashDbURL(server.URL))
+}
+
+func (s *ErrtrackerTestSuite) TestReportHTTPSinkPinned(c *C) {
+	s.mockUnreachableCrashDb(c)
+
+	n := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/submit")
+		c.Check(r.Header.Get("Content-Type"), Equals, "application/json")
+		b, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+
+		var data sinkReport
+		c.Assert(json.Unmarshal(b, &data), IsNil)
+		c.Check(data.Identifier, Matches, "[a-z0-9]+")
+		c.Check(data.Report["ProblemType"], Equals, "Snap")
+		c.Check(data.Report["Snap"], Equals, "some-snap")
+		c.Check(data.Report["ErrorMessage"], Equals, "failed to do stuff")
+		fmt.Fprintf(w, "report-1234\n")
+		n++
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	certFile := filepath.Join(s.tmpdir, "reports.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	c.Assert(ioutil.WriteFile(certFile, certPEM, 0644), IsNil)
+
+	err := errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFile, &errtracker.SinkConfig{
+		Type:        errtracker.SinkHTTP,
+		URL:         server.URL + "/submit",
+		Certificate: certFile,
+	})
+	c.Assert(err, IsNil)
+
+	id, err := errtracker.Report("some-snap", "failed to do stuff", "[failed to do stuff]", nil)
+	c.Assert(err, IsNil)
+	c.Check(id, Equals, "report-1234")
+	c.Check(n, Equals, 1)
+}
+
+func (s *ErrtrackerTestSuite) TestReportHTTPSinkUnpinnedCertificateRejected(c *C) {
+	s.mockUnreachableCrashDb(c)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("the server should not be hit with an untrusted certificate")
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	// pin some other certificate
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "reports.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+
+	certFile := filepath.Join(s.tmpdir, "reports.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	c.Assert(ioutil.WriteFile(certFile, certPEM, 0644), IsNil)
+
+	err = errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFile, &errtracker.SinkConfig{
+		Type:        errtracker.SinkHTTP,
+		URL:         server.URL,
+		Certificate: certFile,
+	})
+	c.Assert(err, IsNil)
+
+	_, err = errtracker.Report("some-snap", "failed to do stuff", "[failed to do stuff]", nil)
+	c.Assert(err, ErrorMatches, ".*certificate.*")
+}
+
+func (s *ErrtrackerTestSuite) TestReportSpoolSink(c *C) {
+	s.mockUnreachableCrashDb(c)
+	restore := errtracker.MockTimeNow(func() time.Time { return time.Date(2017, 2, 17, 9, 51, 0, 0, time.UTC) })
+	defer restore()
+
+	spoolDir := filepath.Join(s.tmpdir, "spool")
+	err := errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFile, &errtracker.SinkConfig{
+		Type: errtracker.SinkSpool,
+		Dir:  spoolDir,
+	})
+	c.Assert(err, IsNil)
+
+	id, err := errtracker.ReportRepair(`"repair (1; brand-id:canonical)"`, "failure in script", "[dupSig]", nil)
+	c.Assert(err, IsNil)
+	c.Check(id, Matches, `20170217T095100\.000000000Z-[a-z0-9]{16}`)
+
+	fname := filepath.Join(spoolDir, id+".json")
+	c.Check(fname, testutil.FilePresent)
+	st, err := os.Stat(fname)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+
+	data, err := ioutil.ReadFile(fname)
+	c.Assert(err, IsNil)
+	var report sinkReport
+	c.Assert(json.Unmarshal(data, &report), IsNil)
+	c.Check(report.Identifier, Matches, "[a-z0-9]+")
+	c.Check(report.Report["ProblemType"], Equals, "Repair")
+	c.Check(report.Report["ErrorMessage"], Equals, "failure in script")
+	c.Check(report.Report["Date"], Equals, "Fri Feb 17 09:51:00 2017")
+}
+
+func (s *ErrtrackerTestSuite) TestReportSpoolSinkIgnoresWhoopsie(c *C) {
+	mockCmd := testutil.MockCommand(c, "systemctl", "echo disabled; exit 1")
+	defer mockCmd.Restore()
+
+	spoolDir := filepath.Join(s.tmpdir, "spool")
+	err := errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFile, &errtracker.SinkConfig{
+		Type: errtracker.SinkSpool,
+		Dir:  spoolDir,
+	})
+	c.Assert(err, IsNil)
+
+	id, err := errtracker.Report("some-snap", "failed to do stuff", "[failed to do stuff]", nil)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(spoolDir, id+".json"), testutil.FilePresent)
+}
+
+func (s *ErrtrackerTestSuite) TestReportBadSinkConfig(c *C) {
+	s.mockUnreachableCrashDb(c)
+
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.ErrtrackerSinkFile), 0755), IsNil)
+	for _, t := range []struct {
+		cfg    string
+		errStr string
+	}{
+		{`{`, `cannot decode error report sink configuration: .*`},
+		{`{"type":"foo"}`, `unknown error report sink type "foo"`},
+		{`{"type":"http","url":"http://example.com"}`, `cannot use error report endpoint "http://example.com": not a https URL`},
+		{`{"type":"http","url":"https://example.com","certificate":"/does/not/exist"}`, `cannot read error report sink certificate: .*`},
+		{`{"type":"spool","dir":"spool"}`, `cannot use error report spool directory "spool": not an absolute path`},
+	} {
+		c.Assert(ioutil.WriteFile(dirs.ErrtrackerSinkFile, []byte(t.cfg), 0644), IsNil)
+		_, err := errtracker.Report("some-snap", "failed to do stuff", "[failed to do stuff]", nil)
+		c.Check(err, ErrorMatches, t.errStr, Commentf(t.cfg))
+	}
+}
+
+func (s *ErrtrackerTestSuite) TestWriteSinkConfigNilRemoves(c *C) {
+	err := errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFile, &errtracker.SinkConfig{Type: errtracker.SinkDefault})
+	c.Assert(err, IsNil)
+	c.Check(dirs.ErrtrackerSinkFile, testutil.FileEquals, `{"type":"default"}`)
+
+	err = errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFile, nil)
+	c.Assert(err, IsNil)
+	c.Check(dirs.ErrtrackerSinkFile, testutil.FileAbsent)
+	// removing again is fine
+	err = errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFile, nil)
+	c.Assert(err, IsNil)
+}
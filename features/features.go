@@ -67,6 +67,21 @@ const (
 	// QuotaGroups enable creating resource quota groups for snaps via the rest API and cli.
 	QuotaGroups
 
+	// HybridConfinement enables approximating strict confinement with seccomp and Landlock when AppArmor is not available.
+	HybridConfinement
+
+	// Landlock enables restricting file system access of strictly confined snaps with Landlock alongside other confinement.
+	Landlock
+
+	// GadgetExtraFilesystems enables installing systems with gadget structures using the btrfs or f2fs filesystems.
+	GadgetExtraFilesystems
+
+	// EncryptedState enables encrypting sensitive parts of the snapd state at rest.
+	EncryptedState
+
+	// TryWatch enables watching the directories of tried snaps, trying them again when their contents change.
+	TryWatch
+
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
 )
@@ -106,6 +121,15 @@ var featureNames = map[SnapdFeature]string{
 	GateAutoRefreshHook: "gate-auto-refresh-hook",
 
 	QuotaGroups: "quota-groups",
+
+	HybridConfinement: "hybrid-confinement",
+	Landlock:          "landlock",
+
+	GadgetExtraFilesystems: "gadget-extra-filesystems",
+
+	EncryptedState: "encrypted-state",
+
+	TryWatch: "try-watch",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
@@ -127,6 +151,11 @@ var featuresExported = map[SnapdFeature]bool{
 	RobustMountNamespaceUpdates:   true,
 	HiddenSnapDataHomeDir:         true,
 	MoveSnapHomeDir:               true,
+
+	HybridConfinement: true,
+	Landlock:          true,
+
+	EncryptedState: true,
 }
 
 // String returns the name of a snapd feature.
@@ -56,6 +56,11 @@ func (*featureSuite) TestName(c *C) {
 	c.Check(features.CheckDiskSpaceRemove.String(), Equals, "check-disk-space-remove")
 	c.Check(features.GateAutoRefreshHook.String(), Equals, "gate-auto-refresh-hook")
 	c.Check(features.QuotaGroups.String(), Equals, "quota-groups")
+	c.Check(features.HybridConfinement.String(), Equals, "hybrid-confinement")
+	c.Check(features.GadgetExtraFilesystems.String(), Equals, "gadget-extra-filesystems")
+	c.Check(features.Landlock.String(), Equals, "landlock")
+	c.Check(features.EncryptedState.String(), Equals, "encrypted-state")
+	c.Check(features.TryWatch.String(), Equals, "try-watch")
 	c.Check(func() { _ = features.SnapdFeature(1000).String() }, PanicMatches, "unknown feature flag code 1000")
 }
 
@@ -85,6 +90,11 @@ func (*featureSuite) TestIsExported(c *C) {
 	c.Check(features.CheckDiskSpaceRefresh.IsExported(), Equals, false)
 	c.Check(features.CheckDiskSpaceRemove.IsExported(), Equals, false)
 	c.Check(features.GateAutoRefreshHook.IsExported(), Equals, false)
+	c.Check(features.HybridConfinement.IsExported(), Equals, true)
+	c.Check(features.GadgetExtraFilesystems.IsExported(), Equals, false)
+	c.Check(features.Landlock.IsExported(), Equals, true)
+	c.Check(features.EncryptedState.IsExported(), Equals, true)
+	c.Check(features.TryWatch.IsExported(), Equals, false)
 }
 
 func (*featureSuite) TestIsEnabled(c *C) {
@@ -123,6 +133,11 @@ func (*featureSuite) TestIsEnabledWhenUnset(c *C) {
 	c.Check(features.CheckDiskSpaceRefresh.IsEnabledWhenUnset(), Equals, false)
 	c.Check(features.CheckDiskSpaceRemove.IsEnabledWhenUnset(), Equals, false)
 	c.Check(features.GateAutoRefreshHook.IsEnabledWhenUnset(), Equals, false)
+	c.Check(features.HybridConfinement.IsEnabledWhenUnset(), Equals, false)
+	c.Check(features.GadgetExtraFilesystems.IsEnabledWhenUnset(), Equals, false)
+	c.Check(features.Landlock.IsEnabledWhenUnset(), Equals, false)
+	c.Check(features.EncryptedState.IsEnabledWhenUnset(), Equals, false)
+	c.Check(features.TryWatch.IsEnabledWhenUnset(), Equals, false)
 }
 
 func (*featureSuite) TestControlFile(c *C) {
@@ -132,6 +147,8 @@ func (*featureSuite) TestControlFile(c *C) {
 	c.Check(features.RobustMountNamespaceUpdates.ControlFile(), Equals, "/var/lib/snapd/features/robust-mount-namespace-updates")
 	c.Check(features.HiddenSnapDataHomeDir.ControlFile(), Equals, "/var/lib/snapd/features/hidden-snap-folder")
 	c.Check(features.MoveSnapHomeDir.ControlFile(), Equals, "/var/lib/snapd/features/move-snap-home-dir")
+	c.Check(features.HybridConfinement.ControlFile(), Equals, "/var/lib/snapd/features/hybrid-confinement")
+	c.Check(features.Landlock.ControlFile(), Equals, "/var/lib/snapd/features/landlock")
 	// Features that are not exported don't have a control file.
 	c.Check(features.Layouts.ControlFile, PanicMatches, `cannot compute the control file of feature "layouts" because that feature is not exported`)
 }
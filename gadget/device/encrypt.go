@@ -81,6 +81,12 @@ func RecoveryKeyUnder(deviceFDEDir string) string {
 	return filepath.Join(deviceFDEDir, "recovery.key")
 }
 
+// EscrowedRecoveryKeyUnder returns the path of the recovery key encrypted
+// to the public key of the brand.
+func EscrowedRecoveryKeyUnder(deviceFDEDir string) string {
+	return filepath.Join(deviceFDEDir, "recovery-key.escrow")
+}
+
 // FallbackDataSealedKeyUnder returns the path of a fallback ubuntu data key.
 func FallbackDataSealedKeyUnder(seedDeviceFDEDir string) string {
 	return filepath.Join(seedDeviceFDEDir, "ubuntu-data.recovery.sealed-key")
@@ -97,6 +103,32 @@ func FactoryResetFallbackSaveSealedKeyUnder(seedDeviceFDEDir string) string {
 	return filepath.Join(seedDeviceFDEDir, "ubuntu-save.recovery.sealed-key.factory-reset")
 }
 
+// passphraseAuthMarkerUnder returns the path of the marker recording that the
+// encrypted volumes are unlocked with a passphrase instead of sealed keys.
+func passphraseAuthMarkerUnder(deviceFDEDir string) string {
+	return filepath.Join(deviceFDEDir, "passphrase-auth")
+}
+
+// HasPassphraseAuthMarkerUnder returns true when the encrypted volumes are
+// unlocked with a passphrase, as recorded in a given directory.
+func HasPassphraseAuthMarkerUnder(deviceFDEDir string) bool {
+	return osutil.FileExists(passphraseAuthMarkerUnder(deviceFDEDir))
+}
+
+// WritePassphraseAuthMarkers records in the given directories that the
+// encrypted volumes are unlocked with a passphrase instead of sealed keys.
+func WritePassphraseAuthMarkers(deviceFDEDirs ...string) error {
+	for _, dir := range deviceFDEDirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(passphraseAuthMarkerUnder(dir), nil, 0644, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // TpmLockoutAuthUnder return the path of the tpm lockout authority key.
 func TpmLockoutAuthUnder(saveDeviceFDEDir string) string {
 	return filepath.Join(saveDeviceFDEDir, "tpm-lockout-auth")
@@ -67,6 +67,20 @@ func (s *deviceSuite) TestEncryptionMarkersRunThrough(c *C) {
 	c.Check(device.HasEncryptedMarkerUnder(filepath.Join(d, boot.InstallHostFDESaveDir)), Equals, true)
 }
 
+func (s *deviceSuite) TestPassphraseAuthMarkers(c *C) {
+	d := c.MkDir()
+	bootFDEDir := filepath.Join(d, "boot/device/fde")
+	seedFDEDir := filepath.Join(d, "seed/device/fde")
+	c.Check(device.HasPassphraseAuthMarkerUnder(bootFDEDir), Equals, false)
+	c.Check(device.HasPassphraseAuthMarkerUnder(seedFDEDir), Equals, false)
+
+	err := device.WritePassphraseAuthMarkers(bootFDEDir, seedFDEDir)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(bootFDEDir, "passphrase-auth"), testutil.FileEquals, "")
+	c.Check(device.HasPassphraseAuthMarkerUnder(bootFDEDir), Equals, true)
+	c.Check(device.HasPassphraseAuthMarkerUnder(seedFDEDir), Equals, true)
+}
+
 func (s *deviceSuite) TestReadEncryptionMarkers(c *C) {
 	tmpdir := c.MkDir()
 
@@ -0,0 +1,168 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/logger"
+)
+
+// emmcBootPartitions are the hardware boot partitions of an eMMC device,
+// structures of volumes with the "emmc" schema are named after the boot
+// partition they are located in.
+var emmcBootPartitions = []string{"boot0", "boot1"}
+
+// emmcBootPartitionNumber returns the number of the given hardware boot
+// partition as used by the BOOT_PARTITION_ENABLE field of the eMMC
+// PARTITION_CONFIG register.
+func emmcBootPartitionNumber(part string) int {
+	for i, p := range emmcBootPartitions {
+		if p == part {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// findEMMCBootPartition returns the kernel device node of the given
+// hardware boot partition of the eMMC device of the system. There must be
+// exactly one eMMC device exposing hardware boot partitions.
+func findEMMCBootPartition(part string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dirs.SysfsDir, "block", "mmcblk*"+part))
+	if err != nil {
+		return "", err
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("cannot find eMMC boot partition %s", part)
+	case 1:
+		return filepath.Join(dirs.GlobalRootDir, "/dev", filepath.Base(matches[0])), nil
+	default:
+		return "", fmt.Errorf("cannot find eMMC boot partition %s: more than one eMMC device found", part)
+	}
+}
+
+// emmcBootPartitionForceROPath returns the path to the sysfs knob
+// controlling the read-only state of the given eMMC boot partition device.
+func emmcBootPartitionForceROPath(device string) string {
+	return filepath.Join(dirs.SysfsDir, "block", filepath.Base(device), "force_ro")
+}
+
+// emmcDiskForBootPartition returns the device node of the eMMC device the
+// given hardware boot partition device belongs to.
+func emmcDiskForBootPartition(device, part string) string {
+	return strings.TrimSuffix(device, part)
+}
+
+// withWritableEMMCBootPartition runs f with the force_ro protection of the
+// given eMMC boot partition device lifted, restoring it afterwards.
+func withWritableEMMCBootPartition(device string, f func() error) error {
+	forceRO := emmcBootPartitionForceROPath(device)
+	if err := ioutil.WriteFile(forceRO, []byte("0"), 0644); err != nil {
+		return fmt.Errorf("cannot make eMMC boot partition %s writable: %v", device, err)
+	}
+	defer func() {
+		if err := ioutil.WriteFile(forceRO, []byte("1"), 0644); err != nil {
+			logger.Noticef("cannot restore read-only protection of eMMC boot partition %s: %v", device, err)
+		}
+	}()
+	return f()
+}
+
+// emmcSetBootPartitionEnable sets up the given eMMC device to boot from
+// the hardware boot partition with the given number and returns the number
+// of the boot partition it was previously set up to boot from.
+var emmcSetBootPartitionEnable = setEMMCBootPartitionEnable
+
+// emmcStructureUpdater implements support for updating structures located
+// in the hardware boot partitions of an eMMC device.
+type emmcStructureUpdater struct {
+	*rawStructureUpdater
+	device string
+	// bootPartChanged is set when the update changed the boot partition
+	// the eMMC device boots from, previousBootPart is then the number of
+	// the boot partition it was set up to boot from before
+	bootPartChanged  bool
+	previousBootPart int
+}
+
+// newEMMCStructureUpdater returns an updater for the given structure located
+// in the eMMC hardware boot partition available at the given location.
+func newEMMCStructureUpdater(contentDir string, ps *LaidOutStructure, backupDir string, loc StructureLocation) (*emmcStructureUpdater, error) {
+	if loc.Device == "" {
+		return nil, fmt.Errorf("internal error: eMMC boot partition device must be provided")
+	}
+	lookup := func(ps *LaidOutStructure) (device string, offs quantity.Offset, err error) {
+		return loc.Device, loc.Offset, nil
+	}
+	ru, err := newRawStructureUpdater(contentDir, ps, backupDir, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return &emmcStructureUpdater{
+		rawStructureUpdater: ru,
+		device:              loc.Device,
+	}, nil
+}
+
+// Update writes the new content to the eMMC boot partition and, when the
+// structure asks for it, sets up the eMMC device to boot from that
+// partition.
+func (u *emmcStructureUpdater) Update() error {
+	err := withWritableEMMCBootPartition(u.device, u.rawStructureUpdater.Update)
+	if err != nil && err != ErrNoUpdate {
+		return err
+	}
+	if !u.ps.EMMCBootEnable {
+		return err
+	}
+
+	part := emmcBootPartitionNumber(u.ps.Name)
+	previous, bootErr := emmcSetBootPartitionEnable(emmcDiskForBootPartition(u.device, u.ps.Name), part)
+	if bootErr != nil {
+		return fmt.Errorf("cannot enable booting from eMMC boot partition %s: %v", u.ps.Name, bootErr)
+	}
+	if previous != part {
+		u.bootPartChanged = true
+		u.previousBootPart = previous
+		// the boot configuration was changed even if the content was
+		// not
+		return nil
+	}
+	return err
+}
+
+// Rollback restores the original content of the eMMC boot partition and the
+// boot configuration of the eMMC device.
+func (u *emmcStructureUpdater) Rollback() error {
+	if u.bootPartChanged {
+		if _, err := emmcSetBootPartitionEnable(emmcDiskForBootPartition(u.device, u.ps.Name), u.previousBootPart); err != nil {
+			return fmt.Errorf("cannot restore eMMC boot partition enable: %v", err)
+		}
+		u.bootPartChanged = false
+	}
+	return withWritableEMMCBootPartition(u.device, u.rawStructureUpdater.Rollback)
+}
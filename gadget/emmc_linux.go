@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmcIocCmd mirrors struct mmc_ioc_cmd from linux/mmc/ioctl.h
+type mmcIocCmd struct {
+	writeFlag      int32
+	isAcmd         int32
+	opcode         uint32
+	arg            uint32
+	response       [4]uint32
+	flags          uint32
+	blksz          uint32
+	blocks         uint32
+	postsleepMinUs uint32
+	postsleepMaxUs uint32
+	dataTimeoutNs  uint32
+	cmdTimeoutMs   uint32
+	pad            uint32
+	dataPtr        uint64
+}
+
+const (
+	// _IOWR(MMC_BLOCK_MAJOR, 0, struct mmc_ioc_cmd)
+	mmcIocCmdIoctl = 0xc048b300
+
+	mmcSwitch     = 6
+	mmcSendExtCSD = 8
+
+	mmcRspPresent = 1 << 0
+	mmcRspCRC     = 1 << 2
+	mmcRspBusy    = 1 << 3
+	mmcRspOpcode  = 1 << 4
+	mmcCmdAC      = 0 << 5
+	mmcCmdADTC    = 1 << 5
+	mmcRspSPIS1   = 1 << 7
+	mmcRspSPIBusy = 1 << 10
+
+	mmcRspR1     = mmcRspPresent | mmcRspCRC | mmcRspOpcode
+	mmcRspR1B    = mmcRspPresent | mmcRspCRC | mmcRspOpcode | mmcRspBusy
+	mmcRspSPIR1  = mmcRspSPIS1
+	mmcRspSPIR1B = mmcRspSPIS1 | mmcRspSPIBusy
+
+	mmcSwitchModeWriteByte = 0x03
+	extCSDCmdSetNormal     = 1 << 0
+
+	extCSDSize            = 512
+	extCSDPartitionConfig = 179
+	// BOOT_PARTITION_ENABLE bits of PARTITION_CONFIG
+	extCSDBootPartEnableMask  = 0x38
+	extCSDBootPartEnableShift = 3
+)
+
+func mmcIoctl(f *os.File, cmd *mmcIocCmd) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), uintptr(mmcIocCmdIoctl), uintptr(unsafe.Pointer(cmd)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// setEMMCBootPartitionEnable updates the BOOT_PARTITION_ENABLE field of the
+// PARTITION_CONFIG register of the given eMMC device, like 'mmc bootpart
+// enable' does, and returns its previous value.
+func setEMMCBootPartitionEnable(device string, part int) (previous int, err error) {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var extCSD [extCSDSize]byte
+	read := mmcIocCmd{
+		opcode:  mmcSendExtCSD,
+		flags:   mmcRspSPIR1 | mmcRspR1 | mmcCmdADTC,
+		blksz:   extCSDSize,
+		blocks:  1,
+		dataPtr: uint64(uintptr(unsafe.Pointer(&extCSD[0]))),
+	}
+	err = mmcIoctl(f, &read)
+	runtime.KeepAlive(&extCSD)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read EXT_CSD register: %v", err)
+	}
+
+	current := extCSD[extCSDPartitionConfig]
+	previous = int(current&extCSDBootPartEnableMask) >> extCSDBootPartEnableShift
+	if previous == part {
+		return previous, nil
+	}
+
+	value := current&^extCSDBootPartEnableMask | byte(part<<extCSDBootPartEnableShift)&extCSDBootPartEnableMask
+	write := mmcIocCmd{
+		writeFlag: 1,
+		opcode:    mmcSwitch,
+		arg:       mmcSwitchModeWriteByte<<24 | extCSDPartitionConfig<<16 | uint32(value)<<8 | extCSDCmdSetNormal,
+		flags:     mmcRspSPIR1B | mmcRspR1B | mmcCmdAC,
+	}
+	if err := mmcIoctl(f, &write); err != nil {
+		return previous, fmt.Errorf("cannot write PARTITION_CONFIG register: %v", err)
+	}
+	return previous, nil
+}
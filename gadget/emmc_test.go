@@ -0,0 +1,236 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type emmcTestSuite struct {
+	testutil.BaseTest
+
+	dir    string
+	backup string
+}
+
+var _ = Suite(&emmcTestSuite{})
+
+func (s *emmcTestSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.dir = c.MkDir()
+	s.backup = c.MkDir()
+}
+
+func (s *emmcTestSuite) mockEMMCBootPartition(c *C, name string, size quantity.Size) string {
+	sysfs := filepath.Join(dirs.SysfsDir, "block", name)
+	c.Assert(os.MkdirAll(sysfs, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(sysfs, "force_ro"), []byte("1\n"), 0644), IsNil)
+	device := filepath.Join(dirs.GlobalRootDir, "/dev", name)
+	makeSizedFile(c, device, size, nil)
+	return device
+}
+
+func (s *emmcTestSuite) TestFindEMMCBootPartition(c *C) {
+	_, err := gadget.FindEMMCBootPartition("boot0")
+	c.Check(err, ErrorMatches, "cannot find eMMC boot partition boot0")
+
+	device := s.mockEMMCBootPartition(c, "mmcblk1boot0", 0)
+	s.mockEMMCBootPartition(c, "mmcblk1boot1", 0)
+
+	found, err := gadget.FindEMMCBootPartition("boot0")
+	c.Assert(err, IsNil)
+	c.Check(found, Equals, device)
+
+	s.mockEMMCBootPartition(c, "mmcblk2boot0", 0)
+	_, err = gadget.FindEMMCBootPartition("boot0")
+	c.Check(err, ErrorMatches, "cannot find eMMC boot partition boot0: more than one eMMC device found")
+}
+
+func (s *emmcTestSuite) emmcStructure(bootEnable bool) *gadget.LaidOutStructure {
+	return &gadget.LaidOutStructure{
+		VolumeStructure: &gadget.VolumeStructure{
+			Name:           "boot1",
+			Type:           "bare",
+			Size:           2048,
+			EMMCBootEnable: bootEnable,
+		},
+		StartOffset: 0,
+		LaidOutContent: []gadget.LaidOutContent{
+			{
+				VolumeContent: &gadget.VolumeContent{
+					Image: "spl.img",
+				},
+				StartOffset: 1024,
+				Size:        128,
+			},
+		},
+	}
+}
+
+func (s *emmcTestSuite) TestEMMCUpdaterBackupUpdateRollback(c *C) {
+	device := s.mockEMMCBootPartition(c, "mmcblk0boot1", 2048)
+	mutateFile(c, device, 2048, []mutateWrite{
+		{[]byte("old spl"), 1024},
+	})
+	makeSizedFile(c, filepath.Join(s.dir, "spl.img"), 128, []byte("new spl"))
+	forceRO := filepath.Join(dirs.SysfsDir, "block/mmcblk0boot1/force_ro")
+
+	var calls []int
+	bootPart := 1
+	restore := gadget.MockEMMCSetBootPartitionEnable(func(disk string, part int) (int, error) {
+		c.Check(disk, Equals, filepath.Join(dirs.GlobalRootDir, "/dev/mmcblk0"))
+		calls = append(calls, part)
+		previous := bootPart
+		bootPart = part
+		return previous, nil
+	})
+	defer restore()
+
+	ps := s.emmcStructure(true)
+	loc := gadget.StructureLocation{
+		Device:            device,
+		EMMCBootPartition: true,
+	}
+	up, err := gadget.UpdaterForStructure(loc, ps, s.dir, s.backup, nil)
+	c.Assert(err, IsNil)
+
+	err = up.Backup()
+	c.Assert(err, IsNil)
+	c.Check(forceRO, testutil.FileEquals, "1\n")
+
+	err = up.Update()
+	c.Assert(err, IsNil)
+	c.Check(forceRO, testutil.FileEquals, "1")
+	c.Check(calls, DeepEquals, []int{2})
+	c.Check(bootPart, Equals, 2)
+
+	expectedPath := filepath.Join(s.dir, "expected.img")
+	mutateFile(c, expectedPath, 2048, []mutateWrite{
+		{[]byte("new spl"), 1024},
+	})
+	c.Check(device, testutil.FileEquals, testutil.FileContentRef(expectedPath))
+
+	err = up.Rollback()
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []int{2, 1})
+	c.Check(bootPart, Equals, 1)
+	mutateFile(c, expectedPath, 2048, []mutateWrite{
+		{[]byte("old spl"), 1024},
+	})
+	c.Check(device, testutil.FileEquals, testutil.FileContentRef(expectedPath))
+}
+
+func (s *emmcTestSuite) TestEMMCUpdaterNoBootEnable(c *C) {
+	device := s.mockEMMCBootPartition(c, "mmcblk0boot1", 2048)
+	makeSizedFile(c, filepath.Join(s.dir, "spl.img"), 128, []byte("new spl"))
+
+	restore := gadget.MockEMMCSetBootPartitionEnable(func(disk string, part int) (int, error) {
+		c.Fatalf("unexpected call")
+		return 0, nil
+	})
+	defer restore()
+
+	ps := s.emmcStructure(false)
+	loc := gadget.StructureLocation{
+		Device:            device,
+		EMMCBootPartition: true,
+	}
+	up, err := gadget.UpdaterForStructure(loc, ps, s.dir, s.backup, nil)
+	c.Assert(err, IsNil)
+	c.Assert(up.Backup(), IsNil)
+	c.Assert(up.Update(), IsNil)
+
+	// identical content is not updated again
+	up, err = gadget.UpdaterForStructure(loc, ps, s.dir, c.MkDir(), nil)
+	c.Assert(err, IsNil)
+	c.Assert(up.Backup(), IsNil)
+	c.Assert(up.Update(), Equals, gadget.ErrNoUpdate)
+}
+
+func (s *emmcTestSuite) TestEMMCUpdaterSameContentBootEnableChanged(c *C) {
+	device := s.mockEMMCBootPartition(c, "mmcblk0boot1", 2048)
+	mutateFile(c, device, 2048, []mutateWrite{
+		{[]byte("new spl"), 1024},
+	})
+	makeSizedFile(c, filepath.Join(s.dir, "spl.img"), 128, []byte("new spl"))
+
+	previous := 1
+	restore := gadget.MockEMMCSetBootPartitionEnable(func(disk string, part int) (int, error) {
+		prev := previous
+		previous = part
+		return prev, nil
+	})
+	defer restore()
+
+	loc := gadget.StructureLocation{
+		Device:            device,
+		EMMCBootPartition: true,
+	}
+	up, err := gadget.UpdaterForStructure(loc, s.emmcStructure(true), s.dir, s.backup, nil)
+	c.Assert(err, IsNil)
+	c.Assert(up.Backup(), IsNil)
+	// the content is the same but the boot configuration changed
+	c.Assert(up.Update(), IsNil)
+
+	// nothing left to change
+	up, err = gadget.UpdaterForStructure(loc, s.emmcStructure(true), s.dir, c.MkDir(), nil)
+	c.Assert(err, IsNil)
+	c.Assert(up.Backup(), IsNil)
+	c.Assert(up.Update(), Equals, gadget.ErrNoUpdate)
+}
+
+func (s *emmcTestSuite) TestEMMCUpdaterErrors(c *C) {
+	device := s.mockEMMCBootPartition(c, "mmcblk0boot1", 2048)
+	makeSizedFile(c, filepath.Join(s.dir, "spl.img"), 128, []byte("new spl"))
+
+	restore := gadget.MockEMMCSetBootPartitionEnable(func(disk string, part int) (int, error) {
+		return 0, errors.New("boom")
+	})
+	defer restore()
+
+	loc := gadget.StructureLocation{
+		Device:            device,
+		EMMCBootPartition: true,
+	}
+	up, err := gadget.UpdaterForStructure(loc, s.emmcStructure(true), s.dir, s.backup, nil)
+	c.Assert(err, IsNil)
+	c.Assert(up.Backup(), IsNil)
+	c.Assert(up.Update(), ErrorMatches, "cannot enable booting from eMMC boot partition boot1: boom")
+
+	// no force_ro control
+	c.Assert(os.Remove(filepath.Join(dirs.SysfsDir, "block/mmcblk0boot1/force_ro")), IsNil)
+	c.Assert(os.Remove(filepath.Join(dirs.SysfsDir, "block/mmcblk0boot1")), IsNil)
+	c.Assert(up.Update(), ErrorMatches, `cannot make eMMC boot partition .*/dev/mmcblk0boot1 writable: .*`)
+
+	_, err = gadget.UpdaterForStructure(gadget.StructureLocation{EMMCBootPartition: true}, s.emmcStructure(true), s.dir, s.backup, nil)
+	c.Assert(err, ErrorMatches, "internal error: eMMC boot partition device must be provided")
+}
@@ -65,6 +65,8 @@ var (
 	OnDiskStructureIsLikelyImplicitSystemDataRole = onDiskStructureIsLikelyImplicitSystemDataRole
 
 	SearchForVolumeWithTraits = searchForVolumeWithTraits
+
+	FindEMMCBootPartition = findEMMCBootPartition
 )
 
 func MockEvalSymlinks(mock func(path string) (string, error)) (restore func()) {
@@ -75,6 +77,14 @@ func MockEvalSymlinks(mock func(path string) (string, error)) (restore func()) {
 	}
 }
 
+func MockEMMCSetBootPartitionEnable(mock func(device string, part int) (int, error)) (restore func()) {
+	old := emmcSetBootPartitionEnable
+	emmcSetBootPartitionEnable = mock
+	return func() {
+		emmcSetBootPartitionEnable = old
+	}
+}
+
 func (m *MountedFilesystemWriter) WriteDirectory(volumeRoot, src, dst string, preserveInDst []string) error {
 	return m.writeDirectory(volumeRoot, src, dst, preserveInDst)
 }
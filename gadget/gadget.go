@@ -52,6 +52,10 @@ const (
 	schemaMBR = "mbr"
 	// schemaGPT identifies a GUID Partition Table partitioning schema
 	schemaGPT = "gpt"
+	// schemaEMMC identifies the hardware boot partitions of an eMMC
+	// device, each structure of such volume is located in the boot
+	// partition it is named after
+	schemaEMMC = "emmc"
 
 	SystemBoot     = "system-boot"
 	SystemData     = "system-data"
@@ -102,6 +106,41 @@ type Info struct {
 	Defaults map[string]map[string]interface{} `yaml:"defaults,omitempty"`
 
 	Connections []Connection `yaml:"connections"`
+
+	// FactoryBoots is the number of boots into run mode, after the device
+	// was installed in factory mode, during which the factory boot flag is
+	// kept set.
+	FactoryBoots int `yaml:"factory-boots,omitempty"`
+
+	// SystemBanks names the two complete system banks laid out by
+	// prepare-image for devices requiring full A/B system redundancy
+	// (UC16/18 only).
+	SystemBanks []string `yaml:"system-banks,omitempty"`
+
+	// RecoveryKeyEscrow asks for a recovery key to be created when
+	// installing an encrypted device, and escrowed with the brand.
+	RecoveryKeyEscrow *RecoveryKeyEscrow `yaml:"recovery-key-escrow,omitempty"`
+}
+
+const (
+	// RecoveryKeyEscrowSeed keeps the escrowed recovery key on
+	// ubuntu-seed.
+	RecoveryKeyEscrowSeed = "ubuntu-seed"
+	// RecoveryKeyEscrowPrepareDevice hands the escrowed recovery key to
+	// the prepare-device hook to upload it.
+	RecoveryKeyEscrowPrepareDevice = "prepare-device"
+)
+
+// RecoveryKeyEscrow describes how the recovery key of an encrypted device is
+// escrowed with the brand. The recovery key is only ever stored outside of
+// the encrypted partitions encrypted to the public key of the brand.
+type RecoveryKeyEscrow struct {
+	// PublicKey is the path in the gadget of the PEM encoded RSA public
+	// key of the brand.
+	PublicKey string `yaml:"public-key"`
+	// Destination is where the escrowed key goes, RecoveryKeyEscrowSeed
+	// (the default) or RecoveryKeyEscrowPrepareDevice.
+	Destination string `yaml:"destination,omitempty"`
 }
 
 // Volume defines the structure and content for the image to be written into a
@@ -115,6 +154,9 @@ type Volume struct {
 	ID string `yaml:"id" json:"id"`
 	// Structure describes the structures that are part of the volume
 	Structure []VolumeStructure `yaml:"structure" json:"structure"`
+	// Update describes how the volume is updated along with the other
+	// volumes of the gadget
+	Update *VolumeUpdateSettings `yaml:"update,omitempty" json:"update,omitempty"`
 	// Name is the name of the volume from the gadget.yaml
 	Name string `json:"-"`
 }
@@ -161,6 +203,10 @@ type VolumeStructure struct {
 	// Content of the structure
 	Content []VolumeContent `yaml:"content" json:"content"`
 	Update  VolumeUpdate    `yaml:"update" json:"update"`
+	// EMMCBootEnable, for structures of volumes using the "emmc" schema,
+	// requests that the eMMC device is set up to boot from the hardware
+	// boot partition of the structure when it is updated.
+	EMMCBootEnable bool `yaml:"emmc-boot-enable,omitempty" json:"emmc-boot-enable,omitempty"`
 
 	// Note that the Device field will never be part of the yaml
 	// and just used as part of the POST /systems/<label> API that
@@ -169,6 +215,13 @@ type VolumeStructure struct {
 }
 
 // HasFilesystem returns true if the structure is using a filesystem.
+// IsExperimentalFilesystem returns true for the filesystems which can only be
+// used to install systems when the experimental.gadget-extra-filesystems
+// feature is enabled.
+func IsExperimentalFilesystem(fs string) bool {
+	return fs == "btrfs" || fs == "f2fs"
+}
+
 func (vs *VolumeStructure) HasFilesystem() bool {
 	return vs.Filesystem != "none" && vs.Filesystem != ""
 }
@@ -217,6 +270,41 @@ type VolumeUpdate struct {
 	Preserve []string       `yaml:"preserve" json:"preserve"`
 }
 
+const (
+	// VolumeUpdatePolicyEdition updates the structures of the volume whose
+	// edition was increased, it is the default.
+	VolumeUpdatePolicyEdition = "edition"
+	// VolumeUpdatePolicyNone never updates the structures of the volume,
+	// e.g. because they are updated by other means.
+	VolumeUpdatePolicyNone = "none"
+)
+
+// VolumeUpdateSettings describes how a volume is updated when the gadget
+// is refreshed, for gadgets whose bootloader spans several volumes. The
+// updates of all the volumes are applied, or rolled back, together.
+type VolumeUpdateSettings struct {
+	// After lists the volumes whose structures must be updated before
+	// the structures of this volume
+	After []string `yaml:"after" json:"after,omitempty"`
+	// Policy is the update policy of the volume, one of "edition" (the
+	// default) or "none"
+	Policy string `yaml:"policy" json:"policy,omitempty"`
+}
+
+func (v *Volume) updatePolicy() string {
+	if v.Update == nil || v.Update.Policy == "" {
+		return VolumeUpdatePolicyEdition
+	}
+	return v.Update.Policy
+}
+
+func (v *Volume) updateAfter() []string {
+	if v.Update == nil {
+		return nil
+	}
+	return v.Update.After
+}
+
 // DiskVolumeDeviceTraits is a set of traits about a disk that were measured at
 // a previous point in time on the same device, and is used primarily to try and
 // map a volume in the gadget.yaml to a physical device on the system after the
@@ -389,6 +477,11 @@ func AllDiskVolumeDeviceTraits(allLaidOutVols map[string]*LaidOutVolume, optsPer
 	// find all devices which map to volumes to save the current state of the
 	// system
 	for name, vol := range allLaidOutVols {
+		if vol.Schema == schemaEMMC {
+			// the eMMC boot partitions are not partitioned disks, they
+			// are located when updating them instead
+			continue
+		}
 		// try to find a device for a structure inside the volume, we have a
 		// loop to attempt to use all structures in the volume in case there are
 		// partitions we can't map to a device directly at first using the
@@ -581,6 +674,18 @@ func InfoFromGadgetYaml(gadgetYaml []byte, model Model) (*Info, error) {
 		}
 	}
 
+	if gi.FactoryBoots < 0 {
+		return nil, fmt.Errorf("invalid factory-boots: %d cannot be negative", gi.FactoryBoots)
+	}
+
+	if err := validateSystemBanks(gi.SystemBanks, model); err != nil {
+		return nil, err
+	}
+
+	if err := validateRecoveryKeyEscrow(gi.RecoveryKeyEscrow, model); err != nil {
+		return nil, err
+	}
+
 	if len(gi.Volumes) == 0 && classicOrUndetermined(model) {
 		// volumes can be left out on classic
 		// can still specify defaults though
@@ -628,9 +733,134 @@ func InfoFromGadgetYaml(gadgetYaml []byte, model Model) (*Info, error) {
 		}
 	}
 
+	if err := validateVolumesUpdate(gi.Volumes); err != nil {
+		return nil, err
+	}
+
 	return &gi, nil
 }
 
+func validateVolumesUpdate(volumes map[string]*Volume) error {
+	for name, v := range volumes {
+		if v.Update == nil {
+			continue
+		}
+		switch v.Update.Policy {
+		case "", VolumeUpdatePolicyEdition, VolumeUpdatePolicyNone:
+			// valid
+		default:
+			return fmt.Errorf("invalid volume %q: invalid update policy %q", name, v.Update.Policy)
+		}
+		for i, after := range v.Update.After {
+			if after == name {
+				return fmt.Errorf("invalid volume %q: cannot update volume after itself", name)
+			}
+			if _, ok := volumes[after]; !ok {
+				return fmt.Errorf("invalid volume %q: cannot update volume after unknown volume %q", name, after)
+			}
+			if strutil.ListContains(v.Update.After[:i], after) {
+				return fmt.Errorf("invalid volume %q: volume %q listed more than once in update after", name, after)
+			}
+		}
+	}
+	if _, err := volumesUpdateOrder(volumes); err != nil {
+		return err
+	}
+	return nil
+}
+
+// volumesUpdateOrder returns the names of the given volumes in the order
+// their structures are updated: a volume comes after the volumes it is
+// declared to be updated after, otherwise volumes are sorted by name.
+func volumesUpdateOrder(volumes map[string]*Volume) ([]string, error) {
+	names := make([]string, 0, len(volumes))
+	for name := range volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	order := make([]string, 0, len(volumes))
+	ordered := make(map[string]bool, len(volumes))
+	for len(order) != len(names) {
+		progress := false
+		for _, name := range names {
+			if ordered[name] {
+				continue
+			}
+			ready := true
+			for _, after := range volumes[name].updateAfter() {
+				if _, ok := volumes[after]; ok && !ordered[after] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				order = append(order, name)
+				ordered[name] = true
+				progress = true
+				// restart from the first volume by name
+				break
+			}
+		}
+		if !progress {
+			var cycle []string
+			for _, name := range names {
+				if !ordered[name] {
+					cycle = append(cycle, name)
+				}
+			}
+			return nil, fmt.Errorf("cannot order the update of volumes %s: update dependencies form a cycle", strutil.Quoted(cycle))
+		}
+	}
+	return order, nil
+}
+
+var validSystemBankName = regexp.MustCompile(`^[a-z0-9]+$`)
+
+func validateSystemBanks(banks []string, model Model) error {
+	if len(banks) == 0 {
+		return nil
+	}
+	if hasGrade(model) {
+		return errors.New("system-banks valid only for UC16/18 models")
+	}
+	if len(banks) != 2 {
+		return fmt.Errorf("invalid system-banks: expected exactly 2 banks, got %d", len(banks))
+	}
+	for _, bank := range banks {
+		if !validSystemBankName.MatchString(bank) {
+			return fmt.Errorf("invalid system-banks: invalid bank name %q", bank)
+		}
+	}
+	if banks[0] == banks[1] {
+		return fmt.Errorf("invalid system-banks: bank %q declared twice", banks[0])
+	}
+	return nil
+}
+
+func validateRecoveryKeyEscrow(escrow *RecoveryKeyEscrow, model Model) error {
+	if escrow == nil {
+		return nil
+	}
+	if model != nil && !hasGrade(model) {
+		return errors.New("recovery-key-escrow valid only for UC20+ models")
+	}
+	if escrow.PublicKey == "" {
+		return errors.New("invalid recovery-key-escrow: public-key is mandatory")
+	}
+	if filepath.IsAbs(escrow.PublicKey) || escrow.PublicKey != filepath.Clean(escrow.PublicKey) || strings.HasPrefix(escrow.PublicKey, "../") {
+		return fmt.Errorf("invalid recovery-key-escrow: public-key %q must be a clean relative path in the gadget", escrow.PublicKey)
+	}
+	switch escrow.Destination {
+	case "":
+		escrow.Destination = RecoveryKeyEscrowSeed
+	case RecoveryKeyEscrowSeed, RecoveryKeyEscrowPrepareDevice:
+	default:
+		return fmt.Errorf("invalid recovery-key-escrow: destination must be %q or %q", RecoveryKeyEscrowSeed, RecoveryKeyEscrowPrepareDevice)
+	}
+	return nil
+}
+
 type volRuleset int
 
 const (
@@ -782,9 +1012,12 @@ func validateVolume(vol *Volume, knownFsLabelsPerVolume map[string]map[string]bo
 	if !validVolumeName.MatchString(vol.Name) {
 		return errors.New("invalid name")
 	}
-	if vol.Schema != "" && vol.Schema != schemaGPT && vol.Schema != schemaMBR {
+	if vol.Schema != "" && vol.Schema != schemaGPT && vol.Schema != schemaMBR && vol.Schema != schemaEMMC {
 		return fmt.Errorf("invalid schema %q", vol.Schema)
 	}
+	if vol.Schema == schemaEMMC {
+		return validateEMMCVolume(vol)
+	}
 
 	// named structures, for cross-referencing relative offset-write names
 	knownStructures := make(map[string]*LaidOutStructure, len(vol.Structure))
@@ -841,6 +1074,62 @@ func validateVolume(vol *Volume, knownFsLabelsPerVolume map[string]map[string]bo
 	return validateCrossVolumeStructure(structures, knownStructures)
 }
 
+// validateEMMCVolume validates a volume describing the content of the
+// hardware boot partitions of an eMMC device.
+func validateEMMCVolume(vol *Volume) error {
+	knownStructures := make(map[string]bool, len(vol.Structure))
+	bootEnabled := false
+	for idx, s := range vol.Structure {
+		if err := validateEMMCVolumeStructure(&s); err != nil {
+			return fmt.Errorf("invalid structure %v: %v", fmtIndexAndName(idx, s.Name), err)
+		}
+		if knownStructures[s.Name] {
+			return fmt.Errorf("structure name %q is not unique", s.Name)
+		}
+		knownStructures[s.Name] = true
+		if s.EMMCBootEnable {
+			if bootEnabled {
+				return fmt.Errorf("more than one structure with emmc-boot-enable set")
+			}
+			bootEnabled = true
+		}
+	}
+	return nil
+}
+
+func validateEMMCVolumeStructure(vs *VolumeStructure) error {
+	if !strutil.ListContains(emmcBootPartitions, vs.Name) {
+		return fmt.Errorf("name must be one of %s", strutil.Quoted(emmcBootPartitions))
+	}
+	if vs.Size == 0 {
+		return errors.New("missing size")
+	}
+	if vs.Type != "bare" {
+		return fmt.Errorf(`invalid type %q: only "bare" is supported in eMMC volumes`, vs.Type)
+	}
+	if vs.Role != "" {
+		return fmt.Errorf("invalid role %q: roles are not supported in eMMC volumes", vs.Role)
+	}
+	if vs.HasFilesystem() {
+		return fmt.Errorf("invalid filesystem %q: filesystems are not supported in eMMC volumes", vs.Filesystem)
+	}
+	if vs.ID != "" || vs.Label != "" {
+		return errors.New("partition ID and filesystem label are not supported in eMMC volumes")
+	}
+	if vs.OffsetWrite != nil {
+		return errors.New("offset-write is not supported in eMMC volumes")
+	}
+	for i, c := range vs.Content {
+		if err := validateBareContent(&c); err != nil {
+			return fmt.Errorf("invalid content #%v: %v", i, err)
+		}
+		if c.OffsetWrite != nil {
+			return fmt.Errorf("invalid content #%v: offset-write is not supported in eMMC volumes", i)
+		}
+	}
+	return validateStructureUpdate(vs)
+}
+
 // isMBR returns whether the structure is the MBR and can be used before setImplicitForVolume
 func isMBR(vs *VolumeStructure) bool {
 	if vs.Role == schemaMBR {
@@ -901,6 +1190,9 @@ func validateVolumeStructure(vs *VolumeStructure, vol *Volume) error {
 	if vs.Size == 0 {
 		return errors.New("missing size")
 	}
+	if vs.EMMCBootEnable {
+		return errors.New(`emmc-boot-enable is only supported in volumes with "emmc" schema`)
+	}
 	if err := validateStructureType(vs.Type, vol); err != nil {
 		return fmt.Errorf("invalid type %q: %v", vs.Type, err)
 	}
@@ -913,9 +1205,12 @@ func validateVolumeStructure(vs *VolumeStructure, vol *Volume) error {
 		}
 		return fmt.Errorf("invalid %s: %v", what, err)
 	}
-	if vs.Filesystem != "" && !strutil.ListContains([]string{"ext4", "vfat", "none"}, vs.Filesystem) {
+	if vs.Filesystem != "" && !strutil.ListContains([]string{"ext4", "vfat", "none"}, vs.Filesystem) && !IsExperimentalFilesystem(vs.Filesystem) {
 		return fmt.Errorf("invalid filesystem %q", vs.Filesystem)
 	}
+	if IsExperimentalFilesystem(vs.Filesystem) && (vs.Role == SystemSeed || vs.Role == SystemSeedNull || vs.Role == SystemBoot) {
+		return fmt.Errorf("invalid filesystem %q: cannot be used for role %q", vs.Filesystem, vs.Role)
+	}
 
 	var contentChecker func(*VolumeContent) error
 
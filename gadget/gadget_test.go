@@ -699,6 +699,68 @@ func (s *gadgetYamlTestSuite) TestReadGadgetDefaultsMultiline(c *C) {
 	})
 }
 
+func (s *gadgetYamlTestSuite) TestReadGadgetFactoryBoots(c *C) {
+	ginfo, err := gadget.InfoFromGadgetYaml([]byte("factory-boots: 3\n"), classicMod)
+	c.Assert(err, IsNil)
+	c.Check(ginfo.FactoryBoots, Equals, 3)
+
+	_, err = gadget.InfoFromGadgetYaml([]byte("factory-boots: -1\n"), classicMod)
+	c.Assert(err, ErrorMatches, "invalid factory-boots: -1 cannot be negative")
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetSystemBanks(c *C) {
+	ginfo, err := gadget.InfoFromGadgetYaml([]byte("system-banks: [a, b]\n"), classicMod)
+	c.Assert(err, IsNil)
+	c.Check(ginfo.SystemBanks, DeepEquals, []string{"a", "b"})
+
+	for _, tc := range []struct {
+		yaml  string
+		model gadget.Model
+		err   string
+	}{
+		{"system-banks: [a]\n", classicMod, "invalid system-banks: expected exactly 2 banks, got 1"},
+		{"system-banks: [a, b, c]\n", classicMod, "invalid system-banks: expected exactly 2 banks, got 3"},
+		{"system-banks: [a, a]\n", classicMod, `invalid system-banks: bank "a" declared twice`},
+		{"system-banks: [a, B-1]\n", classicMod, `invalid system-banks: invalid bank name "B-1"`},
+		{"system-banks: [a, b]\n", uc20Mod, "system-banks valid only for UC16/18 models"},
+	} {
+		_, err := gadget.InfoFromGadgetYaml([]byte(tc.yaml), tc.model)
+		c.Check(err, ErrorMatches, tc.err, Commentf(tc.yaml))
+	}
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetRecoveryKeyEscrow(c *C) {
+	ginfo, err := gadget.InfoFromGadgetYaml([]byte("recovery-key-escrow:\n  public-key: escrow/brand.pem\n"), classicWithModesMod)
+	c.Assert(err, IsNil)
+	c.Check(ginfo.RecoveryKeyEscrow, DeepEquals, &gadget.RecoveryKeyEscrow{
+		PublicKey:   "escrow/brand.pem",
+		Destination: "ubuntu-seed",
+	})
+
+	ginfo, err = gadget.InfoFromGadgetYaml([]byte("recovery-key-escrow:\n  public-key: brand.pem\n  destination: prepare-device\n"), classicWithModesMod)
+	c.Assert(err, IsNil)
+	c.Check(ginfo.RecoveryKeyEscrow, DeepEquals, &gadget.RecoveryKeyEscrow{
+		PublicKey:   "brand.pem",
+		Destination: "prepare-device",
+	})
+
+	for _, tc := range []struct {
+		yaml  string
+		model gadget.Model
+		err   string
+	}{
+		{"recovery-key-escrow:\n  public-key: brand.pem\n", classicMod, "recovery-key-escrow valid only for UC20\\+ models"},
+		{"recovery-key-escrow:\n  destination: ubuntu-seed\n", classicWithModesMod, "invalid recovery-key-escrow: public-key is mandatory"},
+		{"recovery-key-escrow:\n  public-key: /brand.pem\n", classicWithModesMod, `invalid recovery-key-escrow: public-key "/brand.pem" must be a clean relative path in the gadget`},
+		{"recovery-key-escrow:\n  public-key: ../brand.pem\n", classicWithModesMod, `invalid recovery-key-escrow: public-key "../brand.pem" must be a clean relative path in the gadget`},
+		{"recovery-key-escrow:\n  public-key: a//brand.pem\n", classicWithModesMod, `invalid recovery-key-escrow: public-key "a//brand.pem" must be a clean relative path in the gadget`},
+		{"recovery-key-escrow:\n  public-key: brand.pem\n  destination: cloud\n", classicWithModesMod, `invalid recovery-key-escrow: destination must be "ubuntu-seed" or "prepare-device"`},
+	} {
+		_, err := gadget.InfoFromGadgetYaml([]byte(tc.yaml), tc.model)
+		c.Check(err, ErrorMatches, tc.err, Commentf(tc.yaml))
+	}
+}
+
 func asOffsetPtr(offs quantity.Offset) *quantity.Offset {
 	goff := offs
 	return &goff
@@ -833,6 +895,94 @@ func (s *gadgetYamlTestSuite) TestReadMultiVolumeGadgetYamlValid(c *C) {
 	})
 }
 
+var mockMultiVolumeUpdateGadgetYaml = `
+volumes:
+  emmc:
+    schema: mbr
+    bootloader: u-boot
+    update:
+      after: [nor]
+    structure:
+      - name: system-boot
+        role: system-boot
+        filesystem: vfat
+        type: 0C
+        size: 128M
+  nor:
+    schema: gpt
+    update:
+      policy: %s
+    structure:
+      - name: spl
+        type: bare
+        size: 1M
+`
+
+func (s *gadgetYamlTestSuite) TestReadMultiVolumeGadgetYamlUpdateSettings(c *C) {
+	ginfo, err := gadget.InfoFromGadgetYaml([]byte(fmt.Sprintf(mockMultiVolumeUpdateGadgetYaml, "none")), coreMod)
+	c.Assert(err, IsNil)
+	c.Check(ginfo.Volumes["emmc"].Update, DeepEquals, &gadget.VolumeUpdateSettings{
+		After: []string{"nor"},
+	})
+	c.Check(ginfo.Volumes["nor"].Update, DeepEquals, &gadget.VolumeUpdateSettings{
+		Policy: gadget.VolumeUpdatePolicyNone,
+	})
+
+	_, err = gadget.InfoFromGadgetYaml([]byte(fmt.Sprintf(mockMultiVolumeUpdateGadgetYaml, "edition")), coreMod)
+	c.Assert(err, IsNil)
+	_, err = gadget.InfoFromGadgetYaml([]byte(fmt.Sprintf(mockMultiVolumeUpdateGadgetYaml, "always")), coreMod)
+	c.Assert(err, ErrorMatches, `invalid volume "nor": invalid update policy "always"`)
+}
+
+func (s *gadgetYamlTestSuite) TestReadMultiVolumeGadgetYamlUpdateAfterErrors(c *C) {
+	const yaml = `
+volumes:
+  vol-a:
+    schema: mbr
+    bootloader: u-boot
+    update:
+      after: [%s]
+    structure:
+      - name: system-boot
+        role: system-boot
+        filesystem: vfat
+        type: 0C
+        size: 128M
+  vol-b:
+    schema: gpt
+    update:
+      after: [%s]
+    structure:
+      - name: spl
+        type: bare
+        size: 1M
+  vol-c:
+    schema: gpt
+    structure:
+      - name: tpl
+        type: bare
+        size: 1M
+`
+	for _, tc := range []struct {
+		afterA, afterB string
+		err            string
+	}{
+		{"vol-b", "vol-c", ""},
+		{"vol-b, vol-c", "vol-c", ""},
+		{"vol-a", "", `invalid volume "vol-a": cannot update volume after itself`},
+		{"vol-d", "", `invalid volume "vol-a": cannot update volume after unknown volume "vol-d"`},
+		{"vol-b, vol-b", "", `invalid volume "vol-a": volume "vol-b" listed more than once in update after`},
+		{"vol-b", "vol-a", `cannot order the update of volumes "vol-a", "vol-b": update dependencies form a cycle`},
+	} {
+		_, err := gadget.InfoFromGadgetYaml([]byte(fmt.Sprintf(yaml, tc.afterA, tc.afterB)), coreMod)
+		if tc.err == "" {
+			c.Check(err, IsNil, Commentf("%v", tc))
+		} else {
+			c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc))
+		}
+	}
+}
+
 func (s *gadgetYamlTestSuite) TestReadGadgetYamlInvalidBootloader(c *C) {
 	mockGadgetYamlBroken := []byte(`
 volumes:
@@ -1254,7 +1404,9 @@ func (s *gadgetYamlTestSuite) TestValidateFilesystem(c *C) {
 		{"vfat", ""},
 		{"ext4", ""},
 		{"none", ""},
-		{"btrfs", `invalid filesystem "btrfs"`},
+		{"btrfs", ""},
+		{"f2fs", ""},
+		{"xfs", `invalid filesystem "xfs"`},
 	} {
 		c.Logf("tc: %v %+v", i, tc.s)
 
@@ -1267,6 +1419,25 @@ func (s *gadgetYamlTestSuite) TestValidateFilesystem(c *C) {
 	}
 }
 
+func (s *gadgetYamlTestSuite) TestValidateExperimentalFilesystemRoles(c *C) {
+	for _, role := range []string{gadget.SystemData, gadget.SystemSave, ""} {
+		for _, fs := range []string{"btrfs", "f2fs"} {
+			vs := &gadget.VolumeStructure{Name: "part", Filesystem: fs, Type: "83,0FC63DAF-8483-4772-8E79-3D69D8477DE4", Size: 123, Role: role}
+			if role != "" {
+				vs.Label = role
+			}
+			c.Check(gadget.ValidateVolumeStructure(vs, &gadget.Volume{}), IsNil, Commentf("role %q fs %q", role, fs))
+		}
+	}
+	for _, role := range []string{gadget.SystemSeed, gadget.SystemBoot} {
+		vs := &gadget.VolumeStructure{Name: "part", Filesystem: "f2fs", Type: "83,0FC63DAF-8483-4772-8E79-3D69D8477DE4", Size: 123, Role: role, Label: role}
+		c.Check(gadget.ValidateVolumeStructure(vs, &gadget.Volume{}), ErrorMatches, fmt.Sprintf(`invalid filesystem "f2fs": cannot be used for role %q`, role))
+	}
+	c.Check(gadget.IsExperimentalFilesystem("btrfs"), Equals, true)
+	c.Check(gadget.IsExperimentalFilesystem("f2fs"), Equals, true)
+	c.Check(gadget.IsExperimentalFilesystem("ext4"), Equals, false)
+}
+
 func (s *gadgetYamlTestSuite) TestValidateVolumeSchema(c *C) {
 	for i, tc := range []struct {
 		s   string
@@ -1274,6 +1445,7 @@ func (s *gadgetYamlTestSuite) TestValidateVolumeSchema(c *C) {
 	}{
 		{"gpt", ""},
 		{"mbr", ""},
+		{"emmc", ""},
 		// implicit GPT
 		{"", ""},
 		// invalid
@@ -1290,6 +1462,54 @@ func (s *gadgetYamlTestSuite) TestValidateVolumeSchema(c *C) {
 	}
 }
 
+func (s *gadgetYamlTestSuite) TestValidateVolumeEMMC(c *C) {
+	valid := []gadget.VolumeStructure{
+		{Name: "boot0", Type: "bare", Size: 4096, Content: []gadget.VolumeContent{{Image: "spl.img"}}},
+		{Name: "boot1", Type: "bare", Size: 4096, EMMCBootEnable: true, Content: []gadget.VolumeContent{{Image: "spl.img"}}},
+	}
+	err := gadget.ValidateVolume(&gadget.Volume{Name: "emmc", Schema: "emmc", Structure: valid}, nil)
+	c.Check(err, IsNil)
+
+	offset := quantity.Offset(512)
+	for i, tc := range []struct {
+		vs  gadget.VolumeStructure
+		err string
+	}{
+		{gadget.VolumeStructure{Name: "boot2", Type: "bare", Size: 1024}, `invalid structure #0 \("boot2"\): name must be one of "boot0", "boot1"`},
+		{gadget.VolumeStructure{Name: "boot0", Type: "bare"}, `invalid structure #0 \("boot0"\): missing size`},
+		{gadget.VolumeStructure{Name: "boot0", Type: "83", Size: 1024}, `invalid structure #0 \("boot0"\): invalid type "83": only "bare" is supported in eMMC volumes`},
+		{gadget.VolumeStructure{Name: "boot0", Type: "bare", Size: 1024, Role: "system-boot"}, `invalid structure #0 \("boot0"\): invalid role "system-boot": roles are not supported in eMMC volumes`},
+		{gadget.VolumeStructure{Name: "boot0", Type: "bare", Size: 1024, Filesystem: "vfat"}, `invalid structure #0 \("boot0"\): invalid filesystem "vfat": filesystems are not supported in eMMC volumes`},
+		{gadget.VolumeStructure{Name: "boot0", Type: "bare", Size: 1024, OffsetWrite: &gadget.RelativeOffset{Offset: 10}}, `invalid structure #0 \("boot0"\): offset-write is not supported in eMMC volumes`},
+		{gadget.VolumeStructure{Name: "boot0", Type: "bare", Size: 1024, Content: []gadget.VolumeContent{{UnresolvedSource: "foo", Target: "/"}}}, `invalid structure #0 \("boot0"\): invalid content #0: cannot use non-image content for bare file system`},
+		{gadget.VolumeStructure{Name: "boot0", Type: "bare", Size: 1024, Content: []gadget.VolumeContent{{Image: "foo", OffsetWrite: &gadget.RelativeOffset{Offset: 10}}}}, `invalid structure #0 \("boot0"\): invalid content #0: offset-write is not supported in eMMC volumes`},
+		// offsets within the boot partition are fine
+		{gadget.VolumeStructure{Name: "boot0", Type: "bare", Size: 1024, Offset: &offset}, ""},
+	} {
+		c.Logf("tc: %v %+v", i, tc.vs)
+		err := gadget.ValidateVolume(&gadget.Volume{Name: "emmc", Schema: "emmc", Structure: []gadget.VolumeStructure{tc.vs}}, nil)
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err)
+		} else {
+			c.Check(err, IsNil)
+		}
+	}
+
+	err = gadget.ValidateVolume(&gadget.Volume{Name: "emmc", Schema: "emmc", Structure: []gadget.VolumeStructure{valid[0], valid[0]}}, nil)
+	c.Check(err, ErrorMatches, `structure name "boot0" is not unique`)
+
+	bothEnabled := []gadget.VolumeStructure{valid[0], valid[1]}
+	bothEnabled[0].EMMCBootEnable = true
+	err = gadget.ValidateVolume(&gadget.Volume{Name: "emmc", Schema: "emmc", Structure: bothEnabled}, nil)
+	c.Check(err, ErrorMatches, `more than one structure with emmc-boot-enable set`)
+
+	// emmc-boot-enable is not supported outside of eMMC volumes
+	err = gadget.ValidateVolume(&gadget.Volume{Name: "pc", Schema: "gpt", Structure: []gadget.VolumeStructure{
+		{Name: "boot", Type: "bare", Size: 1024, EMMCBootEnable: true},
+	}}, nil)
+	c.Check(err, ErrorMatches, `invalid structure #0 \("boot"\): emmc-boot-enable is only supported in volumes with "emmc" schema`)
+}
+
 func (s *gadgetYamlTestSuite) TestValidateVolumeName(c *C) {
 
 	for i, tc := range []struct {
@@ -24,6 +24,7 @@ package install
 import (
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/kernel/fde"
+	"github.com/snapcore/snapd/secboot"
 	"github.com/snapcore/snapd/secboot/keys"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -41,8 +42,26 @@ func MockSecbootFormatEncryptedDevice(f func(key keys.EncryptionKey, label, node
 
 }
 
+func MockSecbootAddPassphrase(f func(key keys.EncryptionKey, passphrase, node string) error) (restore func()) {
+	r := testutil.Backup(&secbootAddPassphrase)
+	secbootAddPassphrase = f
+	return r
+}
+
 func MockBootRunFDESetupHook(f func(req *fde.SetupRequest) ([]byte, error)) (restore func()) {
 	r := testutil.Backup(&boot.RunFDESetupHook)
 	boot.RunFDESetupHook = f
 	return r
 }
+
+func BuildEncryptionSetupDataWithRawDevices(labelToDevice map[string]string, encryptionType secboot.EncryptionType) *EncryptionSetupData {
+	esd := &EncryptionSetupData{
+		parts: map[string]partEncryptionData{}}
+	for label, dev := range labelToDevice {
+		esd.parts[label] = partEncryptionData{
+			device:           dev,
+			encryptionParams: createEncryptionParams(encryptionType),
+		}
+	}
+	return esd
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/boot"
@@ -675,6 +676,27 @@ func EncryptPartitions(onVolumes map[string]*gadget.Volume, encryptionType secbo
 	return setupData, nil
 }
 
+// AddPassphrase adds the given passphrase to the encrypted partitions of
+// the setup data, so that they can be unlocked with it.
+func AddPassphrase(setupData *EncryptionSetupData, passphrase string) error {
+	names := make([]string, 0, len(setupData.parts))
+	for name := range setupData.parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := setupData.parts[name]
+		if p.encryptionParams.Method != gadget.EncryptionLUKS {
+			return fmt.Errorf("cannot add passphrase to %q: unsupported encryption method %q", p.device, p.encryptionParams.Method)
+		}
+		logger.Debugf("adding passphrase to partition %s", p.device)
+		if err := secbootAddPassphrase(p.encryptionKey, passphrase, p.device); err != nil {
+			return fmt.Errorf("cannot add passphrase to %q: %v", p.device, err)
+		}
+	}
+	return nil
+}
+
 func KeysForRole(setupData *EncryptionSetupData) map[string]keys.EncryptionKey {
 	keyForRole := make(map[string]keys.EncryptionKey)
 	for _, p := range setupData.parts {
@@ -60,6 +60,10 @@ func EncryptPartitions(onVolumes map[string]*gadget.Volume, encryptionType secbo
 	return nil, fmt.Errorf("build without secboot support")
 }
 
+func AddPassphrase(setupData *EncryptionSetupData, passphrase string) error {
+	return fmt.Errorf("build without secboot support")
+}
+
 func KeysForRole(setupData *EncryptionSetupData) map[string]keys.EncryptionKey {
 	return nil
 }
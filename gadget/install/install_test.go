@@ -1108,6 +1108,7 @@ func (s *installSuite) TestInstallWriteContentDeviceNotFound(c *C) {
 
 type encryptPartitionsOpts struct {
 	encryptType secboot.EncryptionType
+	passphrase  string
 }
 
 func (s *installSuite) testEncryptPartitions(c *C, opts encryptPartitionsOpts) {
@@ -1155,6 +1156,25 @@ func (s *installSuite) testEncryptPartitions(c *C, opts encryptPartitionsOpts) {
 		{"cryptsetup", "config", "--priority", "prefer", "--key-slot", "0", "/dev/vda5"},
 		{"cryptsetup", "open", "--key-file", "-", "/dev/vda5", "ubuntu-data"},
 	})
+	c.Check(encryptSetup.RawDevices(), DeepEquals, map[string]string{
+		"system-save": "/dev/vda4",
+		"system-data": "/dev/vda5",
+	})
+
+	if opts.passphrase == "" {
+		return
+	}
+	var passphraseDevs []string
+	restore = install.MockSecbootAddPassphrase(func(key keys.EncryptionKey, passphrase, node string) error {
+		c.Check(key, HasLen, 32)
+		c.Check(passphrase, Equals, opts.passphrase)
+		passphraseDevs = append(passphraseDevs, node)
+		return nil
+	})
+	defer restore()
+	err = install.AddPassphrase(encryptSetup, opts.passphrase)
+	c.Assert(err, IsNil)
+	c.Check(passphraseDevs, DeepEquals, []string{"/dev/vda5", "/dev/vda4"})
 }
 
 func (s *installSuite) TestInstallEncryptPartitionsLUKSHappy(c *C) {
@@ -1163,6 +1183,28 @@ func (s *installSuite) TestInstallEncryptPartitionsLUKSHappy(c *C) {
 	})
 }
 
+func (s *installSuite) TestInstallEncryptPartitionsLUKSWithPassphraseHappy(c *C) {
+	s.testEncryptPartitions(c, encryptPartitionsOpts{
+		encryptType: secboot.EncryptionTypeLUKS,
+		passphrase:  "correct horse battery staple",
+	})
+}
+
+func (s *installSuite) TestInstallAddPassphraseError(c *C) {
+	restore := install.MockSecbootAddPassphrase(func(key keys.EncryptionKey, passphrase, node string) error {
+		return fmt.Errorf("boom")
+	})
+	defer restore()
+
+	esd := install.BuildEncryptionSetupDataWithRawDevices(map[string]string{"ubuntu-data": "/dev/vda5"}, secboot.EncryptionTypeLUKS)
+	err := install.AddPassphrase(esd, "passphrase")
+	c.Check(err, ErrorMatches, `cannot add passphrase to "/dev/vda5": boom`)
+
+	esd = install.BuildEncryptionSetupDataWithRawDevices(map[string]string{"ubuntu-data": "/dev/vda5"}, secboot.EncryptionTypeDeviceSetupHook)
+	err = install.AddPassphrase(esd, "passphrase")
+	c.Check(err, ErrorMatches, `cannot add passphrase to "/dev/vda5": unsupported encryption method "ICE"`)
+}
+
 func (s *installSuite) TestInstallEncryptPartitionsNoDeviceSet(c *C) {
 	vdaSysPath := "/sys/devices/pci0000:00/0000:00:03.0/virtio1/block/vda"
 	restore := install.MockSysfsPathForBlockDevice(func(device string) (string, error) {
@@ -71,3 +71,13 @@ func (esd *EncryptionSetupData) EncryptedDevices() map[string]string {
 	}
 	return m
 }
+
+// RawDevices returns a map partition role -> device node of the partition
+// holding the LUKS container.
+func (esd *EncryptionSetupData) RawDevices() map[string]string {
+	m := make(map[string]string, len(esd.parts))
+	for _, p := range esd.parts {
+		m[p.role] = p.device
+	}
+	return m
+}
@@ -155,10 +155,17 @@ func layoutVolumeStructures(volume *Volume, constraints LayoutConstraints) (stru
 	structures = make([]LaidOutStructure, len(volume.Structure))
 	byName = make(map[string]*LaidOutStructure, len(volume.Structure))
 
+	// structures of eMMC volumes are each located in their own hardware
+	// boot partition, their offsets are relative to the start of that
+	// partition
+	isEMMC := volume.Schema == schemaEMMC
+
 	for idx, s := range volume.Structure {
 		var start quantity.Offset
 		if s.Offset == nil {
-			if s.Role != schemaMBR && previousEnd < constraints.NonMBRStartOffset {
+			if isEMMC {
+				start = 0
+			} else if s.Role != schemaMBR && previousEnd < constraints.NonMBRStartOffset {
 				start = constraints.NonMBRStartOffset
 			} else {
 				start = previousEnd
@@ -188,7 +195,7 @@ func layoutVolumeStructures(volume *Volume, constraints LayoutConstraints) (stru
 
 	previousEnd = quantity.Offset(0)
 	for idx, ps := range structures {
-		if ps.StartOffset < previousEnd {
+		if ps.StartOffset < previousEnd && !isEMMC {
 			return nil, nil, fmt.Errorf("cannot lay out volume, structure %v overlaps with preceding structure %v", ps, structures[idx-1])
 		}
 		previousEnd = ps.StartOffset + quantity.Offset(ps.Size)
@@ -120,6 +120,52 @@ volumes:
 	})
 }
 
+func (p *layoutTestSuite) TestLayoutVolumeEMMC(c *C) {
+	gadgetYaml := `
+volumes:
+  main:
+    bootloader: u-boot
+    structure:
+        - type: 83,00000000-0000-0000-0000-0000feedface
+          role: system-data
+          size: 100M
+  emmc-boot:
+    schema: emmc
+    structure:
+        - name: boot0
+          type: bare
+          size: 1M
+          content:
+            - image: spl.img
+        - name: boot1
+          type: bare
+          offset: 1024
+          size: 1M
+          emmc-boot-enable: true
+          content:
+            - image: spl.img
+`
+	makeSizedFile(c, filepath.Join(p.dir, "spl.img"), 512, nil)
+
+	vol := mustParseVolume(c, gadgetYaml, "emmc-boot")
+	c.Assert(vol.Structure, HasLen, 2)
+	c.Check(vol.Structure[1].EMMCBootEnable, Equals, true)
+
+	opts := &gadget.LayoutOptions{GadgetRootDir: p.dir}
+	v, err := gadget.LayoutVolume(vol, defaultConstraints, opts)
+	c.Assert(err, IsNil)
+
+	// each structure is laid out relative to its own boot partition
+	c.Assert(v.LaidOutStructure, HasLen, 2)
+	c.Check(v.LaidOutStructure[0].YamlIndex, Equals, 0)
+	c.Check(v.LaidOutStructure[0].StartOffset, Equals, quantity.Offset(0))
+	c.Check(v.LaidOutStructure[0].LaidOutContent, HasLen, 1)
+	c.Check(v.LaidOutStructure[0].LaidOutContent[0].StartOffset, Equals, quantity.Offset(0))
+	c.Check(v.LaidOutStructure[1].YamlIndex, Equals, 1)
+	c.Check(v.LaidOutStructure[1].StartOffset, Equals, quantity.Offset(1024))
+	c.Check(v.LaidOutStructure[1].LaidOutContent[0].StartOffset, Equals, quantity.Offset(1024))
+}
+
 func (p *layoutTestSuite) TestLayoutVolumeImplicitOrdering(c *C) {
 	gadgetYaml := `
 volumes:
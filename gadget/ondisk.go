@@ -173,3 +173,25 @@ func OnDiskStructureFromPartition(p disks.Partition) (OnDiskStructure, error) {
 		Node:      p.KernelDeviceNode,
 	}, nil
 }
+
+// RoleFromFilesystemLabel returns the role of the structure carrying the
+// given filesystem label, as recognized by the labels the roles are given
+// on install, and whether the label is the one of the encrypted container
+// of the structure. An empty role is returned for other labels.
+func RoleFromFilesystemLabel(label string) (role string, encrypted bool) {
+	switch label {
+	case ubuntuSeedLabel:
+		return SystemSeed, false
+	case ubuntuBootLabel, SystemBoot:
+		return SystemBoot, false
+	case ubuntuDataLabel, implicitSystemDataLabel:
+		return SystemData, false
+	case ubuntuDataLabel + "-enc":
+		return SystemData, true
+	case ubuntuSaveLabel:
+		return SystemSave, false
+	case ubuntuSaveLabel + "-enc":
+		return SystemSave, true
+	}
+	return "", false
+}
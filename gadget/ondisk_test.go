@@ -467,3 +467,27 @@ func (s *ondiskTestSuite) TestOnDiskStructureFromPartition(c *C) {
 		Node:      "/dev/sda2",
 	})
 }
+
+func (s *ondiskTestSuite) TestRoleFromFilesystemLabel(c *C) {
+	for _, t := range []struct {
+		label     string
+		role      string
+		encrypted bool
+	}{
+		{"ubuntu-seed", gadget.SystemSeed, false},
+		{"ubuntu-boot", gadget.SystemBoot, false},
+		{"system-boot", gadget.SystemBoot, false},
+		{"ubuntu-data", gadget.SystemData, false},
+		{"writable", gadget.SystemData, false},
+		{"ubuntu-data-enc", gadget.SystemData, true},
+		{"ubuntu-save", gadget.SystemSave, false},
+		{"ubuntu-save-enc", gadget.SystemSave, true},
+		{"ubuntu-seed-enc", "", false},
+		{"other", "", false},
+		{"", "", false},
+	} {
+		role, encrypted := gadget.RoleFromFilesystemLabel(t.label)
+		c.Check(role, Equals, t.role, Commentf("%q", t.label))
+		c.Check(encrypted, Equals, t.encrypted, Commentf("%q", t.label))
+	}
+}
@@ -279,7 +279,7 @@ func (r *rawStructureUpdater) Rollback() error {
 	return nil
 }
 
-func (r *rawStructureUpdater) updateDifferent(disk io.WriteSeeker, pc *LaidOutContent) error {
+func (r *rawStructureUpdater) updateDifferent(disk io.WriteSeeker, device string, pc *LaidOutContent) error {
 	backupPath := rawContentBackupPath(r.backupDir, r.ps, pc)
 
 	if osutil.FileExists(backupPath + ".same") {
@@ -293,6 +293,12 @@ func (r *rawStructureUpdater) updateDifferent(disk io.WriteSeeker, pc *LaidOutCo
 		return fmt.Errorf("missing backup file")
 	}
 
+	// record where the backup goes, so that it can be restored even
+	// without the gadget data, see RestoreRawRollbackSnapshots()
+	if err := writeRawContentLocation(backupPath, device, pc); err != nil {
+		return err
+	}
+
 	if err := r.writeRawImage(disk, pc); err != nil {
 		return err
 	}
@@ -316,7 +322,7 @@ func (r *rawStructureUpdater) Update() error {
 
 	skipped := 0
 	for _, pc := range structForDevice.LaidOutContent {
-		if err := r.updateDifferent(disk, &pc); err != nil {
+		if err := r.updateDifferent(disk, device, &pc); err != nil {
 			if err == ErrNoUpdate {
 				skipped++
 				continue
@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// rawContentLocation describes where the backup of a raw content region
+// was read from, and thus where it needs to be written back to.
+type rawContentLocation struct {
+	Device string          `json:"device"`
+	Offset quantity.Offset `json:"offset"`
+	Size   quantity.Size   `json:"size"`
+}
+
+func writeRawContentLocation(backupPath, device string, pc *LaidOutContent) error {
+	loc := rawContentLocation{
+		Device: device,
+		Offset: pc.StartOffset,
+		Size:   pc.Size,
+	}
+	data, err := json.Marshal(&loc)
+	if err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(backupPath+".location", data, 0644, 0); err != nil {
+		return fmt.Errorf("cannot record backup location: %v", err)
+	}
+	return nil
+}
+
+// rawSnapshots returns the base paths of the raw content backups in the
+// directory that have their location recorded, that is the ones of the
+// regions that were overwritten.
+func rawSnapshots(dir string) ([]string, error) {
+	locations, err := filepath.Glob(filepath.Join(dir, "struct-*.location"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(locations)
+	snapshots := make([]string, 0, len(locations))
+	for _, loc := range locations {
+		snapshots = append(snapshots, strings.TrimSuffix(loc, ".location"))
+	}
+	return snapshots, nil
+}
+
+// SaveRawRollbackSnapshots moves the previous content of the raw
+// structure regions overwritten by a gadget update from the update
+// rollback directory to the snapshot directory, so that it can be
+// written back with RestoreRawRollbackSnapshots should the system fail
+// to boot with the new content. Snapshots already present in the
+// snapshot directory are kept, as they hold older content. It returns
+// ErrNoUpdate if no raw content was overwritten by the update.
+func SaveRawRollbackSnapshots(rollbackDir, snapshotDir string) error {
+	snapshots, err := rawSnapshots(rollbackDir)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return ErrNoUpdate
+	}
+	if err := os.MkdirAll(snapshotDir, 0700); err != nil {
+		return fmt.Errorf("cannot create raw rollback snapshot directory: %v", err)
+	}
+	for _, snapshot := range snapshots {
+		target := filepath.Join(snapshotDir, filepath.Base(snapshot))
+		if osutil.FileExists(target + ".location") {
+			continue
+		}
+		// the backup goes first, a snapshot is complete only once
+		// its location is present
+		for _, ext := range []string{".backup", ".location"} {
+			if err := osutil.CopyFile(snapshot+ext, target+ext, osutil.CopyFlagSync|osutil.CopyFlagOverwrite); err != nil {
+				return fmt.Errorf("cannot save raw rollback snapshot: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// RestoreRawRollbackSnapshots writes the previous content of the raw
+// structure regions saved in the snapshot directory back to the devices
+// it was read from.
+func RestoreRawRollbackSnapshots(snapshotDir string) error {
+	snapshots, err := rawSnapshots(snapshotDir)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if err := restoreRawSnapshot(snapshot); err != nil {
+			return fmt.Errorf("cannot restore raw rollback snapshot %q: %v", filepath.Base(snapshot), err)
+		}
+	}
+	return nil
+}
+
+func restoreRawSnapshot(snapshot string) error {
+	data, err := os.ReadFile(snapshot + ".location")
+	if err != nil {
+		return err
+	}
+	var loc rawContentLocation
+	if err := json.Unmarshal(data, &loc); err != nil {
+		return fmt.Errorf("cannot decode location: %v", err)
+	}
+
+	backup, err := os.Open(snapshot + ".backup")
+	if err != nil {
+		return fmt.Errorf("cannot open backup image: %v", err)
+	}
+	defer backup.Close()
+
+	disk, err := os.OpenFile(loc.Device, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("cannot open device for writing: %v", err)
+	}
+	defer disk.Close()
+
+	pc := &LaidOutContent{StartOffset: loc.Offset, Size: loc.Size}
+	if err := writeRawStream(disk, pc, backup); err != nil {
+		return fmt.Errorf("cannot restore backup: %v", err)
+	}
+	return disk.Sync()
+}
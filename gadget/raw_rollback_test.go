@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package gadget_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (r *rawTestSuite) updateRawForSnapshots(c *C) (ps *gadget.LaidOutStructure, diskPath, pristinePath, expectedPath string) {
+	diskPath = filepath.Join(r.dir, "disk.img")
+	mutateFile(c, diskPath, 2048, []mutateWrite{
+		{[]byte("foo foo foo"), 0},
+		{[]byte("bar bar bar"), 1024},
+	})
+	pristinePath = filepath.Join(r.dir, "pristine.img")
+	err := osutil.CopyFile(diskPath, pristinePath, 0)
+	c.Assert(err, IsNil)
+
+	expectedPath = filepath.Join(r.dir, "expected.img")
+	mutateFile(c, expectedPath, 2048, []mutateWrite{
+		{[]byte("zzz zzz zzz zzz"), 0},
+		{[]byte("bar bar bar"), 1024},
+	})
+
+	// only the first image differs
+	makeSizedFile(c, filepath.Join(r.dir, "foo.img"), 128, []byte("zzz zzz zzz zzz"))
+	makeSizedFile(c, filepath.Join(r.dir, "bar.img"), 128, []byte("bar bar bar"))
+	ps = &gadget.LaidOutStructure{
+		VolumeStructure: &gadget.VolumeStructure{
+			Size: 2048,
+		},
+		YamlIndex: 1,
+		LaidOutContent: []gadget.LaidOutContent{
+			{
+				VolumeContent: &gadget.VolumeContent{
+					Image: "foo.img",
+				},
+				Size: 128,
+			}, {
+				VolumeContent: &gadget.VolumeContent{
+					Image: "bar.img",
+				},
+				StartOffset: 1024,
+				Size:        128,
+				Index:       1,
+			},
+		},
+	}
+	ru, err := gadget.NewRawStructureUpdater(r.dir, ps, r.backup, func(to *gadget.LaidOutStructure) (string, quantity.Offset, error) {
+		return diskPath, ps.StartOffset, nil
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(ru.Backup(), IsNil)
+	c.Assert(ru.Update(), IsNil)
+	c.Assert(osutil.FilesAreEqual(diskPath, expectedPath), Equals, true)
+	return ps, diskPath, pristinePath, expectedPath
+}
+
+func (r *rawTestSuite) TestRawRollbackSnapshotsSaveRestore(c *C) {
+	ps, diskPath, pristinePath, _ := r.updateRawForSnapshots(c)
+
+	snapshotDir := filepath.Join(c.MkDir(), "snapshots")
+	err := gadget.SaveRawRollbackSnapshots(r.backup, snapshotDir)
+	c.Assert(err, IsNil)
+
+	// only the overwritten region is kept
+	first := filepath.Join(snapshotDir, filepath.Base(gadget.RawContentBackupPath(r.backup, ps, &ps.LaidOutContent[0])))
+	second := filepath.Join(snapshotDir, filepath.Base(gadget.RawContentBackupPath(r.backup, ps, &ps.LaidOutContent[1])))
+	c.Check(first+".backup", testutil.FilePresent)
+	c.Check(first+".location", testutil.FilePresent)
+	c.Check(second+".backup", testutil.FileAbsent)
+	c.Check(second+".location", testutil.FileAbsent)
+
+	// the rollback directory can now go away
+	c.Assert(os.RemoveAll(r.backup), IsNil)
+
+	err = gadget.RestoreRawRollbackSnapshots(snapshotDir)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FilesAreEqual(diskPath, pristinePath), Equals, true)
+}
+
+func (r *rawTestSuite) TestRawRollbackSnapshotsKeepsOlder(c *C) {
+	ps, _, _, _ := r.updateRawForSnapshots(c)
+
+	snapshotDir := c.MkDir()
+	snapshot := filepath.Join(snapshotDir, filepath.Base(gadget.RawContentBackupPath(r.backup, ps, &ps.LaidOutContent[0])))
+	makeSizedFile(c, snapshot+".backup", 128, []byte("older"))
+	makeSizedFile(c, snapshot+".location", 0, []byte(`{"device":"/dev/foo","offset":0,"size":128}`))
+
+	err := gadget.SaveRawRollbackSnapshots(r.backup, snapshotDir)
+	c.Assert(err, IsNil)
+	c.Check(snapshot+".location", testutil.FileEquals, `{"device":"/dev/foo","offset":0,"size":128}`)
+	c.Check(snapshot+".backup", testutil.FileContains, "older")
+}
+
+func (r *rawTestSuite) TestRawRollbackSnapshotsNothingOverwritten(c *C) {
+	makeSizedFile(c, filepath.Join(r.backup, "struct-0-0.backup"), 128, nil)
+	makeSizedFile(c, filepath.Join(r.backup, "struct-0-1.same"), 0, nil)
+
+	snapshotDir := filepath.Join(c.MkDir(), "snapshots")
+	err := gadget.SaveRawRollbackSnapshots(r.backup, snapshotDir)
+	c.Assert(err, Equals, gadget.ErrNoUpdate)
+	c.Check(snapshotDir, testutil.FileAbsent)
+
+	// nothing to restore
+	err = gadget.RestoreRawRollbackSnapshots(snapshotDir)
+	c.Assert(err, IsNil)
+}
+
+func (r *rawTestSuite) TestRawRollbackSnapshotsRestoreErrors(c *C) {
+	snapshotDir := c.MkDir()
+	snapshot := filepath.Join(snapshotDir, "struct-1-0")
+	makeSizedFile(c, snapshot+".location", 0, []byte(`{"device":"`+filepath.Join(r.dir, "missing")+`","offset":0,"size":128}`))
+
+	err := gadget.RestoreRawRollbackSnapshots(snapshotDir)
+	c.Assert(err, ErrorMatches, `cannot restore raw rollback snapshot "struct-1-0": cannot open backup image: .*`)
+
+	makeSizedFile(c, snapshot+".backup", 128, nil)
+	err = gadget.RestoreRawRollbackSnapshots(snapshotDir)
+	c.Assert(err, ErrorMatches, `cannot restore raw rollback snapshot "struct-1-0": cannot open device for writing: .*`)
+
+	makeSizedFile(c, snapshot+".location", 0, []byte(`garbage`))
+	err = gadget.RestoreRawRollbackSnapshots(snapshotDir)
+	c.Assert(err, ErrorMatches, `cannot restore raw rollback snapshot "struct-1-0": cannot decode location: .*`)
+}
@@ -924,6 +924,11 @@ type StructureLocation struct {
 	// on the system, but this one is guaranteed to be writable and thus
 	// suitable for gadget asset updates.
 	RootMountPoint string
+
+	// EMMCBootPartition is set for raw structures located in a hardware
+	// boot partition of an eMMC device, in which case Device is the
+	// device node of that boot partition.
+	EMMCBootPartition bool
 }
 
 func buildVolumeStructureToLocation(mod Model,
@@ -1135,13 +1140,53 @@ func volumeStructureToLocationMapImpl(old GadgetData, mod Model, laidOutVols map
 	// because we just constructed it or that we were provided it the .json file
 	// we have to build up a map for the updaters to use to find the structure
 	// location to update given the LaidOutStructure
-	return buildVolumeStructureToLocation(
+	locations, err := buildVolumeStructureToLocation(
 		mod,
 		old,
 		laidOutVols,
 		volToDeviceMapping,
 		missingInitialMapping,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	// the hardware boot partitions of eMMC devices are not part of the
+	// mapping, locate them directly
+	for volName, vol := range old.Info.Volumes {
+		if vol.Schema != schemaEMMC {
+			continue
+		}
+		emmcLocations, err := buildEMMCVolumeStructureToLocation(vol, laidOutVols[volName])
+		if err != nil {
+			return nil, err
+		}
+		locations[volName] = emmcLocations
+	}
+	return locations, nil
+}
+
+// buildEMMCVolumeStructureToLocation builds the locations of the structures
+// of a volume describing the hardware boot partitions of an eMMC device.
+func buildEMMCVolumeStructureToLocation(vol *Volume, laidOutVol *LaidOutVolume) (map[int]StructureLocation, error) {
+	if laidOutVol == nil {
+		return nil, fmt.Errorf("internal error: missing LaidOutVolume for volume %s", vol.Name)
+	}
+	locations := make(map[int]StructureLocation, len(laidOutVol.LaidOutStructure))
+	// structures of eMMC volumes may all start at the same offset, use
+	// the YAML index rather than the laid out order
+	for _, ps := range laidOutVol.LaidOutStructure {
+		device, err := findEMMCBootPartition(ps.Name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot locate structure %d on volume %s: %v", ps.YamlIndex, vol.Name, err)
+		}
+		locations[ps.YamlIndex] = StructureLocation{
+			Device:            device,
+			Offset:            ps.StartOffset,
+			EMMCBootPartition: true,
+		}
+	}
+	return locations, nil
 }
 
 // Update applies the gadget update given the gadget information and data from
@@ -1158,6 +1203,11 @@ func volumeStructureToLocationMapImpl(old GadgetData, mod Model, laidOutVols map
 // rollback directory. Should the apply step fail, the modified data is
 // recovered.
 //
+// When the gadget has several volumes, their structures are updated volume
+// by volume, in the order declared with the update settings of the volumes,
+// and the updates of all the volumes are rolled back should any of them
+// fail. Volumes with the "none" update policy are never updated.
+//
 //
 // The rules for gadget/kernel updates with "$kernel:refs":
 //
@@ -1220,9 +1270,13 @@ func Update(model Model, old, new GadgetData, rollbackDirPath string, updatePoli
 	// we treat the whole gadget as invalid and return an error blocking the
 	// refresh
 
-	// TODO: should we handle the updates on multiple volumes in a
-	// deterministic order? iterating over maps is not deterministic, but we
-	// perform all updates at the end together in one call
+	// the updates of the volumes are applied in a deterministic order,
+	// honoring the declared dependencies between the volumes, but all
+	// together in one call at the end
+	volumesOrder, err := volumesUpdateOrder(new.Info.Volumes)
+	if err != nil {
+		return err
+	}
 
 	// ensure all required kernel assets are found in the gadget
 	kernelInfo, err := kernel.ReadInfo(new.KernelRootDir)
@@ -1249,7 +1303,8 @@ func Update(model Model, old, new GadgetData, rollbackDirPath string, updatePoli
 
 	allUpdates := []updatePair{}
 	laidOutVols := map[string]*LaidOutVolume{}
-	for volName, oldVol := range old.Info.Volumes {
+	for _, volName := range volumesOrder {
+		oldVol := old.Info.Volumes[volName]
 		newVol := new.Info.Volumes[volName]
 
 		if oldVol.Schema == "" || newVol.Schema == "" {
@@ -1274,6 +1329,11 @@ func Update(model Model, old, new GadgetData, rollbackDirPath string, updatePoli
 			return fmt.Errorf("cannot apply update to volume %s: %v", volName, err)
 		}
 
+		if newVol.updatePolicy() == VolumeUpdatePolicyNone {
+			logger.Debugf("skipping update of volume %s as per its update policy", volName)
+			continue
+		}
+
 		// if we haven't consumed any kernel assets yet check if this volume
 		// consumes at least one - we require at least one asset to be consumed
 		// by some volume in the gadget
@@ -1334,19 +1394,28 @@ func Update(model Model, old, new GadgetData, rollbackDirPath string, updatePoli
 	if len(new.Info.Volumes) != 1 {
 		logger.Debugf("gadget asset update routine for multiple volumes")
 
-		// check if the structure location map has only one volume in it - this
+		// check if the structure location map has only one volume in it,
+		// not counting eMMC boot partitions which are always located - this
 		// is the case in legacy update operations where we only support updates
 		// to the system-boot / main volume
-		if len(structureLocations) == 1 {
+		mappedVolumes := make([]string, 0, len(structureLocations))
+		for volName := range structureLocations {
+			if old.Info.Volumes[volName].Schema != schemaEMMC {
+				mappedVolumes = append(mappedVolumes, volName)
+			}
+		}
+		if len(mappedVolumes) == 1 {
 			// log a message and drop all updates to structures not in the
 			// volume we have
-			supportedVolume := ""
-			for volName := range structureLocations {
-				supportedVolume = volName
-			}
+			supportedVolume := mappedVolumes[0]
 			keepUpdates := make([]updatePair, 0, len(allUpdates))
 			for _, update := range allUpdates {
-				if update.volume.Name != supportedVolume {
+				if update.volume.Name != supportedVolume && update.volume.Schema != schemaEMMC {
+					if isOrderedVolume(new.Info.Volumes, update.volume.Name) {
+						// the volumes are updated all or
+						// nothing, do not update only some
+						return fmt.Errorf("cannot update volume structure %v on volume %s: volume is part of an ordered multi-volume update but cannot be located", update.to, update.volume.Name)
+					}
 					// TODO: or should we error here instead?
 					logger.Noticef("skipping update on non-supported volume %s to structure %s", update.volume.Name, update.to.Name)
 				} else {
@@ -1365,6 +1434,22 @@ func Update(model Model, old, new GadgetData, rollbackDirPath string, updatePoli
 	return nil
 }
 
+// isOrderedVolume returns whether the volume with the given name is
+// declared to be updated after other volumes or other volumes are declared
+// to be updated after it.
+func isOrderedVolume(volumes map[string]*Volume, name string) bool {
+	for volName, vol := range volumes {
+		after := vol.updateAfter()
+		if volName == name && len(after) != 0 {
+			return true
+		}
+		if strutil.ListContains(after, name) {
+			return true
+		}
+	}
+	return false
+}
+
 func resolveVolume(old *Info, new *Info) (oldVol, newVol *Volume, err error) {
 	// support only one volume
 	if len(new.Volumes) != 1 || len(old.Volumes) != 1 {
@@ -1413,8 +1498,9 @@ func isLegacyMBRTransition(from *LaidOutStructure, to *LaidOutStructure) bool {
 }
 
 func canUpdateStructure(from *LaidOutStructure, to *LaidOutStructure, schema string) error {
-	if schema == schemaGPT && from.Name != to.Name {
-		// partition names are only effective when GPT is used
+	if (schema == schemaGPT || schema == schemaEMMC) && from.Name != to.Name {
+		// partition names are only effective when GPT is used, and
+		// identify the boot partition of structures of eMMC volumes
 		return fmt.Errorf("cannot change structure name from %q to %q", from.Name, to.Name)
 	}
 	if from.Size != to.Size {
@@ -1649,8 +1735,9 @@ func applyUpdates(structureLocations map[string]map[int]StructureLocation, new G
 	}
 
 	logger.Noticef("cannot update gadget: %v", updateErr)
-	// not so good, rollback ones that got applied
-	for i := 0; i <= updateLastAttempted; i++ {
+	// not so good, rollback ones that got applied, in reverse order so that
+	// the structures updated after others are restored first
+	for i := updateLastAttempted; i >= 0; i-- {
 		one := updaters[i]
 		if err := one.Rollback(); err != nil {
 			// TODO: log errors to oplog
@@ -1672,6 +1759,9 @@ var updaterForStructure = updaterForStructureImpl
 func updaterForStructureImpl(loc StructureLocation, ps *LaidOutStructure, newRootDir, rollbackDir string, observer ContentUpdateObserver) (Updater, error) {
 	// TODO: this is sort of clunky, we already did the lookup, but doing the
 	// lookup out of band from this function makes for easier mocking
+	if loc.EMMCBootPartition {
+		return newEMMCStructureUpdater(newRootDir, ps, rollbackDir, loc)
+	}
 	if !ps.HasFilesystem() {
 		lookup := func(ps *LaidOutStructure) (device string, offs quantity.Offset, err error) {
 			return loc.Device, loc.Offset, nil
@@ -3218,6 +3218,175 @@ func (u *updateTestSuite) TestUpdaterMultiVolumesAddedRemovedErrors(c *C) {
 	c.Assert(err, ErrorMatches, "cannot update gadget assets: volumes were added")
 }
 
+func (u *updateTestSuite) orderedMultiVolumeUpdateDataSet(c *C) (oldData gadget.GadgetData, newData gadget.GadgetData, rollbackDir string) {
+	mkInfo := func() *gadget.Info {
+		return &gadget.Info{
+			Volumes: map[string]*gadget.Volume{
+				"main": {
+					Name:       "main",
+					Bootloader: "u-boot",
+					Schema:     "gpt",
+					Update:     &gadget.VolumeUpdateSettings{After: []string{"nor"}},
+					Structure: []gadget.VolumeStructure{{
+						VolumeName: "main",
+						Name:       "boot",
+						Type:       "bare",
+						Size:       quantity.SizeMiB,
+						Offset:     asOffsetPtr(quantity.OffsetMiB),
+						Content:    []gadget.VolumeContent{{Image: "boot.img"}},
+					}},
+				},
+				"nor": {
+					Name:   "nor",
+					Schema: "gpt",
+					Structure: []gadget.VolumeStructure{{
+						VolumeName: "nor",
+						Name:       "spl",
+						Type:       "bare",
+						Size:       quantity.SizeMiB,
+						Offset:     asOffsetPtr(quantity.OffsetMiB),
+						Content:    []gadget.VolumeContent{{Image: "spl.img"}},
+					}},
+				},
+			},
+		}
+	}
+
+	r := gadget.MockVolumeStructureToLocationMap(func(_ gadget.GadgetData, _ gadget.Model, _ map[string]*gadget.LaidOutVolume) (map[string]map[int]gadget.StructureLocation, error) {
+		return map[string]map[int]gadget.StructureLocation{
+			"main": {0: {Device: "/dev/mmcblk0", Offset: quantity.OffsetMiB}},
+			"nor":  {0: {Device: "/dev/mtdblock0", Offset: quantity.OffsetMiB}},
+		}, nil
+	})
+	u.AddCleanup(r)
+
+	oldData = gadget.GadgetData{Info: mkInfo(), RootDir: c.MkDir()}
+	newRootDir := c.MkDir()
+	makeSizedFile(c, filepath.Join(newRootDir, "boot.img"), quantity.SizeKiB, nil)
+	makeSizedFile(c, filepath.Join(newRootDir, "spl.img"), quantity.SizeKiB, nil)
+	newData = gadget.GadgetData{Info: mkInfo(), RootDir: newRootDir}
+	newData.Info.Volumes["main"].Structure[0].Update.Edition = 1
+	newData.Info.Volumes["nor"].Structure[0].Update.Edition = 1
+
+	return oldData, newData, c.MkDir()
+}
+
+func (u *updateTestSuite) TestUpdateApplyOrderedMultiVolume(c *C) {
+	oldData, newData, rollbackDir := u.orderedMultiVolumeUpdateDataSet(c)
+
+	var calls []string
+	restore := gadget.MockUpdaterForStructure(func(loc gadget.StructureLocation, ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string, observer gadget.ContentUpdateObserver) (gadget.Updater, error) {
+		name := ps.VolumeName + ":" + ps.Name
+		return &mockUpdater{
+			backupCb: func() error {
+				calls = append(calls, "backup "+name)
+				return nil
+			},
+			updateCb: func() error {
+				calls = append(calls, "update "+name)
+				return nil
+			},
+			rollbackCb: func() error {
+				c.Fatalf("unexpected rollback call")
+				return nil
+			},
+		}, nil
+	})
+	defer restore()
+
+	err := gadget.Update(uc20Model, oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, IsNil)
+	// the main volume is declared to be updated after the nor one
+	c.Check(calls, DeepEquals, []string{
+		"backup nor:spl",
+		"backup main:boot",
+		"update nor:spl",
+		"update main:boot",
+	})
+}
+
+func (u *updateTestSuite) TestUpdateApplyOrderedMultiVolumeFailsThenRollback(c *C) {
+	oldData, newData, rollbackDir := u.orderedMultiVolumeUpdateDataSet(c)
+
+	var calls []string
+	restore := gadget.MockUpdaterForStructure(func(loc gadget.StructureLocation, ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string, observer gadget.ContentUpdateObserver) (gadget.Updater, error) {
+		name := ps.VolumeName + ":" + ps.Name
+		return &mockUpdater{
+			updateCb: func() error {
+				calls = append(calls, "update "+name)
+				if ps.VolumeName == "main" {
+					return errors.New("failed")
+				}
+				return nil
+			},
+			rollbackCb: func() error {
+				calls = append(calls, "rollback "+name)
+				return nil
+			},
+		}, nil
+	})
+	defer restore()
+
+	err := gadget.Update(uc20Model, oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, ErrorMatches, `cannot update volume structure #0 \("boot"\) on volume main: failed`)
+	// all or nothing, rolled back in reverse order
+	c.Check(calls, DeepEquals, []string{
+		"update nor:spl",
+		"update main:boot",
+		"rollback main:boot",
+		"rollback nor:spl",
+	})
+}
+
+func (u *updateTestSuite) TestUpdateApplyMultiVolumeUpdatePolicyNone(c *C) {
+	oldData, newData, rollbackDir := u.orderedMultiVolumeUpdateDataSet(c)
+	newData.Info.Volumes["nor"].Update = &gadget.VolumeUpdateSettings{Policy: gadget.VolumeUpdatePolicyNone}
+
+	var calls []string
+	restore := gadget.MockUpdaterForStructure(func(loc gadget.StructureLocation, ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string, observer gadget.ContentUpdateObserver) (gadget.Updater, error) {
+		name := ps.VolumeName + ":" + ps.Name
+		return &mockUpdater{
+			updateCb: func() error {
+				calls = append(calls, "update "+name)
+				return nil
+			},
+		}, nil
+	})
+	defer restore()
+
+	err := gadget.Update(uc20Model, oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, IsNil)
+	// the structures of the nor volume are never updated
+	c.Check(calls, DeepEquals, []string{"update main:boot"})
+
+	// same when it would be the only update
+	newData.Info.Volumes["main"].Structure[0].Update.Edition = 0
+	calls = nil
+	err = gadget.Update(uc20Model, oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, Equals, gadget.ErrNoUpdate)
+	c.Check(calls, HasLen, 0)
+}
+
+func (u *updateTestSuite) TestUpdateApplyOrderedMultiVolumeCannotLocateVolume(c *C) {
+	oldData, newData, rollbackDir := u.orderedMultiVolumeUpdateDataSet(c)
+	// only the main volume can be located
+	r := gadget.MockVolumeStructureToLocationMap(func(_ gadget.GadgetData, _ gadget.Model, _ map[string]*gadget.LaidOutVolume) (map[string]map[int]gadget.StructureLocation, error) {
+		return map[string]map[int]gadget.StructureLocation{
+			"main": {0: {Device: "/dev/mmcblk0", Offset: quantity.OffsetMiB}},
+		}, nil
+	})
+	defer r()
+
+	restore := gadget.MockUpdaterForStructure(func(loc gadget.StructureLocation, ps *gadget.LaidOutStructure, psRootDir, psRollbackDir string, observer gadget.ContentUpdateObserver) (gadget.Updater, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+
+	err := gadget.Update(uc20Model, oldData, newData, rollbackDir, nil, nil)
+	c.Assert(err, ErrorMatches, `cannot update volume structure #0 \("spl"\) on volume nor: volume is part of an ordered multi-volume update but cannot be located`)
+}
+
 func (u *updateTestSuite) TestUpdateApplyNoChangedContentInAll(c *C) {
 	oldData, newData, rollbackDir := u.updateDataSet(c)
 	// first structure is updated
@@ -411,6 +411,12 @@ func ValidateContent(info *Info, gadgetSnapRootDir, kernelSnapRootDir string) er
 		}
 	}
 
+	if info.RecoveryKeyEscrow != nil {
+		if !osutil.FileExists(filepath.Join(gadgetSnapRootDir, info.RecoveryKeyEscrow.PublicKey)) {
+			return fmt.Errorf("invalid recovery-key-escrow: public-key %q not found in the gadget", info.RecoveryKeyEscrow.PublicKey)
+		}
+	}
+
 	// Ensure that at least one kernel.yaml reference can be resolved
 	// by the gadget
 	if kernelSnapRootDir != "" {
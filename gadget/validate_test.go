@@ -657,6 +657,25 @@ volumes:
 	c.Assert(err, ErrorMatches, `invalid layout of volume "pc": cannot lay out structure #0 \("foo"\): content "foo.img": stat .*/foo.img: no such file or directory`)
 }
 
+func (s *validateGadgetTestSuite) TestValidateContentRecoveryKeyEscrow(c *C) {
+	var gadgetYamlContent = `
+volumes:
+  pc:
+    bootloader: grub
+recovery-key-escrow:
+  public-key: escrow/brand.pem
+`
+	makeSizedFile(c, filepath.Join(s.dir, "meta/gadget.yaml"), 0, []byte(gadgetYamlContent))
+
+	ginfo, err := gadget.ReadInfo(s.dir, nil)
+	c.Assert(err, IsNil)
+	err = gadget.ValidateContent(ginfo, s.dir, "")
+	c.Assert(err, ErrorMatches, `invalid recovery-key-escrow: public-key "escrow/brand.pem" not found in the gadget`)
+
+	makeSizedFile(c, filepath.Join(s.dir, "escrow/brand.pem"), 0, []byte("key"))
+	c.Assert(gadget.ValidateContent(ginfo, s.dir, ""), IsNil)
+}
+
 func (s *validateGadgetTestSuite) TestValidateContentMultiVolumeContent(c *C) {
 	var gadgetYamlContent = `
 volumes:
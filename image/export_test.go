@@ -21,6 +21,7 @@ package image
 
 import (
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/signtool"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/image/preseed"
 	"github.com/snapcore/snapd/store/tooling"
@@ -65,3 +66,13 @@ func MockSetupSeed(f func(tsto *tooling.ToolingStore, model *asserts.Model, opts
 	setupSeed = f
 	return r
 }
+
+func MockGetKeypairManager(f func() (signtool.KeypairManager, error)) (restore func()) {
+	r := testutil.Backup(&getKeypairManager)
+	getKeypairManager = f
+	return r
+}
+
+func (m *Manifest) AddAssets(info *gadget.Info, gadgetName, gadgetUnpackDir, kernelName, kernelUnpackDir string) error {
+	return m.addAssets(info, gadgetName, gadgetUnpackDir, kernelName, kernelUnpackDir)
+}
@@ -149,6 +149,10 @@ func Prepare(opts *Options) error {
 		return err
 	}
 
+	if opts.DualBank && (model.Classic() || model.Grade() != asserts.ModelGradeUnset) {
+		return fmt.Errorf("cannot prepare a dual-bank image for a model other than UC16/18")
+	}
+
 	if err := setupSeed(tsto, model, opts); err != nil {
 		return err
 	}
@@ -203,6 +207,30 @@ func decodeModelAssertion(opts *Options) (*asserts.Model, error) {
 	return modela, nil
 }
 
+func readExtraAssertions(fns []string) ([]asserts.Assertion, error) {
+	var as []asserts.Assertion
+	for _, fn := range fns {
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read extra assertions: %s", err)
+		}
+		dec := asserts.NewDecoder(f)
+		for {
+			a, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("cannot decode extra assertions %q: %s", fn, err)
+			}
+			as = append(as, a)
+		}
+		f.Close()
+	}
+	return as, nil
+}
+
 func unpackSnap(gadgetFname, gadgetUnpackDir string) error {
 	// FIXME: jumping through layers here, we need to make
 	//        unpack part of the container interface (again)
@@ -322,10 +350,16 @@ var setupSeed = func(tsto *tooling.ToolingStore, model *asserts.Model, opts *Opt
 		return err
 	}
 
+	extraAssertions, err := readExtraAssertions(opts.ExtraAssertions)
+	if err != nil {
+		return err
+	}
+
 	wOpts := &seedwriter.Options{
-		SeedDir:        seedDir,
-		Label:          label,
-		DefaultChannel: opts.Channel,
+		SeedDir:         seedDir,
+		Label:           label,
+		DefaultChannel:  opts.Channel,
+		ExtraAssertions: extraAssertions,
 
 		TestSkipCopyUnverifiedModel: osutil.GetenvBool("UBUNTU_IMAGE_SKIP_COPY_UNVERIFIED_MODEL"),
 	}
@@ -384,6 +418,9 @@ var setupSeed = func(tsto *tooling.ToolingStore, model *asserts.Model, opts *Opt
 		return err
 	}
 
+	// all the snaps of the seed, for the manifest
+	seedSnaps := append([]*seedwriter.SeedSnap(nil), localSnaps...)
+
 	var curSnaps []*tooling.CurrentSnap
 	for _, sn := range localSnaps {
 		si, aRefs, err := seedwriter.DeriveSideInfo(sn.Path, model, f, db)
@@ -438,6 +475,7 @@ var setupSeed = func(tsto *tooling.ToolingStore, model *asserts.Model, opts *Opt
 			return sn.Path, nil
 		}
 		snapToDownloadOptions := make([]tooling.SnapToDownload, len(toDownload))
+		seedSnaps = append(seedSnaps, toDownload...)
 		for i, sn := range toDownload {
 			byName[sn.SnapName()] = sn
 			snapToDownloadOptions[i].Snap = sn
@@ -513,6 +551,21 @@ var setupSeed = func(tsto *tooling.ToolingStore, model *asserts.Model, opts *Opt
 		return err
 	}
 
+	var manifest *Manifest
+	if opts.Manifest != "" {
+		buildID := label
+		if buildID == "" {
+			buildID = makeLabel(time.Now())
+		}
+		manifest = newManifest(model, buildID)
+		if err := manifest.addSnaps(seedSnaps); err != nil {
+			return err
+		}
+		if err := manifest.addAssertions(f.Refs(), db); err != nil {
+			return err
+		}
+	}
+
 	// TODO: There will be classic UC20+ model based systems
 	//       that will have a bootable  ubuntu-seed partition.
 	//       This will need to be handled here eventually too.
@@ -533,6 +586,9 @@ var setupSeed = func(tsto *tooling.ToolingStore, model *asserts.Model, opts *Opt
 				fmt.Fprintf(Stderr, "WARNING: ensure that the contents under %s are owned by root:root in the (final) image\n", seedDir)
 			}
 		}
+		if manifest != nil {
+			return writeManifest(manifest, opts.Manifest, opts.ManifestSignKey, db, newFetcher)
+		}
 		// done already
 		return nil
 	}
@@ -591,28 +647,106 @@ var setupSeed = func(tsto *tooling.ToolingStore, model *asserts.Model, opts *Opt
 		return err
 	}
 
+	if manifest != nil {
+		if err := manifest.addAssets(gadgetInfo, bootWith.Gadget.SnapName(), gadgetUnpackDir, bootWith.Kernel.SnapName(), kernelUnpackDir); err != nil {
+			return err
+		}
+		if err := writeManifest(manifest, opts.Manifest, opts.ManifestSignKey, db, newFetcher); err != nil {
+			return err
+		}
+	}
+
+	if opts.DualBank {
+		return setupSystemBanks(model, opts, rootDir, bootWith, gadgetInfo, gadgetUnpackDir)
+	}
+
 	if err := boot.MakeBootableImage(model, bootRootDir, bootWith, opts.Customizations.BootFlags); err != nil {
 		return err
 	}
 
 	// early config & cloud-init config (done at install for Core 20)
 	if !hasModes {
-		// and the cloud-init things
-		if err := installCloudConfig(rootDir, gadgetUnpackDir); err != nil {
+		return configureImageRoot(model, rootDir, gadgetUnpackDir, gadgetInfo, &opts.Customizations)
+	}
+
+	return nil
+}
+
+// configureImageRoot applies the early and cloud-init configuration to the
+// writable root of a UC16/18 image.
+func configureImageRoot(model *asserts.Model, rootDir, gadgetUnpackDir string, gadgetInfo *gadget.Info, custo *Customizations) error {
+	// and the cloud-init things
+	if err := installCloudConfig(rootDir, gadgetUnpackDir); err != nil {
+		return err
+	}
+
+	defaultsDir := sysconfig.WritableDefaultsDir(rootDir)
+	defaults := gadget.SystemDefaults(gadgetInfo.Defaults)
+	if len(defaults) > 0 {
+		if err := os.MkdirAll(sysconfig.WritableDefaultsDir(rootDir, "/etc"), 0755); err != nil {
 			return err
 		}
+		return sysconfig.ApplyFilesystemOnlyDefaults(model, defaultsDir, defaults)
+	}
 
-		defaultsDir := sysconfig.WritableDefaultsDir(rootDir)
-		defaults := gadget.SystemDefaults(gadgetInfo.Defaults)
-		if len(defaults) > 0 {
-			if err := os.MkdirAll(sysconfig.WritableDefaultsDir(rootDir, "/etc"), 0755); err != nil {
-				return err
+	customizeImage(rootDir, defaultsDir, custo)
+	return nil
+}
+
+// setupSystemBanks lays out the two system banks declared by the gadget for
+// a dual-bank UC16/18 image. The seed written under rootDir becomes the one of
+// the first bank and is copied over to the second one, then each bank is made
+// bootable and configured on its own. rootDir is left with the content of the
+// boot partition shared by the banks, whose bootloader environment selects
+// the bank to boot, initially the first one.
+func setupSystemBanks(model *asserts.Model, opts *Options, rootDir string, bootWith *boot.BootableSet, gadgetInfo *gadget.Info, gadgetUnpackDir string) error {
+	banks := gadgetInfo.SystemBanks
+	if len(banks) == 0 {
+		return fmt.Errorf("cannot prepare a dual-bank image: gadget does not declare system-banks")
+	}
+
+	bankRootDirs := make([]string, len(banks))
+	for i, bank := range banks {
+		bankRootDirs[i] = filepath.Join(opts.PrepareDir, "image-"+bank)
+		var err error
+		if i == 0 {
+			err = os.Rename(rootDir, bankRootDirs[i])
+		} else {
+			err = copyTree(dirs.SnapSeedDirUnder(bankRootDirs[0]), dirs.SnapSeedDirUnder(bankRootDirs[i]))
+		}
+		if err != nil {
+			return fmt.Errorf("cannot set up system bank %q: %v", bank, err)
+		}
+	}
+
+	for i, bank := range banks {
+		bankRootDir := bankRootDirs[i]
+		// the boot snaps were written under rootDir
+		rebase := func(p string) (string, error) {
+			rel, err := filepath.Rel(rootDir, p)
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(bankRootDir, rel), nil
+		}
+		bankBootWith := *bootWith
+		var err error
+		for _, p := range []*string{&bankBootWith.BasePath, &bankBootWith.KernelPath, &bankBootWith.GadgetPath} {
+			if *p, err = rebase(*p); err != nil {
+				return fmt.Errorf("cannot set up system bank %q: %v", bank, err)
 			}
-			return sysconfig.ApplyFilesystemOnlyDefaults(model, defaultsDir, defaults)
 		}
 
-		customizeImage(rootDir, defaultsDir, &opts.Customizations)
+		if err := boot.MakeBootableImage(model, bankRootDir, &bankBootWith, opts.Customizations.BootFlags); err != nil {
+			return err
+		}
+		if err := configureImageRoot(model, bankRootDir, gadgetUnpackDir, gadgetInfo, &opts.Customizations); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return err
+	}
+	return boot.MakeSystemBankSelector(rootDir, gadgetUnpackDir, banks, banks[0])
 }
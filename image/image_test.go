@@ -1279,6 +1279,39 @@ func (s *imageSuite) TestPrepareUC18CustomizationsUnsupported(c *C) {
 	c.Assert(err, ErrorMatches, `cannot support with UC16/18 model requested customizations: boot flags \(boot-flag\)`)
 }
 
+func (s *imageSuite) TestPrepareDualBankUC20Unsupported(c *C) {
+	restore := image.MockTrusted(s.StoreSigning.Trusted)
+	defer restore()
+
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"display-name": "my model",
+		"architecture": "amd64",
+		"base":         "core20",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":            "pc-kernel",
+				"id":              s.AssertedSnapID("pc-kernel"),
+				"type":            "kernel",
+				"default-channel": "20",
+			},
+			map[string]interface{}{
+				"name":            "pc",
+				"id":              s.AssertedSnapID("pc"),
+				"type":            "gadget",
+				"default-channel": "20",
+			}},
+	})
+	fn := filepath.Join(c.MkDir(), "model.assertion")
+	err := ioutil.WriteFile(fn, asserts.Encode(model), 0644)
+	c.Assert(err, IsNil)
+
+	err = image.Prepare(&image.Options{
+		ModelFile: fn,
+		DualBank:  true,
+	})
+	c.Assert(err, ErrorMatches, `cannot prepare a dual-bank image for a model other than UC16/18`)
+}
+
 func (s *imageSuite) TestSetupSeedWithBaseLegacySnap(c *C) {
 	restore := image.MockTrusted(s.StoreSigning.Trusted)
 	defer restore()
@@ -2431,6 +2464,100 @@ func (s *imageSuite) TestSetupSeedCore18GadgetDefaults(c *C) {
 	c.Check(osutil.FileExists(filepath.Join(rootdir, "_writable_defaults/etc/ssh/sshd_not_to_be_run")), Equals, true)
 }
 
+func (s *imageSuite) TestSetupSeedCore18DualBank(c *C) {
+	restore := image.MockTrusted(s.StoreSigning.Trusted)
+	defer restore()
+
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"gadget":       "pc18",
+		"kernel":       "pc-kernel",
+		"base":         "core18",
+	})
+
+	prepareDir := c.MkDir()
+	s.setupSnaps(c, map[string]string{
+		"pc18":      "canonical",
+		"pc-kernel": "canonical",
+	}, "system-banks: [a, b]\n")
+
+	snapdFn := snaptest.MakeTestSnapWithFiles(c, snapdSnap, [][]string{{"local", ""}})
+	core18Fn := snaptest.MakeTestSnapWithFiles(c, packageCore18, [][]string{{"local", ""}})
+
+	opts := &image.Options{
+		Snaps: []string{
+			snapdFn,
+			core18Fn,
+		},
+		PrepareDir: prepareDir,
+		DualBank:   true,
+	}
+
+	err := image.SetupSeed(s.tsto, model, opts)
+	c.Assert(err, IsNil)
+
+	// the single image root only keeps the shared boot partition
+	c.Check(filepath.Join(prepareDir, "image/var/lib/snapd"), testutil.FileAbsent)
+	for _, bank := range []string{"a", "b"} {
+		rootdir := filepath.Join(prepareDir, "image-"+bank)
+		seeddir := filepath.Join(rootdir, "var/lib/snapd/seed")
+		c.Check(filepath.Join(seeddir, "seed.yaml"), testutil.FilePresent)
+		for _, fn := range []string{"snapd_x1.snap", "core18_x1.snap", "pc18_4.snap", "pc-kernel_2.snap"} {
+			c.Check(filepath.Join(seeddir, "snaps", fn), testutil.FilePresent)
+		}
+
+		// boot snaps in each bank point to the bank seed
+		dst, err := os.Readlink(filepath.Join(rootdir, "var/lib/snapd/snaps", "core18_x1.snap"))
+		c.Assert(err, IsNil)
+		c.Check(dst, Equals, "../seed/snaps/core18_x1.snap")
+	}
+
+	// the bootloader mock is shared by both banks and the selector, the
+	// selector boots the first bank
+	m, err := s.bootloader.GetBootVars("snap_core", "snap_kernel", "snap_banks", "snap_bank", "snap_try_bank", "snap_bank_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_core":      "core18_x1.snap",
+		"snap_kernel":    "pc-kernel_2.snap",
+		"snap_banks":     "a,b",
+		"snap_bank":      "a",
+		"snap_try_bank":  "",
+		"snap_bank_mode": "",
+	})
+}
+
+func (s *imageSuite) TestSetupSeedDualBankNoSystemBanks(c *C) {
+	restore := image.MockTrusted(s.StoreSigning.Trusted)
+	defer restore()
+
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"gadget":       "pc18",
+		"kernel":       "pc-kernel",
+		"base":         "core18",
+	})
+
+	s.setupSnaps(c, map[string]string{
+		"pc18":      "canonical",
+		"pc-kernel": "canonical",
+	}, "")
+
+	snapdFn := snaptest.MakeTestSnapWithFiles(c, snapdSnap, [][]string{{"local", ""}})
+	core18Fn := snaptest.MakeTestSnapWithFiles(c, packageCore18, [][]string{{"local", ""}})
+
+	opts := &image.Options{
+		Snaps: []string{
+			snapdFn,
+			core18Fn,
+		},
+		PrepareDir: c.MkDir(),
+		DualBank:   true,
+	}
+
+	err := image.SetupSeed(s.tsto, model, opts)
+	c.Assert(err, ErrorMatches, "cannot prepare a dual-bank image: gadget does not declare system-banks")
+}
+
 func (s *imageSuite) TestSetupSeedSnapCoreSatisfiesCore16(c *C) {
 	restore := image.MockTrusted(s.StoreSigning.Trusted)
 	defer restore()
@@ -3457,3 +3584,78 @@ func (s *imageSuite) TestSetupSeedCore20DelegatedSnap(c *C) {
 	err := image.SetupSeed(s.tsto, model, opts)
 	c.Check(err, IsNil)
 }
+
+func (s *imageSuite) TestSetupSeedExtraAssertions(c *C) {
+	restore := image.MockTrusted(s.StoreSigning.Trusted)
+	defer restore()
+
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"gadget":       "pc18",
+		"kernel":       "pc-kernel",
+		"base":         "core18",
+	})
+
+	rootdir := filepath.Join(c.MkDir(), "image")
+	s.setupSnaps(c, map[string]string{
+		"core18":    "canonical",
+		"pc18":      "canonical",
+		"pc-kernel": "canonical",
+		"snapd":     "canonical",
+	}, "")
+
+	vs, err := s.Brands.Signing("my-brand").Sign(asserts.ValidationSetType, map[string]interface{}{
+		"series":       "16",
+		"account-id":   "my-brand",
+		"authority-id": "my-brand",
+		"name":         "my-set",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name": "pc-kernel",
+				"id":   s.AssertedSnapID("pc-kernel"),
+			},
+		},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	vsFn := filepath.Join(c.MkDir(), "my-set.assert")
+	c.Assert(ioutil.WriteFile(vsFn, asserts.Encode(vs), 0644), IsNil)
+
+	opts := &image.Options{
+		PrepareDir:      filepath.Dir(rootdir),
+		ExtraAssertions: []string{vsFn},
+		Customizations: image.Customizations{
+			Validation: "ignore",
+		},
+	}
+
+	err = image.SetupSeed(s.tsto, model, opts)
+	c.Assert(err, IsNil)
+
+	seeddir := filepath.Join(rootdir, "var/lib/snapd/seed")
+	c.Check(filepath.Join(seeddir, "assertions", "16,my-brand,my-set,1.validation-set"), testutil.FileEquals, asserts.Encode(vs))
+}
+
+func (s *imageSuite) TestSetupSeedExtraAssertionsDecodeError(c *C) {
+	restore := image.MockTrusted(s.StoreSigning.Trusted)
+	defer restore()
+
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"gadget":       "pc18",
+		"kernel":       "pc-kernel",
+		"base":         "core18",
+	})
+
+	fn := filepath.Join(c.MkDir(), "garbage.assert")
+	c.Assert(ioutil.WriteFile(fn, []byte("garbage"), 0644), IsNil)
+
+	opts := &image.Options{
+		PrepareDir:      c.MkDir(),
+		ExtraAssertions: []string{fn},
+	}
+
+	err := image.SetupSeed(s.tsto, model, opts)
+	c.Assert(err, ErrorMatches, `cannot decode extra assertions ".*/garbage.assert": .*`)
+}
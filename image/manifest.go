@@ -0,0 +1,365 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/signtool"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/seed/seedwriter"
+	"github.com/snapcore/snapd/snap"
+)
+
+var getKeypairManager = signtool.GetKeypairManager
+
+// Manifest is the build manifest of an image, listing everything that went
+// into it such that a device can be checked against it later.
+type Manifest struct {
+	Series  string `json:"series"`
+	BrandID string `json:"brand-id"`
+	Model   string `json:"model"`
+	BuildID string `json:"build-id"`
+
+	Snaps      []*ManifestSnap      `json:"snaps"`
+	Assertions []*ManifestAssertion `json:"assertions"`
+	// Assets are the files of the gadget and kernel snaps that are
+	// written to the structures of the gadget volumes (core only).
+	Assets []*ManifestAsset `json:"assets,omitempty"`
+}
+
+// ManifestSnap describes a snap included in an image.
+type ManifestSnap struct {
+	Name string `json:"name"`
+	// SnapID is empty for unasserted snaps.
+	SnapID   string        `json:"snap-id,omitempty"`
+	Revision snap.Revision `json:"revision"`
+	Channel  string        `json:"channel,omitempty"`
+	SHA3_384 string        `json:"sha3-384"`
+	Size     uint64        `json:"size"`
+}
+
+// ManifestAssertion describes an assertion included in an image.
+type ManifestAssertion struct {
+	Type            string            `json:"type"`
+	PrimaryKey      map[string]string `json:"primary-key"`
+	Revision        int               `json:"revision"`
+	SignKeySHA3_384 string            `json:"sign-key-sha3-384,omitempty"`
+}
+
+// ManifestAsset describes a file from the gadget or kernel snap written to
+// a structure of a gadget volume, and where it was written.
+type ManifestAsset struct {
+	// Snap is the name of the snap the asset comes from.
+	Snap string `json:"snap"`
+	// Path is the path of the asset relative to the top of the snap.
+	Path     string `json:"path"`
+	SHA3_384 string `json:"sha3-384"`
+	Size     uint64 `json:"size"`
+	// Volume and Structure are the names of the gadget volume and of
+	// the structure the asset is written to, Role is the role of the
+	// structure, if any.
+	Volume    string `json:"volume"`
+	Structure string `json:"structure"`
+	Role      string `json:"role,omitempty"`
+	// Target is the path of the asset relative to the top of the
+	// filesystem of the structure, for filesystem content.
+	Target string `json:"target,omitempty"`
+	// Offset is the offset of the asset on the disk, for raw content.
+	Offset *quantity.Offset `json:"offset,omitempty"`
+}
+
+// ReadManifest reads the build manifest in the given file.
+func ReadManifest(fn string) (*Manifest, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read image manifest: %v", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot decode image manifest %q: %v", fn, err)
+	}
+	return &m, nil
+}
+
+func newManifest(model *asserts.Model, buildID string) *Manifest {
+	return &Manifest{
+		Series:  model.Series(),
+		BrandID: model.BrandID(),
+		Model:   model.Model(),
+		BuildID: buildID,
+	}
+}
+
+func (m *Manifest) addSnaps(seedSnaps []*seedwriter.SeedSnap) error {
+	for _, sn := range seedSnaps {
+		digest, size, err := asserts.SnapFileSHA3_384(sn.Path)
+		if err != nil {
+			return err
+		}
+		m.Snaps = append(m.Snaps, &ManifestSnap{
+			Name:     sn.Info.SnapName(),
+			SnapID:   sn.Info.ID(),
+			Revision: sn.Info.Revision,
+			Channel:  sn.Channel,
+			SHA3_384: digest,
+			Size:     size,
+		})
+	}
+	sort.Slice(m.Snaps, func(i, j int) bool {
+		return m.Snaps[i].Name < m.Snaps[j].Name
+	})
+	return nil
+}
+
+func (m *Manifest) addAssertions(refs []*asserts.Ref, db asserts.RODatabase) error {
+	seen := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		if seen[ref.Unique()] {
+			continue
+		}
+		seen[ref.Unique()] = true
+		a, err := ref.Resolve(db.Find)
+		if err != nil {
+			return fmt.Errorf("internal error: lost assertion %v: %v", ref, err)
+		}
+		primaryKey := make(map[string]string, len(ref.PrimaryKey))
+		for i, k := range ref.Type.PrimaryKey {
+			primaryKey[k] = ref.PrimaryKey[i]
+		}
+		m.Assertions = append(m.Assertions, &ManifestAssertion{
+			Type:            ref.Type.Name,
+			PrimaryKey:      primaryKey,
+			Revision:        a.Revision(),
+			SignKeySHA3_384: a.SignKeyID(),
+		})
+	}
+	return nil
+}
+
+func (m *Manifest) addAsset(snapName, snapRootDir, path string, deployed *ManifestAsset) error {
+	rel, err := filepath.Rel(snapRootDir, path)
+	if err != nil {
+		return err
+	}
+	digest, size, err := osutil.FileDigest(path, crypto.SHA3_384)
+	if err != nil {
+		return err
+	}
+	encDigest, err := asserts.EncodeDigest(crypto.SHA3_384, digest)
+	if err != nil {
+		return err
+	}
+	deployed.Snap = snapName
+	deployed.Path = rel
+	deployed.SHA3_384 = encDigest
+	deployed.Size = size
+	m.Assets = append(m.Assets, deployed)
+	return nil
+}
+
+// addAssets records the digests of the gadget and kernel files that are
+// written to the structures of the gadget volumes, either as raw images or
+// as filesystem content, along with where they are written.
+func (m *Manifest) addAssets(info *gadget.Info, gadgetName, gadgetUnpackDir, kernelName, kernelUnpackDir string) error {
+	opts := &gadget.LayoutOptions{
+		GadgetRootDir: gadgetUnpackDir,
+		KernelRootDir: kernelUnpackDir,
+	}
+	// targets are relative to the top of the filesystem of the
+	// structure, with or without a leading slash
+	relTarget := func(target string) string {
+		return strings.TrimPrefix(filepath.Join("/", target), "/")
+	}
+	addFile := func(path string, deployed *ManifestAsset) error {
+		if strings.HasPrefix(path, kernelUnpackDir+"/") {
+			return m.addAsset(kernelName, kernelUnpackDir, path, deployed)
+		}
+		return m.addAsset(gadgetName, gadgetUnpackDir, path, deployed)
+	}
+	for volName, vol := range info.Volumes {
+		pvol, err := gadget.LayoutVolume(vol, gadget.DefaultConstraints, opts)
+		if err != nil {
+			return err
+		}
+		for _, ps := range pvol.LaidOutStructure {
+			inStructure := func() *ManifestAsset {
+				return &ManifestAsset{
+					Volume:    volName,
+					Structure: ps.Name,
+					Role:      ps.Role,
+				}
+			}
+			for _, lc := range ps.LaidOutContent {
+				deployed := inStructure()
+				offset := lc.StartOffset
+				deployed.Offset = &offset
+				if err := addFile(filepath.Join(gadgetUnpackDir, lc.Image), deployed); err != nil {
+					return err
+				}
+			}
+			for _, rc := range ps.ResolvedContent {
+				if !osutil.IsDirectory(rc.ResolvedSource) && !strings.HasSuffix(rc.ResolvedSource, "/") {
+					// same rules as when writing the content
+					target := rc.Target
+					if strings.HasSuffix(target, "/") {
+						target = filepath.Join(target, filepath.Base(rc.ResolvedSource))
+					}
+					deployed := inStructure()
+					deployed.Target = relTarget(target)
+					if err := addFile(rc.ResolvedSource, deployed); err != nil {
+						return err
+					}
+					continue
+				}
+				targetDir := rc.Target
+				if !strings.HasSuffix(rc.ResolvedSource, "/") {
+					targetDir = filepath.Join(targetDir, filepath.Base(rc.ResolvedSource))
+				}
+				err := filepath.Walk(rc.ResolvedSource, func(path string, fi os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+					if !fi.Mode().IsRegular() {
+						return nil
+					}
+					rel, err := filepath.Rel(rc.ResolvedSource, path)
+					if err != nil {
+						return err
+					}
+					deployed := inStructure()
+					deployed.Target = relTarget(filepath.Join(targetDir, rel))
+					return addFile(path, deployed)
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	sort.Slice(m.Assets, func(i, j int) bool {
+		ai, aj := m.Assets[i], m.Assets[j]
+		if ai.Snap != aj.Snap {
+			return ai.Snap < aj.Snap
+		}
+		if ai.Path != aj.Path {
+			return ai.Path < aj.Path
+		}
+		if ai.Volume != aj.Volume {
+			return ai.Volume < aj.Volume
+		}
+		if ai.Structure != aj.Structure {
+			return ai.Structure < aj.Structure
+		}
+		return ai.Target < aj.Target
+	})
+	return nil
+}
+
+// writeManifest writes the build manifest to the given file, and the
+// image-manifest assertion signed with the given key of the brand next to
+// it, along with the account-key assertion of the key unless it is part of
+// the seed already.
+func writeManifest(m *Manifest, fn, signKey string, db *asserts.Database, newFetcher seedwriter.NewFetcherFunc) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	keypairMgr, err := getKeypairManager()
+	if err != nil {
+		return err
+	}
+	if signKey == "" {
+		signKey = `default`
+	}
+	privKey, err := keypairMgr.GetByName(signKey)
+	if err != nil {
+		// TRANSLATORS: %q is the key name, %v the error message
+		return fmt.Errorf(i18n.G("cannot use %q key: %v"), signKey, err)
+	}
+
+	signDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore:      asserts.NewMemoryBackstore(),
+		KeypairManager: keypairMgr,
+	})
+	if err != nil {
+		return err
+	}
+	h := crypto.SHA3_384.New()
+	h.Write(data)
+	encDigest, err := asserts.EncodeDigest(crypto.SHA3_384, h.Sum(nil))
+	if err != nil {
+		return err
+	}
+	headers := map[string]interface{}{
+		"type":              "image-manifest",
+		"authority-id":      m.BrandID,
+		"series":            m.Series,
+		"brand-id":          m.BrandID,
+		"model":             m.Model,
+		"build-id":          m.BuildID,
+		"manifest-sha3-384": encDigest,
+		"timestamp":         time.Now().UTC().Format(time.RFC3339),
+	}
+	signed, err := signDB.Sign(asserts.ImageManifestType, headers, nil, privKey.PublicKey().ID())
+	if err != nil {
+		return fmt.Errorf("cannot sign image manifest: %v", err)
+	}
+
+	// this checks the signature against the account-key of the brand,
+	// fetching it if necessary
+	f := newFetcher(func(asserts.Assertion) error { return nil })
+	if err := f.Save(signed); err != nil {
+		return fmt.Errorf("cannot sign image manifest: %v", err)
+	}
+
+	accKey, err := db.Find(asserts.AccountKeyType, map[string]string{
+		"public-key-sha3-384": signed.SignKeyID(),
+	})
+	if err != nil {
+		return fmt.Errorf("internal error: %v", err)
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := asserts.NewEncoder(buf)
+	for _, a := range []asserts.Assertion{accKey, signed} {
+		if err := enc.Encode(a); err != nil {
+			return fmt.Errorf("cannot write assertion %s: %v", a.Ref(), err)
+		}
+	}
+
+	if err := osutil.AtomicWriteFile(fn, data, 0644, 0); err != nil {
+		return fmt.Errorf("cannot write image manifest: %v", err)
+	}
+	return osutil.AtomicWriteFile(fn+".assert", buf.Bytes(), 0644, 0)
+}
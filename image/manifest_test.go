@@ -0,0 +1,318 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image_test
+
+import (
+	"crypto"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/signtool"
+	"github.com/snapcore/snapd/bootloader"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/image"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type fakeKeyMgr struct {
+	key asserts.PrivateKey
+}
+
+func (f *fakeKeyMgr) Put(privKey asserts.PrivateKey) error                  { return nil }
+func (f *fakeKeyMgr) Get(keyID string) (asserts.PrivateKey, error)          { return f.key, nil }
+func (f *fakeKeyMgr) Delete(keyID string) error                             { return nil }
+func (f *fakeKeyMgr) GetByName(keyNname string) (asserts.PrivateKey, error) { return f.key, nil }
+func (f *fakeKeyMgr) Export(keyName string) ([]byte, error)                 { return nil, nil }
+func (f *fakeKeyMgr) List() ([]asserts.ExternalKeyInfo, error)              { return nil, nil }
+func (f *fakeKeyMgr) DeleteByName(keyName string) error                     { return nil }
+
+const pcUC20GadgetWithContentYaml = `
+ volumes:
+   pc:
+     bootloader: grub
+     structure:
+       - name: ubuntu-seed
+         role: system-seed
+         filesystem: vfat
+         type: EF,C12A7328-F81F-11D2-BA4B-00A0C93EC93B
+         size: 100M
+         content:
+           - source: grubx64.efi
+             target: EFI/boot/grubx64.efi
+       - name: ubuntu-data
+         role: system-data
+         type: 83,0FC63DAF-8483-4772-8E79-3D69D8477DE4
+         size: 200M
+ `
+
+func (s *imageSuite) setupUC20ManifestSnaps(c *C) {
+	s.makeSnap(c, "snapd", nil, snap.R(1), "")
+	s.makeSnap(c, "core20", nil, snap.R(20), "")
+	s.makeSnap(c, "pc-kernel=20", nil, snap.R(1), "")
+	gadgetContent := [][]string{
+		{"grub-recovery.conf", "# recovery grub.cfg"},
+		{"grub.conf", "# boot grub.cfg"},
+		{"grubx64.efi", "grub efi binary"},
+		{"meta/gadget.yaml", pcUC20GadgetWithContentYaml},
+	}
+	s.makeSnap(c, "pc=20", gadgetContent, snap.R(22), "")
+	s.makeSnap(c, "required20", nil, snap.R(21), "other")
+}
+
+func sha3_384Of(c *C, data string) string {
+	h := crypto.SHA3_384.New()
+	h.Write([]byte(data))
+	digest, err := asserts.EncodeDigest(crypto.SHA3_384, h.Sum(nil))
+	c.Assert(err, IsNil)
+	return digest
+}
+
+func (s *imageSuite) TestSetupSeedCore20Manifest(c *C) {
+	bootloader.Force(nil)
+	restore := image.MockTrusted(s.StoreSigning.Trusted)
+	defer restore()
+	restore = image.MockGetKeypairManager(func() (signtool.KeypairManager, error) {
+		return &fakeKeyMgr{brandPrivKey}, nil
+	})
+	defer restore()
+
+	model := s.makeUC20Model(nil)
+	s.setupUC20ManifestSnaps(c)
+
+	prepareDir := c.MkDir()
+	manifestFn := filepath.Join(c.MkDir(), "manifest.json")
+	opts := &image.Options{
+		PrepareDir: prepareDir,
+		Manifest:   manifestFn,
+		Customizations: image.Customizations{
+			Validation: "ignore",
+		},
+	}
+
+	err := image.SetupSeed(s.tsto, model, opts)
+	c.Assert(err, IsNil)
+
+	systems, err := filepath.Glob(filepath.Join(prepareDir, "system-seed", "systems", "*"))
+	c.Assert(err, IsNil)
+	c.Assert(systems, HasLen, 1)
+	label := filepath.Base(systems[0])
+
+	m, err := image.ReadManifest(manifestFn)
+	c.Assert(err, IsNil)
+	c.Check(m.Series, Equals, "16")
+	c.Check(m.BrandID, Equals, "my-brand")
+	c.Check(m.Model, Equals, "my-model")
+	c.Check(m.BuildID, Equals, label)
+
+	seedsnapsdir := filepath.Join(prepareDir, "system-seed", "snaps")
+	var expectedSnaps []*image.ManifestSnap
+	for _, name := range []string{"core20", "pc", "pc-kernel", "required20", "snapd"} {
+		info := s.AssertedSnapInfo(name)
+		digest, size, err := asserts.SnapFileSHA3_384(filepath.Join(seedsnapsdir, info.Filename()))
+		c.Assert(err, IsNil)
+		channel := "latest/stable"
+		if name == "pc" || name == "pc-kernel" {
+			channel = "20"
+		}
+		expectedSnaps = append(expectedSnaps, &image.ManifestSnap{
+			Name:     name,
+			SnapID:   info.SnapID,
+			Revision: info.Revision,
+			Channel:  channel,
+			SHA3_384: digest,
+			Size:     size,
+		})
+	}
+	c.Check(m.Snaps, DeepEquals, expectedSnaps)
+
+	types := make(map[string]int)
+	for _, a := range m.Assertions {
+		types[a.Type]++
+		if a.Type == "model" {
+			c.Check(a, DeepEquals, &image.ManifestAssertion{
+				Type: "model",
+				PrimaryKey: map[string]string{
+					"series":   "16",
+					"brand-id": "my-brand",
+					"model":    "my-model",
+				},
+				Revision:        0,
+				SignKeySHA3_384: brandPrivKey.PublicKey().ID(),
+			})
+		}
+	}
+	c.Check(types["model"], Equals, 1)
+	c.Check(types["snap-declaration"], Equals, 5)
+	c.Check(types["snap-revision"], Equals, 5)
+
+	c.Check(m.Assets, DeepEquals, []*image.ManifestAsset{{
+		Snap:      "pc",
+		Path:      "grubx64.efi",
+		SHA3_384:  sha3_384Of(c, "grub efi binary"),
+		Size:      uint64(len("grub efi binary")),
+		Volume:    "pc",
+		Structure: "ubuntu-seed",
+		Role:      "system-seed",
+		Target:    "EFI/boot/grubx64.efi",
+	}})
+
+	// the signed assertion refers to the manifest and comes with the
+	// account-key of the brand
+	f, err := os.Open(manifestFn + ".assert")
+	c.Assert(err, IsNil)
+	defer f.Close()
+	dec := asserts.NewDecoder(f)
+	a, err := dec.Decode()
+	c.Assert(err, IsNil)
+	c.Assert(a.Type(), Equals, asserts.AccountKeyType)
+	c.Check(a.(*asserts.AccountKey).PublicKeyID(), Equals, brandPrivKey.PublicKey().ID())
+	a, err = dec.Decode()
+	c.Assert(err, IsNil)
+	c.Assert(a.Type(), Equals, asserts.ImageManifestType)
+	ima := a.(*asserts.ImageManifest)
+	c.Check(ima.BrandID(), Equals, "my-brand")
+	c.Check(ima.Model(), Equals, "my-model")
+	c.Check(ima.BuildID(), Equals, label)
+	c.Check(ima.SignKeyID(), Equals, brandPrivKey.PublicKey().ID())
+	digest, _, err := osutil.FileDigest(manifestFn, crypto.SHA3_384)
+	c.Assert(err, IsNil)
+	encDigest, err := asserts.EncodeDigest(crypto.SHA3_384, digest)
+	c.Assert(err, IsNil)
+	c.Check(ima.ManifestSHA3_384(), Equals, encDigest)
+	_, err = dec.Decode()
+	c.Check(err, Equals, io.EOF)
+}
+
+func (s *imageSuite) TestSetupSeedCore20ManifestUnknownKey(c *C) {
+	bootloader.Force(nil)
+	restore := image.MockTrusted(s.StoreSigning.Trusted)
+	defer restore()
+	otherKey, _ := assertstest.GenerateKey(752)
+	restore = image.MockGetKeypairManager(func() (signtool.KeypairManager, error) {
+		return &fakeKeyMgr{otherKey}, nil
+	})
+	defer restore()
+
+	model := s.makeUC20Model(nil)
+	s.setupUC20ManifestSnaps(c)
+
+	opts := &image.Options{
+		PrepareDir: c.MkDir(),
+		Manifest:   filepath.Join(c.MkDir(), "manifest.json"),
+		Customizations: image.Customizations{
+			Validation: "ignore",
+		},
+	}
+
+	err := image.SetupSeed(s.tsto, model, opts)
+	c.Assert(err, ErrorMatches, `cannot sign image manifest: .*not found`)
+	c.Check(opts.Manifest, testutil.FileAbsent)
+	c.Check(opts.Manifest+".assert", testutil.FileAbsent)
+}
+
+const pcGadgetWithRawAndDirContentYaml = `
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: mbr
+        type: mbr
+        size: 440
+        content:
+          - image: pc-boot.img
+      - name: BIOS Boot
+        type: DA,21686148-6449-6E6F-744E-656564454649
+        size: 1M
+        offset: 1M
+        content:
+          - image: pc-core.img
+      - name: EFI System
+        role: system-boot
+        filesystem: vfat
+        type: EF,C12A7328-F81F-11D2-BA4B-00A0C93EC93B
+        size: 50M
+        content:
+          - source: grubx64.efi
+            target: EFI/boot/
+          - source: efi/
+            target: EFI/ubuntu
+          - source: $kernel:dtbs/dtbs/
+            target: /
+`
+
+func (s *imageSuite) TestManifestAddAssets(c *C) {
+	gadgetDir := c.MkDir()
+	kernelDir := c.MkDir()
+	for _, f := range [][]string{
+		{"pc-boot.img", "boot img"},
+		{"pc-core.img", "core img"},
+		{"grubx64.efi", "grub efi binary"},
+		{"efi/shim.efi", "shim"},
+		{"efi/sub/mm.efi", "mm"},
+		{"meta/gadget.yaml", pcGadgetWithRawAndDirContentYaml},
+	} {
+		c.Assert(os.MkdirAll(filepath.Dir(filepath.Join(gadgetDir, f[0])), 0755), IsNil)
+		c.Assert(os.WriteFile(filepath.Join(gadgetDir, f[0]), []byte(f[1]), 0644), IsNil)
+	}
+	for _, f := range [][]string{
+		{"dtbs/foo.dtb", "dtb"},
+		{"meta/kernel.yaml", "assets:\n  dtbs:\n    update: true\n    content:\n      - dtbs/\n"},
+	} {
+		c.Assert(os.MkdirAll(filepath.Dir(filepath.Join(kernelDir, f[0])), 0755), IsNil)
+		c.Assert(os.WriteFile(filepath.Join(kernelDir, f[0]), []byte(f[1]), 0644), IsNil)
+	}
+	info, err := gadget.InfoFromGadgetYaml([]byte(pcGadgetWithRawAndDirContentYaml), nil)
+	c.Assert(err, IsNil)
+
+	m := &image.Manifest{}
+	err = m.AddAssets(info, "pc", gadgetDir, "pc-kernel", kernelDir)
+	c.Assert(err, IsNil)
+
+	offset := func(o quantity.Offset) *quantity.Offset { return &o }
+	asset := func(snapName, path, content, structure, role, target string, off *quantity.Offset) *image.ManifestAsset {
+		return &image.ManifestAsset{
+			Snap:      snapName,
+			Path:      path,
+			SHA3_384:  sha3_384Of(c, content),
+			Size:      uint64(len(content)),
+			Volume:    "pc",
+			Structure: structure,
+			Role:      role,
+			Target:    target,
+			Offset:    off,
+		}
+	}
+	c.Check(m.Assets, DeepEquals, []*image.ManifestAsset{
+		asset("pc", "efi/shim.efi", "shim", "EFI System", "system-boot", "EFI/ubuntu/shim.efi", nil),
+		asset("pc", "efi/sub/mm.efi", "mm", "EFI System", "system-boot", "EFI/ubuntu/sub/mm.efi", nil),
+		asset("pc", "grubx64.efi", "grub efi binary", "EFI System", "system-boot", "EFI/boot/grubx64.efi", nil),
+		asset("pc", "pc-boot.img", "boot img", "mbr", "mbr", "", offset(0)),
+		asset("pc", "pc-core.img", "core img", "BIOS Boot", "", "", offset(quantity.OffsetMiB)),
+		asset("pc-kernel", "dtbs/foo.dtb", "dtb", "EFI System", "system-boot", "foo.dtb", nil),
+	})
+}
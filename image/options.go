@@ -46,6 +46,11 @@ type Options struct {
 	Snaps        []string
 	SnapChannels map[string]string
 
+	// ExtraAssertions are paths of files holding additional
+	// assertions to include in the seed, see
+	// seedwriter.Options.ExtraAssertions.
+	ExtraAssertions []string
+
 	// WideCohortKey can be used to supply a cohort covering all
 	// the snaps in the image, there is no generally suppported API
 	// to create such a cohort key.
@@ -57,6 +62,20 @@ type Options struct {
 	// useful only for classic mode. If set must match the model otherwise.
 	Architecture string
 
+	// DualBank requests laying out and seeding the two complete system
+	// banks declared by the gadget, in image-<bank> directories under
+	// PrepareDir instead of a single image directory (UC16/18 only).
+	DualBank bool
+
+	// Manifest is the optional path of the file to write the build
+	// manifest of the image to, the image-manifest assertion signed
+	// by the brand is written next to it with an additional .assert
+	// suffix.
+	Manifest string
+	// ManifestSignKey is the name of the key to use for signing the
+	// image-manifest assertion (empty means the default key).
+	ManifestSignKey string
+
 	Customizations Customizations
 }
 
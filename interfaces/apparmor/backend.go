@@ -110,6 +110,11 @@ func (b *Backend) Initialize(opts *interfaces.SecurityBackendOptions) error {
 		return fmt.Errorf("cannot create snap-confine policy directory: %s", err)
 	}
 
+	// Keep the profile compilation statistics across restarts.
+	if err := apparmor_sandbox.PersistCompileStats(dirs.SnapAppArmorCompileStatsFile); err != nil {
+		logger.Noticef("%v", err)
+	}
+
 	// Check the /proc/self/exe symlink, this is needed below but we want to
 	// fail early if this fails for whatever reason.
 	exe, err := os.Readlink(procSelfExe)
@@ -377,6 +382,31 @@ func profileIsRemovableOnCoreSetup(fn string) bool {
 	return true
 }
 
+// isSnapConfineProfile returns whether the given profile filename is one of
+// the snap-confine profiles listed above.
+func isSnapConfineProfile(fn string) bool {
+	bn := path.Base(fn)
+	return strings.HasPrefix(bn, "snap-confine.core.") || strings.HasPrefix(bn, "snap-confine.snapd.") || strings.Contains(bn, "usr.lib.snapd.snap-confine")
+}
+
+// isForwardCoreSetup returns whether the given core or snapd snap is the same
+// or a newer store revision than the current one, in which case it is safe
+// to keep the apparmor cache.
+func isForwardCoreSetup(snapInfo *snap.Info) bool {
+	target, err := os.Readlink(filepath.Join(dirs.SnapMountDir, snapInfo.InstanceName(), "current"))
+	if err != nil {
+		return false
+	}
+	current, err := snap.ParseRevision(filepath.Base(target))
+	if err != nil {
+		return false
+	}
+	if !current.Store() || !snapInfo.Revision.Store() {
+		return false
+	}
+	return snapInfo.Revision.N >= current.N
+}
+
 type profilePathsResults struct {
 	changed   []string
 	unchanged []string
@@ -393,6 +423,9 @@ func (b *Backend) prepareProfiles(snapInfo *snap.Info, opts interfaces.Confineme
 	// Add snippets for parallel snap installation mapping
 	spec.(*Specification).AddOvername(snapInfo)
 
+	// Add snippets for the size limited private tmpfs.
+	spec.(*Specification).AddPrivateTmpfs(snapInfo)
+
 	// Add snippets derived from the layout definition.
 	spec.(*Specification).AddLayout(snapInfo)
 
@@ -420,10 +453,16 @@ func (b *Backend) prepareProfiles(snapInfo *snap.Info, opts interfaces.Confineme
 	// See LP:#1460152 and
 	// https://forum.snapcraft.io/t/core-snap-revert-issues-on-core-devices/
 	//
+	// When moving forward the cache is kept warm though, and only the
+	// revision specific snap-confine profiles are dropped.
 	if (snapInfo.Type() == snap.TypeOS || snapInfo.Type() == snap.TypeSnapd) && !release.OnClassic {
+		removable := profileIsRemovableOnCoreSetup
+		if isForwardCoreSetup(snapInfo) {
+			removable = isSnapConfineProfile
+		}
 		if li, err := filepath.Glob(filepath.Join(apparmor_sandbox.SystemCacheDir, "*")); err == nil {
 			for _, p := range li {
-				if st, err := os.Stat(p); err == nil && st.Mode().IsRegular() && profileIsRemovableOnCoreSetup(p) {
+				if st, err := os.Stat(p); err == nil && st.Mode().IsRegular() && removable(p) {
 					if err := os.Remove(p); err != nil {
 						logger.Noticef("cannot remove %q: %s", p, err)
 					}
@@ -432,8 +471,14 @@ func (b *Backend) prepareProfiles(snapInfo *snap.Info, opts interfaces.Confineme
 		}
 	}
 
+	// Adjustments of the template shipped by the base snap
+	basePolicy, err := basePolicySnippet(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the files that this snap should have
-	content := b.deriveContent(spec.(*Specification), snapInfo, opts)
+	content := b.deriveContent(spec.(*Specification), snapInfo, opts, basePolicy)
 
 	dir := dirs.SnapAppArmorDir
 	globs := profileGlobs(snapInfo.InstanceName())
@@ -629,18 +674,18 @@ const (
 	attachComplain = "(attach_disconnected,mediate_deleted,complain)"
 )
 
-func (b *Backend) deriveContent(spec *Specification, snapInfo *snap.Info, opts interfaces.ConfinementOptions) (content map[string]osutil.FileState) {
+func (b *Backend) deriveContent(spec *Specification, snapInfo *snap.Info, opts interfaces.ConfinementOptions, basePolicy string) (content map[string]osutil.FileState) {
 	content = make(map[string]osutil.FileState, len(snapInfo.Apps)+len(snapInfo.Hooks)+1)
 
 	// Add profile for each app.
 	for _, appInfo := range snapInfo.Apps {
 		securityTag := appInfo.SecurityTag()
-		b.addContent(securityTag, snapInfo, appInfo.Name, opts, spec.SnippetForTag(securityTag), content, spec)
+		b.addContent(securityTag, snapInfo, appInfo.Name, opts, spec.SnippetForTag(securityTag), basePolicy, content, spec)
 	}
 	// Add profile for each hook.
 	for _, hookInfo := range snapInfo.Hooks {
 		securityTag := hookInfo.SecurityTag()
-		b.addContent(securityTag, snapInfo, "hook."+hookInfo.Name, opts, spec.SnippetForTag(securityTag), content, spec)
+		b.addContent(securityTag, snapInfo, "hook."+hookInfo.Name, opts, spec.SnippetForTag(securityTag), basePolicy, content, spec)
 	}
 	// Add profile for snap-update-ns if we have any apps or hooks.
 	// If we have neither then we don't have any need to create an executing environment.
@@ -681,7 +726,7 @@ func addUpdateNSProfile(snapInfo *snap.Info, snippets string, content map[string
 	}
 }
 
-func (b *Backend) addContent(securityTag string, snapInfo *snap.Info, cmdName string, opts interfaces.ConfinementOptions, snippetForTag string, content map[string]osutil.FileState, spec *Specification) {
+func (b *Backend) addContent(securityTag string, snapInfo *snap.Info, cmdName string, opts interfaces.ConfinementOptions, snippetForTag, basePolicy string, content map[string]osutil.FileState, spec *Specification) {
 	// If base is specified and it doesn't match the core snaps (not
 	// specifying a base should use the default core policy since in this
 	// case, the 'core' snap is used for the runtime), use the base
@@ -901,6 +946,10 @@ func (b *Backend) addContent(securityTag string, snapInfo *snap.Info, cmdName st
 			}
 
 			if !ignoreSnippets {
+				// The base snap may need more than the default
+				// template allows
+				tagSnippets += basePolicy
+
 				// For policy with snippets that request
 				// suppression of 'ptrace (trace)' denials, add
 				// the suppression rule unless another
@@ -1495,6 +1495,62 @@ func (s *backendSuite) testCoreOrSnapdOnCoreCleansApparmorCache(c *C, coreOrSnap
 	c.Check(l, DeepEquals, []string{dotKept, dirsAreKept, sunCanaryKept, snapCanaryKept, symlinksAreKept})
 }
 
+func (s *backendSuite) TestCoreOnCoreRefreshKeepsApparmorCache(c *C) {
+	restorer := release.MockOnClassic(false)
+	defer restorer()
+
+	coreInfo := snaptest.MockInfo(c, coreYaml, &snap.SideInfo{Revision: snap.R(111)})
+	s.writeVanillaSnapConfineProfile(c, coreInfo)
+
+	// the current revision is older than the one being set up
+	err := os.MkdirAll(filepath.Join(dirs.SnapMountDir, "core"), 0755)
+	c.Assert(err, IsNil)
+	err = os.Symlink("100", filepath.Join(dirs.SnapMountDir, "core", "current"))
+	c.Assert(err, IsNil)
+
+	err = os.MkdirAll(apparmor_sandbox.SystemCacheDir, 0755)
+	c.Assert(err, IsNil)
+	// system profiles are kept
+	canaryKept := filepath.Join(apparmor_sandbox.SystemCacheDir, "meep")
+	err = ioutil.WriteFile(canaryKept, nil, 0644)
+	c.Assert(err, IsNil)
+	// but the snap-confine profiles are removed
+	for _, name := range []string{"usr.lib.snapd.snap-confine.real", "snap-confine.core.100", "snap-confine.snapd.6405"} {
+		err = ioutil.WriteFile(filepath.Join(apparmor_sandbox.SystemCacheDir, name), nil, 0644)
+		c.Assert(err, IsNil)
+	}
+
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", coreYaml, 111)
+
+	l, err := filepath.Glob(filepath.Join(apparmor_sandbox.SystemCacheDir, "*"))
+	c.Assert(err, IsNil)
+	c.Check(l, DeepEquals, []string{canaryKept})
+}
+
+func (s *backendSuite) TestCoreOnCoreRevertCleansApparmorCache(c *C) {
+	restorer := release.MockOnClassic(false)
+	defer restorer()
+
+	coreInfo := snaptest.MockInfo(c, coreYaml, &snap.SideInfo{Revision: snap.R(111)})
+	s.writeVanillaSnapConfineProfile(c, coreInfo)
+
+	// the current revision is newer than the one being set up
+	err := os.MkdirAll(filepath.Join(dirs.SnapMountDir, "core"), 0755)
+	c.Assert(err, IsNil)
+	err = os.Symlink("200", filepath.Join(dirs.SnapMountDir, "core", "current"))
+	c.Assert(err, IsNil)
+
+	err = os.MkdirAll(apparmor_sandbox.SystemCacheDir, 0755)
+	c.Assert(err, IsNil)
+	canaryPath := filepath.Join(apparmor_sandbox.SystemCacheDir, "meep")
+	err = ioutil.WriteFile(canaryPath, nil, 0644)
+	c.Assert(err, IsNil)
+
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", coreYaml, 111)
+
+	c.Check(canaryPath, testutil.FileAbsent)
+}
+
 // snap-confine policy when NFS is not used.
 func (s *backendSuite) TestSetupSnapConfineGeneratedPolicyNoNFS(c *C) {
 	// Make it appear as if NFS was not used.
@@ -2268,6 +2324,76 @@ apps:
 `)
 }
 
+func (s *backendSuite) TestBasePolicyIsAdded(c *C) {
+	restoreTemplate := apparmor.MockTemplate("template\n###SNIPPETS###\n")
+	defer restoreTemplate()
+	restoreClassicTemplate := apparmor.MockClassicTemplate("classic-template\n###SNIPPETS###\n")
+	defer restoreClassicTemplate()
+	restore := apparmor_sandbox.MockLevel(apparmor_sandbox.Full)
+	defer restore()
+	restore = osutil.MockIsHomeUsingNFS(func() (bool, error) { return false, nil })
+	defer restore()
+	restore = osutil.MockIsRootWritableOverlay(func() (string, error) { return "", nil })
+	defer restore()
+
+	basePolicyDir := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(basePolicyDir, "apparmor"), []byte(`# the dynamic linker lives elsewhere
+/usr/lib/base/ld-*.so mrix,
+  owner /usr/share/base/** rk,
+/etc/ld.so.cache r,
+`), 0644)
+	c.Assert(err, IsNil)
+
+	opts := interfaces.ConfinementOptions{BasePolicyDir: basePolicyDir}
+	snapInfo := s.InstallSnap(c, opts, "", ifacetest.SambaYamlV1, 1)
+	profile := filepath.Join(dirs.SnapAppArmorDir, "snap.samba.smbd")
+	c.Check(profile, testutil.FileContains, `
+  # Adjustments of the template shipped by the base snap
+  /usr/lib/base/ld-*.so mrix,
+  owner /usr/share/base/** rk,
+  /etc/ld.so.cache r,
+`)
+	s.RemoveSnap(c, snapInfo)
+
+	// classic confinement does not need it
+	opts.Classic = true
+	s.InstallSnap(c, opts, "", ifacetest.SambaYamlV1, 1)
+	c.Check(profile, Not(testutil.FileContains), "Adjustments of the template")
+}
+
+func (s *backendSuite) TestBasePolicyIsValidated(c *C) {
+	restoreTemplate := apparmor.MockTemplate("template\n###SNIPPETS###\n")
+	defer restoreTemplate()
+
+	basePolicyDir := c.MkDir()
+	snapInfo := snaptest.MockInfo(c, ifacetest.SambaYamlV1, nil)
+	opts := interfaces.ConfinementOptions{BasePolicyDir: basePolicyDir}
+	for _, t := range []struct {
+		policy string
+		err    string
+	}{
+		{"/usr/lib/base/** w,\n", `line 1: only read, map, lock and inherited execute file rules are allowed: "/usr/lib/base/\*\* w,"`},
+		{"/usr/bin/* ux,\n", `line 1: only read, map, lock and inherited execute file rules are allowed: "/usr/bin/\* ux,"`},
+		{"/usr/lib/base/ld.so r,\ncapability sys_admin,\n", `line 2: only read, map, lock and inherited execute file rules are allowed: "capability sys_admin,"`},
+		{"/usr/lib/base/ld.so r, /etc/shadow r,\n", `line 1: only read, map, lock and inherited execute file rules are allowed: .*`},
+		{"owner @{HOME}/.cache/base/** rk,\n", `line 1: only read, map, lock and inherited execute file rules are allowed: .*`},
+		{"#include <abstractions/base>\n", `line 1: includes are not allowed`},
+		{"/** r,\n", `line 1: access to "/\*\*" cannot be granted by a base`},
+		{"/dev/mem r,\n", `line 1: access to "/dev/mem" cannot be granted by a base`},
+		{"/etc/shadow r,\n", `line 1: access to "/etc/shadow" cannot be granted by a base`},
+		{"/etc/ld.so.cache* r,\n", `line 1: access to "/etc/ld.so.cache\*" cannot be granted by a base`},
+		{"/usr/lib/../../etc/shadow r,\n", `line 1: access to "/usr/lib/../../etc/shadow" cannot be granted by a base`},
+		{"/usr/lib/** r,\n", `line 1: access to "/usr/lib/\*\*" cannot be granted by a base`},
+		{"/lib/modules/** r,\n", `line 1: access to "/lib/modules/\*\*" cannot be granted by a base`},
+		{"/usr/lib/snapd/snap-confine mrix,\n", `line 1: access to "/usr/lib/snapd/snap-confine" cannot be granted by a base`},
+	} {
+		err := ioutil.WriteFile(filepath.Join(basePolicyDir, "apparmor"), []byte(t.policy), 0644)
+		c.Assert(err, IsNil)
+		err = s.Backend.Setup(snapInfo, opts, s.Repo, s.meas)
+		c.Check(err, ErrorMatches, "cannot use apparmor policy of base snap: "+t.err, Commentf("%q", t.policy))
+	}
+}
+
 func (s *backendSuite) TestPtraceTraceRule(c *C) {
 	restoreTemplate := apparmor.MockTemplate("template\n###SNIPPETS###\n")
 	defer restoreTemplate()
@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+)
+
+// A base policy fragment may only grant read, map, lock and inherited
+// execute access to paths, typically to the locations of the dynamic
+// linker and of the shared libraries of the base.
+var basePolicyRulePattern = regexp.MustCompile(`^(?:owner\s+)?(/[^\s,"#@]*)\s+(?:[rmk]|ix)+,$`)
+
+// basePolicyPathPrefixes are the directories, provided by the base snap
+// itself, to which a base policy fragment may grant access.
+var basePolicyPathPrefixes = []string{
+	"/bin/",
+	"/lib/",
+	"/lib32/",
+	"/lib64/",
+	"/libx32/",
+	"/sbin/",
+	"/usr/bin/",
+	"/usr/lib/",
+	"/usr/lib32/",
+	"/usr/lib64/",
+	"/usr/libexec/",
+	"/usr/libx32/",
+	"/usr/sbin/",
+	"/usr/share/",
+	"/etc/ld.so.conf.d/",
+}
+
+// basePolicyPaths are the files, provided by the base snap itself, to
+// which a base policy fragment may grant access.
+var basePolicyPaths = []string{
+	"/etc/ld.so.cache",
+	"/etc/ld.so.conf",
+}
+
+// basePolicyHostPathPrefixes are the directories under the allowed ones
+// that are provided by the host and not by the base.
+var basePolicyHostPathPrefixes = []string{
+	"/lib/firmware/",
+	"/lib/modules/",
+	"/usr/lib/firmware/",
+	"/usr/lib/modules/",
+	"/usr/lib/snapd/",
+}
+
+func basePolicyPathAllowed(path string) bool {
+	if strings.Contains(path, "..") {
+		return false
+	}
+	for _, p := range basePolicyPaths {
+		if path == p {
+			return true
+		}
+	}
+	for _, prefix := range basePolicyHostPathPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	for _, prefix := range basePolicyPathPrefixes {
+		// the prefix must be followed by something else than a pattern
+		// which could match the host provided directories
+		if strings.HasPrefix(path, prefix) && len(path) > len(prefix) && !strings.ContainsAny(path[len(prefix):len(prefix)+1], "*?{[") {
+			return true
+		}
+	}
+	return false
+}
+
+// basePolicySnippet returns the apparmor policy fragment shipped by the
+// base snap as a snippet to add to the profiles, after validating it.
+func basePolicySnippet(opts interfaces.ConfinementOptions) (string, error) {
+	fragment, err := interfaces.BasePolicyFragment(opts, interfaces.SecurityAppArmor)
+	if err != nil || fragment == "" {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\n  # Adjustments of the template shipped by the base snap\n")
+	for i, line := range strings.Split(fragment, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			// apparmor treats #include as a directive
+			if strings.HasPrefix(strings.TrimSpace(line[1:]), "include") {
+				return "", fmt.Errorf("cannot use apparmor policy of base snap: line %d: includes are not allowed", i+1)
+			}
+			continue
+		}
+		m := basePolicyRulePattern.FindStringSubmatch(line)
+		if m == nil {
+			return "", fmt.Errorf("cannot use apparmor policy of base snap: line %d: only read, map, lock and inherited execute file rules are allowed: %q", i+1, line)
+		}
+		if !basePolicyPathAllowed(m[1]) {
+			return "", fmt.Errorf("cannot use apparmor policy of base snap: line %d: access to %q cannot be granted by a base", i+1, m[1])
+		}
+		fmt.Fprintf(&buf, "  %s\n", line)
+	}
+	return buf.String(), nil
+}
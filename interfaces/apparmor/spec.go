@@ -362,6 +362,28 @@ func (spec *Specification) AddOvername(si *snap.Info) {
 	spec.AddUpdateNS(buf.String())
 }
 
+// AddPrivateTmpfs adds AppArmor snippets allowing snap-update-ns to mount
+// the size limited tmpfs of the snap over /tmp and /dev/shm.
+func (spec *Specification) AddPrivateTmpfs(si *snap.Info) {
+	if len(si.PrivateTmpfsSizes) == 0 {
+		return
+	}
+	paths := make([]string, 0, len(si.PrivateTmpfsSizes))
+	for path := range si.PrivateTmpfsSizes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "  # Allow mounting the size limited private tmpfs\n")
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "  mount fstype=tmpfs options=(rw nosuid nodev) tmpfs -> \"%s/\",\n", path)
+		fmt.Fprintf(&buf, "  mount options=(rprivate) -> \"%s/\",\n", path)
+		fmt.Fprintf(&buf, "  umount \"%s/\",\n", path)
+	}
+	spec.AddUpdateNS(buf.String())
+}
+
 // isProbably writable returns true if the path is probably representing writable area.
 func isProbablyWritable(path string) bool {
 	return strings.HasPrefix(path, "/var/snap/") || strings.HasPrefix(path, "/home/") || strings.HasPrefix(path, "/root/")
@@ -506,6 +506,33 @@ func (s *specSuite) TestApparmorOvernameSnippets(c *C) {
 	c.Assert(updateNS[0], Equals, profile)
 }
 
+func (s *specSuite) TestApparmorPrivateTmpfsSnippets(c *C) {
+	snapInfo := snaptest.MockInfo(c, snapTrivial, &snap.SideInfo{Revision: snap.R(42)})
+	s.spec.AddPrivateTmpfs(snapInfo)
+	// no private tmpfs, no snippets
+	c.Assert(s.spec.UpdateNS(), HasLen, 0)
+
+	snapInfo.PrivateTmpfsSizes = map[string]int64{
+		"/tmp":     64 * 1000 * 1000,
+		"/dev/shm": 1000 * 1000,
+	}
+	s.spec.AddPrivateTmpfs(snapInfo)
+	c.Assert(s.spec.Snippets(), HasLen, 0)
+
+	updateNS := s.spec.UpdateNS()
+	c.Assert(updateNS, HasLen, 1)
+
+	profile := `  # Allow mounting the size limited private tmpfs
+  mount fstype=tmpfs options=(rw nosuid nodev) tmpfs -> "/dev/shm/",
+  mount options=(rprivate) -> "/dev/shm/",
+  umount "/dev/shm/",
+  mount fstype=tmpfs options=(rw nosuid nodev) tmpfs -> "/tmp/",
+  mount options=(rprivate) -> "/tmp/",
+  umount "/tmp/",
+`
+	c.Assert(updateNS[0], Equals, profile)
+}
+
 func (s *specSuite) TestApparmorExtraLayouts(c *C) {
 	snapInfo := snaptest.MockInfo(c, snapTrivial, &snap.SideInfo{Revision: snap.R(42)})
 	snapInfo.InstanceKey = "instance"
@@ -69,6 +69,15 @@ type ConfinementOptions struct {
 	// as systemd provides a mount namespace which will clash with the
 	// one snapd sets up.
 	ExtraLayouts []snap.Layout
+	// DeniedSyscalls is a list of system calls that must not be allowed
+	// for the snap, regardless of its plugs and slots. This is used to
+	// tighten the seccomp profile of a snap as requested by the brand of
+	// the device.
+	DeniedSyscalls []string
+	// BasePolicyDir is the directory with the sandbox policy fragments
+	// shipped by the base snap of the snap to adjust the default
+	// templates, one file per security system. See BasePolicyFragment.
+	BasePolicyDir string
 }
 
 // SecurityBackendOptions carries extra flags that affect initialization of the
@@ -24,12 +24,14 @@ import (
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/dbus"
 	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/mount"
 	"github.com/snapcore/snapd/interfaces/polkit"
 	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/interfaces/systemd"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/sandbox"
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 )
 
@@ -68,5 +70,12 @@ func All() []interfaces.SecurityBackend {
 	case apparmor_sandbox.Partial, apparmor_sandbox.Full:
 		all = append(all, &apparmor.Backend{})
 	}
+
+	// Enable landlock backend when file system access is restricted with
+	// landlock by snap-exec, either in the experimental hybrid confinement
+	// mode or alongside other confinement.
+	if sandbox.LandlockConfinement() {
+		all = append(all, &landlock.Backend{})
+	}
 	return all
 }
@@ -18,12 +18,18 @@
 package backends_test
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/interfaces/backends"
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
+	"github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/sandbox/seccomp"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -74,3 +80,42 @@ func (s *backendsSuite) TestEssentialOrdering(c *C) {
 	c.Assert(sdIndex, testutil.IntNotEqual, -1)
 	c.Assert(sdIndex, testutil.IntLessThan, aaIndex)
 }
+
+func (s *backendsSuite) TestLandlockEnabledWithHybridConfinement(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	defer apparmor_sandbox.MockLevel(apparmor_sandbox.Unsupported)()
+	defer landlock.MockABI(2)()
+	defer seccomp.MockActions([]string{"allow", "errno"})()
+
+	names := func() []string {
+		var names []string
+		for _, backend := range backends.All() {
+			names = append(names, string(backend.Name()))
+		}
+		return names
+	}
+	c.Check(names(), Not(testutil.Contains), "landlock")
+
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(features.HybridConfinement.ControlFile(), nil, 0644), IsNil)
+	c.Check(names(), testutil.Contains, "landlock")
+	c.Check(names(), Not(testutil.Contains), "apparmor")
+}
+
+func (s *backendsSuite) TestLandlockEnabledAlongsideAppArmor(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	defer apparmor_sandbox.MockLevel(apparmor_sandbox.Full)()
+	defer landlock.MockABI(1)()
+
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(features.Landlock.ControlFile(), nil, 0644), IsNil)
+
+	var names []string
+	for _, backend := range backends.All() {
+		names = append(names, string(backend.Name()))
+	}
+	c.Check(names, testutil.Contains, "apparmor")
+	c.Check(names, testutil.Contains, "landlock")
+}
@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package interfaces
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// maxBasePolicySize is the maximum size of a base policy fragment.
+const maxBasePolicySize = 64 * 1024
+
+// BasePolicyDir returns the directory in which the given base snap ships
+// its sandbox policy fragments.
+func BasePolicyDir(baseInfo *snap.Info) string {
+	return filepath.Join(baseInfo.MountDir(), "meta", "sandbox")
+}
+
+// BasePolicyFragment returns the policy fragment that the base snap ships
+// to adjust the default template of the given security system, if any.
+// The fragment is meant to be validated by the backend of the security
+// system before use.
+func BasePolicyFragment(opts ConfinementOptions, system SecuritySystem) (string, error) {
+	if opts.BasePolicyDir == "" {
+		return "", nil
+	}
+	fname := filepath.Join(opts.BasePolicyDir, string(system))
+	st, err := os.Stat(fname)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !st.Mode().IsRegular() {
+		return "", fmt.Errorf("cannot use base %s policy %q: not a regular file", system, fname)
+	}
+	if st.Size() > maxBasePolicySize {
+		return "", fmt.Errorf("cannot use base %s policy %q: size %d exceeds the limit of %d bytes", system, fname, st.Size(), maxBasePolicySize)
+	}
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,83 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package interfaces_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type basePolicySuite struct{}
+
+var _ = Suite(&basePolicySuite{})
+
+func (s *basePolicySuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *basePolicySuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *basePolicySuite) TestBasePolicyDir(c *C) {
+	baseInfo := snaptest.MockInfo(c, "name: core22\nversion: 1\ntype: base\n", &snap.SideInfo{Revision: snap.R(5)})
+	c.Check(interfaces.BasePolicyDir(baseInfo), Equals, filepath.Join(dirs.SnapMountDir, "core22/5/meta/sandbox"))
+}
+
+func (s *basePolicySuite) TestBasePolicyFragment(c *C) {
+	dir := c.MkDir()
+	opts := interfaces.ConfinementOptions{BasePolicyDir: dir}
+
+	// no directory
+	fragment, err := interfaces.BasePolicyFragment(interfaces.ConfinementOptions{}, interfaces.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Check(fragment, Equals, "")
+
+	// no fragment for the security system
+	fragment, err = interfaces.BasePolicyFragment(opts, interfaces.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Check(fragment, Equals, "")
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "apparmor"), []byte("/opt/lib/** rm,\n"), 0644), IsNil)
+	fragment, err = interfaces.BasePolicyFragment(opts, interfaces.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Check(fragment, Equals, "/opt/lib/** rm,\n")
+}
+
+func (s *basePolicySuite) TestBasePolicyFragmentErrors(c *C) {
+	dir := c.MkDir()
+	opts := interfaces.ConfinementOptions{BasePolicyDir: dir}
+
+	c.Assert(os.Mkdir(filepath.Join(dir, "seccomp"), 0755), IsNil)
+	_, err := interfaces.BasePolicyFragment(opts, interfaces.SecuritySecComp)
+	c.Check(err, ErrorMatches, `cannot use base seccomp policy ".*/seccomp": not a regular file`)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "apparmor"), make([]byte, 64*1024+1), 0644), IsNil)
+	_, err = interfaces.BasePolicyFragment(opts, interfaces.SecurityAppArmor)
+	c.Check(err, ErrorMatches, `cannot use base apparmor policy ".*/apparmor": size 65537 exceeds the limit of 65536 bytes`)
+}
@@ -0,0 +1,247 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
+const blockDeviceRawSummary = `allows access to specific whole disk block device`
+
+// block-device-raw grants access to a particular whole disk, as needed by
+// imaging or diagnostic tools, without granting access to all the block
+// devices of the system like block-devices does. As with raw-volume, the disk
+// is device-specific, so require a snap declaration for connecting the
+// interface at all.
+//
+// Note that the read-only attribute is only enforced by AppArmor: the device
+// cgroup has no notion of access modes per device, the disk is added to it
+// with read, write and mknod access either way. Without AppArmor, or with it
+// in complain mode, a read-only connection still allows writing to the disk.
+const blockDeviceRawBaseDeclarationSlots = `
+  block-device-raw:
+    allow-installation:
+      slot-snap-type:
+        - core
+        - gadget
+    deny-connection: true
+    deny-auto-connection: true
+`
+
+const blockDeviceRawConnectedPlugAppArmor = `
+# Description: can access the whole disk %[1]s
+%[1]s %[3]s,
+
+# allow read access to sysfs and udev for the disk
+@{PROC}/devices r,
+/run/udev/data/b[0-9]*:[0-9]* r,
+/sys/block/ r,
+/sys/devices/**/block/%[2]s/ r,
+/sys/devices/**/block/%[2]s/** r,
+`
+
+const blockDeviceRawConnectedPlugAppArmorWrite = `
+# needed to discard blocks and to have the kernel re-read the partition table
+# once the disk has been written to
+capability sys_admin,
+`
+
+// The type for this interface
+type blockDeviceRawInterface struct{}
+
+// Getter for the name of this interface
+func (iface *blockDeviceRawInterface) Name() string {
+	return "block-device-raw"
+}
+
+func (iface *blockDeviceRawInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              blockDeviceRawSummary,
+		BaseDeclarationSlots: blockDeviceRawBaseDeclarationSlots,
+	}
+}
+
+func (iface *blockDeviceRawInterface) String() string {
+	return iface.Name()
+}
+
+// The same devices as raw-volume, but the whole disks instead of their
+// partitions.
+
+// IDE, MFM, RLL hda-hdt:
+const hdDiskPat = `hd[a-t]`
+
+// SCSI sda-sdiv:
+const sdDiskPat = `sd([a-z]|[a-h][a-z]|i[a-v])`
+
+// I2O i2o/hda-hddx:
+const i2oDiskPat = `i2o/hd([a-z]|[a-c][a-z]|d[a-x])`
+
+// MMC mmcblk0-999:
+const mmcDiskPat = `mmcblk([0-9]|[1-9][0-9]{1,2})`
+
+// NVMe nvme0-99, with 1-63 namespaces:
+const nvmeDiskPat = `nvme([0-9]|[1-9][0-9])n([1-9]|[1-5][0-9]|6[0-3])`
+
+// virtio vda-vdz:
+const vdDiskPat = `vd[a-z]`
+
+var blockDeviceRawDiskPattern = regexp.MustCompile(fmt.Sprintf("^/dev/(%s|%s|%s|%s|%s|%s)$", hdDiskPat, sdDiskPat, i2oDiskPat, mmcDiskPat, nvmeDiskPat, vdDiskPat))
+
+const invalidDiskSlotPathErrFmt = "slot %q path attribute must be a valid whole disk device node"
+
+// blockDeviceRawSlotAttrs returns the path of the disk of the slot and
+// whether it must only be read.
+func blockDeviceRawSlotAttrs(slotRef *interfaces.SlotRef, attrs interfaces.Attrer) (path string, readOnly bool, err error) {
+	path, err = verifySlotPathAttribute(slotRef, attrs, blockDeviceRawDiskPattern, invalidDiskSlotPathErrFmt)
+	if err != nil {
+		return "", false, err
+	}
+	if v, ok := attrs.Lookup("read-only"); ok {
+		readOnly, ok = v.(bool)
+		if !ok {
+			return "", false, fmt.Errorf("slot %q read-only attribute must be a boolean", slotRef)
+		}
+	}
+	return path, readOnly, nil
+}
+
+// Check validity of the defined slot
+func (iface *blockDeviceRawInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, _, err := blockDeviceRawSlotAttrs(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot)
+	return err
+}
+
+// Check validity of the defined plug, which can ask for read-only access to
+// a disk that could otherwise be written to.
+func (iface *blockDeviceRawInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	// It's fine if 'read-only' isn't specified, but if it is, it needs to be bool
+	if v, ok := plug.Attrs["read-only"]; ok {
+		if _, ok = v.(bool); !ok {
+			return fmt.Errorf(`block-device-raw "read-only" attribute must be a boolean`)
+		}
+	}
+	return nil
+}
+
+func (iface *blockDeviceRawInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, readOnly, err := blockDeviceRawSlotAttrs(slot.Ref(), slot)
+	if err != nil {
+		return nil
+	}
+	// the disk is write-protected if either side asks for it, this is
+	// the only place where the protection is enforced
+	var plugReadOnly bool
+	_ = plug.Attr("read-only", &plugReadOnly)
+	readOnly = readOnly || plugReadOnly
+
+	access := "rwk"
+	if readOnly {
+		access = "r"
+	}
+	spec.AddSnippet(fmt.Sprintf(blockDeviceRawConnectedPlugAppArmor, path, strings.TrimPrefix(path, "/dev/"), access))
+	if !readOnly {
+		spec.AddSnippet(blockDeviceRawConnectedPlugAppArmorWrite)
+	}
+
+	return nil
+}
+
+func (iface *blockDeviceRawInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	path, _, err := blockDeviceRawSlotAttrs(slot.Ref(), slot)
+	if err != nil {
+		return nil
+	}
+
+	// Only tag the disk itself, such that the partitions on it are not
+	// added to the device cgroup of the snap. The device cgroup grants
+	// write access even to a read-only disk, which is only protected by
+	// the AppArmor rules, see AppArmorConnectedPlug.
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="block", ENV{DEVTYPE}=="disk", KERNEL=="%s"`, strings.TrimPrefix(path, "/dev/")))
+
+	return nil
+}
+
+func (iface *blockDeviceRawInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
+	// Allow what is allowed in the declarations
+	return true
+}
+
+// usbStorageDeviceClass is the class of the USB mass storage disks. Each LUN
+// of a card reader is a disk of its own, which ID_SERIAL accounts for.
+var usbStorageDeviceClass = hotplug.DeviceClass{
+	Name:              "usb-storage",
+	Subsystem:         "block",
+	DeviceType:        "disk",
+	Bus:               "usb",
+	DeviceNamePattern: blockDeviceRawDiskPattern,
+	KeyAttrs: [][]string{
+		{"ID_VENDOR_ID"},
+		{"ID_MODEL_ID"},
+		// the same disk plugged into another port keeps its identity
+		// only if it has a serial number
+		{"ID_SERIAL", "ID_PATH"},
+	},
+	NameAttrs: []string{"ID_MODEL", "ID_MODEL_FROM_DATABASE"},
+}
+
+func (iface *blockDeviceRawInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	if !usbStorageDeviceClass.Matches(di) {
+		return nil, nil
+	}
+
+	slot := hotplug.ProposedSlot{
+		Name: usbStorageDeviceClass.SlotName(di),
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}
+	if vendor, ok := di.Attribute("ID_VENDOR_ID"); ok {
+		slot.Attrs["usb-vendor"] = vendor
+	}
+	if product, ok := di.Attribute("ID_MODEL_ID"); ok {
+		slot.Attrs["usb-product"] = product
+	}
+	return &slot, nil
+}
+
+func (iface *blockDeviceRawInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	return usbStorageDeviceClass.Key(di)
+}
+
+func (iface *blockDeviceRawInterface) HandledByGadget(di *hotplug.HotplugDeviceInfo, slot *snap.SlotInfo) bool {
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return false
+	}
+	return di.DeviceName() == path
+}
+
+func init() {
+	registerIface(&blockDeviceRawInterface{})
+}
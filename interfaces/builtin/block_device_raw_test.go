@@ -0,0 +1,313 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type blockDeviceRawInterfaceSuite struct {
+	testutil.BaseTest
+	iface interfaces.Interface
+
+	slotInfo         *snap.SlotInfo
+	slot             *interfaces.ConnectedSlot
+	readOnlySlotInfo *snap.SlotInfo
+	readOnlySlot     *interfaces.ConnectedSlot
+
+	plugInfo         *snap.PlugInfo
+	plug             *interfaces.ConnectedPlug
+	readOnlyPlugInfo *snap.PlugInfo
+	readOnlyPlug     *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&blockDeviceRawInterfaceSuite{
+	iface: builtin.MustInterface("block-device-raw"),
+})
+
+const blockDeviceRawMockSlotSnapInfoYaml = `name: some-device
+version: 0
+type: gadget
+slots:
+  sdcard:
+    interface: block-device-raw
+    path: /dev/mmcblk1
+  emmc:
+    interface: block-device-raw
+    path: /dev/mmcblk0
+    read-only: true
+`
+
+const blockDeviceRawMockPlugSnapInfoYaml = `name: client-snap
+version: 0
+plugs:
+  disk:
+    interface: block-device-raw
+  disk-ro:
+    interface: block-device-raw
+    read-only: true
+apps:
+  imager:
+    command: foo
+    plugs: [disk]
+  inspector:
+    command: foo
+    plugs: [disk-ro]
+`
+
+func (s *blockDeviceRawInterfaceSuite) SetUpTest(c *C) {
+	slotSnapInfo := snaptest.MockInfo(c, blockDeviceRawMockSlotSnapInfoYaml, nil)
+	s.slotInfo = slotSnapInfo.Slots["sdcard"]
+	s.slot = interfaces.NewConnectedSlot(s.slotInfo, nil, nil)
+	s.readOnlySlotInfo = slotSnapInfo.Slots["emmc"]
+	s.readOnlySlot = interfaces.NewConnectedSlot(s.readOnlySlotInfo, nil, nil)
+
+	plugSnapInfo := snaptest.MockInfo(c, blockDeviceRawMockPlugSnapInfoYaml, nil)
+	s.plugInfo = plugSnapInfo.Plugs["disk"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+	s.readOnlyPlugInfo = plugSnapInfo.Plugs["disk-ro"]
+	s.readOnlyPlug = interfaces.NewConnectedPlug(s.readOnlyPlugInfo, nil, nil)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "block-device-raw")
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.readOnlySlotInfo), IsNil)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestSanitizeSlotPaths(c *C) {
+	const mockSnapYaml = `name: block-device-raw-slot-snap
+type: gadget
+version: 1.0
+slots:
+  block-device-raw:
+    path: $t
+`
+
+	const invalidErr = `slot "block-device-raw-slot-snap:block-device-raw" path attribute must be a valid whole disk device node`
+	for _, t := range []struct {
+		path string
+		err  string
+	}{
+		{`/dev/hda`, ""},
+		{`/dev/hdt`, ""},
+		{`/dev/sda`, ""},
+		{`/dev/sdde`, ""},
+		{`/dev/sdiv`, ""},
+		{`/dev/i2o/hda`, ""},
+		{`/dev/i2o/hddx`, ""},
+		{`/dev/mmcblk0`, ""},
+		{`/dev/mmcblk999`, ""},
+		{`/dev/nvme0n1`, ""},
+		{`/dev/nvme99n63`, ""},
+		{`/dev/vda`, ""},
+		{`/dev/vdz`, ""},
+		{`/dev/hdu`, invalidErr},
+		{`/dev/sda1`, invalidErr},
+		{`/dev/sdiw`, invalidErr},
+		{`/dev/i2o/hddy`, invalidErr},
+		{`/dev/mmcblk0p1`, invalidErr},
+		{`/dev/mmcblk1000`, invalidErr},
+		{`/dev/mmcblk0boot0`, invalidErr},
+		{`/dev/nvme0`, invalidErr},
+		{`/dev/nvme0n0`, invalidErr},
+		{`/dev/nvme0n1p1`, invalidErr},
+		{`/dev/vda1`, invalidErr},
+		{`/dev/loop0`, invalidErr},
+		{`/dev/./sda`, `cannot use slot "block-device-raw-slot-snap:block-device-raw" path "/dev/./sda": try "/dev/sda".*`},
+		{`""`, `slot "block-device-raw-slot-snap:block-device-raw" must have a path attribute`},
+	} {
+		yml := strings.Replace(mockSnapYaml, "$t", t.path, -1)
+		info := snaptest.MockInfo(c, yml, nil)
+		slot := info.Slots["block-device-raw"]
+
+		err := interfaces.BeforePrepareSlot(s.iface, slot)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("unexpected error for %q", t.path))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("unexpected error for %q", t.path))
+		}
+	}
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestSanitizeSlotReadOnlyNotBool(c *C) {
+	const mockSnapYaml = `name: some-device
+type: gadget
+version: 1.0
+slots:
+  block-device-raw:
+    path: /dev/sda
+    read-only: "yes"
+`
+	info := snaptest.MockInfo(c, mockSnapYaml, nil)
+	c.Check(interfaces.BeforePrepareSlot(s.iface, info.Slots["block-device-raw"]), ErrorMatches,
+		`slot "some-device:block-device-raw" read-only attribute must be a boolean`)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.readOnlyPlugInfo), IsNil)
+
+	const mockSnapYaml = `name: client-snap
+version: 1.0
+plugs:
+  block-device-raw:
+    read-only: 1
+`
+	info := snaptest.MockInfo(c, mockSnapYaml, nil)
+	c.Check(interfaces.BeforePreparePlug(s.iface, info.Plugs["block-device-raw"]), ErrorMatches,
+		`block-device-raw "read-only" attribute must be a boolean`)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Assert(spec.Snippets()[0], Equals, `# block-device-raw
+SUBSYSTEM=="block", ENV{DEVTYPE}=="disk", KERNEL=="mmcblk1", TAG+="snap_client-snap_imager"`)
+	c.Assert(spec.Snippets(), testutil.Contains, fmt.Sprintf(`TAG=="snap_client-snap_imager", RUN+="%v/snap-device-helper $env{ACTION} snap_client-snap_imager $devpath $major:$minor"`, dirs.DistroLibExecDir))
+
+	// the device is tagged the same when it is write-protected
+	spec = &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.readOnlyPlug, s.readOnlySlot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Assert(spec.Snippets()[0], Equals, `# block-device-raw
+SUBSYSTEM=="block", ENV{DEVTYPE}=="disk", KERNEL=="mmcblk0", TAG+="snap_client-snap_inspector"`)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.client-snap.imager"})
+	c.Check(spec.SnippetForTag("snap.client-snap.imager"), testutil.Contains, `/dev/mmcblk1 rwk,`)
+	c.Check(spec.SnippetForTag("snap.client-snap.imager"), testutil.Contains, `capability sys_admin,`)
+	c.Check(spec.SnippetForTag("snap.client-snap.imager"), testutil.Contains, `/sys/devices/**/block/mmcblk1/** r,`)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestAppArmorSpecReadOnly(c *C) {
+	for _, t := range []struct {
+		plug *interfaces.ConnectedPlug
+		slot *interfaces.ConnectedSlot
+		path string
+	}{
+		// write-protected by the slot
+		{s.plug, s.readOnlySlot, "/dev/mmcblk0"},
+		// write-protected by the plug
+		{s.readOnlyPlug, s.slot, "/dev/mmcblk1"},
+		{s.readOnlyPlug, s.readOnlySlot, "/dev/mmcblk0"},
+	} {
+		spec := &apparmor.Specification{}
+		c.Assert(spec.AddConnectedPlug(s.iface, t.plug, t.slot), IsNil)
+		c.Assert(spec.SecurityTags(), HasLen, 1)
+		snippet := spec.SnippetForTag(spec.SecurityTags()[0])
+		c.Check(snippet, testutil.Contains, t.path+" r,")
+		c.Check(snippet, Not(testutil.Contains), t.path+" rwk,")
+		c.Check(snippet, Not(testutil.Contains), `capability sys_admin,`)
+	}
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, false)
+	c.Assert(si.Summary, Equals, `allows access to specific whole disk block device`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "block-device-raw")
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(nil, nil), Equals, true)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sdb", "DEVTYPE": "disk", "ID_VENDOR_ID": "0781", "ID_MODEL_ID": "5567", "ID_MODEL": "Cruzer_Blade", "ACTION": "add", "SUBSYSTEM": "block", "ID_BUS": "usb"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Assert(proposedSlot, DeepEquals, &hotplug.ProposedSlot{Name: "cruzerblade", Attrs: map[string]interface{}{"path": "/dev/sdb", "usb-vendor": "0781", "usb-product": "5567"}})
+
+	// the hotplugged slot is a valid block-device-raw slot
+	slotInfo := MockHotplugSlot(c, "name: core\nversion: 0\ntype: os\n", nil, "1234", "block-device-raw", "cruzerblade", proposedSlot.Attrs)
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, interfaces.NewConnectedSlot(slotInfo, nil, nil)), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.imager"), testutil.Contains, "/dev/sdb rwk,")
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestHotplugDeviceDetectedNotUSBDisk(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	for _, env := range []map[string]string{
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sdb1", "DEVTYPE": "partition", "SUBSYSTEM": "block", "ID_BUS": "usb"},
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sda", "DEVTYPE": "disk", "SUBSYSTEM": "block", "ID_BUS": "ata"},
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sr0", "DEVTYPE": "disk", "SUBSYSTEM": "block", "ID_BUS": "usb"},
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "SUBSYSTEM": "tty", "ID_BUS": "usb"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil)
+	}
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestHotplugKey(c *C) {
+	keyHandler := s.iface.(hotplug.HotplugKeyHandler)
+	env := map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/sdb", "DEVTYPE": "disk", "ID_VENDOR_ID": "0781", "ID_MODEL_ID": "5567", "ID_SERIAL": "SanDisk_Cruzer_Blade_4C530001-0:0", "SUBSYSTEM": "block", "ID_BUS": "usb"}
+	di, err := hotplug.NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+	key, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key, Not(Equals), snap.HotplugKey(""))
+
+	// another LUN of the same device
+	env["ID_SERIAL"] = "SanDisk_Cruzer_Blade_4C530001-0:1"
+	di, err = hotplug.NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+	other, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(other, Not(Equals), key)
+}
+
+func (s *blockDeviceRawInterfaceSuite) TestHotplugHandledByGadget(c *C) {
+	byGadgetPred := s.iface.(hotplug.HandledByGadgetPredicate)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/mmcblk1", "DEVTYPE": "disk", "SUBSYSTEM": "block"})
+	c.Assert(err, IsNil)
+	c.Check(byGadgetPred.HandledByGadget(di, s.slotInfo), Equals, true)
+	c.Check(byGadgetPred.HandledByGadget(di, s.readOnlySlotInfo), Equals, false)
+}
@@ -19,6 +19,18 @@
 
 package builtin
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
 const cameraSummary = `allows access to all cameras`
 
 const cameraBaseDeclarationSlots = `
@@ -52,13 +64,106 @@ const cameraConnectedPlugAppArmor = `
 /sys/devices/platform/**/usb*/**/video4linux/** r,
 `
 
+// The slots created for hotplugged cameras grant access to that camera only.
+const cameraDeviceConnectedPlugAppArmor = `
+# Description: can access the camera %[1]s
+%[1]s rw,
+
+# Allow detection of the camera
+/sys/bus/usb/devices/ r,
+/run/udev/data/c81:[0-9]* r, # video4linux (/dev/video*, etc)
+/run/udev/data/+usb:* r,
+/sys/class/video4linux/ r,
+/sys/devices/pci**/usb*/**/video4linux/%[2]s/** r,
+/sys/devices/platform/**/usb*/**/video4linux/%[2]s/** r,
+`
+
 var cameraConnectedPlugUDev = []string{
 	`KERNEL=="video[0-9]*"`,
 	`KERNEL=="vchiq"`,
 }
 
+var cameraDeviceNodePattern = regexp.MustCompile(`^/dev/video[0-9]+$`)
+
+// cameraDeviceClass is the class of the USB cameras supported by V4L2. A
+// camera may have more than one device node, e.g. one for capturing and one
+// for metadata, only the capture nodes get a slot.
+var cameraDeviceClass = hotplug.DeviceClass{
+	Name:              "usb-camera",
+	Subsystem:         "video4linux",
+	Bus:               "usb",
+	DeviceNamePattern: cameraDeviceNodePattern,
+	Match: func(di *hotplug.HotplugDeviceInfo) bool {
+		caps, _ := di.Attribute("ID_V4L_CAPABILITIES")
+		return strings.Contains(caps, ":capture:")
+	},
+	KeyAttrs: [][]string{
+		{"ID_VENDOR_ID"},
+		{"ID_MODEL_ID"},
+		// the same camera plugged into another port keeps its
+		// identity only if it has a serial number
+		{"ID_SERIAL_SHORT", "ID_PATH"},
+		{"ID_USB_INTERFACE_NUM"},
+	},
+	NameAttrs: []string{"ID_V4L_PRODUCT", "ID_MODEL_FROM_DATABASE", "ID_MODEL"},
+}
+
+// cameraInterface grants access to all cameras through the implicit slot,
+// and to a single camera through the slots created for hotplugged cameras,
+// which carry the path of the device.
+type cameraInterface struct {
+	commonInterface
+}
+
+func (iface *cameraInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return iface.commonInterface.AppArmorConnectedPlug(spec, plug, slot)
+	}
+	if !cameraDeviceNodePattern.MatchString(path) {
+		return nil
+	}
+	spec.AddSnippet(fmt.Sprintf(cameraDeviceConnectedPlugAppArmor, path, strings.TrimPrefix(path, "/dev/")))
+	return nil
+}
+
+func (iface *cameraInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return iface.commonInterface.UDevConnectedPlug(spec, plug, slot)
+	}
+	if !cameraDeviceNodePattern.MatchString(path) {
+		return nil
+	}
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="video4linux", KERNEL=="%s"`, strings.TrimPrefix(path, "/dev/")))
+	return nil
+}
+
+func (iface *cameraInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	if !cameraDeviceClass.Matches(di) {
+		return nil, nil
+	}
+	slot := hotplug.ProposedSlot{
+		Name: cameraDeviceClass.SlotName(di),
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}
+	if vendor, ok := di.Attribute("ID_VENDOR_ID"); ok {
+		slot.Attrs["usb-vendor"] = vendor
+	}
+	if product, ok := di.Attribute("ID_MODEL_ID"); ok {
+		slot.Attrs["usb-product"] = product
+	}
+	return &slot, nil
+}
+
+func (iface *cameraInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	return cameraDeviceClass.Key(di)
+}
+
 func init() {
-	registerIface(&commonInterface{
+	registerIface(&cameraInterface{commonInterface{
 		name:                  "camera",
 		summary:               cameraSummary,
 		implicitOnCore:        true,
@@ -66,5 +171,5 @@ func init() {
 		baseDeclarationSlots:  cameraBaseDeclarationSlots,
 		connectedPlugAppArmor: cameraConnectedPlugAppArmor,
 		connectedPlugUDev:     cameraConnectedPlugUDev,
-	})
+	}})
 }
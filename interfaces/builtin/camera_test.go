@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -109,3 +110,66 @@ func (s *CameraInterfaceSuite) TestAutoConnect(c *C) {
 func (s *CameraInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *CameraInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video2", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_V4L_PRODUCT": "UVC Camera (046d:0825)", "ID_V4L_CAPABILITIES": ":capture:", "ACTION": "add", "SUBSYSTEM": "video4linux", "ID_BUS": "usb"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Assert(proposedSlot, DeepEquals, &hotplug.ProposedSlot{Name: "uvccamera046d0825", Attrs: map[string]interface{}{"path": "/dev/video2", "usb-vendor": "046d", "usb-product": "0825"}})
+}
+
+func (s *CameraInterfaceSuite) TestHotplugDeviceDetectedNotCamera(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	for _, env := range []map[string]string{
+		// metadata node of a camera
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video3", "ID_V4L_CAPABILITIES": ":", "SUBSYSTEM": "video4linux", "ID_BUS": "usb"},
+		// built-in camera
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video0", "ID_V4L_CAPABILITIES": ":capture:", "SUBSYSTEM": "video4linux"},
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/v4l-subdev0", "ID_V4L_CAPABILITIES": ":capture:", "SUBSYSTEM": "video4linux", "ID_BUS": "usb"},
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/ttyUSB0", "SUBSYSTEM": "tty", "ID_BUS": "usb"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil)
+	}
+}
+
+func (s *CameraInterfaceSuite) TestHotplugKey(c *C) {
+	keyHandler := s.iface.(hotplug.HotplugKeyHandler)
+	env := map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/video2", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "0825", "ID_SERIAL_SHORT": "D5A4E2A0", "ID_PATH": "pci-0000:00:14.0-usb-0:1:1.0", "ID_USB_INTERFACE_NUM": "00", "SUBSYSTEM": "video4linux", "ID_BUS": "usb"}
+	di, err := hotplug.NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+	key, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key, Not(Equals), snap.HotplugKey(""))
+
+	// the camera keeps its identity when plugged into another port
+	env["ID_PATH"] = "pci-0000:00:14.0-usb-0:2:1.0"
+	env["DEVNAME"] = "/dev/video4"
+	di, err = hotplug.NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+	other, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(other, Equals, key)
+}
+
+func (s *CameraInterfaceSuite) TestHotplugSlotSpecs(c *C) {
+	slotInfo := MockHotplugSlot(c, cameraCoreYaml, nil, "1234", "camera", "uvccamera", map[string]interface{}{"path": "/dev/video2"})
+	slot := interfaces.NewConnectedSlot(slotInfo, nil, nil)
+
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), testutil.Contains, "/dev/video2 rw,")
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/video[0-9]*")
+	c.Check(apparmorSpec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "/dev/vchiq")
+
+	udevSpec := &udev.Specification{}
+	c.Assert(udevSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(udevSpec.Snippets(), HasLen, 2)
+	c.Check(udevSpec.Snippets(), testutil.Contains, `# camera
+SUBSYSTEM=="video4linux", KERNEL=="video2", TAG+="snap_consumer_app"`)
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/mount"
 	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/interfaces/udev"
@@ -51,6 +52,8 @@ type commonInterface struct {
 
 	affectsPlugOnRefresh bool
 
+	deprecatedBy string
+
 	baseDeclarationPlugs string
 	baseDeclarationSlots string
 
@@ -62,6 +65,8 @@ type commonInterface struct {
 	connectedPlugUpdateNSAppArmor string
 	connectedPlugMount            []osutil.MountEntry
 
+	connectedPlugLandlock []string
+
 	connectedPlugKModModules []string
 	connectedSlotKModModules []string
 	permanentPlugKModModules []string
@@ -90,6 +95,7 @@ func (iface *commonInterface) StaticInfo() interfaces.StaticInfo {
 		DocURL:               iface.docURL,
 		ImplicitOnCore:       iface.implicitOnCore,
 		ImplicitOnClassic:    iface.implicitOnClassic,
+		DeprecatedBy:         iface.deprecatedBy,
 		BaseDeclarationPlugs: iface.baseDeclarationPlugs,
 		BaseDeclarationSlots: iface.baseDeclarationSlots,
 		// affects the plug snap because of mount backend
@@ -160,6 +166,13 @@ func (iface *commonInterface) MountConnectedPlug(spec *mount.Specification, plug
 	return nil
 }
 
+func (iface *commonInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	for _, rule := range iface.connectedPlugLandlock {
+		spec.AddRule(rule)
+	}
+	return nil
+}
+
 func (iface *commonInterface) KModPermanentPlug(spec *kmod.Specification, plug *snap.PlugInfo) error {
 	for _, m := range iface.permanentPlugKModModules {
 		if err := spec.AddModule(m); err != nil {
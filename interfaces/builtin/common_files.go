@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/snap"
 )
@@ -165,3 +166,25 @@ func (iface *commonFilesInterface) AppArmorConnectedPlug(spec *apparmor.Specific
 
 	return nil
 }
+
+func (iface *commonFilesInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	var reads, writes []interface{}
+	_ = plug.Attr("read", &reads)
+	_ = plug.Attr("write", &writes)
+
+	errPrefix := fmt.Sprintf(`cannot connect plug %s: `, plug.Name())
+	for _, rule := range []struct {
+		access string
+		paths  []interface{}
+	}{{"ro", reads}, {"rw", writes}} {
+		for _, rawPath := range rule.paths {
+			p, ok := rawPath.(string)
+			if !ok {
+				return fmt.Errorf("%s%[2]v (%[2]T) is not a string", errPrefix, rawPath)
+			}
+			// $HOME is expanded by snap-exec
+			spec.AddRule(fmt.Sprintf("%s %s", rule.access, filepath.Clean(p)))
+		}
+	}
+	return nil
+}
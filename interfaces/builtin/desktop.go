@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/mount"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
+	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/strutil"
 )
@@ -347,6 +348,18 @@ func (iface *desktopInterface) fontconfigDirs(plug *interfaces.ConnectedPlug) ([
 func (iface *desktopInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
 	spec.AddSnippet(desktopConnectedPlugAppArmor)
 
+	if apparmor_sandbox.ProbedLevel() != apparmor_sandbox.Unsupported {
+		features, err := apparmor_sandbox.ParserFeatures()
+		if err != nil {
+			return err
+		}
+		if strutil.ListContains(features, "include-if-exists") {
+			// the launch policy enforced by the session launcher of
+			// snap userd must not be bypassed via the portals
+			spec.AddSnippet(fmt.Sprintf("#include if exists %q\n", apparmor_sandbox.LaunchPolicyRulesPath))
+		}
+	}
+
 	// Allow mounting document portal
 	emit := spec.AddUpdateNSf
 	emit("  # Mount the document portal\n")
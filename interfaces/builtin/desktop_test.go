@@ -33,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/builtin"
 	"github.com/snapcore/snapd/interfaces/mount"
 	"github.com/snapcore/snapd/release"
+	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -130,6 +131,24 @@ func (s *DesktopInterfaceSuite) TestAppArmorSpec(c *C) {
 	c.Assert(spec.SecurityTags(), HasLen, 0)
 }
 
+func (s *DesktopInterfaceSuite) TestAppArmorSpecLaunchPolicyRules(c *C) {
+	restore := apparmor_sandbox.MockLevel(apparmor_sandbox.Full)
+	defer restore()
+	restore = apparmor_sandbox.MockFeatures(nil, nil, []string{"include-if-exists"}, nil)
+	defer restore()
+
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.coreSlot), IsNil)
+	c.Check(spec.SnippetForTag("snap.consumer.app"), testutil.Contains, `#include if exists "/var/lib/snapd/apparmor/launch-policy"`+"\n")
+
+	// without support for conditional includes in the parser
+	restore = apparmor_sandbox.MockFeatures(nil, nil, nil, nil)
+	defer restore()
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.coreSlot), IsNil)
+	c.Check(spec.SnippetForTag("snap.consumer.app"), Not(testutil.Contains), "launch-policy")
+}
+
 func (s *DesktopInterfaceSuite) TestMountSpec(c *C) {
 	tmpdir := c.MkDir()
 	dirs.SetRootDir(tmpdir)
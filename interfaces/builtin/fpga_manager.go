@@ -0,0 +1,214 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/systemd"
+	"github.com/snapcore/snapd/snap"
+)
+
+const fpgaManagerSummary = `allows loading bitstreams with a specific FPGA manager`
+
+const fpgaManagerBaseDeclarationSlots = `
+  fpga-manager:
+    allow-installation:
+      slot-snap-type:
+        - core
+        - gadget
+    deny-auto-connection: true
+`
+
+// https://github.com/torvalds/linux/blob/master/Documentation/ABI/testing/sysfs-class-fpga-manager
+const fpgaManagerConnectedPlugAppArmor = `
+# Description: Can load bitstreams with a specific FPGA manager.
+
+/sys/class/fpga_manager/###MANAGER### r,
+/sys/devices/**/fpga_manager/###MANAGER###/{name,state,status} r,
+/sys/devices/**/fpga_manager/###MANAGER###/firmware w,
+/sys/devices/**/fpga_manager/###MANAGER###/{flags,key} rw,
+
+# Bitstreams are looked up by the kernel in the firmware search path set up
+# for the connection, where each manager has a directory for the snap to
+# put them in, as /lib/firmware is not writable by snaps. The firmware
+# to load is then named ###MANAGER###/<bitstream> relative to it.
+/var/lib/snapd/fpga-firmware/###MANAGER###/ rw,
+/var/lib/snapd/fpga-firmware/###MANAGER###/** rw,
+`
+
+// Rules common to all connections, giving insight into the state of the
+// FPGA regions and bridges which are reconfigured along with the manager.
+var fpgaManagerCommonConnectedPlugAppArmor = []string{
+	"/sys/class/fpga_manager/ r,",
+	"/sys/devices/**/fpga_region/region[0-9]*/compat_id r,",
+	"/sys/devices/**/fpga_bridge/bridge[0-9]*/{name,state} r,",
+	// Xilinx zynqmp module parameters (not upstreamed yet)
+	// https://github.com/Xilinx/linux-xlnx/blob/master/drivers/fpga/zynqmp-fpga.c#L36
+	// The parameter applies to all the FPGA managers, it is set through the
+	// readback-type plug attribute rather than by the apps.
+	"/sys/module/zynqmp_fpga/parameters/readback_type r,",
+	"/sys/module/firmware_class/parameters/path r,",
+	"/var/lib/snapd/fpga-firmware/ r,",
+}
+
+// fpgaManagerFirmwareDir is the firmware search path of the kernel while
+// FPGA managers are connected, it is writable by the connected snaps unlike
+// /lib/firmware.
+const fpgaManagerFirmwareDir = "/var/lib/snapd/fpga-firmware"
+
+const fpgaManagerFirmwarePathParam = "/sys/module/firmware_class/parameters/path"
+
+// fpgaManagerFirmwarePathSaved holds the firmware search path from before
+// it was set for the connections, so that it can be restored.
+const fpgaManagerFirmwarePathSaved = "/run/snapd/firmware-class-path"
+
+const fpgaManagerReadbackTypeParam = "/sys/module/zynqmp_fpga/parameters/readback_type"
+
+// fpgaManagerReadbackTypeSaved holds the value of the readback_type parameter
+// from before it was set for a connection, so that it can be restored.
+const fpgaManagerReadbackTypeSaved = "/run/snapd/zynqmp-fpga-readback-type"
+
+// Values of the readback_type parameter of the Xilinx zynqmp module.
+var fpgaManagerReadbackTypes = map[string]int{
+	"configuration-registers": 0,
+	"configuration-data":      1,
+}
+
+// fpgaManagerInterface is the type for fpga-manager interfaces.
+//
+// While connected, the custom firmware search path of the kernel is set to
+// /var/lib/snapd/fpga-firmware, with a directory named after each connected
+// manager where the snap puts the bitstreams to load with that manager.
+//
+// Plugs may set the readback-type attribute to either
+// "configuration-registers" or "configuration-data", for the type of readback
+// of Xilinx zynqmp FPGA managers. The readback type is set while the plug is
+// connected and the previous one is restored when it is disconnected.
+type fpgaManagerInterface struct{}
+
+// Name of the fpga-manager interface.
+func (iface *fpgaManagerInterface) Name() string {
+	return "fpga-manager"
+}
+
+func (iface *fpgaManagerInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              fpgaManagerSummary,
+		BaseDeclarationSlots: fpgaManagerBaseDeclarationSlots,
+	}
+}
+
+func (iface *fpgaManagerInterface) String() string {
+	return iface.Name()
+}
+
+// Pattern to match the names of FPGA managers as listed in
+// /sys/class/fpga_manager.
+var fpgaManagerNamePattern = regexp.MustCompile("^fpga[0-9]+$")
+
+func (iface *fpgaManagerInterface) manager(attrs interfaces.Attrer) (string, error) {
+	var manager string
+	if err := attrs.Attr("manager", &manager); err != nil || manager == "" {
+		return "", fmt.Errorf("%s slot must have a manager attribute", iface.Name())
+	}
+	if !fpgaManagerNamePattern.MatchString(manager) {
+		return "", fmt.Errorf("%s manager attribute must be a valid FPGA manager name", iface.Name())
+	}
+	return manager, nil
+}
+
+// BeforePrepareSlot checks validity of the defined slot.
+func (iface *fpgaManagerInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, err := iface.manager(slot)
+	return err
+}
+
+func (iface *fpgaManagerInterface) readbackType(attrs interfaces.Attrer) (readbackType string, err error) {
+	if _, ok := attrs.Lookup("readback-type"); !ok {
+		return "", nil
+	}
+	if err := attrs.Attr("readback-type", &readbackType); err != nil {
+		return "", fmt.Errorf("%s readback-type attribute must be a string", iface.Name())
+	}
+	if _, ok := fpgaManagerReadbackTypes[readbackType]; !ok {
+		return "", fmt.Errorf(`%s readback-type attribute must be either "configuration-registers" or "configuration-data"`, iface.Name())
+	}
+	return readbackType, nil
+}
+
+// BeforePreparePlug checks validity of the defined plug.
+func (iface *fpgaManagerInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	_, err := iface.readbackType(plug)
+	return err
+}
+
+func (iface *fpgaManagerInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	manager, err := iface.manager(slot)
+	if err != nil {
+		return nil
+	}
+	spec.AddSnippet(strings.Replace(fpgaManagerConnectedPlugAppArmor, "###MANAGER###", manager, -1))
+	for _, rule := range fpgaManagerCommonConnectedPlugAppArmor {
+		spec.AddDeduplicatedSnippet(rule)
+	}
+	return nil
+}
+
+func (iface *fpgaManagerInterface) SystemdConnectedSlot(spec *systemd.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	// the search path is the same for all connections, the previous one
+	// is restored once there are none left
+	firmwarePath := &systemd.Service{
+		Type:            "oneshot",
+		RemainAfterExit: true,
+		ExecStart:       fmt.Sprintf("/bin/sh -c 'mkdir -p %[1]s && { test -e %[2]s || { mkdir -p /run/snapd && head -c -1 %[3]s > %[2]s; }; } && echo -n %[1]s > %[3]s'", fpgaManagerFirmwareDir, fpgaManagerFirmwarePathSaved, fpgaManagerFirmwarePathParam),
+		ExecStop:        fmt.Sprintf("/bin/sh -c 'test ! -e %[1]s || { cat %[1]s > %[2]s && rm %[1]s; }'", fpgaManagerFirmwarePathSaved, fpgaManagerFirmwarePathParam),
+	}
+	if err := spec.AddService("fpga-manager-firmware-path", firmwarePath); err != nil {
+		return err
+	}
+
+	readbackType, err := iface.readbackType(plug)
+	if err != nil || readbackType == "" {
+		return err
+	}
+	// the parameter is global, conflicting readback types of different
+	// connections make for conflicting services
+	service := &systemd.Service{
+		Type:            "oneshot",
+		RemainAfterExit: true,
+		ExecStart:       fmt.Sprintf("/bin/sh -c 'test -e %[1]s || { mkdir -p /run/snapd && cat %[2]s > %[1]s; }; echo %[3]d > %[2]s'", fpgaManagerReadbackTypeSaved, fpgaManagerReadbackTypeParam, fpgaManagerReadbackTypes[readbackType]),
+		ExecStop:        fmt.Sprintf("/bin/sh -c 'test ! -e %[1]s || { cat %[1]s > %[2]s && rm %[1]s; }'", fpgaManagerReadbackTypeSaved, fpgaManagerReadbackTypeParam),
+	}
+	return spec.AddService("zynqmp-fpga-readback-type", service)
+}
+
+func (iface *fpgaManagerInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
+	// allow what declarations allowed
+	return true
+}
+
+func init() {
+	registerIface(&fpgaManagerInterface{})
+}
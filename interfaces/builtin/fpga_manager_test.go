@@ -0,0 +1,279 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/systemd"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type FpgaManagerInterfaceSuite struct {
+	iface interfaces.Interface
+
+	slot0Info *snap.SlotInfo
+	slot0     *interfaces.ConnectedSlot
+	slot1Info *snap.SlotInfo
+	slot1     *interfaces.ConnectedSlot
+
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&FpgaManagerInterfaceSuite{
+	iface: builtin.MustInterface("fpga-manager"),
+})
+
+func (s *FpgaManagerInterfaceSuite) SetUpTest(c *C) {
+	gadgetInfo := snaptest.MockInfo(c, `
+name: some-device
+version: 0
+type: gadget
+slots:
+  pl-fpga:
+    interface: fpga-manager
+    manager: fpga0
+  other-fpga:
+    interface: fpga-manager
+    manager: fpga1
+`, nil)
+	s.slot0Info = gadgetInfo.Slots["pl-fpga"]
+	s.slot0 = interfaces.NewConnectedSlot(s.slot0Info, nil, nil)
+	s.slot1Info = gadgetInfo.Slots["other-fpga"]
+	s.slot1 = interfaces.NewConnectedSlot(s.slot1Info, nil, nil)
+
+	s.plug, s.plugInfo = MockConnectedPlug(c, `
+name: consumer
+version: 0
+apps:
+  app:
+    plugs: [fpga-manager]
+`, nil, "fpga-manager")
+}
+
+func (s *FpgaManagerInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "fpga-manager")
+}
+
+func (s *FpgaManagerInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slot0Info), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slot1Info), IsNil)
+
+	gadgetInfo := snaptest.MockInfo(c, `
+name: some-device
+version: 0
+type: gadget
+slots:
+  missing:
+    interface: fpga-manager
+  empty:
+    interface: fpga-manager
+    manager: ""
+  bad-name:
+    interface: fpga-manager
+    manager: region0
+  glob:
+    interface: fpga-manager
+    manager: fpga*
+  path:
+    interface: fpga-manager
+    manager: ../fpga0
+`, nil)
+	c.Check(interfaces.BeforePrepareSlot(s.iface, gadgetInfo.Slots["missing"]), ErrorMatches,
+		`fpga-manager slot must have a manager attribute`)
+	c.Check(interfaces.BeforePrepareSlot(s.iface, gadgetInfo.Slots["empty"]), ErrorMatches,
+		`fpga-manager slot must have a manager attribute`)
+	for _, name := range []string{"bad-name", "glob", "path"} {
+		c.Check(interfaces.BeforePrepareSlot(s.iface, gadgetInfo.Slots[name]), ErrorMatches,
+			`fpga-manager manager attribute must be a valid FPGA manager name`)
+	}
+}
+
+func (s *FpgaManagerInterfaceSuite) TestSanitizePlug(c *C) {
+	c.Assert(interfaces.BeforePreparePlug(s.iface, s.plugInfo), IsNil)
+
+	for _, readbackType := range []string{"configuration-registers", "configuration-data"} {
+		_, plugInfo := MockConnectedPlug(c, `
+name: consumer
+version: 0
+plugs:
+  fpga-manager:
+    readback-type: `+readbackType+`
+apps:
+  app:
+    plugs: [fpga-manager]
+`, nil, "fpga-manager")
+		c.Check(interfaces.BeforePreparePlug(s.iface, plugInfo), IsNil)
+	}
+}
+
+func (s *FpgaManagerInterfaceSuite) TestSanitizePlugBadReadbackType(c *C) {
+	for _, tc := range []struct {
+		readbackType string
+		err          string
+	}{
+		{"foo", `fpga-manager readback-type attribute must be either "configuration-registers" or "configuration-data"`},
+		{"1", `fpga-manager readback-type attribute must be a string`},
+	} {
+		_, plugInfo := MockConnectedPlug(c, `
+name: consumer
+version: 0
+plugs:
+  fpga-manager:
+    readback-type: `+tc.readbackType+`
+apps:
+  app:
+    plugs: [fpga-manager]
+`, nil, "fpga-manager")
+		c.Check(interfaces.BeforePreparePlug(s.iface, plugInfo), ErrorMatches, tc.err)
+	}
+}
+
+func (s *FpgaManagerInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot0), IsNil)
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot1), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	c.Check(snippet, testutil.Contains, "/sys/devices/**/fpga_manager/fpga0/firmware w,\n")
+	c.Check(snippet, testutil.Contains, "/sys/devices/**/fpga_manager/fpga0/{flags,key} rw,\n")
+	c.Check(snippet, testutil.Contains, "/sys/devices/**/fpga_manager/fpga1/firmware w,\n")
+	c.Check(snippet, Not(testutil.Contains), "fpga[0-9]")
+	// common rules are not duplicated
+	c.Check(snippet, testutil.Contains, "/sys/class/fpga_manager/ r,\n"+
+		"/sys/devices/**/fpga_region/region[0-9]*/compat_id r,\n"+
+		"/sys/devices/**/fpga_bridge/bridge[0-9]*/{name,state} r,\n"+
+		"/sys/module/zynqmp_fpga/parameters/readback_type r,\n"+
+		"/sys/module/firmware_class/parameters/path r,\n"+
+		"/var/lib/snapd/fpga-firmware/ r,")
+}
+
+func (s *FpgaManagerInterfaceSuite) TestAppArmorSpecFirmware(c *C) {
+	plug, _ := MockConnectedPlug(c, `
+name: consumer
+version: 0
+confinement: strict
+apps:
+  app:
+    plugs: [fpga-manager]
+`, nil, "fpga-manager")
+	c.Assert(plug.Snap().Confinement, Equals, snap.StrictConfinement)
+
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, plug, s.slot0), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.consumer.app"})
+	snippet := spec.SnippetForTag("snap.consumer.app")
+	// the confined snap can put bitstreams in the firmware directory of
+	// the connected manager only
+	c.Check(snippet, testutil.Contains, "/var/lib/snapd/fpga-firmware/fpga0/ rw,\n")
+	c.Check(snippet, testutil.Contains, "/var/lib/snapd/fpga-firmware/fpga0/** rw,\n")
+	c.Check(snippet, Not(testutil.Contains), "/var/lib/snapd/fpga-firmware/fpga1/")
+	c.Check(snippet, Not(testutil.Contains), "\n/lib/firmware/")
+
+	// the slot side needs no rules
+	spec = &apparmor.Specification{}
+	c.Assert(spec.AddConnectedSlot(s.iface, plug, s.slot0), IsNil)
+	c.Check(spec.SecurityTags(), HasLen, 0)
+}
+
+var fpgaManagerFirmwarePathService = &systemd.Service{
+	Type:            "oneshot",
+	RemainAfterExit: true,
+	ExecStart:       `/bin/sh -c 'mkdir -p /var/lib/snapd/fpga-firmware && { test -e /run/snapd/firmware-class-path || { mkdir -p /run/snapd && head -c -1 /sys/module/firmware_class/parameters/path > /run/snapd/firmware-class-path; }; } && echo -n /var/lib/snapd/fpga-firmware > /sys/module/firmware_class/parameters/path'`,
+	ExecStop:        `/bin/sh -c 'test ! -e /run/snapd/firmware-class-path || { cat /run/snapd/firmware-class-path > /sys/module/firmware_class/parameters/path && rm /run/snapd/firmware-class-path; }'`,
+}
+
+func (s *FpgaManagerInterfaceSuite) TestSystemdConnectedSlot(c *C) {
+	// only the firmware search path is set up without a readback type
+	spec := &systemd.Specification{}
+	c.Assert(spec.AddConnectedSlot(s.iface, s.plug, s.slot0), IsNil)
+	// the same for another manager
+	c.Assert(spec.AddConnectedSlot(s.iface, s.plug, s.slot1), IsNil)
+	c.Check(spec.Services(), DeepEquals, map[string]*systemd.Service{
+		"fpga-manager-firmware-path": fpgaManagerFirmwarePathService,
+	})
+
+	plug, _ := MockConnectedPlug(c, `
+name: consumer
+version: 0
+plugs:
+  fpga-manager:
+    readback-type: configuration-data
+apps:
+  app:
+    plugs: [fpga-manager]
+`, nil, "fpga-manager")
+	spec = &systemd.Specification{}
+	c.Assert(spec.AddConnectedSlot(s.iface, plug, s.slot0), IsNil)
+	// the same readback type for another manager
+	c.Assert(spec.AddConnectedSlot(s.iface, plug, s.slot1), IsNil)
+	c.Check(spec.Services(), DeepEquals, map[string]*systemd.Service{
+		"fpga-manager-firmware-path": fpgaManagerFirmwarePathService,
+		"zynqmp-fpga-readback-type": {
+			Type:            "oneshot",
+			RemainAfterExit: true,
+			ExecStart:       `/bin/sh -c 'test -e /run/snapd/zynqmp-fpga-readback-type || { mkdir -p /run/snapd && cat /sys/module/zynqmp_fpga/parameters/readback_type > /run/snapd/zynqmp-fpga-readback-type; }; echo 1 > /sys/module/zynqmp_fpga/parameters/readback_type'`,
+			ExecStop:        `/bin/sh -c 'test ! -e /run/snapd/zynqmp-fpga-readback-type || { cat /run/snapd/zynqmp-fpga-readback-type > /sys/module/zynqmp_fpga/parameters/readback_type && rm /run/snapd/zynqmp-fpga-readback-type; }'`,
+		},
+	})
+
+	// connections cannot ask for different readback types
+	otherPlug, _ := MockConnectedPlug(c, `
+name: other-consumer
+version: 0
+plugs:
+  fpga-manager:
+    readback-type: configuration-registers
+apps:
+  app:
+    plugs: [fpga-manager]
+`, nil, "fpga-manager")
+	c.Check(spec.AddConnectedSlot(s.iface, otherPlug, s.slot1), ErrorMatches, `internal error: interface "fpga-manager" has inconsistent system needs: .*`)
+}
+
+func (s *FpgaManagerInterfaceSuite) TestUDevSpec(c *C) {
+	// FPGA managers expose no device nodes
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot0), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 0)
+}
+
+func (s *FpgaManagerInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, false)
+	c.Assert(si.Summary, Equals, `allows loading bitstreams with a specific FPGA manager`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "fpga-manager")
+}
+
+func (s *FpgaManagerInterfaceSuite) TestAutoConnect(c *C) {
+	c.Assert(s.iface.AutoConnect(s.plugInfo, s.slot0Info), Equals, true)
+}
+
+func (s *FpgaManagerInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
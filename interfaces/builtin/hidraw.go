@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 )
@@ -193,6 +194,67 @@ func (iface *hidrawInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
 	return true
 }
 
+// hidrawDeviceClass is the class of the USB HID devices. A device has a
+// hidraw node for each of its HID interfaces, the interface number is part of
+// its identity.
+var hidrawDeviceClass = hotplug.DeviceClass{
+	Name:              "usb-hid",
+	Subsystem:         "hidraw",
+	Bus:               "usb",
+	DeviceNamePattern: hidrawDeviceNodePattern,
+	KeyAttrs: [][]string{
+		{"ID_VENDOR_ID"},
+		{"ID_MODEL_ID"},
+		// the same device plugged into another port keeps its
+		// identity only if it has a serial number
+		{"ID_SERIAL_SHORT", "ID_PATH"},
+		{"ID_USB_INTERFACE_NUM"},
+	},
+	NameAttrs:      []string{"ID_MODEL_FROM_DATABASE", "ID_MODEL"},
+	SlotNamePrefix: "hid",
+}
+
+func (iface *hidrawInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
+	if !hidrawDeviceClass.Matches(di) {
+		return nil, nil
+	}
+
+	slot := hotplug.ProposedSlot{
+		Name: hidrawDeviceClass.SlotName(di),
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	}
+	if vendor, ok := di.Attribute("ID_VENDOR_ID"); ok {
+		slot.Attrs["usb-vendor"] = vendor
+	}
+	if product, ok := di.Attribute("ID_MODEL_ID"); ok {
+		slot.Attrs["usb-product"] = product
+	}
+	return &slot, nil
+}
+
+func (iface *hidrawInterface) HotplugKey(di *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
+	return hidrawDeviceClass.Key(di)
+}
+
+func (iface *hidrawInterface) HandledByGadget(di *hotplug.HotplugDeviceInfo, slot *snap.SlotInfo) bool {
+	// if the slot has vendor and product set, check if they match
+	var usbVendor, usbProduct int64
+	if err := slot.Attr("usb-vendor", &usbVendor); err == nil {
+		if err := slot.Attr("usb-product", &usbProduct); err != nil {
+			return false
+		}
+		return slotDeviceAttrEqual(di, "ID_VENDOR_ID", usbVendor) && slotDeviceAttrEqual(di, "ID_MODEL_ID", usbProduct)
+	}
+
+	var path string
+	if err := slot.Attr("path", &path); err != nil {
+		return false
+	}
+	return di.DeviceName() == path
+}
+
 func (iface *hidrawInterface) hasUsbAttrs(attrs interfaces.Attrer) bool {
 	var v int64
 	if err := attrs.Attr("usb-vendor", &v); err == nil {
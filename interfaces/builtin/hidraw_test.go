@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -365,3 +366,69 @@ SUBSYSTEM=="hidraw", SUBSYSTEMS=="usb", ATTRS{idVendor}=="ffff", ATTRS{idProduct
 func (s *HidrawInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *HidrawInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/hidraw3", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "c52b", "ID_MODEL": "USB_Receiver", "ACTION": "add", "SUBSYSTEM": "hidraw", "ID_BUS": "usb"})
+	c.Assert(err, IsNil)
+	proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+	c.Assert(err, IsNil)
+	c.Assert(proposedSlot, DeepEquals, &hotplug.ProposedSlot{Name: "hid-usbreceiver", Attrs: map[string]interface{}{"path": "/dev/hidraw3", "usb-vendor": "046d", "usb-product": "c52b"}})
+
+	// a hotplugged slot grants access to the device node only
+	slot := MockHotplugSlot(c, "name: core\nversion: 0\ntype: os\n", nil, "1234", "hidraw", "hid-usbreceiver", proposedSlot.Attrs)
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.testPlugPort1, interfaces.NewConnectedSlot(slot, nil, nil)), IsNil)
+	c.Check(apparmorSpec.SnippetForTag("snap.client-snap.app-accessing-2-devices"), Equals, "/dev/hidraw3 rw,")
+}
+
+func (s *HidrawInterfaceSuite) TestHotplugDeviceDetectedNotHidraw(c *C) {
+	hotplugIface := s.iface.(hotplug.Definer)
+	for _, env := range []map[string]string{
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/hidraw3", "SUBSYSTEM": "hidraw", "ID_BUS": "bluetooth"},
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/input/event3", "SUBSYSTEM": "input", "ID_BUS": "usb"},
+		{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/other", "SUBSYSTEM": "hidraw", "ID_BUS": "usb"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		proposedSlot, err := hotplugIface.HotplugDeviceDetected(di)
+		c.Assert(err, IsNil)
+		c.Check(proposedSlot, IsNil)
+	}
+}
+
+func (s *HidrawInterfaceSuite) TestHotplugKey(c *C) {
+	keyHandler := s.iface.(hotplug.HotplugKeyHandler)
+	env := map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/hidraw3", "ID_VENDOR_ID": "046d", "ID_MODEL_ID": "c52b", "ID_PATH": "pci-0000:00:14.0-usb-0:1:1.0", "ID_USB_INTERFACE_NUM": "00", "SUBSYSTEM": "hidraw", "ID_BUS": "usb"}
+	di, err := hotplug.NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+	key, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key, Not(Equals), snap.HotplugKey(""))
+
+	// each HID interface of the device is a device of its own
+	env["ID_USB_INTERFACE_NUM"] = "01"
+	di, err = hotplug.NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+	other, err := keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(other, Not(Equals), key)
+
+	// without an interface number the default key is used
+	delete(env, "ID_USB_INTERFACE_NUM")
+	di, err = hotplug.NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+	key, err = keyHandler.HotplugKey(di)
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, snap.HotplugKey(""))
+}
+
+func (s *HidrawInterfaceSuite) TestHotplugHandledByGadget(c *C) {
+	byGadgetPred := s.iface.(hotplug.HandledByGadgetPredicate)
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/sys/foo/bar", "DEVNAME": "/dev/hidraw0", "ID_VENDOR_ID": "0001", "ID_MODEL_ID": "0001", "SUBSYSTEM": "hidraw", "ID_BUS": "usb"})
+	c.Assert(err, IsNil)
+	c.Check(byGadgetPred.HandledByGadget(di, s.testSlot1Info), Equals, true)
+	c.Check(byGadgetPred.HandledByGadget(di, s.testSlot2Info), Equals, false)
+	c.Check(byGadgetPred.HandledByGadget(di, s.testUDev1Info), Equals, true)
+	c.Check(byGadgetPred.HandledByGadget(di, s.testUDev2Info), Equals, false)
+}
@@ -121,12 +121,19 @@ func (iface *homeInterface) AppArmorConnectedPlug(spec *apparmor.Specification,
 	return nil
 }
 
+// Landlock cannot tell hidden files apart, the whole home directory is made
+// writable. AppArmor, when available, keeps enforcing the finer policy.
+var homeConnectedPlugLandlock = []string{
+	"rw $HOME",
+}
+
 func init() {
 	registerIface(&homeInterface{commonInterface{
-		name:                 "home",
-		summary:              homeSummary,
-		implicitOnCore:       true,
-		implicitOnClassic:    true,
-		baseDeclarationSlots: homeBaseDeclarationSlots,
+		name:                  "home",
+		summary:               homeSummary,
+		implicitOnCore:        true,
+		implicitOnClassic:     true,
+		baseDeclarationSlots:  homeBaseDeclarationSlots,
+		connectedPlugLandlock: homeConnectedPlugLandlock,
 	}})
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -149,6 +150,13 @@ func (s *HomeInterfaceSuite) TestConnectedPlugAppArmorWithoutAttrib(c *C) {
 	c.Check(apparmorSpec.SnippetForTag("snap.other.app"), Not(testutil.Contains), `# Allow non-owner read`)
 }
 
+func (s *HomeInterfaceSuite) TestConnectedPlugLandlock(c *C) {
+	spec := &landlock.Specification{}
+	err := spec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Check(spec.Rules("snap.other.app"), DeepEquals, []string{"rw $HOME"})
+}
+
 func (s *HomeInterfaceSuite) TestConnectedPlugAppArmorWithAttribAll(c *C) {
 	const mockSnapYaml = `name: home-plug-snap
 version: 1.0
@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
+const nvmeAdminSummary = `allows NVMe admin commands on specific NVMe controller`
+
+// nvme-admin grants admin passthrough access to a particular NVMe controller,
+// which includes commands like formatting or sanitizing the drive. As with
+// raw-volume, the controller is device-specific, so require a snap
+// declaration for connecting the interface at all.
+const nvmeAdminBaseDeclarationSlots = `
+  nvme-admin:
+    allow-installation:
+      slot-snap-type:
+        - core
+        - gadget
+    deny-connection: true
+    deny-auto-connection: true
+`
+
+const nvmeAdminConnectedPlugAppArmor = `
+# Description: can send NVMe admin passthrough commands (eg, to query the
+# SMART log or update the firmware) to the controller
+%[1]s rw,
+
+# NVME_IOCTL_ADMIN_CMD requires CAP_SYS_ADMIN
+capability sys_admin,
+
+# allow read access to sysfs and udev for the controller
+/run/udev/data/c[0-9]*:[0-9]* r,
+/sys/class/nvme/ r,
+/sys/devices/**/nvme/%[2]s/** r,
+`
+
+// The type for this interface
+type nvmeAdminInterface struct{}
+
+// Getter for the name of this interface
+func (iface *nvmeAdminInterface) Name() string {
+	return "nvme-admin"
+}
+
+func (iface *nvmeAdminInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              nvmeAdminSummary,
+		BaseDeclarationSlots: nvmeAdminBaseDeclarationSlots,
+	}
+}
+
+func (iface *nvmeAdminInterface) String() string {
+	return iface.Name()
+}
+
+// Only allow NVMe controller character devices nvme0-99, not their
+// namespaces or partitions.
+var nvmeAdminControllerPattern = regexp.MustCompile(`^/dev/nvme([0-9]|[1-9][0-9])$`)
+
+const invalidNVMeControllerSlotPathErrFmt = "slot %q path attribute must be a valid NVMe controller device node"
+
+// Check validity of the defined slot
+func (iface *nvmeAdminInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, err := verifySlotPathAttribute(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, nvmeAdminControllerPattern, invalidNVMeControllerSlotPathErrFmt)
+	return err
+}
+
+func (iface *nvmeAdminInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	cleanedPath, err := verifySlotPathAttribute(slot.Ref(), slot, nvmeAdminControllerPattern, invalidNVMeControllerSlotPathErrFmt)
+	if err != nil {
+		return nil
+	}
+
+	spec.AddSnippet(fmt.Sprintf(nvmeAdminConnectedPlugAppArmor, cleanedPath, strings.TrimPrefix(cleanedPath, "/dev/")))
+
+	return nil
+}
+
+func (iface *nvmeAdminInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	cleanedPath, err := verifySlotPathAttribute(slot.Ref(), slot, nvmeAdminControllerPattern, invalidNVMeControllerSlotPathErrFmt)
+	if err != nil {
+		return nil
+	}
+
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="nvme", KERNEL=="%s"`, strings.TrimPrefix(cleanedPath, "/dev/")))
+
+	return nil
+}
+
+func (iface *nvmeAdminInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
+	// Allow what is allowed in the declarations
+	return true
+}
+
+func init() {
+	registerIface(&nvmeAdminInterface{})
+}
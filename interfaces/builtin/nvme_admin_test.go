@@ -0,0 +1,167 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package builtin_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type nvmeAdminInterfaceSuite struct {
+	testutil.BaseTest
+	iface interfaces.Interface
+
+	slotInfo    *snap.SlotInfo
+	slot        *interfaces.ConnectedSlot
+	badSlotInfo *snap.SlotInfo
+
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&nvmeAdminInterfaceSuite{
+	iface: builtin.MustInterface("nvme-admin"),
+})
+
+const nvmeAdminMockSlotSnapInfoYaml = `name: some-device
+version: 0
+type: gadget
+slots:
+  nvme0:
+    interface: nvme-admin
+    path: /dev/nvme0
+  nvme0-namespace:
+    interface: nvme-admin
+    path: /dev/nvme0n1
+`
+
+const nvmeAdminMockPlugSnapInfoYaml = `name: client-snap
+version: 0
+plugs:
+  nvme0:
+    interface: nvme-admin
+apps:
+  smart:
+    command: foo
+    plugs: [nvme0]
+`
+
+func (s *nvmeAdminInterfaceSuite) SetUpTest(c *C) {
+	slotSnapInfo := snaptest.MockInfo(c, nvmeAdminMockSlotSnapInfoYaml, nil)
+	s.slotInfo = slotSnapInfo.Slots["nvme0"]
+	s.slot = interfaces.NewConnectedSlot(s.slotInfo, nil, nil)
+	s.badSlotInfo = slotSnapInfo.Slots["nvme0-namespace"]
+
+	plugSnapInfo := snaptest.MockInfo(c, nvmeAdminMockPlugSnapInfoYaml, nil)
+	s.plugInfo = plugSnapInfo.Plugs["nvme0"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+}
+
+func (s *nvmeAdminInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "nvme-admin")
+}
+
+func (s *nvmeAdminInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.badSlotInfo), ErrorMatches, `slot "some-device:nvme0-namespace" path attribute must be a valid NVMe controller device node`)
+}
+
+func (s *nvmeAdminInterfaceSuite) TestSanitizeSlotPaths(c *C) {
+	const mockSnapYaml = `name: nvme-admin-slot-snap
+type: gadget
+version: 1.0
+slots:
+  nvme-admin:
+    path: $t
+`
+
+	for _, t := range []struct {
+		path string
+		err  string
+	}{
+		{`/dev/nvme0`, ""},
+		{`/dev/nvme9`, ""},
+		{`/dev/nvme12`, ""},
+		{`/dev/nvme99`, ""},
+		{`/dev/nvme100`, `slot "nvme-admin-slot-snap:nvme-admin" path attribute must be a valid NVMe controller device node`},
+		{`/dev/nvme01`, `slot "nvme-admin-slot-snap:nvme-admin" path attribute must be a valid NVMe controller device node`},
+		{`/dev/nvme0n1`, `slot "nvme-admin-slot-snap:nvme-admin" path attribute must be a valid NVMe controller device node`},
+		{`/dev/nvme0n1p1`, `slot "nvme-admin-slot-snap:nvme-admin" path attribute must be a valid NVMe controller device node`},
+		{`/dev/sda`, `slot "nvme-admin-slot-snap:nvme-admin" path attribute must be a valid NVMe controller device node`},
+		{`/dev/nvme`, `slot "nvme-admin-slot-snap:nvme-admin" path attribute must be a valid NVMe controller device node`},
+		{`/dev/./nvme0`, `cannot use slot "nvme-admin-slot-snap:nvme-admin" path "/dev/./nvme0": try "/dev/nvme0".*`},
+		{`""`, `slot "nvme-admin-slot-snap:nvme-admin" must have a path attribute`},
+	} {
+		yml := strings.Replace(mockSnapYaml, "$t", t.path, -1)
+		info := snaptest.MockInfo(c, yml, nil)
+		slot := info.Slots["nvme-admin"]
+
+		err := interfaces.BeforePrepareSlot(s.iface, slot)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("unexpected error for %q", t.path))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("unexpected error for %q", t.path))
+		}
+	}
+}
+
+func (s *nvmeAdminInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Assert(spec.Snippets()[0], Equals, `# nvme-admin
+SUBSYSTEM=="nvme", KERNEL=="nvme0", TAG+="snap_client-snap_smart"`)
+	c.Assert(spec.Snippets(), testutil.Contains, fmt.Sprintf(`TAG=="snap_client-snap_smart", RUN+="%v/snap-device-helper $env{ACTION} snap_client-snap_smart $devpath $major:$minor"`, dirs.DistroLibExecDir))
+}
+
+func (s *nvmeAdminInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.client-snap.smart"})
+	c.Assert(spec.SnippetForTag("snap.client-snap.smart"), testutil.Contains, `/dev/nvme0 rw,`)
+	c.Assert(spec.SnippetForTag("snap.client-snap.smart"), testutil.Contains, `capability sys_admin,`)
+	c.Assert(spec.SnippetForTag("snap.client-snap.smart"), testutil.Contains, `/sys/devices/**/nvme/nvme0/** r,`)
+}
+
+func (s *nvmeAdminInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, false)
+	c.Assert(si.Summary, Equals, `allows NVMe admin commands on specific NVMe controller`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "nvme-admin")
+}
+
+func (s *nvmeAdminInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(nil, nil), Equals, true)
+}
+
+func (s *nvmeAdminInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -87,6 +88,18 @@ owner "@{HOME}/.write-file{,/,/**}" rwkl,
 `)
 }
 
+func (s *personalFilesInterfaceSuite) TestConnectedPlugLandlock(c *C) {
+	spec := &landlock.Specification{}
+	err := spec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Check(spec.Rules("snap.other.app"), DeepEquals, []string{
+		"ro $HOME/.read-dir",
+		"ro $HOME/.read-file",
+		"rw $HOME/.write-dir",
+		"rw $HOME/.write-file",
+	})
+}
+
 func (s *personalFilesInterfaceSuite) TestSanitizeSlot(c *C) {
 	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
 }
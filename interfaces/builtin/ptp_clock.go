@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+)
+
+const ptpClockSummary = `allows access to a specific PTP hardware clock`
+
+// ptp-clock grants access to a single PTP hardware clock, and optionally to
+// the hardware timestamping of the network interface it belongs to, as
+// needed by PTP/TSN daemons like ptp4l. Unlike ptp, which grants access to
+// all the clocks of the system, the slot describes the device so it is
+// provided by the gadget or the system.
+const ptpClockBaseDeclarationSlots = `
+  ptp-clock:
+    allow-installation:
+      slot-snap-type:
+        - core
+        - gadget
+    deny-auto-connection: true
+`
+
+const ptpClockConnectedPlugAppArmor = `
+# Description: Can access the PTP hardware clock %[1]s.
+%[1]s %[3]s,
+/run/udev/data/c[0-9]*:[0-9]* r,
+/sys/class/ptp/ r,
+/sys/devices/**/ptp/%[2]s/ r,
+/sys/devices/**/ptp/%[2]s/** r,
+`
+
+const ptpClockConnectedPlugAppArmorControl = `
+# Can enable the external timestamps and periodic outputs of the clock
+/sys/devices/**/ptp/%[1]s/{extts_enable,period,pps_enable} w,
+`
+
+const ptpClockConnectedPlugAppArmorNetwork = `
+# Can configure the hardware timestamping of the network interface %[1]s
+# with the SIOCSHWTSTAMP and SIOCETHTOOL ioctls, and send and receive PTP
+# messages over UDP or directly over Ethernet (AF_PACKET is allowed by the
+# seccomp template).
+/sys/class/net/ r,
+/sys/devices/**/net/%[1]s/ r,
+/sys/devices/**/net/%[1]s/** r,
+capability net_admin,
+capability net_raw,
+network inet dgram,
+network inet6 dgram,
+network packet raw,
+`
+
+// The PTP hardware clocks are dynamic POSIX clocks, which are adjusted
+// with the clock_* syscalls on a file descriptor of the device.
+const ptpClockConnectedPlugSecComp = `
+# Description: Can adjust the PTP hardware clock.
+clock_adjtime
+clock_adjtime64
+clock_settime
+clock_settime64
+`
+
+// The type for this interface
+type ptpClockInterface struct{}
+
+// Getter for the name of this interface
+func (iface *ptpClockInterface) Name() string {
+	return "ptp-clock"
+}
+
+func (iface *ptpClockInterface) StaticInfo() interfaces.StaticInfo {
+	return interfaces.StaticInfo{
+		Summary:              ptpClockSummary,
+		BaseDeclarationSlots: ptpClockBaseDeclarationSlots,
+	}
+}
+
+func (iface *ptpClockInterface) String() string {
+	return iface.Name()
+}
+
+var ptpClockDevicePattern = regexp.MustCompile(`^/dev/ptp[0-9]+$`)
+
+// Network interface names are at most IFNAMSIZ-1 characters long.
+var ptpClockNetworkInterfacePattern = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]{0,14}$`)
+
+const invalidPTPClockSlotPathErrFmt = "slot %q path attribute must be a valid PTP clock device node"
+
+// ptpClockAttrs holds the attributes of a ptp-clock slot.
+type ptpClockAttrs struct {
+	path     string
+	netIface string
+	readOnly bool
+}
+
+func (a *ptpClockAttrs) deviceName() string {
+	return strings.TrimPrefix(a.path, "/dev/")
+}
+
+func ptpClockSlotAttrs(slotRef *interfaces.SlotRef, attrs interfaces.Attrer) (*ptpClockAttrs, error) {
+	path, err := verifySlotPathAttribute(slotRef, attrs, ptpClockDevicePattern, invalidPTPClockSlotPathErrFmt)
+	if err != nil {
+		return nil, err
+	}
+	a := &ptpClockAttrs{path: path}
+	if v, ok := attrs.Lookup("network-interface"); ok {
+		netIface, ok := v.(string)
+		if !ok || !ptpClockNetworkInterfacePattern.MatchString(netIface) || netIface == "." || netIface == ".." {
+			return nil, fmt.Errorf("slot %q network-interface attribute must be a valid network interface name", slotRef)
+		}
+		a.netIface = netIface
+	}
+	if v, ok := attrs.Lookup("read-only"); ok {
+		readOnly, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("slot %q read-only attribute must be a boolean", slotRef)
+		}
+		a.readOnly = readOnly
+	}
+	return a, nil
+}
+
+// Check validity of the defined slot
+func (iface *ptpClockInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
+	_, err := ptpClockSlotAttrs(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot)
+	return err
+}
+
+func (iface *ptpClockInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	attrs, err := ptpClockSlotAttrs(slot.Ref(), slot)
+	if err != nil {
+		return nil
+	}
+
+	access := "rw"
+	if attrs.readOnly {
+		access = "r"
+	}
+	spec.AddSnippet(fmt.Sprintf(ptpClockConnectedPlugAppArmor, attrs.path, attrs.deviceName(), access))
+	if !attrs.readOnly {
+		spec.AddSnippet(fmt.Sprintf(ptpClockConnectedPlugAppArmorControl, attrs.deviceName()))
+		if attrs.netIface != "" {
+			spec.AddSnippet(fmt.Sprintf(ptpClockConnectedPlugAppArmorNetwork, attrs.netIface))
+		}
+	}
+
+	return nil
+}
+
+func (iface *ptpClockInterface) SecCompConnectedPlug(spec *seccomp.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	attrs, err := ptpClockSlotAttrs(slot.Ref(), slot)
+	if err != nil || attrs.readOnly {
+		return nil
+	}
+
+	spec.AddSnippet(ptpClockConnectedPlugSecComp)
+
+	return nil
+}
+
+func (iface *ptpClockInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	attrs, err := ptpClockSlotAttrs(slot.Ref(), slot)
+	if err != nil {
+		return nil
+	}
+
+	spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="ptp", KERNEL=="%s"`, attrs.deviceName()))
+
+	return nil
+}
+
+func (iface *ptpClockInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bool {
+	// Allow what is allowed in the declarations
+	return true
+}
+
+func init() {
+	registerIface(&ptpClockInterface{})
+}
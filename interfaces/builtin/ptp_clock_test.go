@@ -0,0 +1,220 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package builtin_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type ptpClockInterfaceSuite struct {
+	testutil.BaseTest
+	iface interfaces.Interface
+
+	slotInfo         *snap.SlotInfo
+	slot             *interfaces.ConnectedSlot
+	netSlotInfo      *snap.SlotInfo
+	netSlot          *interfaces.ConnectedSlot
+	readOnlySlotInfo *snap.SlotInfo
+	readOnlySlot     *interfaces.ConnectedSlot
+
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+}
+
+var _ = Suite(&ptpClockInterfaceSuite{
+	iface: builtin.MustInterface("ptp-clock"),
+})
+
+const ptpClockMockSlotSnapInfoYaml = `name: some-device
+version: 0
+type: gadget
+slots:
+  ptp0:
+    interface: ptp-clock
+    path: /dev/ptp0
+  ptp1:
+    interface: ptp-clock
+    path: /dev/ptp1
+    network-interface: eth1
+  ptp2:
+    interface: ptp-clock
+    path: /dev/ptp2
+    network-interface: eth2
+    read-only: true
+`
+
+const ptpClockMockPlugSnapInfoYaml = `name: client-snap
+version: 0
+plugs:
+  ptp:
+    interface: ptp-clock
+apps:
+  ptp4l:
+    command: foo
+    plugs: [ptp]
+`
+
+func (s *ptpClockInterfaceSuite) SetUpTest(c *C) {
+	slotSnapInfo := snaptest.MockInfo(c, ptpClockMockSlotSnapInfoYaml, nil)
+	s.slotInfo = slotSnapInfo.Slots["ptp0"]
+	s.slot = interfaces.NewConnectedSlot(s.slotInfo, nil, nil)
+	s.netSlotInfo = slotSnapInfo.Slots["ptp1"]
+	s.netSlot = interfaces.NewConnectedSlot(s.netSlotInfo, nil, nil)
+	s.readOnlySlotInfo = slotSnapInfo.Slots["ptp2"]
+	s.readOnlySlot = interfaces.NewConnectedSlot(s.readOnlySlotInfo, nil, nil)
+
+	plugSnapInfo := snaptest.MockInfo(c, ptpClockMockPlugSnapInfoYaml, nil)
+	s.plugInfo = plugSnapInfo.Plugs["ptp"]
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+}
+
+func (s *ptpClockInterfaceSuite) TestName(c *C) {
+	c.Assert(s.iface.Name(), Equals, "ptp-clock")
+}
+
+func (s *ptpClockInterfaceSuite) TestSanitizeSlot(c *C) {
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.netSlotInfo), IsNil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.readOnlySlotInfo), IsNil)
+}
+
+func (s *ptpClockInterfaceSuite) TestSanitizeSlotAttrs(c *C) {
+	const mockSnapYaml = `name: ptp-clock-slot-snap
+type: gadget
+version: 1.0
+slots:
+  ptp-clock:
+$t
+`
+
+	for _, t := range []struct {
+		attrs string
+		err   string
+	}{
+		{"    path: /dev/ptp0", ""},
+		{"    path: /dev/ptp12", ""},
+		{"    path: /dev/ptp0\n    network-interface: enp0s31f6", ""},
+		{"    path: /dev/ptp0\n    network-interface: eth0.100", ""},
+		{"    path: /dev/ptp0\n    read-only: false", ""},
+		{"    path: /dev/ptp", `slot "ptp-clock-slot-snap:ptp-clock" path attribute must be a valid PTP clock device node`},
+		{"    path: /dev/pps0", `slot "ptp-clock-slot-snap:ptp-clock" path attribute must be a valid PTP clock device node`},
+		{"    path: /dev/ptp0a", `slot "ptp-clock-slot-snap:ptp-clock" path attribute must be a valid PTP clock device node`},
+		{"    path: /dev/./ptp0", `cannot use slot "ptp-clock-slot-snap:ptp-clock" path "/dev/./ptp0": try "/dev/ptp0".*`},
+		{`    path: ""`, `slot "ptp-clock-slot-snap:ptp-clock" must have a path attribute`},
+		{"    network-interface: eth0", `slot "ptp-clock-slot-snap:ptp-clock" must have a path attribute`},
+		{"    path: /dev/ptp0\n    network-interface: eth0/foo", `slot "ptp-clock-slot-snap:ptp-clock" network-interface attribute must be a valid network interface name`},
+		{"    path: /dev/ptp0\n    network-interface: a-very-long-interface", `slot "ptp-clock-slot-snap:ptp-clock" network-interface attribute must be a valid network interface name`},
+		{"    path: /dev/ptp0\n    network-interface: ..", `slot "ptp-clock-slot-snap:ptp-clock" network-interface attribute must be a valid network interface name`},
+		{"    path: /dev/ptp0\n    network-interface: [eth0]", `slot "ptp-clock-slot-snap:ptp-clock" network-interface attribute must be a valid network interface name`},
+		{"    path: /dev/ptp0\n    read-only: yes please", `slot "ptp-clock-slot-snap:ptp-clock" read-only attribute must be a boolean`},
+	} {
+		yml := strings.Replace(mockSnapYaml, "$t", t.attrs, -1)
+		info := snaptest.MockInfo(c, yml, nil)
+		slot := info.Slots["ptp-clock"]
+
+		err := interfaces.BeforePrepareSlot(s.iface, slot)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("unexpected error for %q", t.attrs))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("unexpected error for %q", t.attrs))
+		}
+	}
+}
+
+func (s *ptpClockInterfaceSuite) TestUDevSpec(c *C) {
+	spec := &udev.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.Snippets(), HasLen, 2)
+	c.Assert(spec.Snippets()[0], Equals, `# ptp-clock
+SUBSYSTEM=="ptp", KERNEL=="ptp0", TAG+="snap_client-snap_ptp4l"`)
+	c.Assert(spec.Snippets(), testutil.Contains, fmt.Sprintf(`TAG=="snap_client-snap_ptp4l", RUN+="%v/snap-device-helper $env{ACTION} snap_client-snap_ptp4l $devpath $major:$minor"`, dirs.DistroLibExecDir))
+}
+
+func (s *ptpClockInterfaceSuite) TestAppArmorSpec(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.client-snap.ptp4l"})
+	snippet := spec.SnippetForTag("snap.client-snap.ptp4l")
+	c.Check(snippet, testutil.Contains, `/dev/ptp0 rw,`)
+	c.Check(snippet, testutil.Contains, `/sys/devices/**/ptp/ptp0/** r,`)
+	c.Check(snippet, testutil.Contains, `/sys/devices/**/ptp/ptp0/{extts_enable,period,pps_enable} w,`)
+	c.Check(snippet, Not(testutil.Contains), `capability net_admin,`)
+}
+
+func (s *ptpClockInterfaceSuite) TestAppArmorSpecNetworkInterface(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.netSlot), IsNil)
+	snippet := spec.SnippetForTag("snap.client-snap.ptp4l")
+	c.Check(snippet, testutil.Contains, `/dev/ptp1 rw,`)
+	c.Check(snippet, testutil.Contains, `/sys/devices/**/net/eth1/** r,`)
+	c.Check(snippet, testutil.Contains, `capability net_admin,`)
+	c.Check(snippet, testutil.Contains, `network packet raw,`)
+}
+
+func (s *ptpClockInterfaceSuite) TestAppArmorSpecReadOnly(c *C) {
+	spec := &apparmor.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.readOnlySlot), IsNil)
+	snippet := spec.SnippetForTag("snap.client-snap.ptp4l")
+	c.Check(snippet, testutil.Contains, `/dev/ptp2 r,`)
+	c.Check(snippet, testutil.Contains, `/sys/devices/**/ptp/ptp2/** r,`)
+	c.Check(snippet, Not(testutil.Contains), ` w,`)
+	c.Check(snippet, Not(testutil.Contains), `capability net_admin,`)
+}
+
+func (s *ptpClockInterfaceSuite) TestSecCompSpec(c *C) {
+	spec := &seccomp.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(spec.SecurityTags(), DeepEquals, []string{"snap.client-snap.ptp4l"})
+	c.Check(spec.SnippetForTag("snap.client-snap.ptp4l"), testutil.Contains, "clock_adjtime\n")
+	c.Check(spec.SnippetForTag("snap.client-snap.ptp4l"), testutil.Contains, "clock_settime\n")
+
+	spec = &seccomp.Specification{}
+	c.Assert(spec.AddConnectedPlug(s.iface, s.plug, s.readOnlySlot), IsNil)
+	c.Check(spec.SecurityTags(), HasLen, 0)
+}
+
+func (s *ptpClockInterfaceSuite) TestStaticInfo(c *C) {
+	si := interfaces.StaticInfoOf(s.iface)
+	c.Assert(si.ImplicitOnCore, Equals, false)
+	c.Assert(si.ImplicitOnClassic, Equals, false)
+	c.Assert(si.Summary, Equals, `allows access to a specific PTP hardware clock`)
+	c.Assert(si.BaseDeclarationSlots, testutil.Contains, "ptp-clock")
+}
+
+func (s *ptpClockInterfaceSuite) TestAutoConnect(c *C) {
+	c.Check(s.iface.AutoConnect(nil, nil), Equals, true)
+}
+
+func (s *ptpClockInterfaceSuite) TestInterfaces(c *C) {
+	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
+}
@@ -49,6 +49,12 @@ const removableMediaConnectedPlugAppArmor = `
 /mnt/** mrwklix,
 `
 
+var removableMediaConnectedPlugLandlock = []string{
+	"rw /media",
+	"rw /run/media",
+	"rw /mnt",
+}
+
 func init() {
 	registerIface(&commonInterface{
 		name:                  "removable-media",
@@ -57,5 +63,6 @@ func init() {
 		implicitOnClassic:     true,
 		baseDeclarationSlots:  removableMediaBaseDeclarationSlots,
 		connectedPlugAppArmor: removableMediaConnectedPlugAppArmor,
+		connectedPlugLandlock: removableMediaConnectedPlugLandlock,
 	})
 }
@@ -44,6 +44,15 @@ const serialPortBaseDeclarationSlots = `
     deny-auto-connection: true
 `
 
+// The RS-485 (TIOC[GS]RS485) and custom divisor (TIOC[GS]SERIAL) ioctls are
+// allowed by the default seccomp template. The optional custom-baud slot
+// attribute allows reading the clock of the UART, which is needed to compute
+// the custom divisor for non-standard baud rates.
+const serialPortConnectedPlugAppArmorCustomBaud = `
+# the divisor is computed from the clock of the UART
+/sys/devices/**/tty/%s/{uartclk,custom_divisor} r,
+`
+
 // serialPortInterface is the type for serial port interfaces.
 type serialPortInterface struct{}
 
@@ -97,6 +106,10 @@ func (iface *serialPortInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
 	// performs additional verification.
 	path = filepath.Clean(path)
 
+	if _, err := serialPortCustomBaud(slot); err != nil {
+		return err
+	}
+
 	if iface.hasUsbAttrs(slot) {
 		// Must be path attribute where symlink will be placed and usb vendor and product identifiers
 		// Check the path attribute is in the allowable pattern
@@ -158,12 +171,34 @@ SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}==
 	return nil
 }
 
+// serialPortCustomBaud returns whether the port is used with non-standard
+// baud rates.
+func serialPortCustomBaud(attrs interfaces.Attrer) (bool, error) {
+	v, ok := attrs.Lookup("custom-baud")
+	if !ok {
+		return false, nil
+	}
+	customBaud, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("serial-port custom-baud attribute must be a boolean")
+	}
+	return customBaud, nil
+}
+
 func (iface *serialPortInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	customBaud, err := serialPortCustomBaud(slot)
+	if err != nil {
+		return err
+	}
+
 	if iface.hasUsbAttrs(slot) {
 		// This apparmor rule is an approximation of serialDeviceNodePattern
 		// (AARE is different than regex, so we must approximate).
 		// UDev tagging and device cgroups will restrict down to the specific device
 		spec.AddSnippet("/dev/tty[A-Z]*[0-9] rwk,")
+		if customBaud {
+			spec.AddSnippet(fmt.Sprintf(serialPortConnectedPlugAppArmorCustomBaud, "tty[A-Z]*[0-9]"))
+		}
 		return nil
 	}
 
@@ -174,6 +209,9 @@ func (iface *serialPortInterface) AppArmorConnectedPlug(spec *apparmor.Specifica
 	}
 	cleanedPath := filepath.Clean(path)
 	spec.AddSnippet(fmt.Sprintf("%s rwk,", cleanedPath))
+	if customBaud {
+		spec.AddSnippet(fmt.Sprintf(serialPortConnectedPlugAppArmorCustomBaud, strings.TrimPrefix(cleanedPath, "/dev/")))
+	}
 	return nil
 }
 
@@ -213,9 +251,17 @@ func (iface *serialPortInterface) AutoConnect(*snap.PlugInfo, *snap.SlotInfo) bo
 	return true
 }
 
+// serialPortDeviceClass is the class of the USB serial ports. The default
+// hotplug key is used for them.
+var serialPortDeviceClass = hotplug.DeviceClass{
+	Name:              "usb-serial-port",
+	Subsystem:         "tty",
+	Bus:               "usb",
+	DeviceNamePattern: serialDeviceNodePattern,
+}
+
 func (iface *serialPortInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo) (*hotplug.ProposedSlot, error) {
-	bus, _ := di.Attribute("ID_BUS")
-	if di.Subsystem() != "tty" || bus != "usb" || !serialDeviceNodePattern.MatchString(di.DeviceName()) {
+	if !serialPortDeviceClass.Matches(di) {
 		return nil, nil
 	}
 
@@ -589,6 +589,62 @@ func (s *SerialPortInterfaceSuite) TestConnectedPlugAppArmorSnippets(c *C) {
 	checkConnectedPlugSnippet(s.testPlugPort2, s.testUDev3, expectedSnippet102)
 }
 
+const serialPortCustomBaudGadgetYaml = `name: gadget
+version: 0
+type: gadget
+slots:
+  custom-baud-port:
+    interface: serial-port
+    path: /dev/ttyUSB0
+    custom-baud: true
+  usb-port:
+    interface: serial-port
+    usb-vendor: 0x0403
+    usb-product: 0x6001
+    path: /dev/serial-port-modbus
+    custom-baud: true
+  bad-custom-baud-port:
+    interface: serial-port
+    path: /dev/ttyS1
+    custom-baud: 1
+`
+
+func (s *SerialPortInterfaceSuite) TestSanitizeSlotsWithCustomBaud(c *C) {
+	for _, slotName := range []string{"custom-baud-port", "usb-port"} {
+		slot := builtin.MockSlot(c, serialPortCustomBaudGadgetYaml, nil, slotName)
+		c.Check(interfaces.BeforePrepareSlot(s.iface, slot), IsNil, Commentf(slotName))
+	}
+
+	slot := builtin.MockSlot(c, serialPortCustomBaudGadgetYaml, nil, "bad-custom-baud-port")
+	c.Check(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches, "serial-port custom-baud attribute must be a boolean")
+}
+
+func (s *SerialPortInterfaceSuite) TestConnectedPlugAppArmorSnippetsWithCustomBaud(c *C) {
+	tag := "snap.client-snap.app-accessing-2-ports"
+
+	// no extra rules without the attribute
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.testPlugPort1, s.testSlot1), IsNil)
+	c.Check(apparmorSpec.SnippetForTag(tag), Not(testutil.Contains), "uartclk")
+
+	slot, _ := builtin.MockConnectedSlot(c, serialPortCustomBaudGadgetYaml, nil, "custom-baud-port")
+	apparmorSpec = &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.testPlugPort1, slot), IsNil)
+	c.Check(apparmorSpec.SnippetForTag(tag), testutil.Contains, "/dev/ttyUSB0 rwk,")
+	c.Check(apparmorSpec.SnippetForTag(tag), testutil.Contains, "/sys/devices/**/tty/ttyUSB0/{uartclk,custom_divisor} r,")
+
+	slot, _ = builtin.MockConnectedSlot(c, serialPortCustomBaudGadgetYaml, nil, "usb-port")
+	apparmorSpec = &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.testPlugPort1, slot), IsNil)
+	c.Check(apparmorSpec.SnippetForTag(tag), testutil.Contains, "/dev/tty[A-Z]*[0-9] rwk,")
+	c.Check(apparmorSpec.SnippetForTag(tag), testutil.Contains, "/sys/devices/**/tty/tty[A-Z]*[0-9]/{uartclk,custom_divisor} r,")
+
+	// invalid attributes are reported
+	slot, _ = builtin.MockConnectedSlot(c, serialPortCustomBaudGadgetYaml, nil, "bad-custom-baud-port")
+	apparmorSpec = &apparmor.Specification{}
+	c.Check(apparmorSpec.AddConnectedPlug(s.iface, s.testPlugPort1, slot), ErrorMatches, "serial-port custom-baud attribute must be a boolean")
+}
+
 func (s *SerialPortInterfaceSuite) TestConnectedPlugUDevSnippetsForPath(c *C) {
 	checkConnectedPlugSnippet := func(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot, expectedSnippet string, expectedExtraSnippet string) {
 		udevSpec := &udev.Specification{}
@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -86,6 +87,18 @@ func (s *systemFilesInterfaceSuite) TestConnectedPlugAppArmor(c *C) {
 `)
 }
 
+func (s *systemFilesInterfaceSuite) TestConnectedPlugLandlock(c *C) {
+	spec := &landlock.Specification{}
+	err := spec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Check(spec.Rules("snap.other.app"), DeepEquals, []string{
+		"ro /etc/read-dir2",
+		"ro /etc/read-file2",
+		"rw /etc/write-dir2",
+		"rw /etc/write-file2",
+	})
+}
+
 func (s *systemFilesInterfaceSuite) TestSanitizeSlot(c *C) {
 	c.Assert(interfaces.BeforePrepareSlot(s.iface, s.slotInfo), IsNil)
 }
@@ -19,6 +19,19 @@
 
 package builtin
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/seccomp"
+	"github.com/snapcore/snapd/snap"
+)
+
 const systemTraceSummary = `allows using kernel tracing facilities`
 
 const systemTraceBaseDeclarationSlots = `
@@ -61,14 +74,300 @@ bpf
 perf_event_open
 `
 
+// systemTraceScopedConnectedPlugAppArmor is the header of the rules used when
+// the plug restricts the tracing facilities with attributes, the actual rules
+// are generated from the attributes.
+const systemTraceScopedConnectedPlugAppArmor = `
+# Description: Can use a subset of the kernel tracing facilities, as declared
+# by the plug attributes. This is restricted because it may give privileged
+# access to processes on the system and should only be used with trusted apps.
+`
+
+const systemTraceScopedConnectedPlugSecComp = `
+# Description: Can use a subset of the kernel tracing facilities, as declared
+# by the plug attributes. This is restricted because it may give privileged
+# access to processes on the system and should only be used with trusted apps.
+`
+
+// tracefs is mounted at /sys/kernel/tracing on newer kernels and is also
+// reachable through debugfs at /sys/kernel/debug/tracing.
+const systemTraceTracefs = "/sys/kernel/{debug/,}tracing/"
+
+// systemTraceBPFAppArmor relies on CAP_BPF rather than CAP_SYS_ADMIN, so
+// that the scoped plug does not grant the unrelated privileges of the latter.
+const systemTraceBPFAppArmor = `
+  # For the bpf() syscall and manipulating bpf map types
+  capability bpf,
+  capability sys_resource,
+
+  # Access to kernel headers required for iovisor/bcc
+  /usr/src/ r,
+  /usr/src/** r,
+`
+
+// systemTraceBPFSecComp are the bpf() commands needed to load a program of
+// any type and use maps with it. Note that the program type is passed inside
+// the bpf_attr union and cannot be filtered by seccomp, the type specific
+// rules below only grant the means to attach programs of the given type.
+var systemTraceBPFSecComp = []string{
+	"# BPF_MAP_CREATE",
+	"bpf 0",
+	"# BPF_MAP_LOOKUP_ELEM",
+	"bpf 1",
+	"# BPF_MAP_UPDATE_ELEM",
+	"bpf 2",
+	"# BPF_MAP_DELETE_ELEM",
+	"bpf 3",
+	"# BPF_MAP_GET_NEXT_KEY",
+	"bpf 4",
+	"# BPF_PROG_LOAD",
+	"bpf 5",
+	"# BPF_OBJ_GET_INFO_BY_FD",
+	"bpf 15",
+	"# BPF_BTF_LOAD",
+	"bpf 18",
+	"# BPF_LINK_CREATE",
+	"bpf 28",
+	"# BPF_LINK_DETACH",
+	"bpf 34",
+}
+
+type systemTraceBPFProgram struct {
+	// tracefs lists the paths under tracefs, with their permissions,
+	// needed to attach programs of this type
+	tracefs []string
+	// apparmor holds additional AppArmor rules
+	apparmor string
+	// seccomp holds additional seccomp rules
+	seccomp []string
+	// perfEvents is set for program types attached with perf_event_open()
+	perfEvents bool
+}
+
+var systemTraceBPFPrograms = map[string]systemTraceBPFProgram{
+	"kprobe": {
+		tracefs:    []string{"kprobe_events rw", "events/ r", "events/kprobes/** r"},
+		apparmor:   "  /sys/bus/event_source/devices/kprobe/** r,\n  /sys/kernel/debug/kprobes/blacklist r,\n",
+		perfEvents: true,
+	},
+	"uprobe": {
+		tracefs:    []string{"uprobe_events rw", "events/ r", "events/uprobes/** r"},
+		apparmor:   "  /sys/bus/event_source/devices/uprobe/** r,\n",
+		perfEvents: true,
+	},
+	"tracepoint": {
+		tracefs:    []string{"available_events r", "events/ r", "events/** r"},
+		perfEvents: true,
+	},
+	"raw-tracepoint": {
+		seccomp: []string{"# BPF_RAW_TRACEPOINT_OPEN", "bpf 17"},
+	},
+	"perf-event": {
+		apparmor:   "  /sys/bus/event_source/devices/** r,\n",
+		perfEvents: true,
+	},
+}
+
+// systemTraceInterface allows using kernel tracing facilities, optionally
+// scoped with the plug attributes:
+//
+//   - tracing-paths: paths under tracefs which may be read and written
+//   - perf-events: either "self", allowing perf_event_open() only for the
+//     calling process, or "system", allowing it for any process
+//   - bpf-programs: types of BPF programs which may be loaded and attached
+//
+// Without any of those attributes the plug gives access to all of the
+// tracing facilities.
+type systemTraceInterface struct {
+	commonInterface
+}
+
+type systemTraceScope struct {
+	tracingPaths []string
+	perfEvents   string
+	bpfPrograms  []string
+}
+
+// tracefs paths are relative to the root of tracefs and may end in a glob
+var systemTracePathPattern = regexp.MustCompile(`^[a-zA-Z0-9_.+:-]+(/[a-zA-Z0-9_.+:-]+)*(/\*\*?)?$`)
+
+func systemTraceStringList(attrer interfaces.Attrer, key string) ([]string, error) {
+	var stringList []string
+	err := attrer.Attr(key, &stringList)
+	if err != nil && !errors.Is(err, snap.AttributeNotFoundError{}) {
+		value, _ := attrer.Lookup(key)
+		return nil, fmt.Errorf(`system-trace %q attribute must be a list of strings, not "%v"`, key, value)
+	}
+	return stringList, nil
+}
+
+// systemTraceScopeOf returns the scope declared by the plug attributes, or
+// nil if the plug is not scoped.
+func systemTraceScopeOf(attrer interfaces.Attrer) (*systemTraceScope, error) {
+	var scope systemTraceScope
+	scoped := false
+
+	paths, err := systemTraceStringList(attrer, "tracing-paths")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := attrer.Lookup("tracing-paths"); ok {
+		scoped = true
+	}
+	for _, path := range paths {
+		if !systemTracePathPattern.MatchString(path) || !cleanSubPath(path) {
+			return nil, fmt.Errorf("system-trace tracing path is invalid: %q", path)
+		}
+	}
+	scope.tracingPaths = paths
+
+	if value, ok := attrer.Lookup("perf-events"); ok {
+		scoped = true
+		perfEvents, ok := value.(string)
+		if !ok || (perfEvents != "self" && perfEvents != "system") {
+			return nil, fmt.Errorf(`system-trace "perf-events" attribute must be either "self" or "system"`)
+		}
+		scope.perfEvents = perfEvents
+	}
+
+	programs, err := systemTraceStringList(attrer, "bpf-programs")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := attrer.Lookup("bpf-programs"); ok {
+		scoped = true
+	}
+	for _, program := range programs {
+		if _, ok := systemTraceBPFPrograms[program]; !ok {
+			return nil, fmt.Errorf("system-trace BPF program type %q is not supported", program)
+		}
+	}
+	scope.bpfPrograms = programs
+
+	if !scoped {
+		return nil, nil
+	}
+	return &scope, nil
+}
+
+func (iface *systemTraceInterface) BeforePreparePlug(plug *snap.PlugInfo) error {
+	_, err := systemTraceScopeOf(plug)
+	return err
+}
+
+func (iface *systemTraceInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	scope, err := systemTraceScopeOf(plug)
+	if err != nil {
+		return err
+	}
+	if scope == nil {
+		spec.AddSnippet(systemTraceConnectedPlugAppArmor)
+		return nil
+	}
+
+	tracefs := make(map[string]bool)
+	for _, path := range scope.tracingPaths {
+		tracefs[path+" rw"] = true
+	}
+
+	perfEvents := scope.perfEvents
+	for _, program := range scope.bpfPrograms {
+		if systemTraceBPFPrograms[program].perfEvents {
+			// programs are attached to events which are not
+			// specific to a process
+			perfEvents = "system"
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(systemTraceScopedConnectedPlugAppArmor)
+	if perfEvents == "system" {
+		buf.WriteString("\n  # For system wide perf events\n")
+		buf.WriteString("  capability perfmon,\n")
+		buf.WriteString("  /sys/bus/event_source/devices/** r,\n")
+	}
+	if len(scope.bpfPrograms) > 0 {
+		buf.WriteString(systemTraceBPFAppArmor)
+		for _, program := range scope.bpfPrograms {
+			p := systemTraceBPFPrograms[program]
+			for _, rule := range p.tracefs {
+				tracefs[rule] = true
+			}
+			if p.apparmor != "" {
+				fmt.Fprintf(&buf, "\n  # For attaching %s programs\n", program)
+				buf.WriteString(p.apparmor)
+			}
+		}
+	}
+	if len(tracefs) > 0 {
+		rules := make([]string, 0, len(tracefs))
+		for rule := range tracefs {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		buf.WriteString("\n  # Allowed tracefs paths\n")
+		fmt.Fprintf(&buf, "  %s r,\n", systemTraceTracefs)
+		for _, rule := range rules {
+			fmt.Fprintf(&buf, "  %s%s,\n", systemTraceTracefs, rule)
+		}
+	}
+	spec.AddSnippet(buf.String())
+	return nil
+}
+
+func (iface *systemTraceInterface) SecCompConnectedPlug(spec *seccomp.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	scope, err := systemTraceScopeOf(plug)
+	if err != nil {
+		return err
+	}
+	if scope == nil {
+		spec.AddSnippet(systemTraceConnectedPlugSecComp)
+		return nil
+	}
+
+	perfEvents := scope.perfEvents
+	var rules []string
+	if len(scope.bpfPrograms) > 0 {
+		rules = append(rules, systemTraceBPFSecComp...)
+		for _, program := range scope.bpfPrograms {
+			p := systemTraceBPFPrograms[program]
+			rules = append(rules, p.seccomp...)
+			if p.perfEvents {
+				// programs are attached to events which are
+				// not specific to a process
+				perfEvents = "system"
+			}
+		}
+	}
+	switch perfEvents {
+	case "self":
+		// pid 0 is the calling process
+		rules = append(rules, "perf_event_open - 0")
+	case "system":
+		rules = append(rules, "perf_event_open")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(systemTraceScopedConnectedPlugSecComp)
+	buf.WriteString("\n")
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if seen[rule] {
+			continue
+		}
+		seen[rule] = true
+		fmt.Fprintf(&buf, "%s\n", rule)
+	}
+	spec.AddSnippet(buf.String())
+	return nil
+}
+
 func init() {
-	registerIface(&commonInterface{
-		name:                  "system-trace",
-		summary:               systemTraceSummary,
-		implicitOnCore:        true,
-		implicitOnClassic:     true,
-		baseDeclarationSlots:  systemTraceBaseDeclarationSlots,
-		connectedPlugAppArmor: systemTraceConnectedPlugAppArmor,
-		connectedPlugSecComp:  systemTraceConnectedPlugSecComp,
-	})
+	registerIface(&systemTraceInterface{commonInterface{
+		name:                 "system-trace",
+		summary:              systemTraceSummary,
+		implicitOnCore:       true,
+		implicitOnClassic:    true,
+		baseDeclarationSlots: systemTraceBaseDeclarationSlots,
+	}})
 }
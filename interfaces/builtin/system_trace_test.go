@@ -20,11 +20,14 @@
 package builtin_test
 
 import (
+	"fmt"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/seccomp"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
@@ -82,6 +85,168 @@ func (s *SystemTraceInterfaceSuite) TestUsedSecuritySystems(c *C) {
 	c.Check(apparmorSpec.SnippetForTag("snap.other.app"), testutil.Contains, "/sys/kernel/debug/tracing/ r,")
 }
 
+func (s *SystemTraceInterfaceSuite) TestUnscopedSecComp(c *C) {
+	seccompSpec := &seccomp.Specification{}
+	err := seccompSpec.AddConnectedPlug(s.iface, s.plug, s.slot)
+	c.Assert(err, IsNil)
+	c.Check(seccompSpec.SnippetForTag("snap.other.app"), testutil.Contains, "\nbpf\nperf_event_open\n")
+}
+
+const systemTraceScopedMockPlugSnapInfo = `name: other
+version: 1.0
+plugs:
+ system-trace:
+  %s
+apps:
+ app:
+  command: foo
+  plugs: [system-trace]
+`
+
+func (s *SystemTraceInterfaceSuite) mockScopedPlug(c *C, attrs string) (*interfaces.ConnectedPlug, *snap.PlugInfo) {
+	return MockConnectedPlug(c, fmt.Sprintf(systemTraceScopedMockPlugSnapInfo, attrs), nil, "system-trace")
+}
+
+func (s *SystemTraceInterfaceSuite) TestSanitizePlugScoped(c *C) {
+	for _, attrs := range []string{
+		"tracing-paths: [trace_pipe, events/sched/**]",
+		"tracing-paths: []",
+		"perf-events: self",
+		"perf-events: system",
+		"bpf-programs: [kprobe, uprobe, tracepoint, raw-tracepoint, perf-event]",
+	} {
+		_, plugInfo := s.mockScopedPlug(c, attrs)
+		c.Check(interfaces.BeforePreparePlug(s.iface, plugInfo), IsNil, Commentf("%s", attrs))
+	}
+}
+
+func (s *SystemTraceInterfaceSuite) TestSanitizePlugScopedErrors(c *C) {
+	for _, tc := range []struct {
+		attrs string
+		err   string
+	}{
+		{"tracing-paths: trace_pipe", `system-trace "tracing-paths" attribute must be a list of strings, not "trace_pipe"`},
+		{"tracing-paths: [/trace_pipe]", `system-trace tracing path is invalid: "/trace_pipe"`},
+		{"tracing-paths: [../kprobes]", `system-trace tracing path is invalid: "../kprobes"`},
+		{"tracing-paths: [events/./sched]", `system-trace tracing path is invalid: "events/./sched"`},
+		{"tracing-paths: [\"events/{a,b}\"]", `system-trace tracing path is invalid: "events/{a,b}"`},
+		{"tracing-paths: [events/*/enable]", `system-trace tracing path is invalid: "events/\*/enable"`},
+		{"perf-events: all", `system-trace "perf-events" attribute must be either "self" or "system"`},
+		{"perf-events: [self]", `system-trace "perf-events" attribute must be either "self" or "system"`},
+		{"bpf-programs: [xdp]", `system-trace BPF program type "xdp" is not supported`},
+	} {
+		_, plugInfo := s.mockScopedPlug(c, tc.attrs)
+		c.Check(interfaces.BeforePreparePlug(s.iface, plugInfo), ErrorMatches, tc.err, Commentf("%s", tc.attrs))
+	}
+}
+
+func (s *SystemTraceInterfaceSuite) TestScopedTracingPaths(c *C) {
+	plug, _ := s.mockScopedPlug(c, "tracing-paths: [trace_pipe, events/sched/**]")
+
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet := apparmorSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "/sys/kernel/{debug/,}tracing/ r,\n")
+	c.Check(snippet, testutil.Contains, "/sys/kernel/{debug/,}tracing/trace_pipe rw,\n")
+	c.Check(snippet, testutil.Contains, "/sys/kernel/{debug/,}tracing/events/sched/** rw,\n")
+	c.Check(snippet, Not(testutil.Contains), "capability")
+	c.Check(snippet, Not(testutil.Contains), "/sys/kernel/debug/tracing/** rw,")
+
+	seccompSpec := &seccomp.Specification{}
+	err = seccompSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet = seccompSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, Not(testutil.Contains), "bpf")
+	c.Check(snippet, Not(testutil.Contains), "perf_event_open")
+}
+
+func (s *SystemTraceInterfaceSuite) TestScopedPerfEventsSelf(c *C) {
+	plug, _ := s.mockScopedPlug(c, "perf-events: self")
+
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet := apparmorSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, Not(testutil.Contains), "capability")
+	c.Check(snippet, Not(testutil.Contains), "tracing/")
+
+	seccompSpec := &seccomp.Specification{}
+	err = seccompSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet = seccompSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "\nperf_event_open - 0\n")
+	c.Check(snippet, Not(testutil.Contains), "bpf")
+}
+
+func (s *SystemTraceInterfaceSuite) TestScopedPerfEventsSystem(c *C) {
+	plug, _ := s.mockScopedPlug(c, "perf-events: system")
+
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet := apparmorSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "capability perfmon,\n")
+	c.Check(snippet, Not(testutil.Contains), "sys_admin")
+	c.Check(snippet, testutil.Contains, "/sys/bus/event_source/devices/** r,\n")
+
+	seccompSpec := &seccomp.Specification{}
+	err = seccompSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet = seccompSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "\nperf_event_open\n")
+	c.Check(snippet, Not(testutil.Contains), "bpf")
+}
+
+func (s *SystemTraceInterfaceSuite) TestScopedBPFPrograms(c *C) {
+	plug, _ := s.mockScopedPlug(c, "bpf-programs: [kprobe, raw-tracepoint]")
+
+	apparmorSpec := &apparmor.Specification{}
+	err := apparmorSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet := apparmorSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "capability bpf,\n")
+	c.Check(snippet, testutil.Contains, "capability sys_resource,\n")
+	// kprobes are attached through perf events
+	c.Check(snippet, testutil.Contains, "capability perfmon,\n")
+	c.Check(snippet, Not(testutil.Contains), "sys_admin")
+	c.Check(snippet, testutil.Contains, "/sys/kernel/{debug/,}tracing/kprobe_events rw,\n")
+	c.Check(snippet, testutil.Contains, "/sys/kernel/{debug/,}tracing/events/kprobes/** r,\n")
+	c.Check(snippet, testutil.Contains, "/sys/bus/event_source/devices/kprobe/** r,\n")
+	c.Check(snippet, Not(testutil.Contains), "uprobe")
+
+	seccompSpec := &seccomp.Specification{}
+	err = seccompSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet = seccompSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "\nbpf 0\n")
+	c.Check(snippet, testutil.Contains, "\nbpf 5\n")
+	c.Check(snippet, testutil.Contains, "\nbpf 17\n")
+	c.Check(snippet, Not(testutil.Contains), "\nbpf\n")
+	// kprobes are attached through perf events
+	c.Check(snippet, testutil.Contains, "\nperf_event_open\n")
+}
+
+func (s *SystemTraceInterfaceSuite) TestScopedBPFProgramsRawTracepointOnly(c *C) {
+	plug, _ := s.mockScopedPlug(c, "bpf-programs: [raw-tracepoint]\n  perf-events: self")
+
+	seccompSpec := &seccomp.Specification{}
+	err := seccompSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet := seccompSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "\nbpf 17\n")
+	c.Check(snippet, testutil.Contains, "\nperf_event_open - 0\n")
+	c.Check(snippet, Not(testutil.Contains), "\nperf_event_open\n")
+
+	apparmorSpec := &apparmor.Specification{}
+	err = apparmorSpec.AddConnectedPlug(s.iface, plug, s.slot)
+	c.Assert(err, IsNil)
+	snippet = apparmorSpec.SnippetForTag("snap.other.app")
+	c.Check(snippet, testutil.Contains, "capability bpf,\n")
+	c.Check(snippet, Not(testutil.Contains), "perfmon")
+	c.Check(snippet, Not(testutil.Contains), "sys_admin")
+}
+
 func (s *SystemTraceInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
@@ -20,6 +20,7 @@
 package builtin
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -57,23 +58,66 @@ func (iface *uioInterface) StaticInfo() interfaces.StaticInfo {
 
 var uioPattern = regexp.MustCompile(`^/dev/uio[0-9]+$`)
 
+// Pattern of the name of the uio device, as reported by the driver in
+// /sys/class/uio/uioN/name. Device nodes are numbered in the order of probing
+// and thus selecting a device by name is more stable than by path.
+var uioNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.:-]{0,63}$`)
+
 const invalidUioDeviceNodeSlotPathErrFmt = "slot %q path attribute must be a valid UIO device node"
 
 func (iface *uioInterface) path(slotRef *interfaces.SlotRef, attrs interfaces.Attrer) (string, error) {
 	return verifySlotPathAttribute(slotRef, attrs, uioPattern, invalidUioDeviceNodeSlotPathErrFmt)
 }
 
+// uioName returns the value of the uio-name attribute, or an empty string when
+// the device is selected by path.
+func (iface *uioInterface) uioName(attrs interfaces.Attrer) (string, error) {
+	var name string
+	if err := attrs.Attr("uio-name", &name); err != nil {
+		if errors.Is(err, snap.AttributeNotFoundError{}) {
+			return "", nil
+		}
+		return "", err
+	}
+	if !uioNamePattern.MatchString(name) {
+		return "", fmt.Errorf("uio-name attribute must be a valid uio device name")
+	}
+	return name, nil
+}
+
 func (iface *uioInterface) BeforePrepareSlot(slot *snap.SlotInfo) error {
-	_, err := verifySlotPathAttribute(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, uioPattern, invalidUioDeviceNodeSlotPathErrFmt)
+	name, err := iface.uioName(slot)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		if _, ok := slot.Attrs["path"]; ok {
+			return fmt.Errorf("uio slot cannot have both path and uio-name attributes")
+		}
+		return nil
+	}
+	_, err = verifySlotPathAttribute(&interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}, slot, uioPattern, invalidUioDeviceNodeSlotPathErrFmt)
 	return err
 }
 
 func (iface *uioInterface) AppArmorConnectedPlug(spec *apparmor.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
-	path, err := iface.path(slot.Ref(), slot)
+	name, err := iface.uioName(slot)
 	if err != nil {
 		return nil
 	}
-	spec.AddSnippet(fmt.Sprintf("%s rw,", path))
+	if name != "" {
+		// This apparmor rule must match uioPattern. UDev tagging and
+		// device cgroups will restrict down to the specific device.
+		spec.AddDeduplicatedSnippet("/dev/uio[0-9]* rw,  # common rule for uio connections by name")
+		// Allow finding the device node with a matching name.
+		spec.AddDeduplicatedSnippet("/sys/class/uio/ r,  # common rule for uio connections by name")
+	} else {
+		path, err := iface.path(slot.Ref(), slot)
+		if err != nil {
+			return nil
+		}
+		spec.AddSnippet(fmt.Sprintf("%s rw,", path))
+	}
 	// Assuming sysfs_base is /sys/class/uio/uio[0-9]+ where the leaf directory
 	// name matches /dev/uio[0-9]+ device name, the following files exists or
 	// may exist:
@@ -104,6 +148,14 @@ func (iface *uioInterface) AppArmorConnectedPlug(spec *apparmor.Specification, p
 }
 
 func (iface *uioInterface) UDevConnectedPlug(spec *udev.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	name, err := iface.uioName(slot)
+	if err != nil {
+		return nil
+	}
+	if name != "" {
+		spec.TagDevice(fmt.Sprintf(`SUBSYSTEM=="uio", ATTR{name}=="%s"`, name))
+		return nil
+	}
 	path, err := iface.path(slot.Ref(), slot)
 	if err != nil {
 		return nil
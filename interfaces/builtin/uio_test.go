@@ -119,6 +119,69 @@ func (s *uioInterfaceSuite) TestAppArmorSpec(c *C) {
 		"/sys/devices/platform/**/uio/uio[0-9]** r,  # common rule for all uio connections")
 }
 
+func (s *uioInterfaceSuite) TestUioNameSlot(c *C) {
+	info := snaptest.MockInfo(c, `
+name: gadget
+version: 0
+type: gadget
+slots:
+  plc:
+    interface: uio
+    uio-name: plc-io
+  fpga:
+    interface: uio
+    uio-name: fpga.0
+`, nil)
+	c.Assert(interfaces.BeforePrepareSlot(s.iface, info.Slots["plc"]), IsNil)
+	slot := interfaces.NewConnectedSlot(info.Slots["plc"], nil, nil)
+	otherSlot := interfaces.NewConnectedSlot(info.Slots["fpga"], nil, nil)
+
+	udevSpec := &udev.Specification{}
+	c.Assert(udevSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(udevSpec.Snippets(), HasLen, 2)
+	c.Assert(udevSpec.Snippets(), testutil.Contains, `# uio
+SUBSYSTEM=="uio", ATTR{name}=="plc-io", TAG+="snap_consumer_app"`)
+
+	apparmorSpec := &apparmor.Specification{}
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, slot), IsNil)
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, otherSlot), IsNil)
+	c.Assert(apparmorSpec.AddConnectedPlug(s.iface, s.plug, s.slotGadget0), IsNil)
+	c.Assert(apparmorSpec.SnippetForTag("snap.consumer.app"), Equals, ""+
+		"/dev/uio0 rw,\n"+
+		"/dev/uio[0-9]* rw,  # common rule for uio connections by name\n"+
+		"/sys/class/uio/ r,  # common rule for uio connections by name\n"+
+		"/sys/devices/platform/**/uio/uio[0-9]** r,  # common rule for all uio connections")
+}
+
+func (s *uioInterfaceSuite) TestSanitizeSlotUioName(c *C) {
+	for _, tc := range []struct {
+		attrs string
+		err   string
+	}{{
+		attrs: "uio-name: plc\n    path: /dev/uio0",
+		err:   "uio slot cannot have both path and uio-name attributes",
+	}, {
+		attrs: `uio-name: foo"bar`,
+		err:   "uio-name attribute must be a valid uio device name",
+	}, {
+		attrs: "uio-name: -foo",
+		err:   "uio-name attribute must be a valid uio device name",
+	}, {
+		attrs: "uio-name: 1",
+		err:   `snap "broken-gadget" has interface "uio" with invalid value type int64 for "uio-name" attribute: \*string`,
+	}} {
+		slot := snaptest.MockInfo(c, fmt.Sprintf(`
+name: broken-gadget
+version: 1
+type: gadget
+slots:
+  uio:
+    %s
+`, tc.attrs), nil).Slots["uio"]
+		c.Check(interfaces.BeforePrepareSlot(s.iface, slot), ErrorMatches, tc.err, Commentf("attrs: %s", tc.attrs))
+	}
+}
+
 func (s *uioInterfaceSuite) TestStaticInfo(c *C) {
 	si := interfaces.StaticInfoOf(s.iface)
 	c.Assert(si.ImplicitOnCore, Equals, false)
@@ -227,6 +227,11 @@ type StaticInfo struct {
 	// system-packages-doc that could get the flag set back to false.
 	AffectsPlugOnRefresh bool `json:"affects-plug-on-refresh,omitempty"`
 
+	// DeprecatedBy is the name of the interface replacing this one when
+	// it is deprecated. Existing connections keep working but their
+	// snaps are expected to move to the replacement.
+	DeprecatedBy string `json:"deprecated-by,omitempty"`
+
 	// BaseDeclarationPlugs defines an optional extension to the base-declaration assertion relevant for this interface.
 	BaseDeclarationPlugs string
 	// BaseDeclarationSlots defines an optional extension to the base-declaration assertion relevant for this interface.
@@ -265,6 +270,56 @@ func StaticInfoOf(iface Interface) (si StaticInfo) {
 	return si
 }
 
+// ConnectionMigrator can be implemented by deprecated interfaces that need
+// custom logic to map a connection to the plug and slot of the replacement
+// interface.
+type ConnectionMigrator interface {
+	MigrateConnection(plug *snap.PlugInfo, slot *snap.SlotInfo) (*snap.PlugInfo, *snap.SlotInfo, error)
+}
+
+// MigrateConnection returns the plug and slot of the replacement of the
+// given deprecated interface that the connection between plug and slot
+// maps to. Unless the interface implements ConnectionMigrator the
+// connection maps to the only plug and slot of the replacement interface
+// of the respective snaps. It returns nil plug and slot without error if
+// there is no such mapping.
+func MigrateConnection(iface Interface, plug *snap.PlugInfo, slot *snap.SlotInfo) (*snap.PlugInfo, *snap.SlotInfo, error) {
+	replacement := StaticInfoOf(iface).DeprecatedBy
+	if replacement == "" {
+		return nil, nil, fmt.Errorf("interface %q is not deprecated", iface.Name())
+	}
+	if migrator, ok := iface.(ConnectionMigrator); ok {
+		return migrator.MigrateConnection(plug, slot)
+	}
+
+	var newPlug *snap.PlugInfo
+	for _, p := range plug.Snap.Plugs {
+		if p.Interface != replacement {
+			continue
+		}
+		if newPlug != nil {
+			// ambiguous
+			return nil, nil, nil
+		}
+		newPlug = p
+	}
+	var newSlot *snap.SlotInfo
+	for _, s := range slot.Snap.Slots {
+		if s.Interface != replacement {
+			continue
+		}
+		if newSlot != nil {
+			// ambiguous
+			return nil, nil, nil
+		}
+		newSlot = s
+	}
+	if newPlug == nil || newSlot == nil {
+		return nil, nil, nil
+	}
+	return newPlug, newSlot, nil
+}
+
 // Specification describes interactions between backends and interfaces.
 type Specification interface {
 	// AddPermanentSlot records side-effects of having a slot.
@@ -297,6 +352,8 @@ const (
 	SecuritySystemd SecuritySystem = "systemd"
 	// SecurityPolkit identifies the polkit security system.
 	SecurityPolkit SecuritySystem = "polkit"
+	// SecurityLandlock identifies the landlock security system.
+	SecurityLandlock SecuritySystem = "landlock"
 )
 
 var isValidBusName = regexp.MustCompile(`^[a-zA-Z_-][a-zA-Z0-9_-]*(\.[a-zA-Z_-][a-zA-Z0-9_-]*)+$`).MatchString
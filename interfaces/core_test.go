@@ -279,3 +279,64 @@ slots:
 		InterfaceName: "other",
 	}, slot), ErrorMatches, `cannot sanitize slot "snap:slot" \(interface "iface"\) using interface "other"`)
 }
+
+type migratingIface struct {
+	ifacetest.TestInterface
+	migrate func(plug *snap.PlugInfo, slot *snap.SlotInfo) (*snap.PlugInfo, *snap.SlotInfo, error)
+}
+
+func (iface *migratingIface) MigrateConnection(plug *snap.PlugInfo, slot *snap.SlotInfo) (*snap.PlugInfo, *snap.SlotInfo, error) {
+	return iface.migrate(plug, slot)
+}
+
+func (s *CoreSuite) TestMigrateConnection(c *C) {
+	plugInfo := snaptest.MockInfo(c, `
+name: consumer
+version: 0
+plugs:
+  old:
+    interface: old-iface
+  new:
+    interface: new-iface
+`, nil)
+	slotInfo := snaptest.MockInfo(c, `
+name: producer
+version: 0
+slots:
+  old:
+    interface: old-iface
+  new:
+    interface: new-iface
+`, nil)
+	iface := &ifacetest.TestInterface{
+		InterfaceName:       "old-iface",
+		InterfaceStaticInfo: interfaces.StaticInfo{DeprecatedBy: "new-iface"},
+	}
+
+	plug, slot, err := interfaces.MigrateConnection(iface, plugInfo.Plugs["old"], slotInfo.Slots["old"])
+	c.Assert(err, IsNil)
+	c.Check(plug, Equals, plugInfo.Plugs["new"])
+	c.Check(slot, Equals, slotInfo.Slots["new"])
+
+	// no mapping without a plug of the replacement interface
+	delete(plugInfo.Plugs, "new")
+	plug, slot, err = interfaces.MigrateConnection(iface, plugInfo.Plugs["old"], slotInfo.Slots["old"])
+	c.Assert(err, IsNil)
+	c.Check(plug, IsNil)
+	c.Check(slot, IsNil)
+
+	// interfaces can provide their own mapping
+	migrating := &migratingIface{
+		TestInterface: *iface,
+		migrate: func(plug *snap.PlugInfo, slot *snap.SlotInfo) (*snap.PlugInfo, *snap.SlotInfo, error) {
+			return plug, slotInfo.Slots["new"], nil
+		},
+	}
+	plug, slot, err = interfaces.MigrateConnection(migrating, plugInfo.Plugs["old"], slotInfo.Slots["old"])
+	c.Assert(err, IsNil)
+	c.Check(plug, Equals, plugInfo.Plugs["old"])
+	c.Check(slot, Equals, slotInfo.Slots["new"])
+
+	_, _, err = interfaces.MigrateConnection(&ifacetest.TestInterface{InterfaceName: "old-iface"}, plugInfo.Plugs["old"], slotInfo.Slots["old"])
+	c.Check(err, ErrorMatches, `interface "old-iface" is not deprecated`)
+}
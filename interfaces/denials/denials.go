@@ -0,0 +1,291 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package denials parses the AppArmor denials logged by the kernel or the
+// audit daemon and attributes them to snaps and to the interfaces which
+// would likely allow the denied operations.
+package denials
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnknownInterface is the interface of denials which cannot be attributed
+// to any interface.
+const UnknownInterface = "unknown"
+
+// Denial is an operation of a snap denied by AppArmor.
+type Denial struct {
+	// Snap is the instance name of the snap the denial is attributed to.
+	Snap string
+	// App is the app or hook the denial is attributed to, hooks are
+	// prefixed with "hook.".
+	App string
+	// Interface is the interface which would likely allow the operation,
+	// or UnknownInterface.
+	Interface string
+	// Profile is the AppArmor profile which denied the operation.
+	Profile string
+	// Operation is the denied operation, e.g. "open" or
+	// "dbus_method_call".
+	Operation string
+	// Name is the object of the operation, e.g. a path, a capability or
+	// a D-Bus bus name.
+	Name string
+	// DeniedMask is the denied access, e.g. "r" or "send".
+	DeniedMask string
+	// DBusInterface and DBusMember are set for D-Bus denials.
+	DBusInterface string
+	DBusMember    string
+}
+
+// Key identifies the snap and interface the denial is attributed to.
+func (d *Denial) Key() string {
+	return d.Snap + "/" + d.Interface
+}
+
+// Data returns the details of the denial as the data of a notice.
+func (d *Denial) Data() map[string]string {
+	data := map[string]string{
+		"snap":      d.Snap,
+		"app":       d.App,
+		"interface": d.Interface,
+		"profile":   d.Profile,
+		"operation": d.Operation,
+	}
+	for k, v := range map[string]string{
+		"name":           d.Name,
+		"denied-mask":    d.DeniedMask,
+		"dbus-interface": d.DBusInterface,
+		"dbus-member":    d.DBusMember,
+	} {
+		if v != "" {
+			data[k] = v
+		}
+	}
+	return data
+}
+
+var fieldRegexp = regexp.MustCompile(`([a-z_]+)=("[^"]*"|[^ ]+)`)
+
+func parseFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for _, m := range fieldRegexp.FindAllStringSubmatch(line, -1) {
+		if _, ok := fields[m[1]]; ok {
+			// keep the first occurrence, later ones may come from
+			// the untrusted name of the object
+			continue
+		}
+		fields[m[1]] = strings.Trim(m[2], `"`)
+	}
+	return fields
+}
+
+// Parse returns the denial logged in the given line of the kernel or
+// audit log, and false if the line does not log the denial of an operation
+// of a snap.
+func Parse(line string) (*Denial, bool) {
+	if !strings.Contains(line, `apparmor="DENIED"`) {
+		return nil, false
+	}
+	fields := parseFields(line)
+	if fields["apparmor"] != "DENIED" {
+		return nil, false
+	}
+	// D-Bus denials name the profile label
+	profile := fields["profile"]
+	if profile == "" {
+		profile = fields["label"]
+	}
+	snapName, app, ok := parseProfile(profile)
+	if !ok {
+		return nil, false
+	}
+	d := &Denial{
+		Snap:          snapName,
+		App:           app,
+		Profile:       profile,
+		Operation:     fields["operation"],
+		Name:          fields["name"],
+		DeniedMask:    fields["denied_mask"],
+		DBusInterface: fields["interface"],
+		DBusMember:    fields["member"],
+	}
+	if d.Operation == "capable" {
+		d.Name = fields["capname"]
+	}
+	if d.DeniedMask == "" {
+		d.DeniedMask = fields["mask"]
+	}
+	d.Interface = attribute(d)
+	return d, true
+}
+
+// parseProfile returns the snap and app of a profile such as
+// "snap.foo.app" or "snap.foo.hook.configure".
+func parseProfile(profile string) (snapName, app string, ok bool) {
+	// child profiles are reported as "parent//child"
+	if i := strings.Index(profile, "//"); i >= 0 {
+		profile = profile[:i]
+	}
+	if !strings.HasPrefix(profile, "snap.") {
+		return "", "", false
+	}
+	parts := strings.SplitN(profile, ".", 3)
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+type hint struct {
+	re    *regexp.Regexp
+	iface string
+}
+
+func hints(iface string, patterns ...string) []hint {
+	hs := make([]hint, len(patterns))
+	for i, p := range patterns {
+		hs[i] = hint{re: regexp.MustCompile(p), iface: iface}
+	}
+	return hs
+}
+
+func concat(lists ...[]hint) []hint {
+	var all []hint
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// pathHints map the paths most commonly accessed by snaps lacking an
+// interface to the interface, the first match wins.
+var pathHints = concat(
+	hints("camera", `^/dev/video[0-9]+$`),
+	hints("alsa", `^/dev/snd/`),
+	hints("audio-playback", `^/run/user/[0-9]+/pulse/`, `^/run/user/[0-9]+/pipewire-`),
+	hints("serial-port", `^/dev/tty(USB|ACM|S)[0-9]+$`),
+	hints("hidraw", `^/dev/hidraw[0-9]+$`),
+	hints("raw-usb", `^/dev/bus/usb/`, `^/sys/bus/usb/`),
+	hints("gpio", `^/sys/class/gpio/`, `^/dev/gpiochip[0-9]+$`),
+	hints("i2c", `^/dev/i2c-[0-9]+$`),
+	hints("spi", `^/dev/spidev[0-9.]+$`),
+	hints("joystick", `^/dev/input/js[0-9]+$`),
+	hints("kvm", `^/dev/kvm$`),
+	hints("opengl", `^/dev/dri/`, `^/dev/nvidia`),
+	hints("tpm", `^/dev/tpm`),
+	hints("log-observe", `^/var/log/`),
+	hints("mount-observe", `^/proc/[0-9]+/mount`),
+	hints("network-observe", `^/sys/class/net/`, `^/proc/[0-9]+/net/`),
+	hints("hardware-observe", `^/sys/devices/`, `^/run/udev/data/`),
+	hints("removable-media", `^/media/`, `^/run/media/`, `^/mnt/`),
+	hints("personal-files", `^/home/[^/]+/\.`),
+	hints("home", `^/home/`),
+)
+
+// dbusHints map the D-Bus interfaces most commonly used by snaps lacking
+// an interface to the interface, by prefix.
+var dbusHints = []struct {
+	prefix string
+	iface  string
+}{
+	{"org.freedesktop.NetworkManager", "network-manager"},
+	{"org.freedesktop.ModemManager1", "modem-manager"},
+	{"org.freedesktop.Notifications", "desktop"},
+	{"org.freedesktop.UPower", "upower-observe"},
+	{"org.freedesktop.login1", "login-session-observe"},
+	{"org.freedesktop.hostname1", "hostname-control"},
+	{"org.freedesktop.timedate1", "timezone-control"},
+	{"org.freedesktop.systemd1", "system-observe"},
+	{"org.bluez", "bluez"},
+	{"org.freedesktop.Avahi", "avahi-observe"},
+}
+
+// capabilityHints map the capabilities most commonly used by snaps lacking
+// an interface to the interface.
+var capabilityHints = map[string]string{
+	"net_admin":  "network-control",
+	"net_raw":    "network-observe",
+	"sys_module": "kernel-module-control",
+	"sys_time":   "time-control",
+	"sys_ptrace": "system-trace",
+	"sys_rawio":  "raw-volume",
+}
+
+func attribute(d *Denial) string {
+	switch {
+	case strings.HasPrefix(d.Operation, "dbus_"):
+		for _, h := range dbusHints {
+			if strings.HasPrefix(d.DBusInterface, h.prefix) || strings.HasPrefix(d.Name, h.prefix) {
+				return h.iface
+			}
+		}
+	case d.Operation == "capable":
+		if iface, ok := capabilityHints[d.Name]; ok {
+			return iface
+		}
+	case strings.HasPrefix(d.Name, "/"):
+		for _, h := range pathHints {
+			if h.re.MatchString(d.Name) {
+				return h.iface
+			}
+		}
+	}
+	return UnknownInterface
+}
+
+// Limiter rate-limits the denials by key, so that a snap repeatedly
+// denied the same operation does not flood its consumers.
+type Limiter struct {
+	interval time.Duration
+
+	mu         sync.Mutex
+	last       map[string]time.Time
+	suppressed map[string]int
+}
+
+// NewLimiter returns a limiter allowing a denial per key per interval.
+func NewLimiter(interval time.Duration) *Limiter {
+	return &Limiter{
+		interval:   interval,
+		last:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// Allow returns whether a denial with the given key at the given time is
+// allowed and, if so, how many denials with the key were suppressed since
+// the last allowed one.
+func (l *Limiter) Allow(key string, now time.Time) (allowed bool, suppressed int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if last, ok := l.last[key]; ok && now.Sub(last) < l.interval {
+		l.suppressed[key]++
+		return false, 0
+	}
+	suppressed = l.suppressed[key]
+	l.last[key] = now
+	delete(l.suppressed, key)
+	return true, suppressed
+}
@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package denials_test
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/denials"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type denialsSuite struct{}
+
+var _ = Suite(&denialsSuite{})
+
+func (s *denialsSuite) TestParseKernelLog(c *C) {
+	line := `Jan 10 12:00:00 host kernel: [ 1234.5678] audit: type=1400 audit(1673352000.123:42): apparmor="DENIED" operation="open" profile="snap.foo.app" name="/dev/video0" pid=1234 comm="app" requested_mask="wr" denied_mask="wr" fsuid=1000 ouid=0`
+	d, ok := denials.Parse(line)
+	c.Assert(ok, Equals, true)
+	c.Check(d, DeepEquals, &denials.Denial{
+		Snap:       "foo",
+		App:        "app",
+		Interface:  "camera",
+		Profile:    "snap.foo.app",
+		Operation:  "open",
+		Name:       "/dev/video0",
+		DeniedMask: "wr",
+	})
+	c.Check(d.Key(), Equals, "foo/camera")
+	c.Check(d.Data(), DeepEquals, map[string]string{
+		"snap":        "foo",
+		"app":         "app",
+		"interface":   "camera",
+		"profile":     "snap.foo.app",
+		"operation":   "open",
+		"name":        "/dev/video0",
+		"denied-mask": "wr",
+	})
+}
+
+func (s *denialsSuite) TestParseAuditLog(c *C) {
+	line := `type=AVC msg=audit(1673352000.123:42): apparmor="DENIED" operation="dbus_method_call" bus="system" path="/org/freedesktop/NetworkManager" interface="org.freedesktop.NetworkManager" member="GetDevices" mask="send" name="org.freedesktop.NetworkManager" pid=1234 label="snap.foo.hook.configure" peer_label="unconfined"`
+	d, ok := denials.Parse(line)
+	c.Assert(ok, Equals, true)
+	c.Check(d, DeepEquals, &denials.Denial{
+		Snap:          "foo",
+		App:           "hook.configure",
+		Interface:     "network-manager",
+		Profile:       "snap.foo.hook.configure",
+		Operation:     "dbus_method_call",
+		Name:          "org.freedesktop.NetworkManager",
+		DeniedMask:    "send",
+		DBusInterface: "org.freedesktop.NetworkManager",
+		DBusMember:    "GetDevices",
+	})
+}
+
+func (s *denialsSuite) TestParseCapability(c *C) {
+	line := `audit: type=1400 audit(1673352000.123:42): apparmor="DENIED" operation="capable" profile="snap.foo_bar.daemon" pid=1234 comm="daemon" capability=12 capname="net_admin"`
+	d, ok := denials.Parse(line)
+	c.Assert(ok, Equals, true)
+	c.Check(d.Snap, Equals, "foo_bar")
+	c.Check(d.App, Equals, "daemon")
+	c.Check(d.Name, Equals, "net_admin")
+	c.Check(d.Interface, Equals, "network-control")
+}
+
+func (s *denialsSuite) TestParseAttribution(c *C) {
+	for _, t := range []struct {
+		name, iface string
+	}{
+		{"/dev/snd/pcmC0D0p", "alsa"},
+		{"/run/user/1000/pulse/native", "audio-playback"},
+		{"/dev/ttyUSB0", "serial-port"},
+		{"/dev/bus/usb/001/002", "raw-usb"},
+		{"/media/user/disk/file", "removable-media"},
+		{"/home/user/.config/foo", "personal-files"},
+		{"/home/user/Documents/foo", "home"},
+		{"/var/log/syslog", "log-observe"},
+		{"/etc/something", denials.UnknownInterface},
+	} {
+		line := `apparmor="DENIED" operation="open" profile="snap.foo.app" name="` + t.name + `" pid=1 comm="app" requested_mask="r" denied_mask="r"`
+		d, ok := denials.Parse(line)
+		c.Assert(ok, Equals, true)
+		c.Check(d.Interface, Equals, t.iface, Commentf("path %q", t.name))
+	}
+}
+
+func (s *denialsSuite) TestParseIgnored(c *C) {
+	for _, line := range []string{
+		"",
+		`Jan 10 12:00:00 host kernel: usb 1-1: new high-speed USB device`,
+		`audit: type=1400 audit(1673352000.123:42): apparmor="ALLOWED" operation="open" profile="snap.foo.app" name="/dev/video0"`,
+		`audit: type=1400 audit(1673352000.123:42): apparmor="DENIED" operation="open" profile="/usr/sbin/cupsd" name="/etc/foo"`,
+		`audit: type=1400 audit(1673352000.123:42): apparmor="DENIED" operation="open" profile="snap.foo" name="/etc/foo"`,
+		// not fooled by the name of the object
+		`audit: type=1400 audit(1673352000.123:42): apparmor="STATUS" operation="open" profile="snap.foo.app" name="apparmor="DENIED""`,
+	} {
+		_, ok := denials.Parse(line)
+		c.Check(ok, Equals, false, Commentf("line %q", line))
+	}
+}
+
+func (s *denialsSuite) TestParseChildProfile(c *C) {
+	line := `apparmor="DENIED" operation="open" profile="snap.foo.app//null-/usr/bin/helper" name="/dev/kvm" pid=1 comm="helper" requested_mask="r" denied_mask="r"`
+	d, ok := denials.Parse(line)
+	c.Assert(ok, Equals, true)
+	c.Check(d.Snap, Equals, "foo")
+	c.Check(d.App, Equals, "app")
+	c.Check(d.Interface, Equals, "kvm")
+}
+
+func (s *denialsSuite) TestLimiter(c *C) {
+	l := denials.NewLimiter(time.Minute)
+	t0 := time.Now()
+
+	allowed, suppressed := l.Allow("foo/camera", t0)
+	c.Check(allowed, Equals, true)
+	c.Check(suppressed, Equals, 0)
+
+	for i := 1; i <= 3; i++ {
+		allowed, _ = l.Allow("foo/camera", t0.Add(time.Duration(i)*time.Second))
+		c.Check(allowed, Equals, false)
+	}
+	// other keys are limited separately
+	allowed, _ = l.Allow("foo/home", t0.Add(time.Second))
+	c.Check(allowed, Equals, true)
+
+	allowed, suppressed = l.Allow("foo/camera", t0.Add(time.Minute))
+	c.Check(allowed, Equals, true)
+	c.Check(suppressed, Equals, 3)
+
+	allowed, suppressed = l.Allow("foo/camera", t0.Add(3*time.Minute))
+	c.Check(allowed, Equals, true)
+	c.Check(suppressed, Equals, 0)
+}
@@ -0,0 +1,173 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package hotplug
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// DeviceClass describes a class of devices, like USB storage or V4L2
+// cameras, that hotplug slots are created for, together with the policies
+// to derive a stable identity and a slot name for each device of the class.
+// Interfaces use it to implement Definer and HotplugKeyHandler.
+type DeviceClass struct {
+	// Name of the class, e.g. "usb-storage". It is part of the keys of
+	// the devices and the fallback slot name.
+	Name string
+
+	// Subsystem is the udev subsystem of the devices, e.g. "block".
+	Subsystem string
+	// DeviceType is the udev device type of the devices, e.g. "disk",
+	// if any.
+	DeviceType string
+	// Bus is the bus the devices are attached to as reported by the
+	// ID_BUS property, e.g. "usb", if any.
+	Bus string
+	// DeviceNamePattern must match the device node of the devices, if set.
+	DeviceNamePattern *regexp.Regexp
+	// Match is an additional predicate for the devices, if set.
+	Match func(di *HotplugDeviceInfo) bool
+
+	// KeyAttrs are the groups of udev properties making up the stable
+	// identity of a device. The first non-empty property of each group
+	// goes into the key and every group must have one for a key to be
+	// computed. When no key can be computed the default key of the
+	// hotplug subsystem is used instead.
+	// Warning, any future changes to these definitions change the keys of
+	// the devices already known.
+	KeyAttrs [][]string
+
+	// NameAttrs are the udev properties the slot name is derived from, in
+	// order of preference. The name of the class is used when none of
+	// them is set.
+	NameAttrs []string
+	// SlotNamePrefix is prepended to the slot names derived from
+	// NameAttrs, if set.
+	SlotNamePrefix string
+}
+
+// Matches returns whether the device belongs to the class.
+func (dc *DeviceClass) Matches(di *HotplugDeviceInfo) bool {
+	if di.Subsystem() != dc.Subsystem {
+		return false
+	}
+	if dc.DeviceType != "" && di.DeviceType() != dc.DeviceType {
+		return false
+	}
+	if dc.Bus != "" {
+		if bus, _ := di.Attribute("ID_BUS"); bus != dc.Bus {
+			return false
+		}
+	}
+	if dc.DeviceNamePattern != nil && !dc.DeviceNamePattern.MatchString(di.DeviceName()) {
+		return false
+	}
+	if dc.Match != nil && !dc.Match(di) {
+		return false
+	}
+	return true
+}
+
+// Key returns the hotplug key of the device, computed from the properties
+// listed in KeyAttrs. An empty key is returned when the device does not have
+// all of them, in which case the default key should be used.
+func (dc *DeviceClass) Key(di *HotplugDeviceInfo) (snap.HotplugKey, error) {
+	if len(dc.KeyAttrs) == 0 {
+		return "", nil
+	}
+	if dc.Name == "" {
+		return "", fmt.Errorf("internal error: device class without a name")
+	}
+	key := sha256.New()
+	key.Write([]byte(dc.Name))
+	key.Write([]byte{0})
+	for _, group := range dc.KeyAttrs {
+		attr, val := di.firstAttrOf(group...)
+		if val == "" {
+			return "", nil
+		}
+		key.Write([]byte(attr))
+		key.Write([]byte{0})
+		key.Write([]byte(val))
+		key.Write([]byte{0})
+	}
+	return snap.HotplugKey(fmt.Sprintf("%x", key.Sum(nil))), nil
+}
+
+// SlotName returns the name proposed for the slot of the device.
+func (dc *DeviceClass) SlotName(di *HotplugDeviceInfo) string {
+	_, val := di.firstAttrOf(dc.NameAttrs...)
+	if dc.SlotNamePrefix != "" && val != "" {
+		val = dc.SlotNamePrefix + "-" + val
+	}
+	if name := MakeSlotName(val); name != "" {
+		return name
+	}
+	return dc.Name
+}
+
+func (h *HotplugDeviceInfo) firstAttrOf(tryAttrs ...string) (attr, val string) {
+	for _, attr := range tryAttrs {
+		if val, _ := h.Attribute(attr); val != "" {
+			return attr, val
+		}
+	}
+	return "", ""
+}
+
+const maxGenerateSlotNameLen = 20
+
+// MakeSlotName sanitizes a string to make it a valid slot name that
+// passes validation rules implemented by ValidateSlotName (see snap/validate.go):
+// - only lowercase letter, digits and dashes are allowed
+// - must start with a letter
+// - no double dashes, cannot end with a dash.
+// In addition names are truncated not to exceed maxGenerateSlotNameLen characters.
+func MakeSlotName(s string) string {
+	var out []rune
+	// the dash flag is used to prevent consecutive dashes, and the dash in the front
+	dash := true
+	for _, c := range s {
+		switch {
+		case c == '-' && !dash:
+			dash = true
+			out = append(out, '-')
+		case unicode.IsLetter(c):
+			out = append(out, unicode.ToLower(c))
+			dash = false
+		case unicode.IsDigit(c) && len(out) > 0:
+			out = append(out, c)
+			dash = false
+		default:
+			// any other character is ignored
+		}
+		if len(out) >= maxGenerateSlotNameLen {
+			break
+		}
+	}
+	// make sure the name doesn't end with a dash
+	return strings.TrimRight(string(out), "-")
+}
@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package hotplug
+
+import (
+	"regexp"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type deviceClassSuite struct{}
+
+var _ = Suite(&deviceClassSuite{})
+
+var testStorageClass = DeviceClass{
+	Name:              "usb-storage",
+	Subsystem:         "block",
+	DeviceType:        "disk",
+	Bus:               "usb",
+	DeviceNamePattern: regexp.MustCompile(`^/dev/sd[a-z]$`),
+	KeyAttrs: [][]string{
+		{"ID_VENDOR_ID"},
+		{"ID_MODEL_ID"},
+		{"ID_SERIAL", "ID_PATH"},
+	},
+	NameAttrs: []string{"ID_MODEL", "ID_MODEL_FROM_DATABASE"},
+}
+
+func storageDevice(c *C, extra map[string]string) *HotplugDeviceInfo {
+	env := map[string]string{
+		"DEVPATH":      "/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/host0/target0:0:0/0:0:0:0/block/sdb",
+		"DEVNAME":      "/dev/sdb",
+		"DEVTYPE":      "disk",
+		"SUBSYSTEM":    "block",
+		"ID_BUS":       "usb",
+		"ID_VENDOR_ID": "0781",
+		"ID_MODEL_ID":  "5567",
+		"ID_MODEL":     "Cruzer_Blade",
+		"ID_SERIAL":    "SanDisk_Cruzer_Blade_4C530001-0:0",
+		"ID_PATH":      "pci-0000:00:14.0-usb-0:1:1.0-scsi-0:0:0:0",
+	}
+	for k, v := range extra {
+		if v == "" {
+			delete(env, k)
+		} else {
+			env[k] = v
+		}
+	}
+	di, err := NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+	return di
+}
+
+func (s *deviceClassSuite) TestMatches(c *C) {
+	c.Check(testStorageClass.Matches(storageDevice(c, nil)), Equals, true)
+
+	for _, extra := range []map[string]string{
+		{"SUBSYSTEM": "tty"},
+		{"DEVTYPE": "partition", "DEVNAME": "/dev/sdb1"},
+		{"DEVTYPE": "partition"},
+		{"ID_BUS": "ata"},
+		{"ID_BUS": ""},
+		{"DEVNAME": "/dev/nvme0n1"},
+	} {
+		c.Check(testStorageClass.Matches(storageDevice(c, extra)), Equals, false, Commentf("%v", extra))
+	}
+
+	class := testStorageClass
+	class.Match = func(di *HotplugDeviceInfo) bool {
+		return di.DeviceName() != "/dev/sdb"
+	}
+	c.Check(class.Matches(storageDevice(c, nil)), Equals, false)
+	c.Check(class.Matches(storageDevice(c, map[string]string{"DEVNAME": "/dev/sdc"})), Equals, true)
+}
+
+func (s *deviceClassSuite) TestKey(c *C) {
+	key, err := testStorageClass.Key(storageDevice(c, nil))
+	c.Assert(err, IsNil)
+	c.Check(string(key), Matches, "[0-9a-f]{64}")
+
+	// the key does not depend on the device node or the port when there
+	// is a serial
+	other, err := testStorageClass.Key(storageDevice(c, map[string]string{
+		"DEVNAME": "/dev/sdc",
+		"ID_PATH": "pci-0000:00:14.0-usb-0:2:1.0-scsi-0:0:0:0",
+	}))
+	c.Assert(err, IsNil)
+	c.Check(other, Equals, key)
+
+	// but on the serial
+	other, err = testStorageClass.Key(storageDevice(c, map[string]string{"ID_SERIAL": "SanDisk_Cruzer_Blade_4C530002-0:0"}))
+	c.Assert(err, IsNil)
+	c.Check(other, Not(Equals), key)
+
+	// and on the port otherwise
+	noSerial, err := testStorageClass.Key(storageDevice(c, map[string]string{"ID_SERIAL": ""}))
+	c.Assert(err, IsNil)
+	c.Check(string(noSerial), Matches, "[0-9a-f]{64}")
+	c.Check(noSerial, Not(Equals), key)
+	other, err = testStorageClass.Key(storageDevice(c, map[string]string{
+		"ID_SERIAL": "",
+		"ID_PATH":   "pci-0000:00:14.0-usb-0:2:1.0-scsi-0:0:0:0",
+	}))
+	c.Assert(err, IsNil)
+	c.Check(other, Not(Equals), noSerial)
+
+	// and on the class
+	class := testStorageClass
+	class.Name = "other"
+	other, err = class.Key(storageDevice(c, nil))
+	c.Assert(err, IsNil)
+	c.Check(other, Not(Equals), key)
+}
+
+func (s *deviceClassSuite) TestKeyMissingAttrs(c *C) {
+	key, err := testStorageClass.Key(storageDevice(c, map[string]string{"ID_SERIAL": "", "ID_PATH": ""}))
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, snap.HotplugKey(""))
+
+	key, err = testStorageClass.Key(storageDevice(c, map[string]string{"ID_VENDOR_ID": ""}))
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, snap.HotplugKey(""))
+
+	// no key attributes, the default key is used
+	class := testStorageClass
+	class.KeyAttrs = nil
+	key, err = class.Key(storageDevice(c, nil))
+	c.Assert(err, IsNil)
+	c.Check(key, Equals, snap.HotplugKey(""))
+}
+
+func (s *deviceClassSuite) TestKeyNoName(c *C) {
+	class := testStorageClass
+	class.Name = ""
+	_, err := class.Key(storageDevice(c, nil))
+	c.Assert(err, ErrorMatches, "internal error: device class without a name")
+}
+
+func (s *deviceClassSuite) TestSlotName(c *C) {
+	c.Check(testStorageClass.SlotName(storageDevice(c, nil)), Equals, "cruzerblade")
+	c.Check(testStorageClass.SlotName(storageDevice(c, map[string]string{
+		"ID_MODEL":               "",
+		"ID_MODEL_FROM_DATABASE": "Cruzer-Blade",
+	})), Equals, "cruzer-blade")
+	c.Check(testStorageClass.SlotName(storageDevice(c, map[string]string{"ID_MODEL": "__"})), Equals, "usb-storage")
+	c.Check(testStorageClass.SlotName(storageDevice(c, map[string]string{"ID_MODEL": ""})), Equals, "usb-storage")
+
+	class := testStorageClass
+	class.SlotNamePrefix = "disk"
+	c.Check(class.SlotName(storageDevice(c, nil)), Equals, "disk-cruzerblade")
+	c.Check(class.SlotName(storageDevice(c, map[string]string{"ID_MODEL": ""})), Equals, "usb-storage")
+}
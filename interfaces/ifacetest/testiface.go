@@ -25,6 +25,7 @@ import (
 	"github.com/snapcore/snapd/interfaces/dbus"
 	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/kmod"
+	"github.com/snapcore/snapd/interfaces/landlock"
 	"github.com/snapcore/snapd/interfaces/mount"
 	"github.com/snapcore/snapd/interfaces/polkit"
 	"github.com/snapcore/snapd/interfaces/seccomp"
@@ -111,6 +112,13 @@ type TestInterface struct {
 	PolkitConnectedSlotCallback func(spec *polkit.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
 	PolkitPermanentPlugCallback func(spec *polkit.Specification, plug *snap.PlugInfo) error
 	PolkitPermanentSlotCallback func(spec *polkit.Specification, slot *snap.SlotInfo) error
+
+	// Support for interacting with the landlock backend.
+
+	LandlockConnectedPlugCallback func(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	LandlockConnectedSlotCallback func(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	LandlockPermanentPlugCallback func(spec *landlock.Specification, plug *snap.PlugInfo) error
+	LandlockPermanentSlotCallback func(spec *landlock.Specification, slot *snap.SlotInfo) error
 }
 
 // TestHotplugInterface is an interface for various kinds of tests
@@ -450,6 +458,36 @@ func (t *TestInterface) PolkitPermanentPlug(spec *polkit.Specification, plug *sn
 	return nil
 }
 
+// Support for interacting with the landlock backend.
+
+func (t *TestInterface) LandlockConnectedPlug(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if t.LandlockConnectedPlugCallback != nil {
+		return t.LandlockConnectedPlugCallback(spec, plug, slot)
+	}
+	return nil
+}
+
+func (t *TestInterface) LandlockConnectedSlot(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	if t.LandlockConnectedSlotCallback != nil {
+		return t.LandlockConnectedSlotCallback(spec, plug, slot)
+	}
+	return nil
+}
+
+func (t *TestInterface) LandlockPermanentSlot(spec *landlock.Specification, slot *snap.SlotInfo) error {
+	if t.LandlockPermanentSlotCallback != nil {
+		return t.LandlockPermanentSlotCallback(spec, slot)
+	}
+	return nil
+}
+
+func (t *TestInterface) LandlockPermanentPlug(spec *landlock.Specification, plug *snap.PlugInfo) error {
+	if t.LandlockPermanentPlugCallback != nil {
+		return t.LandlockPermanentPlugCallback(spec, plug)
+	}
+	return nil
+}
+
 // Support for interacting with hotplug subsystem.
 
 func (t *TestHotplugInterface) HotplugKey(deviceInfo *hotplug.HotplugDeviceInfo) (snap.HotplugKey, error) {
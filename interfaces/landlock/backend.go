@@ -0,0 +1,197 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package landlock implements integration between snapd and snap-exec around
+// restricting the file system access of snap applications with Landlock.
+//
+// Landlock profiles are used with hybrid confinement, which approximates
+// strict confinement on systems where AppArmor is not available, or when the
+// experimental landlock feature is enabled, either as defense in depth
+// alongside AppArmor or as partial confinement without it. Profiles are derived
+// from a default template and from the connected interfaces. Snap-exec applies
+// the profile of the security tag it runs, if there is one, before executing
+// the application.
+package landlock
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timings"
+)
+
+// defaultTemplate contains the rules common to all strictly confined snaps.
+// Variables are expanded by snap-exec from the environment of the
+// application. Landlock only grants accesses, anything that is not listed
+// here or added by the interfaces connected to the snap is denied.
+const defaultTemplate = `# Read access to the programs and libraries of the base snap
+ro /bin
+ro /sbin
+ro /lib
+ro /lib32
+ro /lib64
+ro /libx32
+ro /usr
+# Read access to the system configuration, including the resolver
+# configuration /etc/resolv.conf usually links to
+ro /etc
+ro /run/systemd/resolve
+ro /run/resolvconf
+# Read access to the snap and to the graphics libraries of the host
+ro $SNAP
+ro /var/lib/snapd/lib
+# Write access to the data directories of the snap
+rw $SNAP_DATA
+rw $SNAP_COMMON
+rw $SNAP_USER_DATA
+rw $SNAP_USER_COMMON
+rw $XDG_RUNTIME_DIR
+# Private temporary directories and shared memory
+rw /tmp
+rw /var/tmp
+rw /dev/shm
+# Common devices, other devices are granted by interfaces
+rw /dev/null
+rw /dev/zero
+rw /dev/full
+ro /dev/random
+ro /dev/urandom
+rw /dev/tty
+rw /dev/ptmx
+rw /dev/pts
+# Information about the system and the process itself
+ro /proc
+rw /proc/self
+ro /sys/devices/system/cpu
+`
+
+// ProfileFile returns the path of the landlock profile of a security tag.
+func ProfileFile(securityTag string) string {
+	return filepath.Join(dirs.SnapLandlockDir, securityTag)
+}
+
+func profileGlob(snapName string) string {
+	return fmt.Sprintf("snap.%s.*", snapName)
+}
+
+// Backend is responsible for maintaining landlock profiles for snap-exec.
+type Backend struct{}
+
+// Initialize does nothing.
+func (b *Backend) Initialize(*interfaces.SecurityBackendOptions) error {
+	return nil
+}
+
+// Name returns the name of the backend.
+func (b *Backend) Name() interfaces.SecuritySystem {
+	return interfaces.SecurityLandlock
+}
+
+// Setup creates landlock profiles specific to a given snap.
+//
+// Landlock has no concept of a complain mode, profiles are only written for
+// snaps in strict confinement.
+func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) error {
+	snapName := snapInfo.InstanceName()
+	spec, err := repo.SnapSpecification(b.Name(), snapName)
+	if err != nil {
+		return fmt.Errorf("cannot obtain landlock specification for snap %q: %s", snapName, err)
+	}
+
+	var content map[string]osutil.FileState
+	if !opts.DevMode && (!opts.Classic || opts.JailMode) {
+		content = deriveContent(spec.(*Specification), snapInfo)
+	}
+
+	dir := dirs.SnapLandlockDir
+	if content != nil {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("cannot create directory for landlock profiles %q: %s", dir, err)
+		}
+	}
+	if _, _, err := osutil.EnsureDirState(dir, profileGlob(snapName), content); err != nil {
+		return fmt.Errorf("cannot synchronize landlock profiles for snap %q: %s", snapName, err)
+	}
+	return nil
+}
+
+// Remove removes landlock profiles of a given snap.
+func (b *Backend) Remove(snapName string) error {
+	if _, _, err := osutil.EnsureDirState(dirs.SnapLandlockDir, profileGlob(snapName), nil); err != nil {
+		return fmt.Errorf("cannot synchronize landlock profiles for snap %q: %s", snapName, err)
+	}
+	return nil
+}
+
+// deriveContent combines the default template with the rules collected from
+// all the interfaces affecting a given snap into a content map applicable to
+// EnsureDirState.
+func deriveContent(spec *Specification, snapInfo *snap.Info) map[string]osutil.FileState {
+	var securityTags []string
+	for _, app := range snapInfo.Apps {
+		securityTags = append(securityTags, app.SecurityTag())
+	}
+	for _, hook := range snapInfo.Hooks {
+		securityTags = append(securityTags, hook.SecurityTag())
+	}
+	if len(securityTags) == 0 {
+		return nil
+	}
+	sort.Strings(securityTags)
+
+	content := make(map[string]osutil.FileState, len(securityTags))
+	for _, tag := range securityTags {
+		var buf bytes.Buffer
+		buf.WriteString("# This file is automatically generated.\n")
+		buf.WriteString(defaultTemplate)
+		if rules := spec.Rules(tag); len(rules) > 0 {
+			buf.WriteString("# Rules from interfaces\n")
+			for _, rule := range rules {
+				buf.WriteString(rule)
+				buf.WriteByte('\n')
+			}
+		}
+		content[tag] = &osutil.MemoryFileState{
+			Content: buf.Bytes(),
+			Mode:    0644,
+		}
+	}
+	return content
+}
+
+func (b *Backend) NewSpecification() interfaces.Specification {
+	return &Specification{}
+}
+
+// SandboxFeatures returns the version of the landlock ABI used to restrict
+// the file system access of snaps.
+func (b *Backend) SandboxFeatures() []string {
+	if abi := landlock.ProbedABI(); abi > 0 {
+		return []string{fmt.Sprintf("abi:%d", abi)}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/landlock"
+	landlock_sandbox "github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type backendSuite struct {
+	ifacetest.BackendSuite
+}
+
+var _ = Suite(&backendSuite{})
+
+func (s *backendSuite) SetUpTest(c *C) {
+	s.Backend = &landlock.Backend{}
+	s.BackendSuite.SetUpTest(c)
+	c.Assert(s.Repo.AddBackend(s.Backend), IsNil)
+}
+
+func (s *backendSuite) TearDownTest(c *C) {
+	s.BackendSuite.TearDownTest(c)
+}
+
+func (s *backendSuite) TestName(c *C) {
+	c.Check(s.Backend.Name(), Equals, interfaces.SecurityLandlock)
+}
+
+func (s *backendSuite) TestInstallingSnapWritesProfiles(c *C) {
+	s.Iface.LandlockPermanentSlotCallback = func(spec *landlock.Specification, slot *snap.SlotInfo) error {
+		spec.AddRule("rw /run/samba")
+		return nil
+	}
+	for _, opts := range []interfaces.ConfinementOptions{{}, {JailMode: true}, {Classic: true, JailMode: true}} {
+		snapInfo := s.InstallSnap(c, opts, "", ifacetest.SambaYamlV1, 0)
+		profile := landlock.ProfileFile("snap.samba.smbd")
+		c.Check(profile, testutil.FileContains, "ro /usr\n")
+		c.Check(profile, Not(testutil.FileContains), "ro /\n")
+		c.Check(profile, testutil.FileContains, "# Rules from interfaces\nrw /run/samba\n")
+		s.RemoveSnap(c, snapInfo)
+		c.Check(profile, testutil.FileAbsent)
+	}
+}
+
+func (s *backendSuite) TestNoProfilesWithoutStrictConfinement(c *C) {
+	for _, opts := range []interfaces.ConfinementOptions{{DevMode: true}, {Classic: true}} {
+		snapInfo := s.InstallSnap(c, opts, "", ifacetest.SambaYamlV1, 0)
+		c.Check(landlock.ProfileFile("snap.samba.smbd"), testutil.FileAbsent)
+		s.RemoveSnap(c, snapInfo)
+	}
+}
+
+func (s *backendSuite) TestProfilesForHooks(c *C) {
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.HookYaml, 0)
+	c.Check(landlock.ProfileFile("snap.foo.hook.configure"), testutil.FilePresent)
+	s.RemoveSnap(c, snapInfo)
+	c.Check(landlock.ProfileFile("snap.foo.hook.configure"), testutil.FileAbsent)
+}
+
+func (s *backendSuite) TestUnexpectedProfilesRemoved(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapLandlockDir, 0755), IsNil)
+	stale := filepath.Join(dirs.SnapLandlockDir, "snap.samba.old-app")
+	c.Assert(ioutil.WriteFile(stale, nil, 0644), IsNil)
+	other := filepath.Join(dirs.SnapLandlockDir, "snap.samba_instance.smbd")
+	c.Assert(ioutil.WriteFile(other, nil, 0644), IsNil)
+
+	snapInfo := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1, 0)
+	c.Check(stale, testutil.FileAbsent)
+	s.RemoveSnap(c, snapInfo)
+	// profiles of other instances are left alone
+	c.Check(other, testutil.FilePresent)
+}
+
+func (s *backendSuite) TestSandboxFeatures(c *C) {
+	restore := landlock_sandbox.MockABI(0)
+	defer restore()
+	c.Check(s.Backend.SandboxFeatures(), HasLen, 0)
+
+	restore = landlock_sandbox.MockABI(2)
+	defer restore()
+	c.Check(s.Backend.SandboxFeatures(), DeepEquals, []string{"abi:2"})
+}
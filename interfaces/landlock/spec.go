@@ -0,0 +1,116 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock
+
+import (
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+)
+
+// Specification keeps the landlock rules of the security tags of a snap.
+type Specification struct {
+	// rules for each security tag
+	rules map[string][]string
+
+	securityTags []string
+}
+
+// AddRule adds a rule, eg. "rw /run/foo", to the landlock profiles of the
+// security tags in scope.
+func (spec *Specification) AddRule(rule string) {
+	if len(spec.securityTags) == 0 {
+		return
+	}
+	if spec.rules == nil {
+		spec.rules = make(map[string][]string)
+	}
+	for _, tag := range spec.securityTags {
+		spec.rules[tag] = append(spec.rules[tag], rule)
+		sort.Strings(spec.rules[tag])
+	}
+}
+
+// Rules returns the rules added to the given security tag.
+func (spec *Specification) Rules(securityTag string) []string {
+	return append([]string(nil), spec.rules[securityTag]...)
+}
+
+func (spec *Specification) setScope(securityTags []string) (restore func()) {
+	spec.securityTags = securityTags
+	return func() {
+		spec.securityTags = nil
+	}
+}
+
+// Implementation of methods required by interfaces.Specification
+
+// AddConnectedPlug records landlock-specific side-effects of having a connected plug.
+func (spec *Specification) AddConnectedPlug(iface interfaces.Interface, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	type definer interface {
+		LandlockConnectedPlug(spec *Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(plug.SecurityTags())
+		defer restore()
+		return iface.LandlockConnectedPlug(spec, plug, slot)
+	}
+	return nil
+}
+
+// AddConnectedSlot records landlock-specific side-effects of having a connected slot.
+func (spec *Specification) AddConnectedSlot(iface interfaces.Interface, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+	type definer interface {
+		LandlockConnectedSlot(spec *Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(slot.SecurityTags())
+		defer restore()
+		return iface.LandlockConnectedSlot(spec, plug, slot)
+	}
+	return nil
+}
+
+// AddPermanentPlug records landlock-specific side-effects of having a plug.
+func (spec *Specification) AddPermanentPlug(iface interfaces.Interface, plug *snap.PlugInfo) error {
+	type definer interface {
+		LandlockPermanentPlug(spec *Specification, plug *snap.PlugInfo) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(plug.SecurityTags())
+		defer restore()
+		return iface.LandlockPermanentPlug(spec, plug)
+	}
+	return nil
+}
+
+// AddPermanentSlot records landlock-specific side-effects of having a slot.
+func (spec *Specification) AddPermanentSlot(iface interfaces.Interface, slot *snap.SlotInfo) error {
+	type definer interface {
+		LandlockPermanentSlot(spec *Specification, slot *snap.SlotInfo) error
+	}
+	if iface, ok := iface.(definer); ok {
+		restore := spec.setScope(slot.SecurityTags())
+		defer restore()
+		return iface.LandlockPermanentSlot(spec, slot)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/interfaces/landlock"
+	"github.com/snapcore/snapd/snap"
+)
+
+type specSuite struct {
+	iface    *ifacetest.TestInterface
+	spec     *landlock.Specification
+	plugInfo *snap.PlugInfo
+	plug     *interfaces.ConnectedPlug
+	slotInfo *snap.SlotInfo
+	slot     *interfaces.ConnectedSlot
+}
+
+var _ = Suite(&specSuite{
+	iface: &ifacetest.TestInterface{
+		InterfaceName: "test",
+		LandlockConnectedPlugCallback: func(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			spec.AddRule("rw /connected-plug")
+			return nil
+		},
+		LandlockConnectedSlotCallback: func(spec *landlock.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			spec.AddRule("rw /connected-slot")
+			return nil
+		},
+		LandlockPermanentPlugCallback: func(spec *landlock.Specification, plug *snap.PlugInfo) error {
+			spec.AddRule("ro /permanent-plug")
+			return nil
+		},
+		LandlockPermanentSlotCallback: func(spec *landlock.Specification, slot *snap.SlotInfo) error {
+			spec.AddRule("ro /permanent-slot")
+			return nil
+		},
+	},
+	plugInfo: &snap.PlugInfo{
+		Snap:      &snap.Info{SuggestedName: "snap1"},
+		Name:      "name",
+		Interface: "test",
+		Apps: map[string]*snap.AppInfo{
+			"app1": {
+				Snap: &snap.Info{
+					SuggestedName: "snap1",
+				},
+				Name: "app1"}},
+	},
+	slotInfo: &snap.SlotInfo{
+		Snap:      &snap.Info{SuggestedName: "snap2"},
+		Name:      "name",
+		Interface: "test",
+		Apps: map[string]*snap.AppInfo{
+			"app2": {
+				Snap: &snap.Info{
+					SuggestedName: "snap2",
+				},
+				Name: "app2"}},
+	},
+})
+
+func (s *specSuite) SetUpTest(c *C) {
+	s.spec = &landlock.Specification{}
+	s.plug = interfaces.NewConnectedPlug(s.plugInfo, nil, nil)
+	s.slot = interfaces.NewConnectedSlot(s.slotInfo, nil, nil)
+}
+
+// The spec.Specification can be used through the interfaces.Specification interface
+func (s *specSuite) TestSpecificationIface(c *C) {
+	var r interfaces.Specification = s.spec
+	c.Assert(r.AddConnectedPlug(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(r.AddConnectedSlot(s.iface, s.plug, s.slot), IsNil)
+	c.Assert(r.AddPermanentPlug(s.iface, s.plugInfo), IsNil)
+	c.Assert(r.AddPermanentSlot(s.iface, s.slotInfo), IsNil)
+	c.Check(s.spec.Rules("snap.snap1.app1"), DeepEquals, []string{"ro /permanent-plug", "rw /connected-plug"})
+	c.Check(s.spec.Rules("snap.snap2.app2"), DeepEquals, []string{"ro /permanent-slot", "rw /connected-slot"})
+	c.Check(s.spec.Rules("snap.snap1.other"), HasLen, 0)
+}
+
+func (s *specSuite) TestAddRuleOutOfScope(c *C) {
+	s.spec.AddRule("rw /foo")
+	c.Check(s.spec.Rules("snap.snap1.app1"), HasLen, 0)
+}
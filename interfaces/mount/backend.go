@@ -64,6 +64,7 @@ func (b *Backend) Setup(snapInfo *snap.Info, confinement interfaces.ConfinementO
 		return fmt.Errorf("cannot obtain mount security snippets for snap %q: %s", snapName, err)
 	}
 	spec.(*Specification).AddOvername(snapInfo)
+	spec.(*Specification).AddPrivateTmpfs(snapInfo)
 	spec.(*Specification).AddLayout(snapInfo)
 	spec.(*Specification).AddExtraLayouts(confinement.ExtraLayouts)
 	content := deriveContent(spec.(*Specification), snapInfo)
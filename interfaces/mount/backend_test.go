@@ -220,6 +220,22 @@ func (s *backendSuite) TestParallelInstanceSetup(c *C) {
 	c.Check(fn, testutil.FileEquals, userFsEntry.String()+"\n")
 }
 
+func (s *backendSuite) TestSetupPrivateTmpfs(c *C) {
+	const snapYaml = `name: snap-name
+version: 1
+private-tmpfs:
+    /tmp: 64MB
+apps:
+    app1:
+`
+	tmpEntry := osutil.MountEntry{Name: "tmpfs", Dir: "/tmp", Type: "tmpfs", Options: []string{"nosuid", "nodev", "size=64000000", "mode=1777"}}
+
+	s.InstallSnap(c, interfaces.ConfinementOptions{}, "", snapYaml, 0)
+
+	fn := filepath.Join(dirs.SnapMountPolicyDir, "snap.snap-name.fstab")
+	c.Check(fn, testutil.FileEquals, tmpEntry.String()+"\n")
+}
+
 func (s *backendSuite) TestSandboxFeatures(c *C) {
 	restore := cgroup.MockVersion(cgroup.V1, nil)
 	defer restore()
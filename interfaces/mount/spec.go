@@ -42,10 +42,11 @@ type Specification struct {
 	// the source of given mount entry and MountEntry.Dir. See
 	// cmd/snap-update-ns/sorting.go for details.
 
-	layout   []osutil.MountEntry
-	general  []osutil.MountEntry
-	user     []osutil.MountEntry
-	overname []osutil.MountEntry
+	layout       []osutil.MountEntry
+	general      []osutil.MountEntry
+	user         []osutil.MountEntry
+	overname     []osutil.MountEntry
+	privateTmpfs []osutil.MountEntry
 }
 
 // AddMountEntry adds a new mount entry.
@@ -152,11 +153,12 @@ func (spec *Specification) AddExtraLayouts(layouts []snap.Layout) {
 
 // MountEntries returns a copy of the added mount entries.
 func (spec *Specification) MountEntries() []osutil.MountEntry {
-	result := make([]osutil.MountEntry, 0, len(spec.overname)+len(spec.layout)+len(spec.general))
+	result := make([]osutil.MountEntry, 0, len(spec.overname)+len(spec.privateTmpfs)+len(spec.layout)+len(spec.general))
 	// overname is the mappings that were added to support parallel
 	// installation of snaps and must come first, as they establish the base
 	// namespace for any further operations
 	result = append(result, spec.overname...)
+	result = append(result, spec.privateTmpfs...)
 	result = append(result, spec.layout...)
 	result = append(result, spec.general...)
 	return unclashMountEntries(result)
@@ -355,3 +357,30 @@ func (spec *Specification) AddOvername(info *snap.Info) {
 		Options: []string{"rbind", osutil.XSnapdOriginOvername()},
 	})
 }
+
+// AddPrivateTmpfs adds mount entries for the size limited tmpfs of the
+// snap.
+//
+// The tmpfs is mounted over the private /tmp directory bind mounted by
+// snap-confine, or over /dev/shm, so that the apps of the snap cannot
+// exhaust the memory of the system by filling them.
+func (spec *Specification) AddPrivateTmpfs(info *snap.Info) {
+	paths := make([]string, 0, len(info.PrivateTmpfsSizes))
+	for path := range info.PrivateTmpfsSizes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		spec.privateTmpfs = append(spec.privateTmpfs, osutil.MountEntry{
+			Name: "tmpfs",
+			Dir:  path,
+			Type: "tmpfs",
+			Options: []string{
+				"nosuid", "nodev",
+				fmt.Sprintf("size=%d", info.PrivateTmpfsSizes[path]),
+				"mode=1777",
+			},
+		})
+	}
+}
@@ -286,3 +286,25 @@ func (s *specSuite) TestParallelInstanceMountEntriesReal(c *C) {
 	})
 	c.Assert(s.spec.UserMountEntries(), HasLen, 0)
 }
+
+func (s *specSuite) TestPrivateTmpfsMountEntries(c *C) {
+	snapInfo := &snap.Info{
+		SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(42)},
+		PrivateTmpfsSizes: map[string]int64{
+			"/tmp":     64 * 1000 * 1000,
+			"/dev/shm": 1000 * 1000,
+		},
+	}
+	s.spec.AddPrivateTmpfs(snapInfo)
+	c.Assert(s.spec.MountEntries(), DeepEquals, []osutil.MountEntry{
+		{Name: "tmpfs", Dir: "/dev/shm", Type: "tmpfs", Options: []string{"nosuid", "nodev", "size=1000000", "mode=1777"}},
+		{Name: "tmpfs", Dir: "/tmp", Type: "tmpfs", Options: []string{"nosuid", "nodev", "size=64000000", "mode=1777"}},
+	})
+	c.Assert(s.spec.UserMountEntries(), HasLen, 0)
+}
+
+func (s *specSuite) TestPrivateTmpfsMountEntriesNone(c *C) {
+	snapInfo := &snap.Info{SideInfo: snap.SideInfo{RealName: "foo", Revision: snap.R(42)}}
+	s.spec.AddPrivateTmpfs(snapInfo)
+	c.Assert(s.spec.MountEntries(), HasLen, 0)
+}
@@ -770,6 +770,7 @@ var (
 		"autopilot-introspection":   {"core"},
 		"avahi-control":             {"app", "core"},
 		"avahi-observe":             {"app", "core"},
+		"block-device-raw":          {"core", "gadget"},
 		"bluez":                     {"app", "core"},
 		"bool-file":                 {"core", "gadget"},
 		"browser-support":           {"core"},
@@ -782,6 +783,7 @@ var (
 		"desktop-launch":            {"core"},
 		"dsp":                       {"core", "gadget"},
 		"empty":                     {"app"},
+		"fpga-manager":              {"core", "gadget"},
 		"fwupd":                     {"app", "core"},
 		"gpio":                      {"core", "gadget"},
 		"gpio-control":              {"core"},
@@ -805,11 +807,13 @@ var (
 		"network-manager":           {"app", "core"},
 		"network-manager-observe":   {"app", "core"},
 		"network-status":            {"core"},
+		"nvme-admin":                {"core", "gadget"},
 		"ofono":                     {"app", "core"},
 		"online-accounts-service":   {"app"},
 		"power-control":             {"core"},
 		"ppp":                       {"core"},
 		"pulseaudio":                {"app", "core"},
+		"ptp-clock":                 {"core", "gadget"},
 		"pwm":                       {"core", "gadget"},
 		"qualcomm-ipc-router":       {"core"},
 		"raw-volume":                {"core", "gadget"},
@@ -993,6 +997,7 @@ func (s *baseDeclSuite) TestConnection(c *C) {
 	// connecting with these interfaces needs to be allowed on
 	// case-by-case basis
 	noconnect := map[string]bool{
+		"block-device-raw":          true,
 		"content":                   true,
 		"cups":                      true,
 		"custom-device":             true,
@@ -1004,6 +1009,7 @@ func (s *baseDeclSuite) TestConnection(c *C) {
 		"maliit":                    true,
 		"microceph":                 true,
 		"mir":                       true,
+		"nvme-admin":                true,
 		"online-accounts-service":   true,
 		"posix-mq":                  true,
 		"raw-volume":                true,
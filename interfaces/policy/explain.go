@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+// ConstraintsOutcome tells whether any of the alternative constraints
+// of a rule matched a candidate connection.
+type ConstraintsOutcome struct {
+	Matched bool `json:"matched"`
+	// Mismatches are the reasons why the alternatives before the
+	// matching one, or all of them, did not match.
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+// RuleExplanation explains how the rule of a declaration for an
+// interface was evaluated for a candidate connection.
+type RuleExplanation struct {
+	// Declaration is either "snap-declaration" or "base-declaration".
+	Declaration string `json:"declaration"`
+	// Side is either "plug" or "slot".
+	Side string `json:"side"`
+	// Snap is the snap the snap-declaration is for.
+	Snap  string             `json:"snap,omitempty"`
+	Deny  ConstraintsOutcome `json:"deny"`
+	Allow ConstraintsOutcome `json:"allow"`
+	// Decisive is set for the rule that decided the outcome, that is
+	// the first one in order of precedence.
+	Decisive bool `json:"decisive,omitempty"`
+}
+
+// Allowed returns whether the rule allows the candidate connection.
+func (r *RuleExplanation) Allowed() bool {
+	return !r.Deny.Matched && r.Allow.Matched
+}
+
+// Explanation explains the outcome of checking a candidate connection
+// against the declarations.
+type Explanation struct {
+	// Kind is either "connection" or "auto-connection".
+	Kind    string `json:"kind"`
+	Allowed bool   `json:"allowed"`
+	// Error is why the candidate connection is not allowed.
+	Error string `json:"error,omitempty"`
+	// Rules are the rules for the interface, in order of precedence.
+	Rules []*RuleExplanation `json:"rules,omitempty"`
+	// Hint tells what would need to change for the candidate
+	// connection to be allowed.
+	Hint string `json:"hint,omitempty"`
+}
+
+func explainPlugConnectionAltConstraints(connc *ConnectCandidate, altConstraints []*asserts.PlugConnectionConstraints) ConstraintsOutcome {
+	var outcome ConstraintsOutcome
+	for _, constraints := range altConstraints {
+		if err := checkPlugConnectionConstraints1(connc, constraints); err != nil {
+			outcome.Mismatches = append(outcome.Mismatches, err.Error())
+			continue
+		}
+		outcome.Matched = true
+		break
+	}
+	return outcome
+}
+
+func explainSlotConnectionAltConstraints(connc *ConnectCandidate, altConstraints []*asserts.SlotConnectionConstraints) ConstraintsOutcome {
+	var outcome ConstraintsOutcome
+	for _, constraints := range altConstraints {
+		if err := checkSlotConnectionConstraints1(connc, constraints); err != nil {
+			outcome.Mismatches = append(outcome.Mismatches, err.Error())
+			continue
+		}
+		outcome.Matched = true
+		break
+	}
+	return outcome
+}
+
+func (connc *ConnectCandidate) explainPlugRule(kind string, rule *asserts.PlugRule) (deny, allow ConstraintsOutcome) {
+	denyConst := rule.DenyConnection
+	allowConst := rule.AllowConnection
+	if kind == "auto-connection" {
+		denyConst = rule.DenyAutoConnection
+		allowConst = rule.AllowAutoConnection
+	}
+	return explainPlugConnectionAltConstraints(connc, denyConst), explainPlugConnectionAltConstraints(connc, allowConst)
+}
+
+func (connc *ConnectCandidate) explainSlotRule(kind string, rule *asserts.SlotRule) (deny, allow ConstraintsOutcome) {
+	denyConst := rule.DenyConnection
+	allowConst := rule.AllowConnection
+	if kind == "auto-connection" {
+		denyConst = rule.DenyAutoConnection
+		allowConst = rule.AllowAutoConnection
+	}
+	return explainSlotConnectionAltConstraints(connc, denyConst), explainSlotConnectionAltConstraints(connc, allowConst)
+}
+
+// Explain checks whether the candidate connection is allowed, as
+// Check does for "connection" and CheckAutoConnect does for
+// "auto-connection", and explains the outcome by evaluating all the
+// rules of the declarations for the interface, not only the decisive one.
+func (connc *ConnectCandidate) Explain(kind string) (*Explanation, error) {
+	if kind != "connection" && kind != "auto-connection" {
+		return nil, fmt.Errorf("internal error: cannot explain %q", kind)
+	}
+	baseDecl := connc.BaseDeclaration
+	if baseDecl == nil {
+		return nil, fmt.Errorf("internal error: improperly initialized ConnectCandidate")
+	}
+
+	expl := &Explanation{Kind: kind}
+	if _, err := connc.check(kind); err != nil {
+		expl.Error = err.Error()
+	} else {
+		expl.Allowed = true
+	}
+
+	iface := connc.Plug.Interface()
+	if connc.Slot.Interface() != iface {
+		// nothing to evaluate
+		return expl, nil
+	}
+
+	if plugDecl := connc.PlugSnapDeclaration; plugDecl != nil {
+		if rule := plugDecl.PlugRule(iface); rule != nil {
+			deny, allow := connc.explainPlugRule(kind, rule)
+			expl.Rules = append(expl.Rules, &RuleExplanation{
+				Declaration: "snap-declaration",
+				Side:        "plug",
+				Snap:        plugDecl.SnapName(),
+				Deny:        deny,
+				Allow:       allow,
+			})
+		}
+	}
+	if slotDecl := connc.SlotSnapDeclaration; slotDecl != nil {
+		if rule := slotDecl.SlotRule(iface); rule != nil {
+			deny, allow := connc.explainSlotRule(kind, rule)
+			expl.Rules = append(expl.Rules, &RuleExplanation{
+				Declaration: "snap-declaration",
+				Side:        "slot",
+				Snap:        slotDecl.SnapName(),
+				Deny:        deny,
+				Allow:       allow,
+			})
+		}
+	}
+	if rule := baseDecl.PlugRule(iface); rule != nil {
+		deny, allow := connc.explainPlugRule(kind, rule)
+		expl.Rules = append(expl.Rules, &RuleExplanation{
+			Declaration: "base-declaration",
+			Side:        "plug",
+			Deny:        deny,
+			Allow:       allow,
+		})
+	}
+	if rule := baseDecl.SlotRule(iface); rule != nil {
+		deny, allow := connc.explainSlotRule(kind, rule)
+		expl.Rules = append(expl.Rules, &RuleExplanation{
+			Declaration: "base-declaration",
+			Side:        "slot",
+			Deny:        deny,
+			Allow:       allow,
+		})
+	}
+
+	if len(expl.Rules) == 0 {
+		return expl, nil
+	}
+	decisive := expl.Rules[0]
+	decisive.Decisive = true
+	if !expl.Allowed {
+		expl.Hint = explainHint(kind, decisive)
+	}
+	return expl, nil
+}
+
+func explainHint(kind string, decisive *RuleExplanation) string {
+	if decisive.Declaration == "snap-declaration" {
+		return fmt.Sprintf("the %s rule in the snap-declaration of %q would need to allow the %s, snap-declarations are issued by the store",
+			decisive.Side, decisive.Snap, kind)
+	}
+	return fmt.Sprintf("the plug or slot snap would need a snap-declaration rule granting the %s, snap-declarations are issued by the store", kind)
+}
@@ -0,0 +1,158 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package policy_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/policy"
+)
+
+func (s *policySuite) TestExplainNoRules(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:            interfaces.NewConnectedPlug(s.plugSnap.Plugs["random"], nil, nil),
+		Slot:            interfaces.NewConnectedSlot(s.slotSnap.Slots["random"], nil, nil),
+		BaseDeclaration: s.baseDecl,
+	}
+
+	expl, err := cand.Explain("connection")
+	c.Assert(err, IsNil)
+	c.Check(expl, DeepEquals, &policy.Explanation{
+		Kind:    "connection",
+		Allowed: true,
+	})
+}
+
+func (s *policySuite) TestExplainBaseDeclDenied(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:            interfaces.NewConnectedPlug(s.plugSnap.Plugs["base-plug-deny"], nil, nil),
+		Slot:            interfaces.NewConnectedSlot(s.slotSnap.Slots["base-plug-deny"], nil, nil),
+		BaseDeclaration: s.baseDecl,
+	}
+
+	expl, err := cand.Explain("connection")
+	c.Assert(err, IsNil)
+	c.Check(expl.Allowed, Equals, false)
+	c.Check(expl.Error, Equals, `connection denied by plug rule of interface "base-plug-deny"`)
+	c.Assert(expl.Rules, HasLen, 1)
+	rule := expl.Rules[0]
+	c.Check(rule.Declaration, Equals, "base-declaration")
+	c.Check(rule.Side, Equals, "plug")
+	c.Check(rule.Decisive, Equals, true)
+	c.Check(rule.Deny.Matched, Equals, true)
+	c.Check(rule.Allowed(), Equals, false)
+	c.Check(expl.Hint, Matches, `the plug or slot snap would need a snap-declaration rule granting the connection, .*`)
+
+	// auto-connection is not covered by the deny-connection
+	expl, err = cand.Explain("auto-connection")
+	c.Assert(err, IsNil)
+	c.Check(expl.Allowed, Equals, true)
+	c.Check(expl.Hint, Equals, "")
+	c.Assert(expl.Rules, HasLen, 1)
+	c.Check(expl.Rules[0].Allowed(), Equals, true)
+}
+
+func (s *policySuite) TestExplainAlternativesMismatches(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:            interfaces.NewConnectedPlug(s.plugSnap.Plugs["plug-or-p1-s2"], nil, nil),
+		Slot:            interfaces.NewConnectedSlot(s.slotSnap.Slots["plug-or-p1-s2"], nil, nil),
+		BaseDeclaration: s.baseDecl,
+	}
+
+	expl, err := cand.Explain("connection")
+	c.Assert(err, IsNil)
+	c.Check(expl.Allowed, Equals, false)
+	c.Check(expl.Error, Matches, `connection not allowed by plug rule of interface "plug-or"`)
+	c.Assert(expl.Rules, HasLen, 1)
+	rule := expl.Rules[0]
+	c.Check(rule.Deny.Matched, Equals, false)
+	c.Check(rule.Allow.Matched, Equals, false)
+	// one reason per alternative
+	c.Assert(rule.Allow.Mismatches, HasLen, 2)
+	c.Check(rule.Allow.Mismatches[0], Matches, `attribute "s" value "S2" does not match .*`)
+	c.Check(rule.Allow.Mismatches[1], Matches, `attribute "p" value "P1" does not match .*`)
+}
+
+func (s *policySuite) TestExplainSnapDeclPrecedence(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:                interfaces.NewConnectedPlug(s.plugSnap.Plugs["base-deny-snap-slot-allow"], nil, nil),
+		Slot:                interfaces.NewConnectedSlot(s.slotSnap.Slots["base-deny-snap-slot-allow"], nil, nil),
+		PlugSnapDeclaration: s.plugDecl,
+		SlotSnapDeclaration: s.slotDecl,
+		BaseDeclaration:     s.baseDecl,
+	}
+
+	expl, err := cand.Explain("connection")
+	c.Assert(err, IsNil)
+	c.Check(expl.Allowed, Equals, true)
+	c.Assert(expl.Rules, HasLen, 2)
+	c.Check(expl.Rules[0].Declaration, Equals, "snap-declaration")
+	c.Check(expl.Rules[0].Side, Equals, "slot")
+	c.Check(expl.Rules[0].Snap, Equals, "slot-snap")
+	c.Check(expl.Rules[0].Decisive, Equals, true)
+	c.Check(expl.Rules[0].Allowed(), Equals, true)
+	// the base-declaration would not allow it on its own
+	c.Check(expl.Rules[1].Declaration, Equals, "base-declaration")
+	c.Check(expl.Rules[1].Decisive, Equals, false)
+	c.Check(expl.Rules[1].Allowed(), Equals, false)
+}
+
+func (s *policySuite) TestExplainSnapDeclNotAllowed(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:                interfaces.NewConnectedPlug(s.plugSnap.Plugs["snap-slot-not-allow"], nil, nil),
+		Slot:                interfaces.NewConnectedSlot(s.slotSnap.Slots["snap-slot-not-allow"], nil, nil),
+		PlugSnapDeclaration: s.plugDecl,
+		SlotSnapDeclaration: s.slotDecl,
+		BaseDeclaration:     s.baseDecl,
+	}
+
+	expl, err := cand.Explain("connection")
+	c.Assert(err, IsNil)
+	c.Check(expl.Allowed, Equals, false)
+	c.Check(expl.Hint, Equals, `the slot rule in the snap-declaration of "slot-snap" would need to allow the connection, snap-declarations are issued by the store`)
+}
+
+func (s *policySuite) TestExplainErrors(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug: interfaces.NewConnectedPlug(s.plugSnap.Plugs["random"], nil, nil),
+		Slot: interfaces.NewConnectedSlot(s.slotSnap.Slots["random"], nil, nil),
+	}
+	_, err := cand.Explain("connection")
+	c.Check(err, ErrorMatches, "internal error: improperly initialized ConnectCandidate")
+
+	cand.BaseDeclaration = s.baseDecl
+	_, err = cand.Explain("installation")
+	c.Check(err, ErrorMatches, `internal error: cannot explain "installation"`)
+}
+
+func (s *policySuite) TestExplainInterfaceMismatch(c *C) {
+	cand := policy.ConnectCandidate{
+		Plug:            interfaces.NewConnectedPlug(s.plugSnap.Plugs["mismatchy"], nil, nil),
+		Slot:            interfaces.NewConnectedSlot(s.slotSnap.Slots["mismatchy"], nil, nil),
+		BaseDeclaration: s.baseDecl,
+	}
+
+	expl, err := cand.Explain("connection")
+	c.Assert(err, IsNil)
+	c.Check(expl.Allowed, Equals, false)
+	c.Check(expl.Error, Equals, `cannot connect mismatched plug interface "bar" to slot interface "baz"`)
+	c.Check(expl.Rules, HasLen, 0)
+}
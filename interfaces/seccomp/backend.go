@@ -26,7 +26,9 @@
 // kernel for the duration of the execution of the process.
 //
 // There is no binary cache for seccomp, each time the launcher starts an
-// application the profile is parsed and re-compiled.
+// application the profile is parsed and re-compiled. Snapd however keeps a
+// cache of the compiled profiles to avoid compiling identical profiles more
+// than once.
 //
 // The actual profiles are stored in /var/lib/snappy/seccomp/bpf/*.{src,bin}.
 // This directory is hard-coded in snap-confine.
@@ -43,6 +45,7 @@ import (
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox/apparmor"
@@ -286,7 +289,14 @@ func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions,
 		}
 	}
 
-	return parallelCompile(b.snapSeccomp, changed)
+	compiler := &cachingCompiler{Compiler: b.snapSeccomp, versionInfo: b.versionInfo}
+	if err := parallelCompile(compiler, changed); err != nil {
+		return err
+	}
+	if err := pruneProfileCache(); err != nil {
+		logger.Noticef("cannot prune seccomp profile cache: %v", err)
+	}
+	return nil
 }
 
 // Remove removes seccomp profiles of a given snap.
@@ -296,6 +306,9 @@ func (b *Backend) Remove(snapName string) error {
 	if err != nil {
 		return fmt.Errorf("cannot synchronize security files for snap %q: %s", snapName, err)
 	}
+	if err := pruneProfileCache(); err != nil {
+		logger.Noticef("cannot prune seccomp profile cache: %v", err)
+	}
 	return nil
 }
 
@@ -312,6 +325,12 @@ func (b *Backend) deriveContent(spec *Specification, opts interfaces.Confinement
 	// template
 	addSocketcall := requiresSocketcall(snapInfo.Base)
 
+	// The base snap may need more than the default template allows
+	basePolicy, err := basePolicySnippet(opts)
+	if err != nil {
+		return nil, err
+	}
+
 	var uidGidChownSyscalls bytes.Buffer
 	if len(snapInfo.SystemUsernames) == 0 {
 		uidGidChownSyscalls.WriteString(barePrivDropSyscalls)
@@ -334,7 +353,7 @@ func (b *Backend) deriveContent(spec *Specification, opts interfaces.Confinement
 
 		path := securityTag + ".src"
 		content[path] = &osutil.MemoryFileState{
-			Content: generateContent(opts, spec.SnippetForTag(securityTag), addSocketcall, b.versionInfo, uidGidChownSyscalls.String()),
+			Content: generateContent(opts, spec.SnippetForTag(securityTag), basePolicy, addSocketcall, b.versionInfo, uidGidChownSyscalls.String()),
 			Mode:    0644,
 		}
 	}
@@ -345,7 +364,7 @@ func (b *Backend) deriveContent(spec *Specification, opts interfaces.Confinement
 		securityTag := appInfo.SecurityTag()
 		path := securityTag + ".src"
 		content[path] = &osutil.MemoryFileState{
-			Content: generateContent(opts, spec.SnippetForTag(securityTag), addSocketcall, b.versionInfo, uidGidChownSyscalls.String()),
+			Content: generateContent(opts, spec.SnippetForTag(securityTag), basePolicy, addSocketcall, b.versionInfo, uidGidChownSyscalls.String()),
 			Mode:    0644,
 		}
 	}
@@ -353,7 +372,7 @@ func (b *Backend) deriveContent(spec *Specification, opts interfaces.Confinement
 	return content, nil
 }
 
-func generateContent(opts interfaces.ConfinementOptions, snippetForTag string, addSocketcall bool, versionInfo seccomp.VersionInfo, uidGidChownSyscalls string) []byte {
+func generateContent(opts interfaces.ConfinementOptions, snippetForTag, basePolicy string, addSocketcall bool, versionInfo seccomp.VersionInfo, uidGidChownSyscalls string) []byte {
 	var buffer bytes.Buffer
 
 	if versionInfo != "" {
@@ -374,6 +393,7 @@ func generateContent(opts interfaces.ConfinementOptions, snippetForTag string, a
 	}
 
 	buffer.Write(defaultTemplate)
+	buffer.WriteString(basePolicy)
 	buffer.WriteString(snippetForTag)
 	buffer.WriteString(uidGidChownSyscalls)
 
@@ -388,6 +408,26 @@ func generateContent(opts interfaces.ConfinementOptions, snippetForTag string, a
 		buffer.WriteString(socketcallSyscallDeprecated)
 	}
 
+	if len(opts.DeniedSyscalls) > 0 {
+		return removeDeniedSyscalls(buffer.Bytes(), opts.DeniedSyscalls)
+	}
+
+	return buffer.Bytes()
+}
+
+// removeDeniedSyscalls comments out all the rules of the given seccomp profile
+// that allow any of the denied system calls. As the profile is a list of
+// allowed system calls, this can only ever tighten the confinement.
+func removeDeniedSyscalls(content []byte, denied []string) []byte {
+	var buffer bytes.Buffer
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strutil.ListContains(denied, fields[0]) {
+			buffer.WriteString("# denied by the brand of the device: ")
+		}
+		buffer.WriteString(line)
+	}
+	fmt.Fprintf(&buffer, "# denied system calls: %s\n", strings.Join(denied, " "))
 	return buffer.Bytes()
 }
 
@@ -28,6 +28,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 
 	. "gopkg.in/check.v1"
@@ -314,6 +315,14 @@ var combineSnippetsScenarios = []combineSnippetsScenario{{
 	opts:    interfaces.ConfinementOptions{Classic: true, JailMode: true},
 	snippet: "snippet",
 	content: "default\nsnippet\n",
+}, {
+	opts:    interfaces.ConfinementOptions{DeniedSyscalls: []string{"snippet"}},
+	snippet: "snippet",
+	content: "default\n# denied by the brand of the device: snippet\n# denied system calls: snippet\n",
+}, {
+	opts:    interfaces.ConfinementOptions{DeniedSyscalls: []string{"other", "default"}},
+	snippet: "snippet",
+	content: "# denied by the brand of the device: default\nsnippet\n# denied system calls: other default\n",
 }}
 
 func (s *backendSuite) TestCombineSnippets(c *C) {
@@ -385,6 +394,72 @@ func (s *backendSuite) TestCombineSnippetsOrdering(c *C) {
 	c.Check(stat.Mode(), Equals, os.FileMode(0644))
 }
 
+func (s *backendSuite) TestDeniedSyscallsAreRemoved(c *C) {
+	restore := apparmor_sandbox.MockLevel(apparmor_sandbox.Full)
+	defer restore()
+	restore = seccomp.MockRequiresSocketcall(func(string) bool { return false })
+	defer restore()
+
+	s.Iface.SecCompPermanentSlotCallback = func(spec *seccomp.Specification, slot *snap.SlotInfo) error {
+		spec.AddSnippet("io_uring_setup\nio_uring_enter\nsocket AF_NETLINK - NETLINK_ROUTE")
+		return nil
+	}
+
+	// NOTE: we don't call seccomp.MockTemplate()
+	opts := interfaces.ConfinementOptions{DeniedSyscalls: []string{"io_uring_setup", "socket"}}
+	s.InstallSnap(c, opts, "", ifacetest.SambaYamlV1, 0)
+	profile := filepath.Join(dirs.SnapSeccompDir, "snap.samba.smbd")
+	c.Check(profile+".src", testutil.FileContains, "# denied by the brand of the device: io_uring_setup\nio_uring_enter\n")
+	c.Check(profile+".src", testutil.FileContains, "# denied by the brand of the device: socket AF_NETLINK - NETLINK_ROUTE\n")
+	c.Check(profile+".src", testutil.FileContains, "# denied by the brand of the device: socket AF_UNIX\n")
+	c.Check(profile+".src", testutil.FileMatches, `(?s).*\nsocketpair\n.*`)
+	c.Check(profile+".src", testutil.FileMatches, `(?s).*\n# denied system calls: io_uring_setup socket\n$`)
+}
+
+func (s *backendSuite) TestBasePolicyIsAdded(c *C) {
+	restore := seccomp.MockTemplate([]byte("default\n"))
+	defer restore()
+	restore = seccomp.MockRequiresSocketcall(func(string) bool { return false })
+	defer restore()
+
+	basePolicyDir := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(basePolicyDir, "seccomp"), []byte("# needed by the new libc\nclone3\n\n  rseq\n"), 0644)
+	c.Assert(err, IsNil)
+
+	// the policy of the brand of the device still wins
+	opts := interfaces.ConfinementOptions{BasePolicyDir: basePolicyDir, DeniedSyscalls: []string{"rseq"}}
+	s.InstallSnap(c, opts, "", ifacetest.SambaYamlV1, 0)
+	profile := filepath.Join(dirs.SnapSeccompDir, "snap.samba.smbd")
+	c.Check(profile+".src", testutil.FileContains, "default\n# Adjustments of the template shipped by the base snap\nclone3\n# denied by the brand of the device: rseq\n")
+}
+
+func (s *backendSuite) TestBasePolicyIsValidated(c *C) {
+	restore := seccomp.MockTemplate([]byte("default\n"))
+	defer restore()
+
+	basePolicyDir := c.MkDir()
+	snapInfo := snaptest.MockInfo(c, ifacetest.SambaYamlV1, nil)
+	opts := interfaces.ConfinementOptions{BasePolicyDir: basePolicyDir}
+	for _, t := range []struct {
+		policy string
+		err    string
+	}{
+		{"clone3\nsocket AF_NETLINK - NETLINK_ROUTE\n", `cannot use seccomp policy of base snap: line 2: only system call names are allowed: "socket AF_NETLINK - NETLINK_ROUTE"`},
+		{"@unrestricted\n", `cannot use seccomp policy of base snap: line 1: only system call names are allowed: "@unrestricted"`},
+		{"# comment\nmount\n", `cannot use seccomp policy of base snap: line 2: system call "mount" cannot be allowed by a base`},
+		{"clone3\nchown\n", `cannot use seccomp policy of base snap: line 2: system call "chown" cannot be allowed by a base`},
+		{"mknod\n", `cannot use seccomp policy of base snap: line 1: system call "mknod" cannot be allowed by a base`},
+		{"setuid\n", `cannot use seccomp policy of base snap: line 1: system call "setuid" cannot be allowed by a base`},
+		{"socket\n", `cannot use seccomp policy of base snap: line 1: system call "socket" cannot be allowed by a base`},
+		{strings.Repeat("a", 64*1024+1), `cannot use base seccomp policy ".*/seccomp": size 65537 exceeds the limit of 65536 bytes`},
+	} {
+		err := ioutil.WriteFile(filepath.Join(basePolicyDir, "seccomp"), []byte(t.policy), 0644)
+		c.Assert(err, IsNil)
+		err = s.Backend.Setup(snapInfo, opts, s.Repo, s.meas)
+		c.Check(err, ErrorMatches, `cannot obtain expected security files for snap "samba": `+t.err)
+	}
+}
+
 func (s *backendSuite) TestBindIsAddedForNonFullApparmorSystems(c *C) {
 	restore := apparmor_sandbox.MockLevel(apparmor_sandbox.Partial)
 	defer restore()
@@ -874,6 +949,85 @@ fi
 
 }
 
+func (s *backendSuite) TestCompiledProfilesCache(c *C) {
+	restore := apparmor_sandbox.MockLevel(apparmor_sandbox.Full)
+	defer restore()
+	restore = seccomp_sandbox.MockActions([]string{"log"})
+	defer restore()
+	restore = seccomp.MockRequiresSocketcall(func(string) bool { return false })
+	defer restore()
+
+	// NOTE: replace the real template with a shorter variant
+	restore = seccomp.MockTemplate([]byte("\ndefault\n"))
+	defer restore()
+
+	snapSeccomp := testutil.MockLockedCommand(c, filepath.Join(dirs.DistroLibExecDir, "snap-seccomp"), `
+if [ "$1" = "version-info" ]; then
+    echo "abcdef 1.2.3 1234abcd -"
+elif [ "$1" = "compile" ]; then
+    echo "compiled" > "$3"
+fi`)
+	defer snapSeccomp.Restore()
+	err := s.Backend.Initialize(nil)
+	c.Assert(err, IsNil)
+	snapSeccomp.ForgetCalls()
+
+	smbdProfile := filepath.Join(dirs.SnapSeccompDir, "snap.samba.smbd")
+	otherProfile := filepath.Join(dirs.SnapSeccompDir, "snap.other.smbd")
+
+	sambaInfo := snaptest.MockInfo(c, ifacetest.SambaYamlV1, nil)
+	err = s.Backend.Setup(sambaInfo, interfaces.ConfinementOptions{}, s.Repo, s.meas)
+	c.Assert(err, IsNil)
+	c.Check(snapSeccomp.Calls(), DeepEquals, [][]string{
+		{"snap-seccomp", "compile", smbdProfile + ".src", smbdProfile + ".bin"},
+	})
+	cached, err := filepath.Glob(filepath.Join(dirs.SnapSeccompCacheDir, "*.bin"))
+	c.Assert(err, IsNil)
+	c.Assert(cached, HasLen, 1)
+
+	// the same profile of another snap is installed from the cache
+	otherInfo := snaptest.MockInfo(c, "name: other\nversion: 1\napps:\n smbd:\n", nil)
+	err = s.Backend.Setup(otherInfo, interfaces.ConfinementOptions{}, s.Repo, s.meas)
+	c.Assert(err, IsNil)
+	c.Check(snapSeccomp.Calls(), HasLen, 1)
+	c.Check(otherProfile+".bin", testutil.FileEquals, "compiled\n")
+	fi1, err := os.Stat(otherProfile + ".bin")
+	c.Assert(err, IsNil)
+	fi2, err := os.Stat(cached[0])
+	c.Assert(err, IsNil)
+	c.Check(os.SameFile(fi1, fi2), Equals, true)
+
+	// the entry is kept while it is used
+	err = s.Backend.Remove("samba")
+	c.Assert(err, IsNil)
+	c.Check(cached[0], testutil.FilePresent)
+
+	// and removed once it is not
+	err = s.Backend.Remove("other")
+	c.Assert(err, IsNil)
+	c.Check(cached[0], testutil.FileAbsent)
+
+	// a different version of snap-seccomp does not use the cache
+	snapSeccomp.ForgetCalls()
+	err = s.Backend.Setup(sambaInfo, interfaces.ConfinementOptions{}, s.Repo, s.meas)
+	c.Assert(err, IsNil)
+	c.Check(snapSeccomp.Calls(), HasLen, 1)
+	restore = seccomp.MockVersionInfo(s.Backend.(*seccomp.Backend), "abcdef 2.3.4 1234abcd -")
+	defer restore()
+	err = s.Backend.Setup(otherInfo, interfaces.ConfinementOptions{}, s.Repo, s.meas)
+	c.Assert(err, IsNil)
+	c.Check(snapSeccomp.Calls(), HasLen, 2)
+}
+
+func (s *backendSuite) TestProfileCacheKey(c *C) {
+	key := seccomp.ProfileCacheKey("abcdef 1.2.3 1234abcd -", []byte("# comment\nread\nwrite\n\n"))
+	// comments, empty lines, order and duplicates do not matter
+	c.Check(seccomp.ProfileCacheKey("abcdef 1.2.3 1234abcd -", []byte("write\n# other\nread\nwrite\n")), Equals, key)
+	// the rules and the compiler version do
+	c.Check(seccomp.ProfileCacheKey("abcdef 1.2.3 1234abcd -", []byte("read\n")), Not(Equals), key)
+	c.Check(seccomp.ProfileCacheKey("abcdef 2.3.4 1234abcd -", []byte("read\nwrite\n")), Not(Equals), key)
+}
+
 type mockedSyncedCompiler struct {
 	lock     sync.Mutex
 	profiles []string
@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package seccomp
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+)
+
+// A base policy fragment may only allow system calls, by name and without
+// argument filtering.
+var basePolicySyscallPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// basePolicyAllowedSyscalls are the system calls that a base snap can
+// allow, typically newer variants of system calls the default template
+// already allows and which the libc of the base needs. System calls that
+// are not listed are only ever granted by interfaces, if at all.
+var basePolicyAllowedSyscalls = map[string]bool{
+	"cachestat":               true,
+	"clone3":                  true,
+	"close_range":             true,
+	"copy_file_range":         true,
+	"epoll_pwait2":            true,
+	"faccessat2":              true,
+	"fchmodat2":               true,
+	"futex_requeue":           true,
+	"futex_wait":              true,
+	"futex_waitv":             true,
+	"futex_wake":              true,
+	"getrandom":               true,
+	"landlock_add_rule":       true,
+	"landlock_create_ruleset": true,
+	"landlock_restrict_self":  true,
+	"map_shadow_stack":        true,
+	"membarrier":              true,
+	"memfd_secret":            true,
+	"mlock2":                  true,
+	"mseal":                   true,
+	"openat2":                 true,
+	"pidfd_getfd":             true,
+	"pidfd_open":              true,
+	"pidfd_send_signal":       true,
+	"pkey_alloc":              true,
+	"pkey_free":               true,
+	"pkey_mprotect":           true,
+	"preadv2":                 true,
+	"process_madvise":         true,
+	"process_mrelease":        true,
+	"pwritev2":                true,
+	"rseq":                    true,
+	"sched_getattr":           true,
+	"set_mempolicy_home_node": true,
+	"statx":                   true,
+}
+
+// basePolicySnippet returns the seccomp policy fragment shipped by the base
+// snap as a snippet to add to the profiles, after validating it.
+func basePolicySnippet(opts interfaces.ConfinementOptions) (string, error) {
+	fragment, err := interfaces.BasePolicyFragment(opts, interfaces.SecuritySecComp)
+	if err != nil || fragment == "" {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Adjustments of the template shipped by the base snap\n")
+	for i, line := range strings.Split(fragment, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !basePolicySyscallPattern.MatchString(line) {
+			return "", fmt.Errorf("cannot use seccomp policy of base snap: line %d: only system call names are allowed: %q", i+1, line)
+		}
+		if !basePolicyAllowedSyscalls[line] {
+			return "", fmt.Errorf("cannot use seccomp policy of base snap: line %d: system call %q cannot be allowed by a base", i+1, line)
+		}
+		fmt.Fprintf(&buf, "%s\n", line)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package seccomp
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/randutil"
+	"github.com/snapcore/snapd/sandbox/seccomp"
+)
+
+// The compiled profiles are kept in a content-addressed cache, keyed by the
+// canonical form of the source profile and the version of snap-seccomp, so
+// that identical profiles, be it of different snaps or of the same snap
+// across reconnects, are compiled only once. Profiles installed from the
+// cache are hard links to the cache entries, an entry that is not linked
+// anymore is not used by any profile and can be garbage collected.
+
+// cachingCompiler is a Compiler which uses the cache of compiled profiles.
+type cachingCompiler struct {
+	Compiler
+	versionInfo seccomp.VersionInfo
+}
+
+// profileCacheKey returns the cache key for the given source profile. The
+// profile is canonicalized by dropping comments and empty lines, which are
+// ignored by snap-seccomp, and by sorting the remaining rules, as their
+// order does not affect the compiled program.
+func profileCacheKey(versionInfo seccomp.VersionInfo, content []byte) string {
+	var rules []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	sort.Strings(rules)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", versionInfo)
+	prev := ""
+	for i, rule := range rules {
+		if i > 0 && rule == prev {
+			continue
+		}
+		fmt.Fprintf(h, "%s\n", rule)
+		prev = rule
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// linkAtomic creates a hard link of target at linkPath, replacing linkPath
+// atomically.
+func linkAtomic(target, linkPath string) error {
+	for tries := 0; tries < 10; tries++ {
+		tmp := linkPath + "." + randutil.RandomString(12) + "~"
+		if err := os.Link(target, tmp); err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return err
+		}
+		if err := os.Rename(tmp, linkPath); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return nil
+	}
+	return errors.New("cannot create a temporary link")
+}
+
+// Compile installs the compiled profile from the cache if possible,
+// otherwise it compiles the profile and adds the result to the cache.
+func (c *cachingCompiler) Compile(in, out string) error {
+	content, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	cached := filepath.Join(dirs.SnapSeccompCacheDir, profileCacheKey(c.versionInfo, content)+".bin")
+	err = linkAtomic(cached, out)
+	if err == nil {
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		logger.Noticef("cannot use cached seccomp profile %s: %v", cached, err)
+	}
+
+	if err := c.Compiler.Compile(in, out); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dirs.SnapSeccompCacheDir, 0755); err != nil {
+		logger.Noticef("cannot create seccomp profile cache directory: %v", err)
+		return nil
+	}
+	if err := linkAtomic(out, cached); err != nil {
+		logger.Noticef("cannot cache compiled seccomp profile %s: %v", out, err)
+	}
+	return nil
+}
+
+// pruneProfileCache removes the cache entries which are not used by any
+// compiled profile.
+func pruneProfileCache() error {
+	entries, err := ioutil.ReadDir(dirs.SnapSeccompCacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var firstErr error
+	for _, fi := range entries {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		// leftovers of interrupted links are never used
+		unused := strings.HasSuffix(name, "~")
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok && st.Nlink <= 1 {
+			unused = true
+		}
+		if !unused {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dirs.SnapSeccompCacheDir, name)); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -90,6 +90,14 @@ func (b *Backend) VersionInfo() seccomp_compiler.VersionInfo {
 	return b.versionInfo
 }
 
+func MockVersionInfo(b *Backend, versionInfo seccomp_compiler.VersionInfo) (restore func()) {
+	old := b.versionInfo
+	b.versionInfo = versionInfo
+	return func() {
+		b.versionInfo = old
+	}
+}
+
 var (
 	RequiresSocketcall = requiresSocketcall
 
@@ -98,4 +106,5 @@ var (
 	IsBigEndian     = isBigEndian
 
 	ParallelCompile = parallelCompile
+	ProfileCacheKey = profileCacheKey
 )
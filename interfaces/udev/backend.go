@@ -42,6 +42,8 @@ import (
 // Backend is responsible for maintaining udev rules.
 type Backend struct {
 	preseed bool
+
+	retriggers retriggerQueue
 }
 
 // Initialize does nothing.
@@ -70,17 +72,59 @@ func snapRulesFilePath(snapName string) string {
 //
 // If the method fails it should be re-tried (with a sensible strategy) by the caller.
 func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) error {
+	changed, subsystemTriggers, err := b.writeRules(snapInfo, opts, repo)
+	if err != nil || !changed {
+		return err
+	}
+	// FIXME: somehow detect the interfaces that were disconnected and set
+	// subsystemTriggers appropriately. ATM, it is always going to be empty
+	// on disconnect.
+	return b.retrigger(subsystemTriggers)
+}
+
+// SetupMany creates udev rules specific to multiple snaps. The udev database
+// is reloaded and devices are retriggered at most once for all of them.
+//
+// SetupMany tries to write the rules of all snaps without interrupting on
+// errors, but collects and returns them all.
+func (b *Backend) SetupMany(snaps []*snap.Info, confinement func(snapName string) interfaces.ConfinementOptions, repo *interfaces.Repository, tm timings.Measurer) []error {
+	var errors []error
+	var allSubsystemTriggers []string
+	anyChanged := false
+	for _, snapInfo := range snaps {
+		changed, subsystemTriggers, err := b.writeRules(snapInfo, confinement(snapInfo.InstanceName()), repo)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("cannot setup udev rules for snap %q: %s", snapInfo.InstanceName(), err))
+			continue
+		}
+		if changed {
+			anyChanged = true
+			allSubsystemTriggers = append(allSubsystemTriggers, subsystemTriggers...)
+		}
+	}
+	if anyChanged {
+		if err := b.retrigger(allSubsystemTriggers); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// writeRules writes the udev rules file of the given snap, or removes it if
+// the snap needs no rules, and returns whether the file was changed along with
+// the subsystems that need to be retriggered.
+func (b *Backend) writeRules(snapInfo *snap.Info, opts interfaces.ConfinementOptions, repo *interfaces.Repository) (changed bool, subsystemTriggers []string, err error) {
 	snapName := snapInfo.InstanceName()
 	spec, err := repo.SnapSpecification(b.Name(), snapName)
 	if err != nil {
-		return fmt.Errorf("cannot obtain udev specification for snap %q: %s", snapName, err)
+		return false, nil, fmt.Errorf("cannot obtain udev specification for snap %q: %s", snapName, err)
 	}
 	content := b.deriveContent(spec.(*Specification), snapInfo)
-	subsystemTriggers := spec.(*Specification).TriggeredSubsystems()
+	subsystemTriggers = spec.(*Specification).TriggeredSubsystems()
 
 	dir := dirs.SnapUdevRulesDir
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("cannot create directory for udev rules %q: %s", dir, err)
+		return false, nil, fmt.Errorf("cannot create directory for udev rules %q: %s", dir, err)
 	}
 
 	rulesFilePath := snapRulesFilePath(snapInfo.InstanceName())
@@ -90,14 +134,9 @@ func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions,
 		// content and exists.
 		err = os.Remove(rulesFilePath)
 		if err != nil && !os.IsNotExist(err) {
-			return err
-		} else if err == nil {
-			// FIXME: somehow detect the interfaces that were
-			// disconnected and set subsystemTriggers appropriately.
-			// ATM, it is always going to be empty on disconnect.
-			return b.reloadRules(subsystemTriggers)
+			return false, nil, err
 		}
-		return nil
+		return err == nil, subsystemTriggers, nil
 	}
 
 	var buffer bytes.Buffer
@@ -124,15 +163,11 @@ func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions,
 	// udev rules when not needed.
 	err = osutil.EnsureFileState(rulesFilePath, rulesFileState)
 	if err == osutil.ErrSameState {
-		return nil
+		return false, nil, nil
 	} else if err != nil {
-		return err
+		return false, nil, err
 	}
-
-	// FIXME: somehow detect the interfaces that were disconnected and set
-	// subsystemTriggers appropriately. ATM, it is always going to be empty
-	// on disconnect.
-	return b.reloadRules(subsystemTriggers)
+	return true, subsystemTriggers, nil
 }
 
 // Remove removes udev rules specific to a given snap.
@@ -154,7 +189,7 @@ func (b *Backend) Remove(snapName string) error {
 	// FIXME: somehow detect the interfaces that were disconnected and set
 	// subsystemTriggers appropriately. ATM, it is always going to be empty
 	// on disconnect.
-	return b.reloadRules(nil)
+	return b.retrigger(nil)
 }
 
 func (b *Backend) deriveContent(spec *Specification, snapInfo *snap.Info) (content []string) {
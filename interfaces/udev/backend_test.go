@@ -21,8 +21,11 @@ package udev_test
 
 import (
 	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -30,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/ifacetest"
 	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/sandbox/cgroup"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/testutil"
@@ -517,6 +521,102 @@ func (s *backendSuite) TestInstallingSnapWritesAndLoadsRulesWithInputJoystickSub
 	}
 }
 
+func (s *backendSuite) TestSetupManyReloadsOnce(c *C) {
+	s.Iface.UDevPermanentSlotCallback = func(spec *udev.Specification, slot *snap.SlotInfo) error {
+		spec.AddSnippet("sample")
+		if slot.Snap.InstanceName() == "samba_foo" {
+			spec.TriggerSubsystem("input/key")
+		}
+		return nil
+	}
+	snapInfo1 := s.InstallSnap(c, interfaces.ConfinementOptions{}, "", ifacetest.SambaYamlV1, 0)
+	snapInfo2 := s.InstallSnap(c, interfaces.ConfinementOptions{}, "samba_foo", ifacetest.SambaYamlV1, 0)
+	fname1 := filepath.Join(dirs.SnapUdevRulesDir, "70-snap.samba.rules")
+	fname2 := filepath.Join(dirs.SnapUdevRulesDir, "70-snap.samba_foo.rules")
+	c.Assert(os.Remove(fname1), IsNil)
+	c.Assert(os.Remove(fname2), IsNil)
+	s.udevadmCmd.ForgetCalls()
+
+	setupManyInterface, ok := s.Backend.(interfaces.SecurityBackendSetupMany)
+	c.Assert(ok, Equals, true)
+	errs := setupManyInterface.SetupMany([]*snap.Info{snapInfo1, snapInfo2}, func(snapName string) interfaces.ConfinementOptions {
+		return interfaces.ConfinementOptions{}
+	}, s.Repo, s.meas)
+	c.Assert(errs, HasLen, 0)
+	c.Check(fname1, testutil.FilePresent)
+	c.Check(fname2, testutil.FilePresent)
+	// udev rules were reloaded and devices retriggered once for both snaps
+	c.Check(s.udevadmCmd.Calls(), DeepEquals, [][]string{
+		{"udevadm", "control", "--reload-rules"},
+		{"udevadm", "trigger", "--subsystem-nomatch=input"},
+		{"udevadm", "trigger", "--property-match=ID_INPUT_KEY=1", "--property-match=ID_INPUT_KEYBOARD!=1"},
+		{"udevadm", "trigger", "--property-match=ID_INPUT_JOYSTICK=1"},
+		{"udevadm", "settle", "--timeout=10"},
+	})
+
+	// nothing changed, nothing is reloaded
+	s.udevadmCmd.ForgetCalls()
+	errs = setupManyInterface.SetupMany([]*snap.Info{snapInfo1, snapInfo2}, func(snapName string) interfaces.ConfinementOptions {
+		return interfaces.ConfinementOptions{}
+	}, s.Repo, s.meas)
+	c.Assert(errs, HasLen, 0)
+	c.Check(s.udevadmCmd.Calls(), HasLen, 0)
+}
+
+func (s *backendSuite) TestRetriggersCoalesced(c *C) {
+	settling := filepath.Join(c.MkDir(), "settling")
+	settled := filepath.Join(c.MkDir(), "settled")
+	// the first settle blocks until the test lets it complete
+	cmd := testutil.MockCommand(c, "udevadm", fmt.Sprintf(`
+if [ "$1" = "settle" ] && [ ! -e %[1]q ]; then
+    touch %[1]q
+    while [ ! -e %[2]q ]; do sleep 0.01; done
+fi
+`, settling, settled))
+	defer cmd.Restore()
+
+	b := s.Backend.(*udev.Backend)
+	errs := make(chan error, 3)
+	go func() { errs <- b.Retrigger(nil) }()
+	for !osutil.FileExists(settling) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	pending, _ := b.PendingRetriggers()
+	c.Check(pending, Equals, false)
+
+	// requests made while the first one is in progress are queued
+	go func() { errs <- b.Retrigger([]string{"input/key"}) }()
+	go func() { errs <- b.Retrigger([]string{"input"}) }()
+	for {
+		pending, subsystems := b.PendingRetriggers()
+		if len(subsystems) == 2 {
+			c.Check(pending, Equals, true)
+			c.Check(subsystems, DeepEquals, []string{"input", "input/key"})
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.Assert(ioutil.WriteFile(settled, nil, 0644), IsNil)
+	for i := 0; i < 3; i++ {
+		c.Check(<-errs, IsNil)
+	}
+	pending, _ = b.PendingRetriggers()
+	c.Check(pending, Equals, false)
+
+	// and carried out together
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"udevadm", "control", "--reload-rules"},
+		{"udevadm", "trigger", "--subsystem-nomatch=input"},
+		{"udevadm", "trigger", "--property-match=ID_INPUT_JOYSTICK=1"},
+		{"udevadm", "settle", "--timeout=10"},
+		{"udevadm", "control", "--reload-rules"},
+		{"udevadm", "trigger", "--subsystem-nomatch=input"},
+		{"udevadm", "trigger", "--subsystem-match=input"},
+		{"udevadm", "settle", "--timeout=10"},
+	})
+}
+
 func (s *backendSuite) TestSandboxFeatures(c *C) {
 	restore := cgroup.MockVersion(cgroup.V1, nil)
 	defer restore()
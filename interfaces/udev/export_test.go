@@ -22,3 +22,7 @@ package udev
 func (b *Backend) ReloadRules(subsystemTriggers []string) error {
 	return b.reloadRules(subsystemTriggers)
 }
+
+func (b *Backend) Retrigger(subsystemTriggers []string) error {
+	return b.retrigger(subsystemTriggers)
+}
@@ -22,6 +22,8 @@ package udev
 import (
 	"fmt"
 	"os/exec"
+	"sort"
+	"sync"
 )
 
 // udevadmTrigger runs "udevadm trigger" but ignores an non-zero exit codes.
@@ -121,3 +123,83 @@ func (b *Backend) reloadRules(subsystemTriggers []string) error {
 
 	return nil
 }
+
+// retriggerBatch collects the subsystems of retrigger requests that are
+// carried out together.
+type retriggerBatch struct {
+	subsystems map[string]bool
+	done       chan struct{}
+	err        error
+}
+
+// retriggerQueue coalesces requests to reload the udev rules and retrigger
+// devices. Requests made while a reload is in progress, which includes
+// waiting for udev to settle, are carried out together by a single reload
+// once it completes.
+type retriggerQueue struct {
+	mu      sync.Mutex
+	pending *retriggerBatch
+
+	// runMu is held while a batch is being carried out
+	runMu sync.Mutex
+}
+
+// retrigger requests that the udev rules are reloaded and devices are
+// retriggered, including the given subsystems. It returns once a reload
+// covering the request has completed.
+func (b *Backend) retrigger(subsystemTriggers []string) error {
+	q := &b.retriggers
+
+	q.mu.Lock()
+	batch := q.pending
+	if batch == nil {
+		batch = &retriggerBatch{
+			subsystems: make(map[string]bool),
+			done:       make(chan struct{}),
+		}
+		q.pending = batch
+	}
+	for _, subsystem := range subsystemTriggers {
+		batch.subsystems[subsystem] = true
+	}
+	q.mu.Unlock()
+
+	q.runMu.Lock()
+	q.mu.Lock()
+	if q.pending == batch {
+		// nobody picked up the batch yet, carry it out
+		q.pending = nil
+		q.mu.Unlock()
+		subsystems := make([]string, 0, len(batch.subsystems))
+		for subsystem := range batch.subsystems {
+			subsystems = append(subsystems, subsystem)
+		}
+		sort.Strings(subsystems)
+		batch.err = b.reloadRules(subsystems)
+		close(batch.done)
+	} else {
+		q.mu.Unlock()
+	}
+	q.runMu.Unlock()
+
+	<-batch.done
+	return batch.err
+}
+
+// PendingRetriggers returns whether a reload of the udev rules and retrigger
+// of devices is queued, waiting for the one in progress to complete, along
+// with the subsystems that will be explicitly retriggered.
+func (b *Backend) PendingRetriggers() (pending bool, subsystemTriggers []string) {
+	q := &b.retriggers
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		return false, nil
+	}
+	for subsystem := range q.pending.subsystems {
+		subsystemTriggers = append(subsystemTriggers, subsystem)
+	}
+	sort.Strings(subsystemTriggers)
+	return true, subsystemTriggers
+}
@@ -21,6 +21,7 @@ package netutil
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/godbus/dbus"
 
@@ -36,9 +37,29 @@ const (
 	NetworkManagerMeteredGuessNo  = 4
 )
 
+const (
+	nmBusName    = "org.freedesktop.NetworkManager"
+	nmObjectPath = "/org/freedesktop/NetworkManager"
+)
+
+// meteredWatch holds the metered state last signalled by NetworkManager
+// while WatchMeteredConnection is active.
+var meteredWatch struct {
+	mu       sync.Mutex
+	watching bool
+	metered  bool
+}
+
 // IsOnMeteredConnection checks whether the current default network connection
 // is metered. If the state can not be determined, returns false and an error.
 func IsOnMeteredConnection() (bool, error) {
+	meteredWatch.mu.Lock()
+	watching, metered := meteredWatch.watching, meteredWatch.metered
+	meteredWatch.mu.Unlock()
+	if watching {
+		return metered, nil
+	}
+
 	// obtain a shared connection to system bus, no need to close it
 	conn, err := dbus.SystemBus()
 	if err != nil {
@@ -49,7 +70,7 @@ func IsOnMeteredConnection() (bool, error) {
 }
 
 func isNMOnMetered(conn *dbus.Conn) (bool, error) {
-	nmObj := conn.Object("org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager")
+	nmObj := conn.Object(nmBusName, nmObjectPath)
 	// https://developer.gnome.org/NetworkManager/stable/gdbus-org.freedesktop.NetworkManager.html
 	dbusV, err := nmObj.GetProperty("org.freedesktop.NetworkManager.Metered")
 	if err != nil {
@@ -61,5 +82,103 @@ func isNMOnMetered(conn *dbus.Conn) (bool, error) {
 	}
 	logger.Debugf("metered state reported by NetworkManager: %s", dbusV)
 
-	return v == NetworkManagerMeteredGuessYes || v == NetworkManagerMeteredYes, nil
+	return isNMMeteredValue(v), nil
+}
+
+func isNMMeteredValue(v uint32) bool {
+	return v == NetworkManagerMeteredGuessYes || v == NetworkManagerMeteredYes
+}
+
+// WatchMeteredConnection starts following the changes of the metered state
+// of the default network connection signalled by NetworkManager. While
+// watching, IsOnMeteredConnection reports the last signalled state without
+// querying NetworkManager. The returned function stops watching.
+func WatchMeteredConnection() (stop func(), err error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to system bus: %v", err)
+	}
+
+	matchRules := []dbus.MatchOption{
+		dbus.WithMatchSender(nmBusName),
+		dbus.WithMatchObjectPath(nmObjectPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+		dbus.WithMatchOption("arg0", nmBusName),
+	}
+	if err := conn.AddMatchSignal(matchRules...); err != nil {
+		return nil, err
+	}
+	// TODO: upgrade godbus and use un-buffered channel.
+	ch := make(chan *dbus.Signal, 10)
+	conn.Signal(ch)
+
+	// only query the initial state once signals are delivered so that
+	// no change is missed
+	metered, err := isNMOnMetered(conn)
+	if err != nil {
+		conn.RemoveSignal(ch)
+		conn.RemoveMatchSignal(matchRules...)
+		return nil, err
+	}
+	meteredWatch.mu.Lock()
+	meteredWatch.watching = true
+	meteredWatch.metered = metered
+	meteredWatch.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case sig, ok := <-ch:
+				if !ok {
+					return
+				}
+				processMeteredSignal(sig)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		conn.RemoveSignal(ch)
+		if err := conn.RemoveMatchSignal(matchRules...); err != nil {
+			logger.Noticef("Cannot remove D-Bus signal matcher: %v", err)
+		}
+		meteredWatch.mu.Lock()
+		meteredWatch.watching = false
+		meteredWatch.mu.Unlock()
+	}, nil
+}
+
+func processMeteredSignal(sig *dbus.Signal) {
+	if sig.Path != nmObjectPath || sig.Name != "org.freedesktop.DBus.Properties.PropertiesChanged" {
+		return
+	}
+	var iface string
+	var changed map[string]dbus.Variant
+	var invalidated []string
+	if err := dbus.Store(sig.Body, &iface, &changed, &invalidated); err != nil {
+		logger.Debugf("cannot decode NetworkManager properties change: %v", err)
+		return
+	}
+	if iface != nmBusName {
+		return
+	}
+	dbusV, ok := changed["Metered"]
+	if !ok {
+		return
+	}
+	v, ok := dbusV.Value().(uint32)
+	if !ok {
+		logger.Debugf("network manager signalled invalid value for metering verification: %s", dbusV)
+		return
+	}
+	logger.Debugf("metered state changed by NetworkManager: %s", dbusV)
+
+	meteredWatch.mu.Lock()
+	meteredWatch.metered = isNMMeteredValue(v)
+	meteredWatch.mu.Unlock()
 }
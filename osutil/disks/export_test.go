@@ -45,3 +45,11 @@ func MockUdevPropertiesForDevice(new func(string, string) (map[string]string, er
 var UnregisterDeviceMapperBackResolver = unregisterDeviceMapperBackResolver
 
 var CryptLuks2DeviceMapperBackResolver = cryptLuks2DeviceMapperBackResolver
+
+func MockSmartctlCommand(f func(node string) ([]byte, error)) (restore func()) {
+	old := smartctlCommand
+	smartctlCommand = f
+	return func() {
+		smartctlCommand = old
+	}
+}
@@ -0,0 +1,244 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package disks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Sources of disk health information.
+const (
+	// HealthSourceEMMC is the life time estimation of eMMC devices, as
+	// read from their EXT_CSD register.
+	HealthSourceEMMC = "emmc"
+	// HealthSourceSMART is the SMART data of ATA, SCSI and NVMe devices.
+	HealthSourceSMART = "smart"
+)
+
+// ErrNoHealthInfo is returned by DiskHealth when the disk does not report
+// any health information, or when it cannot be read on this system.
+var ErrNoHealthInfo = errors.New("no health information available for disk")
+
+// Health describes the health of a disk as reported by its firmware.
+type Health struct {
+	// Source is where the information comes from, HealthSourceEMMC or
+	// HealthSourceSMART.
+	Source string
+	// LifeUsed is the estimated percentage of the rated life time of the
+	// disk that was used so far, or -1 if it is not known. It can exceed
+	// 100 once the rated life time is exceeded.
+	LifeUsed int
+	// PreEOL is the pre end of life information of eMMC devices about the
+	// consumption of their reserved blocks: "normal", "warning" or
+	// "urgent".
+	PreEOL string
+	// Failing is set when the firmware of the disk reports that it is
+	// failing or about to fail.
+	Failing bool
+	// Attributes are the raw values the health is derived from.
+	Attributes map[string]string
+}
+
+var smartctlCommand = func(node string) ([]byte, error) {
+	output, err := exec.Command("smartctl", "--json=c", "--health", "--attributes", node).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// smartctl reports problems found with the disk in bits 2 and
+		// above of the exit status, with valid output
+		if !errors.As(err, &exitErr) || exitErr.ExitCode()&0x3 != 0 {
+			return nil, err
+		}
+	}
+	return output, nil
+}
+
+// DiskHealth returns the health information reported by the given disk. The
+// eMMC life time estimation is used for eMMC devices, SMART data read with
+// smartctl otherwise. ErrNoHealthInfo is returned when no information is
+// available.
+func DiskHealth(disk Disk) (*Health, error) {
+	health, err := emmcHealth(disk.KernelDevicePath())
+	if err == nil || !errors.Is(err, ErrNoHealthInfo) {
+		return health, err
+	}
+	return smartHealth(disk.KernelDeviceNode())
+}
+
+// emmc pre_eol_info values, see JESD84-B51 section 7.4.22
+var emmcPreEOL = map[uint64]string{
+	0x01: "normal",
+	0x02: "warning",
+	0x03: "urgent",
+}
+
+func readSysfsHex(path string) ([]uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(content))
+	values := make([]uint64, 0, len(fields))
+	for _, f := range fields {
+		v, err := strconv.ParseUint(f, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// emmcHealth reads the life time estimations exposed by the mmc driver,
+// for the SLC (type A) and MLC (type B) areas of the device, in steps of
+// 10% of the rated life time used.
+func emmcHealth(devPath string) (*Health, error) {
+	lifeTimePath := filepath.Join(devPath, "device", "life_time")
+	lifeTime, err := readSysfsHex(lifeTimePath)
+	if os.IsNotExist(err) {
+		return nil, ErrNoHealthInfo
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(lifeTime) != 2 {
+		return nil, fmt.Errorf("cannot parse %s: expected 2 values, got %d", lifeTimePath, len(lifeTime))
+	}
+	health := &Health{
+		Source:   HealthSourceEMMC,
+		LifeUsed: -1,
+		Attributes: map[string]string{
+			"life-time-est-typ-a": fmt.Sprintf("0x%02x", lifeTime[0]),
+			"life-time-est-typ-b": fmt.Sprintf("0x%02x", lifeTime[1]),
+		},
+	}
+	for _, est := range lifeTime {
+		// 0x00 is not defined, 0x01 to 0x0a are 0-10% to 90-100%
+		// used and 0x0b means the life time was exceeded, the upper
+		// bound is reported
+		if est >= 0x01 && est <= 0x0b && int(est)*10 > health.LifeUsed {
+			health.LifeUsed = int(est) * 10
+		}
+	}
+
+	preEOL, err := readSysfsHex(filepath.Join(devPath, "device", "pre_eol_info"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(preEOL) == 1 {
+		health.Attributes["pre-eol-info"] = fmt.Sprintf("0x%02x", preEOL[0])
+		health.PreEOL = emmcPreEOL[preEOL[0]]
+	}
+	health.Failing = health.PreEOL == "urgent"
+	return health, nil
+}
+
+type smartctlOutput struct {
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	ATASmartAttributes *struct {
+		Table []struct {
+			ID    int    `json:"id"`
+			Name  string `json:"name"`
+			Value int    `json:"value"`
+			Raw   struct {
+				Value int64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	NVMeSmartHealthInformationLog *struct {
+		CriticalWarning         int `json:"critical_warning"`
+		AvailableSpare          int `json:"available_spare"`
+		AvailableSpareThreshold int `json:"available_spare_threshold"`
+		PercentageUsed          int `json:"percentage_used"`
+		MediaErrors             int `json:"media_errors"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// ATA attributes whose normalized value is the percentage of the rated life
+// time remaining, depending on the vendor
+var ataWearAttributes = map[int]bool{
+	// Wear_Leveling_Count
+	177: true,
+	// SSD_Life_Left
+	231: true,
+	// Media_Wearout_Indicator
+	233: true,
+}
+
+func smartHealth(node string) (*Health, error) {
+	output, err := smartctlCommand(node)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, ErrNoHealthInfo
+		}
+		return nil, fmt.Errorf("cannot read SMART data of %s: %v", node, err)
+	}
+	var out smartctlOutput
+	if err := json.Unmarshal(output, &out); err != nil {
+		return nil, fmt.Errorf("cannot parse SMART data of %s: %v", node, err)
+	}
+	if out.SmartStatus == nil {
+		// smartctl could not identify the device or it does not
+		// support SMART
+		return nil, ErrNoHealthInfo
+	}
+
+	health := &Health{
+		Source:     HealthSourceSMART,
+		LifeUsed:   -1,
+		Failing:    !out.SmartStatus.Passed,
+		Attributes: map[string]string{},
+	}
+	if log := out.NVMeSmartHealthInformationLog; log != nil {
+		health.LifeUsed = log.PercentageUsed
+		health.Attributes["percentage-used"] = strconv.Itoa(log.PercentageUsed)
+		health.Attributes["available-spare"] = strconv.Itoa(log.AvailableSpare)
+		health.Attributes["available-spare-threshold"] = strconv.Itoa(log.AvailableSpareThreshold)
+		health.Attributes["critical-warning"] = fmt.Sprintf("0x%02x", log.CriticalWarning)
+		health.Attributes["media-errors"] = strconv.Itoa(log.MediaErrors)
+		if log.CriticalWarning != 0 {
+			health.Failing = true
+		}
+	}
+	if attrs := out.ATASmartAttributes; attrs != nil {
+		for _, attr := range attrs.Table {
+			switch {
+			case ataWearAttributes[attr.ID]:
+				health.Attributes[attr.Name] = strconv.Itoa(attr.Value)
+				if used := 100 - attr.Value; used > health.LifeUsed {
+					health.LifeUsed = used
+				}
+			case attr.ID == 5 || attr.ID == 197:
+				// Reallocated_Sector_Ct and Current_Pending_Sector
+				health.Attributes[attr.Name] = strconv.FormatInt(attr.Raw.Value, 10)
+			}
+		}
+	}
+	return health, nil
+}
@@ -0,0 +1,204 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package disks_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type diskHealthSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&diskHealthSuite{})
+
+func (s *diskHealthSuite) mockDisk(c *C, name string) *disks.MockDiskMapping {
+	devPath := filepath.Join(c.MkDir(), "block", name)
+	c.Assert(os.MkdirAll(filepath.Join(devPath, "device"), 0755), IsNil)
+	return &disks.MockDiskMapping{
+		DevNode: "/dev/" + name,
+		DevPath: devPath,
+	}
+}
+
+func (s *diskHealthSuite) mockSmartctl(c *C, output string, err error) *[]string {
+	var calls []string
+	s.AddCleanup(disks.MockSmartctlCommand(func(node string) ([]byte, error) {
+		calls = append(calls, node)
+		return []byte(output), err
+	}))
+	return &calls
+}
+
+func (s *diskHealthSuite) TestEMMCHealth(c *C) {
+	calls := s.mockSmartctl(c, "", errors.New("unexpected call"))
+	d := s.mockDisk(c, "mmcblk0")
+	c.Assert(ioutil.WriteFile(filepath.Join(d.DevPath, "device", "life_time"), []byte("0x02 0x05\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d.DevPath, "device", "pre_eol_info"), []byte("0x02\n"), 0644), IsNil)
+
+	health, err := disks.DiskHealth(d)
+	c.Assert(err, IsNil)
+	c.Check(health, DeepEquals, &disks.Health{
+		Source:   disks.HealthSourceEMMC,
+		LifeUsed: 50,
+		PreEOL:   "warning",
+		Attributes: map[string]string{
+			"life-time-est-typ-a": "0x02",
+			"life-time-est-typ-b": "0x05",
+			"pre-eol-info":        "0x02",
+		},
+	})
+	c.Check(*calls, HasLen, 0)
+}
+
+func (s *diskHealthSuite) TestEMMCHealthExceededUrgent(c *C) {
+	d := s.mockDisk(c, "mmcblk0")
+	c.Assert(ioutil.WriteFile(filepath.Join(d.DevPath, "device", "life_time"), []byte("0x0b 0x00\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(d.DevPath, "device", "pre_eol_info"), []byte("0x03\n"), 0644), IsNil)
+
+	health, err := disks.DiskHealth(d)
+	c.Assert(err, IsNil)
+	c.Check(health.LifeUsed, Equals, 110)
+	c.Check(health.PreEOL, Equals, "urgent")
+	c.Check(health.Failing, Equals, true)
+}
+
+func (s *diskHealthSuite) TestEMMCHealthUndefined(c *C) {
+	d := s.mockDisk(c, "mmcblk0")
+	c.Assert(ioutil.WriteFile(filepath.Join(d.DevPath, "device", "life_time"), []byte("0x00 0x00\n"), 0644), IsNil)
+
+	health, err := disks.DiskHealth(d)
+	c.Assert(err, IsNil)
+	c.Check(health.LifeUsed, Equals, -1)
+	c.Check(health.PreEOL, Equals, "")
+	c.Check(health.Failing, Equals, false)
+}
+
+func (s *diskHealthSuite) TestEMMCHealthInvalid(c *C) {
+	d := s.mockDisk(c, "mmcblk0")
+	lifeTimePath := filepath.Join(d.DevPath, "device", "life_time")
+
+	c.Assert(ioutil.WriteFile(lifeTimePath, []byte("0x02\n"), 0644), IsNil)
+	_, err := disks.DiskHealth(d)
+	c.Check(err, ErrorMatches, `cannot parse .*/life_time: expected 2 values, got 1`)
+
+	c.Assert(ioutil.WriteFile(lifeTimePath, []byte("0x02 bad\n"), 0644), IsNil)
+	_, err = disks.DiskHealth(d)
+	c.Check(err, ErrorMatches, `cannot parse .*/life_time: strconv.ParseUint: parsing "bad": invalid syntax`)
+}
+
+const smartctlNVMeOutput = `{
+  "smartctl": {"version": [7, 2], "exit_status": 0},
+  "device": {"name": "/dev/nvme0n1", "type": "nvme", "protocol": "NVMe"},
+  "smart_status": {"passed": true, "nvme": {"value": 0}},
+  "nvme_smart_health_information_log": {
+    "critical_warning": 0,
+    "temperature": 35,
+    "available_spare": 100,
+    "available_spare_threshold": 10,
+    "percentage_used": 7,
+    "media_errors": 0
+  }
+}`
+
+func (s *diskHealthSuite) TestSMARTHealthNVMe(c *C) {
+	calls := s.mockSmartctl(c, smartctlNVMeOutput, nil)
+	d := s.mockDisk(c, "nvme0n1")
+
+	health, err := disks.DiskHealth(d)
+	c.Assert(err, IsNil)
+	c.Check(health, DeepEquals, &disks.Health{
+		Source:   disks.HealthSourceSMART,
+		LifeUsed: 7,
+		Attributes: map[string]string{
+			"percentage-used":           "7",
+			"available-spare":           "100",
+			"available-spare-threshold": "10",
+			"critical-warning":          "0x00",
+			"media-errors":              "0",
+		},
+	})
+	c.Check(*calls, DeepEquals, []string{"/dev/nvme0n1"})
+}
+
+const smartctlATAOutput = `{
+  "smartctl": {"version": [7, 2], "exit_status": 8},
+  "smart_status": {"passed": false},
+  "ata_smart_attributes": {
+    "revision": 1,
+    "table": [
+      {"id": 5, "name": "Reallocated_Sector_Ct", "value": 90, "raw": {"value": 12, "string": "12"}},
+      {"id": 9, "name": "Power_On_Hours", "value": 99, "raw": {"value": 1234, "string": "1234"}},
+      {"id": 177, "name": "Wear_Leveling_Count", "value": 15, "raw": {"value": 2900, "string": "2900"}}
+    ]
+  }
+}`
+
+func (s *diskHealthSuite) TestSMARTHealthATA(c *C) {
+	s.mockSmartctl(c, smartctlATAOutput, nil)
+	d := s.mockDisk(c, "sda")
+
+	health, err := disks.DiskHealth(d)
+	c.Assert(err, IsNil)
+	c.Check(health, DeepEquals, &disks.Health{
+		Source:   disks.HealthSourceSMART,
+		LifeUsed: 85,
+		Failing:  true,
+		Attributes: map[string]string{
+			"Reallocated_Sector_Ct": "12",
+			"Wear_Leveling_Count":   "15",
+		},
+	})
+}
+
+func (s *diskHealthSuite) TestSMARTHealthUnavailable(c *C) {
+	d := s.mockDisk(c, "sda")
+
+	// smartctl is not installed
+	s.mockSmartctl(c, "", &exec.Error{Name: "smartctl", Err: exec.ErrNotFound})
+	_, err := disks.DiskHealth(d)
+	c.Check(err, Equals, disks.ErrNoHealthInfo)
+
+	// the device does not support SMART
+	s.mockSmartctl(c, `{"smartctl": {"exit_status": 4}}`, nil)
+	_, err = disks.DiskHealth(d)
+	c.Check(err, Equals, disks.ErrNoHealthInfo)
+}
+
+func (s *diskHealthSuite) TestSMARTHealthErrors(c *C) {
+	d := s.mockDisk(c, "sda")
+
+	s.mockSmartctl(c, "", errors.New("exit status 2"))
+	_, err := disks.DiskHealth(d)
+	c.Check(err, ErrorMatches, `cannot read SMART data of /dev/sda: exit status 2`)
+
+	s.mockSmartctl(c, "not json", nil)
+	_, err = disks.DiskHealth(d)
+	c.Check(err, ErrorMatches, `cannot parse SMART data of /dev/sda: invalid character .*`)
+}
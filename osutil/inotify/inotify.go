@@ -0,0 +1,201 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package inotify provides a minimal wrapper around the inotify API of the
+// Linux kernel to monitor file system events.
+package inotify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Events that can be monitored, see inotify(7).
+const (
+	InAccess     = unix.IN_ACCESS
+	InAttrib     = unix.IN_ATTRIB
+	InCloseWrite = unix.IN_CLOSE_WRITE
+	InCreate     = unix.IN_CREATE
+	InDelete     = unix.IN_DELETE
+	InDeleteSelf = unix.IN_DELETE_SELF
+	InModify     = unix.IN_MODIFY
+	InMovedFrom  = unix.IN_MOVED_FROM
+	InMovedTo    = unix.IN_MOVED_TO
+	InMoveSelf   = unix.IN_MOVE_SELF
+
+	// InIsDir is set in the mask of events about directories.
+	InIsDir = unix.IN_ISDIR
+	// InQOverflow is set in the mask of the event reported when the
+	// kernel event queue overflowed and events were lost.
+	InQOverflow = unix.IN_Q_OVERFLOW
+)
+
+// Event is a file system event.
+type Event struct {
+	// Path is the path of the file the event is about, it is empty
+	// when the event queue overflowed.
+	Path string
+	Mask uint32
+}
+
+// Watcher reports the file system events of the watched paths on its
+// Events channel. Errors reading the events are reported on the Errors
+// channel, after which no more events are reported.
+type Watcher struct {
+	Events chan Event
+	Errors chan error
+
+	file *os.File
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	mu    sync.Mutex
+	paths map[int]string
+}
+
+// NewWatcher returns a new watcher, with nothing watched yet.
+func NewWatcher() (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &Watcher{
+		Events: make(chan Event),
+		Errors: make(chan error, 1),
+		// as the descriptor is non-blocking, reads go through the
+		// runtime poller and are interrupted when it is closed
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		done:  make(chan struct{}),
+		paths: make(map[int]string),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// AddWatch starts watching the given events of path. For a directory, the
+// events of the files directly inside it are reported too.
+func (w *Watcher) AddWatch(path string, mask uint32) error {
+	rawConn, err := w.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var wd int
+	var addErr error
+	err = rawConn.Control(func(fd uintptr) {
+		wd, addErr = unix.InotifyAddWatch(int(fd), path, mask)
+	})
+	if err != nil {
+		return err
+	}
+	if addErr != nil {
+		return fmt.Errorf("cannot watch %q: %v", path, addErr)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.paths[wd] = path
+	return nil
+}
+
+// Close stops watching, the Events channel is closed once done.
+func (w *Watcher) Close() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+	}
+	close(w.done)
+	err := w.file.Close()
+	w.wg.Wait()
+	return err
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+	defer close(w.Events)
+
+	var buf [(unix.SizeofInotifyEvent + unix.NAME_MAX + 1) * 16]byte
+	for {
+		n, err := w.file.Read(buf[:])
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+			select {
+			case w.Errors <- err:
+			case <-w.done:
+			}
+			return
+		}
+		for _, ev := range w.parse(buf[:n]) {
+			select {
+			case w.Events <- ev:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *Watcher) parse(buf []byte) []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []Event
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		offset = nameStart + int(raw.Len)
+		if offset > len(buf) {
+			// cannot happen, the kernel only returns whole events
+			break
+		}
+
+		if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+			events = append(events, Event{Mask: raw.Mask})
+			continue
+		}
+		path, ok := w.paths[int(raw.Wd)]
+		if !ok {
+			continue
+		}
+		if raw.Mask&unix.IN_IGNORED != 0 {
+			// the watch was removed, eg. because path is gone
+			delete(w.paths, int(raw.Wd))
+			continue
+		}
+		if raw.Len > 0 {
+			name := buf[nameStart:offset]
+			// the name is padded with NUL bytes
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			path = filepath.Join(path, string(name))
+		}
+		events = append(events, Event{Path: path, Mask: raw.Mask})
+	}
+	return events
+}
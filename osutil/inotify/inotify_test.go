@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package inotify_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil/inotify"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type inotifySuite struct{}
+
+var _ = Suite(&inotifySuite{})
+
+func nextEvent(c *C, w *inotify.Watcher) inotify.Event {
+	select {
+	case ev := <-w.Events:
+		return ev
+	case err := <-w.Errors:
+		c.Fatalf("unexpected error: %v", err)
+	case <-time.After(5 * time.Second):
+		c.Fatalf("timeout waiting for event")
+	}
+	return inotify.Event{}
+}
+
+func (s *inotifySuite) TestWatchDirectory(c *C) {
+	dir := c.MkDir()
+	w, err := inotify.NewWatcher()
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	c.Assert(w.AddWatch(dir, inotify.InCloseWrite|inotify.InCreate|inotify.InDelete), IsNil)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "foo"), []byte("foo"), 0644), IsNil)
+	ev := nextEvent(c, w)
+	c.Check(ev.Path, Equals, filepath.Join(dir, "foo"))
+	c.Check(ev.Mask&inotify.InCreate, Not(Equals), uint32(0))
+	ev = nextEvent(c, w)
+	c.Check(ev.Path, Equals, filepath.Join(dir, "foo"))
+	c.Check(ev.Mask&inotify.InCloseWrite, Not(Equals), uint32(0))
+
+	c.Assert(os.Mkdir(filepath.Join(dir, "bar"), 0755), IsNil)
+	ev = nextEvent(c, w)
+	c.Check(ev.Path, Equals, filepath.Join(dir, "bar"))
+	c.Check(ev.Mask&inotify.InIsDir, Not(Equals), uint32(0))
+
+	c.Assert(os.Remove(filepath.Join(dir, "foo")), IsNil)
+	ev = nextEvent(c, w)
+	c.Check(ev.Path, Equals, filepath.Join(dir, "foo"))
+	c.Check(ev.Mask&inotify.InDelete, Not(Equals), uint32(0))
+}
+
+func (s *inotifySuite) TestAddWatchError(c *C) {
+	w, err := inotify.NewWatcher()
+	c.Assert(err, IsNil)
+	defer w.Close()
+
+	missing := filepath.Join(c.MkDir(), "missing")
+	err = w.AddWatch(missing, inotify.InCreate)
+	c.Check(err, ErrorMatches, `cannot watch ".*/missing": no such file or directory`)
+}
+
+func (s *inotifySuite) TestCloseClosesEvents(c *C) {
+	w, err := inotify.NewWatcher()
+	c.Assert(err, IsNil)
+	c.Assert(w.AddWatch(c.MkDir(), inotify.InCreate), IsNil)
+
+	c.Assert(w.Close(), IsNil)
+	_, ok := <-w.Events
+	c.Check(ok, Equals, false)
+	// closing again is fine
+	c.Check(w.Close(), IsNil)
+}
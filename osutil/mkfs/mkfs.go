@@ -38,8 +38,10 @@ type MakeFunc func(imgFile, label, contentsRootDir string, deviceSize, sectorSiz
 
 var (
 	mkfsHandlers = map[string]MakeFunc{
-		"vfat": mkfsVfat,
-		"ext4": mkfsExt4,
+		"vfat":  mkfsVfat,
+		"ext4":  mkfsExt4,
+		"btrfs": mkfsBtrfs,
+		"f2fs":  mkfsF2fs,
 	}
 )
 
@@ -208,3 +210,63 @@ func mkfsVfat(img, label, contentsRootDir string, deviceSize, sectorSize quantit
 	}
 	return nil
 }
+
+// mkfsBtrfs creates a Btrfs filesystem in given image file, with an optional
+// filesystem label, and populates it with the contents of provided root
+// directory.
+func mkfsBtrfs(img, label, contentsRootDir string, deviceSize, sectorSize quantity.Size) error {
+	mkfsArgs := []string{
+		// overwrite any existing filesystem, like when reinstalling
+		"-f",
+	}
+	if label != "" {
+		mkfsArgs = append(mkfsArgs, "-L", label)
+	}
+	if contentsRootDir != "" {
+		// mkfs.btrfs can populate the filesystem with contents of given
+		// root directory
+		mkfsArgs = append(mkfsArgs, "--rootdir", contentsRootDir)
+	}
+	mkfsArgs = append(mkfsArgs, img)
+
+	cmd := exec.Command("mkfs.btrfs", mkfsArgs...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return osutil.OutputErr(out, err)
+	}
+	return nil
+}
+
+// mkfsF2fs creates a F2FS filesystem in given image file, with an optional
+// filesystem label, and populates it with the contents of provided root
+// directory.
+func mkfsF2fs(img, label, contentsRootDir string, deviceSize, sectorSize quantity.Size) error {
+	mkfsArgs := []string{
+		// overwrite any existing filesystem, like when reinstalling
+		"-f",
+	}
+	if label != "" {
+		mkfsArgs = append(mkfsArgs, "-l", label)
+	}
+	mkfsArgs = append(mkfsArgs, img)
+
+	cmd := exec.Command("mkfs.f2fs", mkfsArgs...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return osutil.OutputErr(out, err)
+	}
+
+	// if there is no content to copy we are done now
+	if contentsRootDir == "" {
+		return nil
+	}
+
+	// mkfs.f2fs does not know how to populate the filesystem with
+	// contents, use sload.f2fs to place them at the / of the filesystem
+	cmd = exec.Command("sload.f2fs", "-f", contentsRootDir, "-t", "/", img)
+	out, err = cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot populate f2fs filesystem: %v", osutil.OutputErr(out, err))
+	}
+	return nil
+}
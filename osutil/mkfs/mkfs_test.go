@@ -352,6 +352,71 @@ func (m *mkfsSuite) TestMkfsVfatHappyNoContents(c *C) {
 	c.Assert(cmdMcopy.Calls(), HasLen, 0)
 }
 
+func (m *mkfsSuite) TestMkfsBtrfsHappy(c *C) {
+	cmd := testutil.MockCommand(c, "mkfs.btrfs", "")
+	defer cmd.Restore()
+
+	err := mkfs.MakeWithContent("btrfs", "foo.img", "my-label", "contents", 0, 0)
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"mkfs.btrfs", "-f", "-L", "my-label", "--rootdir", "contents", "foo.img"},
+	})
+
+	cmd.ForgetCalls()
+
+	// no label, no content
+	err = mkfs.Make("btrfs", "foo.img", "", 0, 0)
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"mkfs.btrfs", "-f", "foo.img"},
+	})
+}
+
+func (m *mkfsSuite) TestMkfsBtrfsError(c *C) {
+	cmd := testutil.MockCommand(c, "mkfs.btrfs", "echo 'command failed'; exit 1")
+	defer cmd.Restore()
+
+	err := mkfs.MakeWithContent("btrfs", "foo.img", "my-label", "contents", 0, 0)
+	c.Assert(err, ErrorMatches, "command failed")
+}
+
+func (m *mkfsSuite) TestMkfsF2fsHappy(c *C) {
+	cmdMkfs := testutil.MockCommand(c, "mkfs.f2fs", "")
+	defer cmdMkfs.Restore()
+	cmdSload := testutil.MockCommand(c, "sload.f2fs", "")
+	defer cmdSload.Restore()
+
+	err := mkfs.MakeWithContent("f2fs", "foo.img", "my-label", "contents", 0, 0)
+	c.Assert(err, IsNil)
+	c.Check(cmdMkfs.Calls(), DeepEquals, [][]string{
+		{"mkfs.f2fs", "-f", "-l", "my-label", "foo.img"},
+	})
+	c.Check(cmdSload.Calls(), DeepEquals, [][]string{
+		{"sload.f2fs", "-f", "contents", "-t", "/", "foo.img"},
+	})
+
+	cmdMkfs.ForgetCalls()
+	cmdSload.ForgetCalls()
+
+	// no label, no content
+	err = mkfs.Make("f2fs", "foo.img", "", 0, 0)
+	c.Assert(err, IsNil)
+	c.Check(cmdMkfs.Calls(), DeepEquals, [][]string{
+		{"mkfs.f2fs", "-f", "foo.img"},
+	})
+	c.Check(cmdSload.Calls(), HasLen, 0)
+}
+
+func (m *mkfsSuite) TestMkfsF2fsErrorInSload(c *C) {
+	cmdMkfs := testutil.MockCommand(c, "mkfs.f2fs", "")
+	defer cmdMkfs.Restore()
+	cmdSload := testutil.MockCommand(c, "sload.f2fs", "echo 'hard fail'; exit 1")
+	defer cmdSload.Restore()
+
+	err := mkfs.MakeWithContent("f2fs", "foo.img", "my-label", "contents", 0, 0)
+	c.Assert(err, ErrorMatches, "cannot populate f2fs filesystem: hard fail")
+}
+
 func (m *mkfsSuite) TestMkfsInvalidFs(c *C) {
 	err := mkfs.MakeWithContent("no-fs", "foo.img", "my-label", "", 0, 0)
 	c.Assert(err, ErrorMatches, `cannot create unsupported filesystem "no-fs"`)
@@ -20,7 +20,9 @@
 package assertstate
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"gopkg.in/tomb.v2"
 
@@ -42,6 +44,7 @@ func Manager(s *state.State, runner *state.TaskRunner) (*AssertManager, error) {
 	delayedCrossMgrInit()
 
 	runner.AddHandler("validate-snap", doValidateSnap, nil)
+	runner.AddErrorClassifier(classifyAssertionError)
 
 	db, err := sysdb.Open()
 	if err != nil {
@@ -80,6 +83,55 @@ func DB(s *state.State) asserts.RODatabase {
 	return cachedDB(s)
 }
 
+// snapFileSHA3_384 returns the SHA3-384 digest and size of the snap file of
+// the given snap setup, reusing the digest verified while downloading it to
+// avoid reading the whole file once more.
+func snapFileSHA3_384(snapsup *snapstate.SnapSetup) (string, uint64, error) {
+	if snapsup.VerifiedSha3_384 == "" {
+		return asserts.SnapFileSHA3_384(snapsup.SnapPath)
+	}
+	fi, err := os.Stat(snapsup.SnapPath)
+	if err != nil {
+		return "", 0, err
+	}
+	return snapsup.VerifiedSha3_384, uint64(fi.Size()), nil
+}
+
+// snapVerificationError is returned when a snap cannot be verified with
+// its assertions.
+type snapVerificationError struct {
+	snap string
+	err  error
+}
+
+func (e *snapVerificationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *snapVerificationError) Unwrap() error {
+	return e.err
+}
+
+// classifyAssertionError classifies for the task runner the errors of
+// failed tasks caused by missing or invalid assertions.
+func classifyAssertionError(t *state.Task, err error) *state.ErrorDetails {
+	var verifyErr *snapVerificationError
+	if errors.As(err, &verifyErr) {
+		return &state.ErrorDetails{
+			Kind:  "bad-assertion",
+			Value: map[string]interface{}{"snap-name": verifyErr.snap},
+		}
+	}
+	var notFound *asserts.NotFoundError
+	if errors.As(err, &notFound) {
+		return &state.ErrorDetails{
+			Kind:  "bad-assertion",
+			Value: map[string]interface{}{"assertion-type": notFound.Type.Name},
+		}
+	}
+	return nil
+}
+
 // doValidateSnap fetches the relevant assertions for the snap being installed and cross checks them with the snap.
 func doValidateSnap(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
@@ -91,7 +143,7 @@ func doValidateSnap(t *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("internal error: cannot obtain snap setup: %s", err)
 	}
 
-	sha3_384, snapSize, err := asserts.SnapFileSHA3_384(snapsup.SnapPath)
+	sha3_384, snapSize, err := snapFileSHA3_384(snapsup)
 	if err != nil {
 		return err
 	}
@@ -125,9 +177,15 @@ func doValidateSnap(t *state.Task, _ *tomb.Tomb) error {
 	})
 	if notFound, ok := err.(*asserts.NotFoundError); ok {
 		if notFound.Type == asserts.SnapRevisionType {
-			return fmt.Errorf("cannot verify snap %q, no matching signatures found", snapsup.InstanceName())
+			return &snapVerificationError{
+				snap: snapsup.InstanceName(),
+				err:  fmt.Errorf("cannot verify snap %q, no matching signatures found", snapsup.InstanceName()),
+			}
 		} else {
-			return fmt.Errorf("cannot find supported signatures to verify snap %q and its hash (%v)", snapsup.InstanceName(), notFound)
+			return &snapVerificationError{
+				snap: snapsup.InstanceName(),
+				err:  fmt.Errorf("cannot find supported signatures to verify snap %q and its hash (%v)", snapsup.InstanceName(), notFound),
+			}
 		}
 	}
 	if err != nil {
@@ -140,14 +198,14 @@ func doValidateSnap(t *state.Task, _ *tomb.Tomb) error {
 		// TODO: trigger a global validity check
 		// that will generate the changes to deal with this
 		// for things like snap-decl revocation and renames?
-		return err
+		return &snapVerificationError{snap: snapsup.InstanceName(), err: err}
 	}
 
 	// we have an authorized snap-revision with matching hash for
 	// the blob, double check that the snap metadata provenance
 	// matches
 	if err := snapasserts.CheckProvenanceWithVerifiedRevision(snapsup.SnapPath, verifiedRev); err != nil {
-		return err
+		return &snapVerificationError{snap: snapsup.InstanceName(), err: err}
 	}
 
 	// TODO: set DeveloperID from assertions
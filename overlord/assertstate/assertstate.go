@@ -375,6 +375,12 @@ func delayedCrossMgrInit() {
 	snapstate.RestoreValidationSetsTracking = RestoreValidationSetsTracking
 	// hook helper for enforcing validation sets without fetching them
 	snapstate.EnforceValidationSets = ApplyEnforcedValidationSets
+	// hook deriving the side info of snap blobs from their assertions
+	snapstate.DeriveSideInfo = deriveSideInfo
+}
+
+func deriveSideInfo(st *state.State, snapPath string, deviceCtx snapstate.DeviceContext) (*snap.SideInfo, error) {
+	return snapasserts.DeriveSideInfo(snapPath, deviceCtx.Model(), DB(st))
 }
 
 // AutoRefreshAssertions tries to refresh all assertions
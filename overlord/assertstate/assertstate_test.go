@@ -25,6 +25,9 @@ import (
 	"crypto"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
@@ -86,6 +89,10 @@ type fakeStore struct {
 
 	snapActionErr         error
 	downloadAssertionsErr error
+	// snapActionErr is returned only once snapActionErrAfter calls
+	// to SnapAction have been made
+	snapActionErrAfter int
+	snapActionCalls    int
 }
 
 func (sto *fakeStore) pokeStateLock() {
@@ -117,7 +124,8 @@ func (sto *fakeStore) SnapAction(_ context.Context, currentSnaps []*store.Curren
 		return nil, nil, err
 	}
 
-	if sto.snapActionErr != nil {
+	sto.snapActionCalls++
+	if sto.snapActionErr != nil && sto.snapActionCalls > sto.snapActionErrAfter {
 		return nil, nil, sto.snapActionErr
 	}
 
@@ -765,6 +773,71 @@ func (s *assertMgrSuite) TestValidateSnap(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *assertMgrSuite) TestValidateSnapUsesVerifiedDigest(c *C) {
+	headers := map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "snap-id-1",
+		"snap-name":    "foo",
+		"publisher-id": s.dev1Acct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+	snapDecl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, headers, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(s.storeSigning.Add(snapDecl), IsNil)
+
+	// the digest is taken from the snap setup, the snap is not read
+	// again to compute it
+	snapPath := filepath.Join(c.MkDir(), "foo_10.snap")
+	c.Assert(os.MkdirAll(filepath.Join(snapPath, "meta"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(snapPath, "meta/snap.yaml"), []byte("name: foo\nversion: 1\n"), 0644), IsNil)
+	fi, err := os.Stat(snapPath)
+	c.Assert(err, IsNil)
+	digest := makeDigest(10)
+	headers = map[string]interface{}{
+		"snap-id":       "snap-id-1",
+		"snap-sha3-384": digest,
+		"snap-size":     fmt.Sprintf("%d", fi.Size()),
+		"snap-revision": "10",
+		"developer-id":  s.dev1Acct.AccountID(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}
+	snapRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, headers, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(s.storeSigning.Add(snapRev), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupModelAndStore(c)
+
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("validate-snap", "Fetch and check snap assertions")
+	snapsup := snapstate.SnapSetup{
+		SnapPath: snapPath,
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "snap-id-1",
+			Revision: snap.R(10),
+		},
+		VerifiedSha3_384: digest,
+	}
+	t.Set("snap-setup", snapsup)
+	chg.AddTask(t)
+
+	s.state.Unlock()
+	defer s.se.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+
+	_, err = assertstate.DB(s.state).Find(asserts.SnapRevisionType, map[string]string{
+		"snap-id":       "snap-id-1",
+		"snap-sha3-384": digest,
+	})
+	c.Assert(err, IsNil)
+}
+
 func (s *assertMgrSuite) TestValidateSnapStoreNotFound(c *C) {
 	paths, digests := s.prereqSnapAssertions(c, 10)
 
@@ -825,6 +898,24 @@ func (s *assertMgrSuite) TestValidateSnapMissingSnapSetup(c *C) {
 	s.state.Lock()
 
 	c.Assert(chg.Err(), ErrorMatches, `(?s).*internal error: cannot obtain snap setup: no state entry for key.*`)
+	c.Check(t.ErrorDetails(), IsNil)
+}
+
+func (s *assertMgrSuite) TestClassifyAssertionError(c *C) {
+	details := assertstate.ClassifyAssertionError(nil, assertstate.NewSnapVerificationError("foo", errors.New("cannot verify")))
+	c.Check(details, DeepEquals, &state.ErrorDetails{
+		Kind:  "bad-assertion",
+		Value: map[string]interface{}{"snap-name": "foo"},
+	})
+
+	notFound := &asserts.NotFoundError{Type: asserts.SnapDeclarationType}
+	details = assertstate.ClassifyAssertionError(nil, fmt.Errorf("cannot refresh: %w", notFound))
+	c.Check(details, DeepEquals, &state.ErrorDetails{
+		Kind:  "bad-assertion",
+		Value: map[string]interface{}{"assertion-type": "snap-declaration"},
+	})
+
+	c.Check(assertstate.ClassifyAssertionError(nil, errors.New("boom")), IsNil)
 }
 
 func (s *assertMgrSuite) TestValidateSnapNotFound(c *C) {
@@ -855,6 +946,10 @@ func (s *assertMgrSuite) TestValidateSnapNotFound(c *C) {
 	s.state.Lock()
 
 	c.Assert(chg.Err(), ErrorMatches, `(?s).*cannot verify snap "foo", no matching signatures found.*`)
+	c.Check(t.ErrorDetails(), DeepEquals, &state.ErrorDetails{
+		Kind:  "bad-assertion",
+		Value: map[string]interface{}{"snap-name": "foo"},
+	})
 }
 
 func (s *assertMgrSuite) TestValidateSnapCrossCheckFail(c *C) {
@@ -887,6 +982,10 @@ func (s *assertMgrSuite) TestValidateSnapCrossCheckFail(c *C) {
 	s.state.Lock()
 
 	c.Assert(chg.Err(), ErrorMatches, `(?s).*cannot install "f", snap "f" is undergoing a rename to "foo".*`)
+	c.Check(t.ErrorDetails(), DeepEquals, &state.ErrorDetails{
+		Kind:  "bad-assertion",
+		Value: map[string]interface{}{"snap-name": "f"},
+	})
 }
 
 func (s *assertMgrSuite) TestValidateDelegatedSnap(c *C) {
@@ -1789,6 +1888,66 @@ func (s *assertMgrSuite) TestRefreshSnapDeclarationsMany17NoStoreMergeErrors(c *
 	})
 }
 
+func (s *assertMgrSuite) TestRefreshSnapDeclarationsMany17NoStoreResume(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setModel(sysdb.GenericClassicModel())
+
+	// fail the 2nd round, 2 calls for the 1st one including the one
+	// reporting no more results
+	fakeStore := s.fakeStore.(*fakeStore)
+	fakeStore.snapActionErr = new(httputil.PersistentNetworkError)
+	fakeStore.snapActionErrAfter = 2
+
+	err := s.testRefreshSnapDeclarationsMany(c, 17)
+	c.Assert(err, FitsTypeOf, new(httputil.PersistentNetworkError))
+
+	// the progress of the 1st round was recorded
+	var progress map[string]interface{}
+	c.Assert(s.state.Get("snap-declarations-refresh-progress", &progress), IsNil)
+	c.Check(progress["done"], HasLen, 16)
+	c.Check(progress["done"], Not(testutil.DeepContains), "foo9")
+
+	fakeStore.snapActionErr = nil
+	fakeStore.requestedTypes = nil
+
+	// resumes with the remaining snap
+	err = assertstate.RefreshSnapDeclarations(s.state, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(fakeStore.requestedTypes, DeepEquals, [][]string{
+		{"account", "account-key", "snap-declaration"},
+	})
+
+	a, err := assertstate.DB(s.state).Find(asserts.SnapDeclarationType, map[string]string{
+		"series":  "16",
+		"snap-id": "foo9-id",
+	})
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SnapDeclaration).SnapName(), Equals, "fo-o-9")
+
+	// the refresh completed
+	err = s.state.Get("snap-declarations-refresh-progress", &progress)
+	c.Check(errors.Is(err, state.ErrNoState), Equals, true)
+}
+
+func (s *assertMgrSuite) TestRefreshSnapDeclarationsIgnoresStaleProgress(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.setModel(sysdb.GenericClassicModel())
+
+	s.state.Set("snap-declarations-refresh-progress", map[string]interface{}{
+		"started": time.Now().Add(-7 * time.Hour),
+		"done":    []string{"foo1", "foo2"},
+	})
+
+	err := s.testRefreshSnapDeclarationsMany(c, 2)
+	c.Assert(err, IsNil)
+
+	var progress map[string]interface{}
+	err = s.state.Get("snap-declarations-refresh-progress", &progress)
+	c.Check(errors.Is(err, state.ErrNoState), Equals, true)
+}
+
 func (s *assertMgrSuite) TestRefreshSnapDeclarationsMany31WithStore(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -21,9 +21,11 @@ package assertstate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
@@ -44,28 +46,85 @@ const storeGroup = "store assertion"
 // that. Most systems should be done in one request anyway.
 var maxGroups = 256
 
+// snapDeclarationsRefreshProgress records the snaps whose snap-declarations
+// were refreshed so far by a bulk refresh, so that a refresh interrupted by
+// an error can be resumed from there instead of from scratch.
+type snapDeclarationsRefreshProgress struct {
+	Started time.Time `json:"started"`
+	Done    []string  `json:"done"`
+}
+
+// resumeRefreshWindow is for how long the progress of an interrupted bulk
+// refresh of snap-declarations is used to resume it.
+var resumeRefreshWindow = 6 * time.Hour
+
+func snapDeclarationsRefreshProgressFromState(s *state.State) (*snapDeclarationsRefreshProgress, error) {
+	var progress snapDeclarationsRefreshProgress
+	err := s.Get("snap-declarations-refresh-progress", &progress)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if err != nil || time.Since(progress.Started) > resumeRefreshWindow {
+		return &snapDeclarationsRefreshProgress{Started: time.Now()}, nil
+	}
+	return &progress, nil
+}
+
 func bulkRefreshSnapDeclarations(s *state.State, snapStates map[string]*snapstate.SnapState, userID int, deviceCtx snapstate.DeviceContext, opts *RefreshAssertionsOptions) error {
 	db := cachedDB(s)
 
 	pool := asserts.NewPool(db, maxGroups)
 
+	progress, err := snapDeclarationsRefreshProgressFromState(s)
+	if err != nil {
+		return err
+	}
+	done := make(map[string]bool, len(progress.Done))
+	for _, instanceName := range progress.Done {
+		done[instanceName] = true
+	}
+
 	var mergedRPErr *resolvePoolError
+	// snaps whose snap-declarations are being resolved in the
+	// current round
+	var round []string
 	tryResolvePool := func() error {
 		err := resolvePool(s, pool, nil, userID, deviceCtx, opts)
-		if rpe, ok := err.(*resolvePoolError); ok {
+		rpe, ok := err.(*resolvePoolError)
+		if err != nil && !ok {
+			return err
+		}
+		if rpe != nil {
 			if mergedRPErr == nil {
 				mergedRPErr = rpe
 			} else {
 				mergedRPErr.merge(rpe)
 			}
-			return nil
 		}
-		return err
+		// record the progress so that an error in a later round
+		// does not require refreshing these again
+		for _, instanceName := range round {
+			if rpe != nil && rpe.errors[instanceName] != nil {
+				continue
+			}
+			progress.Done = append(progress.Done, instanceName)
+		}
+		round = round[:0]
+		s.Set("snap-declarations-refresh-progress", progress)
+		return nil
+	}
+
+	instanceNames := make([]string, 0, len(snapStates))
+	for instanceName := range snapStates {
+		instanceNames = append(instanceNames, instanceName)
 	}
+	sort.Strings(instanceNames)
 
-	c := 0
-	for instanceName, snapst := range snapStates {
-		sideInfo := snapst.CurrentSideInfo()
+	for _, instanceName := range instanceNames {
+		if done[instanceName] {
+			continue
+		}
+		sideInfo := snapStates[instanceName].CurrentSideInfo()
 		if sideInfo.SnapID == "" {
 			continue
 		}
@@ -80,15 +139,16 @@ func bulkRefreshSnapDeclarations(s *state.State, snapStates map[string]*snapstat
 			return fmt.Errorf("cannot prepare snap-declaration refresh for snap %q: %v", instanceName, err)
 		}
 
-		c++
-		if c%maxGroups == 0 {
+		round = append(round, instanceName)
+		if len(round) == maxGroups {
 			// we have exhausted max groups, resolve
-			// what we setup so far and then clear groups
-			// to reuse the pool
+			// what we setup so far and then clear the pool
+			// to reuse it, the resolved assertions are now
+			// in the database and need not be kept around
 			if err := tryResolvePool(); err != nil {
 				return err
 			}
-			if err := pool.ClearGroups(); err != nil {
+			if err := pool.ClearResolved(); err != nil {
 				// this shouldn't happen but if it
 				// does fallback
 				return &bulkAssertionFallbackError{err}
@@ -124,6 +184,8 @@ func bulkRefreshSnapDeclarations(s *state.State, snapStates map[string]*snapstat
 	if err := tryResolvePool(); err != nil {
 		return err
 	}
+	// the refresh went through all the snaps
+	s.Set("snap-declarations-refresh-progress", nil)
 
 	if mergedRPErr != nil {
 		if e := mergedRPErr.errors[storeGroup]; asserts.IsNotFound(e) || e == asserts.ErrUnresolved {
@@ -43,3 +43,9 @@ func MockMaxValidationSetsHistorySize(n int) (restore func()) {
 		maxValidationSetsHistorySize = oldMaxValidationSetsHistorySize
 	}
 }
+
+var ClassifyAssertionError = classifyAssertionError
+
+func NewSnapVerificationError(snap string, err error) error {
+	return &snapVerificationError{snap: snap, err: err}
+}
@@ -20,20 +20,72 @@
 package overlord
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 type overlordStateBackend struct {
 	path         string
 	ensureBefore func(d time.Duration)
+
+	keyMu sync.Mutex
+	key   []byte
 }
 
+// stateEncryptionKey returns the key the sensitive parts of the state are
+// encrypted with, as provided by the initramfs for the current boot.
+var stateEncryptionKey = state.EncryptionKey
+
 func (osb *overlordStateBackend) Checkpoint(data []byte) error {
+	if features.EncryptedState.IsEnabled() {
+		key, err := osb.encryptionKey()
+		if err != nil {
+			// never fall back to writing the sensitive parts in
+			// the clear
+			return fmt.Errorf("cannot encrypt state: %v", err)
+		}
+		data, err = state.EncryptData(data, key)
+		if err != nil {
+			return err
+		}
+	}
 	return osutil.AtomicWriteFile(osb.path, data, 0600, 0)
 }
 
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
 	osb.ensureBefore(d)
 }
+
+// encryptionKey returns the key used to encrypt the sensitive parts of
+// the state, or state.ErrNoEncryptionKey if none is available.
+func (osb *overlordStateBackend) encryptionKey() ([]byte, error) {
+	osb.keyMu.Lock()
+	defer osb.keyMu.Unlock()
+
+	if osb.key != nil {
+		return osb.key, nil
+	}
+	key, err := stateEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	osb.key = key
+	return key, nil
+}
+
+// decrypt returns the given state data with any encrypted parts decrypted.
+func (osb *overlordStateBackend) decrypt(data []byte) ([]byte, error) {
+	if !state.IsEncryptedData(data) {
+		return data, nil
+	}
+	key, err := osb.encryptionKey()
+	if err != nil && err != state.ErrNoEncryptionKey {
+		return nil, err
+	}
+	return state.DecryptData(data, key)
+}
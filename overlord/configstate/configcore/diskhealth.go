@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/settings"
+)
+
+func init() {
+	supportedConfigurations["core.disk-health.disabled"] = true
+	supportedConfigurations["core.disk-health.interval"] = true
+	supportedConfigurations["core.disk-health.wear-threshold"] = true
+}
+
+func validateDiskHealthSettings(tr config.Conf) error {
+	if err := validateBoolFlag(tr, "disk-health.disabled"); err != nil {
+		return err
+	}
+	for key, parse := range map[string]func(string) error{
+		"disk-health.interval": func(v string) error {
+			_, err := settings.ParseDiskHealthInterval(v)
+			return err
+		},
+		"disk-health.wear-threshold": func(v string) error {
+			_, err := settings.ParseDiskHealthWearThreshold(v)
+			return err
+		},
+	} {
+		v, err := coreCfg(tr, key)
+		if err != nil {
+			return err
+		}
+		if v == "" {
+			continue
+		}
+		if err := parse(v); err != nil {
+			return fmt.Errorf("cannot set %s to %q: %v", key, v, err)
+		}
+	}
+	return nil
+}
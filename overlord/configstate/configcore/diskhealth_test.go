@@ -0,0 +1,62 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type diskHealthSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&diskHealthSuite{})
+
+func (s *diskHealthSuite) TestConfigureDiskHealth(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"disk-health.disabled":       "false",
+			"disk-health.interval":       "12h",
+			"disk-health.wear-threshold": "70%",
+		},
+	})
+	c.Check(err, IsNil)
+}
+
+func (s *diskHealthSuite) TestConfigureDiskHealthRejected(c *C) {
+	for _, t := range []struct {
+		key, value, err string
+	}{
+		{"disk-health.disabled", "maybe", `disk-health.disabled can only be set to 'true' or 'false'`},
+		{"disk-health.interval", "5m", `cannot set disk-health.interval to "5m": interval must be at least 1h0m0s`},
+		{"disk-health.wear-threshold", "80", `cannot set disk-health.wear-threshold to "80": wear threshold must be a percentage`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%s", t.key, t.value))
+	}
+}
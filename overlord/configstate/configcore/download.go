@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+// +build !nomanagers
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+)
+
+func init() {
+	supportedConfigurations["core.download.direct-io"] = true
+	supportedConfigurations["core.download.mirror"] = true
+}
+
+func validateDownloadSettings(tr config.Conf) error {
+	if err := validateBoolFlag(tr, "download.direct-io"); err != nil {
+		return err
+	}
+
+	mirror, err := coreCfg(tr, "download.mirror")
+	if err != nil {
+		return err
+	}
+	if mirror == "" {
+		return nil
+	}
+	u, err := url.Parse(mirror)
+	if err != nil {
+		return fmt.Errorf("cannot parse download.mirror: %v", err)
+	}
+	// no credentials are sent to the mirror, but the transport must
+	// still be protected
+	if u.Scheme != "https" || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("download.mirror must be an https URL without credentials, query or fragment, not %q", mirror)
+	}
+	return nil
+}
@@ -20,6 +20,7 @@
 package configcore
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
@@ -27,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/sysconfig"
 )
 
@@ -37,6 +39,8 @@ func init() {
 	}
 }
 
+var stateEncryptionKey = state.EncryptionKey
+
 func earlyExperimentalSettingsFilter(values, early map[string]interface{}) {
 	for key, v := range values {
 		if strings.HasPrefix(key, "experimental.") && supportedConfigurations["core."+key] {
@@ -54,6 +58,18 @@ func validateExperimentalSettings(tr config.ConfGetter) error {
 			return err
 		}
 	}
+
+	// snapd refuses to run with encrypted state but without the key
+	encryptedState, err := features.Flag(tr, features.EncryptedState)
+	if err != nil {
+		return err
+	}
+	if encryptedState {
+		if _, err := stateEncryptionKey(); err != nil {
+			_, confName := features.EncryptedState.ConfigOption()
+			return fmt.Errorf("cannot enable %s: %v", confName, err)
+		}
+	}
 	return nil
 }
 
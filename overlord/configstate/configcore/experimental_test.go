@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -53,6 +54,9 @@ func (s *experimentalSuite) TestConfigureExperimentalSettingsInvalid(c *C) {
 }
 
 func (s *experimentalSuite) TestConfigureExperimentalSettingsHappy(c *C) {
+	// needed for experimental.encrypted-state
+	c.Assert(state.ProvideEncryptionKey([]byte("secret")), IsNil)
+
 	for _, feature := range features.KnownFeatures() {
 		for _, t := range []string{"true", "false"} {
 			conf := &mockConf{
@@ -65,6 +69,19 @@ func (s *experimentalSuite) TestConfigureExperimentalSettingsHappy(c *C) {
 	}
 }
 
+func (s *experimentalSuite) TestConfigureEncryptedStateNoKey(c *C) {
+	conf := &mockConf{
+		state:   s.state,
+		changes: map[string]interface{}{featureConf(features.EncryptedState): true},
+	}
+	err := configcore.Run(classicDev, conf)
+	c.Check(err, ErrorMatches, `cannot enable experimental.encrypted-state: no state encryption key available`)
+	c.Check(features.EncryptedState.ControlFile(), testutil.FileAbsent)
+
+	conf.changes[featureConf(features.EncryptedState)] = false
+	c.Check(configcore.Run(classicDev, conf), IsNil)
+}
+
 func (s *experimentalSuite) TestExportedFeatures(c *C) {
 	conf := &mockConf{
 		state: s.state,
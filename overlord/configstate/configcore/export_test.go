@@ -24,6 +24,7 @@ import (
 
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/testutil"
@@ -105,3 +106,9 @@ func MockDevicestateResetSession(f func(*state.State) error) (restore func()) {
 	devicestateResetSession = f
 	return restore
 }
+
+func MockDevicestateReloadClientCertificate(f func(*state.State, config.ConfGetter) error) (restore func()) {
+	restore = testutil.Backup(&devicestateReloadClientCertificate)
+	devicestateReloadClientCertificate = f
+	return restore
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+// +build !nomanagers
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+)
+
+const (
+	minFreezeTimeout = 100 * time.Millisecond
+	maxFreezeTimeout = 5 * time.Minute
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.freeze.timeout"] = true
+	supportedConfigurations["core.freeze.disabled"] = true
+}
+
+// validateFreezeSettings validates the options controlling the freezing of
+// the processes of snaps while their data is copied at refresh or saved in a
+// snapshot.
+func validateFreezeSettings(tr config.Conf) error {
+	if err := validateBoolFlag(tr, "freeze.disabled"); err != nil {
+		return err
+	}
+
+	timeoutStr, err := coreCfg(tr, "freeze.timeout")
+	if err != nil {
+		return err
+	}
+	if timeoutStr == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil {
+		return fmt.Errorf("freeze.timeout cannot be parsed: %v", err)
+	}
+	if timeout < minFreezeTimeout || timeout > maxFreezeTimeout {
+		return fmt.Errorf("freeze.timeout must be between %v and %v", minFreezeTimeout, maxFreezeTimeout)
+	}
+	return nil
+}
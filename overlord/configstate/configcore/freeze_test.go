@@ -0,0 +1,71 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type freezeSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&freezeSuite{})
+
+func (s *freezeSuite) TestConfigureFreezeHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"freeze.timeout":  "10s",
+			"freeze.disabled": "false",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *freezeSuite) TestConfigureFreezeDisabled(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"freeze.disabled": "true",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *freezeSuite) TestConfigureFreezeInvalid(c *C) {
+	for _, tc := range []struct {
+		conf map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"freeze.timeout": "invalid"}, `freeze.timeout cannot be parsed:.*`},
+		{map[string]interface{}{"freeze.timeout": "10ms"}, `freeze.timeout must be between 100ms and 5m0s`},
+		{map[string]interface{}{"freeze.timeout": "1h"}, `freeze.timeout must be between 100ms and 5m0s`},
+		{map[string]interface{}{"freeze.disabled": "maybe"}, `freeze.disabled can only be set to 'true' or 'false'`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  tc.conf,
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.conf))
+	}
+}
@@ -113,6 +113,12 @@ func init() {
 	// system.faillock
 	addFSOnlyHandler(validateFaillockSettings, handleFaillockConfiguration, coreOnly)
 
+	// problem-reports.{sink,http.url,http.certificate,spool.dir}
+	addFSOnlyHandler(validateProblemReportsSettings, handleProblemReportsConfiguration, nil)
+
+	// desktop.launch-policy.*
+	addFSOnlyHandler(validateLaunchPolicySettings, handleLaunchPolicyConfiguration, nil)
+
 	sysconfig.ApplyFilesystemOnlyDefaultsImpl = filesystemOnlyApply
 }
 
@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/settings"
+)
+
+var hookLimitParsers = map[string]func(string) error{
+	"timeout": func(v string) error {
+		_, err := settings.ParseHookTimeout(v)
+		return err
+	},
+	"memory-max": func(v string) error {
+		_, err := settings.ParseHookMemoryMax(v)
+		return err
+	},
+	"cpu-quota": func(v string) error {
+		_, err := settings.ParseHookCPUQuota(v)
+		return err
+	},
+}
+
+func init() {
+	// add supported configuration of this module
+	for _, class := range settings.HookLimitClasses {
+		for limit := range hookLimitParsers {
+			supportedConfigurations[fmt.Sprintf("core.hooks.%s.%s", class, limit)] = true
+		}
+	}
+}
+
+func validateHookLimitsSettings(tr config.Conf) error {
+	for _, class := range settings.HookLimitClasses {
+		for limit, parse := range hookLimitParsers {
+			key := fmt.Sprintf("hooks.%s.%s", class, limit)
+			v, err := coreCfg(tr, key)
+			if err != nil {
+				return err
+			}
+			if v == "" {
+				continue
+			}
+			if err := parse(v); err != nil {
+				return fmt.Errorf("cannot set %s to %q: %v", key, v, err)
+			}
+		}
+	}
+	return nil
+}
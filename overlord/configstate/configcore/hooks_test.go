@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type hooksSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&hooksSuite{})
+
+func (s *hooksSuite) TestConfigureHookLimits(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"hooks.default.timeout":           "15m",
+			"hooks.configure.memory-max":      "256MB",
+			"hooks.install-device.cpu-quota":  "200%",
+			"hooks.install-device.timeout":    "1h",
+			"hooks.install-device.memory-max": "",
+		},
+	})
+	c.Check(err, IsNil)
+}
+
+func (s *hooksSuite) TestConfigureHookLimitsRejected(c *C) {
+	for _, t := range []struct {
+		key, value, err string
+	}{
+		{"hooks.configure.timeout", "-1s", `cannot set hooks.configure.timeout to "-1s": timeout must be positive`},
+		{"hooks.default.memory-max", "lots", `cannot set hooks.default.memory-max to "lots": cannot parse "lots": .*`},
+		{"hooks.install-device.cpu-quota", "0%", `cannot set hooks.install-device.cpu-quota to "0%": cpu quota must be a positive percentage`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%s", t.key, t.value))
+	}
+}
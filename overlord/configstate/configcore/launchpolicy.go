@@ -0,0 +1,228 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/desktop/launchpolicy"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/sysconfig"
+)
+
+// The session launcher of snap userd, which opens URLs and files on behalf
+// of snaps, can be restricted with options of the form
+//
+//	snap set system desktop.launch-policy.mode=restricted
+//	snap set system desktop.launch-policy.allowed-schemes=https,mailto
+//	snap set system desktop.launch-policy.snaps.<snap>.allowed-schemes=zoommtg
+//	snap set system desktop.launch-policy.snaps.<snap>.allowed-handlers=firefox_firefox.desktop
+//	snap set system desktop.launch-policy.snaps.<snap>.allow-files=true
+//
+// In the default mode the allowed schemes extend those the launcher
+// accepts anyway. In the restricted mode only the allowed schemes can be
+// opened and files can only be opened by snaps explicitly allowed to.
+
+const (
+	launchPolicyOption      = "desktop.launch-policy"
+	launchPolicySnapsOption = launchPolicyOption + ".snaps"
+)
+
+var (
+	validURLScheme     = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+	validDesktopFileID = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*\.desktop$`)
+)
+
+func isLaunchPolicyChange(chg string) bool {
+	return chg == "core."+launchPolicyOption || strings.HasPrefix(chg, "core."+launchPolicyOption+".")
+}
+
+func launchPolicyList(key string, v interface{}, valid *regexp.Regexp) ([]string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("cannot set %s: value must be a comma separated list", key)
+	}
+	var l []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !valid.MatchString(item) {
+			return nil, fmt.Errorf("cannot set %s: invalid value %q", key, item)
+		}
+		l = append(l, item)
+	}
+	return l, nil
+}
+
+func launchPolicySchemes(key string, v interface{}) ([]string, error) {
+	schemes, err := launchPolicyList(key, v, validURLScheme)
+	if err != nil {
+		return nil, err
+	}
+	for _, scheme := range schemes {
+		if scheme == "file" {
+			// files are opened with the OpenFile method
+			return nil, fmt.Errorf("cannot set %s: scheme %q cannot be allowed", key, scheme)
+		}
+	}
+	return schemes, nil
+}
+
+func launchPolicyBool(key string, v interface{}) (bool, error) {
+	switch v {
+	case true, "true":
+		return true, nil
+	case false, "false", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("cannot set %s: value must be 'true' or 'false'", key)
+}
+
+func launchPolicySnap(snapName string, doc interface{}) (*launchpolicy.SnapPolicy, error) {
+	if err := snap.ValidateName(snapName); err != nil {
+		return nil, fmt.Errorf("cannot set %s.%s: %v", launchPolicySnapsOption, snapName, err)
+	}
+	opts, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot set %s.%s: value must be a map of options", launchPolicySnapsOption, snapName)
+	}
+	var sp launchpolicy.SnapPolicy
+	var err error
+	for name, v := range opts {
+		key := fmt.Sprintf("%s.%s.%s", launchPolicySnapsOption, snapName, name)
+		switch name {
+		case "allowed-schemes":
+			sp.AllowedSchemes, err = launchPolicySchemes(key, v)
+		case "allowed-handlers":
+			sp.AllowedHandlers, err = launchPolicyList(key, v, validDesktopFileID)
+		case "allow-files":
+			sp.AllowFiles, err = launchPolicyBool(key, v)
+		default:
+			err = fmt.Errorf("cannot set %s: unsupported launch policy option", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &sp, nil
+}
+
+// getLaunchPolicy returns the launch policy described by the
+// desktop.launch-policy options, or nil if none is set.
+func getLaunchPolicy(tr config.ConfGetter) (*launchpolicy.Policy, error) {
+	var doc interface{}
+	if err := tr.Get("core", launchPolicyOption, &doc); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, nil
+	}
+	opts, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot set %s: value must be a map of options", launchPolicyOption)
+	}
+	if len(opts) == 0 {
+		return nil, nil
+	}
+
+	var p launchpolicy.Policy
+	// sorted for stable error messages
+	names := make([]string, 0, len(opts))
+	for name := range opts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v := opts[name]
+		key := launchPolicyOption + "." + name
+		switch name {
+		case "mode":
+			switch v {
+			case "", "default":
+			case "restricted":
+				p.Restricted = true
+			default:
+				return nil, fmt.Errorf("%s can only be set to 'default' or 'restricted'", key)
+			}
+		case "allowed-schemes":
+			schemes, err := launchPolicySchemes(key, v)
+			if err != nil {
+				return nil, err
+			}
+			p.AllowedSchemes = schemes
+		case "snaps":
+			snaps, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("cannot set %s: value must be a map of snaps", key)
+			}
+			for snapName, snapDoc := range snaps {
+				sp, err := launchPolicySnap(snapName, snapDoc)
+				if err != nil {
+					return nil, err
+				}
+				if p.Snaps == nil {
+					p.Snaps = make(map[string]*launchpolicy.SnapPolicy, len(snaps))
+				}
+				p.Snaps[snapName] = sp
+			}
+		default:
+			return nil, fmt.Errorf("cannot set %s: unsupported launch policy option", key)
+		}
+	}
+	return &p, nil
+}
+
+func validateLaunchPolicySettings(tr config.ConfGetter) error {
+	_, err := getLaunchPolicy(tr)
+	return err
+}
+
+func handleLaunchPolicyConfiguration(_ sysconfig.Device, tr config.ConfGetter, opts *fsOnlyContext) error {
+	p, err := getLaunchPolicy(tr)
+	if err != nil {
+		return err
+	}
+
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+	if err := launchpolicy.Write(dirs.SnapLaunchPolicyFileUnder(rootDir), p); err != nil {
+		return err
+	}
+
+	// the launch policy is only enforced by the session launcher, snaps
+	// must not open URLs and files via the portals instead
+	changed, err := apparmorUpdateLaunchPolicyRules(rootDir, p != nil)
+	if err != nil {
+		return err
+	}
+	if !changed || opts != nil {
+		return nil
+	}
+	return apparmorReloadAllSnapProfiles()
+}
@@ -0,0 +1,173 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/desktop/launchpolicy"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type launchPolicySuite struct {
+	configcoreSuite
+
+	reloads int
+}
+
+var _ = Suite(&launchPolicySuite{})
+
+func (s *launchPolicySuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	s.reloads = 0
+	s.AddCleanup(configcore.MockApparmorReloadAllSnapProfiles(func() error {
+		s.reloads++
+		return nil
+	}))
+}
+
+func (s *launchPolicySuite) run(c *C, values map[string]interface{}) error {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	s.state.Unlock()
+	for k, v := range values {
+		c.Assert(tr.Set("core", k, v), IsNil)
+	}
+	err := configcore.Run(classicDev, tr)
+	if err == nil {
+		s.state.Lock()
+		tr.Commit()
+		s.state.Unlock()
+	}
+	return err
+}
+
+func (s *launchPolicySuite) TestLaunchPolicyHappy(c *C) {
+	err := s.run(c, map[string]interface{}{
+		"desktop.launch-policy.mode":                         "restricted",
+		"desktop.launch-policy.allowed-schemes":              "https, mailto",
+		"desktop.launch-policy.snaps.kiosk.allowed-schemes":  "zoommtg",
+		"desktop.launch-policy.snaps.kiosk.allowed-handlers": "firefox_firefox.desktop,org.gnome.Evolution.desktop",
+		"desktop.launch-policy.snaps.kiosk.allow-files":      true,
+		"desktop.launch-policy.snaps.other.allow-files":      "false",
+	})
+	c.Assert(err, IsNil)
+
+	p, err := launchpolicy.Read(dirs.SnapLaunchPolicyFile)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, &launchpolicy.Policy{
+		Restricted:     true,
+		AllowedSchemes: []string{"https", "mailto"},
+		Snaps: map[string]*launchpolicy.SnapPolicy{
+			"kiosk": {
+				AllowedSchemes:  []string{"zoommtg"},
+				AllowedHandlers: []string{"firefox_firefox.desktop", "org.gnome.Evolution.desktop"},
+				AllowFiles:      true,
+			},
+			"other": {},
+		},
+	})
+	// the portal is denied to snaps so that they cannot bypass the
+	// policy
+	rulesFile := filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/apparmor/launch-policy")
+	c.Check(rulesFile, testutil.FileContains, "interface=org.freedesktop.portal.OpenURI,")
+	c.Check(s.reloads > 0, Equals, true)
+
+	// changing the policy does not need to reload the profiles
+	reloads := s.reloads
+	err = s.run(c, map[string]interface{}{
+		"desktop.launch-policy.mode": "default",
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.reloads, Equals, reloads)
+
+	// unsetting the policy removes the files
+	err = s.run(c, map[string]interface{}{
+		"desktop.launch-policy": nil,
+	})
+	c.Assert(err, IsNil)
+	c.Check(dirs.SnapLaunchPolicyFile, testutil.FileAbsent)
+	c.Check(rulesFile, testutil.FileAbsent)
+	c.Check(s.reloads, Equals, reloads+1)
+}
+
+func (s *launchPolicySuite) TestLaunchPolicyDefaultMode(c *C) {
+	err := s.run(c, map[string]interface{}{
+		"desktop.launch-policy.allowed-schemes": "zoommtg",
+	})
+	c.Assert(err, IsNil)
+
+	p, err := launchpolicy.Read(dirs.SnapLaunchPolicyFile)
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, &launchpolicy.Policy{
+		AllowedSchemes: []string{"zoommtg"},
+	})
+}
+
+func (s *launchPolicySuite) TestLaunchPolicyValidation(c *C) {
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"desktop.launch-policy", "restricted", `cannot set desktop.launch-policy: value must be a map of options`},
+		{"desktop.launch-policy.mode", "kiosk", `desktop.launch-policy.mode can only be set to 'default' or 'restricted'`},
+		{"desktop.launch-policy.foo", "bar", `cannot set desktop.launch-policy.foo: unsupported launch policy option`},
+		{"desktop.launch-policy.allowed-schemes", "https,file", `cannot set desktop.launch-policy.allowed-schemes: scheme "file" cannot be allowed`},
+		{"desktop.launch-policy.allowed-schemes", "Http", `cannot set desktop.launch-policy.allowed-schemes: invalid value "Http"`},
+		{"desktop.launch-policy.allowed-schemes", 1, `cannot set desktop.launch-policy.allowed-schemes: value must be a comma separated list`},
+		{"desktop.launch-policy.snaps", "kiosk", `cannot set desktop.launch-policy.snaps: value must be a map of snaps`},
+		{"desktop.launch-policy.snaps.kiosk", "true", `cannot set desktop.launch-policy.snaps.kiosk: value must be a map of options`},
+		{"desktop.launch-policy.snaps.a-very-long-snap-name-that-cannot-be-a-snap.allow-files", true, `cannot set desktop.launch-policy.snaps.a-very-long-snap-name-that-cannot-be-a-snap: invalid snap name: "a-very-long-snap-name-that-cannot-be-a-snap"`},
+		{"desktop.launch-policy.snaps.kiosk.allow-files", "yes", `cannot set desktop.launch-policy.snaps.kiosk.allow-files: value must be 'true' or 'false'`},
+		{"desktop.launch-policy.snaps.kiosk.allowed-handlers", "firefox", `cannot set desktop.launch-policy.snaps.kiosk.allowed-handlers: invalid value "firefox"`},
+		{"desktop.launch-policy.snaps.kiosk.foo", "bar", `cannot set desktop.launch-policy.snaps.kiosk.foo: unsupported launch policy option`},
+	} {
+		err := s.run(c, map[string]interface{}{t.key: t.value})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%v", t.key, t.value))
+		c.Check(dirs.SnapLaunchPolicyFile, testutil.FileAbsent)
+	}
+}
+
+func (s *launchPolicySuite) TestFilesystemOnlyApply(c *C) {
+	tmpDir := c.MkDir()
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"desktop.launch-policy": map[string]interface{}{
+			"mode": "restricted",
+		},
+	})
+	c.Assert(configcore.FilesystemOnlyApply(classicDev, tmpDir, conf), IsNil)
+
+	p, err := launchpolicy.Read(filepath.Join(tmpDir, "var/lib/snapd/desktop/launch-policy.json"))
+	c.Assert(err, IsNil)
+	c.Check(p, DeepEquals, &launchpolicy.Policy{Restricted: true})
+	c.Check(filepath.Join(tmpDir, "var/lib/snapd/apparmor/launch-policy"), testutil.FilePresent)
+	_, err = os.Stat(dirs.SnapLaunchPolicyFile)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
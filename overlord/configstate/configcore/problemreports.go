@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errtracker"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/sysconfig"
+)
+
+func init() {
+	supportedConfigurations["core.problem-reports.sink"] = true
+	supportedConfigurations["core.problem-reports.http.url"] = true
+	supportedConfigurations["core.problem-reports.http.certificate"] = true
+	supportedConfigurations["core.problem-reports.spool.dir"] = true
+}
+
+func problemReportsSinkConfig(tr config.ConfGetter) (*errtracker.SinkConfig, error) {
+	sink, err := coreCfg(tr, "problem-reports.sink")
+	if err != nil {
+		return nil, err
+	}
+	switch sink {
+	case "", errtracker.SinkDefault:
+		return nil, nil
+	case errtracker.SinkHTTP:
+		endpoint, err := coreCfg(tr, "problem-reports.http.url")
+		if err != nil {
+			return nil, err
+		}
+		cert, err := coreCfg(tr, "problem-reports.http.certificate")
+		if err != nil {
+			return nil, err
+		}
+		return &errtracker.SinkConfig{Type: sink, URL: endpoint, Certificate: cert}, nil
+	case errtracker.SinkSpool:
+		dir, err := coreCfg(tr, "problem-reports.spool.dir")
+		if err != nil {
+			return nil, err
+		}
+		return &errtracker.SinkConfig{Type: sink, Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("problem-reports.sink can only be set to 'default', 'http' or 'spool'")
+	}
+}
+
+func validateProblemReportsSettings(tr config.ConfGetter) error {
+	cfg, err := problemReportsSinkConfig(tr)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Type {
+	case errtracker.SinkHTTP:
+		if cfg.URL == "" {
+			return fmt.Errorf("problem-reports.http.url must be set when using the http sink")
+		}
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return fmt.Errorf("cannot parse problem-reports.http.url: %v", err)
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("problem-reports.http.url must be a https URL, not %q", cfg.URL)
+		}
+		if cfg.Certificate != "" && !filepath.IsAbs(cfg.Certificate) {
+			return fmt.Errorf("problem-reports.http.certificate must be an absolute path, not %q", cfg.Certificate)
+		}
+	case errtracker.SinkSpool:
+		if cfg.Dir == "" {
+			return fmt.Errorf("problem-reports.spool.dir must be set when using the spool sink")
+		}
+		if !filepath.IsAbs(cfg.Dir) {
+			return fmt.Errorf("problem-reports.spool.dir must be an absolute path, not %q", cfg.Dir)
+		}
+	}
+	return nil
+}
+
+func handleProblemReportsConfiguration(_ sysconfig.Device, tr config.ConfGetter, opts *fsOnlyContext) error {
+	cfg, err := problemReportsSinkConfig(tr)
+	if err != nil {
+		return err
+	}
+
+	rootDir := dirs.GlobalRootDir
+	if opts != nil {
+		rootDir = opts.RootDir
+	}
+	return errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFileUnder(rootDir), cfg)
+}
@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/errtracker"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type problemReportsSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&problemReportsSuite{})
+
+func readSinkConfig(c *C, fname string) *errtracker.SinkConfig {
+	data, err := ioutil.ReadFile(fname)
+	c.Assert(err, IsNil)
+	var cfg errtracker.SinkConfig
+	c.Assert(json.Unmarshal(data, &cfg), IsNil)
+	return &cfg
+}
+
+func (s *problemReportsSuite) TestConfigureHTTPSink(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"problem-reports.sink":             "http",
+			"problem-reports.http.url":         "https://reports.example.com/submit",
+			"problem-reports.http.certificate": "/etc/ssl/reports.pem",
+		},
+	})
+	c.Assert(err, IsNil)
+
+	c.Check(readSinkConfig(c, dirs.ErrtrackerSinkFile), DeepEquals, &errtracker.SinkConfig{
+		Type:        "http",
+		URL:         "https://reports.example.com/submit",
+		Certificate: "/etc/ssl/reports.pem",
+	})
+}
+
+func (s *problemReportsSuite) TestConfigureSpoolSink(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"problem-reports.sink":      "spool",
+			"problem-reports.spool.dir": "/var/spool/snapd-reports",
+		},
+	})
+	c.Assert(err, IsNil)
+
+	c.Check(readSinkConfig(c, dirs.ErrtrackerSinkFile), DeepEquals, &errtracker.SinkConfig{
+		Type: "spool",
+		Dir:  "/var/spool/snapd-reports",
+	})
+}
+
+func (s *problemReportsSuite) TestConfigureDefaultSinkRemovesConfig(c *C) {
+	err := errtracker.WriteSinkConfig(dirs.ErrtrackerSinkFile, &errtracker.SinkConfig{Type: "spool", Dir: "/tmp"})
+	c.Assert(err, IsNil)
+
+	for _, sink := range []string{"default", ""} {
+		err = configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"problem-reports.sink": sink,
+			},
+		})
+		c.Assert(err, IsNil)
+		c.Check(dirs.ErrtrackerSinkFile, testutil.FileAbsent)
+	}
+}
+
+func (s *problemReportsSuite) TestConfigureInvalid(c *C) {
+	for _, t := range []struct {
+		conf   map[string]interface{}
+		errStr string
+	}{
+		{map[string]interface{}{"problem-reports.sink": "foo"}, `problem-reports.sink can only be set to 'default', 'http' or 'spool'`},
+		{map[string]interface{}{"problem-reports.sink": "http"}, `problem-reports.http.url must be set when using the http sink`},
+		{map[string]interface{}{"problem-reports.sink": "http", "problem-reports.http.url": "http://example.com"}, `problem-reports.http.url must be a https URL, not "http://example.com"`},
+		{map[string]interface{}{"problem-reports.sink": "http", "problem-reports.http.url": "https://example.com", "problem-reports.http.certificate": "cert.pem"}, `problem-reports.http.certificate must be an absolute path, not "cert.pem"`},
+		{map[string]interface{}{"problem-reports.sink": "spool"}, `problem-reports.spool.dir must be set when using the spool sink`},
+		{map[string]interface{}{"problem-reports.sink": "spool", "problem-reports.spool.dir": "spool"}, `problem-reports.spool.dir must be an absolute path, not "spool"`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf:  t.conf,
+		})
+		c.Check(err, ErrorMatches, t.errStr, Commentf("%v", t.conf))
+	}
+	c.Check(dirs.ErrtrackerSinkFile, testutil.FileAbsent)
+}
+
+func (s *problemReportsSuite) TestFilesystemOnlyApply(c *C) {
+	conf := configcore.PlainCoreConfig(map[string]interface{}{
+		"problem-reports.sink":      "spool",
+		"problem-reports.spool.dir": "/var/spool/snapd-reports",
+	})
+	tmpDir := c.MkDir()
+	c.Assert(configcore.FilesystemOnlyApply(coreDev, tmpDir, conf), IsNil)
+
+	c.Check(readSinkConfig(c, dirs.ErrtrackerSinkFileUnder(tmpDir)), DeepEquals, &errtracker.SinkConfig{
+		Type: "spool",
+		Dir:  "/var/spool/snapd-reports",
+	})
+}
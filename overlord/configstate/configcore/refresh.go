@@ -24,10 +24,12 @@ package configcore
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timeutil"
 )
@@ -80,7 +82,7 @@ func validateRefreshSchedule(tr config.Conf) error {
 		return err
 	}
 	switch refreshOnMeteredStr {
-	case "", "hold":
+	case "", "hold", "hold-all", "defer-large":
 		// noop
 	default:
 		return fmt.Errorf("refresh.metered value %q is invalid", refreshOnMeteredStr)
@@ -147,3 +149,52 @@ func validateRefreshRateLimit(tr config.Conf) error {
 	}
 	return nil
 }
+
+const refreshAutoRevertOption = "refresh.auto-revert"
+
+func isRefreshAutoRevertChange(chg string) bool {
+	return chg == "core."+refreshAutoRevertOption || strings.HasPrefix(chg, "core."+refreshAutoRevertOption+".")
+}
+
+func validateRefreshAutoRevertWindow(tr config.Conf, key string) error {
+	window, err := coreCfg(tr, key)
+	if err != nil {
+		return err
+	}
+	// reset is fine
+	if window == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d < 0 {
+		return fmt.Errorf("%s must be a positive duration, not %q", key, window)
+	}
+	return nil
+}
+
+// validateRefreshAutoRevert validates the windows during which the health
+// of snaps is verified after they were refreshed, either for all snaps with
+// refresh.auto-revert.window or for a single snap with
+// refresh.auto-revert.snaps.<snap>.window.
+func validateRefreshAutoRevert(tr config.Conf) error {
+	for _, k := range tr.Changes() {
+		if !isRefreshAutoRevertChange(k) {
+			continue
+		}
+		key := strings.TrimPrefix(k, "core.")
+		subkeys := strings.Split(strings.TrimPrefix(key, refreshAutoRevertOption+"."), ".")
+		switch {
+		case len(subkeys) == 1 && subkeys[0] == "window":
+		case len(subkeys) == 3 && subkeys[0] == "snaps" && subkeys[2] == "window":
+			if err := snap.ValidateName(subkeys[1]); err != nil {
+				return fmt.Errorf("cannot set %q: %v", key, err)
+			}
+		default:
+			return fmt.Errorf("cannot set %q: unsupported system option", k)
+		}
+		if err := validateRefreshAutoRevertWindow(tr, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -171,6 +171,16 @@ func (s *refreshSuite) TestConfigureRefreshHoldOnMeteredHappy(c *C) {
 	})
 	c.Assert(err, IsNil)
 
+	for _, v := range []string{"hold-all", "defer-large"} {
+		err = configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"refresh.metered": v,
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+
 	err = configcore.Run(classicDev, &mockConf{
 		state: s.state,
 		conf: map[string]interface{}{
@@ -219,3 +229,37 @@ func (s *refreshSuite) TestConfigureRefreshRetainInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `retain must be a number between 2 and 20, not "invalid"`)
 }
+
+func (s *refreshSuite) TestConfigureRefreshAutoRevertHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"refresh.auto-revert.window":                  "10m",
+			"refresh.auto-revert.snaps.some-snap.window":  "0",
+			"refresh.auto-revert.snaps.other-snap.window": "1h30m",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *refreshSuite) TestConfigureRefreshAutoRevertInvalid(c *C) {
+	for _, tc := range []struct {
+		key, value string
+		err        string
+	}{
+		{"refresh.auto-revert.window", "10", `refresh.auto-revert.window must be a positive duration, not "10"`},
+		{"refresh.auto-revert.window", "-1m", `refresh.auto-revert.window must be a positive duration, not "-1m"`},
+		{"refresh.auto-revert.snaps.some-snap.window", "soon", `refresh.auto-revert.snaps.some-snap.window must be a positive duration, not "soon"`},
+		{"refresh.auto-revert.snaps.0.window", "1m", `cannot set "refresh.auto-revert.snaps.0.window": invalid snap name: "0"`},
+		{"refresh.auto-revert.snaps.some-snap.delay", "1m", `cannot set "core.refresh.auto-revert.snaps.some-snap.delay": unsupported system option`},
+		{"refresh.auto-revert.delay", "1m", `cannot set "core.refresh.auto-revert.delay": unsupported system option`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				tc.key: tc.value,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s=%s", tc.key, tc.value))
+	}
+}
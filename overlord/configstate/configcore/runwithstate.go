@@ -48,18 +48,33 @@ func init() {
 	// resilience.vitality-hint
 	addWithStateHandler(validateVitalitySettings, handleVitalityConfiguration, nil)
 
+	// service-ordering.<snap>.{after,before}
+	addWithStateHandler(validateServiceOrdering, handleServiceOrdering, nil)
+
 	// XXX: this should become a FSOnlyHandler. We need to
 	// add/implement Changes() to the ConfGetter interface
 	// store-certs.*
 	addWithStateHandler(validateCertSettings, handleCertConfiguration, nil)
 
+	// store.tls.client-{cert,key}
+	addWithStateHandler(validateStoreTLSSettings, handleStoreTLSSettings, nil)
+
+	// system.kernel.sysctl.*
+	addWithStateHandler(validateSysctlParams, handleSysctlParamsConfiguration, &flags{coreOnlyConfig: true})
+
 	// users.create.automatic
 	addWithStateHandler(validateUsersSettings, handleUserSettings, &flags{earlyConfigFilter: earlyUsersSettingsFilter})
 
 	validateOnly := &flags{validatedOnlyStateConfig: true}
 	addWithStateHandler(validateRefreshSchedule, nil, validateOnly)
 	addWithStateHandler(validateRefreshRateLimit, nil, validateOnly)
+	addWithStateHandler(validateRefreshAutoRevert, nil, validateOnly)
 	addWithStateHandler(validateAutomaticSnapshotsExpiration, nil, validateOnly)
+	addWithStateHandler(validateFreezeSettings, nil, validateOnly)
+	addWithStateHandler(validateDownloadSettings, nil, validateOnly)
+	addWithStateHandler(validateHookLimitsSettings, nil, validateOnly)
+	addWithStateHandler(validateDiskHealthSettings, nil, validateOnly)
+	addWithStateHandler(validateInterfacesSettings, nil, validateOnly)
 
 	// netplan.*
 	addWithStateHandler(validateNetplanSettings, handleNetplanConfiguration, &flags{coreOnlyConfig: true})
@@ -123,6 +138,14 @@ func applyHandlers(dev sysconfig.Device, cfg config.Conf, handlers []configHandl
 			if !validCertOption(k) {
 				return fmt.Errorf("cannot set store ssl certificate under name %q: name must only contain word characters or a dash", k)
 			}
+		case isSysctlParamsChange(k):
+			// validated by validateSysctlParams
+		case isLaunchPolicyChange(k):
+			// validated by validateLaunchPolicySettings
+		case isRefreshAutoRevertChange(k):
+			// validated by validateRefreshAutoRevert
+		case isServiceOrderingChange(k):
+			// validated by validateServiceOrdering
 		case isNetplanChange(k):
 			if release.OnClassic {
 				return fmt.Errorf("cannot set netplan configuration on classic")
@@ -0,0 +1,199 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+// +build !nomanagers
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+// service-ordering.<snap>.{after,before} hold comma separated lists of host
+// systemd units that the services of <snap> are ordered after or before.
+const serviceOrderingOption = "service-ordering"
+
+var validHostUnitName = regexp.MustCompile(`^[a-zA-Z0-9:_.\\@-]+\.(service|socket|target|mount|automount|path|timer|device|swap|slice|scope)$`).MatchString
+
+func isServiceOrderingChange(chg string) bool {
+	return chg == "core."+serviceOrderingOption || strings.HasPrefix(chg, "core."+serviceOrderingOption+".")
+}
+
+// serviceOrderingSnap returns the name of the snap affected by the given
+// service-ordering change, or an empty name if the change affects all the
+// snaps, as when unsetting service-ordering altogether. Besides the after
+// and before options, only the subtrees can be changed, and only unset.
+func serviceOrderingSnap(tr config.ConfGetter, chg string) (string, error) {
+	key := strings.TrimPrefix(chg, "core.")
+	var subkeys []string
+	if key != serviceOrderingOption {
+		subkeys = strings.Split(strings.TrimPrefix(key, serviceOrderingOption+"."), ".")
+	}
+	switch {
+	case len(subkeys) == 2 && (subkeys[1] == "after" || subkeys[1] == "before"):
+		// the ordering of the services of a snap
+	case len(subkeys) < 2:
+		var value interface{}
+		if err := tr.GetMaybe("core", key, &value); err != nil {
+			return "", err
+		}
+		if value != nil {
+			return "", fmt.Errorf("cannot set %q: unsupported system option", chg)
+		}
+		if len(subkeys) == 0 {
+			return "", nil
+		}
+	default:
+		return "", fmt.Errorf("cannot set %q: unsupported system option", chg)
+	}
+	if err := snap.ValidateName(subkeys[0]); err != nil {
+		return "", fmt.Errorf("cannot set %q: %v", key, err)
+	}
+	return subkeys[0], nil
+}
+
+func validateServiceOrdering(tr config.Conf) error {
+	for _, k := range tr.Changes() {
+		if !isServiceOrderingChange(k) {
+			continue
+		}
+		snapName, err := serviceOrderingSnap(tr, k)
+		if err != nil {
+			return err
+		}
+		key := strings.TrimPrefix(k, "core.")
+		units, err := coreCfg(tr, key)
+		if err != nil {
+			return err
+		}
+		// unsetting is always fine
+		if units == "" {
+			continue
+		}
+		if snapName == "snapd" {
+			return fmt.Errorf("cannot set %q: snapd snap services cannot be ordered", key)
+		}
+		for _, unit := range strings.Split(units, ",") {
+			unit = strings.TrimSpace(unit)
+			if unit == "" {
+				continue
+			}
+			if !validHostUnitName(unit) {
+				return fmt.Errorf("cannot set %q: invalid systemd unit name %q", key, unit)
+			}
+			if strings.HasPrefix(unit, "snap.") {
+				return fmt.Errorf("cannot set %q: cannot order against snap unit %q", key, unit)
+			}
+		}
+	}
+	return nil
+}
+
+// handleServiceOrdering rewrites the service units of the snaps for which
+// the ordering against host units changed. The new ordering takes effect
+// the next time the services are started or stopped.
+func handleServiceOrdering(tr config.Conf, opts *fsOnlyContext) error {
+	changed := map[string]bool{}
+	for _, k := range tr.Changes() {
+		if !isServiceOrderingChange(k) {
+			continue
+		}
+		snapName, err := serviceOrderingSnap(tr, k)
+		if err != nil {
+			return err
+		}
+		// an empty name stands for all the snaps
+		changed[snapName] = true
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+
+	all, err := snapstate.All(st)
+	if err != nil {
+		return err
+	}
+
+	// use a single cache of the quota groups for calculating the quota groups
+	// that services should be in
+	grps, err := servicestate.AllQuotas(st)
+	if err != nil {
+		return err
+	}
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{}
+	for instanceName, snapst := range all {
+		if !changed[""] && !changed[snap.InstanceSnap(instanceName)] {
+			continue
+		}
+		// not active, the ordering will be applied when the snap
+		// becomes active
+		if !snapst.Active {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return err
+		}
+		if len(info.Services()) == 0 {
+			continue
+		}
+		snapSvcOpts, err := servicestate.SnapServiceOptions(st, instanceName, grps)
+		if err != nil {
+			return err
+		}
+		// SnapServiceOptions reads the committed configuration, use the
+		// ordering of this transaction instead
+		snapSvcOpts.HostAfter, snapSvcOpts.HostBefore, err = servicestate.HostUnitsOrdering(tr, instanceName)
+		if err != nil {
+			return err
+		}
+		m[info] = snapSvcOpts
+	}
+	if len(m) == 0 {
+		return nil
+	}
+
+	// TODO: use sysconfig.Device instead
+	deviceCtx, err := snapstate.DeviceCtx(st, nil, nil)
+	if err != nil {
+		return err
+	}
+	ensureOpts := &wrappers.EnsureSnapServicesOptions{}
+	// we need the snapd snap mounted whenever in order for services to
+	// start for all services on UC18+
+	if !deviceCtx.Classic() && deviceCtx.Model().Base() != "" {
+		ensureOpts.RequireMountedSnapdSnap = true
+	}
+
+	return wrappers.EnsureSnapServices(m, ensureOpts, nil, progress.Null)
+}
@@ -0,0 +1,159 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type serviceOrderingSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&serviceOrderingSuite{})
+
+func (s *serviceOrderingSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	model := assertstest.FakeAssertion(map[string]interface{}{
+		"type":         "model",
+		"authority-id": "canonical",
+		"series":       "16",
+		"brand-id":     "canonical",
+		"model":        "pc",
+		"gadget":       "pc",
+		"kernel":       "kernel",
+		"architecture": "amd64",
+	}).(*asserts.Model)
+
+	s.AddCleanup(snapstatetest.MockDeviceModel(model))
+}
+
+func (s *serviceOrderingSuite) TestConfigureServiceOrderingUnhappy(c *C) {
+	for _, t := range []struct {
+		key, value string
+		err        string
+	}{
+		{"service-ordering.test-snap.after", "time-sync", `cannot set "service-ordering.test-snap.after": invalid systemd unit name "time-sync"`},
+		{"service-ordering.test-snap.before", "foo.service,bar baz.service", `cannot set "service-ordering.test-snap.before": invalid systemd unit name "bar baz.service"`},
+		{"service-ordering.test-snap.after", "snap.other.svc.service", `cannot set "service-ordering.test-snap.after": cannot order against snap unit "snap.other.svc.service"`},
+		{"service-ordering.test-snap.requires", "foo.service", `cannot set "core.service-ordering.test-snap.requires": unsupported system option`},
+		{"service-ordering.test-snap", "foo.service", `cannot set "core.service-ordering.test-snap": unsupported system option`},
+		{"service-ordering.-invalid-.after", "foo.service", `cannot set "service-ordering.-invalid-.after": invalid snap name: ".*"`},
+		{"service-ordering.snapd.after", "foo.service", `cannot set "service-ordering.snapd.after": snapd snap services cannot be ordered`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%s", t.key, t.value))
+	}
+}
+
+func (s *serviceOrderingSuite) TestConfigureServiceOrderingNotInstalled(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"service-ordering.test-snap.after": "time-sync.target",
+		},
+	})
+	c.Assert(err, IsNil)
+	// no snap named "test-snap" is installed, so no systemd action
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *serviceOrderingSuite) TestConfigureServiceOrdering(c *C) {
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, mockSnapWithService, si)
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+	s.state.Unlock()
+
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"service-ordering.test-snap.after":  "time-sync.target, vendor-daemon.service",
+			"service-ordering.test-snap.before": "shutdown-hook.service",
+		},
+	})
+	c.Assert(err, IsNil)
+	// the units are rewritten but the services are not restarted
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+	svcPath := filepath.Join(dirs.SnapServicesDir, "snap.test-snap.foo.service")
+	c.Check(svcPath, testutil.FileContains, " network.target snapd.apparmor.service time-sync.target vendor-daemon.service\n")
+	c.Check(svcPath, testutil.FileContains, "\nBefore=shutdown-hook.service\n")
+}
+
+func (s *serviceOrderingSuite) TestConfigureServiceOrderingUnset(c *C) {
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, mockSnapWithService, si)
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+	s.state.Unlock()
+
+	svcPath := filepath.Join(dirs.SnapServicesDir, "snap.test-snap.foo.service")
+	for _, key := range []string{"service-ordering.test-snap", "service-ordering", "service-ordering.snapd"} {
+		s.systemctlArgs = nil
+		c.Assert(os.RemoveAll(svcPath), IsNil)
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				key: nil,
+			},
+		})
+		c.Assert(err, IsNil, Commentf(key))
+		if key == "service-ordering.snapd" {
+			// no snap with services affected
+			c.Check(s.systemctlArgs, HasLen, 0)
+			continue
+		}
+		c.Check(s.systemctlArgs, DeepEquals, [][]string{
+			{"daemon-reload"},
+		}, Commentf(key))
+		c.Check(svcPath, Not(testutil.FileContains), "time-sync.target", Commentf(key))
+	}
+}
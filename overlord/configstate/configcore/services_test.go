@@ -79,7 +79,7 @@ func (s *servicesSuite) TestConfigureServiceNotDisabled(c *C) {
 	err := configcore.SwitchDisableService("sshd.service", false, nil)
 	c.Assert(err, IsNil)
 	c.Check(s.systemctlArgs, DeepEquals, [][]string{
-		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", "sshd.service"},
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", "sshd.service"},
 		{"unmask", "sshd.service"},
 		{"--no-reload", "enable", "sshd.service"},
 		{"daemon-reload"},
@@ -91,7 +91,7 @@ func (s *servicesSuite) TestConfigureServiceDisabled(c *C) {
 	err := configcore.SwitchDisableService("sshd.service", true, nil)
 	c.Assert(err, IsNil)
 	c.Check(s.systemctlArgs, DeepEquals, [][]string{
-		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", "sshd.service"},
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", "sshd.service"},
 		{"--no-reload", "disable", "sshd.service"},
 		{"mask", "sshd.service"},
 		{"stop", "sshd.service"},
@@ -137,7 +137,7 @@ func (s *servicesSuite) TestConfigureServiceDisabledIntegration(c *C) {
 		default:
 			if service.installed {
 				c.Check(s.systemctlArgs, DeepEquals, [][]string{
-					{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", srv},
+					{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", srv},
 					{"--no-reload", "disable", srv},
 					{"mask", srv},
 					{"stop", srv},
@@ -145,7 +145,7 @@ func (s *servicesSuite) TestConfigureServiceDisabledIntegration(c *C) {
 				})
 			} else {
 				c.Check(s.systemctlArgs, DeepEquals, [][]string{
-					{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", srv},
+					{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", srv},
 				})
 			}
 		}
@@ -295,7 +295,7 @@ func (s *servicesSuite) TestConfigureServiceEnableIntegration(c *C) {
 		default:
 			if service.installed {
 				c.Check(s.systemctlArgs, DeepEquals, [][]string{
-					{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", srv},
+					{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", srv},
 					{"unmask", srv},
 					{"--no-reload", "enable", srv},
 					{"daemon-reload"},
@@ -303,7 +303,7 @@ func (s *servicesSuite) TestConfigureServiceEnableIntegration(c *C) {
 				})
 			} else {
 				c.Check(s.systemctlArgs, DeepEquals, [][]string{
-					{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", srv},
+					{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", srv},
 				})
 			}
 		}
@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+)
+
+var (
+	devicestateReloadClientCertificate = devicestate.ReloadClientCertificate
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.store.tls.client-cert"] = true
+	supportedConfigurations["core.store.tls.client-key"] = true
+}
+
+func validateStoreTLSSettings(tr config.Conf) error {
+	certFile, err := coreCfg(tr, "store.tls.client-cert")
+	if err != nil {
+		return err
+	}
+	keyFile, err := coreCfg(tr, "store.tls.client-key")
+	if err != nil {
+		return err
+	}
+	if certFile == "" && keyFile == "" {
+		return nil
+	}
+	if certFile == "" || keyFile == "" {
+		return fmt.Errorf("cannot set store.tls.client-cert and store.tls.client-key independently")
+	}
+
+	if !filepath.IsAbs(certFile) {
+		return fmt.Errorf("store.tls.client-cert must be an absolute path, not %q", certFile)
+	}
+	if keyFile == devicestate.ClientCertificateDeviceKey {
+		// the certificate is matched against the device key when used
+		// as the device key may not have been generated yet
+		certPEM, err := ioutil.ReadFile(certFile)
+		if err != nil {
+			return fmt.Errorf("cannot read TLS client certificate: %v", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(certPEM) {
+			return fmt.Errorf("cannot decode TLS client certificate %q", certFile)
+		}
+		return nil
+	}
+	if !filepath.IsAbs(keyFile) {
+		return fmt.Errorf("store.tls.client-key must be an absolute path or %q, not %q", devicestate.ClientCertificateDeviceKey, keyFile)
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return fmt.Errorf("cannot load TLS client certificate: %v", err)
+	}
+	return nil
+}
+
+func handleStoreTLSSettings(tr config.Conf, opts *fsOnlyContext) error {
+	changed := false
+	for _, name := range tr.Changes() {
+		if name == "core.store.tls.client-cert" || name == "core.store.tls.client-key" {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	// XXX this takes effect before committing, as for proxy.store
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+	return devicestateReloadClientCertificate(st, tr)
+}
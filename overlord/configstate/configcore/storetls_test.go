@@ -0,0 +1,176 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type storeTLSSuite struct {
+	configcoreSuite
+
+	certFile string
+	keyFile  string
+
+	reloaded []map[string]string
+}
+
+var _ = Suite(&storeTLSSuite{})
+
+func (s *storeTLSSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	dir := c.MkDir()
+	s.certFile = filepath.Join(dir, "client.crt")
+	s.keyFile = filepath.Join(dir, "client.key")
+	err = ioutil.WriteFile(s.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(s.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	c.Assert(err, IsNil)
+
+	s.reloaded = nil
+	s.AddCleanup(configcore.MockDevicestateReloadClientCertificate(func(st *state.State, tr config.ConfGetter) error {
+		var certFile, keyFile string
+		c.Assert(tr.GetMaybe("core", "store.tls.client-cert", &certFile), IsNil)
+		c.Assert(tr.GetMaybe("core", "store.tls.client-key", &keyFile), IsNil)
+		s.reloaded = append(s.reloaded, map[string]string{"cert": certFile, "key": keyFile})
+		return nil
+	}))
+}
+
+func (s *storeTLSSuite) TestConfigureClientCertificateFiles(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"store.tls.client-cert": s.certFile,
+			"store.tls.client-key":  s.keyFile,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.reloaded, DeepEquals, []map[string]string{
+		{"cert": s.certFile, "key": s.keyFile},
+	})
+}
+
+func (s *storeTLSSuite) TestConfigureClientCertificateDeviceKey(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		changes: map[string]interface{}{
+			"store.tls.client-cert": s.certFile,
+			"store.tls.client-key":  "device-key",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.reloaded, DeepEquals, []map[string]string{
+		{"cert": s.certFile, "key": "device-key"},
+	})
+}
+
+func (s *storeTLSSuite) TestConfigureClientCertificateUnset(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.tls.client-cert": s.certFile,
+			"store.tls.client-key":  s.keyFile,
+		},
+		changes: map[string]interface{}{
+			"store.tls.client-cert": "",
+			"store.tls.client-key":  "",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.reloaded, DeepEquals, []map[string]string{
+		{"cert": "", "key": ""},
+	})
+}
+
+func (s *storeTLSSuite) TestConfigureClientCertificateUnchanged(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"store.tls.client-cert": s.certFile,
+			"store.tls.client-key":  s.keyFile,
+		},
+		changes: map[string]interface{}{
+			"proxy.https": "http://proxy",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.reloaded, HasLen, 0)
+}
+
+func (s *storeTLSSuite) TestConfigureClientCertificateErrors(c *C) {
+	otherCert := filepath.Join(c.MkDir(), "other.crt")
+	c.Assert(ioutil.WriteFile(otherCert, []byte(mockCert), 0644), IsNil)
+	garbage := filepath.Join(c.MkDir(), "garbage")
+	c.Assert(ioutil.WriteFile(garbage, []byte("garbage"), 0644), IsNil)
+
+	for _, t := range []struct {
+		cert, key string
+		err       string
+	}{
+		{s.certFile, "", `cannot set store.tls.client-cert and store.tls.client-key independently`},
+		{"", s.keyFile, `cannot set store.tls.client-cert and store.tls.client-key independently`},
+		{"client.crt", s.keyFile, `store.tls.client-cert must be an absolute path, not "client.crt"`},
+		{s.certFile, "client.key", `store.tls.client-key must be an absolute path or "device-key", not "client.key"`},
+		{"/no/such/file", s.keyFile, `cannot load TLS client certificate: open /no/such/file: .*`},
+		{otherCert, s.keyFile, `cannot load TLS client certificate: .*`},
+		{"/no/such/file", "device-key", `cannot read TLS client certificate: open /no/such/file: .*`},
+		{garbage, "device-key", `cannot decode TLS client certificate ".*/garbage"`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			changes: map[string]interface{}{
+				"store.tls.client-cert": t.cert,
+				"store.tls.client-key":  t.key,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s %s", t.cert, t.key))
+	}
+	c.Check(s.reloaded, HasLen, 0)
+}
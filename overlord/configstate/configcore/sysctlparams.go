@@ -0,0 +1,304 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// Arbitrary kernel parameters can be set with options of the form
+// system.kernel.sysctl.<parameter>, e.g.
+//
+//	snap set system system.kernel.sysctl.net.ipv4.ip-forward=1
+//
+// As configuration keys cannot contain underscores, dashes in the
+// option name are mapped to underscores in the parameter name, unless
+// the kernel knows the name with dashes, as is the case for network
+// interfaces, e.g. net.ipv4.conf.br-lan.rp-filter is set as
+// net.ipv4.conf.br-lan.rp_filter. The
+// values are written to a snapd owned file in /etc/sysctl.d, so that
+// they are applied again on boot, and the kernel is checked to have
+// accepted them. Networking parameters additionally must not break the
+// connectivity to the store, otherwise the change is reverted.
+
+const (
+	sysctlParamsOption = "system.kernel.sysctl"
+	// sorts after 99-snapd.conf
+	snapdSysctlParamsConf = "99-snapd-sysctl.conf"
+)
+
+// sysctl parameters that are handled by dedicated system options
+var sysctlReservedParams = map[string]string{
+	"kernel.printk":                  "system.kernel.printk.console-loglevel",
+	"net.ipv6.conf.all.disable_ipv6": "network.disable-ipv6",
+}
+
+func isSysctlParamsChange(chg string) bool {
+	return chg == "core."+sysctlParamsOption || strings.HasPrefix(chg, "core."+sysctlParamsOption+".")
+}
+
+// sysctlParamName returns the name of the kernel parameter set with the
+// given option path below system.kernel.sysctl.
+func sysctlParamName(path []string) string {
+	names := make([]string, len(path))
+	dir := filepath.Join(dirs.GlobalRootDir, "/proc/sys")
+	for i, elem := range path {
+		name := strings.Replace(elem, "-", "_", -1)
+		if name != elem && osutil.FileExists(filepath.Join(dir, elem)) {
+			// the dashes are part of the name
+			name = elem
+		}
+		names[i] = name
+		dir = filepath.Join(dir, name)
+	}
+	return strings.Join(names, ".")
+}
+
+// sysctlParams returns the kernel parameters set in the given document
+// of system.kernel.sysctl options, mapped to their values.
+func sysctlParams(doc interface{}) (map[string]string, error) {
+	params := make(map[string]string)
+	var collect func(path []string, v interface{}) error
+	collect = func(path []string, v interface{}) error {
+		switch v := v.(type) {
+		case nil:
+			// unset
+		case map[string]interface{}:
+			for k, sub := range v {
+				if err := collect(append(path[:len(path):len(path)], k), sub); err != nil {
+					return err
+				}
+			}
+		case string, json.Number:
+			name := sysctlParamName(path)
+			if len(path) < 2 {
+				return fmt.Errorf("cannot set %s.%s: invalid kernel parameter name", sysctlParamsOption, strings.Join(path, "."))
+			}
+			if option, ok := sysctlReservedParams[name]; ok {
+				return fmt.Errorf("cannot set kernel parameter %q: use the %s system option instead", name, option)
+			}
+			value := strings.TrimSpace(fmt.Sprintf("%v", v))
+			if value == "" {
+				return fmt.Errorf("cannot set kernel parameter %q: empty value", name)
+			}
+			if strings.ContainsAny(value, "\n\r") {
+				return fmt.Errorf("cannot set kernel parameter %q: value cannot span multiple lines", name)
+			}
+			params[name] = value
+		default:
+			return fmt.Errorf("cannot set kernel parameter %q: value must be a string or a number", sysctlParamName(path))
+		}
+		return nil
+	}
+	if err := collect(nil, doc); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func getSysctlParams(get func(snapName, key string, result interface{}) error) (map[string]string, error) {
+	var doc interface{}
+	if err := get("core", sysctlParamsOption, &doc); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if doc != nil {
+		if _, ok := doc.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("cannot set %s: value must be a map of kernel parameters", sysctlParamsOption)
+		}
+	}
+	return sysctlParams(doc)
+}
+
+func validateSysctlParams(tr config.Conf) error {
+	_, err := getSysctlParams(tr.Get)
+	return err
+}
+
+// sysctlParamPath returns the path under /proc/sys of the kernel parameter.
+func sysctlParamPath(name string) string {
+	return filepath.Join(dirs.GlobalRootDir, "/proc/sys", strings.Replace(name, ".", "/", -1))
+}
+
+func readSysctlParam(name string) (string, error) {
+	data, err := ioutil.ReadFile(sysctlParamPath(name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// sysctlValuesEqual compares values as the kernel reports them, which
+// may differ in whitespace or in the formatting of numbers.
+func sysctlValuesEqual(a, b string) bool {
+	fieldsA := strings.Fields(a)
+	fieldsB := strings.Fields(b)
+	if len(fieldsA) != len(fieldsB) {
+		return false
+	}
+	for i := range fieldsA {
+		if fieldsA[i] == fieldsB[i] {
+			continue
+		}
+		nA, errA := strconv.ParseInt(fieldsA[i], 10, 64)
+		nB, errB := strconv.ParseInt(fieldsB[i], 10, 64)
+		if errA != nil || errB != nil || nA != nB {
+			return false
+		}
+	}
+	return true
+}
+
+func writeSysctlParamsConf(dir string, params map[string]string) error {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dirContent := map[string]osutil.FileState{}
+	if len(names) > 0 {
+		content := bytes.NewBufferString("# managed by snapd, see the system.kernel.sysctl.* system options\n")
+		for _, name := range names {
+			fmt.Fprintf(content, "%s = %s\n", name, params[name])
+		}
+		dirContent[snapdSysctlParamsConf] = &osutil.MemoryFileState{
+			Content: content.Bytes(),
+			Mode:    0644,
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	_, _, err := osutil.EnsureDirState(dir, snapdSysctlParamsConf, dirContent)
+	return err
+}
+
+func handleSysctlParamsConfiguration(tr config.Conf, opts *fsOnlyContext) error {
+	params, err := getSysctlParams(tr.Get)
+	if err != nil {
+		return err
+	}
+	oldParams, err := getSysctlParams(tr.GetPristine)
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	for name, value := range params {
+		if oldValue, ok := oldParams[name]; !ok || oldValue != value {
+			changed = append(changed, name)
+		}
+	}
+	for name := range oldParams {
+		if _, ok := params[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+	affectsNetworking := false
+	for _, name := range changed {
+		if strings.HasPrefix(name, "net.") {
+			affectsNetworking = true
+		}
+	}
+
+	// remember the current values to be able to revert
+	oldValues := make(map[string]string, len(changed))
+	for _, name := range changed {
+		value, err := readSysctlParam(name)
+		if err != nil {
+			// not known to the kernel (yet)
+			continue
+		}
+		oldValues[name] = value
+	}
+
+	var storeReachableBefore bool
+	if affectsNetworking {
+		// re-try reaching the store to guard against flaky networks
+		var tries int
+		tries, storeReachableBefore = testStoreReachableWithRetry(tr.State(), 5, storeReachableRetryWait)
+		logger.Debugf("store reachable before sysctl changes: %v (tried %v times)", storeReachableBefore, tries)
+	}
+
+	dir := filepath.Join(dirs.GlobalRootDir, sysctlConfsDir)
+	if err := writeSysctlParamsConf(dir, params); err != nil {
+		return err
+	}
+
+	revert := func(err error) error {
+		if e := writeSysctlParamsConf(dir, oldParams); e != nil {
+			logger.Noticef("cannot restore %s: %v", snapdSysctlParamsConf, e)
+		}
+		for name, value := range oldValues {
+			if e := ioutil.WriteFile(sysctlParamPath(name), []byte(value), 0644); e != nil {
+				logger.Noticef("cannot restore kernel parameter %q: %v", name, e)
+			}
+		}
+		return fmt.Errorf("cannot set kernel parameters: %v", err)
+	}
+
+	if err := systemd.Sysctl(changed); err != nil {
+		return revert(err)
+	}
+	for _, name := range changed {
+		expected, ok := params[name]
+		if !ok {
+			// unset, defaults are restored by systemd-sysctl if
+			// there are any, otherwise the value is kept until
+			// reboot
+			continue
+		}
+		value, err := readSysctlParam(name)
+		if err != nil {
+			return revert(fmt.Errorf("kernel parameter %q not available: %v", name, err))
+		}
+		if !sysctlValuesEqual(value, expected) {
+			return revert(fmt.Errorf("kernel did not accept %q for %q", expected, name))
+		}
+	}
+
+	if affectsNetworking {
+		tries, storeReachableAfter := testStoreReachableWithRetry(tr.State(), 5, storeReachableRetryWait)
+		logger.Debugf("store reachable after sysctl changes: %v (tried %v times)", storeReachableAfter, tries)
+		if storeReachableBefore && !storeReachableAfter {
+			return revert(fmt.Errorf("store no longer reachable"))
+		}
+	}
+
+	return nil
+}
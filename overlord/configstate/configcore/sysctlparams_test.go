@@ -0,0 +1,289 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type sysctlParamsSuite struct {
+	configcoreSuite
+
+	confPath  string
+	fakestore *fakeConnectivityCheckStore
+	// kernelRejects lists the parameters the fake kernel does not
+	// accept values for
+	kernelRejects map[string]bool
+}
+
+var _ = Suite(&sysctlParamsSuite{})
+
+func (s *sysctlParamsSuite) SetUpTest(c *C) {
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	s.confPath = filepath.Join(dirs.GlobalRootDir, "/etc/sysctl.d/99-snapd-sysctl.conf")
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/etc"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/environment"), nil, 0644), IsNil)
+	s.kernelRejects = nil
+
+	// the fake kernel knows about these parameters
+	s.mockProcSys(c, "net.ipv4.ip_forward", "0")
+	s.mockProcSys(c, "vm.swappiness", "60")
+	s.mockProcSys(c, "net.ipv4.tcp_rmem", "4096\t131072\t6291456")
+
+	s.fakestore = &fakeConnectivityCheckStore{status: map[string]bool{"host1": true}}
+	s.AddCleanup(configcore.MockSnapstateStore(func(st *state.State, deviceCtx snapstate.DeviceContext) configcore.ConnectivityCheckStore {
+		return s.fakestore
+	}))
+	s.AddCleanup(configcore.MockStoreReachableRetryWait(1 * time.Millisecond))
+
+	// systemd-sysctl applies the snapd configuration to the fake kernel
+	s.AddCleanup(systemd.MockSystemdSysctl(func(args ...string) error {
+		s.systemdSysctlArgs = append(s.systemdSysctlArgs, args)
+		data, err := ioutil.ReadFile(s.confPath)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		c.Assert(err, IsNil)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			l := scanner.Text()
+			if strings.HasPrefix(l, "#") {
+				continue
+			}
+			kv := strings.SplitN(l, " = ", 2)
+			c.Assert(kv, HasLen, 2)
+			if s.kernelRejects[kv[0]] || !osutil.FileExists(s.procSysPath(kv[0])) {
+				continue
+			}
+			s.mockProcSys(c, kv[0], kv[1])
+		}
+		return nil
+	}))
+}
+
+func (s *sysctlParamsSuite) mockProcSys(c *C, name, value string) {
+	p := filepath.Join(dirs.GlobalRootDir, "/proc/sys", strings.Replace(name, ".", "/", -1))
+	c.Assert(os.MkdirAll(filepath.Dir(p), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(p, []byte(value+"\n"), 0644), IsNil)
+}
+
+func (s *sysctlParamsSuite) procSysPath(name string) string {
+	return filepath.Join(dirs.GlobalRootDir, "/proc/sys", strings.Replace(name, ".", "/", -1))
+}
+
+func (s *sysctlParamsSuite) run(c *C, values map[string]interface{}) error {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	s.state.Unlock()
+	for k, v := range values {
+		c.Assert(tr.Set("core", k, v), IsNil)
+	}
+	err := configcore.Run(coreDev, tr)
+	if err == nil {
+		s.state.Lock()
+		tr.Commit()
+		s.state.Unlock()
+	}
+	return err
+}
+
+func (s *sysctlParamsSuite) TestSysctlParamsHappy(c *C) {
+	err := s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.net.ipv4.ip-forward": "1",
+		"system.kernel.sysctl.vm.swappiness":       10,
+		"system.kernel.sysctl.net.ipv4.tcp-rmem":   "4096 87380 6291456",
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileEquals, `# managed by snapd, see the system.kernel.sysctl.* system options
+net.ipv4.ip_forward = 1
+net.ipv4.tcp_rmem = 4096 87380 6291456
+vm.swappiness = 10
+`)
+	c.Check(s.systemdSysctlArgs, DeepEquals, [][]string{
+		{"--prefix", "net.ipv4.ip_forward", "--prefix", "net.ipv4.tcp_rmem", "--prefix", "vm.swappiness"},
+	})
+	c.Check(s.procSysPath("vm.swappiness"), testutil.FileEquals, "10\n")
+	s.systemdSysctlArgs = nil
+
+	// unchanged values are not applied again
+	err = s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.vm.swappiness": 10,
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemdSysctlArgs, HasLen, 0)
+
+	// unset a parameter
+	err = s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.vm.swappiness":     nil,
+		"system.kernel.sysctl.net.ipv4.tcp-rmem": nil,
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileEquals, `# managed by snapd, see the system.kernel.sysctl.* system options
+net.ipv4.ip_forward = 1
+`)
+	c.Check(s.systemdSysctlArgs, DeepEquals, [][]string{
+		{"--prefix", "net.ipv4.tcp_rmem", "--prefix", "vm.swappiness"},
+	})
+	s.systemdSysctlArgs = nil
+
+	// unset all
+	err = s.run(c, map[string]interface{}{
+		"system.kernel.sysctl": nil,
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileAbsent)
+	c.Check(s.systemdSysctlArgs, DeepEquals, [][]string{
+		{"--prefix", "net.ipv4.ip_forward"},
+	})
+}
+
+func (s *sysctlParamsSuite) TestSysctlParamsNameWithDashes(c *C) {
+	s.mockProcSys(c, "net.ipv4.conf.br-lan.rp_filter", "2")
+
+	err := s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.net.ipv4.conf.br-lan.rp-filter": "1",
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileEquals, `# managed by snapd, see the system.kernel.sysctl.* system options
+net.ipv4.conf.br-lan.rp_filter = 1
+`)
+	c.Check(s.procSysPath("net.ipv4.conf.br-lan.rp_filter"), testutil.FileEquals, "1\n")
+}
+
+func (s *sysctlParamsSuite) TestSysctlParamsNotOnClassic(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	s.state.Unlock()
+	c.Assert(tr.Set("core", "system.kernel.sysctl.vm.swappiness", 10), IsNil)
+
+	err := configcore.Run(classicDev, tr)
+	c.Assert(err, IsNil)
+	c.Check(s.confPath, testutil.FileAbsent)
+	c.Check(s.systemdSysctlArgs, HasLen, 0)
+}
+
+func (s *sysctlParamsSuite) TestSysctlParamsValidation(c *C) {
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"system.kernel.sysctl.kernel.printk", "4 4 1 7", `cannot set kernel parameter "kernel.printk": use the system.kernel.printk.console-loglevel system option instead`},
+		{"system.kernel.sysctl.net.ipv6.conf.all.disable-ipv6", "1", `cannot set kernel parameter "net.ipv6.conf.all.disable_ipv6": use the network.disable-ipv6 system option instead`},
+		{"system.kernel.sysctl.swappiness", "10", `cannot set system.kernel.sysctl.swappiness: invalid kernel parameter name`},
+		{"system.kernel.sysctl.vm.swappiness", true, `cannot set kernel parameter "vm.swappiness": value must be a string or a number`},
+		{"system.kernel.sysctl.vm.swappiness", []interface{}{"1"}, `cannot set kernel parameter "vm.swappiness": value must be a string or a number`},
+		{"system.kernel.sysctl.vm.swappiness", " ", `cannot set kernel parameter "vm.swappiness": empty value`},
+		{"system.kernel.sysctl.vm.swappiness", "1\nkernel.printk = 7", `cannot set kernel parameter "vm.swappiness": value cannot span multiple lines`},
+		{"system.kernel.sysctl", "foo", `cannot set system.kernel.sysctl: value must be a map of kernel parameters`},
+	} {
+		err := s.run(c, map[string]interface{}{t.key: t.value})
+		c.Check(err, ErrorMatches, t.err, Commentf("%s=%v", t.key, t.value))
+	}
+	c.Check(s.confPath, testutil.FileAbsent)
+	c.Check(s.systemdSysctlArgs, HasLen, 0)
+}
+
+func (s *sysctlParamsSuite) TestSysctlParamsRevertNotAccepted(c *C) {
+	err := s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.vm.swappiness": 10,
+	})
+	c.Assert(err, IsNil)
+	s.systemdSysctlArgs = nil
+
+	s.kernelRejects = map[string]bool{"net.ipv4.ip_forward": true}
+	err = s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.vm.swappiness":       20,
+		"system.kernel.sysctl.net.ipv4.ip-forward": 1,
+	})
+	c.Assert(err, ErrorMatches, `cannot set kernel parameters: kernel did not accept "1" for "net.ipv4.ip_forward"`)
+
+	// the previous configuration and values are restored
+	c.Check(s.confPath, testutil.FileEquals, `# managed by snapd, see the system.kernel.sysctl.* system options
+vm.swappiness = 10
+`)
+	c.Check(s.procSysPath("vm.swappiness"), testutil.FileEquals, "10")
+	c.Check(s.procSysPath("net.ipv4.ip_forward"), testutil.FileEquals, "0")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var swappiness interface{}
+	c.Assert(config.NewTransaction(s.state).Get("core", "system.kernel.sysctl.vm.swappiness", &swappiness), IsNil)
+	c.Check(swappiness, DeepEquals, json.Number("10"))
+}
+
+func (s *sysctlParamsSuite) TestSysctlParamsRevertUnknownParameter(c *C) {
+	err := s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.net.netfilter.nf-conntrack-max": 262144,
+	})
+	c.Assert(err, ErrorMatches, `cannot set kernel parameters: kernel parameter "net.netfilter.nf_conntrack_max" not available: .*`)
+	c.Check(s.confPath, testutil.FileAbsent)
+}
+
+func (s *sysctlParamsSuite) TestSysctlParamsRevertStoreUnreachable(c *C) {
+	s.fakestore.statusSeq = []map[string]bool{
+		{"host1": true},
+		{"host1": false},
+		{"host1": false},
+		{"host1": false},
+		{"host1": false},
+		{"host1": false},
+	}
+
+	err := s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.net.ipv4.ip-forward": 1,
+	})
+	c.Assert(err, ErrorMatches, `cannot set kernel parameters: store no longer reachable`)
+	c.Check(s.confPath, testutil.FileAbsent)
+	c.Check(s.procSysPath("net.ipv4.ip_forward"), testutil.FileEquals, "0")
+	c.Check(s.fakestore.seq, Equals, 6)
+}
+
+func (s *sysctlParamsSuite) TestSysctlParamsNoConnectivityCheckForNonNetworking(c *C) {
+	s.fakestore.statusSeq = []map[string]bool{{"host1": true}}
+
+	err := s.run(c, map[string]interface{}{
+		"system.kernel.sysctl.vm.swappiness": 10,
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.procSysPath("vm.swappiness"), testutil.FileEquals, "10\n")
+	c.Check(s.fakestore.seq, Equals, 0)
+}
@@ -20,9 +20,15 @@
 package settings
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
 )
 
 // ProblemReportsDisabled returns true if the problem reports are disabled
@@ -39,3 +45,196 @@ func ProblemReportsDisabled(st *state.State) bool {
 
 	return disableProblemReports
 }
+
+// HookLimitClasses are the classes of hooks for which resource limits can be
+// set via the "core.hooks.<class>.{timeout,memory-max,cpu-quota}" settings.
+// The limits of the "default" class apply to all hooks not otherwise
+// classified.
+var HookLimitClasses = []string{"default", "configure", "install-device"}
+
+// HookLimits are resource limits of hooks, a zero value means that the
+// limit is not set.
+type HookLimits struct {
+	Timeout time.Duration
+	// MemoryMax is in bytes.
+	MemoryMax uint64
+	// CPUQuota is in percent of a single CPU.
+	CPUQuota int
+}
+
+// ParseHookTimeout parses the value of a hook timeout setting.
+func ParseHookTimeout(v string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	return timeout, nil
+}
+
+// ParseHookMemoryMax parses the value of a hook memory limit setting, a
+// size like 512MB.
+func ParseHookMemoryMax(v string) (uint64, error) {
+	size, err := strutil.ParseByteSize(v)
+	if err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		return 0, fmt.Errorf("memory limit must be positive")
+	}
+	return uint64(size), nil
+}
+
+// ParseHookCPUQuota parses the value of a hook CPU quota setting, a
+// percentage of a single CPU like 50%.
+func ParseHookCPUQuota(v string) (int, error) {
+	if !strings.HasSuffix(v, "%") {
+		return 0, fmt.Errorf("cpu quota must be a percentage")
+	}
+	quota, err := strconv.Atoi(strings.TrimSuffix(v, "%"))
+	if err != nil || quota <= 0 {
+		return 0, fmt.Errorf("cpu quota must be a positive percentage")
+	}
+	return quota, nil
+}
+
+// HookLimitsForClass returns the resource limits set for the given class of
+// hooks via the "core.hooks.<class>.*" settings.
+//
+// The state must be locked when this is called.
+func HookLimitsForClass(st *state.State, class string) (HookLimits, error) {
+	var limits HookLimits
+
+	tr := config.NewTransaction(st)
+	get := func(name string) (string, error) {
+		var v interface{}
+		key := fmt.Sprintf("hooks.%s.%s", class, name)
+		if err := tr.GetMaybe("core", key, &v); err != nil {
+			return "", fmt.Errorf("cannot get %s setting: %v", key, err)
+		}
+		if v == nil {
+			return "", nil
+		}
+		return fmt.Sprintf("%v", v), nil
+	}
+
+	v, err := get("timeout")
+	if err != nil {
+		return limits, err
+	}
+	if v != "" {
+		if limits.Timeout, err = ParseHookTimeout(v); err != nil {
+			return limits, fmt.Errorf("invalid hooks.%s.timeout setting: %v", class, err)
+		}
+	}
+	v, err = get("memory-max")
+	if err != nil {
+		return limits, err
+	}
+	if v != "" {
+		if limits.MemoryMax, err = ParseHookMemoryMax(v); err != nil {
+			return limits, fmt.Errorf("invalid hooks.%s.memory-max setting: %v", class, err)
+		}
+	}
+	v, err = get("cpu-quota")
+	if err != nil {
+		return limits, err
+	}
+	if v != "" {
+		if limits.CPUQuota, err = ParseHookCPUQuota(v); err != nil {
+			return limits, fmt.Errorf("invalid hooks.%s.cpu-quota setting: %v", class, err)
+		}
+	}
+
+	return limits, nil
+}
+
+const (
+	// DefaultDiskHealthInterval is how often the health of the disks
+	// backing the system is checked by default.
+	DefaultDiskHealthInterval = 24 * time.Hour
+	// DefaultDiskHealthWearThreshold is the percentage of the rated life
+	// time of a disk used above which a warning is raised by default.
+	DefaultDiskHealthWearThreshold = 80
+
+	minDiskHealthInterval = time.Hour
+)
+
+// DiskHealth holds the settings of the monitoring of the health of the disks
+// backing the system.
+type DiskHealth struct {
+	Disabled bool
+	// Interval is how often the health of the disks is checked.
+	Interval time.Duration
+	// WearThreshold is the percentage of the rated life time of a disk
+	// used above which a warning is raised.
+	WearThreshold int
+}
+
+// ParseDiskHealthInterval parses the value of the disk health check
+// interval setting, a duration of at least an hour.
+func ParseDiskHealthInterval(v string) (time.Duration, error) {
+	interval, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if interval < minDiskHealthInterval {
+		return 0, fmt.Errorf("interval must be at least %s", minDiskHealthInterval)
+	}
+	return interval, nil
+}
+
+// ParseDiskHealthWearThreshold parses the value of the disk wear warning
+// threshold setting, a percentage of the rated life time like 80%.
+func ParseDiskHealthWearThreshold(v string) (int, error) {
+	if !strings.HasSuffix(v, "%") {
+		return 0, fmt.Errorf("wear threshold must be a percentage")
+	}
+	threshold, err := strconv.Atoi(strings.TrimSuffix(v, "%"))
+	if err != nil || threshold <= 0 || threshold > 100 {
+		return 0, fmt.Errorf("wear threshold must be a percentage between 1%% and 100%%")
+	}
+	return threshold, nil
+}
+
+// DiskHealthSettings returns the settings of the monitoring of the health
+// of the disks set via the "core.disk-health.{disabled,interval,
+// wear-threshold}" settings.
+//
+// The state must be locked when this is called.
+func DiskHealthSettings(st *state.State) (DiskHealth, error) {
+	settings := DiskHealth{
+		Interval:      DefaultDiskHealthInterval,
+		WearThreshold: DefaultDiskHealthWearThreshold,
+	}
+
+	tr := config.NewTransaction(st)
+	if err := tr.GetMaybe("core", "disk-health.disabled", &settings.Disabled); err != nil {
+		return settings, fmt.Errorf("cannot get disk-health.disabled setting: %v", err)
+	}
+	var v string
+	if err := tr.GetMaybe("core", "disk-health.interval", &v); err != nil {
+		return settings, fmt.Errorf("cannot get disk-health.interval setting: %v", err)
+	}
+	if v != "" {
+		interval, err := ParseDiskHealthInterval(v)
+		if err != nil {
+			return settings, fmt.Errorf("invalid disk-health.interval setting: %v", err)
+		}
+		settings.Interval = interval
+	}
+	v = ""
+	if err := tr.GetMaybe("core", "disk-health.wear-threshold", &v); err != nil {
+		return settings, fmt.Errorf("cannot get disk-health.wear-threshold setting: %v", err)
+	}
+	if v != "" {
+		threshold, err := ParseDiskHealthWearThreshold(v)
+		if err != nil {
+			return settings, fmt.Errorf("invalid disk-health.wear-threshold setting: %v", err)
+		}
+		settings.WearThreshold = threshold
+	}
+	return settings, nil
+}
@@ -21,6 +21,7 @@ package settings_test
 
 import (
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -58,3 +59,135 @@ func (s *settingsSuite) TestSettingProblemReportsDisable(c *C) {
 
 	c.Check(settings.ProblemReportsDisabled(s.state), Equals, true)
 }
+
+func (s *settingsSuite) TestHookLimitsForClassUnset(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	limits, err := settings.HookLimitsForClass(s.state, "configure")
+	c.Assert(err, IsNil)
+	c.Check(limits, Equals, settings.HookLimits{})
+}
+
+func (s *settingsSuite) TestHookLimitsForClass(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "hooks.configure.timeout", "90s")
+	tr.Set("core", "hooks.configure.memory-max", "64MB")
+	tr.Set("core", "hooks.configure.cpu-quota", "50%")
+	tr.Set("core", "hooks.default.timeout", "1h")
+	tr.Commit()
+
+	limits, err := settings.HookLimitsForClass(s.state, "configure")
+	c.Assert(err, IsNil)
+	c.Check(limits, Equals, settings.HookLimits{
+		Timeout:   90 * time.Second,
+		MemoryMax: 64 * 1000 * 1000,
+		CPUQuota:  50,
+	})
+
+	limits, err = settings.HookLimitsForClass(s.state, "default")
+	c.Assert(err, IsNil)
+	c.Check(limits, Equals, settings.HookLimits{Timeout: time.Hour})
+}
+
+func (s *settingsSuite) TestHookLimitsForClassInvalid(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "hooks.install-device.memory-max", "lots")
+	tr.Commit()
+
+	_, err := settings.HookLimitsForClass(s.state, "install-device")
+	c.Assert(err, ErrorMatches, `invalid hooks.install-device.memory-max setting: .*`)
+}
+
+func (s *settingsSuite) TestParseHookLimits(c *C) {
+	timeout, err := settings.ParseHookTimeout("5m")
+	c.Assert(err, IsNil)
+	c.Check(timeout, Equals, 5*time.Minute)
+	_, err = settings.ParseHookTimeout("-5m")
+	c.Check(err, ErrorMatches, "timeout must be positive")
+	_, err = settings.ParseHookTimeout("soon")
+	c.Check(err, ErrorMatches, `time: invalid duration .*`)
+
+	mem, err := settings.ParseHookMemoryMax("1GB")
+	c.Assert(err, IsNil)
+	c.Check(mem, Equals, uint64(1000*1000*1000))
+	_, err = settings.ParseHookMemoryMax("0B")
+	c.Check(err, ErrorMatches, "memory limit must be positive")
+
+	quota, err := settings.ParseHookCPUQuota("150%")
+	c.Assert(err, IsNil)
+	c.Check(quota, Equals, 150)
+	for _, v := range []string{"0%", "-1%", "x%"} {
+		_, err = settings.ParseHookCPUQuota(v)
+		c.Check(err, ErrorMatches, "cpu quota must be a positive percentage", Commentf("%q", v))
+	}
+	_, err = settings.ParseHookCPUQuota("50")
+	c.Check(err, ErrorMatches, "cpu quota must be a percentage")
+}
+
+func (s *settingsSuite) TestDiskHealthSettingsDefault(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	dh, err := settings.DiskHealthSettings(s.state)
+	c.Assert(err, IsNil)
+	c.Check(dh, Equals, settings.DiskHealth{
+		Interval:      24 * time.Hour,
+		WearThreshold: 80,
+	})
+}
+
+func (s *settingsSuite) TestDiskHealthSettings(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "disk-health.disabled", true)
+	tr.Set("core", "disk-health.interval", "6h")
+	tr.Set("core", "disk-health.wear-threshold", "90%")
+	tr.Commit()
+
+	dh, err := settings.DiskHealthSettings(s.state)
+	c.Assert(err, IsNil)
+	c.Check(dh, Equals, settings.DiskHealth{
+		Disabled:      true,
+		Interval:      6 * time.Hour,
+		WearThreshold: 90,
+	})
+}
+
+func (s *settingsSuite) TestDiskHealthSettingsInvalid(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "disk-health.wear-threshold", "120%")
+	tr.Commit()
+
+	_, err := settings.DiskHealthSettings(s.state)
+	c.Check(err, ErrorMatches, `invalid disk-health.wear-threshold setting: wear threshold must be a percentage between 1% and 100%`)
+}
+
+func (s *settingsSuite) TestParseDiskHealthSettings(c *C) {
+	interval, err := settings.ParseDiskHealthInterval("2h30m")
+	c.Assert(err, IsNil)
+	c.Check(interval, Equals, 150*time.Minute)
+	_, err = settings.ParseDiskHealthInterval("10m")
+	c.Check(err, ErrorMatches, "interval must be at least 1h0m0s")
+	_, err = settings.ParseDiskHealthInterval("daily")
+	c.Check(err, ErrorMatches, `time: invalid duration "?daily"?`)
+
+	threshold, err := settings.ParseDiskHealthWearThreshold("75%")
+	c.Assert(err, IsNil)
+	c.Check(threshold, Equals, 75)
+	_, err = settings.ParseDiskHealthWearThreshold("75")
+	c.Check(err, ErrorMatches, "wear threshold must be a percentage")
+	_, err = settings.ParseDiskHealthWearThreshold("0%")
+	c.Check(err, ErrorMatches, "wear threshold must be a percentage between 1% and 100%")
+}
@@ -0,0 +1,198 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// The TLS client certificate presented to the store, the proxy store and
+// to the HTTPS proxies in front of them (as required by many enterprise
+// egress proxies) can be configured with the following system options:
+//
+//	store.tls.client-cert: path of the PEM encoded certificate (chain)
+//	store.tls.client-key:  path of the PEM encoded private key, or
+//	                       "device-key" to use the device key pair
+//
+// The files are loaded each time a certificate is requested, so they can
+// be rotated without restarting snapd. When no client certificate is
+// configured the enrollment device certificate is used, if any.
+
+// ClientCertificateDeviceKey is the value of the store.tls.client-key
+// option selecting the device key pair as TLS client key.
+const ClientCertificateDeviceKey = "device-key"
+
+// clientCertificateConfig caches the TLS client certificate configuration
+// as it is needed without the state lock while establishing connections.
+type clientCertificateConfig struct {
+	mu sync.Mutex
+
+	certFile string
+	keyFile  string
+
+	// deviceKeyID and deviceKey are set when using the device key
+	deviceKeyID string
+	deviceKey   crypto.Signer
+}
+
+// ReloadClientCertificate reloads the configuration of the TLS client
+// certificate presented to the store from the store.tls.* system
+// options as they are set in the given configuration.
+func ReloadClientCertificate(st *state.State, tr config.ConfGetter) error {
+	return deviceMgr(st).reloadClientCertificate(tr)
+}
+
+func (m *DeviceManager) reloadClientCertificate(tr config.ConfGetter) error {
+	// state must be locked
+	var certFile, keyFile string
+	if err := tr.GetMaybe("core", "store.tls.client-cert", &certFile); err != nil {
+		return err
+	}
+	if err := tr.GetMaybe("core", "store.tls.client-key", &keyFile); err != nil {
+		return err
+	}
+
+	var deviceKeyID string
+	var deviceKey crypto.Signer
+	if keyFile == ClientCertificateDeviceKey {
+		var err error
+		deviceKeyID, deviceKey, err = m.clientCertificateDeviceKey()
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg := &m.clientCert
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cfg.certFile = certFile
+	cfg.keyFile = keyFile
+	cfg.deviceKeyID = deviceKeyID
+	cfg.deviceKey = deviceKey
+	return nil
+}
+
+// clientCertificateDeviceKey returns the device key pair usable as TLS
+// client key, if the device has one yet.
+func (m *DeviceManager) clientCertificateDeviceKey() (keyID string, signer crypto.Signer, err error) {
+	privKey, err := m.keyPair()
+	if errors.Is(err, state.ErrNoState) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	signer, err = asserts.CryptoSigner(privKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return privKey.PublicKey().ID(), signer, nil
+}
+
+// deviceKeyChanged updates the cached device key used as TLS client key,
+// if any, after the device key pair changed.
+func (m *DeviceManager) deviceKeyChanged(keyID string) {
+	cfg := &m.clientCert
+	cfg.mu.Lock()
+	usesDeviceKey := cfg.keyFile == ClientCertificateDeviceKey
+	changed := cfg.deviceKeyID != keyID
+	cfg.mu.Unlock()
+	if !usesDeviceKey || !changed {
+		return
+	}
+	if err := m.reloadClientCertificate(config.NewTransaction(m.state)); err != nil {
+		logger.Noticef("cannot reload TLS client certificate configuration: %v", err)
+	}
+}
+
+// clientCertificate returns the TLS client certificate configured with
+// the store.tls.* system options, if any, or nil otherwise.
+func (m *DeviceManager) clientCertificate() (*tls.Certificate, error) {
+	cfg := &m.clientCert
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+
+	if cfg.certFile == "" || cfg.keyFile == "" {
+		return nil, nil
+	}
+	if cfg.keyFile != ClientCertificateDeviceKey {
+		cert, err := tls.LoadX509KeyPair(cfg.certFile, cfg.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load TLS client certificate: %v", err)
+		}
+		return &cert, nil
+	}
+
+	if cfg.deviceKey == nil {
+		// no device key yet
+		return nil, nil
+	}
+	certPEM, err := ioutil.ReadFile(cfg.certFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load TLS client certificate: %v", err)
+	}
+	cert, err := certificateForKey(certPEM, cfg.deviceKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load TLS client certificate: %v", err)
+	}
+	return cert, nil
+}
+
+// certificateForKey builds a TLS certificate out of the given PEM encoded
+// certificate chain and of the signer holding its private key.
+func certificateForKey(certPEM []byte, signer crypto.Signer) (*tls.Certificate, error) {
+	var cert tls.Certificate
+	for {
+		var block *pem.Block
+		block, certPEM = pem.Decode(certPEM)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("no certificate found in PEM data")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(signer.Public()) {
+		return nil, fmt.Errorf("certificate does not match the device key")
+	}
+	cert.PrivateKey = signer
+	cert.Leaf = leaf
+	return &cert, nil
+}
@@ -0,0 +1,165 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
+)
+
+// writeClientCertificate writes a self-signed certificate for the given
+// public key signed by the given signer.
+func writeClientCertificate(c *C, pub crypto.PublicKey, signer crypto.Signer) string {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, pub, signer)
+	c.Assert(err, IsNil)
+	certFile := filepath.Join(c.MkDir(), "client.crt")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	c.Assert(err, IsNil)
+	return certFile
+}
+
+func (s *deviceMgrSerialSuite) setClientCertificateConfig(c *C, certFile, keyFile string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "store.tls.client-cert", certFile), IsNil)
+	c.Assert(tr.Set("core", "store.tls.client-key", keyFile), IsNil)
+	// the configuration in the transaction is used, before committing
+	c.Assert(devicestate.ReloadClientCertificate(s.state, tr), IsNil)
+	tr.Commit()
+}
+
+func (s *deviceMgrSerialSuite) TestClientCertificateFromFiles(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	certFile := writeClientCertificate(c, key.Public(), key)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+	keyFile := filepath.Join(c.MkDir(), "client.key")
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	c.Assert(err, IsNil)
+
+	scb := s.mgr.StoreContextBackend()
+
+	// nothing configured
+	cert, err := scb.DeviceCertificate()
+	c.Assert(err, IsNil)
+	c.Check(cert, IsNil)
+
+	s.setClientCertificateConfig(c, certFile, keyFile)
+
+	cert, err = scb.DeviceCertificate()
+	c.Assert(err, IsNil)
+	c.Assert(cert, NotNil)
+	c.Check(cert.Certificate, HasLen, 1)
+	c.Check(key.Public().(*ecdsa.PublicKey).Equal(cert.PrivateKey.(*ecdsa.PrivateKey).Public()), Equals, true)
+
+	// the files are reloaded when needed
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	otherCertFile := writeClientCertificate(c, otherKey.Public(), otherKey)
+	data, err := ioutil.ReadFile(otherCertFile)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(certFile, data, 0644), IsNil)
+	_, err = scb.DeviceCertificate()
+	c.Check(err, ErrorMatches, `cannot load TLS client certificate: tls: private key does not match public key`)
+
+	// unset
+	s.setClientCertificateConfig(c, "", "")
+	cert, err = scb.DeviceCertificate()
+	c.Assert(err, IsNil)
+	c.Check(cert, IsNil)
+}
+
+func (s *deviceMgrSerialSuite) TestClientCertificateFromDeviceKey(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeModelAssertionInState(c, "canonical", "pc", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+
+	signer, err := asserts.CryptoSigner(devKey)
+	c.Assert(err, IsNil)
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	certFile := writeClientCertificate(c, signer.Public(), caKey)
+
+	scb := s.mgr.StoreContextBackend()
+
+	// no device key yet
+	s.setClientCertificateConfig(c, certFile, "device-key")
+	cert, err := scb.DeviceCertificate()
+	c.Assert(err, IsNil)
+	c.Check(cert, IsNil)
+
+	// the device key is picked up once set
+	devicestate.KeypairManager(s.mgr).Put(devKey)
+	err = scb.SetDevice(&auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+		KeyID: devKey.PublicKey().ID(),
+	})
+	c.Assert(err, IsNil)
+
+	cert, err = scb.DeviceCertificate()
+	c.Assert(err, IsNil)
+	c.Assert(cert, NotNil)
+	c.Check(cert.Certificate, HasLen, 1)
+	c.Check(cert.PrivateKey.(crypto.Signer).Public(), DeepEquals, signer.Public())
+	c.Check(cert.Leaf.Subject.CommonName, Equals, "device")
+
+	// a certificate for another key is refused
+	otherCertFile := writeClientCertificate(c, caKey.Public(), caKey)
+	s.setClientCertificateConfig(c, otherCertFile, "device-key")
+	_, err = scb.DeviceCertificate()
+	c.Check(err, ErrorMatches, `cannot load TLS client certificate: certificate does not match the device key`)
+}
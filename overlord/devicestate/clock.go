@@ -0,0 +1,161 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// Devices with a dead RTC battery can boot with a clock that is far in
+// the past, until it gets synchronized over the network. Meanwhile the
+// certificates of the store appear not to be valid yet, which can prevent
+// reaching the store at all. To help with this the device manager keeps
+// a trusted lower bound for the current time: the most recent of the
+// timestamps of the model and serial assertions and of the last time
+// recorded while the clock was synchronized. While the clock is grossly
+// behind this bound, server certificates are verified as of the bound
+// instead.
+
+var (
+	clockNow = time.Now
+
+	// clockSkewTolerance is how far behind the trusted lower bound the
+	// clock can be before it is considered wrong
+	clockSkewTolerance = 24 * time.Hour
+	// clockCheckInterval is how often the last known good time is
+	// recorded while the clock is synchronized
+	clockCheckInterval = time.Hour
+)
+
+// clockSanity tracks the trusted lower bound for the current time, it
+// is needed without the state lock while establishing connections.
+type clockSanity struct {
+	mu         sync.Mutex
+	lowerBound time.Time
+
+	// lastCheck is only used from Ensure
+	lastCheck time.Time
+}
+
+func (c *clockSanity) bound() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lowerBound
+}
+
+func (c *clockSanity) raiseBound(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.lowerBound) {
+		c.lowerBound = t
+	}
+}
+
+func clockBehind(now, bound time.Time) bool {
+	return !bound.IsZero() && now.Before(bound.Add(-clockSkewTolerance))
+}
+
+// trustedTimeLowerBound returns the most recent of the trusted
+// timestamps known to the device.
+func (m *DeviceManager) trustedTimeLowerBound() (time.Time, error) {
+	// state must be locked
+	var bound time.Time
+	if err := m.state.Get("clock-last-known-good", &bound); err != nil && !errors.Is(err, state.ErrNoState) {
+		return time.Time{}, err
+	}
+
+	model, err := m.Model()
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return time.Time{}, err
+	}
+	if model != nil && model.Timestamp().After(bound) {
+		bound = model.Timestamp()
+	}
+	serial, err := m.Serial()
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return time.Time{}, err
+	}
+	if serial != nil && serial.Timestamp().After(bound) {
+		bound = serial.Timestamp()
+	}
+	return bound, nil
+}
+
+// checkClock compares the system clock with the trusted lower bound for
+// the current time at startup and warns if it is grossly wrong.
+func (m *DeviceManager) checkClock() error {
+	// state must be locked
+	bound, err := m.trustedTimeLowerBound()
+	if err != nil {
+		return err
+	}
+	m.clock.raiseBound(bound)
+
+	now := clockNow()
+	if clockBehind(now, bound) {
+		logger.Noticef("system clock %s is behind the trusted time %s, verifying store certificates as of the latter until the clock is synchronized", now.UTC().Format(time.RFC3339), bound.UTC().Format(time.RFC3339))
+		m.state.Warnf("system clock is set to %s which is before the trusted time %s, the real-time clock battery may be dead: until the clock is synchronized store certificates are verified as of the trusted time", now.UTC().Format(time.RFC3339), bound.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// certificateVerificationTime returns the trusted lower bound for the
+// current time if the system clock is grossly behind it, otherwise the
+// zero time.
+func (m *DeviceManager) certificateVerificationTime() time.Time {
+	bound := m.clock.bound()
+	if clockBehind(clockNow(), bound) {
+		return bound
+	}
+	return time.Time{}
+}
+
+// ensureClockLastKnownGood records the current time as last known good
+// time while the clock is synchronized.
+func (m *DeviceManager) ensureClockLastKnownGood() error {
+	now := clockNow()
+	if !m.clock.lastCheck.IsZero() && !now.Before(m.clock.lastCheck) && now.Sub(m.clock.lastCheck) < clockCheckInterval {
+		return nil
+	}
+	m.clock.lastCheck = now
+
+	synced, err := timeutilIsNTPSynchronized()
+	if err != nil {
+		if !errors.As(err, &timeutil.NoTimedate1Error{}) {
+			logger.Debugf("cannot check if ntp is syncronized: %v", err)
+		}
+		return nil
+	}
+	if !synced {
+		return nil
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.state.Set("clock-last-known-good", now)
+	m.clock.raiseBound(now)
+	return nil
+}
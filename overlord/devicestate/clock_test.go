@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
+)
+
+func (s *deviceMgrSuite) setModelWithTimestamp(c *C, timestamp time.Time) {
+	s.makeModelAssertionInState(c, "canonical", "pc", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+		"timestamp":    timestamp.Format(time.RFC3339),
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+}
+
+func (s *deviceMgrSuite) TestClockBehindTrustedTime(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	modelTime := time.Now().Add(time.Minute).Truncate(time.Second).UTC()
+	s.setModelWithTimestamp(c, modelTime)
+
+	// dead RTC battery
+	now := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer devicestate.MockClockNow(func() time.Time { return now })()
+
+	c.Assert(devicestate.CheckClock(s.mgr), IsNil)
+
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Matches, `system clock is set to 1970-01-01T00:00:00Z which is before the trusted time `+modelTime.Format(time.RFC3339)+`, the real-time clock battery may be dead: .*`)
+
+	scb := s.mgr.StoreContextBackend()
+	c.Check(scb.CertificateVerificationTime(), Equals, modelTime)
+
+	// the clock got synchronized
+	now = modelTime.Add(time.Hour)
+	c.Check(scb.CertificateVerificationTime().IsZero(), Equals, true)
+}
+
+func (s *deviceMgrSuite) TestClockSane(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	modelTime := time.Now().Add(time.Minute).Truncate(time.Second).UTC()
+	s.setModelWithTimestamp(c, modelTime)
+
+	// small skews are tolerated
+	now := modelTime.Add(-time.Hour)
+	defer devicestate.MockClockNow(func() time.Time { return now })()
+
+	c.Assert(devicestate.CheckClock(s.mgr), IsNil)
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+	c.Check(s.mgr.StoreContextBackend().CertificateVerificationTime().IsZero(), Equals, true)
+}
+
+func (s *deviceMgrSuite) TestClockNoTrustedTime(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	defer devicestate.MockClockNow(func() time.Time {
+		return time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	})()
+
+	c.Assert(devicestate.CheckClock(s.mgr), IsNil)
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+	c.Check(s.mgr.StoreContextBackend().CertificateVerificationTime().IsZero(), Equals, true)
+}
+
+func (s *deviceMgrSuite) TestClockLastKnownGood(c *C) {
+	synced := false
+	ntpCalls := 0
+	defer devicestate.MockTimeutilIsNTPSynchronized(func() (bool, error) {
+		ntpCalls++
+		return synced, nil
+	})()
+	goodTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := goodTime
+	defer devicestate.MockClockNow(func() time.Time { return now })()
+
+	// not synchronized, nothing is recorded
+	c.Assert(devicestate.EnsureClockLastKnownGood(s.mgr), IsNil)
+	c.Check(ntpCalls, Equals, 1)
+	var lastGood time.Time
+	s.state.Lock()
+	c.Check(s.state.Get("clock-last-known-good", &lastGood), NotNil)
+	s.state.Unlock()
+
+	// checks are throttled
+	synced = true
+	c.Assert(devicestate.EnsureClockLastKnownGood(s.mgr), IsNil)
+	c.Check(ntpCalls, Equals, 1)
+
+	now = goodTime.Add(2 * time.Hour)
+	c.Assert(devicestate.EnsureClockLastKnownGood(s.mgr), IsNil)
+	c.Check(ntpCalls, Equals, 2)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(s.state.Get("clock-last-known-good", &lastGood), IsNil)
+	c.Check(lastGood.Equal(now), Equals, true)
+
+	// after a reboot with a dead RTC battery the recorded time is
+	// trusted
+	now = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Assert(devicestate.CheckClock(s.mgr), IsNil)
+	c.Check(s.state.AllWarnings(), HasLen, 1)
+	c.Check(s.mgr.StoreContextBackend().CertificateVerificationTime().Equal(goodTime.Add(2*time.Hour)), Equals, true)
+}
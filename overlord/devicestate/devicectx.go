@@ -140,3 +140,9 @@ var _ snapstate.DeviceContext = &modelDeviceContext{}
 func SystemModeInfoFromState(st *state.State) (*SystemModeInfo, error) {
 	return deviceMgr(st).SystemModeInfo()
 }
+
+// ClearFactoryBootFlag clears, on behalf of the given gadget snap, the factory
+// boot flag for the following boots of the device.
+func ClearFactoryBootFlag(st *state.State, gadgetSnap string) error {
+	return deviceMgr(st).ClearFactoryBootFlag(gadgetSnap)
+}
@@ -21,6 +21,8 @@ package devicestate
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -98,6 +100,8 @@ type DeviceManager struct {
 	bootOkRan            bool
 	bootRevisionsUpdated bool
 
+	ensureGadgetRawRollbackRan bool
+
 	seedTimings *timings.Timings
 	// these are used as needed as cache during StartUp and cleared after
 	earlyDeviceCtx  snapstate.DeviceContext
@@ -125,6 +129,13 @@ type DeviceManager struct {
 	preseedSystemLabel string
 
 	ntpSyncedOrTimedOut bool
+
+	clientCert clientCertificateConfig
+
+	clock clockSanity
+
+	// diskHealthLastCheck is only used from Ensure
+	diskHealthLastCheck time.Time
 }
 
 // Manager returns a new device manager.
@@ -170,7 +181,7 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 		return nil, err
 	}
 
-	hookManager.Register(regexp.MustCompile("^prepare-device$"), newBasicHookStateHandler)
+	hookManager.Register(regexp.MustCompile("^prepare-device$"), newPrepareDeviceHookHandler)
 	hookManager.Register(regexp.MustCompile("^install-device$"), newBasicHookStateHandler)
 
 	runner.AddHandler("generate-device-key", m.doGenerateDeviceKey, nil)
@@ -202,12 +213,18 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	runner.AddHandler("create-recovery-system", m.doCreateRecoverySystem, m.undoCreateRecoverySystem)
 	runner.AddHandler("finalize-recovery-system", m.doFinalizeTriedRecoverySystem, m.undoFinalizeTriedRecoverySystem)
 	runner.AddCleanup("finalize-recovery-system", m.cleanupRecoverySystem)
+	// system banks of dual-bank UC16/18 devices
+	runner.AddHandler("try-system-bank", m.doTrySystemBank, nil)
+	runner.AddHandler("finalize-system-bank", m.doFinalizeSystemBank, nil)
 
 	// used from the install API
 	// TODO: use better task names that are close to our usual pattern
 	runner.AddHandler("install-finish", m.doInstallFinish, nil)
 	runner.AddHandler("install-setup-storage-encryption", m.doInstallSetupStorageEncryption, nil)
 
+	// system actions scheduled from the systems API
+	runner.AddHandler("perform-system-action", m.doPerformSystemAction, nil)
+
 	runner.AddBlocked(gadgetUpdateBlocked)
 
 	// wire FDE kernel hook support into boot
@@ -242,6 +259,58 @@ func newBasicHookStateHandler(context *hookstate.Context) hookstate.Handler {
 	return genericHook{}
 }
 
+// prepareDeviceHook hands the escrowed recovery key, if any, to the gadget
+// prepare-device hook and schedules the reboot requested by the hook via
+// snapctl reboot once the hook has finished.
+type prepareDeviceHook struct {
+	genericHook
+	context *hookstate.Context
+}
+
+func newPrepareDeviceHookHandler(context *hookstate.Context) hookstate.Handler {
+	return &prepareDeviceHook{context: context}
+}
+
+// Before makes the recovery key escrowed at install time for the hook to
+// upload available as registration.recovery-key-escrow.
+func (h *prepareDeviceHook) Before() error {
+	escrowed, err := ioutil.ReadFile(device.EscrowedRecoveryKeyUnder(dirs.SnapFDEDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read escrowed recovery key: %v", err)
+	}
+
+	h.context.Lock()
+	defer h.context.Unlock()
+	tr := config.NewTransaction(h.context.State())
+	if err := tr.Set(h.context.InstanceName(), "registration.recovery-key-escrow", string(escrowed)); err != nil {
+		return err
+	}
+	tr.Commit()
+	return nil
+}
+
+func (h *prepareDeviceHook) Done() error {
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	var rebootOpts RebootOptions
+	if err := h.context.Get("reboot", &rebootOpts); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil
+		}
+		return err
+	}
+	chg, err := scheduleHookReboot(h.context.State(), &rebootOpts)
+	if err != nil {
+		return err
+	}
+	logger.Noticef("prepare-device hook scheduled a system reboot by change %s", chg.ID())
+	return nil
+}
+
 func maybeReadModeenv() (*boot.Modeenv, error) {
 	modeenv, err := boot.ReadModeenv("")
 	if err != nil && !os.IsNotExist(err) {
@@ -313,6 +382,16 @@ func (m *DeviceManager) StartUp() error {
 		logger.Noticef("%v", fmt.Errorf("cannot ensure device file/dir permissions: %v", err))
 	}
 
+	if err := m.reloadClientCertificate(config.NewTransaction(m.state)); err != nil {
+		logger.Noticef("cannot load TLS client certificate configuration: %v", err)
+	}
+
+	if !m.preseed {
+		if err := m.checkClock(); err != nil {
+			logger.Noticef("cannot check the system clock: %v", err)
+		}
+	}
+
 	// TODO: setup proper timings measurements for this
 
 	return EarlyConfig(m.state, m.earlyPreloadGadget)
@@ -1027,6 +1106,106 @@ func (m *DeviceManager) ensureBootOk() error {
 	return nil
 }
 
+// ensureGadgetRawRollback discards the previous content of raw gadget
+// structures once the system reached run mode after the update that
+// overwrote them, or writes it back if the system ended up in recover
+// mode instead, as the new content may have failed to boot. In the
+// latter case the snap that brought the new content is reverted once
+// back in run mode, so that the state matches the content on disk.
+func (m *DeviceManager) ensureGadgetRawRollback() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	if m.ensureGadgetRawRollbackRan {
+		return nil
+	}
+	m.ensureGadgetRawRollbackRan = true
+
+	switch m.SystemMode(SysAny) {
+	case "run":
+		snapshotDir := gadgetRawRollbackDir(dirs.SnapSaveDir)
+		if osutil.FileExists(filepath.Join(snapshotDir, "restored")) {
+			return m.revertToRestoredGadgetRawContent(snapshotDir)
+		}
+		data, err := os.ReadFile(filepath.Join(snapshotDir, "boot-id"))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err == nil {
+			bootID, err := osutilBootID()
+			if err != nil {
+				return err
+			}
+			if string(data) == bootID {
+				// not rebooted since the update
+				return nil
+			}
+		}
+		logger.Noticef("discarding previous raw gadget content after reaching run mode")
+		return os.RemoveAll(snapshotDir)
+	case "recover":
+		snapshotDir := gadgetRawRollbackDir(boot.InitramfsUbuntuSaveDir)
+		if !osutil.FileExists(filepath.Join(snapshotDir, "boot-id")) {
+			return nil
+		}
+		restoredFile := filepath.Join(snapshotDir, "restored")
+		if osutil.FileExists(restoredFile) {
+			return nil
+		}
+		logger.Noticef("restoring previous raw gadget content after failing to reach run mode")
+		if err := gadget.RestoreRawRollbackSnapshots(snapshotDir); err != nil {
+			return fmt.Errorf("cannot restore previous raw gadget content: %v", err)
+		}
+		// the run system state is reverted to match once back in
+		// run mode
+		if err := osutil.AtomicWriteFile(restoredFile, nil, 0600, 0); err != nil {
+			return err
+		}
+		m.state.Warnf("previous raw gadget content was restored as the system did not reach run mode after a gadget update")
+	}
+	return nil
+}
+
+// revertToRestoredGadgetRawContent reverts the snap whose update overwrote
+// the raw gadget content that was restored from recover mode to the
+// revision the restored content belongs to.
+func (m *DeviceManager) revertToRestoredGadgetRawContent(snapshotDir string) error {
+	var revs gadgetRawRollbackRevisions
+	data, err := os.ReadFile(filepath.Join(snapshotDir, "revisions"))
+	if err == nil {
+		err = json.Unmarshal(data, &revs)
+	}
+	if err != nil {
+		logger.Noticef("cannot determine the snap revision matching the restored raw gadget content: %v", err)
+		return os.RemoveAll(snapshotDir)
+	}
+
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(m.state, revs.Snap, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if snapst.Current != revs.Revision || revs.Previous.Unset() {
+		// the snap was already reverted or refreshed since
+		return os.RemoveAll(snapshotDir)
+	}
+
+	ts, err := snapstate.RevertToRevision(m.state, revs.Snap, revs.Previous, snapstate.Flags{}, "")
+	if err != nil {
+		var cerr *snapstate.ChangeConflictError
+		if errors.As(err, &cerr) {
+			// try again once the conflicting change is done
+			m.ensureGadgetRawRollbackRan = false
+			return nil
+		}
+		return err
+	}
+	logger.Noticef("reverting %q snap to revision %s to match the restored raw gadget content", revs.Snap, revs.Previous)
+	chg := m.state.NewChange("revert-snap", fmt.Sprintf("Revert %q snap to match the restored raw gadget content", revs.Snap))
+	chg.AddAll(ts)
+	m.state.EnsureBefore(0)
+	return os.RemoveAll(snapshotDir)
+}
+
 func (m *DeviceManager) ensureCloudInitRestricted() error {
 	m.state.Lock()
 	defer m.state.Unlock()
@@ -1669,6 +1848,10 @@ func (m *DeviceManager) Ensure() error {
 			errs = append(errs, err)
 		}
 
+		if err := m.ensureGadgetRawRollback(); err != nil {
+			errs = append(errs, err)
+		}
+
 		if err := m.ensureSeedInConfig(); err != nil {
 			errs = append(errs, err)
 		}
@@ -1692,6 +1875,14 @@ func (m *DeviceManager) Ensure() error {
 		if err := m.ensureExpiredUsersRemoved(); err != nil {
 			errs = append(errs, err)
 		}
+
+		if err := m.ensureClockLastKnownGood(); err != nil {
+			errs = append(errs, err)
+		}
+
+		if err := m.ensureDiskHealth(); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
 	if len(errs) > 0 {
@@ -1707,6 +1898,7 @@ func (m *DeviceManager) ResetToPostBootState() {
 	m.bootOkRan = false
 	m.bootRevisionsUpdated = false
 	m.ensureTriedRecoverySystemRan = false
+	m.ensureGadgetRawRollbackRan = false
 }
 
 var errNoSaveSupport = errors.New("no save directory before UC20")
@@ -1878,7 +2070,11 @@ func (m *DeviceManager) device() (*auth.DeviceState, error) {
 
 // setDevice sets the device details in the state.
 func (m *DeviceManager) setDevice(device *auth.DeviceState) error {
-	return internal.SetDevice(m.state, device)
+	if err := internal.SetDevice(m.state, device); err != nil {
+		return err
+	}
+	m.deviceKeyChanged(device.KeyID)
+	return nil
 }
 
 // Model returns the device model assertion.
@@ -1897,6 +2093,9 @@ type SystemModeInfo struct {
 	Seeded            bool
 	BootFlags         []string
 	HostDataLocations []string
+	// FactoryBoots is the number of following boots during which the
+	// factory boot flag remains set.
+	FactoryBoots int
 }
 
 // SystemModeInfo returns details about the current system mode the device is in.
@@ -1928,6 +2127,14 @@ func (m *DeviceManager) SystemModeInfo() (*SystemModeInfo, error) {
 		}
 		smi.BootFlags = bootFlags
 
+		if mode == boot.ModeRun && strutil.ListContains(bootFlags, "factory") {
+			factoryBoots, err := boot.FactoryBoots(deviceCtx)
+			if err != nil {
+				return nil, err
+			}
+			smi.FactoryBoots = factoryBoots
+		}
+
 		hostDataLocs, err := boot.HostUbuntuDataForMode(mode, deviceCtx.Model())
 		if err != nil {
 			return nil, err
@@ -1937,6 +2144,41 @@ func (m *DeviceManager) SystemModeInfo() (*SystemModeInfo, error) {
 	return &smi, nil
 }
 
+// ClearFactoryBootFlag clears, on behalf of the given gadget snap, the factory
+// boot flag for the following boots of the run system, the boot flags of the
+// current boot are not affected. In install mode this affects the run system
+// being installed.
+func (m *DeviceManager) ClearFactoryBootFlag(gadgetSnap string) error {
+	deviceCtx, err := DeviceCtx(m.state, nil, nil)
+	if errors.Is(err, state.ErrNoState) {
+		return fmt.Errorf("cannot clear factory boot flag before device model is acknowledged")
+	}
+	if err != nil {
+		return err
+	}
+	if !deviceCtx.HasModeenv() {
+		return fmt.Errorf("cannot clear factory boot flag on a system without modes")
+	}
+	if gadgetSnap != deviceCtx.Model().Gadget() {
+		return fmt.Errorf("cannot clear factory boot flag: only the gadget snap can end factory mode")
+	}
+
+	var rootdir string
+	switch mode := deviceCtx.SystemMode(); mode {
+	case boot.ModeRun:
+		rootdir = dirs.GlobalRootDir
+	case boot.ModeInstall:
+		// the run system may not have been set up yet
+		rootdir = boot.InstallHostWritableDir(deviceCtx.Model())
+		if !osutil.FileExists(dirs.SnapModeenvFileUnder(rootdir)) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("cannot clear factory boot flag in %q mode", mode)
+	}
+	return boot.ClearFactoryBootFlag(rootdir)
+}
+
 type SystemAction struct {
 	Title string
 	Mode  string
@@ -2098,6 +2340,8 @@ func (m *DeviceManager) loadSystemAndEssentialSnaps(wantedSystemLabel string, ty
 
 var ErrUnsupportedAction = errors.New("unsupported action")
 
+var systemActionRebootReason = &restart.RebootReason{Code: restart.RebootReasonSystemAction}
+
 // Reboot triggers a reboot into the given systemLabel and mode.
 //
 // When called without a systemLabel and without a mode it will just
@@ -2109,9 +2353,24 @@ var ErrUnsupportedAction = errors.New("unsupported action")
 // Note that "recover" and "run" modes are only available for the
 // current system.
 func (m *DeviceManager) Reboot(systemLabel, mode string) error {
+	return m.restartIntoSystem(systemLabel, mode, restart.RestartSystemNow)
+}
+
+// Shutdown powers off the device. With a system label and a mode, the device
+// is set up to boot into the given system and mode the next time it is
+// started. The same rules as for Reboot apply to the system label and mode.
+func (m *DeviceManager) Shutdown(systemLabel, mode string) error {
+	return m.restartIntoSystem(systemLabel, mode, restart.RestartSystemPoweroffNow)
+}
+
+func (m *DeviceManager) restartIntoSystem(systemLabel, mode string, rt restart.RestartType) error {
+	verb := "rebooting"
+	if rt == restart.RestartSystemPoweroffNow {
+		verb = "powering off"
+	}
 	rebootCurrent := func() {
-		logger.Noticef("rebooting system")
-		restart.Request(m.state, restart.RestartSystemNow, nil)
+		logger.Noticef("%s system", verb)
+		restart.RequestWithReason(m.state, rt, systemActionRebootReason, nil)
 	}
 
 	// most simple case: just reboot
@@ -2134,8 +2393,8 @@ func (m *DeviceManager) Reboot(systemLabel, mode string) error {
 	}
 
 	switched := func(systemLabel string, sysAction *SystemAction) {
-		logger.Noticef("rebooting into system %q in %q mode", systemLabel, sysAction.Mode)
-		restart.Request(m.state, restart.RestartSystemNow, nil)
+		logger.Noticef("%s into system %q in %q mode", verb, systemLabel, sysAction.Mode)
+		restart.RequestWithReason(m.state, rt, systemActionRebootReason, nil)
 	}
 	// even if we are already in the right mode we restart here by
 	// passing rebootCurrent as this is what the user requested
@@ -2154,12 +2413,35 @@ func (m *DeviceManager) RequestSystemAction(systemLabel string, action SystemAct
 	nop := func() {}
 	switched := func(systemLabel string, sysAction *SystemAction) {
 		logger.Noticef("restarting into system %q for action %q", systemLabel, sysAction.Title)
-		restart.Request(m.state, restart.RestartSystemNow, nil)
+		restart.RequestWithReason(m.state, restart.RestartSystemNow, systemActionRebootReason, nil)
 	}
 	// we do nothing (nop) if the mode and system are the same
 	return m.switchToSystemAndMode(systemLabel, action.Mode, nop, switched)
 }
 
+// systemActionForMode returns the action of the seed system with the given
+// label which runs it in the given mode. The current system, which may be
+// nil, is needed as its actions differ from the ones of other systems.
+func systemActionForMode(systemLabel, mode string, currentSys *currentSystem) (*SystemAction, error) {
+	systemSeedDir := filepath.Join(dirs.SnapSeedDir, "systems", systemLabel)
+	if _, err := os.Stat(systemSeedDir); err != nil {
+		// XXX: should we wrap this instead return a naked stat error?
+		return nil, err
+	}
+	system, err := systemFromSeed(systemLabel, currentSys)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load seed system: %v", err)
+	}
+
+	for _, act := range system.Actions {
+		if mode == act.Mode {
+			return &act, nil
+		}
+	}
+	// XXX: provide more context here like what mode was requested?
+	return nil, ErrUnsupportedAction
+}
+
 // switchToSystemAndMode switches to given systemLabel and mode.
 // If the systemLabel and mode are the same as current, it calls
 // sameSystemAndMode. If successful otherwise it calls switched. Both
@@ -2177,26 +2459,9 @@ func (m *DeviceManager) switchToSystemAndMode(systemLabel, mode string, sameSyst
 	// TODO: should we log the error?
 	currentSys, _ := currentSystemForMode(m.state, systemMode)
 
-	systemSeedDir := filepath.Join(dirs.SnapSeedDir, "systems", systemLabel)
-	if _, err := os.Stat(systemSeedDir); err != nil {
-		// XXX: should we wrap this instead return a naked stat error?
-		return err
-	}
-	system, err := systemFromSeed(systemLabel, currentSys)
+	sysAction, err := systemActionForMode(systemLabel, mode, currentSys)
 	if err != nil {
-		return fmt.Errorf("cannot load seed system: %v", err)
-	}
-
-	var sysAction *SystemAction
-	for _, act := range system.Actions {
-		if mode == act.Mode {
-			sysAction = &act
-			break
-		}
-	}
-	if sysAction == nil {
-		// XXX: provide more context here like what mode was requested?
-		return ErrUnsupportedAction
+		return err
 	}
 
 	// XXX: requested mode is valid; only current system has 'run' and
@@ -2288,6 +2553,25 @@ func (scb storeContextBackend) SignDeviceSessionRequest(serial *asserts.Serial,
 	return a.(*asserts.DeviceSessionRequest), nil
 }
 
+// DeviceCertificate returns the TLS client certificate configured with
+// the store.tls.* system options or otherwise the device certificate
+// obtained through enrollment with an external device management
+// service, if any.
+func (scb storeContextBackend) DeviceCertificate() (*tls.Certificate, error) {
+	cert, err := scb.DeviceManager.clientCertificate()
+	if err != nil || cert != nil {
+		return cert, err
+	}
+	return deviceCertificate()
+}
+
+// CertificateVerificationTime returns the trusted time as of which
+// server certificates should be verified while the system clock is
+// grossly wrong, or the zero time otherwise.
+func (scb storeContextBackend) CertificateVerificationTime() time.Time {
+	return scb.DeviceManager.certificateVerificationTime()
+}
+
 func (m *DeviceManager) StoreContextBackend() storecontext.Backend {
 	return storeContextBackend{m}
 }
@@ -2533,6 +2817,42 @@ func (m *DeviceManager) RemoveRecoveryKeys() error {
 	return secbootRemoveRecoveryKeys(recoveryKeyDevices)
 }
 
+var (
+	bootPrepareSecureBootDbxUpdate  = boot.PrepareSecureBootDbxUpdate
+	bootCompleteSecureBootDbxUpdate = boot.CompleteSecureBootDbxUpdate
+)
+
+func (m *DeviceManager) checkSecureBootDbxUpdate() error {
+	mode := m.SystemMode(SysAny)
+	if mode != "run" {
+		return fmt.Errorf("cannot update secure boot DBX from system mode %q", mode)
+	}
+	if !device.HasEncryptedMarkerUnder(dirs.SnapFDEDir) {
+		return fmt.Errorf("system does not use disk encryption")
+	}
+	return nil
+}
+
+// PrepareSecureBootDbxUpdate reseals the encryption keys such that the
+// system keeps booting once the given EFI DBX update is applied to the
+// firmware, which is expected to happen afterwards.
+func (m *DeviceManager) PrepareSecureBootDbxUpdate(update []byte) error {
+	if err := m.checkSecureBootDbxUpdate(); err != nil {
+		return err
+	}
+	return bootPrepareSecureBootDbxUpdate(update)
+}
+
+// CompleteSecureBootDbxUpdate reseals the encryption keys to the current
+// secure boot policy once a previously prepared EFI DBX update has been
+// applied to the firmware.
+func (m *DeviceManager) CompleteSecureBootDbxUpdate() error {
+	if err := m.checkSecureBootDbxUpdate(); err != nil {
+		return err
+	}
+	return bootCompleteSecureBootDbxUpdate()
+}
+
 // EncryptionSupportInfo describes what encryption is available and needed
 // for the current device.
 type EncryptionSupportInfo struct {
@@ -43,13 +43,16 @@ import (
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate/internal"
 	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
+	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/secboot"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/channel"
 	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 var (
@@ -1091,6 +1094,39 @@ func CreateRecoverySystem(st *state.State, label string) (*state.Change, error)
 	return chg, nil
 }
 
+// TrySystemBank creates a change that reboots into the given system bank of
+// a dual-bank UC16/18 device, which becomes the current one if it boots
+// successfully. Otherwise the bootloader boots the current bank again and
+// the change fails.
+func TrySystemBank(st *state.State, bank string) (*state.Change, error) {
+	deviceCtx, err := DeviceCtx(st, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	current, err := boot.SystemBank(deviceCtx)
+	if err != nil {
+		return nil, err
+	}
+	if bank == current {
+		return nil, fmt.Errorf("cannot try system bank %q: bank is already the current one", bank)
+	}
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "try-system-bank" && !chg.IsReady() {
+			return nil, fmt.Errorf("cannot try system bank %q: change %s is already trying a system bank", bank, chg.ID())
+		}
+	}
+
+	chg := st.NewChange("try-system-bank", fmt.Sprintf("Try system bank %q", bank))
+	try := st.NewTask("try-system-bank", fmt.Sprintf("Boot into system bank %q", bank))
+	try.Set("system-bank", bank)
+	finalize := st.NewTask("finalize-system-bank", fmt.Sprintf("Finalize system bank %q", bank))
+	finalize.Set("system-bank", bank)
+	finalize.WaitFor(try)
+	chg.AddTask(try)
+	chg.AddTask(finalize)
+	return chg, nil
+}
+
 // InstallFinish creates a change that will finish the install for the given
 // label and volumes. This includes writing missing volume content, seting
 // up the bootloader and installing the kernel.
@@ -1111,22 +1147,273 @@ func InstallFinish(st *state.State, label string, onVolumes map[string]*gadget.V
 	return chg, nil
 }
 
+// VolumesAuthMode is how the encrypted volumes of an installed system can
+// be unlocked.
+type VolumesAuthMode string
+
+const (
+	// VolumesAuthModeTPM only uses the key sealed to the TPM, or the one
+	// handled by the fde-setup hook of the kernel.
+	VolumesAuthModeTPM VolumesAuthMode = "tpm"
+	// VolumesAuthModePassphrase unlocks the volumes with a passphrase
+	// chosen on install instead, no keys are sealed to the TPM then.
+	VolumesAuthModePassphrase VolumesAuthMode = "passphrase"
+)
+
+// InstallEncryptionOptions are the choices of an installer about the
+// storage encryption of the system being installed.
+type InstallEncryptionOptions struct {
+	// Type is the encryption to use, it must be the one supported by
+	// the device. If empty the one supported by the device is used.
+	Type secboot.EncryptionType
+	// VolumesAuthMode is how the encrypted volumes can be unlocked, it
+	// defaults to VolumesAuthModeTPM.
+	VolumesAuthMode VolumesAuthMode
+	// Passphrase is the passphrase for VolumesAuthModePassphrase. It is
+	// only kept in memory and never saved in the state.
+	Passphrase string
+}
+
+func (opts *InstallEncryptionOptions) validate() error {
+	switch opts.Type {
+	case secboot.EncryptionTypeNone, secboot.EncryptionTypeLUKS, secboot.EncryptionTypeDeviceSetupHook:
+		// ok
+	default:
+		return fmt.Errorf("unknown encryption type %q", opts.Type)
+	}
+	switch opts.VolumesAuthMode {
+	case "", VolumesAuthModeTPM:
+		if opts.Passphrase != "" {
+			return fmt.Errorf("passphrase can only be used with %q volumes authentication mode", VolumesAuthModePassphrase)
+		}
+	case VolumesAuthModePassphrase:
+		if opts.Passphrase == "" {
+			return fmt.Errorf("%q volumes authentication mode requires a passphrase", VolumesAuthModePassphrase)
+		}
+		if opts.Type == secboot.EncryptionTypeDeviceSetupHook {
+			return fmt.Errorf("%q volumes authentication mode cannot be used with %q encryption", VolumesAuthModePassphrase, opts.Type)
+		}
+	default:
+		return fmt.Errorf("unknown volumes authentication mode %q", opts.VolumesAuthMode)
+	}
+	return nil
+}
+
 // InstallSetupStorageEncryption creates a change that will setup the
 // storage encryption for the install of the given label and
-// volumes.
-func InstallSetupStorageEncryption(st *state.State, label string, onVolumes map[string]*gadget.Volume) (*state.Change, error) {
+// volumes, as chosen with the given options, if any.
+func InstallSetupStorageEncryption(st *state.State, label string, onVolumes map[string]*gadget.Volume, opts *InstallEncryptionOptions) (*state.Change, error) {
 	if label == "" {
 		return nil, fmt.Errorf("cannot setup storage encryption with an empty system label")
 	}
 	if onVolumes == nil {
 		return nil, fmt.Errorf("cannot setup storage encryption without volumes data")
 	}
+	if opts == nil {
+		opts = &InstallEncryptionOptions{}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, fmt.Errorf("cannot setup storage encryption: %v", err)
+	}
 
 	chg := st.NewChange("install-step-setup-storage-encryption", fmt.Sprintf("Setup storage encryption for installing system %q", label))
 	setupStorageEncryptionTask := st.NewTask("install-setup-storage-encryption", fmt.Sprintf("Setup storage encryption for installing system %q", label))
 	setupStorageEncryptionTask.Set("system-label", label)
 	setupStorageEncryptionTask.Set("on-volumes", onVolumes)
+	if opts.Type != secboot.EncryptionTypeNone {
+		setupStorageEncryptionTask.Set("encryption-type", opts.Type)
+	}
+	if opts.VolumesAuthMode != "" {
+		setupStorageEncryptionTask.Set("volumes-auth-mode", opts.VolumesAuthMode)
+	}
+	// the passphrase is only kept in memory so that it does not end up
+	// in the state
+	if opts.Passphrase != "" {
+		st.Cache(installPassphraseKey{label}, opts.Passphrase)
+	} else {
+		st.Cache(installPassphraseKey{label}, nil)
+	}
 	chg.AddTask(setupStorageEncryptionTask)
 
 	return chg, nil
 }
+
+// ScheduledSystemAction describes a reboot, a shutdown or a system action
+// request that is carried out at a later time.
+type ScheduledSystemAction struct {
+	// Action is one of "reboot", "shutdown" or "do".
+	Action string `json:"action"`
+	// SystemLabel is the label of the system, for reboot and shutdown it
+	// can be empty in which case the current system is used.
+	SystemLabel string `json:"system-label,omitempty"`
+	// Mode is the mode of the system.
+	Mode string `json:"mode,omitempty"`
+	// Title is the title of the system action, only used with "do".
+	Title string `json:"title,omitempty"`
+}
+
+// SystemActionSchedule describes when a scheduled system action is carried
+// out. Exactly one of At or Window must be set.
+type SystemActionSchedule struct {
+	// At is the time at which the action is carried out.
+	At time.Time
+	// Window is a schedule in the format of refresh.timer, the action is
+	// carried out at the beginning of the next matching window.
+	Window string
+}
+
+const scheduledSystemActionChangeKind = "scheduled-system-action"
+
+func (sched *SystemActionSchedule) next(now time.Time) (time.Time, error) {
+	if sched.At.IsZero() == (sched.Window == "") {
+		return time.Time{}, fmt.Errorf("either a time or a window must be provided")
+	}
+	if sched.Window == "" {
+		return sched.At, nil
+	}
+	windows, err := timeutil.ParseSchedule(sched.Window)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse window: %v", err)
+	}
+	if timeutil.Includes(windows, now) {
+		return now, nil
+	}
+	var at time.Time
+	for _, w := range windows {
+		next := w.Next(now)
+		if at.IsZero() || next.Start.Before(at) {
+			at = next.Start
+		}
+	}
+	return at, nil
+}
+
+// ScheduleSystemAction creates a change that carries out the given reboot,
+// shutdown or system action at the time described by the schedule. The
+// action can be cancelled by aborting the change until it is carried out.
+// Only one scheduled system action can be pending at a time.
+func ScheduleSystemAction(st *state.State, action *ScheduledSystemAction, sched SystemActionSchedule) (*state.Change, error) {
+	var summary string
+	switch action.Action {
+	case "reboot":
+		summary = "Reboot"
+	case "shutdown":
+		summary = "Shut down"
+	case "do":
+		if action.SystemLabel == "" {
+			return nil, fmt.Errorf("system action requires the system label to be provided")
+		}
+		if action.Mode == "" {
+			return nil, fmt.Errorf("system action requires the mode to be provided")
+		}
+		summary = fmt.Sprintf("Perform action %q", action.Title)
+	default:
+		return nil, fmt.Errorf("cannot schedule unsupported system action %q", action.Action)
+	}
+	if action.SystemLabel != "" || action.Mode != "" {
+		if err := checkScheduledSystemAction(st, action); err != nil {
+			return nil, err
+		}
+		// the label can be empty for reboot and shutdown
+		summary += fmt.Sprintf(" into system %q in %q mode", action.SystemLabel, action.Mode)
+	}
+
+	at, err := sched.next(timeNow())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chg := range st.Changes() {
+		if chg.Kind() == scheduledSystemActionChangeKind && !chg.Status().Ready() {
+			return nil, &snapstate.ChangeConflictError{
+				ChangeKind: scheduledSystemActionChangeKind,
+				Message:    "cannot schedule system action, another one is already scheduled",
+				ChangeID:   chg.ID(),
+			}
+		}
+	}
+
+	summary += fmt.Sprintf(" at %s", at.Format(time.RFC3339))
+	chg := st.NewChange(scheduledSystemActionChangeKind, summary)
+	t := st.NewTask("perform-system-action", summary)
+	t.Set("scheduled-system-action", action)
+	t.Set("at", at)
+	chg.AddTask(t)
+
+	return chg, nil
+}
+
+// checkScheduledSystemAction checks that the system of the action is
+// available and supports the mode of the action, so that an invalid request
+// is rejected right away rather than when the action is carried out.
+var checkScheduledSystemAction = func(st *state.State, action *ScheduledSystemAction) error {
+	systemMode := deviceMgr(st).SystemMode(SysAny)
+	switch systemMode {
+	case "install", "factory-reset":
+		return ErrUnsupportedAction
+	}
+	currentSys, err := currentSystemForModeLocked(st, systemMode)
+	systemLabel := action.SystemLabel
+	if systemLabel == "" {
+		// no label means the current system
+		if err != nil {
+			return fmt.Errorf("cannot get current system: %v", err)
+		}
+		systemLabel = currentSys.System
+	}
+	_, err = systemActionForMode(systemLabel, action.Mode, currentSys)
+	return err
+}
+
+// scheduleHookReboot schedules the reboot or power off requested by a gadget
+// hook via snapctl reboot after the requested delay.
+func scheduleHookReboot(st *state.State, opts *RebootOptions) (*state.Change, error) {
+	action := &ScheduledSystemAction{Action: "reboot"}
+	switch opts.Op {
+	case "":
+	case RebootPoweroffOp:
+		action.Action = "shutdown"
+	default:
+		return nil, fmt.Errorf("cannot schedule a delayed system %s", opts.Op)
+	}
+	action.Mode = opts.Mode
+	return ScheduleSystemAction(st, action, SystemActionSchedule{At: timeNow().Add(opts.Delay)})
+}
+
+// ScheduledMaintenance returns the maintenance planned by the pending
+// scheduled system action, if any.
+func ScheduledMaintenance(st *state.State) (*restart.PlannedMaintenance, error) {
+	for _, chg := range st.Changes() {
+		if chg.Kind() != scheduledSystemActionChangeKind || chg.Status().Ready() {
+			continue
+		}
+		for _, t := range chg.Tasks() {
+			if t.Kind() != "perform-system-action" || t.Status().Ready() {
+				continue
+			}
+			var action ScheduledSystemAction
+			if err := t.Get("scheduled-system-action", &action); err != nil {
+				return nil, err
+			}
+			var at time.Time
+			if err := t.Get("at", &at); err != nil {
+				return nil, err
+			}
+			m := &restart.PlannedMaintenance{
+				Kind:   restart.MaintenanceSystemRestart,
+				Action: "reboot",
+				RebootReason: restart.RebootReason{
+					Code:     restart.RebootReasonSystemAction,
+					ChangeID: chg.ID(),
+					TaskID:   t.ID(),
+				},
+				Time: at,
+			}
+			if action.Action == "shutdown" {
+				m.Action = "poweroff"
+			}
+			return m, nil
+		}
+	}
+	return nil, nil
+}
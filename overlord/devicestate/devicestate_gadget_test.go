@@ -1876,3 +1876,180 @@ func (s *deviceMgrGadgetSuite) TestUpdateGadgetCommandlineWithFullArgs(c *C) {
 		"snapd_full_cmdline_args":  "full args",
 	})
 }
+
+func (s *deviceMgrGadgetSuite) TestUpdateGadgetOnUC20KeepsRawRollbackSnapshots(c *C) {
+	restore := devicestate.MockOsutilBootID(func() (string, error) {
+		return "boot-id-1", nil
+	})
+	defer restore()
+
+	restore = devicestate.MockGadgetUpdate(func(model gadget.Model, current, update gadget.GadgetData, path string, policy gadget.UpdatePolicyFunc, observer gadget.ContentUpdateObserver) error {
+		// mock raw content that was overwritten, and one that was not
+		c.Assert(ioutil.WriteFile(filepath.Join(path, "struct-1-0.backup"), []byte("previous"), 0644), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(path, "struct-1-0.location"), []byte(`{"device":"/dev/mmcblk0","offset":0,"size":8}`), 0644), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(path, "struct-1-1.same"), nil, 0644), IsNil)
+		return nil
+	})
+	defer restore()
+
+	c.Assert(os.MkdirAll(dirs.SnapDeviceSaveDir, 0755), IsNil)
+
+	chg, t := s.setupGadgetUpdate(c, "dangerous", uc20gadgetYaml, "", false)
+	s.mockModeenvForMode(c, "run")
+	devicestate.SetBootOkRan(s.mgr, true)
+
+	s.state.Lock()
+	s.state.Set("seeded", true)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.IsReady(), Equals, true)
+	c.Check(chg.Err(), IsNil)
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(s.restartRequests, DeepEquals, []restart.RestartType{restart.RestartSystem})
+
+	c.Check(osutil.IsDirectory(filepath.Join(dirs.SnapRollbackDir, "foo-gadget_34")), Equals, false)
+	snapshotDir := filepath.Join(dirs.SnapDeviceSaveDir, "gadget-raw-rollback")
+	c.Check(filepath.Join(snapshotDir, "boot-id"), testutil.FileEquals, "boot-id-1")
+	c.Check(filepath.Join(snapshotDir, "struct-1-0.backup"), testutil.FileEquals, "previous")
+	c.Check(filepath.Join(snapshotDir, "struct-1-0.location"), testutil.FilePresent)
+	c.Check(filepath.Join(snapshotDir, "struct-1-1.same"), testutil.FileAbsent)
+	c.Check(filepath.Join(snapshotDir, "revisions"), testutil.FileEquals, `{"snap":"foo-gadget","revision":"34","previous":"33"}`)
+}
+
+func (s *deviceMgrGadgetSuite) mockRawRollbackSnapshot(c *C, saveDir, device string) (snapshotDir string) {
+	snapshotDir = filepath.Join(saveDir, "device", "gadget-raw-rollback")
+	c.Assert(os.MkdirAll(snapshotDir, 0700), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(snapshotDir, "boot-id"), []byte("boot-id-1"), 0600), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(snapshotDir, "struct-1-0.backup"), []byte("previous"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(snapshotDir, "struct-1-0.location"), []byte(`{"device":"`+device+`","offset":4,"size":8}`), 0644), IsNil)
+	return snapshotDir
+}
+
+func (s *deviceMgrGadgetSuite) TestEnsureGadgetRawRollbackRunModeSameBoot(c *C) {
+	restore := devicestate.MockOsutilBootID(func() (string, error) {
+		return "boot-id-1", nil
+	})
+	defer restore()
+	devicestate.SetSystemMode(s.mgr, "run")
+	snapshotDir := s.mockRawRollbackSnapshot(c, dirs.SnapSaveDir, "/dev/mmcblk0")
+
+	err := devicestate.EnsureGadgetRawRollback(s.mgr)
+	c.Assert(err, IsNil)
+	// not rebooted yet
+	c.Check(osutil.IsDirectory(snapshotDir), Equals, true)
+}
+
+func (s *deviceMgrGadgetSuite) TestEnsureGadgetRawRollbackRunModeDiscards(c *C) {
+	restore := devicestate.MockOsutilBootID(func() (string, error) {
+		return "boot-id-2", nil
+	})
+	defer restore()
+	devicestate.SetSystemMode(s.mgr, "run")
+	snapshotDir := s.mockRawRollbackSnapshot(c, dirs.SnapSaveDir, "/dev/mmcblk0")
+
+	err := devicestate.EnsureGadgetRawRollback(s.mgr)
+	c.Assert(err, IsNil)
+	c.Check(osutil.IsDirectory(snapshotDir), Equals, false)
+}
+
+func (s *deviceMgrGadgetSuite) TestEnsureGadgetRawRollbackRecoverModeRestores(c *C) {
+	devicestate.SetSystemMode(s.mgr, "recover")
+	device := filepath.Join(c.MkDir(), "mmcblk0")
+	c.Assert(ioutil.WriteFile(device, []byte("0123newcontent89"), 0644), IsNil)
+	snapshotDir := s.mockRawRollbackSnapshot(c, boot.InitramfsUbuntuSaveDir, device)
+
+	err := devicestate.EnsureGadgetRawRollback(s.mgr)
+	c.Assert(err, IsNil)
+	c.Check(device, testutil.FileEquals, "0123previousnt89")
+	// kept for reverting the state once back in run mode
+	c.Check(filepath.Join(snapshotDir, "restored"), testutil.FilePresent)
+
+	s.state.Lock()
+	warns := s.state.AllWarnings()
+	s.state.Unlock()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Matches, "previous raw gadget content was restored .*")
+
+	// only done once
+	c.Assert(ioutil.WriteFile(device, []byte("0123newcontent89"), 0644), IsNil)
+	s.mgr.ResetToPostBootState()
+	err = devicestate.EnsureGadgetRawRollback(s.mgr)
+	c.Assert(err, IsNil)
+	c.Check(device, testutil.FileEquals, "0123newcontent89")
+}
+
+func (s *deviceMgrGadgetSuite) mockRestoredRawRollbackSnapshot(c *C) (snapshotDir string) {
+	snapshotDir = s.mockRawRollbackSnapshot(c, dirs.SnapSaveDir, "/dev/mmcblk0")
+	c.Assert(ioutil.WriteFile(filepath.Join(snapshotDir, "revisions"), []byte(`{"snap":"foo-gadget","revision":"34","previous":"33"}`), 0600), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(snapshotDir, "restored"), nil, 0600), IsNil)
+	return snapshotDir
+}
+
+func (s *deviceMgrGadgetSuite) TestEnsureGadgetRawRollbackRunModeRevertsRestored(c *C) {
+	s.setupGadgetUpdate(c, "dangerous", uc20gadgetYaml, "", false)
+	devicestate.SetSystemMode(s.mgr, "run")
+	snapshotDir := s.mockRestoredRawRollbackSnapshot(c)
+
+	s.state.Lock()
+	si33 := &snap.SideInfo{RealName: "foo-gadget", Revision: snap.R(33), SnapID: "foo-id"}
+	si34 := &snap.SideInfo{RealName: "foo-gadget", Revision: snap.R(34), SnapID: "foo-id"}
+	snapstate.Set(s.state, "foo-gadget", &snapstate.SnapState{
+		SnapType: "gadget",
+		Sequence: []*snap.SideInfo{si33, si34},
+		Current:  si34.Revision,
+		Active:   true,
+	})
+	// the change of the update is still around
+	for _, chg := range s.state.Changes() {
+		chg.SetStatus(state.DoneStatus)
+	}
+	s.state.Unlock()
+
+	err := devicestate.EnsureGadgetRawRollback(s.mgr)
+	c.Assert(err, IsNil)
+	c.Check(osutil.IsDirectory(snapshotDir), Equals, false)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var revertChg *state.Change
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "revert-snap" {
+			revertChg = chg
+		}
+	}
+	c.Assert(revertChg, NotNil)
+	c.Check(revertChg.Summary(), Equals, `Revert "foo-gadget" snap to match the restored raw gadget content`)
+	snapsup, err := snapstate.TaskSnapSetup(revertChg.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Revision(), Equals, snap.R(33))
+}
+
+func (s *deviceMgrGadgetSuite) TestEnsureGadgetRawRollbackRunModeRestoredAlreadyReverted(c *C) {
+	// the gadget is at revision 33 already
+	s.setupGadgetUpdate(c, "dangerous", uc20gadgetYaml, "", false)
+	devicestate.SetSystemMode(s.mgr, "run")
+	snapshotDir := s.mockRestoredRawRollbackSnapshot(c)
+
+	err := devicestate.EnsureGadgetRawRollback(s.mgr)
+	c.Assert(err, IsNil)
+	c.Check(osutil.IsDirectory(snapshotDir), Equals, false)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	for _, chg := range s.state.Changes() {
+		c.Check(chg.Kind(), Not(Equals), "revert-snap")
+	}
+}
+
+func (s *deviceMgrGadgetSuite) TestEnsureGadgetRawRollbackRecoverModeError(c *C) {
+	devicestate.SetSystemMode(s.mgr, "recover")
+	snapshotDir := s.mockRawRollbackSnapshot(c, boot.InitramfsUbuntuSaveDir, filepath.Join(c.MkDir(), "missing"))
+
+	err := devicestate.EnsureGadgetRawRollback(s.mgr)
+	c.Assert(err, ErrorMatches, `cannot restore previous raw gadget content: cannot restore raw rollback snapshot "struct-1-0": cannot open device for writing: .*`)
+	c.Check(osutil.IsDirectory(snapshotDir), Equals, true)
+}
@@ -333,21 +333,38 @@ func (s *deviceMgrInstallAPISuite) TestInstallFinishNoLabel(c *C) {
 - install API finish step \(cannot load assertions for label "classic": no seed assertions\)`)
 }
 
-func (s *deviceMgrInstallAPISuite) testInstallSetupStorageEncryption(c *C, hasTPM bool) {
+type setupStorageEncryptionOpts struct {
+	hasTPM         bool
+	encryptionType secboot.EncryptionType
+	passphrase     string
+	expectedErr    string
+}
+
+func (s *deviceMgrInstallAPISuite) testInstallSetupStorageEncryption(c *C, opts setupStorageEncryptionOpts) {
 	// Mock label
 	label := "classic"
 	isClassic := true
 	gadgetSnapPath, kernelSnapPath, ginfo, mountCmd := s.mockSystemSeedWithLabel(c, label, isClassic)
 
 	// Simulate system with TPM
-	if hasTPM {
+	if opts.hasTPM {
 		restore := devicestate.MockSecbootCheckTPMKeySealingSupported(func() error { return nil })
 		s.AddCleanup(restore)
 	}
 
+	// Mock adding of the passphrase
+	addPassphraseCalls := 0
+	restore := devicestate.MockInstallAddPassphrase(func(setupData *install.EncryptionSetupData, passphrase string) error {
+		addPassphraseCalls++
+		c.Check(setupData, NotNil)
+		c.Check(passphrase, Equals, opts.passphrase)
+		return nil
+	})
+	s.AddCleanup(restore)
+
 	// Mock encryption of partitions
 	encrytpPartCalls := 0
-	restore := devicestate.MockInstallEncryptPartitions(func(onVolumes map[string]*gadget.Volume, encryptionType secboot.EncryptionType, model *asserts.Model, gadgetRoot, kernelRoot string, perfTimings timings.Measurer) (*install.EncryptionSetupData, error) {
+	restore = devicestate.MockInstallEncryptPartitions(func(onVolumes map[string]*gadget.Volume, encryptionType secboot.EncryptionType, model *asserts.Model, gadgetRoot, kernelRoot string, perfTimings timings.Measurer) (*install.EncryptionSetupData, error) {
 		encrytpPartCalls++
 		c.Check(encryptionType, Equals, secboot.EncryptionTypeLUKS)
 		saveFound := false
@@ -376,6 +393,13 @@ func (s *deviceMgrInstallAPISuite) testInstallSetupStorageEncryption(c *C, hasTP
 		"install API set-up encryption step")
 	encryptTask.Set("system-label", label)
 	encryptTask.Set("on-volumes", ginfo.Volumes)
+	if opts.encryptionType != secboot.EncryptionTypeNone {
+		encryptTask.Set("encryption-type", opts.encryptionType)
+	}
+	if opts.passphrase != "" {
+		encryptTask.Set("volumes-auth-mode", devicestate.VolumesAuthModePassphrase)
+		devicestate.MockInstallPassphraseInCache(s.state, label, opts.passphrase)
+	}
 	chg.AddTask(encryptTask)
 
 	// now let the change run - some checks will happen in the mocked functions
@@ -394,10 +418,13 @@ func (s *deviceMgrInstallAPISuite) testInstallSetupStorageEncryption(c *C, hasTP
 	s.state.Lock()
 	defer s.state.Unlock()
 
+	// the passphrase is never kept around
+	c.Check(devicestate.InstallPassphraseFromCache(s.state, label), Equals, "")
+
 	// Checks now
-	if !hasTPM {
-		c.Check(chg.Err(), ErrorMatches, `.*
-.*encryption unavailable on this device: not encrypting device storage as checking TPM gave: .*`)
+	if opts.expectedErr != "" {
+		c.Check(chg.Err(), ErrorMatches, opts.expectedErr)
+		c.Check(addPassphraseCalls, Equals, 0)
 		return
 	}
 
@@ -411,6 +438,14 @@ func (s *deviceMgrInstallAPISuite) testInstallSetupStorageEncryption(c *C, hasTP
 		{"systemd-mount", "--umount", kernelDir},
 	})
 	c.Check(encrytpPartCalls, Equals, 1)
+	if opts.passphrase != "" {
+		c.Check(addPassphraseCalls, Equals, 1)
+		// the keys will not be sealed when finishing the install
+		c.Check(devicestate.VolumesAuthModeFromCache(s.state, label), Equals, devicestate.VolumesAuthModePassphrase)
+	} else {
+		c.Check(addPassphraseCalls, Equals, 0)
+		c.Check(devicestate.VolumesAuthModeFromCache(s.state, label), Equals, devicestate.VolumesAuthModeTPM)
+	}
 	// Check that some data has been stored in the change
 	apiData := make(map[string]interface{})
 	c.Check(chg.Get("api-data", &apiData), IsNil)
@@ -421,11 +456,38 @@ func (s *deviceMgrInstallAPISuite) testInstallSetupStorageEncryption(c *C, hasTP
 }
 
 func (s *deviceMgrInstallAPISuite) TestInstallSetupStorageEncryptionHappy(c *C) {
-	s.testInstallSetupStorageEncryption(c, true)
+	s.testInstallSetupStorageEncryption(c, setupStorageEncryptionOpts{hasTPM: true})
+}
+
+func (s *deviceMgrInstallAPISuite) TestInstallSetupStorageEncryptionWithTypeHappy(c *C) {
+	s.testInstallSetupStorageEncryption(c, setupStorageEncryptionOpts{
+		hasTPM:         true,
+		encryptionType: secboot.EncryptionTypeLUKS,
+	})
+}
+
+func (s *deviceMgrInstallAPISuite) TestInstallSetupStorageEncryptionWithPassphraseHappy(c *C) {
+	s.testInstallSetupStorageEncryption(c, setupStorageEncryptionOpts{
+		hasTPM:     true,
+		passphrase: "correct horse battery staple",
+	})
+}
+
+func (s *deviceMgrInstallAPISuite) TestInstallSetupStorageEncryptionUnsupportedType(c *C) {
+	s.testInstallSetupStorageEncryption(c, setupStorageEncryptionOpts{
+		hasTPM:         true,
+		encryptionType: secboot.EncryptionTypeDeviceSetupHook,
+		expectedErr: `.*
+.*cannot use encryption type "device-setup-hook", this device supports "cryptsetup".*`,
+	})
 }
 
 func (s *deviceMgrInstallAPISuite) TestInstallSetupStorageEncryptionNoCrypto(c *C) {
-	s.testInstallSetupStorageEncryption(c, false)
+	s.testInstallSetupStorageEncryption(c, setupStorageEncryptionOpts{
+		hasTPM: false,
+		expectedErr: `.*
+.*encryption unavailable on this device: not encrypting device storage as checking TPM gave: .*`,
+	})
 }
 
 func (s *deviceMgrInstallAPISuite) TestInstallSetupStorageEncryptionNoLabel(c *C) {
@@ -23,6 +23,11 @@ import (
 	"bytes"
 	"compress/gzip"
 	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -213,6 +218,9 @@ type encTestCase struct {
 	bypass            bool
 	encrypt           bool
 	trustedBootloader bool
+	// gadget.yaml snippet asking for the recovery key to be escrowed
+	recoveryKeyEscrowYaml string
+	escrowPublicKeyPEM    []byte
 }
 
 var (
@@ -242,14 +250,20 @@ func (s *deviceMgrInstallModeSuite) doRunChangeTestWithEncryption(c *C, grade st
 		installSealingObserver = obs
 		installRunCalled++
 		var keyForRole map[string]keys.EncryptionKey
+		var deviceForRole map[string]string
 		if tc.encrypt {
 			keyForRole = map[string]keys.EncryptionKey{
 				gadget.SystemData: dataEncryptionKey,
 				gadget.SystemSave: saveKey,
 			}
+			deviceForRole = map[string]string{
+				gadget.SystemData: "/dev/mapper/ubuntu-data",
+				gadget.SystemSave: "/dev/mapper/ubuntu-save",
+			}
 		}
 		return &install.InstalledSystemSideData{
-			KeyForRole: keyForRole,
+			KeyForRole:    keyForRole,
+			DeviceForRole: deviceForRole,
 		}, nil
 	})
 	defer restore()
@@ -277,8 +291,12 @@ func (s *deviceMgrInstallModeSuite) doRunChangeTestWithEncryption(c *C, grade st
 
 	s.state.Lock()
 	mockModel := s.makeMockInstallModel(c, grade)
-	s.makeMockInstalledPcKernelAndGadget(c, "", "")
+	s.makeMockInstalledPcKernelAndGadget(c, "", tc.recoveryKeyEscrowYaml)
 	s.state.Unlock()
+	if tc.escrowPublicKeyPEM != nil {
+		err := ioutil.WriteFile(filepath.Join(dirs.SnapMountDir, "pc/1/escrow.pem"), tc.escrowPublicKeyPEM, 0644)
+		c.Assert(err, IsNil)
+	}
 
 	bypassEncryptionPath := filepath.Join(boot.InitramfsUbuntuSeedDir, ".force-unencrypted")
 	if tc.bypass {
@@ -1198,6 +1216,84 @@ func (s *deviceMgrInstallModeSuite) TestInstallWithInstallDeviceHookSnapctlReboo
 	s.testInstallWithInstallDeviceHookSnapctlReboot(c, "--poweroff", restart.RestartSystemPoweroffNow)
 }
 
+func (s *deviceMgrInstallModeSuite) TestInstallWithInstallDeviceHookSnapctlRebootIntoRecoverDelayed(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	restore = devicestate.MockInstallRun(func(mod gadget.Model, gadgetRoot, kernelRoot, device string, options install.Options, _ gadget.ContentObserver, _ timings.Measurer) (*install.InstalledSystemSideData, error) {
+		return nil, nil
+	})
+	defer restore()
+
+	restore = hookstate.MockRunHook(func(ctx *hookstate.Context, tomb *tomb.Tomb) ([]byte, error) {
+		c.Assert(ctx.HookName(), Equals, "install-device")
+
+		_, _, err := ctlcmd.Run(ctx, []string{"reboot", "--into=recover", "--delay=10m"}, 0)
+		return nil, err
+	})
+	defer restore()
+
+	restore = devicestate.MockBootEnsureNextBootToRunMode(func(systemLabel string) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})
+	defer restore()
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+	// each look at the clock is an hour later, so that the delay has
+	// passed by the time the scheduled reboot is checked
+	now := time.Now()
+	restore = devicestate.MockTimeNow(func() time.Time {
+		now = now.Add(time.Hour)
+		return now
+	})
+	defer restore()
+	var setRecoveryCalls []string
+	restore = devicestate.MockBootSetRecoveryBootSystemAndMode(func(dev snap.Device, systemLabel, mode string) error {
+		setRecoveryCalls = append(setRecoveryCalls, systemLabel+":"+mode)
+		return nil
+	})
+	defer restore()
+
+	err := ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/modeenv"),
+		[]byte("mode=install\nrecovery_system=20191218\n"), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	s.makeMockInstallModel(c, "dangerous")
+	s.makeMockInstalledPcKernelAndGadget(c, "install-device-hook-content", "")
+	devicestate.SetSystemMode(s.mgr, "install")
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	installSystem := s.findInstallSystem()
+	c.Check(installSystem.Err(), IsNil)
+	c.Check(installSystem.Status(), Equals, state.DoneStatus)
+
+	c.Check(setRecoveryCalls, DeepEquals, []string{"20191218:recover"})
+	// the reboot was carried out by a scheduled system action change
+	// that could be aborted until then
+	c.Check(s.restartRequests, DeepEquals, []restart.RestartType{restart.RestartSystemNow})
+	c.Check(logbuf.String(), Matches, `(?s).*system restart scheduled in 10m0s by change [0-9]+\n.*`)
+	var scheduled *state.Change
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "scheduled-system-action" {
+			scheduled = chg
+		}
+	}
+	c.Assert(scheduled, NotNil)
+	c.Check(scheduled.Status(), Equals, state.DoneStatus)
+	tasks := scheduled.Tasks()
+	c.Assert(tasks, HasLen, 1)
+	var action devicestate.ScheduledSystemAction
+	c.Assert(tasks[0].Get("scheduled-system-action", &action), IsNil)
+	c.Check(action, DeepEquals, devicestate.ScheduledSystemAction{Action: "reboot"})
+}
+
 func (s *deviceMgrInstallModeSuite) TestInstallWithBrokenInstallDeviceHookUnhappy(c *C) {
 	restore := release.MockOnClassic(false)
 	defer restore()
@@ -1443,6 +1539,95 @@ func (s *deviceMgrInstallModeSuite) TestInstallSecuredWithTPMAndSave(c *C) {
 	c.Check(filepath.Join(boot.InstallHostFDESaveDir, "marker"), testutil.FileEquals, marker)
 }
 
+func (s *deviceMgrInstallModeSuite) mockEscrowPublicKey(c *C) *rsa.PrivateKey {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	return priv
+}
+
+func escrowPublicKeyPEM(c *C, priv *rsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	c.Assert(err, IsNil)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func (s *deviceMgrInstallModeSuite) testInstallSecuredWithRecoveryKeyEscrow(c *C, destination, escrowedKeyFile string) {
+	priv := s.mockEscrowPublicKey(c)
+
+	var added []string
+	var addedKey keys.RecoveryKey
+	restore := devicestate.MockSecbootAddRecoveryKey(func(key keys.EncryptionKey, rkey keys.RecoveryKey, node string) error {
+		switch node {
+		case "/dev/mapper/ubuntu-data":
+			c.Check(key, DeepEquals, dataEncryptionKey)
+		case "/dev/mapper/ubuntu-save":
+			c.Check(key, DeepEquals, saveKey)
+		default:
+			c.Errorf("unexpected node %q", node)
+		}
+		if addedKey != (keys.RecoveryKey{}) {
+			c.Check(rkey, Equals, addedKey)
+		}
+		addedKey = rkey
+		added = append(added, node)
+		return nil
+	})
+	defer restore()
+
+	escrowYaml := "recovery-key-escrow:\n  public-key: escrow.pem\n"
+	if destination != "" {
+		escrowYaml += "  destination: " + destination + "\n"
+	}
+	err := s.doRunChangeTestWithEncryption(c, "secured", encTestCase{
+		tpm: true, bypass: false, encrypt: true, trustedBootloader: true,
+		recoveryKeyEscrowYaml: escrowYaml,
+		escrowPublicKeyPEM:    escrowPublicKeyPEM(c, priv),
+	})
+	c.Assert(err, IsNil)
+	c.Check(added, DeepEquals, []string{"/dev/mapper/ubuntu-data", "/dev/mapper/ubuntu-save"})
+
+	// the recovery key is kept on ubuntu-data
+	fdeDir := filepath.Join(dirs.GlobalRootDir, "/run/mnt/ubuntu-data/system-data/var/lib/snapd/device/fde")
+	rkey, err := keys.RecoveryKeyFromFile(filepath.Join(fdeDir, "recovery.key"))
+	c.Assert(err, IsNil)
+	c.Check(*rkey, Equals, addedKey)
+
+	// and can be recovered by the brand from the escrowed copy
+	data, err := ioutil.ReadFile(escrowedKeyFile)
+	c.Assert(err, IsNil)
+	var escrowed keys.EscrowedRecoveryKey
+	c.Assert(json.Unmarshal(data, &escrowed), IsNil)
+	c.Check(escrowed.BrandID, Equals, "my-brand")
+	c.Check(escrowed.Model, Equals, "my-model")
+	decrypted, err := escrowed.Decrypt(priv)
+	c.Assert(err, IsNil)
+	c.Check(decrypted, Equals, addedKey)
+}
+
+func (s *deviceMgrInstallModeSuite) TestInstallSecuredWithRecoveryKeyEscrowToSeed(c *C) {
+	s.testInstallSecuredWithRecoveryKeyEscrow(c, "", filepath.Join(boot.InitramfsSeedEncryptionKeyDir, "recovery-key.escrow"))
+}
+
+func (s *deviceMgrInstallModeSuite) TestInstallSecuredWithRecoveryKeyEscrowForPrepareDevice(c *C) {
+	s.testInstallSecuredWithRecoveryKeyEscrow(c, "prepare-device", filepath.Join(dirs.GlobalRootDir, "/run/mnt/ubuntu-data/system-data/var/lib/snapd/device/fde/recovery-key.escrow"))
+	c.Check(filepath.Join(boot.InitramfsSeedEncryptionKeyDir, "recovery-key.escrow"), testutil.FileAbsent)
+}
+
+func (s *deviceMgrInstallModeSuite) TestInstallSecuredWithRecoveryKeyEscrowAddFails(c *C) {
+	priv := s.mockEscrowPublicKey(c)
+	restore := devicestate.MockSecbootAddRecoveryKey(func(key keys.EncryptionKey, rkey keys.RecoveryKey, node string) error {
+		return fmt.Errorf("boom")
+	})
+	defer restore()
+
+	err := s.doRunChangeTestWithEncryption(c, "secured", encTestCase{
+		tpm: true, bypass: false, encrypt: true, trustedBootloader: true,
+		recoveryKeyEscrowYaml: "recovery-key-escrow:\n  public-key: escrow.pem\n",
+		escrowPublicKeyPEM:    escrowPublicKeyPEM(c, priv),
+	})
+	c.Assert(err, ErrorMatches, `(?s).*cannot add recovery key to /dev/mapper/ubuntu-data: boom.*`)
+}
+
 func (s *deviceMgrInstallModeSuite) TestInstallSecuredBypassEncryption(c *C) {
 	err := s.doRunChangeTestWithEncryption(c, "secured", encTestCase{tpm: false, bypass: true, encrypt: false})
 	c.Assert(err, ErrorMatches, "(?s).*cannot encrypt device storage as mandated by model grade secured:.*TPM not available.*")
@@ -2340,6 +2525,9 @@ type resetTestCase struct {
 	tpm               bool
 	encrypt           bool
 	trustedBootloader bool
+
+	recoveryKeyEscrowYaml string
+	escrowPublicKeyPEM    []byte
 }
 
 func (s *deviceMgrInstallModeSuite) doRunFactoryResetChange(c *C, model *asserts.Model, tc resetTestCase) error {
@@ -2409,9 +2597,14 @@ func (s *deviceMgrInstallModeSuite) doRunFactoryResetChange(c *C, model *asserts
 	}
 
 	s.state.Lock()
-	s.makeMockInstalledPcKernelAndGadget(c, "", "")
+	s.makeMockInstalledPcKernelAndGadget(c, "", tc.recoveryKeyEscrowYaml)
 	s.state.Unlock()
 
+	if tc.escrowPublicKeyPEM != nil {
+		err := ioutil.WriteFile(filepath.Join(dirs.SnapMountDir, "pc/1/escrow.pem"), tc.escrowPublicKeyPEM, 0644)
+		c.Assert(err, IsNil)
+	}
+
 	var saveKey keys.EncryptionKey
 	restore = devicestate.MockSecbootTransitionEncryptionKeyChange(func(node string, key keys.EncryptionKey) error {
 		c.Errorf("unexpected call")
@@ -2798,6 +2991,68 @@ echo "mock output of: $(basename "$0") $*"
 		testutil.FileEquals, "save")
 }
 
+func (s *deviceMgrInstallModeSuite) TestFactoryResetEncryptionWithRecoveryKeyEscrow(c *C) {
+	s.state.Lock()
+	model := s.makeMockInstallModel(c, "dangerous")
+	s.state.Unlock()
+
+	// for debug timinigs
+	mockedSnapCmd := testutil.MockCommand(c, "snap", `
+echo "mock output of: $(basename "$0") $*"
+`)
+	defer mockedSnapCmd.Restore()
+
+	// pretend snap-bootstrap mounted ubuntu-save
+	err := os.MkdirAll(boot.InitramfsUbuntuSaveDir, 0755)
+	c.Assert(err, IsNil)
+	snaptest.PopulateDir(boot.InitramfsSeedEncryptionKeyDir, [][]string{
+		{"ubuntu-data.recovery.sealed-key", "old-data"},
+		{"ubuntu-save.recovery.sealed-key", "old-save"},
+	})
+	makeDeviceSerialAssertionInDir(c, boot.InstallHostDeviceSaveDir, s.storeSigning, s.brands,
+		model, devKey, "serial-1234")
+	err = os.MkdirAll(filepath.Join(boot.InitramfsUbuntuSaveDir, "device/fde"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(boot.InitramfsUbuntuSaveDir, "device/fde/marker"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	priv := s.mockEscrowPublicKey(c)
+	var added []string
+	var addedKey keys.RecoveryKey
+	restore := devicestate.MockSecbootAddRecoveryKey(func(key keys.EncryptionKey, rkey keys.RecoveryKey, node string) error {
+		c.Check(key, NotNil)
+		if addedKey != (keys.RecoveryKey{}) {
+			c.Check(rkey, Equals, addedKey)
+		}
+		addedKey = rkey
+		added = append(added, node)
+		return nil
+	})
+	defer restore()
+
+	err = s.doRunFactoryResetChange(c, model, resetTestCase{
+		tpm: true, encrypt: true, trustedBootloader: true,
+		recoveryKeyEscrowYaml: "recovery-key-escrow:\n  public-key: escrow.pem\n",
+		escrowPublicKeyPEM:    escrowPublicKeyPEM(c, priv),
+	})
+	c.Assert(err, IsNil)
+	// the recovery key removed from ubuntu-save is replaced
+	c.Check(added, DeepEquals, []string{"/dev/foo-data", "/dev/foo-save"})
+
+	fdeDir := filepath.Join(dirs.GlobalRootDir, "/run/mnt/ubuntu-data/system-data/var/lib/snapd/device/fde")
+	rkey, err := keys.RecoveryKeyFromFile(filepath.Join(fdeDir, "recovery.key"))
+	c.Assert(err, IsNil)
+	c.Check(*rkey, Equals, addedKey)
+
+	data, err := ioutil.ReadFile(filepath.Join(boot.InitramfsSeedEncryptionKeyDir, "recovery-key.escrow"))
+	c.Assert(err, IsNil)
+	var escrowed keys.EscrowedRecoveryKey
+	c.Assert(json.Unmarshal(data, &escrowed), IsNil)
+	decrypted, err := escrowed.Decrypt(priv)
+	c.Assert(err, IsNil)
+	c.Check(decrypted, Equals, addedKey)
+}
+
 func (s *deviceMgrInstallModeSuite) TestFactoryResetSerialsWithoutKey(c *C) {
 	s.state.Lock()
 	model := s.makeMockInstallModel(c, "dangerous")
@@ -3909,7 +4164,7 @@ func (s *installStepSuite) TestDeviceManagerInstallSetupStorageEncryptionEmptyLa
 	s.state.Lock()
 	defer s.state.Unlock()
 
-	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "", mockOnVolumes)
+	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "", mockOnVolumes, nil)
 	c.Check(err, ErrorMatches, "cannot setup storage encryption with an empty system label")
 	c.Check(chg, IsNil)
 }
@@ -3918,7 +4173,7 @@ func (s *installStepSuite) TestDeviceManagerInstallSetupStorageEncryptionNoVolum
 	s.state.Lock()
 	defer s.state.Unlock()
 
-	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "1234", nil)
+	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "1234", nil, nil)
 	c.Check(err, ErrorMatches, "cannot setup storage encryption without volumes data")
 	c.Check(chg, IsNil)
 }
@@ -3927,7 +4182,7 @@ func (s *installStepSuite) TestDeviceManagerInstallSetupStorageEncryptionTasksAn
 	s.state.Lock()
 	defer s.state.Unlock()
 
-	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "1234", mockOnVolumes)
+	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "1234", mockOnVolumes, nil)
 	c.Assert(err, IsNil)
 	c.Assert(chg, NotNil)
 	c.Check(chg.Summary(), Matches, `Setup storage encryption for installing system "1234"`)
@@ -3952,7 +4207,7 @@ func (s *installStepSuite) TestDeviceManagerInstallSetupStorageEncryptionRunthro
 	defer st.Unlock()
 
 	s.state.Set("seeded", true)
-	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "1234", mockOnVolumes)
+	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "1234", mockOnVolumes, nil)
 	c.Assert(err, IsNil)
 
 	st.Unlock()
@@ -3964,3 +4219,98 @@ func (s *installStepSuite) TestDeviceManagerInstallSetupStorageEncryptionRunthro
 	c.Check(chg.Err().Error(), testutil.Contains, `cannot perform the following tasks:
 - Setup storage encryption for installing system "1234" (cannot load assertions for label "1234": no seed assertions)`)
 }
+
+func (s *installStepSuite) TestDeviceManagerInstallSetupStorageEncryptionWithOptions(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg, err := devicestate.InstallSetupStorageEncryption(s.state, "1234", mockOnVolumes, &devicestate.InstallEncryptionOptions{
+		Type:            secboot.EncryptionTypeLUKS,
+		VolumesAuthMode: devicestate.VolumesAuthModePassphrase,
+		Passphrase:      "secret passphrase",
+	})
+	c.Assert(err, IsNil)
+	tsks := chg.Tasks()
+	c.Assert(tsks, HasLen, 1)
+	var encType secboot.EncryptionType
+	c.Assert(tsks[0].Get("encryption-type", &encType), IsNil)
+	c.Check(encType, Equals, secboot.EncryptionTypeLUKS)
+	var authMode devicestate.VolumesAuthMode
+	c.Assert(tsks[0].Get("volumes-auth-mode", &authMode), IsNil)
+	c.Check(authMode, Equals, devicestate.VolumesAuthModePassphrase)
+
+	// the passphrase is kept in memory only
+	c.Check(devicestate.InstallPassphraseFromCache(s.state, "1234"), Equals, "secret passphrase")
+	data, err := json.Marshal(s.state)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Not(testutil.Contains), "secret passphrase")
+
+	// and forgotten when the step is requested again without one
+	_, err = devicestate.InstallSetupStorageEncryption(s.state, "1234", mockOnVolumes, nil)
+	c.Assert(err, IsNil)
+	c.Check(devicestate.InstallPassphraseFromCache(s.state, "1234"), Equals, "")
+}
+
+func (s *installStepSuite) TestDeviceManagerInstallSetupStorageEncryptionInvalidOptions(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, tc := range []struct {
+		opts devicestate.InstallEncryptionOptions
+		err  string
+	}{
+		{
+			opts: devicestate.InstallEncryptionOptions{Type: "foo"},
+			err:  `cannot setup storage encryption: unknown encryption type "foo"`,
+		}, {
+			opts: devicestate.InstallEncryptionOptions{VolumesAuthMode: "pin"},
+			err:  `cannot setup storage encryption: unknown volumes authentication mode "pin"`,
+		}, {
+			opts: devicestate.InstallEncryptionOptions{VolumesAuthMode: devicestate.VolumesAuthModePassphrase},
+			err:  `cannot setup storage encryption: "passphrase" volumes authentication mode requires a passphrase`,
+		}, {
+			opts: devicestate.InstallEncryptionOptions{Passphrase: "secret"},
+			err:  `cannot setup storage encryption: passphrase can only be used with "passphrase" volumes authentication mode`,
+		}, {
+			opts: devicestate.InstallEncryptionOptions{
+				VolumesAuthMode: devicestate.VolumesAuthModeTPM,
+				Passphrase:      "secret",
+			},
+			err: `cannot setup storage encryption: passphrase can only be used with "passphrase" volumes authentication mode`,
+		}, {
+			opts: devicestate.InstallEncryptionOptions{
+				Type:            secboot.EncryptionTypeDeviceSetupHook,
+				VolumesAuthMode: devicestate.VolumesAuthModePassphrase,
+				Passphrase:      "secret",
+			},
+			err: `cannot setup storage encryption: "passphrase" volumes authentication mode cannot be used with "device-setup-hook" encryption`,
+		},
+	} {
+		opts := tc.opts
+		chg, err := devicestate.InstallSetupStorageEncryption(s.state, "1234", mockOnVolumes, &opts)
+		c.Check(err, ErrorMatches, tc.err)
+		c.Check(chg, IsNil)
+	}
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *installStepSuite) TestCheckEncryptionSetupMatchesVolumes(c *C) {
+	setupDevices := map[string]string{
+		gadget.SystemSave: "/dev/vda4",
+		gadget.SystemData: "/dev/vda5",
+	}
+	onVolumes := map[string]*gadget.Volume{
+		"pc": {
+			Structure: []gadget.VolumeStructure{
+				{Role: gadget.SystemBoot, Device: "/dev/vda3"},
+				{Role: gadget.SystemSave, Device: "/dev/vda4"},
+				{Role: gadget.SystemData, Device: "/dev/vda5"},
+			},
+		},
+	}
+	c.Check(devicestate.CheckEncryptionSetupMatchesVolumes(setupDevices, onVolumes), IsNil)
+
+	onVolumes["pc"].Structure[2].Device = "/dev/vdb1"
+	c.Check(devicestate.CheckEncryptionSetupMatchesVolumes(setupDevices, onVolumes), ErrorMatches,
+		`storage encryption was set up for system-data on "/dev/vda5" but volumes use "/dev/vdb1"`)
+}
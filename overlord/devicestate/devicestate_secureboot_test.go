@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/devicestate"
+)
+
+var _ = Suite(&deviceMgrSecureBootSuite{})
+
+type deviceMgrSecureBootSuite struct {
+	deviceMgrBaseSuite
+}
+
+func (s *deviceMgrSecureBootSuite) SetUpTest(c *C) {
+	s.deviceMgrBaseSuite.setupBaseTest(c, false)
+	s.setUC20PCModelInState(c)
+
+	devicestate.SetSystemMode(s.mgr, "run")
+}
+
+func (s *deviceMgrSecureBootSuite) TestPrepareSecureBootDbxUpdate(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	var updates [][]byte
+	defer devicestate.MockBootPrepareSecureBootDbxUpdate(func(update []byte) error {
+		updates = append(updates, update)
+		return nil
+	})()
+
+	err := s.mgr.PrepareSecureBootDbxUpdate([]byte("dbx"))
+	c.Check(err, ErrorMatches, `system does not use disk encryption`)
+	c.Check(updates, HasLen, 0)
+
+	mockSnapFDEFile(c, "marker", nil)
+
+	err = s.mgr.PrepareSecureBootDbxUpdate([]byte("dbx"))
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, [][]byte{[]byte("dbx")})
+}
+
+func (s *deviceMgrSecureBootSuite) TestPrepareSecureBootDbxUpdateError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	defer devicestate.MockBootPrepareSecureBootDbxUpdate(func(update []byte) error {
+		return errors.New("boom")
+	})()
+	mockSnapFDEFile(c, "marker", nil)
+
+	err := s.mgr.PrepareSecureBootDbxUpdate([]byte("dbx"))
+	c.Assert(err, ErrorMatches, "boom")
+}
+
+func (s *deviceMgrSecureBootSuite) TestCompleteSecureBootDbxUpdate(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	calls := 0
+	defer devicestate.MockBootCompleteSecureBootDbxUpdate(func() error {
+		calls++
+		return nil
+	})()
+
+	err := s.mgr.CompleteSecureBootDbxUpdate()
+	c.Check(err, ErrorMatches, `system does not use disk encryption`)
+	c.Check(calls, Equals, 0)
+
+	mockSnapFDEFile(c, "marker", nil)
+
+	err = s.mgr.CompleteSecureBootDbxUpdate()
+	c.Assert(err, IsNil)
+	c.Check(calls, Equals, 1)
+}
+
+func (s *deviceMgrSecureBootSuite) TestSecureBootDbxUpdateOtherModes(c *C) {
+	defer devicestate.MockBootPrepareSecureBootDbxUpdate(func(update []byte) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})()
+	defer devicestate.MockBootCompleteSecureBootDbxUpdate(func() error {
+		c.Fatalf("unexpected call")
+		return nil
+	})()
+	mockSnapFDEFile(c, "marker", nil)
+
+	for _, mode := range []string{"recover", "install"} {
+		devicestate.SetSystemMode(s.mgr, mode)
+
+		err := s.mgr.PrepareSecureBootDbxUpdate([]byte("dbx"))
+		c.Check(err, ErrorMatches, fmt.Sprintf(`cannot update secure boot DBX from system mode %q`, mode))
+		err = s.mgr.CompleteSecureBootDbxUpdate()
+		c.Check(err, ErrorMatches, fmt.Sprintf(`cannot update secure boot DBX from system mode %q`, mode))
+	}
+}
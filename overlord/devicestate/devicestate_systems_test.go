@@ -42,6 +42,8 @@ import (
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -980,6 +982,88 @@ func (s *deviceMgrSystemsSuite) TestRebootUnhappy(c *C) {
 	c.Check(s.logbuf.String(), Equals, "")
 }
 
+func (s *deviceMgrSystemsSuite) TestShutdownLabelAndModeHappy(c *C) {
+	s.state.Lock()
+	s.state.Set("seeded-systems", []devicestate.SeededSystem{
+		{
+			System:  s.mockedSystemSeeds[0].label,
+			Model:   s.mockedSystemSeeds[0].model.Model(),
+			BrandID: s.mockedSystemSeeds[0].brand.AccountID(),
+		},
+	})
+	s.state.Unlock()
+
+	err := s.mgr.Shutdown("20191119", "install")
+	c.Assert(err, IsNil)
+
+	m, err := s.bootloader.GetBootVars("snapd_recovery_mode", "snapd_recovery_system")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snapd_recovery_system": "20191119",
+		"snapd_recovery_mode":   "install",
+	})
+	c.Check(s.restartRequests, DeepEquals, []restart.RestartType{restart.RestartSystemPoweroffNow})
+	c.Check(s.logbuf.String(), Matches, `.*: powering off into system "20191119" in "install" mode\n`)
+}
+
+func (s *deviceMgrSystemsSuite) TestScheduleSystemActionChecksSystem(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded-systems", []devicestate.SeededSystem{
+		{
+			System:  s.mockedSystemSeeds[0].label,
+			Model:   s.mockedSystemSeeds[0].model.Model(),
+			BrandID: s.mockedSystemSeeds[0].brand.AccountID(),
+		},
+	})
+
+	at := time.Now().Add(time.Hour)
+	for _, action := range []devicestate.ScheduledSystemAction{
+		{Action: "reboot", SystemLabel: "20191119", Mode: "recover"},
+		// the current system
+		{Action: "shutdown", Mode: "run"},
+		{Action: "do", SystemLabel: "20200318", Mode: "install", Title: "Install"},
+	} {
+		chg, err := devicestate.ScheduleSystemAction(s.state, &action, devicestate.SystemActionSchedule{At: at})
+		c.Assert(err, IsNil)
+		chg.Abort()
+		chg.SetStatus(state.HoldStatus)
+	}
+
+	errUnsupportedActionStr := devicestate.ErrUnsupportedAction.Error()
+	for _, tc := range []struct {
+		action      devicestate.ScheduledSystemAction
+		expectedErr string
+	}{
+		{devicestate.ScheduledSystemAction{Action: "reboot", Mode: "unknown-mode"}, errUnsupportedActionStr},
+		// only the current system can be recovered
+		{devicestate.ScheduledSystemAction{Action: "reboot", SystemLabel: "20200318", Mode: "recover"}, errUnsupportedActionStr},
+		{devicestate.ScheduledSystemAction{Action: "shutdown", SystemLabel: "unknown-system", Mode: "run"}, `stat /.*: no such file or directory`},
+		{devicestate.ScheduledSystemAction{Action: "do", SystemLabel: "unknown-system", Mode: "install"}, `stat /.*: no such file or directory`},
+	} {
+		_, err := devicestate.ScheduleSystemAction(s.state, &tc.action, devicestate.SystemActionSchedule{At: at})
+		c.Check(err, ErrorMatches, tc.expectedErr)
+	}
+	for _, chg := range s.state.Changes() {
+		c.Check(chg.Status(), Equals, state.HoldStatus)
+	}
+}
+
+func (s *deviceMgrSystemsSuite) TestScheduleSystemActionUnsupportedInInstallMode(c *C) {
+	devicestate.SetSystemMode(s.mgr, "install")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := devicestate.ScheduleSystemAction(s.state, &devicestate.ScheduledSystemAction{
+		Action:      "reboot",
+		SystemLabel: "20191119",
+		Mode:        "install",
+	}, devicestate.SystemActionSchedule{At: time.Now().Add(time.Hour)})
+	c.Check(err, Equals, devicestate.ErrUnsupportedAction)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
 func (s *deviceMgrSystemsSuite) TestDeviceManagerEnsureTriedSystemSuccessfuly(c *C) {
 	err := s.bootloader.SetBootVars(map[string]string{
 		"try_recovery_system":    "1234",
@@ -2177,6 +2261,279 @@ func (s *deviceMgrSystemsCreateSuite) TestDeviceManagerCreateRecoverySystemReboo
 	c.Check(triedSystems, HasLen, 0)
 }
 
+type systemActionScheduleSuite struct {
+	deviceMgrBaseSuite
+
+	logbuf *bytes.Buffer
+}
+
+var _ = Suite(&systemActionScheduleSuite{})
+
+func (s *systemActionScheduleSuite) SetUpTest(c *C) {
+	classic := false
+	s.deviceMgrBaseSuite.setupBaseTest(c, classic)
+
+	logbuf, restore := logger.MockLogger()
+	s.logbuf = logbuf
+	s.AddCleanup(restore)
+
+	// there are no seed systems, validating them is tested along with
+	// rebooting into them
+	s.AddCleanup(devicestate.MockCheckScheduledSystemAction(func(*state.State, *devicestate.ScheduledSystemAction) error {
+		return nil
+	}))
+}
+
+// runTasks runs the task runner only, the device manager would otherwise
+// attempt to seed the device
+func (s *systemActionScheduleSuite) runTasks(c *C) {
+	runner := s.o.TaskRunner()
+	c.Assert(runner.Ensure(), IsNil)
+	runner.Wait()
+}
+
+func (s *systemActionScheduleSuite) TestScheduleSystemActionChange(c *C) {
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	restore := devicestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	action := &devicestate.ScheduledSystemAction{
+		Action:      "reboot",
+		SystemLabel: "20191119",
+		Mode:        "recover",
+	}
+	chg, err := devicestate.ScheduleSystemAction(s.state, action, devicestate.SystemActionSchedule{
+		At: now.Add(time.Hour),
+	})
+	c.Assert(err, IsNil)
+	c.Check(chg.Kind(), Equals, "scheduled-system-action")
+	c.Check(chg.Summary(), Equals, `Reboot into system "20191119" in "recover" mode at 2030-01-02T04:04:05Z`)
+	tsks := chg.Tasks()
+	c.Assert(tsks, HasLen, 1)
+	c.Check(tsks[0].Kind(), Equals, "perform-system-action")
+	var taskAction devicestate.ScheduledSystemAction
+	c.Assert(tsks[0].Get("scheduled-system-action", &taskAction), IsNil)
+	c.Check(taskAction, DeepEquals, *action)
+	var at time.Time
+	c.Assert(tsks[0].Get("at", &at), IsNil)
+	c.Check(at.Equal(now.Add(time.Hour)), Equals, true)
+
+	// only one system action can be scheduled at a time
+	_, err = devicestate.ScheduleSystemAction(s.state, &devicestate.ScheduledSystemAction{Action: "shutdown"}, devicestate.SystemActionSchedule{
+		At: now.Add(time.Hour),
+	})
+	c.Assert(err, FitsTypeOf, &snapstate.ChangeConflictError{})
+	c.Check(err, ErrorMatches, "cannot schedule system action, another one is already scheduled")
+	c.Check(err.(*snapstate.ChangeConflictError).ChangeID, Equals, chg.ID())
+
+	// but it is possible once the previous one is cancelled
+	chg.Abort()
+	chg.SetStatus(state.HoldStatus)
+	chg2, err := devicestate.ScheduleSystemAction(s.state, &devicestate.ScheduledSystemAction{Action: "shutdown"}, devicestate.SystemActionSchedule{
+		At: now.Add(time.Hour),
+	})
+	c.Assert(err, IsNil)
+	c.Check(chg2.Summary(), Equals, "Shut down at 2030-01-02T04:04:05Z")
+}
+
+func (s *systemActionScheduleSuite) TestScheduleSystemActionWindow(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// a window that always includes the current time
+	chg, err := devicestate.ScheduleSystemAction(s.state, &devicestate.ScheduledSystemAction{
+		Action:      "do",
+		SystemLabel: "20191119",
+		Mode:        "install",
+		Title:       "reinstall",
+	}, devicestate.SystemActionSchedule{Window: "00:00-24:00"})
+	c.Assert(err, IsNil)
+	c.Check(chg.Summary(), Matches, `Perform action "reinstall" into system "20191119" in "install" mode at .*`)
+	var at time.Time
+	c.Assert(chg.Tasks()[0].Get("at", &at), IsNil)
+	c.Check(at.After(time.Now()), Equals, false)
+}
+
+func (s *systemActionScheduleSuite) TestScheduledMaintenance(c *C) {
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	restore := devicestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	m, err := devicestate.ScheduledMaintenance(s.state)
+	c.Assert(err, IsNil)
+	c.Check(m, IsNil)
+
+	chg, err := devicestate.ScheduleSystemAction(s.state, &devicestate.ScheduledSystemAction{Action: "shutdown"}, devicestate.SystemActionSchedule{
+		At: now.Add(time.Hour),
+	})
+	c.Assert(err, IsNil)
+
+	m, err = devicestate.ScheduledMaintenance(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(m, NotNil)
+	c.Check(m.Time.Equal(now.Add(time.Hour)), Equals, true)
+	m.Time = time.Time{}
+	c.Check(m, DeepEquals, &restart.PlannedMaintenance{
+		Kind:   "system-restart",
+		Action: "poweroff",
+		RebootReason: restart.RebootReason{
+			Code:     restart.RebootReasonSystemAction,
+			ChangeID: chg.ID(),
+			TaskID:   chg.Tasks()[0].ID(),
+		},
+	})
+
+	// nothing is planned once the action is cancelled
+	chg.Abort()
+	chg.SetStatus(state.HoldStatus)
+	m, err = devicestate.ScheduledMaintenance(s.state)
+	c.Assert(err, IsNil)
+	c.Check(m, IsNil)
+}
+
+func (s *systemActionScheduleSuite) TestPrepareDeviceHookSchedulesReboot(c *C) {
+	now := time.Now()
+	restore := devicestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	task := s.state.NewTask("run-hook", "")
+	setup := &hookstate.HookSetup{Snap: "pc", Revision: snap.R(1), Hook: "prepare-device"}
+	s.state.Unlock()
+	ctx, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+	handler := devicestate.NewPrepareDeviceHookHandler(ctx)
+
+	// nothing requested
+	c.Assert(handler.Done(), IsNil)
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 0)
+	s.state.Unlock()
+
+	// as done by snapctl reboot --into=recover --delay=5m
+	ctx.Lock()
+	ctx.Set("reboot", devicestate.RebootOptions{Mode: "recover", Delay: 5 * time.Minute})
+	ctx.Unlock()
+	c.Assert(handler.Done(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	changes := s.state.Changes()
+	c.Assert(changes, HasLen, 1)
+	chg := changes[0]
+	c.Check(chg.Kind(), Equals, "scheduled-system-action")
+	var action devicestate.ScheduledSystemAction
+	c.Assert(chg.Tasks()[0].Get("scheduled-system-action", &action), IsNil)
+	c.Check(action, DeepEquals, devicestate.ScheduledSystemAction{Action: "reboot", Mode: "recover"})
+	var at time.Time
+	c.Assert(chg.Tasks()[0].Get("at", &at), IsNil)
+	c.Check(at.Equal(now.Add(5*time.Minute)), Equals, true)
+	c.Check(s.logbuf.String(), Matches, `(?s).*prepare-device hook scheduled a system reboot by change [0-9]+\n`)
+}
+
+func (s *systemActionScheduleSuite) TestScheduleSystemActionErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	at := time.Now().Add(time.Hour)
+	for _, tc := range []struct {
+		action devicestate.ScheduledSystemAction
+		sched  devicestate.SystemActionSchedule
+		err    string
+	}{
+		{devicestate.ScheduledSystemAction{Action: "install"}, devicestate.SystemActionSchedule{At: at}, `cannot schedule unsupported system action "install"`},
+		{devicestate.ScheduledSystemAction{Action: "do", Mode: "install"}, devicestate.SystemActionSchedule{At: at}, `system action requires the system label to be provided`},
+		{devicestate.ScheduledSystemAction{Action: "do", SystemLabel: "1234"}, devicestate.SystemActionSchedule{At: at}, `system action requires the mode to be provided`},
+		{devicestate.ScheduledSystemAction{Action: "reboot"}, devicestate.SystemActionSchedule{}, `either a time or a window must be provided`},
+		{devicestate.ScheduledSystemAction{Action: "reboot"}, devicestate.SystemActionSchedule{At: at, Window: "mon"}, `either a time or a window must be provided`},
+		{devicestate.ScheduledSystemAction{Action: "reboot"}, devicestate.SystemActionSchedule{Window: "foo"}, `cannot parse window: .*`},
+	} {
+		_, err := devicestate.ScheduleSystemAction(s.state, &tc.action, tc.sched)
+		c.Check(err, ErrorMatches, tc.err)
+	}
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *systemActionScheduleSuite) TestScheduledRebootWaitsAndCanBeCancelled(c *C) {
+	now := time.Now()
+	restore := devicestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	chg, err := devicestate.ScheduleSystemAction(s.state, &devicestate.ScheduledSystemAction{Action: "reboot"}, devicestate.SystemActionSchedule{
+		At: now.Add(time.Hour),
+	})
+	c.Assert(err, IsNil)
+	s.state.Unlock()
+
+	s.runTasks(c)
+
+	s.state.Lock()
+	// still waiting
+	c.Check(chg.Status(), Equals, state.DoingStatus)
+	c.Check(s.restartRequests, HasLen, 0)
+
+	// cancel the action
+	chg.Abort()
+	s.state.Unlock()
+
+	s.runTasks(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Status(), Equals, state.HoldStatus)
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
+func (s *systemActionScheduleSuite) TestScheduledRebootRunThrough(c *C) {
+	now := time.Now()
+	restore := devicestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	chg, err := devicestate.ScheduleSystemAction(s.state, &devicestate.ScheduledSystemAction{Action: "reboot"}, devicestate.SystemActionSchedule{
+		At: now.Add(-time.Minute),
+	})
+	c.Assert(err, IsNil)
+	s.state.Unlock()
+
+	s.runTasks(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.restartRequests, DeepEquals, []restart.RestartType{restart.RestartSystemNow})
+	c.Check(s.logbuf.String(), Matches, `(?s).*: rebooting system\n`)
+}
+
+func (s *systemActionScheduleSuite) TestScheduledShutdownNotRepeated(c *C) {
+	now := time.Now()
+	restore := devicestate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	chg, err := devicestate.ScheduleSystemAction(s.state, &devicestate.ScheduledSystemAction{Action: "shutdown"}, devicestate.SystemActionSchedule{
+		At: now.Add(-time.Minute),
+	})
+	c.Assert(err, IsNil)
+	// pretend snapd was restarted after the action was carried out
+	chg.Tasks()[0].Set("performed", true)
+	s.state.Unlock()
+
+	s.runTasks(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.restartRequests, HasLen, 0)
+}
+
 type systemSnapTrackingSuite struct {
 	deviceMgrSystemsBaseSuite
 }
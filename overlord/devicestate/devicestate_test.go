@@ -681,6 +681,125 @@ func (s *deviceMgrSuite) TestDeviceManagerEnsureBootOkError(c *C) {
 	c.Assert(err, ErrorMatches, "devicemgr: cannot mark boot successful: bootloader err")
 }
 
+func (s *deviceMgrSuite) mockSystemBanks(c *C) {
+	s.setPCModelInState(c)
+	s.bootloader.SetBootVars(map[string]string{
+		"snap_banks": "a,b",
+		"snap_bank":  "a",
+	})
+	devicestate.SetBootOkRan(s.mgr, true)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("seeded", true)
+	siCore1 := &snap.SideInfo{RealName: "core", Revision: snap.R(1)}
+	snapstate.Set(s.state, "core", &snapstate.SnapState{
+		SnapType: "os",
+		Active:   true,
+		Sequence: []*snap.SideInfo{siCore1},
+		Current:  siCore1.Revision,
+	})
+}
+
+func (s *deviceMgrSuite) testTrySystemBank(c *C, booted bool) *state.Change {
+	s.mockSystemBanks(c)
+
+	s.state.Lock()
+	chg, err := devicestate.TrySystemBank(s.state, "b")
+	c.Assert(err, IsNil)
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[0].Kind(), Equals, "try-system-bank")
+	c.Check(tasks[1].Kind(), Equals, "finalize-system-bank")
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{tasks[0]})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	c.Check(chg.IsReady(), Equals, false)
+	c.Check(tasks[0].Status(), Equals, state.DoneStatus)
+	c.Check(tasks[1].Status(), Equals, state.DoingStatus)
+	c.Check(s.restartRequests, DeepEquals, []restart.RestartType{restart.RestartSystemNow})
+	m, err := s.bootloader.GetBootVars("snap_bank", "snap_try_bank", "snap_bank_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_bank":      "a",
+		"snap_try_bank":  "b",
+		"snap_bank_mode": boot.TryStatus,
+	})
+
+	// reboot
+	restart.MockPending(s.state, restart.RestartUnset)
+	if booted {
+		s.bootloader.SetBootVars(map[string]string{"snap_bank_mode": boot.TryingStatus})
+	} else {
+		// the bootloader went back to the current bank
+		s.bootloader.SetBootVars(map[string]string{"snap_bank_mode": boot.DefaultStatus})
+	}
+	devicestate.SetBootOkRan(s.mgr, false)
+	s.state.Unlock()
+
+	c.Assert(devicestate.EnsureBootOk(s.mgr), IsNil)
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.IsReady(), Equals, true)
+	return chg
+}
+
+func (s *deviceMgrSuite) TestTrySystemBankHappy(c *C) {
+	chg := s.testTrySystemBank(c, true)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Err(), IsNil)
+	m, err := s.bootloader.GetBootVars("snap_bank", "snap_try_bank", "snap_bank_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_bank":      "b",
+		"snap_try_bank":  "",
+		"snap_bank_mode": boot.DefaultStatus,
+	})
+}
+
+func (s *deviceMgrSuite) TestTrySystemBankFailedToBoot(c *C) {
+	chg := s.testTrySystemBank(c, false)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Err(), ErrorMatches, `(?s).*tried system bank "b" failed to boot.*`)
+	m, err := s.bootloader.GetBootVars("snap_bank", "snap_try_bank", "snap_bank_mode")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_bank":      "a",
+		"snap_try_bank":  "",
+		"snap_bank_mode": boot.DefaultStatus,
+	})
+}
+
+func (s *deviceMgrSuite) TestTrySystemBankErrors(c *C) {
+	s.setPCModelInState(c)
+
+	s.state.Lock()
+	_, err := devicestate.TrySystemBank(s.state, "b")
+	c.Check(err, ErrorMatches, "cannot get system bank: system is not using system banks")
+	s.state.Unlock()
+
+	s.mockSystemBanks(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	_, err = devicestate.TrySystemBank(s.state, "a")
+	c.Check(err, ErrorMatches, `cannot try system bank "a": bank is already the current one`)
+
+	chg, err := devicestate.TrySystemBank(s.state, "b")
+	c.Assert(err, IsNil)
+	_, err = devicestate.TrySystemBank(s.state, "b")
+	c.Check(err, ErrorMatches, fmt.Sprintf(`cannot try system bank "b": change %s is already trying a system bank`, chg.ID()))
+}
+
 func fakeMyModel(extra map[string]interface{}) *asserts.Model {
 	model := map[string]interface{}{
 		"type":         "model",
@@ -1475,6 +1594,85 @@ func (s *deviceMgrSuite) TestDeviceManagerSystemModeInfoUC20Run(c *C) {
 	})
 }
 
+func (s *deviceMgrSuite) TestDeviceManagerSystemModeInfoUC20RunFactoryBoots(c *C) {
+	modeEnv := &boot.Modeenv{
+		Mode:         "run",
+		BootFlags:    []string{"factory"},
+		FactoryBoots: 2,
+	}
+	err := modeEnv.WriteTo("")
+	c.Assert(err, IsNil)
+
+	runner := s.o.TaskRunner()
+	mgr, err := devicestate.Manager(s.state, s.hookMgr, runner, s.newStore)
+	c.Assert(err, IsNil)
+
+	s.setUC20PCModelInState(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(boot.InitramfsExposeBootFlagsForSystem([]string{"factory"}), IsNil)
+
+	smi, err := mgr.SystemModeInfo()
+	c.Assert(err, IsNil)
+	c.Check(smi, DeepEquals, &devicestate.SystemModeInfo{
+		Mode:              "run",
+		HasModeenv:        true,
+		BootFlags:         []string{"factory"},
+		HostDataLocations: []string{boot.InitramfsDataDir, dirs.GlobalRootDir},
+		FactoryBoots:      2,
+	})
+}
+
+func (s *deviceMgrSuite) TestDeviceManagerClearFactoryBootFlagUC20Run(c *C) {
+	modeEnv := &boot.Modeenv{
+		Mode:         "run",
+		BootFlags:    []string{"factory"},
+		FactoryBoots: 2,
+	}
+	err := modeEnv.WriteTo("")
+	c.Assert(err, IsNil)
+
+	runner := s.o.TaskRunner()
+	mgr, err := devicestate.Manager(s.state, s.hookMgr, runner, s.newStore)
+	c.Assert(err, IsNil)
+
+	s.setUC20PCModelInState(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err = devicestate.ClearFactoryBootFlag(s.state, "other-snap")
+	c.Assert(err, ErrorMatches, "cannot clear factory boot flag: only the gadget snap can end factory mode")
+
+	err = mgr.ClearFactoryBootFlag("pc")
+	c.Assert(err, IsNil)
+
+	m, err := boot.ReadModeenv("")
+	c.Assert(err, IsNil)
+	c.Check(m.BootFlags, HasLen, 0)
+	c.Check(m.FactoryBoots, Equals, 0)
+}
+
+func (s *deviceMgrSuite) TestDeviceManagerClearFactoryBootFlagUC18(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeModelAssertionInState(c, "canonical", "pc", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "pc",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+
+	err := s.mgr.ClearFactoryBootFlag("pc")
+	c.Assert(err, ErrorMatches, "cannot clear factory boot flag on a system without modes")
+}
+
 const (
 	mountRunMntUbuntuSaveFmt = `26 27 8:3 / %s/run/mnt/ubuntu-save rw,relatime shared:7 - ext4 /dev/fakedevice0p1 rw,data=ordered`
 	mountSnapSaveFmt         = `26 27 8:3 / %s/var/lib/snapd/save rw,relatime shared:7 - ext4 /dev/fakedevice0p1 rw,data=ordered`
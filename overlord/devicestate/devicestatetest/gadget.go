@@ -40,6 +40,8 @@ type PrepareDeviceBehavior struct {
 	Headers        map[string]string
 	RegBody        map[string]string
 	ProposedSerial string
+	// EnrollmentURL is the base URL of an EST service to enroll with
+	EnrollmentURL string
 }
 
 func MockGadget(c *C, st *state.State, name string, revision snap.Revision, pDBhv *PrepareDeviceBehavior) (restore func()) {
@@ -94,6 +96,11 @@ version: gadget
 			c.Assert(err, IsNil)
 		}
 
+		if pDBhv.EnrollmentURL != "" {
+			_, _, err = ctlcmd.Run(ctx, []string{"enroll-device", "est", pDBhv.EnrollmentURL, "--username=device", "--password=secret"}, 0)
+			c.Assert(err, IsNil)
+		}
+
 		if len(pDBhv.RegBody) != 0 {
 			d, err := yaml.Marshal(pDBhv.RegBody)
 			c.Assert(err, IsNil)
@@ -0,0 +1,243 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget/device"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/overlord/configstate/settings"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// Flash storage wears out with writes and commonly dies well before the
+// rest of a device. To let fleets learn about it in advance, the health
+// reported by the firmware of the disks backing ubuntu-boot and ubuntu-data
+// is checked periodically, as configured by the core.disk-health.*
+// settings, and recorded in the state. Warnings and notices are raised for
+// the disks that are failing or have used most of their rated life time.
+
+var (
+	diskHealthNow           = time.Now
+	disksDiskFromMountPoint = disks.DiskFromMountPoint
+	disksDiskHealth         = disks.DiskHealth
+
+	// diskHealthNoticeRepeatAfter is how often the notice of a disk whose
+	// health stays beyond the thresholds is repeated
+	diskHealthNoticeRepeatAfter = 24 * time.Hour
+)
+
+// DiskHealth is the health of a disk backing the system, as last checked.
+type DiskHealth struct {
+	// Device is the kernel device node of the disk, eg. /dev/mmcblk0.
+	Device string `json:"device"`
+	// Roles are the roles of the partitions of the disk that the system
+	// is using, ubuntu-boot and/or ubuntu-data.
+	Roles []string `json:"roles"`
+	// Source is where the information comes from, "emmc" or "smart".
+	Source string `json:"source,omitempty"`
+	// LifeUsed is the estimated percentage of the rated life time of the
+	// disk used so far, if known.
+	LifeUsed *int `json:"life-used,omitempty"`
+	// PreEOL is the pre end of life information of eMMC devices.
+	PreEOL     string            `json:"pre-eol,omitempty"`
+	Failing    bool              `json:"failing,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Warnings are the reasons the disk crossed the warning thresholds.
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is set if the health of the disk could not be read.
+	Error string `json:"error,omitempty"`
+}
+
+// DiskHealthReport is the outcome of the last check of the health of the
+// disks backing the system.
+type DiskHealthReport struct {
+	CheckTime time.Time     `json:"check-time"`
+	Disks     []*DiskHealth `json:"disks"`
+}
+
+// LastDiskHealthReport returns the outcome of the last check of the health
+// of the disks backing the system, or state.ErrNoState if there was none.
+// The state must be locked by the caller.
+func LastDiskHealthReport(st *state.State) (*DiskHealthReport, error) {
+	var report DiskHealthReport
+	if err := st.Get("disk-health", &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// systemDisks returns the disks backing ubuntu-boot and ubuntu-data in run
+// mode, along with the roles of the partitions the system is using on them.
+func systemDisks() ([]disks.Disk, map[string][]string, error) {
+	dataOpts := &disks.Options{
+		IsDecryptedDevice: device.HasEncryptedMarkerUnder(dirs.SnapFDEDir),
+	}
+	var found []disks.Disk
+	roles := make(map[string][]string, 2)
+	for _, mnt := range []struct {
+		role, dir string
+		opts      *disks.Options
+	}{
+		{"ubuntu-boot", boot.InitramfsUbuntuBootDir, nil},
+		{"ubuntu-data", boot.InitramfsDataDir, dataOpts},
+	} {
+		disk, err := disksDiskFromMountPoint(mnt.dir, mnt.opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot find disk backing %s: %v", mnt.role, err)
+		}
+		node := disk.KernelDeviceNode()
+		if _, ok := roles[node]; !ok {
+			found = append(found, disk)
+		}
+		roles[node] = append(roles[node], mnt.role)
+	}
+	return found, roles, nil
+}
+
+// checkDiskHealth returns the health of the given disk, or nil if it does
+// not report any.
+func checkDiskHealth(disk disks.Disk, roles []string, wearThreshold int) *DiskHealth {
+	dh := &DiskHealth{
+		Device: disk.KernelDeviceNode(),
+		Roles:  roles,
+	}
+	health, err := disksDiskHealth(disk)
+	if errors.Is(err, disks.ErrNoHealthInfo) {
+		logger.Debugf("no health information available for disk %s", dh.Device)
+		return nil
+	}
+	if err != nil {
+		logger.Noticef("cannot check health of disk %s: %v", dh.Device, err)
+		dh.Error = err.Error()
+		return dh
+	}
+	dh.Source = health.Source
+	if health.LifeUsed >= 0 {
+		lifeUsed := health.LifeUsed
+		dh.LifeUsed = &lifeUsed
+	}
+	dh.PreEOL = health.PreEOL
+	dh.Failing = health.Failing
+	dh.Attributes = health.Attributes
+
+	if health.Failing {
+		dh.Warnings = append(dh.Warnings, "the disk reports that it is failing")
+	}
+	if health.LifeUsed >= wearThreshold {
+		dh.Warnings = append(dh.Warnings, fmt.Sprintf("an estimated %d%% of its rated life time is used", health.LifeUsed))
+	}
+	if health.PreEOL == "warning" {
+		// urgent means the disk is failing
+		dh.Warnings = append(dh.Warnings, "most of its reserved blocks are consumed")
+	}
+	return dh
+}
+
+// ensureDiskHealth checks the health of the disks backing the system in
+// run mode, as often as configured.
+func (m *DeviceManager) ensureDiskHealth() error {
+	if m.sysMode != "run" {
+		return nil
+	}
+
+	m.state.Lock()
+	cfg, err := settings.DiskHealthSettings(m.state)
+	if err != nil {
+		m.state.Unlock()
+		return err
+	}
+	if cfg.Disabled {
+		m.state.Unlock()
+		return nil
+	}
+	if m.diskHealthLastCheck.IsZero() {
+		// do not check again on every restart
+		report, err := LastDiskHealthReport(m.state)
+		if err != nil && !errors.Is(err, state.ErrNoState) {
+			m.state.Unlock()
+			return err
+		}
+		if report != nil {
+			m.diskHealthLastCheck = report.CheckTime
+		}
+	}
+	m.state.Unlock()
+
+	now := diskHealthNow()
+	if !m.diskHealthLastCheck.IsZero() && !now.Before(m.diskHealthLastCheck) && now.Sub(m.diskHealthLastCheck) < cfg.Interval {
+		return nil
+	}
+	m.diskHealthLastCheck = now
+
+	// reading the health of the disks can take a while, do it without
+	// holding the state lock
+	systemDisks, roles, err := systemDisks()
+	if err != nil {
+		logger.Noticef("cannot check disk health: %v", err)
+		return nil
+	}
+	report := &DiskHealthReport{CheckTime: now}
+	for _, disk := range systemDisks {
+		if dh := checkDiskHealth(disk, roles[disk.KernelDeviceNode()], cfg.WearThreshold); dh != nil {
+			report.Disks = append(report.Disks, dh)
+		}
+	}
+	sort.Slice(report.Disks, func(i, j int) bool { return report.Disks[i].Device < report.Disks[j].Device })
+
+	m.state.Lock()
+	defer m.state.Unlock()
+	m.state.Set("disk-health", report)
+	for _, dh := range report.Disks {
+		if len(dh.Warnings) == 0 {
+			continue
+		}
+		m.state.Warnf("disk %s backing %s needs to be replaced soon: %s", dh.Device, strings.Join(dh.Roles, " and "), strings.Join(dh.Warnings, ", "))
+		data := map[string]string{
+			"roles":    strings.Join(dh.Roles, ","),
+			"failing":  strconv.FormatBool(dh.Failing),
+			"warnings": strings.Join(dh.Warnings, "; "),
+		}
+		if dh.LifeUsed != nil {
+			data["life-used"] = strconv.Itoa(*dh.LifeUsed)
+		}
+		if dh.PreEOL != "" {
+			data["pre-eol"] = dh.PreEOL
+		}
+		key := strings.TrimPrefix(dh.Device, "/dev/")
+		if _, err := m.state.AddNotice(state.DiskHealthNotice, key, &state.AddNoticeOptions{
+			Data:        data,
+			RepeatAfter: diskHealthNoticeRepeatAfter,
+			Time:        now,
+		}); err != nil {
+			logger.Noticef("cannot record health of disk %s: %v", dh.Device, err)
+		}
+	}
+	return nil
+}
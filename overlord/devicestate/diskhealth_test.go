@@ -0,0 +1,216 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate_test
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func (s *deviceMgrSuite) mockSystemDisks(c *C, bootDisk, dataDisk disks.Disk) {
+	s.AddCleanup(devicestate.MockDisksDiskFromMountPoint(func(mountpoint string, opts *disks.Options) (disks.Disk, error) {
+		switch mountpoint {
+		case boot.InitramfsUbuntuBootDir:
+			c.Check(opts, IsNil)
+			return bootDisk, nil
+		case boot.InitramfsDataDir:
+			c.Check(opts, DeepEquals, &disks.Options{IsDecryptedDevice: false})
+			return dataDisk, nil
+		}
+		return nil, errors.New("unexpected mount point")
+	}))
+}
+
+func (s *deviceMgrSuite) TestEnsureDiskHealth(c *C) {
+	devicestate.SetSystemMode(s.mgr, "run")
+	emmc := &disks.MockDiskMapping{DevNode: "/dev/mmcblk0"}
+	s.mockSystemDisks(c, emmc, emmc)
+	now := time.Now().Add(-48 * time.Hour).Truncate(time.Second).UTC()
+	defer devicestate.MockDiskHealthNow(func() time.Time { return now })()
+	healthCalls := 0
+	lifeUsed := 50
+	defer devicestate.MockDisksDiskHealth(func(d disks.Disk) (*disks.Health, error) {
+		healthCalls++
+		c.Check(d, Equals, emmc)
+		return &disks.Health{
+			Source:     disks.HealthSourceEMMC,
+			LifeUsed:   lifeUsed,
+			PreEOL:     "normal",
+			Attributes: map[string]string{"life-time-est-typ-a": "0x01"},
+		}, nil
+	})()
+
+	c.Assert(devicestate.EnsureDiskHealth(s.mgr), IsNil)
+	c.Check(healthCalls, Equals, 1)
+
+	s.state.Lock()
+	report, err := devicestate.LastDiskHealthReport(s.state)
+	c.Assert(err, IsNil)
+	used := 50
+	c.Check(report, DeepEquals, &devicestate.DiskHealthReport{
+		CheckTime: now,
+		Disks: []*devicestate.DiskHealth{{
+			Device:     "/dev/mmcblk0",
+			Roles:      []string{"ubuntu-boot", "ubuntu-data"},
+			Source:     "emmc",
+			LifeUsed:   &used,
+			PreEOL:     "normal",
+			Attributes: map[string]string{"life-time-est-typ-a": "0x01"},
+		}},
+	})
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+	c.Check(s.state.Notices(nil), HasLen, 0)
+	s.state.Unlock()
+
+	// checks are throttled
+	lifeUsed = 90
+	now = now.Add(time.Hour)
+	c.Assert(devicestate.EnsureDiskHealth(s.mgr), IsNil)
+	c.Check(healthCalls, Equals, 1)
+
+	// the wear threshold is crossed
+	now = now.Add(24 * time.Hour)
+	c.Assert(devicestate.EnsureDiskHealth(s.mgr), IsNil)
+	c.Check(healthCalls, Equals, 2)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	report, err = devicestate.LastDiskHealthReport(s.state)
+	c.Assert(err, IsNil)
+	c.Check(report.CheckTime, Equals, now)
+	c.Check(*report.Disks[0].LifeUsed, Equals, 90)
+	c.Check(report.Disks[0].Warnings, DeepEquals, []string{"an estimated 90% of its rated life time is used"})
+
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Equals, "disk /dev/mmcblk0 backing ubuntu-boot and ubuntu-data needs to be replaced soon: an estimated 90% of its rated life time is used")
+	notices := s.state.Notices(nil)
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Type(), Equals, state.DiskHealthNotice)
+	c.Check(notices[0].Key(), Equals, "mmcblk0")
+	c.Check(notices[0].LastData(), DeepEquals, map[string]string{
+		"roles":     "ubuntu-boot,ubuntu-data",
+		"failing":   "false",
+		"warnings":  "an estimated 90% of its rated life time is used",
+		"life-used": "90",
+		"pre-eol":   "normal",
+	})
+}
+
+func (s *deviceMgrSuite) TestEnsureDiskHealthFailingAndErrors(c *C) {
+	devicestate.SetSystemMode(s.mgr, "run")
+	nvme := &disks.MockDiskMapping{DevNode: "/dev/nvme0n1"}
+	sd := &disks.MockDiskMapping{DevNode: "/dev/sda"}
+	s.mockSystemDisks(c, sd, nvme)
+	defer devicestate.MockDisksDiskHealth(func(d disks.Disk) (*disks.Health, error) {
+		if d == sd {
+			return nil, errors.New("cannot read SMART data of /dev/sda: boom")
+		}
+		return &disks.Health{
+			Source:   disks.HealthSourceSMART,
+			LifeUsed: -1,
+			Failing:  true,
+		}, nil
+	})()
+
+	c.Assert(devicestate.EnsureDiskHealth(s.mgr), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	report, err := devicestate.LastDiskHealthReport(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(report.Disks, HasLen, 2)
+	c.Check(report.Disks[0], DeepEquals, &devicestate.DiskHealth{
+		Device:   "/dev/nvme0n1",
+		Roles:    []string{"ubuntu-data"},
+		Source:   "smart",
+		Failing:  true,
+		Warnings: []string{"the disk reports that it is failing"},
+	})
+	c.Check(report.Disks[1], DeepEquals, &devicestate.DiskHealth{
+		Device: "/dev/sda",
+		Roles:  []string{"ubuntu-boot"},
+		Error:  "cannot read SMART data of /dev/sda: boom",
+	})
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Equals, "disk /dev/nvme0n1 backing ubuntu-data needs to be replaced soon: the disk reports that it is failing")
+}
+
+func (s *deviceMgrSuite) TestEnsureDiskHealthNoHealthInfo(c *C) {
+	devicestate.SetSystemMode(s.mgr, "run")
+	vda := &disks.MockDiskMapping{DevNode: "/dev/vda"}
+	s.mockSystemDisks(c, vda, vda)
+	defer devicestate.MockDisksDiskHealth(func(d disks.Disk) (*disks.Health, error) {
+		return nil, disks.ErrNoHealthInfo
+	})()
+
+	c.Assert(devicestate.EnsureDiskHealth(s.mgr), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	report, err := devicestate.LastDiskHealthReport(s.state)
+	c.Assert(err, IsNil)
+	c.Check(report.Disks, HasLen, 0)
+}
+
+func (s *deviceMgrSuite) TestEnsureDiskHealthSkipped(c *C) {
+	healthCalls := 0
+	defer devicestate.MockDisksDiskHealth(func(d disks.Disk) (*disks.Health, error) {
+		healthCalls++
+		return nil, disks.ErrNoHealthInfo
+	})()
+	vda := &disks.MockDiskMapping{DevNode: "/dev/vda"}
+	s.mockSystemDisks(c, vda, vda)
+
+	// not in run mode
+	devicestate.SetSystemMode(s.mgr, "recover")
+	c.Assert(devicestate.EnsureDiskHealth(s.mgr), IsNil)
+	c.Check(healthCalls, Equals, 0)
+
+	// disabled
+	devicestate.SetSystemMode(s.mgr, "run")
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "disk-health.disabled", true)
+	tr.Commit()
+	s.state.Unlock()
+	c.Assert(devicestate.EnsureDiskHealth(s.mgr), IsNil)
+	c.Check(healthCalls, Equals, 0)
+
+	// checked recently before a restart
+	s.state.Lock()
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "disk-health.disabled", false)
+	tr.Set("core", "disk-health.interval", "6h")
+	tr.Commit()
+	s.state.Set("disk-health", &devicestate.DiskHealthReport{CheckTime: time.Now().Add(-time.Hour)})
+	s.state.Unlock()
+	c.Assert(devicestate.EnsureDiskHealth(s.mgr), IsNil)
+	c.Check(healthCalls, Equals, 0)
+}
@@ -0,0 +1,378 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snapdenv"
+)
+
+// Enrollment with an external device management server lets the
+// prepare-device hook of the gadget point snapd to a PKI service (eg. the
+// corporate one of the brand) from which a device certificate is obtained
+// before requesting a serial. The certificate is then presented as TLS
+// client certificate to the device service and to the store.
+//
+// The hook configures enrollment with "snapctl enroll-device", which sets
+// the following gadget options:
+//
+//   device-service.enrollment.protocol   the enrollment protocol, eg. "est"
+//   device-service.enrollment.url        the base URL of the service
+//   device-service.enrollment.username   optional HTTP basic auth username
+//
+// The optional HTTP basic auth password is kept out of the configuration,
+// which can be read through the API, and is stored in a file only readable
+// by root instead.
+
+type enrollmentConfig struct {
+	protocol string
+	url      *url.URL
+	username string
+	password string
+}
+
+// enrollFunc submits the given DER encoded certificate signing request to
+// the enrollment service and returns the issued certificates.
+type enrollFunc func(client *http.Client, cfg *enrollmentConfig, csr []byte) ([]*x509.Certificate, error)
+
+// enrollers maps the supported enrollment protocols to their
+// implementations.
+var enrollers = map[string]enrollFunc{
+	"est": estEnroll,
+}
+
+// errEnrollmentPending is returned by an enrollFunc when the enrollment
+// service accepted the request but the certificate is not issued yet.
+var errEnrollmentPending = errors.New("enrollment pending")
+
+// IsValidEnrollmentProtocol returns whether enrollment with the given
+// protocol is supported.
+func IsValidEnrollmentProtocol(protocol string) bool {
+	_, ok := enrollers[protocol]
+	return ok
+}
+
+func getEnrollmentConfig(tr *config.Transaction, gadgetName string) (*enrollmentConfig, error) {
+	var protocol string
+	if err := tr.GetMaybe(gadgetName, "device-service.enrollment.protocol", &protocol); err != nil {
+		return nil, err
+	}
+	if protocol == "" {
+		return nil, nil
+	}
+	if !IsValidEnrollmentProtocol(protocol) {
+		return nil, fmt.Errorf("unsupported device enrollment protocol %q", protocol)
+	}
+
+	var enrollURI string
+	if err := tr.GetMaybe(gadgetName, "device-service.enrollment.url", &enrollURI); err != nil {
+		return nil, err
+	}
+	if enrollURI == "" {
+		return nil, fmt.Errorf("cannot use device enrollment protocol %q without an URL", protocol)
+	}
+	enrollURL, err := url.Parse(enrollURI)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse device enrollment URL %q: %v", enrollURI, err)
+	}
+	if !strings.HasSuffix(enrollURL.Path, "/") {
+		enrollURL.Path += "/"
+	}
+
+	cfg := &enrollmentConfig{
+		protocol: protocol,
+		url:      enrollURL,
+	}
+	if err := tr.GetMaybe(gadgetName, "device-service.enrollment.username", &cfg.username); err != nil {
+		return nil, err
+	}
+	password, err := ioutil.ReadFile(enrollmentPasswordFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot read device enrollment password: %v", err)
+	}
+	cfg.password = string(password)
+	return cfg, nil
+}
+
+func enrollmentDir() string {
+	return filepath.Join(dirs.SnapDeviceDir, "enrollment")
+}
+
+func enrollmentKeyFile() string {
+	return filepath.Join(enrollmentDir(), "device-key.pem")
+}
+
+func enrollmentCertFile() string {
+	return filepath.Join(enrollmentDir(), "device-cert.pem")
+}
+
+func enrollmentPasswordFile() string {
+	return filepath.Join(enrollmentDir(), "password")
+}
+
+// SetEnrollmentPassword stores the password for HTTP basic authentication
+// with the enrollment service in a file only readable by root. An empty
+// password removes it.
+func SetEnrollmentPassword(password string) error {
+	if password == "" {
+		if err := os.Remove(enrollmentPasswordFile()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove device enrollment password: %v", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(enrollmentDir(), 0755); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(enrollmentPasswordFile(), []byte(password), 0600, 0); err != nil {
+		return fmt.Errorf("cannot store device enrollment password: %v", err)
+	}
+	return nil
+}
+
+// deviceCertificate returns the device certificate obtained through
+// enrollment, if any, or nil otherwise.
+func deviceCertificate() (*tls.Certificate, error) {
+	if !osutil.FileExists(enrollmentCertFile()) {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(enrollmentCertFile(), enrollmentKeyFile())
+	if err != nil {
+		return nil, fmt.Errorf("cannot load device certificate: %v", err)
+	}
+	return &cert, nil
+}
+
+// deviceCertificateTLSConfig returns a TLS configuration presenting the
+// device certificate, if any, when requested by the server. The
+// certificate is loaded on demand as it can be obtained after the
+// configuration was created.
+func deviceCertificateTLSConfig() *tls.Config {
+	return &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := deviceCertificate()
+			if err != nil {
+				return nil, err
+			}
+			if cert == nil {
+				// no certificate is sent
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+	}
+}
+
+func newEnrollmentRequest(device *auth.DeviceState, proposedSerial string) (csr []byte, key *ecdsa.PrivateKey, err error) {
+	key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot generate device enrollment key: %v", err)
+	}
+	tmpl := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			Organization: []string{device.Brand},
+			CommonName:   device.Model,
+			SerialNumber: proposedSerial,
+		},
+	}
+	csr, err = x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create device certificate signing request: %v", err)
+	}
+	return csr, key, nil
+}
+
+func writeDeviceCertificate(key crypto.PrivateKey, certs []*x509.Certificate) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("cannot encode device enrollment key: %v", err)
+	}
+	var certsPEM bytes.Buffer
+	for _, cert := range certs {
+		if err := pem.Encode(&certsPEM, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(enrollmentDir(), 0755); err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := osutil.AtomicWriteFile(enrollmentKeyFile(), keyPEM, 0600, 0); err != nil {
+		return err
+	}
+	// the certificate is written last as its presence marks the
+	// enrollment as done
+	return osutil.AtomicWriteFile(enrollmentCertFile(), certsPEM.Bytes(), 0644, 0)
+}
+
+// enrollDevice obtains a device certificate from the configured enrollment
+// service unless one was obtained already. It expects the state to be
+// locked and releases it while talking to the service.
+func enrollDevice(t *state.Task, device *auth.DeviceState, client *http.Client, cfg *serialRequestConfig) error {
+	if cfg.enrollment == nil || osutil.FileExists(enrollmentCertFile()) {
+		return nil
+	}
+
+	csr, key, err := newEnrollmentRequest(device, cfg.proposedSerial)
+	if err != nil {
+		return err
+	}
+
+	st := t.State()
+	st.Unlock()
+	defer st.Lock()
+
+	certs, err := enrollers[cfg.enrollment.protocol](client, cfg.enrollment, csr)
+	if err == errEnrollmentPending {
+		return errPoll
+	}
+	if err != nil {
+		if httputil.ShouldRetryError(err) {
+			return retryErr(t, 0, "cannot enroll device: %v", err)
+		}
+		return fmt.Errorf("cannot enroll device: %v", err)
+	}
+
+	// the issued certificate must be first
+	for i, cert := range certs {
+		if pub, ok := cert.PublicKey.(*ecdsa.PublicKey); ok && pub.X.Cmp(key.X) == 0 && pub.Y.Cmp(key.Y) == 0 {
+			certs[0], certs[i] = certs[i], certs[0]
+			return writeDeviceCertificate(key, certs)
+		}
+	}
+	return fmt.Errorf("cannot enroll device: enrollment service did not issue a certificate for the device key")
+}
+
+// estEnroll implements the simple enrollment of RFC 7030 (Enrollment over
+// Secure Transport).
+func estEnroll(client *http.Client, cfg *enrollmentConfig, csr []byte) ([]*x509.Certificate, error) {
+	enrollURL := cfg.url.ResolveReference(&url.URL{Path: "simpleenroll"})
+	body := base64.StdEncoding.EncodeToString(csr)
+	req, err := http.NewRequest("POST", enrollURL.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot create enrollment request %q", enrollURL)
+	}
+	req.Header.Set("User-Agent", snapdenv.UserAgent())
+	req.Header.Set("Content-Type", "application/pkcs10")
+	req.Header.Set("Content-Transfer-Encoding", "base64")
+	if cfg.username != "" {
+		req.SetBasicAuth(cfg.username, cfg.password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200:
+	case 202:
+		return nil, errEnrollmentPending
+	default:
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read enrollment response: %v", err)
+	}
+	der, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(data), nil)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode enrollment response: %v", err)
+	}
+	return parsePKCS7Certificates(der)
+}
+
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// parsePKCS7Certificates returns the certificates carried by a DER encoded
+// PKCS#7 "certs-only" message, as returned by EST services.
+func parsePKCS7Certificates(der []byte) ([]*x509.Certificate, error) {
+	var ci pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("cannot parse PKCS#7 message: %v", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("cannot parse PKCS#7 message: unexpected content type %v", ci.ContentType)
+	}
+
+	// the content is kept with its [0] tag, SignedData is a SEQUENCE of
+	// version, digestAlgorithms, encapContentInfo and then the optional
+	// [0] IMPLICIT certificates
+	var signedData asn1.RawValue
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &signedData); err != nil {
+		return nil, fmt.Errorf("cannot parse PKCS#7 signed data: %v", err)
+	}
+	rest := signedData.Bytes
+	for i := 0; i < 3; i++ {
+		var skipped asn1.RawValue
+		var err error
+		rest, err = asn1.Unmarshal(rest, &skipped)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse PKCS#7 signed data: %v", err)
+		}
+	}
+	var certsField asn1.RawValue
+	if _, err := asn1.Unmarshal(rest, &certsField); err != nil {
+		return nil, fmt.Errorf("cannot parse PKCS#7 signed data: %v", err)
+	}
+	if certsField.Class != asn1.ClassContextSpecific || certsField.Tag != 0 {
+		return nil, fmt.Errorf("cannot parse PKCS#7 signed data: no certificates")
+	}
+	certs, err := x509.ParseCertificates(certsField.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse PKCS#7 certificates: %v", err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("cannot parse PKCS#7 signed data: no certificates")
+	}
+	return certs, nil
+}
@@ -0,0 +1,292 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type estServiceBehavior struct {
+	// pending is the number of simpleenroll requests to answer with
+	// 202 Accepted before issuing the certificate
+	pending int
+	// badKey makes the service issue a certificate for a different key
+	// than the requested one
+	badKey bool
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (s *deviceMgrSerialSuite) mockESTService(c *C, bhv *estServiceBehavior) *httptest.Server {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	c.Assert(err, IsNil)
+	caCert, err := x509.ParseCertificate(caDER)
+	c.Assert(err, IsNil)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bhv.mu.Lock()
+		defer bhv.mu.Unlock()
+		bhv.requests++
+
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/.well-known/est/simpleenroll")
+		c.Check(r.Header.Get("Content-Type"), Equals, "application/pkcs10")
+		user, password, ok := r.BasicAuth()
+		c.Check(ok, Equals, true)
+		c.Check(user, Equals, "device")
+		c.Check(password, Equals, "secret")
+
+		if bhv.pending > 0 {
+			bhv.pending--
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(202)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		csrDER, err := base64.StdEncoding.DecodeString(string(body))
+		c.Assert(err, IsNil)
+		csr, err := x509.ParseCertificateRequest(csrDER)
+		c.Assert(err, IsNil)
+		c.Assert(csr.CheckSignature(), IsNil)
+
+		pub := csr.PublicKey
+		if bhv.badKey {
+			otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			c.Assert(err, IsNil)
+			pub = otherKey.Public()
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      csr.Subject,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, pub, caKey)
+		c.Assert(err, IsNil)
+
+		w.Header().Set("Content-Type", "application/pkcs7-mime; smime-type=certs-only")
+		w.Header().Set("Content-Transfer-Encoding", "base64")
+		w.WriteHeader(200)
+		w.Write([]byte(base64.StdEncoding.EncodeToString(pkcs7CertsOnly(c, caDER, certDER))))
+	}))
+}
+
+// pkcs7CertsOnly builds a degenerate PKCS#7 signed data message carrying
+// only the given certificates.
+func pkcs7CertsOnly(c *C, certs ...[]byte) []byte {
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      struct{ ContentType asn1.ObjectIdentifier }
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+		ContentInfo: struct{ ContentType asn1.ObjectIdentifier }{
+			ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1},
+		},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: bytes.Join(certs, nil)},
+		SignerInfos:  asn1.RawValue{Tag: asn1.TagSet, IsCompound: true},
+	})
+	c.Assert(err, IsNil)
+	contentInfo, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+	c.Assert(err, IsNil)
+	return contentInfo
+}
+
+func (s *deviceMgrSerialSuite) testFullDeviceRegistrationWithEnrollment(c *C, estBhv *estServiceBehavior) {
+	r1 := devicestate.MockKeyLength(testKeyLength)
+	defer r1()
+
+	bhv := &devicestatetest.DeviceServiceBehavior{
+		RequestIDURLPath: "/svc/request-id",
+		SerialURLPath:    "/svc/serial",
+	}
+	mockServer := s.mockServer(c, "REQID-1", bhv)
+	defer mockServer.Close()
+
+	estServer := s.mockESTService(c, estBhv)
+	defer estServer.Close()
+
+	// immediately
+	r2 := devicestate.MockRetryInterval(0)
+	defer r2()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	pDBhv := &devicestatetest.PrepareDeviceBehavior{
+		DeviceSvcURL:   mockServer.URL + "/svc/",
+		ProposedSerial: "Y9999",
+		EnrollmentURL:  estServer.URL + "/.well-known/est",
+	}
+	r3 := devicestatetest.MockGadget(c, s.state, "gadget", snap.R(2), pDBhv)
+	defer r3()
+
+	s.makeModelAssertionInState(c, "canonical", "pc2", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "gadget",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc2",
+	})
+	s.state.Set("seeded", true)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	becomeOperational := s.findBecomeOperationalChange()
+	c.Assert(becomeOperational, NotNil)
+	for i := 0; i < 3 && !becomeOperational.Status().Ready(); i++ {
+		s.state.Unlock()
+		s.settle(c)
+		s.state.Lock()
+	}
+	c.Check(becomeOperational.Status().Ready(), Equals, true)
+	c.Check(becomeOperational.Err(), IsNil)
+
+	device, err := devicestatetest.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Serial, Equals, "Y9999")
+
+	// the password is not readable from the configuration
+	tr := config.NewTransaction(s.state)
+	var password string
+	c.Check(tr.GetMaybe("gadget", "device-service.enrollment.password", &password), IsNil)
+	c.Check(password, Equals, "")
+	c.Check(filepath.Join(dirs.SnapDeviceDir, "enrollment/password"), testutil.FileEquals, "secret")
+
+	// the device certificate was stored
+	c.Check(filepath.Join(dirs.SnapDeviceDir, "enrollment/device-key.pem"), testutil.FilePresent)
+	cert, err := s.mgr.StoreContextBackend().DeviceCertificate()
+	c.Assert(err, IsNil)
+	c.Assert(cert, NotNil)
+	c.Assert(cert.Certificate, HasLen, 2)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	c.Assert(err, IsNil)
+	c.Check(leaf.Subject.Organization, DeepEquals, []string{"canonical"})
+	c.Check(leaf.Subject.CommonName, Equals, "pc2")
+	c.Check(leaf.Subject.SerialNumber, Equals, "Y9999")
+}
+
+func (s *deviceMgrSerialSuite) TestFullDeviceRegistrationWithEnrollment(c *C) {
+	estBhv := &estServiceBehavior{}
+	s.testFullDeviceRegistrationWithEnrollment(c, estBhv)
+	c.Check(estBhv.requests, Equals, 1)
+}
+
+func (s *deviceMgrSerialSuite) TestFullDeviceRegistrationWithEnrollmentPending(c *C) {
+	estBhv := &estServiceBehavior{pending: 1}
+	s.testFullDeviceRegistrationWithEnrollment(c, estBhv)
+	c.Check(estBhv.requests, Equals, 2)
+}
+
+func (s *deviceMgrSerialSuite) TestFullDeviceRegistrationWithEnrollmentWrongKey(c *C) {
+	r1 := devicestate.MockKeyLength(testKeyLength)
+	defer r1()
+
+	mockServer := s.mockServer(c, "REQID-1", nil)
+	defer mockServer.Close()
+
+	estServer := s.mockESTService(c, &estServiceBehavior{badKey: true})
+	defer estServer.Close()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	pDBhv := &devicestatetest.PrepareDeviceBehavior{
+		DeviceSvcURL:  mockServer.URL + "/svc/",
+		EnrollmentURL: estServer.URL + "/.well-known/est/",
+	}
+	r2 := devicestatetest.MockGadget(c, s.state, "gadget", snap.R(2), pDBhv)
+	defer r2()
+
+	s.makeModelAssertionInState(c, "canonical", "pc2", map[string]interface{}{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "gadget",
+	})
+	devicestatetest.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc2",
+	})
+	s.state.Set("seeded", true)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	becomeOperational := s.findBecomeOperationalChange()
+	c.Assert(becomeOperational, NotNil)
+	c.Check(becomeOperational.Err(), ErrorMatches, `(?s).*cannot enroll device: enrollment service did not issue a certificate for the device key.*`)
+
+	cert, err := s.mgr.StoreContextBackend().DeviceCertificate()
+	c.Assert(err, IsNil)
+	c.Check(cert, IsNil)
+}
@@ -33,6 +33,8 @@ import (
 	"github.com/snapcore/snapd/httputil"
 	"github.com/snapcore/snapd/kernel/fde"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/disks"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/storecontext"
@@ -94,6 +96,12 @@ func MockTimeNow(f func() time.Time) (restore func()) {
 	}
 }
 
+func MockCheckScheduledSystemAction(f func(st *state.State, action *ScheduledSystemAction) error) (restore func()) {
+	restore = testutil.Backup(&checkScheduledSystemAction)
+	checkScheduledSystemAction = f
+	return restore
+}
+
 func KeypairManager(m *DeviceManager) (keypairMgr asserts.KeypairManager) {
 	// XXX expose the with... method at some point
 	err := m.withKeypairMgr(func(km asserts.KeypairManager) error {
@@ -200,6 +208,16 @@ func EnsureBootOk(m *DeviceManager) error {
 	return m.ensureBootOk()
 }
 
+func EnsureGadgetRawRollback(m *DeviceManager) error {
+	return m.ensureGadgetRawRollback()
+}
+
+func MockOsutilBootID(f func() (string, error)) (restore func()) {
+	r := testutil.Backup(&osutilBootID)
+	osutilBootID = f
+	return r
+}
+
 func SetBootOkRan(m *DeviceManager, b bool) {
 	m.bootOkRan = b
 }
@@ -329,6 +347,12 @@ func MockBootEnsureNextBootToRunMode(f func(systemLabel string) error) (restore
 	}
 }
 
+func MockBootSetRecoveryBootSystemAndMode(f func(dev snap.Device, systemLabel, mode string) error) (restore func()) {
+	restore = testutil.Backup(&bootSetRecoveryBootSystemAndMode)
+	bootSetRecoveryBootSystemAndMode = f
+	return restore
+}
+
 func MockSecbootCheckTPMKeySealingSupported(f func() error) (restore func()) {
 	old := secbootCheckTPMKeySealingSupported
 	secbootCheckTPMKeySealingSupported = f
@@ -391,6 +415,14 @@ func MockInstallEncryptPartitions(f func(onVolumes map[string]*gadget.Volume, en
 	}
 }
 
+func MockInstallAddPassphrase(f func(setupData *install.EncryptionSetupData, passphrase string) error) (restore func()) {
+	old := installAddPassphrase
+	installAddPassphrase = f
+	return func() {
+		installAddPassphrase = old
+	}
+}
+
 func MockInstallSaveStorageTraits(f func(model gadget.Model, allLaidOutVols map[string]*gadget.LaidOutVolume, encryptSetupData *install.EncryptionSetupData) error) (restore func()) {
 	old := installSaveStorageTraits
 	installSaveStorageTraits = f
@@ -473,12 +505,30 @@ func MockSecbootEnsureRecoveryKey(f func(recoveryKeyFile string, rkeyDevs []secb
 	return restore
 }
 
+func MockSecbootAddRecoveryKey(f func(key keys.EncryptionKey, rkey keys.RecoveryKey, node string) error) (restore func()) {
+	restore = testutil.Backup(&secbootAddRecoveryKey)
+	secbootAddRecoveryKey = f
+	return restore
+}
+
 func MockSecbootRemoveRecoveryKeys(f func(rkeyDevToKey map[secboot.RecoveryKeyDevice]string) error) (restore func()) {
 	restore = testutil.Backup(&secbootRemoveRecoveryKeys)
 	secbootRemoveRecoveryKeys = f
 	return restore
 }
 
+func MockBootPrepareSecureBootDbxUpdate(f func(update []byte) error) (restore func()) {
+	restore = testutil.Backup(&bootPrepareSecureBootDbxUpdate)
+	bootPrepareSecureBootDbxUpdate = f
+	return restore
+}
+
+func MockBootCompleteSecureBootDbxUpdate(f func() error) (restore func()) {
+	restore = testutil.Backup(&bootCompleteSecureBootDbxUpdate)
+	bootCompleteSecureBootDbxUpdate = f
+	return restore
+}
+
 func MockMarkFactoryResetComplete(f func(encrypted bool) error) (restore func()) {
 	restore = testutil.Backup(&bootMarkFactoryResetComplete)
 	bootMarkFactoryResetComplete = f
@@ -543,7 +593,72 @@ func CheckEncryptionSetupDataFromCache(st *state.State, label string) error {
 	return nil
 }
 
+func MockInstallPassphraseInCache(st *state.State, label, passphrase string) {
+	st.Cache(installPassphraseKey{label}, passphrase)
+}
+
+func InstallPassphraseFromCache(st *state.State, label string) string {
+	passphrase, _ := st.Cached(installPassphraseKey{label}).(string)
+	return passphrase
+}
+
+func VolumesAuthModeFromCache(st *state.State, label string) VolumesAuthMode {
+	mode, _ := st.Cached(volumesAuthModeKey{label}).(VolumesAuthMode)
+	return mode
+}
+
+var CheckEncryptionSetupMatchesVolumes = checkEncryptionSetupMatchesVolumes
+
 func CleanUpEncryptionSetupDataInCache(st *state.State, label string) {
 	key := encryptionSetupDataKey{label}
 	st.Cache(key, nil)
+	st.Cache(volumesAuthModeKey{label}, nil)
+}
+
+func MockClockNow(f func() time.Time) (restore func()) {
+	old := clockNow
+	clockNow = f
+	return func() {
+		clockNow = old
+	}
+}
+
+func CheckClock(m *DeviceManager) error {
+	return m.checkClock()
+}
+
+func EnsureClockLastKnownGood(m *DeviceManager) error {
+	return m.ensureClockLastKnownGood()
+}
+
+func NewPrepareDeviceHookHandler(context *hookstate.Context) hookstate.Handler {
+	return newPrepareDeviceHookHandler(context)
+}
+
+func MockDiskHealthNow(f func() time.Time) (restore func()) {
+	old := diskHealthNow
+	diskHealthNow = f
+	return func() {
+		diskHealthNow = old
+	}
+}
+
+func MockDisksDiskFromMountPoint(f func(mountpoint string, opts *disks.Options) (disks.Disk, error)) (restore func()) {
+	old := disksDiskFromMountPoint
+	disksDiskFromMountPoint = f
+	return func() {
+		disksDiskFromMountPoint = old
+	}
+}
+
+func MockDisksDiskHealth(f func(disks.Disk) (*disks.Health, error)) (restore func()) {
+	old := disksDiskHealth
+	disksDiskHealth = f
+	return func() {
+		disksDiskHealth = old
+	}
+}
+
+func EnsureDiskHealth(m *DeviceManager) error {
+	return m.ensureDiskHealth()
 }
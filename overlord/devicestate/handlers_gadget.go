@@ -19,6 +19,7 @@
 package devicestate
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -31,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -79,9 +81,71 @@ func pendingGadgetInfo(snapsup *snapstate.SnapSetup, pendingDeviceCtx snapstate.
 
 var (
 	gadgetUpdate = gadget.Update
+	osutilBootID = osutil.BootID
 )
 
-func (m *DeviceManager) doUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error {
+// gadgetRawRollbackDir returns the directory keeping the previous content
+// of raw structures overwritten by a gadget update, until the system
+// reaches run mode with the new content. It lives on ubuntu-save so that
+// it is reachable from recover mode too.
+func gadgetRawRollbackDir(saveDir string) string {
+	return filepath.Join(saveDir, "device", "gadget-raw-rollback")
+}
+
+// gadgetRawRollbackRevisions records the snap whose update overwrote raw
+// structure content, so that its revision can be reverted in the state
+// when the previous content is written back.
+type gadgetRawRollbackRevisions struct {
+	Snap     string        `json:"snap"`
+	Revision snap.Revision `json:"revision"`
+	// Previous is the revision of the snap the previous content belongs
+	// to.
+	Previous snap.Revision `json:"previous"`
+}
+
+// saveGadgetRawRollbackSnapshots keeps the previous content of the raw
+// structures overwritten by the update with the given rollback directory,
+// along with the revisions of the updated snap and the current boot id.
+func saveGadgetRawRollbackSnapshots(rollbackDir string, revs *gadgetRawRollbackRevisions) error {
+	if !osutil.IsDirectory(dirs.SnapDeviceSaveDir) {
+		// no ubuntu-save, no recover mode to rollback from
+		return nil
+	}
+	bootID, err := osutilBootID()
+	if err != nil {
+		return err
+	}
+	snapshotDir := gadgetRawRollbackDir(dirs.SnapSaveDir)
+	bootIDFile := filepath.Join(snapshotDir, "boot-id")
+	// snapshots from an earlier update in this boot hold the content
+	// that is known to boot, anything else is stale
+	if data, err := os.ReadFile(bootIDFile); err != nil || string(data) != bootID {
+		if err := os.RemoveAll(snapshotDir); err != nil {
+			return err
+		}
+	}
+	if err := gadget.SaveRawRollbackSnapshots(rollbackDir, snapshotDir); err != nil {
+		if err == gadget.ErrNoUpdate {
+			return nil
+		}
+		return err
+	}
+	// as with the snapshots, the revisions of the first update in this
+	// boot are the ones to go back to
+	revsFile := filepath.Join(snapshotDir, "revisions")
+	if !osutil.FileExists(revsFile) {
+		data, err := json.Marshal(revs)
+		if err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(revsFile, data, 0600, 0); err != nil {
+			return err
+		}
+	}
+	return osutil.AtomicWriteFile(bootIDFile, []byte(bootID), 0600, 0)
+}
+
+func (m *DeviceManager) doUpdateGadgetAssets(t *state.Task, tomb *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
 	defer st.Unlock()
@@ -187,6 +251,12 @@ func (m *DeviceManager) doUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error
 	if err == nil {
 		updateObserver = observeTrustedBootAssets
 	}
+	// nothing was written yet, do not start updating the assets if
+	// asked to stop
+	if err := state.CheckpointBeforeWork(tomb); err != nil {
+		return err
+	}
+
 	// do not release the state lock, the update observer may attempt to
 	// modify modeenv inside, which implicitly is guarded by the state lock;
 	// on top of that we do not expect the update to be moving large amounts
@@ -201,13 +271,27 @@ func (m *DeviceManager) doUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error
 		return err
 	}
 
+	if model.Grade() != asserts.ModelGradeUnset {
+		revs := &gadgetRawRollbackRevisions{
+			Snap:     snapsup.InstanceName(),
+			Revision: snapsup.Revision(),
+		}
+		if info, err := snapstate.CurrentInfo(st, snapsup.InstanceName()); err == nil {
+			revs.Previous = info.Revision
+		}
+		if err := saveGadgetRawRollbackSnapshots(snapRollbackDir, revs); err != nil {
+			// the update itself was applied
+			t.Logf("cannot keep previous raw structure content for rollback: %v", err)
+		}
+	}
+
 	if err := os.RemoveAll(snapRollbackDir); err != nil && !os.IsNotExist(err) {
 		logger.Noticef("failed to remove gadget update rollback directory %q: %v", snapRollbackDir, err)
 	}
 
 	// TODO: consider having the option to do this early via recovery in
 	// core20, have fallback code as well there
-	return snapstate.FinishTaskWithRestart(t, state.DoneStatus, restart.RestartSystem, nil)
+	return snapstate.FinishTaskWithRestart(t, state.DoneStatus, restart.RestartSystem, restart.RebootReasonGadgetAssetUpdate, nil)
 }
 
 func (m *DeviceManager) updateGadgetCommandLine(t *state.Task, st *state.State, isUndo bool) (updated bool, err error) {
@@ -286,7 +370,7 @@ func (m *DeviceManager) doUpdateGadgetCommandLine(t *state.Task, _ *tomb.Tomb) e
 
 	// kernel command line was updated, request a reboot to make it effective
 
-	return snapstate.FinishTaskWithRestart(t, state.DoneStatus, restart.RestartSystem, nil)
+	return snapstate.FinishTaskWithRestart(t, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelCommandLineUpdate, nil)
 }
 
 func (m *DeviceManager) undoUpdateGadgetCommandLine(t *state.Task, _ *tomb.Tomb) error {
@@ -324,5 +408,5 @@ func (m *DeviceManager) undoUpdateGadgetCommandLine(t *state.Task, _ *tomb.Tomb)
 	t.Logf("Reverted kernel command line change")
 
 	// kernel command line was updated, request a reboot to make it effective
-	return snapstate.FinishTaskWithRestart(t, state.UndoneStatus, restart.RestartSystem, nil)
+	return snapstate.FinishTaskWithRestart(t, state.UndoneStatus, restart.RestartSystem, restart.RebootReasonKernelCommandLineUpdate, nil)
 }
@@ -33,6 +33,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 
 	_ "golang.org/x/crypto/sha3"
 	"gopkg.in/tomb.v2"
@@ -42,12 +43,14 @@ import (
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/bootloader"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/gadget/device"
 	"github.com/snapcore/snapd/gadget/install"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -59,6 +62,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapfile"
 	"github.com/snapcore/snapd/snap/squashfs"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/sysconfig"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/timings"
@@ -70,14 +74,17 @@ var (
 	bootMakeRunnableStandalone           = boot.MakeRunnableStandaloneSystem
 	bootMakeRunnableAfterDataReset       = boot.MakeRunnableSystemAfterDataReset
 	bootEnsureNextBootToRunMode          = boot.EnsureNextBootToRunMode
+	bootSetRecoveryBootSystemAndMode     = boot.SetRecoveryBootSystemAndMode
 	installRun                           = install.Run
 	installFactoryReset                  = install.FactoryReset
 	installMountVolumes                  = install.MountVolumes
 	installWriteContent                  = install.WriteContent
 	installEncryptPartitions             = install.EncryptPartitions
 	installSaveStorageTraits             = install.SaveStorageTraits
+	installAddPassphrase                 = install.AddPassphrase
 	secbootStageEncryptionKeyChange      = secboot.StageEncryptionKeyChange
 	secbootTransitionEncryptionKeyChange = secboot.TransitionEncryptionKeyChange
+	secbootAddRecoveryKey                = secboot.AddRecoveryKey
 
 	sysconfigConfigureTargetSystem = sysconfig.ConfigureTargetSystem
 )
@@ -264,6 +271,29 @@ func writeTimings(st *state.State, rootdir, fromMode string) error {
 // applicable, otherwise the returned gadget.ContentObserver is nil.
 // The observer if any is also returned as non-nil trustedObserver if
 // encryption is in use.
+// checkGadgetFilesystems checks that the filesystems of the given volumes
+// can be used for installing the system, the experimental ones need the
+// experimental.gadget-extra-filesystems feature to be enabled.
+func checkGadgetFilesystems(st *state.State, volumes map[string]*gadget.Volume) error {
+	for _, vol := range volumes {
+		for _, vs := range vol.Structure {
+			if !gadget.IsExperimentalFilesystem(vs.Filesystem) {
+				continue
+			}
+			tr := config.NewTransaction(st)
+			enabled, err := features.Flag(tr, features.GadgetExtraFilesystems)
+			if err != nil {
+				return err
+			}
+			if !enabled {
+				_, confName := features.GadgetExtraFilesystems.ConfigOption()
+				return fmt.Errorf("cannot use filesystem %q for structure %q without enabling %q", vs.Filesystem, vs.Name, confName)
+			}
+		}
+	}
+	return nil
+}
+
 func buildInstallObserver(model *asserts.Model, gadgetDir string, useEncryption bool) (
 	observer gadget.ContentObserver, trustedObserver *boot.TrustedAssetsInstallObserver, err error) {
 
@@ -297,6 +327,24 @@ func (m *DeviceManager) doSetupUbuntuSave(t *state.Task, _ *tomb.Tomb) error {
 	return m.setupUbuntuSave(deviceCtx)
 }
 
+// factoryBoots returns the number of boots into run mode during which the
+// factory boot flag is kept, as declared by the gadget, if the device is
+// being installed in factory mode.
+func factoryBoots(deviceCtx snapstate.DeviceContext, ginfo *gadget.Info) int {
+	if ginfo.FactoryBoots == 0 {
+		return 0
+	}
+	flags, err := boot.BootFlags(deviceCtx)
+	if err != nil && !boot.IsUnknownBootFlagError(err) {
+		logger.Noticef("cannot get boot flags: %v", err)
+		return 0
+	}
+	if !strutil.ListContains(flags, "factory") {
+		return 0
+	}
+	return ginfo.FactoryBoots
+}
+
 func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
@@ -357,6 +405,9 @@ func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
 	if err := gadget.ValidateContent(ginfo, gadgetDir, kernelDir); err != nil {
 		return fmt.Errorf("cannot use gadget: %v", err)
 	}
+	if err := checkGadgetFilesystems(st, ginfo.Volumes); err != nil {
+		return fmt.Errorf("cannot use gadget: %v", err)
+	}
 
 	installObserver, trustedInstallObserver, err := buildInstallObserver(model, gadgetDir, useEncryption)
 	if err != nil {
@@ -379,6 +430,9 @@ func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
 		if err := prepareEncryptedSystemData(model, installedSystem.KeyForRole, trustedInstallObserver); err != nil {
 			return err
 		}
+		if err := escrowRecoveryKey(model, ginfo.RecoveryKeyEscrow, gadgetDir, installedSystem); err != nil {
+			return err
+		}
 	}
 
 	if err := prepareRunSystemData(model, gadgetDir, perfTimings); err != nil {
@@ -402,6 +456,8 @@ func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
 		UnpackedGadgetDir: gadgetDir,
 
 		RecoverySystemLabel: modeEnv.RecoverySystem,
+
+		FactoryBoots: factoryBoots(deviceCtx, ginfo),
 	}
 	timings.Run(perfTimings, "boot-make-runnable", "Make target system runnable", func(timings.Measurer) {
 		err = bootMakeRunnable(deviceCtx.Model(), bootWith, trustedInstallObserver)
@@ -413,6 +469,61 @@ func (m *DeviceManager) doSetupRunSystem(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+// escrowRecoveryKey adds a recovery key to the encrypted partitions of the
+// installed system when the gadget asks for it to be escrowed with the brand.
+// The recovery key is kept in the clear only on ubuntu-data, the escrowed
+// copy encrypted to the public key of the brand goes either to ubuntu-seed
+// or to ubuntu-data for the prepare-device hook to upload it.
+func escrowRecoveryKey(model *asserts.Model, escrow *gadget.RecoveryKeyEscrow, gadgetDir string, installedSystem *install.InstalledSystemSideData) error {
+	if escrow == nil {
+		return nil
+	}
+	pemData, err := ioutil.ReadFile(filepath.Join(gadgetDir, escrow.PublicKey))
+	if err != nil {
+		return fmt.Errorf("cannot read recovery key escrow public key: %v", err)
+	}
+	pub, err := keys.ParseEscrowPublicKey(pemData)
+	if err != nil {
+		return err
+	}
+
+	rkey, err := keys.NewRecoveryKey()
+	if err != nil {
+		return fmt.Errorf("cannot create recovery key: %v", err)
+	}
+	for _, role := range []string{gadget.SystemData, gadget.SystemSave} {
+		node := installedSystem.DeviceForRole[role]
+		key := installedSystem.KeyForRole[role]
+		if node == "" || key == nil {
+			return fmt.Errorf("internal error: no encrypted %s device", role)
+		}
+		if err := secbootAddRecoveryKey(key, rkey, node); err != nil {
+			return fmt.Errorf("cannot add recovery key to %s: %v", node, err)
+		}
+	}
+
+	fdeDir := boot.InstallHostFDEDataDir(model)
+	// such that the same recovery key is reported once the system is
+	// running
+	if err := rkey.Save(device.RecoveryKeyUnder(fdeDir)); err != nil {
+		return fmt.Errorf("cannot store recovery key: %v", err)
+	}
+
+	escrowed, err := rkey.Escrow(pub, model.BrandID(), model.Model())
+	if err != nil {
+		return err
+	}
+	escrowedKeyFile := device.EscrowedRecoveryKeyUnder(boot.InitramfsSeedEncryptionKeyDir)
+	if escrow.Destination == gadget.RecoveryKeyEscrowPrepareDevice {
+		escrowedKeyFile = device.EscrowedRecoveryKeyUnder(fdeDir)
+	}
+	if err := escrowed.Save(escrowedKeyFile); err != nil {
+		return fmt.Errorf("cannot store escrowed recovery key: %v", err)
+	}
+	logger.Noticef("recovery key escrowed to %s", escrowedKeyFile)
+	return nil
+}
+
 func prepareEncryptedSystemData(model *asserts.Model, keyForRole map[string]keys.EncryptionKey, trustedInstallObserver *boot.TrustedAssetsInstallObserver) error {
 	// validity check
 	if len(keyForRole) == 0 || keyForRole[gadget.SystemData] == nil || keyForRole[gadget.SystemSave] == nil {
@@ -548,6 +659,12 @@ func saveKeys(model *asserts.Model, keyForRole map[string]keys.EncryptionKey) er
 // their restart behavior.
 type RebootOptions struct {
 	Op string `json:"op,omitempty"`
+	// Mode is the mode of the system to boot into, one of "run",
+	// "recover" or "factory-reset". When empty run mode is used.
+	Mode string `json:"mode,omitempty"`
+	// Delay postpones the restart, which is then carried out by a
+	// scheduled system action change that can be aborted until then.
+	Delay time.Duration `json:"delay,omitempty"`
 }
 
 const (
@@ -589,17 +706,27 @@ func (m *DeviceManager) doRestartSystemToRunMode(t *state.Task, _ *tomb.Tomb) er
 		logger.Noticef("preseed data not present, will do normal seeding")
 	}
 
-	// ensure the next boot goes into run mode
-	if err := bootEnsureNextBootToRunMode(modeEnv.RecoverySystem); err != nil {
-		return err
-	}
-
 	var rebootOpts RebootOptions
 	err = t.Get("reboot", &rebootOpts)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
 		return err
 	}
 
+	switch rebootOpts.Mode {
+	case "", "run":
+		// ensure the next boot goes into run mode
+		if err := bootEnsureNextBootToRunMode(modeEnv.RecoverySystem); err != nil {
+			return err
+		}
+	case "recover", "factory-reset":
+		// install-device asked to go back to the recovery system
+		if err := bootSetRecoveryBootSystemAndMode(deviceCtx, modeEnv.RecoverySystem, rebootOpts.Mode); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("internal error: unsupported reboot mode %q", rebootOpts.Mode)
+	}
+
 	// write timing information
 	if err := writeTimings(st, boot.InstallHostWritableDir(model), modeEnv.Mode); err != nil {
 		logger.Noticef("cannot write timings: %v", err)
@@ -622,8 +749,25 @@ func (m *DeviceManager) doRestartSystemToRunMode(t *state.Task, _ *tomb.Tomb) er
 		what = "poweroff"
 		rst = restart.RestartSystemPoweroffNow
 	}
+	reason := &restart.RebootReason{
+		Code:     restart.RebootReasonInstall,
+		ChangeID: t.Change().ID(),
+		TaskID:   t.ID(),
+	}
+	if modeEnv.Mode == "factory-reset" {
+		reason.Code = restart.RebootReasonFactoryReset
+	}
+	if rebootOpts.Delay > 0 {
+		// the next boot was already set up above
+		chg, err := scheduleHookReboot(st, &RebootOptions{Op: rebootOpts.Op, Delay: rebootOpts.Delay})
+		if err != nil {
+			return err
+		}
+		logger.Noticef("system %s scheduled in %v by change %s", what, rebootOpts.Delay, chg.ID())
+		return nil
+	}
 	logger.Noticef("request immediate system %s", what)
-	restart.Request(st, rst, nil)
+	restart.RequestWithReason(st, rst, reason, nil)
 
 	return nil
 }
@@ -911,6 +1055,9 @@ func (m *DeviceManager) doFactoryResetRunSystem(t *state.Task, _ *tomb.Tomb) err
 	if err := gadget.ValidateContent(ginfo, gadgetDir, kernelDir); err != nil {
 		return fmt.Errorf("cannot use gadget: %v", err)
 	}
+	if err := checkGadgetFilesystems(st, ginfo.Volumes); err != nil {
+		return fmt.Errorf("cannot use gadget: %v", err)
+	}
 
 	var trustedInstallObserver *boot.TrustedAssetsInstallObserver
 	// get a nice nil interface by default
@@ -986,6 +1133,11 @@ func (m *DeviceManager) doFactoryResetRunSystem(t *state.Task, _ *tomb.Tomb) err
 		if err := prepareEncryptedSystemData(model, installedSystem.KeyForRole, trustedInstallObserver); err != nil {
 			return err
 		}
+		// the recovery key of ubuntu-save was removed above, a new
+		// one is escrowed along with the one of ubuntu-data
+		if err := escrowRecoveryKey(model, ginfo.RecoveryKeyEscrow, gadgetDir, installedSystem); err != nil {
+			return err
+		}
 	}
 
 	if err := prepareRunSystemData(model, gadgetDir, perfTimings); err != nil {
@@ -1226,6 +1378,34 @@ type encryptionSetupDataKey struct {
 	systemLabel string
 }
 
+type installPassphraseKey struct {
+	systemLabel string
+}
+
+type volumesAuthModeKey struct {
+	systemLabel string
+}
+
+// checkEncryptionSetupMatchesVolumes checks that the install is finished on
+// the same partitions that the storage encryption was set up for, as given
+// by a map of role -> device node of the encrypted partition.
+func checkEncryptionSetupMatchesVolumes(setupDeviceForRole map[string]string, onVolumes map[string]*gadget.Volume) error {
+	deviceForRole := make(map[string]string)
+	for _, vol := range onVolumes {
+		for _, vs := range vol.Structure {
+			if vs.Role == gadget.SystemData || vs.Role == gadget.SystemSave {
+				deviceForRole[vs.Role] = vs.Device
+			}
+		}
+	}
+	for role, device := range setupDeviceForRole {
+		if deviceForRole[role] != device {
+			return fmt.Errorf("storage encryption was set up for %s on %q but volumes use %q", role, device, deviceForRole[role])
+		}
+	}
+	return nil
+}
+
 func mountSeedSnap(seedSn *seed.Snap) (mountpoint string, unmount func() error, err error) {
 	mountpoint = filepath.Join(dirs.SnapRunDir, "snap-content", string(seedSn.EssentialType))
 	if err := os.MkdirAll(mountpoint, 0755); err != nil {
@@ -1313,11 +1493,13 @@ func (m *DeviceManager) doInstallFinish(t *state.Task, _ *tomb.Tomb) error {
 	cached := st.Cached(encryptionSetupDataKey{systemLabel})
 	if cached != nil {
 		var ok bool
-		// TODO check that encryptSetupData is not out of sync with the onVolumes we get
 		encryptSetupData, ok = cached.(*install.EncryptionSetupData)
 		if !ok {
 			return fmt.Errorf("internal error: wrong data type under encryptionSetupDataKey")
 		}
+		if err := checkEncryptionSetupMatchesVolumes(encryptSetupData.RawDevices(), onVolumes); err != nil {
+			return fmt.Errorf("cannot finish install: %v", err)
+		}
 	}
 
 	st.Unlock()
@@ -1329,6 +1511,9 @@ func (m *DeviceManager) doInstallFinish(t *state.Task, _ *tomb.Tomb) error {
 	defer unmount()
 
 	// TODO validation of onVolumes versus gadget.yaml
+	if err := checkGadgetFilesystems(st, onVolumes); err != nil {
+		return err
+	}
 
 	// Check if encryption is mandatory
 	if sys.Model.StorageSafety() == asserts.StorageSafetyEncrypted && encryptSetupData == nil {
@@ -1377,6 +1562,22 @@ func (m *DeviceManager) doInstallFinish(t *state.Task, _ *tomb.Tomb) error {
 			if err := prepareEncryptedSystemData(sys.Model, install.KeysForRole(encryptSetupData), trustedInstallObserver); err != nil {
 				return err
 			}
+			ginfo, err := gadget.ReadInfo(mntPtForType[snap.TypeGadget], sys.Model)
+			if err != nil {
+				return err
+			}
+			installedSystem := &install.InstalledSystemSideData{
+				KeyForRole:    install.KeysForRole(encryptSetupData),
+				DeviceForRole: encryptSetupData.RawDevices(),
+			}
+			if err := escrowRecoveryKey(sys.Model, ginfo.RecoveryKeyEscrow, mntPtForType[snap.TypeGadget], installedSystem); err != nil {
+				return err
+			}
+			// the volumes are unlocked with the passphrase
+			// instead of keys sealed to the TPM
+			if st.Cached(volumesAuthModeKey{systemLabel}) == VolumesAuthModePassphrase {
+				trustedInstallObserver.ChosenPassphraseAuth()
+			}
 		}
 	}
 
@@ -1433,7 +1634,24 @@ func (m *DeviceManager) doInstallSetupStorageEncryption(t *state.Task, _ *tomb.T
 	if err := t.Get("on-volumes", &onVolumes); err != nil {
 		return err
 	}
-	logger.Debugf("install-setup-storage-encryption for %q on %v", systemLabel, onVolumes)
+	var requestedType secboot.EncryptionType
+	if err := t.Get("encryption-type", &requestedType); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	authMode := VolumesAuthModeTPM
+	if err := t.Get("volumes-auth-mode", &authMode); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	var passphrase string
+	if authMode == VolumesAuthModePassphrase {
+		passphrase, _ = st.Cached(installPassphraseKey{systemLabel}).(string)
+		if passphrase == "" {
+			return fmt.Errorf("cannot setup storage encryption: passphrase is not available anymore")
+		}
+	}
+	// whatever happens the passphrase is not needed anymore
+	defer st.Cache(installPassphraseKey{systemLabel}, nil)
+	logger.Debugf("install-setup-storage-encryption for %q on %v (volumes authentication: %s)", systemLabel, onVolumes, authMode)
 
 	st.Unlock()
 	sys, snapInfos, snapSeeds, mntPtForType, unmount, err := m.loadAndMountSystemLabelSnaps(systemLabel)
@@ -1467,11 +1685,29 @@ func (m *DeviceManager) doInstallSetupStorageEncryption(t *state.Task, _ *tomb.T
 		return fmt.Errorf("encryption unavailable on this device: %v", whyStr)
 	}
 
-	encryptionSetupData, err := installEncryptPartitions(onVolumes, secboot.EncryptionTypeLUKS, sys.Model, mntPtForType[snap.TypeGadget], mntPtForType[snap.TypeKernel], perfTimings)
+	if requestedType != secboot.EncryptionTypeNone && requestedType != encryptInfo.Type {
+		return fmt.Errorf("cannot use encryption type %q, this device supports %q", requestedType, encryptInfo.Type)
+	}
+	if authMode == VolumesAuthModePassphrase && encryptInfo.Type != secboot.EncryptionTypeLUKS {
+		return fmt.Errorf("cannot use %q volumes authentication mode with %q encryption", authMode, encryptInfo.Type)
+	}
+
+	encryptionSetupData, err := installEncryptPartitions(onVolumes, encryptInfo.Type, sys.Model, mntPtForType[snap.TypeGadget], mntPtForType[snap.TypeKernel], perfTimings)
 	if err != nil {
 		return err
 	}
 
+	if authMode == VolumesAuthModePassphrase {
+		timings.Run(perfTimings, "add-passphrase", "Add passphrase to encrypted partitions", func(tm timings.Measurer) {
+			st.Unlock()
+			defer st.Lock()
+			err = installAddPassphrase(encryptionSetupData, passphrase)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	// Store created devices in the change so they can be accessed from the installer
 	apiData := map[string]interface{}{
 		"encrypted-devices": encryptionSetupData.EncryptedDevices(),
@@ -1480,6 +1716,7 @@ func (m *DeviceManager) doInstallSetupStorageEncryption(t *state.Task, _ *tomb.T
 	chg.Set("api-data", apiData)
 
 	st.Cache(encryptionSetupDataKey{systemLabel}, encryptionSetupData)
+	st.Cache(volumesAuthModeKey{systemLabel}, authMode)
 
 	return nil
 }
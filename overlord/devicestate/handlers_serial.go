@@ -501,6 +501,7 @@ func getSerial(t *state.Task, regCtx registrationContext, privKey asserts.Privat
 	proxyConf := proxyconf.New(st)
 	client := httputilNewHTTPClient(&httputil.ClientOptions{
 		Timeout:            30 * time.Second,
+		TLSConfig:          deviceCertificateTLSConfig(),
 		MayLogBody:         true,
 		Proxy:              proxyConf.Conf,
 		ProxyConnectHeader: http.Header{"User-Agent": []string{snapdenv.UserAgent()}},
@@ -511,6 +512,13 @@ func getSerial(t *state.Task, regCtx registrationContext, privKey asserts.Privat
 		return nil, nil, err
 	}
 
+	timings.Run(tm, "enroll-device", "enroll device with external device management service", func(timings.Measurer) {
+		err = enrollDevice(t, device, client, cfg)
+	})
+	if err != nil { // errors & retries
+		return nil, nil, err
+	}
+
 	// NB: until we get at least an Accepted (202) we need to
 	// retry from scratch creating a new request-id because the
 	// previous one used could have expired
@@ -572,6 +580,7 @@ type serialRequestConfig struct {
 	headers          map[string]string
 	proposedSerial   string
 	body             []byte
+	enrollment       *enrollmentConfig
 }
 
 func (cfg *serialRequestConfig) applyHeaders(req *http.Request) {
@@ -634,6 +643,11 @@ func getSerialRequestConfig(t *state.Task, regCtx registrationContext, client *h
 		if err != nil {
 			return nil, err
 		}
+
+		cfg.enrollment, err = getEnrollmentConfig(tr, gadgetName)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if proxyURL != nil && svcURL != nil && !newEnoughProxy(st, proxyURL, client) {
@@ -27,6 +27,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/tomb.v2"
 
@@ -120,7 +121,7 @@ func purgeNewSystemSnapFiles(logfile string) error {
 	return s.Err()
 }
 
-func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) (err error) {
+func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, tomb *tomb.Tomb) (err error) {
 	if release.OnClassic {
 		// TODO: this may need to be lifted in the future
 		return fmt.Errorf("cannot create recovery systems on a classic system")
@@ -203,6 +204,12 @@ func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) (err
 		if recoverySystemDir != systemDirectory {
 			return fmt.Errorf("internal error: unexpected recovery system path %q", recoverySystemDir)
 		}
+		// copying the snaps of the seed can take a while, stop in
+		// between them if asked to, the partial system is removed
+		// below
+		if err := state.Checkpoint(tomb); err != nil {
+			return err
+		}
 		// track all the files, both asserted shared snaps and private
 		// ones
 		return logNewSystemSnapFile(filepath.Join(recoverySystemDir, "snapd-new-file-log"), where)
@@ -253,6 +260,9 @@ func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) (err
 	// consider clearing the recovery system directory and restarting from
 	// scratch
 	_, err = createSystemForModelFromValidatedSnaps(model, label, db, infoGetter, observeSnapFileWrite)
+	if _, ok := err.(*state.Retry); ok {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("cannot create a recovery system with label %q for %v: %v", label, model.Model(), err)
 	}
@@ -274,7 +284,7 @@ func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) (err
 
 	// this task is done, further processing happens in finalize
 	logger.Noticef("restarting into candidate system %q", label)
-	return snapstate.FinishTaskWithRestart(t, state.DoneStatus, restart.RestartSystemNow, nil)
+	return snapstate.FinishTaskWithRestart(t, state.DoneStatus, restart.RestartSystemNow, restart.RebootReasonRecoverySystem, nil)
 }
 
 func (m *DeviceManager) undoCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) error {
@@ -417,3 +427,113 @@ func (m *DeviceManager) cleanupRecoverySystem(t *state.Task, _ *tomb.Tomb) error
 	}
 	return nil
 }
+
+func (m *DeviceManager) doPerformSystemAction(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	var action ScheduledSystemAction
+	if err := t.Get("scheduled-system-action", &action); err != nil {
+		st.Unlock()
+		return err
+	}
+	var at time.Time
+	if err := t.Get("at", &at); err != nil {
+		st.Unlock()
+		return err
+	}
+	if wait := at.Sub(timeNow()); wait > 0 {
+		st.Unlock()
+		// the change can be aborted while waiting
+		return &state.Retry{After: wait}
+	}
+	var performed bool
+	if err := t.Get("performed", &performed); err != nil && !errors.Is(err, state.ErrNoState) {
+		st.Unlock()
+		return err
+	}
+	if performed {
+		// the action was carried out already, but snapd was restarted
+		// before the task could complete, do not repeat it
+		st.Unlock()
+		return nil
+	}
+	t.Set("performed", true)
+	// make sure the action is not repeated should snapd be restarted
+	// before the task is marked as done
+	st.Unlock()
+
+	var err error
+	switch action.Action {
+	case "reboot":
+		err = m.Reboot(action.SystemLabel, action.Mode)
+	case "shutdown":
+		err = m.Shutdown(action.SystemLabel, action.Mode)
+	case "do":
+		err = m.RequestSystemAction(action.SystemLabel, SystemAction{
+			Title: action.Title,
+			Mode:  action.Mode,
+		})
+	default:
+		err = fmt.Errorf("internal error: unsupported system action %q", action.Action)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot perform scheduled system action: %v", err)
+	}
+	return nil
+}
+
+func (m *DeviceManager) doTrySystemBank(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var bank string
+	if err := t.Get("system-bank", &bank); err != nil {
+		return err
+	}
+	deviceCtx, err := DeviceCtx(st, t, nil)
+	if err != nil {
+		return err
+	}
+	if err := boot.SetTrySystemBank(deviceCtx, bank); err != nil {
+		return err
+	}
+
+	// further processing happens in finalize
+	logger.Noticef("restarting into system bank %q", bank)
+	return snapstate.FinishTaskWithRestart(t, state.DoneStatus, restart.RestartSystemNow, restart.RebootReasonSystemBank, nil)
+}
+
+func (m *DeviceManager) doFinalizeSystemBank(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if ok, _ := restart.Pending(st); ok {
+		// don't continue until we are in the restarted snapd
+		t.Logf("Waiting for system reboot...")
+		return &state.Retry{}
+	}
+	if !m.bootOkRan {
+		// the tried bank is made the current one when marking the boot
+		// successful
+		return &state.Retry{}
+	}
+
+	var bank string
+	if err := t.Get("system-bank", &bank); err != nil {
+		return err
+	}
+	deviceCtx, err := DeviceCtx(st, t, nil)
+	if err != nil {
+		return err
+	}
+	current, err := boot.SystemBank(deviceCtx)
+	if err != nil {
+		return err
+	}
+	if current != bank {
+		return fmt.Errorf("tried system bank %q failed to boot", bank)
+	}
+	return nil
+}
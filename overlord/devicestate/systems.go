@@ -122,6 +122,20 @@ func (c *currentSystem) sameAs(other *System) bool {
 }
 
 func currentSystemForMode(st *state.State, mode string) (*currentSystem, error) {
+	return currentSystemForModeWith(mode, func() (*seededSystem, error) {
+		return currentSeededSystem(st)
+	})
+}
+
+// currentSystemForModeLocked is like currentSystemForMode but must be called
+// with the state lock held.
+func currentSystemForModeLocked(st *state.State, mode string) (*currentSystem, error) {
+	return currentSystemForModeWith(mode, func() (*seededSystem, error) {
+		return currentSeededSystemLocked(st)
+	})
+}
+
+func currentSystemForModeWith(mode string, seededSystemInRunMode func() (*seededSystem, error)) (*currentSystem, error) {
 	var system *seededSystem
 	var actions []SystemAction
 	var err error
@@ -129,7 +143,7 @@ func currentSystemForMode(st *state.State, mode string) (*currentSystem, error)
 	switch mode {
 	case "run":
 		actions = currentSystemActions
-		system, err = currentSeededSystem(st)
+		system, err = seededSystemInRunMode()
 	case "install":
 		// there is no current system for install mode
 		return nil, nil
@@ -154,6 +168,10 @@ func currentSeededSystem(st *state.State) (*seededSystem, error) {
 	st.Lock()
 	defer st.Unlock()
 
+	return currentSeededSystemLocked(st)
+}
+
+func currentSeededSystemLocked(st *state.State) (*seededSystem, error) {
 	var whatseeded []seededSystem
 	if err := st.Get("seeded-systems", &whatseeded); err != nil {
 		return nil, err
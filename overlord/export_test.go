@@ -35,6 +35,14 @@ var (
 	LockWithTimeout = lockWithTimeout
 )
 
+// MockStateEncryptionKey mocks the key the sensitive parts of the state
+// are encrypted with.
+func MockStateEncryptionKey(f func() ([]byte, error)) (restore func()) {
+	r := testutil.Backup(&stateEncryptionKey)
+	stateEncryptionKey = f
+	return r
+}
+
 // MockEnsureInterval sets the overlord ensure interval for tests.
 func MockEnsureInterval(d time.Duration) (restore func()) {
 	old := ensureInterval
@@ -116,3 +124,15 @@ func MockSystemdSdNotify(f func(notifyState string) error) (restore func()) {
 		systemdSdNotify = old
 	}
 }
+
+func MockOsutilBootID(f func() (string, error)) (restore func()) {
+	old := osutilBootID
+	osutilBootID = f
+	return func() {
+		osutilBootID = old
+	}
+}
+
+func (o *Overlord) PersistTimings() {
+	o.persistTimings()
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/systemd"
 )
 
 var checkTimeout = 30 * time.Second
@@ -47,6 +48,7 @@ func init() {
 	}
 
 	snapstate.CheckHealthHook = Hook
+	snapstate.VerifyRefreshHealth = verifyRefreshHealth
 }
 
 func Hook(st *state.State, snapName string, snapRev snap.Revision) *state.Task {
@@ -225,3 +227,51 @@ func Get(st *state.State, snap string) (*HealthState, error) {
 
 	return &health, nil
 }
+
+// maxRefreshServiceRestarts is how many times systemd can restart a service
+// of a refreshed snap before the snap is considered unhealthy.
+const maxRefreshServiceRestarts = 3
+
+// verifyRefreshHealth checks the health of a snap after it was refreshed to
+// the given revision, considering it unhealthy if its health check
+// reported an error or its services keep being restarted.
+func verifyRefreshHealth(st *state.State, instanceName string, rev snap.Revision) error {
+	health, err := Get(st, instanceName)
+	if err != nil {
+		return err
+	}
+	if health != nil && health.Revision == rev && health.Status == ErrorStatus {
+		if health.Message != "" {
+			return fmt.Errorf("health check reported an error: %s", health.Message)
+		}
+		return fmt.Errorf("health check reported an error")
+	}
+
+	info, err := snapstate.CurrentInfo(st, instanceName)
+	if err != nil {
+		return err
+	}
+	var serviceNames []string
+	for _, app := range info.Services() {
+		// the restarts of user services cannot be tracked globally
+		if app.DaemonScope == snap.SystemDaemon {
+			serviceNames = append(serviceNames, app.ServiceName())
+		}
+	}
+	if len(serviceNames) == 0 {
+		return nil
+	}
+	sysd := systemd.New(systemd.SystemMode, nil)
+	sts, err := sysd.Status(serviceNames)
+	if err != nil {
+		// not conclusive
+		logger.Noticef("cannot get status of services of snap %q: %v", instanceName, err)
+		return nil
+	}
+	for _, status := range sts {
+		if status.NRestarts >= maxRefreshServiceRestarts {
+			return fmt.Errorf("service %q was restarted %d times", status.Name, status.NRestarts)
+		}
+	}
+	return nil
+}
@@ -20,6 +20,7 @@
 package healthstate_test
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -37,6 +38,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -252,3 +254,81 @@ func (s *healthSuite) TestSetFromHookContextEmpty(c *check.C) {
 	// no health in the context -> no health in state
 	c.Check(s.state.Get("health", &hs), testutil.ErrorIs, state.ErrNoState)
 }
+
+func (s *healthSuite) TestVerifyRefreshHealthHealthy(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// no health recorded, no services
+	c.Check(healthstate.VerifyRefreshHealth(s.state, "test-snap", snap.R(42)), check.IsNil)
+
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"test-snap": {Revision: snap.R(42), Status: healthstate.OkayStatus},
+	})
+	c.Check(healthstate.VerifyRefreshHealth(s.state, "test-snap", snap.R(42)), check.IsNil)
+
+	// errors reported by other revisions do not matter
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"test-snap": {Revision: snap.R(41), Status: healthstate.ErrorStatus},
+	})
+	c.Check(healthstate.VerifyRefreshHealth(s.state, "test-snap", snap.R(42)), check.IsNil)
+}
+
+func (s *healthSuite) TestVerifyRefreshHealthError(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"test-snap": {Revision: snap.R(42), Status: healthstate.ErrorStatus, Message: "database is gone"},
+	})
+	err := healthstate.VerifyRefreshHealth(s.state, "test-snap", snap.R(42))
+	c.Check(err, check.ErrorMatches, "health check reported an error: database is gone")
+}
+
+func (s *healthSuite) testVerifyRefreshHealthServices(c *check.C, restarts int) error {
+	sideInfo := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(42)}
+	snaptest.MockSnap(c, `name: test-snap
+version: v1
+apps:
+  svc:
+    daemon: simple
+  user-svc:
+    daemon: simple
+    daemon-scope: user
+`, sideInfo)
+
+	var calls [][]string
+	restore := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		return []byte(fmt.Sprintf(`Id=%s
+Names=%[1]s
+Type=simple
+ActiveState=active
+UnitFileState=enabled
+NeedDaemonReload=no
+NRestarts=%d
+ExecMainCode=0
+ExecMainStatus=0
+StateChangeTimestamp=Fri 2022-10-14 12:34:56 UTC
+`, args[len(args)-1], restarts)), nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	err := healthstate.VerifyRefreshHealth(s.state, "test-snap", snap.R(42))
+	// only the system service is considered
+	c.Assert(calls, check.HasLen, 1)
+	c.Check(calls[0][len(calls[0])-1], check.Equals, "snap.test-snap.svc.service")
+	return err
+}
+
+func (s *healthSuite) TestVerifyRefreshHealthServicesHealthy(c *check.C) {
+	err := s.testVerifyRefreshHealthServices(c, 2)
+	c.Check(err, check.IsNil)
+}
+
+func (s *healthSuite) TestVerifyRefreshHealthServicesRestarted(c *check.C) {
+	err := s.testVerifyRefreshHealthServices(c, 3)
+	c.Check(err, check.ErrorMatches, `service "snap.test-snap.svc.service" was restarted 3 times`)
+}
@@ -172,14 +172,29 @@ func Run(context *hookstate.Context, args []string, uid uint32) (stdout, stderr
 	return stdoutBuffer.Bytes(), stderrBuffer.Bytes(), err
 }
 
+// rootOnlyOptions lists the options of commands in nonRootAllowed that still
+// require snapctl to be invoked by root.
+var rootOnlyOptions = map[string][]string{
+	"system-mode": {"--clear-factory"},
+}
+
 func isAllowedToRun(uid uint32, args []string) bool {
 	// A command can run if any of the following are true:
 	//	* It runs as root
-	//	* It's contained in nonRootAllowed
+	//	* It's contained in nonRootAllowed and used without any of its
+	//	  rootOnlyOptions
 	//	* It's used with the -h or --help flags
 	// note: commands still need valid context and snaps can only access own config.
-	return uid == 0 ||
-		strutil.ListContains(nonRootAllowed, args[0]) ||
-		strutil.ListContains(args, "-h") ||
-		strutil.ListContains(args, "--help")
+	if uid == 0 || strutil.ListContains(args, "-h") || strutil.ListContains(args, "--help") {
+		return true
+	}
+	if !strutil.ListContains(nonRootAllowed, args[0]) {
+		return false
+	}
+	for _, opt := range rootOnlyOptions[args[0]] {
+		if strutil.ListContains(args, opt) {
+			return false
+		}
+	}
+	return true
 }
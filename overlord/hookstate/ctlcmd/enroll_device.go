@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/devicestate"
+)
+
+var (
+	shortEnrollDeviceHelp = i18n.G("Enroll the device with a device management service")
+	longEnrollDeviceHelp  = i18n.G(`
+The enroll-device command can be used from the gadget prepare-device hook to
+have the device obtain a certificate from a device management service (eg. a
+corporate PKI) before requesting a serial. The certificate is then presented
+as TLS client certificate to the device service and to the store.
+
+Currently only the EST protocol (RFC 7030) is supported, the URL being the base
+of the EST service to which "simpleenroll" is appended:
+
+    $ snapctl enroll-device est https://est.example.com/.well-known/est/ --username=device --password=$PASSWORD
+`)
+)
+
+func init() {
+	addCommand("enroll-device", shortEnrollDeviceHelp, longEnrollDeviceHelp, func() command { return &enrollDeviceCommand{} })
+}
+
+type enrollDeviceCommand struct {
+	baseCommand
+
+	Username string `long:"username" description:"username for HTTP basic authentication with the service"`
+	Password string `long:"password" description:"password for HTTP basic authentication with the service"`
+
+	Positional struct {
+		Protocol string `positional-arg-name:"<protocol>" required:"yes"`
+		URL      string `positional-arg-name:"<url>" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (c *enrollDeviceCommand) Execute([]string) error {
+	ctx, err := c.ensureContext()
+	if err != nil {
+		return err
+	}
+	if ctx.HookName() != "prepare-device" {
+		return fmt.Errorf("cannot use enroll-device command outside of gadget prepare-device hook")
+	}
+
+	if !devicestate.IsValidEnrollmentProtocol(c.Positional.Protocol) {
+		return fmt.Errorf("unsupported device enrollment protocol %q", c.Positional.Protocol)
+	}
+	u, err := url.Parse(c.Positional.URL)
+	if err != nil {
+		return fmt.Errorf("cannot parse device enrollment URL %q: %v", c.Positional.URL, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("cannot use device enrollment URL %q: unsupported scheme", c.Positional.URL)
+	}
+	if c.Password != "" && c.Username == "" {
+		return fmt.Errorf("cannot use --password without --username")
+	}
+
+	ctx.Lock()
+	tr := configstate.ContextTransaction(ctx)
+	ctx.Unlock()
+
+	instanceName := ctx.InstanceName()
+	tr.Set(instanceName, "device-service.enrollment.protocol", c.Positional.Protocol)
+	tr.Set(instanceName, "device-service.enrollment.url", c.Positional.URL)
+	tr.Set(instanceName, "device-service.enrollment.username", c.Username)
+
+	// the password is not part of the configuration, which can be read
+	// through the API, it is stored separately once the hook is done
+	password := c.Password
+	ctx.Lock()
+	ctx.OnDone(func() error {
+		return devicestate.SetEnrollmentPassword(password)
+	})
+	ctx.Unlock()
+
+	return nil
+}
@@ -0,0 +1,143 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type enrollDeviceSuite struct {
+	testutil.BaseTest
+	state       *state.State
+	mockContext *hookstate.Context
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&enrollDeviceSuite{})
+
+func (s *enrollDeviceSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.mockHandler = hooktest.NewMockHandler()
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+	task := s.state.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "gadget", Revision: snap.R(1), Hook: "prepare-device"}
+
+	ctx, err := hookstate.NewContext(task, s.state, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+	s.mockContext = ctx
+}
+
+func (s *enrollDeviceSuite) TestBadHook(c *C) {
+	s.state.Lock()
+	task := s.state.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "gadget", Revision: snap.R(1), Hook: "configure"}
+	s.state.Unlock()
+
+	ctx, err := hookstate.NewContext(task, s.state, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(ctx, []string{"enroll-device", "est", "https://est.example.com"}, 0)
+	c.Assert(err, ErrorMatches, `cannot use enroll-device command outside of gadget prepare-device hook`)
+}
+
+func (s *enrollDeviceSuite) TestBadArgs(c *C) {
+	for i, t := range []struct {
+		args []string
+		err  string
+	}{{
+		[]string{"enroll-device", "est"},
+		"the required argument `<url>` was not provided",
+	}, {
+		[]string{"enroll-device", "scep", "https://scep.example.com"},
+		`unsupported device enrollment protocol "scep"`,
+	}, {
+		[]string{"enroll-device", "est", "ftp://est.example.com"},
+		`cannot use device enrollment URL "ftp://est.example.com": unsupported scheme`,
+	}, {
+		[]string{"enroll-device", "est", "https://est.example.com", "--password=secret"},
+		"cannot use --password without --username",
+	}} {
+		_, _, err := ctlcmd.Run(s.mockContext, t.args, 0)
+		c.Check(err, ErrorMatches, t.err, Commentf("%d", i))
+	}
+}
+
+func (s *enrollDeviceSuite) TestEnrollDevice(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"enroll-device", "est", "https://est.example.com/.well-known/est/", "--username=device", "--password=secret"}, 0)
+	c.Assert(err, IsNil)
+
+	s.mockContext.Lock()
+	c.Assert(s.mockContext.Done(), IsNil)
+	s.mockContext.Unlock()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	var enrollment map[string]interface{}
+	c.Assert(tr.Get("gadget", "device-service.enrollment", &enrollment), IsNil)
+	c.Check(enrollment, DeepEquals, map[string]interface{}{
+		"protocol": "est",
+		"url":      "https://est.example.com/.well-known/est/",
+		"username": "device",
+	})
+
+	// the password is stored in a file only readable by root instead
+	passwordFile := filepath.Join(dirs.SnapDeviceDir, "enrollment/password")
+	c.Check(passwordFile, testutil.FileEquals, "secret")
+	st, err := os.Stat(passwordFile)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+}
+
+func (s *enrollDeviceSuite) TestEnrollDeviceWithoutPasswordRemovesIt(c *C) {
+	passwordFile := filepath.Join(dirs.SnapDeviceDir, "enrollment/password")
+	c.Assert(os.MkdirAll(filepath.Dir(passwordFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(passwordFile, []byte("old"), 0600), IsNil)
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"enroll-device", "est", "https://est.example.com/.well-known/est/"}, 0)
+	c.Assert(err, IsNil)
+	// nothing changes until the hook is done
+	c.Check(passwordFile, testutil.FileEquals, "old")
+
+	s.mockContext.Lock()
+	c.Assert(s.mockContext.Done(), IsNil)
+	s.mockContext.Unlock()
+
+	c.Check(passwordFile, testutil.FileAbsent)
+}
@@ -155,3 +155,9 @@ func MockAutoRefreshForGatingSnap(f func(st *state.State, gatingSnap string) err
 		autoRefreshForGatingSnap = old
 	}
 }
+
+func MockDevicestateClearFactoryBootFlag(f func(st *state.State, gadgetSnap string) error) (restore func()) {
+	old := devicestateClearFactoryBootFlag
+	devicestateClearFactoryBootFlag = f
+	return func() { devicestateClearFactoryBootFlag = old }
+}
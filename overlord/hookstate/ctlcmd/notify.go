@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	shortNotifyHelp = i18n.G("Emit a notice")
+	longNotifyHelp  = i18n.G(`
+The notify command is called from within a snap to record the occurrence of an
+event of its own as a notice, which management agents and other clients can
+then retrieve from snapd.
+
+The key identifies the event within the snap, it is made of lowercase ASCII
+letters and numbers, optionally separated by single dashes or dots. The notice
+has the "snap" type and the key is prefixed with the name of the snap:
+
+$ snapctl notify door.opened '{"door": "front"}'
+
+is retrieved from the snapd API with
+
+GET /v2/notices?types=snap&keys=<snap>/door.opened
+
+The optional payload of the occurrence must be a JSON document of at most 4096
+bytes. Recurring events update the same notice, which is only repeated for
+occurrences at least --repeat-after after the previous repeat.
+
+A snap can record at most 32 distinct notices.
+`)
+)
+
+const (
+	maxSnapNoticeKeyLength   = 64
+	maxSnapNoticePayloadSize = 4096
+	maxSnapNotices           = 32
+)
+
+var validSnapNoticeKey = regexp.MustCompile(`^[a-z0-9](?:-?[a-z0-9])*(?:\.[a-z0-9](?:-?[a-z0-9])*)*$`).MatchString
+
+func init() {
+	addCommand("notify", shortNotifyHelp, longNotifyHelp, func() command { return &notifyCommand{} })
+}
+
+type notifyCommand struct {
+	baseCommand
+	Positional struct {
+		Key     string `positional-arg-name:"<key>" required:"yes" description:"the key identifying the event within the snap"`
+		Payload string `positional-arg-name:"<payload>" description:"a JSON document describing the occurrence"`
+	} `positional-args:"yes"`
+	RepeatAfter time.Duration `long:"repeat-after" value-name:"<duration>" description:"do not repeat the notice for occurrences within this duration of the previous repeat"`
+}
+
+func (c *notifyCommand) Execute([]string) error {
+	key := c.Positional.Key
+	if len(key) > maxSnapNoticeKeyLength || !validSnapNoticeKey(key) {
+		return fmt.Errorf("invalid notice key %q", key)
+	}
+	var payload json.RawMessage
+	if c.Positional.Payload != "" {
+		if len(c.Positional.Payload) > maxSnapNoticePayloadSize {
+			return fmt.Errorf("notice payload cannot be longer than %d bytes", maxSnapNoticePayloadSize)
+		}
+		payload = json.RawMessage(c.Positional.Payload)
+		if !json.Valid(payload) {
+			return fmt.Errorf("notice payload must be a JSON document")
+		}
+	}
+	if c.RepeatAfter < 0 {
+		return fmt.Errorf("cannot repeat notice after a negative duration")
+	}
+
+	ctx, err := c.ensureContext()
+	if err != nil {
+		return err
+	}
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	st := ctx.State()
+	prefix := ctx.InstanceName() + "/"
+	noticeKey := prefix + key
+	existing := st.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapNotice}})
+	count := 0
+	for _, n := range existing {
+		if n.Key() == noticeKey {
+			// updating a notice does not count against the quota
+			count = 0
+			break
+		}
+		if strings.HasPrefix(n.Key(), prefix) {
+			count++
+		}
+	}
+	if count >= maxSnapNotices {
+		return fmt.Errorf("cannot record more than %d notices of snap %q", maxSnapNotices, ctx.InstanceName())
+	}
+
+	_, err = st.AddNotice(state.SnapNotice, noticeKey, &state.AddNoticeOptions{
+		Payload:     payload,
+		RepeatAfter: c.RepeatAfter,
+	})
+	return err
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type notifySuite struct {
+	state       *state.State
+	mockContext *hookstate.Context
+}
+
+var _ = Suite(&notifySuite{})
+
+func (s *notifySuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	task := s.state.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	ctx, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+	s.mockContext = ctx
+}
+
+func (s *notifySuite) snapNotices() []*state.Notice {
+	s.state.Lock()
+	defer s.state.Unlock()
+	return s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapNotice}})
+}
+
+func (s *notifySuite) TestNotify(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"notify", "door.opened", `{"door": "front"}`}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+
+	notices := s.snapNotices()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Key(), Equals, "test-snap/door.opened")
+	c.Check(string(notices[0].LastPayload()), Equals, `{"door": "front"}`)
+	c.Check(notices[0].Occurrences(), Equals, 1)
+
+	// recurring events update the notice
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"notify", "--repeat-after=1h", "door.opened"}, 0)
+	c.Assert(err, IsNil)
+	notices = s.snapNotices()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Occurrences(), Equals, 2)
+	c.Check(notices[0].LastPayload(), IsNil)
+}
+
+func (s *notifySuite) TestNotifyNonRoot(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"notify", "door.opened"}, 1000)
+	c.Check(err, DeepEquals, &ctlcmd.ForbiddenCommandError{Message: `cannot use "notify" with uid 1000, try with sudo`})
+	c.Check(s.snapNotices(), HasLen, 0)
+}
+
+func (s *notifySuite) TestNotifyErrors(c *C) {
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"notify"}, "the required argument `<key>` was not provided"},
+		{[]string{"notify", "Door"}, `invalid notice key "Door"`},
+		{[]string{"notify", "door..opened"}, `invalid notice key "door..opened"`},
+		{[]string{"notify", "door/opened"}, `invalid notice key "door/opened"`},
+		{[]string{"notify", strings.Repeat("a", 65)}, `invalid notice key "a+"`},
+		{[]string{"notify", "door", "{"}, `notice payload must be a JSON document`},
+		{[]string{"notify", "door", fmt.Sprintf("%q", strings.Repeat("a", 4096))}, `notice payload cannot be longer than 4096 bytes`},
+		{[]string{"notify", "--repeat-after=-1h", "door"}, `cannot repeat notice after a negative duration`},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, tc.args, 0)
+		c.Check(err, ErrorMatches, tc.err, Commentf("%v", tc.args))
+	}
+	c.Check(s.snapNotices(), HasLen, 0)
+}
+
+func (s *notifySuite) TestNotifyQuota(c *C) {
+	s.state.Lock()
+	// notices of other snaps do not count
+	_, err := s.state.AddNotice(state.SnapNotice, "other-snap/door", nil)
+	c.Assert(err, IsNil)
+	s.state.Unlock()
+
+	for i := 0; i < 32; i++ {
+		_, _, err := ctlcmd.Run(s.mockContext, []string{"notify", fmt.Sprintf("event-%d", i)}, 0)
+		c.Assert(err, IsNil)
+	}
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"notify", "one-too-many"}, 0)
+	c.Check(err, ErrorMatches, `cannot record more than 32 notices of snap "test-snap"`)
+
+	// existing notices can still be updated
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"notify", "event-0"}, 0)
+	c.Check(err, IsNil)
+	c.Check(s.snapNotices(), HasLen, 33)
+}
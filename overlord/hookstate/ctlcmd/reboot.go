@@ -21,6 +21,7 @@ package ctlcmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/devicestate"
@@ -31,7 +32,11 @@ var (
 	longRebootHelp  = i18n.G(`
 The reboot command can used from allowed hooks to control the reboot behavior of the system.
 
-Currently it can only be invoked from gadget install-device during UC20 install mode. After invoking it from install-device with --halt or --poweroff the device will not reboot into run mode after finishing install mode but will instead either halt or power off. From install-device the effect is therefore not immediate but delayed until the end of installation itself.
+Currently it can only be invoked from the gadget install-device and prepare-device hooks. After invoking it from install-device with --halt or --poweroff the device will not reboot into run mode after finishing install mode but will instead either halt or power off. With --into=recover or --into=factory-reset the device reboots into the given mode of the recovery system it was installed from instead of run mode. From install-device the effect is therefore not immediate but delayed until the end of installation itself.
+
+From prepare-device the reboot, optionally into the given mode with --into, or power off is carried out once the hook has finished.
+
+With --delay the reboot is postponed by the given duration. Until then it is tracked by a change that can be aborted to cancel it.
 `)
 )
 
@@ -42,8 +47,10 @@ func init() {
 type rebootCommand struct {
 	baseCommand
 
-	Halt     bool `long:"halt"`
-	Poweroff bool `long:"poweroff"`
+	Halt     bool   `long:"halt"`
+	Poweroff bool   `long:"poweroff"`
+	Into     string `long:"into" choice:"run" choice:"recover" choice:"factory-reset"`
+	Delay    string `long:"delay"`
 }
 
 func (c *rebootCommand) Execute([]string) error {
@@ -51,24 +58,59 @@ func (c *rebootCommand) Execute([]string) error {
 	if err != nil {
 		return err
 	}
-	if ctx.HookName() != "install-device" {
-		return fmt.Errorf("cannot use reboot command outside of gadget install-device hook")
+	hookName := ctx.HookName()
+	if hookName != "install-device" && hookName != "prepare-device" {
+		return fmt.Errorf("cannot use reboot command outside of gadget install-device or prepare-device hook")
 	}
 	task, ok := ctx.Task()
 	if !ok {
-		return fmt.Errorf("internal error: inside gadget install-device hook but no task")
+		return fmt.Errorf("internal error: inside gadget %s hook but no task", hookName)
 	}
-	if !c.Halt && !c.Poweroff {
-		return fmt.Errorf("either --halt or --poweroff must be specified")
+	if !c.Halt && !c.Poweroff && c.Into == "" {
+		return fmt.Errorf("either --halt, --poweroff or --into must be specified")
 	}
 	if c.Halt && c.Poweroff {
 		return fmt.Errorf("cannot specify both --halt and --poweroff")
 	}
+	if c.Halt && hookName != "install-device" {
+		return fmt.Errorf("cannot use --halt outside of gadget install-device hook")
+	}
+	var delay time.Duration
+	if c.Delay != "" {
+		delay, err = time.ParseDuration(c.Delay)
+		if err != nil {
+			return fmt.Errorf("cannot parse delay: %v", err)
+		}
+		if delay < 0 {
+			return fmt.Errorf("cannot use a negative delay")
+		}
+	}
+	if c.Halt && delay > 0 {
+		return fmt.Errorf("cannot delay a halt")
+	}
+
+	var op string
+	if c.Halt {
+		op = devicestate.RebootHaltOp
+	} else if c.Poweroff {
+		op = devicestate.RebootPoweroffOp
+	}
+	rebootOpts := devicestate.RebootOptions{
+		Op:    op,
+		Mode:  c.Into,
+		Delay: delay,
+	}
 
 	ctx.Lock()
 	defer ctx.Unlock()
 	st := ctx.State()
 
+	if hookName == "prepare-device" {
+		// the reboot is scheduled once the hook has finished
+		ctx.Set("reboot", rebootOpts)
+		return nil
+	}
+
 	var restartTaskID string
 	err = task.Get("restart-task", &restartTaskID)
 	if err != nil {
@@ -80,16 +122,7 @@ func (c *rebootCommand) Execute([]string) error {
 		return fmt.Errorf("internal error: tasks are being pruned")
 	}
 
-	var op string
-	if c.Halt {
-		op = devicestate.RebootHaltOp
-	} else if c.Poweroff {
-		op = devicestate.RebootPoweroffOp
-	}
-
-	restartTask.Set("reboot", devicestate.RebootOptions{
-		Op: op,
-	})
+	restartTask.Set("reboot", rebootOpts)
 
 	return nil
 }
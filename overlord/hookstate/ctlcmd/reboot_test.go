@@ -20,6 +20,8 @@
 package ctlcmd_test
 
 import (
+	"time"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
@@ -73,7 +75,7 @@ func (s *rebootSuite) TestBadHook(c *C) {
 	c.Assert(err, IsNil)
 
 	_, _, err = ctlcmd.Run(ctx, []string{"reboot", "--halt"}, 0)
-	c.Assert(err, ErrorMatches, `cannot use reboot command outside of gadget install-device hook`)
+	c.Assert(err, ErrorMatches, `cannot use reboot command outside of gadget install-device or prepare-device hook`)
 }
 
 func (s *rebootSuite) TestBadArgs(c *C) {
@@ -84,10 +86,22 @@ func (s *rebootSuite) TestBadArgs(c *C) {
 	table := []tableT{
 		{
 			[]string{"reboot"},
-			"either --halt or --poweroff must be specified",
+			"either --halt, --poweroff or --into must be specified",
 		}, {
 			[]string{"reboot", "--halt", "--poweroff"},
 			"cannot specify both --halt and --poweroff",
+		}, {
+			[]string{"reboot", "--into=install"},
+			"Invalid value `install' for option `--into'.*",
+		}, {
+			[]string{"reboot", "--into=recover", "--delay=soon"},
+			`cannot parse delay: time: invalid duration "soon"`,
+		}, {
+			[]string{"reboot", "--into=recover", "--delay=-1m"},
+			"cannot use a negative delay",
+		}, {
+			[]string{"reboot", "--halt", "--delay=1m"},
+			"cannot delay a halt",
 		}, {
 			[]string{"reboot", "--foo"},
 			"unknown flag `foo'",
@@ -138,3 +152,51 @@ func (s *rebootSuite) TestRegularRunPoweroff(c *C) {
 	c.Check(rebootOpts.Op, Equals, devicestate.RebootPoweroffOp)
 
 }
+
+func (s *rebootSuite) TestRegularRunIntoWithDelay(c *C) {
+	s.state.Lock()
+	s.hookTask.Set("restart-task", s.restartTask.ID())
+	chg := s.state.NewChange("install-device", "install-device")
+	chg.AddTask(s.restartTask)
+	s.state.Unlock()
+
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"reboot", "--into=recover", "--delay=5m"}, 0)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var rebootOpts devicestate.RebootOptions
+	err = s.restartTask.Get("reboot", &rebootOpts)
+	c.Assert(err, IsNil)
+
+	c.Check(rebootOpts, DeepEquals, devicestate.RebootOptions{
+		Mode:  "recover",
+		Delay: 5 * time.Minute,
+	})
+}
+
+func (s *rebootSuite) TestPrepareDevice(c *C) {
+	s.state.Lock()
+	task := s.state.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(42), Hook: "prepare-device"}
+	s.state.Unlock()
+
+	ctx, err := hookstate.NewContext(task, s.state, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(ctx, []string{"reboot", "--halt"}, 0)
+	c.Assert(err, ErrorMatches, `cannot use --halt outside of gadget install-device hook`)
+
+	_, _, err = ctlcmd.Run(ctx, []string{"reboot", "--into=factory-reset", "--delay=30s"}, 0)
+	c.Assert(err, IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+	var rebootOpts devicestate.RebootOptions
+	err = ctx.Get("reboot", &rebootOpts)
+	c.Assert(err, IsNil)
+	c.Check(rebootOpts, DeepEquals, devicestate.RebootOptions{
+		Mode:  "factory-reset",
+		Delay: 30 * time.Second,
+	})
+}
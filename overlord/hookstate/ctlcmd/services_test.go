@@ -624,8 +624,8 @@ NeedDaemonReload=no
 	c.Assert(err, IsNil)
 	c.Check(string(stdout), Equals, `
 Service                    Startup  Current  Notes
-test-snap.another-service  enabled  active   -
-test-snap.test-service     enabled  active   -
+test-snap.another-service  enabled  active   reloadable
+test-snap.test-service     enabled  active   reloadable
 test-snap.user-service     enabled  -        user
 `[1:])
 	c.Check(string(stderr), Equals, "")
@@ -649,7 +649,7 @@ NeedDaemonReload=no
 	c.Assert(err, IsNil)
 	c.Check(string(stdout), Equals, `
 Service                 Startup  Current  Notes
-test-snap.test-service  enabled  active   -
+test-snap.test-service  enabled  active   reloadable
 `[1:])
 	c.Check(string(stderr), Equals, "")
 }
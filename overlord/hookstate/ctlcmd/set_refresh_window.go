@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+var (
+	shortSetRefreshWindowHelp = i18n.G("Set the refresh window of the snap")
+	longSetRefreshWindowHelp  = i18n.G(`
+The set-refresh-window command sets the maintenance window in which the snap
+prefers auto-refreshes to happen, in the format of the refresh.timer system
+option. It takes precedence over the refresh-window declared in snap.yaml.
+
+The window is intersected with refresh.timer. Auto-refreshes of the snap are
+not deferred for more than 14 days to respect the window.
+
+It can be called from any hook, and from the apps themselves:
+
+    $ snapctl set-refresh-window mon-fri,02:00-04:00
+
+To revert to the refresh-window declared in snap.yaml:
+
+    $ snapctl set-refresh-window --unset
+`)
+)
+
+func init() {
+	addCommand("set-refresh-window", shortSetRefreshWindowHelp, longSetRefreshWindowHelp, func() command { return &setRefreshWindowCommand{} })
+}
+
+type setRefreshWindowCommand struct {
+	baseCommand
+
+	Unset bool `long:"unset" description:"revert to the refresh window declared in snap.yaml"`
+
+	Positional struct {
+		Window string `positional-arg-name:"<window>"`
+	} `positional-args:"yes"`
+}
+
+func (c *setRefreshWindowCommand) Execute([]string) error {
+	window := c.Positional.Window
+	switch {
+	case c.Unset && window != "":
+		return fmt.Errorf("cannot use --unset with a refresh window")
+	case !c.Unset && window == "":
+		return fmt.Errorf("refresh window or --unset required")
+	}
+	if window != "" {
+		if _, err := timeutil.ParseSchedule(window); err != nil {
+			return fmt.Errorf("cannot parse refresh window: %v", err)
+		}
+	}
+
+	ctx, err := c.ensureContext()
+	if err != nil {
+		return err
+	}
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	instanceName := ctx.InstanceName()
+	ctx.OnDone(func() error {
+		return snapstate.SetRefreshWindow(ctx.State(), instanceName, window)
+	})
+	return nil
+}
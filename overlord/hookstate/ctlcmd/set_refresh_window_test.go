@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type setRefreshWindowSuite struct {
+	testutil.BaseTest
+	state       *state.State
+	mockContext *hookstate.Context
+}
+
+var _ = Suite(&setRefreshWindowSuite{})
+
+func (s *setRefreshWindowSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+
+	task := s.state.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	ctx, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+	s.mockContext = ctx
+}
+
+func (s *setRefreshWindowSuite) refreshWindow(c *C) string {
+	s.state.Lock()
+	defer s.state.Unlock()
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), IsNil)
+	return snapst.RefreshWindow
+}
+
+func (s *setRefreshWindowSuite) TestBadArgs(c *C) {
+	for i, t := range []struct {
+		args []string
+		err  string
+	}{{
+		[]string{"set-refresh-window"},
+		"refresh window or --unset required",
+	}, {
+		[]string{"set-refresh-window", "--unset", "mon,02:00-04:00"},
+		"cannot use --unset with a refresh window",
+	}, {
+		[]string{"set-refresh-window", "mon,25:00"},
+		`cannot parse refresh window: .*`,
+	}} {
+		_, _, err := ctlcmd.Run(s.mockContext, t.args, 0)
+		c.Check(err, ErrorMatches, t.err, Commentf("%d", i))
+	}
+}
+
+func (s *setRefreshWindowSuite) TestSetAndUnset(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set-refresh-window", "mon-fri,02:00-04:00"}, 0)
+	c.Assert(err, IsNil)
+	// nothing changes until the hook is done
+	c.Check(s.refreshWindow(c), Equals, "")
+
+	s.mockContext.Lock()
+	c.Assert(s.mockContext.Done(), IsNil)
+	s.mockContext.Unlock()
+	c.Check(s.refreshWindow(c), Equals, "mon-fri,02:00-04:00")
+
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"set-refresh-window", "--unset"}, 0)
+	c.Assert(err, IsNil)
+	s.mockContext.Lock()
+	c.Assert(s.mockContext.Done(), IsNil)
+	s.mockContext.Unlock()
+	c.Check(s.refreshWindow(c), Equals, "")
+}
+
+func (s *setRefreshWindowSuite) TestNotAllowedForNonRoot(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set-refresh-window", "mon,02:00-04:00"}, 1000)
+	c.Check(err, ErrorMatches, `cannot use "set-refresh-window" with uid 1000, try with sudo`)
+}
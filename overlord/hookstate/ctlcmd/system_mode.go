@@ -29,6 +29,8 @@ import (
 
 type systemModeCommand struct {
 	baseCommand
+
+	ClearFactory bool `long:"clear-factory" description:"clear the factory boot flag for the following boots (gadget only)"`
 }
 
 var shortSystemModeHelp = i18n.G("Get the current system mode and associated details")
@@ -40,23 +42,30 @@ This information includes the mode itself and whether the model snaps have been
 
 Retrieved information can also include "factory mode" details: 'factory: true' declares whether the device booted an image flagged as for factory use. This flag can be set for convenience when building the image. No security sensitive decisions should be based on this bit alone.
 
+The gadget can keep the flag set for a number of boots into run mode after installing the device with 'factory-boots' in gadget.yaml, 'factory-boots' then reports for how many of the following boots the flag remains set. The gadget can end the factory mode earlier with --clear-factory, which clears the flag for the following boots, including when used from the install-device hook.
+
 The output is in YAML format. Example output:
     $ snapctl system-mode
-    system-mode: install
+    system-mode: run
     seed-loaded: true
     factory: true
+    factory-boots: 2
 `)
 
 func init() {
 	addCommand("system-mode", shortSystemModeHelp, longSystemModeHelp, func() command { return &systemModeCommand{} })
 }
 
-var devicestateSystemModeInfoFromState = devicestate.SystemModeInfoFromState
+var (
+	devicestateSystemModeInfoFromState = devicestate.SystemModeInfoFromState
+	devicestateClearFactoryBootFlag    = devicestate.ClearFactoryBootFlag
+)
 
 type systemModeResult struct {
-	SystemMode string `yaml:"system-mode,omitempty"`
-	Seeded     bool   `yaml:"seed-loaded"`
-	Factory    bool   `yaml:"factory,omitempty"`
+	SystemMode   string `yaml:"system-mode,omitempty"`
+	Seeded       bool   `yaml:"seed-loaded"`
+	Factory      bool   `yaml:"factory,omitempty"`
+	FactoryBoots int    `yaml:"factory-boots,omitempty"`
 }
 
 func (c *systemModeCommand) Execute(args []string) error {
@@ -69,6 +78,10 @@ func (c *systemModeCommand) Execute(args []string) error {
 	st.Lock()
 	defer st.Unlock()
 
+	if c.ClearFactory {
+		return devicestateClearFactoryBootFlag(st, context.InstanceName())
+	}
+
 	smi, err := devicestateSystemModeInfoFromState(st)
 	if err != nil {
 		return err
@@ -80,6 +93,7 @@ func (c *systemModeCommand) Execute(args []string) error {
 	}
 	if strutil.ListContains(smi.BootFlags, "factory") {
 		res.Factory = true
+		res.FactoryBoots = smi.FactoryBoots
 	}
 
 	b, err := yaml.Marshal(res)
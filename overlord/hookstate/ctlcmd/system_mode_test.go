@@ -92,6 +92,15 @@ func (s *systemModeSuite) TestSystemMode(c *C) {
 				BootFlags:  []string{"factory"},
 			},
 			stdout: "system-mode: install\nseed-loaded: true\nfactory: true\n",
+		}, {
+			smi: devicestate.SystemModeInfo{
+				Mode:         "run",
+				HasModeenv:   true,
+				Seeded:       true,
+				BootFlags:    []string{"factory"},
+				FactoryBoots: 2,
+			},
+			stdout: "system-mode: run\nseed-loaded: true\nfactory: true\nfactory-boots: 2\n",
 		}, {
 			smi: devicestate.SystemModeInfo{
 				Mode:       "run",
@@ -124,3 +133,44 @@ func (s *systemModeSuite) TestSystemMode(c *C) {
 		}
 	}
 }
+
+func (s *systemModeSuite) TestSystemModeClearFactory(c *C) {
+	s.st.Lock()
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "pc", Revision: snap.R(1), Hook: "install-device"}
+	mockContext, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Check(err, IsNil)
+	s.st.Unlock()
+
+	var called []string
+	clearErr := error(nil)
+	r := ctlcmd.MockDevicestateClearFactoryBootFlag(func(st *state.State, gadgetSnap string) error {
+		// the mocked function requires the state lock,
+		// panic if it is not held
+		st.Unlock()
+		defer st.Lock()
+		called = append(called, gadgetSnap)
+		return clearErr
+	})
+	defer r()
+	r = ctlcmd.MockDevicestateSystemModeInfoFromState(func(*state.State) (*devicestate.SystemModeInfo, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer r()
+
+	stdout, stderr, err := ctlcmd.Run(mockContext, []string{"system-mode", "--clear-factory"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+	c.Check(called, DeepEquals, []string{"pc"})
+
+	clearErr = fmt.Errorf("cannot clear factory boot flag: only the gadget snap can end factory mode")
+	_, _, err = ctlcmd.Run(mockContext, []string{"system-mode", "--clear-factory"}, 0)
+	c.Check(err, ErrorMatches, "cannot clear factory boot flag: only the gadget snap can end factory mode")
+
+	// clearing requires root
+	_, _, err = ctlcmd.Run(mockContext, []string{"system-mode", "--clear-factory"}, 1000)
+	c.Check(err, DeepEquals, &ctlcmd.ForbiddenCommandError{Message: `cannot use "system-mode" with uid 1000, try with sudo`})
+	c.Check(called, HasLen, 2)
+}
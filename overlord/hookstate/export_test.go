@@ -44,3 +44,5 @@ func MockErrtrackerReport(mock func(string, string, string, map[string]string) (
 	errtrackerReport = mock
 	return func() { errtrackerReport = prev }
 }
+
+var HookLimits = hookLimits
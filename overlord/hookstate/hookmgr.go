@@ -24,11 +24,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"gopkg.in/tomb.v2"
@@ -42,11 +44,43 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
 )
 
 type hijackFunc func(ctx *Context) error
 type hijackKey struct{ hook, snap string }
 
+// hookError is returned by run-hook tasks when the hook failed.
+type hookError struct {
+	snap string
+	hook string
+	// exitCode is the exit code of the hook, or -1 if it did not exit
+	// (e.g. it was killed) or was hijacked
+	exitCode int
+	err      error
+}
+
+func (e *hookError) Error() string {
+	return fmt.Sprintf("run hook %q: %v", e.hook, e.err)
+}
+
+// classifyHookError classifies the errors of failed hooks for the task
+// runner.
+func classifyHookError(t *state.Task, err error) *state.ErrorDetails {
+	var hookErr *hookError
+	if !errors.As(err, &hookErr) {
+		return nil
+	}
+	value := map[string]interface{}{
+		"snap-name": hookErr.snap,
+		"hook":      hookErr.hook,
+	}
+	if hookErr.exitCode >= 0 {
+		value["exit-code"] = hookErr.exitCode
+	}
+	return &state.ErrorDetails{Kind: "hook-failed", Value: value}
+}
+
 // HookManager is responsible for the maintenance of hooks in the system state.
 // It runs hooks when they're requested, assuming they're present in the given
 // snap. Otherwise they're skipped with no error.
@@ -127,6 +161,7 @@ func Manager(s *state.State, runner *state.TaskRunner) (*HookManager, error) {
 	}
 
 	runner.AddHandler("run-hook", manager.doRunHook, manager.undoRunHook)
+	runner.AddErrorClassifier(classifyHookError)
 	// Compatibility with snapd between 2.29 and 2.30 in edge only.
 	// We generated a configure-snapd task on core refreshes and
 	// for compatibility we need to handle those.
@@ -407,6 +442,11 @@ func (m *HookManager) runHook(context *Context, snapst *snapstate.SnapState, hoo
 		if hooksup.TrackError {
 			trackHookError(context, output, err)
 		}
+		exitCode := -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
 		err = osutil.OutputErr(output, err)
 		if hooksup.IgnoreError {
 			context.Lock()
@@ -421,7 +461,7 @@ func (m *HookManager) runHook(context *Context, snapst *snapstate.SnapState, hoo
 				return nil
 			}
 
-			return fmt.Errorf("run hook %q: %v", hooksup.Hook, err)
+			return &hookError{snap: hooksup.Snap, hook: hooksup.Hook, exitCode: exitCode, err: err}
 		}
 	}
 
@@ -439,7 +479,13 @@ func (m *HookManager) runHook(context *Context, snapst *snapstate.SnapState, hoo
 }
 
 func runHookImpl(c *Context, tomb *tomb.Tomb) ([]byte, error) {
-	return runHookAndWait(c.InstanceName(), c.SnapRevision(), c.HookName(), c.ID(), c.Timeout(), tomb)
+	c.Lock()
+	limits, err := hookLimits(c)
+	c.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return runHookAndWait(c.InstanceName(), c.SnapRevision(), c.HookName(), c.ID(), limits, tomb)
 }
 
 var runHook = runHookImpl
@@ -478,8 +524,85 @@ func snapCmd() string {
 
 var defaultHookTimeout = 10 * time.Minute
 
-func runHookAndWait(snapName string, revision snap.Revision, hookName, hookContext string, timeout time.Duration, tomb *tomb.Tomb) ([]byte, error) {
-	argv := []string{snapCmd(), "run", "--hook", hookName, "-r", revision.String(), snapName}
+// builtinHookLimits are the resource limits of the classes of hooks when
+// they are not set otherwise.
+var builtinHookLimits = map[string]settings.HookLimits{
+	// the install-device hook can take a while to prepare the device
+	"install-device": {Timeout: 30 * time.Minute},
+}
+
+// hookLimitClass returns the class of the given hook for the purpose of
+// resource limits.
+func hookLimitClass(hookName string) string {
+	if strutil.ListContains(settings.HookLimitClasses, hookName) {
+		return hookName
+	}
+	return "default"
+}
+
+// hookLimits returns the resource limits of the hook of the given context.
+// The limits set for the class of the hook take precedence, then the
+// timeout of the hook setup, then the limits set for all hooks and at last
+// the built-in ones.
+//
+// The context must be locked.
+func hookLimits(c *Context) (settings.HookLimits, error) {
+	st := c.State()
+	class := hookLimitClass(c.HookName())
+	limits, err := settings.HookLimitsForClass(st, class)
+	if err != nil {
+		return limits, err
+	}
+	if limits.Timeout == 0 {
+		limits.Timeout = c.Timeout()
+	}
+
+	var fallbacks []settings.HookLimits
+	if class != "default" {
+		defaults, err := settings.HookLimitsForClass(st, "default")
+		if err != nil {
+			return limits, err
+		}
+		fallbacks = append(fallbacks, defaults)
+	}
+	fallbacks = append(fallbacks, builtinHookLimits[class], settings.HookLimits{Timeout: defaultHookTimeout})
+	for _, fallback := range fallbacks {
+		if limits.Timeout == 0 {
+			limits.Timeout = fallback.Timeout
+		}
+		if limits.MemoryMax == 0 {
+			limits.MemoryMax = fallback.MemoryMax
+		}
+		if limits.CPUQuota == 0 {
+			limits.CPUQuota = fallback.CPUQuota
+		}
+	}
+	return limits, nil
+}
+
+// killedBySignal returns whether the command failing with the given error
+// was killed by sig.
+func killedBySignal(err error, sig syscall.Signal) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	ws, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled() && ws.Signal() == sig
+}
+
+func runHookAndWait(snapName string, revision snap.Revision, hookName, hookContext string, limits settings.HookLimits, tomb *tomb.Tomb) ([]byte, error) {
+	argv := []string{snapCmd(), "run", "--hook", hookName, "-r", revision.String()}
+	// the memory and CPU limits are enforced by the transient scope
+	// created by snap run for the hook
+	if limits.MemoryMax != 0 {
+		argv = append(argv, fmt.Sprintf("--hook-memory-max=%d", limits.MemoryMax))
+	}
+	if limits.CPUQuota != 0 {
+		argv = append(argv, fmt.Sprintf("--hook-cpu-quota=%d", limits.CPUQuota))
+	}
+	argv = append(argv, snapName)
+	timeout := limits.Timeout
 	if timeout == 0 {
 		timeout = defaultHookTimeout
 	}
@@ -494,7 +617,13 @@ func runHookAndWait(snapName string, revision snap.Revision, hookName, hookConte
 		fmt.Sprintf("SNAP_CONTEXT=%s", hookContext),
 	}
 
-	return osutil.RunAndWait(argv, env, timeout, tomb)
+	output, err := osutil.RunAndWait(argv, env, timeout, tomb)
+	if err != nil && limits.MemoryMax != 0 && killedBySignal(err, syscall.SIGKILL) {
+		// the timeout and aborts are reported differently, most
+		// likely the kernel killed the hook when it ran out of memory
+		err = fmt.Errorf("killed, possibly for exceeding its memory limit of %s", strutil.SizeToStr(int64(limits.MemoryMax)))
+	}
+	return output, err
 }
 
 var errtrackerReport = errtracker.Report
@@ -77,6 +77,23 @@ func SetupPreRefreshHook(st *state.State, snapName string) *state.Task {
 	return task
 }
 
+// SetupPostRestoreHook creates the task running the post-restore hook of
+// the snap once its data was restored from the snapshot set with the
+// given id. Failure of the hook causes the restore to be undone.
+func SetupPostRestoreHook(st *state.State, snapName string, setID uint64) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "post-restore",
+		Optional: true,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run post-restore hook of %q snap"), hooksup.Snap)
+	contextData := map[string]interface{}{
+		"snapshot-set-id": setID,
+	}
+	return HookTask(st, summary, hooksup, contextData)
+}
+
 type gateAutoRefreshHookHandler struct {
 	context             *Context
 	refreshAppAwareness bool
@@ -318,5 +335,6 @@ func setupHooks(hookMgr *HookManager) {
 	hookMgr.Register(regexp.MustCompile("^post-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^pre-refresh$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^remove$"), handlerGenerator)
+	hookMgr.Register(regexp.MustCompile("^post-restore$"), handlerGenerator)
 	hookMgr.Register(regexp.MustCompile("^gate-auto-refresh$"), gateAutoRefreshHandlerGenerator)
 }
@@ -35,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/settings"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
 	"github.com/snapcore/snapd/overlord/restart"
@@ -414,6 +415,14 @@ func (s *hookManagerSuite) TestHookTaskHandlesHookError(c *C) {
 	c.Check(s.task.Status(), Equals, state.ErrorStatus)
 	c.Check(s.change.Status(), Equals, state.ErrorStatus)
 	checkTaskLogContains(c, s.task, ".*failed at user request.*")
+	c.Check(s.task.ErrorDetails(), DeepEquals, &state.ErrorDetails{
+		Kind: "hook-failed",
+		Value: map[string]interface{}{
+			"snap-name": "test-snap",
+			"hook":      "configure",
+			"exit-code": float64(1),
+		},
+	})
 
 	c.Check(s.manager.NumRunningHooks(), Equals, 0)
 }
@@ -529,6 +538,97 @@ func (s *hookManagerSuite) TestHookTaskEnforcesDefaultTimeout(c *C) {
 	checkTaskLogContains(c, s.task, `.*exceeded maximum runtime of 150ms`)
 }
 
+func (s *hookManagerSuite) TestHookTaskLimitsFromSettings(c *C) {
+	s.state.Lock()
+	var hooksup hookstate.HookSetup
+	s.task.Get("hook-setup", &hooksup)
+	hooksup.Timeout = time.Hour
+	s.task.Set("hook-setup", &hooksup)
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "hooks.configure.timeout", "150ms")
+	tr.Set("core", "hooks.configure.memory-max", "64MB")
+	tr.Set("core", "hooks.default.cpu-quota", "50%")
+	tr.Commit()
+	s.state.Unlock()
+
+	// Force the snap command to hang
+	cmd := testutil.MockCommand(c, "snap", "while true; do sleep 1; done")
+	defer cmd.Restore()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{
+		"snap", "run", "--hook", "configure", "-r", "1", "--hook-memory-max=64000000", "--hook-cpu-quota=50", "test-snap",
+	}})
+	c.Check(s.mockHandler.Err, ErrorMatches, `.*exceeded maximum runtime of 150ms.*`)
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+}
+
+func (s *hookManagerSuite) TestHookTaskKilledWithMemoryLimit(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "hooks.configure.memory-max", "64MB")
+	tr.Commit()
+	s.state.Unlock()
+
+	// the hook gets killed as if it ran out of memory
+	cmd := testutil.MockCommand(c, "snap", "kill -9 $$")
+	defer cmd.Restore()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.mockHandler.Err, ErrorMatches, `killed, possibly for exceeding its memory limit of 64MB`)
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	checkTaskLogContains(c, s.task, `.*killed, possibly for exceeding its memory limit of 64MB`)
+	// the hook did not exit
+	c.Check(s.task.ErrorDetails(), DeepEquals, &state.ErrorDetails{
+		Kind: "hook-failed",
+		Value: map[string]interface{}{
+			"snap-name": "test-snap",
+			"hook":      "configure",
+		},
+	})
+}
+
+func (s *hookManagerSuite) TestHookLimits(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	limitsFor := func(hook string, timeout time.Duration) settings.HookLimits {
+		hooksup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: hook, Timeout: timeout}
+		ctx, err := hookstate.NewContext(s.task, s.state, hooksup, nil, "")
+		c.Assert(err, IsNil)
+		limits, err := hookstate.HookLimits(ctx)
+		c.Assert(err, IsNil)
+		return limits
+	}
+
+	// built-in defaults
+	c.Check(limitsFor("configure", 5*time.Minute), Equals, settings.HookLimits{Timeout: 5 * time.Minute})
+	c.Check(limitsFor("install-device", 0), Equals, settings.HookLimits{Timeout: 30 * time.Minute})
+	c.Check(limitsFor("prepare-device", 0), Equals, settings.HookLimits{Timeout: 10 * time.Minute})
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "hooks.default.timeout", "1m")
+	tr.Set("core", "hooks.default.memory-max", "100MB")
+	tr.Set("core", "hooks.install-device.memory-max", "1GB")
+	tr.Set("core", "hooks.install-device.cpu-quota", "200%")
+	tr.Commit()
+
+	// the timeout of the hook setup wins over the default settings
+	c.Check(limitsFor("configure", 5*time.Minute), Equals, settings.HookLimits{Timeout: 5 * time.Minute, MemoryMax: 100 * 1000 * 1000})
+	c.Check(limitsFor("install-device", 0), Equals, settings.HookLimits{Timeout: time.Minute, MemoryMax: 1000 * 1000 * 1000, CPUQuota: 200})
+	c.Check(limitsFor("prepare-device", 0), Equals, settings.HookLimits{Timeout: time.Minute, MemoryMax: 100 * 1000 * 1000})
+}
+
 func (s *hookManagerSuite) TestHookTaskEnforcedTimeoutWithIgnoreError(c *C) {
 	var hooksup hookstate.HookSetup
 
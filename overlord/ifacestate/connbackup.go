@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/ifacestate/schema"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// ConnectionIntent records a connection that was manually established, or
+// an automatic connection that was manually disconnected, such that it can
+// be re-applied on another installation of the snaps.
+type ConnectionIntent struct {
+	Plug      interfaces.PlugRef
+	Slot      interfaces.SlotRef
+	Interface string
+	// Undesired is set for automatic connections that were manually
+	// disconnected.
+	Undesired bool
+}
+
+// SkippedConnectionIntent is a connection intent that was not re-applied,
+// along with the reason.
+type SkippedConnectionIntent struct {
+	*ConnectionIntent
+	Reason string
+}
+
+// portableSnapName returns the name of the snap as it is referred to in
+// connection intents, which are not bound to the system snap of the device.
+func portableSnapName(snapName string) string {
+	if snapName == SystemSnapName() {
+		return "system"
+	}
+	return snapName
+}
+
+// ExportConnections returns the intents of the manual connections and
+// disconnections involving the given snap, or of all of them if snapName
+// is empty. Automatic connections are left out as they are established
+// again when the snaps are installed.
+// The state must be locked by the caller.
+func ExportConnections(st *state.State, snapName string) ([]*ConnectionIntent, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+
+	intents := make([]*ConnectionIntent, 0, len(conns))
+	for id, cstate := range conns {
+		if cstate.HotplugGone {
+			// the device and the slot are gone
+			continue
+		}
+		if cstate.Auto && !cstate.Undesired {
+			continue
+		}
+		cref, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, err
+		}
+		if snapName != "" && cref.PlugRef.Snap != snapName && cref.SlotRef.Snap != snapName {
+			continue
+		}
+		cref.PlugRef.Snap = portableSnapName(cref.PlugRef.Snap)
+		cref.SlotRef.Snap = portableSnapName(cref.SlotRef.Snap)
+		intents = append(intents, &ConnectionIntent{
+			Plug:      cref.PlugRef,
+			Slot:      cref.SlotRef,
+			Interface: cstate.Interface,
+			Undesired: cstate.Undesired,
+		})
+	}
+	sort.Slice(intents, func(i, j int) bool {
+		icref := interfaces.ConnRef{PlugRef: intents[i].Plug, SlotRef: intents[i].Slot}
+		jcref := interfaces.ConnRef{PlugRef: intents[j].Plug, SlotRef: intents[j].Slot}
+		return icref.SortsBefore(&jcref)
+	})
+	return intents, nil
+}
+
+// RestoreConnections returns the task sets re-applying the given connection
+// intents. Connections are checked against the policy when they are
+// established, as for any manual connection. Disconnections of automatic
+// connections that are not established are recorded right away, such that
+// the connections are not established when the snaps get installed.
+// Intents that are applied already, or whose plug or slot is missing, are
+// skipped and returned along with the reason.
+// The state must be locked by the caller.
+func RestoreConnections(st *state.State, repo *interfaces.Repository, intents []*ConnectionIntent) ([]*state.TaskSet, []*SkippedConnectionIntent, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tss []*state.TaskSet
+	var skipped []*SkippedConnectionIntent
+	skip := func(intent *ConnectionIntent, format string, a ...interface{}) {
+		skipped = append(skipped, &SkippedConnectionIntent{
+			ConnectionIntent: intent,
+			Reason:           fmt.Sprintf(format, a...),
+		})
+	}
+	recorded := false
+	for _, intent := range intents {
+		cref := &interfaces.ConnRef{
+			PlugRef: interfaces.PlugRef{Snap: RemapSnapFromRequest(intent.Plug.Snap), Name: intent.Plug.Name},
+			SlotRef: interfaces.SlotRef{Snap: RemapSnapFromRequest(intent.Slot.Snap), Name: intent.Slot.Name},
+		}
+		cstate, ok := conns[cref.ID()]
+		connected := ok && !cstate.Undesired && !cstate.HotplugGone
+
+		if intent.Undesired {
+			switch {
+			case ok && cstate.Undesired:
+				skip(intent, "already disconnected")
+			case connected:
+				conn, err := repo.Connection(cref)
+				if err != nil {
+					return nil, nil, err
+				}
+				ts, err := Disconnect(st, conn)
+				if err != nil {
+					return nil, nil, err
+				}
+				ts.JoinLane(st.NewLane())
+				tss = append(tss, ts)
+			default:
+				conns[cref.ID()] = &schema.ConnState{
+					Interface: intent.Interface,
+					Auto:      true,
+					Undesired: true,
+				}
+				recorded = true
+			}
+			continue
+		}
+
+		if connected {
+			skip(intent, "already connected")
+			continue
+		}
+		plug := repo.Plug(cref.PlugRef.Snap, cref.PlugRef.Name)
+		if plug == nil {
+			skip(intent, "snap %q has no plug named %q", cref.PlugRef.Snap, cref.PlugRef.Name)
+			continue
+		}
+		slot := repo.Slot(cref.SlotRef.Snap, cref.SlotRef.Name)
+		if slot == nil {
+			skip(intent, "snap %q has no slot named %q", cref.SlotRef.Snap, cref.SlotRef.Name)
+			continue
+		}
+		if plug.Interface != intent.Interface || slot.Interface != intent.Interface {
+			skip(intent, "plug or slot no longer use interface %q", intent.Interface)
+			continue
+		}
+		ts, err := Connect(st, cref.PlugRef.Snap, cref.PlugRef.Name, cref.SlotRef.Snap, cref.SlotRef.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		ts.JoinLane(st.NewLane())
+		tss = append(tss, ts)
+	}
+	if recorded {
+		setConns(st, conns)
+	}
+	return tss, skipped, nil
+}
@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+)
+
+func (s *interfaceManagerSuite) TestExportConnections(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test",
+		},
+		"consumer:otherplug core:otherslot": map[string]interface{}{
+			"interface": "test2",
+		},
+		"consumer2:plug producer:slot": map[string]interface{}{
+			"interface": "test", "auto": true,
+		},
+		"consumer2:plug producer2:slot": map[string]interface{}{
+			"interface": "test", "auto": true, "undesired": true,
+		},
+		"consumer2:plug core:hotplugslot": map[string]interface{}{
+			"interface": "test", "hotplug-gone": true, "hotplug-key": "1234",
+		},
+	})
+
+	intents, err := ifacestate.ExportConnections(s.state, "")
+	c.Assert(err, IsNil)
+	c.Check(intents, DeepEquals, []*ifacestate.ConnectionIntent{{
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "otherplug"},
+		Slot:      interfaces.SlotRef{Snap: "system", Name: "otherslot"},
+		Interface: "test2",
+	}, {
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+	}, {
+		Plug:      interfaces.PlugRef{Snap: "consumer2", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer2", Name: "slot"},
+		Interface: "test",
+		Undesired: true,
+	}})
+
+	intents, err = ifacestate.ExportConnections(s.state, "producer2")
+	c.Assert(err, IsNil)
+	c.Check(intents, DeepEquals, []*ifacestate.ConnectionIntent{{
+		Plug:      interfaces.PlugRef{Snap: "consumer2", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer2", Name: "slot"},
+		Interface: "test",
+		Undesired: true,
+	}})
+
+	intents, err = ifacestate.ExportConnections(s.state, "other")
+	c.Assert(err, IsNil)
+	c.Check(intents, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestRestoreConnections(c *C) {
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	s.mockSnap(c, producerYaml)
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	intents := []*ifacestate.ConnectionIntent{{
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+	}, {
+		Plug:      interfaces.PlugRef{Snap: "consumer2", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+		Undesired: true,
+	}, {
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "otherplug"},
+		Slot:      interfaces.SlotRef{Snap: "system", Name: "otherslot"},
+		Interface: "test2",
+	}, {
+		Plug:      interfaces.PlugRef{Snap: "missing", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+	}, {
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "otherplug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+	}}
+
+	tss, skipped, err := ifacestate.RestoreConnections(s.state, mgr.Repository(), intents)
+	c.Assert(err, IsNil)
+	c.Assert(tss, HasLen, 1)
+	connectTask := tss[0].Tasks()[2]
+	c.Check(connectTask.Kind(), Equals, "connect")
+	var plug interfaces.PlugRef
+	c.Assert(connectTask.Get("plug", &plug), IsNil)
+	c.Check(plug, Equals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
+
+	c.Assert(skipped, HasLen, 3)
+	c.Check(skipped[0].ConnectionIntent, Equals, intents[2])
+	c.Check(skipped[0].Reason, Equals, `snap "core" has no slot named "otherslot"`)
+	c.Check(skipped[1].ConnectionIntent, Equals, intents[3])
+	c.Check(skipped[1].Reason, Equals, `snap "missing" has no plug named "plug"`)
+	c.Check(skipped[2].ConnectionIntent, Equals, intents[4])
+	c.Check(skipped[2].Reason, Equals, `plug or slot no longer use interface "test"`)
+
+	// the disconnection is recorded right away
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer2:plug producer:slot": map[string]interface{}{
+			"interface": "test", "auto": true, "undesired": true,
+		},
+	})
+
+	// restoring it again is a no-op
+	_, skipped, err = ifacestate.RestoreConnections(s.state, mgr.Repository(), intents[1:2])
+	c.Assert(err, IsNil)
+	c.Assert(skipped, HasLen, 1)
+	c.Check(skipped[0].Reason, Equals, "already disconnected")
+}
+
+func (s *interfaceManagerSuite) TestRestoreConnectionsEstablished(c *C) {
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, consumer2Yaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test",
+		},
+		"consumer2:plug producer:slot": map[string]interface{}{
+			"interface": "test", "auto": true,
+		},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	intents := []*ifacestate.ConnectionIntent{{
+		Plug:      interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+	}, {
+		Plug:      interfaces.PlugRef{Snap: "consumer2", Name: "plug"},
+		Slot:      interfaces.SlotRef{Snap: "producer", Name: "slot"},
+		Interface: "test",
+		Undesired: true,
+	}}
+
+	tss, skipped, err := ifacestate.RestoreConnections(s.state, mgr.Repository(), intents)
+	c.Assert(err, IsNil)
+	c.Assert(tss, HasLen, 1)
+	disconnectTask := tss[0].Tasks()[len(tss[0].Tasks())-1]
+	c.Check(disconnectTask.Kind(), Equals, "disconnect")
+	var plug interfaces.PlugRef
+	c.Assert(disconnectTask.Get("plug", &plug), IsNil)
+	c.Check(plug, Equals, interfaces.PlugRef{Snap: "consumer2", Name: "plug"})
+
+	c.Assert(skipped, HasLen, 1)
+	c.Check(skipped[0].ConnectionIntent, Equals, intents[0])
+	c.Check(skipped[0].Reason, Equals, "already connected")
+}
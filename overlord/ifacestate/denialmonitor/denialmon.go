@@ -0,0 +1,101 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package denialmonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/interfaces/denials"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type Interface interface {
+	Run() error
+	Stop() error
+}
+
+type DenialFunc func(denial *denials.Denial)
+
+// journalctl streams the kernel and audit messages logged to the journal
+// from now on, in the JSON format. The journal is used as the kernel and
+// audit logs are not kept in files on all systems, e.g. on Ubuntu Core.
+var journalctl = func() (io.ReadCloser, error) {
+	return osutil.StreamCommand("journalctl", "--output=json", "--no-pager",
+		"--follow", "--lines=0", "_TRANSPORT=kernel", "_TRANSPORT=audit")
+}
+
+// Monitor follows the kernel and audit messages logged to the journal and
+// reports the AppArmor denials of snaps among them.
+type Monitor struct {
+	tomb     tomb.Tomb
+	onDenial DenialFunc
+
+	r io.ReadCloser
+}
+
+func New(onDenial DenialFunc) Interface {
+	return &Monitor{
+		onDenial: onDenial,
+	}
+}
+
+// Run starts following the journal, skipping the denials logged so far, and
+// starts a new goroutine reporting the denials logged from then on. It
+// returns immediately. The goroutine must be stopped by calling Stop()
+// method.
+func (m *Monitor) Run() error {
+	r, err := journalctl()
+	if err != nil {
+		return fmt.Errorf("cannot follow the journal: %v", err)
+	}
+	m.r = r
+	m.tomb.Go(func() error {
+		dec := json.NewDecoder(r)
+		for {
+			var entry systemd.Log
+			if err := dec.Decode(&entry); err != nil {
+				select {
+				case <-m.tomb.Dying():
+					// reading fails once the reader is closed
+					return nil
+				default:
+				}
+				return fmt.Errorf("cannot read the journal: %v", err)
+			}
+			if denial, ok := denials.Parse(entry.Message()); ok {
+				m.onDenial(denial)
+			}
+		}
+	})
+	return nil
+}
+
+// Stop stops the monitor and waits for its goroutine to finish.
+func (m *Monitor) Stop() error {
+	m.tomb.Kill(nil)
+	// the process is killed, which also unblocks the reading
+	m.r.Close()
+	return m.tomb.Wait()
+}
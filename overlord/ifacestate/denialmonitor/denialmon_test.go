@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package denialmonitor_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/denials"
+	"github.com/snapcore/snapd/overlord/ifacestate/denialmonitor"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func TestDenialMonitor(t *testing.T) { TestingT(t) }
+
+type denialMonitorSuite struct {
+	testutil.BaseTest
+
+	journal *io.PipeWriter
+	denials chan *denials.Denial
+}
+
+var _ = Suite(&denialMonitorSuite{})
+
+func (s *denialMonitorSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.denials = make(chan *denials.Denial, 10)
+
+	r, w := io.Pipe()
+	s.journal = w
+	s.AddCleanup(denialmonitor.MockJournalctl(func() (io.ReadCloser, error) {
+		return r, nil
+	}))
+}
+
+func (s *denialMonitorSuite) onDenial(d *denials.Denial) {
+	s.denials <- d
+}
+
+func denialMessage(snapName, path string) string {
+	return fmt.Sprintf(`audit: type=1400 audit(1673352000.123:42): apparmor="DENIED" operation="open" profile="snap.%s.app" name="%s" pid=1 comm="app" requested_mask="r" denied_mask="r"`, snapName, path)
+}
+
+func (s *denialMonitorSuite) logToJournal(c *C, entry map[string]interface{}) {
+	data, err := json.Marshal(entry)
+	c.Assert(err, IsNil)
+	_, err = s.journal.Write(append(data, '\n'))
+	c.Assert(err, IsNil)
+}
+
+func (s *denialMonitorSuite) waitDenial(c *C) *denials.Denial {
+	select {
+	case d := <-s.denials:
+		return d
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for denial")
+	}
+	return nil
+}
+
+func (s *denialMonitorSuite) checkNoDenial(c *C) {
+	select {
+	case d := <-s.denials:
+		c.Fatalf("unexpected denial: %+v", d)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func (s *denialMonitorSuite) TestRunJournalError(c *C) {
+	s.AddCleanup(denialmonitor.MockJournalctl(func() (io.ReadCloser, error) {
+		return nil, errors.New("boom")
+	}))
+	mon := denialmonitor.New(s.onDenial)
+	c.Assert(mon.Run(), ErrorMatches, "cannot follow the journal: boom")
+}
+
+func (s *denialMonitorSuite) TestReportsDenials(c *C) {
+	mon := denialmonitor.New(s.onDenial)
+	c.Assert(mon.Run(), IsNil)
+
+	s.logToJournal(c, map[string]interface{}{
+		"_TRANSPORT": "kernel",
+		"MESSAGE":    "usb 1-1: new high-speed USB device",
+	})
+	s.logToJournal(c, map[string]interface{}{
+		"_TRANSPORT": "kernel",
+		"MESSAGE":    denialMessage("foo", "/dev/video0"),
+	})
+	d := s.waitDenial(c)
+	c.Check(d.Snap, Equals, "foo")
+	c.Check(d.Interface, Equals, "camera")
+
+	// as logged by the audit subsystem
+	s.logToJournal(c, map[string]interface{}{
+		"_TRANSPORT": "audit",
+		"MESSAGE":    denialMessage("bar", "/dev/kvm"),
+	})
+	d = s.waitDenial(c)
+	c.Check(d.Snap, Equals, "bar")
+	c.Check(d.Interface, Equals, "kvm")
+
+	// messages with non-UTF8 bytes are logged as arrays of bytes
+	msg := []int{}
+	for _, b := range []byte(denialMessage("baz", "/dev/video0")) {
+		msg = append(msg, int(b))
+	}
+	s.logToJournal(c, map[string]interface{}{
+		"_TRANSPORT": "kernel",
+		"MESSAGE":    msg,
+	})
+	c.Check(s.waitDenial(c).Snap, Equals, "baz")
+	s.checkNoDenial(c)
+
+	c.Assert(mon.Stop(), IsNil)
+}
+
+func (s *denialMonitorSuite) TestJournalEnds(c *C) {
+	mon := denialmonitor.New(s.onDenial)
+	c.Assert(mon.Run(), IsNil)
+
+	s.logToJournal(c, map[string]interface{}{
+		"MESSAGE": denialMessage("foo", "/dev/video0"),
+	})
+	c.Check(s.waitDenial(c).Snap, Equals, "foo")
+	// journalctl exited
+	s.journal.Close()
+	select {
+	case <-mon.(*denialmonitor.Monitor).Dead():
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout waiting for the monitor to stop")
+	}
+
+	c.Assert(mon.Stop(), ErrorMatches, "cannot read the journal: EOF")
+}
@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/interfaces/denials"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/ifacestate/denialmonitor"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/apparmor"
+)
+
+var (
+	// denials of the same snap attributed to the same interface are
+	// recorded at most once per denialRateLimitInterval, the notice is
+	// repeated at most once per denialNoticeRepeatAfter
+	denialRateLimitInterval = 10 * time.Second
+	denialNoticeRepeatAfter = time.Hour
+
+	createDenialMonitor = denialmonitor.New
+	denialsTimeNow      = time.Now
+)
+
+func (m *InterfaceManager) ensureDenialMonitor() {
+	m.denialMonMu.Lock()
+	defer m.denialMonMu.Unlock()
+	if m.denialMonitorDisabled || m.denialMon != nil {
+		return
+	}
+	if apparmor.ProbedLevel() == apparmor.Unsupported {
+		// nothing is denied by AppArmor then
+		return
+	}
+	mon := createDenialMonitor(m.denialObserved)
+	if err := mon.Run(); err != nil {
+		logger.Noticef("Cannot start denial monitor: %v", err)
+		m.denialMonitorDisabled = true
+		return
+	}
+	m.denialMon = mon
+}
+
+func (m *InterfaceManager) stopDenialMonitor() {
+	m.denialMonMu.Lock()
+	defer m.denialMonMu.Unlock()
+	if m.denialMon == nil {
+		return
+	}
+	if err := m.denialMon.Stop(); err != nil {
+		logger.Noticef("Cannot stop denial monitor: %s", err)
+	}
+	m.denialMon = nil
+}
+
+// DisableDenialMonitor disables the instantiation of the denial monitor,
+// but has no effect if it is already running; it should be called after
+// creating InterfaceManager, before first Ensure.
+// This method is meant for tests only.
+func (m *InterfaceManager) DisableDenialMonitor() {
+	m.denialMonMu.Lock()
+	defer m.denialMonMu.Unlock()
+	m.denialMonitorDisabled = true
+}
+
+// denialObserved records a denial of a snap as a notice, subject to rate
+// limiting.
+func (m *InterfaceManager) denialObserved(denial *denials.Denial) {
+	key := denial.Key()
+	now := denialsTimeNow()
+	allowed, suppressed := m.denialLimiter.Allow(key, now)
+	if !allowed {
+		return
+	}
+
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, denial.Snap, &snapst); err != nil || !snapst.IsInstalled() {
+		// a snap removed in the meantime
+		return
+	}
+
+	data := denial.Data()
+	if suppressed > 0 {
+		data["suppressed"] = strconv.Itoa(suppressed)
+	}
+	_, err := st.AddNotice(state.InterfaceDenialNotice, key, &state.AddNoticeOptions{
+		Data:        data,
+		RepeatAfter: denialNoticeRepeatAfter,
+		Time:        now,
+	})
+	if err != nil {
+		logger.Noticef("Cannot record denial of snap %q: %v", denial.Snap, err)
+	}
+}
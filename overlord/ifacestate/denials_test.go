@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/denials"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/ifacestate/denialmonitor"
+	"github.com/snapcore/snapd/overlord/state"
+	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
+)
+
+type denialMonitorMock struct {
+	onDenial denialmonitor.DenialFunc
+	running  bool
+	runErr   error
+}
+
+func (m *denialMonitorMock) Run() error {
+	if m.runErr != nil {
+		return m.runErr
+	}
+	m.running = true
+	return nil
+}
+
+func (m *denialMonitorMock) Stop() error {
+	m.running = false
+	return nil
+}
+
+func (s *interfaceManagerSuite) mockDenialMonitor(c *C) *denialMonitorMock {
+	s.AddCleanup(apparmor_sandbox.MockLevel(apparmor_sandbox.Full))
+	mon := &denialMonitorMock{}
+	s.AddCleanup(ifacestate.MockCreateDenialMonitor(func(onDenial denialmonitor.DenialFunc) denialmonitor.Interface {
+		mon.onDenial = onDenial
+		return mon
+	}))
+	return mon
+}
+
+func (s *interfaceManagerSuite) TestDenialMonitorRunning(c *C) {
+	mon := s.mockDenialMonitor(c)
+	mgr := s.manager(c)
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(mon.running, Equals, true)
+
+	mgr.Stop()
+	c.Check(mon.running, Equals, false)
+}
+
+func (s *interfaceManagerSuite) TestDenialMonitorNoAppArmor(c *C) {
+	mon := s.mockDenialMonitor(c)
+	s.AddCleanup(apparmor_sandbox.MockLevel(apparmor_sandbox.Unsupported))
+	mgr := s.manager(c)
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(mon.running, Equals, false)
+}
+
+func (s *interfaceManagerSuite) TestDenialMonitorRunError(c *C) {
+	mon := s.mockDenialMonitor(c)
+	mon.runErr = errors.New("cannot follow the journal: boom")
+	mgr := s.manager(c)
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(mon.running, Equals, false)
+
+	// not retried
+	mon.runErr = nil
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(mon.running, Equals, false)
+}
+
+func (s *interfaceManagerSuite) TestDenialMonitorDisabled(c *C) {
+	mon := s.mockDenialMonitor(c)
+	mgr := s.manager(c)
+	mgr.DisableDenialMonitor()
+
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(mon.running, Equals, false)
+}
+
+func (s *interfaceManagerSuite) TestDenialMonitorAddsNotices(c *C) {
+	mon := s.mockDenialMonitor(c)
+	s.mockSnap(c, consumerYaml)
+	mgr := s.manager(c)
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Assert(mon.running, Equals, true)
+
+	now := time.Now()
+	s.AddCleanup(ifacestate.MockDenialsTimeNow(func() time.Time { return now }))
+
+	denial := func(snapName, path string) *denials.Denial {
+		d, ok := denials.Parse(`apparmor="DENIED" operation="open" profile="snap.` + snapName + `.app" name="` + path + `" pid=1 comm="app" requested_mask="r" denied_mask="r"`)
+		c.Assert(ok, Equals, true)
+		return d
+	}
+
+	mon.onDenial(denial("consumer", "/dev/video0"))
+	// rate-limited
+	now = now.Add(time.Second)
+	mon.onDenial(denial("consumer", "/dev/video1"))
+	mon.onDenial(denial("consumer", "/dev/video2"))
+	// snaps which are not installed are ignored
+	mon.onDenial(denial("other", "/dev/video0"))
+
+	s.state.Lock()
+	notices := s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.InterfaceDenialNotice}})
+	s.state.Unlock()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Key(), Equals, "consumer/camera")
+	c.Check(notices[0].Occurrences(), Equals, 1)
+	c.Check(notices[0].LastData()["name"], Equals, "/dev/video0")
+
+	now = now.Add(time.Minute)
+	mon.onDenial(denial("consumer", "/dev/video3"))
+
+	s.state.Lock()
+	notices = s.state.Notices(nil)
+	s.state.Unlock()
+	c.Assert(notices, HasLen, 1)
+	c.Check(notices[0].Occurrences(), Equals, 2)
+	c.Check(notices[0].LastData(), DeepEquals, map[string]string{
+		"snap":        "consumer",
+		"app":         "app",
+		"interface":   "camera",
+		"profile":     "snap.consumer.app",
+		"operation":   "open",
+		"name":        "/dev/video3",
+		"denied-mask": "r",
+		"suppressed":  "2",
+	})
+}
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+const migrateConnectionsChangeKind = "migrate-connections"
+
+// warnDeprecatedConnection adds a warning when the plug of the given
+// connection uses a deprecated interface.
+func (m *InterfaceManager) warnDeprecatedConnection(connRef *interfaces.ConnRef, ifaceName string) {
+	iface := m.repo.Interface(ifaceName)
+	if iface == nil {
+		return
+	}
+	replacement := interfaces.StaticInfoOf(iface).DeprecatedBy
+	if replacement == "" {
+		return
+	}
+	m.state.Warnf("snap %q uses deprecated interface %q with plug %q, it should use interface %q instead",
+		connRef.PlugRef.Snap, ifaceName, connRef.PlugRef.Name, replacement)
+}
+
+// warnDeprecatedConnections adds a warning for each established connection
+// of a deprecated interface.
+func (m *InterfaceManager) warnDeprecatedConnections() error {
+	conns, err := getConns(m.state)
+	if err != nil {
+		return err
+	}
+	for id, cstate := range conns {
+		if cstate.Undesired || cstate.HotplugGone {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		m.warnDeprecatedConnection(connRef, cstate.Interface)
+	}
+	return nil
+}
+
+// ensureDeprecatedConnectionsMigrated creates a change moving connections
+// of deprecated interfaces to their replacement when the
+// core.interfaces.migrate-deprecated option is set. A connection is only
+// migrated when a mapping to a plug and slot of the replacement interface
+// exists.
+func (m *InterfaceManager) ensureDeprecatedConnectionsMigrated() error {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	var migrate bool
+	tr := config.NewTransaction(st)
+	if err := tr.GetMaybe("core", "interfaces.migrate-deprecated", &migrate); err != nil {
+		return err
+	}
+	if !migrate {
+		return nil
+	}
+	for _, chg := range st.Changes() {
+		if chg.Kind() == migrateConnectionsChangeKind && !chg.IsReady() {
+			return nil
+		}
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(conns))
+	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var chg *state.Change
+	for _, id := range ids {
+		cstate := conns[id]
+		if cstate.Undesired || cstate.HotplugGone || m.migrationAttempted[id] {
+			continue
+		}
+		iface := m.repo.Interface(cstate.Interface)
+		if iface == nil || interfaces.StaticInfoOf(iface).DeprecatedBy == "" {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		conn, err := m.repo.Connection(connRef)
+		if err != nil {
+			// not active, e.g. the snap is disabled
+			continue
+		}
+		plug := m.repo.Plug(connRef.PlugRef.Snap, connRef.PlugRef.Name)
+		slot := m.repo.Slot(connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		newPlug, newSlot, err := interfaces.MigrateConnection(iface, plug, slot)
+		if err != nil {
+			logger.Noticef("cannot migrate connection %s: %v", id, err)
+			m.migrationAttempted[id] = true
+			continue
+		}
+		if newPlug == nil {
+			// no mapping, the connection is kept
+			m.migrationAttempted[id] = true
+			continue
+		}
+
+		connectTs, err := Connect(st, newPlug.Snap.InstanceName(), newPlug.Name, newSlot.Snap.InstanceName(), newSlot.Name)
+		var conflictErr *snapstate.ChangeConflictError
+		var alreadyErr *ErrAlreadyConnected
+		switch {
+		case errors.As(err, &conflictErr):
+			// try again once the other change is done
+			continue
+		case errors.As(err, &alreadyErr):
+			// only the old connection needs to go
+		case err != nil:
+			logger.Noticef("cannot migrate connection %s: %v", id, err)
+			m.migrationAttempted[id] = true
+			continue
+		}
+		disconnectTs, err := Disconnect(st, conn)
+		if err != nil {
+			logger.Noticef("cannot migrate connection %s: %v", id, err)
+			continue
+		}
+		m.migrationAttempted[id] = true
+
+		if chg == nil {
+			chg = st.NewChange(migrateConnectionsChangeKind, "Migrate connections of deprecated interfaces")
+		}
+		if connectTs != nil {
+			disconnectTs.WaitAll(connectTs)
+			chg.AddAll(connectTs)
+		}
+		chg.AddAll(disconnectTs)
+		logger.Noticef("migrating connection %s of deprecated interface %q to %s:%s", id, cstate.Interface, newSlot.Snap.InstanceName(), newSlot.Name)
+	}
+	if chg != nil {
+		st.EnsureBefore(0)
+	}
+	return nil
+}
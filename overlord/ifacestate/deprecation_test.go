@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var deprecatedConsumerYaml = `
+name: consumer
+version: 1
+plugs:
+ plug:
+  interface: test
+ newplug:
+  interface: test2
+`
+
+var deprecatedProducerYaml = `
+name: producer
+version: 1
+slots:
+ slot:
+  interface: test
+ newslot:
+  interface: test2
+`
+
+func (s *interfaceManagerSuite) mockDeprecatedIfaces() {
+	s.mockIfaces(&ifacetest.TestInterface{
+		InterfaceName:       "test",
+		InterfaceStaticInfo: interfaces.StaticInfo{DeprecatedBy: "test2"},
+	}, &ifacetest.TestInterface{InterfaceName: "test2"})
+}
+
+func (s *interfaceManagerSuite) TestDeprecatedConnectionWarning(c *C) {
+	s.mockDeprecatedIfaces()
+	s.mockSnap(c, deprecatedConsumerYaml)
+	s.mockSnap(c, deprecatedProducerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Equals, `snap "consumer" uses deprecated interface "test" with plug "plug", it should use interface "test2" instead`)
+}
+
+func (s *interfaceManagerSuite) TestMigrateDeprecatedConnectionsDisabled(c *C) {
+	s.mockDeprecatedIfaces()
+	s.mockSnap(c, deprecatedConsumerYaml)
+	s.mockSnap(c, deprecatedProducerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+
+	s.manager(c)
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestMigrateDeprecatedConnections(c *C) {
+	s.MockModel(c, nil)
+	s.mockDeprecatedIfaces()
+	s.mockSnap(c, deprecatedConsumerYaml)
+	s.mockSnap(c, deprecatedProducerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "interfaces.migrate-deprecated", true)
+	tr.Commit()
+	s.state.Unlock()
+
+	s.manager(c)
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	changes := s.state.Changes()
+	c.Assert(changes, HasLen, 1)
+	chg := changes[0]
+	c.Check(chg.Kind(), Equals, "migrate-connections")
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+
+	// the old connection is remembered as undesired so that it is not
+	// auto-connected again
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:newplug producer:newslot": map[string]interface{}{"interface": "test2"},
+		"consumer:plug producer:slot":       map[string]interface{}{"interface": "test", "auto": true, "undesired": true},
+	})
+
+	// the migration is not attempted again
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+}
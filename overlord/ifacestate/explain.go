@@ -0,0 +1,128 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate
+
+import (
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/policy"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ConnectionExplanation explains whether a plug can be connected to a
+// slot, manually and automatically, according to the declarations.
+type ConnectionExplanation struct {
+	Plug      interfaces.PlugRef
+	Slot      interfaces.SlotRef
+	Interface string
+	// Connected is set if the plug is connected to the slot.
+	Connected bool
+	// Unasserted is set if either snap has no snap-declaration, as
+	// when installed with --dangerous, manual connections are then
+	// not checked.
+	Unasserted     bool
+	Connection     *policy.Explanation
+	AutoConnection *policy.Explanation
+}
+
+// ExplainConnections explains whether the plugs and slots of the given
+// snap can be connected, manually and automatically, to each of the
+// slots and plugs of the same interface in the system. If name is not
+// empty only the plug or slot with that name is considered.
+// The state must be locked by the caller.
+func (m *InterfaceManager) ExplainConnections(snapName, name string) ([]*ConnectionExplanation, error) {
+	deviceCtx, err := snapstate.DeviceCtx(m.state, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	checker, err := newConnectChecker(m.state, deviceCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	var expls []*ConnectionExplanation
+	explain := func(plug *snap.PlugInfo, slot *snap.SlotInfo) error {
+		expl, err := m.explainConnection(checker, plug, slot)
+		if err != nil {
+			return err
+		}
+		expls = append(expls, expl)
+		return nil
+	}
+	for _, plug := range m.repo.Plugs(snapName) {
+		if name != "" && plug.Name != name {
+			continue
+		}
+		for _, slot := range m.repo.AllSlots(plug.Interface) {
+			if err := explain(plug, slot); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, slot := range m.repo.Slots(snapName) {
+		if name != "" && slot.Name != name {
+			continue
+		}
+		for _, plug := range m.repo.AllPlugs(slot.Interface) {
+			if plug.Snap.InstanceName() == snapName {
+				// explained already from the plug side
+				continue
+			}
+			if err := explain(plug, slot); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return expls, nil
+}
+
+func (m *InterfaceManager) explainConnection(checker *connectChecker, plugInfo *snap.PlugInfo, slotInfo *snap.SlotInfo) (*ConnectionExplanation, error) {
+	connRef := interfaces.NewConnRef(plugInfo, slotInfo)
+	expl := &ConnectionExplanation{
+		Plug:      connRef.PlugRef,
+		Slot:      connRef.SlotRef,
+		Interface: plugInfo.Interface,
+	}
+
+	var plug *interfaces.ConnectedPlug
+	var slot *interfaces.ConnectedSlot
+	if conn, err := m.repo.Connection(connRef); err == nil {
+		// use the attributes of the connection
+		expl.Connected = true
+		plug = conn.Plug
+		slot = conn.Slot
+	} else {
+		plug = interfaces.NewConnectedPlug(plugInfo, nil, nil)
+		slot = interfaces.NewConnectedSlot(slotInfo, nil, nil)
+	}
+
+	cand, err := checker.candidate(plug, slot)
+	if err != nil {
+		return nil, err
+	}
+	expl.Unasserted = cand.PlugSnapDeclaration == nil || cand.SlotSnapDeclaration == nil
+	if expl.Connection, err = cand.Explain("connection"); err != nil {
+		return nil, err
+	}
+	if expl.AutoConnection, err = cand.Explain("auto-connection"); err != nil {
+		return nil, err
+	}
+	return expl, nil
+}
@@ -0,0 +1,115 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ifacestate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+)
+
+func (s *interfaceManagerSuite) mockExplainConnections(c *C, consumerPublisher string) {
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-connection:
+      plug-publisher-id:
+        - $SLOT_PUBLISHER_ID
+    deny-auto-connection: true
+`))
+	s.AddCleanup(restore)
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+
+	s.MockModel(c, nil)
+	s.MockSnapDecl(c, "consumer", consumerPublisher, nil)
+	s.mockSnap(c, consumerYaml)
+	s.MockSnapDecl(c, "producer", "producer-publisher", nil)
+	s.mockSnap(c, producerYaml)
+}
+
+func (s *interfaceManagerSuite) TestExplainConnections(c *C) {
+	s.mockExplainConnections(c, "consumer-publisher")
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	expls, err := mgr.ExplainConnections("consumer", "")
+	c.Assert(err, IsNil)
+	// otherplug has no matching slot
+	c.Assert(expls, HasLen, 1)
+	expl := expls[0]
+	c.Check(expl.Plug, Equals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
+	c.Check(expl.Slot, Equals, interfaces.SlotRef{Snap: "producer", Name: "slot"})
+	c.Check(expl.Interface, Equals, "test")
+	c.Check(expl.Connected, Equals, false)
+	c.Check(expl.Unasserted, Equals, false)
+
+	c.Check(expl.Connection.Allowed, Equals, false)
+	c.Check(expl.Connection.Error, Equals, `connection not allowed by slot rule of interface "test"`)
+	c.Assert(expl.Connection.Rules, HasLen, 1)
+	rule := expl.Connection.Rules[0]
+	c.Check(rule.Declaration, Equals, "base-declaration")
+	c.Check(rule.Side, Equals, "slot")
+	c.Check(rule.Decisive, Equals, true)
+	c.Check(rule.Allow.Mismatches, DeepEquals, []string{"publisher id does not match"})
+
+	c.Check(expl.AutoConnection.Allowed, Equals, false)
+	c.Check(expl.AutoConnection.Error, Equals, `auto-connection denied by slot rule of interface "test"`)
+
+	// the same from the slot side
+	expls2, err := mgr.ExplainConnections("producer", "slot")
+	c.Assert(err, IsNil)
+	c.Check(expls2, DeepEquals, expls)
+
+	expls, err = mgr.ExplainConnections("producer", "other")
+	c.Assert(err, IsNil)
+	c.Check(expls, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestExplainConnectionsConnected(c *C) {
+	s.mockExplainConnections(c, "producer-publisher")
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	_, err := mgr.Repository().Connect(&interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}, nil, nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+
+	expls, err := mgr.ExplainConnections("consumer", "plug")
+	c.Assert(err, IsNil)
+	c.Assert(expls, HasLen, 1)
+	c.Check(expls[0].Connected, Equals, true)
+	c.Check(expls[0].Connection.Allowed, Equals, true)
+	c.Check(expls[0].Connection.Rules[0].Allow.Matched, Equals, true)
+	c.Check(expls[0].AutoConnection.Allowed, Equals, false)
+}
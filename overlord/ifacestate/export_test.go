@@ -21,6 +21,8 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/overlord/ifacestate/denialmonitor"
 	"github.com/snapcore/snapd/overlord/ifacestate/schema"
 	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -41,7 +43,7 @@ var (
 	SetConns                     = setConns
 	DefaultDeviceKey             = defaultDeviceKey
 	RemoveDevice                 = removeDevice
-	MakeSlotName                 = makeSlotName
+	MakeSlotName                 = hotplug.MakeSlotName
 	EnsureUniqueName             = ensureUniqueName
 	SuggestedSlotName            = suggestedSlotName
 	HotplugSlotName              = hotplugSlotName
@@ -64,6 +66,7 @@ var (
 	BatchConnectTasks                = batchConnectTasks
 	FirstTaskAfterBootWhenPreseeding = firstTaskAfterBootWhenPreseeding
 	BuildConfinementOptions          = buildConfinementOptions
+	BasePolicyDependents             = basePolicyDependents
 )
 
 type ConnectOpts = connectOpts
@@ -116,6 +119,22 @@ func MockCreateUDevMonitor(new func(udevmonitor.DeviceAddedFunc, udevmonitor.Dev
 	}
 }
 
+func MockCreateDenialMonitor(new func(onDenial denialmonitor.DenialFunc) denialmonitor.Interface) (restore func()) {
+	old := createDenialMonitor
+	createDenialMonitor = new
+	return func() {
+		createDenialMonitor = old
+	}
+}
+
+func MockDenialsTimeNow(f func() time.Time) (restore func()) {
+	old := denialsTimeNow
+	denialsTimeNow = f
+	return func() {
+		denialsTimeNow = old
+	}
+}
+
 func MockUDevInitRetryTimeout(t time.Duration) (restore func()) {
 	old := udevInitRetryTimeout
 	udevInitRetryTimeout = t
@@ -30,11 +30,14 @@ import (
 
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate/schema"
 	"github.com/snapcore/snapd/overlord/servicestate"
@@ -42,6 +45,7 @@ import (
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timings"
 )
 
@@ -81,17 +85,124 @@ func getExtraLayouts(st *state.State, snapInstanceName string) ([]snap.Layout, e
 	return extraLayouts, nil
 }
 
-func buildConfinementOptions(st *state.State, snapInstanceName string, flags snapstate.Flags) (interfaces.ConfinementOptions, error) {
+// getDeniedSyscalls returns the system calls denied to the snap by a
+// seccomp-deny assertion of the brand of the device, if any.
+func getDeniedSyscalls(st *state.State, snapInfo *snap.Info) ([]string, error) {
+	if snapInfo.SnapID == "" {
+		// unasserted snap
+		return nil, nil
+	}
+	deviceCtx, err := snapstate.DeviceCtx(st, nil, nil)
+	if errors.Is(err, state.ErrNoState) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	model := deviceCtx.Model()
+	a, err := assertstate.DB(st).Find(asserts.SeccompDenyType, map[string]string{
+		"brand-id": model.BrandID(),
+		"snap-id":  snapInfo.SnapID,
+	})
+	if asserts.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seccompDeny := a.(*asserts.SeccompDeny)
+	if !seccompDeny.AppliesTo(model) {
+		return nil, nil
+	}
+	return seccompDeny.Syscalls(), nil
+}
+
+// snapBaseName returns the name of the base snap of the given snap, if it
+// has one.
+func snapBaseName(snapInfo *snap.Info) string {
+	switch {
+	case snapInfo.Base == "none":
+		return ""
+	case snapInfo.Base != "":
+		return snapInfo.Base
+	case snapInfo.Type() == snap.TypeApp:
+		return "core"
+	}
+	return ""
+}
+
+// basePolicyDir returns the directory with the sandbox policy fragments
+// shipped by the given base snap, if it ships any and its snap-declaration
+// allows it to.
+func basePolicyDir(st *state.State, baseInfo *snap.Info) (string, error) {
+	dir := interfaces.BasePolicyDir(baseInfo)
+	if !osutil.IsDirectory(dir) {
+		return "", nil
+	}
+	if baseInfo.SnapID == "" {
+		logger.Noticef("ignoring sandbox policy of unasserted base snap %q", baseInfo.InstanceName())
+		return "", nil
+	}
+	decl, err := assertstate.SnapDeclaration(st, baseInfo.SnapID)
+	if errors.Is(err, &asserts.NotFoundError{}) {
+		logger.Noticef("ignoring sandbox policy of base snap %q without snap-declaration", baseInfo.InstanceName())
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot find snap-declaration of base snap %q: %v", baseInfo.InstanceName(), err)
+	}
+	if !decl.SandboxPolicy() {
+		logger.Noticef("ignoring sandbox policy of base snap %q not allowed by its snap-declaration", baseInfo.InstanceName())
+		return "", nil
+	}
+	return dir, nil
+}
+
+// getBasePolicyDir returns the directory with the sandbox policy fragments
+// shipped by the installed base of the given snap, if any.
+func getBasePolicyDir(st *state.State, snapInfo *snap.Info) (string, error) {
+	baseName := snapBaseName(snapInfo)
+	if baseName == "" {
+		return "", nil
+	}
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, baseName, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+		return "", err
+	}
+	if !snapst.IsInstalled() {
+		return "", nil
+	}
+	baseInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return "", err
+	}
+	return basePolicyDir(st, baseInfo)
+}
+
+func buildConfinementOptions(st *state.State, snapInfo *snap.Info, flags snapstate.Flags) (interfaces.ConfinementOptions, error) {
+	snapInstanceName := snapInfo.InstanceName()
 	extraLayouts, err := getExtraLayouts(st, snapInstanceName)
 	if err != nil {
 		return interfaces.ConfinementOptions{}, fmt.Errorf("cannot get extra mount layouts of snap %q: %s", snapInstanceName, err)
 	}
 
+	deniedSyscalls, err := getDeniedSyscalls(st, snapInfo)
+	if err != nil {
+		return interfaces.ConfinementOptions{}, fmt.Errorf("cannot get denied system calls of snap %q: %v", snapInstanceName, err)
+	}
+
+	basePolicyDir, err := getBasePolicyDir(st, snapInfo)
+	if err != nil {
+		return interfaces.ConfinementOptions{}, fmt.Errorf("cannot get base policy of snap %q: %v", snapInstanceName, err)
+	}
+
 	return interfaces.ConfinementOptions{
-		DevMode:      flags.DevMode,
-		JailMode:     flags.JailMode,
-		Classic:      flags.Classic,
-		ExtraLayouts: extraLayouts,
+		DevMode:        flags.DevMode,
+		JailMode:       flags.JailMode,
+		Classic:        flags.Classic,
+		ExtraLayouts:   extraLayouts,
+		DeniedSyscalls: deniedSyscalls,
+		BasePolicyDir:  basePolicyDir,
 	}, nil
 }
 
@@ -116,7 +227,7 @@ func (m *InterfaceManager) setupAffectedSnaps(task *state.Task, affectingSnap st
 		if err := addImplicitSlots(st, affectedSnapInfo); err != nil {
 			return err
 		}
-		opts, err := buildConfinementOptions(st, affectedSnapInfo.InstanceName(), snapst.Flags)
+		opts, err := buildConfinementOptions(st, affectedSnapInfo, snapst.Flags)
 		if err != nil {
 			return err
 		}
@@ -162,7 +273,7 @@ func (m *InterfaceManager) doSetupProfiles(task *state.Task, tomb *tomb.Tomb) er
 		return nil
 	}
 
-	opts, err := buildConfinementOptions(task.State(), snapInfo.InstanceName(), snapsup.Flags)
+	opts, err := buildConfinementOptions(task.State(), snapInfo, snapsup.Flags)
 	if err != nil {
 		return err
 	}
@@ -195,6 +306,59 @@ func setPendingProfilesSideInfo(st *state.State, instanceName string, si *snap.S
 	return nil
 }
 
+// basePolicyDependents returns, if the given snap is a base that ships
+// sandbox policy fragments or whose current revision does, the directory
+// with the fragments of the given revision and the installed snaps using
+// the base.
+func basePolicyDependents(st *state.State, snapInfo *snap.Info) (dir string, dependents []string, err error) {
+	if typ := snapInfo.Type(); typ != snap.TypeBase && typ != snap.TypeOS {
+		return "", nil, nil
+	}
+	baseName := snapInfo.InstanceName()
+	dir, err = basePolicyDir(st, snapInfo)
+	if err != nil {
+		return "", nil, err
+	}
+	if dir == "" {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, baseName, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return "", nil, err
+		}
+		if !snapst.IsInstalled() {
+			return "", nil, nil
+		}
+		curInfo, err := snapst.CurrentInfo()
+		if err != nil {
+			return "", nil, err
+		}
+		curDir, err := basePolicyDir(st, curInfo)
+		if err != nil {
+			return "", nil, err
+		}
+		if curDir == "" {
+			return "", nil, nil
+		}
+	}
+
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return "", nil, err
+	}
+	for name, snapst := range snapStates {
+		if name == baseName || !snapst.IsInstalled() {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			continue
+		}
+		if snapBaseName(info) == baseName {
+			dependents = append(dependents, name)
+		}
+	}
+	return dir, dependents, nil
+}
+
 func (m *InterfaceManager) setupProfilesForSnap(task *state.Task, _ *tomb.Tomb, snapInfo *snap.Info, opts interfaces.ConfinementOptions, tm timings.Measurer) error {
 	st := task.State()
 
@@ -248,6 +412,15 @@ func (m *InterfaceManager) setupProfilesForSnap(task *state.Task, _ *tomb.Tomb,
 	for _, name := range reconnectedSnaps {
 		affectedSet[name] = true
 	}
+	// The profiles of the snaps using a base that ships sandbox policy
+	// fragments, or used to, need to follow the revision being setup.
+	newBasePolicyDir, baseDependents, err := basePolicyDependents(st, snapInfo)
+	if err != nil {
+		return err
+	}
+	for _, name := range baseDependents {
+		affectedSet[name] = true
+	}
 
 	// Sort the set of affected names, ensuring that the snap being setup
 	// is first regardless of the name it has.
@@ -281,10 +454,13 @@ func (m *InterfaceManager) setupProfilesForSnap(task *state.Task, _ *tomb.Tomb,
 		if err := addImplicitSlots(st, snapInfo); err != nil {
 			return err
 		}
-		opts, err := buildConfinementOptions(st, snapInfo.InstanceName(), snapst.Flags)
+		opts, err := buildConfinementOptions(st, snapInfo, snapst.Flags)
 		if err != nil {
 			return err
 		}
+		if snapBaseName(snapInfo) == snapName {
+			opts.BasePolicyDir = newBasePolicyDir
+		}
 
 		affectedSnaps = append(affectedSnaps, snapInfo)
 		confinementOpts = append(confinementOpts, opts)
@@ -383,7 +559,7 @@ func (m *InterfaceManager) undoSetupProfiles(task *state.Task, tomb *tomb.Tomb)
 		if err != nil {
 			return err
 		}
-		opts, err := buildConfinementOptions(task.State(), snapInfo.InstanceName(), snapst.Flags)
+		opts, err := buildConfinementOptions(task.State(), snapInfo, snapst.Flags)
 		if err != nil {
 			return err
 		}
@@ -432,7 +608,9 @@ func (m *InterfaceManager) doDiscardConns(task *state.Task, _ *tomb.Tomb) error
 	}
 	task.Set("removed", removed)
 	setConns(st, conns)
-	return nil
+	return dropPendingAutoConns(st, func(plugRef *interfaces.PlugRef) bool {
+		return plugRef.Snap == instanceName
+	})
 }
 
 func (m *InterfaceManager) undoDiscardConns(task *state.Task, _ *tomb.Tomb) error {
@@ -591,7 +769,7 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) (err error)
 	}()
 
 	if !delayedSetupProfiles {
-		slotOpts, err := buildConfinementOptions(st, slotSnapst.InstanceName(), slotSnapst.Flags)
+		slotOpts, err := buildConfinementOptions(st, slot.Snap, slotSnapst.Flags)
 		if err != nil {
 			return err
 		}
@@ -599,7 +777,7 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) (err error)
 			return err
 		}
 
-		plugOpts, err := buildConfinementOptions(st, plugSnapst.InstanceName(), plugSnapst.Flags)
+		plugOpts, err := buildConfinementOptions(st, plug.Snap, plugSnapst.Flags)
 		if err != nil {
 			return err
 		}
@@ -630,6 +808,13 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) (err error)
 		HotplugKey:       slot.HotplugKey,
 	}
 	setConns(st, conns)
+	m.warnDeprecatedConnection(connRef, conn.Interface())
+
+	if err := dropPendingAutoConns(st, func(ref *interfaces.PlugRef) bool {
+		return *ref == plugRef
+	}); err != nil {
+		return err
+	}
 
 	// the dynamic attributes might have been updated by the interface's BeforeConnectPlug/Slot code,
 	// so we need to update the task for connect-plug- and connect-slot- hooks to see new values.
@@ -703,7 +888,7 @@ func (m *InterfaceManager) doDisconnect(task *state.Task, _ *tomb.Tomb) error {
 		if err != nil {
 			return err
 		}
-		opts, err := buildConfinementOptions(st, snapInfo.InstanceName(), snapst.Flags)
+		opts, err := buildConfinementOptions(st, snapInfo, snapst.Flags)
 		if err != nil {
 			return err
 		}
@@ -812,7 +997,7 @@ func (m *InterfaceManager) undoDisconnect(task *state.Task, _ *tomb.Tomb) error
 		return err
 	}
 
-	slotOpts, err := buildConfinementOptions(st, slotSnapst.InstanceName(), slotSnapst.Flags)
+	slotOpts, err := buildConfinementOptions(st, slot.Snap, slotSnapst.Flags)
 	if err != nil {
 		return err
 	}
@@ -820,7 +1005,7 @@ func (m *InterfaceManager) undoDisconnect(task *state.Task, _ *tomb.Tomb) error
 		return err
 	}
 
-	plugOpts, err := buildConfinementOptions(st, plugSnapst.InstanceName(), plugSnapst.Flags)
+	plugOpts, err := buildConfinementOptions(st, plug.Snap, plugSnapst.Flags)
 	if err != nil {
 		return err
 	}
@@ -903,7 +1088,7 @@ func (m *InterfaceManager) undoConnect(task *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	slotOpts, err := buildConfinementOptions(st, slotSnapst.InstanceName(), slotSnapst.Flags)
+	slotOpts, err := buildConfinementOptions(st, slot.Snap, slotSnapst.Flags)
 	if err != nil {
 		return err
 	}
@@ -911,7 +1096,7 @@ func (m *InterfaceManager) undoConnect(task *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	plugOpts, err := buildConfinementOptions(st, plugSnapst.InstanceName(), plugSnapst.Flags)
+	plugOpts, err := buildConfinementOptions(st, plug.Snap, plugSnapst.Flags)
 	if err != nil {
 		return err
 	}
@@ -1227,6 +1412,51 @@ func filterForSlot(slot *snap.SlotInfo) func(candSlots []*snap.SlotInfo) []*snap
 	}
 }
 
+// doConnectInstalled creates the tasks connecting the plugs and slots requested
+// with ConnectInstalled, once the snaps are installed.
+func (m *InterfaceManager) doConnectInstalled(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var conns []*interfaces.ConnRef
+	if err := task.Get("connections", &conns); err != nil {
+		return err
+	}
+
+	connectTs := state.NewTaskSet()
+	var prev *state.TaskSet
+	for _, conn := range conns {
+		connRef, err := m.repo.ResolveConnect(conn.PlugRef.Snap, conn.PlugRef.Name, conn.SlotRef.Snap, conn.SlotRef.Name)
+		if err != nil {
+			return err
+		}
+		ts, err := connect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name, connectOpts{})
+		if err != nil {
+			if _, ok := err.(*ErrAlreadyConnected); ok {
+				continue
+			}
+			return err
+		}
+		// connect in the requested order
+		if prev != nil {
+			ts.WaitAll(prev)
+		}
+		prev = ts
+		connectTs.AddAll(ts)
+	}
+
+	if len(connectTs.Tasks()) > 0 {
+		snapstate.InjectTasks(task, connectTs)
+		st.EnsureBefore(0)
+	}
+
+	// make sure that we add tasks and mark this task done in the same atomic write, otherwise there is a risk of re-adding tasks again
+	task.SetStatus(state.DoneStatus)
+
+	return nil
+}
+
 // doAutoConnect creates task(s) to connect the given snap to viable candidates.
 func (m *InterfaceManager) doAutoConnect(task *state.Task, _ *tomb.Tomb) error {
 	st := task.State()
@@ -1298,6 +1528,31 @@ func (m *InterfaceManager) doAutoConnect(task *state.Task, _ *tomb.Tomb) error {
 		}
 	}
 
+	// Plugs of the snap are evaluated again below, while pending
+	// auto-connections of plugs of other snaps are retried as the slots
+	// they were missing may have just appeared.
+	pending, err := getPendingAutoConns(st)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var retryPlugs []*snap.PlugInfo
+	for _, id := range ids {
+		delete(pending, id)
+		parts := strings.SplitN(id, ":", 2)
+		if len(parts) != 2 || parts[0] == snapName {
+			continue
+		}
+		if plug := m.repo.Plug(parts[0], parts[1]); plug != nil {
+			retryPlugs = append(retryPlugs, plug)
+		}
+	}
+	autochecker.pending = pending
+
 	plugs := m.repo.Plugs(snapName)
 	slots := m.repo.Slots(snapName)
 	newconns := make(map[string]*interfaces.ConnRef, len(plugs)+len(slots))
@@ -1346,6 +1601,11 @@ func (m *InterfaceManager) doAutoConnect(task *state.Task, _ *tomb.Tomb) error {
 			return err
 		}
 	}
+	// Retry pending auto-connections of other snaps
+	if err := autochecker.addAutoConnections(newconns, retryPlugs, nil, conns, cannotAutoConnectLog, conflictError); err != nil {
+		return err
+	}
+	setPendingAutoConns(st, pending)
 
 	autots, hasInterfaceHooks, err := batchConnectTasks(st, snapsup, newconns, connOpts)
 	if err != nil {
@@ -1532,6 +1792,155 @@ func (m *InterfaceManager) undoTransitionUbuntuCore(t *state.Task, _ *tomb.Tomb)
 	return m.transitionConnectionsCoreMigration(st, newName, oldName)
 }
 
+// transitionSnapConnections carries over the connections of the snap
+// oldName to newName, its renamed successor, for the plugs and slots with
+// the same name and interface in the successor. Connections which cannot be
+// carried over are dropped, they would go away with the removal of the old
+// snap anyway. The connections of both snaps as they were are returned.
+func (m *InterfaceManager) transitionSnapConnections(st *state.State, oldName, newName string) (prevConns map[string]*schema.ConnState, affected []string, err error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prevConns = make(map[string]*schema.ConnState)
+	for id, connState := range conns {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch oldName {
+		case connRef.PlugRef.Snap, connRef.SlotRef.Snap:
+		default:
+			if connRef.PlugRef.Snap == newName || connRef.SlotRef.Snap == newName {
+				prevConns[id] = connState
+			}
+			continue
+		}
+		prevConns[id] = connState
+		delete(conns, id)
+
+		if connRef.PlugRef.Snap == oldName {
+			connRef.PlugRef.Snap = newName
+			if plug := m.repo.Plug(newName, connRef.PlugRef.Name); plug == nil || plug.Interface != connState.Interface {
+				logger.Noticef("cannot transition connection %q to snap %q: no matching plug", id, newName)
+				continue
+			}
+		}
+		if connRef.SlotRef.Snap == oldName {
+			connRef.SlotRef.Snap = newName
+			if slot := m.repo.Slot(newName, connRef.SlotRef.Name); slot == nil || slot.Interface != connState.Interface {
+				logger.Noticef("cannot transition connection %q to snap %q: no matching slot", id, newName)
+				continue
+			}
+		}
+		conns[connRef.ID()] = connState
+	}
+	setConns(st, conns)
+
+	// the connections of the old snap are now gone from the state, remove
+	// them from the repository as well
+	if err := m.removeConnections(oldName); err != nil {
+		return nil, nil, err
+	}
+	affected, err = m.reloadConnections(newName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return prevConns, affected, nil
+}
+
+func (m *InterfaceManager) doTransitionSnapConnections(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	perfTimings := state.TimingsForTask(t)
+	defer perfTimings.Save(st)
+
+	var oldName, newName string
+	if err := t.Get("old-name", &oldName); err != nil {
+		return err
+	}
+	if err := t.Get("new-name", &newName); err != nil {
+		return err
+	}
+
+	prevConns, affected, err := m.transitionSnapConnections(st, oldName, newName)
+	if err != nil {
+		return err
+	}
+	t.Set("old-conns", prevConns)
+
+	// the security profiles of the successor and the snaps connected to
+	// it need to reflect the carried over connections
+	return m.setupAffectedSnaps(t, "", affected, perfTimings)
+}
+
+func (m *InterfaceManager) undoTransitionSnapConnections(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	perfTimings := state.TimingsForTask(t)
+	defer perfTimings.Save(st)
+
+	var oldName, newName string
+	if err := t.Get("old-name", &oldName); err != nil {
+		return err
+	}
+	if err := t.Get("new-name", &newName); err != nil {
+		return err
+	}
+	var prevConns map[string]*schema.ConnState
+	if err := t.Get("old-conns", &prevConns); err != nil {
+		return err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	for id := range conns {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{oldName, newName} {
+			if connRef.PlugRef.Snap == name || connRef.SlotRef.Snap == name {
+				delete(conns, id)
+			}
+		}
+	}
+	for id, connState := range prevConns {
+		conns[id] = connState
+	}
+	setConns(st, conns)
+
+	repoConns, err := m.repo.Connections(newName)
+	if err != nil {
+		return fmt.Errorf("internal error: %v", err)
+	}
+	for _, conn := range repoConns {
+		if err := m.repo.Disconnect(conn.PlugRef.Snap, conn.PlugRef.Name, conn.SlotRef.Snap, conn.SlotRef.Name); err != nil {
+			return fmt.Errorf("internal error: %v", err)
+		}
+	}
+	affected, err := m.reloadConnections(oldName)
+	if err != nil {
+		return err
+	}
+	affectedNew, err := m.reloadConnections(newName)
+	if err != nil {
+		return err
+	}
+	affected = strutil.Deduplicate(append(affected, affectedNew...))
+	if !strutil.ListContains(affected, newName) {
+		affected = append(affected, newName)
+	}
+	return m.setupAffectedSnaps(t, "", affected, perfTimings)
+}
+
 // doHotplugConnect creates task(s) to (re)create old connections or auto-connect viable slots in response to hotplug "add" event.
 func (m *InterfaceManager) doHotplugConnect(task *state.Task, _ *tomb.Tomb) error {
 	st := task.State()
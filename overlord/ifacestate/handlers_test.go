@@ -20,19 +20,27 @@
 package ifacestate_test
 
 import (
+	"os"
 	"path"
+	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
 	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
 )
 
 const snapAyaml = `name: snap-a
@@ -41,18 +49,23 @@ base: base-snap-a
 `
 
 type handlersSuite struct {
+	testutil.BaseTest
 	st *state.State
 }
 
 var _ = Suite(&handlersSuite{})
 
 func (s *handlersSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
 	s.st = state.New(nil)
 	dirs.SetRootDir(c.MkDir())
+	// no model assertion yet
+	s.AddCleanup(snapstatetest.MockDeviceModel(nil))
 }
 
 func (s *handlersSuite) TearDownTest(c *C) {
 	dirs.SetRootDir("")
+	s.BaseTest.TearDownTest(c)
 }
 
 func (s *handlersSuite) TestInSameChangeWaitChain(c *C) {
@@ -131,7 +144,7 @@ func (s *handlersSuite) TestBuildConfinementOptions(c *C) {
 
 	snapInfo := mockInstalledSnap(c, s.st, snapAyaml)
 	flags := snapstate.Flags{}
-	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo.InstanceName(), snapstate.Flags{})
+	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
 
 	c.Check(err, IsNil)
 	c.Check(len(opts.ExtraLayouts), Equals, 0)
@@ -155,7 +168,7 @@ func (s *handlersSuite) TestBuildConfinementOptionsWithLogNamespace(c *C) {
 	c.Assert(err, IsNil)
 
 	flags := snapstate.Flags{}
-	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo.InstanceName(), snapstate.Flags{})
+	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
 
 	c.Check(err, IsNil)
 	c.Assert(len(opts.ExtraLayouts), Equals, 1)
@@ -165,3 +178,263 @@ func (s *handlersSuite) TestBuildConfinementOptionsWithLogNamespace(c *C) {
 	c.Check(opts.DevMode, Equals, flags.DevMode)
 	c.Check(opts.JailMode, Equals, flags.JailMode)
 }
+
+func (s *handlersSuite) TestBuildConfinementOptionsWithSeccompDeny(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	storeSigning := assertstest.NewStoreStack("canonical", nil)
+	brands := assertstest.NewSigningAccounts(storeSigning)
+	brandPrivKey, _ := assertstest.GenerateKey(752)
+	brandSigning := brands.Register("my-brand", brandPrivKey, nil)
+	otherPrivKey, _ := assertstest.GenerateKey(752)
+	otherSigning := brands.Register("other-brand", otherPrivKey, nil)
+
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	assertstest.AddMany(db, storeSigning.StoreAccountKey(""))
+	assertstest.AddMany(db, brands.AccountsAndKeys("my-brand", "other-brand")...)
+	assertstate.ReplaceDB(s.st, db)
+
+	snapInfo := mockInstalledSnap(c, s.st, snapAyaml)
+	snapInfo.SnapID = "snapaidididididididididididididi"
+
+	addSeccompDeny := func(signing *assertstest.SigningDB, brandID string, models []interface{}, syscalls ...interface{}) {
+		headers := map[string]interface{}{
+			"brand-id":  brandID,
+			"snap-id":   snapInfo.SnapID,
+			"syscalls":  syscalls,
+			"timestamp": time.Now().Format(time.RFC3339),
+		}
+		if models != nil {
+			headers["models"] = models
+		}
+		a, err := signing.Sign(asserts.SeccompDenyType, headers, nil, "")
+		c.Assert(err, IsNil)
+		c.Assert(db.Add(a), IsNil)
+	}
+
+	// no model assertion yet
+	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.DeniedSyscalls, IsNil)
+
+	s.AddCleanup(snapstatetest.MockDeviceModel(brands.Model("my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"gadget":       "gadget",
+		"kernel":       "kernel",
+	})))
+
+	// no seccomp-deny assertion
+	opts, err = ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.DeniedSyscalls, IsNil)
+
+	// assertions of other brands are ignored
+	addSeccompDeny(otherSigning, "other-brand", nil, "io_uring_setup")
+	opts, err = ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.DeniedSyscalls, IsNil)
+
+	// as well as those restricted to other models
+	addSeccompDeny(brandSigning, "my-brand", []interface{}{"other-model"}, "io_uring_setup")
+	opts, err = ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.DeniedSyscalls, IsNil)
+}
+
+func (s *handlersSuite) TestBuildConfinementOptionsWithSeccompDenyForModel(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	storeSigning := assertstest.NewStoreStack("canonical", nil)
+	brands := assertstest.NewSigningAccounts(storeSigning)
+	brandPrivKey, _ := assertstest.GenerateKey(752)
+	brandSigning := brands.Register("my-brand", brandPrivKey, nil)
+
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	assertstest.AddMany(db, storeSigning.StoreAccountKey(""))
+	assertstest.AddMany(db, brands.AccountsAndKeys("my-brand")...)
+	assertstate.ReplaceDB(s.st, db)
+	s.AddCleanup(snapstatetest.MockDeviceModel(brands.Model("my-brand", "my-model", map[string]interface{}{
+		"architecture": "amd64",
+		"gadget":       "gadget",
+		"kernel":       "kernel",
+	})))
+
+	snapInfo := mockInstalledSnap(c, s.st, snapAyaml)
+	snapInfo.SnapID = "snapaidididididididididididididi"
+	a, err := brandSigning.Sign(asserts.SeccompDenyType, map[string]interface{}{
+		"brand-id":  "my-brand",
+		"snap-id":   snapInfo.SnapID,
+		"models":    []interface{}{"other-model", "my-model"},
+		"syscalls":  []interface{}{"io_uring_setup", "io_uring_enter"},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(a), IsNil)
+
+	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.DeniedSyscalls, DeepEquals, []string{"io_uring_setup", "io_uring_enter"})
+}
+
+func (s *handlersSuite) mockBaseSnapDeclaration(c *C, snapID string, headers map[string]interface{}) {
+	storeSigning := assertstest.NewStoreStack("canonical", nil)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	assertstest.AddMany(db, storeSigning.StoreAccountKey(""))
+	assertstate.ReplaceDB(s.st, db)
+
+	if headers == nil {
+		return
+	}
+	decl := map[string]interface{}{
+		"series":       "16",
+		"snap-id":      snapID,
+		"snap-name":    "base-snap-a",
+		"publisher-id": "canonical",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+	for k, v := range headers {
+		decl[k] = v
+	}
+	a, err := storeSigning.Sign(asserts.SnapDeclarationType, decl, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(a), IsNil)
+}
+
+func (s *handlersSuite) TestBuildConfinementOptionsWithBasePolicy(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockBaseSnapDeclaration(c, "base-snap-a-id", map[string]interface{}{
+		"sandbox-policy": "true",
+	})
+	snapInfo := mockInstalledSnap(c, s.st, snapAyaml)
+
+	// base not installed
+	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.BasePolicyDir, Equals, "")
+
+	// base without policy
+	baseInfo := mockInstalledSnap(c, s.st, "name: base-snap-a\ntype: base\nversion: 1\n")
+	opts, err = ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.BasePolicyDir, Equals, "")
+
+	c.Assert(os.MkdirAll(interfaces.BasePolicyDir(baseInfo), 0755), IsNil)
+	opts, err = ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.BasePolicyDir, Equals, interfaces.BasePolicyDir(baseInfo))
+}
+
+func (s *handlersSuite) TestBuildConfinementOptionsWithBasePolicyNotAllowed(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	snapInfo := mockInstalledSnap(c, s.st, snapAyaml)
+	baseInfo := mockInstalledSnap(c, s.st, "name: base-snap-a\ntype: base\nversion: 1\n")
+	c.Assert(os.MkdirAll(interfaces.BasePolicyDir(baseInfo), 0755), IsNil)
+
+	// no snap-declaration for the base
+	s.mockBaseSnapDeclaration(c, "base-snap-a-id", nil)
+	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.BasePolicyDir, Equals, "")
+
+	// the snap-declaration does not allow sandbox policy
+	s.mockBaseSnapDeclaration(c, "base-snap-a-id", map[string]interface{}{})
+	opts, err = ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.BasePolicyDir, Equals, "")
+
+	s.mockBaseSnapDeclaration(c, "base-snap-a-id", map[string]interface{}{
+		"sandbox-policy": "false",
+	})
+	opts, err = ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.BasePolicyDir, Equals, "")
+}
+
+func (s *handlersSuite) TestBuildConfinementOptionsWithBasePolicyUnasserted(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	snapInfo := mockInstalledSnap(c, s.st, snapAyaml)
+	baseInfo := snaptest.MockSnap(c, "name: base-snap-a\ntype: base\nversion: 1\n", &snap.SideInfo{Revision: snap.R("x1")})
+	si := &snap.SideInfo{RealName: "base-snap-a", Revision: snap.R("x1")}
+	snapstate.Set(s.st, "base-snap-a", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "base",
+	})
+	c.Assert(os.MkdirAll(interfaces.BasePolicyDir(baseInfo), 0755), IsNil)
+
+	opts, err := ifacestate.BuildConfinementOptions(s.st, snapInfo, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(opts.BasePolicyDir, Equals, "")
+}
+
+func (s *handlersSuite) TestBasePolicyDependents(c *C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	s.mockBaseSnapDeclaration(c, "base-snap-a-id", map[string]interface{}{
+		"sandbox-policy": "true",
+	})
+	appInfo := mockInstalledSnap(c, s.st, snapAyaml)
+	mockInstalledSnap(c, s.st, "name: snap-b\ntype: app\nbase: other-base\n")
+	curInfo := mockInstalledSnap(c, s.st, "name: base-snap-a\ntype: base\nversion: 1\n")
+	newInfo := snaptest.MockSnap(c, "name: base-snap-a\ntype: base\nversion: 2\n", &snap.SideInfo{
+		SnapID:   "base-snap-a-id",
+		Revision: snap.R(2),
+	})
+
+	// only bases have dependents
+	dir, dependents, err := ifacestate.BasePolicyDependents(s.st, appInfo)
+	c.Assert(err, IsNil)
+	c.Check(dir, Equals, "")
+	c.Check(dependents, IsNil)
+
+	// neither revision of the base ships policy
+	dir, dependents, err = ifacestate.BasePolicyDependents(s.st, newInfo)
+	c.Assert(err, IsNil)
+	c.Check(dir, Equals, "")
+	c.Check(dependents, IsNil)
+
+	// the new revision does
+	c.Assert(os.MkdirAll(interfaces.BasePolicyDir(newInfo), 0755), IsNil)
+	dir, dependents, err = ifacestate.BasePolicyDependents(s.st, newInfo)
+	c.Assert(err, IsNil)
+	c.Check(dir, Equals, interfaces.BasePolicyDir(newInfo))
+	c.Check(dependents, DeepEquals, []string{"snap-a"})
+
+	// only the current revision does, the dependents need to drop it
+	c.Assert(os.RemoveAll(interfaces.BasePolicyDir(newInfo)), IsNil)
+	c.Assert(os.MkdirAll(interfaces.BasePolicyDir(curInfo), 0755), IsNil)
+	dir, dependents, err = ifacestate.BasePolicyDependents(s.st, newInfo)
+	c.Assert(err, IsNil)
+	c.Check(dir, Equals, "")
+	c.Check(dependents, DeepEquals, []string{"snap-a"})
+
+	// the policy is not allowed by the snap-declaration
+	c.Assert(os.MkdirAll(interfaces.BasePolicyDir(newInfo), 0755), IsNil)
+	s.mockBaseSnapDeclaration(c, "base-snap-a-id", map[string]interface{}{})
+	dir, dependents, err = ifacestate.BasePolicyDependents(s.st, newInfo)
+	c.Assert(err, IsNil)
+	c.Check(dir, Equals, "")
+	c.Check(dependents, IsNil)
+}
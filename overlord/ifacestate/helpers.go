@@ -158,6 +158,7 @@ func snapdAppArmorServiceIsDisabledImpl() bool {
 }
 
 // regenerateAllSecurityProfiles will regenerate all security profiles.
+// The state must be locked, it is released while writing the profiles.
 func (m *InterfaceManager) regenerateAllSecurityProfiles(tm timings.Measurer) error {
 	// Get all the security backends
 	securityBackends := m.repo.Backends()
@@ -185,17 +186,36 @@ func (m *InterfaceManager) regenerateAllSecurityProfiles(tm timings.Measurer) er
 	shouldWriteSystemKey := true
 	os.Remove(dirs.SnapSystemKeyFile)
 
-	confinementOpts := func(snapName string) interfaces.ConfinementOptions {
+	// the confinement options are computed upfront as the state is
+	// unlocked while the profiles are written
+	allConfinementOpts := make(map[string]interfaces.ConfinementOptions, len(snaps))
+	for _, snapInfo := range snaps {
+		snapName := snapInfo.InstanceName()
 		var snapst snapstate.SnapState
 		if err := snapstate.Get(m.state, snapName, &snapst); err != nil {
 			logger.Noticef("cannot get state of snap %q: %s", snapName, err)
 		}
-		opts, err := buildConfinementOptions(m.state, snapst.InstanceName(), snapst.Flags)
+		curInfo, err := snapst.CurrentInfo()
+		if err != nil {
+			logger.Noticef("cannot get current info of snap %q: %s", snapName, err)
+			curInfo = &snap.Info{SideInfo: snap.SideInfo{RealName: snapName}}
+		}
+		opts, err := buildConfinementOptions(m.state, curInfo, snapst.Flags)
 		if err != nil {
 			logger.Noticef("cannot get confinement options for snap %q: %s", snapName, err)
 		}
-		return opts
+		allConfinementOpts[snapName] = opts
 	}
+	confinementOpts := func(snapName string) interfaces.ConfinementOptions {
+		return allConfinementOpts[snapName]
+	}
+
+	// Writing the profiles of all snaps can take a while, it is done
+	// before the first Ensure so no change can be running meanwhile
+	// and the repository is protected by its own lock, the state is
+	// unlocked so that the API stays responsive.
+	m.state.Unlock()
+	defer m.state.Lock()
 
 	// For each backend:
 	for _, backend := range securityBackends {
@@ -677,6 +697,10 @@ type autoConnectChecker struct {
 	deviceCtx snapstate.DeviceContext
 	cache     map[string]*asserts.SnapDeclaration
 	baseDecl  *asserts.BaseDeclaration
+
+	// pending, if set, collects the plugs that could not be
+	// auto-connected
+	pending map[string]*pendingAutoConnState
 }
 
 func newAutoConnectChecker(s *state.State, task *state.Task, repo *interfaces.Repository, deviceCtx snapstate.DeviceContext) (*autoConnectChecker, error) {
@@ -761,6 +785,30 @@ func (c *autoConnectChecker) check(plug *interfaces.ConnectedPlug, slot *interfa
 	return false, nil, nil
 }
 
+// missingSlotReason returns why no slot is available for auto-connecting the
+// given plug, if the plug expects one to be provided.
+func (c *autoConnectChecker) missingSlotReason(plug *snap.PlugInfo) string {
+	for provider := range snap.DefaultContentProviders([]*snap.PlugInfo{plug}) {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(c.st, provider, &snapst); errors.Is(err, state.ErrNoState) {
+			return fmt.Sprintf("default provider %q is not installed", provider)
+		}
+		return fmt.Sprintf("no matching slot of default provider %q", provider)
+	}
+	return ""
+}
+
+func (c *autoConnectChecker) recordPending(plug *snap.PlugInfo, reason string) {
+	if c.pending == nil || reason == "" {
+		return
+	}
+	plugRef := interfaces.PlugRef{Snap: plug.Snap.InstanceName(), Name: plug.Name}
+	c.pending[plugRef.String()] = &pendingAutoConnState{
+		Interface: plug.Interface,
+		Reason:    reason,
+	}
+}
+
 // filterUbuntuCoreSlots filters out any ubuntu-core slots,
 // if there are both ubuntu-core and core slots. This would occur
 // during a ubuntu-core -> core transition.
@@ -805,6 +853,9 @@ func (c *autoConnectChecker) addAutoConnections(newconns map[string]*interfaces.
 		candSlots, arities := c.repo.AutoConnectCandidateSlots(plug.Snap.InstanceName(), plug.Name, c.check)
 
 		if len(candSlots) == 0 {
+			if filter == nil {
+				c.recordPending(plug, c.missingSlotReason(plug))
+			}
 			continue
 		}
 
@@ -837,6 +888,9 @@ func (c *autoConnectChecker) addAutoConnections(newconns map[string]*interfaces.
 				crefs[i] = candidate.String()
 			}
 			c.task.Logf(cannotAutoConnectLog(plug, crefs))
+			if filter == nil {
+				c.recordPending(plug, fmt.Sprintf("multiple candidate slots: %s", strings.Join(crefs, ", ")))
+			}
 			continue
 		}
 
@@ -868,7 +922,9 @@ func newConnectChecker(s *state.State, deviceCtx snapstate.DeviceContext) (*conn
 	}, nil
 }
 
-func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) (bool, error) {
+// candidate returns the candidate connection of the plug to the slot to
+// check against the declarations' rules.
+func (c *connectChecker) candidate(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) (*policy.ConnectCandidate, error) {
 	modelAs := c.deviceCtx.Model()
 
 	var storeAs *asserts.Store
@@ -876,7 +932,7 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		var err error
 		storeAs, err = assertstate.Store(c.st, modelAs.Store())
 		if err != nil && !asserts.IsNotFound(err) {
-			return false, err
+			return nil, err
 		}
 	}
 
@@ -885,7 +941,7 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		var err error
 		plugDecl, err = assertstate.SnapDeclaration(c.st, plug.Snap().SnapID)
 		if err != nil {
-			return false, fmt.Errorf("cannot find snap declaration for %q: %v", plug.Snap().InstanceName(), err)
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", plug.Snap().InstanceName(), err)
 		}
 	}
 
@@ -894,12 +950,11 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		var err error
 		slotDecl, err = assertstate.SnapDeclaration(c.st, slot.Snap().SnapID)
 		if err != nil {
-			return false, fmt.Errorf("cannot find snap declaration for %q: %v", slot.Snap().InstanceName(), err)
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", slot.Snap().InstanceName(), err)
 		}
 	}
 
-	// check the connection against the declarations' rules
-	ic := policy.ConnectCandidate{
+	return &policy.ConnectCandidate{
 		Plug:                plug,
 		PlugSnapDeclaration: plugDecl,
 		Slot:                slot,
@@ -907,12 +962,20 @@ func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.
 		BaseDeclaration:     c.baseDecl,
 		Model:               modelAs,
 		Store:               storeAs,
+	}, nil
+}
+
+func (c *connectChecker) check(plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) (bool, error) {
+	// check the connection against the declarations' rules
+	ic, err := c.candidate(plug, slot)
+	if err != nil {
+		return false, err
 	}
 
 	// if either of plug or slot snaps don't have a declaration it
 	// means they were installed with "dangerous", so the security
 	// check should be skipped at this point.
-	if plugDecl != nil && slotDecl != nil {
+	if ic.PlugSnapDeclaration != nil && ic.SlotSnapDeclaration != nil {
 		if err := ic.Check(); err != nil {
 			return false, err
 		}
@@ -985,6 +1048,59 @@ func setConns(st *state.State, conns map[string]*schema.ConnState) {
 	st.Set("conns", remapped)
 }
 
+// pendingAutoConnState describes a plug that could not be auto-connected
+// and the reason why.
+type pendingAutoConnState struct {
+	Interface string `json:"interface"`
+	Reason    string `json:"reason"`
+}
+
+// getPendingAutoConns returns the plugs that could not be auto-connected,
+// indexed by plug reference.
+func getPendingAutoConns(st *state.State) (map[string]*pendingAutoConnState, error) {
+	var pending map[string]*pendingAutoConnState
+	err := st.Get("pending-auto-connections", &pending)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, fmt.Errorf("cannot obtain data about pending auto-connections: %s", err)
+	}
+	if pending == nil {
+		pending = make(map[string]*pendingAutoConnState)
+	}
+	return pending, nil
+}
+
+// setPendingAutoConns sets the plugs that could not be auto-connected.
+func setPendingAutoConns(st *state.State, pending map[string]*pendingAutoConnState) {
+	if len(pending) == 0 {
+		st.Set("pending-auto-connections", nil)
+		return
+	}
+	st.Set("pending-auto-connections", pending)
+}
+
+// dropPendingAutoConns forgets about pending auto-connections of plugs
+// matching the given predicate.
+func dropPendingAutoConns(st *state.State, matches func(plugRef *interfaces.PlugRef) bool) error {
+	pending, err := getPendingAutoConns(st)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	for id := range pending {
+		parts := strings.SplitN(id, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("internal error: malformed plug reference %q", id)
+		}
+		if matches(&interfaces.PlugRef{Snap: parts[0], Name: parts[1]}) {
+			delete(pending, id)
+		}
+	}
+	setPendingAutoConns(st, pending)
+	return nil
+}
+
 // snapsWithSecurityProfiles returns all snaps that have active
 // security profiles: these are either snaps that are active,
 // inactive snaps that are being operated on, whose profile state
@@ -1237,6 +1353,17 @@ func connectDisconnectAffectedSnaps(t *state.Task) ([]string, error) {
 	return []string{plugRef.Snap, slotRef.Snap}, nil
 }
 
+func transitionAffectedSnaps(t *state.Task) ([]string, error) {
+	var oldName, newName string
+	if err := t.Get("old-name", &oldName); err != nil {
+		return nil, fmt.Errorf("internal error: cannot obtain snap names from task: %s", t.Summary())
+	}
+	if err := t.Get("new-name", &newName); err != nil {
+		return nil, fmt.Errorf("internal error: cannot obtain snap names from task: %s", t.Summary())
+	}
+	return []string{oldName, newName}, nil
+}
+
 func checkSystemSnapIsPresent(st *state.State) bool {
 	st.Lock()
 	defer st.Unlock()
@@ -412,6 +412,8 @@ apps:
 	c.Assert(err, IsNil)
 	err = mgr.StartUp()
 	c.Assert(err, IsNil)
+	err = mgr.DeferredStartUp()
+	c.Assert(err, IsNil)
 
 	// Check that system key is not on disk.
 	c.Check(log.String(), Matches, `.*cannot regenerate BROKEN profiles\n.*FAILED.*\n`)
@@ -482,8 +484,20 @@ func (s *helpersSuite) TestProfileRegenerationSetupMany(c *C) {
 	err = mgr.StartUp()
 	c.Assert(err, IsNil)
 
+	// the regeneration is deferred
+	c.Check(writeKey, Equals, false)
+	c.Check(setupManyCalls, Equals, 0)
+
+	err = mgr.DeferredStartUp()
+	c.Assert(err, IsNil)
+
 	c.Check(writeKey, Equals, true)
 	c.Check(setupManyCalls, Equals, 1)
+
+	// and done only once
+	err = mgr.DeferredStartUp()
+	c.Assert(err, IsNil)
+	c.Check(setupManyCalls, Equals, 1)
 }
 
 func (s *helpersSuite) TestProfileRegenerationSetupManyFailsSystemKeyNotWritten(c *C) {
@@ -529,6 +543,8 @@ func (s *helpersSuite) TestProfileRegenerationSetupManyFailsSystemKeyNotWritten(
 	c.Assert(err, IsNil)
 	err = mgr.StartUp()
 	c.Assert(err, IsNil)
+	err = mgr.DeferredStartUp()
+	c.Assert(err, IsNil)
 
 	// Check that system key is not on disk.
 	c.Check(writeKey, Equals, false)
@@ -23,8 +23,6 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
-	"strings"
-	"unicode"
 
 	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/interfaces"
@@ -354,40 +352,6 @@ func ensureUniqueName(proposedName string, isUnique func(string) bool) string {
 	}
 }
 
-const maxGenerateSlotNameLen = 20
-
-// makeSlotName sanitizes a string to make it a valid slot name that
-// passes validation rules implemented by ValidateSlotName (see snap/validate.go):
-// - only lowercase letter, digits and dashes are allowed
-// - must start with a letter
-// - no double dashes, cannot end with a dash.
-// In addition names are truncated not to exceed maxGenerateSlotNameLen characters.
-func makeSlotName(s string) string {
-	var out []rune
-	// the dash flag is used to prevent consecutive dashes, and the dash in the front
-	dash := true
-	for _, c := range s {
-		switch {
-		case c == '-' && !dash:
-			dash = true
-			out = append(out, '-')
-		case unicode.IsLetter(c):
-			out = append(out, unicode.ToLower(c))
-			dash = false
-		case unicode.IsDigit(c) && len(out) > 0:
-			out = append(out, c)
-			dash = false
-		default:
-			// any other character is ignored
-		}
-		if len(out) >= maxGenerateSlotNameLen {
-			break
-		}
-	}
-	// make sure the name doesn't end with a dash
-	return strings.TrimRight(string(out), "-")
-}
-
 var nameAttrs = []string{"NAME", "ID_MODEL_FROM_DATABASE", "ID_MODEL"}
 
 // suggestedSlotName returns the shortest name derived from attributes defined
@@ -399,7 +363,7 @@ func suggestedSlotName(devinfo *hotplug.HotplugDeviceInfo, fallbackName string)
 	for _, attr := range nameAttrs {
 		name, ok := devinfo.Attribute(attr)
 		if ok {
-			if name := makeSlotName(name); name != "" {
+			if name := hotplug.MakeSlotName(name); name != "" {
 				if shortestName == "" || len(name) < len(shortestName) {
 					shortestName = name
 				}
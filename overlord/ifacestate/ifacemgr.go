@@ -26,8 +26,10 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/backends"
+	"github.com/snapcore/snapd/interfaces/denials"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate/denialmonitor"
 	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
 	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -59,11 +61,24 @@ type InterfaceManager struct {
 	// maps sysfs path -> [(interface name, device key)...]
 	hotplugDevicePaths map[string][]deviceData
 
+	denialMonMu           sync.Mutex
+	denialMon             denialmonitor.Interface
+	denialMonitorDisabled bool
+	denialLimiter         *denials.Limiter
+
 	// extras
 	extraInterfaces []interfaces.Interface
 	extraBackends   []interfaces.SecurityBackend
 
 	preseed bool
+
+	// profilesNeedRegeneration is set on startup when the security
+	// profiles need to be regenerated by DeferredStartUp
+	profilesNeedRegeneration bool
+
+	// migrationAttempted tracks the connections of deprecated
+	// interfaces that were considered for migration already
+	migrationAttempted map[string]bool
 }
 
 // Manager returns a new InterfaceManager.
@@ -83,6 +98,8 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 		// note: enumeratedDeviceKeys is reset to nil when enumeration is done
 		enumeratedDeviceKeys: make(map[string]map[snap.HotplugKey]bool),
 		hotplugDevicePaths:   make(map[string][]deviceData),
+		denialLimiter:        denials.NewLimiter(denialRateLimitInterval),
+		migrationAttempted:   make(map[string]bool),
 		// extras
 		extraInterfaces: extraInterfaces,
 		extraBackends:   extraBackends,
@@ -101,6 +118,7 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	addHandler("remove-profiles", m.doRemoveProfiles, m.doSetupProfiles)
 	addHandler("discard-conns", m.doDiscardConns, m.undoDiscardConns)
 	addHandler("auto-connect", m.doAutoConnect, m.undoAutoConnect)
+	addHandler("connect-installed", m.doConnectInstalled, nil)
 	addHandler("auto-disconnect", m.doAutoDisconnect, nil)
 	addHandler("hotplug-add-slot", m.doHotplugAddSlot, nil)
 	addHandler("hotplug-connect", m.doHotplugConnect, nil)
@@ -114,6 +132,9 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 	// helper for ubuntu-core -> core
 	addHandler("transition-ubuntu-core", m.doTransitionUbuntuCore, m.undoTransitionUbuntuCore)
 
+	// helper for the migration of a snap to its renamed successor
+	addHandler("transition-snap-connections", m.doTransitionSnapConnections, m.undoTransitionSnapConnections)
+
 	// interface tasks might touch more than the immediate task target snap, serialize them
 	runner.AddBlocked(func(t *state.Task, running []*state.Task) bool {
 		if !taskKinds[t.Kind()] {
@@ -135,7 +156,6 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, runner *state.T
 // StartUp implements StateStarterUp.Startup.
 func (m *InterfaceManager) StartUp() error {
 	s := m.state
-	perfTimings := timings.New(map[string]string{"startup": "ifacemgr"})
 
 	s.Lock()
 	defer s.Unlock()
@@ -169,11 +189,12 @@ func (m *InterfaceManager) StartUp() error {
 	if _, err := m.reloadConnections(""); err != nil {
 		return err
 	}
-	if profilesNeedRegeneration() {
-		if err := m.regenerateAllSecurityProfiles(perfTimings); err != nil {
-			return err
-		}
+	if err := m.warnDeprecatedConnections(); err != nil {
+		return err
 	}
+	// the regeneration is expensive, it is deferred until the API is
+	// served, snap run waits for it to be done
+	m.profilesNeedRegeneration = profilesNeedRegeneration()
 	if snapdAppArmorServiceIsDisabled() {
 		s.Warnf(`the snapd.apparmor service is disabled; snap applications will likely not start.
 Run "systemctl enable --now snapd.apparmor" to correct this.`)
@@ -184,6 +205,25 @@ Run "systemctl enable --now snapd.apparmor" to correct this.`)
 	// wire late profile removal support into snapstate
 	snapstate.SecurityProfilesRemoveLate = m.discardSecurityProfilesLate
 
+	return nil
+}
+
+// DeferredStartUp implements StateDeferredStarterUp.
+func (m *InterfaceManager) DeferredStartUp() error {
+	if !m.profilesNeedRegeneration {
+		return nil
+	}
+	s := m.state
+	perfTimings := timings.New(map[string]string{"startup": "ifacemgr"})
+
+	s.Lock()
+	defer s.Unlock()
+
+	if err := m.regenerateAllSecurityProfiles(perfTimings); err != nil {
+		return err
+	}
+	m.profilesNeedRegeneration = false
+
 	perfTimings.Save(s)
 
 	return nil
@@ -191,11 +231,17 @@ Run "systemctl enable --now snapd.apparmor" to correct this.`)
 
 // Ensure implements StateManager.Ensure.
 func (m *InterfaceManager) Ensure() error {
-	// do not worry about udev monitor in preseeding mode
+	// do not worry about udev and denial monitors in preseeding mode
 	if m.preseed {
 		return nil
 	}
 
+	m.ensureDenialMonitor()
+
+	if err := m.ensureDeprecatedConnectionsMigrated(); err != nil {
+		logger.Noticef("cannot migrate connections of deprecated interfaces: %v", err)
+	}
+
 	if m.udevMonitorDisabled {
 		return nil
 	}
@@ -224,9 +270,11 @@ func (m *InterfaceManager) Ensure() error {
 	return nil
 }
 
-// Stop implements StateStopper. It stops the udev monitor,
+// Stop implements StateStopper. It stops the udev and denial monitors,
 // if running.
 func (m *InterfaceManager) Stop() {
+	m.stopDenialMonitor()
+
 	m.udevMonMu.Lock()
 	udevMon := m.udevMon
 	m.udevMonMu.Unlock()
@@ -312,6 +360,42 @@ func (m *InterfaceManager) ConnectionStates() (connStateByRef map[string]Connect
 	return ConnectionStates(m.state)
 }
 
+// PendingAutoConnection describes a plug that could not be auto-connected.
+// Pending auto-connections are retried whenever another snap is
+// auto-connected.
+type PendingAutoConnection struct {
+	Interface string
+	// Reason explains why the plug could not be auto-connected.
+	Reason string
+}
+
+// PendingAutoConnections returns the plugs that could not be auto-connected,
+// indexed by plug reference.
+// The state must be locked by the caller.
+func PendingAutoConnections(st *state.State) (map[string]PendingAutoConnection, error) {
+	pending, err := getPendingAutoConns(st)
+	if err != nil {
+		return nil, err
+	}
+	pendingByRef := make(map[string]PendingAutoConnection, len(pending))
+	for plugRef, p := range pending {
+		pendingByRef[plugRef] = PendingAutoConnection{
+			Interface: p.Interface,
+			Reason:    p.Reason,
+		}
+	}
+	return pendingByRef, nil
+}
+
+// PendingAutoConnections returns the plugs that could not be auto-connected
+// tracked by the manager.
+func (m *InterfaceManager) PendingAutoConnections() (map[string]PendingAutoConnection, error) {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	return PendingAutoConnections(m.state)
+}
+
 // ResolveDisconnect resolves potentially missing plug or slot names and
 // returns a list of fully populated connection references that can be
 // disconnected.
@@ -24,6 +24,7 @@ package ifacestate
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var connectRetryTimeout = time.Second * 5
@@ -97,6 +99,62 @@ func Connect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*s
 	return connect(st, plugSnap, plugName, slotSnap, slotName, connectOpts{})
 }
 
+// SetupProfiles returns the tasks setting up again the security profiles of
+// the installed snaps with the given snap IDs, for instance so that
+// seccomp-deny assertions added for them take effect. It also returns the
+// names of the affected snaps.
+func SetupProfiles(st *state.State, snapIDs []string) ([]string, *state.TaskSet, error) {
+	all, err := snapstate.All(st)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var affected []string
+	ts := state.NewTaskSet()
+	for _, name := range names {
+		snapst := all[name]
+		si := snapst.CurrentSideInfo()
+		if si == nil || si.SnapID == "" || !strutil.ListContains(snapIDs, si.SnapID) {
+			continue
+		}
+		if err := snapstate.CheckChangeConflict(st, name, nil); err != nil {
+			return nil, nil, err
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return nil, nil, err
+		}
+		snapsup := &snapstate.SnapSetup{
+			SideInfo:    si,
+			Flags:       snapst.Flags.ForSnapSetup(),
+			Type:        info.Type(),
+			PlugsOnly:   len(info.Slots) == 0,
+			InstanceKey: snapst.InstanceKey,
+		}
+		setupProfiles := st.NewTask("setup-profiles", fmt.Sprintf(i18n.G("Setup snap %q (%s) security profiles"), name, snapst.Current))
+		setupProfiles.Set("snap-setup", snapsup)
+		ts.AddTask(setupProfiles)
+		affected = append(affected, name)
+	}
+	return affected, ts, nil
+}
+
+// ConnectInstalled returns a task for connecting the given plugs and slots of
+// snaps that are installed by the change the task is added to. The task must
+// wait for the tasks installing the snaps. Slot snap and slot names can be left
+// empty, they are resolved as for a manual connection once the snaps are
+// installed.
+func ConnectInstalled(st *state.State, conns []*interfaces.ConnRef) *state.Task {
+	t := st.NewTask("connect-installed", i18n.G("Connect plugs and slots of installed snaps"))
+	t.Set("connections", conns)
+	return t
+}
+
 func connect(st *state.State, plugSnap, plugName, slotSnap, slotName string, flags connectOpts) (*state.TaskSet, error) {
 	// TODO: Store the intent-to-connect in the state so that we automatically
 	// try to reconnect on reboot (reconnection can fail or can connect with
@@ -536,6 +594,7 @@ func delayedCrossMgrInit() {
 		// hook into conflict checks mechanisms
 		snapstate.RegisterAffectedSnapsByKind("connect", connectDisconnectAffectedSnaps)
 		snapstate.RegisterAffectedSnapsByKind("disconnect", connectDisconnectAffectedSnaps)
+		snapstate.RegisterAffectedSnapsByKind("transition-snap-connections", transitionAffectedSnaps)
 
 		// hook into snap linking/unlinking and activation state changes
 		snapstate.AddLinkSnapParticipant(snapstate.LinkSnapParticipantFunc(OnSnapLinkageChanged))
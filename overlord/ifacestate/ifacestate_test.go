@@ -51,6 +51,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
+	apparmor_sandbox "github.com/snapcore/snapd/sandbox/apparmor"
 	seccomp_compiler "github.com/snapcore/snapd/sandbox/seccomp"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -254,6 +255,8 @@ func (s *interfaceManagerSuite) SetUpTest(c *C) {
 	s.log = buf
 
 	s.BaseTest.AddCleanup(ifacestate.MockConnectRetryTimeout(0))
+	// the denial monitor is mocked where it is used
+	s.BaseTest.AddCleanup(apparmor_sandbox.MockLevel(apparmor_sandbox.Unsupported))
 	restore = seccomp_compiler.MockCompilerVersionInfo("abcdef 1.2.3 1234abcd -")
 	s.BaseTest.AddCleanup(restore)
 
@@ -1424,6 +1427,101 @@ slots:
 	check(change)
 }
 
+func (s *interfaceManagerSuite) TestConnectInstalledTask(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	t := ifacestate.ConnectInstalled(s.state, []*interfaces.ConnRef{{
+		// the slot is resolved once the snaps are installed
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer"},
+	}})
+	change.AddTask(t)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+	var kinds []string
+	for _, t := range change.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, DeepEquals, []string{"connect-installed", "run-hook", "run-hook", "connect", "run-hook", "run-hook"})
+
+	repo := s.manager(c).Repository()
+	ifaces := repo.Interfaces()
+	c.Check(ifaces.Connections, DeepEquals, []*interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"}}})
+}
+
+func (s *interfaceManagerSuite) TestConnectInstalledTaskNoSuchPlug(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	t := ifacestate.ConnectInstalled(s.state, []*interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "whatplug"},
+	}})
+	change.AddTask(t)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Err(), ErrorMatches, `(?s).*snap "consumer" has no plug named "whatplug".*`)
+	c.Check(change.Tasks(), HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestSetupProfilesForSnapIDs(c *C) {
+	s.MockModel(c, nil)
+
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.MockSnapDecl(c, "consumer", "one-publisher", nil)
+	consumer := s.mockSnap(c, consumerYaml)
+	s.MockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	names, ts, err := ifacestate.SetupProfiles(s.state, []string{consumer.SnapID, "unknownsnapidididididididididid"})
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"consumer"})
+	c.Assert(ts.Tasks(), HasLen, 1)
+	c.Check(ts.Tasks()[0].Kind(), Equals, "setup-profiles")
+	change := s.state.NewChange("setup-profiles", "...")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+	// only the profiles of the snap were set up again
+	c.Assert(s.secBackend.SetupCalls, HasLen, 1)
+	c.Check(s.secBackend.SetupCalls[0].SnapInfo.InstanceName(), Equals, "consumer")
+}
+
 func (s *interfaceManagerSuite) TestConnectTaskCheckDeviceScopeNoStore(c *C) {
 	s.MockModel(c, nil)
 
@@ -5892,6 +5990,110 @@ slots:
 	c.Check(tConnectPlug.Status(), Equals, state.DoneStatus)
 }
 
+func (s *interfaceManagerSuite) TestAutoconnectPendingUntilDefaultContentProviderInstalled(c *C) {
+	s.MockModel(c, nil)
+	// content is auto-connected between snaps of the same publisher
+	s.MockSnapDecl(c, "snap-content-plug", "one-publisher", nil)
+	s.MockSnapDecl(c, "snap-content-slot", "one-publisher", nil)
+
+	s.mockSnap(c, `name: snap-content-plug
+version: 1
+plugs:
+ shared-content-plug:
+  interface: content
+  default-provider: snap-content-slot
+  content: shared-content
+`)
+	s.manager(c)
+
+	autoConnect := func(snapName string) {
+		s.state.Lock()
+		chg := s.state.NewChange("install", "...")
+		t := s.state.NewTask("auto-connect", "...")
+		t.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{
+				Revision: snap.R(1),
+				RealName: snapName},
+		})
+		chg.AddTask(t)
+		s.state.Unlock()
+
+		s.settle(c)
+
+		s.state.Lock()
+		defer s.state.Unlock()
+		c.Assert(chg.Err(), IsNil)
+	}
+
+	// the default provider is not installed yet
+	autoConnect("snap-content-plug")
+
+	s.state.Lock()
+	pending, err := ifacestate.PendingAutoConnections(s.state)
+	s.state.Unlock()
+	c.Assert(err, IsNil)
+	c.Check(pending, DeepEquals, map[string]ifacestate.PendingAutoConnection{
+		"snap-content-plug:shared-content-plug": {
+			Interface: "content",
+			Reason:    `default provider "snap-content-slot" is not installed`,
+		},
+	})
+
+	// the provider appears and the plug is connected
+	slotInfo := s.mockSnap(c, `name: snap-content-slot
+version: 1
+slots:
+ shared-content-slot:
+  interface: content
+  content: shared-content
+`)
+	c.Assert(s.manager(c).Repository().AddSnap(slotInfo), IsNil)
+	autoConnect("snap-content-slot")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	pending, err = ifacestate.PendingAutoConnections(s.state)
+	c.Assert(err, IsNil)
+	c.Check(pending, HasLen, 0)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, HasLen, 1)
+	c.Check(conns["snap-content-plug:shared-content-plug snap-content-slot:shared-content-slot"], NotNil)
+}
+
+func (s *interfaceManagerSuite) TestPendingAutoConnectionsDroppedOnDiscardConns(c *C) {
+	s.mockSnap(c, sampleSnapYaml)
+	s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("pending-auto-connections", map[string]interface{}{
+		"snap:network":  map[string]interface{}{"interface": "network", "reason": "foo"},
+		"other:network": map[string]interface{}{"interface": "network", "reason": "bar"},
+	})
+	snapstate.Set(s.state, "snap", nil)
+
+	chg := s.state.NewChange("remove", "...")
+	t := s.state.NewTask("discard-conns", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "snap",
+		},
+	})
+	chg.AddTask(t)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	pending, err := ifacestate.PendingAutoConnections(s.state)
+	c.Assert(err, IsNil)
+	c.Check(pending, DeepEquals, map[string]ifacestate.PendingAutoConnection{
+		"other:network": {Interface: "network", Reason: "bar"},
+	})
+}
+
 func (s *interfaceManagerSuite) TestAutoconnectForDefaultContentProviderWrongOrderWaitChain(c *C) {
 	s.MockModel(c, nil)
 
@@ -8329,6 +8531,104 @@ func (s *interfaceManagerSuite) TestTransitionConnectionsCoreMigration(c *C) {
 	c.Assert(repoConns, HasLen, 0)
 }
 
+const renamedSnapYaml = `name: renamed
+version: 1
+plugs:
+ plug:
+  interface: test
+ otherplug:
+  interface: test
+`
+
+const renamedSuccessorSnapYaml = `name: renamed-ng
+version: 1
+plugs:
+ plug:
+  interface: test
+ otherplug:
+  interface: test2
+`
+
+func (s *interfaceManagerSuite) TestTransitionSnapConnectionsDoUndo(c *C) {
+	s.mockIfaces(&ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, producerYaml)
+	s.mockSnap(c, renamedSnapYaml)
+	s.mockSnap(c, renamedSuccessorSnapYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"renamed:plug producer:slot":      map[string]interface{}{"interface": "test"},
+		"renamed:otherplug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+
+	repo := s.manager(c).Repository()
+
+	s.state.Lock()
+	chg := s.state.NewChange("refresh", "")
+	t := s.state.NewTask("transition-snap-connections", "")
+	t.Set("old-name", "renamed")
+	t.Set("new-name", "renamed-ng")
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	c.Assert(chg.Err(), IsNil)
+	// the connection of the plug which changed interface is dropped
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"renamed-ng:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	repoConns, err := repo.Connections("renamed")
+	c.Assert(err, IsNil)
+	c.Check(repoConns, HasLen, 0)
+	repoConns, err = repo.Connections("renamed-ng")
+	c.Assert(err, IsNil)
+	c.Check(repoConns, HasLen, 1)
+
+	var setupSnaps []string
+	for _, call := range s.secBackend.SetupCalls {
+		setupSnaps = append(setupSnaps, call.SnapInfo.InstanceName())
+	}
+	c.Check(setupSnaps, testutil.DeepUnsortedMatches, []string{"producer", "renamed-ng"})
+
+	// now undo
+	chg = s.state.NewChange("refresh", "")
+	t = s.state.NewTask("transition-snap-connections", "")
+	t.Set("old-name", "renamed")
+	t.Set("new-name", "renamed-ng")
+	chg.AddTask(t)
+	terr := s.state.NewTask("error-trigger", "provoking undo")
+	terr.WaitFor(t)
+	chg.AddTask(terr)
+	s.state.Set("conns", map[string]interface{}{
+		"renamed:plug producer:slot":      map[string]interface{}{"interface": "test"},
+		"renamed:otherplug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.UndoneStatus)
+	conns = nil
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"renamed:plug producer:slot":      map[string]interface{}{"interface": "test"},
+		"renamed:otherplug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	repoConns, err = repo.Connections("renamed")
+	c.Assert(err, IsNil)
+	c.Check(repoConns, HasLen, 2)
+	repoConns, err = repo.Connections("renamed-ng")
+	c.Assert(err, IsNil)
+	c.Check(repoConns, HasLen, 0)
+}
+
 func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsDeclBasedAnySlotsPerPlugPlugSide(c *C) {
 	s.MockModel(c, nil)
 
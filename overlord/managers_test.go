@@ -9089,6 +9089,8 @@ WantedBy=multi-user.target
 
 	// mock a restart of snapd to progress with the change
 	restart.MockPending(st, restart.RestartUnset)
+	// and that the new snapd passed its self-test
+	c.Assert(snapstate.MarkSnapdRevisionGood(st), IsNil)
 
 	// let the change run its course
 	st.Unlock()
@@ -9331,6 +9333,8 @@ WantedBy=multi-user.target
 
 	// mock a restart of snapd to progress with the change
 	restart.MockPending(st, restart.RestartUnset)
+	// and that the new snapd passed its self-test
+	c.Assert(snapstate.MarkSnapdRevisionGood(st), IsNil)
 
 	// let the change try to run its course
 	st.Unlock()
@@ -9363,6 +9367,8 @@ WantedBy=multi-user.target
 	// the manager ensure loop doesn't fail after we restart since the unit
 	// files don't need to be rewritten
 	restart.MockPending(st, restart.RestartUnset)
+	// and that the new snapd passed its self-test
+	c.Assert(snapstate.MarkSnapdRevisionGood(st), IsNil)
 
 	// we want the service ensure loop to run again to show it doesn't break
 	// anything
@@ -9506,6 +9512,8 @@ volumes:
 
 		// simulate successful daemon restart happened
 		restart.MockPending(st, restart.RestartUnset)
+		// and that the new snapd passed its self-test
+		c.Assert(snapstate.MarkSnapdRevisionGood(st), IsNil)
 
 		// let the change run its course
 		st.Unlock()
@@ -9653,6 +9661,8 @@ func (s *mgrsSuite) testNonUC20RunUpdateManagedBootConfig(c *C, snapPath string,
 
 	// simulate successful restart happened
 	restart.MockPending(st, restart.RestartUnset)
+	// and that the new snapd passed its self-test
+	c.Assert(snapstate.MarkSnapdRevisionGood(st), IsNil)
 	if si.RealName == "core" {
 		// pretend we switched to a new core
 		bl.SetBootVars(map[string]string{
@@ -10437,6 +10447,8 @@ base: core20
 	c.Assert(ok, Equals, true)
 	c.Assert(rst, Equals, restart.RestartDaemon)
 	restart.MockPending(st, restart.RestartUnset)
+	// and that the new snapd passed its self-test
+	c.Assert(snapstate.MarkSnapdRevisionGood(st), IsNil)
 
 	autoConnectStatus := func(inDoing string, done []string) {
 		autoConnectCount := 0
@@ -10569,6 +10581,8 @@ base: core20
 	c.Assert(ok, Equals, true)
 	c.Assert(rst, Equals, restart.RestartDaemon)
 	restart.MockPending(st, restart.RestartUnset)
+	// and that the new snapd passed its self-test
+	c.Assert(snapstate.MarkSnapdRevisionGood(st), IsNil)
 
 	st.Unlock()
 	err = s.o.Settle(settleTimeout)
@@ -10667,6 +10681,8 @@ base: core20
 	c.Assert(ok, Equals, true)
 	c.Assert(rst, Equals, restart.RestartDaemon)
 	restart.MockPending(st, restart.RestartUnset)
+	// and that the new snapd passed its self-test
+	c.Assert(snapstate.MarkSnapdRevisionGood(st), IsNil)
 
 	st.Unlock()
 	err = s.o.Settle(settleTimeout)
@@ -21,8 +21,10 @@
 package overlord
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -34,6 +36,7 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
@@ -95,6 +98,8 @@ type Overlord struct {
 
 	startOfOperationTime time.Time
 
+	readiness *readinessTracker
+
 	// managers
 	inited     bool
 	startedUp  bool
@@ -118,7 +123,8 @@ var storeNew = store.New
 // It can be provided with an optional restart.Handler.
 func New(restartHandler restart.Handler) (*Overlord, error) {
 	o := &Overlord{
-		inited: true,
+		inited:    true,
+		readiness: newReadinessTracker(),
 	}
 
 	backend := &overlordStateBackend{
@@ -258,7 +264,7 @@ func lockWithTimeout(l *osutil.FileLock, timeout time.Duration) error {
 	}
 }
 
-func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handler) (*state.State, *restart.RestartManager, error) {
+func (o *Overlord) loadState(backend *overlordStateBackend, restartHandler restart.Handler) (*state.State, *restart.RestartManager, error) {
 	flock, err := initStateFileLock()
 	if err != nil {
 		return nil, nil, fmt.Errorf("fatal: error opening lock file: %v", err)
@@ -277,6 +283,14 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 		return nil, nil, fmt.Errorf("fatal: cannot find current boot id: %v", err)
 	}
 
+	if features.EncryptedState.IsEnabled() {
+		// refuse to run rather than keep the sensitive parts of the
+		// state in the clear
+		if _, err := backend.encryptionKey(); err != nil {
+			return nil, nil, fmt.Errorf("cannot encrypt state: %v", err)
+		}
+	}
+
 	perfTimings := timings.New(map[string]string{"startup": "load-state"})
 
 	if !osutil.FileExists(dirs.SnapStateFile) {
@@ -295,15 +309,19 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 		return s, restartMgr, nil
 	}
 
-	r, err := os.Open(dirs.SnapStateFile)
+	data, err := ioutil.ReadFile(dirs.SnapStateFile)
 	if err != nil {
 		return nil, nil, fmt.Errorf("cannot read the state file: %s", err)
 	}
-	defer r.Close()
+	// sensitive parts of the state may be encrypted at rest
+	data, err = backend.decrypt(data)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var s *state.State
 	timings.Run(perfTimings, "read-state", "read snapd state from disk", func(tm timings.Measurer) {
-		s, err = state.ReadState(backend, r)
+		s, err = state.ReadState(backend, bytes.NewReader(data))
 	})
 	if err != nil {
 		return nil, nil, err
@@ -317,7 +335,19 @@ func (o *Overlord) loadState(backend state.Backend, restartHandler restart.Handl
 		return nil, nil, err
 	}
 
-	// one-shot migrations
+	// one-shot migrations, validated on a copy of the state first such
+	// that a failing patch does not leave the state half patched
+	pending, err := patch.DryRun(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pending {
+		// keep the state as it was before patching for
+		// snap debug migrate-state --rollback
+		if err := patch.Backup(dirs.SnapStateFile); err != nil {
+			return nil, nil, err
+		}
+	}
 	err = patch.Apply(s)
 	if err != nil {
 		return nil, nil, err
@@ -343,12 +373,28 @@ func (o *Overlord) newStoreWithContext(storeCtx store.DeviceAndAuthContext) snap
 // The device backend will tie them to the remodeling device state.
 func (o *Overlord) newStore(devBE storecontext.DeviceBackend) snapstate.StoreService {
 	scb := o.deviceMgr.StoreContextBackend()
-	stoCtx := storecontext.NewComposed(o.State(), devBE, scb, scb)
+	stoCtx := storecontext.NewComposed(o.State(), devBE, scb, scb, scb)
 	return o.newStoreWithContext(stoCtx)
 }
 
-// StartUp proceeds to run any expensive Overlord or managers initialization. After this is done once it is a noop.
+// StartUp proceeds to run any expensive Overlord or managers initialization,
+// including the deferred one. After this is done once it is a noop.
 func (o *Overlord) StartUp() error {
+	if err := o.startUp(); err != nil {
+		return err
+	}
+	return o.deferredStartUp()
+}
+
+// LazyStartUp proceeds to run the Overlord or managers initialization
+// needed before serving the API, the deferred initialization of the
+// managers is run by Loop before ensuring the state the first time.
+// Readiness reports the progress. After this is done once it is a noop.
+func (o *Overlord) LazyStartUp() error {
+	return o.startUp()
+}
+
+func (o *Overlord) startUp() error {
 	if o.startedUp {
 		return nil
 	}
@@ -376,7 +422,25 @@ func (o *Overlord) StartUp() error {
 		}
 	}
 
-	return o.stateEng.StartUp()
+	if err := o.stateEng.StartUp(); err != nil {
+		return err
+	}
+	o.readiness.set(ReadinessInitializing, nil)
+	return nil
+}
+
+func (o *Overlord) deferredStartUp() error {
+	switch o.readiness.get().State {
+	case ReadinessReady, ReadinessDegraded:
+		// already done
+		return nil
+	}
+	if err := o.stateEng.DeferredStartUp(); err != nil {
+		o.readiness.set(ReadinessDegraded, err)
+		return err
+	}
+	o.readiness.set(ReadinessReady, nil)
+	return nil
 }
 
 // StartupTimeout computes a usable timeout for the startup
@@ -454,6 +518,12 @@ func (o *Overlord) Loop() {
 		o.loopTomb = new(tomb.Tomb)
 	}
 	o.loopTomb.Go(func() error {
+		// errors are reported through Readiness, carry on with
+		// what could be initialized unless preseeding
+		if err := o.deferredStartUp(); err != nil && preseed {
+			o.State().Lock()
+			preseedExitWithError(err)
+		}
 		for {
 			// TODO: pass a proper context into Ensure
 			o.ensureTimerReset()
@@ -482,6 +552,8 @@ func (o *Overlord) Loop() {
 				}
 				st := o.State()
 				st.Lock()
+				// record the changes before they get pruned
+				o.persistTimings()
 				st.Prune(o.startOfOperationTime, pruneWait, abortWait, pruneMaxChanges)
 				st.Unlock()
 			}
@@ -506,6 +578,12 @@ func (o *Overlord) Stop() error {
 		err = o.loopTomb.Wait()
 	}
 	o.stateEng.Stop()
+	if o.loopTomb != nil && !snapdenv.Preseeding() {
+		st := o.State()
+		st.Lock()
+		o.persistTimings()
+		st.Unlock()
+	}
 	if o.stateFLock != nil {
 		// This will also unlock the file
 		o.stateFLock.Close()
@@ -684,7 +762,8 @@ func Mock() *Overlord {
 // disk. Managers can be added with AddManager. For testing.
 func MockWithState(s *state.State) *Overlord {
 	o := &Overlord{
-		inited: false,
+		inited:    false,
+		readiness: newReadinessTracker(),
 	}
 	if s == nil {
 		s = state.New(mockBackend{o: o})
@@ -29,6 +29,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"syscall"
 	"testing"
 	"time"
@@ -264,6 +265,46 @@ func (ovs *overlordSuite) TestNewWithPatches(c *C) {
 
 	c.Assert(state.Get("patched2", &b), IsNil)
 	c.Check(b, Equals, true)
+
+	// the state from before patching was kept
+	c.Check(patch.BackupFile(dirs.SnapStateFile), testutil.FileEquals, fakeState)
+}
+
+func (ovs *overlordSuite) TestNewWithPatchesNothingToDoNoBackup(c *C) {
+	restore := patch.Mock(1, 0, map[int][]patch.PatchFunc{1: {func(s *state.State) error { return nil }}})
+	defer restore()
+
+	fakeState := []byte(`{"data":{"patch-level":1, "patch-sublevel":0}}`)
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	_, err = overlord.New(nil)
+	c.Assert(err, IsNil)
+
+	c.Check(patch.BackupFile(dirs.SnapStateFile), testutil.FileAbsent)
+}
+
+func (ovs *overlordSuite) TestNewWithFailingPatchDoesNotTouchState(c *C) {
+	p := func(s *state.State) error {
+		s.Set("patched", true)
+		return nil
+	}
+	sp := func(s *state.State) error {
+		return fmt.Errorf("boom")
+	}
+	restore := patch.Mock(1, 1, map[int][]patch.PatchFunc{1: {p, sp}})
+	defer restore()
+
+	fakeState := []byte(`{"data":{"patch-level":0, "patch-sublevel":0}}`)
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	_, err = overlord.New(nil)
+	c.Assert(err, ErrorMatches, `cannot patch system state to level 1, sublevel 1: boom`)
+
+	// the first patch was not written to disk
+	c.Check(dirs.SnapStateFile, testutil.FileEquals, fakeState)
+	c.Check(patch.BackupFile(dirs.SnapStateFile), testutil.FileAbsent)
 }
 
 func (ovs *overlordSuite) TestNewFailedConfigstate(c *C) {
@@ -394,6 +435,118 @@ func (ovs *overlordSuite) TestEnsureLoopRunAndStop(c *C) {
 	c.Check(witness.startedUp, Equals, 1)
 }
 
+type deferredWitnessManager struct {
+	witnessManager
+	calls         []string
+	deferredError error
+}
+
+func (wm *deferredWitnessManager) DeferredStartUp() error {
+	wm.calls = append(wm.calls, "deferred-startup")
+	return wm.deferredError
+}
+
+func (wm *deferredWitnessManager) Ensure() error {
+	if len(wm.calls) == 0 || wm.calls[len(wm.calls)-1] != "ensure" {
+		wm.calls = append(wm.calls, "ensure")
+	}
+	return wm.witnessManager.Ensure()
+}
+
+func (ovs *overlordSuite) TestStartUpReadiness(c *C) {
+	o := overlord.Mock()
+
+	witness := &deferredWitnessManager{
+		witnessManager: witnessManager{state: o.State()},
+	}
+	o.AddManager(witness)
+
+	c.Check(o.Readiness().State, Equals, overlord.ReadinessStarting)
+
+	err := o.StartUp()
+	c.Assert(err, IsNil)
+	c.Check(witness.startedUp, Equals, 1)
+	c.Check(witness.calls, DeepEquals, []string{"deferred-startup"})
+	readiness := o.Readiness()
+	c.Check(readiness.State, Equals, overlord.ReadinessReady)
+	c.Check(readiness.Since.IsZero(), Equals, false)
+	c.Check(readiness.Err, IsNil)
+
+	// noop
+	err = o.StartUp()
+	c.Assert(err, IsNil)
+	c.Check(witness.startedUp, Equals, 1)
+	c.Check(witness.calls, HasLen, 1)
+	c.Check(o.Readiness(), DeepEquals, readiness)
+}
+
+func (ovs *overlordSuite) TestLazyStartUpDefersToLoop(c *C) {
+	restoreIntv := overlord.MockEnsureInterval(10 * time.Millisecond)
+	defer restoreIntv()
+	o := overlord.Mock()
+
+	witness := &deferredWitnessManager{
+		witnessManager: witnessManager{
+			state:          o.State(),
+			expectedEnsure: 2,
+			ensureCalled:   make(chan struct{}),
+		},
+	}
+	o.AddManager(witness)
+
+	err := o.LazyStartUp()
+	c.Assert(err, IsNil)
+	c.Check(witness.startedUp, Equals, 1)
+	c.Check(witness.calls, HasLen, 0)
+	c.Check(o.Readiness().State, Equals, overlord.ReadinessInitializing)
+
+	o.Loop()
+	defer o.Stop()
+
+	select {
+	case <-witness.ensureCalled:
+	case <-time.After(2 * time.Second):
+		c.Fatal("Ensure calls not happening")
+	}
+	c.Assert(o.Stop(), IsNil)
+
+	// the deferred startup happened before ensuring
+	c.Check(witness.calls, DeepEquals, []string{"deferred-startup", "ensure"})
+	c.Check(o.Readiness().State, Equals, overlord.ReadinessReady)
+}
+
+func (ovs *overlordSuite) TestLazyStartUpDeferredError(c *C) {
+	restoreIntv := overlord.MockEnsureInterval(10 * time.Millisecond)
+	defer restoreIntv()
+	o := overlord.Mock()
+
+	witness := &deferredWitnessManager{
+		witnessManager: witnessManager{
+			state:          o.State(),
+			expectedEnsure: 2,
+			ensureCalled:   make(chan struct{}),
+		},
+		deferredError: errors.New("boom"),
+	}
+	o.AddManager(witness)
+
+	c.Assert(o.LazyStartUp(), IsNil)
+	o.Loop()
+	defer o.Stop()
+
+	// ensuring carries on
+	select {
+	case <-witness.ensureCalled:
+	case <-time.After(2 * time.Second):
+		c.Fatal("Ensure calls not happening")
+	}
+	c.Assert(o.Stop(), IsNil)
+
+	readiness := o.Readiness()
+	c.Check(readiness.State, Equals, overlord.ReadinessDegraded)
+	c.Check(readiness.Err, ErrorMatches, `state startup errors: \[boom\]`)
+}
+
 func (ovs *overlordSuite) TestEnsureLoopMediatedEnsureBeforeImmediate(c *C) {
 	restoreIntv := overlord.MockEnsureInterval(10 * time.Minute)
 	defer restoreIntv()
@@ -1382,3 +1535,57 @@ func (ovs *overlordSuite) TestLockWithTimeoutFailed(c *C) {
 		"EXTEND_TIMEOUT_USEC=5000",
 	})
 }
+
+func (ovs *overlordSuite) TestPersistTimings(c *C) {
+	restore := overlord.MockOsutilBootID(func() (string, error) {
+		return "8f0b9a6e-55a3-4c6e-a0e0-1ed4bb4b01a1", nil
+	})
+	defer restore()
+	defer func(old time.Duration) { timings.DurationThreshold = old }(timings.DurationThreshold)
+	timings.DurationThreshold = 0
+
+	o := overlord.Mock()
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg1 := st.NewChange("refresh-snap", "...")
+	chg1.SetStatus(state.DoneStatus)
+	st.NewChange("install-snap", "...")
+
+	tm := timings.New(map[string]string{"ensure": "auto-refresh"})
+	sp := tm.StartSpan("span", "...")
+	sp.Stop()
+	tm.Save(st)
+
+	o.PersistTimings()
+
+	records, err := timings.ReadRecords(dirs.SnapTimingsDBFile)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 2)
+	kinds := []string{records[0].Kind, records[1].Kind}
+	sort.Strings(kinds)
+	c.Check(kinds, DeepEquals, []string{"change:refresh-snap", "ensure:auto-refresh"})
+	for _, rec := range records {
+		c.Check(rec.BootID, Equals, "8f0b9a6e-55a3-4c6e-a0e0-1ed4bb4b01a1")
+		if rec.Kind == "change:refresh-snap" {
+			c.Check(rec.Duration, Equals, chg1.ReadyTime().Sub(chg1.SpawnTime()))
+		}
+	}
+
+	// nothing is recorded twice
+	o.PersistTimings()
+	records, err = timings.ReadRecords(dirs.SnapTimingsDBFile)
+	c.Assert(err, IsNil)
+	c.Check(records, HasLen, 2)
+
+	// newly ready changes are recorded
+	time.Sleep(time.Millisecond)
+	chg3 := st.NewChange("seed", "...")
+	chg3.SetStatus(state.DoneStatus)
+	o.PersistTimings()
+	records, err = timings.ReadRecords(dirs.SnapTimingsDBFile)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	c.Check(records[2].Kind, Equals, "change:seed")
+}
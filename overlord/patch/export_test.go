@@ -88,3 +88,16 @@ func Patch6SnapSetup(task *state.Task) (patch6SnapSetup, error) {
 	err := task.Get("snap-setup", &snapsup)
 	return snapsup, err
 }
+
+// MockSideEffectPatch marks the patch at the given level and sublevel as
+// changing the system besides the state.
+func MockSideEffectPatch(level, sublevel int) (restore func()) {
+	ref := patchRef{level, sublevel}
+	old := sideEffectPatches[ref]
+	sideEffectPatches[ref] = true
+	return func() {
+		if !old {
+			delete(sideEffectPatches, ref)
+		}
+	}
+}
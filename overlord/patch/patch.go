@@ -20,10 +20,14 @@
 package patch
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snapdtool"
 )
@@ -41,6 +45,14 @@ type PatchFunc func(s *state.State) error
 // patches maps from patch level L to the list of sublevel patches.
 var patches = make(map[int][]PatchFunc)
 
+type patchRef struct {
+	level, sublevel int
+}
+
+// sideEffectPatches are the patches which change the system besides the
+// state, those are not run by DryRun.
+var sideEffectPatches = make(map[patchRef]bool)
+
 // Init initializes an empty state to the current implemented patch level.
 func Init(s *state.State) {
 	s.Lock()
@@ -58,12 +70,16 @@ func Init(s *state.State) {
 
 // applySublevelPatches applies all sublevel patches for given level, starting
 // from firstSublevel index.
-func applySublevelPatches(level, firstSublevel int, s *state.State) error {
+func applySublevelPatches(level, firstSublevel int, s *state.State, dryRun bool) error {
 	for sublevel := firstSublevel; sublevel < len(patches[level]); sublevel++ {
 		if sublevel > 0 {
 			logger.Noticef("Patching system state level %d to sublevel %d...", level, sublevel)
 		}
-		err := applyOne(patches[level][sublevel], s, level, sublevel)
+		patch := patches[level][sublevel]
+		if dryRun && sideEffectPatches[patchRef{level, sublevel}] {
+			patch = func(*state.State) error { return nil }
+		}
+		err := applyOne(patch, s, level, sublevel)
 		if err != nil {
 			logger.Noticef("Cannot patch: %v", err)
 			return fmt.Errorf("cannot patch system state to level %d, sublevel %d: %v", level, sublevel, err)
@@ -97,6 +113,10 @@ func maybeResetSublevelForLevel60(s *state.State, sublevel *int) error {
 // Apply applies any necessary patches to update the provided state to
 // conventions required by the current patch level of the system.
 func Apply(s *state.State) error {
+	return apply(s, false)
+}
+
+func apply(s *state.State, dryRun bool) error {
 	var stateLevel, stateSublevel int
 	s.Lock()
 	err := s.Get("patch-level", &stateLevel)
@@ -137,7 +157,7 @@ func Apply(s *state.State) error {
 	// the 0th sublevel patch is a patch for major level update (e.g. 7.0),
 	// therefore there is +1 for the indices.
 	if stateSublevel+1 < len(patches[stateLevel]) {
-		if err := applySublevelPatches(stateLevel, stateSublevel+1, s); err != nil {
+		if err := applySublevelPatches(stateLevel, stateSublevel+1, s, dryRun); err != nil {
 			return err
 		}
 	}
@@ -149,7 +169,7 @@ func Apply(s *state.State) error {
 		if sublevels == nil {
 			return fmt.Errorf("cannot upgrade: snapd is too new for the current system state (patch level %d)", level-1)
 		}
-		if err := applySublevelPatches(level, 0, s); err != nil {
+		if err := applySublevelPatches(level, 0, s, dryRun); err != nil {
 			return err
 		}
 	}
@@ -162,6 +182,96 @@ func Apply(s *state.State) error {
 	return nil
 }
 
+// behind returns whether the state is at a lower patch level or sublevel
+// than the implemented ones, or is reset to a lower sublevel by Apply.
+func behind(s *state.State) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var stateLevel, stateSublevel int
+	if err := s.Get("patch-level", &stateLevel); err != nil && !errors.Is(err, state.ErrNoState) {
+		return false, err
+	}
+	if err := s.Get("patch-sublevel", &stateSublevel); err != nil && !errors.Is(err, state.ErrNoState) {
+		return false, err
+	}
+	if stateLevel != Level {
+		return stateLevel < Level, nil
+	}
+	if stateSublevel < Sublevel {
+		return true, nil
+	}
+	// see maybeResetSublevelForLevel60
+	if stateLevel == 6 && stateSublevel > 0 {
+		var lastVersion string
+		if err := s.Get("patch-sublevel-last-version", &lastVersion); err != nil && !errors.Is(err, state.ErrNoState) {
+			return false, err
+		}
+		return lastVersion != snapdtool.Version, nil
+	}
+	return false, nil
+}
+
+// DryRun applies the patches the provided state is behind on to a copy of
+// it, leaving the state itself untouched, such that a failing patch does
+// not leave the state half patched. Patches which change the system besides
+// the state are skipped. It returns whether Apply will patch the state and
+// the error it would fail with, if any.
+func DryRun(s *state.State) (pending bool, err error) {
+	pending, err = behind(s)
+	if err != nil || !pending {
+		return false, err
+	}
+
+	s.Lock()
+	data, err := s.MarshalJSON()
+	s.Unlock()
+	if err != nil {
+		return false, err
+	}
+	scratch, err := state.ReadState(nil, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	if err := apply(scratch, true); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// BackupFile returns the path of the copy of the given state file taken
+// before patching it.
+func BackupFile(stateFile string) string {
+	return stateFile + ".pre-patch"
+}
+
+// Backup keeps a copy of the given state file, as found on disk, before the
+// state loaded from it is patched. The copy can be put back in place with
+// Rollback should the patched state turn out to be unusable.
+func Backup(stateFile string) error {
+	data, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		return fmt.Errorf("cannot back up the state file: %v", err)
+	}
+	if err := osutil.AtomicWriteFile(BackupFile(stateFile), data, 0600, 0); err != nil {
+		return fmt.Errorf("cannot back up the state file: %v", err)
+	}
+	return nil
+}
+
+// Rollback replaces the given state file with the copy taken by Backup
+// before it was last patched. snapd must not be running.
+func Rollback(stateFile string) error {
+	backup := BackupFile(stateFile)
+	if !osutil.FileExists(backup) {
+		return fmt.Errorf("cannot roll back: no backup of the state from before patching")
+	}
+	if err := os.Rename(backup, stateFile); err != nil {
+		return fmt.Errorf("cannot roll back: %v", err)
+	}
+	return nil
+}
+
 func applyOne(patch func(s *state.State) error, s *state.State, newLevel, newSublevel int) error {
 	s.Lock()
 	defer s.Unlock()
@@ -30,6 +30,8 @@ import (
 
 func init() {
 	patches[5] = []PatchFunc{patch5}
+	// services are restarted
+	sideEffectPatches[patchRef{5, 0}] = true
 }
 
 type log struct{}
@@ -21,6 +21,8 @@ package patch_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"sort"
 	"testing"
 
@@ -371,6 +373,160 @@ func (s *patchSuite) TestDifferentSnapdVersionPatchLevel6(c *C) {
 	s.testMaybeResetPatchLevel6(c, "snapd-version-1", "snapd-version-2", []int{61, 62})
 }
 
+func (s *patchSuite) TestDryRun(c *C) {
+	p12 := func(st *state.State) error {
+		st.Set("n", 1)
+		return nil
+	}
+	restore := patch.Mock(2, 0, map[int][]patch.PatchFunc{
+		2: {p12},
+	})
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	st.Set("patch-level", 1)
+	st.Unlock()
+
+	pending, err := patch.DryRun(st)
+	c.Assert(err, IsNil)
+	c.Check(pending, Equals, true)
+
+	// the state itself was not patched
+	st.Lock()
+	defer st.Unlock()
+	var level int
+	c.Assert(st.Get("patch-level", &level), IsNil)
+	c.Check(level, Equals, 1)
+	var n int
+	c.Check(st.Get("n", &n), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *patchSuite) TestDryRunNothingToDo(c *C) {
+	restore := patch.Mock(2, 0, nil)
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	st.Set("patch-level", 2)
+	st.Set("patch-sublevel", 0)
+	st.Unlock()
+
+	pending, err := patch.DryRun(st)
+	c.Assert(err, IsNil)
+	c.Check(pending, Equals, false)
+}
+
+func (s *patchSuite) TestDryRunError(c *C) {
+	p12 := func(st *state.State) error {
+		st.Set("n", 1)
+		return nil
+	}
+	p23 := func(st *state.State) error {
+		return fmt.Errorf("boom")
+	}
+	restore := patch.Mock(3, 0, map[int][]patch.PatchFunc{
+		2: {p12},
+		3: {p23},
+	})
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	st.Set("patch-level", 1)
+	st.Unlock()
+
+	_, err := patch.DryRun(st)
+	c.Assert(err, ErrorMatches, `cannot patch system state to level 3, sublevel 0: boom`)
+
+	// not even the patches that succeeded were applied
+	st.Lock()
+	defer st.Unlock()
+	var level int
+	c.Assert(st.Get("patch-level", &level), IsNil)
+	c.Check(level, Equals, 1)
+	var n int
+	c.Check(st.Get("n", &n), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *patchSuite) TestDryRunSkipsSideEffects(c *C) {
+	sideEffect := false
+	p12 := func(st *state.State) error {
+		sideEffect = true
+		return nil
+	}
+	p23 := func(st *state.State) error {
+		st.Set("n", 1)
+		return nil
+	}
+	restore := patch.Mock(3, 0, map[int][]patch.PatchFunc{
+		2: {p12},
+		3: {p23},
+	})
+	defer restore()
+	restore = patch.MockSideEffectPatch(2, 0)
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	st.Set("patch-level", 1)
+	st.Unlock()
+
+	pending, err := patch.DryRun(st)
+	c.Assert(err, IsNil)
+	c.Check(pending, Equals, true)
+	c.Check(sideEffect, Equals, false)
+
+	c.Assert(patch.Apply(st), IsNil)
+	c.Check(sideEffect, Equals, true)
+}
+
+func (s *patchSuite) TestDryRunSublevelAhead(c *C) {
+	p2 := func(st *state.State) error {
+		c.Fatalf("unexpected patch")
+		return nil
+	}
+	restore := patch.Mock(2, 0, map[int][]patch.PatchFunc{
+		2: {p2},
+	})
+	defer restore()
+
+	// a downgrade within the same level only updates the sublevel
+	st := state.New(nil)
+	st.Lock()
+	st.Set("patch-level", 2)
+	st.Set("patch-sublevel", 1)
+	st.Unlock()
+
+	pending, err := patch.DryRun(st)
+	c.Assert(err, IsNil)
+	c.Check(pending, Equals, false)
+}
+
+func (s *patchSuite) TestBackupAndRollback(c *C) {
+	stateFile := filepath.Join(c.MkDir(), "state.json")
+	c.Assert(ioutil.WriteFile(stateFile, []byte("before"), 0600), IsNil)
+
+	c.Assert(patch.Backup(stateFile), IsNil)
+	c.Check(patch.BackupFile(stateFile), testutil.FileEquals, "before")
+
+	c.Assert(ioutil.WriteFile(stateFile, []byte("after"), 0600), IsNil)
+
+	c.Assert(patch.Rollback(stateFile), IsNil)
+	c.Check(stateFile, testutil.FileEquals, "before")
+	c.Check(patch.BackupFile(stateFile), testutil.FileAbsent)
+
+	// there is nothing to roll back to anymore
+	err := patch.Rollback(stateFile)
+	c.Assert(err, ErrorMatches, "cannot roll back: no backup of the state from before patching")
+	c.Check(stateFile, testutil.FileEquals, "before")
+}
+
+func (s *patchSuite) TestBackupNoStateFile(c *C) {
+	err := patch.Backup(filepath.Join(c.MkDir(), "state.json"))
+	c.Assert(err, ErrorMatches, "cannot back up the state file: open .*: no such file or directory")
+}
+
 func (s *patchSuite) TestValidity(c *C) {
 	patches := patch.PatchesForTest()
 	levels := make([]int, 0, len(patches))
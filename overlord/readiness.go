@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord
+
+import (
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// ReadinessState describes how far the Overlord got in starting up.
+type ReadinessState string
+
+const (
+	// ReadinessStarting is the state until the managers started up.
+	ReadinessStarting ReadinessState = "starting"
+	// ReadinessInitializing is the state while the deferred
+	// initialization of the managers is performed, the API can be
+	// served already.
+	ReadinessInitializing ReadinessState = "initializing"
+	// ReadinessReady is the state once all the initialization is done.
+	ReadinessReady ReadinessState = "ready"
+	// ReadinessDegraded is the state when the deferred initialization
+	// of some managers failed.
+	ReadinessDegraded ReadinessState = "degraded"
+)
+
+// Readiness carries the current readiness state of the Overlord.
+type Readiness struct {
+	State ReadinessState
+	// Since is when the current state was entered.
+	Since time.Time
+	// Err is what made the Overlord degraded, if it is.
+	Err error
+}
+
+// startUpBudget is how long the startup of the managers needed before
+// serving the API is expected to take at most.
+var startUpBudget = 10 * time.Second
+
+type readinessTracker struct {
+	mu      sync.Mutex
+	created time.Time
+	current Readiness
+}
+
+func newReadinessTracker() *readinessTracker {
+	now := time.Now()
+	return &readinessTracker{
+		created: now,
+		current: Readiness{State: ReadinessStarting, Since: now},
+	}
+}
+
+func (rt *readinessTracker) get() Readiness {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.current
+}
+
+func (rt *readinessTracker) set(st ReadinessState, err error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.current.State == st {
+		return
+	}
+	now := time.Now()
+	rt.current = Readiness{State: st, Since: now, Err: err}
+	elapsed := now.Sub(rt.created)
+	switch st {
+	case ReadinessInitializing:
+		if elapsed > startUpBudget {
+			logger.Noticef("startup took %v, more than the expected %v", elapsed, startUpBudget)
+		} else {
+			logger.Debugf("startup took %v", elapsed)
+		}
+	case ReadinessReady:
+		logger.Debugf("ready after %v", elapsed)
+	case ReadinessDegraded:
+		logger.Noticef("cannot complete deferred startup after %v: %v", elapsed, err)
+	}
+}
+
+// Readiness returns how far the Overlord got in starting up.
+func (o *Overlord) Readiness() Readiness {
+	return o.readiness.get()
+}
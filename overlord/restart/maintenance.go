@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package restart
+
+import (
+	"os"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+const (
+	// MaintenanceDaemonRestart is the kind of maintenance for restarts of
+	// snapd itself.
+	MaintenanceDaemonRestart = "daemon-restart"
+	// MaintenanceSystemRestart is the kind of maintenance for reboots,
+	// halts and power offs of the system.
+	MaintenanceSystemRestart = "system-restart"
+)
+
+// PlannedMaintenance describes a restart of snapd or of the system planned
+// by snapd, so that other workloads on the system can prepare for it.
+type PlannedMaintenance struct {
+	// Kind is one of MaintenanceDaemonRestart or MaintenanceSystemRestart.
+	Kind string `json:"kind"`
+	// Action is one of "reboot", "halt" or "poweroff" for system restarts.
+	Action string `json:"action,omitempty"`
+	// RebootReason carries the cause of the restart.
+	RebootReason
+	// Time is when the restart is planned to happen.
+	Time time.Time `json:"time"`
+}
+
+// SystemRestartDelay returns how long snapd waits before carrying out a
+// system restart that was not requested to be immediate.
+func SystemRestartDelay() time.Duration {
+	delay := 1 * time.Minute
+	if ovr := os.Getenv("SNAPD_REBOOT_DELAY"); ovr != "" { // for tests
+		if d, err := time.ParseDuration(ovr); err == nil {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// maintenanceFor returns the maintenance planned for a restart of the given
+// type, or nil for restarts which do not disrupt the system.
+func maintenanceFor(t RestartType, reason *RebootReason) *PlannedMaintenance {
+	m := &PlannedMaintenance{Time: timeNow()}
+	switch t {
+	case RestartDaemon:
+		m.Kind = MaintenanceDaemonRestart
+	case RestartSystem:
+		m.Kind = MaintenanceSystemRestart
+		m.Time = m.Time.Add(SystemRestartDelay())
+	case RestartSystemNow, RestartSystemHaltNow, RestartSystemPoweroffNow:
+		m.Kind = MaintenanceSystemRestart
+	default:
+		// socket activation and stopping are not maintenance
+		return nil
+	}
+	if m.Kind == MaintenanceSystemRestart {
+		m.Action = rebootAction(t)
+	}
+	if reason != nil {
+		m.RebootReason = *reason
+	}
+	if m.Code == "" {
+		m.Code = RebootReasonUnknown
+	}
+	return m
+}
+
+// Maintenance returns the maintenance planned by the pending restart
+// request, if any.
+func Maintenance(st *state.State) *PlannedMaintenance {
+	cached := st.Cached(restartManagerKey{})
+	if cached == nil {
+		return nil
+	}
+	rm := cached.(*RestartManager)
+	return rm.maintenance
+}
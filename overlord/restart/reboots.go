@@ -0,0 +1,174 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package restart
+
+import (
+	"errors"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// RebootReasonCode identifies why snapd requested a reboot of the system.
+type RebootReasonCode string
+
+const (
+	// RebootReasonUnknown is used for requests that did not specify
+	// a reason.
+	RebootReasonUnknown RebootReasonCode = "unknown"
+	// RebootReasonKernelRefresh is used when a new kernel was installed.
+	RebootReasonKernelRefresh RebootReasonCode = "kernel-refresh"
+	// RebootReasonBaseRefresh is used when a new boot base was installed.
+	RebootReasonBaseRefresh RebootReasonCode = "base-refresh"
+	// RebootReasonGadgetRefresh is used when a new gadget requires
+	// a reboot as a boot participant.
+	RebootReasonGadgetRefresh RebootReasonCode = "gadget-refresh"
+	// RebootReasonGadgetAssetUpdate is used when the gadget assets
+	// were updated.
+	RebootReasonGadgetAssetUpdate RebootReasonCode = "gadget-asset-update"
+	// RebootReasonKernelCommandLineUpdate is used when the kernel command
+	// line was updated.
+	RebootReasonKernelCommandLineUpdate RebootReasonCode = "kernel-command-line-update"
+	// RebootReasonBootConfigUpdate is used when the managed boot
+	// configuration was updated.
+	RebootReasonBootConfigUpdate RebootReasonCode = "boot-config-update"
+	// RebootReasonRecoverySystem is used when trying a new recovery
+	// system.
+	RebootReasonRecoverySystem RebootReasonCode = "recovery-system"
+	// RebootReasonSystemBank is used when trying another system bank.
+	RebootReasonSystemBank RebootReasonCode = "system-bank"
+	// RebootReasonRemodel is used for any reboot requested while
+	// remodeling.
+	RebootReasonRemodel RebootReasonCode = "remodel"
+	// RebootReasonInstall is used at the end of the installation of
+	// the system.
+	RebootReasonInstall RebootReasonCode = "install"
+	// RebootReasonFactoryReset is used at the end of a factory reset.
+	RebootReasonFactoryReset RebootReasonCode = "factory-reset"
+	// RebootReasonSystemAction is used when switching system or mode,
+	// or rebooting and shutting down on request.
+	RebootReasonSystemAction RebootReasonCode = "system-action"
+	// RebootReasonServiceFailure is used when the services killed by
+	// a refresh of snapd could not be restarted.
+	RebootReasonServiceFailure RebootReasonCode = "service-failure"
+	// RebootReasonSnapdRefresh is used when snapd restarts into a new
+	// snapd, it is not the reason of system restarts.
+	RebootReasonSnapdRefresh RebootReasonCode = "snapd-refresh"
+)
+
+// maxRebootRecords is the number of reboot records kept in the state.
+const maxRebootRecords = 50
+
+// RebootReason describes why a reboot of the system was requested.
+type RebootReason struct {
+	Code RebootReasonCode `json:"code"`
+	// ChangeID and TaskID identify the task that requested the reboot,
+	// if any.
+	ChangeID string `json:"change-id,omitempty"`
+	TaskID   string `json:"task-id,omitempty"`
+	// Snap is the snap that caused the reboot, if any.
+	Snap string `json:"snap,omitempty"`
+}
+
+// RebootRecord tracks a reboot requested by snapd.
+type RebootRecord struct {
+	RebootReason
+	// Action is one of "reboot", "halt" or "poweroff".
+	Action      string    `json:"action"`
+	RequestTime time.Time `json:"request-time"`
+	// FromBootID is the boot id at the time of the request.
+	FromBootID string `json:"from-boot-id"`
+	// RebootTime is set when snapd started again after the requested
+	// reboot happened.
+	RebootTime *time.Time `json:"reboot-time,omitempty"`
+}
+
+// Pending returns whether the requested reboot did not happen yet.
+func (r *RebootRecord) Pending() bool {
+	return r.RebootTime == nil
+}
+
+var timeNow = time.Now
+
+func rebootAction(t RestartType) string {
+	switch t {
+	case RestartSystemHaltNow:
+		return "halt"
+	case RestartSystemPoweroffNow:
+		return "poweroff"
+	default:
+		return "reboot"
+	}
+}
+
+// RebootRecords returns the most recent reboots requested by snapd,
+// oldest first. The records survive the reboots themselves.
+func RebootRecords(st *state.State) ([]*RebootRecord, error) {
+	var records []*RebootRecord
+	if err := st.Get("system-reboots", &records); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (rm *RestartManager) recordReboot(t RestartType, reason *RebootReason) error {
+	records, err := RebootRecords(rm.state)
+	if err != nil {
+		return err
+	}
+	rec := &RebootRecord{
+		Action:      rebootAction(t),
+		RequestTime: timeNow(),
+		FromBootID:  rm.bootID,
+	}
+	if reason != nil {
+		rec.RebootReason = *reason
+	}
+	if rec.Code == "" {
+		rec.Code = RebootReasonUnknown
+	}
+	records = append(records, rec)
+	if len(records) > maxRebootRecords {
+		records = records[len(records)-maxRebootRecords:]
+	}
+	rm.state.Set("system-reboots", records)
+	return nil
+}
+
+// markRebootsHappened sets the reboot time of pending records that were
+// requested during a previous boot.
+func (rm *RestartManager) markRebootsHappened() error {
+	records, err := RebootRecords(rm.state)
+	if err != nil {
+		return err
+	}
+	changed := false
+	now := timeNow()
+	for _, rec := range records {
+		if rec.Pending() && rec.FromBootID != rm.bootID {
+			rec.RebootTime = &now
+			changed = true
+		}
+	}
+	if changed {
+		rm.state.Set("system-reboots", records)
+	}
+	return nil
+}
@@ -73,8 +73,10 @@ type restartManagerKey struct{}
 type RestartManager struct {
 	state      *state.State
 	restarting RestartType
-	h          Handler
-	bootID     string
+	// maintenance is the maintenance planned by the pending restart
+	maintenance *PlannedMaintenance
+	h           Handler
+	bootID      string
 }
 
 // Manager returns a new restart manager and initializes the support
@@ -94,6 +96,9 @@ func Manager(st *state.State, curBootID string, h Handler) (*RestartManager, err
 		return nil, err
 	}
 	st.Cache(restartManagerKey{}, rm)
+	if err := rm.markRebootsHappened(); err != nil {
+		return nil, err
+	}
 	if err := rm.init(fromBootID, curBootID); err != nil {
 		return nil, err
 	}
@@ -241,12 +246,24 @@ func restartManager(st *state.State, errMsg string) *RestartManager {
 // Request asks for a restart of the managing process.
 // The state needs to be locked to request a restart.
 func Request(st *state.State, t RestartType, rebootInfo *boot.RebootInfo) {
+	RequestWithReason(st, t, nil, rebootInfo)
+}
+
+// RequestWithReason is like Request but for system restarts it also
+// records the given reason in the reboot history, see RebootRecords.
+// The reason is also reported with the planned maintenance, see Maintenance.
+// The state needs to be locked to request a restart.
+func RequestWithReason(st *state.State, t RestartType, reason *RebootReason, rebootInfo *boot.RebootInfo) {
 	rm := restartManager(st, "internal error: cannot request a restart before RestartManager initialization")
 	switch t {
 	case RestartSystem, RestartSystemNow, RestartSystemHaltNow, RestartSystemPoweroffNow:
 		st.Set("system-restart-from-boot-id", rm.bootID)
+		if err := rm.recordReboot(t, reason); err != nil {
+			logger.Noticef("cannot record reboot reason: %v", err)
+		}
 	}
 	rm.restarting = t
+	rm.maintenance = maintenanceFor(t, reason)
 	rm.handleRestart(t, rebootInfo)
 }
 
@@ -299,7 +316,17 @@ func notifyRebootRequiredClassic(rebootRequiredSnap string) error {
 // WaitStatus and return a marker error of type state.Wait.
 // The restart manager itself will then make sure to set the the status as
 // requested later on system restart to allow progress again.
-func FinishTaskWithRestart(task *state.Task, status state.Status, rt RestartType, snapName string, rebootInfo *boot.RebootInfo) error {
+// The reason code and the snap name are recorded together with the task
+// and change ids in the reboot history for system restarts.
+func FinishTaskWithRestart(task *state.Task, status state.Status, rt RestartType, code RebootReasonCode, snapName string, rebootInfo *boot.RebootInfo) error {
+	reason := &RebootReason{
+		Code:   code,
+		TaskID: task.ID(),
+		Snap:   snapName,
+	}
+	if chg := task.Change(); chg != nil {
+		reason.ChangeID = chg.ID()
+	}
 	// if a system restart is requested on classic set the task to wait
 	// instead or just log the request if we are on the undo path
 	switch rt {
@@ -307,6 +334,11 @@ func FinishTaskWithRestart(task *state.Task, status state.Status, rt RestartType
 		if release.OnClassic {
 			if status == state.DoneStatus {
 				rm := restartManager(task.State(), "internal error: cannot request a restart before RestartManager initialization")
+				// the reboot is left to the user but it is still
+				// one requested by snapd
+				if err := rm.recordReboot(rt, reason); err != nil {
+					logger.Noticef("cannot record reboot reason: %v", err)
+				}
 				// notify the system that a reboot is required
 				if err := notifyRebootRequiredClassic(snapName); err != nil {
 					logger.Noticef("cannot notify about pending reboot: %v", err)
@@ -326,7 +358,7 @@ func FinishTaskWithRestart(task *state.Task, status state.Status, rt RestartType
 		}
 	}
 	task.SetStatus(status)
-	Request(task.State(), rt, rebootInfo)
+	RequestWithReason(task.State(), rt, reason, rebootInfo)
 	return nil
 }
 
@@ -22,8 +22,10 @@ package restart_test
 import (
 	"bytes"
 	"errors"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -250,7 +252,7 @@ func (s *restartSuite) TestFinishTaskWithRestart(c *C) {
 		chg.AddTask(task)
 		task.SetStatus(t.initial)
 
-		err := restart.FinishTaskWithRestart(task, t.final, t.restartType, "some-snap", nil)
+		err := restart.FinishTaskWithRestart(task, t.final, t.restartType, restart.RebootReasonKernelRefresh, "some-snap", nil)
 		setStatus := t.final
 		if t.wait {
 			setStatus = state.WaitStatus
@@ -305,14 +307,14 @@ func (s *restartSuite) TestStartUpWaitTasks(c *C) {
 
 	t2 := st.NewTask("wait-for-reboot", "...")
 	chg.AddTask(t2)
-	err = restart.FinishTaskWithRestart(t2, state.DoneStatus, restart.RestartSystem, "some-snap", nil)
+	err = restart.FinishTaskWithRestart(t2, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelRefresh, "some-snap", nil)
 	c.Assert(err, FitsTypeOf, &state.Wait{})
 
 	restart.ReplaceBootID(st, "boot-id-2")
 
 	t3 := st.NewTask("wait-for-reboot-same-boot", "...")
 	chg.AddTask(t3)
-	err = restart.FinishTaskWithRestart(t3, state.DoneStatus, restart.RestartSystem, "some-snap", nil)
+	err = restart.FinishTaskWithRestart(t3, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelRefresh, "some-snap", nil)
 	c.Assert(err, FitsTypeOf, &state.Wait{})
 
 	c.Assert(chg.IsReady(), Equals, false)
@@ -374,7 +376,7 @@ func (s *restartSuite) TestPendingForSystemRestart(c *C) {
 	chg2.AddTask(t4)
 	t3.WaitFor(t2)
 	t4.WaitFor(t2)
-	err = restart.FinishTaskWithRestart(t2, state.DoneStatus, restart.RestartSystem, "some-snap", nil)
+	err = restart.FinishTaskWithRestart(t2, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelRefresh, "some-snap", nil)
 	c.Assert(err, FitsTypeOf, &state.Wait{})
 	t3.SetStatus(state.UndoStatus)
 	t4.SetStatus(state.WaitStatus)
@@ -389,7 +391,7 @@ func (s *restartSuite) TestPendingForSystemRestart(c *C) {
 	chg3.AddTask(t7)
 	t6.WaitFor(t5)
 	t7.WaitFor(t5)
-	err = restart.FinishTaskWithRestart(t6, state.DoneStatus, restart.RestartSystem, "some-snap", nil)
+	err = restart.FinishTaskWithRestart(t6, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelRefresh, "some-snap", nil)
 	c.Assert(err, FitsTypeOf, &state.Wait{})
 	t6.SetStatus(state.WaitStatus)
 	t7.SetStatus(state.DoStatus)
@@ -400,6 +402,149 @@ func (s *restartSuite) TestPendingForSystemRestart(c *C) {
 	c.Check(rm.PendingForSystemRestart(chg3), Equals, false)
 }
 
+func (s *restartSuite) TestRebootRecords(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	defer release.MockOnClassic(false)()
+
+	t0 := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	restore := restart.MockTimeNow(func() time.Time { return t0 })
+	defer restore()
+
+	_, err := restart.Manager(st, "boot-id-1", nil)
+	c.Assert(err, IsNil)
+
+	records, err := restart.RebootRecords(st)
+	c.Assert(err, IsNil)
+	c.Check(records, HasLen, 0)
+
+	// daemon restarts are not recorded
+	restart.Request(st, restart.RestartDaemon, nil)
+
+	chg := st.NewChange("refresh-snap", "...")
+	task := st.NewTask("link-snap", "...")
+	chg.AddTask(task)
+	err = restart.FinishTaskWithRestart(task, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelRefresh, "pc-kernel", nil)
+	c.Assert(err, IsNil)
+
+	restart.RequestWithReason(st, restart.RestartSystemPoweroffNow, &restart.RebootReason{Code: restart.RebootReasonSystemAction}, nil)
+	restart.Request(st, restart.RestartSystemNow, nil)
+
+	records, err = restart.RebootRecords(st)
+	c.Assert(err, IsNil)
+	c.Check(records, DeepEquals, []*restart.RebootRecord{{
+		RebootReason: restart.RebootReason{
+			Code:     restart.RebootReasonKernelRefresh,
+			ChangeID: chg.ID(),
+			TaskID:   task.ID(),
+			Snap:     "pc-kernel",
+		},
+		Action:      "reboot",
+		RequestTime: t0,
+		FromBootID:  "boot-id-1",
+	}, {
+		RebootReason: restart.RebootReason{Code: restart.RebootReasonSystemAction},
+		Action:       "poweroff",
+		RequestTime:  t0,
+		FromBootID:   "boot-id-1",
+	}, {
+		RebootReason: restart.RebootReason{Code: restart.RebootReasonUnknown},
+		Action:       "reboot",
+		RequestTime:  t0,
+		FromBootID:   "boot-id-1",
+	}})
+	for _, rec := range records {
+		c.Check(rec.Pending(), Equals, true)
+	}
+
+	// the reboot did not happen
+	_, err = restart.Manager(st, "boot-id-1", nil)
+	c.Assert(err, IsNil)
+	records, err = restart.RebootRecords(st)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	for _, rec := range records {
+		c.Check(rec.Pending(), Equals, true)
+	}
+
+	// the reboot happened
+	t1 := t0.Add(time.Minute)
+	restart.MockTimeNow(func() time.Time { return t1 })
+	_, err = restart.Manager(st, "boot-id-2", nil)
+	c.Assert(err, IsNil)
+
+	restart.RequestWithReason(st, restart.RestartSystem, &restart.RebootReason{Code: restart.RebootReasonGadgetAssetUpdate}, nil)
+
+	records, err = restart.RebootRecords(st)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 4)
+	for _, rec := range records[:3] {
+		c.Check(rec.Pending(), Equals, false)
+		c.Check(*rec.RebootTime, Equals, t1)
+	}
+	c.Check(records[3].Pending(), Equals, true)
+	c.Check(records[3].FromBootID, Equals, "boot-id-2")
+}
+
+func (s *restartSuite) TestRebootRecordsOnClassic(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	defer release.MockOnClassic(true)()
+
+	_, err := restart.Manager(st, "boot-id-1", nil)
+	c.Assert(err, IsNil)
+
+	chg := st.NewChange("refresh-snap", "...")
+	task := st.NewTask("link-snap", "...")
+	chg.AddTask(task)
+	err = restart.FinishTaskWithRestart(task, state.DoneStatus, restart.RestartSystem, restart.RebootReasonBaseRefresh, "core22", nil)
+	c.Assert(err, FitsTypeOf, &state.Wait{})
+
+	// a manual reboot is still accounted for
+	records, err := restart.RebootRecords(st)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 1)
+	c.Check(records[0].Code, Equals, restart.RebootReasonBaseRefresh)
+	c.Check(records[0].Snap, Equals, "core22")
+	c.Check(records[0].Pending(), Equals, true)
+
+	// nothing is recorded when undoing
+	task2 := st.NewTask("link-snap", "...")
+	chg.AddTask(task2)
+	err = restart.FinishTaskWithRestart(task2, state.UndoneStatus, restart.RestartSystem, restart.RebootReasonBaseRefresh, "core22", nil)
+	c.Assert(err, IsNil)
+	records, err = restart.RebootRecords(st)
+	c.Assert(err, IsNil)
+	c.Check(records, HasLen, 1)
+}
+
+func (s *restartSuite) TestRebootRecordsLimit(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := restart.Manager(st, "boot-id-1", nil)
+	c.Assert(err, IsNil)
+
+	for i := 0; i < restart.MaxRebootRecords+2; i++ {
+		code := restart.RebootReasonUnknown
+		if i == 2 {
+			code = restart.RebootReasonRemodel
+		}
+		restart.RequestWithReason(st, restart.RestartSystem, &restart.RebootReason{Code: code}, nil)
+	}
+
+	records, err := restart.RebootRecords(st)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, restart.MaxRebootRecords)
+	// the oldest ones were dropped
+	c.Check(records[0].Code, Equals, restart.RebootReasonRemodel)
+}
+
 type notifyRebootRequiredSuite struct {
 	testutil.BaseTest
 
@@ -447,7 +592,7 @@ test "$DPKG_MAINTSCRIPT_NAME" = "postinst"
 `)
 	defer mockNrr.Restore()
 
-	err := restart.FinishTaskWithRestart(s.t1, state.DoneStatus, restart.RestartSystem, "some-snap", nil)
+	err := restart.FinishTaskWithRestart(s.t1, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelRefresh, "some-snap", nil)
 	c.Check(err, DeepEquals, &state.Wait{Reason: "waiting for manual system restart"})
 	c.Check(mockNrr.Calls(), DeepEquals, [][]string{
 		{"notify-reboot-required", "snap:some-snap"},
@@ -463,7 +608,7 @@ func (s *notifyRebootRequiredSuite) TestFinishTaskWithRestartNotifiesRebootRequi
 	mockNrr := testutil.MockCommand(c, s.mockNrrPath, `echo fail; exit 1`)
 	defer mockNrr.Restore()
 
-	err := restart.FinishTaskWithRestart(s.t1, state.DoneStatus, restart.RestartSystem, "some-snap", nil)
+	err := restart.FinishTaskWithRestart(s.t1, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelRefresh, "some-snap", nil)
 	c.Check(err, DeepEquals, &state.Wait{Reason: "waiting for manual system restart"})
 	c.Check(mockNrr.Calls(), DeepEquals, [][]string{
 		{"notify-reboot-required", "snap:some-snap"},
@@ -487,8 +632,76 @@ func (s *notifyRebootRequiredSuite) TestFinishTaskWithRestartNotifiesRebootRequi
 	mockNrr := testutil.MockCommand(c, s.mockNrrPath, "")
 	defer mockNrr.Restore()
 
-	err := restart.FinishTaskWithRestart(s.t1, state.DoneStatus, restart.RestartSystem, "some-snap", nil)
+	err := restart.FinishTaskWithRestart(s.t1, state.DoneStatus, restart.RestartSystem, restart.RebootReasonKernelRefresh, "some-snap", nil)
 	c.Check(err, IsNil)
 	c.Check(mockNrr.Calls(), HasLen, 0)
 	c.Check(s.mockLog.String(), Equals, "")
 }
+
+func (s *restartSuite) TestMaintenance(c *C) {
+	now := time.Date(2023, 3, 4, 5, 6, 7, 0, time.UTC)
+	restore := restart.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	// uninitialized
+	c.Check(restart.Maintenance(st), IsNil)
+
+	_, err := restart.Manager(st, "boot-id-1", nil)
+	c.Assert(err, IsNil)
+	c.Check(restart.Maintenance(st), IsNil)
+
+	restart.RequestWithReason(st, restart.RestartDaemon, &restart.RebootReason{
+		Code: restart.RebootReasonSnapdRefresh,
+		Snap: "snapd",
+	}, nil)
+	c.Check(restart.Maintenance(st), DeepEquals, &restart.PlannedMaintenance{
+		Kind: "daemon-restart",
+		RebootReason: restart.RebootReason{
+			Code: restart.RebootReasonSnapdRefresh,
+			Snap: "snapd",
+		},
+		Time: now,
+	})
+
+	// delayed system restart
+	restart.RequestWithReason(st, restart.RestartSystem, &restart.RebootReason{
+		Code:     restart.RebootReasonKernelRefresh,
+		ChangeID: "1",
+		Snap:     "pc-kernel",
+	}, nil)
+	c.Check(restart.Maintenance(st), DeepEquals, &restart.PlannedMaintenance{
+		Kind:   "system-restart",
+		Action: "reboot",
+		RebootReason: restart.RebootReason{
+			Code:     restart.RebootReasonKernelRefresh,
+			ChangeID: "1",
+			Snap:     "pc-kernel",
+		},
+		Time: now.Add(time.Minute),
+	})
+
+	// immediate power off without a reason
+	restart.Request(st, restart.RestartSystemPoweroffNow, nil)
+	c.Check(restart.Maintenance(st), DeepEquals, &restart.PlannedMaintenance{
+		Kind:         "system-restart",
+		Action:       "poweroff",
+		RebootReason: restart.RebootReason{Code: restart.RebootReasonUnknown},
+		Time:         now,
+	})
+
+	// going into socket activation is not maintenance
+	restart.Request(st, restart.RestartSocket, nil)
+	c.Check(restart.Maintenance(st), IsNil)
+}
+
+func (s *restartSuite) TestSystemRestartDelay(c *C) {
+	c.Check(restart.SystemRestartDelay(), Equals, time.Minute)
+
+	os.Setenv("SNAPD_REBOOT_DELAY", "10m")
+	defer os.Unsetenv("SNAPD_REBOOT_DELAY")
+	c.Check(restart.SystemRestartDelay(), Equals, 10*time.Minute)
+}
@@ -66,6 +66,7 @@ apps:
     after: [bar]
   foo:
     daemon: simple
+    reload-command: reload
   bar:
     daemon: simple
     after: [foo]
@@ -1001,10 +1002,15 @@ func (s *serviceControlSuite) TestReloadAllServices(c *C) {
 	c.Assert(err, IsNil)
 
 	c.Assert(t.Status(), Equals, state.DoneStatus)
+	// only foo has a reload command
 	c.Check(s.sysctlArgs, DeepEquals, [][]string{
 		{"reload-or-restart", "snap.test-snap.foo.service"},
-		{"reload-or-restart", "snap.test-snap.bar.service"},
-		{"reload-or-restart", "snap.test-snap.abc.service"},
+		{"stop", "snap.test-snap.bar.service"},
+		{"show", "--property=ActiveState", "snap.test-snap.bar.service"},
+		{"start", "snap.test-snap.bar.service"},
+		{"stop", "snap.test-snap.abc.service"},
+		{"show", "--property=ActiveState", "snap.test-snap.abc.service"},
+		{"start", "snap.test-snap.abc.service"},
 	})
 }
 
@@ -190,7 +190,7 @@ func (m *ServiceManager) ensureSnapServicesUpdated() (err error) {
 		// we need to immediately reboot in the hopes that this restores
 		// services to a functioning state
 
-		restart.Request(m.state, restart.RestartSystemNow, nil)
+		restart.RequestWithReason(m.state, restart.RestartSystemNow, &restart.RebootReason{Code: restart.RebootReasonServiceFailure}, nil)
 		return fmt.Errorf("error trying to restart killed services, immediately rebooting: %v", err)
 	}
 
@@ -25,9 +25,13 @@ import (
 	"io"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"golang.org/x/sys/unix"
+
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
@@ -250,6 +254,29 @@ func Control(st *state.State, appInfos []*snap.AppInfo, inst *Instruction, flags
 	return tts, nil
 }
 
+// CLD_* codes as reported by systemd in ExecMainCode, see waitid(2)
+const (
+	cldExited = 1
+	cldKilled = 2
+	cldDumped = 3
+)
+
+// decorateWithRuntimeStatus adds the restart count, last exit status and
+// last state change time of the service unit to the given client.AppInfo.
+func decorateWithRuntimeStatus(appInfo *client.AppInfo, st *systemd.UnitStatus) {
+	appInfo.Restarts = st.NRestarts
+	appInfo.StateChanged = st.StateChangeTimestamp
+	switch st.ExecMainCode {
+	case cldExited:
+		appInfo.LastExitCode = st.ExecMainStatus
+	case cldKilled, cldDumped:
+		appInfo.LastExitSignal = unix.SignalName(syscall.Signal(st.ExecMainStatus))
+		if appInfo.LastExitSignal == "" {
+			appInfo.LastExitSignal = strconv.Itoa(st.ExecMainStatus)
+		}
+	}
+}
+
 // StatusDecorator supports decorating client.AppInfos with service status.
 type StatusDecorator struct {
 	sysd           systemd.Systemd
@@ -320,6 +347,7 @@ func (sd *StatusDecorator) DecorateWithStatus(appInfo *client.AppInfo, snapApp *
 		case ".service":
 			appInfo.Enabled = st.Enabled
 			appInfo.Active = st.Active
+			decorateWithRuntimeStatus(appInfo, st)
 		case ".timer":
 			appInfo.Activators = append(appInfo.Activators, client.AppActivator{
 				Name:    snapApp.Name,
@@ -389,6 +417,11 @@ func SnapServiceOptions(st *state.State, instanceName string, quotaGroups map[st
 		}
 	}
 
+	opts.HostAfter, opts.HostBefore, err = HostUnitsOrdering(tr, instanceName)
+	if err != nil {
+		return nil, err
+	}
+
 	// also check for quota group for this instance name
 	for _, grp := range quotaGroups {
 		if strutil.ListContains(grp.Snaps, instanceName) {
@@ -400,6 +433,41 @@ func SnapServiceOptions(st *state.State, instanceName string, quotaGroups map[st
 	return opts, nil
 }
 
+// HostUnitsOrdering returns the host systemd units that the services of the
+// given snap instance must be ordered after and before, as set with the
+// service-ordering.<snap>.after and service-ordering.<snap>.before system
+// options. The options apply to all the instances of a snap.
+func HostUnitsOrdering(tr config.ConfGetter, instanceName string) (after, before []string, err error) {
+	snapName := snap.InstanceSnap(instanceName)
+	if snap.ValidateName(snapName) != nil {
+		// cannot be used as an option name, and no ordering can
+		// have been set for it either
+		return nil, nil, nil
+	}
+	units := func(which string) ([]string, error) {
+		var unitsStr string
+		err := tr.Get("core", fmt.Sprintf("service-ordering.%s.%s", snapName, which), &unitsStr)
+		if err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+		var units []string
+		for _, unit := range strings.Split(unitsStr, ",") {
+			unit = strings.TrimSpace(unit)
+			if unit != "" {
+				units = append(units, unit)
+			}
+		}
+		return units, nil
+	}
+	if after, err = units("after"); err != nil {
+		return nil, nil, err
+	}
+	if before, err = units("before"); err != nil {
+		return nil, nil, err
+	}
+	return after, before, nil
+}
+
 // LogReader returns an io.ReadCloser which produce logs for the provided
 // snap AppInfo's. It is a convenience wrapper around the systemd.LogReader
 // implementation.
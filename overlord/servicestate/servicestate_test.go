@@ -27,6 +27,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -278,6 +279,80 @@ type snapServiceOptionsSuite struct {
 
 var _ = Suite(&snapServiceOptionsSuite{})
 
+func (s *statusDecoratorSuite) TestDecorateWithRuntimeStatus(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	snp := &snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(1),
+		},
+	}
+	err := os.MkdirAll(snp.MountDir(), 0755)
+	c.Assert(err, IsNil)
+	err = os.Symlink(snp.Revision.String(), filepath.Join(filepath.Dir(snp.MountDir()), "current"))
+	c.Assert(err, IsNil)
+
+	var execMainCode, execMainStatus int
+	r := systemd.MockSystemctl(func(args ...string) (buf []byte, err error) {
+		c.Assert(args[0], Equals, "show")
+		return []byte(fmt.Sprintf(`Id=%s
+Names=%[1]s
+Type=simple
+ActiveState=activating
+UnitFileState=enabled
+NeedDaemonReload=no
+NRestarts=5
+ExecMainCode=%d
+ExecMainStatus=%d
+StateChangeTimestamp=Fri 2022-10-14 12:34:56 UTC
+`, args[2], execMainCode, execMainStatus)), nil
+	})
+	defer r()
+
+	sd := servicestate.NewStatusDecorator(nil)
+	snapApp := &snap.AppInfo{
+		Snap:        snp,
+		Name:        "svc",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+	}
+
+	for _, tc := range []struct {
+		code, status int
+		exitCode     int
+		exitSignal   string
+	}{
+		// never exited
+		{0, 0, 0, ""},
+		// CLD_EXITED
+		{1, 2, 2, ""},
+		// CLD_KILLED
+		{2, 9, 0, "SIGKILL"},
+		// CLD_DUMPED
+		{3, 11, 0, "SIGSEGV"},
+	} {
+		execMainCode, execMainStatus = tc.code, tc.status
+		app := &client.AppInfo{
+			Snap:   snp.InstanceName(),
+			Name:   "svc",
+			Daemon: "simple",
+		}
+		err = sd.DecorateWithStatus(app, snapApp)
+		c.Assert(err, IsNil)
+		c.Check(app, DeepEquals, &client.AppInfo{
+			Snap:           snp.InstanceName(),
+			Name:           "svc",
+			Daemon:         "simple",
+			Enabled:        true,
+			Restarts:       5,
+			LastExitCode:   tc.exitCode,
+			LastExitSignal: tc.exitSignal,
+			StateChanged:   time.Date(2022, 10, 14, 12, 34, 56, 0, time.UTC),
+		}, Commentf("%v", tc))
+	}
+}
+
 func (s *snapServiceOptionsSuite) SetUpTest(c *C) {
 	s.BaseTest.SetUpTest(c)
 	s.state = state.New(nil)
@@ -309,6 +384,57 @@ func (s *snapServiceOptionsSuite) TestSnapServiceOptionsVitalityRank(c *C) {
 	})
 }
 
+func (s *snapServiceOptionsSuite) TestSnapServiceOptionsHostUnitsOrdering(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+	t := config.NewTransaction(st)
+	err := t.Set("core", "service-ordering.foo.after", "time-sync.target, vendor.service")
+	c.Assert(err, IsNil)
+	err = t.Set("core", "service-ordering.foo.before", "shutdown.target")
+	c.Assert(err, IsNil)
+	t.Commit()
+
+	opts, err := servicestate.SnapServiceOptions(st, "foo", nil)
+	c.Assert(err, IsNil)
+	c.Check(opts, DeepEquals, &wrappers.SnapServiceOptions{
+		HostAfter:  []string{"time-sync.target", "vendor.service"},
+		HostBefore: []string{"shutdown.target"},
+	})
+	// the ordering applies to all instances of the snap
+	opts, err = servicestate.SnapServiceOptions(st, "foo_instance", nil)
+	c.Assert(err, IsNil)
+	c.Check(opts, DeepEquals, &wrappers.SnapServiceOptions{
+		HostAfter:  []string{"time-sync.target", "vendor.service"},
+		HostBefore: []string{"shutdown.target"},
+	})
+	opts, err = servicestate.SnapServiceOptions(st, "bar", nil)
+	c.Assert(err, IsNil)
+	c.Check(opts, DeepEquals, &wrappers.SnapServiceOptions{})
+}
+
+func (s *snapServiceOptionsSuite) TestHostUnitsOrderingInvalidSnapName(c *C) {
+	st := s.state
+	st.Lock()
+	defer st.Unlock()
+	t := config.NewTransaction(st)
+	err := t.Set("core", "service-ordering.foo.after", "time-sync.target")
+	c.Assert(err, IsNil)
+	t.Commit()
+
+	for _, name := range []string{"", "-foo-", "foo.bar"} {
+		after, before, err := servicestate.HostUnitsOrdering(config.NewTransaction(st), name)
+		c.Assert(err, IsNil, Commentf("%q", name))
+		c.Check(after, HasLen, 0)
+		c.Check(before, HasLen, 0)
+	}
+
+	opts, err := servicestate.SnapServiceOptions(st, "", nil)
+	c.Assert(err, IsNil)
+	c.Check(opts.HostAfter, HasLen, 0)
+	c.Check(opts.HostBefore, HasLen, 0)
+}
+
 func (s *snapServiceOptionsSuite) TestSnapServiceOptionsQuotaGroups(c *C) {
 	st := s.state
 	st.Lock()
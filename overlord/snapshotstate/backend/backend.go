@@ -919,14 +919,24 @@ type SnapshotExport struct {
 
 	// cached size, needs to be calculated with CalculateSize
 	size int64
+
+	// encryption of the export, if any
+	encryption *ExportEncryption
 }
 
 // NewSnapshotExport will return a SnapshotExport structure. It must be
-// Close()ed after use to avoid leaking file descriptors.
-func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, err error) {
+// Close()ed after use to avoid leaking file descriptors. If enc is not
+// nil the export is encrypted.
+func NewSnapshotExport(ctx context.Context, setID uint64, enc *ExportEncryption) (se *SnapshotExport, err error) {
 	var snapshotFiles []*os.File
 	var snapshotSet client.SnapshotSet
 
+	if enc != nil {
+		if err := enc.validate(); err != nil {
+			return nil, fmt.Errorf("cannot export snapshot %v: %v", setID, err)
+		}
+	}
+
 	defer func() {
 		// cleanup any open FDs if anything goes wrong
 		if err != nil {
@@ -972,7 +982,7 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 	if err != nil {
 		return nil, fmt.Errorf("cannot calculate content hash for snapshot export %v: %v", setID, err)
 	}
-	se = &SnapshotExport{snapshotFiles: snapshotFiles, setID: setID, contentHash: h}
+	se = &SnapshotExport{snapshotFiles: snapshotFiles, setID: setID, contentHash: h, encryption: enc}
 
 	// ensure we never leak FDs even if the user does not call close
 	runtime.SetFinalizer(se, (*SnapshotExport).Close)
@@ -1016,6 +1026,20 @@ type contentJSON struct {
 }
 
 func (se *SnapshotExport) StreamTo(w io.Writer) error {
+	if se.encryption == nil {
+		return se.streamTarTo(w)
+	}
+	ew, err := newEncryptingWriter(w, se.encryption, se.setID)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt snapshot export %v: %v", se.setID, err)
+	}
+	if err := se.streamTarTo(ew); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+func (se *SnapshotExport) streamTarTo(w io.Writer) error {
 	// write out a tar
 	var files []string
 	tw := tar.NewWriter(w)
@@ -1086,7 +1086,7 @@ func (s *snapshotSuite) TestImportDuplicated(c *check.C) {
 	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil)
 	c.Assert(err, check.IsNil)
 
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	err = export.Init()
 	c.Assert(err, check.IsNil)
@@ -1120,7 +1120,7 @@ func (s *snapshotSuite) TestImportExportRoundtrip(c *check.C) {
 	c.Check(backend.Filename(shw), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
 	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
 
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	err = export.Init()
 	c.Assert(err, check.IsNil)
@@ -1285,7 +1285,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	// export once
 	buf := bytes.NewBuffer(nil)
 	ctx := context.Background()
-	se, err := backend.NewSnapshotExport(ctx, shID)
+	se, err := backend.NewSnapshotExport(ctx, shID, nil)
 	c.Check(err, check.IsNil)
 	err = se.Init()
 	c.Assert(err, check.IsNil)
@@ -1302,7 +1302,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	// change.
 	restore = backend.MockTimeNow(func() time.Time { return time.Date(2242, 1, 1, 12, 0, 0, 0, time.UTC) })
 	defer restore()
-	se2, err := backend.NewSnapshotExport(ctx, shID)
+	se2, err := backend.NewSnapshotExport(ctx, shID, nil)
 	c.Check(err, check.IsNil)
 	err = se2.Init()
 	c.Assert(err, check.IsNil)
@@ -1315,7 +1315,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 }
 
 func (s *snapshotSuite) TestExportUnhappy(c *check.C) {
-	se, err := backend.NewSnapshotExport(context.Background(), 5)
+	se, err := backend.NewSnapshotExport(context.Background(), 5, nil)
 	c.Assert(err, check.ErrorMatches, "no snapshot data found for 5")
 	c.Assert(se, check.IsNil)
 }
@@ -1610,12 +1610,12 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 	c.Check(err, check.IsNil)
 
 	// now export it
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.HasLen, sha256.Size)
 
 	// and check that exporting it again leads to the same content hash
-	export2, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export2, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.DeepEquals, export2.ContentHash())
 
@@ -1631,7 +1631,7 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil)
 	c.Check(err, check.IsNil)
 
-	export3, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export3, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.Not(check.DeepEquals), export3.ContentHash())
 }
@@ -0,0 +1,411 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget/device"
+)
+
+const (
+	// EncryptionKeyDevice encrypts the export with a key bound to the
+	// device, such an export can only be imported on the same device.
+	EncryptionKeyDevice = "device"
+	// EncryptionKeyPassphrase encrypts the export with a key derived from
+	// a passphrase, such an export can be imported on any device.
+	EncryptionKeyPassphrase = "passphrase"
+)
+
+// ExportEncryption describes how a snapshot export is encrypted.
+type ExportEncryption struct {
+	// KeySource is where the encryption key comes from, one of
+	// EncryptionKeyDevice or EncryptionKeyPassphrase.
+	KeySource string
+	// Passphrase is used with EncryptionKeyPassphrase.
+	Passphrase string
+}
+
+const (
+	exportCipher = "aes-256-gcm"
+
+	// encryptedExportChunkSize is the size of the plain text sealed at
+	// once, the exports are streamed so they cannot be sealed as a whole.
+	encryptedExportChunkSize = 64 * 1024
+
+	// the nonce of a chunk is the nonce prefix of the export, followed by
+	// the chunk counter and a byte marking the last chunk, the latter
+	// prevents truncating an export at a chunk boundary unnoticed
+	exportNoncePrefixSize = 7
+)
+
+// encryptedExportMagic starts the encrypted exports, which cannot be
+// mistaken for the tar archive of plain exports.
+var encryptedExportMagic = []byte("snapd-snapshot-encrypted\n")
+
+type scryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+var defaultScryptParams = scryptParams{N: 1 << 15, R: 8, P: 1}
+
+const (
+	// maxScryptN is the largest cost accepted from the header of an
+	// export, such that importing an untrusted export cannot exhaust the
+	// memory or the time of snapd when deriving the key
+	maxScryptN = 1 << 20
+	maxScryptR = 32
+	maxScryptP = 16
+	// maxScryptMemory bounds the memory used by scrypt, which is about
+	// 128*N*R bytes
+	maxScryptMemory = 256 * 1024 * 1024
+)
+
+// validate checks that the parameters read from an export are within the
+// bounds of what snapd creates, possibly with a few more rounds.
+func (p *scryptParams) validate() error {
+	if p.N < 2 || p.N > maxScryptN || p.N&(p.N-1) != 0 {
+		return fmt.Errorf("invalid scrypt cost %d", p.N)
+	}
+	if p.R < 1 || p.R > maxScryptR {
+		return fmt.Errorf("invalid scrypt block size %d", p.R)
+	}
+	if p.P < 1 || p.P > maxScryptP {
+		return fmt.Errorf("invalid scrypt parallelization %d", p.P)
+	}
+	if 128*p.N*p.R > maxScryptMemory {
+		return fmt.Errorf("scrypt parameters require too much memory")
+	}
+	return nil
+}
+
+// encryptedExportHeader is the metadata of an encrypted export. It is
+// stored in the clear in front of the encrypted data but it is
+// authenticated along with each chunk of the data.
+type encryptedExportHeader struct {
+	Format    int           `json:"format"`
+	Cipher    string        `json:"cipher"`
+	KeySource string        `json:"key-source"`
+	Salt      []byte        `json:"salt"`
+	Scrypt    *scryptParams `json:"scrypt,omitempty"`
+	Nonce     []byte        `json:"nonce"`
+	ChunkSize int           `json:"chunk-size"`
+	SetID     uint64        `json:"set-id"`
+	Date      time.Time     `json:"date"`
+}
+
+var (
+	randRead = rand.Read
+
+	// exportKeySecret returns the secret the device-bound key is derived
+	// from, that is the key of ubuntu-save. Note that this key is not
+	// sealed, it is kept on ubuntu-data, so it is only as protected as
+	// the data of the system: it cannot be read once the disk is taken
+	// out of the device, but anyone who can read it as root on the
+	// running system can also decrypt device-bound exports. The exports
+	// are bound to the device, not to its boot state as measured by the
+	// TPM.
+	exportKeySecret = func() ([]byte, error) {
+		secret, err := ioutil.ReadFile(device.SaveKeyUnder(dirs.SnapFDEDir))
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no device encryption key available")
+		}
+		return secret, err
+	}
+)
+
+// validate checks the encryption options before anything is exported.
+func (enc *ExportEncryption) validate() error {
+	switch enc.KeySource {
+	case EncryptionKeyDevice:
+		if _, err := exportKeySecret(); err != nil {
+			return fmt.Errorf("cannot use device-bound encryption: %v", err)
+		}
+	case EncryptionKeyPassphrase:
+		if enc.Passphrase == "" {
+			return fmt.Errorf("cannot use passphrase encryption without a passphrase")
+		}
+	default:
+		return fmt.Errorf("unknown snapshot encryption key source %q", enc.KeySource)
+	}
+	return nil
+}
+
+func exportEncryptionKey(hdr *encryptedExportHeader, passphrase string) ([]byte, error) {
+	switch hdr.KeySource {
+	case EncryptionKeyDevice:
+		secret, err := exportKeySecret()
+		if err != nil {
+			return nil, err
+		}
+		key := make([]byte, 32)
+		kdf := hkdf.New(sha256.New, secret, hdr.Salt, []byte("snapd snapshot export encryption"))
+		if _, err := io.ReadFull(kdf, key); err != nil {
+			return nil, err
+		}
+		return key, nil
+	case EncryptionKeyPassphrase:
+		if passphrase == "" {
+			return nil, fmt.Errorf("snapshot export is encrypted with a passphrase")
+		}
+		if hdr.Scrypt == nil {
+			return nil, fmt.Errorf("missing key derivation parameters")
+		}
+		if err := hdr.Scrypt.validate(); err != nil {
+			return nil, fmt.Errorf("invalid key derivation parameters: %v", err)
+		}
+		return scrypt.Key([]byte(passphrase), hdr.Salt, hdr.Scrypt.N, hdr.Scrypt.R, hdr.Scrypt.P, 32)
+	}
+	return nil, fmt.Errorf("unknown key source %q", hdr.KeySource)
+}
+
+func newExportAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, exportNoncePrefixSize+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[exportNoncePrefixSize:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptingWriter seals the data written to it in chunks, the last chunk
+// is only sealed on Close.
+type encryptingWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	aad     []byte
+	counter uint32
+	buf     []byte
+}
+
+// newEncryptingWriter writes the header of an encrypted export of the given
+// set to w and returns the writer for the data of the export. The writer
+// must be closed to complete the export.
+func newEncryptingWriter(w io.Writer, enc *ExportEncryption, setID uint64) (io.WriteCloser, error) {
+	hdr := encryptedExportHeader{
+		Format:    1,
+		Cipher:    exportCipher,
+		KeySource: enc.KeySource,
+		Salt:      make([]byte, 32),
+		Nonce:     make([]byte, exportNoncePrefixSize),
+		ChunkSize: encryptedExportChunkSize,
+		SetID:     setID,
+		// keep the header size stable, the export is sized before it is streamed
+		Date: timeNow().UTC().Truncate(time.Second),
+	}
+	if enc.KeySource == EncryptionKeyPassphrase {
+		params := defaultScryptParams
+		hdr.Scrypt = &params
+	}
+	if _, err := randRead(hdr.Salt); err != nil {
+		return nil, err
+	}
+	if _, err := randRead(hdr.Nonce); err != nil {
+		return nil, err
+	}
+	key, err := exportEncryptionKey(&hdr, enc.Passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive snapshot encryption key: %v", err)
+	}
+	aead, err := newExportAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	hdrBuf, err := json.Marshal(&hdr)
+	if err != nil {
+		return nil, err
+	}
+	var aad bytes.Buffer
+	aad.Write(encryptedExportMagic)
+	binary.Write(&aad, binary.BigEndian, uint32(len(hdrBuf)))
+	aad.Write(hdrBuf)
+	if _, err := w.Write(aad.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return &encryptingWriter{
+		w:      w,
+		aead:   aead,
+		prefix: hdr.Nonce,
+		aad:    aad.Bytes(),
+		buf:    make([]byte, 0, encryptedExportChunkSize),
+	}, nil
+}
+
+func (ew *encryptingWriter) sealChunk(last bool) error {
+	sealed := ew.aead.Seal(nil, chunkNonce(ew.prefix, ew.counter, last), ew.buf, ew.aad)
+	ew.counter++
+	ew.buf = ew.buf[:0]
+	_, err := ew.w.Write(sealed)
+	return err
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		// only seal a full chunk once more data follows, as the last
+		// chunk is sealed differently
+		if len(ew.buf) == encryptedExportChunkSize {
+			if err := ew.sealChunk(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+func (ew *encryptingWriter) Close() error {
+	return ew.sealChunk(true)
+}
+
+// decryptingReader opens the chunks of an encrypted export as they are read.
+type decryptingReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	aad     []byte
+	counter uint32
+	chunk   []byte
+	plain   []byte
+	done    bool
+}
+
+var errTruncatedExport = errors.New("encrypted snapshot export is truncated")
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(dr.r, dr.chunk)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			if err == io.EOF {
+				return 0, errTruncatedExport
+			}
+			return 0, err
+		}
+		// the last chunk is followed by nothing
+		last := err == io.ErrUnexpectedEOF
+		if !last {
+			if _, err := dr.r.Peek(1); err == io.EOF {
+				last = true
+			}
+		}
+		plain, err := dr.aead.Open(dr.chunk[:0], chunkNonce(dr.prefix, dr.counter, last), dr.chunk[:n], dr.aad)
+		if err != nil {
+			if !last {
+				// a chunk sealed as the last one followed by
+				// more data, or tampered data
+				return 0, fmt.Errorf("cannot decrypt snapshot export: %v", err)
+			}
+			return 0, fmt.Errorf("cannot decrypt snapshot export: %v (truncated or corrupted data?)", err)
+		}
+		dr.counter++
+		dr.plain = plain
+		dr.done = last
+	}
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
+
+// NewImportReader returns a reader for the plain export read from r. If the
+// export is encrypted it is decrypted while being read, with a key derived
+// from the passphrase if it was encrypted with one.
+func NewImportReader(r io.Reader, passphrase string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(encryptedExportMagic))
+	if err != nil || !bytes.Equal(magic, encryptedExportMagic) {
+		// not encrypted, the tar reader reports any errors
+		return br, nil
+	}
+	prologue := make([]byte, len(encryptedExportMagic)+4)
+	if _, err := io.ReadFull(br, prologue); err != nil {
+		return nil, errTruncatedExport
+	}
+	hdrLen := binary.BigEndian.Uint32(prologue[len(encryptedExportMagic):])
+	// the header is small, anything else is garbage
+	if hdrLen > 4096 {
+		return nil, fmt.Errorf("invalid encrypted snapshot export header")
+	}
+	hdrBuf := make([]byte, hdrLen)
+	if _, err := io.ReadFull(br, hdrBuf); err != nil {
+		return nil, errTruncatedExport
+	}
+	var hdr encryptedExportHeader
+	if err := json.Unmarshal(hdrBuf, &hdr); err != nil {
+		return nil, fmt.Errorf("invalid encrypted snapshot export header: %v", err)
+	}
+	if hdr.Format != 1 || hdr.Cipher != exportCipher {
+		return nil, fmt.Errorf("unsupported encrypted snapshot export format %d with cipher %q", hdr.Format, hdr.Cipher)
+	}
+	if len(hdr.Nonce) != exportNoncePrefixSize || hdr.ChunkSize <= 0 || hdr.ChunkSize > 16*encryptedExportChunkSize {
+		return nil, fmt.Errorf("invalid encrypted snapshot export header")
+	}
+	key, err := exportEncryptionKey(&hdr, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive snapshot encryption key: %v", err)
+	}
+	aead, err := newExportAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{
+		r:      br,
+		aead:   aead,
+		prefix: hdr.Nonce,
+		aad:    append(prologue, hdrBuf...),
+		chunk:  make([]byte, hdr.ChunkSize+aead.Overhead()),
+	}, nil
+}
@@ -0,0 +1,232 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type encryptionSuite struct {
+	testutil.BaseTest
+
+	secret []byte
+}
+
+var _ = check.Suite(&encryptionSuite{})
+
+func (s *encryptionSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.secret = []byte("device-secret")
+	s.AddCleanup(backend.MockExportKeySecret(func() ([]byte, error) {
+		return s.secret, nil
+	}))
+}
+
+func (s *encryptionSuite) encrypt(c *check.C, enc *backend.ExportEncryption, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := backend.NewEncryptingWriter(&buf, enc, 42)
+	c.Assert(err, check.IsNil)
+	// write in odd pieces to cross the chunk boundaries
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		_, err := w.Write(data[:n])
+		c.Assert(err, check.IsNil)
+		data = data[n:]
+	}
+	c.Assert(w.Close(), check.IsNil)
+	return buf.Bytes()
+}
+
+func (s *encryptionSuite) decrypt(encrypted []byte, passphrase string) ([]byte, error) {
+	r, err := backend.NewImportReader(bytes.NewReader(encrypted), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func (s *encryptionSuite) TestRoundtripDevice(c *check.C) {
+	enc := &backend.ExportEncryption{KeySource: backend.EncryptionKeyDevice}
+	chunk := backend.EncryptedExportChunkSize
+	for _, size := range []int{0, 1, chunk - 1, chunk, chunk + 1, 3*chunk + 5} {
+		data := bytes.Repeat([]byte{'x'}, size)
+		encrypted := s.encrypt(c, enc, data)
+
+		plain, err := s.decrypt(encrypted, "")
+		c.Assert(err, check.IsNil, check.Commentf("size %d", size))
+		c.Check(plain, check.DeepEquals, data, check.Commentf("size %d", size))
+	}
+}
+
+func (s *encryptionSuite) TestRoundtripPassphrase(c *check.C) {
+	enc := &backend.ExportEncryption{KeySource: backend.EncryptionKeyPassphrase, Passphrase: "sekrit"}
+	data := bytes.Repeat([]byte("snapshot data "), 10000)
+	encrypted := s.encrypt(c, enc, data)
+
+	// passphrase exports do not depend on the device
+	s.secret = []byte("other-device-secret")
+	plain, err := s.decrypt(encrypted, "sekrit")
+	c.Assert(err, check.IsNil)
+	c.Check(plain, check.DeepEquals, data)
+
+	_, err = s.decrypt(encrypted, "wrong")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot export: cipher: message authentication failed`)
+
+	_, err = s.decrypt(encrypted, "")
+	c.Check(err, check.ErrorMatches, `cannot derive snapshot encryption key: snapshot export is encrypted with a passphrase`)
+}
+
+func (s *encryptionSuite) TestOtherDevice(c *check.C) {
+	enc := &backend.ExportEncryption{KeySource: backend.EncryptionKeyDevice}
+	encrypted := s.encrypt(c, enc, []byte("snapshot data"))
+
+	s.secret = []byte("other-device-secret")
+	_, err := s.decrypt(encrypted, "")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot export: cipher: message authentication failed \(truncated or corrupted data\?\)`)
+
+	s.AddCleanup(backend.MockExportKeySecret(func() ([]byte, error) {
+		return nil, errors.New("no device encryption key available")
+	}))
+	_, err = s.decrypt(encrypted, "")
+	c.Check(err, check.ErrorMatches, `cannot derive snapshot encryption key: no device encryption key available`)
+}
+
+func (s *encryptionSuite) TestTamperedHeader(c *check.C) {
+	enc := &backend.ExportEncryption{KeySource: backend.EncryptionKeyDevice}
+	encrypted := s.encrypt(c, enc, []byte("snapshot data"))
+
+	c.Assert(bytes.Count(encrypted, []byte(`"set-id":42`)), check.Equals, 1)
+	tampered := bytes.Replace(encrypted, []byte(`"set-id":42`), []byte(`"set-id":43`), 1)
+	_, err := s.decrypt(tampered, "")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot export: .*`)
+}
+
+func (s *encryptionSuite) TestTamperedData(c *check.C) {
+	enc := &backend.ExportEncryption{KeySource: backend.EncryptionKeyDevice}
+	data := bytes.Repeat([]byte{'x'}, 2*backend.EncryptedExportChunkSize+10)
+	encrypted := s.encrypt(c, enc, data)
+
+	tampered := append([]byte(nil), encrypted...)
+	tampered[len(tampered)-backend.EncryptedExportChunkSize] ^= 1
+	_, err := s.decrypt(tampered, "")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot export: .*`)
+}
+
+func (s *encryptionSuite) TestInvalidScryptParams(c *check.C) {
+	enc := &backend.ExportEncryption{KeySource: backend.EncryptionKeyPassphrase, Passphrase: "sekrit"}
+	encrypted := s.encrypt(c, enc, []byte("snapshot data"))
+
+	magicLen := len("snapd-snapshot-encrypted\n")
+	hdrLen := int(binary.BigEndian.Uint32(encrypted[magicLen:]))
+	hdr := string(encrypted[magicLen+4 : magicLen+4+hdrLen])
+	c.Assert(strings.Count(hdr, `"scrypt":{"n":32768,"r":8,"p":1}`), check.Equals, 1)
+
+	for _, t := range []struct {
+		params string
+		err    string
+	}{
+		{`{"n":1073741824,"r":8,"p":1}`, `invalid scrypt cost 1073741824`},
+		{`{"n":0,"r":8,"p":1}`, `invalid scrypt cost 0`},
+		{`{"n":1000,"r":8,"p":1}`, `invalid scrypt cost 1000`},
+		{`{"n":32768,"r":1024,"p":1}`, `invalid scrypt block size 1024`},
+		{`{"n":32768,"r":8,"p":0}`, `invalid scrypt parallelization 0`},
+		{`{"n":32768,"r":8,"p":100000}`, `invalid scrypt parallelization 100000`},
+		{`{"n":1048576,"r":32,"p":1}`, `scrypt parameters require too much memory`},
+	} {
+		tamperedHdr := strings.Replace(hdr, `{"n":32768,"r":8,"p":1}`, t.params, 1)
+		var tampered bytes.Buffer
+		tampered.Write(encrypted[:magicLen])
+		binary.Write(&tampered, binary.BigEndian, uint32(len(tamperedHdr)))
+		tampered.WriteString(tamperedHdr)
+		tampered.Write(encrypted[magicLen+4+hdrLen:])
+
+		_, err := s.decrypt(tampered.Bytes(), "sekrit")
+		c.Check(err, check.ErrorMatches, `cannot derive snapshot encryption key: invalid key derivation parameters: `+t.err, check.Commentf("%s", t.params))
+	}
+}
+
+func (s *encryptionSuite) TestTruncated(c *check.C) {
+	enc := &backend.ExportEncryption{KeySource: backend.EncryptionKeyDevice}
+	data := bytes.Repeat([]byte{'x'}, 2*backend.EncryptedExportChunkSize+10)
+	encrypted := s.encrypt(c, enc, data)
+
+	// drop the last chunk, the export ends at a chunk boundary then
+	lastChunk := 10 + 16
+	_, err := s.decrypt(encrypted[:len(encrypted)-lastChunk], "")
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot export: .* \(truncated or corrupted data\?\)`)
+
+	// drop all chunks
+	sealedData := len(data) + 3*16
+	_, err = s.decrypt(encrypted[:len(encrypted)-sealedData], "")
+	c.Check(err, check.ErrorMatches, `encrypted snapshot export is truncated`)
+
+	// truncated header
+	_, err = s.decrypt(encrypted[:40], "")
+	c.Check(err, check.ErrorMatches, `encrypted snapshot export is truncated`)
+}
+
+func (s *encryptionSuite) TestNotEncrypted(c *check.C) {
+	for _, data := range []string{"", "short", "some plain export data that is not encrypted"} {
+		plain, err := s.decrypt([]byte(data), "")
+		c.Assert(err, check.IsNil)
+		c.Check(string(plain), check.Equals, data)
+	}
+}
+
+func (s *encryptionSuite) TestNewSnapshotExportInvalidEncryption(c *check.C) {
+	s.AddCleanup(backend.MockExportKeySecret(func() ([]byte, error) {
+		return nil, errors.New("no device encryption key available")
+	}))
+
+	for _, t := range []struct {
+		enc *backend.ExportEncryption
+		err string
+	}{
+		{&backend.ExportEncryption{KeySource: backend.EncryptionKeyDevice}, `cannot export snapshot 1: cannot use device-bound encryption: no device encryption key available`},
+		{&backend.ExportEncryption{KeySource: backend.EncryptionKeyPassphrase}, `cannot export snapshot 1: cannot use passphrase encryption without a passphrase`},
+		{&backend.ExportEncryption{KeySource: "foo"}, `cannot export snapshot 1: unknown snapshot encryption key source "foo"`},
+	} {
+		_, err := backend.NewSnapshotExport(context.Background(), 1, t.enc)
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+}
+
+func (s *encryptionSuite) TestHeaderIsReadable(c *check.C) {
+	enc := &backend.ExportEncryption{KeySource: backend.EncryptionKeyPassphrase, Passphrase: "sekrit"}
+	encrypted := s.encrypt(c, enc, []byte("snapshot data"))
+
+	c.Check(strings.HasPrefix(string(encrypted), "snapd-snapshot-encrypted\n"), check.Equals, true)
+	c.Check(string(encrypted), testutil.Contains, `"cipher":"aes-256-gcm","key-source":"passphrase"`)
+	c.Check(string(encrypted), check.Not(testutil.Contains), "snapshot data")
+}
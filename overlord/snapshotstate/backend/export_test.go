@@ -146,3 +146,17 @@ func MockFilepathGlob(new func(pattern string) (matches []string, err error)) (r
 func (se *SnapshotExport) ContentHash() []byte {
 	return se.contentHash
 }
+
+var (
+	NewEncryptingWriter = newEncryptingWriter
+
+	EncryptedExportChunkSize = encryptedExportChunkSize
+)
+
+func MockExportKeySecret(f func() ([]byte, error)) (restore func()) {
+	old := exportKeySecret
+	exportKeySecret = f
+	return func() {
+		exportKeySecret = old
+	}
+}
@@ -179,7 +179,7 @@ func MockBackendEstimateSnapshotSize(f func(*snap.Info, []string, *dirs.SnapDirO
 	}
 }
 
-func MockBackendNewSnapshotExport(f func(ctx context.Context, setID uint64) (se *SnapshotExport, err error)) (restore func()) {
+func MockBackendNewSnapshotExport(f func(ctx context.Context, setID uint64, enc *ExportEncryption) (se *SnapshotExport, err error)) (restore func()) {
 	old := backendNewSnapshotExport
 	backendNewSnapshotExport = f
 	return func() {
@@ -215,3 +215,11 @@ func MockGetSnapDirOptions(f func(*state.State, string) (*dirs.SnapDirOptions, e
 		getSnapDirOpts = old
 	}
 }
+
+func MockSnapstateWithFrozenSnapProcesses(f func(*state.State, string, func() error) error) (restore func()) {
+	old := snapstateWithFrozenSnapProcesses
+	snapstateWithFrozenSnapProcesses = f
+	return func() {
+		snapstateWithFrozenSnapProcesses = old
+	}
+}
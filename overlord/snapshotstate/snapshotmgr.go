@@ -52,10 +52,13 @@ var (
 	backendCleanup       = (*backend.RestoreState).Cleanup
 
 	backendCleanupAbandondedImports = backend.CleanupAbandondedImports
+	backendNewImportReader          = backend.NewImportReader
 
 	autoExpirationInterval = time.Hour * 24 // interval between forgetExpiredSnapshots runs as part of Ensure()
 
 	getSnapDirOpts = snapstate.GetSnapDirOpts
+
+	snapstateWithFrozenSnapProcesses = snapstate.WithFrozenSnapProcesses
 )
 
 // SnapshotManager takes snapshots of active snaps
@@ -232,7 +235,12 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 		return err
 	}
 
-	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, opts)
+	// the snap is frozen such that the data of the processes still running
+	// is saved in a consistent state
+	err = snapstateWithFrozenSnapProcesses(st, snapshot.Snap, func() error {
+		_, err := backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, opts)
+		return err
+	})
 	if err != nil {
 		st.Lock()
 		defer st.Unlock()
@@ -377,6 +385,10 @@ func doCleanupAfterRestore(task *state.Task, tomb *tomb.Tomb) error {
 	restoreTasks := task.WaitTasks()
 	st.Unlock()
 	for _, t := range restoreTasks {
+		if t.Kind() != "restore-snapshot" {
+			// eg. post-restore hooks
+			continue
+		}
 		if err := cleanupRestore(t, tomb); err != nil {
 			logger.Noticef("Cleanup of restore task %s failed: %v", task.ID(), err)
 			// do not quit the loop: we must perform all cleanups anyway
@@ -35,10 +35,13 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -338,6 +341,48 @@ func (snapshotSuite) TestDoSaveGetsSnapDirOpts(c *check.C) {
 	c.Check(checkOpts, check.Equals, true)
 }
 
+func (snapshotSuite) TestDoSaveFreezesSnap(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
+		return &snapInfo, nil
+	})()
+
+	frozen := false
+	saved := false
+	defer snapshotstate.MockSnapstateWithFrozenSnapProcesses(func(_ *state.State, snapName string, f func() error) error {
+		c.Check(snapName, check.Equals, "a-snap")
+		frozen = true
+		err := f()
+		c.Check(saved, check.Equals, true)
+		frozen = false
+		return err
+	})()
+	defer snapshotstate.MockBackendSave(func(context.Context, uint64, *snap.Info, map[string]interface{}, []string, *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		// the snapshot is taken while the snap is frozen
+		c.Check(frozen, check.Equals, true)
+		saved = true
+		return nil, errors.New("bzzt")
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]interface{}{
+		"snap": "a-snap",
+	})
+	st.Unlock()
+
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Assert(err, check.ErrorMatches, "bzzt")
+	c.Check(saved, check.Equals, true)
+}
+
 func (snapshotSuite) TestDoSaveFailsWithNoSnap(c *check.C) {
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
 		return nil, errors.New("bzzt")
@@ -908,3 +953,97 @@ func (snapshotSuite) TestManagerRunCleanupAbandondedImportsAtStartupErrorLogged(
 	c.Check(n, check.Equals, 1)
 	c.Check(logbuf.String(), testutil.Contains, "cannot cleanup incomplete imports: some error\n")
 }
+
+func (snapshotSuite) testRestoreWithPostRestoreHook(c *check.C, hookErr error) (chg *state.Change, calls []string) {
+	o := overlord.Mock()
+	st := o.State()
+	hookMgr, err := hookstate.Manager(st, o.TaskRunner())
+	c.Assert(err, check.IsNil)
+	o.AddManager(hookMgr)
+	o.AddManager(snapshotstate.Manager(st, o.TaskRunner()))
+	o.AddManager(o.TaskRunner())
+
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		calls = append(calls, ctx.HookName()+" hook")
+		return nil, hookErr
+	})
+	defer restore()
+
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "foo.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		return f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 1, Snap: "a-snap"},
+			File:     shotfile,
+		})
+	})()
+	defer snapshotstate.MockBackendOpen(func(string, uint64) (*backend.Reader, error) {
+		return &backend.Reader{}, nil
+	})()
+	defer snapshotstate.MockBackendRestore(func(*backend.Reader, context.Context, snap.Revision, []string, backend.Logf, *dirs.SnapDirOptions) (*backend.RestoreState, error) {
+		calls = append(calls, "restore")
+		return &backend.RestoreState{}, nil
+	})()
+	defer snapshotstate.MockBackendRevert(func(*backend.RestoreState) {
+		calls = append(calls, "revert")
+	})()
+	defer snapshotstate.MockBackendCleanup(func(*backend.RestoreState) {
+		calls = append(calls, "cleanup")
+	})()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
+		return nil, nil
+	})()
+	defer snapshotstate.MockConfigSetSnapConfig(func(*state.State, string, *json.RawMessage) error {
+		return nil
+	})()
+	defer snapshotstate.MockGetSnapDirOptions(func(*state.State, string) (*dirs.SnapDirOptions, error) {
+		return nil, nil
+	})()
+
+	st.Lock()
+	defer st.Unlock()
+
+	sideInfo := &snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)}
+	snapstate.Set(st, "a-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{sideInfo},
+		Current:  sideInfo.Revision,
+		SnapType: "app",
+	})
+	snaptest.MockSnap(c, "{name: a-snap, version: v1, hooks: {post-restore: }}", sideInfo)
+
+	_, ts, err := snapshotstate.Restore(st, 1, nil, nil)
+	c.Assert(err, check.IsNil)
+	chg = st.NewChange("restore-snapshot", "...")
+	chg.AddAll(ts)
+
+	st.Unlock()
+	err = o.Settle(5 * time.Second)
+	st.Lock()
+	c.Assert(err, check.IsNil)
+
+	return chg, calls
+}
+
+func (s snapshotSuite) TestRestoreWithPostRestoreHookHappy(c *check.C) {
+	chg, calls := s.testRestoreWithPostRestoreHook(c, nil)
+	st := chg.State()
+	st.Lock()
+	defer st.Unlock()
+
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+	c.Check(calls, check.DeepEquals, []string{"restore", "post-restore hook", "cleanup"})
+}
+
+func (s snapshotSuite) TestRestoreWithPostRestoreHookFailure(c *check.C) {
+	chg, calls := s.testRestoreWithPostRestoreHook(c, errors.New("cannot migrate data"))
+	st := chg.State()
+	st.Lock()
+	defer st.Unlock()
+
+	c.Check(chg.Status(), check.Equals, state.ErrorStatus)
+	c.Check(chg.Err(), check.ErrorMatches, `(?s).*run hook "post-restore": cannot migrate data.*`)
+	// the restore is undone
+	c.Check(calls, check.DeepEquals, []string{"restore", "post-restore hook", "revert"})
+}
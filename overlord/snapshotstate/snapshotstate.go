@@ -31,6 +31,7 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -46,6 +47,7 @@ var (
 	backendEstimateSnapshotSize      = backend.EstimateSnapshotSize
 	backendList                      = backend.List
 	backendNewSnapshotExport         = backend.NewSnapshotExport
+	setupPostRestoreHook             = hookstate.SetupPostRestoreHook
 
 	// Default expiration time for automatic snapshots, if not set by the user
 	defaultAutomaticSnapshotExpiration = time.Hour * 24 * 31
@@ -335,8 +337,15 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 	return sets, nil
 }
 
-// Import a given snapshot ID from an exported snapshot
-func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, snapNames []string, err error) {
+// Import a given snapshot ID from an exported snapshot. If the export is
+// encrypted with a passphrase, the passphrase must be given.
+func Import(ctx context.Context, st *state.State, r io.Reader, passphrase string) (setID uint64, snapNames []string, err error) {
+	// the export may need to be read twice below, wrap it only once
+	r, err = backendNewImportReader(r, passphrase)
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot import snapshot: %v", err)
+	}
+
 	st.Lock()
 	setID, err = newSnapshotSetID(st)
 	// note, this is a new set id which is not exposed yet, no need to mark it
@@ -491,6 +500,7 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 
 	for _, summary := range summaries {
 		var current snap.Revision
+		var hasPostRestoreHook bool
 		if snapst, ok := all[summary.snap]; ok {
 			info, err := snapst.CurrentInfo()
 			if err != nil {
@@ -506,6 +516,7 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 				return nil, nil, fmt.Errorf(tpl, summary.snap, info.SnapID, summary.snapID)
 			}
 			current = snapst.Current
+			hasPostRestoreHook = info.Hooks["post-restore"] != nil
 		}
 
 		desc := fmt.Sprintf("Restore data of snap %q from snapshot set #%d", summary.snap, setID)
@@ -520,6 +531,14 @@ func Restore(st *state.State, setID uint64, snapNames []string, users []string)
 		task.Set("snapshot-setup", &snapshot)
 		// see the note about snapshots not using lanes, above.
 		ts.AddTask(task)
+
+		if hasPostRestoreHook {
+			// let the snap migrate or validate the restored data;
+			// if the hook fails all the restores are undone
+			hookTask := setupPostRestoreHook(st, summary.snap, setID)
+			hookTask.WaitFor(task)
+			ts.AddTask(hookTask)
+		}
 	}
 
 	if len(summaries) > 0 {
@@ -625,15 +644,15 @@ func UnsetSnapshotOpInProgress(st *state.State, setID uint64) string {
 	return op
 }
 
-// Export exports a given snapshot ID
+// Export exports a given snapshot ID, encrypted if enc is not nil.
 // Note that the state must be locked by the caller.
-func Export(ctx context.Context, st *state.State, setID uint64) (se *backend.SnapshotExport, err error) {
+func Export(ctx context.Context, st *state.State, setID uint64, enc *ExportEncryption) (se *backend.SnapshotExport, err error) {
 	if err := checkSnapshotConflict(st, setID, "forget-snapshot"); err != nil {
 		return nil, err
 	}
 
 	setSnapshotOpInProgress(st, setID, "export-snapshot")
-	se, err = backendNewSnapshotExport(ctx, setID)
+	se, err = backendNewSnapshotExport(ctx, setID, enc)
 	if err != nil {
 		UnsetSnapshotOpInProgress(st, setID)
 	}
@@ -642,3 +661,6 @@ func Export(ctx context.Context, st *state.State, setID uint64) (se *backend.Sna
 
 // SnapshotExport provides a snapshot export that can be streamed out
 type SnapshotExport = backend.SnapshotExport
+
+// ExportEncryption describes how a snapshot export is encrypted
+type ExportEncryption = backend.ExportEncryption
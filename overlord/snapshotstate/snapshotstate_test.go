@@ -45,6 +45,7 @@ import (
 	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -1135,6 +1136,69 @@ func (snapshotSuite) TestRestoreWorksWithCompatibleEpoch(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestRestoreTasksWithPostRestoreHook(c *check.C) {
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+
+	fakeSnapstateAll := func(*state.State) (map[string]*snapstate.SnapState, error) {
+		all := make(map[string]*snapstate.SnapState)
+		for _, name := range []string{"a-snap", "b-snap"} {
+			sideInfo := &snap.SideInfo{RealName: name, Revision: snap.R(1)}
+			all[name] = &snapstate.SnapState{
+				Active:   true,
+				Sequence: []*snap.SideInfo{sideInfo},
+				Current:  sideInfo.Revision,
+			}
+		}
+		return all, nil
+	}
+	defer snapshotstate.MockSnapstateAll(fakeSnapstateAll)()
+	snaptest.MockSnap(c, "{name: a-snap, version: v1, hooks: {post-restore: }}", &snap.SideInfo{RealName: "a-snap", Revision: snap.R(1)})
+	snaptest.MockSnap(c, "{name: b-snap, version: v1}", &snap.SideInfo{RealName: "b-snap", Revision: snap.R(1)})
+
+	fakeIter := func(_ context.Context, f func(*backend.Reader) error) error {
+		for _, name := range []string{"a-snap", "b-snap"} {
+			c.Assert(f(&backend.Reader{
+				Snapshot: client.Snapshot{SetID: 42, Snap: name},
+				File:     shotfile,
+			}), check.IsNil)
+		}
+		return nil
+	}
+	defer snapshotstate.MockBackendIter(fakeIter)()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	found, taskset, err := snapshotstate.Restore(st, 42, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(found, check.DeepEquals, []string{"a-snap", "b-snap"})
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 4)
+	c.Check(tasks[0].Kind(), check.Equals, "restore-snapshot")
+	c.Check(tasks[0].Summary(), check.Equals, `Restore data of snap "a-snap" from snapshot set #42`)
+	// only a-snap has the hook
+	c.Check(tasks[1].Kind(), check.Equals, "run-hook")
+	c.Check(tasks[1].Summary(), check.Equals, `Run post-restore hook of "a-snap" snap`)
+	c.Check(tasks[1].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0]})
+	var hooksup hookstate.HookSetup
+	c.Assert(tasks[1].Get("hook-setup", &hooksup), check.IsNil)
+	c.Check(hooksup, check.DeepEquals, hookstate.HookSetup{
+		Snap:     "a-snap",
+		Hook:     "post-restore",
+		Optional: true,
+	})
+	var hookCtx map[string]interface{}
+	c.Assert(tasks[1].Get("hook-context", &hookCtx), check.IsNil)
+	c.Check(hookCtx, check.DeepEquals, map[string]interface{}{"snapshot-set-id": 42.})
+	c.Check(tasks[2].Kind(), check.Equals, "restore-snapshot")
+	c.Check(tasks[2].Summary(), check.Equals, `Restore data of snap "b-snap" from snapshot set #42`)
+	c.Check(tasks[3].Kind(), check.Equals, "cleanup-after-restore")
+	c.Check(tasks[3].WaitTasks(), check.DeepEquals, tasks[:3])
+}
+
 func (snapshotSuite) TestRestore(c *check.C) {
 	shotfile, err := os.Create(filepath.Join(c.MkDir(), "yadda.zip"))
 	c.Assert(err, check.IsNil)
@@ -1825,7 +1889,7 @@ func (snapshotSuite) TestImportSnapshotHappy(c *check.C) {
 	})
 	defer restore()
 
-	sid, names, err := snapshotstate.Import(context.TODO(), st, buf)
+	sid, names, err := snapshotstate.Import(context.TODO(), st, buf, "")
 	c.Assert(err, check.IsNil)
 	c.Check(sid, check.Equals, uint64(1))
 	c.Check(names, check.DeepEquals, fakeSnapNames)
@@ -1840,7 +1904,7 @@ func (snapshotSuite) TestImportSnapshotImportError(c *check.C) {
 	defer restore()
 
 	r := bytes.NewBufferString("faked-import-data")
-	sid, _, err := snapshotstate.Import(context.TODO(), st, r)
+	sid, _, err := snapshotstate.Import(context.TODO(), st, r, "")
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, "some-error")
 	c.Check(sid, check.Equals, uint64(0))
@@ -1861,7 +1925,7 @@ func (snapshotSuite) TestImportSnapshotDuplicate(c *check.C) {
 	})
 	st.Unlock()
 
-	sid, snapNames, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""))
+	sid, snapNames, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""), "")
 	c.Assert(err, check.IsNil)
 	c.Check(sid, check.Equals, uint64(3))
 	c.Check(snapNames, check.DeepEquals, []string{"foo-snap"})
@@ -1979,7 +2043,7 @@ func (snapshotSuite) TestExportSnapshotConflictsWithForget(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, err := snapshotstate.Export(context.TODO(), st, 42)
+	_, err := snapshotstate.Export(context.TODO(), st, 42, nil)
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, `cannot operate on snapshot set #42 while change "1" is in progress`)
 }
@@ -1995,7 +2059,7 @@ func (snapshotSuite) TestImportSnapshotDuplicatedNoConflict(c *check.C) {
 	defer restore()
 
 	st := state.New(nil)
-	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf)
+	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf, "")
 	c.Check(importCalls, check.Equals, 1)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(42))
@@ -2035,7 +2099,7 @@ func (snapshotSuite) TestImportSnapshotConflictsWithForget(c *check.C) {
 	chg.AddTask(tsk)
 
 	st.Unlock()
-	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf)
+	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf, "")
 	st.Lock()
 	c.Check(importCalls, check.Equals, 2)
 	c.Assert(err, check.IsNil)
@@ -2044,7 +2108,7 @@ func (snapshotSuite) TestImportSnapshotConflictsWithForget(c *check.C) {
 }
 
 func (snapshotSuite) TestExportSnapshotSetsOpInProgress(c *check.C) {
-	restore := snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64) (se *backend.SnapshotExport, err error) {
+	restore := snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64, enc *snapshotstate.ExportEncryption) (se *backend.SnapshotExport, err error) {
 		return nil, nil
 	})
 	defer restore()
@@ -2053,7 +2117,7 @@ func (snapshotSuite) TestExportSnapshotSetsOpInProgress(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, err := snapshotstate.Export(context.TODO(), st, 42)
+	_, err := snapshotstate.Export(context.TODO(), st, 42, nil)
 	c.Assert(err, check.IsNil)
 
 	ops := st.Cached("snapshot-ops")
@@ -37,16 +37,19 @@ import (
 // If Manual is set it is the target of an enabled manual alias.
 // Auto is set to the target for an automatic alias, enabled or
 // disabled depending on the automatic aliases flag state.
+// SetDisabled is set if the alias belongs to an alias set of the snap
+// that is disabled, in which case the alias is disabled regardless.
 type AliasTarget struct {
-	Manual string `json:"manual,omitempty"`
-	Auto   string `json:"auto,omitempty"`
+	Manual      string `json:"manual,omitempty"`
+	Auto        string `json:"auto,omitempty"`
+	SetDisabled bool   `json:"set-disabled,omitempty"`
 }
 
 // Effective returns the target to use considering whether automatic
 // aliases are disabled for the whole snap (autoDisabled), returns ""
 // if the alias is disabled.
 func (at *AliasTarget) Effective(autoDisabled bool) string {
-	if at == nil {
+	if at == nil || at.SetDisabled {
 		return ""
 	}
 	if at.Manual != "" {
@@ -65,6 +68,7 @@ func (at *AliasTarget) Effective(autoDisabled bool) string {
                 ...
 		Aliases              map[string]*AliasTarget
 		AutoAliasesDisabled  bool
+		DisabledAliasSets    []string
 	}
 
    There are two kinds of aliases:
@@ -87,6 +91,11 @@ func (at *AliasTarget) Effective(autoDisabled bool) string {
      that has the same name as an automatic one, the manual target
      is what wins
 
+   * a snap can group aliases, automatic or manual, in named alias
+     sets declared in its snap.yaml; the aliases of a disabled set
+     (tracked with DisabledAliasSets) are disabled together, this is
+     reflected in AliasTarget.SetDisabled
+
 */
 
 // autoDisabled options and doApply
@@ -384,7 +393,7 @@ func disableAliases(curAliases map[string]*AliasTarget) (newAliases map[string]*
 			disabledManual[alias] = curTarget.Manual
 		}
 		if curTarget.Auto != "" {
-			newAliases[alias] = &AliasTarget{Auto: curTarget.Auto}
+			newAliases[alias] = &AliasTarget{Auto: curTarget.Auto, SetDisabled: curTarget.SetDisabled}
 		}
 	}
 	if len(disabledManual) == 0 {
@@ -438,12 +447,32 @@ func pruneAutoAliases(curAliases map[string]*AliasTarget, autoAliases []string)
 		if curTarget.Manual == "" {
 			delete(newAliases, alias)
 		} else {
-			newAliases[alias] = &AliasTarget{Manual: curTarget.Manual}
+			newAliases[alias] = &AliasTarget{Manual: curTarget.Manual, SetDisabled: curTarget.SetDisabled}
 		}
 	}
 	return newAliases
 }
 
+// applyAliasSets returns newAliases from curAliases with the aliases
+// belonging to the alias sets of info listed in disabledSets marked as
+// disabled, and any other alias unmarked.
+func applyAliasSets(info *snap.Info, disabledSets []string, curAliases map[string]*AliasTarget) (newAliases map[string]*AliasTarget) {
+	newAliases = make(map[string]*AliasTarget, len(curAliases))
+	for alias, aliasTarget := range curAliases {
+		setDisabled := false
+		if set := info.AliasSetOf(alias); set != "" {
+			setDisabled = strutil.ListContains(disabledSets, set)
+		}
+		if aliasTarget.SetDisabled != setDisabled {
+			newTarget := *aliasTarget
+			newTarget.SetDisabled = setDisabled
+			aliasTarget = &newTarget
+		}
+		newAliases[alias] = aliasTarget
+	}
+	return newAliases
+}
+
 // transition to aliases v2
 func (m *SnapManager) ensureAliasesV2() error {
 	m.state.Lock()
@@ -501,6 +530,7 @@ func (m *SnapManager) ensureAliasesV2() error {
 			logger.Noticef("cannot get automatic aliases for %q: %v", instanceName, err)
 			continue
 		}
+		newAliases = applyAliasSets(info, snapst.DisabledAliasSets, newAliases)
 		// TODO: check for conflicts
 		if len(newAliases) != 0 {
 			snapst.Aliases = newAliases
@@ -670,12 +700,63 @@ func manualUnalias(curAliases map[string]*AliasTarget, alias string) (newAliases
 	if newTarget.Auto == "" {
 		delete(newAliases, alias)
 	} else {
-		newAliases[alias] = &AliasTarget{Auto: newTarget.Auto}
+		newAliases[alias] = &AliasTarget{Auto: newTarget.Auto, SetDisabled: newTarget.SetDisabled}
 	}
 
 	return newAliases, nil
 }
 
+// EnableAliasSet enables all the aliases in the given alias set of a snap.
+func EnableAliasSet(st *state.State, instanceName, set string) (*state.TaskSet, error) {
+	return toggleAliasSet(st, instanceName, set, true)
+}
+
+// DisableAliasSet disables all the aliases in the given alias set of a
+// snap, manual ones included.
+func DisableAliasSet(st *state.State, instanceName, set string) (*state.TaskSet, error) {
+	return toggleAliasSet(st, instanceName, set, false)
+}
+
+func toggleAliasSet(st *state.State, instanceName, set string, enable bool) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, instanceName, &snapst)
+	if errors.Is(err, state.ErrNoState) {
+		return nil, &snap.NotInstalledError{Snap: instanceName}
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := info.AliasSets[set]; !ok {
+		return nil, fmt.Errorf("snap %q has no alias set %q", instanceName, set)
+	}
+
+	if err := CheckChangeConflict(st, instanceName, nil); err != nil {
+		return nil, err
+	}
+
+	snapName, instanceKey := snap.SplitInstanceName(instanceName)
+	snapsup := &SnapSetup{
+		SideInfo:    &snap.SideInfo{RealName: snapName},
+		InstanceKey: instanceKey,
+	}
+
+	kind := "disable-alias-set"
+	summary := fmt.Sprintf(i18n.G("Disable alias set %q for snap %q"), set, instanceName)
+	if enable {
+		kind = "enable-alias-set"
+		summary = fmt.Sprintf(i18n.G("Enable alias set %q for snap %q"), set, instanceName)
+	}
+	toggle := st.NewTask(kind, summary)
+	toggle.Set("alias-set", set)
+	toggle.Set("snap-setup", &snapsup)
+
+	return state.NewTaskSet(toggle), nil
+}
+
 // Prefer enables all aliases of a snap in preference to conflicting aliases
 // of other snaps whose aliases will be disabled (removed for manual ones).
 func Prefer(st *state.State, name string) (*state.TaskSet, error) {
@@ -1552,3 +1552,135 @@ func (s *snapmgrTestSuite) TestPreferUpdateChangeConflict(c *C) {
 	_, err = snapstate.Update(s.state, "some-snap", nil, s.user.ID, snapstate.Flags{})
 	c.Assert(err, ErrorMatches, `snap "some-snap" has "prefer" change in progress`)
 }
+
+func (s *snapmgrTestSuite) TestAliasSetTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+		Active:  true,
+	})
+
+	ts, err := snapstate.DisableAliasSet(s.state, "alias-snap", "tools")
+	c.Assert(err, IsNil)
+	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"disable-alias-set",
+	})
+	var set string
+	c.Assert(ts.Tasks()[0].Get("alias-set", &set), IsNil)
+	c.Check(set, Equals, "tools")
+
+	ts, err = snapstate.EnableAliasSet(s.state, "alias-snap", "tools")
+	c.Assert(err, IsNil)
+	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"enable-alias-set",
+	})
+	c.Assert(s.state.TaskCount(), Equals, 2)
+}
+
+func (s *snapmgrTestSuite) TestAliasSetErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+		Active:  true,
+	})
+
+	_, err := snapstate.DisableAliasSet(s.state, "alias-snap", "other")
+	c.Check(err, ErrorMatches, `snap "alias-snap" has no alias set "other"`)
+
+	_, err = snapstate.EnableAliasSet(s.state, "no-snap", "tools")
+	c.Check(err, ErrorMatches, `snap "no-snap" is not installed`)
+	c.Check(s.state.TaskCount(), Equals, 0)
+}
+
+func (s *snapmgrTestSuite) TestDisableEnableAliasSetRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+		Active:  true,
+		Aliases: map[string]*snapstate.AliasTarget{
+			"alias1": {Manual: "cmd5", Auto: "cmd1"},
+			"alias2": {Auto: "cmd2"},
+			"alias3": {Manual: "cmd3"},
+		},
+	})
+
+	chg := s.state.NewChange("disable-alias-set", "...")
+	ts, err := snapstate.DisableAliasSet(s.state, "alias-snap", "tools")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "alias-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.DisabledAliasSets, DeepEquals, []string{"tools"})
+	c.Check(snapst.AutoAliasesDisabled, Equals, false)
+	c.Check(snapst.Aliases, DeepEquals, map[string]*snapstate.AliasTarget{
+		"alias1": {Manual: "cmd5", Auto: "cmd1", SetDisabled: true},
+		"alias2": {Auto: "cmd2", SetDisabled: true},
+		"alias3": {Manual: "cmd3"},
+	})
+
+	chg = s.state.NewChange("enable-alias-set", "...")
+	ts, err = snapstate.EnableAliasSet(s.state, "alias-snap", "tools")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.settle(c)
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	expected := fakeOps{
+		{
+			op: "update-aliases",
+			rmAliases: []*backend.Alias{
+				{Name: "alias1", Target: "alias-snap.cmd5"},
+				{Name: "alias2", Target: "alias-snap.cmd2"},
+			},
+		},
+		{
+			op: "update-aliases",
+			aliases: []*backend.Alias{
+				{Name: "alias1", Target: "alias-snap.cmd5"},
+				{Name: "alias2", Target: "alias-snap.cmd2"},
+			},
+		},
+	}
+	// start with an easier-to-read error if this fails:
+	c.Assert(s.fakeBackend.ops.Ops(), DeepEquals, expected.Ops())
+	c.Assert(s.fakeBackend.ops, DeepEquals, expected)
+
+	err = snapstate.Get(s.state, "alias-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.DisabledAliasSets, HasLen, 0)
+	c.Check(snapst.Aliases, DeepEquals, map[string]*snapstate.AliasTarget{
+		"alias1": {Manual: "cmd5", Auto: "cmd1"},
+		"alias2": {Auto: "cmd2"},
+		"alias3": {Manual: "cmd3"},
+	})
+
+	var trace traceData
+	err = chg.Get("api-data", &trace)
+	c.Assert(err, IsNil)
+	c.Check(trace.Added, HasLen, 2)
+	c.Check(trace.Removed, HasLen, 0)
+}
@@ -55,6 +55,14 @@ const maxPostponementBuffer = 5 * 24 * time.Hour
 // to "13 days" left.
 const maxInhibition = 14*24*time.Hour - time.Second
 
+// cannot defer auto-refreshes of a snap to respect the refresh window it
+// declares for more than maxRefreshWindowPostponement since its last refresh
+const maxRefreshWindowPostponement = 14 * 24 * time.Hour
+
+// how far ahead the overlap of the refresh window of a snap with the refresh
+// schedule is looked for, long enough for schedules of weekdays in a month
+const refreshWindowOverlapHorizon = 5 * 7 * 24 * time.Hour
+
 // maxDuration is used to represent "forever" internally (it's 290 years).
 const maxDuration = time.Duration(1<<63 - 1)
 
@@ -227,23 +235,36 @@ func (m *autoRefresh) AtSeed() error {
 	return nil
 }
 
-func canRefreshOnMeteredConnection(st *state.State) (bool, error) {
+// meteredLargeDownloadSize is the download size above which the
+// auto-refresh of a snap is deferred while on a metered connection, if
+// refresh.metered is set to defer-large.
+var meteredLargeDownloadSize int64 = 512 * 1024 * 1024
+
+func refreshMeteredOption(st *state.State) (string, error) {
 	tr := config.NewTransaction(st)
 	var onMetered string
 	err := tr.GetMaybe("core", "refresh.metered", &onMetered)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return "", err
+	}
+	return onMetered, nil
+}
+
+func canRefreshOnMeteredConnection(st *state.State) (bool, error) {
+	onMetered, err := refreshMeteredOption(st)
+	if err != nil {
 		return false, err
 	}
 
-	return onMetered != "hold", nil
+	return onMetered != "hold" && onMetered != "hold-all", nil
 }
 
 func (m *autoRefresh) canRefreshRespectingMetered(now, lastRefresh time.Time) (can bool, err error) {
-	can, err = canRefreshOnMeteredConnection(m.state)
+	onMetered, err := refreshMeteredOption(m.state)
 	if err != nil {
 		return false, err
 	}
-	if can {
+	if onMetered != "hold" && onMetered != "hold-all" {
 		return true, nil
 	}
 
@@ -254,7 +275,8 @@ func (m *autoRefresh) canRefreshRespectingMetered(now, lastRefresh time.Time) (c
 		return true, nil
 	}
 
-	if now.Sub(lastRefresh) >= maxPostponement {
+	// hold-all holds refreshes for as long as the connection is metered
+	if onMetered == "hold" && now.Sub(lastRefresh) >= maxPostponement {
 		// TODO use warnings when the infra becomes available
 		logger.Noticef("Auto refresh disabled while on metered connections, but pending for too long (%d days). Trying to refresh now.", int(maxPostponement.Hours()/24))
 		return true, nil
@@ -265,6 +287,47 @@ func (m *autoRefresh) canRefreshRespectingMetered(now, lastRefresh time.Time) (c
 	return false, nil
 }
 
+// deferLargeRefreshesOnMetered drops from the auto-refresh updates the ones
+// with a download larger than meteredLargeDownloadSize while on a metered
+// connection, if refresh.metered is set to defer-large. Those are then
+// refreshed by a later auto-refresh, once the connection is not metered or
+// auto-refreshes have been pending for too long.
+func deferLargeRefreshesOnMetered(st *state.State, updates []*snap.Info) ([]*snap.Info, error) {
+	onMetered, err := refreshMeteredOption(st)
+	if err != nil {
+		return nil, err
+	}
+	if onMetered != "defer-large" || IsOnMeteredConnection == nil {
+		return updates, nil
+	}
+
+	// ignore any errors that occurred while checking if we are on a metered
+	// connection
+	metered, _ := IsOnMeteredConnection()
+	if !metered {
+		return updates, nil
+	}
+
+	lastRefresh, err := getTime(st, "last-refresh")
+	if err != nil {
+		return nil, err
+	}
+	if !lastRefresh.IsZero() && timeNow().Sub(lastRefresh) >= maxPostponement {
+		logger.Noticef("Large auto refreshes deferred while on metered connections, but pending for too long (%d days). Trying to refresh now.", int(maxPostponement.Hours()/24))
+		return updates, nil
+	}
+
+	actual := make([]*snap.Info, 0, len(updates))
+	for _, update := range updates {
+		if update.DownloadInfo.Size > meteredLargeDownloadSize {
+			logger.Noticef("Auto refresh of snap %q deferred while on metered connection, download size of %s", update.InstanceName(), strutil.SizeToStr(update.DownloadInfo.Size))
+			continue
+		}
+		actual = append(actual, update)
+	}
+	return actual, nil
+}
+
 // Ensure ensures that we refresh all installed snaps periodically
 func (m *autoRefresh) Ensure() error {
 	m.state.Lock()
@@ -325,6 +388,18 @@ func (m *autoRefresh) Ensure() error {
 		logger.Debugf("Next refresh scheduled for %s.", m.nextRefresh.Format(time.RFC3339))
 	}
 
+	// snaps skipped by the last auto-refresh because they were outside
+	// of their refresh window are refreshed once their window and the
+	// refresh schedule overlap, if that happens earlier
+	windowRefresh, err := getTime(m.state, "next-refresh-window")
+	if err != nil {
+		return err
+	}
+	if windowRefresh.After(lastRefresh) && windowRefresh.Before(m.nextRefresh) {
+		m.nextRefresh = windowRefresh
+		logger.Debugf("Next refresh scheduled for %s to respect refresh windows.", m.nextRefresh.Format(time.RFC3339))
+	}
+
 	held, holdTime, err := m.isRefreshHeld()
 	if err != nil {
 		return err
@@ -646,6 +721,159 @@ func inhibitRefresh(st *state.State, snapst *SnapState, info *snap.Info, checker
 	return checkerErr
 }
 
+// refreshTimerSchedule returns the schedule of auto-refreshes, or nil if
+// they are managed by a snap.
+func refreshTimerSchedule(st *state.State) ([]*timeutil.Schedule, error) {
+	scheduleConf, legacy, err := getRefreshScheduleConf(st)
+	if err != nil {
+		return nil, err
+	}
+	var sched []*timeutil.Schedule
+	switch {
+	case scheduleConf == "managed":
+		if CanManageRefreshes != nil && CanManageRefreshes(st) {
+			return nil, nil
+		}
+		return defaultRefreshSchedule, nil
+	case scheduleConf == "":
+		return defaultRefreshSchedule, nil
+	case legacy:
+		sched, err = timeutil.ParseLegacySchedule(scheduleConf)
+	default:
+		sched, err = timeutil.ParseSchedule(scheduleConf)
+	}
+	if err != nil {
+		// the invalid configuration is reported by the auto-refresh
+		return defaultRefreshSchedule, nil
+	}
+	return sched, nil
+}
+
+// nextRefreshWindowOverlap returns the earliest time, from the given one,
+// that is both in the refresh window of a snap and in the refresh schedule,
+// or the zero time if they do not overlap. A nil schedule includes any time.
+func nextRefreshWindowOverlap(window, schedule []*timeutil.Schedule, from time.Time) time.Time {
+	end := from.Add(refreshWindowOverlapHorizon)
+	// schedules have the granularity of a minute
+	for t := from; t.Before(end); t = t.Truncate(time.Minute).Add(time.Minute) {
+		if timeutil.Includes(window, t) && (schedule == nil || timeutil.Includes(schedule, t)) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// recordNextRefreshWindow records when a snap skipped by the auto-refresh is
+// within its refresh window and the refresh schedule again, the next
+// auto-refresh happens then at the latest.
+func recordNextRefreshWindow(st *state.State, next time.Time) error {
+	recorded, err := getTime(st, "next-refresh-window")
+	if err != nil {
+		return err
+	}
+	if recorded.After(timeNow()) && recorded.Before(next) {
+		return nil
+	}
+	st.Set("next-refresh-window", next)
+	return nil
+}
+
+// effectiveRefreshWindow returns the refresh window of the snap, as set with
+// snapctl or otherwise declared in snap.yaml.
+func effectiveRefreshWindow(snapst *SnapState) (string, error) {
+	if snapst.RefreshWindow != "" {
+		return snapst.RefreshWindow, nil
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return "", err
+	}
+	return info.RefreshWindow, nil
+}
+
+// RefreshWindow returns the refresh window of the given snap, as set with
+// snapctl or otherwise declared in snap.yaml, or an empty string if the snap
+// has none.
+func RefreshWindow(st *state.State, instanceName string) (string, error) {
+	var snapst SnapState
+	if err := Get(st, instanceName, &snapst); err != nil {
+		return "", err
+	}
+	return effectiveRefreshWindow(&snapst)
+}
+
+// SetRefreshWindow sets the refresh window of the given snap, in the format
+// of refresh.timer, overriding the one declared in snap.yaml. An empty
+// window reverts to the one declared in snap.yaml.
+func SetRefreshWindow(st *state.State, instanceName, window string) error {
+	if window != "" {
+		if _, err := timeutil.ParseSchedule(window); err != nil {
+			return fmt.Errorf("cannot parse refresh window: %v", err)
+		}
+	}
+	var snapst SnapState
+	if err := Get(st, instanceName, &snapst); err != nil {
+		return err
+	}
+	snapst.RefreshWindow = window
+	Set(st, instanceName, &snapst)
+	return nil
+}
+
+// outsideRefreshWindow returns whether the auto-refresh of the given snap
+// should be deferred because the snap has a refresh window that does not
+// include the current time. The window is intersected with the refresh
+// schedule, it is ignored if they never overlap, and the next auto-refresh
+// is scheduled for when they do. Auto-refreshes are not deferred for longer
+// than maxRefreshWindowPostponement since the last refresh of the snap.
+func outsideRefreshWindow(st *state.State, instanceName string) (bool, error) {
+	var snapst SnapState
+	if err := Get(st, instanceName, &snapst); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return false, nil
+		}
+		return false, err
+	}
+	windowStr, err := effectiveRefreshWindow(&snapst)
+	if err != nil {
+		return false, err
+	}
+	if windowStr == "" {
+		return false, nil
+	}
+	window, err := timeutil.ParseSchedule(windowStr)
+	if err != nil {
+		// the window is validated when it is set
+		logger.Noticef("cannot use refresh window of snap %q: %v", instanceName, err)
+		return false, nil
+	}
+
+	now := timeNow()
+	if timeutil.Includes(window, now) {
+		return false, nil
+	}
+	schedule, err := refreshTimerSchedule(st)
+	if err != nil {
+		return false, err
+	}
+	next := nextRefreshWindowOverlap(window, schedule, now)
+	if next.IsZero() {
+		logger.Noticef("cannot use refresh window of snap %q: it does not overlap with the refresh schedule", instanceName)
+		return false, nil
+	}
+	lastRefresh, err := lastRefreshed(st, instanceName)
+	if err != nil {
+		return false, err
+	}
+	if lastRefresh.Add(maxRefreshWindowPostponement).Before(now) {
+		return false, nil
+	}
+	if err := recordNextRefreshWindow(st, next); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // for testing outside of snapstate
 func MockRefreshCandidate(snapSetup *SnapSetup, version string) interface{} {
 	return &refreshCandidate{
@@ -713,7 +713,7 @@ func conditionalAutoRefreshAffectedSnaps(t *state.Task) ([]string, error) {
 }
 
 // snapsToRefresh returns all snaps that should proceed with refresh considering
-// hold behavior.
+// hold behavior and the refresh windows of the snaps.
 var snapsToRefresh = func(gatingTask *state.Task) ([]*refreshCandidate, error) {
 	var snaps map[string]*refreshCandidate
 	if err := gatingTask.Get("snaps", &snaps); err != nil {
@@ -725,20 +725,32 @@ var snapsToRefresh = func(gatingTask *state.Task) ([]*refreshCandidate, error) {
 		return nil, err
 	}
 
-	var skipped []string
+	var skipped, outsideWindow []string
 	var candidates []*refreshCandidate
 	for _, s := range snaps {
-		if !held[s.InstanceName()] {
-			candidates = append(candidates, s)
-		} else {
+		if held[s.InstanceName()] {
 			skipped = append(skipped, s.InstanceName())
+			continue
+		}
+		outside, err := outsideRefreshWindow(gatingTask.State(), s.InstanceName())
+		if err != nil {
+			return nil, err
+		}
+		if outside {
+			outsideWindow = append(outsideWindow, s.InstanceName())
+			continue
 		}
+		candidates = append(candidates, s)
 	}
 
 	if len(skipped) > 0 {
 		sort.Strings(skipped)
 		logger.Noticef("skipping refresh of held snaps: %s", strings.Join(skipped, ","))
 	}
+	if len(outsideWindow) > 0 {
+		sort.Strings(outsideWindow)
+		logger.Noticef("skipping refresh of snaps outside of their refresh window: %s", strings.Join(outsideWindow, ","))
+	}
 
 	return candidates, nil
 }
@@ -292,6 +292,34 @@ func (s *autoRefreshTestSuite) TestLastRefreshNoRefreshNeeded(c *C) {
 	c.Check(s.store.ops, HasLen, 0)
 }
 
+func (s *autoRefreshTestSuite) TestRefreshForRefreshWindows(c *C) {
+	s.state.Lock()
+	t0 := time.Now()
+	s.state.Set("last-refresh", t0.Add(-time.Hour))
+	// snaps were skipped by the last auto-refresh, their refresh window
+	// overlaps with the refresh schedule in an hour
+	s.state.Set("next-refresh-window", t0.Add(time.Hour))
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", "00:00-24:00/1")
+	tr.Commit()
+	s.state.Unlock()
+
+	af := snapstate.NewAutoRefresh(s.state)
+	err := af.Ensure()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, HasLen, 0)
+	c.Check(af.NextRefresh().Equal(t0.Add(time.Hour)), Equals, true)
+
+	// a time recorded before the last refresh is obsolete
+	s.state.Lock()
+	s.state.Set("next-refresh-window", t0.Add(-2*time.Hour))
+	s.state.Unlock()
+	af = snapstate.NewAutoRefresh(s.state)
+	err = af.Ensure()
+	c.Check(err, IsNil)
+	c.Check(af.NextRefresh().After(t0.Add(time.Hour)), Equals, true)
+}
+
 func (s *autoRefreshTestSuite) TestRefreshBackoff(c *C) {
 	s.store.err = fmt.Errorf("random store error")
 	af := snapstate.NewAutoRefresh(s.state)
@@ -853,6 +881,26 @@ func (s *autoRefreshTestSuite) TestCanRefreshOnMetered(c *C) {
 	c.Assert(can, Equals, false)
 	c.Assert(err, Equals, nil)
 
+	// hold all refreshes when on metered connection
+	tr = config.NewTransaction(s.state)
+	err = tr.Set("core", "refresh.metered", "hold-all")
+	c.Assert(err, IsNil)
+	tr.Commit()
+
+	can, err = snapstate.CanRefreshOnMeteredConnection(s.state)
+	c.Assert(can, Equals, false)
+	c.Assert(err, Equals, nil)
+
+	// only large downloads are deferred when on metered connection
+	tr = config.NewTransaction(s.state)
+	err = tr.Set("core", "refresh.metered", "defer-large")
+	c.Assert(err, IsNil)
+	tr.Commit()
+
+	can, err = snapstate.CanRefreshOnMeteredConnection(s.state)
+	c.Assert(can, Equals, true)
+	c.Assert(err, Equals, nil)
+
 	// explicitly disable holding refreshes when on metered connection
 	tr = config.NewTransaction(s.state)
 	err = tr.Set("core", "refresh.metered", "")
@@ -900,6 +948,80 @@ func (s *autoRefreshTestSuite) TestRefreshOnMeteredConnIsMetered(c *C) {
 	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
 }
 
+func (s *autoRefreshTestSuite) TestRefreshOnMeteredConnIsMeteredHoldAll(c *C) {
+	// pretend we're on metered connection
+	revert := snapstate.MockIsOnMeteredConnection(func() (bool, error) {
+		return true, nil
+	})
+	defer revert()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.metered", "hold-all")
+	tr.Commit()
+
+	af := snapstate.NewAutoRefresh(s.state)
+
+	// last refresh over 96 days ago, still no refresh
+	s.state.Set("last-refresh", time.Now().Add(-96*24*time.Hour))
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, HasLen, 0)
+
+	c.Check(af.NextRefresh(), DeepEquals, time.Time{})
+}
+
+func (s *autoRefreshTestSuite) TestDeferLargeRefreshesOnMetered(c *C) {
+	metered := true
+	revert := snapstate.MockIsOnMeteredConnection(func() (bool, error) {
+		return metered, nil
+	})
+	defer revert()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	small := &snap.Info{SideInfo: snap.SideInfo{RealName: "small"}, DownloadInfo: snap.DownloadInfo{Size: 100 * 1024 * 1024}}
+	large := &snap.Info{SideInfo: snap.SideInfo{RealName: "large"}, DownloadInfo: snap.DownloadInfo{Size: 2 * 1024 * 1024 * 1024}}
+	updates := []*snap.Info{small, large}
+
+	s.state.Set("last-refresh", time.Now().Add(-5*24*time.Hour))
+
+	// nothing deferred by default
+	actual, err := snapstate.DeferLargeRefreshesOnMetered(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(actual, DeepEquals, []*snap.Info{small, large})
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.metered", "defer-large")
+	tr.Commit()
+
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	actual, err = snapstate.DeferLargeRefreshesOnMetered(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(actual, DeepEquals, []*snap.Info{small})
+	c.Check(logbuf.String(), testutil.Contains, `Auto refresh of snap "large" deferred while on metered connection, download size of 2GB`)
+
+	// nothing deferred when not on metered connection
+	metered = false
+	actual, err = snapstate.DeferLargeRefreshesOnMetered(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(actual, DeepEquals, []*snap.Info{small, large})
+
+	// nor when refreshes are pending for too long
+	metered = true
+	s.state.Set("last-refresh", time.Now().Add(-96*24*time.Hour))
+	actual, err = snapstate.DeferLargeRefreshesOnMetered(s.state, updates)
+	c.Assert(err, IsNil)
+	c.Check(actual, DeepEquals, []*snap.Info{small, large})
+}
+
 func (s *autoRefreshTestSuite) TestRefreshOnMeteredConnNotMetered(c *C) {
 	// pretend we're on non-metered connection
 	revert := snapstate.MockIsOnMeteredConnection(func() (bool, error) {
@@ -75,7 +75,7 @@ type managerBackend interface {
 	CopySnapData(newSnap, oldSnap *snap.Info, opts *dirs.SnapDirOptions, meter progress.Meter) error
 	SetupSnapSaveData(info *snap.Info, dev snap.Device, meter progress.Meter) error
 	LinkSnap(info *snap.Info, dev snap.Device, linkCtx backend.LinkContext, tm timings.Measurer) (rebootInfo boot.RebootInfo, err error)
-	StartServices(svcs []*snap.AppInfo, disabledSvcs []string, meter progress.Meter, tm timings.Measurer) error
+	StartServices(svcs []*snap.AppInfo, disabledSvcs []string, handoff bool, meter progress.Meter, tm timings.Measurer) error
 	StopServices(svcs []*snap.AppInfo, reason snap.ServiceStopReason, meter progress.Meter, tm timings.Measurer) error
 	ServicesEnableState(info *snap.Info, meter progress.Meter) (map[string]bool, error)
 	QueryDisabledServices(info *snap.Info, pb progress.Meter) ([]string, error)
@@ -87,6 +87,10 @@ type managerBackend interface {
 	// cleanup
 	ClearTrashedData(oldSnap *snap.Info)
 
+	// successor migration related
+	MigrateSnapData(oldSnap, newSnap *snap.Info, opts *dirs.SnapDirOptions) error
+	UndoMigrateSnapData(oldSnap, newSnap *snap.Info, opts *dirs.SnapDirOptions) error
+
 	// remove related
 	UnlinkSnap(info *snap.Info, linkCtx backend.LinkContext, meter progress.Meter) error
 	RemoveSnapFiles(s snap.PlaceInfo, typ snap.Type, installRecord *backend.InstallRecord, dev snap.Device, meter progress.Meter) error
@@ -142,6 +142,23 @@ func (b Backend) UndoSetupSnapSaveData(newInfo, oldInfo *snap.Info, dev snap.Dev
 	return nil
 }
 
+// MigrateSnapData makes a copy of the data of oldSnap for newSnap, a
+// different snap taking over from oldSnap after a rename.
+func (b Backend) MigrateSnapData(oldSnap, newSnap *snap.Info, opts *dirs.SnapDirOptions) error {
+	return migrateSnapData(oldSnap, newSnap, opts)
+}
+
+// UndoMigrateSnapData removes the copy of the data of oldSnap made for
+// newSnap, restoring what may have been there before.
+func (b Backend) UndoMigrateSnapData(oldSnap, newSnap *snap.Info, opts *dirs.SnapDirOptions) error {
+	_, newDirs, err := migratedDataDirs(oldSnap, newSnap, opts)
+	if err != nil {
+		return err
+	}
+	undoMigratedDirs(newDirs)
+	return nil
+}
+
 // ClearTrashedData removes the trash. It returns no errors on the assumption that it is called very late in the game.
 func (b Backend) ClearTrashedData(oldSnap *snap.Info) {
 	dataDirs, err := snapDataDirs(oldSnap, nil)
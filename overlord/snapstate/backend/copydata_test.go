@@ -582,6 +582,47 @@ func (s *copydataSuite) TestUndoCopyDataSameRevision(c *C) {
 	}
 }
 
+func (s *copydataSuite) TestMigrateSnapDataDoUndo(c *C) {
+	v1 := snaptest.MockSnap(c, helloYaml1, &snap.SideInfo{Revision: snap.R(10)})
+	homedir1 := s.populateHomeData(c, "user1", snap.R(10))
+	c.Assert(os.MkdirAll(filepath.Join(homedir1, "hello", "common"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(homedir1, "hello", "common", "canary.common_home"), nil, 0644), IsNil)
+	c.Assert(os.MkdirAll(v1.DataDir(), 0755), IsNil)
+	c.Assert(os.MkdirAll(v1.CommonDataDir(), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(v1.DataDir(), "canary.txt"), nil, 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(v1.CommonDataDir(), "canary.common"), nil, 0644), IsNil)
+
+	successor := snaptest.MockSnap(c, "name: hello-ng\nversion: 1.0\n", &snap.SideInfo{Revision: snap.R(3)})
+	// leftovers of a previous install of the successor are moved aside
+	c.Assert(os.MkdirAll(successor.DataDir(), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(successor.DataDir(), "leftover"), nil, 0644), IsNil)
+
+	err := s.be.MigrateSnapData(v1, successor, nil)
+	c.Assert(err, IsNil)
+
+	migrated := []string{
+		filepath.Join(dirs.SnapDataDir, "hello-ng", "3", "canary.txt"),
+		filepath.Join(dirs.SnapDataDir, "hello-ng", "common", "canary.common"),
+		filepath.Join(homedir1, "hello-ng", "3", "canary.home"),
+		filepath.Join(homedir1, "hello-ng", "common", "canary.common_home"),
+	}
+	for _, fn := range migrated {
+		c.Check(osutil.FileExists(fn), Equals, true, Commentf(fn))
+	}
+	c.Check(filepath.Join(successor.DataDir(), "leftover"), testutil.FileAbsent)
+	// the data of the old snap is left alone
+	c.Check(filepath.Join(v1.DataDir(), "canary.txt"), testutil.FilePresent)
+	c.Check(filepath.Join(homedir1, "hello", "10", "canary.home"), testutil.FilePresent)
+
+	err = s.be.UndoMigrateSnapData(v1, successor, nil)
+	c.Assert(err, IsNil)
+	for _, fn := range migrated {
+		c.Check(osutil.FileExists(fn), Equals, false, Commentf(fn))
+	}
+	c.Check(filepath.Join(successor.DataDir(), "leftover"), testutil.FilePresent)
+	c.Check(filepath.Join(v1.DataDir(), "canary.txt"), testutil.FilePresent)
+}
+
 const (
 	mountRunMntUbuntuSaveFmt = `26 27 8:3 / %s/run/mnt/ubuntu-save rw,relatime shared:7 - ext4 /dev/fakedevice0p1 rw,data=ordered`
 	mountSnapSaveFmt         = `26 27 8:3 / %s/var/lib/snapd/save rw,relatime shared:7 - ext4 /dev/fakedevice0p1 rw,data=ordered`
@@ -175,8 +175,11 @@ func (b Backend) LinkSnap(info *snap.Info, dev snap.Device, linkCtx LinkContext,
 	return rebootInfo, nil
 }
 
-func (b Backend) StartServices(apps []*snap.AppInfo, disabledSvcs []string, meter progress.Meter, tm timings.Measurer) error {
-	flags := &wrappers.StartServicesFlags{Enable: true}
+// StartServices starts the services of the given apps. If handoff is set,
+// running services using socket handoff are first handed off to the new
+// revision instead of being stopped with their sockets.
+func (b Backend) StartServices(apps []*snap.AppInfo, disabledSvcs []string, handoff bool, meter progress.Meter, tm timings.Measurer) error {
+	flags := &wrappers.StartServicesFlags{Enable: true, Handoff: handoff}
 	return wrappers.StartServices(apps, disabledSvcs, flags, meter, tm)
 }
 
@@ -208,9 +211,12 @@ func (b Backend) generateWrappers(s *snap.Info, linkCtx LinkContext) error {
 
 	vitalityRank := 0
 	var quotaGrp *quota.Group
+	var hostAfter, hostBefore []string
 	if linkCtx.ServiceOptions != nil {
 		vitalityRank = linkCtx.ServiceOptions.VitalityRank
 		quotaGrp = linkCtx.ServiceOptions.QuotaGroup
+		hostAfter = linkCtx.ServiceOptions.HostAfter
+		hostBefore = linkCtx.ServiceOptions.HostBefore
 	}
 	// add the daemons from the snap.yaml
 	opts := &wrappers.AddSnapServicesOptions{
@@ -218,6 +224,8 @@ func (b Backend) generateWrappers(s *snap.Info, linkCtx LinkContext) error {
 		Preseeding:              b.preseed,
 		RequireMountedSnapdSnap: linkCtx.RequireMountedSnapdSnap,
 		QuotaGroup:              quotaGrp,
+		HostAfter:               hostAfter,
+		HostBefore:              hostBefore,
 	}
 	// TODO: switch to EnsureSnapServices
 	if err = wrappers.AddSnapServices(s, opts, progress.Null); err != nil {
@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/snap"
 )
 
@@ -189,6 +190,89 @@ func copySnapData(oldSnap, newSnap *snap.Info, opts *dirs.SnapDirOptions) (err e
 	return nil
 }
 
+// migratedDataDirs returns the data directories of oldSnap, including the
+// common ones, along with the matching directories of newSnap, a different
+// snap taking over the data after a rename.
+func migratedDataDirs(oldSnap, newSnap *snap.Info, opts *dirs.SnapDirOptions) (oldDirs, newDirs []string, err error) {
+	dataDirs, err := snapDataDirs(oldSnap, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	commonDirs, err := filepath.Glob(oldSnap.CommonDataHomeDir(opts))
+	if err != nil {
+		return nil, nil, err
+	}
+	commonDirs = append(commonDirs, oldSnap.UserCommonDataDir(filepath.Join(dirs.GlobalRootDir, "/root/"), opts))
+	commonDirs = append(commonDirs, oldSnap.CommonDataDir())
+
+	newSuffix := filepath.Base(newSnap.DataDir())
+	for _, oldDir := range dataDirs {
+		// replace the trailing "$old-name/$old-suffix" with "$new-name/$new-suffix"
+		oldDirs = append(oldDirs, oldDir)
+		newDirs = append(newDirs, filepath.Join(filepath.Dir(filepath.Dir(oldDir)), newSnap.InstanceName(), newSuffix))
+	}
+	for _, oldDir := range commonDirs {
+		oldDirs = append(oldDirs, oldDir)
+		newDirs = append(newDirs, filepath.Join(filepath.Dir(filepath.Dir(oldDir)), newSnap.InstanceName(), "common"))
+	}
+	return oldDirs, newDirs, nil
+}
+
+// Copy all data of oldSnap to newSnap, which has a different name
+// (but never overwrite)
+func migrateSnapData(oldSnap, newSnap *snap.Info, opts *dirs.SnapDirOptions) (err error) {
+	oldDirs, newDirs, err := migratedDataDirs(oldSnap, newSnap, opts)
+	if err != nil {
+		return err
+	}
+	done := make([]string, 0, len(newDirs))
+	defer func() {
+		if err == nil {
+			return
+		}
+		undoMigratedDirs(done)
+	}()
+
+	for i, oldDir := range oldDirs {
+		newDir := newDirs[i]
+		oldParent, err := os.Stat(filepath.Dir(oldDir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// the directory of the snap in the home of a user belongs to
+		// the user
+		uid, gid := sys.UserID(osutil.NoChown), sys.GroupID(osutil.NoChown)
+		if st, ok := oldParent.Sys().(*unix.Stat_t); ok {
+			uid, gid = sys.UserID(st.Uid), sys.GroupID(st.Gid)
+		}
+		if err := osutil.MkdirAllChown(filepath.Dir(newDir), oldParent.Mode().Perm(), uid, gid); err != nil {
+			return err
+		}
+		if err := copySnapDataDirectory(oldDir, newDir); err != nil {
+			return err
+		}
+		done = append(done, newDir)
+	}
+
+	return nil
+}
+
+// undoMigratedDirs removes the given migrated data directories and restores
+// what was there before.
+func undoMigratedDirs(newDirs []string) {
+	for _, newDir := range newDirs {
+		if err := os.RemoveAll(newDir); err != nil {
+			logger.Noticef("while undoing migration of data directory %q: %v", newDir, err)
+		}
+		if err := untrash(newDir); err != nil {
+			logger.Noticef("while restoring the old version of data directory %q: %v", newDir, err)
+		}
+	}
+}
+
 // trashPath returns the trash path for the given path. This will
 // differ only in the last element.
 func trashPath(path string) string {
@@ -167,9 +167,14 @@ type fakeStore struct {
 	fakeCurrentProgress int
 	fakeTotalProgress   int
 	// snap -> error map for simulating download errors
-	downloadError   map[string]error
-	state           *state.State
-	seenPrivacyKeys map[string]bool
+	downloadError map[string]error
+	// snaps whose download is served from the download cache, without
+	// the store verifying their digest
+	downloadCached map[string]bool
+	// called while downloading, for simulating slow downloads
+	downloadCallback func(ctx context.Context) error
+	state            *state.State
+	seenPrivacyKeys  map[string]bool
 }
 
 func (f *fakeStore) pokeStateLock() {
@@ -323,8 +328,35 @@ func (f *fakeStore) snap(spec snapSpec) (*snap.Info, error) {
 		slot.Apps["dbus-daemon"] = info.Apps["dbus-daemon"]
 	}
 
-	if spec.Name == "provenance-snap" {
+	switch spec.Name {
+	case "provenance-snap":
 		info.SnapProvenance = "prov"
+	case "nested-producer":
+		info.Plugs = map[string]*snap.PlugInfo{
+			"content-plug": {
+				Snap:      info,
+				Interface: "content",
+				Attrs: map[string]interface{}{
+					"content":          "leaf-content",
+					"default-provider": "leaf-producer",
+				},
+			},
+		}
+		info.Slots = map[string]*snap.SlotInfo{
+			"content-slot": {
+				Snap:      info,
+				Interface: "content",
+				Attrs:     map[string]interface{}{"content": "nested-content"},
+			},
+		}
+	case "leaf-producer":
+		info.Slots = map[string]*snap.SlotInfo{
+			"content-slot": {
+				Snap:      info,
+				Interface: "content",
+				Attrs:     map[string]interface{}{"content": "leaf-content"},
+			},
+		}
 	}
 
 	return info, nil
@@ -423,6 +455,8 @@ func (f *fakeStore) lookupRefresh(cand refreshCand) (*snap.Info, error) {
 		name = "some-other-snap"
 	case "provenance-snap-id":
 		name = "provenance-snap"
+	case "renamed-snap-id":
+		name = "renamed-snap"
 	default:
 		panic(fmt.Sprintf("refresh: unknown snap-id: %s", cand.snapID))
 	}
@@ -483,6 +517,8 @@ func (f *fakeStore) lookupRefresh(cand refreshCand) (*snap.Info, error) {
 		}
 	} else if name == "provenance-snap" {
 		info.SnapProvenance = "prov"
+	} else if name == "renamed-snap" {
+		info.Successor = "renamed-snap-ng"
 	}
 
 	switch cand.channel {
@@ -700,14 +736,16 @@ func (f *fakeStore) Download(ctx context.Context, name, targetFn string, snapInf
 		macaroon = user.StoreMacaroon
 	}
 	// only add the options if they contain anything interesting
-	if *dlOpts == (store.DownloadOptions{}) {
-		dlOpts = nil
+	var opts *store.DownloadOptions
+	if *dlOpts != (store.DownloadOptions{}) {
+		optsCopy := *dlOpts
+		opts = &optsCopy
 	}
 	f.downloads = append(f.downloads, fakeDownload{
 		macaroon: macaroon,
 		name:     name,
 		target:   targetFn,
-		opts:     dlOpts,
+		opts:     opts,
 	})
 	f.fakeBackend.appendOp(&fakeOp{op: "storesvc-download", name: name})
 
@@ -717,6 +755,12 @@ func (f *fakeStore) Download(ctx context.Context, name, targetFn string, snapInf
 	if e, ok := f.downloadError[name]; ok {
 		return e
 	}
+	if f.downloadCallback != nil {
+		if err := f.downloadCallback(ctx); err != nil {
+			return err
+		}
+	}
+	dlOpts.HashVerified = !f.downloadCached[name]
 
 	return nil
 }
@@ -761,6 +805,8 @@ type fakeSnappyBackend struct {
 	emptyContainer          snap.Container
 
 	servicesCurrentlyDisabled []string
+	// handoff passed to the last StartServices call
+	startServicesHandoff bool
 
 	lockDir string
 
@@ -830,8 +876,9 @@ func (f *fakeSnappyBackend) OpenSnapFile(snapFilePath string, si *snap.SideInfo)
 }
 
 // XXX: this is now something that is overridden by tests that need a
-//      different service setup so it should be configurable and part
-//      of the fakeSnappyBackend?
+//
+//	different service setup so it should be configurable and part
+//	of the fakeSnappyBackend?
 var servicesSnapYaml = `name: services-snap
 apps:
   svc1:
@@ -931,11 +978,19 @@ apps:
   cmd5:
   cmddaemon:
     daemon: simple
+alias-sets:
+  tools:
+    - alias1
+    - alias2
 `))
 		if err != nil {
 			panic(err)
 		}
 		info.SideInfo = *si
+	case "renamed-snap", "renamed-snap-ng":
+		info.Apps = map[string]*snap.AppInfo{
+			"app": {Snap: info, Name: "app"},
+		}
 	case "snap-core18-to-core22":
 		info.Base = "core18"
 		if info.Revision.N > 1 {
@@ -972,6 +1027,19 @@ apps:
 	f.infos[name] = info
 }
 
+func (f *fakeSnappyBackend) addSnapYaml(snapYaml string) {
+	if f.infos == nil {
+		f.infos = make(map[string]*snap.Info)
+	}
+
+	info, err := snap.InfoFromSnapYaml([]byte(snapYaml))
+	if err != nil {
+		panic(err)
+	}
+
+	f.infos[info.InstanceName()] = info
+}
+
 func (f *fakeSnappyBackend) ClearTrashedData(si *snap.Info) {
 	f.appendOp(&fakeOp{
 		op:    "cleanup-trash",
@@ -1066,7 +1134,8 @@ func svcSnapMountDir(svcs []*snap.AppInfo) string {
 	return svcs[0].Snap.MountDir()
 }
 
-func (f *fakeSnappyBackend) StartServices(svcs []*snap.AppInfo, disabledSvcs []string, meter progress.Meter, tm timings.Measurer) error {
+func (f *fakeSnappyBackend) StartServices(svcs []*snap.AppInfo, disabledSvcs []string, handoff bool, meter progress.Meter, tm timings.Measurer) error {
+	f.startServicesHandoff = handoff
 	services := make([]string, 0, len(svcs))
 	for _, svc := range svcs {
 		services = append(services, svc.Name)
@@ -1153,6 +1222,24 @@ func (f *fakeSnappyBackend) UndoCopySnapData(newInfo *snap.Info, oldInfo *snap.I
 	return f.maybeErrForLastOp()
 }
 
+func (f *fakeSnappyBackend) MigrateSnapData(oldInfo, newInfo *snap.Info, opts *dirs.SnapDirOptions) error {
+	f.appendOp(&fakeOp{
+		op:   "migrate-snap-data",
+		path: newInfo.MountDir(),
+		old:  oldInfo.MountDir(),
+	})
+	return f.maybeErrForLastOp()
+}
+
+func (f *fakeSnappyBackend) UndoMigrateSnapData(oldInfo, newInfo *snap.Info, opts *dirs.SnapDirOptions) error {
+	f.appendOp(&fakeOp{
+		op:   "undo-migrate-snap-data",
+		path: newInfo.MountDir(),
+		old:  oldInfo.MountDir(),
+	})
+	return f.maybeErrForLastOp()
+}
+
 func (f *fakeSnappyBackend) UndoSetupSnapSaveData(newInfo, oldInfo *snap.Info, _ snap.Device, meter progress.Meter) error {
 	old := "<no-old>"
 	if oldInfo != nil {
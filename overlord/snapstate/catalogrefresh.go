@@ -20,8 +20,11 @@
 package snapstate
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
@@ -36,18 +39,24 @@ import (
 	"github.com/snapcore/snapd/randutil"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timings"
 )
 
 var (
 	catalogRefreshDelayBase      = 24 * time.Hour
 	catalogRefreshDelayWithDelta = 24*time.Hour + 1 + randutil.RandomDuration(6*time.Hour)
+	// the first catalog refresh is not done right away so that it
+	// does not compete with the rest of the startup
+	catalogRefreshStartupDelay = 1 * time.Minute
 )
 
 type catalogRefresh struct {
 	state *state.State
 
 	nextCatalogRefresh time.Time
+	// firstRefresh is set while the first refresh is pending
+	firstRefresh bool
 }
 
 func newCatalogRefresh(st *state.State) *catalogRefresh {
@@ -96,13 +105,22 @@ func (r *catalogRefresh) Ensure() error {
 			// add the delay with the delta so we spread the load a bit
 			r.nextCatalogRefresh = st.ModTime().Add(catalogRefreshDelayWithDelta)
 		} else {
-			// first time scheduling, add the delta
-			delay = catalogRefreshDelayWithDelta
+			// first time scheduling, refresh shortly after
+			// starting up
+			r.nextCatalogRefresh = now.Add(catalogRefreshStartupDelay)
+			r.firstRefresh = true
+			if catalogRefreshStartupDelay > 0 {
+				r.state.EnsureBefore(catalogRefreshStartupDelay)
+			}
 		}
 	}
+	if r.firstRefresh {
+		// add the delta to the following refresh
+		delay = catalogRefreshDelayWithDelta
+	}
 
 	theStore := Store(r.state, nil)
-	needsRefresh := r.nextCatalogRefresh.IsZero() || r.nextCatalogRefresh.Before(now)
+	needsRefresh := !r.nextCatalogRefresh.After(now)
 
 	if !needsRefresh {
 		return nil
@@ -111,6 +129,7 @@ func (r *catalogRefresh) Ensure() error {
 	next := now.Add(delay)
 	// catalog refresh does not carry on trying on error
 	r.nextCatalogRefresh = next
+	r.firstRefresh = false
 
 	logger.Debugf("Catalog refresh starting now; next scheduled for %s.", next)
 
@@ -163,6 +182,15 @@ func refreshCatalogs(st *state.State, theStore StoreService) error {
 		return err
 	}
 
+	// the search index is best effort, a failure keeps the previous one
+	var searchIndex *store.SearchIndex
+	timings.Run(perfTimings, "write-search-index", "query store for the snaps in each section", func(tm timings.Measurer) {
+		searchIndex, err = writeSearchIndex(theStore, sections)
+	})
+	if err != nil {
+		logger.Noticef("cannot refresh the search index: %v", err)
+	}
+
 	namesFile, err := osutil.NewAtomicFile(dirs.SnapNamesFile, 0644, 0, osutil.NoChown, osutil.NoChown)
 	if err != nil {
 		return err
@@ -177,12 +205,16 @@ func refreshCatalogs(st *state.State, theStore StoreService) error {
 	// if all goes well we'll Commit() making this a NOP:
 	defer cmdDB.Rollback()
 
+	var names bytes.Buffer
 	timings.Run(perfTimings, "write-catalogs", "query store for catalogs", func(tm timings.Measurer) {
-		err = theStore.WriteCatalogs(auth.EnsureContextTODO(), namesFile, cmdDB)
+		err = theStore.WriteCatalogs(auth.EnsureContextTODO(), &names, cmdDB)
 	})
 	if err != nil {
 		return err
 	}
+	if err := writeNames(namesFile, &names, searchIndex); err != nil {
+		return err
+	}
 
 	err1 := namesFile.Commit()
 	err2 := cmdDB.Commit()
@@ -197,3 +229,55 @@ func refreshCatalogs(st *state.State, theStore StoreService) error {
 
 	return err1
 }
+
+// writeSearchIndex queries the store for the snaps in each of the given
+// sections and writes the resulting search index.
+func writeSearchIndex(theStore StoreService, sections []string) (*store.SearchIndex, error) {
+	idx := store.NewSearchIndex()
+	for _, section := range sections {
+		search := &store.Search{
+			Category: section,
+			Scope:    "wide",
+		}
+		found, err := theStore.Find(auth.EnsureContextTODO(), search, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find snaps in section %q: %v", section, err)
+		}
+		idx.Add(section, found)
+	}
+
+	indexFile, err := osutil.NewAtomicFile(dirs.SnapSearchIndexFile, 0644, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return nil, err
+	}
+	defer indexFile.Cancel()
+	if err := idx.Write(indexFile); err != nil {
+		return nil, err
+	}
+	if err := indexFile.Commit(); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// writeNames writes the sorted names of the snaps from the catalog and from
+// the search index, if any, used for completing snap names.
+func writeNames(w io.Writer, catalogNames io.Reader, searchIndex *store.SearchIndex) error {
+	var names []string
+	scanner := bufio.NewScanner(catalogNames)
+	for scanner.Scan() {
+		if name := scanner.Text(); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if searchIndex != nil {
+		names = append(names, searchIndex.Names()...)
+	}
+	sort.Strings(names)
+	names = strutil.Deduplicate(names)
+	_, err := io.WriteString(w, strings.Join(names, "\n"))
+	return err
+}
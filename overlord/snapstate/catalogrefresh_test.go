@@ -21,6 +21,7 @@ package snapstate_test
 
 import (
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -36,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/store/storetest"
@@ -45,8 +47,34 @@ import (
 type catalogStore struct {
 	storetest.Store
 
-	ops     []string
-	tooMany bool
+	ops      []string
+	tooMany  bool
+	findFail bool
+}
+
+func (r *catalogStore) Find(ctx context.Context, search *store.Search, _ *auth.UserState) ([]*snap.Info, error) {
+	if ctx == nil || !auth.IsEnsureContext(ctx) {
+		panic("Ensure marked context required")
+	}
+	r.ops = append(r.ops, "find:"+search.Category)
+	if r.findFail {
+		return nil, errors.New("boom")
+	}
+	pkg3 := &snap.Info{
+		SideInfo: snap.SideInfo{RealName: "pkg3", SnapID: "pkg3-id", EditedSummary: "pkg3 summary"},
+		Version:  "3.0",
+	}
+	switch search.Category {
+	case "section1":
+		return []*snap.Info{pkg3}, nil
+	case "section2":
+		pkg1 := &snap.Info{
+			SideInfo: snap.SideInfo{RealName: "pkg1", SnapID: "pkg1-id", EditedSummary: "pkg1 summary"},
+			Version:  "1.0",
+		}
+		return []*snap.Info{pkg3, pkg1}, nil
+	}
+	return nil, nil
 }
 
 func (r *catalogStore) WriteCatalogs(ctx context.Context, w io.Writer, a store.SnapAdder) error {
@@ -101,6 +129,9 @@ func (s *catalogRefreshTestSuite) SetUpTest(c *C) {
 
 	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
 	s.AddCleanup(func() { snapstate.CanAutoRefresh = nil })
+
+	// refresh right away
+	s.AddCleanup(snapstate.MockCatalogRefreshStartupDelay(0))
 }
 
 func (s *catalogRefreshTestSuite) TestCatalogRefresh(c *C) {
@@ -120,13 +151,26 @@ func (s *catalogRefreshTestSuite) TestCatalogRefresh(c *C) {
 	// next now has a delta (next refresh is not before t0 + delta)
 	c.Check(snapstate.NextCatalogRefresh(cr7).Before(t0.Add(snapstate.CatalogRefreshDelayWithDelta)), Equals, false)
 
-	c.Check(s.store.ops, DeepEquals, []string{"sections", "write-catalog"})
+	c.Check(s.store.ops, DeepEquals, []string{"sections", "find:section1", "find:section2", "write-catalog"})
 
 	c.Check(osutil.FileExists(dirs.SnapSectionsFile), Equals, true)
 	c.Check(dirs.SnapSectionsFile, testutil.FileEquals, "section1\nsection2")
 
 	c.Check(osutil.FileExists(dirs.SnapNamesFile), Equals, true)
-	c.Check(dirs.SnapNamesFile, testutil.FileEquals, "pkg1\npkg2")
+	// the names of the snaps in the search index are included
+	c.Check(dirs.SnapNamesFile, testutil.FileEquals, "pkg1\npkg2\npkg3")
+
+	c.Check(dirs.SnapSearchIndexFile, testutil.FilePresent)
+	found, err := store.FindInSearchIndex(dirs.SnapSearchIndexFile, &store.Search{Category: "section1"})
+	c.Assert(err, IsNil)
+	c.Assert(found, HasLen, 1)
+	c.Check(found[0].InstanceName(), Equals, "pkg3")
+	found, err = store.FindInSearchIndex(dirs.SnapSearchIndexFile, &store.Search{Query: "summary"})
+	c.Assert(err, IsNil)
+	c.Assert(found, HasLen, 2)
+	c.Check(found[0].InstanceName(), Equals, "pkg1")
+	c.Check(found[0].Summary(), Equals, "pkg1 summary")
+	c.Check(found[1].InstanceName(), Equals, "pkg3")
 
 	c.Check(osutil.FileExists(dirs.SnapCommandsDB), Equals, true)
 	dump, err := advisor.DumpCommands()
@@ -138,6 +182,47 @@ func (s *catalogRefreshTestSuite) TestCatalogRefresh(c *C) {
 	})
 }
 
+func (s *catalogRefreshTestSuite) TestCatalogRefreshSearchIndexFailure(c *C) {
+	s.store.findFail = true
+
+	cr7 := snapstate.NewCatalogRefresh(s.state)
+	err := cr7.Ensure()
+	c.Check(err, IsNil)
+
+	// the rest of the catalog is still refreshed
+	c.Check(s.store.ops, DeepEquals, []string{"sections", "find:section1", "write-catalog"})
+	c.Check(dirs.SnapSearchIndexFile, testutil.FileAbsent)
+	c.Check(dirs.SnapSectionsFile, testutil.FileEquals, "section1\nsection2")
+	c.Check(dirs.SnapNamesFile, testutil.FileEquals, "pkg1\npkg2")
+}
+
+func (s *catalogRefreshTestSuite) TestCatalogRefreshDelayedOnStartup(c *C) {
+	restore := snapstate.MockCatalogRefreshStartupDelay(time.Minute)
+	defer restore()
+
+	cr7 := snapstate.NewCatalogRefresh(s.state)
+	t0 := time.Now()
+
+	err := cr7.Ensure()
+	c.Check(err, IsNil)
+
+	// the first refresh is scheduled shortly after
+	c.Check(s.store.ops, HasLen, 0)
+	next := snapstate.NextCatalogRefresh(cr7)
+	c.Check(next.Before(t0.Add(time.Minute)), Equals, false)
+	c.Check(next.After(time.Now().Add(time.Minute)), Equals, false)
+
+	// time passes
+	snapstate.MockCatalogRefreshNextRefresh(cr7, time.Now().Add(-time.Second))
+	t1 := time.Now()
+	err = cr7.Ensure()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, DeepEquals, []string{"sections", "find:section1", "find:section2", "write-catalog"})
+
+	// next now has a delta (next refresh is not before t1 + delta)
+	c.Check(snapstate.NextCatalogRefresh(cr7).Before(t1.Add(snapstate.CatalogRefreshDelayWithDelta)), Equals, false)
+}
+
 func (s *catalogRefreshTestSuite) TestCatalogRefreshTooMany(c *C) {
 	s.store.tooMany = true
 
@@ -203,7 +288,7 @@ func (s *catalogRefreshTestSuite) TestCatalogRefreshTooNew(c *C) {
 	cr7 := snapstate.NewCatalogRefresh(s.state)
 	err := cr7.Ensure()
 	c.Check(err, IsNil)
-	c.Check(s.store.ops, DeepEquals, []string{"sections", "write-catalog"})
+	c.Check(s.store.ops, DeepEquals, []string{"sections", "find:section1", "find:section2", "write-catalog"})
 }
 
 func (s *catalogRefreshTestSuite) TestCatalogRefreshUnSeeded(c *C) {
@@ -289,7 +374,7 @@ func (s *catalogRefreshTestSuite) TestCatalogRefreshSkipWhenTesting(c *C) {
 	c.Check(err, IsNil)
 
 	// refresh happened
-	c.Check(s.store.ops, DeepEquals, []string{"sections", "write-catalog"})
+	c.Check(s.store.ops, DeepEquals, []string{"sections", "find:section1", "find:section2", "write-catalog"})
 
 	c.Check(dirs.SnapSectionsFile, testutil.FilePresent)
 	c.Check(dirs.SnapNamesFile, testutil.FilePresent)
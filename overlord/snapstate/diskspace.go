@@ -0,0 +1,457 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// SpaceShortfall describes the space missing on a filesystem to carry out a
+// change.
+type SpaceShortfall struct {
+	// Path is a location on the filesystem that was checked
+	Path string
+	// Required is the space required on the filesystem, including the
+	// safety margin
+	Required uint64
+	// Missing is the additional free space needed on the filesystem
+	Missing uint64
+}
+
+const (
+	// downloadReservation is the space reserved for downloading a snap,
+	// it is released when the snap is downloaded
+	downloadReservation = "download"
+	// dataCopyReservation is the space reserved for copying the data of
+	// a snap on refresh, it is released when the data is copied
+	dataCopyReservation = "data-copy"
+)
+
+// reservationKey identifies the space needed by a snap for a given purpose.
+type reservationKey struct {
+	instanceName string
+	kind         string
+}
+
+// diskSpacePlan accumulates the space a change will need on the different
+// filesystems it touches, so that it can be checked and reserved before the
+// change is started, rather than failing half way through it.
+type diskSpacePlan struct {
+	// paths in the order they were first added to the plan
+	paths    []string
+	required map[string]uint64
+	// reservations holds the space needed at each path, per snap and
+	// purpose
+	reservations map[string]map[reservationKey]uint64
+}
+
+func newDiskSpacePlan() *diskSpacePlan {
+	return &diskSpacePlan{
+		required:     make(map[string]uint64),
+		reservations: make(map[string]map[reservationKey]uint64),
+	}
+}
+
+// add records that size bytes will be needed at path by the given snap for
+// the given purpose.
+func (p *diskSpacePlan) add(path string, key reservationKey, size uint64) {
+	if _, ok := p.required[path]; !ok {
+		p.paths = append(p.paths, path)
+		p.reservations[path] = make(map[reservationKey]uint64)
+	}
+	p.required[path] += size
+	p.reservations[path][key] += size
+}
+
+// fsDevice returns the ID of the device holding the filesystem of path, or
+// of its closest existing parent, since the directories a change will create
+// may not exist yet.
+var fsDevice = func(path string) (uint64, error) {
+	for {
+		var st syscall.Stat_t
+		err := syscall.Stat(path, &st)
+		if err == nil {
+			return uint64(st.Dev), nil
+		}
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+}
+
+// check verifies that each filesystem of the plan has enough free space for
+// all the requirements on it, plus a safety margin, and returns the
+// shortfalls of those that do not.
+func (p *diskSpacePlan) check() ([]SpaceShortfall, error) {
+	// paths on the same filesystem are checked together, using the
+	// first path added to the plan
+	var groups []string
+	groupRequired := make(map[string]uint64)
+	devGroup := make(map[uint64]string)
+	for _, path := range p.paths {
+		group := path
+		if dev, err := fsDevice(path); err == nil {
+			if g, ok := devGroup[dev]; ok {
+				group = g
+			} else {
+				devGroup[dev] = path
+			}
+		}
+		if _, ok := groupRequired[group]; !ok {
+			groups = append(groups, group)
+		}
+		groupRequired[group] += p.required[path]
+	}
+
+	var shortfalls []SpaceShortfall
+	for _, path := range groups {
+		required := safetyMarginDiskSpace(groupRequired[path])
+		err := osutilCheckFreeSpace(path, required)
+		if err == nil {
+			continue
+		}
+		var notEnough *osutil.NotEnoughDiskSpaceError
+		if !errors.As(err, &notEnough) {
+			return nil, err
+		}
+		shortfalls = append(shortfalls, SpaceShortfall{
+			Path:     path,
+			Required: required,
+			Missing:  uint64(notEnough.Delta),
+		})
+	}
+	return shortfalls, nil
+}
+
+// dirSize returns the total size of the regular files under dir, which may
+// not exist.
+var dirSize = func(dir string) (uint64, error) {
+	var total uint64
+	err := filepath.Walk(dir, func(path string, finfo os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if finfo.Mode().IsRegular() {
+			total += uint64(finfo.Size())
+		}
+		return nil
+	})
+	return total, err
+}
+
+// dataCopy describes the data of the current revision of a snap that is
+// copied for its new revision on refresh.
+type dataCopy struct {
+	instanceName string
+	// dir is the data directory of the current revision
+	dir string
+	// path is where the copy is accounted in the plan
+	path string
+}
+
+// dataCopies returns the data directories of the current revision of the
+// snap that are copied for its new revision, as done on refresh.
+func dataCopies(st *state.State, instanceName string) ([]dataCopy, error) {
+	var snapst SnapState
+	if err := Get(st, instanceName, &snapst); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !snapst.IsInstalled() {
+		return nil, nil
+	}
+
+	copies := []dataCopy{{
+		instanceName: instanceName,
+		dir:          snap.DataDir(instanceName, snapst.Current),
+		path:         dirs.SnapDataDir,
+	}}
+
+	opts := &dirs.SnapDirOptions{HiddenSnapDataDir: snapst.MigratedHidden}
+	userDataDirs, err := filepath.Glob(filepath.Join(snap.DataHomeGlob(opts), instanceName, snapst.Current.String()))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range userDataDirs {
+		copies = append(copies, dataCopy{
+			instanceName: instanceName,
+			dir:          dir,
+			path:         filepath.Dir(filepath.Dir(dir)),
+		})
+	}
+	return copies, nil
+}
+
+// addDataCopies adds to the plan the space needed for the given data
+// copies. Sizing the data can take a while, it is meant to be called without
+// holding the state lock.
+func (p *diskSpacePlan) addDataCopies(copies []dataCopy) error {
+	for _, cp := range copies {
+		sz, err := dirSize(cp.dir)
+		if err != nil {
+			return err
+		}
+		if sz > 0 {
+			p.add(cp.path, reservationKey{cp.instanceName, dataCopyReservation}, sz)
+		}
+	}
+	return nil
+}
+
+// spaceReservation records the files holding the space reserved for the
+// needs of a snap, per purpose.
+type spaceReservation struct {
+	Files map[string][]string `json:"files"`
+	Time  time.Time           `json:"time"`
+}
+
+func spaceReservations(st *state.State) (map[string]*spaceReservation, error) {
+	var reservations map[string]*spaceReservation
+	if err := st.Get("space-reservations", &reservations); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if reservations == nil {
+		reservations = make(map[string]*spaceReservation)
+	}
+	return reservations, nil
+}
+
+// reserveDiskSpace allocates size bytes to the file at path.
+var reserveDiskSpace = func(path string, size uint64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = syscall.Fallocate(int(f.Fd()), 0, 0, int64(size))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		// the filesystem cannot reserve space, the space was only
+		// checked
+		return os.Remove(path)
+	}
+	return err
+}
+
+func reservationFile(path string, key reservationKey) string {
+	return filepath.Join(path, fmt.Sprintf(".snapd-space-reservation-%s-%s", key.instanceName, key.kind))
+}
+
+// reserve allocates files holding the space of the plan, so that it is not
+// taken by something else before the change gets to use it. It returns the
+// filesystems where this failed for lack of space.
+func (p *diskSpacePlan) reserve(st *state.State) ([]SpaceShortfall, error) {
+	reservations, err := spaceReservations(st)
+	if err != nil {
+		return nil, err
+	}
+
+	var reserved []string
+	abort := func() {
+		for _, fn := range reserved {
+			if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+				logger.Noticef("cannot remove disk space reservation %q: %v", fn, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	newReservations := make(map[string]*spaceReservation)
+	for _, path := range p.paths {
+		keys := make([]reservationKey, 0, len(p.reservations[path]))
+		for key := range p.reservations[path] {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].instanceName != keys[j].instanceName {
+				return keys[i].instanceName < keys[j].instanceName
+			}
+			return keys[i].kind < keys[j].kind
+		})
+
+		for _, key := range keys {
+			size := p.reservations[path][key]
+			if size == 0 {
+				continue
+			}
+			if err := os.MkdirAll(path, 0755); err != nil {
+				abort()
+				return nil, err
+			}
+			fn := reservationFile(path, key)
+			if err := reserveDiskSpace(fn, size); err != nil {
+				os.Remove(fn)
+				abort()
+				if err == syscall.ENOSPC {
+					return []SpaceShortfall{{
+						Path:     path,
+						Required: safetyMarginDiskSpace(p.required[path]),
+						Missing:  size,
+					}}, nil
+				}
+				return nil, err
+			}
+			reserved = append(reserved, fn)
+
+			res := newReservations[key.instanceName]
+			if res == nil {
+				res = &spaceReservation{Files: make(map[string][]string), Time: now}
+				newReservations[key.instanceName] = res
+			}
+			res.Files[key.kind] = append(res.Files[key.kind], fn)
+		}
+	}
+
+	for name, res := range newReservations {
+		// a reservation left behind by an earlier attempt is replaced,
+		// the files at the same locations were reused already
+		if old := reservations[name]; old != nil {
+			reused := make(map[string]bool)
+			for _, files := range res.Files {
+				for _, fn := range files {
+					reused[fn] = true
+				}
+			}
+			for _, files := range old.Files {
+				for _, fn := range files {
+					if !reused[fn] {
+						os.Remove(fn)
+					}
+				}
+			}
+		}
+		reservations[name] = res
+	}
+	st.Set("space-reservations", reservations)
+	return nil, nil
+}
+
+// releaseSpaceReservation releases the space reserved for the given purpose
+// of the snap, either because it is about to be used or because it is not
+// needed anymore.
+func releaseSpaceReservation(st *state.State, instanceName, kind string) {
+	reservations, err := spaceReservations(st)
+	if err != nil {
+		logger.Noticef("cannot release disk space reserved for snap %q: %v", instanceName, err)
+		return
+	}
+	res := reservations[instanceName]
+	if res == nil {
+		return
+	}
+	for _, fn := range res.Files[kind] {
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			logger.Noticef("cannot remove disk space reservation %q: %v", fn, err)
+		}
+	}
+	delete(res.Files, kind)
+	if len(res.Files) == 0 {
+		delete(reservations, instanceName)
+	}
+	st.Set("space-reservations", reservations)
+}
+
+var spaceReservationCleanupWait = 10 * time.Minute
+
+// reservationTaskKinds are the kinds of the tasks that consume the space
+// reserved for each purpose.
+var reservationTaskKinds = map[string][]string{
+	downloadReservation: {"prefetch-snap", "download-snap"},
+	dataCopyReservation: {"copy-snap-data"},
+}
+
+// cleanupSpaceReservations releases the space reserved for changes that
+// were never created or will not consume it anymore, for example because
+// they were aborted.
+func (m *SnapManager) cleanupSpaceReservations() error {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	reservations, err := spaceReservations(st)
+	if err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return nil
+	}
+
+	// tasks yet to consume space, by kind and snap
+	pending := make(map[string]map[string]bool)
+	for _, chg := range st.Changes() {
+		if chg.IsReady() {
+			continue
+		}
+		for _, t := range chg.Tasks() {
+			if t.Status() != state.DoStatus {
+				continue
+			}
+			snapsup, err := TaskSnapSetup(t)
+			if err != nil {
+				continue
+			}
+			if pending[t.Kind()] == nil {
+				pending[t.Kind()] = make(map[string]bool)
+			}
+			pending[t.Kind()][snapsup.InstanceName()] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-spaceReservationCleanupWait)
+	for name, res := range reservations {
+		// leave time for the change to be created
+		if res.Time.After(cutoff) {
+			continue
+		}
+		for kind := range res.Files {
+			inUse := false
+			for _, taskKind := range reservationTaskKinds[kind] {
+				if pending[taskKind][name] {
+					inUse = true
+					break
+				}
+			}
+			if !inUse {
+				releaseSpaceReservation(st, name, kind)
+			}
+		}
+	}
+	return nil
+}
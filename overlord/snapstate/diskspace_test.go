@@ -0,0 +1,316 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type diskSpaceCheck struct {
+	path string
+	size uint64
+}
+
+func (s *snapmgrTestSuite) mockDiskSpace(c *C, free map[string]uint64) *[]diskSpaceCheck {
+	var checks []diskSpaceCheck
+	restore := snapstate.MockOsutilCheckFreeSpace(func(path string, sz uint64) error {
+		checks = append(checks, diskSpaceCheck{path, sz})
+		if sz > free[path] {
+			return &osutil.NotEnoughDiskSpaceError{Path: path, Delta: int64(sz - free[path])}
+		}
+		return nil
+	})
+	s.AddCleanup(restore)
+
+	// /var/snap is on a separate filesystem
+	restore = snapstate.MockFsDevice(func(path string) (uint64, error) {
+		if strings.HasPrefix(path, dirs.SnapDataDir) {
+			return 2, nil
+		}
+		return 1, nil
+	})
+	s.AddCleanup(restore)
+
+	restore = snapstate.MockInstallSize(func(st *state.State, snaps []snapstate.MinimalInstallInfo, userID int) (uint64, error) {
+		return 100, nil
+	})
+	s.AddCleanup(restore)
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "experimental.check-disk-space-install", true)
+	tr.Set("core", "experimental.check-disk-space-refresh", true)
+	tr.Commit()
+
+	return &checks
+}
+
+func (s *snapmgrTestSuite) TestCheckDiskSpaceRefreshAccountsDataCopy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	stateDir := dirs.SnapdStateDir(dirs.GlobalRootDir)
+	checks := s.mockDiskSpace(c, map[string]uint64{
+		stateDir:         1 << 30,
+		dirs.SnapDataDir: 1 << 30,
+	})
+
+	info := mockInstalledSnap(c, s.state, snapAyaml, false)
+	dataDir := snap.DataDir("snap-a", snap.R(1))
+	c.Assert(os.MkdirAll(filepath.Join(dataDir, "sub"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dataDir, "sub", "data"), make([]byte, 1000), 0644), IsNil)
+
+	err := snapstate.CheckDiskSpace(s.state, "refresh", []snapstate.MinimalInstallInfo{snapstate.InstallSnapInfo{Info: info}}, 0)
+	c.Assert(err, IsNil)
+	c.Check(*checks, DeepEquals, []diskSpaceCheck{
+		{stateDir, snapstate.SafetyMarginDiskSpace(100)},
+		{dirs.SnapDataDir, snapstate.SafetyMarginDiskSpace(1000)},
+	})
+
+	// data is not copied on install
+	*checks = nil
+	err = snapstate.CheckDiskSpace(s.state, "install", []snapstate.MinimalInstallInfo{snapstate.InstallSnapInfo{Info: info}}, 0)
+	c.Assert(err, IsNil)
+	c.Check(*checks, DeepEquals, []diskSpaceCheck{
+		{stateDir, snapstate.SafetyMarginDiskSpace(100)},
+	})
+}
+
+func (s *snapmgrTestSuite) TestCheckDiskSpaceReportsShortfallPerFilesystem(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	stateDir := dirs.SnapdStateDir(dirs.GlobalRootDir)
+	s.mockDiskSpace(c, map[string]uint64{
+		stateDir:         1 << 30,
+		dirs.SnapDataDir: 1000,
+	})
+
+	info := mockInstalledSnap(c, s.state, snapAyaml, false)
+	dataDir := snap.DataDir("snap-a", snap.R(1))
+	c.Assert(os.MkdirAll(dataDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dataDir, "data"), make([]byte, 1000), 0644), IsNil)
+
+	err := snapstate.CheckDiskSpace(s.state, "refresh", []snapstate.MinimalInstallInfo{snapstate.InstallSnapInfo{Info: info}}, 0)
+	c.Assert(err, ErrorMatches, `insufficient space to perform "refresh" change for the following snaps: snap-a \(at least 5MB more is required in ".*/var/snap"\)`)
+	diskSpaceErr, ok := err.(*snapstate.InsufficientSpaceError)
+	c.Assert(ok, Equals, true)
+	c.Check(diskSpaceErr.Path, Equals, dirs.SnapDataDir)
+	c.Check(diskSpaceErr.Shortfalls, DeepEquals, []snapstate.SpaceShortfall{{
+		Path:     dirs.SnapDataDir,
+		Required: snapstate.SafetyMarginDiskSpace(1000),
+		Missing:  snapstate.SafetyMarginDiskSpace(0),
+	}})
+}
+
+type diskSpaceReservation struct {
+	path string
+	size uint64
+}
+
+func (s *snapmgrTestSuite) mockReserveDiskSpace(c *C, reserveErr error) *[]diskSpaceReservation {
+	var reservations []diskSpaceReservation
+	restore := snapstate.MockReserveDiskSpace(func(path string, size uint64) error {
+		if reserveErr != nil {
+			return reserveErr
+		}
+		reservations = append(reservations, diskSpaceReservation{path, size})
+		return ioutil.WriteFile(path, nil, 0600)
+	})
+	s.AddCleanup(restore)
+	return &reservations
+}
+
+func (s *snapmgrTestSuite) TestCheckDiskSpaceReservesSpace(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	stateDir := dirs.SnapdStateDir(dirs.GlobalRootDir)
+	s.mockDiskSpace(c, map[string]uint64{
+		stateDir:         1 << 30,
+		dirs.SnapDataDir: 1 << 30,
+	})
+	reservations := s.mockReserveDiskSpace(c, nil)
+
+	info := mockInstalledSnap(c, s.state, snapAyaml, false)
+	dataDir := snap.DataDir("snap-a", snap.R(1))
+	c.Assert(os.MkdirAll(dataDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dataDir, "data"), make([]byte, 1000), 0644), IsNil)
+	other := &snap.Info{
+		SideInfo:     snap.SideInfo{RealName: "other-snap"},
+		DownloadInfo: snap.DownloadInfo{Size: 30},
+	}
+
+	err := snapstate.CheckDiskSpace(s.state, "refresh", []snapstate.MinimalInstallInfo{
+		snapstate.InstallSnapInfo{Info: info},
+		snapstate.InstallSnapInfo{Info: other},
+	}, 0)
+	c.Assert(err, IsNil)
+
+	// the space of the prerequisites is reserved with the first snap
+	downloadA := filepath.Join(stateDir, ".snapd-space-reservation-snap-a-download")
+	dataA := filepath.Join(dirs.SnapDataDir, ".snapd-space-reservation-snap-a-data-copy")
+	downloadOther := filepath.Join(stateDir, ".snapd-space-reservation-other-snap-download")
+	c.Check(*reservations, DeepEquals, []diskSpaceReservation{
+		{downloadOther, 30},
+		{downloadA, 70},
+		{dataA, 1000},
+	})
+	c.Check(downloadA, testutil.FilePresent)
+	c.Check(dataA, testutil.FilePresent)
+	c.Check(downloadOther, testutil.FilePresent)
+
+	var reserved map[string]struct {
+		Files map[string][]string `json:"files"`
+	}
+	c.Assert(s.state.Get("space-reservations", &reserved), IsNil)
+	c.Check(reserved, HasLen, 2)
+	c.Check(reserved["snap-a"].Files, DeepEquals, map[string][]string{
+		"download":  {downloadA},
+		"data-copy": {dataA},
+	})
+	c.Check(reserved["other-snap"].Files, DeepEquals, map[string][]string{
+		"download": {downloadOther},
+	})
+}
+
+func (s *snapmgrTestSuite) TestCheckDiskSpaceReservationFails(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	stateDir := dirs.SnapdStateDir(dirs.GlobalRootDir)
+	s.mockDiskSpace(c, map[string]uint64{
+		stateDir: 1 << 30,
+	})
+	// the space got taken after it was checked
+	s.mockReserveDiskSpace(c, syscall.ENOSPC)
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "some-snap"}}
+	err := snapstate.CheckDiskSpace(s.state, "install", []snapstate.MinimalInstallInfo{snapstate.InstallSnapInfo{Info: info}}, 0)
+	c.Assert(err, ErrorMatches, `insufficient space to perform "install" change for the following snaps: some-snap \(at least 100B more is required in ".*/var/lib/snapd"\)`)
+	c.Check(filepath.Join(stateDir, ".snapd-space-reservation-some-snap-download"), testutil.FileAbsent)
+
+	var reserved map[string]interface{}
+	c.Check(s.state.Get("space-reservations", &reserved), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *snapmgrTestSuite) TestCheckDiskSpaceSizesDataWithoutStateLock(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockDiskSpace(c, map[string]uint64{
+		dirs.SnapdStateDir(dirs.GlobalRootDir): 1 << 30,
+		dirs.SnapDataDir:                       1 << 30,
+	})
+	s.mockReserveDiskSpace(c, nil)
+
+	info := mockInstalledSnap(c, s.state, snapAyaml, false)
+	dataDir := snap.DataDir("snap-a", snap.R(1))
+	c.Assert(os.MkdirAll(dataDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dataDir, "data"), make([]byte, 1000), 0644), IsNil)
+
+	restore := snapstate.MockOsutilCheckFreeSpace(func(path string, sz uint64) error {
+		return nil
+	})
+	defer restore()
+	sized := false
+	restore = snapstate.MockDirSize(func(dir string) (uint64, error) {
+		// the state can be taken meanwhile
+		ok := make(chan bool)
+		go func() {
+			s.state.Lock()
+			s.state.Unlock()
+			close(ok)
+		}()
+		<-ok
+		sized = true
+		return 1000, nil
+	})
+	defer restore()
+
+	err := snapstate.CheckDiskSpace(s.state, "refresh", []snapstate.MinimalInstallInfo{snapstate.InstallSnapInfo{Info: info}}, 0)
+	c.Assert(err, IsNil)
+	c.Check(sized, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestCleanupSpaceReservations(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	stateDir := dirs.SnapdStateDir(dirs.GlobalRootDir)
+	s.mockDiskSpace(c, map[string]uint64{
+		stateDir: 1 << 30,
+	})
+	s.mockReserveDiskSpace(c, nil)
+
+	var infos []snapstate.MinimalInstallInfo
+	for _, name := range []string{"pending-snap", "gone-snap"} {
+		infos = append(infos, snapstate.InstallSnapInfo{Info: &snap.Info{
+			SideInfo:     snap.SideInfo{RealName: name},
+			DownloadInfo: snap.DownloadInfo{Size: 50},
+		}})
+	}
+	c.Assert(snapstate.CheckDiskSpace(s.state, "install", infos, 0), IsNil)
+	pending := filepath.Join(stateDir, ".snapd-space-reservation-pending-snap-download")
+	gone := filepath.Join(stateDir, ".snapd-space-reservation-gone-snap-download")
+
+	// a change is yet to download one of the snaps
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("download-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "pending-snap", Revision: snap.R(2)},
+	})
+	chg.AddTask(t)
+
+	// recent reservations are kept while their change is created
+	s.state.Unlock()
+	err := snapstate.CleanupSpaceReservations(s.snapmgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(pending, testutil.FilePresent)
+	c.Check(gone, testutil.FilePresent)
+
+	restore := snapstate.MockSpaceReservationCleanupWait(0)
+	defer restore()
+	s.state.Unlock()
+	err = snapstate.CleanupSpaceReservations(s.snapmgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(pending, testutil.FilePresent)
+	c.Check(gone, testutil.FileAbsent)
+
+	var reserved map[string]interface{}
+	c.Assert(s.state.Get("space-reservations", &reserved), IsNil)
+	c.Check(reserved, HasLen, 1)
+	c.Check(reserved["pending-snap"], NotNil)
+}
@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/store"
+)
+
+// EpochMigrationStep is a revision installed while migrating a snap
+// across epochs.
+type EpochMigrationStep struct {
+	Revision snap.Revision `json:"revision"`
+	Version  string        `json:"version"`
+	Epoch    snap.Epoch    `json:"epoch"`
+	// Channel is the channel the revision was found in.
+	Channel string `json:"channel"`
+}
+
+// EpochMigration explains whether the revision in a channel can be
+// reached from the installed revision of a snap, given their epochs.
+type EpochMigration struct {
+	Snap            string             `json:"snap"`
+	Channel         string             `json:"channel"`
+	CurrentRevision snap.Revision      `json:"current-revision"`
+	CurrentEpoch    snap.Epoch         `json:"current-epoch"`
+	Target          EpochMigrationStep `json:"target"`
+	// Reachable is set when the target revision can be reached,
+	// directly or through intermediate revisions.
+	Reachable bool `json:"reachable"`
+	// Steps are the revisions to install in order, ending with the
+	// target one. A direct refresh has a single step.
+	Steps []EpochMigrationStep `json:"steps,omitempty"`
+}
+
+func epochMigrationStep(ch *snap.ChannelSnapInfo) EpochMigrationStep {
+	return EpochMigrationStep{
+		Revision: ch.Revision,
+		Version:  ch.Version,
+		Epoch:    ch.Epoch,
+		Channel:  ch.Channel,
+	}
+}
+
+// planEpochMigration finds the shortest sequence of candidate revisions,
+// ending with target, such that each revision can read the data written
+// by the previous one, starting from the current epoch. Newer revisions
+// are preferred as intermediate steps. It returns nil if there is no such
+// sequence.
+func planEpochMigration(current snap.Epoch, currentRev snap.Revision, candidates []*snap.ChannelSnapInfo, target *snap.ChannelSnapInfo) []*snap.ChannelSnapInfo {
+	if target.Epoch.CanRead(current) {
+		return []*snap.ChannelSnapInfo{target}
+	}
+
+	// the channel map lists the same revision once per channel
+	seen := map[snap.Revision]bool{
+		currentRev:      true,
+		target.Revision: true,
+	}
+	var intermediates []*snap.ChannelSnapInfo
+	for _, cand := range candidates {
+		if seen[cand.Revision] {
+			continue
+		}
+		seen[cand.Revision] = true
+		intermediates = append(intermediates, cand)
+	}
+	sort.Slice(intermediates, func(i, j int) bool {
+		return intermediates[j].Revision.N < intermediates[i].Revision.N
+	})
+
+	// breadth-first search over the intermediate revisions
+	type node struct {
+		info *snap.ChannelSnapInfo
+		prev *node
+	}
+	visited := make(map[snap.Revision]bool, len(intermediates))
+	var queue []*node
+	for _, cand := range intermediates {
+		if cand.Epoch.CanRead(current) {
+			visited[cand.Revision] = true
+			queue = append(queue, &node{info: cand})
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if target.Epoch.CanRead(n.info.Epoch) {
+			path := []*snap.ChannelSnapInfo{target}
+			for ; n != nil; n = n.prev {
+				path = append([]*snap.ChannelSnapInfo{n.info}, path...)
+			}
+			return path
+		}
+		for _, cand := range intermediates {
+			if visited[cand.Revision] || !cand.Epoch.CanRead(n.info.Epoch) {
+				continue
+			}
+			visited[cand.Revision] = true
+			queue = append(queue, &node{info: cand, prev: n})
+		}
+	}
+	return nil
+}
+
+// ExplainEpochMigration explains whether the revision of the given snap
+// in the given channel, or in the tracked one if empty, can be reached
+// from the installed revision given their epochs, and which intermediate
+// revisions from the store need to be installed first if so.
+// Note that the state must be locked by the caller, it is unlocked while
+// talking to the store.
+func ExplainEpochMigration(ctx context.Context, st *state.State, name, channelName string, userID int) (*EpochMigration, error) {
+	var snapst SnapState
+	if err := Get(st, name, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if !snapst.IsInstalled() {
+		return nil, &snap.NotInstalledError{Snap: name}
+	}
+	curInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	if channelName == "" {
+		channelName = snapst.TrackingChannel
+	}
+	fullChannel, err := channel.Full(channelName)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := userFromUserID(st, userID)
+	if err != nil {
+		return nil, err
+	}
+	theStore := Store(st, nil)
+	st.Unlock()
+	info, err := theStore.SnapInfo(ctx, store.SnapSpec{Name: curInfo.SnapName()}, user)
+	st.Lock()
+	if err != nil {
+		return nil, err
+	}
+
+	target := info.Channels[fullChannel]
+	if target == nil {
+		return nil, fmt.Errorf("snap %q has no revision in channel %q", name, channelName)
+	}
+
+	candidates := make([]*snap.ChannelSnapInfo, 0, len(info.Channels))
+	for _, ch := range info.Channels {
+		candidates = append(candidates, ch)
+	}
+
+	mig := &EpochMigration{
+		Snap:            name,
+		Channel:         fullChannel,
+		CurrentRevision: curInfo.Revision,
+		CurrentEpoch:    curInfo.Epoch,
+		Target:          epochMigrationStep(target),
+	}
+	for _, step := range planEpochMigration(curInfo.Epoch, curInfo.Revision, candidates, target) {
+		mig.Steps = append(mig.Steps, epochMigrationStep(step))
+	}
+	mig.Reachable = len(mig.Steps) > 0
+	return mig, nil
+}
+
+// UpdateEpochMigration initiates the refresh of a snap through all the
+// steps of the given epoch migration, each one waiting for the previous
+// one. The tracked channel is switched to the one of the migration.
+// Note that the state must be locked by the caller.
+func UpdateEpochMigration(st *state.State, mig *EpochMigration, userID int, flags Flags) ([]*state.TaskSet, error) {
+	if !mig.Reachable {
+		return nil, fmt.Errorf("cannot refresh %q to revision %s: no epoch migration path from epoch %s to epoch %s",
+			mig.Snap, mig.Target.Revision, mig.CurrentEpoch, mig.Target.Epoch)
+	}
+	// the steps are explicit, re-refreshing in between would race them
+	flags.NoReRefresh = true
+
+	var tss []*state.TaskSet
+	for _, step := range mig.Steps {
+		opts := &RevisionOptions{
+			Channel:  mig.Channel,
+			Revision: step.Revision,
+		}
+		ts, err := UpdateWithDeviceContext(st, mig.Snap, opts, userID, flags, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(tss) > 0 {
+			ts.WaitAll(tss[len(tss)-1])
+		}
+		tss = append(tss, ts)
+	}
+	return tss, nil
+}
@@ -0,0 +1,193 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// epochsStore adds a channel map to the snap info of the fake store
+type epochsStore struct {
+	*fakeStore
+	channels map[string]*snap.ChannelSnapInfo
+}
+
+func (s epochsStore) SnapInfo(ctx context.Context, spec store.SnapSpec, user *auth.UserState) (*snap.Info, error) {
+	info, err := s.fakeStore.SnapInfo(ctx, spec, user)
+	if err != nil {
+		return nil, err
+	}
+	info.Channels = s.channels
+	return info, nil
+}
+
+func (s *snapmgrTestSuite) mockEpochsSnap(c *C, channels map[string]*snap.ChannelSnapInfo) {
+	snapstate.ReplaceStore(s.state, epochsStore{fakeStore: s.fakeStore, channels: channels})
+
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}
+	snaptest.MockSnap(c, "name: some-snap\nversion: 1.0\nepoch: 1", si)
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:          true,
+		TrackingChannel: "latest/stable",
+		Sequence:        []*snap.SideInfo{si},
+		Current:         si.Revision,
+		SnapType:        "app",
+	})
+}
+
+func epochsChannel(name string, rev int, epoch string) *snap.ChannelSnapInfo {
+	return &snap.ChannelSnapInfo{
+		Revision: snap.R(rev),
+		Version:  "v" + snap.R(rev).String(),
+		Epoch:    snap.E(epoch),
+		Channel:  name,
+	}
+}
+
+func (s *snapmgrTestSuite) TestExplainEpochMigrationDirect(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockEpochsSnap(c, map[string]*snap.ChannelSnapInfo{
+		"latest/stable": epochsChannel("latest/stable", 11, "2*"),
+	})
+
+	mig, err := snapstate.ExplainEpochMigration(context.Background(), s.state, "some-snap", "", 0)
+	c.Assert(err, IsNil)
+	c.Check(mig, DeepEquals, &snapstate.EpochMigration{
+		Snap:            "some-snap",
+		Channel:         "latest/stable",
+		CurrentRevision: snap.R(7),
+		CurrentEpoch:    snap.E("1*"),
+		Target: snapstate.EpochMigrationStep{
+			Revision: snap.R(11),
+			Version:  "v11",
+			Epoch:    snap.E("2*"),
+			Channel:  "latest/stable",
+		},
+		Reachable: true,
+		Steps: []snapstate.EpochMigrationStep{{
+			Revision: snap.R(11),
+			Version:  "v11",
+			Epoch:    snap.E("2*"),
+			Channel:  "latest/stable",
+		}},
+	})
+}
+
+func (s *snapmgrTestSuite) TestExplainEpochMigrationMultiStep(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockEpochsSnap(c, map[string]*snap.ChannelSnapInfo{
+		"latest/stable":    epochsChannel("latest/stable", 7, "1"),
+		"latest/candidate": epochsChannel("latest/candidate", 9, "2*"),
+		"latest/beta":      epochsChannel("latest/beta", 10, "2*"),
+		"2/stable":         epochsChannel("2/stable", 11, "3*"),
+		"3/stable":         epochsChannel("3/stable", 12, "4*"),
+	})
+
+	mig, err := snapstate.ExplainEpochMigration(context.Background(), s.state, "some-snap", "3", 0)
+	c.Assert(err, IsNil)
+	c.Check(mig.Channel, Equals, "3/stable")
+	c.Check(mig.Target.Revision, Equals, snap.R(12))
+	c.Check(mig.Reachable, Equals, true)
+	var revs []snap.Revision
+	for _, step := range mig.Steps {
+		revs = append(revs, step.Revision)
+	}
+	// the newest revision is preferred among equivalent ones
+	c.Check(revs, DeepEquals, []snap.Revision{snap.R(10), snap.R(11), snap.R(12)})
+}
+
+func (s *snapmgrTestSuite) TestExplainEpochMigrationUnreachable(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockEpochsSnap(c, map[string]*snap.ChannelSnapInfo{
+		"latest/stable": epochsChannel("latest/stable", 7, "1"),
+		"latest/edge":   epochsChannel("latest/edge", 11, "5"),
+	})
+
+	mig, err := snapstate.ExplainEpochMigration(context.Background(), s.state, "some-snap", "edge", 0)
+	c.Assert(err, IsNil)
+	c.Check(mig.Target.Revision, Equals, snap.R(11))
+	c.Check(mig.Reachable, Equals, false)
+	c.Check(mig.Steps, HasLen, 0)
+
+	_, err = snapstate.UpdateEpochMigration(s.state, mig, 0, snapstate.Flags{})
+	c.Check(err, ErrorMatches, `cannot refresh "some-snap" to revision 11: no epoch migration path from epoch 1\* to epoch 5`)
+}
+
+func (s *snapmgrTestSuite) TestExplainEpochMigrationErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.ExplainEpochMigration(context.Background(), s.state, "some-snap", "", 0)
+	c.Check(err, ErrorMatches, `snap "some-snap" is not installed`)
+
+	s.mockEpochsSnap(c, map[string]*snap.ChannelSnapInfo{
+		"latest/stable": epochsChannel("latest/stable", 7, "1"),
+	})
+	_, err = snapstate.ExplainEpochMigration(context.Background(), s.state, "some-snap", "edge", 0)
+	c.Check(err, ErrorMatches, `snap "some-snap" has no revision in channel "edge"`)
+}
+
+func (s *snapmgrTestSuite) TestUpdateEpochMigration(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockEpochsSnap(c, map[string]*snap.ChannelSnapInfo{
+		"latest/beta": epochsChannel("latest/beta", 10, "2*"),
+		"2/stable":    epochsChannel("2/stable", 11, "3*"),
+	})
+
+	mig, err := snapstate.ExplainEpochMigration(context.Background(), s.state, "some-snap", "2/stable", 0)
+	c.Assert(err, IsNil)
+	c.Assert(mig.Steps, HasLen, 2)
+
+	tss, err := snapstate.UpdateEpochMigration(s.state, mig, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Assert(tss, HasLen, 2)
+
+	for i, ts := range tss {
+		var snapsup snapstate.SnapSetup
+		c.Assert(ts.Tasks()[0].Get("snap-setup", &snapsup), IsNil)
+		c.Check(snapsup.Revision(), Equals, mig.Steps[i].Revision)
+		c.Check(snapsup.Channel, Equals, "2/stable")
+		// no re-refresh in between the steps
+		for _, t := range ts.Tasks() {
+			c.Check(t.Kind(), Not(Equals), "check-rerefresh")
+		}
+	}
+	// the second step waits for the first one
+	for _, t := range tss[0].Tasks() {
+		c.Check(tss[1].Tasks()[0].WaitTasks(), testutil.Contains, t)
+	}
+}
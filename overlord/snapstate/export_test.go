@@ -21,6 +21,7 @@ package snapstate
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
@@ -63,6 +64,12 @@ func MockSnapReadInfo(mock func(name string, si *snap.SideInfo) (*snap.Info, err
 	return func() { snapReadInfo = old }
 }
 
+func MockCgroupWithFrozenSnapProcesses(f func(snapName string, timeout time.Duration, f func() error) error) (restore func()) {
+	old := cgroupWithFrozenSnapProcesses
+	cgroupWithFrozenSnapProcesses = f
+	return func() { cgroupWithFrozenSnapProcesses = old }
+}
+
 func MockMountPollInterval(intv time.Duration) (restore func()) {
 	old := mountPollInterval
 	mountPollInterval = intv
@@ -127,6 +134,8 @@ var (
 	GetDirMigrationOpts = getDirMigrationOpts
 	WriteSeqFile        = writeSeqFile
 	TriggeredMigration  = triggeredMigration
+
+	WarmCacheLookup = warmCacheLookup
 )
 
 func PreviousSideInfo(snapst *SnapState) *snap.SideInfo {
@@ -162,6 +171,7 @@ var (
 	NewAutoRefresh                = newAutoRefresh
 	NewRefreshHints               = newRefreshHints
 	CanRefreshOnMeteredConnection = canRefreshOnMeteredConnection
+	DeferLargeRefreshesOnMetered  = deferLargeRefreshesOnMetered
 
 	NewCatalogRefresh            = newCatalogRefresh
 	CatalogRefreshDelayBase      = catalogRefreshDelayBase
@@ -186,6 +196,12 @@ func MockCatalogRefreshNextRefresh(cr *catalogRefresh, when time.Time) {
 	cr.nextCatalogRefresh = when
 }
 
+func MockCatalogRefreshStartupDelay(d time.Duration) (restore func()) {
+	restore = testutil.Backup(&catalogRefreshStartupDelay)
+	catalogRefreshStartupDelay = d
+	return restore
+}
+
 func NextCatalogRefresh(cr *catalogRefresh) time.Time {
 	return cr.nextCatalogRefresh
 }
@@ -303,6 +319,36 @@ func MockInstallSize(f func(st *state.State, snaps []minimalInstallInfo, userID
 	}
 }
 
+var CheckDiskSpace = checkDiskSpace
+
+func MockFsDevice(f func(path string) (uint64, error)) (restore func()) {
+	old := fsDevice
+	fsDevice = f
+	return func() {
+		fsDevice = old
+	}
+}
+
+func MockReserveDiskSpace(f func(path string, size uint64) error) (restore func()) {
+	restore = testutil.Backup(&reserveDiskSpace)
+	reserveDiskSpace = f
+	return restore
+}
+
+func MockDirSize(f func(dir string) (uint64, error)) (restore func()) {
+	restore = testutil.Backup(&dirSize)
+	dirSize = f
+	return restore
+}
+
+var CleanupSpaceReservations = (*SnapManager).cleanupSpaceReservations
+
+func MockSpaceReservationCleanupWait(d time.Duration) (restore func()) {
+	restore = testutil.Backup(&spaceReservationCleanupWait)
+	spaceReservationCleanupWait = d
+	return restore
+}
+
 func MockGenerateSnapdWrappers(f func(snapInfo *snap.Info, opts *backend.GenerateSnapdWrappersOptions) error) func() {
 	old := generateSnapdWrappers
 	generateSnapdWrappers = f
@@ -450,6 +496,21 @@ func MockEnforcedValidationSets(f func(st *state.State, extraVss ...*asserts.Val
 	}
 }
 
+func MockDeriveSideInfo(f func(st *state.State, snapPath string, deviceCtx DeviceContext) (*snap.SideInfo, error)) func() {
+	old := DeriveSideInfo
+	DeriveSideInfo = f
+	return func() {
+		DeriveSideInfo = old
+	}
+}
+
+func MockWarmCacheMaxAge(d time.Duration) (restore func()) {
+	restore = testutil.Backup(&warmCacheMaxAge, &warmCacheLastPruneCheck)
+	warmCacheMaxAge = d
+	warmCacheLastPruneCheck = time.Time{}
+	return restore
+}
+
 func MockEnforceValidationSets(f func(*state.State, map[string]*asserts.ValidationSet, map[string]int, []*snapasserts.InstalledSnap, map[string]bool, int) error) func() {
 	old := EnforceValidationSets
 	EnforceValidationSets = f
@@ -457,3 +518,41 @@ func MockEnforceValidationSets(f func(*state.State, map[string]*asserts.Validati
 		EnforceValidationSets = old
 	}
 }
+
+type RefreshHealthWatch = refreshHealthWatch
+
+func (m *SnapManager) EnsureRefreshHealth() error {
+	return m.ensureRefreshHealth()
+}
+
+func MockVerifyRefreshHealth(f func(st *state.State, instanceName string, rev snap.Revision) error) (restore func()) {
+	old := VerifyRefreshHealth
+	VerifyRefreshHealth = f
+	return func() {
+		VerifyRefreshHealth = old
+	}
+}
+
+var ClassifyTaskError = classifyTaskError
+
+func (m *SnapManager) EnsureTryWatches() error {
+	return m.ensureTryWatches()
+}
+
+func MockNewDirWatcher(f func(path string, changed func()) (io.Closer, error)) (restore func()) {
+	old := newDirWatcher
+	newDirWatcher = func(path string, changed func()) (dirWatcher, error) {
+		return f(path, changed)
+	}
+	return func() {
+		newDirWatcher = old
+	}
+}
+
+func MockTryWatchSettleDelay(d time.Duration) (restore func()) {
+	old := tryWatchSettleDelay
+	tryWatchSettleDelay = d
+	return func() {
+		tryWatchSettleDelay = old
+	}
+}
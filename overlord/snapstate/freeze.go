@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var cgroupWithFrozenSnapProcesses = cgroup.WithFrozenSnapProcesses
+
+// freezeOptions returns the time freezing the processes of a snap may take
+// and whether freezing is disabled, as set with the freeze.timeout and
+// freeze.disabled system options.
+func freezeOptions(st *state.State) (timeout time.Duration, disabled bool, err error) {
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "freeze.disabled", &disabled); err != nil && !config.IsNoOption(err) {
+		return 0, false, err
+	}
+
+	timeout = cgroup.DefaultFreezeTimeout
+	var timeoutStr string
+	if err := tr.Get("core", "freeze.timeout", &timeoutStr); err != nil && !config.IsNoOption(err) {
+		return 0, false, err
+	}
+	if timeoutStr != "" {
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil {
+			return 0, false, fmt.Errorf("cannot parse freeze.timeout: %v", err)
+		}
+	}
+	return timeout, disabled, nil
+}
+
+// frozenSnaps returns the snaps whose processes may have been left frozen,
+// as recorded in the state.
+func frozenSnaps(st *state.State) ([]string, error) {
+	var frozen []string
+	if err := st.Get("frozen-snaps", &frozen); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	return frozen, nil
+}
+
+// recordFrozenSnap records in the state that the processes of the snap are
+// about to be frozen, or that they were thawed, such that they can be
+// thawed if snapd is restarted in between.
+func recordFrozenSnap(st *state.State, instanceName string, frozen bool) error {
+	st.Lock()
+	defer st.Unlock()
+
+	snaps, err := frozenSnaps(st)
+	if err != nil {
+		return err
+	}
+	listed := strutil.ListContains(snaps, instanceName)
+	switch {
+	case frozen && !listed:
+		snaps = append(snaps, instanceName)
+	case !frozen && listed:
+		kept := snaps[:0]
+		for _, name := range snaps {
+			if name != instanceName {
+				kept = append(kept, name)
+			}
+		}
+		snaps = kept
+	default:
+		return nil
+	}
+	if len(snaps) == 0 {
+		st.Set("frozen-snaps", nil)
+	} else {
+		st.Set("frozen-snaps", snaps)
+	}
+	return nil
+}
+
+// thawFrozenSnaps thaws the processes of the snaps that were left frozen
+// by a copy or snapshot of their data interrupted by a restart of snapd.
+func thawFrozenSnaps(st *state.State) error {
+	snaps, err := frozenSnaps(st)
+	if err != nil || len(snaps) == 0 {
+		return err
+	}
+	for _, instanceName := range snaps {
+		logger.Noticef("thawing processes of snap %q left frozen", instanceName)
+		if err := cgroup.ThawSnapProcesses(instanceName); err != nil {
+			// not much else that can be done
+			logger.Noticef("cannot thaw processes of snap %q: %v", instanceName, err)
+		}
+	}
+	st.Set("frozen-snaps", nil)
+	return nil
+}
+
+// WithFrozenSnapProcesses runs f, which copies or serializes the data of the
+// given snap, while the processes of the snap are frozen such that the data
+// is consistent. Should the processes not freeze in time, f is run with them
+// running as before. The state must not be locked.
+func WithFrozenSnapProcesses(st *state.State, instanceName string, f func() error) error {
+	st.Lock()
+	timeout, disabled, err := freezeOptions(st)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	if disabled {
+		return f()
+	}
+
+	if err := recordFrozenSnap(st, instanceName, true); err != nil {
+		return err
+	}
+	ran := false
+	err = cgroupWithFrozenSnapProcesses(instanceName, timeout, func() error {
+		ran = true
+		return f()
+	})
+	if err := recordFrozenSnap(st, instanceName, false); err != nil {
+		logger.Noticef("cannot record that processes of snap %q were thawed: %v", instanceName, err)
+	}
+	if err != nil && !ran {
+		logger.Noticef("cannot freeze processes of snap %q, continuing without: %v", instanceName, err)
+		return f()
+	}
+	return err
+}
@@ -468,6 +468,39 @@ func (m *SnapManager) installPrereqs(t *state.Task, base string, prereq map[stri
 		tss = append(tss, ts)
 	}
 
+	// default providers can have default providers of their own, install
+	// the whole closure together instead of discovering it one provider
+	// at a time as their prerequisites tasks run
+	seen := make(map[string]bool, len(prereq))
+	for prereqName := range prereq {
+		seen[prereqName] = true
+	}
+	for i := 0; i < len(tss); i++ {
+		nested, err := taskSetPrereqs(tss[i])
+		if err != nil {
+			return err
+		}
+		for prereqName, contentAttrs := range nested {
+			if seen[prereqName] {
+				continue
+			}
+			seen[prereqName] = true
+
+			var ts *state.TaskSet
+			timings.Run(tm, "install-prereq", fmt.Sprintf("install %q", prereqName), func(timings.Measurer) {
+				noTypeBaseCheck := false
+				ts, err = m.installOneBaseOrRequired(t, prereqName, contentAttrs, noTypeBaseCheck, defaultPrereqSnapsChannel(), nil, userID, flags)
+			})
+			if err != nil {
+				return prereqError("prerequisite", prereqName, err)
+			}
+			if ts == nil {
+				continue
+			}
+			tss = append(tss, ts)
+		}
+	}
+
 	// for base snaps we need to wait until the change is done
 	// (either finished or failed)
 	onInFlightErr := &state.Retry{After: prerequisitesRetryTimeout}
@@ -528,18 +561,46 @@ func (m *SnapManager) installPrereqs(t *state.Task, base string, prereq map[stri
 		joinLane(ts)
 		chg.AddAll(ts)
 	}
-	// add the base if needed, prereqs else must wait on this
 	if tsBase != nil {
 		joinLane(tsBase)
+	}
+	if tsSnapd != nil {
+		joinLane(tsSnapd)
+	}
+
+	// everything else waits for the base and snapd to be installed,
+	// download the other snaps in the meantime
+	if tsBase != nil || tsSnapd != nil {
+		downloads := downloadTasks(t.HaltTasks())
+		for _, ts := range tss {
+			downloads = append(downloads, downloadTasks(ts.Tasks())...)
+		}
+		if tsBase != nil && tsSnapd != nil {
+			downloads = append(downloads, downloadTasks(tsBase.Tasks())...)
+		}
+		for _, download := range downloads {
+			if err := addPrefetchTask(chg, download); err != nil {
+				return err
+			}
+		}
+	}
+
+	// add the base if needed, prereqs else must wait on this
+	if tsBase != nil {
 		for _, t := range chg.Tasks() {
+			if t.Kind() == "prefetch-snap" {
+				continue
+			}
 			t.WaitAll(tsBase)
 		}
 		chg.AddAll(tsBase)
 	}
 	// add snapd if needed, everything must wait on this
 	if tsSnapd != nil {
-		joinLane(tsSnapd)
 		for _, t := range chg.Tasks() {
+			if t.Kind() == "prefetch-snap" {
+				continue
+			}
 			t.WaitAll(tsSnapd)
 		}
 		chg.AddAll(tsSnapd)
@@ -552,6 +613,33 @@ func (m *SnapManager) installPrereqs(t *state.Task, base string, prereq map[stri
 	return nil
 }
 
+// taskSetPrereqs returns the default providers, along with the content
+// they should provide, of the snap installed by the given task set.
+func taskSetPrereqs(ts *state.TaskSet) (map[string][]string, error) {
+	for _, t := range ts.Tasks() {
+		if t.Kind() != "prerequisites" {
+			continue
+		}
+		snapsup, err := TaskSnapSetup(t)
+		if err != nil {
+			return nil, err
+		}
+		return snapsup.PrereqContentAttrs, nil
+	}
+	return nil, nil
+}
+
+// downloadTasks returns the download-snap tasks among the given ones.
+func downloadTasks(tasks []*state.Task) []*state.Task {
+	var downloads []*state.Task
+	for _, t := range tasks {
+		if t.Kind() == "download-snap" {
+			downloads = append(downloads, t)
+		}
+	}
+	return downloads
+}
+
 func prereqError(what, snapName string, err error) error {
 	if _, ok := err.(*state.Retry); ok {
 		return err
@@ -693,6 +781,18 @@ func autoRefreshRateLimited(st *state.State) (rate int64) {
 	return val
 }
 
+// downloadDirectIO returns whether snaps should be downloaded using direct
+// I/O.
+func downloadDirectIO(st *state.State) bool {
+	tr := config.NewTransaction(st)
+
+	var directIO bool
+	if err := tr.GetMaybe("core", "download.direct-io", &directIO); err != nil {
+		return false
+	}
+	return directIO
+}
+
 func downloadSnapParams(st *state.State, t *state.Task) (*SnapSetup, StoreService, *auth.UserState, error) {
 	snapsup, err := TaskSnapSetup(t)
 	if err != nil {
@@ -725,6 +825,12 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 		// NOTE rate is never negative
 		rate = autoRefreshRateLimited(st)
 	}
+	directIO := downloadDirectIO(st)
+	prefetched, prefetchVerified := snapPrefetched(t)
+	if err == nil {
+		// the download reserves the space it needs itself
+		releaseSpaceReservation(st, snapsup.InstanceName(), downloadReservation)
+	}
 	st.Unlock()
 	if err != nil {
 		return err
@@ -736,8 +842,15 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	dlOpts := &store.DownloadOptions{
 		IsAutoRefresh: snapsup.IsAutoRefresh,
 		RateLimit:     rate,
-	}
-	if snapsup.DownloadInfo == nil {
+		DirectIO:      directIO,
+	}
+	var downloadInfo *snap.DownloadInfo
+	if prefetched {
+		// the blob was downloaded already while the prerequisites of
+		// the snap were being installed
+		downloadInfo = snapsup.DownloadInfo
+		dlOpts.HashVerified = prefetchVerified
+	} else if snapsup.DownloadInfo == nil {
 		var storeInfo store.SnapActionResult
 		// COMPATIBILITY - this task was created from an older version
 		// of snapd that did not store the DownloadInfo in the state
@@ -750,16 +863,30 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 			err = theStore.Download(tomb.Context(nil), snapsup.SnapName(), targetFn, &storeInfo.DownloadInfo, meter, user, dlOpts)
 		})
 		snapsup.SideInfo = &storeInfo.SideInfo
+		downloadInfo = &storeInfo.DownloadInfo
 	} else {
 		timings.Run(perfTimings, "download", fmt.Sprintf("download snap %q", snapsup.SnapName()), func(timings.Measurer) {
 			err = theStore.Download(tomb.Context(nil), snapsup.SnapName(), targetFn, snapsup.DownloadInfo, meter, user, dlOpts)
 		})
+		downloadInfo = snapsup.DownloadInfo
 	}
 	if err != nil {
+		if stopErr := state.CheckpointBeforeWork(tomb); stopErr != nil {
+			// the download was interrupted on purpose, there
+			// is nothing to undo, a partial download is kept
+			// to be resumed
+			return stopErr
+		}
 		return err
 	}
 
 	snapsup.SnapPath = targetFn
+	if dlOpts.HashVerified {
+		// the store verified the digest of the blob while
+		// downloading it, spare reading it once more when checking
+		// the assertions
+		snapsup.VerifiedSha3_384 = downloadInfo.Sha3_384
+	}
 
 	// update the snap setup for the follow up tasks
 	st.Lock()
@@ -770,6 +897,119 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	return nil
 }
 
+// snapPrefetched returns whether the blob of the snap of the given
+// download-snap task was fetched already by a prefetch-snap task, and
+// whether the store verified its digest while doing so.
+func snapPrefetched(t *state.Task) (prefetched, verified bool) {
+	for _, wt := range t.WaitTasks() {
+		if wt.Kind() != "prefetch-snap" || wt.Status() != state.DoneStatus {
+			continue
+		}
+		if err := wt.Get("prefetched", &prefetched); err != nil && !errors.Is(err, state.ErrNoState) {
+			return false, false
+		}
+		if err := wt.Get("verified", &verified); err != nil && !errors.Is(err, state.ErrNoState) {
+			return prefetched, false
+		}
+		return prefetched, verified
+	}
+	return false, false
+}
+
+// addPrefetchTask adds a prefetch-snap task for the given download-snap
+// task to the change, such that the snap is downloaded while the tasks the
+// download waits for, like the installation of the base, are still running.
+func addPrefetchTask(chg *state.Change, download *state.Task) error {
+	snapsup, err := TaskSnapSetup(download)
+	if err != nil {
+		return err
+	}
+	if snapsup.SnapPath != "" || snapsup.DownloadInfo == nil {
+		// nothing to download
+		return nil
+	}
+
+	st := download.State()
+	prefetch := st.NewTask("prefetch-snap", fmt.Sprintf(i18n.G("Prefetch snap %q (%s)"), snapsup.InstanceName(), snapsup.Revision()))
+	prefetch.Set("snap-setup-task", download.ID())
+	for _, lane := range download.Lanes() {
+		prefetch.JoinLane(lane)
+	}
+	download.WaitFor(prefetch)
+	chg.AddTask(prefetch)
+	return nil
+}
+
+func (m *SnapManager) doPrefetchSnap(t *state.Task, tomb *tomb.Tomb) error {
+	st := t.State()
+
+	st.Lock()
+	perfTimings := state.TimingsForTask(t)
+	snapsup, theStore, user, err := downloadSnapParams(st, t)
+	directIO := downloadDirectIO(st)
+	if err == nil {
+		// the download reserves the space it needs itself
+		releaseSpaceReservation(st, snapsup.InstanceName(), downloadReservation)
+	}
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	meter := NewTaskProgressAdapterUnlocked(t)
+	dlOpts := &store.DownloadOptions{
+		DirectIO: directIO,
+	}
+	timings.Run(perfTimings, "download", fmt.Sprintf("prefetch snap %q", snapsup.SnapName()), func(timings.Measurer) {
+		err = theStore.Download(tomb.Context(nil), snapsup.SnapName(), snapsup.MountFile(), snapsup.DownloadInfo, meter, user, dlOpts)
+	})
+
+	st.Lock()
+	defer st.Unlock()
+	if err != nil {
+		if stopErr := state.CheckpointBeforeWork(tomb); stopErr != nil {
+			return stopErr
+		}
+		// the download-snap task will try again, there is no need
+		// to fail the change from here
+		t.Logf("cannot prefetch snap %q: %v", snapsup.InstanceName(), err)
+		return nil
+	}
+	t.Set("prefetched", true)
+	if dlOpts.HashVerified {
+		t.Set("verified", true)
+	}
+	perfTimings.Save(st)
+
+	return nil
+}
+
+func (m *SnapManager) undoPrefetchSnap(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var prefetched bool
+	if err := t.Get("prefetched", &prefetched); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if !prefetched {
+		return nil
+	}
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	if snapst.LastIndex(snapsup.Revision()) >= 0 {
+		// the revision is installed, the blob is in use
+		return nil
+	}
+	if err := os.Remove(snapsup.MountFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 var (
 	mountPollInterval = 1 * time.Second
 )
@@ -815,7 +1055,7 @@ func checkKernelHasUpdateAssetsTask(t *state.Task) error {
 	return ErrKernelGadgetUpdateTaskMissing
 }
 
-func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
+func (m *SnapManager) doMountSnap(t *state.Task, tomb *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
 	perfTimings := state.TimingsForTask(t)
@@ -874,6 +1114,12 @@ func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	}
 
+	// checking the snap can take a while, do not go on with mounting it
+	// and extracting its assets if the change was aborted meanwhile
+	if err := state.CheckpointBeforeWork(tomb); err != nil {
+		return err
+	}
+
 	setupOpts := &backend.SetupSnapOptions{
 		SkipKernelExtraction: snapsup.SkipKernelExtraction,
 	}
@@ -1204,7 +1450,7 @@ func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 	return m.finishTaskWithMaybeRestart(t, state.UndoneStatus, restartPossibility{info: oldInfo, RebootInfo: reboot})
 }
 
-func (m *SnapManager) doCopySnapData(t *state.Task, _ *tomb.Tomb) (err error) {
+func (m *SnapManager) doCopySnapData(t *state.Task, tomb *tomb.Tomb) (err error) {
 	st := t.State()
 	st.Lock()
 	snapsup, snapst, err := snapSetupAndState(t)
@@ -1237,9 +1483,30 @@ func (m *SnapManager) doCopySnapData(t *state.Task, _ *tomb.Tomb) (err error) {
 		return err
 	}
 
+	// nothing was copied yet
+	if err := state.CheckpointBeforeWork(tomb); err != nil {
+		return err
+	}
+
+	// the space reserved for the copy is about to be used
+	st.Lock()
+	releaseSpaceReservation(st, snapsup.InstanceName(), dataCopyReservation)
+	st.Unlock()
+
 	dirOpts := opts.getSnapDirOpts()
 	pb := NewTaskProgressAdapterUnlocked(t)
-	if copyDataErr := m.backend.CopySnapData(newInfo, oldInfo, dirOpts, pb); copyDataErr != nil {
+	copyData := func() error {
+		return m.backend.CopySnapData(newInfo, oldInfo, dirOpts, pb)
+	}
+	var copyDataErr error
+	if oldInfo != nil {
+		// processes of the snap still running may be writing to the data
+		// being copied
+		copyDataErr = WithFrozenSnapProcesses(st, snapsup.InstanceName(), copyData)
+	} else {
+		copyDataErr = copyData()
+	}
+	if copyDataErr != nil {
 		if oldInfo != nil {
 			// there is another revision of the snap, cannot remove
 			// shared data directory
@@ -1263,6 +1530,12 @@ func (m *SnapManager) doCopySnapData(t *state.Task, _ *tomb.Tomb) (err error) {
 		return copyDataErr
 	}
 
+	// copying can take a long time, the undo handler takes care of
+	// removing the copy if the change was aborted meanwhile
+	if err := state.Checkpoint(tomb); err != nil {
+		return err
+	}
+
 	if err := m.backend.SetupSnapSaveData(newInfo, deviceCtx, pb); err != nil {
 		return err
 	}
@@ -1893,6 +2166,18 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) (err error) {
 		}
 	}
 
+	// Verify the health of the snap for a while after a refresh,
+	// if configured to.
+	if err := maybeWatchRefreshHealth(t, snapsup, newInfo, oldCurrent); err != nil {
+		return err
+	}
+
+	// Put the new snapd on probation until it tested itself after
+	// the restart.
+	if newInfo.Type() == snap.TypeSnapd && !m.preseed {
+		startSnapdCanary(t, snapsup, oldCurrent)
+	}
+
 	// Do at the end so we only preserve the new state if it worked.
 	Set(st, snapsup.InstanceName(), snapst)
 
@@ -1963,13 +2248,13 @@ func (m *SnapManager) finishTaskWithMaybeRestart(t *state.Task, status state.Sta
 
 	st := t.State()
 
+	typ := restartPoss.info.Type()
+
 	if restartPoss.RebootRequired {
 		t.Logf("Requested system restart.")
-		return FinishTaskWithRestart(t, status, restart.RestartSystem, &restartPoss.RebootInfo)
+		return FinishTaskWithRestart(t, status, restart.RestartSystem, rebootReasonCode(typ), &restartPoss.RebootInfo)
 	}
 
-	typ := restartPoss.info.Type()
-
 	// If the type of the snap requesting this start is non-trivial that either
 	// means we are on Ubuntu Core and the type is a base/kernel/gadget which
 	// requires a reboot of the system, or that the type is snapd in which case
@@ -1986,7 +2271,21 @@ func (m *SnapManager) finishTaskWithMaybeRestart(t *state.Task, status state.Sta
 	}
 
 	t.Logf(restartReason)
-	return FinishTaskWithRestart(t, status, restart.RestartDaemon, nil)
+	return FinishTaskWithRestart(t, status, restart.RestartDaemon, restart.RebootReasonSnapdRefresh, nil)
+}
+
+// rebootReasonCode returns the code recorded for a reboot requested
+// after linking a snap of the given type.
+func rebootReasonCode(typ snap.Type) restart.RebootReasonCode {
+	switch typ {
+	case snap.TypeKernel:
+		return restart.RebootReasonKernelRefresh
+	case snap.TypeBase, snap.TypeOS:
+		return restart.RebootReasonBaseRefresh
+	case snap.TypeGadget:
+		return restart.RebootReasonGadgetRefresh
+	}
+	return restart.RebootReasonUnknown
 }
 
 func daemonRestartReason(st *state.State, typ snap.Type) string {
@@ -2300,7 +2599,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	// core snap -> next core snap
 	if release.OnClassic && newInfo.Type() == snap.TypeOS && oldCurrent.Unset() {
 		t.Logf("Requested daemon restart (undo classic initial core install)")
-		return FinishTaskWithRestart(t, finalStatus, restart.RestartDaemon, nil)
+		return FinishTaskWithRestart(t, finalStatus, restart.RestartDaemon, restart.RebootReasonSnapdRefresh, nil)
 	}
 
 	return nil
@@ -2491,10 +2790,15 @@ func (m *SnapManager) startSnapServices(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
+	var handoff bool
+	if err := t.Get("handoff", &handoff); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+
 	pb := NewTaskProgressAdapterUnlocked(t)
 
 	st.Unlock()
-	err = m.backend.StartServices(startupOrdered, svcsToDisable, pb, perfTimings)
+	err = m.backend.StartServices(startupOrdered, svcsToDisable, handoff, pb, perfTimings)
 	st.Lock()
 
 	return err
@@ -2660,7 +2964,7 @@ func (m *SnapManager) undoStopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	st.Unlock()
-	err = m.backend.StartServices(startupOrdered, disabledServices, progress.Null, perfTimings)
+	err = m.backend.StartServices(startupOrdered, disabledServices, false, progress.Null, perfTimings)
 	st.Lock()
 	if err != nil {
 		return err
@@ -2860,6 +3164,15 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 	// drop any potential revert status for this revision
 	delete(snapst.RevertStatus, snapsup.Revision().N)
 
+	var keepCache bool
+	if err := t.Get("keep-cache", &keepCache); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	var discardedSideInfo *snap.SideInfo
+	if idx := snapst.LastIndex(snapsup.Revision()); idx >= 0 {
+		discardedSideInfo = snapst.Sequence[idx]
+	}
+
 	if len(snapst.Sequence) == 1 {
 		snapst.Sequence = nil
 		snapst.Current = snap.Revision{}
@@ -2878,6 +3191,15 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 		}
 	}
 
+	var keptInCache bool
+	if keepCache && len(snapst.Sequence) == 0 && discardedSideInfo != nil {
+		// failing to keep the snap around is not fatal for its removal
+		keptInCache, err = keepInWarmCache(st, snapsup.InstanceName(), discardedSideInfo, snapst.TrackingChannel)
+		if err != nil {
+			t.Logf("cannot keep snap %q in the cache: %v", snapsup.InstanceName(), err)
+		}
+	}
+
 	pb := NewTaskProgressAdapterLocked(t)
 	typ, err := snapst.Type()
 	if err != nil {
@@ -2927,9 +3249,12 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 			return fmt.Errorf("cannot remove snap directory: %v", err)
 		}
 
-		// try to remove the auxiliary store info
-		if err := discardAuxStoreInfo(snapsup.SideInfo.SnapID); err != nil {
-			logger.Noticef("Cannot remove auxiliary store info for %q: %v", snapsup.InstanceName(), err)
+		// try to remove the auxiliary store info, unless it is kept
+		// together with the cached snap for its reinstall
+		if !keptInCache {
+			if err := discardAuxStoreInfo(snapsup.SideInfo.SnapID); err != nil {
+				logger.Noticef("Cannot remove auxiliary store info for %q: %v", snapsup.InstanceName(), err)
+			}
 		}
 
 		// XXX: also remove sequence files?
@@ -3014,6 +3339,7 @@ func (m *SnapManager) doSetAutoAliases(t *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
+	newAliases = applyAliasSets(curInfo, snapst.DisabledAliasSets, newAliases)
 	_, err = checkAliasesConflicts(st, snapName, snapst.AutoAliasesDisabled, newAliases, nil)
 	if err != nil {
 		return err
@@ -3088,6 +3414,7 @@ func (m *SnapManager) doRefreshAliases(t *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
+	newAliases = applyAliasSets(curInfo, snapst.DisabledAliasSets, newAliases)
 	_, err = checkAliasesConflicts(st, snapName, autoDisabled, newAliases, nil)
 	if err != nil {
 		return err
@@ -3325,6 +3652,7 @@ func (m *SnapManager) doAlias(t *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
+	newAliases = applyAliasSets(curInfo, snapst.DisabledAliasSets, newAliases)
 	_, err = checkAliasesConflicts(st, snapName, autoDisabled, newAliases, nil)
 	if err != nil {
 		return err
@@ -3410,6 +3738,92 @@ func (m *SnapManager) doUnalias(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+func (m *SnapManager) doToggleAliasSet(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	var set string
+	err = t.Get("alias-set", &set)
+	if err != nil {
+		return err
+	}
+	enable := t.Kind() == "enable-alias-set"
+	snapName := snapsup.InstanceName()
+	curInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+	if _, ok := curInfo.AliasSets[set]; !ok {
+		return fmt.Errorf("snap %q has no alias set %q", snapName, set)
+	}
+
+	oldDisabledSets := snapst.DisabledAliasSets
+	var newDisabledSets []string
+	for _, disabled := range oldDisabledSets {
+		if disabled != set {
+			newDisabledSets = append(newDisabledSets, disabled)
+		}
+	}
+	if !enable {
+		newDisabledSets = append(newDisabledSets, set)
+	}
+
+	autoDisabled := snapst.AutoAliasesDisabled
+	oldAliases := snapst.Aliases
+	newAliases := applyAliasSets(curInfo, newDisabledSets, oldAliases)
+	if enable {
+		_, err = checkAliasesConflicts(st, snapName, autoDisabled, newAliases, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	added, removed, err := applyAliasesChange(snapName, autoDisabled, oldAliases, autoDisabled, newAliases, m.backend, snapst.AliasesPending)
+	if err != nil {
+		return err
+	}
+	if err := aliasesTrace(t, added, removed); err != nil {
+		return err
+	}
+
+	t.Set("old-disabled-alias-sets", oldDisabledSets)
+	snapst.DisabledAliasSets = newDisabledSets
+	t.Set("old-aliases-v2", oldAliases)
+	snapst.Aliases = newAliases
+	Set(st, snapName, snapst)
+	return nil
+}
+
+func (m *SnapManager) undoToggleAliasSet(t *state.Task, tomb *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	var oldDisabledSets []string
+	err = t.Get("old-disabled-alias-sets", &oldDisabledSets)
+	if errors.Is(err, state.ErrNoState) {
+		// nothing to do
+		st.Unlock()
+		return nil
+	}
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	snapst.DisabledAliasSets = oldDisabledSets
+	Set(st, snapsup.InstanceName(), snapst)
+	st.Unlock()
+
+	return m.undoRefreshAliases(t, tomb)
+}
+
 // otherDisabledAliases is used to track for the benefit of undo what
 // changes were made aka what aliases were disabled of another
 // conflicting snap by prefer logic
@@ -1950,3 +1950,186 @@ func (s *snapmgrTestSuite) TestDoUndoPreferAliasesConflict(c *C) {
 		"alias3": {Manual: "cmd5", Auto: "cmd3"},
 	})
 }
+
+func (s *snapmgrTestSuite) TestDoUndoDisableAliasSet(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+		Active:  true,
+		Aliases: map[string]*snapstate.AliasTarget{
+			"alias1": {Auto: "cmd1"},
+			"alias2": {Manual: "cmd2"},
+			"alias3": {Auto: "cmd3"},
+		},
+	})
+
+	t := s.state.NewTask("disable-alias-set", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "alias-snap"},
+	})
+	t.Set("alias-set", "tools")
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(t)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+
+	for i := 0; i < 3; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
+
+	s.state.Lock()
+	c.Check(t.Status(), Equals, state.UndoneStatus, Commentf("%v", chg.Err()))
+
+	expected := fakeOps{
+		{
+			op: "update-aliases",
+			rmAliases: []*backend.Alias{
+				{Name: "alias1", Target: "alias-snap.cmd1"},
+				{Name: "alias2", Target: "alias-snap.cmd2"},
+			},
+		},
+		{
+			op: "update-aliases",
+			aliases: []*backend.Alias{
+				{Name: "alias1", Target: "alias-snap.cmd1"},
+				{Name: "alias2", Target: "alias-snap.cmd2"},
+			},
+		},
+	}
+	// start with an easier-to-read error if this fails:
+	c.Assert(s.fakeBackend.ops.Ops(), DeepEquals, expected.Ops())
+	c.Assert(s.fakeBackend.ops, DeepEquals, expected)
+
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "alias-snap", &snapst)
+	c.Assert(err, IsNil)
+
+	c.Check(snapst.DisabledAliasSets, HasLen, 0)
+	c.Check(snapst.Aliases, DeepEquals, map[string]*snapstate.AliasTarget{
+		"alias1": {Auto: "cmd1"},
+		"alias2": {Manual: "cmd2"},
+		"alias3": {Auto: "cmd3"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestDoEnableAliasSetConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap", Revision: snap.R(11)},
+		},
+		Current:           snap.R(11),
+		Active:            true,
+		DisabledAliasSets: []string{"tools"},
+		Aliases: map[string]*snapstate.AliasTarget{
+			"alias1": {Auto: "cmd1", SetDisabled: true},
+		},
+	})
+	snapstate.Set(s.state, "other-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "other-snap", Revision: snap.R(3)},
+		},
+		Current: snap.R(3),
+		Active:  true,
+		Aliases: map[string]*snapstate.AliasTarget{
+			"alias1": {Auto: "foo"},
+		},
+	})
+
+	t := s.state.NewTask("enable-alias-set", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "alias-snap"},
+	})
+	t.Set("alias-set", "tools")
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot enable alias "alias1" for "alias-snap", already enabled for "other-snap".*`)
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "alias-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.DisabledAliasSets, DeepEquals, []string{"tools"})
+}
+
+func (s *snapmgrTestSuite) TestDoRefreshAliasesDisabledAliasSet(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.AutoAliases = func(st *state.State, info *snap.Info) (map[string]string, error) {
+		return map[string]string{
+			"alias1": "cmd1",
+			"alias2": "cmd2",
+			"alias4": "cmd4",
+		}, nil
+	}
+
+	snapstate.Set(s.state, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap", Revision: snap.R(11)},
+		},
+		Current:           snap.R(11),
+		Active:            true,
+		DisabledAliasSets: []string{"tools"},
+		Aliases: map[string]*snapstate.AliasTarget{
+			"alias1": {Auto: "cmd1", SetDisabled: true},
+		},
+	})
+
+	t := s.state.NewTask("refresh-aliases", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "alias-snap"},
+	})
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+
+	c.Check(t.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	// the new alias2 is part of the disabled set
+	expected := fakeOps{
+		{
+			op: "update-aliases",
+			aliases: []*backend.Alias{
+				{Name: "alias4", Target: "alias-snap.cmd4"},
+			},
+		},
+	}
+	c.Assert(s.fakeBackend.ops, DeepEquals, expected)
+
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "alias-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Aliases, DeepEquals, map[string]*snapstate.AliasTarget{
+		"alias1": {Auto: "cmd1", SetDisabled: true},
+		"alias2": {Auto: "cmd2", SetDisabled: true},
+		"alias4": {Auto: "cmd4"},
+	})
+}
@@ -21,12 +21,17 @@ package snapstate_test
 
 import (
 	"errors"
+	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type copySnapDataSuite struct {
@@ -81,3 +86,149 @@ func (s *copySnapDataSuite) TestDoCopySnapDataFailedRead(c *C) {
 	c.Check(chg.Status(), Equals, state.ErrorStatus)
 	c.Check(chg.Err(), ErrorMatches, `(?s).*\(some error\)`)
 }
+
+func (s *copySnapDataSuite) runCopySnapData(c *C, current *snap.SideInfo) *state.Task {
+	restore := snapstatetest.MockDeviceModel(DefaultModel())
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	if current != nil {
+		snapstate.Set(s.state, "pkg", &snapstate.SnapState{
+			Sequence: []*snap.SideInfo{current},
+			Current:  current.Revision,
+			Active:   true,
+		})
+	}
+
+	task := s.state.NewTask("copy-snap-data", "test")
+	task.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "pkg",
+			Revision: snap.R(43),
+		},
+	})
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(task)
+
+	s.state.Unlock()
+	s.se.Ensure()
+	s.se.Wait()
+	s.state.Lock()
+
+	return task
+}
+
+func (s *copySnapDataSuite) TestDoCopySnapDataFreezesSnap(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "freeze.timeout", "10s")
+	tr.Commit()
+	s.state.Unlock()
+
+	var frozen []string
+	restore := snapstate.MockCgroupWithFrozenSnapProcesses(func(snapName string, timeout time.Duration, f func() error) error {
+		frozen = append(frozen, snapName)
+		c.Check(timeout, Equals, 10*time.Second)
+		// recorded for thawing should snapd restart meanwhile
+		s.state.Lock()
+		var frozenSnaps []string
+		c.Check(s.state.Get("frozen-snaps", &frozenSnaps), IsNil)
+		s.state.Unlock()
+		c.Check(frozenSnaps, DeepEquals, []string{"pkg"})
+		// the data is copied while the snap is frozen
+		c.Check(s.fakeBackend.ops.Ops(), HasLen, 0)
+		err := f()
+		c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"copy-data"})
+		return err
+	})
+	defer restore()
+
+	task := s.runCopySnapData(c, &snap.SideInfo{RealName: "pkg", Revision: snap.R(42)})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Check(frozen, DeepEquals, []string{"pkg"})
+	var frozenSnaps []string
+	c.Check(s.state.Get("frozen-snaps", &frozenSnaps), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *copySnapDataSuite) TestStartUpThawsFrozenSnaps(c *C) {
+	var thawed []string
+	restore := cgroup.MockFreezing(func(string) error {
+		c.Fatalf("unexpected freeze")
+		return nil
+	}, func(snapName string) error {
+		thawed = append(thawed, snapName)
+		if snapName == "bar" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	s.state.Set("frozen-snaps", []string{"foo", "bar"})
+	s.state.Unlock()
+
+	c.Assert(s.snapmgr.StartUp(), IsNil)
+	c.Check(thawed, DeepEquals, []string{"foo", "bar"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var frozenSnaps []string
+	c.Check(s.state.Get("frozen-snaps", &frozenSnaps), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (s *copySnapDataSuite) TestDoCopySnapDataFreezeDisabled(c *C) {
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "freeze.disabled", true)
+	tr.Commit()
+	s.state.Unlock()
+
+	restore := snapstate.MockCgroupWithFrozenSnapProcesses(func(snapName string, timeout time.Duration, f func() error) error {
+		c.Fatalf("unexpected freeze")
+		return nil
+	})
+	defer restore()
+
+	task := s.runCopySnapData(c, &snap.SideInfo{RealName: "pkg", Revision: snap.R(42)})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"copy-data", "setup-snap-save-data"})
+}
+
+func (s *copySnapDataSuite) TestDoCopySnapDataFreezeFailsCopiesAnyway(c *C) {
+	restore := snapstate.MockCgroupWithFrozenSnapProcesses(func(snapName string, timeout time.Duration, f func() error) error {
+		c.Check(timeout, Equals, cgroup.DefaultFreezeTimeout)
+		return errors.New("cannot freeze")
+	})
+	defer restore()
+
+	task := s.runCopySnapData(c, &snap.SideInfo{RealName: "pkg", Revision: snap.R(42)})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"copy-data", "setup-snap-save-data"})
+}
+
+func (s *copySnapDataSuite) TestDoCopySnapDataNoCurrentNoFreeze(c *C) {
+	restore := snapstate.MockCgroupWithFrozenSnapProcesses(func(snapName string, timeout time.Duration, f func() error) error {
+		c.Fatalf("unexpected freeze")
+		return nil
+	})
+	defer restore()
+
+	task := s.runCopySnapData(c, nil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(task.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"copy-data", "setup-snap-save-data"})
+}
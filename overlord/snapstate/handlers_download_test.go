@@ -20,7 +20,11 @@
 package snapstate_test
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
 	"path/filepath"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
@@ -175,6 +179,93 @@ func (s *downloadSnapSuite) TestDoDownloadSnapNormal(c *C) {
 	})
 }
 
+func (s *downloadSnapSuite) TestDoDownloadSnapReleasesSpaceReservation(c *C) {
+	s.state.Lock()
+
+	reservation := filepath.Join(c.MkDir(), ".snapd-space-reservation-foo-download")
+	c.Assert(ioutil.WriteFile(reservation, nil, 0600), IsNil)
+	s.state.Set("space-reservations", map[string]interface{}{
+		"foo": map[string]interface{}{
+			"files": map[string][]string{"download": {reservation}},
+		},
+	})
+
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "mySnapID",
+			Revision: snap.R(11),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(reservation, testutil.FileAbsent)
+	var reserved map[string]interface{}
+	c.Assert(s.state.Get("space-reservations", &reserved), IsNil)
+	c.Check(reserved, HasLen, 0)
+}
+
+func (s *downloadSnapSuite) TestDoDownloadSnapAbortedInFlight(c *C) {
+	started := make(chan bool)
+	s.fakeStore.downloadCallback = func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	s.state.Lock()
+	si := &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "mySnapID",
+		Revision: snap.R(11),
+	}
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.se.Ensure()
+	<-started
+
+	s.state.Lock()
+	c.Check(s.runner.Abortable(t), Equals, true)
+	chg.Abort()
+	s.state.Unlock()
+
+	// interrupts the download
+	s.se.Ensure()
+	s.se.Wait()
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	// nothing was downloaded, so the task is not undone
+	c.Check(t.Status(), Equals, state.HoldStatus)
+	c.Check(chg.Status(), Equals, state.HoldStatus)
+	c.Check(chg.Err(), IsNil)
+}
+
 func (s *downloadSnapSuite) TestDoDownloadSnapWithDeviceContext(c *C) {
 	s.state.Lock()
 
@@ -303,3 +394,225 @@ func (s *downloadSnapSuite) TestDoDownloadRateLimitedIntegration(c *C) {
 	})
 
 }
+
+func (s *downloadSnapSuite) TestDoDownloadDirectIOIntegration(c *C) {
+	s.state.Lock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "download.direct-io", true)
+	tr.Commit()
+
+	si := &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(11),
+	}
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+			Sha3_384:    "sha3-384-digest",
+		},
+	})
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(s.fakeStore.downloads, DeepEquals, []fakeDownload{
+		{
+			name:   "foo",
+			target: filepath.Join(dirs.SnapBlobDir, "foo_11.snap"),
+			opts: &store.DownloadOptions{
+				DirectIO: true,
+			},
+		},
+	})
+
+	// the digest verified by the store during the download is recorded
+	var snapsup snapstate.SnapSetup
+	c.Assert(t.Get("snap-setup", &snapsup), IsNil)
+	c.Check(snapsup.VerifiedSha3_384, Equals, "sha3-384-digest")
+}
+
+func (s *downloadSnapSuite) TestDoDownloadCachedNotVerified(c *C) {
+	s.fakeStore.downloadCached = map[string]bool{"foo": true}
+
+	s.state.Lock()
+	si := &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(11),
+	}
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+			Sha3_384:    "sha3-384-digest",
+		},
+	})
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(chg.Err(), IsNil)
+
+	// the blob came from the download cache, its digest was not
+	// verified and needs checking against the assertions
+	var snapsup snapstate.SnapSetup
+	c.Assert(t.Get("snap-setup", &snapsup), IsNil)
+	c.Check(snapsup.SnapPath, Equals, filepath.Join(dirs.SnapBlobDir, "foo_11.snap"))
+	c.Check(snapsup.VerifiedSha3_384, Equals, "")
+}
+
+func (s *downloadSnapSuite) TestDoPrefetchSnap(c *C) {
+	s.state.Lock()
+
+	si := &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(11),
+	}
+	download := s.state.NewTask("download-snap", "test")
+	download.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+			Sha3_384:    "sha3-384-digest",
+		},
+	})
+	prefetch := s.state.NewTask("prefetch-snap", "test")
+	prefetch.Set("snap-setup-task", download.ID())
+	download.WaitFor(prefetch)
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(prefetch)
+	chg.AddTask(download)
+
+	s.state.Unlock()
+
+	for i := 0; i < 2; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(prefetch.Status(), Equals, state.DoneStatus)
+	c.Check(download.Status(), Equals, state.DoneStatus)
+
+	// the snap was downloaded only once
+	c.Assert(s.fakeStore.downloads, DeepEquals, []fakeDownload{
+		{
+			name:   "foo",
+			target: filepath.Join(dirs.SnapBlobDir, "foo_11.snap"),
+		},
+	})
+
+	var snapsup snapstate.SnapSetup
+	c.Assert(download.Get("snap-setup", &snapsup), IsNil)
+	c.Check(snapsup.SnapPath, Equals, filepath.Join(dirs.SnapBlobDir, "foo_11.snap"))
+	c.Check(snapsup.VerifiedSha3_384, Equals, "sha3-384-digest")
+}
+
+func (s *downloadSnapSuite) TestDoPrefetchSnapCachedNotVerified(c *C) {
+	s.fakeStore.downloadCached = map[string]bool{"foo": true}
+
+	s.state.Lock()
+
+	si := &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(11),
+	}
+	download := s.state.NewTask("download-snap", "test")
+	download.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+			Sha3_384:    "sha3-384-digest",
+		},
+	})
+	prefetch := s.state.NewTask("prefetch-snap", "test")
+	prefetch.Set("snap-setup-task", download.ID())
+	download.WaitFor(prefetch)
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(prefetch)
+	chg.AddTask(download)
+
+	s.state.Unlock()
+
+	for i := 0; i < 2; i++ {
+		s.se.Ensure()
+		s.se.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(download.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeStore.downloads, HasLen, 1)
+
+	var snapsup snapstate.SnapSetup
+	c.Assert(download.Get("snap-setup", &snapsup), IsNil)
+	c.Check(snapsup.SnapPath, Equals, filepath.Join(dirs.SnapBlobDir, "foo_11.snap"))
+	c.Check(snapsup.VerifiedSha3_384, Equals, "")
+}
+
+func (s *downloadSnapSuite) TestDoPrefetchSnapErrorIsNotFatal(c *C) {
+	s.fakeStore.downloadError = map[string]error{
+		"foo": errors.New("boom"),
+	}
+
+	s.state.Lock()
+
+	si := &snap.SideInfo{
+		RealName: "foo",
+		SnapID:   "foo-id",
+		Revision: snap.R(11),
+	}
+	download := s.state.NewTask("download-snap", "test")
+	download.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: si,
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	prefetch := s.state.NewTask("prefetch-snap", "test")
+	prefetch.Set("snap-setup-task", download.ID())
+	download.WaitFor(prefetch)
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(prefetch)
+	chg.AddTask(download)
+
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(prefetch.Status(), Equals, state.DoneStatus)
+	c.Check(strings.Join(prefetch.Log(), ""), Matches, `.*cannot prefetch snap "foo": boom`)
+	var prefetched bool
+	c.Check(prefetch.Get("prefetched", &prefetched), testutil.ErrorIs, state.ErrNoState)
+}
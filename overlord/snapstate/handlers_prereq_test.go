@@ -732,3 +732,103 @@ func (s *prereqSuite) TestDoPrereqSnapdNoRevision(c *C) {
 	c.Check(chg.Status(), Equals, state.ErrorStatus)
 	c.Check(chg.Err(), ErrorMatches, `cannot perform the following tasks:\n.*- test \(cannot install system snap "snapd": no snap revision available as specified\)`)
 }
+
+func (s *prereqSuite) TestDoPrereqInstallsDefaultProviderClosure(c *C) {
+	s.state.Lock()
+
+	t := s.state.NewTask("prerequisites", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(33),
+		},
+		Base:               "none",
+		PrereqContentAttrs: map[string][]string{"nested-producer": {"nested-content"}},
+	})
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+
+	// the default provider of the default provider was queued right away
+	var linkedSnaps []string
+	for _, t := range chg.Tasks() {
+		if t.Kind() == "link-snap" {
+			snapsup, err := snapstate.TaskSnapSetup(t)
+			c.Assert(err, IsNil)
+			linkedSnaps = append(linkedSnaps, snapsup.InstanceName())
+		}
+	}
+	c.Check(linkedSnaps, testutil.DeepUnsortedMatches, []string{"nested-producer", "leaf-producer", "snapd"})
+}
+
+func (s *prereqSuite) TestDoPrereqPrefetchesWhileWaitingForSnapd(c *C) {
+	s.state.Lock()
+
+	t := s.state.NewTask("prerequisites", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(33),
+		},
+		Base:               "none",
+		PrereqContentAttrs: map[string][]string{"prereq1": {"some-content"}},
+	})
+	download := s.state.NewTask("download-snap", "test")
+	download.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(33),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	download.WaitFor(t)
+	chg := s.state.NewChange("sample", "...")
+	chg.AddTask(t)
+	chg.AddTask(download)
+	s.state.Unlock()
+
+	s.se.Ensure()
+	s.se.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+
+	var snapdTasks []*state.Task
+	for _, t := range chg.Tasks() {
+		snapsup, err := snapstate.TaskSnapSetup(t)
+		if err == nil && snapsup.InstanceName() == "snapd" {
+			snapdTasks = append(snapdTasks, t)
+		}
+	}
+	c.Assert(snapdTasks, Not(HasLen), 0)
+
+	prefetched := make(map[string]bool)
+	for _, t := range chg.Tasks() {
+		if t.Kind() != "prefetch-snap" {
+			continue
+		}
+		snapsup, err := snapstate.TaskSnapSetup(t)
+		c.Assert(err, IsNil)
+		prefetched[snapsup.InstanceName()] = true
+
+		// prefetching does not wait for snapd
+		for _, wt := range t.WaitTasks() {
+			c.Check(snapdTasks, Not(testutil.DeepContains), wt)
+		}
+		// while the download waits for the prefetch
+		c.Assert(t.HaltTasks(), HasLen, 1)
+		c.Check(t.HaltTasks()[0].Kind(), Equals, "download-snap")
+	}
+	c.Check(prefetched, DeepEquals, map[string]bool{"foo": true, "prereq1": true})
+	c.Check(download.WaitTasks(), testutil.DeepContains, snapdTasks[0])
+}
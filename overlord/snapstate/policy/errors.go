@@ -39,6 +39,11 @@ var (
 
 type inUseByErr []string
 
+// UsedBy returns the names of the snaps using the snap.
+func (e inUseByErr) UsedBy() []string {
+	return e
+}
+
 func (e inUseByErr) Error() string {
 	switch len(e) {
 	case 0:
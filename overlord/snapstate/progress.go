@@ -34,6 +34,8 @@ type taskProgressAdapter struct {
 	label    string
 	total    float64
 	current  float64
+	// unit is "bytes" once progress is reported through Write
+	unit string
 
 	lastReported float64
 }
@@ -52,6 +54,7 @@ func NewTaskProgressAdapterLocked(t *state.Task) progress.Meter {
 func (t *taskProgressAdapter) Start(label string, total float64) {
 	t.label = label
 	t.total = total
+	t.unit = ""
 	t.Set(0.0)
 }
 
@@ -73,7 +76,7 @@ func (t *taskProgressAdapter) Set(current float64) {
 		t.task.State().Lock()
 		defer t.task.State().Unlock()
 	}
-	t.task.SetProgress(t.label, int(current), int(t.total))
+	t.task.SetProgressInUnits(t.label, int(current), int(t.total), t.unit)
 }
 
 // SetTotal sets the maximum progress
@@ -87,11 +90,12 @@ func (t *taskProgressAdapter) Finished() {
 		t.task.State().Lock()
 		defer t.task.State().Unlock()
 	}
-	t.task.SetProgress(t.label, int(t.total), int(t.total))
+	t.task.SetProgressInUnits(t.label, int(t.total), int(t.total), t.unit)
 }
 
 // Write sets the current write progress
 func (t *taskProgressAdapter) Write(p []byte) (n int, err error) {
+	t.unit = "bytes"
 	t.Set(t.current + float64(len(p)))
 	return len(p), nil
 }
@@ -47,14 +47,24 @@ func (s *progressAdapterTestSuite) TestProgressAdapterWriteLocked(c *C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	m := NewTaskProgressAdapterLocked(st.NewTask("op", "msg"))
+	t := st.NewTask("op", "msg")
+	m := NewTaskProgressAdapterLocked(t)
 	p := m.(*taskProgressAdapter)
 
 	m.Start("msg", 161803)
 	c.Check(p.total, Equals, float64(161803))
+	unit, _ := t.ProgressDetails()
+	c.Check(unit, Equals, "")
 
 	m.Write([]byte("some-bytes"))
 	c.Check(p.current, Equals, float64(len("some-bytes")))
+
+	// progress written is in bytes
+	m.Write(make([]byte, 1000))
+	_, cur, _ := t.Progress()
+	c.Check(cur, Equals, 1010)
+	unit, _ = t.ProgressDetails()
+	c.Check(unit, Equals, "bytes")
 }
 
 func (s *progressAdapterTestSuite) TestProgressAdapterSetTaskProgress(c *C) {
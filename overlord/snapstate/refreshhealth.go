@@ -0,0 +1,211 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// After an app snap is refreshed its health can be verified for a while,
+// reverting the snap to the revision it was refreshed from if it becomes
+// unhealthy. The verification is enabled for all snaps with
+//
+//	snap set system refresh.auto-revert.window=10m
+//
+// and can be enabled or disabled (with a window of 0) for a single snap with
+//
+//	snap set system refresh.auto-revert.snaps.<snap>.window=30m
+//
+// The data of the revision a snap is reverted to is the data the snap had
+// before the refresh, as it was kept aside when the data was copied for the
+// new revision. The health is checked whenever the snap manager is
+// ensured.
+
+// VerifyRefreshHealth allows to hook the verification of the health of a
+// snap after it was refreshed. It returns an error describing why the snap
+// is unhealthy, if it is.
+var VerifyRefreshHealth func(st *state.State, instanceName string, rev snap.Revision) error
+
+// refreshHealthWatch records that the health of a snap is verified after
+// it was refreshed.
+type refreshHealthWatch struct {
+	Revision    snap.Revision `json:"revision"`
+	OldRevision snap.Revision `json:"old-revision"`
+	ChangeID    string        `json:"change-id"`
+	Until       time.Time     `json:"until"`
+}
+
+func refreshHealthWindowOption(tr *config.Transaction, key string) (window time.Duration, isSet bool, err error) {
+	var v interface{}
+	if err := tr.GetMaybe("core", key, &v); err != nil {
+		return 0, false, err
+	}
+	if v == nil || v == "" {
+		return 0, false, nil
+	}
+	window, err = time.ParseDuration(fmt.Sprintf("%v", v))
+	if err != nil {
+		return 0, false, fmt.Errorf("cannot parse %s: %v", key, err)
+	}
+	return window, true, nil
+}
+
+// refreshHealthWindow returns for how long the health of the given snap is
+// verified after it is refreshed. A zero window means the health is not
+// verified.
+func refreshHealthWindow(st *state.State, snapName string) (time.Duration, error) {
+	tr := config.NewTransaction(st)
+	window, isSet, err := refreshHealthWindowOption(tr, fmt.Sprintf("refresh.auto-revert.snaps.%s.window", snapName))
+	if err != nil || isSet {
+		return window, err
+	}
+	window, _, err = refreshHealthWindowOption(tr, "refresh.auto-revert.window")
+	return window, err
+}
+
+func refreshHealthWatches(st *state.State) (map[string]*refreshHealthWatch, error) {
+	var watches map[string]*refreshHealthWatch
+	if err := st.Get("refresh-health-watches", &watches); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if watches == nil {
+		watches = make(map[string]*refreshHealthWatch)
+	}
+	return watches, nil
+}
+
+// maybeWatchRefreshHealth starts verifying the health of the snap being
+// refreshed by the given link-snap task, if configured to.
+func maybeWatchRefreshHealth(t *state.Task, snapsup *SnapSetup, info *snap.Info, oldCurrent snap.Revision) error {
+	st := t.State()
+	if info.Type() != snap.TypeApp || snapsup.Revert || oldCurrent.Unset() {
+		return nil
+	}
+	window, err := refreshHealthWindow(st, snapsup.SnapName())
+	if err != nil {
+		return err
+	}
+	if window <= 0 {
+		return nil
+	}
+
+	watches, err := refreshHealthWatches(st)
+	if err != nil {
+		return err
+	}
+	watches[snapsup.InstanceName()] = &refreshHealthWatch{
+		Revision:    snapsup.Revision(),
+		OldRevision: oldCurrent,
+		ChangeID:    t.Change().ID(),
+		Until:       timeNow().Add(window),
+	}
+	st.Set("refresh-health-watches", watches)
+	return nil
+}
+
+// checkRefreshHealth verifies the health of a refreshed snap, reverting it
+// if it is unhealthy. It returns whether the snap is still to be watched.
+func checkRefreshHealth(st *state.State, instanceName string, w *refreshHealthWatch, now time.Time) (keep bool, err error) {
+	if chg := st.Change(w.ChangeID); chg != nil {
+		if !chg.Status().Ready() {
+			// still refreshing
+			return true, nil
+		}
+		if chg.Status() != state.DoneStatus {
+			// the refresh was undone
+			return false, nil
+		}
+	}
+
+	var snapst SnapState
+	if err := Get(st, instanceName, &snapst); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return false, nil
+		}
+		return false, err
+	}
+	if snapst.Current != w.Revision {
+		// removed, reverted or refreshed again meanwhile
+		return false, nil
+	}
+
+	if VerifyRefreshHealth != nil {
+		if healthErr := VerifyRefreshHealth(st, instanceName, w.Revision); healthErr != nil {
+			ts, err := RevertToRevision(st, instanceName, w.OldRevision, Flags{}, "")
+			if err != nil {
+				if _, ok := err.(*ChangeConflictError); ok {
+					// try again later
+					return true, nil
+				}
+				logger.Noticef("cannot revert unhealthy snap %q: %v", instanceName, err)
+				return false, nil
+			}
+			msg := fmt.Sprintf(i18n.G("Revert %q snap after failed health verification"), instanceName)
+			chg := st.NewChange("revert-snap", msg)
+			chg.AddAll(ts)
+			chg.Set("snap-names", []string{instanceName})
+			st.Warnf("snap %q is reverted to revision %s as it became unhealthy after being refreshed to revision %s: %v", instanceName, w.OldRevision, w.Revision, healthErr)
+			return false, nil
+		}
+	}
+
+	return now.Before(w.Until), nil
+}
+
+// ensureRefreshHealth verifies the health of the recently refreshed snaps
+// and reverts those that became unhealthy.
+func (m *SnapManager) ensureRefreshHealth() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	watches, err := refreshHealthWatches(m.state)
+	if err != nil {
+		return err
+	}
+	if len(watches) == 0 {
+		return nil
+	}
+
+	now := timeNow()
+	for instanceName, w := range watches {
+		keep, err := checkRefreshHealth(m.state, instanceName, w, now)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			delete(watches, instanceName)
+		}
+	}
+
+	if len(watches) == 0 {
+		m.state.Set("refresh-health-watches", nil)
+		return nil
+	}
+	m.state.Set("refresh-health-watches", watches)
+	return nil
+}
@@ -0,0 +1,239 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+func (s *snapmgrTestSuite) refreshWithHealthWindow(c *C, options map[string]interface{}) (*state.Change, time.Time) {
+	si := snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(7),
+		SnapID:   "services-snap-id",
+	}
+	snaptest.MockSnap(c, `name: services-snap`, &si)
+
+	now, err := time.Parse(time.RFC3339, "2022-06-10T10:00:00Z")
+	c.Assert(err, IsNil)
+	s.AddCleanup(snapstate.MockTimeNow(func() time.Time { return now }))
+
+	tr := config.NewTransaction(s.state)
+	for k, v := range options {
+		c.Assert(tr.Set("core", k, v), IsNil)
+	}
+	tr.Commit()
+
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active:          true,
+		Sequence:        []*snap.SideInfo{&si},
+		Current:         si.Revision,
+		SnapType:        "app",
+		TrackingChannel: "latest/stable",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "services-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.IsReady(), Equals, true)
+	return chg, now
+}
+
+func (s *snapmgrTestSuite) refreshHealthWatches(c *C) map[string]*snapstate.RefreshHealthWatch {
+	var watches map[string]*snapstate.RefreshHealthWatch
+	err := s.state.Get("refresh-health-watches", &watches)
+	if err != nil {
+		c.Assert(err, ErrorMatches, "no state entry for key.*")
+	}
+	return watches
+}
+
+func (s *snapmgrTestSuite) TestRefreshHealthNotWatchedByDefault(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.refreshWithHealthWindow(c, nil)
+	c.Check(s.refreshHealthWatches(c), HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestRefreshHealthWatched(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg, now := s.refreshWithHealthWindow(c, map[string]interface{}{
+		"refresh.auto-revert.window": "10m",
+	})
+	c.Check(s.refreshHealthWatches(c), DeepEquals, map[string]*snapstate.RefreshHealthWatch{
+		"services-snap": {
+			Revision:    snap.R(11),
+			OldRevision: snap.R(7),
+			ChangeID:    chg.ID(),
+			Until:       now.Add(10 * time.Minute),
+		},
+	})
+}
+
+func (s *snapmgrTestSuite) TestRefreshHealthWatchedPerSnap(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, now := s.refreshWithHealthWindow(c, map[string]interface{}{
+		"refresh.auto-revert.window":                     "10m",
+		"refresh.auto-revert.snaps.services-snap.window": "1h",
+	})
+	watches := s.refreshHealthWatches(c)
+	c.Assert(watches["services-snap"], NotNil)
+	c.Check(watches["services-snap"].Until, Equals, now.Add(time.Hour))
+}
+
+func (s *snapmgrTestSuite) TestRefreshHealthNotWatchedPerSnap(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.refreshWithHealthWindow(c, map[string]interface{}{
+		"refresh.auto-revert.window":                     "10m",
+		"refresh.auto-revert.snaps.services-snap.window": "0",
+	})
+	c.Check(s.refreshHealthWatches(c), HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestEnsureRefreshHealthHealthy(c *C) {
+	var verified []string
+	restore := snapstate.MockVerifyRefreshHealth(func(st *state.State, instanceName string, rev snap.Revision) error {
+		verified = append(verified, fmt.Sprintf("%s:%s", instanceName, rev))
+		return nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	_, now := s.refreshWithHealthWindow(c, map[string]interface{}{
+		"refresh.auto-revert.window": "10m",
+	})
+	verified = nil
+	s.state.Unlock()
+
+	c.Assert(s.snapmgr.EnsureRefreshHealth(), IsNil)
+	c.Check(verified, DeepEquals, []string{"services-snap:11"})
+
+	s.state.Lock()
+	c.Check(s.refreshHealthWatches(c), HasLen, 1)
+	s.state.Unlock()
+
+	// the window is over
+	restore = snapstate.MockTimeNow(func() time.Time { return now.Add(10 * time.Minute) })
+	defer restore()
+	c.Assert(s.snapmgr.EnsureRefreshHealth(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.refreshHealthWatches(c), HasLen, 0)
+	for _, chg := range s.state.Changes() {
+		c.Check(chg.Kind(), Not(Equals), "revert-snap")
+	}
+}
+
+func (s *snapmgrTestSuite) TestEnsureRefreshHealthUnhealthyReverts(c *C) {
+	unhealthy := false
+	restore := snapstate.MockVerifyRefreshHealth(func(st *state.State, instanceName string, rev snap.Revision) error {
+		if unhealthy {
+			return fmt.Errorf("service %q was restarted 3 times", "snap.services-snap.svc1.service")
+		}
+		return nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.refreshWithHealthWindow(c, map[string]interface{}{
+		"refresh.auto-revert.window": "10m",
+	})
+
+	unhealthy = true
+	s.state.Unlock()
+	err := s.snapmgr.EnsureRefreshHealth()
+	s.state.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(s.refreshHealthWatches(c), HasLen, 0)
+	var revertChg *state.Change
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "revert-snap" {
+			revertChg = chg
+		}
+	}
+	c.Assert(revertChg, NotNil)
+	c.Check(revertChg.Summary(), Equals, `Revert "services-snap" snap after failed health verification`)
+	var snapNames []string
+	c.Assert(revertChg.Get("snap-names", &snapNames), IsNil)
+	c.Check(snapNames, DeepEquals, []string{"services-snap"})
+	snapsup, err := snapstate.TaskSnapSetup(revertChg.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Revision(), Equals, snap.R(7))
+	c.Check(snapsup.Revert, Equals, true)
+
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Equals, `snap "services-snap" is reverted to revision 7 as it became unhealthy after being refreshed to revision 11: service "snap.services-snap.svc1.service" was restarted 3 times`)
+}
+
+func (s *snapmgrTestSuite) TestEnsureRefreshHealthDropsStaleWatches(c *C) {
+	restore := snapstate.MockVerifyRefreshHealth(func(st *state.State, instanceName string, rev snap.Revision) error {
+		return fmt.Errorf("unhealthy")
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	failedChg := s.state.NewChange("refresh", "...")
+	failedChg.AddTask(s.state.NewTask("foo", "..."))
+	failedChg.SetStatus(state.ErrorStatus)
+	s.state.Set("refresh-health-watches", map[string]*snapstate.RefreshHealthWatch{
+		// the refresh failed
+		"some-snap": {Revision: snap.R(2), OldRevision: snap.R(1), ChangeID: failedChg.ID()},
+		// not installed anymore
+		"other-snap": {Revision: snap.R(2), OldRevision: snap.R(1), ChangeID: "999"},
+	})
+
+	s.state.Unlock()
+	err := s.snapmgr.EnsureRefreshHealth()
+	s.state.Lock()
+	c.Assert(err, IsNil)
+
+	c.Check(s.refreshHealthWatches(c), HasLen, 0)
+	for _, chg := range s.state.Changes() {
+		c.Check(chg.Kind(), Not(Equals), "revert-snap")
+	}
+}
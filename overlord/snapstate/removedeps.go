@@ -0,0 +1,192 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// DependentSnapsError is returned when removing a snap that other
+// installed snaps still need, either as their base or as the default
+// provider of their content plugs.
+type DependentSnapsError struct {
+	Snap       string
+	Dependents []string
+}
+
+func (e *DependentSnapsError) Error() string {
+	if len(e.Dependents) == 1 {
+		return fmt.Sprintf("snap %q is not removable: snap is needed by snap %q", e.Snap, e.Dependents[0])
+	}
+	return fmt.Sprintf("snap %q is not removable: snap is needed by snaps %s", e.Snap, strutil.Quoted(e.Dependents))
+}
+
+// snapDependencies returns the names of the snaps the given snap needs to
+// be installed: its base and the default providers of its content plugs.
+func snapDependencies(info *snap.Info) []string {
+	var deps []string
+	switch {
+	case info.Base != "" && info.Base != "none":
+		deps = append(deps, info.Base)
+	case info.Base == "" && info.Type() == snap.TypeApp && info.SnapName() != "snapd":
+		deps = append(deps, "core")
+	}
+	for provider := range snap.NeededDefaultProviders(info) {
+		if provider != info.InstanceName() {
+			deps = append(deps, provider)
+		}
+	}
+	return deps
+}
+
+// installedDependents returns a map from the name of each installed snap
+// to the sorted names of the installed snaps that depend on it.
+func installedDependents(st *state.State) (map[string][]string, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+	dependents := make(map[string][]string)
+	for name, snapst := range snapStates {
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			// a broken snap does not hold up removals
+			continue
+		}
+		for _, dep := range snapDependencies(info) {
+			if _, ok := snapStates[dep]; !ok {
+				continue
+			}
+			if !strutil.ListContains(dependents[dep], name) {
+				dependents[dep] = append(dependents[dep], name)
+			}
+		}
+	}
+	for _, names := range dependents {
+		sort.Strings(names)
+	}
+	return dependents, nil
+}
+
+// checkDependents returns a DependentSnapsError if the named snap is
+// needed by installed snaps other than the ones in removing.
+func checkDependents(st *state.State, name string, removing map[string]bool) error {
+	dependents, err := installedDependents(st)
+	if err != nil {
+		return err
+	}
+	return dependentsError(name, dependents[name], removing)
+}
+
+func dependentsError(name string, dependents []string, removing map[string]bool) error {
+	var missing []string
+	for _, dep := range dependents {
+		if !removing[dep] {
+			missing = append(missing, dep)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return &DependentSnapsError{Snap: name, Dependents: missing}
+}
+
+// RemovalPlan returns the names of the installed snaps that removing the
+// given snaps involves, in the order they are removed: snaps come before
+// the snaps they depend on. With cascade the installed snaps depending on
+// the given ones, directly or not, are included. Without cascade a
+// DependentSnapsError is returned if one of the given snaps is needed by a
+// snap not being removed. Snaps that are not installed are left out.
+func RemovalPlan(st *state.State, names []string, cascade bool) ([]string, error) {
+	plan, _, err := removalPlan(st, names, cascade)
+	return plan, err
+}
+
+// removalPlan is RemovalPlan also returning the dependents of the
+// installed snaps.
+func removalPlan(st *state.State, names []string, cascade bool) (plan []string, dependents map[string][]string, err error) {
+	dependents, err = installedDependents(st)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	removing := make(map[string]bool, len(names))
+	var queue []string
+	for _, name := range names {
+		var snapst SnapState
+		if err := Get(st, name, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return nil, nil, err
+		}
+		if !snapst.IsInstalled() || removing[name] {
+			continue
+		}
+		removing[name] = true
+		queue = append(queue, name)
+	}
+	roots := append([]string(nil), queue...)
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		var missing []string
+		for _, dep := range dependents[name] {
+			if removing[dep] {
+				continue
+			}
+			if !cascade {
+				missing = append(missing, dep)
+				continue
+			}
+			removing[dep] = true
+			queue = append(queue, dep)
+		}
+		if len(missing) > 0 {
+			return nil, nil, &DependentSnapsError{Snap: name, Dependents: missing}
+		}
+	}
+
+	// depth first, emitting a snap after all its dependents
+	plan = make([]string, 0, len(removing))
+	visited := make(map[string]bool, len(removing))
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dep := range dependents[name] {
+			if removing[dep] {
+				visit(dep)
+			}
+		}
+		plan = append(plan, name)
+	}
+	for _, name := range roots {
+		visit(name)
+	}
+	return plan, dependents, nil
+}
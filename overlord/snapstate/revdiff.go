@@ -0,0 +1,263 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	_ "golang.org/x/crypto/sha3"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdir"
+)
+
+// DiffEntries lists the names of the things added, removed and modified
+// between two revisions of a snap.
+type DiffEntries struct {
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+}
+
+// Empty returns whether there are no differences.
+func (d *DiffEntries) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+func (d *DiffEntries) sort() {
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Modified)
+}
+
+// MetadataChange is a snap.yaml field whose value differs between two
+// revisions of a snap.
+type MetadataChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// RevisionDiff describes what changed between two locally available
+// revisions of a snap.
+type RevisionDiff struct {
+	Snap string        `json:"snap"`
+	From snap.Revision `json:"from"`
+	To   snap.Revision `json:"to"`
+	// Files are the paths, relative to the top of the snap, of the
+	// files and symlinks that differ.
+	Files    DiffEntries      `json:"files"`
+	Metadata []MetadataChange `json:"metadata,omitempty"`
+	Apps     DiffEntries      `json:"apps"`
+	Plugs    DiffEntries      `json:"plugs"`
+	Slots    DiffEntries      `json:"slots"`
+	Hooks    DiffEntries      `json:"hooks"`
+}
+
+// DiffRevisions compares the given revisions of an installed snap, both
+// of which must still be available on the system.
+// Note that the state must be locked by the caller, it is released while
+// the files of the revisions are compared.
+func DiffRevisions(st *state.State, name string, from, to snap.Revision) (*RevisionDiff, error) {
+	var snapst SnapState
+	if err := Get(st, name, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if !snapst.IsInstalled() {
+		return nil, &snap.NotInstalledError{Snap: name}
+	}
+
+	infos := make([]*snap.Info, 2)
+	for i, rev := range []snap.Revision{from, to} {
+		idx := snapst.LastIndex(rev)
+		if idx < 0 {
+			return nil, &snap.NotInstalledError{Snap: name, Rev: rev}
+		}
+		info, err := readInfo(name, snapst.Sequence[idx], errorOnBroken)
+		if err != nil {
+			return nil, err
+		}
+		infos[i] = info
+	}
+	fromInfo, toInfo := infos[0], infos[1]
+
+	// hashing every file of both revisions can take a while, do not
+	// hold the state lock meanwhile
+	st.Unlock()
+	fromFiles, err := snapFileDigests(fromInfo.MountDir())
+	var toFiles map[string]string
+	if err == nil {
+		toFiles, err = snapFileDigests(toInfo.MountDir())
+	}
+	st.Lock()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &RevisionDiff{
+		Snap:     name,
+		From:     from,
+		To:       to,
+		Files:    diffMaps(fromFiles, toFiles, nil),
+		Metadata: metadataChanges(fromInfo, toInfo),
+		Apps: diffMaps(fromInfo.Apps, toInfo.Apps, func(a, b interface{}) bool {
+			return appSignature(a.(*snap.AppInfo)) == appSignature(b.(*snap.AppInfo))
+		}),
+		Plugs: diffMaps(fromInfo.Plugs, toInfo.Plugs, func(a, b interface{}) bool {
+			pa, pb := a.(*snap.PlugInfo), b.(*snap.PlugInfo)
+			return pa.Interface == pb.Interface && reflect.DeepEqual(pa.Attrs, pb.Attrs)
+		}),
+		Slots: diffMaps(fromInfo.Slots, toInfo.Slots, func(a, b interface{}) bool {
+			sa, sb := a.(*snap.SlotInfo), b.(*snap.SlotInfo)
+			return sa.Interface == sb.Interface && reflect.DeepEqual(sa.Attrs, sb.Attrs)
+		}),
+	}
+	diff.Hooks = diffMaps(fromInfo.Hooks, toInfo.Hooks, func(a, b interface{}) bool {
+		ha, hb := a.(*snap.HookInfo), b.(*snap.HookInfo)
+		// the hook executable may have changed too
+		hookFile := filepath.Join("meta", "hooks", ha.Name)
+		return hookSignature(ha) == hookSignature(hb) && fromFiles[hookFile] == toFiles[hookFile]
+	})
+	return diff, nil
+}
+
+// snapFileDigests returns a map from the path, relative to dir, of every
+// file and symlink under dir to a digest of its content and mode.
+func snapFileDigests(dir string) (map[string]string, error) {
+	digests := make(map[string]string)
+	container := snapdir.New(dir)
+	err := container.Walk(".", func(path string, st os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if st.IsDir() {
+			return nil
+		}
+		full := filepath.Join(dir, path)
+		var content string
+		switch {
+		case st.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(full)
+			if err != nil {
+				return err
+			}
+			content = "symlink:" + target
+		case st.Mode().IsRegular():
+			digest, _, err := osutil.FileDigest(full, crypto.SHA3_384)
+			if err != nil {
+				return err
+			}
+			content = hex.EncodeToString(digest)
+		default:
+			content = "special"
+		}
+		digests[filepath.Clean(path)] = fmt.Sprintf("%s:%s", st.Mode(), content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list files of %q: %v", dir, err)
+	}
+	return digests, nil
+}
+
+// diffMaps compares the keys of two maps of the same type. Values under
+// the same key are compared with equal, or with == if equal is nil.
+func diffMaps(from, to interface{}, equal func(a, b interface{}) bool) DiffEntries {
+	var d DiffEntries
+	fromV, toV := reflect.ValueOf(from), reflect.ValueOf(to)
+	for _, k := range fromV.MapKeys() {
+		toVal := toV.MapIndex(k)
+		if !toVal.IsValid() {
+			d.Removed = append(d.Removed, k.String())
+			continue
+		}
+		a, b := fromV.MapIndex(k).Interface(), toVal.Interface()
+		same := a == b
+		if equal != nil {
+			same = equal(a, b)
+		}
+		if !same {
+			d.Modified = append(d.Modified, k.String())
+		}
+	}
+	for _, k := range toV.MapKeys() {
+		if !fromV.MapIndex(k).IsValid() {
+			d.Added = append(d.Added, k.String())
+		}
+	}
+	d.sort()
+	return d
+}
+
+// metadataChanges returns the top-level snap.yaml fields that differ
+// between the given revisions.
+func metadataChanges(from, to *snap.Info) []MetadataChange {
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"version", from.Version, to.Version},
+		{"type", string(from.Type()), string(to.Type())},
+		{"base", from.Base, to.Base},
+		{"epoch", from.Epoch.String(), to.Epoch.String()},
+		{"confinement", string(from.Confinement), string(to.Confinement)},
+		{"summary", from.Summary(), to.Summary()},
+		{"description", from.Description(), to.Description()},
+	}
+	var changes []MetadataChange
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, MetadataChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appSignature and hookSignature summarize what is compared of apps and
+// hooks beyond their presence.
+func appSignature(app *snap.AppInfo) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s", app.Command, strings.Join(app.CommandChain, " "), app.Daemon,
+		strings.Join(sortedKeys(app.Plugs), ","), strings.Join(sortedKeys(app.Slots), ","))
+}
+
+func hookSignature(hook *snap.HookInfo) string {
+	return fmt.Sprintf("%s|%s|%s", strings.Join(hook.CommandChain, " "),
+		strings.Join(sortedKeys(hook.Plugs), ","), strings.Join(sortedKeys(hook.Slots), ","))
+}
@@ -0,0 +1,190 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+const diffSnapYamlR1 = `name: some-snap
+version: 1.0
+summary: some snap
+apps:
+  app:
+    command: bin/app
+    plugs: [network]
+  svc:
+    command: bin/svc
+    daemon: simple
+hooks:
+  configure:
+  install:
+plugs:
+  data:
+    interface: content
+    target: $SNAP/data
+`
+
+const diffSnapYamlR2 = `name: some-snap
+version: 2.0
+summary: some snap
+base: core20
+apps:
+  app:
+    command: bin/app
+    plugs: [network, home]
+  tool:
+    command: bin/tool
+hooks:
+  configure:
+  post-refresh:
+plugs:
+  data:
+    interface: content
+    target: $SNAP/other
+slots:
+  dbus-svc:
+    interface: dbus
+    bus: session
+    name: org.example.Svc
+`
+
+func (s *snapmgrTestSuite) mockDiffSnap(c *C, yaml string, rev int, files map[string]string) *snap.SideInfo {
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(rev)}
+	info := snaptest.MockSnap(c, yaml, si)
+	for name, content := range files {
+		fname := filepath.Join(info.MountDir(), name)
+		c.Assert(os.MkdirAll(filepath.Dir(fname), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(fname, []byte(content), 0755), IsNil)
+	}
+	return si
+}
+
+func (s *snapmgrTestSuite) TestDiffRevisions(c *C) {
+	restore := snapstate.MockSnapReadInfo(snap.ReadInfo)
+	defer restore()
+
+	si1 := s.mockDiffSnap(c, diffSnapYamlR1, 1, map[string]string{
+		"bin/app":              "app v1",
+		"bin/svc":              "svc",
+		"meta/hooks/configure": "configure v1",
+		"meta/hooks/install":   "install",
+		"share/same":           "same",
+	})
+	si2 := s.mockDiffSnap(c, diffSnapYamlR2, 2, map[string]string{
+		"bin/app":                 "app v2",
+		"bin/tool":                "tool",
+		"meta/hooks/configure":    "configure v2",
+		"meta/hooks/post-refresh": "post-refresh",
+		"share/same":              "same",
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si1, si2},
+		Current:  si2.Revision,
+		SnapType: "app",
+	})
+
+	diff, err := snapstate.DiffRevisions(s.state, "some-snap", snap.R(1), snap.R(2))
+	c.Assert(err, IsNil)
+	c.Check(diff.Snap, Equals, "some-snap")
+	c.Check(diff.From, Equals, snap.R(1))
+	c.Check(diff.To, Equals, snap.R(2))
+	c.Check(diff.Files, DeepEquals, snapstate.DiffEntries{
+		Added:    []string{"bin/tool", "meta/hooks/post-refresh"},
+		Removed:  []string{"bin/svc", "meta/hooks/install"},
+		Modified: []string{"bin/app", "meta/hooks/configure", "meta/snap.yaml"},
+	})
+	c.Check(diff.Metadata, DeepEquals, []snapstate.MetadataChange{
+		{Field: "version", From: "1.0", To: "2.0"},
+		{Field: "base", From: "", To: "core20"},
+	})
+	c.Check(diff.Apps, DeepEquals, snapstate.DiffEntries{
+		Added:    []string{"tool"},
+		Removed:  []string{"svc"},
+		Modified: []string{"app"},
+	})
+	c.Check(diff.Plugs, DeepEquals, snapstate.DiffEntries{
+		Added:    []string{"home"},
+		Modified: []string{"data"},
+	})
+	c.Check(diff.Slots, DeepEquals, snapstate.DiffEntries{
+		Added: []string{"dbus-svc"},
+	})
+	c.Check(diff.Hooks, DeepEquals, snapstate.DiffEntries{
+		Added:    []string{"post-refresh"},
+		Removed:  []string{"install"},
+		Modified: []string{"configure"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestDiffRevisionsSame(c *C) {
+	restore := snapstate.MockSnapReadInfo(snap.ReadInfo)
+	defer restore()
+
+	si := s.mockDiffSnap(c, diffSnapYamlR1, 1, map[string]string{"bin/app": "app"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+
+	diff, err := snapstate.DiffRevisions(s.state, "some-snap", snap.R(1), snap.R(1))
+	c.Assert(err, IsNil)
+	c.Check(diff.Files.Empty(), Equals, true)
+	c.Check(diff.Metadata, HasLen, 0)
+	c.Check(diff.Apps.Empty(), Equals, true)
+	c.Check(diff.Plugs.Empty(), Equals, true)
+	c.Check(diff.Slots.Empty(), Equals, true)
+	c.Check(diff.Hooks.Empty(), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestDiffRevisionsErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.DiffRevisions(s.state, "some-snap", snap.R(1), snap.R(2))
+	c.Check(err, ErrorMatches, `snap "some-snap" is not installed`)
+
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)}
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+	_, err = snapstate.DiffRevisions(s.state, "some-snap", snap.R(1), snap.R(2))
+	c.Check(err, ErrorMatches, `revision 2 of snap "some-snap" is not installed`)
+}
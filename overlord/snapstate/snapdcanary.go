@@ -0,0 +1,174 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// After the snapd snap is refreshed snapd restarts into the new revision,
+// which is then on probation: the change refreshing it waits until the new
+// snapd has tested itself, checking that the state loads, that the backends
+// initialize and that the API responds, and marked the revision as good.
+// A snapd that fails its self-test exits, such that the snapd failure
+// handling restarts the previous revision, which undoes the refresh.
+
+// SnapdCanaryStatus is the status of a snapd revision being tested after
+// a refresh.
+type SnapdCanaryStatus string
+
+const (
+	// SnapdCanaryTesting is the status of a snapd revision until it
+	// passed its self-test.
+	SnapdCanaryTesting SnapdCanaryStatus = "testing"
+	// SnapdCanaryGood is the status of a snapd revision that passed
+	// its self-test.
+	SnapdCanaryGood SnapdCanaryStatus = "good"
+	// SnapdCanaryFailed is the status of a snapd revision that failed
+	// its self-test or could not start, and was reverted.
+	SnapdCanaryFailed SnapdCanaryStatus = "failed"
+)
+
+// SnapdCanary records the self-test of the snapd revision the system was
+// last refreshed to.
+type SnapdCanary struct {
+	Revision    snap.Revision     `json:"revision"`
+	OldRevision snap.Revision     `json:"old-revision"`
+	ChangeID    string            `json:"change-id"`
+	Status      SnapdCanaryStatus `json:"status"`
+	// Reason is why the revision failed, if it did.
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// SnapdCanaryInfo returns the record of the self-test of the snapd revision
+// the system was last refreshed to, or nil if there is none.
+func SnapdCanaryInfo(st *state.State) (*SnapdCanary, error) {
+	var canary SnapdCanary
+	err := st.Get("snapd-canary", &canary)
+	if errors.Is(err, state.ErrNoState) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &canary, nil
+}
+
+// startSnapdCanary puts the snapd revision being linked by the given task
+// on probation until it passed its self-test.
+func startSnapdCanary(t *state.Task, snapsup *SnapSetup, oldCurrent snap.Revision) {
+	t.State().Set("snapd-canary", &SnapdCanary{
+		Revision:    snapsup.Revision(),
+		OldRevision: oldCurrent,
+		ChangeID:    t.Change().ID(),
+		Status:      SnapdCanaryTesting,
+		Since:       timeNow(),
+	})
+}
+
+func setSnapdCanaryStatus(st *state.State, status SnapdCanaryStatus, reason string) (*SnapdCanary, error) {
+	canary, err := SnapdCanaryInfo(st)
+	if err != nil {
+		return nil, err
+	}
+	if canary == nil || canary.Status != SnapdCanaryTesting {
+		return nil, nil
+	}
+	canary.Status = status
+	canary.Reason = reason
+	canary.Since = timeNow()
+	st.Set("snapd-canary", canary)
+	return canary, nil
+}
+
+// MarkSnapdRevisionGood records that the snapd revision being tested passed
+// its self-test, letting the change that refreshed it carry on. It does
+// nothing if no revision is being tested.
+func MarkSnapdRevisionGood(st *state.State) error {
+	canary, err := setSnapdCanaryStatus(st, SnapdCanaryGood, "")
+	if err != nil {
+		return err
+	}
+	if canary != nil {
+		// let the waiting change carry on right away
+		st.EnsureBefore(0)
+	}
+	return nil
+}
+
+// MarkSnapdRevisionFailed records that the snapd revision being tested
+// failed its self-test for the given reason. It does nothing if no
+// revision is being tested.
+func MarkSnapdRevisionFailed(st *state.State, reason error) error {
+	canary, err := setSnapdCanaryStatus(st, SnapdCanaryFailed, reason.Error())
+	if err != nil {
+		return err
+	}
+	if canary != nil {
+		st.Warnf("snapd revision %s failed its self-test and is reverted to revision %s: %v", canary.Revision, canary.OldRevision, reason)
+	}
+	return nil
+}
+
+// checkSnapdCanary returns a Retry error if the snapd revision linked by
+// the change of the given task did not pass its self-test yet. A revision
+// that failed it is waited on as well, until the snapd failure handling
+// rolled it back.
+func checkSnapdCanary(task *state.Task) error {
+	chg := task.Change()
+	if chg == nil {
+		return nil
+	}
+	canary, err := SnapdCanaryInfo(task.State())
+	if err != nil {
+		return err
+	}
+	if canary == nil || canary.Status == SnapdCanaryGood || canary.ChangeID != chg.ID() {
+		return nil
+	}
+	if canary.Status == SnapdCanaryTesting {
+		task.Logf("Waiting for snapd revision %s to pass its self-test...", canary.Revision)
+	}
+	return &state.Retry{}
+}
+
+// snapdRollbackError returns the error for the change of the given task
+// once the snapd revision it linked was rolled back across the restart,
+// recording that the revision failed if it was being tested.
+func snapdRollbackError(task *state.Task) error {
+	st := task.State()
+	if err := MarkSnapdRevisionFailed(st, errors.New("cannot start snapd")); err != nil {
+		return err
+	}
+	canary, err := SnapdCanaryInfo(st)
+	if err != nil {
+		return err
+	}
+	if chg := task.Change(); canary != nil && chg != nil && canary.ChangeID == chg.ID() && canary.Reason != "" {
+		return fmt.Errorf("there was a snapd rollback across the restart: %s", canary.Reason)
+	}
+	return fmt.Errorf("there was a snapd rollback across the restart")
+}
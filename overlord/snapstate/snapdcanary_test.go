@@ -0,0 +1,163 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"os"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+func (s *snapmgrTestSuite) TestUpdateSnapdStartsCanary(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "snapd", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "snapd", SnapID: "snapd-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "snapd",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh snapd")
+	ts, err := snapstate.Update(s.state, "snapd", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	canary, err := snapstate.SnapdCanaryInfo(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(canary, NotNil)
+	c.Check(canary.Revision, Equals, snap.R(11))
+	c.Check(canary.OldRevision, Equals, snap.R(1))
+	c.Check(canary.ChangeID, Equals, chg.ID())
+	c.Check(canary.Status, Equals, snapstate.SnapdCanaryTesting)
+}
+
+func (s *snapmgrTestSuite) setupSnapdCanary(c *C) (*state.Task, *snapstate.SnapSetup) {
+	r := release.MockOnClassic(true)
+	s.AddCleanup(r)
+
+	si := &snap.SideInfo{RealName: "snapd", Revision: snap.R(2)}
+	snaptest.MockSnapCurrent(c, "name: snapd\ntype: snapd", si)
+	snapsup := &snapstate.SnapSetup{SideInfo: si, Type: snap.TypeSnapd}
+
+	chg := s.state.NewChange("refresh", "...")
+	link := s.state.NewTask("link-snap", "...")
+	chg.AddTask(link)
+	task := s.state.NewTask("auto-connect", "...")
+	chg.AddTask(task)
+
+	s.state.Set("snapd-canary", &snapstate.SnapdCanary{
+		Revision:    snap.R(2),
+		OldRevision: snap.R(1),
+		ChangeID:    chg.ID(),
+		Status:      snapstate.SnapdCanaryTesting,
+	})
+	restart.MockPending(s.state, restart.RestartUnset)
+	return task, snapsup
+}
+
+func (s *snapmgrTestSuite) TestFinishRestartWaitsForSnapdCanary(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	task, snapsup := s.setupSnapdCanary(c)
+
+	err := snapstate.FinishRestart(task, snapsup)
+	c.Check(err, FitsTypeOf, &state.Retry{})
+	c.Check(task.Log(), HasLen, 1)
+	c.Check(task.Log()[0], Matches, `.* Waiting for snapd revision 2 to pass its self-test...`)
+
+	c.Assert(snapstate.MarkSnapdRevisionGood(s.state), IsNil)
+	canary, err := snapstate.SnapdCanaryInfo(s.state)
+	c.Assert(err, IsNil)
+	c.Check(canary.Status, Equals, snapstate.SnapdCanaryGood)
+
+	c.Check(snapstate.FinishRestart(task, snapsup), IsNil)
+}
+
+func (s *snapmgrTestSuite) TestFinishRestartWaitsForRollbackOfFailedSnapdCanary(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	task, snapsup := s.setupSnapdCanary(c)
+
+	c.Assert(snapstate.MarkSnapdRevisionFailed(s.state, errors.New("cannot query API: boom")), IsNil)
+	canary, err := snapstate.SnapdCanaryInfo(s.state)
+	c.Assert(err, IsNil)
+	c.Check(canary.Status, Equals, snapstate.SnapdCanaryFailed)
+	c.Check(canary.Reason, Equals, "cannot query API: boom")
+	warns := s.state.AllWarnings()
+	c.Assert(warns, HasLen, 1)
+	c.Check(warns[0].String(), Equals, `snapd revision 2 failed its self-test and is reverted to revision 1: cannot query API: boom`)
+
+	// the failed revision does not carry on
+	err = snapstate.FinishRestart(task, snapsup)
+	c.Check(err, FitsTypeOf, &state.Retry{})
+
+	// the previous revision reports the rollback
+	os.Setenv("SNAPD_REVERT_TO_REV", "1")
+	defer os.Unsetenv("SNAPD_REVERT_TO_REV")
+	err = snapstate.FinishRestart(task, snapsup)
+	c.Check(err, ErrorMatches, `there was a snapd rollback across the restart: cannot query API: boom`)
+}
+
+func (s *snapmgrTestSuite) TestFinishRestartSnapdRollbackMarksCanaryFailed(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	task, snapsup := s.setupSnapdCanary(c)
+
+	os.Setenv("SNAPD_REVERT_TO_REV", "1")
+	defer os.Unsetenv("SNAPD_REVERT_TO_REV")
+	err := snapstate.FinishRestart(task, snapsup)
+	c.Check(err, ErrorMatches, `there was a snapd rollback across the restart: cannot start snapd`)
+
+	canary, err := snapstate.SnapdCanaryInfo(s.state)
+	c.Assert(err, IsNil)
+	c.Check(canary.Status, Equals, snapstate.SnapdCanaryFailed)
+	c.Check(s.state.AllWarnings(), HasLen, 1)
+}
+
+func (s *snapmgrTestSuite) TestMarkSnapdRevisionNoCanary(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(snapstate.MarkSnapdRevisionGood(s.state), IsNil)
+	c.Assert(snapstate.MarkSnapdRevisionFailed(s.state, errors.New("boom")), IsNil)
+
+	canary, err := snapstate.SnapdCanaryInfo(s.state)
+	c.Assert(err, IsNil)
+	c.Check(canary, IsNil)
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+}
@@ -64,6 +64,7 @@ type SnapManager struct {
 	autoRefresh    *autoRefresh
 	refreshHints   *refreshHints
 	catalogRefresh *catalogRefresh
+	tryWatchers    *tryWatchers
 
 	preseed bool
 }
@@ -104,6 +105,10 @@ type SnapSetup struct {
 	SideInfo     *snap.SideInfo     `json:"side-info,omitempty"`
 	auxStoreInfo
 
+	// VerifiedSha3_384 is the SHA3-384 digest of the blob at SnapPath, as
+	// verified while it was being downloaded.
+	VerifiedSha3_384 string `json:"verified-sha3-384,omitempty"`
+
 	// InstanceKey is set by the user during installation and differs for
 	// each instance of given snap
 	InstanceKey string `json:"instance-key,omitempty"`
@@ -210,6 +215,7 @@ type SnapState struct {
 	Aliases             map[string]*AliasTarget `json:"aliases,omitempty"`
 	AutoAliasesDisabled bool                    `json:"auto-aliases-disabled,omitempty"`
 	AliasesPending      bool                    `json:"aliases-pending,omitempty"`
+	DisabledAliasSets   []string                `json:"disabled-alias-sets,omitempty"`
 
 	// UserID of the user requesting the install
 	UserID int `json:"user-id,omitempty"`
@@ -227,6 +233,10 @@ type SnapState struct {
 	// LastRefreshTime records the time when the snap was last refreshed.
 	LastRefreshTime *time.Time `json:"last-refresh-time,omitempty"`
 
+	// RefreshWindow is the maintenance window set by the snap with
+	// snapctl, it takes precedence over the one declared in snap.yaml.
+	RefreshWindow string `json:"refresh-window,omitempty"`
+
 	// MigratedHidden is set if the user's snap dir has been migrated
 	// to ~/.snap/data.
 	MigratedHidden bool `json:"migrated-hidden,omitempty"`
@@ -479,6 +489,7 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 		autoRefresh:    newAutoRefresh(st),
 		refreshHints:   newRefreshHints(st),
 		catalogRefresh: newCatalogRefresh(st),
+		tryWatchers:    newTryWatchers(st),
 		preseed:        preseed,
 	}
 	if preseed {
@@ -500,6 +511,8 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 		return nil
 	}, nil)
 
+	runner.AddErrorClassifier(classifyTaskError)
+
 	// install/update related
 
 	// TODO: no undo handler here, we may use the GC for this and just
@@ -507,10 +520,13 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 	runner.AddHandler("prerequisites", m.doPrerequisites, nil)
 	runner.AddHandler("prepare-snap", m.doPrepareSnap, m.undoPrepareSnap)
 	runner.AddHandler("download-snap", m.doDownloadSnap, m.undoPrepareSnap)
+	runner.AddHandler("prefetch-snap", m.doPrefetchSnap, m.undoPrefetchSnap)
 	runner.AddHandler("mount-snap", m.doMountSnap, m.undoMountSnap)
 	runner.AddHandler("unlink-current-snap", m.doUnlinkCurrentSnap, m.undoUnlinkCurrentSnap)
 	runner.AddHandler("copy-snap-data", m.doCopySnapData, m.undoCopySnapData)
 	runner.AddCleanup("copy-snap-data", m.cleanupCopySnapData)
+	// these check for abort while running, see state.Checkpoint
+	runner.AddAbortable("download-snap", "prefetch-snap", "mount-snap", "copy-snap-data")
 	runner.AddHandler("link-snap", m.doLinkSnap, m.undoLinkSnap)
 	runner.AddHandler("start-snap-services", m.startSnapServices, m.undoStartSnapServices)
 	runner.AddHandler("switch-snap-channel", m.doSwitchSnapChannel, nil)
@@ -540,10 +556,17 @@ func Manager(st *state.State, runner *state.TaskRunner) (*SnapManager, error) {
 	runner.AddHandler("unalias", m.doUnalias, m.undoRefreshAliases)
 	runner.AddHandler("disable-aliases", m.doDisableAliases, m.undoRefreshAliases)
 	runner.AddHandler("prefer-aliases", m.doPreferAliases, m.undoRefreshAliases)
+	runner.AddHandler("enable-alias-set", m.doToggleAliasSet, m.undoToggleAliasSet)
+	runner.AddHandler("disable-alias-set", m.doToggleAliasSet, m.undoToggleAliasSet)
 
 	// misc
 	runner.AddHandler("switch-snap", m.doSwitchSnap, nil)
 	runner.AddHandler("migrate-snap-home", m.doMigrateSnapHome, m.undoMigrateSnapHome)
+
+	// migration to a renamed successor
+	runner.AddHandler("migrate-snap-data", m.doMigrateSnapData, m.undoMigrateSnapData)
+	runner.AddHandler("transfer-aliases", m.doTransferAliases, m.undoRefreshAliases)
+
 	// no undo for now since it's last task in valset auto-resolution change
 	runner.AddHandler("enforce-validation-sets", m.doEnforceValidationSets, nil)
 
@@ -564,6 +587,9 @@ func (m *SnapManager) StartUp() error {
 	if err := m.SyncCookies(m.state); err != nil {
 		return fmt.Errorf("failed to generate cookies: %q", err)
 	}
+	if err := thawFrozenSnaps(m.state); err != nil {
+		return fmt.Errorf("cannot thaw snaps left frozen: %v", err)
+	}
 	return nil
 }
 
@@ -1067,6 +1093,20 @@ func (m *SnapManager) localInstallCleanup() error {
 	return osutil.UnlinkManyAt(d, filenames)
 }
 
+// ensureWarmCachePruned forgets the snaps kept in the cache on removal once
+// they expire, it checks for expired entries at most once a day.
+func (m *SnapManager) ensureWarmCachePruned() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	now := time.Now()
+	if warmCacheLastPruneCheck.After(now.Add(-warmCachePruneWait)) {
+		return nil
+	}
+	warmCacheLastPruneCheck = now
+	return pruneWarmCache(m.state)
+}
+
 // Ensure implements StateManager.Ensure.
 func (m *SnapManager) Ensure() error {
 	if m.preseed {
@@ -1086,7 +1126,11 @@ func (m *SnapManager) Ensure() error {
 		m.refreshHints.Ensure(),
 		m.catalogRefresh.Ensure(),
 		m.localInstallCleanup(),
+		m.ensureWarmCachePruned(),
+		m.cleanupSpaceReservations(),
 		m.ensureVulnerableSnapConfineVersionsRemovedOnClassic(),
+		m.ensureRefreshHealth(),
+		m.ensureTryWatches(),
 	}
 
 	//FIXME: use firstErr helper
@@ -1098,3 +1142,9 @@ func (m *SnapManager) Ensure() error {
 
 	return nil
 }
+
+// Stop implements StateStopper. It stops watching the directories of tried
+// snaps.
+func (m *SnapManager) Stop() {
+	m.tryWatchers.stopAllExcept(nil)
+}
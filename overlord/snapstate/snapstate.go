@@ -227,12 +227,21 @@ type InsufficientSpaceError struct {
 	ChangeKind string
 	// Message is optional, otherwise one is composed from the other information
 	Message string
+	// Shortfalls details the missing space per filesystem, if known
+	Shortfalls []SpaceShortfall
 }
 
 func (e *InsufficientSpaceError) Error() string {
 	if e.Message != "" {
 		return e.Message
 	}
+	if len(e.Shortfalls) > 0 {
+		details := make([]string, len(e.Shortfalls))
+		for i, sf := range e.Shortfalls {
+			details[i] = fmt.Sprintf("at least %s more is required in %q", strutil.SizeToStr(int64(sf.Missing)), sf.Path)
+		}
+		return fmt.Sprintf("insufficient space to perform %q change for the following snaps: %s (%s)", e.ChangeKind, strings.Join(e.Snaps, ", "), strings.Join(details, ", "))
+	}
 	if len(e.Snaps) > 0 {
 		snaps := strings.Join(e.Snaps, ", ")
 		return fmt.Sprintf("insufficient space in %q to perform %q change for the following snaps: %s", e.Path, e.ChangeKind, snaps)
@@ -517,9 +526,10 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	addTask(setupAliases)
 	prev = setupAliases
 
-	if isCoreBoot && snapsup.Type == snap.TypeSnapd {
+	if isCoreBoot && (snapsup.Type == snap.TypeSnapd || snapsup.Type == snap.TypeGadget) {
 		// only run for core devices and the snapd snap, run late enough
-		// so that the task is executed by the new snapd
+		// so that the task is executed by the new snapd; the gadget snap
+		// may carry boot config assets superseding the built-in ones
 		bootConfigUpdate := st.NewTask("update-managed-boot-config", fmt.Sprintf(i18n.G("Update managed boot config assets from %q%s"), snapsup.InstanceName(), revisionStr))
 		addTask(bootConfigUpdate)
 		prev = bootConfigUpdate
@@ -550,6 +560,11 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 
 	// run new services
 	startSnapServices := st.NewTask("start-snap-services", fmt.Sprintf(i18n.G("Start snap %q%s services"), snapsup.InstanceName(), revisionStr))
+	if snapst.IsInstalled() {
+		// services using socket handoff were left running by
+		// stop-snap-services, hand them off to the new revision
+		startSnapServices.Set("handoff", true)
+	}
 	addTask(startSnapServices)
 	prev = startSnapServices
 
@@ -729,7 +744,11 @@ func FinishRestart(task *state.Task, snapsup *SnapSetup) (err error) {
 
 	if snapsup.Type == snap.TypeSnapd {
 		if os.Getenv("SNAPD_REVERT_TO_REV") != "" {
-			return fmt.Errorf("there was a snapd rollback across the restart")
+			return snapdRollbackError(task)
+		}
+		// wait for the new snapd to pass its self-test
+		if err := checkSnapdCanary(task); err != nil {
+			return err
 		}
 
 		// if we have restarted and snapd was refreshed, then we need to generate
@@ -820,8 +839,13 @@ func FinishRestart(task *state.Task, snapsup *SnapSetup) (err error) {
 // from the caller.
 // It delegates the work to restart.FinishTaskWithRestart which can decide
 // to set the task to wait returning state.Wait.
-func FinishTaskWithRestart(task *state.Task, status state.Status, rt restart.RestartType, rebootInfo *boot.RebootInfo) error {
+// The reason code is recorded for system restarts, it is replaced by
+// restart.RebootReasonRemodel if the task is part of a remodel.
+func FinishTaskWithRestart(task *state.Task, status state.Status, rt restart.RestartType, code restart.RebootReasonCode, rebootInfo *boot.RebootInfo) error {
 	var rebootRequiredSnap string
+	if chg := task.Change(); chg != nil && chg.Kind() == "remodel" {
+		code = restart.RebootReasonRemodel
+	}
 	// If system restart is requested, consider how the change the
 	// task belongs to is configured (system-restart-immediate) to
 	// choose whether request an immediate restart or not.
@@ -845,8 +869,14 @@ func FinishTaskWithRestart(task *state.Task, status state.Status, rt restart.Res
 			rt = restart.RestartSystemNow
 		}
 	}
+	if rt == restart.RestartDaemon {
+		// only used to report the cause of the restart
+		if snapsup, err := TaskSnapSetup(task); err == nil {
+			rebootRequiredSnap = snapsup.InstanceName()
+		}
+	}
 
-	return restart.FinishTaskWithRestart(task, status, rt, rebootRequiredSnap, rebootInfo)
+	return restart.FinishTaskWithRestart(task, status, rt, code, rebootRequiredSnap, rebootInfo)
 }
 
 // IsErrAndNotWait returns true if err is not nil and neither state.Wait, it is
@@ -1164,6 +1194,19 @@ func InstallWithDeviceContext(ctx context.Context, st *state.State, name string,
 		return nil, fmt.Errorf("invalid instance name: %v", err)
 	}
 
+	if !opts.Revision.Unset() {
+		// reinstall a revision kept in the cache on removal without
+		// talking to the store
+		path, si, err := warmCacheLookup(st, name, opts.Revision, deviceCtx)
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			ts, _, err := InstallPath(st, si, path, name, opts.Channel, flags)
+			return ts, err
+		}
+	}
+
 	sar, err := installInfo(ctx, st, name, opts, userID, flags, deviceCtx)
 	if err != nil {
 		return nil, err
@@ -1622,6 +1665,13 @@ func updateManyFiltered(ctx context.Context, st *state.State, names []string, re
 		updates = actual
 	}
 
+	if flags.IsAutoRefresh && len(names) == 0 {
+		updates, err = deferLargeRefreshesOnMetered(st, updates)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	if ValidateRefreshes != nil && len(updates) != 0 {
 		updates, err = ValidateRefreshes(st, updates, ignoreValidation, userID, deviceCtx)
 		if err != nil {
@@ -1661,15 +1711,43 @@ func updateManyFiltered(ctx context.Context, st *state.State, names []string, re
 		return nil, nil, err
 	}
 
+	// snaps renamed in the store are migrated to their successor rather
+	// than refreshed
+	var migrations []*snap.Info
+	toRefresh := make([]minimalInstallInfo, 0, len(toUpdate))
+	for _, up := range toUpdate {
+		if info, ok := up.(installSnapInfo); ok && info.Successor != "" {
+			migrations = append(migrations, info.Info)
+			continue
+		}
+		toRefresh = append(toRefresh, up)
+	}
+	toUpdate = toRefresh
+
 	updated, tasksets, err := doUpdate(ctx, st, names, toUpdate, params, userID, flags, deviceCtx, fromChange)
 	if err != nil {
 		return nil, nil, err
 	}
+	for _, info := range migrations {
+		tss, err := MigrateToSuccessor(ctx, st, info.InstanceName(), info.Successor, userID)
+		if err != nil {
+			// not doing "refresh all", report the error
+			if len(names) != 0 {
+				return nil, nil, err
+			}
+			// doing "refresh all", log the problem
+			logger.Noticef("cannot migrate snap %q to its successor %q: %v", info.InstanceName(), info.Successor, err)
+			continue
+		}
+		tasksets = append(tasksets, tss...)
+		updated = append(updated, info.InstanceName())
+	}
 	tasksets = finalizeUpdate(st, tasksets, len(updates) > 0, updated, userID, flags)
 	return updated, tasksets, nil
 }
 
 // filterHeldSnaps filters held snaps from being updated in a general refresh.
+// In an auto-refresh, snaps outside of their refresh window are filtered too.
 func filterHeldSnaps(st *state.State, updates []minimalInstallInfo, flags *Flags) ([]minimalInstallInfo, error) {
 	holdLevel := HoldGeneral
 	if flags.IsAutoRefresh {
@@ -1680,11 +1758,27 @@ func filterHeldSnaps(st *state.State, updates []minimalInstallInfo, flags *Flags
 		return nil, err
 	}
 
+	var outsideWindow []string
 	filteredUpdates := make([]minimalInstallInfo, 0, len(updates))
 	for _, update := range updates {
-		if !heldSnaps[update.InstanceName()] {
-			filteredUpdates = append(filteredUpdates, update)
+		if heldSnaps[update.InstanceName()] {
+			continue
 		}
+		if flags.IsAutoRefresh {
+			outside, err := outsideRefreshWindow(st, update.InstanceName())
+			if err != nil {
+				return nil, err
+			}
+			if outside {
+				outsideWindow = append(outsideWindow, update.InstanceName())
+				continue
+			}
+		}
+		filteredUpdates = append(filteredUpdates, update)
+	}
+
+	if len(outsideWindow) > 0 {
+		logger.Noticef("skipping auto-refresh of snaps outside of their refresh window: %s", strutil.Quoted(outsideWindow))
 	}
 
 	return filteredUpdates, nil
@@ -2445,6 +2539,13 @@ func autoRefreshPhase1(ctx context.Context, st *state.State, forGatingSnap strin
 		return nil, nil, err
 	}
 
+	// large downloads may need to wait for a non-metered connection, they
+	// are kept as refresh candidates nonetheless
+	candidates, err = deferLargeRefreshesOnMetered(st, candidates)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	updates := make([]string, 0, len(hints))
 
 	// check conflicts
@@ -2591,6 +2692,7 @@ func autoRefreshPhase2(ctx context.Context, st *state.State, updates []*refreshC
 }
 
 // checkDiskSpace checks if there is enough space for the requested snaps and their prerequisites
+// and reserves it until the tasks of the change use it.
 func checkDiskSpace(st *state.State, changeKind string, infos []minimalInstallInfo, userID int) error {
 	var featFlag features.SnapdFeature
 
@@ -2618,21 +2720,61 @@ func checkDiskSpace(st *state.State, changeKind string, infos []minimalInstallIn
 		return err
 	}
 
-	requiredSpace := safetyMarginDiskSpace(totalSize)
+	plan := newDiskSpacePlan()
+	// the snaps are downloaded into the blob directory, the space of the
+	// prerequisites is accounted to the first snap
 	path := dirs.SnapdStateDir(dirs.GlobalRootDir)
-	if err := osutilCheckFreeSpace(path, requiredSpace); err != nil {
+	remaining := totalSize
+	for i := len(infos) - 1; i >= 0; i-- {
+		sz := uint64(infos[i].DownloadSize())
+		if sz > remaining || i == 0 {
+			sz = remaining
+		}
+		plan.add(path, reservationKey{infos[i].InstanceName(), downloadReservation}, sz)
+		remaining -= sz
+	}
+	if changeKind == "refresh" {
+		// the data of the current revisions is copied for the new ones
+		var copies []dataCopy
+		for _, info := range infos {
+			cps, err := dataCopies(st, info.InstanceName())
+			if err != nil {
+				return err
+			}
+			copies = append(copies, cps...)
+		}
+		// sizing the data does not need the state, and can take a
+		// while
+		st.Unlock()
+		err := plan.addDataCopies(copies)
+		st.Lock()
+		if err != nil {
+			return err
+		}
+	}
+
+	shortfalls, err := plan.check()
+	if err != nil {
+		return err
+	}
+	if len(shortfalls) == 0 {
+		// the space could still be taken by the time it is needed
+		shortfalls, err = plan.reserve(st)
+		if err != nil {
+			return err
+		}
+	}
+	if len(shortfalls) > 0 {
 		snaps := make([]string, len(infos))
 		for i, up := range infos {
 			snaps[i] = up.InstanceName()
 		}
-		if _, ok := err.(*osutil.NotEnoughDiskSpaceError); ok {
-			return &InsufficientSpaceError{
-				Path:       path,
-				Snaps:      snaps,
-				ChangeKind: changeKind,
-			}
+		return &InsufficientSpaceError{
+			Path:       shortfalls[0].Path,
+			Snaps:      snaps,
+			ChangeKind: changeKind,
+			Shortfalls: shortfalls,
 		}
-		return err
 	}
 
 	return nil
@@ -3033,15 +3175,30 @@ func canDisable(si *snap.Info) bool {
 	return true
 }
 
-// canRemove verifies that a snap can be removed.
-func canRemove(st *state.State, si *snap.Info, snapst *SnapState, removeAll bool, deviceCtx DeviceContext) error {
+// canRemove verifies that a snap can be removed, together with the snaps in
+// removing if any.
+func canRemove(st *state.State, si *snap.Info, snapst *SnapState, removeAll bool, removing map[string]bool, deviceCtx DeviceContext) error {
 	rev := snap.Revision{}
 	if !removeAll {
 		rev = si.Revision
 	}
 
 	if err := PolicyFor(si.Type(), deviceCtx.Model()).CanRemove(st, snapst, rev, deviceCtx); err != nil {
-		return err
+		usedBy, ok := err.(interface{ UsedBy() []string })
+		if !ok {
+			return err
+		}
+		// the snap is in use by other snaps, which is fine as long as
+		// those are removed first
+		if err := dependentsError(si.InstanceName(), usedBy.UsedBy(), removing); err != nil {
+			return err
+		}
+	}
+
+	if removeAll {
+		if err := checkDependents(st, si.InstanceName(), removing); err != nil {
+			return err
+		}
 	}
 
 	// check if this snap is required by any validation set in enforcing mode
@@ -3084,12 +3241,22 @@ func canRemove(st *state.State, si *snap.Info, snapst *SnapState, removeAll bool
 type RemoveFlags struct {
 	// Remove the snap without creating snapshot data
 	Purge bool
+	// Keep the blob of the current revision of the snap in the cache so
+	// that reinstalling the same revision does not need the store
+	KeepCache bool
+	// Cascade removes the snaps that need the removed snaps as well,
+	// instead of refusing to remove snaps that are still needed. It is
+	// only supported by RemoveMany.
+	Cascade bool
 }
 
 // Remove returns a set of tasks for removing snap.
 // Note that the state must be locked by the caller.
 func Remove(st *state.State, name string, revision snap.Revision, flags *RemoveFlags) (*state.TaskSet, error) {
-	ts, snapshotSize, err := removeTasks(st, name, revision, flags)
+	if flags != nil && flags.Cascade {
+		return nil, fmt.Errorf("internal error: cascading removal is only supported by RemoveMany")
+	}
+	ts, snapshotSize, err := removeTasks(st, name, revision, flags, nil)
 	// removeTasks() checks check-disk-space-remove feature flag, so snapshotSize
 	// will only be greater than 0 if the feature is enabled.
 	if snapshotSize > 0 {
@@ -3111,7 +3278,8 @@ func Remove(st *state.State, name string, revision snap.Revision, flags *RemoveF
 
 // removeTasks provides the task set to remove snap name after taking a snapshot
 // if flags.Purge is not true, it also computes an estimate of the latter size.
-func removeTasks(st *state.State, name string, revision snap.Revision, flags *RemoveFlags) (removeTs *state.TaskSet, snapshotSize uint64, err error) {
+// The snaps in removing are removed as part of the same change, before name.
+func removeTasks(st *state.State, name string, revision snap.Revision, flags *RemoveFlags, removing map[string]bool) (removeTs *state.TaskSet, snapshotSize uint64, err error) {
 	var snapst SnapState
 	err = Get(st, name, &snapst)
 	if err != nil && !errors.Is(err, state.ErrNoState) {
@@ -3161,7 +3329,10 @@ func removeTasks(st *state.State, name string, revision snap.Revision, flags *Re
 	}
 
 	// check if this is something that can be removed
-	if err := canRemove(st, info, &snapst, removeAll, deviceCtx); err != nil {
+	if err := canRemove(st, info, &snapst, removeAll, removing, deviceCtx); err != nil {
+		if _, ok := err.(*DependentSnapsError); ok {
+			return nil, 0, err
+		}
 		return nil, 0, fmt.Errorf("snap %q is not removable: %v", name, err)
 	}
 
@@ -3272,10 +3443,18 @@ func removeTasks(st *state.State, name string, revision snap.Revision, flags *Re
 		// add tasks for removing the current revision last,
 		// this is then also when common data will be removed
 		if currentIndex >= 0 {
-			addNext(removeInactiveRevision(st, name, info.SnapID, seq[currentIndex].Revision, snapsup.Type))
+			ts := removeInactiveRevision(st, name, info.SnapID, seq[currentIndex].Revision, snapsup.Type)
+			if flags != nil && flags.KeepCache {
+				markKeepCache(ts)
+			}
+			addNext(ts)
 		}
 	} else {
-		addNext(removeInactiveRevision(st, name, info.SnapID, revision, snapsup.Type))
+		ts := removeInactiveRevision(st, name, info.SnapID, revision, snapsup.Type)
+		if removeAll && flags != nil && flags.KeepCache {
+			markKeepCache(ts)
+		}
+		addNext(ts)
 	}
 
 	return removeTs, snapshotSize, nil
@@ -3303,6 +3482,16 @@ func removeInactiveRevision(st *state.State, name, snapID string, revision snap.
 	return state.NewTaskSet(clearData, discardSnap)
 }
 
+// markKeepCache flags the discard-snap task of the given task set to keep the
+// blob of the discarded revision in the cache.
+func markKeepCache(ts *state.TaskSet) {
+	for _, t := range ts.Tasks() {
+		if t.Kind() == "discard-snap" {
+			t.Set("keep-cache", true)
+		}
+	}
+}
+
 // RemoveMany removes everything from the given list of names.
 // Note that the state must be locked by the caller.
 func RemoveMany(st *state.State, names []string, flags *RemoveFlags) ([]string, []*state.TaskSet, error) {
@@ -3312,24 +3501,39 @@ func RemoveMany(st *state.State, names []string, flags *RemoveFlags) ([]string,
 		return nil, nil, err
 	}
 
-	removed := make([]string, 0, len(names))
-	tasksets := make([]*state.TaskSet, 0, len(names))
+	// snaps that are not installed are left out of the plan
+	// FIXME: is this expected behavior?
+	plan, dependents, err := removalPlan(st, names, flags != nil && flags.Cascade)
+	if err != nil {
+		return nil, nil, err
+	}
+	removing := make(map[string]bool, len(plan))
+	for _, name := range plan {
+		removing[name] = true
+	}
+
+	removed := make([]string, 0, len(plan))
+	tasksets := make([]*state.TaskSet, 0, len(plan))
+	removeTss := make(map[string]*state.TaskSet, len(plan))
 
 	var totalSnapshotsSize uint64
 	path := dirs.SnapdStateDir(dirs.GlobalRootDir)
 
-	for _, name := range names {
-		ts, snapshotSize, err := removeTasks(st, name, snap.R(0), flags)
-		// FIXME: is this expected behavior?
-		if _, ok := err.(*snap.NotInstalledError); ok {
-			continue
-		}
+	for _, name := range plan {
+		ts, snapshotSize, err := removeTasks(st, name, snap.R(0), flags, removing)
 		if err != nil {
 			return nil, nil, err
 		}
 		totalSnapshotsSize += snapshotSize
 		removed = append(removed, name)
 		ts.JoinLane(st.NewLane())
+		// the snaps needing this one are removed first
+		for _, dep := range dependents[name] {
+			if depTs := removeTss[dep]; depTs != nil {
+				ts.WaitAll(depTs)
+			}
+		}
+		removeTss[name] = ts
 		tasksets = append(tasksets, ts)
 	}
 
@@ -3341,7 +3545,7 @@ func RemoveMany(st *state.State, names []string, flags *RemoveFlags) ([]string,
 			if _, ok := err.(*osutil.NotEnoughDiskSpaceError); ok {
 				return nil, nil, &InsufficientSpaceError{
 					Path:       path,
-					Snaps:      names,
+					Snaps:      removed,
 					ChangeKind: "remove",
 				}
 			}
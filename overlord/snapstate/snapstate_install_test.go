@@ -66,7 +66,7 @@ func expectedDoInstallTasks(typ snap.Type, opts, discards int, startTasks []stri
 	if !release.OnClassic {
 		switch typ {
 		case snap.TypeGadget:
-			opts |= updatesGadget
+			opts |= updatesGadget | updatesBootConfig
 		case snap.TypeKernel:
 			opts |= updatesGadgetAssets
 		case snap.TypeSnapd:
@@ -560,6 +560,24 @@ func (s *snapmgrTestSuite) TestInstallFailsOnSystem(c *C) {
 	c.Assert(err, ErrorMatches, `cannot install reserved snap name 'system'`)
 }
 
+func (s *snapmgrTestSuite) TestInstallTasksStartServicesNoHandoff(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", nil, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	var start *state.Task
+	for _, t := range ts.Tasks() {
+		if t.Kind() == "start-snap-services" {
+			start = t
+		}
+	}
+	c.Assert(start, NotNil)
+	var handoff bool
+	c.Check(start.Get("handoff", &handoff), testutil.ErrorIs, state.ErrNoState)
+}
+
 func (s *snapmgrTestSuite) TestDoInstallChannelDefault(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -2319,7 +2337,8 @@ func (s *snapmgrTestSuite) TestInstallWithoutCoreRunThrough1(c *C) {
 	// ensure all our tasks ran
 	c.Assert(chg.Err(), IsNil)
 	c.Assert(chg.IsReady(), Equals, true)
-	c.Check(s.fakeStore.downloads, DeepEquals, []fakeDownload{
+	// the snap is downloaded while core gets installed
+	c.Check(s.fakeStore.downloads, testutil.DeepUnsortedMatches, []fakeDownload{
 		{
 			macaroon: s.user.StoreMacaroon,
 			name:     "core",
@@ -2366,6 +2385,10 @@ func (s *snapmgrTestSuite) TestInstallWithoutCoreRunThrough1(c *C) {
 			op:   "storesvc-download",
 			name: "core",
 		},
+		{
+			op:   "storesvc-download",
+			name: "some-snap",
+		},
 		{
 			op:    "validate-snap:Doing",
 			name:  "core",
@@ -2427,10 +2450,6 @@ func (s *snapmgrTestSuite) TestInstallWithoutCoreRunThrough1(c *C) {
 			op: "update-aliases",
 		},
 		// after core is in place continue with the snap
-		{
-			op:   "storesvc-download",
-			name: "some-snap",
-		},
 		{
 			op:    "validate-snap:Doing",
 			name:  "some-snap",
@@ -2506,7 +2525,10 @@ func (s *snapmgrTestSuite) TestInstallWithoutCoreRunThrough1(c *C) {
 	// compare the details without the cleanup tasks, the order is random
 	// as they run in parallel
 	opsLenWithoutCleanups := len(s.fakeBackend.ops) - 2
-	c.Assert(s.fakeBackend.ops[:opsLenWithoutCleanups], DeepEquals, expected[:opsLenWithoutCleanups])
+	c.Assert(s.fakeBackend.ops[:4], DeepEquals, expected[:4])
+	// the downloads run in parallel too
+	c.Assert(s.fakeBackend.ops[4:6], testutil.DeepUnsortedMatches, expected[4:6])
+	c.Assert(s.fakeBackend.ops[6:opsLenWithoutCleanups], DeepEquals, expected[6:opsLenWithoutCleanups])
 
 	// verify core in the system state
 	var snaps map[string]*snapstate.SnapState
@@ -2561,7 +2583,8 @@ func (s *snapmgrTestSuite) TestInstallWithoutCoreTwoSnapsRunThrough(c *C) {
 	if len(chg1.Tasks()) < len(chg2.Tasks()) {
 		chg1, chg2 = chg2, chg1
 	}
-	c.Assert(taskKinds(chg1.Tasks()), HasLen, 28)
+	// the snap is prefetched while core gets installed
+	c.Assert(taskKinds(chg1.Tasks()), HasLen, 29)
 	c.Assert(taskKinds(chg2.Tasks()), HasLen, 14)
 
 	// FIXME: add helpers and do a DeepEquals here for the operations
@@ -3002,7 +3025,7 @@ func (s *snapmgrTestSuite) TestInstallDiskSpaceError(c *C) {
 	opts := &snapstate.RevisionOptions{Channel: "some-channel"}
 	_, err := snapstate.Install(context.Background(), s.state, "some-snap", opts, s.user.ID, snapstate.Flags{})
 	diskSpaceErr := err.(*snapstate.InsufficientSpaceError)
-	c.Assert(diskSpaceErr, ErrorMatches, `insufficient space in .* to perform "install" change for the following snaps: some-snap`)
+	c.Assert(diskSpaceErr, ErrorMatches, `insufficient space to perform "install" change for the following snaps: some-snap \(at least 0B more is required in ".*/var/lib/snapd"\)`)
 	c.Check(diskSpaceErr.Path, Equals, filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd"))
 	c.Check(diskSpaceErr.Snaps, DeepEquals, []string{"some-snap"})
 }
@@ -3586,7 +3609,7 @@ func (s *snapmgrTestSuite) TestInstallManyDiskSpaceError(c *C) {
 
 	_, _, err := snapstate.InstallMany(s.state, []string{"one", "two"}, nil, 0, nil)
 	diskSpaceErr := err.(*snapstate.InsufficientSpaceError)
-	c.Assert(diskSpaceErr, ErrorMatches, `insufficient space in .* to perform "install" change for the following snaps: one, two`)
+	c.Assert(diskSpaceErr, ErrorMatches, `insufficient space to perform "install" change for the following snaps: one, two \(at least 0B more is required in ".*/var/lib/snapd"\)`)
 	c.Check(diskSpaceErr.Path, Equals, filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd"))
 	c.Check(diskSpaceErr.Snaps, DeepEquals, []string{"one", "two"})
 	c.Check(diskSpaceErr.ChangeKind, Equals, "install")
@@ -5070,7 +5093,7 @@ epoch: 1
 	_, err := snapstate.InstallPathMany(context.Background(), s.state, sideInfos, paths, 0, nil)
 	diskSpaceErr, ok := err.(*snapstate.InsufficientSpaceError)
 	c.Assert(ok, Equals, true)
-	c.Check(diskSpaceErr, ErrorMatches, `insufficient space in .* to perform "install" change for the following snaps: some-snap, other-snap`)
+	c.Check(diskSpaceErr, ErrorMatches, `insufficient space to perform "install" change for the following snaps: some-snap, other-snap \(at least 0B more is required in ".*/var/lib/snapd"\)`)
 	c.Check(diskSpaceErr.Path, Equals, filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd"))
 	c.Check(diskSpaceErr.Snaps, DeepEquals, snapNames)
 }
@@ -5614,3 +5637,57 @@ func (s *snapmgrTestSuite) TestInstallWithTransactionLaneForbidden(c *C) {
 	c.Assert(err, ErrorMatches, "transaction lane is unsupported in InstallWithDeviceContext")
 	c.Check(tss, IsNil)
 }
+
+func (s *snapmgrTestSuite) TestInstallWithRevisionFromWarmCache(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{
+		RealName: "some-snap",
+		SnapID:   "some-snap-id",
+		Revision: snap.R(42),
+	}
+	// mock a snap kept in the cache on removal
+	mockSnap := makeTestSnap(c, "name: some-snap\nversion: 1.0")
+	digest, size, err := asserts.SnapFileSHA3_384(mockSnap)
+	c.Assert(err, IsNil)
+	cached := filepath.Join(dirs.SnapDownloadCacheDir, digest)
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0700), IsNil)
+	c.Assert(os.Rename(mockSnap, cached), IsNil)
+	s.state.Set("warm-cache", map[string]interface{}{
+		"some-snap": map[string]interface{}{
+			"side-info": si,
+			"sha3-384":  digest,
+			"size":      size,
+		},
+	})
+	restore := snapstate.MockDeriveSideInfo(func(st *state.State, snapPath string, deviceCtx snapstate.DeviceContext) (*snap.SideInfo, error) {
+		c.Check(snapPath, Equals, cached)
+		return si, nil
+	})
+	defer restore()
+
+	opts := &snapstate.RevisionOptions{Revision: snap.R(42)}
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", opts, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	// the store was not involved
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+	for _, t := range ts.Tasks() {
+		c.Check(t.Kind(), Not(Equals), "download-snap")
+	}
+	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.SnapPath, Equals, cached)
+	c.Check(snapsup.SideInfo, DeepEquals, si)
+	c.Check(snapsup.RemoveSnapPath, Equals, false)
+
+	// other revisions come from the store
+	opts = &snapstate.RevisionOptions{Revision: snap.R(43)}
+	ts, err = snapstate.Install(context.Background(), s.state, "some-snap", opts, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	snapsup, err = snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.SnapPath, Equals, "")
+	c.Check(snapsup.DownloadInfo, NotNil)
+}
@@ -20,7 +20,9 @@
 package snapstate_test
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"time"
@@ -2048,3 +2050,461 @@ func (s *snapmgrTestSuite) TestRemoveManyWithPurge(c *C) {
 	}
 
 }
+
+func (s *snapmgrTestSuite) TestRemoveKeepCacheTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	makeTestSnaps(c, s.state)
+
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), &snapstate.RemoveFlags{KeepCache: true})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("remove", "remove a snap")
+	chg.AddAll(ts)
+
+	// only the current revision is kept
+	var kept []snap.Revision
+	for _, t := range ts.Tasks() {
+		if t.Kind() != "discard-snap" || !t.Has("keep-cache") {
+			continue
+		}
+		snapsup, err := snapstate.TaskSnapSetup(t)
+		c.Assert(err, IsNil)
+		kept = append(kept, snapsup.Revision())
+	}
+	c.Check(kept, DeepEquals, []snap.Revision{snap.R(1)})
+}
+
+func (s *snapmgrTestSuite) TestRemoveOneRevisionKeepCacheIgnored(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	makeTestSnaps(c, s.state)
+
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(2), &snapstate.RemoveFlags{KeepCache: true})
+	c.Assert(err, IsNil)
+	for _, t := range ts.Tasks() {
+		c.Check(t.Has("keep-cache"), Equals, false)
+	}
+}
+
+func (s *snapmgrTestSuite) TestRemoveKeepCacheRunThrough(c *C) {
+	si := snap.SideInfo{
+		SnapID:   "some-snap-id",
+		RealName: "some-snap",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:          true,
+		Sequence:        []*snap.SideInfo{&si},
+		Current:         si.Revision,
+		SnapType:        "app",
+		TrackingChannel: "latest/edge",
+	})
+	blob := snap.MountFile("some-snap", si.Revision)
+	c.Assert(os.MkdirAll(filepath.Dir(blob), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(blob, []byte("blob"), 0644), IsNil)
+	digest, _, err := asserts.SnapFileSHA3_384(blob)
+	c.Assert(err, IsNil)
+	auxInfo := filepath.Join(dirs.SnapAuxStoreInfoDir, "some-snap-id.json")
+	c.Assert(os.MkdirAll(dirs.SnapAuxStoreInfoDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(auxInfo, []byte(`{"store-url":"https://snapcraft.io/some-snap"}`), 0644), IsNil)
+
+	chg := s.state.NewChange("remove", "remove a snap")
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), &snapstate.RemoveFlags{KeepCache: true})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(snapstate.Get(s.state, "some-snap", &snapstate.SnapState{}), testutil.ErrorIs, state.ErrNoState)
+
+	// the blob was kept in the download cache
+	cached := filepath.Join(dirs.SnapDownloadCacheDir, digest)
+	c.Check(cached, testutil.FileEquals, "blob")
+	// and so was the auxiliary store info
+	c.Check(auxInfo, testutil.FilePresent)
+
+	restore := snapstate.MockDeriveSideInfo(func(st *state.State, snapPath string, deviceCtx snapstate.DeviceContext) (*snap.SideInfo, error) {
+		c.Check(snapPath, Equals, cached)
+		return &si, nil
+	})
+	defer restore()
+
+	path, cachedSi, err := snapstate.WarmCacheLookup(s.state, "some-snap", snap.R(7), nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, cached)
+	c.Check(cachedSi, DeepEquals, &si)
+
+	// other revisions are not found
+	path, cachedSi, err = snapstate.WarmCacheLookup(s.state, "some-snap", snap.R(8), nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, "")
+	c.Check(cachedSi, IsNil)
+
+	// the entry is dropped once the blob is cleaned up from the cache
+	c.Assert(os.Remove(cached), IsNil)
+	path, _, err = snapstate.WarmCacheLookup(s.state, "some-snap", snap.R(7), nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, "")
+	var entries map[string]interface{}
+	c.Assert(s.state.Get("warm-cache", &entries), IsNil)
+	c.Check(entries, HasLen, 0)
+	c.Check(auxInfo, testutil.FileAbsent)
+}
+
+func (s *snapmgrTestSuite) mockWarmCacheEntry(c *C, si *snap.SideInfo, content string, age time.Duration) (cached, auxInfo string) {
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0700), IsNil)
+	cached = filepath.Join(dirs.SnapDownloadCacheDir, "some-digest")
+	c.Assert(ioutil.WriteFile(cached, []byte(content), 0644), IsNil)
+	auxInfo = filepath.Join(dirs.SnapAuxStoreInfoDir, si.SnapID+".json")
+	c.Assert(os.MkdirAll(dirs.SnapAuxStoreInfoDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(auxInfo, []byte(`{}`), 0644), IsNil)
+	s.state.Set("warm-cache", map[string]interface{}{
+		si.RealName: map[string]interface{}{
+			"side-info": si,
+			"sha3-384":  "some-digest",
+			"size":      len(content),
+			"time":      time.Now().Add(-age),
+		},
+	})
+	return cached, auxInfo
+}
+
+func (s *snapmgrTestSuite) TestWarmCacheLookupNotAsserted(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{
+		SnapID:   "some-snap-id",
+		RealName: "some-snap",
+		Revision: snap.R(7),
+	}
+	cached, auxInfo := s.mockWarmCacheEntry(c, si, "blob", 0)
+
+	// the blob does not match any snap-revision assertion
+	restore := snapstate.MockDeriveSideInfo(func(st *state.State, snapPath string, deviceCtx snapstate.DeviceContext) (*snap.SideInfo, error) {
+		return nil, &asserts.NotFoundError{Type: asserts.SnapRevisionType}
+	})
+	defer restore()
+
+	path, cachedSi, err := snapstate.WarmCacheLookup(s.state, "some-snap", snap.R(7), nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, "")
+	c.Check(cachedSi, IsNil)
+
+	// the entry and the untrusted blob are dropped
+	var entries map[string]interface{}
+	c.Assert(s.state.Get("warm-cache", &entries), IsNil)
+	c.Check(entries, HasLen, 0)
+	c.Check(cached, testutil.FileAbsent)
+	c.Check(auxInfo, testutil.FileAbsent)
+}
+
+func (s *snapmgrTestSuite) TestWarmCacheLookupOtherRevisionAsserted(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{
+		SnapID:   "some-snap-id",
+		RealName: "some-snap",
+		Revision: snap.R(7),
+	}
+	cached, _ := s.mockWarmCacheEntry(c, si, "blob", 0)
+
+	// the blob is the one of another revision
+	restore := snapstate.MockDeriveSideInfo(func(st *state.State, snapPath string, deviceCtx snapstate.DeviceContext) (*snap.SideInfo, error) {
+		return &snap.SideInfo{
+			SnapID:   "some-snap-id",
+			RealName: "some-snap",
+			Revision: snap.R(6),
+		}, nil
+	})
+	defer restore()
+
+	path, _, err := snapstate.WarmCacheLookup(s.state, "some-snap", snap.R(7), nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, "")
+	c.Check(cached, testutil.FileAbsent)
+}
+
+func (s *snapmgrTestSuite) TestWarmCacheLookupError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{
+		SnapID:   "some-snap-id",
+		RealName: "some-snap",
+		Revision: snap.R(7),
+	}
+	cached, _ := s.mockWarmCacheEntry(c, si, "blob", 0)
+
+	restore := snapstate.MockDeriveSideInfo(func(st *state.State, snapPath string, deviceCtx snapstate.DeviceContext) (*snap.SideInfo, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+
+	_, _, err := snapstate.WarmCacheLookup(s.state, "some-snap", snap.R(7), nil)
+	c.Assert(err, ErrorMatches, "boom")
+	// the entry is kept
+	var entries map[string]interface{}
+	c.Assert(s.state.Get("warm-cache", &entries), IsNil)
+	c.Check(entries, HasLen, 1)
+	c.Check(cached, testutil.FilePresent)
+}
+
+func (s *snapmgrTestSuite) TestEnsureWarmCachePruned(c *C) {
+	restore := snapstate.MockWarmCacheMaxAge(time.Hour)
+	defer restore()
+
+	s.state.Lock()
+	si := &snap.SideInfo{
+		SnapID:   "some-snap-id",
+		RealName: "some-snap",
+		Revision: snap.R(7),
+	}
+	cached, auxInfo := s.mockWarmCacheEntry(c, si, "blob", 2*time.Hour)
+	s.state.Unlock()
+
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	// the expired entry was dropped together with its blob
+	var entries map[string]interface{}
+	c.Assert(s.state.Get("warm-cache", &entries), IsNil)
+	c.Check(entries, HasLen, 0)
+	c.Check(cached, testutil.FileAbsent)
+	c.Check(auxInfo, testutil.FileAbsent)
+}
+
+func (s *snapmgrTestSuite) TestEnsureWarmCachePrunedKeepsRecentAndInUse(c *C) {
+	restore := snapstate.MockWarmCacheMaxAge(time.Hour)
+	defer restore()
+
+	s.state.Lock()
+	si := &snap.SideInfo{
+		SnapID:   "some-snap-id",
+		RealName: "some-snap",
+		Revision: snap.R(7),
+	}
+	cached, auxInfo := s.mockWarmCacheEntry(c, si, "blob", time.Minute)
+	s.state.Unlock()
+
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+
+	s.state.Lock()
+	// the recent entry is kept
+	var entries map[string]interface{}
+	c.Assert(s.state.Get("warm-cache", &entries), IsNil)
+	c.Check(entries, HasLen, 1)
+	c.Check(cached, testutil.FilePresent)
+	c.Check(auxInfo, testutil.FilePresent)
+
+	// once expired, a blob which is in use by an installed snap is kept
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	blob := snap.MountFile("some-snap", si.Revision)
+	c.Assert(os.MkdirAll(filepath.Dir(blob), 0755), IsNil)
+	c.Assert(os.Link(cached, blob), IsNil)
+	s.mockWarmCacheEntry(c, si, "blob", 2*time.Hour)
+	s.state.Unlock()
+
+	restore = snapstate.MockWarmCacheMaxAge(time.Hour)
+	defer restore()
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	entries = nil
+	c.Assert(s.state.Get("warm-cache", &entries), IsNil)
+	c.Check(entries, HasLen, 0)
+	c.Check(cached, testutil.FilePresent)
+	// the snap is installed, its auxiliary store info is in use
+	c.Check(auxInfo, testutil.FilePresent)
+}
+
+func (s *snapmgrTestSuite) TestRemoveKeepCacheUnassertedRunThrough(c *C) {
+	si := snap.SideInfo{
+		RealName: "some-snap",
+		Revision: snap.R("x1"),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("remove", "remove a snap")
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), &snapstate.RemoveFlags{KeepCache: true})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	path, _, err := snapstate.WarmCacheLookup(s.state, "some-snap", snap.R("x1"), nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, "")
+	c.Check(dirs.SnapDownloadCacheDir, testutil.FileAbsent)
+}
+
+func (s *snapmgrTestSuite) mockRemoveDependencies(c *C) {
+	s.fakeBackend.addSnapYaml("name: some-base\ntype: base\n")
+	s.fakeBackend.addSnapYaml(`name: content-provider
+base: some-base
+slots:
+  shared:
+    interface: content
+    content: shared-content
+`)
+	s.fakeBackend.addSnapYaml(`name: content-consumer
+base: some-base
+plugs:
+  shared:
+    interface: content
+    content: shared-content
+    target: $SNAP/shared
+    default-provider: content-provider
+`)
+	s.fakeBackend.addSnapYaml("name: unrelated\nbase: other-base\n")
+
+	for _, name := range []string{"some-base", "content-provider", "content-consumer", "unrelated"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, SnapID: name + "-id", Revision: snap.R(1)},
+			},
+			Current: snap.R(1),
+		})
+	}
+}
+
+func (s *snapmgrTestSuite) TestRemoveContentProviderBlocked(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.mockRemoveDependencies(c)
+
+	_, err := snapstate.Remove(s.state, "content-provider", snap.R(0), nil)
+	c.Assert(err, ErrorMatches, `snap "content-provider" is not removable: snap is needed by snap "content-consumer"`)
+	c.Check(err, DeepEquals, &snapstate.DependentSnapsError{
+		Snap:       "content-provider",
+		Dependents: []string{"content-consumer"},
+	})
+
+	_, err = snapstate.Remove(s.state, "some-base", snap.R(0), nil)
+	c.Assert(err, ErrorMatches, `snap "some-base" is not removable: snap is needed by snaps "content-consumer", "content-provider"`)
+
+	// nothing depends on the consumer
+	_, err = snapstate.Remove(s.state, "content-consumer", snap.R(0), nil)
+	c.Assert(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestRemoveCascadeUnsupported(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.mockRemoveDependencies(c)
+
+	_, err := snapstate.Remove(s.state, "content-provider", snap.R(0), &snapstate.RemoveFlags{Cascade: true})
+	c.Assert(err, ErrorMatches, "internal error: cascading removal is only supported by RemoveMany")
+}
+
+func (s *snapmgrTestSuite) TestRemoveManyBlockedByDependents(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.mockRemoveDependencies(c)
+
+	_, _, err := snapstate.RemoveMany(s.state, []string{"unrelated", "content-provider"}, nil)
+	c.Assert(err, ErrorMatches, `snap "content-provider" is not removable: snap is needed by snap "content-consumer"`)
+	c.Check(s.state.TaskCount(), Equals, 0)
+}
+
+func (s *snapmgrTestSuite) TestRemoveManyWithDependentsOrdered(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.mockRemoveDependencies(c)
+
+	// dependents given together with what they need are removed first
+	removed, tss, err := snapstate.RemoveMany(s.state, []string{"content-provider", "content-consumer"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"content-consumer", "content-provider"})
+	c.Assert(tss, HasLen, 2)
+
+	c.Check(tss[0].Tasks()[0].WaitTasks(), HasLen, 0)
+	for _, t := range tss[0].Tasks() {
+		c.Check(tss[1].Tasks()[0].WaitTasks(), testutil.Contains, t)
+	}
+}
+
+func (s *snapmgrTestSuite) TestRemoveManyCascade(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.mockRemoveDependencies(c)
+
+	removed, tss, err := snapstate.RemoveMany(s.state, []string{"some-base"}, &snapstate.RemoveFlags{Cascade: true})
+	c.Assert(err, IsNil)
+	c.Check(removed, DeepEquals, []string{"content-consumer", "content-provider", "some-base"})
+	c.Assert(tss, HasLen, 3)
+
+	waitsFor := func(ts, other *state.TaskSet) bool {
+		for _, t := range other.Tasks() {
+			found := false
+			for _, wt := range ts.Tasks()[0].WaitTasks() {
+				if wt == t {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	// the provider waits for its consumer, the base for both
+	c.Check(waitsFor(tss[1], tss[0]), Equals, true)
+	c.Check(waitsFor(tss[2], tss[0]), Equals, true)
+	c.Check(waitsFor(tss[2], tss[1]), Equals, true)
+	c.Check(waitsFor(tss[0], tss[1]), Equals, false)
+
+	// every removal is in its own lane
+	for i, ts := range tss {
+		for _, t := range ts.Tasks() {
+			c.Check(t.Lanes(), DeepEquals, []int{i + 1})
+		}
+	}
+}
+
+func (s *snapmgrTestSuite) TestRemovalPlan(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.mockRemoveDependencies(c)
+
+	plan, err := snapstate.RemovalPlan(s.state, []string{"some-base", "unrelated", "not-installed"}, true)
+	c.Assert(err, IsNil)
+	c.Check(plan, DeepEquals, []string{"content-consumer", "content-provider", "some-base", "unrelated"})
+
+	plan, err = snapstate.RemovalPlan(s.state, []string{"content-provider", "content-consumer"}, false)
+	c.Assert(err, IsNil)
+	c.Check(plan, DeepEquals, []string{"content-consumer", "content-provider"})
+
+	_, err = snapstate.RemovalPlan(s.state, []string{"some-base"}, false)
+	c.Assert(err, ErrorMatches, `snap "some-base" is not removable: snap is needed by snaps "content-consumer", "content-provider"`)
+
+	// planning does not create any tasks
+	c.Check(s.state.TaskCount(), Equals, 0)
+}
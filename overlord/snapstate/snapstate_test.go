@@ -134,6 +134,7 @@ func (s *snapmgrBaseTest) SetUpTest(c *C) {
 
 	restoreCheckFreeSpace := snapstate.MockOsutilCheckFreeSpace(func(string, uint64) error { return nil })
 	s.AddCleanup(restoreCheckFreeSpace)
+	s.AddCleanup(snapstate.MockReserveDiskSpace(func(string, uint64) error { return nil }))
 
 	s.fakeBackend = &fakeSnappyBackend{}
 	s.fakeBackend.emptyContainer = emptyContainer(c)
@@ -7329,6 +7330,37 @@ func (s *snapmgrTestSuite) TestStartSnapServicesWithDisabledServicesMissing(c *C
 	c.Assert(buf.String(), Matches, `.*previously disabled service old-disabled-svc no longer exists\n.*`)
 }
 
+func (s *snapmgrTestSuite) TestStartSnapServicesHandoff(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "hello-snap", SnapID: "hello-snap-id", Revision: snap.R(1)}
+	snaptest.MockSnap(c, servicesSnap, si)
+
+	snapstate.Set(s.state, "hello-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+
+	// using MockSnap, we want to read the bits on disk
+	snapstate.MockSnapReadInfo(snap.ReadInfo)
+
+	chg := s.state.NewChange("services..", "")
+	t := s.state.NewTask("start-snap-services", "")
+	sup := &snapstate.SnapSetup{SideInfo: si}
+	t.Set("snap-setup", sup)
+	t.Set("handoff", true)
+	chg.AddTask(t)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeBackend.startServicesHandoff, Equals, true)
+}
+
 func (s *snapmgrTestSuite) TestStartSnapServicesUndo(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -7940,6 +7972,7 @@ var nonReLinkKinds = []string{
 	"start-snap-services",
 	"run-hook[configure]",
 	"run-hook[check-health]",
+	"update-managed-boot-config",
 }
 
 func kindsToSet(kinds []string) map[string]bool {
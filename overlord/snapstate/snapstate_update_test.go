@@ -719,6 +719,33 @@ func (s *snapmgrTestSuite) TestUpdateTasks(c *C) {
 	c.Check(snapsup.Channel, Equals, "some-channel")
 }
 
+func (s *snapmgrTestSuite) TestUpdateTasksStartServicesHandoff(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:          true,
+		TrackingChannel: "latest/edge",
+		Sequence:        []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:         snap.R(7),
+		SnapType:        "app",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", &snapstate.RevisionOptions{Channel: "some-channel"}, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	var start *state.Task
+	for _, t := range ts.Tasks() {
+		if t.Kind() == "start-snap-services" {
+			start = t
+		}
+	}
+	c.Assert(start, NotNil)
+	var handoff bool
+	c.Assert(start.Get("handoff", &handoff), IsNil)
+	c.Check(handoff, Equals, true)
+}
+
 func (s *snapmgrTestSuite) TestUpdateAmendRunThrough(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -1469,7 +1496,8 @@ func (s *snapmgrTestSuite) TestUpdateWithNewBase(c *C) {
 	defer s.se.Stop()
 	s.settle(c)
 
-	c.Check(s.fakeStore.downloads, DeepEquals, []fakeDownload{
+	// the snap is downloaded while the base gets installed
+	c.Check(s.fakeStore.downloads, testutil.DeepUnsortedMatches, []fakeDownload{
 		{macaroon: s.user.StoreMacaroon, name: "some-base", target: filepath.Join(dirs.SnapBlobDir, "some-base_11.snap")},
 		{macaroon: s.user.StoreMacaroon, name: "some-snap", target: filepath.Join(dirs.SnapBlobDir, "some-snap_11.snap")},
 	})
@@ -5486,7 +5514,7 @@ func (s *snapmgrTestSuite) TestUpdateManyDiskSpaceCheckError(c *C) {
 	failInstallSize := false
 	err := s.testUpdateManyDiskSpaceCheck(c, featureFlag, failDiskCheck, failInstallSize)
 	diskSpaceErr := err.(*snapstate.InsufficientSpaceError)
-	c.Assert(diskSpaceErr, ErrorMatches, `insufficient space in .* to perform "refresh" change for the following snaps: snapd, some-snap`)
+	c.Assert(diskSpaceErr, ErrorMatches, `insufficient space to perform "refresh" change for the following snaps: snapd, some-snap \(at least 0B more is required in ".*/var/lib/snapd"\)`)
 	c.Check(diskSpaceErr.Path, Equals, filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd"))
 	c.Check(diskSpaceErr.Snaps, DeepEquals, []string{"snapd", "some-snap"})
 }
@@ -6139,7 +6167,7 @@ func (s *snapmgrTestSuite) TestUpdateDiskSpaceError(c *C) {
 	failDiskCheck := true
 	err := s.testUpdateDiskSpaceCheck(c, featureFlag, failInstallSize, failDiskCheck)
 	diskSpaceErr := err.(*snapstate.InsufficientSpaceError)
-	c.Assert(diskSpaceErr, ErrorMatches, `insufficient space in .* to perform "refresh" change for the following snaps: some-snap`)
+	c.Assert(diskSpaceErr, ErrorMatches, `insufficient space to perform "refresh" change for the following snaps: some-snap \(at least 0B more is required in ".*/var/lib/snapd"\)`)
 	c.Check(diskSpaceErr.Path, Equals, filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd"))
 	c.Check(diskSpaceErr.Snaps, DeepEquals, []string{"some-snap"})
 }
@@ -8885,6 +8913,143 @@ func (s *snapmgrTestSuite) TestGeneralRefreshSkipsGatedSnaps(c *C) {
 	c.Check(chg.Status(), Equals, state.DoneStatus)
 }
 
+func (s *snapmgrTestSuite) TestAutoRefreshRespectsRefreshWindow(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// a Monday
+	now := time.Date(2022, 5, 2, 12, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	restore = snapstate.MockSnapReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		if err != nil {
+			return nil, err
+		}
+		info.RefreshWindow = "mon,02:00-04:00"
+		return info, nil
+	})
+	defer restore()
+
+	lastRefresh := now.Add(-24 * time.Hour)
+	for _, name := range []string{"some-snap", "some-other-snap"} {
+		si := &snap.SideInfo{
+			RealName: name,
+			SnapID:   fmt.Sprintf("%s-id", name),
+			Revision: snap.R(7),
+		}
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:          true,
+			Sequence:        []*snap.SideInfo{si},
+			Current:         si.Revision,
+			LastRefreshTime: &lastRefresh,
+		})
+	}
+	// the window of some-other-snap was missed for too long
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-other-snap", &snapst), IsNil)
+	longAgo := now.Add(-15 * 24 * time.Hour)
+	snapst.LastRefreshTime = &longAgo
+	snapstate.Set(s.state, "some-other-snap", &snapst)
+
+	logbuf, restoreLogger := logger.MockLogger()
+	defer restoreLogger()
+
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, nil, s.user.ID, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-other-snap"})
+	c.Check(logbuf.String(), testutil.Contains, `skipping auto-refresh of snaps outside of their refresh window: "some-snap"`)
+	// the next auto-refresh happens in the window at the latest
+	var next time.Time
+	c.Assert(s.state.Get("next-refresh-window", &next), IsNil)
+	c.Check(next.Equal(time.Date(2022, 5, 9, 2, 0, 0, 0, time.UTC)), Equals, true)
+
+	// a general refresh is not affected by the window
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, nil, s.user.ID, nil)
+	c.Assert(err, IsNil)
+	sort.Strings(updates)
+	c.Check(updates, DeepEquals, []string{"some-other-snap", "some-snap"})
+
+	// and within the window the auto-refresh proceeds
+	now = time.Date(2022, 5, 2, 3, 0, 0, 0, time.UTC)
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, nil, s.user.ID, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	sort.Strings(updates)
+	c.Check(updates, DeepEquals, []string{"some-other-snap", "some-snap"})
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshRefreshWindowIntersectedWithTimer(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// a Monday
+	now := time.Date(2022, 5, 2, 12, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	restore = snapstate.MockSnapReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		if err != nil {
+			return nil, err
+		}
+		info.RefreshWindow = "mon,02:00-04:00"
+		return info, nil
+	})
+	defer restore()
+
+	lastRefresh := now.Add(-24 * time.Hour)
+	si := &snap.SideInfo{
+		RealName: "some-snap",
+		SnapID:   "some-snap-id",
+		Revision: snap.R(7),
+	}
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:          true,
+		Sequence:        []*snap.SideInfo{si},
+		Current:         si.Revision,
+		LastRefreshTime: &lastRefresh,
+	})
+
+	// the window set with snapctl takes precedence over snap.yaml
+	c.Assert(snapstate.SetRefreshWindow(s.state, "some-snap", "mon,12:00-13:00"), IsNil)
+	window, err := snapstate.RefreshWindow(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(window, Equals, "mon,12:00-13:00")
+	updates, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, nil, s.user.ID, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+	c.Check(snapstate.SetRefreshWindow(s.state, "some-snap", "mon,25:00"), ErrorMatches, "cannot parse refresh window: .*")
+
+	// reverting to the window declared in snap.yaml
+	c.Assert(snapstate.SetRefreshWindow(s.state, "some-snap", ""), IsNil)
+	window, err = snapstate.RefreshWindow(s.state, "some-snap")
+	c.Assert(err, IsNil)
+	c.Check(window, Equals, "mon,02:00-04:00")
+
+	// the next auto-refresh in the window is within refresh.timer
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", "03:30-05:00")
+	tr.Commit()
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, nil, s.user.ID, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 0)
+	var next time.Time
+	c.Assert(s.state.Get("next-refresh-window", &next), IsNil)
+	c.Check(next.Equal(time.Date(2022, 5, 9, 3, 30, 0, 0, time.UTC)), Equals, true)
+
+	// a window that never overlaps with refresh.timer is ignored
+	logbuf, restoreLogger := logger.MockLogger()
+	defer restoreLogger()
+	tr = config.NewTransaction(s.state)
+	tr.Set("core", "refresh.timer", "tue-sun,00:00-24:00")
+	tr.Commit()
+	updates, _, err = snapstate.UpdateMany(context.Background(), s.state, nil, nil, s.user.ID, &snapstate.Flags{IsAutoRefresh: true})
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+	c.Check(logbuf.String(), testutil.Contains, `cannot use refresh window of snap "some-snap": it does not overlap with the refresh schedule`)
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyTransactionalWithLane(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -0,0 +1,276 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// MigrateToSuccessor returns the tasks migrating the device from the snap
+// oldName to newName, its renamed successor. The services of the old snap
+// are stopped and its aliases disabled, its data is copied for the
+// successor which is then installed from the channel tracked by the old
+// snap, the connections and manual aliases of the old snap are carried over
+// to the successor and finally the old snap is removed. All the tasks are in
+// a single lane so that a failure at any point undoes the whole migration.
+func MigrateToSuccessor(ctx context.Context, st *state.State, oldName, newName string, userID int) ([]*state.TaskSet, error) {
+	var oldSnapst, newSnapst SnapState
+	err := Get(st, oldName, &oldSnapst)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if !oldSnapst.IsInstalled() {
+		return nil, &snap.NotInstalledError{Snap: oldName}
+	}
+	if oldSnapst.InstanceKey != "" {
+		return nil, fmt.Errorf("cannot migrate snap %q to %q: parallel instances are not supported", oldName, newName)
+	}
+	if !oldSnapst.Active {
+		return nil, fmt.Errorf("cannot migrate disabled snap %q to %q", oldName, newName)
+	}
+	err = Get(st, newName, &newSnapst)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if newSnapst.IsInstalled() {
+		return nil, fmt.Errorf("cannot migrate snap %q to %q: %q is already installed", oldName, newName, newName)
+	}
+	if err := CheckChangeConflict(st, oldName, nil); err != nil {
+		return nil, err
+	}
+
+	deviceCtx, err := DevicePastSeeding(st, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	revOpts := &RevisionOptions{
+		Channel:   oldSnapst.TrackingChannel,
+		CohortKey: oldSnapst.CohortKey,
+	}
+	flags := oldSnapst.Flags.ForSnapSetup()
+	sar, err := installInfo(ctx, st, newName, revOpts, userID, flags, deviceCtx)
+	if err != nil {
+		return nil, err
+	}
+	newInfo := sar.Info
+	flags, err = ensureInstallPreconditions(st, newInfo, flags, &newSnapst)
+	if err != nil {
+		return nil, err
+	}
+
+	oldSnapsup := &SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: oldName, Revision: oldSnapst.Current},
+		Type:     snap.Type(oldSnapst.SnapType),
+	}
+	newSnapsup := &SnapSetup{
+		Channel:            revOpts.Channel,
+		Base:               newInfo.Base,
+		UserID:             userID,
+		Flags:              flags.ForSnapSetup(),
+		DownloadInfo:       &newInfo.DownloadInfo,
+		SideInfo:           &newInfo.SideInfo,
+		Type:               newInfo.Type(),
+		PlugsOnly:          len(newInfo.Slots) == 0,
+		CohortKey:          revOpts.CohortKey,
+		ExpectedProvenance: newInfo.SnapProvenance,
+		auxStoreInfo: auxStoreInfo{
+			Media:   newInfo.Media,
+			Website: newInfo.Website(),
+		},
+	}
+	if sar.RedirectChannel != "" {
+		newSnapsup.Channel = sar.RedirectChannel
+	}
+
+	// stop the old snap and move its aliases out of the way of the
+	// successor
+	stop := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q services"), oldName))
+	stop.Set("snap-setup", oldSnapsup)
+	stop.Set("stop-reason", "successor-migration")
+	disable := st.NewTask("disable-aliases", fmt.Sprintf(i18n.G("Disable aliases for snap %q"), oldName))
+	disable.Set("snap-setup-task", stop.ID())
+	disable.WaitFor(stop)
+	migrate := st.NewTask("migrate-snap-data", fmt.Sprintf(i18n.G("Migrate data of snap %q to %q"), oldName, newName))
+	migrate.Set("snap-setup", newSnapsup)
+	migrate.Set("old-name", oldName)
+	migrate.WaitFor(disable)
+	// the task of the successor comes first, so that the successor is
+	// considered the snap refreshed by the lane when re-refreshing
+	tsPrepare := state.NewTaskSet(migrate, stop, disable)
+
+	tsInst, err := doInstall(st, &newSnapst, newSnapsup, 0, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	tsInst.WaitAll(tsPrepare)
+
+	transConns := st.NewTask("transition-snap-connections", fmt.Sprintf(i18n.G("Transition connections of snap %q to %q"), oldName, newName))
+	transConns.Set("old-name", oldName)
+	transConns.Set("new-name", newName)
+	transConns.WaitAll(tsInst)
+	transAliases := st.NewTask("transfer-aliases", fmt.Sprintf(i18n.G("Transfer aliases of snap %q to %q"), oldName, newName))
+	transAliases.Set("snap-setup", newSnapsup)
+	transAliases.Set("old-name", oldName)
+	_, manual := disableAliases(oldSnapst.Aliases)
+	transAliases.Set("manual-aliases", manual)
+	transAliases.Set("auto-aliases-disabled", oldSnapst.AutoAliasesDisabled)
+	transAliases.WaitFor(transConns)
+	tsTransition := state.NewTaskSet(transConns, transAliases)
+
+	tsRm, err := Remove(st, oldName, snap.R(0), nil)
+	if err != nil {
+		return nil, err
+	}
+	tsRm.WaitAll(tsTransition)
+
+	all := []*state.TaskSet{tsPrepare, tsInst, tsTransition, tsRm}
+	lane := st.NewLane()
+	for _, ts := range all {
+		ts.JoinLane(lane)
+	}
+	return all, nil
+}
+
+func (m *SnapManager) doMigrateSnapData(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	oldInfo, newInfo, err := migratedSnapInfos(t)
+	if err != nil {
+		return err
+	}
+	opts, err := GetSnapDirOpts(st, oldInfo.InstanceName())
+	if err != nil {
+		return err
+	}
+
+	st.Unlock()
+	err = m.backend.MigrateSnapData(oldInfo, newInfo, opts)
+	st.Lock()
+	return err
+}
+
+func (m *SnapManager) undoMigrateSnapData(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	oldInfo, newInfo, err := migratedSnapInfos(t)
+	if err != nil {
+		return err
+	}
+	opts, err := GetSnapDirOpts(st, oldInfo.InstanceName())
+	if err != nil {
+		return err
+	}
+
+	st.Unlock()
+	err = m.backend.UndoMigrateSnapData(oldInfo, newInfo, opts)
+	st.Lock()
+	return err
+}
+
+// migratedSnapInfos returns the current info of the snap whose data is
+// migrated by the task and the info of its successor, which may not be
+// installed yet.
+func migratedSnapInfos(t *state.Task) (oldInfo, newInfo *snap.Info, err error) {
+	snapsup, err := TaskSnapSetup(t)
+	if err != nil {
+		return nil, nil, err
+	}
+	var oldName string
+	if err := t.Get("old-name", &oldName); err != nil {
+		return nil, nil, err
+	}
+	var oldSnapst SnapState
+	if err := Get(t.State(), oldName, &oldSnapst); err != nil {
+		return nil, nil, err
+	}
+	oldInfo, err = oldSnapst.CurrentInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+	newInfo = &snap.Info{SideInfo: *snapsup.SideInfo}
+	return oldInfo, newInfo, nil
+}
+
+func (m *SnapManager) doTransferAliases(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	var manual map[string]string
+	if err := t.Get("manual-aliases", &manual); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	var oldAutoDisabled bool
+	if err := t.Get("auto-aliases-disabled", &oldAutoDisabled); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	snapName := snapsup.InstanceName()
+	curInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+
+	curAutoDisabled := snapst.AutoAliasesDisabled
+	curAliases := snapst.Aliases
+	// manual aliases are carried over for the apps that the successor
+	// still has, as is the choice of disabling automatic aliases
+	autoDisabled := curAutoDisabled || oldAutoDisabled
+	newAliases := reenableAliases(curInfo, curAliases, manual)
+	_, err = checkAliasesConflicts(st, snapName, autoDisabled, newAliases, nil)
+	if _, ok := err.(*AliasConflictError); ok {
+		t.Logf("cannot transfer manual aliases to snap %q because of conflicts: %v", snapName, err)
+		newAliases = curAliases
+	} else if err != nil {
+		return err
+	}
+
+	if !snapst.AliasesPending {
+		added, removed, err := applyAliasesChange(snapName, curAutoDisabled, curAliases, autoDisabled, newAliases, m.backend, doApply)
+		if err != nil {
+			return err
+		}
+		if err := aliasesTrace(t, added, removed); err != nil {
+			return err
+		}
+	}
+
+	t.Set("old-auto-aliases-disabled", curAutoDisabled)
+	t.Set("old-aliases-v2", curAliases)
+	snapst.AutoAliasesDisabled = autoDisabled
+	snapst.Aliases = newAliases
+	Set(st, snapName, snapst)
+	return nil
+}
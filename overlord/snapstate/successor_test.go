@@ -0,0 +1,204 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *snapmgrTestSuite) mockRenamedSnap(c *C) {
+	si := &snap.SideInfo{
+		RealName: "renamed-snap",
+		SnapID:   "renamed-snap-id",
+		Revision: snap.R(7),
+	}
+	snaptest.MockSnap(c, "name: renamed-snap\nversion: 1\napps:\n  app:\n", si)
+	snapstate.Set(s.state, "renamed-snap", &snapstate.SnapState{
+		Active:          true,
+		Sequence:        []*snap.SideInfo{si},
+		Current:         si.Revision,
+		SnapType:        "app",
+		TrackingChannel: "latest/stable",
+		Aliases: map[string]*snapstate.AliasTarget{
+			"app": {Manual: "app"},
+		},
+	})
+}
+
+func (s *snapmgrTestSuite) TestMigrateToSuccessorTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockRenamedSnap(c)
+
+	tss, err := snapstate.MigrateToSuccessor(context.Background(), s.state, "renamed-snap", "renamed-snap-ng", 0)
+	c.Assert(err, IsNil)
+	c.Assert(tss, HasLen, 4)
+
+	c.Check(taskKinds(tss[0].Tasks()), DeepEquals, []string{
+		"migrate-snap-data",
+		"stop-snap-services",
+		"disable-aliases",
+	})
+	c.Check(taskKinds(tss[2].Tasks()), DeepEquals, []string{
+		"transition-snap-connections",
+		"transfer-aliases",
+	})
+	c.Check(taskKinds(tss[3].Tasks())[0], Equals, "stop-snap-services")
+
+	// the successor is installed from the channel of the old snap
+	snapsup, err := snapstate.TaskSnapSetup(tss[1].Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.InstanceName(), Equals, "renamed-snap-ng")
+	c.Check(snapsup.Channel, Equals, "latest/stable")
+
+	// everything is in a single lane
+	lanes := tss[0].Tasks()[0].Lanes()
+	c.Assert(lanes, HasLen, 1)
+	for _, ts := range tss {
+		for _, t := range ts.Tasks() {
+			c.Check(t.Lanes(), DeepEquals, lanes, Commentf("task %q", t.Kind()))
+		}
+	}
+
+	var manual map[string]string
+	c.Assert(tss[2].Tasks()[1].Get("manual-aliases", &manual), IsNil)
+	c.Check(manual, DeepEquals, map[string]string{"app": "app"})
+}
+
+func (s *snapmgrTestSuite) TestMigrateToSuccessorErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.MigrateToSuccessor(context.Background(), s.state, "renamed-snap", "renamed-snap-ng", 0)
+	c.Assert(err, ErrorMatches, `snap "renamed-snap" is not installed`)
+
+	s.mockRenamedSnap(c)
+	snapstate.Set(s.state, "renamed-snap-ng", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "renamed-snap-ng", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+	})
+	_, err = snapstate.MigrateToSuccessor(context.Background(), s.state, "renamed-snap", "renamed-snap-ng", 0)
+	c.Assert(err, ErrorMatches, `cannot migrate snap "renamed-snap" to "renamed-snap-ng": "renamed-snap-ng" is already installed`)
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyMigratesToSuccessor(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockRenamedSnap(c)
+
+	updates, tss, err := snapstate.UpdateMany(context.Background(), s.state, nil, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"renamed-snap"})
+	c.Assert(tss, HasLen, 5)
+	verifyLastTasksetIsReRefresh(c, tss)
+	c.Check(tss[0].Tasks()[0].Kind(), Equals, "migrate-snap-data")
+}
+
+func (s *snapmgrTestSuite) addNoopTransitionConnectionsHandler() {
+	noop := func(*state.Task, *tomb.Tomb) error { return nil }
+	s.o.TaskRunner().AddHandler("transition-snap-connections", noop, noop)
+}
+
+func (s *snapmgrTestSuite) TestMigrateToSuccessorRunThrough(c *C) {
+	s.addNoopTransitionConnectionsHandler()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockRenamedSnap(c)
+
+	tss, err := snapstate.MigrateToSuccessor(context.Background(), s.state, "renamed-snap", "renamed-snap-ng", 0)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("refresh", "")
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.IsReady(), Equals, true)
+
+	op := s.fakeBackend.ops.First("migrate-snap-data")
+	c.Assert(op, NotNil)
+	c.Check(op.old, Equals, filepath.Join(dirs.SnapMountDir, "renamed-snap/7"))
+	c.Check(op.path, Equals, filepath.Join(dirs.SnapMountDir, "renamed-snap-ng/11"))
+
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "renamed-snap", &snapst)
+	c.Assert(err, testutil.ErrorIs, state.ErrNoState)
+
+	err = snapstate.Get(s.state, "renamed-snap-ng", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.TrackingChannel, Equals, "latest/stable")
+	c.Check(snapst.Aliases, DeepEquals, map[string]*snapstate.AliasTarget{
+		"app": {Manual: "app"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestMigrateToSuccessorUndo(c *C) {
+	s.addNoopTransitionConnectionsHandler()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockRenamedSnap(c)
+	s.fakeBackend.linkSnapFailTrigger = filepath.Join(dirs.SnapMountDir, "renamed-snap-ng/11")
+
+	tss, err := snapstate.MigrateToSuccessor(context.Background(), s.state, "renamed-snap", "renamed-snap-ng", 0)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("refresh", "")
+	for _, ts := range tss {
+		chg.AddAll(ts)
+	}
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Err(), NotNil)
+	c.Check(s.fakeBackend.ops.First("undo-migrate-snap-data"), NotNil)
+
+	// the old snap is left as it was
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "renamed-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.Aliases, DeepEquals, map[string]*snapstate.AliasTarget{
+		"app": {Manual: "app"},
+	})
+	err = snapstate.Get(s.state, "renamed-snap-ng", &snapst)
+	c.Assert(err, testutil.ErrorIs, state.ErrNoState)
+}
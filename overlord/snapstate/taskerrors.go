@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"syscall"
+
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// classifyTaskError classifies for the task runner the errors of failed
+// tasks caused by a lack of disk space or by the store being unreachable.
+func classifyTaskError(t *state.Task, err error) *state.ErrorDetails {
+	var spaceErr *InsufficientSpaceError
+	if errors.As(err, &spaceErr) {
+		value := map[string]interface{}{}
+		if spaceErr.Path != "" {
+			value["path"] = spaceErr.Path
+		}
+		if len(spaceErr.Snaps) > 0 {
+			value["snap-names"] = spaceErr.Snaps
+		}
+		return &state.ErrorDetails{Kind: "no-space", Value: value}
+	}
+	if errors.Is(err, syscall.ENOSPC) {
+		value := map[string]interface{}{}
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			value["path"] = pathErr.Path
+		}
+		return &state.ErrorDetails{Kind: "no-space", Value: value}
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && httputil.NoNetwork(urlErr) {
+		return &state.ErrorDetails{Kind: "store-offline"}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var networkDownErr = &url.Error{
+	Op:  "Get",
+	URL: "https://api.snapcraft.io",
+	Err: &net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ENETUNREACH}},
+}
+
+func (s *snapmgrTestSuite) TestClassifyTaskError(c *C) {
+	for _, t := range []struct {
+		err     error
+		details *state.ErrorDetails
+	}{
+		{
+			err: &snapstate.InsufficientSpaceError{Path: "/var/lib/snapd", Snaps: []string{"foo"}, ChangeKind: "install"},
+			details: &state.ErrorDetails{Kind: "no-space", Value: map[string]interface{}{
+				"path":       "/var/lib/snapd",
+				"snap-names": []string{"foo"},
+			}},
+		}, {
+			err: fmt.Errorf("cannot copy: %w", &os.PathError{Op: "write", Path: "/var/snap/foo/2/bar", Err: syscall.ENOSPC}),
+			details: &state.ErrorDetails{Kind: "no-space", Value: map[string]interface{}{
+				"path": "/var/snap/foo/2/bar",
+			}},
+		}, {
+			err:     syscall.ENOSPC,
+			details: &state.ErrorDetails{Kind: "no-space", Value: map[string]interface{}{}},
+		}, {
+			err:     networkDownErr,
+			details: &state.ErrorDetails{Kind: "store-offline"},
+		}, {
+			err:     fmt.Errorf("cannot download: %w", networkDownErr),
+			details: &state.ErrorDetails{Kind: "store-offline"},
+		}, {
+			// a timeout does not mean the network is down
+			err: &url.Error{Op: "Get", URL: "https://api.snapcraft.io", Err: errors.New("timeout")},
+		}, {
+			err: errors.New("boom"),
+		},
+	} {
+		c.Check(snapstate.ClassifyTaskError(nil, t.err), DeepEquals, t.details, Commentf("%v", t.err))
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallStoreOfflineErrorDetails(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.fakeStore.downloadError["some-snap"] = networkDownErr
+
+	chg := s.state.NewChange("install", "install a snap")
+	opts := &snapstate.RevisionOptions{Channel: "some-channel"}
+	ts, err := snapstate.Install(context.Background(), s.state, "some-snap", opts, s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	defer s.se.Stop()
+	s.settle(c)
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	var found bool
+	for _, t := range chg.Tasks() {
+		if t.Kind() != "download-snap" {
+			c.Check(t.ErrorDetails(), IsNil)
+			continue
+		}
+		found = true
+		c.Check(t.Status(), Equals, state.ErrorStatus)
+		c.Check(t.ErrorDetails(), DeepEquals, &state.ErrorDetails{Kind: "store-offline"})
+	}
+	c.Check(found, Equals, true)
+}
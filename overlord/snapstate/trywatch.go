@@ -0,0 +1,423 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil/inotify"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// When the try-watch experimental feature is enabled, the directory a snap
+// is tried from can be watched, trying the snap again whenever the contents
+// of the directory change. This refreshes the security profiles and mount
+// units of the snap without having to run snap try again after every
+// change made while developing the snap.
+
+var (
+	// tryWatchSettleDelay is how long the contents of a watched directory
+	// must stay unchanged before the snap is tried again, such that a
+	// burst of changes, as done by a build, results in a single retry
+	tryWatchSettleDelay = 2 * time.Second
+
+	tryWatchEvents = uint32(inotify.InAttrib | inotify.InCloseWrite | inotify.InCreate | inotify.InDelete | inotify.InMovedFrom | inotify.InMovedTo)
+)
+
+// ErrTryNotWatched is returned when a snap is not tried from a watched
+// directory.
+var ErrTryNotWatched = errors.New("snap is not tried from a watched directory")
+
+// TryWatch describes a snap tried from a watched directory.
+type TryWatch struct {
+	Snap  string    `json:"snap"`
+	Path  string    `json:"path"`
+	Flags Flags     `json:"flags"`
+	Since time.Time `json:"since"`
+	// LastRetry is when the snap was last tried again because the
+	// contents of the directory changed.
+	LastRetry time.Time `json:"last-retry,omitempty"`
+	// LastChange is the ID of the change that last tried the snap.
+	LastChange string `json:"last-change,omitempty"`
+}
+
+func tryWatches(st *state.State) (map[string]*TryWatch, error) {
+	var watches map[string]*TryWatch
+	if err := st.Get("try-watches", &watches); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if watches == nil {
+		watches = make(map[string]*TryWatch)
+	}
+	return watches, nil
+}
+
+func setTryWatches(st *state.State, watches map[string]*TryWatch) {
+	if len(watches) == 0 {
+		st.Set("try-watches", nil)
+		return
+	}
+	st.Set("try-watches", watches)
+}
+
+// CheckTryWatchEnabled returns an error if watching the directories of
+// tried snaps is not enabled.
+// The state must be locked by the caller.
+func CheckTryWatchEnabled(st *state.State) error {
+	tr := config.NewTransaction(st)
+	enabled, err := features.Flag(tr, features.TryWatch)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		_, confName := features.TryWatch.ConfigOption()
+		return fmt.Errorf("experimental feature disabled - test it by setting '%s' to true", confName)
+	}
+	return nil
+}
+
+// WatchTry starts watching the directory the given snap is tried from by
+// the given change, such that the snap is tried again with the given mode
+// flags whenever the contents of the directory change.
+// The state must be locked by the caller.
+func WatchTry(st *state.State, instanceName, path string, flags Flags, changeID string) error {
+	if err := CheckTryWatchEnabled(st); err != nil {
+		return err
+	}
+
+	watches, err := tryWatches(st)
+	if err != nil {
+		return err
+	}
+	watches[instanceName] = &TryWatch{
+		Snap: instanceName,
+		Path: path,
+		Flags: Flags{
+			DevMode:  flags.DevMode,
+			JailMode: flags.JailMode,
+			Classic:  flags.Classic,
+		},
+		Since:      timeNow(),
+		LastChange: changeID,
+	}
+	setTryWatches(st, watches)
+	return nil
+}
+
+// UnwatchTry stops watching the directory the given snap is tried from.
+// The snap stays installed as it is.
+// The state must be locked by the caller.
+func UnwatchTry(st *state.State, instanceName string) error {
+	watches, err := tryWatches(st)
+	if err != nil {
+		return err
+	}
+	if watches[instanceName] == nil {
+		return ErrTryNotWatched
+	}
+	delete(watches, instanceName)
+	setTryWatches(st, watches)
+	return nil
+}
+
+// TryWatches returns the snaps tried from watched directories, sorted by
+// name.
+// The state must be locked by the caller.
+func TryWatches(st *state.State) ([]*TryWatch, error) {
+	watches, err := tryWatches(st)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*TryWatch, 0, len(watches))
+	for _, w := range watches {
+		out = append(out, w)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Snap < out[j].Snap })
+	return out, nil
+}
+
+// dirWatcher calls back when the contents of a directory tree change.
+type dirWatcher interface {
+	Close() error
+}
+
+var newDirWatcher = newInotifyDirWatcher
+
+type inotifyDirWatcher struct {
+	w *inotify.Watcher
+}
+
+func newInotifyDirWatcher(path string, changed func()) (dirWatcher, error) {
+	w, err := inotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watchTree(w, path); err != nil {
+		w.Close()
+		return nil, err
+	}
+	go func() {
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Mask&inotify.InIsDir != 0 && ev.Mask&(inotify.InCreate|inotify.InMovedTo) != 0 {
+					if err := watchTree(w, ev.Path); err != nil {
+						logger.Noticef("cannot watch %s: %v", ev.Path, err)
+					}
+				}
+				changed()
+			case err := <-w.Errors:
+				logger.Noticef("cannot watch %s any longer: %v", path, err)
+				return
+			}
+		}
+	}()
+	return &inotifyDirWatcher{w: w}, nil
+}
+
+// watchTree watches the given directory and all the directories below it.
+func watchTree(w *inotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path != root && os.IsNotExist(err) {
+				// removed meanwhile
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		return w.AddWatch(path, tryWatchEvents)
+	})
+}
+
+func (d *inotifyDirWatcher) Close() error {
+	return d.w.Close()
+}
+
+// tryWatchers keeps track of the directories being watched and of when
+// their contents last changed.
+type tryWatchers struct {
+	state *state.State
+
+	mu       sync.Mutex
+	watchers map[string]dirWatcher
+	paths    map[string]string
+	changed  map[string]time.Time
+}
+
+func newTryWatchers(st *state.State) *tryWatchers {
+	return &tryWatchers{
+		state:    st,
+		watchers: make(map[string]dirWatcher),
+		paths:    make(map[string]string),
+		changed:  make(map[string]time.Time),
+	}
+}
+
+// ensure makes sure the directory of the given snap is being watched.
+func (tw *tryWatchers) ensure(instanceName, path string) error {
+	tw.mu.Lock()
+	running := tw.watchers[instanceName] != nil && tw.paths[instanceName] == path
+	tw.mu.Unlock()
+	if running {
+		return nil
+	}
+	tw.stop(instanceName)
+
+	watcher, err := newDirWatcher(path, func() {
+		tw.mu.Lock()
+		tw.changed[instanceName] = timeNow()
+		tw.mu.Unlock()
+		tw.state.EnsureBefore(tryWatchSettleDelay)
+	})
+	if err != nil {
+		return err
+	}
+
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.watchers[instanceName] = watcher
+	tw.paths[instanceName] = path
+	return nil
+}
+
+// lastChanged returns when the directory of the given snap last changed,
+// if it did since the last call to settled.
+func (tw *tryWatchers) lastChanged(instanceName string) time.Time {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.changed[instanceName]
+}
+
+// settled forgets about the changes of the directory of the given snap up
+// to the given time.
+func (tw *tryWatchers) settled(instanceName string, upTo time.Time) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.changed[instanceName].After(upTo) {
+		delete(tw.changed, instanceName)
+	}
+}
+
+func (tw *tryWatchers) stop(instanceName string) {
+	tw.mu.Lock()
+	watcher := tw.watchers[instanceName]
+	delete(tw.watchers, instanceName)
+	delete(tw.paths, instanceName)
+	delete(tw.changed, instanceName)
+	tw.mu.Unlock()
+
+	if watcher == nil {
+		return
+	}
+	if err := watcher.Close(); err != nil {
+		logger.Noticef("cannot stop watching the directory of snap %q: %v", instanceName, err)
+	}
+}
+
+// stopAllExcept stops watching the directories of all the snaps but the
+// given ones.
+func (tw *tryWatchers) stopAllExcept(keep map[string]*TryWatch) {
+	tw.mu.Lock()
+	var names []string
+	for instanceName := range tw.watchers {
+		if keep[instanceName] == nil {
+			names = append(names, instanceName)
+		}
+	}
+	tw.mu.Unlock()
+
+	for _, instanceName := range names {
+		tw.stop(instanceName)
+	}
+}
+
+// retryTry tries the snap again from its watched directory, it returns
+// whether the retry needs to be attempted later.
+func retryTry(st *state.State, w *TryWatch, now time.Time) (later bool, err error) {
+	ts, err := TryPath(st, w.Snap, w.Path, w.Flags)
+	if err != nil {
+		if _, ok := err.(*ChangeConflictError); ok {
+			return true, nil
+		}
+		// most likely the contents of the directory are broken
+		// right now, wait for them to be changed again
+		logger.Noticef("cannot try snap %q again from %s: %v", w.Snap, w.Path, err)
+		return false, nil
+	}
+	msg := fmt.Sprintf(i18n.G("Try %q snap again from %s"), w.Snap, w.Path)
+	chg := st.NewChange("try-snap", msg)
+	chg.AddAll(ts)
+	chg.Set("snap-names", []string{w.Snap})
+	w.LastRetry = now
+	w.LastChange = chg.ID()
+	return false, nil
+}
+
+// ensureTryWatches watches the directories of the snaps tried from watched
+// directories and tries the snaps again once their contents have changed.
+func (m *SnapManager) ensureTryWatches() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	watches, err := tryWatches(m.state)
+	if err != nil {
+		return err
+	}
+	tr := config.NewTransaction(m.state)
+	enabled, err := features.Flag(tr, features.TryWatch)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		m.tryWatchers.stopAllExcept(nil)
+		return nil
+	}
+	m.tryWatchers.stopAllExcept(watches)
+	if len(watches) == 0 {
+		return nil
+	}
+
+	now := timeNow()
+	modified := false
+	for instanceName, w := range watches {
+		var snapst SnapState
+		if err := Get(m.state, instanceName, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		if !snapst.IsInstalled() || !snapst.TryMode {
+			if chg := m.state.Change(w.LastChange); chg != nil && !chg.Status().Ready() {
+				// still being tried
+				continue
+			}
+			// removed or installed from elsewhere meanwhile
+			m.tryWatchers.stop(instanceName)
+			delete(watches, instanceName)
+			modified = true
+			continue
+		}
+
+		if err := m.tryWatchers.ensure(instanceName, w.Path); err != nil {
+			logger.Noticef("cannot watch the directory of snap %q: %v", instanceName, err)
+			delete(watches, instanceName)
+			modified = true
+			continue
+		}
+
+		changed := m.tryWatchers.lastChanged(instanceName)
+		if changed.IsZero() {
+			continue
+		}
+		if settle := changed.Add(tryWatchSettleDelay).Sub(now); settle > 0 {
+			m.state.EnsureBefore(settle)
+			continue
+		}
+		later, err := retryTry(m.state, w, now)
+		if err != nil {
+			return err
+		}
+		if later {
+			m.state.EnsureBefore(tryWatchSettleDelay)
+			continue
+		}
+		m.tryWatchers.settled(instanceName, changed)
+		modified = true
+	}
+
+	if modified {
+		setTryWatches(m.state, watches)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type fakeDirWatcher struct {
+	path    string
+	changed func()
+	closed  bool
+}
+
+func (w *fakeDirWatcher) Close() error {
+	w.closed = true
+	return nil
+}
+
+func (s *snapmgrTestSuite) mockTryWatch(c *C) (watchers map[string]*fakeDirWatcher, tryDir string) {
+	watchers = make(map[string]*fakeDirWatcher)
+	s.AddCleanup(snapstate.MockNewDirWatcher(func(path string, changed func()) (io.Closer, error) {
+		w := &fakeDirWatcher{path: path, changed: changed}
+		watchers[path] = w
+		return w, nil
+	}))
+	s.AddCleanup(snapstate.MockTryWatchSettleDelay(0))
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "experimental.try-watch", true), IsNil)
+	tr.Commit()
+
+	tryDir = c.MkDir()
+	c.Assert(os.Chmod(tryDir, 0755), IsNil)
+	c.Assert(os.MkdirAll(filepath.Join(tryDir, "meta"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(tryDir, "meta", "snap.yaml"), []byte("name: foo\nversion: 1.0\nepoch: 1*\n"), 0644), IsNil)
+
+	si := &snap.SideInfo{RealName: "foo", Revision: snap.R(-1)}
+	snaptest.MockSnap(c, "name: foo\nversion: 1.0\n", si)
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(-1),
+		SnapType: "app",
+		Flags:    snapstate.Flags{TryMode: true, DevMode: true},
+	})
+	return watchers, tryDir
+}
+
+func (s *snapmgrTestSuite) TestWatchTryNeedsFeature(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := snapstate.WatchTry(s.state, "foo", "/some/dir", snapstate.Flags{}, "")
+	c.Check(err, ErrorMatches, `experimental feature disabled - test it by setting 'experimental.try-watch' to true`)
+}
+
+func (s *snapmgrTestSuite) TestWatchTryRetriesOnChange(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	watchers, tryDir := s.mockTryWatch(c)
+	c.Assert(snapstate.WatchTry(s.state, "foo", tryDir, snapstate.Flags{DevMode: true, IgnoreRunning: true}, ""), IsNil)
+
+	watches, err := snapstate.TryWatches(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(watches, HasLen, 1)
+	c.Check(watches[0].Snap, Equals, "foo")
+	c.Check(watches[0].Path, Equals, tryDir)
+	// only the mode is kept
+	c.Check(watches[0].Flags, DeepEquals, snapstate.Flags{DevMode: true})
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	c.Assert(watchers[tryDir], NotNil)
+	c.Check(s.state.Changes(), HasLen, 0)
+
+	// the contents of the directory change
+	watchers[tryDir].changed()
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), Equals, "try-snap")
+	c.Check(chg.Summary(), Equals, `Try "foo" snap again from `+tryDir)
+	var snapsup *snapstate.SnapSetup
+	for _, t := range chg.Tasks() {
+		if t.Kind() == "prepare-snap" {
+			snapsup, err = snapstate.TaskSnapSetup(t)
+			c.Assert(err, IsNil)
+		}
+	}
+	c.Assert(snapsup, NotNil)
+	c.Check(snapsup.SnapPath, Equals, tryDir)
+	c.Check(snapsup.Flags.TryMode, Equals, true)
+	c.Check(snapsup.Flags.DevMode, Equals, true)
+
+	watches, err = snapstate.TryWatches(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(watches, HasLen, 1)
+	c.Check(watches[0].LastChange, Equals, chg.ID())
+	c.Check(watches[0].LastRetry.IsZero(), Equals, false)
+
+	// nothing changed since
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *snapmgrTestSuite) TestWatchTrySettles(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	watchers, tryDir := s.mockTryWatch(c)
+	s.AddCleanup(snapstate.MockTryWatchSettleDelay(time.Hour))
+	c.Assert(snapstate.WatchTry(s.state, "foo", tryDir, snapstate.Flags{}, ""), IsNil)
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	watchers[tryDir].changed()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	// still changing
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestUnwatchTry(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	watchers, tryDir := s.mockTryWatch(c)
+	c.Assert(snapstate.WatchTry(s.state, "foo", tryDir, snapstate.Flags{}, ""), IsNil)
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+	c.Assert(watchers[tryDir], NotNil)
+
+	c.Assert(snapstate.UnwatchTry(s.state, "foo"), IsNil)
+	c.Check(snapstate.UnwatchTry(s.state, "foo"), Equals, snapstate.ErrTryNotWatched)
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+	c.Check(watchers[tryDir].closed, Equals, true)
+
+	watches, err := snapstate.TryWatches(s.state)
+	c.Assert(err, IsNil)
+	c.Check(watches, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestWatchTryDroppedWhenNotTriedAnyLonger(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	watchers, tryDir := s.mockTryWatch(c)
+	c.Assert(snapstate.WatchTry(s.state, "foo", tryDir, snapstate.Flags{}, ""), IsNil)
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	// the snap was removed
+	snapstate.Set(s.state, "foo", nil)
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	c.Check(watchers[tryDir].closed, Equals, true)
+	watches, err := snapstate.TryWatches(s.state)
+	c.Assert(err, IsNil)
+	c.Check(watches, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestWatchTryStoppedWhenFeatureDisabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	watchers, tryDir := s.mockTryWatch(c)
+	c.Assert(snapstate.WatchTry(s.state, "foo", tryDir, snapstate.Flags{}, ""), IsNil)
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "experimental.try-watch", false), IsNil)
+	tr.Commit()
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	c.Check(watchers[tryDir].closed, Equals, true)
+	// the watch is kept for when the feature is enabled again
+	watches, err := snapstate.TryWatches(s.state)
+	c.Assert(err, IsNil)
+	c.Check(watches, HasLen, 1)
+}
+
+func (s *snapmgrTestSuite) TestWatchTryKeptWhileBeingTried(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	watchers, tryDir := s.mockTryWatch(c)
+	// the snap is not installed yet
+	snapstate.Set(s.state, "foo", nil)
+
+	chg := s.state.NewChange("try-snap", "...")
+	chg.AddTask(s.state.NewTask("nop", "..."))
+	c.Assert(snapstate.WatchTry(s.state, "foo", tryDir, snapstate.Flags{}, chg.ID()), IsNil)
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	c.Check(watchers, HasLen, 0)
+	watches, err := snapstate.TryWatches(s.state)
+	c.Assert(err, IsNil)
+	c.Check(watches, HasLen, 1)
+
+	// trying the snap failed
+	chg.SetStatus(state.ErrorStatus)
+
+	s.state.Unlock()
+	c.Assert(s.snapmgr.EnsureTryWatches(), IsNil)
+	s.state.Lock()
+
+	watches, err = snapstate.TryWatches(s.state)
+	c.Assert(err, IsNil)
+	c.Check(watches, HasLen, 0)
+}
@@ -0,0 +1,200 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// warmCacheEntry describes a revision of a snap that was removed while
+// asking to keep it cached, so that reinstalling the same revision later
+// does not need to talk to the store. The snap blob itself is kept in the
+// download cache, where it is subject to the usual cache cleanup policy,
+// and the auxiliary store info of the snap, such as its media, is kept in
+// place as long as the entry exists. Entries expire after warmCacheMaxAge.
+type warmCacheEntry struct {
+	SideInfo *snap.SideInfo `json:"side-info"`
+	Channel  string         `json:"channel,omitempty"`
+	Sha3_384 string         `json:"sha3-384"`
+	Size     uint64         `json:"size"`
+	Time     time.Time      `json:"time"`
+}
+
+func (e *warmCacheEntry) path() string {
+	return filepath.Join(dirs.SnapDownloadCacheDir, e.Sha3_384)
+}
+
+func warmCacheEntries(st *state.State) (map[string]*warmCacheEntry, error) {
+	var entries map[string]*warmCacheEntry
+	err := st.Get("warm-cache", &entries)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if entries == nil {
+		entries = make(map[string]*warmCacheEntry)
+	}
+	return entries, nil
+}
+
+// keepInWarmCache keeps the blob of the given snap revision in the download
+// cache and records it so that it can be reinstalled without the store. It
+// returns whether the snap was kept, in which case its auxiliary store info
+// must be kept as well.
+func keepInWarmCache(st *state.State, instanceName string, si *snap.SideInfo, channel string) (kept bool, err error) {
+	if si.SnapID == "" {
+		// without assertions the snap could not be reinstalled as is
+		logger.Noticef("cannot keep unasserted snap %q in the cache", instanceName)
+		return false, nil
+	}
+
+	blob := snap.MinimalPlaceInfo(instanceName, si.Revision).MountFile()
+	digest, size, err := asserts.SnapFileSHA3_384(blob)
+	if err != nil {
+		return false, err
+	}
+	entry := &warmCacheEntry{
+		SideInfo: si,
+		Channel:  channel,
+		Sha3_384: digest,
+		Size:     size,
+		Time:     timeNow(),
+	}
+
+	if err := os.MkdirAll(dirs.SnapDownloadCacheDir, 0700); err != nil {
+		return false, err
+	}
+	if err := os.Link(blob, entry.path()); err != nil && !os.IsExist(err) {
+		return false, err
+	}
+
+	entries, err := warmCacheEntries(st)
+	if err != nil {
+		return false, err
+	}
+	entries[instanceName] = entry
+	st.Set("warm-cache", entries)
+	return true, nil
+}
+
+// DeriveSideInfo derives the side info of the snap blob at the given path
+// from its snap-revision and snap-declaration assertions in the system
+// assertion database. It is hooked in by assertstate.
+var DeriveSideInfo func(st *state.State, snapPath string, deviceCtx DeviceContext) (*snap.SideInfo, error)
+
+var (
+	// warmCacheMaxAge is how long a removed snap is kept in the cache
+	warmCacheMaxAge = 30 * 24 * time.Hour
+
+	warmCachePruneWait      = 24 * time.Hour
+	warmCacheLastPruneCheck time.Time
+)
+
+// dropWarmCacheEntry forgets the given cache entry and discards the
+// auxiliary store info kept for it, unless the snap was installed again
+// meanwhile. The blob is removed from the download cache as well if
+// removeBlob is set and nothing else refers to it.
+func dropWarmCacheEntry(st *state.State, entries map[string]*warmCacheEntry, instanceName string, removeBlob bool) {
+	entry := entries[instanceName]
+	delete(entries, instanceName)
+	st.Set("warm-cache", entries)
+	if err := Get(st, instanceName, &SnapState{}); errors.Is(err, state.ErrNoState) {
+		// the snap was not installed again meanwhile
+		if err := discardAuxStoreInfo(entry.SideInfo.SnapID); err != nil {
+			logger.Noticef("Cannot remove auxiliary store info for %q: %v", instanceName, err)
+		}
+	}
+	if !removeBlob {
+		return
+	}
+	fi, err := os.Stat(entry.path())
+	if err != nil {
+		return
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok && stat.Nlink > 1 {
+		// the blob is in use by an installed snap
+		return
+	}
+	if err := os.Remove(entry.path()); err != nil && !os.IsNotExist(err) {
+		logger.Noticef("Cannot remove cached snap %q: %v", instanceName, err)
+	}
+}
+
+// warmCacheLookup returns the cached blob of the given revision of a snap
+// together with its side info, if it was kept in the cache when the snap
+// was removed and the blob was not cleaned up from the cache since. The
+// blob is only used if it matches the snap-revision assertion of the
+// revision. Stale entries are dropped.
+func warmCacheLookup(st *state.State, instanceName string, revision snap.Revision, deviceCtx DeviceContext) (path string, si *snap.SideInfo, err error) {
+	entries, err := warmCacheEntries(st)
+	if err != nil {
+		return "", nil, err
+	}
+	entry := entries[instanceName]
+	if entry == nil || entry.SideInfo.Revision != revision {
+		return "", nil, nil
+	}
+	fi, err := os.Stat(entry.path())
+	if err != nil || uint64(fi.Size()) != entry.Size {
+		// cleaned up from the cache
+		dropWarmCacheEntry(st, entries, instanceName, false)
+		return "", nil, nil
+	}
+	asserted, err := DeriveSideInfo(st, entry.path(), deviceCtx)
+	if err != nil && !asserts.IsNotFound(err) {
+		return "", nil, err
+	}
+	if err != nil || asserted.SnapID != entry.SideInfo.SnapID || asserted.Revision != entry.SideInfo.Revision {
+		// the blob is not the asserted revision, do not trust it
+		logger.Noticef("Cannot use cached snap %q revision %s: blob does not match its snap-revision assertion", instanceName, revision)
+		dropWarmCacheEntry(st, entries, instanceName, true)
+		return "", nil, nil
+	}
+	return entry.path(), entry.SideInfo, nil
+}
+
+// pruneWarmCache drops the entries of snaps kept in the cache on removal
+// that are older than warmCacheMaxAge or whose blob was cleaned up from the
+// download cache.
+func pruneWarmCache(st *state.State) error {
+	entries, err := warmCacheEntries(st)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-warmCacheMaxAge)
+	for instanceName, entry := range entries {
+		expired := entry.Time.Before(cutoff)
+		if !expired && osutil.FileExists(entry.path()) {
+			continue
+		}
+		dropWarmCacheEntry(st, entries, instanceName, expired)
+	}
+	return nil
+}
@@ -731,3 +731,63 @@ func (c *Change) CheckTaskDependencies() error {
 	}
 	return nil
 }
+
+// ChangeGraphTask describes a task in the dependency graph of a change.
+type ChangeGraphTask struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Summary string `json:"summary"`
+	Status  string `json:"status"`
+	Lanes   []int  `json:"lanes"`
+	// WaitTasks holds the IDs of the tasks this task waits for, they
+	// may belong to other changes.
+	WaitTasks []string `json:"wait-tasks,omitempty"`
+}
+
+// ChangeGraph describes the tasks of a change together with their lanes,
+// statuses and dependencies, to be used for inspecting the change.
+type ChangeGraph struct {
+	ID      string            `json:"id"`
+	Kind    string            `json:"kind"`
+	Summary string            `json:"summary"`
+	Status  string            `json:"status"`
+	Tasks   []ChangeGraphTask `json:"tasks"`
+}
+
+// Graph returns the dependency graph of the tasks of the change, ordered by
+// task ID.
+func (c *Change) Graph() *ChangeGraph {
+	tasks := c.Tasks()
+	sort.Slice(tasks, func(i, j int) bool {
+		return taskIDLess(tasks[i].ID(), tasks[j].ID())
+	})
+	g := &ChangeGraph{
+		ID:      c.ID(),
+		Kind:    c.Kind(),
+		Summary: c.Summary(),
+		Status:  c.Status().String(),
+		Tasks:   make([]ChangeGraphTask, 0, len(tasks)),
+	}
+	for _, t := range tasks {
+		gt := ChangeGraphTask{
+			ID:      t.ID(),
+			Kind:    t.Kind(),
+			Summary: t.Summary(),
+			Status:  t.Status().String(),
+			Lanes:   t.Lanes(),
+		}
+		for _, wt := range t.WaitTasks() {
+			gt.WaitTasks = append(gt.WaitTasks, wt.ID())
+		}
+		g.Tasks = append(g.Tasks, gt)
+	}
+	return g
+}
+
+// taskIDLess orders task IDs numerically.
+func taskIDLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
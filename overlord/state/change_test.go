@@ -1100,3 +1100,50 @@ func (ts *taskRunnerSuite) TestCheckTaskDependencies(c *C) {
 		}
 	}
 }
+
+func (cs *changeSuite) TestGraph(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "install things")
+	var tasks []*state.Task
+	for i := 0; i < 11; i++ {
+		t := st.NewTask(fmt.Sprintf("task%d", i), fmt.Sprintf("task %d", i))
+		chg.AddTask(t)
+		tasks = append(tasks, t)
+	}
+	tasks[1].WaitFor(tasks[0])
+	tasks[10].WaitFor(tasks[1])
+	tasks[10].WaitFor(tasks[0])
+	tasks[1].JoinLane(2)
+	tasks[10].JoinLane(2)
+	tasks[10].JoinLane(3)
+	tasks[0].SetStatus(state.DoneStatus)
+
+	other := st.NewChange("other", "...")
+	ot := st.NewTask("other", "other task")
+	other.AddTask(ot)
+	tasks[1].WaitFor(ot)
+
+	g := chg.Graph()
+	c.Check(g.ID, Equals, chg.ID())
+	c.Check(g.Kind, Equals, "install")
+	c.Check(g.Summary, Equals, "install things")
+	c.Check(g.Status, Equals, "Do")
+	c.Assert(g.Tasks, HasLen, 11)
+	// ordered by numeric task ID
+	c.Check(g.Tasks[0].ID, Equals, tasks[0].ID())
+	c.Check(g.Tasks[10].ID, Equals, tasks[10].ID())
+	c.Check(g.Tasks[0], DeepEquals, state.ChangeGraphTask{
+		ID:      tasks[0].ID(),
+		Kind:    "task0",
+		Summary: "task 0",
+		Status:  "Done",
+		Lanes:   []int{0},
+	})
+	c.Check(g.Tasks[1].WaitTasks, DeepEquals, []string{tasks[0].ID(), ot.ID()})
+	c.Check(g.Tasks[1].Lanes, DeepEquals, []int{2})
+	c.Check(g.Tasks[10].WaitTasks, DeepEquals, []string{tasks[1].ID(), tasks[0].ID()})
+	c.Check(g.Tasks[10].Lanes, DeepEquals, []int{2, 3})
+}
@@ -114,8 +114,8 @@ func CopyState(srcStatePath, dstStatePath string, dataEntries []string) error {
 	defer f.Close()
 
 	// No need to lock/unlock the state here, srcState should not be
-	// in use at all.
-	srcState, err := ReadState(nil, f)
+	// in use at all. Its sensitive parts may be encrypted at rest.
+	srcState, err := ReadEncryptedState(nil, f)
 	if err != nil {
 		return err
 	}
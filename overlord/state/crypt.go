@@ -0,0 +1,243 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// sensitiveStateKeys are the state entries encrypted at rest when the
+// encrypted-state feature is enabled: the user and device macaroons and
+// the device identity including the device key reference.
+var sensitiveStateKeys = []string{"auth", "device"}
+
+const stateCipher = "aes-256-gcm"
+
+// encryptedEntry replaces the value of an encrypted state entry.
+type encryptedEntry struct {
+	Cipher string `json:"cipher"`
+	// Data holds the nonce followed by the sealed value.
+	Data []byte `json:"data"`
+}
+
+var stateCipherMarker = []byte(`"cipher":"` + stateCipher + `"`)
+
+// ErrNoEncryptionKey is returned when the state encryption key is not
+// available for the current boot.
+var ErrNoEncryptionKey = errors.New("no state encryption key available")
+
+// encryptionKeyFile is where the initramfs leaves the state encryption key
+// for the current boot. It lives only in memory, never next to the state.
+func encryptionKeyFile() string {
+	return filepath.Join(dirs.SnapBootstrapRunDir, "state-encryption.key")
+}
+
+// ProvideEncryptionKey derives the state encryption key from the given
+// secret and makes it available for the current boot. The secret is meant
+// to be the unlock key of the encrypted ubuntu-data as unsealed by the TPM
+// or the fde-setup hook, so that the key is bound to the same material
+// protecting the disk while never being stored on it. It is meant to be
+// used only from the initramfs.
+func ProvideEncryptionKey(secret []byte) error {
+	if len(secret) == 0 {
+		return fmt.Errorf("cannot derive state encryption key from an empty secret")
+	}
+	key := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, secret, nil, []byte("snapd state encryption"))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return fmt.Errorf("cannot derive state encryption key: %v", err)
+	}
+	if err := os.MkdirAll(dirs.SnapBootstrapRunDir, 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(encryptionKeyFile(), key, 0600, 0)
+}
+
+// EncryptionKey returns the state encryption key of the current boot, or
+// ErrNoEncryptionKey if none was provided.
+func EncryptionKey() ([]byte, error) {
+	key, err := ioutil.ReadFile(encryptionKeyFile())
+	if os.IsNotExist(err) {
+		return nil, ErrNoEncryptionKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state encryption key: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("cannot use state encryption key: invalid length %d", len(key))
+	}
+	return key, nil
+}
+
+func newStateAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// splitState decodes the top level of the serialized state and its data.
+func splitState(data []byte) (top, entries map[string]*json.RawMessage, err error) {
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, nil, err
+	}
+	if raw := top["data"]; raw != nil {
+		if err := json.Unmarshal(*raw, &entries); err != nil {
+			return nil, nil, err
+		}
+	}
+	return top, entries, nil
+}
+
+func joinState(top, entries map[string]*json.RawMessage) ([]byte, error) {
+	if entries == nil {
+		return json.Marshal(top)
+	}
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	rawEntries := json.RawMessage(raw)
+	top["data"] = &rawEntries
+	return json.Marshal(top)
+}
+
+// EncryptData encrypts the sensitive entries of the given serialized state.
+func EncryptData(data, key []byte) ([]byte, error) {
+	top, entries, err := splitState(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt state: %v", err)
+	}
+	aead, err := newStateAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt state: %v", err)
+	}
+	for _, k := range sensitiveStateKeys {
+		raw := entries[k]
+		if raw == nil {
+			continue
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("cannot encrypt state: %v", err)
+		}
+		// the entry name is authenticated so that sealed values
+		// cannot be swapped around
+		sealed := aead.Seal(nonce, nonce, *raw, []byte(k))
+		enc, err := json.Marshal(&encryptedEntry{Cipher: stateCipher, Data: sealed})
+		if err != nil {
+			return nil, fmt.Errorf("cannot encrypt state: %v", err)
+		}
+		rawEnc := json.RawMessage(enc)
+		entries[k] = &rawEnc
+	}
+	out, err := joinState(top, entries)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt state: %v", err)
+	}
+	return out, nil
+}
+
+// IsEncryptedData returns whether the given serialized state may carry
+// encrypted entries.
+func IsEncryptedData(data []byte) bool {
+	return bytes.Contains(data, stateCipherMarker)
+}
+
+// DecryptData decrypts any encrypted entries of the given serialized state.
+func DecryptData(data, key []byte) ([]byte, error) {
+	top, entries, err := splitState(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt state: %v", err)
+	}
+	var aead cipher.AEAD
+	for k, raw := range entries {
+		if raw == nil {
+			continue
+		}
+		var enc encryptedEntry
+		if err := json.Unmarshal(*raw, &enc); err != nil || enc.Cipher != stateCipher {
+			// not an encrypted entry
+			continue
+		}
+		if key == nil {
+			return nil, fmt.Errorf("cannot decrypt state: %v", ErrNoEncryptionKey)
+		}
+		if aead == nil {
+			aead, err = newStateAEAD(key)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decrypt state: %v", err)
+			}
+		}
+		if len(enc.Data) < aead.NonceSize() {
+			return nil, fmt.Errorf("cannot decrypt state entry %q: data too short", k)
+		}
+		nonce, sealed := enc.Data[:aead.NonceSize()], enc.Data[aead.NonceSize():]
+		plain, err := aead.Open(nil, nonce, sealed, []byte(k))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt state entry %q: %v", k, err)
+		}
+		rawPlain := json.RawMessage(plain)
+		entries[k] = &rawPlain
+	}
+	out, err := joinState(top, entries)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt state: %v", err)
+	}
+	return out, nil
+}
+
+// ReadEncryptedState returns the state read from the given reader, like
+// ReadState, decrypting any encrypted entries with the state encryption key
+// of the current boot.
+func ReadEncryptedState(backend Backend, r io.Reader) (*State, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state: %s", err)
+	}
+	if IsEncryptedData(data) {
+		key, err := EncryptionKey()
+		if err != nil && err != ErrNoEncryptionKey {
+			return nil, err
+		}
+		data, err = DecryptData(data, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ReadState(backend, bytes.NewReader(data))
+}
@@ -0,0 +1,116 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type cryptSuite struct{}
+
+var _ = Suite(&cryptSuite{})
+
+func (s *cryptSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *cryptSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *cryptSuite) TestProvideEncryptionKey(c *C) {
+	_, err := state.EncryptionKey()
+	c.Check(err, Equals, state.ErrNoEncryptionKey)
+
+	c.Assert(state.ProvideEncryptionKey([]byte("disk-unlock-key")), IsNil)
+
+	keyFile := filepath.Join(dirs.SnapBootstrapRunDir, "state-encryption.key")
+	fi, err := os.Stat(keyFile)
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0600))
+
+	key, err := state.EncryptionKey()
+	c.Assert(err, IsNil)
+	c.Check(key, HasLen, 32)
+	// the secret itself is not handed out
+	c.Check(bytes.Contains(key, []byte("disk-unlock-key")), Equals, false)
+
+	// the same secret gives the same key
+	c.Assert(state.ProvideEncryptionKey([]byte("disk-unlock-key")), IsNil)
+	key2, err := state.EncryptionKey()
+	c.Assert(err, IsNil)
+	c.Check(key2, DeepEquals, key)
+
+	c.Check(state.ProvideEncryptionKey(nil), ErrorMatches, `cannot derive state encryption key from an empty secret`)
+
+	c.Assert(ioutil.WriteFile(keyFile, []byte("short"), 0600), IsNil)
+	_, err = state.EncryptionKey()
+	c.Check(err, ErrorMatches, `cannot use state encryption key: invalid length 5`)
+}
+
+func (s *cryptSuite) TestEncryptDecryptData(c *C) {
+	key := bytes.Repeat([]byte{1}, 32)
+	encrypted, err := state.EncryptData(srcStateContent, key)
+	c.Assert(err, IsNil)
+	c.Check(state.IsEncryptedData(encrypted), Equals, true)
+	c.Check(string(encrypted), Not(testutil.Contains), "store-macaroon")
+	// other entries are kept in the clear
+	c.Check(string(encrypted), testutil.Contains, `"api-download-tokens-secret-time"`)
+
+	_, err = state.DecryptData(encrypted, nil)
+	c.Check(err, ErrorMatches, `cannot decrypt state: no state encryption key available`)
+	_, err = state.DecryptData(encrypted, bytes.Repeat([]byte{2}, 32))
+	c.Check(err, ErrorMatches, `cannot decrypt state entry "auth": cipher: message authentication failed`)
+
+	decrypted, err := state.DecryptData(encrypted, key)
+	c.Assert(err, IsNil)
+	c.Check(state.IsEncryptedData(decrypted), Equals, false)
+	c.Check(string(decrypted), testutil.Contains, `"store-macaroon":"5678"`)
+}
+
+func (s *cryptSuite) TestCopyEncryptedState(c *C) {
+	c.Assert(state.ProvideEncryptionKey([]byte("disk-unlock-key")), IsNil)
+	key, err := state.EncryptionKey()
+	c.Assert(err, IsNil)
+	encrypted, err := state.EncryptData(srcStateContent, key)
+	c.Assert(err, IsNil)
+
+	srcStateFile := filepath.Join(c.MkDir(), "src-state.json")
+	c.Assert(ioutil.WriteFile(srcStateFile, encrypted, 0600), IsNil)
+
+	dstStateFile := filepath.Join(c.MkDir(), "dst-state.json")
+	err = state.CopyState(srcStateFile, dstStateFile, []string{"auth.users", "auth.last-id"})
+	c.Assert(err, IsNil)
+	c.Check(dstStateFile, testutil.FileEquals, `{"data":{"auth":{"last-id":1,"users":[{"id":1,"email":"some@user.com","macaroon":"1234","store-macaroon":"5678","store-discharges":["9012345"]}]}}`+stateSuffix)
+
+	// without the key the state cannot be copied
+	c.Assert(os.Remove(filepath.Join(dirs.SnapBootstrapRunDir, "state-encryption.key")), IsNil)
+	err = state.CopyState(srcStateFile, filepath.Join(c.MkDir(), "dst-state.json"), []string{"auth.users"})
+	c.Check(err, ErrorMatches, `cannot decrypt state: no state encryption key available`)
+}
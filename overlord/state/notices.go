@@ -0,0 +1,363 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// NoticeType is the type of a notice.
+type NoticeType string
+
+const (
+	// InterfaceDenialNotice is recorded when the sandbox of a snap denied
+	// an operation of one of its apps. The key is made of the name of the
+	// snap and of the interface the denial was attributed to.
+	InterfaceDenialNotice NoticeType = "interface-denial"
+
+	// LaunchDenialNotice is recorded when the session launcher refused,
+	// as mandated by the launch policy, to open a URL or a file on behalf
+	// of a snap. The key is the name of the snap.
+	LaunchDenialNotice NoticeType = "launch-denial"
+
+	// SnapNotice is recorded when a snap emits a notice of its own with
+	// snapctl notify. The key is made of the name of the snap and of the
+	// key chosen by the snap, separated by a slash.
+	SnapNotice NoticeType = "snap"
+
+	// DiskHealthNotice is recorded when the health of a disk backing the
+	// system crossed one of the configured warning thresholds. The key
+	// is the kernel name of the disk.
+	DiskHealthNotice NoticeType = "disk-health"
+)
+
+func (t NoticeType) valid() bool {
+	switch t {
+	case InterfaceDenialNotice, LaunchDenialNotice, SnapNotice, DiskHealthNotice:
+		return true
+	}
+	return false
+}
+
+var DefaultNoticeExpireAfter = 7 * 24 * time.Hour
+
+// Notice records an occurrence of an event of interest to clients. There
+// is only one notice with the same type and key, recurring events update
+// it.
+type Notice struct {
+	// the unique identifier of the notice
+	id string
+	// the type of the notice, and the key identifying it within the type
+	noticeType NoticeType
+	key        string
+	// the first and last time the event occurred
+	firstOccurred time.Time
+	lastOccurred  time.Time
+	// the last time the notice was repeated, that is the last occurrence
+	// which was not within repeatAfter of the previous repeat
+	lastRepeated time.Time
+	// how many times the event occurred
+	occurrences int
+	// the data and structured payload of the last occurrence
+	lastData    map[string]string
+	lastPayload json.RawMessage
+	// how much time since the last repeat must elapse before another
+	// occurrence repeats the notice
+	repeatAfter time.Duration
+	// how much time since the last occurrence until the notice is dropped
+	expireAfter time.Duration
+}
+
+type jsonNotice struct {
+	ID            string            `json:"id"`
+	Type          NoticeType        `json:"type"`
+	Key           string            `json:"key"`
+	FirstOccurred time.Time         `json:"first-occurred"`
+	LastOccurred  time.Time         `json:"last-occurred"`
+	LastRepeated  time.Time         `json:"last-repeated"`
+	Occurrences   int               `json:"occurrences"`
+	LastData      map[string]string `json:"last-data,omitempty"`
+	LastPayload   json.RawMessage   `json:"last-payload,omitempty"`
+	RepeatAfter   string            `json:"repeat-after,omitempty"`
+	ExpireAfter   string            `json:"expire-after,omitempty"`
+}
+
+func (n *Notice) String() string {
+	return fmt.Sprintf("Notice %s (%s:%s)", n.id, n.noticeType, n.key)
+}
+
+// ID returns the unique identifier of the notice.
+func (n *Notice) ID() string {
+	return n.id
+}
+
+// Type returns the type of the notice.
+func (n *Notice) Type() NoticeType {
+	return n.noticeType
+}
+
+// Key returns the key of the notice, unique within its type.
+func (n *Notice) Key() string {
+	return n.key
+}
+
+// LastRepeated returns the last time the notice was repeated.
+func (n *Notice) LastRepeated() time.Time {
+	return n.lastRepeated
+}
+
+// Occurrences returns how many times the event of the notice occurred.
+func (n *Notice) Occurrences() int {
+	return n.occurrences
+}
+
+// LastData returns the data of the last occurrence of the event.
+func (n *Notice) LastData() map[string]string {
+	return n.lastData
+}
+
+// LastPayload returns the structured payload of the last occurrence of the
+// event, if any.
+func (n *Notice) LastPayload() json.RawMessage {
+	return n.lastPayload
+}
+
+func (n *Notice) MarshalJSON() ([]byte, error) {
+	jn := jsonNotice{
+		ID:            n.id,
+		Type:          n.noticeType,
+		Key:           n.key,
+		FirstOccurred: n.firstOccurred,
+		LastOccurred:  n.lastOccurred,
+		LastRepeated:  n.lastRepeated,
+		Occurrences:   n.occurrences,
+		LastData:      n.lastData,
+		LastPayload:   n.lastPayload,
+	}
+	if n.repeatAfter != 0 {
+		jn.RepeatAfter = n.repeatAfter.String()
+	}
+	if n.expireAfter != 0 {
+		jn.ExpireAfter = n.expireAfter.String()
+	}
+	return json.Marshal(jn)
+}
+
+func (n *Notice) UnmarshalJSON(data []byte) error {
+	var jn jsonNotice
+	if err := json.Unmarshal(data, &jn); err != nil {
+		return err
+	}
+	n.id = jn.ID
+	n.noticeType = jn.Type
+	n.key = jn.Key
+	n.firstOccurred = jn.FirstOccurred
+	n.lastOccurred = jn.LastOccurred
+	n.lastRepeated = jn.LastRepeated
+	n.occurrences = jn.Occurrences
+	n.lastData = jn.LastData
+	n.lastPayload = jn.LastPayload
+	var err error
+	if jn.RepeatAfter != "" {
+		n.repeatAfter, err = time.ParseDuration(jn.RepeatAfter)
+		if err != nil {
+			return err
+		}
+	}
+	if jn.ExpireAfter != "" {
+		n.expireAfter, err = time.ParseDuration(jn.ExpireAfter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Notice) expiredBefore(t time.Time) bool {
+	return n.lastOccurred.Add(n.expireAfter).Before(t)
+}
+
+// AddNoticeOptions holds optional parameters for AddNotice.
+type AddNoticeOptions struct {
+	// Data is the data of this occurrence of the event.
+	Data map[string]string
+	// Payload is the structured payload of this occurrence of the event,
+	// it must be valid JSON.
+	Payload json.RawMessage
+	// RepeatAfter, if set, prevents the notice from being repeated by
+	// occurrences less than this much time after its last repeat.
+	RepeatAfter time.Duration
+	// Time, if set, overrides the time of the occurrence.
+	Time time.Time
+}
+
+func noticeMapKey(noticeType NoticeType, key string) string {
+	return string(noticeType) + ":" + key
+}
+
+// AddNotice records an occurrence of the event identified by the type and
+// key of the notice and returns the identifier of the notice.
+func (s *State) AddNotice(noticeType NoticeType, key string, options *AddNoticeOptions) (string, error) {
+	if options == nil {
+		options = &AddNoticeOptions{}
+	}
+	if !noticeType.valid() {
+		return "", fmt.Errorf("internal error: attempted to add notice with invalid type %q", noticeType)
+	}
+	if key == "" {
+		return "", fmt.Errorf("internal error: attempted to add %s notice with empty key", noticeType)
+	}
+	if len(options.Payload) > 0 && !json.Valid(options.Payload) {
+		return "", fmt.Errorf("internal error: attempted to add %s notice with invalid payload", noticeType)
+	}
+	s.writing()
+
+	now := options.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.UTC()
+
+	mapKey := noticeMapKey(noticeType, key)
+	n, ok := s.notices[mapKey]
+	if !ok {
+		s.lastNoticeId++
+		n = &Notice{
+			id:            strconv.Itoa(s.lastNoticeId),
+			noticeType:    noticeType,
+			key:           key,
+			firstOccurred: now,
+			lastRepeated:  now,
+			expireAfter:   DefaultNoticeExpireAfter,
+		}
+		s.notices[mapKey] = n
+	} else if now.Sub(n.lastRepeated) >= options.RepeatAfter {
+		n.lastRepeated = now
+	}
+	n.lastOccurred = now
+	n.occurrences++
+	n.lastData = options.Data
+	n.lastPayload = options.Payload
+	n.repeatAfter = options.RepeatAfter
+	return n.id, nil
+}
+
+// NoticeFilter allows clients to filter the notices returned by Notices.
+type NoticeFilter struct {
+	// Types, if set, only includes notices of one of these types.
+	Types []NoticeType
+	// Keys, if set, only includes notices with one of these keys.
+	Keys []string
+	// After, if set, only includes notices repeated after this time.
+	After time.Time
+}
+
+func (f *NoticeFilter) matches(n *Notice) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Types) > 0 && !containsType(f.Types, n.noticeType) {
+		return false
+	}
+	if len(f.Keys) > 0 && !containsKey(f.Keys, n.key) {
+		return false
+	}
+	if !f.After.IsZero() && !n.lastRepeated.After(f.After) {
+		return false
+	}
+	return true
+}
+
+func containsType(types []NoticeType, t NoticeType) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsKey(keys []string, k string) bool {
+	for _, key := range keys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
+
+type byLastRepeated []*Notice
+
+func (a byLastRepeated) Len() int      { return len(a) }
+func (a byLastRepeated) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byLastRepeated) Less(i, j int) bool {
+	if a[i].lastRepeated.Equal(a[j].lastRepeated) {
+		return a[i].id < a[j].id
+	}
+	return a[i].lastRepeated.Before(a[j].lastRepeated)
+}
+
+// Notices returns the unexpired notices matching the filter, which can be
+// nil, sorted by the time they were last repeated.
+func (s *State) Notices(filter *NoticeFilter) []*Notice {
+	s.reading()
+
+	now := time.Now()
+	var notices []*Notice
+	for _, n := range s.notices {
+		if n.expiredBefore(now) || !filter.matches(n) {
+			continue
+		}
+		notices = append(notices, n)
+	}
+	sort.Sort(byLastRepeated(notices))
+	return notices
+}
+
+// flattenNotices returns the unexpired notices as a flat list, for
+// serialising. Call with the lock held.
+func (s *State) flattenNotices() []*Notice {
+	now := time.Now()
+	flat := make([]*Notice, 0, len(s.notices))
+	for _, n := range s.notices {
+		if n.expiredBefore(now) {
+			continue
+		}
+		flat = append(flat, n)
+	}
+	return flat
+}
+
+// unflattenNotices replaces the notices with the given flat list, ignoring
+// expired notices. Call with the lock held.
+func (s *State) unflattenNotices(flat []*Notice) {
+	now := time.Now()
+	s.notices = make(map[string]*Notice, len(flat))
+	for _, n := range flat {
+		if n.expiredBefore(now) {
+			continue
+		}
+		s.notices[noticeMapKey(n.noticeType, n.key)] = n
+	}
+}
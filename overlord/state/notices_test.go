@@ -0,0 +1,187 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2023 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func (stateSuite) TestAddNotice(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t0 := time.Now().UTC()
+	id, err := st.AddNotice(state.InterfaceDenialNotice, "foo/home", &state.AddNoticeOptions{
+		Data:        map[string]string{"path": "/home/a"},
+		RepeatAfter: time.Minute,
+		Time:        t0,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "1")
+
+	// within repeat-after the notice is not repeated
+	id, err = st.AddNotice(state.InterfaceDenialNotice, "foo/home", &state.AddNoticeOptions{
+		Data:        map[string]string{"path": "/home/b"},
+		RepeatAfter: time.Minute,
+		Time:        t0.Add(time.Second),
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "1")
+
+	notices := st.Notices(nil)
+	c.Assert(notices, check.HasLen, 1)
+	n := notices[0]
+	c.Check(n.ID(), check.Equals, "1")
+	c.Check(n.Type(), check.Equals, state.InterfaceDenialNotice)
+	c.Check(n.Key(), check.Equals, "foo/home")
+	c.Check(n.Occurrences(), check.Equals, 2)
+	c.Check(n.LastRepeated().Equal(t0), check.Equals, true)
+	c.Check(n.LastData(), check.DeepEquals, map[string]string{"path": "/home/b"})
+
+	// after repeat-after it is
+	_, err = st.AddNotice(state.InterfaceDenialNotice, "foo/home", &state.AddNoticeOptions{
+		RepeatAfter: time.Minute,
+		Time:        t0.Add(2 * time.Minute),
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(n.Occurrences(), check.Equals, 3)
+	c.Check(n.LastRepeated().Equal(t0.Add(2*time.Minute)), check.Equals, true)
+
+	// another key is another notice
+	id, err = st.AddNotice(state.InterfaceDenialNotice, "bar/", nil)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "2")
+	c.Check(st.Notices(nil), check.HasLen, 2)
+}
+
+func (stateSuite) TestAddNoticeErrors(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := st.AddNotice("bogus", "foo", nil)
+	c.Check(err, check.ErrorMatches, `internal error: attempted to add notice with invalid type "bogus"`)
+	_, err = st.AddNotice(state.InterfaceDenialNotice, "", nil)
+	c.Check(err, check.ErrorMatches, `internal error: attempted to add interface-denial notice with empty key`)
+	_, err = st.AddNotice(state.SnapNotice, "foo/door.opened", &state.AddNoticeOptions{
+		Payload: json.RawMessage(`{"door":`),
+	})
+	c.Check(err, check.ErrorMatches, `internal error: attempted to add snap notice with invalid payload`)
+}
+
+func (stateSuite) TestAddNoticePayload(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := st.AddNotice(state.SnapNotice, "foo/door.opened", &state.AddNoticeOptions{
+		Payload: json.RawMessage(`{"door":"front"}`),
+	})
+	c.Assert(err, check.IsNil)
+
+	notices := st.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapNotice}})
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(string(notices[0].LastPayload()), check.Equals, `{"door":"front"}`)
+
+	// the payload is of the last occurrence only
+	_, err = st.AddNotice(state.SnapNotice, "foo/door.opened", nil)
+	c.Assert(err, check.IsNil)
+	c.Check(notices[0].LastPayload(), check.IsNil)
+}
+
+func (stateSuite) TestNoticesFilter(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t0 := time.Now().UTC().Add(-time.Hour)
+	for i, key := range []string{"foo/home", "bar/camera", "foo/network"} {
+		_, err := st.AddNotice(state.InterfaceDenialNotice, key, &state.AddNoticeOptions{
+			Time: t0.Add(time.Duration(i) * time.Minute),
+		})
+		c.Assert(err, check.IsNil)
+	}
+
+	keys := func(notices []*state.Notice) []string {
+		var ks []string
+		for _, n := range notices {
+			ks = append(ks, n.Key())
+		}
+		return ks
+	}
+
+	c.Check(keys(st.Notices(nil)), check.DeepEquals, []string{"foo/home", "bar/camera", "foo/network"})
+	c.Check(keys(st.Notices(&state.NoticeFilter{
+		Types: []state.NoticeType{state.InterfaceDenialNotice},
+		Keys:  []string{"foo/network", "foo/home"},
+	})), check.DeepEquals, []string{"foo/home", "foo/network"})
+	c.Check(keys(st.Notices(&state.NoticeFilter{
+		After: t0,
+	})), check.DeepEquals, []string{"bar/camera", "foo/network"})
+	c.Check(st.Notices(&state.NoticeFilter{
+		Types: []state.NoticeType{"other"},
+	}), check.HasLen, 0)
+}
+
+func (stateSuite) TestNoticesRoundTrip(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	_, err := st.AddNotice(state.InterfaceDenialNotice, "foo/home", &state.AddNoticeOptions{
+		Data:        map[string]string{"path": "/home/a"},
+		RepeatAfter: time.Minute,
+	})
+	c.Assert(err, check.IsNil)
+	_, err = st.AddNotice(state.SnapNotice, "foo/door.opened", &state.AddNoticeOptions{
+		Payload: json.RawMessage(`{"door":"front"}`),
+	})
+	c.Assert(err, check.IsNil)
+	// an expired notice is dropped
+	_, err = st.AddNotice(state.InterfaceDenialNotice, "foo/old", &state.AddNoticeOptions{
+		Time: time.Now().Add(-state.DefaultNoticeExpireAfter - time.Hour),
+	})
+	c.Assert(err, check.IsNil)
+	buf, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	st2, err := state.ReadState(nil, bytes.NewReader(buf))
+	c.Assert(err, check.IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+
+	notices := st2.Notices(nil)
+	c.Assert(notices, check.HasLen, 2)
+	c.Check(notices[0].Key(), check.Equals, "foo/home")
+	c.Check(notices[0].LastData(), check.DeepEquals, map[string]string{"path": "/home/a"})
+	c.Check(notices[1].Key(), check.Equals, "foo/door.opened")
+	c.Check(string(notices[1].LastPayload()), check.Equals, `{"door":"front"}`)
+
+	// identifiers are not reused
+	id, err := st2.AddNotice(state.InterfaceDenialNotice, "bar/", nil)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "4")
+}
@@ -87,12 +87,14 @@ type State struct {
 	lastTaskId   int
 	lastChangeId int
 	lastLaneId   int
+	lastNoticeId int
 
 	backend  Backend
 	data     customData
 	changes  map[string]*Change
 	tasks    map[string]*Task
 	warnings map[string]*Warning
+	notices  map[string]*Notice
 
 	modified bool
 
@@ -109,6 +111,7 @@ func New(backend Backend) *State {
 		changes:             make(map[string]*Change),
 		tasks:               make(map[string]*Task),
 		warnings:            make(map[string]*Warning),
+		notices:             make(map[string]*Notice),
 		modified:            true,
 		cache:               make(map[interface{}]interface{}),
 		pendingChangeByAttr: make(map[string]func(*Change) bool),
@@ -149,10 +152,12 @@ type marshalledState struct {
 	Changes  map[string]*Change          `json:"changes"`
 	Tasks    map[string]*Task            `json:"tasks"`
 	Warnings []*Warning                  `json:"warnings,omitempty"`
+	Notices  []*Notice                   `json:"notices,omitempty"`
 
 	LastChangeId int `json:"last-change-id"`
 	LastTaskId   int `json:"last-task-id"`
 	LastLaneId   int `json:"last-lane-id"`
+	LastNoticeId int `json:"last-notice-id,omitempty"`
 }
 
 // MarshalJSON makes State a json.Marshaller
@@ -163,10 +168,12 @@ func (s *State) MarshalJSON() ([]byte, error) {
 		Changes:  s.changes,
 		Tasks:    s.tasks,
 		Warnings: s.flattenWarnings(),
+		Notices:  s.flattenNotices(),
 
 		LastTaskId:   s.lastTaskId,
 		LastChangeId: s.lastChangeId,
 		LastLaneId:   s.lastLaneId,
+		LastNoticeId: s.lastNoticeId,
 	})
 }
 
@@ -182,9 +189,11 @@ func (s *State) UnmarshalJSON(data []byte) error {
 	s.changes = unmarshalled.Changes
 	s.tasks = unmarshalled.Tasks
 	s.unflattenWarnings(unmarshalled.Warnings)
+	s.unflattenNotices(unmarshalled.Notices)
 	s.lastChangeId = unmarshalled.LastChangeId
 	s.lastTaskId = unmarshalled.LastTaskId
 	s.lastLaneId = unmarshalled.LastLaneId
+	s.lastNoticeId = unmarshalled.LastNoticeId
 	// backlink state again
 	for _, t := range s.tasks {
 		t.state = s
@@ -405,7 +414,7 @@ func (s *State) RegisterPendingChangeByAttr(attr string, f func(*Change) bool) {
 //    changes than the limit set via "maxReadyChanges" those changes in ready
 //    state will also removed even if they are below the pruneWait duration.
 //
-//  * it removes expired warnings.
+//  * it removes expired warnings and notices.
 func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Duration, maxReadyChanges int) {
 	now := time.Now()
 	pruneLimit := now.Add(-pruneWait)
@@ -432,6 +441,11 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 			delete(s.warnings, k)
 		}
 	}
+	for k, n := range s.notices {
+		if n.expiredBefore(now) {
+			delete(s.notices, k)
+		}
+	}
 
 NextChange:
 	for _, chg := range changes {
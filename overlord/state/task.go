@@ -31,6 +31,10 @@ type progress struct {
 	Label string `json:"label"`
 	Done  int    `json:"done"`
 	Total int    `json:"total"`
+	Unit  string `json:"unit,omitempty"`
+	// Start is when progress started being reported for the current
+	// label and total
+	Start time.Time `json:"start"`
 }
 
 // Task represents an individual operation to be performed
@@ -266,6 +270,13 @@ func (t *Task) Progress() (label string, done, total int) {
 
 // SetProgress sets the task progress to cur out of total steps.
 func (t *Task) SetProgress(label string, done, total int) {
+	t.SetProgressInUnits(label, done, total, "")
+}
+
+// SetProgressInUnits sets the task progress to done out of total of the
+// given unit, for example "bytes" when downloading. An empty unit means
+// steps.
+func (t *Task) SetProgressInUnits(label string, done, total int, unit string) {
 	// Only mark state for checkpointing if progress is final.
 	if total > 0 && done == total {
 		t.state.writing()
@@ -276,8 +287,23 @@ func (t *Task) SetProgress(label string, done, total int) {
 		// Doing math wrong is easy. Be conservative.
 		t.progress = nil
 	} else {
-		t.progress = &progress{Label: label, Done: done, Total: total}
+		start := timeNow()
+		if t.progress != nil && t.progress.Label == label && t.progress.Total == total && t.progress.Done <= done {
+			start = t.progress.Start
+		}
+		t.progress = &progress{Label: label, Done: done, Total: total, Unit: unit, Start: start}
+	}
+}
+
+// ProgressDetails returns the unit of the task progress, empty for steps,
+// and when progress started being reported for the current label and
+// total. The start time is zero if no progress was set.
+func (t *Task) ProgressDetails() (unit string, start time.Time) {
+	t.state.reading()
+	if t.progress == nil {
+		return "", time.Time{}
 	}
+	return t.progress.Unit, t.progress.Start
 }
 
 // SpawnTime returns the time when the change was created.
@@ -373,6 +399,29 @@ func (t *Task) Errorf(format string, args ...interface{}) {
 	t.addLog(LogError, format, args)
 }
 
+// ErrorDetails describes in a machine-parseable way why a task failed.
+type ErrorDetails struct {
+	// Kind classifies the error, e.g. "no-space" or "hook-failed".
+	Kind string `json:"kind"`
+	// Value holds further kind-specific details about the error.
+	Value map[string]interface{} `json:"value,omitempty"`
+}
+
+// ErrorDetails returns the details about the error that made the task
+// fail, as classified by the task runner, or nil if the task did not fail
+// or the error is not known.
+func (t *Task) ErrorDetails() *ErrorDetails {
+	t.state.reading()
+	if t.Status() != ErrorStatus {
+		return nil
+	}
+	var details ErrorDetails
+	if err := t.data.get("error-details", &details); err != nil {
+		return nil
+	}
+	return &details
+}
+
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (t *Task) Set(key string, value interface{}) {
@@ -235,6 +235,41 @@ func (ts *taskSuite) TestProgressAndSetProgress(c *C) {
 	c.Check(tot, Equals, 42)
 }
 
+func (ts *taskSuite) TestSetProgressInUnits(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t := st.NewTask("download", "1...")
+	unit, start := t.ProgressDetails()
+	c.Check(unit, Equals, "")
+	c.Check(start.IsZero(), Equals, true)
+
+	t0 := time.Date(2024, 10, 17, 12, 0, 0, 0, time.UTC)
+	restore := state.MockTime(t0)
+	defer restore()
+	t.SetProgressInUnits("snap", 0, 1000, "bytes")
+
+	// the start is kept while progressing
+	state.MockTime(t0.Add(time.Minute))
+	t.SetProgressInUnits("snap", 500, 1000, "bytes")
+	label, cur, tot := t.Progress()
+	c.Check(label, Equals, "snap")
+	c.Check(cur, Equals, 500)
+	c.Check(tot, Equals, 1000)
+	unit, start = t.ProgressDetails()
+	c.Check(unit, Equals, "bytes")
+	c.Check(start.Equal(t0), Equals, true)
+
+	c.Check(jsonStr(t), testutil.Contains, `"progress":{"label":"snap","done":500,"total":1000,"unit":"bytes","start":"2024-10-17T12:00:00Z"}`)
+
+	// but is reset when the progress starts over
+	t.SetProgress("other", 1, 10)
+	unit, start = t.ProgressDetails()
+	c.Check(unit, Equals, "")
+	c.Check(start.Equal(t0.Add(time.Minute)), Equals, true)
+}
+
 func (ts *taskSuite) TestProgressDefaults(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -40,6 +40,11 @@ type HandlerFunc func(task *Task, tomb *tomb.Tomb) error
 type Retry struct {
 	After  time.Duration
 	Reason string
+
+	// nothingDone is set when the handler stopped before doing any
+	// work, in which case there is nothing to undo if the task was
+	// aborted.
+	nothingDone bool
 }
 
 func (r *Retry) Error() string {
@@ -74,9 +79,15 @@ type TaskRunner struct {
 	blocked     []blockedFunc
 	someBlocked bool
 
+	// task kinds whose handlers stop promptly when aborted
+	abortableMu sync.Mutex
+	abortable   map[string]bool
+
 	// optional callback executed on task errors
 	taskErrorCallback func(err error)
 
+	errorClassifiers []ErrorClassifier
+
 	// go-routines lifecycle
 	tombs map[string]*tomb.Tomb
 }
@@ -93,10 +104,11 @@ type optionalHandler struct {
 // NewTaskRunner creates a new TaskRunner
 func NewTaskRunner(s *State) *TaskRunner {
 	return &TaskRunner{
-		state:    s,
-		handlers: make(map[string]handlerPair),
-		cleanups: make(map[string]HandlerFunc),
-		tombs:    make(map[string]*tomb.Tomb),
+		state:     s,
+		handlers:  make(map[string]handlerPair),
+		cleanups:  make(map[string]HandlerFunc),
+		tombs:     make(map[string]*tomb.Tomb),
+		abortable: make(map[string]bool),
 	}
 }
 
@@ -105,6 +117,29 @@ func (r *TaskRunner) OnTaskError(f func(err error)) {
 	r.taskErrorCallback = f
 }
 
+// ErrorClassifier is the type of function used to classify the error that
+// made a task fail. It returns nil if it does not know about the error.
+type ErrorClassifier func(t *Task, err error) *ErrorDetails
+
+// AddErrorClassifier adds a function to classify the errors that make tasks
+// fail. The details returned by the first classifier that knows about an
+// error are recorded in the failed task, see Task.ErrorDetails.
+func (r *TaskRunner) AddErrorClassifier(classify ErrorClassifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errorClassifiers = append(r.errorClassifiers, classify)
+}
+
+func (r *TaskRunner) classifyError(t *Task, err error) {
+	for _, classify := range r.errorClassifiers {
+		if details := classify(t, err); details != nil {
+			t.Set("error-details", details)
+			return
+		}
+	}
+}
+
 // AddHandler registers the functions to concurrently call for doing and
 // undoing tasks of the given kind. The undo handler may be nil.
 func (r *TaskRunner) AddHandler(kind string, do, undo HandlerFunc) {
@@ -114,6 +149,63 @@ func (r *TaskRunner) AddHandler(kind string, do, undo HandlerFunc) {
 	r.handlers[kind] = handlerPair{do, undo}
 }
 
+// AddAbortable declares that the do handlers of tasks of the given kinds
+// check for abort cooperatively while running, using Checkpoint, so that
+// aborting their change interrupts them promptly.
+func (r *TaskRunner) AddAbortable(kind ...string) {
+	r.abortableMu.Lock()
+	defer r.abortableMu.Unlock()
+
+	for _, k := range kind {
+		r.abortable[k] = true
+	}
+}
+
+// Abortable returns whether aborting the change of the given task would
+// take effect on it promptly. That is the case for tasks which have not
+// started yet, or are waiting, and for running tasks of a kind declared
+// with AddAbortable.
+// It must be called with the state lock held.
+func (r *TaskRunner) Abortable(t *Task) bool {
+	switch t.Status() {
+	case DoStatus, WaitStatus:
+		return true
+	case DoingStatus:
+		r.abortableMu.Lock()
+		defer r.abortableMu.Unlock()
+		return r.abortable[t.Kind()]
+	}
+	return false
+}
+
+// Checkpoint is meant to be called by long running task handlers between
+// steps of their work. It returns a *Retry error if the handler was asked to
+// stop, either because its task was aborted or because the task runner is
+// stopping, and nil otherwise. Returning that error right away lets the task
+// runner undo the task or run it again later as appropriate.
+func Checkpoint(tb *tomb.Tomb) error {
+	if tb == nil {
+		return nil
+	}
+	select {
+	case <-tb.Dying():
+		return &Retry{Reason: "task was asked to stop"}
+	default:
+		return nil
+	}
+}
+
+// CheckpointBeforeWork is like Checkpoint but is meant to be called by task
+// handlers before they have done any work which would need undoing. If the
+// task was aborted it is then put on hold rather than undone.
+func CheckpointBeforeWork(tb *tomb.Tomb) error {
+	err := Checkpoint(tb)
+	if err != nil {
+		err.(*Retry).nothingDone = true
+	}
+	return err
+}
+
 // AddOptionalHandler register functions for doing and undoing tasks that match
 // the given predicate if no explicit handler was registered for the task kind.
 func (r *TaskRunner) AddOptionalHandler(match func(t *Task) bool, do, undo HandlerFunc) {
@@ -247,7 +339,11 @@ func (r *TaskRunner) run(t *Task) {
 		switch x := err.(type) {
 		case *Retry:
 			// Handler asked to be called again later.
-			if t.Status() == AbortStatus {
+			if t.Status() == AbortStatus && x.nothingDone {
+				// Stopped before doing anything, hold so it
+				// does not look like it finished.
+				r.hold(t)
+			} else if t.Status() == AbortStatus {
 				// Would work without it but might take two ensures.
 				r.tryUndo(t)
 			} else if x.After != 0 {
@@ -285,6 +381,7 @@ func (r *TaskRunner) run(t *Task) {
 			r.abortLanes(t.Change(), t.Lanes())
 			t.SetStatus(ErrorStatus)
 			t.Errorf("%s", err)
+			r.classifyError(t, err)
 			// ensure the error is available in the global log too
 			logger.Noticef("[change %s %q task] failed: %v", t.Change().ID(), t.Summary(), err)
 			if r.taskErrorCallback != nil {
@@ -352,16 +449,20 @@ func (r *TaskRunner) tryUndo(t *Task) {
 	if t.Status() == AbortStatus && r.handlerPair(t).undo == nil {
 		// Cannot undo but it was stopped in flight.
 		// Hold so it doesn't look like it finished.
-		t.SetStatus(HoldStatus)
-		if len(t.WaitTasks()) > 0 {
-			r.state.EnsureBefore(0)
-		}
+		r.hold(t)
 	} else {
 		t.SetStatus(UndoStatus)
 		r.state.EnsureBefore(0)
 	}
 }
 
+func (r *TaskRunner) hold(t *Task) {
+	t.SetStatus(HoldStatus)
+	if len(t.WaitTasks()) > 0 {
+		r.state.EnsureBefore(0)
+	}
+}
+
 // Ensure starts new goroutines for all known tasks with no pending
 // dependencies.
 // Note that Ensure will lock the state.
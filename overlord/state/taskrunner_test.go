@@ -749,6 +749,139 @@ func (ts *taskRunnerSuite) TestStopKinds(c *C) {
 	c.Check(t2.Status(), Equals, state.DoneStatus)
 }
 
+func (ts *taskRunnerSuite) TestCheckpointOnAbortUndoes(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	ch := make(chan bool)
+	var undone bool
+	r.AddHandler("long-running", func(t *state.Task, tb *tomb.Tomb) error {
+		c.Check(state.Checkpoint(tb), IsNil)
+		ch <- true
+		<-tb.Dying()
+		return state.Checkpoint(tb)
+	}, func(t *state.Task, tb *tomb.Tomb) error {
+		undone = true
+		return nil
+	})
+	r.AddAbortable("long-running")
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("long-running", "...")
+	chg.AddTask(t)
+	c.Check(r.Abortable(t), Equals, true)
+	st.Unlock()
+
+	r.Ensure()
+	<-ch
+
+	st.Lock()
+	c.Check(t.Status(), Equals, state.DoingStatus)
+	c.Check(r.Abortable(t), Equals, true)
+	chg.Abort()
+	st.Unlock()
+
+	// kills the running handler
+	r.Ensure()
+	r.Wait()
+	// runs the undo handler
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(undone, Equals, true)
+	c.Check(t.Status(), Equals, state.UndoneStatus)
+	c.Check(r.Abortable(t), Equals, false)
+}
+
+func (ts *taskRunnerSuite) TestCheckpointBeforeWorkOnAbortHolds(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	c.Check(state.CheckpointBeforeWork(nil), IsNil)
+
+	ch := make(chan bool)
+	var undone bool
+	r.AddHandler("long-running", func(t *state.Task, tb *tomb.Tomb) error {
+		ch <- true
+		<-tb.Dying()
+		return state.CheckpointBeforeWork(tb)
+	}, func(t *state.Task, tb *tomb.Tomb) error {
+		undone = true
+		return nil
+	})
+	r.AddAbortable("long-running")
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("long-running", "...")
+	chg.AddTask(t)
+	st.Unlock()
+
+	r.Ensure()
+	<-ch
+
+	st.Lock()
+	chg.Abort()
+	st.Unlock()
+
+	// kills the running handler
+	r.Ensure()
+	r.Wait()
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	defer st.Unlock()
+	// nothing was done, so there was nothing to undo
+	c.Check(undone, Equals, false)
+	c.Check(t.Status(), Equals, state.HoldStatus)
+	c.Check(chg.Status(), Equals, state.HoldStatus)
+}
+
+func (ts *taskRunnerSuite) TestCheckpointOnStopIsRetried(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	c.Check(state.Checkpoint(nil), IsNil)
+
+	ch := make(chan bool)
+	r.AddHandler("long-running", func(t *state.Task, tb *tomb.Tomb) error {
+		ch <- true
+		<-tb.Dying()
+		return state.Checkpoint(tb)
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("long-running", "...")
+	chg.AddTask(t)
+	st.Unlock()
+
+	r.Ensure()
+	<-ch
+
+	st.Lock()
+	// not declared as abortable
+	c.Check(r.Abortable(t), Equals, false)
+	st.Unlock()
+
+	r.Stop()
+
+	st.Lock()
+	defer st.Unlock()
+	// still Doing, will be retried
+	c.Check(t.Status(), Equals, state.DoingStatus)
+}
+
 func (ts *taskRunnerSuite) TestErrorsOnStopAreRetried(c *C) {
 	sb := &stateBackend{}
 	st := state.New(sb)
@@ -1317,3 +1450,57 @@ func (ts *taskRunnerSuite) TestErrorCallbackNotCalled(c *C) {
 	c.Check(t1.Status(), Equals, state.DoneStatus)
 	c.Check(called, Equals, false)
 }
+
+var errNoSpaceForTest = errors.New("no space")
+
+func (ts *taskRunnerSuite) TestErrorClassifiers(c *C) {
+	sb := &stateBackend{}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+
+	var classified []string
+	r.AddErrorClassifier(func(t *state.Task, err error) *state.ErrorDetails {
+		classified = append(classified, "first:"+t.Kind())
+		return nil
+	})
+	r.AddErrorClassifier(func(t *state.Task, err error) *state.ErrorDetails {
+		classified = append(classified, "second:"+t.Kind())
+		if err == errNoSpaceForTest {
+			return &state.ErrorDetails{Kind: "no-space", Value: map[string]interface{}{"path": "/foo"}}
+		}
+		return nil
+	})
+	r.AddErrorClassifier(func(t *state.Task, err error) *state.ErrorDetails {
+		classified = append(classified, "third:"+t.Kind())
+		return &state.ErrorDetails{Kind: "other"}
+	})
+
+	r.AddHandler("foo", func(t *state.Task, tomb *tomb.Tomb) error {
+		return errNoSpaceForTest
+	}, nil)
+	r.AddHandler("bar", func(t *state.Task, tomb *tomb.Tomb) error {
+		return nil
+	}, nil)
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("foo", "...")
+	chg.AddTask(t1)
+	t2 := st.NewTask("bar", "...")
+	chg.AddTask(t2)
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg)
+	r.Stop()
+
+	st.Lock()
+	defer st.Unlock()
+
+	c.Check(t1.Status(), Equals, state.ErrorStatus)
+	c.Check(t1.ErrorDetails(), DeepEquals, &state.ErrorDetails{
+		Kind:  "no-space",
+		Value: map[string]interface{}{"path": "/foo"},
+	})
+	c.Check(t2.ErrorDetails(), IsNil)
+	c.Check(classified, DeepEquals, []string{"first:foo", "second:foo"})
+}
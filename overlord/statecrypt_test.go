@@ -0,0 +1,182 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type stateCryptSuite struct {
+	testutil.BaseTest
+
+	key []byte
+}
+
+var _ = Suite(&stateCryptSuite{})
+
+func (s *stateCryptSuite) SetUpTest(c *C) {
+	tmpdir := c.MkDir()
+	dirs.SetRootDir(tmpdir)
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(osutil.MockMountInfo(""))
+
+	dirs.SnapStateFile = filepath.Join(tmpdir, "test.json")
+	snapstate.CanAutoRefresh = nil
+	s.AddCleanup(func() { ifacestate.MockSecurityBackends(nil) })
+
+	s.key = bytes.Repeat([]byte{1}, 32)
+	s.AddCleanup(overlord.MockStateEncryptionKey(func() ([]byte, error) {
+		if s.key == nil {
+			return nil, state.ErrNoEncryptionKey
+		}
+		return s.key, nil
+	}))
+}
+
+func (s *stateCryptSuite) enableFeature(c *C, enabled bool) {
+	if enabled {
+		c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+		c.Assert(ioutil.WriteFile(features.EncryptedState.ControlFile(), nil, 0644), IsNil)
+	} else {
+		c.Assert(os.RemoveAll(features.EncryptedState.ControlFile()), IsNil)
+	}
+}
+
+func (s *stateCryptSuite) newOverlordWithUser(c *C) {
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	o.InterfaceManager().DisableUDevMonitor()
+	defer o.Stop()
+
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	_, err = auth.NewUser(st, auth.NewUserParams{
+		Username:   "user",
+		Email:      "user@example.com",
+		Macaroon:   "secret-macaroon",
+		Discharges: []string{"secret-discharge"},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *stateCryptSuite) checkUser(c *C) {
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	o.InterfaceManager().DisableUDevMonitor()
+	defer o.Stop()
+
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	user, err := auth.User(st, 1)
+	c.Assert(err, IsNil)
+	c.Check(user.StoreMacaroon, Equals, "secret-macaroon")
+	c.Check(user.StoreDischarges, DeepEquals, []string{"secret-discharge"})
+}
+
+func (s *stateCryptSuite) TestEncryptedStateRoundTrip(c *C) {
+	s.enableFeature(c, true)
+	s.newOverlordWithUser(c)
+
+	data, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Not(testutil.Contains), "secret-macaroon")
+	c.Check(string(data), Not(testutil.Contains), "secret-discharge")
+
+	var st struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	c.Assert(json.Unmarshal(data, &st), IsNil)
+	var entry map[string]interface{}
+	c.Assert(json.Unmarshal(st.Data["auth"], &entry), IsNil)
+	c.Check(entry["cipher"], Equals, "aes-256-gcm")
+	// other entries are kept in the clear
+	c.Check(st.Data["patch-level"], NotNil)
+
+	s.checkUser(c)
+
+	// the state can still be read once the feature is disabled
+	s.enableFeature(c, false)
+	s.checkUser(c)
+}
+
+func (s *stateCryptSuite) TestEncryptedStateDisabled(c *C) {
+	s.newOverlordWithUser(c)
+
+	c.Check(dirs.SnapStateFile, testutil.FileContains, "secret-macaroon")
+	s.checkUser(c)
+}
+
+func (s *stateCryptSuite) TestEncryptedStateNoKey(c *C) {
+	s.newOverlordWithUser(c)
+
+	// without a key snapd refuses to run rather than keep the state in
+	// the clear
+	s.enableFeature(c, true)
+	s.key = nil
+	_, err := overlord.New(nil)
+	c.Assert(err, ErrorMatches, `cannot encrypt state: no state encryption key available`)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, "secret-macaroon")
+}
+
+func (s *stateCryptSuite) TestEncryptedStateWrongKey(c *C) {
+	s.enableFeature(c, true)
+	s.newOverlordWithUser(c)
+
+	s.key = bytes.Repeat([]byte{2}, 32)
+	_, err := overlord.New(nil)
+	c.Assert(err, ErrorMatches, `cannot decrypt state entry "auth": cipher: message authentication failed`)
+}
+
+func (s *stateCryptSuite) TestEncryptedStateKeyGone(c *C) {
+	s.enableFeature(c, true)
+	s.newOverlordWithUser(c)
+
+	s.key = nil
+	_, err := overlord.New(nil)
+	c.Assert(err, ErrorMatches, `cannot encrypt state: no state encryption key available`)
+}
+
+func (s *stateCryptSuite) TestEncryptedStateKeyGoneFeatureDisabled(c *C) {
+	s.enableFeature(c, true)
+	s.newOverlordWithUser(c)
+
+	s.enableFeature(c, false)
+	s.key = nil
+	_, err := overlord.New(nil)
+	c.Assert(err, ErrorMatches, `cannot decrypt state: no state encryption key available`)
+}
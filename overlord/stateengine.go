@@ -22,6 +22,7 @@ package overlord
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/state"
@@ -42,6 +43,16 @@ type StateStarterUp interface {
 	StartUp() error
 }
 
+// StateDeferredStarterUp is optionally implemented by StateManagers that
+// have expensive initialization which does not need to be complete before
+// the API is served. It is performed after all managers started up and
+// before the first Ensure.
+type StateDeferredStarterUp interface {
+	// DeferredStartUp asks manager to perform any deferred expensive
+	// initialization.
+	DeferredStartUp() error
+}
+
 // StateWaiter is optionally implemented by StateManagers that have running
 // activities that can be waited.
 type StateWaiter interface {
@@ -66,9 +77,10 @@ type StateStopper interface {
 // cope with Ensure calls in any order, coordinating among themselves
 // solely via the state.
 type StateEngine struct {
-	state     *state.State
-	stopped   bool
-	startedUp bool
+	state             *state.State
+	stopped           bool
+	startedUp         bool
+	deferredStartedUp bool
 	// managers in use
 	mgrLock  sync.Mutex
 	managers []StateManager
@@ -117,6 +129,35 @@ func (se *StateEngine) StartUp() error {
 	return nil
 }
 
+// DeferredStartUp asks all managers to perform any deferred expensive
+// initialization. It is a noop after the first invocation.
+func (se *StateEngine) DeferredStartUp() error {
+	se.mgrLock.Lock()
+	defer se.mgrLock.Unlock()
+	if !se.startedUp {
+		return fmt.Errorf("state engine skipped startup")
+	}
+	if se.deferredStartedUp {
+		return nil
+	}
+	se.deferredStartedUp = true
+	var errs []error
+	for _, m := range se.managers {
+		if starterUp, ok := m.(StateDeferredStarterUp); ok {
+			t0 := time.Now()
+			err := starterUp.DeferredStartUp()
+			if err != nil {
+				errs = append(errs, err)
+			}
+			logger.Debugf("deferred startup of %T took %v", m, time.Since(t0))
+		}
+	}
+	if len(errs) != 0 {
+		return &startupError{errs}
+	}
+	return nil
+}
+
 type ensureError struct {
 	errs []error
 }
@@ -107,6 +107,64 @@ func (ses *stateEngineSuite) TestStartUpError(c *C) {
 	c.Check(calls, DeepEquals, []string{"startup:mgr1", "startup:mgr2"})
 }
 
+type fakeDeferredManager struct {
+	fakeManager
+	deferredStartupError error
+}
+
+func (fm *fakeDeferredManager) DeferredStartUp() error {
+	*fm.calls = append(*fm.calls, "deferred-startup:"+fm.name)
+	return fm.deferredStartupError
+}
+
+func (ses *stateEngineSuite) TestDeferredStartUp(c *C) {
+	s := state.New(nil)
+	se := overlord.NewStateEngine(s)
+
+	calls := []string{}
+
+	mgr1 := &fakeManager{name: "mgr1", calls: &calls}
+	mgr2 := &fakeDeferredManager{fakeManager: fakeManager{name: "mgr2", calls: &calls}}
+
+	se.AddManager(mgr1)
+	se.AddManager(mgr2)
+
+	err := se.DeferredStartUp()
+	c.Check(err, ErrorMatches, "state engine skipped startup")
+	c.Check(calls, HasLen, 0)
+
+	err = se.StartUp()
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"startup:mgr1", "startup:mgr2"})
+
+	err = se.DeferredStartUp()
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"startup:mgr1", "startup:mgr2", "deferred-startup:mgr2"})
+
+	// noop
+	err = se.DeferredStartUp()
+	c.Assert(err, IsNil)
+	c.Check(calls, HasLen, 3)
+}
+
+func (ses *stateEngineSuite) TestDeferredStartUpError(c *C) {
+	s := state.New(nil)
+	se := overlord.NewStateEngine(s)
+
+	calls := []string{}
+
+	mgr1 := &fakeDeferredManager{fakeManager: fakeManager{name: "mgr1", calls: &calls}, deferredStartupError: errors.New("boom1")}
+	mgr2 := &fakeDeferredManager{fakeManager: fakeManager{name: "mgr2", calls: &calls}}
+
+	se.AddManager(mgr1)
+	se.AddManager(mgr2)
+
+	c.Assert(se.StartUp(), IsNil)
+	err := se.DeferredStartUp()
+	c.Check(err, ErrorMatches, `state startup errors: \[boom1\]`)
+	c.Check(calls, DeepEquals, []string{"startup:mgr1", "startup:mgr2", "deferred-startup:mgr1", "deferred-startup:mgr2"})
+}
+
 func (ses *stateEngineSuite) TestEnsure(c *C) {
 	s := state.New(nil)
 	se := overlord.NewStateEngine(s)
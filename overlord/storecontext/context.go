@@ -21,10 +21,12 @@
 package storecontext
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/sysdb"
@@ -45,6 +47,8 @@ type Backend interface {
 	DeviceSessionRequestSigner
 
 	ProxyStoreer
+
+	DeviceCertificater
 }
 
 // A DeviceBackend exposes device information and device identity
@@ -74,6 +78,18 @@ type ProxyStoreer interface {
 	ProxyStore() (*asserts.Store, error)
 }
 
+type DeviceCertificater interface {
+	// DeviceCertificate returns the device certificate obtained by
+	// enrollment with an external device management service, if any.
+	// It does not require the state lock to be held.
+	DeviceCertificate() (*tls.Certificate, error)
+	// CertificateVerificationTime returns the time as of which server
+	// certificates should be verified when the system clock is known
+	// to be wrong, or the zero time otherwise.
+	// It does not require the state lock to be held.
+	CertificateVerificationTime() time.Time
+}
+
 // storeContext implements store.DeviceAndAuthContext.
 type storeContext struct {
 	state *state.State
@@ -81,6 +97,7 @@ type storeContext struct {
 	deviceBackend    DeviceBackend
 	sessionReqSigner DeviceSessionRequestSigner
 	proxyStoreer     ProxyStoreer
+	devCertificater  DeviceCertificater
 }
 
 var _ store.DeviceAndAuthContext = (*storeContext)(nil)
@@ -90,12 +107,12 @@ func New(st *state.State, b Backend) store.DeviceAndAuthContext {
 	if b == nil {
 		panic("store context backend cannot be nil")
 	}
-	return NewComposed(st, b, b, b)
+	return NewComposed(st, b, b, b, b)
 }
 
 // NewComposed returns a store.DeviceAndAuthContext using the given backends.
-func NewComposed(st *state.State, devb DeviceBackend, srqs DeviceSessionRequestSigner, pstoer ProxyStoreer) store.DeviceAndAuthContext {
-	if devb == nil || srqs == nil || pstoer == nil {
+func NewComposed(st *state.State, devb DeviceBackend, srqs DeviceSessionRequestSigner, pstoer ProxyStoreer, devcerter DeviceCertificater) store.DeviceAndAuthContext {
+	if devb == nil || srqs == nil || pstoer == nil || devcerter == nil {
 		panic("store context composable backends cannot be nil")
 	}
 	return &storeContext{
@@ -103,6 +120,7 @@ func NewComposed(st *state.State, devb DeviceBackend, srqs DeviceSessionRequestS
 		deviceBackend:    devb,
 		sessionReqSigner: srqs,
 		proxyStoreer:     pstoer,
+		devCertificater:  devcerter,
 	}
 }
 
@@ -265,3 +283,37 @@ func (sc *storeContext) CloudInfo() (*auth.CloudInfo, error) {
 
 	return nil, nil
 }
+
+// DownloadMirror returns the base URL of the mirror of the store downloads
+// set with the download.mirror core option, if any.
+func (sc *storeContext) DownloadMirror() (*url.URL, error) {
+	sc.state.Lock()
+	defer sc.state.Unlock()
+
+	tr := config.NewTransaction(sc.state)
+	var mirror string
+	err := tr.Get("core", "download.mirror", &mirror)
+	if err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if mirror == "" {
+		return nil, nil
+	}
+
+	return url.Parse(mirror)
+}
+
+// ClientCertificate returns the TLS client certificate to present to the
+// store, if any.
+func (sc *storeContext) ClientCertificate() (*tls.Certificate, error) {
+	// the state lock is not taken as this can be called while
+	// establishing connections, with the lock held or not
+	return sc.devCertificater.DeviceCertificate()
+}
+
+// CertificateVerificationTime returns the time as of which the
+// certificates of the store should be verified, or the zero time to use
+// the system clock.
+func (sc *storeContext) CertificateVerificationTime() time.Time {
+	return sc.devCertificater.CertificateVerificationTime()
+}
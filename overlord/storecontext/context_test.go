@@ -20,6 +20,7 @@
 package storecontext_test
 
 import (
+	"crypto/tls"
 	"errors"
 	"net/url"
 	"os"
@@ -245,6 +246,24 @@ func (s *storeCtxSuite) TestCloudInfo(c *C) {
 	c.Check(cloud, DeepEquals, cloudInfo)
 }
 
+func (s *storeCtxSuite) TestDownloadMirror(c *C) {
+	storeCtx := storecontext.New(s.state, &testBackend{nothing: true})
+
+	mirror, err := storeCtx.DownloadMirror()
+	c.Assert(err, IsNil)
+	c.Check(mirror, IsNil)
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "download.mirror", "https://mirror.internal/snaps/")
+	tr.Commit()
+	s.state.Unlock()
+
+	mirror, err = storeCtx.DownloadMirror()
+	c.Assert(err, IsNil)
+	c.Check(mirror.String(), Equals, "https://mirror.internal/snaps/")
+}
+
 const (
 	exModel = `type: model
 authority-id: my-brand
@@ -307,6 +326,8 @@ type testBackend struct {
 	nothing  bool
 	noSerial bool
 	device   *auth.DeviceState
+	cert     *tls.Certificate
+	now      time.Time
 }
 
 func (b *testBackend) Device() (*auth.DeviceState, error) {
@@ -370,6 +391,42 @@ func (b *testBackend) ProxyStore() (*asserts.Store, error) {
 	return a.(*asserts.Store), nil
 }
 
+func (b *testBackend) DeviceCertificate() (*tls.Certificate, error) {
+	return b.cert, nil
+}
+
+func (b *testBackend) CertificateVerificationTime() time.Time {
+	return b.now
+}
+
+func (s *storeCtxSuite) TestClientCertificate(c *C) {
+	storeCtx := storecontext.New(s.state, &testBackend{nothing: true})
+	cert, err := storeCtx.ClientCertificate()
+	c.Assert(err, IsNil)
+	c.Check(cert, IsNil)
+
+	devCert := &tls.Certificate{Certificate: [][]byte{[]byte("cert")}}
+	storeCtx = storecontext.New(s.state, &testBackend{cert: devCert})
+	// the state lock is not needed
+	s.state.Lock()
+	defer s.state.Unlock()
+	cert, err = storeCtx.ClientCertificate()
+	c.Assert(err, IsNil)
+	c.Check(cert, Equals, devCert)
+}
+
+func (s *storeCtxSuite) TestCertificateVerificationTime(c *C) {
+	storeCtx := storecontext.New(s.state, &testBackend{nothing: true})
+	c.Check(storeCtx.CertificateVerificationTime().IsZero(), Equals, true)
+
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	storeCtx = storecontext.New(s.state, &testBackend{now: now})
+	// the state lock is not needed
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(storeCtx.CertificateVerificationTime(), Equals, now)
+}
+
 func (s *storeCtxSuite) TestMissingDeviceAssertions(c *C) {
 	// no assertions in state
 	storeCtx := storecontext.New(s.state, &testBackend{nothing: true})
@@ -467,7 +524,7 @@ func (s *storeCtxSuite) TestComposable(c *C) {
 	b := &testBackend{}
 	bNoSerial := &testBackend{noSerial: true}
 
-	storeCtx := storecontext.NewComposed(s.state, b, bNoSerial, b)
+	storeCtx := storecontext.NewComposed(s.state, b, bNoSerial, b, b)
 
 	params, err := storeCtx.DeviceSessionRequestParams("NONCE-1")
 	c.Assert(err, IsNil)
@@ -476,12 +533,12 @@ func (s *storeCtxSuite) TestComposable(c *C) {
 	c.Check(strings.Contains(req, "nonce: NONCE-1\n"), Equals, true)
 	c.Check(strings.Contains(req, "serial: 9999\n"), Equals, true)
 
-	storeCtx = storecontext.NewComposed(s.state, bNoSerial, b, b)
+	storeCtx = storecontext.NewComposed(s.state, bNoSerial, b, b, b)
 	params, err = storeCtx.DeviceSessionRequestParams("NONCE-1")
 	c.Assert(err, Equals, store.ErrNoSerial)
 
 	srqs := testFailingDeviceSessionRequestSigner{}
-	storeCtx = storecontext.NewComposed(s.state, b, srqs, b)
+	storeCtx = storecontext.NewComposed(s.state, b, srqs, b, b)
 	params, err = storeCtx.DeviceSessionRequestParams("NONCE-1")
 	c.Assert(err, ErrorMatches, "boom")
 }
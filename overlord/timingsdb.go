@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/timings"
+)
+
+var osutilBootID = osutil.BootID
+
+// persistTimings appends a summary of the changes that became ready and
+// of the ensure and startup timings saved since the last time it was
+// called to the timings database, so that they outlive the state which
+// only keeps the most recent ones.
+func (o *Overlord) persistTimings() {
+	st := o.State()
+	// state must be locked
+	var until time.Time
+	if err := st.Get("timings-db-recorded-until", &until); err != nil && !errors.Is(err, state.ErrNoState) {
+		logger.Noticef("cannot persist timings: %v", err)
+		return
+	}
+
+	bootID, err := osutilBootID()
+	if err != nil {
+		logger.Debugf("cannot get boot id for timings: %v", err)
+	}
+
+	var records []*timings.Record
+	newUntil := until
+	add := func(kind string, start time.Time, d time.Duration, mark time.Time) {
+		if !mark.After(until) {
+			return
+		}
+		records = append(records, &timings.Record{
+			Kind:      kind,
+			BootID:    bootID,
+			StartTime: start,
+			Duration:  d,
+		})
+		if mark.After(newUntil) {
+			newUntil = mark
+		}
+	}
+
+	for _, chg := range st.Changes() {
+		if !chg.IsReady() {
+			continue
+		}
+		add("change:"+chg.Kind(), chg.SpawnTime(), chg.ReadyTime().Sub(chg.SpawnTime()), chg.ReadyTime())
+	}
+
+	tms, err := timings.Get(st, 0, func(tags map[string]string) bool {
+		return tags["ensure"] != "" || tags["startup"] != ""
+	})
+	if err != nil {
+		logger.Noticef("cannot persist timings: %v", err)
+		return
+	}
+	for _, tm := range tms {
+		kind := "ensure:" + tm.Tags["ensure"]
+		if tm.Tags["startup"] != "" {
+			kind = "startup:" + tm.Tags["startup"]
+		}
+		add(kind, tm.StartTime, tm.Duration, tm.StartTime.Add(tm.Duration))
+	}
+
+	if len(records) == 0 {
+		return
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].StartTime.Before(records[j].StartTime)
+	})
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapTimingsDBFile), 0755); err != nil {
+		logger.Noticef("cannot persist timings: %v", err)
+		return
+	}
+	if err := timings.AppendRecords(dirs.SnapTimingsDBFile, records); err != nil {
+		logger.Noticef("cannot persist timings: %v", err)
+		return
+	}
+	st.Set("timings-db-recorded-until", newUntil)
+}
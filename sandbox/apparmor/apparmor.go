@@ -482,6 +482,46 @@ func UpdateHomedirsTunable(homedirs []string) error {
 	return osutilAtomicWrite(tunableFilePath, contents, 0644, 0)
 }
 
+// LaunchPolicyRulesPath is the file included, if it exists, by the
+// profiles of snaps that can use the desktop portals.
+const LaunchPolicyRulesPath = "/var/lib/snapd/apparmor/launch-policy"
+
+const launchPolicyRules = `# Generated by snapd -- DO NOT EDIT!
+# The launch policy is enforced by the session launcher of snap userd, do
+# not let snaps open URLs and files via xdg-desktop-portal instead.
+audit deny dbus (send)
+    bus=session
+    path=/org/freedesktop/portal/desktop
+    interface=org.freedesktop.portal.OpenURI,
+`
+
+// UpdateLaunchPolicyRules writes under rootdir the rules denying to snaps
+// the OpenURI portal when a launch policy is enforced, or removes them
+// otherwise. It returns whether the rules changed, in which case the
+// profiles need to be reloaded.
+func UpdateLaunchPolicyRules(rootdir string, enforced bool) (changed bool, err error) {
+	rulesPath := filepath.Join(rootdir, LaunchPolicyRulesPath)
+	if !enforced {
+		err := os.Remove(rulesPath)
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	if err := osMkdirAll(filepath.Dir(rulesPath), 0755); err != nil {
+		return false, fmt.Errorf("cannot create AppArmor rules directory: %v", err)
+	}
+	err = osutil.EnsureFileState(rulesPath, &osutil.MemoryFileState{
+		Content: []byte(launchPolicyRules),
+		Mode:    0644,
+	})
+	if err == osutil.ErrSameState {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 // mocking
 
 type mockAppArmorProbe struct {
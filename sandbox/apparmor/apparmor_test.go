@@ -492,6 +492,32 @@ func (s *apparmorSuite) TestUpdateHomedirsTunableHappyNoDirs(c *C) {
 	c.Check(osutil.FileExists(configFile), Equals, false)
 }
 
+func (s *apparmorSuite) TestUpdateLaunchPolicyRules(c *C) {
+	root := c.MkDir()
+	rulesFile := filepath.Join(root, "/var/lib/snapd/apparmor/launch-policy")
+
+	// nothing to remove
+	changed, err := apparmor.UpdateLaunchPolicyRules(root, false)
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, false)
+	c.Check(rulesFile, testutil.FileAbsent)
+
+	changed, err = apparmor.UpdateLaunchPolicyRules(root, true)
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, true)
+	c.Check(rulesFile, testutil.FileContains, "audit deny dbus (send)\n    bus=session\n    path=/org/freedesktop/portal/desktop\n    interface=org.freedesktop.portal.OpenURI,\n")
+
+	// unchanged
+	changed, err = apparmor.UpdateLaunchPolicyRules(root, true)
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, false)
+
+	changed, err = apparmor.UpdateLaunchPolicyRules(root, false)
+	c.Assert(err, IsNil)
+	c.Check(changed, Equals, true)
+	c.Check(rulesFile, testutil.FileAbsent)
+}
+
 func (s *apparmorSuite) TestSnapdAppArmorSupportsReexecImpl(c *C) {
 	fakeroot := c.MkDir()
 	dirs.SetRootDir(fakeroot)
@@ -22,6 +22,7 @@ package apparmor
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/testutil"
@@ -29,6 +30,7 @@ import (
 
 var (
 	NumberOfJobsParam = numberOfJobsParam
+	SplitProfiles     = splitProfiles
 )
 
 func MockRuntimeNumCPU(new func() int) (restore func()) {
@@ -39,6 +41,25 @@ func MockRuntimeNumCPU(new func() int) (restore func()) {
 	}
 }
 
+func MockTimeNow(f func() time.Time) (restore func()) {
+	r := testutil.Backup(&timeNow)
+	timeNow = f
+	return r
+}
+
+// MockCompileStats resets the compile statistics and stops persisting them.
+func MockCompileStats() (restore func()) {
+	compileStatsMu.Lock()
+	oldStats, oldFile := compileStats, compileStatsFile
+	compileStats, compileStatsFile = CompileStats{}, ""
+	compileStatsMu.Unlock()
+	return func() {
+		compileStatsMu.Lock()
+		defer compileStatsMu.Unlock()
+		compileStats, compileStatsFile = oldStats, oldFile
+	}
+}
+
 func MockMkdirAll(f func(string, os.FileMode) error) func() {
 	r := testutil.Backup(&osMkdirAll)
 	osMkdirAll = f
@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
@@ -53,7 +54,7 @@ const (
 
 var runtimeNumCPU = runtime.NumCPU
 
-func numberOfJobsParam() string {
+func numberOfJobs() int {
 	cpus := runtimeNumCPU()
 	// Do not use all CPUs as this may have negative impact when booting.
 	if cpus > 2 {
@@ -69,23 +70,81 @@ func numberOfJobsParam() string {
 		// -jauto) and 3.x (compile everything in the main process).
 		cpus = 1
 	}
+	return cpus
+}
+
+func numberOfJobsParam() string {
+	return fmt.Sprintf("-j%d", numberOfJobs())
+}
 
-	return fmt.Sprintf("-j%d", cpus)
+// splitProfiles splits the given profiles into at most n batches of
+// similar size, keeping their relative order.
+func splitProfiles(fnames []string, n int) [][]string {
+	if n > len(fnames) {
+		n = len(fnames)
+	}
+	batches := make([][]string, 0, n)
+	for i := 0; i < n; i++ {
+		lo := i * len(fnames) / n
+		hi := (i + 1) * len(fnames) / n
+		batches = append(batches, fnames[lo:hi])
+	}
+	return batches
 }
 
 // LoadProfiles loads apparmor profiles from the given files.
 //
 // If no such profiles were previously loaded then they are simply added to the kernel.
 // If there were some profiles with the same name before, those profiles are replaced.
+//
+// With ConserveCPU on multi-core systems the profiles are split across a
+// bounded pool of apparmor_parser invocations running in parallel, each
+// compiling its share with a single job.
 var LoadProfiles = func(fnames []string, cacheDir string, flags AaParserFlags) error {
 	if len(fnames) == 0 {
 		return nil
 	}
 
+	start := timeNow()
+	batches := [][]string{fnames}
+	jobsParam := ""
+	if flags&ConserveCPU != 0 {
+		jobs := numberOfJobs()
+		if jobs > 1 && len(fnames) > 1 {
+			batches = splitProfiles(fnames, jobs)
+			jobsParam = "-j1"
+		} else {
+			jobsParam = numberOfJobsParam()
+		}
+	}
+
+	errs := make([]error, len(batches))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch []string) {
+			defer wg.Done()
+			errs[i] = loadProfilesBatch(batch, cacheDir, jobsParam, flags)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var err error
+	for _, e := range errs {
+		if e != nil {
+			err = e
+			break
+		}
+	}
+	recordCompileStats(len(fnames), len(batches), flags, start, err)
+	return err
+}
+
+func loadProfilesBatch(fnames []string, cacheDir, jobsParam string, flags AaParserFlags) error {
 	// Use no-expr-simplify since expr-simplify is actually slower on armhf (LP: #1383858)
 	args := []string{"--replace", "--write-cache", "-O", "no-expr-simplify", fmt.Sprintf("--cache-loc=%s", cacheDir)}
-	if flags&ConserveCPU != 0 {
-		args = append(args, numberOfJobsParam())
+	if jobsParam != "" {
+		args = append(args, jobsParam)
 	}
 
 	if flags&SkipKernelLoad != 0 {
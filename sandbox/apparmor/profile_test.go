@@ -21,10 +21,13 @@ package apparmor_test
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
@@ -128,6 +131,79 @@ func (s *appArmorSuite) TestLoadProfilesRunsAppArmorParserReplaceWithSnapdDebug(
 	})
 }
 
+func (s *appArmorSuite) TestLoadProfilesConserveCPUSingleCPU(c *C) {
+	restore := apparmor.MockRuntimeNumCPU(func() int { return 1 })
+	defer restore()
+	cmd := testutil.MockCommand(c, "apparmor_parser", "")
+	defer cmd.Restore()
+	restore = apparmor.MockParserSearchPath(cmd.BinDir())
+	defer restore()
+	err := apparmor.LoadProfiles([]string{"/path/to/snap.samba.smbd", "/path/to/another.profile"}, apparmor.CacheDir, apparmor.ConserveCPU)
+	c.Assert(err, IsNil)
+	c.Assert(cmd.Calls(), DeepEquals, [][]string{
+		{"apparmor_parser", "--replace", "--write-cache", "-O", "no-expr-simplify", "--cache-loc=/var/cache/apparmor", "-j1", "--quiet", "/path/to/snap.samba.smbd", "/path/to/another.profile"},
+	})
+}
+
+func (s *appArmorSuite) TestLoadProfilesConserveCPUParallel(c *C) {
+	restore := apparmor.MockRuntimeNumCPU(func() int { return 4 })
+	defer restore()
+	// the parser runs concurrently, log each call as a single line
+	logFile := filepath.Join(c.MkDir(), "log")
+	cmd := testutil.MockCommand(c, "apparmor_parser", fmt.Sprintf(`echo "$*" >> %q`, logFile))
+	defer cmd.Restore()
+	restore = apparmor.MockParserSearchPath(cmd.BinDir())
+	defer restore()
+
+	err := apparmor.LoadProfiles([]string{"/p/1", "/p/2", "/p/3", "/p/4", "/p/5"}, apparmor.CacheDir, apparmor.ConserveCPU|apparmor.SkipReadCache)
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadFile(logFile)
+	c.Assert(err, IsNil)
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	sort.Strings(calls)
+	args := "--replace --write-cache -O no-expr-simplify --cache-loc=/var/cache/apparmor -j1 --skip-read-cache --quiet"
+	c.Check(calls, DeepEquals, []string{
+		args + " /p/1 /p/2",
+		args + " /p/3 /p/4 /p/5",
+	})
+}
+
+func (s *appArmorSuite) TestLoadProfilesConserveCPUParallelReportsErrors(c *C) {
+	restore := apparmor.MockRuntimeNumCPU(func() int { return 4 })
+	defer restore()
+	cmd := testutil.MockCommand(c, "apparmor_parser", `
+for arg in "$@"; do
+    if [ "$arg" = "/p/2" ]; then
+        echo "bad profile"
+        exit 1
+    fi
+done
+`)
+	defer cmd.Restore()
+	restore = apparmor.MockParserSearchPath(cmd.BinDir())
+	defer restore()
+
+	err := apparmor.LoadProfiles([]string{"/p/1", "/p/2"}, apparmor.CacheDir, apparmor.ConserveCPU)
+	c.Assert(err, ErrorMatches, `(?s)cannot load apparmor profiles: exit status 1\napparmor_parser output:\nbad profile\n`)
+}
+
+func (s *appArmorSuite) TestSplitProfiles(c *C) {
+	for _, t := range []struct {
+		fnames  []string
+		n       int
+		batches [][]string
+	}{
+		{[]string{"a"}, 4, [][]string{{"a"}}},
+		{[]string{"a", "b"}, 2, [][]string{{"a"}, {"b"}}},
+		{[]string{"a", "b", "c"}, 2, [][]string{{"a"}, {"b", "c"}}},
+		{[]string{"a", "b", "c", "d"}, 8, [][]string{{"a"}, {"b"}, {"c"}, {"d"}}},
+		{[]string{"a", "b", "c", "d", "e", "f"}, 3, [][]string{{"a", "b"}, {"c", "d"}, {"e", "f"}}},
+	} {
+		c.Check(apparmor.SplitProfiles(t.fnames, t.n), DeepEquals, t.batches, Commentf("%v/%d", t.fnames, t.n))
+	}
+}
+
 // Tests for Profile.Unload()
 
 func (s *appArmorSuite) TestUnloadProfilesMany(c *C) {
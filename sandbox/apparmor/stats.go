@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// CompileStats holds statistics about the loading of profiles with
+// apparmor_parser.
+type CompileStats struct {
+	// Loads is the number of times a set of profiles was loaded.
+	Loads int `json:"loads"`
+	// Invocations is the number of apparmor_parser invocations.
+	Invocations int `json:"invocations"`
+	// Failures is the number of loads which failed.
+	Failures int `json:"failures"`
+	// Compiled is the number of profiles compiled ignoring the cache.
+	Compiled int `json:"compiled"`
+	// Cacheable is the number of profiles loaded with the cache enabled.
+	Cacheable int `json:"cacheable"`
+	// TotalTime is the time spent loading profiles.
+	TotalTime time.Duration `json:"total-time"`
+	// LongestTime is the longest time spent loading a set of profiles.
+	LongestTime time.Duration `json:"longest-time"`
+	// LastLoad is the time of the last load.
+	LastLoad time.Time `json:"last-load,omitempty"`
+}
+
+var (
+	compileStatsMu   sync.Mutex
+	compileStats     CompileStats
+	compileStatsFile string
+)
+
+var timeNow = time.Now
+
+// PersistCompileStats loads the compile statistics saved in the given file,
+// if any, and arranges for further updates to be saved there so that they
+// are kept across restarts.
+func PersistCompileStats(fname string) error {
+	compileStatsMu.Lock()
+	defer compileStatsMu.Unlock()
+
+	var stats CompileStats
+	data, err := ioutil.ReadFile(fname)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot read apparmor compile stats: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &stats); err != nil {
+			return fmt.Errorf("cannot decode apparmor compile stats: %v", err)
+		}
+	}
+	compileStats = stats
+	compileStatsFile = fname
+	return nil
+}
+
+// CompileStatistics returns the current compile statistics.
+func CompileStatistics() CompileStats {
+	compileStatsMu.Lock()
+	defer compileStatsMu.Unlock()
+	return compileStats
+}
+
+func recordCompileStats(profiles, invocations int, flags AaParserFlags, start time.Time, loadErr error) {
+	compileStatsMu.Lock()
+	defer compileStatsMu.Unlock()
+
+	now := timeNow()
+	took := now.Sub(start)
+	compileStats.Loads++
+	compileStats.Invocations += invocations
+	if loadErr != nil {
+		compileStats.Failures++
+	}
+	if flags&SkipReadCache != 0 {
+		compileStats.Compiled += profiles
+	} else {
+		compileStats.Cacheable += profiles
+	}
+	compileStats.TotalTime += took
+	if took > compileStats.LongestTime {
+		compileStats.LongestTime = took
+	}
+	compileStats.LastLoad = now
+
+	if compileStatsFile == "" {
+		return
+	}
+	data, err := json.Marshal(compileStats)
+	if err != nil {
+		logger.Noticef("cannot encode apparmor compile stats: %v", err)
+		return
+	}
+	if err := osutil.AtomicWriteFile(compileStatsFile, data, 0644, 0); err != nil {
+		logger.Noticef("cannot save apparmor compile stats: %v", err)
+	}
+}
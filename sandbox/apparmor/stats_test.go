@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package apparmor_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/sandbox/apparmor"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type statsSuite struct {
+	testutil.BaseTest
+
+	now time.Time
+}
+
+var _ = Suite(&statsSuite{})
+
+func (s *statsSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(apparmor.MockCompileStats())
+
+	s.now = time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	s.AddCleanup(apparmor.MockTimeNow(func() time.Time {
+		// every call advances the clock by a second
+		s.now = s.now.Add(time.Second)
+		return s.now
+	}))
+	s.AddCleanup(apparmor.MockRuntimeNumCPU(func() int { return 1 }))
+}
+
+func (s *statsSuite) mockParser(c *C, script string) {
+	cmd := testutil.MockCommand(c, "apparmor_parser", script)
+	s.AddCleanup(cmd.Restore)
+	s.AddCleanup(apparmor.MockParserSearchPath(cmd.BinDir()))
+}
+
+func (s *statsSuite) TestLoadProfilesRecordsStats(c *C) {
+	s.mockParser(c, "")
+
+	err := apparmor.LoadProfiles([]string{"/p/1", "/p/2"}, apparmor.CacheDir, apparmor.SkipReadCache)
+	c.Assert(err, IsNil)
+	err = apparmor.LoadProfiles([]string{"/p/3"}, apparmor.CacheDir, 0)
+	c.Assert(err, IsNil)
+
+	c.Check(apparmor.CompileStatistics(), DeepEquals, apparmor.CompileStats{
+		Loads:       2,
+		Invocations: 2,
+		Compiled:    2,
+		Cacheable:   1,
+		TotalTime:   2 * time.Second,
+		LongestTime: time.Second,
+		LastLoad:    s.now,
+	})
+}
+
+func (s *statsSuite) TestLoadProfilesRecordsFailures(c *C) {
+	s.mockParser(c, "exit 1")
+
+	err := apparmor.LoadProfiles([]string{"/p/1"}, apparmor.CacheDir, 0)
+	c.Assert(err, NotNil)
+
+	stats := apparmor.CompileStatistics()
+	c.Check(stats.Loads, Equals, 1)
+	c.Check(stats.Failures, Equals, 1)
+}
+
+func (s *statsSuite) TestPersistCompileStats(c *C) {
+	s.mockParser(c, "")
+	fname := filepath.Join(c.MkDir(), "stats.json")
+
+	err := apparmor.PersistCompileStats(fname)
+	c.Assert(err, IsNil)
+	c.Check(apparmor.CompileStatistics(), DeepEquals, apparmor.CompileStats{})
+
+	err = apparmor.LoadProfiles([]string{"/p/1"}, apparmor.CacheDir, 0)
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadFile(fname)
+	c.Assert(err, IsNil)
+	var saved apparmor.CompileStats
+	c.Assert(json.Unmarshal(data, &saved), IsNil)
+	c.Check(saved, DeepEquals, apparmor.CompileStatistics())
+
+	// reloading picks up the saved statistics
+	restore := apparmor.MockCompileStats()
+	defer restore()
+	err = apparmor.PersistCompileStats(fname)
+	c.Assert(err, IsNil)
+	c.Check(apparmor.CompileStatistics(), DeepEquals, saved)
+}
+
+func (s *statsSuite) TestPersistCompileStatsBadFile(c *C) {
+	fname := filepath.Join(c.MkDir(), "stats.json")
+	c.Assert(ioutil.WriteFile(fname, []byte("{"), 0644), IsNil)
+
+	err := apparmor.PersistCompileStats(fname)
+	c.Assert(err, ErrorMatches, "cannot decode apparmor compile stats: .*")
+}
@@ -27,11 +27,12 @@ import (
 )
 
 var (
-	Cgroup2SuperMagic       = cgroup2SuperMagic
-	ProbeCgroupVersion      = probeCgroupVersion
-	ParsePid                = parsePid
-	DoCreateTransientScope  = doCreateTransientScope
-	SessionOrMaybeSystemBus = sessionOrMaybeSystemBus
+	Cgroup2SuperMagic            = cgroup2SuperMagic
+	ProbeCgroupVersion           = probeCgroupVersion
+	ParsePid                     = parsePid
+	DoCreateTransientScope       = doCreateTransientScope
+	DoCreateTransientScopeNoSync = doCreateTransientScopeNoSync
+	SessionOrMaybeSystemBus      = sessionOrMaybeSystemBus
 
 	ErrDBusUnknownMethod    = errDBusUnknownMethod
 	ErrDBusNameHasNoOwner   = errDBusNameHasNoOwner
@@ -88,7 +89,7 @@ func MockCgroupProcessPathInTrackingCgroup(fn func(pid int) (string, error)) fun
 	}
 }
 
-func MockDoCreateTransientScope(fn func(conn *dbus.Conn, unitName string, pid int) error) func() {
+func MockDoCreateTransientScope(fn func(conn *dbus.Conn, unitName string, pid int, limits ResourceLimits) error) func() {
 	old := doCreateTransientScope
 	doCreateTransientScope = fn
 	return func() {
@@ -46,11 +46,31 @@ func init() {
 func pickFreezerV1Impl() {
 	FreezeSnapProcesses = freezeSnapProcessesImplV1
 	ThawSnapProcesses = thawSnapProcessesImplV1
+	freezeSnapProcessesWithTimeout = freezeSnapProcessesWithTimeoutV1
 }
 
 func pickFreezerV2Impl() {
 	FreezeSnapProcesses = freezeSnapProcessesImplV2
 	ThawSnapProcesses = thawSnapProcessesImplV2
+	freezeSnapProcessesWithTimeout = freezeSnapProcessesWithTimeoutV2
+}
+
+// DefaultFreezeTimeout is the time freezing the processes of a snap is
+// allowed to take by default.
+const DefaultFreezeTimeout = 3 * time.Second
+
+// freezePollInterval is the interval at which the freeze state of the
+// processes is checked while they are being frozen.
+const freezePollInterval = 100 * time.Millisecond
+
+// freezePollAttempts returns how many times the freeze state is checked
+// before giving up after the given timeout.
+func freezePollAttempts(timeout time.Duration) int {
+	attempts := int(timeout / freezePollInterval)
+	if attempts < 1 {
+		attempts = 1
+	}
+	return attempts
 }
 
 // FreezeSnapProcesses suspends execution of all the processes belonging to
@@ -80,10 +100,39 @@ var FreezeSnapProcesses = freezeSnapProcessesImplV1
 // This operation can be mocked with MockFreezing
 var ThawSnapProcesses = thawSnapProcessesImplV1
 
+var freezeSnapProcessesWithTimeout = freezeSnapProcessesWithTimeoutV1
+
+// WithFrozenSnapProcesses runs f while all the processes belonging to a given
+// snap are frozen, such that the data they write is left in a consistent
+// state while f copies or serializes it, and thaws them once f has returned.
+//
+// Freezing must complete within the given timeout, otherwise the processes
+// are thawed and an error is returned without running f.
+//
+// This operation can be mocked with MockFreezing
+func WithFrozenSnapProcesses(snapName string, timeout time.Duration, f func() error) error {
+	if err := freezeSnapProcessesWithTimeout(snapName, timeout); err != nil {
+		return err
+	}
+	fErr := f()
+	if err := ThawSnapProcesses(snapName); err != nil {
+		if fErr != nil {
+			logger.Noticef("cannot thaw processes of snap %q: %v", snapName, err)
+			return fErr
+		}
+		return err
+	}
+	return fErr
+}
+
 // freezeSnapProcessesImplV1 freezes all the processes originating from the given snap.
 // Processes are frozen regardless of which particular snap application they
 // originate from.
 func freezeSnapProcessesImplV1(snapName string) error {
+	return freezeSnapProcessesWithTimeoutV1(snapName, DefaultFreezeTimeout)
+}
+
+func freezeSnapProcessesWithTimeoutV1(snapName string, timeout time.Duration) error {
 	fname := filepath.Join(freezerCgroupV1Dir, fmt.Sprintf("snap.%s", snapName), "freezer.state")
 	if err := ioutil.WriteFile(fname, []byte("FROZEN"), 0644); err != nil && os.IsNotExist(err) {
 		// When there's no freezer cgroup we don't have to freeze anything.
@@ -93,14 +142,14 @@ func freezeSnapProcessesImplV1(snapName string) error {
 	} else if err != nil {
 		return fmt.Errorf("cannot freeze processes of snap %q, %v", snapName, err)
 	}
-	for i := 0; i < 30; i++ {
+	for i := 0; i < freezePollAttempts(timeout); i++ {
 		data, err := ioutil.ReadFile(fname)
 		if err != nil {
 			return fmt.Errorf("cannot determine the freeze state of processes of snap %q, %v", snapName, err)
 		}
 		// If the cgroup is still freezing then wait a moment and try again.
 		if bytes.Equal(data, []byte("FREEZING")) {
-			time.Sleep(100 * time.Millisecond)
+			time.Sleep(freezePollInterval)
 			continue
 		}
 		return nil
@@ -183,6 +232,10 @@ func writeExistingFile(where string, data []byte, mode os.FileMode) error {
 // given snap. Processes are frozen regardless of which particular snap
 // application they originate from.
 func freezeSnapProcessesImplV2(snapName string) error {
+	return freezeSnapProcessesWithTimeoutV2(snapName, DefaultFreezeTimeout)
+}
+
+func freezeSnapProcessesWithTimeoutV2(snapName string, timeout time.Duration) error {
 	// in case of v2, the process calling this code, (eg. snap-update-ns)
 	// may already be part of the trackign cgroup for particular snap, care
 	// must be taken to not freeze ourselves
@@ -205,7 +258,7 @@ func freezeSnapProcessesImplV2(snapName string) error {
 			}
 			return fmt.Errorf("cannot freeze processes of snap %q, %v", snapName, err)
 		}
-		for i := 0; i < 30; i++ {
+		for i := 0; i < freezePollAttempts(timeout); i++ {
 			data, err := ioutil.ReadFile(fname)
 			if err != nil {
 				if os.IsNotExist(err) {
@@ -220,7 +273,7 @@ func freezeSnapProcessesImplV2(snapName string) error {
 				return nil
 			}
 			// add a bit of delay
-			time.Sleep(100 * time.Millisecond)
+			time.Sleep(freezePollInterval)
 		}
 		return fmt.Errorf("cannot freeze processes of snap %q in group %v", snapName, filepath.Base(dir))
 	}
@@ -264,12 +317,17 @@ func thawSnapProcessesImplV2(snapName string) error {
 func MockFreezing(freeze, thaw func(snapName string) error) (restore func()) {
 	oldFreeze := FreezeSnapProcesses
 	oldThaw := ThawSnapProcesses
+	oldFreezeWithTimeout := freezeSnapProcessesWithTimeout
 
 	FreezeSnapProcesses = freeze
 	ThawSnapProcesses = thaw
+	freezeSnapProcessesWithTimeout = func(snapName string, _ time.Duration) error {
+		return freeze(snapName)
+	}
 
 	return func() {
 		FreezeSnapProcesses = oldFreeze
 		ThawSnapProcesses = oldThaw
+		freezeSnapProcessesWithTimeout = oldFreezeWithTimeout
 	}
 }
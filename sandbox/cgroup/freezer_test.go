@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -96,6 +97,70 @@ func (s *freezerV1Suite) TestThawSnapProcessesV1(c *C) {
 	c.Assert(f, testutil.FileEquals, `THAWED`)
 }
 
+func (s *freezerV1Suite) TestWithFrozenSnapProcessesV1(c *C) {
+	defer cgroup.MockVersion(cgroup.V1, nil)()
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	n := "foo"                                                                 // snap name
+	p := filepath.Join(cgroup.FreezerCgroupV1Dir(), fmt.Sprintf("snap.%s", n)) // snap freezer cgroup
+	f := filepath.Join(p, "freezer.state")                                     // freezer.state file of the cgroup
+	c.Assert(os.MkdirAll(p, 0755), IsNil)
+
+	called := false
+	err := cgroup.WithFrozenSnapProcesses(n, time.Second, func() error {
+		called = true
+		c.Check(f, testutil.FileEquals, `FROZEN`)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(called, Equals, true)
+	c.Check(f, testutil.FileEquals, `THAWED`)
+
+	// processes are thawed when the function fails too
+	err = cgroup.WithFrozenSnapProcesses(n, time.Second, func() error {
+		c.Check(f, testutil.FileEquals, `FROZEN`)
+		return fmt.Errorf("boom")
+	})
+	c.Assert(err, ErrorMatches, "boom")
+	c.Check(f, testutil.FileEquals, `THAWED`)
+}
+
+func (s *freezerV1Suite) TestWithFrozenSnapProcessesErrors(c *C) {
+	var thawed []string
+	restore := cgroup.MockFreezing(func(snapName string) error {
+		return fmt.Errorf("cannot freeze")
+	}, func(snapName string) error {
+		thawed = append(thawed, snapName)
+		return nil
+	})
+	defer restore()
+
+	// the function is not run when freezing fails
+	err := cgroup.WithFrozenSnapProcesses("foo", time.Second, func() error {
+		c.Fatalf("unexpected call")
+		return nil
+	})
+	c.Assert(err, ErrorMatches, "cannot freeze")
+	c.Check(thawed, HasLen, 0)
+
+	restore = cgroup.MockFreezing(func(snapName string) error {
+		return nil
+	}, func(snapName string) error {
+		thawed = append(thawed, snapName)
+		return fmt.Errorf("cannot thaw")
+	})
+	defer restore()
+
+	// an error thawing is reported
+	err = cgroup.WithFrozenSnapProcesses("foo", time.Second, func() error { return nil })
+	c.Assert(err, ErrorMatches, "cannot thaw")
+	// but the error of the function takes precedence
+	err = cgroup.WithFrozenSnapProcesses("foo", time.Second, func() error { return fmt.Errorf("boom") })
+	c.Assert(err, ErrorMatches, "boom")
+	c.Check(thawed, DeepEquals, []string{"foo", "foo"})
+}
+
 type freezerV2Suite struct{}
 
 var _ = Suite(&freezerV2Suite{})
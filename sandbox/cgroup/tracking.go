@@ -26,6 +26,19 @@ type TrackingOptions struct {
 	// AllowSessionBus controls if CreateTransientScopeForTracking will
 	// consider using the session bus for making the request.
 	AllowSessionBus bool
+	// Limits are the resource limits of the transient scope.
+	Limits ResourceLimits
+}
+
+// ResourceLimits describes the resource limits enforced by systemd on a
+// transient scope.
+type ResourceLimits struct {
+	// MemoryMax is the maximum amount of memory in bytes, no limit is
+	// set if it is zero.
+	MemoryMax uint64
+	// CPUQuota is the maximum CPU time in percent of a single CPU, no
+	// quota is set if it is zero.
+	CPUQuota int
 }
 
 // CreateTransientScopeForTracking puts the current process in a transient scope.
@@ -92,7 +105,7 @@ func CreateTransientScopeForTracking(securityTag string, opts *TrackingOptions)
 	start := time.Now()
 tryAgain:
 	// Create a transient scope by talking to systemd over DBus.
-	if err := doCreateTransientScope(conn, unitName, pid); err != nil {
+	if err := doCreateTransientScope(conn, unitName, pid, opts.Limits); err != nil {
 		switch err {
 		case errDBusUnknownMethod:
 			return ErrCannotTrackProcess
@@ -223,7 +236,7 @@ var (
 // The scope is created by asking systemd via the specified DBus connection.
 // The unit name and the PID to attach are provided as well. The DBus method
 // call is performed outside confinement established by snap-confine.
-func startTransientScope(conn *dbus.Conn, unitName string, pid int) (job dbus.ObjectPath, err error) {
+func startTransientScope(conn *dbus.Conn, unitName string, pid int, limits ResourceLimits) (job dbus.ObjectPath, err error) {
 	// Documentation of StartTransientUnit is available at
 	// https://www.freedesktop.org/wiki/Software/systemd/dbus/
 	//
@@ -279,6 +292,19 @@ func startTransientScope(conn *dbus.Conn, unitName string, pid int) (job dbus.Ob
 	// Here we choose "fail" to match systemd-run.
 	mode := "fail"
 	properties := []property{{"PIDs", []uint{uint(pid)}}}
+	if limits.MemoryMax != 0 {
+		// MemoryLimit is the cgroup v1 counterpart of MemoryMax
+		name := "MemoryLimit"
+		if IsUnified() {
+			name = "MemoryMax"
+		}
+		properties = append(properties, property{name, limits.MemoryMax})
+	}
+	if limits.CPUQuota != 0 {
+		// the quota is expressed in CPU time per second of wall
+		// clock time
+		properties = append(properties, property{"CPUQuotaPerSecUSec", uint64(limits.CPUQuota) * 10000})
+	}
 	aux := []auxUnit(nil)
 	systemd := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
 	call := systemd.Call(
@@ -323,15 +349,15 @@ func startTransientScope(conn *dbus.Conn, unitName string, pid int) (job dbus.Ob
 // doCreateTransientScopeOpportunisticSync creates a transient scope with a
 // given unit name asking systemd to move the provided pid to that scope, does
 // not wait for the systemd job to complete
-func doCreateTransientScopeNoSync(conn *dbus.Conn, unitName string, pid int) error {
-	_, err := startTransientScope(conn, unitName, pid)
+func doCreateTransientScopeNoSync(conn *dbus.Conn, unitName string, pid int, limits ResourceLimits) error {
+	_, err := startTransientScope(conn, unitName, pid, limits)
 	return err
 }
 
 // doCreateTransientScopeOpportunisticSync creates a transient scope with a
 // given unit name asking systemd to move the provided pid to that scope, and
 // waits for the systemd job to finish
-func doCreateTransientScopeJobRemovedSync(conn *dbus.Conn, unitName string, pid int) error {
+func doCreateTransientScopeJobRemovedSync(conn *dbus.Conn, unitName string, pid int, limits ResourceLimits) error {
 	// set up a watch for JobRemoved signals, so that we'll know when our
 	// request has completed
 	jobRemoveMatch := []dbus.MatchOption{
@@ -409,7 +435,7 @@ func doCreateTransientScopeJobRemovedSync(conn *dbus.Conn, unitName string, pid
 			}
 		}
 	}()
-	job, err := startTransientScope(conn, unitName, pid)
+	job, err := startTransientScope(conn, unitName, pid, limits)
 	if err != nil {
 		return err
 	}
@@ -432,7 +458,7 @@ func doCreateTransientScopeJobRemovedSync(conn *dbus.Conn, unitName string, pid
 // The scope is created by asking systemd via the specified DBus connection.
 // The unit name and the PID to attach are provided as well. The DBus method
 // call is performed outside confinement established by snap-confine.
-var doCreateTransientScope = func(conn *dbus.Conn, unitName string, pid int) error {
+var doCreateTransientScope = func(conn *dbus.Conn, unitName string, pid int, limits ResourceLimits) error {
 	// in theory we could use a single implementation that sync with job
 	// removed signal and inspects the result, however some older
 	// distributions sport an unpatched and broken version of systemd, which
@@ -446,9 +472,9 @@ var doCreateTransientScope = func(conn *dbus.Conn, unitName string, pid int) err
 		// when using cgroup v2, we absolutely must be sure that the
 		// tracking group has been created, otherwise we risk
 		// establishing a device cgroup filtering in the wrong group
-		return doCreateTransientScopeJobRemovedSync(conn, unitName, pid)
+		return doCreateTransientScopeJobRemovedSync(conn, unitName, pid, limits)
 	}
-	return doCreateTransientScopeNoSync(conn, unitName, pid)
+	return doCreateTransientScopeNoSync(conn, unitName, pid, limits)
 }
 
 var randomUUID = func() (string, error) {
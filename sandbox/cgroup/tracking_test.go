@@ -147,7 +147,7 @@ func (s *trackingSuite) TestCreateTransientScopeForTrackingUnhappyNotRootGeneric
 	defer restore()
 
 	// Pretend that attempting to create a transient scope fails with a canned error.
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		return fmt.Errorf("cannot create transient scope for testing")
 	})
 	defer restore()
@@ -158,7 +158,7 @@ func (s *trackingSuite) TestCreateTransientScopeForTrackingUnhappyNotRootGeneric
 
 	// Calling StartTransientUnit fails with org.freedesktop.DBus.UnknownMethod error.
 	// This is possible on old systemd or on deputy systemd.
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		return cgroup.ErrDBusUnknownMethod
 	})
 	defer restore()
@@ -171,7 +171,7 @@ func (s *trackingSuite) TestCreateTransientScopeForTrackingUnhappyNotRootGeneric
 	// Calling StartTransientUnit fails with org.freedesktop.DBus.Spawn.ChildExited error.
 	// This is possible where we try to activate socket activate session bus
 	// but it's not available OR when we try to socket activate systemd --user.
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		return cgroup.ErrDBusSpawnChildExited
 	})
 	defer restore()
@@ -207,7 +207,7 @@ func (s *trackingSuite) TestCreateTransientScopeForTrackingUnhappyRootFallback(c
 	// Calling StartTransientUnit fails on the session and then works on the system bus.
 	// This test emulates a root user falling back from the session bus to the system bus.
 	n := 0
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		n++
 		switch n {
 		case 1:
@@ -259,7 +259,7 @@ func (s *trackingSuite) TestCreateTransientScopeForTrackingUnhappyRootFailedFall
 	defer restore()
 
 	// Calling StartTransientUnit fails so that we try to use the system bus as fallback.
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		return cgroup.ErrDBusSpawnChildExited
 	})
 	defer restore()
@@ -300,7 +300,7 @@ func (s *trackingSuite) TestCreateTransientScopeForTrackingUnhappyNoDBus(c *C) {
 	defer restore()
 
 	// Calling StartTransientUnit is not attempted without a DBus connection.
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		c.Error("test sequence violated")
 		return fmt.Errorf("test was not expected to create a transient scope")
 	})
@@ -345,7 +345,7 @@ func (s *trackingSuite) TestCreateTransientScopeForTrackingSilentlyFails(c *C) {
 	// version is < 238 and when the calling user is in a hierarchy that is
 	// owned by another user. One example is a user logging in remotely over
 	// ssh.
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		return nil
 	})
 	defer restore()
@@ -392,7 +392,7 @@ func (s *trackingSuite) TestCreateTransientScopeForRootOnSystemBus(c *C) {
 	// Pretend that attempting to create a transient scope succeeds.  Measure
 	// the bus used and the unit name provided by the caller.  Note that the
 	// call was made on the system bus, as requested by TrackingOptions below.
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		c.Assert(conn, Equals, systemBus)
 		c.Assert(unitName, Equals, "snap.pkg.app."+uuid+".scope")
 		return nil
@@ -438,7 +438,7 @@ func (s *trackingSuite) testCreateTransientScopeConfirm(c *C, tc testTransientSc
 	c.Assert(err, IsNil)
 	restore = dbusutil.MockOnlySessionBusAvailable(sessionBus)
 	defer restore()
-	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int) error {
+	restore = cgroup.MockDoCreateTransientScope(func(conn *dbus.Conn, unitName string, pid int, limits cgroup.ResourceLimits) error {
 		c.Assert(conn, Equals, sessionBus)
 		c.Assert(unitName, Equals, "snap.pkg.app."+tc.uuid+".scope")
 		return nil
@@ -516,7 +516,7 @@ func checkSystemSignalUnsubscribe(c *C, msg *dbus.Message) *dbus.Message {
 	}
 }
 
-func checkAndRespondToStartTransientUnit(c *C, msg *dbus.Message, scopeName string, pid int) *dbus.Message {
+func checkAndRespondToStartTransientUnit(c *C, msg *dbus.Message, scopeName string, pid int, extraProps ...[]interface{}) *dbus.Message {
 	// XXX: Those types might live in a package somewhere
 	type Property struct {
 		Name  string
@@ -538,12 +538,14 @@ func checkAndRespondToStartTransientUnit(c *C, msg *dbus.Message, scopeName stri
 		dbus.FieldMember:      dbus.MakeVariant("StartTransientUnit"),
 		dbus.FieldSignature:   dbus.MakeVariant(requestSig),
 	})
+	props := [][]interface{}{
+		{"PIDs", dbus.MakeVariant([]uint32{uint32(pid)})},
+	}
+	props = append(props, extraProps...)
 	c.Check(msg.Body, DeepEquals, []interface{}{
 		scopeName,
 		"fail",
-		[][]interface{}{
-			{"PIDs", dbus.MakeVariant([]uint32{uint32(pid)})},
-		},
+		props,
 		[][]interface{}{},
 	})
 
@@ -769,7 +771,7 @@ func (s *trackingSuite) TestDoCreateTransientScopeHappyCgroupV2(c *C) {
 
 	c.Assert(err, IsNil)
 	defer conn.Close()
-	err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123)
+	err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123, cgroup.ResourceLimits{})
 	c.Assert(err, IsNil)
 }
 
@@ -787,10 +789,41 @@ func (s *trackingSuite) TestDoCreateTransientScopeHappyCgroupV1(c *C) {
 	})
 	c.Assert(err, IsNil)
 	defer conn.Close()
-	err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123)
+	err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123, cgroup.ResourceLimits{})
 	c.Assert(err, IsNil)
 }
 
+func (s *trackingSuite) TestDoCreateTransientScopeLimits(c *C) {
+	limits := cgroup.ResourceLimits{MemoryMax: 64 * 1024 * 1024, CPUQuota: 50}
+	for _, t := range []struct {
+		version   int
+		memoryMax string
+	}{
+		{cgroup.V1, "MemoryLimit"},
+		{cgroup.V2, "MemoryMax"},
+	} {
+		restore := cgroup.MockVersion(t.version, nil)
+		defer restore()
+
+		conn, err := dbustest.Connection(func(msg *dbus.Message, n int) ([]*dbus.Message, error) {
+			switch n {
+			case 0:
+				return []*dbus.Message{checkAndRespondToStartTransientUnit(c, msg, "foo.scope", 312123,
+					[]interface{}{t.memoryMax, dbus.MakeVariant(uint64(64 * 1024 * 1024))},
+					[]interface{}{"CPUQuotaPerSecUSec", dbus.MakeVariant(uint64(500000))},
+				)}, nil
+			}
+			return nil, fmt.Errorf("unexpected message #%d: %s", n, msg)
+		})
+		c.Assert(err, IsNil)
+		defer conn.Close()
+		// the job is not waited for, the signal subscription is
+		// exercised by the other tests
+		err = cgroup.DoCreateTransientScopeNoSync(conn, "foo.scope", 312123, limits)
+		c.Assert(err, IsNil)
+	}
+}
+
 func (s *trackingSuite) TestDoCreateTransientScopeForwardedErrors(c *C) {
 	// Certain errors are forwarded and handled in the logic calling into
 	// DoCreateTransientScope. Those are tested here.
@@ -814,7 +847,7 @@ func (s *trackingSuite) TestDoCreateTransientScopeForwardedErrors(c *C) {
 		})
 		c.Assert(err, IsNil)
 		defer conn.Close()
-		err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123)
+		err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123, cgroup.ResourceLimits{})
 		c.Assert(strings.HasSuffix(err.Error(), fmt.Sprintf(" [%s]", t.dbusError)), Equals, true, Commentf("%q ~ %s", err, t.dbusError))
 		c.Check(err, ErrorMatches, t.msg+" .*")
 	}
@@ -837,7 +870,7 @@ func (s *trackingSuite) TestDoCreateTransientScopeClashingScopeName(c *C) {
 	})
 	c.Assert(err, IsNil)
 	defer conn.Close()
-	err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123)
+	err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123, cgroup.ResourceLimits{})
 	c.Assert(err, ErrorMatches, "cannot create transient scope: scope .* clashed: .*")
 }
 
@@ -857,7 +890,7 @@ func (s *trackingSuite) TestDoCreateTransientScopeOtherDBusErrors(c *C) {
 	})
 	c.Assert(err, IsNil)
 	defer conn.Close()
-	err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123)
+	err = cgroup.DoCreateTransientScope(conn, "foo.scope", 312123, cgroup.ResourceLimits{})
 	c.Assert(err, ErrorMatches, `cannot create transient scope: DBus error "org.example.BadHairDay": \[\]`)
 }
 
@@ -22,7 +22,10 @@
 package sandbox
 
 import (
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/sandbox/apparmor"
+	"github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/sandbox/seccomp"
 )
 
 // For testing only
@@ -36,7 +39,7 @@ func ForceDevMode() bool {
 	}
 
 	apparmorFull := apparmor.ProbedLevel() == apparmor.Full
-	return !apparmorFull
+	return !apparmorFull && !HybridConfinement()
 }
 
 // MockForceDevMode fake the system to believe its in a distro
@@ -48,3 +51,29 @@ func MockForceDevMode(forcedDevMode bool) (restore func()) {
 		mockedForceDevMode = old
 	}
 }
+
+// HybridConfinement returns true if strict confinement is approximated by
+// enforcing seccomp filters and restricting file system access with Landlock,
+// because AppArmor is not fully supported. This is an experimental feature
+// that must be enabled explicitly.
+func HybridConfinement() bool {
+	if apparmor.ProbedLevel() == apparmor.Full {
+		return false
+	}
+	if !features.HybridConfinement.IsEnabled() {
+		return false
+	}
+	return landlock.ProbedABI() > 0 && seccomp.SupportsAction("errno")
+}
+
+// LandlockConfinement returns true if the file system access of strictly
+// confined snaps is restricted with Landlock. This is the case with hybrid
+// confinement, or when the experimental landlock feature is enabled and the
+// kernel supports Landlock, in which case it complements AppArmor, or
+// provides partial confinement when AppArmor is not available.
+func LandlockConfinement() bool {
+	if landlock.ProbedABI() == 0 {
+		return false
+	}
+	return features.Landlock.IsEnabled() || HybridConfinement()
+}
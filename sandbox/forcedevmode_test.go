@@ -20,13 +20,19 @@
 package sandbox_test
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/sandbox"
 	"github.com/snapcore/snapd/sandbox/apparmor"
 	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/sandbox/landlock"
+	"github.com/snapcore/snapd/sandbox/seccomp"
 )
 
 func Test(t *testing.T) { TestingT(t) }
@@ -71,3 +77,76 @@ func (s *forceDevModeSuite) TestMockForceDevMode(c *C) {
 		c.Assert(sandbox.ForceDevMode(), Equals, devmode, Commentf("wrong result for %#v", devmode))
 	}
 }
+
+func (s *forceDevModeSuite) TestForceDevModeHybridConfinement(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	defer seccomp.MockActions([]string{"allow", "errno", "kill"})()
+
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+	enableFeature := func(enabled bool) {
+		if enabled {
+			c.Assert(ioutil.WriteFile(features.HybridConfinement.ControlFile(), nil, 0644), IsNil)
+		} else {
+			c.Assert(os.RemoveAll(features.HybridConfinement.ControlFile()), IsNil)
+		}
+	}
+
+	for _, tc := range []struct {
+		apparmorLevel apparmor.LevelType
+		enabled       bool
+		landlockABI   int
+		hybrid        bool
+		devMode       bool
+	}{
+		{apparmor.Unsupported, true, 1, true, false},
+		{apparmor.Partial, true, 2, true, false},
+		// not enabled
+		{apparmor.Unsupported, false, 1, false, true},
+		// no landlock
+		{apparmor.Unsupported, true, 0, false, true},
+		// not needed
+		{apparmor.Full, true, 1, false, false},
+	} {
+		restore := apparmor.MockLevel(tc.apparmorLevel)
+		defer restore()
+		restore = landlock.MockABI(tc.landlockABI)
+		defer restore()
+		enableFeature(tc.enabled)
+
+		comment := Commentf("%+v", tc)
+		c.Check(sandbox.HybridConfinement(), Equals, tc.hybrid, comment)
+		c.Check(sandbox.ForceDevMode(), Equals, tc.devMode, comment)
+	}
+}
+
+func (s *forceDevModeSuite) TestLandlockConfinement(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+	defer seccomp.MockActions([]string{"allow", "errno", "kill"})()
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+
+	for _, tc := range []struct {
+		apparmorLevel apparmor.LevelType
+		feature       features.SnapdFeature
+		landlockABI   int
+		landlock      bool
+	}{
+		{apparmor.Full, features.Landlock, 1, true},
+		{apparmor.Unsupported, features.Landlock, 1, true},
+		{apparmor.Unsupported, features.HybridConfinement, 1, true},
+		// hybrid confinement is not used with AppArmor
+		{apparmor.Full, features.HybridConfinement, 1, false},
+		// no landlock
+		{apparmor.Full, features.Landlock, 0, false},
+	} {
+		restore := apparmor.MockLevel(tc.apparmorLevel)
+		defer restore()
+		restore = landlock.MockABI(tc.landlockABI)
+		defer restore()
+		c.Assert(ioutil.WriteFile(tc.feature.ControlFile(), nil, 0644), IsNil)
+
+		c.Check(sandbox.LandlockConfinement(), Equals, tc.landlock, Commentf("%+v", tc))
+		c.Assert(os.Remove(tc.feature.ControlFile()), IsNil)
+	}
+}
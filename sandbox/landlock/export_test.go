@@ -0,0 +1,41 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock
+
+const (
+	AccessFSv1     = accessFSv1
+	AccessFSv2     = accessFSv2
+	AccessFile     = accessFile
+	AccessReadOnly = accessReadOnly
+)
+
+func MockSyscalls(createRuleset func(handledAccessFS uint64) (int, error), addPathBeneathRule func(rulesetFd, parentFd int, allowedAccess uint64) error, restrictSelf func(rulesetFd int) error) (restore func()) {
+	oldCreateRuleset := landlockCreateRuleset
+	oldAddPathBeneathRule := landlockAddPathBeneathRule
+	oldRestrictSelf := landlockRestrictSelf
+	landlockCreateRuleset = createRuleset
+	landlockAddPathBeneathRule = addPathBeneathRule
+	landlockRestrictSelf = restrictSelf
+	return func() {
+		landlockCreateRuleset = oldCreateRuleset
+		landlockAddPathBeneathRule = oldAddPathBeneathRule
+		landlockRestrictSelf = oldRestrictSelf
+	}
+}
@@ -0,0 +1,273 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package landlock restricts the file system access of processes with
+// Landlock, see https://docs.kernel.org/userspace-api/landlock.html
+package landlock
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// accessFSv1 are the file system accesses handled by the first
+	// version of the Landlock ABI
+	accessFSv1 = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	// accessFSv2 adds the linking and renaming of files across
+	// directories
+	accessFSv2 = accessFSv1 | unix.LANDLOCK_ACCESS_FS_REFER
+
+	// accessFile are the accesses that apply to files rather than
+	// directories
+	accessFile = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE
+
+	accessReadOnly = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+)
+
+// Access is the access to the file system granted beneath a path.
+type Access int
+
+const (
+	// ReadOnly grants reading and executing files and listing
+	// directories.
+	ReadOnly Access = iota
+	// ReadWrite grants all accesses.
+	ReadWrite
+)
+
+func (a Access) String() string {
+	switch a {
+	case ReadOnly:
+		return "ro"
+	case ReadWrite:
+		return "rw"
+	}
+	return fmt.Sprintf("Access:%d", a)
+}
+
+// Rule grants an access to the file system beneath a path.
+type Rule struct {
+	Path   string
+	Access Access
+}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%s %s", r.Access, r.Path)
+}
+
+// ReadProfile reads the rules of a profile. Each line of a profile, other
+// than empty lines and comments starting with '#', is a rule consisting of
+// an access, "ro" or "rw", followed by a path, eg:
+//
+//	ro /
+//	rw $SNAP_USER_DATA
+//
+// Environment variables in paths are expanded with the given function.
+func ReadProfile(r io.Reader, mapping func(string) string) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("cannot parse landlock rule %q", line)
+		}
+		var rule Rule
+		switch fields[0] {
+		case "ro":
+			rule.Access = ReadOnly
+		case "rw":
+			rule.Access = ReadWrite
+		default:
+			return nil, fmt.Errorf("cannot parse landlock rule %q: unknown access %q", line, fields[0])
+		}
+		rule.Path = os.Expand(strings.TrimSpace(fields[1]), mapping)
+		if rule.Path == "" {
+			// the path was made of variables that are not set
+			continue
+		}
+		if !strings.HasPrefix(rule.Path, "/") {
+			return nil, fmt.Errorf("cannot use landlock rule %q: path %q is not absolute", line, rule.Path)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+type abiProbe struct {
+	once sync.Once
+	abi  int
+}
+
+var probe = &abiProbe{}
+
+// ProbedABI returns the version of the Landlock ABI supported by the kernel,
+// or 0 if Landlock is not supported. The result is cached internally.
+func ProbedABI() int {
+	p := probe
+	p.once.Do(func() {
+		abi, err := createRulesetVersion()
+		if err == nil {
+			p.abi = abi
+		}
+	})
+	return p.abi
+}
+
+// MockABI makes ProbedABI return the given version of the Landlock ABI.
+func MockABI(abi int) (restore func()) {
+	old := probe
+	probe = &abiProbe{abi: abi}
+	probe.once.Do(func() {})
+	return func() {
+		probe = old
+	}
+}
+
+func createRulesetVersion() (int, error) {
+	abi, _, errno := syscall.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(abi), nil
+}
+
+func createRuleset(handledAccessFS uint64) (int, error) {
+	attr := unix.LandlockRulesetAttr{Access_fs: handledAccessFS}
+	fd, _, errno := syscall.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func addPathBeneathRule(rulesetFd, parentFd int, allowedAccess uint64) error {
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: allowedAccess, Parent_fd: int32(parentFd)}
+	_, _, errno := syscall.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func restrictSelf(rulesetFd int) error {
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("cannot set no new privileges: %v", err)
+	}
+	_, _, errno := syscall.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFd), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// for the tests
+var (
+	landlockCreateRuleset      = createRuleset
+	landlockAddPathBeneathRule = addPathBeneathRule
+	landlockRestrictSelf       = restrictSelf
+)
+
+// RestrictSelf restricts the file system access of the calling thread, and of
+// the programs it executes, to what the given rules grant. Rules for paths
+// that do not exist are ignored.
+//
+// Landlock restrictions apply to a single thread, the caller must lock the
+// calling goroutine to its thread with runtime.LockOSThread until the program
+// is executed.
+func RestrictSelf(rules []Rule) error {
+	var handled uint64
+	switch abi := ProbedABI(); {
+	case abi >= 2:
+		handled = accessFSv2
+	case abi == 1:
+		handled = accessFSv1
+	default:
+		return fmt.Errorf("cannot restrict file system access: landlock is not supported")
+	}
+
+	rulesetFd, err := landlockCreateRuleset(handled)
+	if err != nil {
+		return fmt.Errorf("cannot create landlock ruleset: %v", err)
+	}
+	defer unix.Close(rulesetFd)
+
+	for _, rule := range rules {
+		fd, err := unix.Open(rule.Path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err == unix.ENOENT {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot open %q: %v", rule.Path, err)
+		}
+		allowed := handled
+		if rule.Access == ReadOnly {
+			allowed = accessReadOnly
+		}
+		var st unix.Stat_t
+		if err := unix.Fstat(fd, &st); err != nil {
+			unix.Close(fd)
+			return fmt.Errorf("cannot stat %q: %v", rule.Path, err)
+		}
+		if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+			allowed &= accessFile
+		}
+		err = landlockAddPathBeneathRule(rulesetFd, fd, allowed)
+		unix.Close(fd)
+		if err != nil {
+			return fmt.Errorf("cannot add landlock rule %q: %v", rule, err)
+		}
+	}
+
+	if err := landlockRestrictSelf(rulesetFd); err != nil {
+		return fmt.Errorf("cannot restrict file system access: %v", err)
+	}
+	return nil
+}
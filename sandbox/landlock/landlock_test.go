@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package landlock_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/sandbox/landlock"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type landlockSuite struct{}
+
+var _ = Suite(&landlockSuite{})
+
+func (s *landlockSuite) TestReadProfile(c *C) {
+	env := map[string]string{
+		"SNAP_USER_DATA": "/home/user/snap/foo/1",
+	}
+	rules, err := landlock.ReadProfile(strings.NewReader(`# a comment
+ro /
+
+rw $SNAP_USER_DATA
+rw $UNSET
+rw ${SNAP_USER_DATA}/sub
+`), func(name string) string { return env[name] })
+	c.Assert(err, IsNil)
+	c.Check(rules, DeepEquals, []landlock.Rule{
+		{Path: "/", Access: landlock.ReadOnly},
+		{Path: "/home/user/snap/foo/1", Access: landlock.ReadWrite},
+		{Path: "/home/user/snap/foo/1/sub", Access: landlock.ReadWrite},
+	})
+	c.Check(rules[1].String(), Equals, "rw /home/user/snap/foo/1")
+}
+
+func (s *landlockSuite) TestReadProfileErrors(c *C) {
+	for _, tc := range []struct {
+		profile, err string
+	}{
+		{"rw", `cannot parse landlock rule "rw"`},
+		{"rx /", `cannot parse landlock rule "rx /": unknown access "rx"`},
+		{"ro foo", `cannot use landlock rule "ro foo": path "foo" is not absolute`},
+	} {
+		_, err := landlock.ReadProfile(strings.NewReader(tc.profile), os.Getenv)
+		c.Check(err, ErrorMatches, tc.err)
+	}
+}
+
+func (s *landlockSuite) TestRestrictSelf(c *C) {
+	defer landlock.MockABI(2)()
+
+	dir := c.MkDir()
+	file := filepath.Join(dir, "file")
+	c.Assert(ioutil.WriteFile(file, nil, 0644), IsNil)
+
+	var handled uint64
+	var allowed []uint64
+	restricted := false
+	defer landlock.MockSyscalls(func(handledAccessFS uint64) (int, error) {
+		handled = handledAccessFS
+		// any open file descriptor
+		return syscall.Dup(0)
+	}, func(rulesetFd, parentFd int, allowedAccess uint64) error {
+		allowed = append(allowed, allowedAccess)
+		return nil
+	}, func(rulesetFd int) error {
+		restricted = true
+		return nil
+	})()
+
+	err := landlock.RestrictSelf([]landlock.Rule{
+		{Path: "/", Access: landlock.ReadOnly},
+		{Path: dir, Access: landlock.ReadWrite},
+		{Path: file, Access: landlock.ReadWrite},
+		// ignored
+		{Path: filepath.Join(dir, "missing"), Access: landlock.ReadWrite},
+	})
+	c.Assert(err, IsNil)
+	c.Check(handled, Equals, uint64(landlock.AccessFSv2))
+	c.Check(allowed, DeepEquals, []uint64{
+		landlock.AccessReadOnly,
+		landlock.AccessFSv2,
+		landlock.AccessFile,
+	})
+	c.Check(restricted, Equals, true)
+}
+
+func (s *landlockSuite) TestRestrictSelfABIv1(c *C) {
+	defer landlock.MockABI(1)()
+
+	var handled uint64
+	defer landlock.MockSyscalls(func(handledAccessFS uint64) (int, error) {
+		handled = handledAccessFS
+		return syscall.Dup(0)
+	}, func(rulesetFd, parentFd int, allowedAccess uint64) error {
+		c.Check(allowedAccess, Equals, uint64(landlock.AccessFSv1))
+		return nil
+	}, func(rulesetFd int) error {
+		return errors.New("boom")
+	})()
+
+	err := landlock.RestrictSelf([]landlock.Rule{{Path: c.MkDir(), Access: landlock.ReadWrite}})
+	c.Assert(err, ErrorMatches, "cannot restrict file system access: boom")
+	c.Check(handled, Equals, uint64(landlock.AccessFSv1))
+}
+
+func (s *landlockSuite) TestRestrictSelfUnsupported(c *C) {
+	defer landlock.MockABI(0)()
+
+	err := landlock.RestrictSelf(nil)
+	c.Assert(err, ErrorMatches, "cannot restrict file system access: landlock is not supported")
+}
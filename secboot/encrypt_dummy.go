@@ -40,3 +40,7 @@ func StageEncryptionKeyChange(node string, key keys.EncryptionKey) error {
 func TransitionEncryptionKeyChange(mountpoint string, key keys.EncryptionKey) error {
 	return errBuildWithoutSecboot
 }
+
+func AddRecoveryKey(key keys.EncryptionKey, rkey keys.RecoveryKey, node string) error {
+	return errBuildWithoutSecboot
+}
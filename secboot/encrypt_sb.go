@@ -74,6 +74,14 @@ func AddRecoveryKey(key keys.EncryptionKey, rkey keys.RecoveryKey, node string)
 	return keymgr.AddRecoveryKeyToLUKSDeviceUsingKey(rkey, key, node)
 }
 
+// AddPassphrase adds a passphrase chosen by the user to the existing
+// encrypted volume created with FormatEncryptedDevice on the block device
+// given by node. The existing key to the encrypted volume is provided in
+// the key argument.
+func AddPassphrase(key keys.EncryptionKey, passphrase string, node string) error {
+	return keymgr.AddPassphraseToLUKSDeviceUsingKey(passphrase, key, node)
+}
+
 func runSnapFDEKeymgr(args []string, stdin io.Reader) error {
 	toolPath, err := snapdtool.InternalToolPath("snap-fde-keymgr")
 	if err != nil {
@@ -169,6 +169,14 @@ func MockSbMeasureSnapModelToTPM(f func(tpm *sb_tpm2.Connection, pcrIndex int, m
 	}
 }
 
+func MockAskPassphrase(f func(name, device string) (string, error)) (restore func()) {
+	old := askPassphrase
+	askPassphrase = f
+	return func() {
+		askPassphrase = old
+	}
+}
+
 func MockRandomKernelUUID(f func() string) (restore func()) {
 	old := randutilRandomKernelUUID
 	randutilRandomKernelUUID = f
@@ -218,6 +226,14 @@ func MockSbDeactivateVolume(f func(volumeName string) error) (restore func()) {
 	}
 }
 
+func MockSbGetDiskUnlockKeyFromKernel(f func(prefix, devicePath string, remove bool) (sb.DiskUnlockKey, error)) (restore func()) {
+	old := sbGetDiskUnlockKeyFromKernel
+	sbGetDiskUnlockKeyFromKernel = f
+	return func() {
+		sbGetDiskUnlockKeyFromKernel = old
+	}
+}
+
 func MockSbReadSealedKeyObjectFromFile(f func(string) (*sb_tpm2.SealedKeyObject, error)) (restore func()) {
 	old := sbReadSealedKeyObjectFromFile
 	sbReadSealedKeyObjectFromFile = f
@@ -30,4 +30,7 @@ func MockGetDiskUnlockKeyFromKernel(f func(prefix, devicePath string, remove boo
 	return restore
 }
 
-var RecoveryKDF = recoveryKDF
+var (
+	RecoveryKDF   = recoveryKDF
+	PassphraseKDF = passphraseKDF
+)
@@ -38,6 +38,8 @@ const (
 	recoveryKeySlot = 1
 	// temporary key slot used when changing the encryption key
 	tempKeySlot = recoveryKeySlot + 1
+	// key slot used by the passphrase chosen on install
+	passphraseKeySlot = tempKeySlot + 1
 )
 
 var (
@@ -75,17 +77,17 @@ func isKeyslotNotActive(err error) bool {
 	return match
 }
 
-func recoveryKDF() (*luks2.KDFOptions, error) {
+// kdfMemoryKiB returns the memory cost, in KiB, of the KDF of a key slot
+// for the given kind of key.
+func kdfMemoryKiB(kind string) (int, error) {
 	usableMem, err := osutil.TotalUsableMemory()
 	if err != nil {
-		return nil, fmt.Errorf("cannot get usable memory for KDF parameters when adding the recovery key: %v", err)
+		return 0, fmt.Errorf("cannot get usable memory for KDF parameters when adding the %s: %v", kind, err)
 	}
 	// The KDF memory is heuristically calculated by taking the
 	// usable memory and subtracting hardcoded 384MB that is
 	// needed to keep the system working. Half of that is the mem
-	// we want to use for the KDF. Doing it this way avoids the expensive
-	// benchmark from cryptsetup. The recovery key is already 128bit
-	// strong so we don't need to be super precise here.
+	// we want to use for the KDF.
 	kdfMem := (int(usableMem) - 384*1024*1024) / 2
 	// at most 1 GB, but at least 32 kB
 	if kdfMem > 1024*1024*1024 {
@@ -93,12 +95,41 @@ func recoveryKDF() (*luks2.KDFOptions, error) {
 	} else if kdfMem < 32*1024 {
 		kdfMem = 32 * 1024
 	}
+	return kdfMem / 1024, nil
+}
+
+func recoveryKDF() (*luks2.KDFOptions, error) {
+	memKiB, err := kdfMemoryKiB("recovery key")
+	if err != nil {
+		return nil, err
+	}
+	// Using fixed parameters avoids the expensive benchmark from
+	// cryptsetup. The recovery key is already 128bit strong so we
+	// don't need to be super precise here.
 	return &luks2.KDFOptions{
-		MemoryKiB:       kdfMem / 1024,
+		MemoryKiB:       memKiB,
 		ForceIterations: 4,
 	}, nil
 }
 
+// passphraseKDFTargetDuration is the time the KDF of a passphrase key slot
+// is benchmarked to take.
+const passphraseKDFTargetDuration = 2 * time.Second
+
+func passphraseKDF() (*luks2.KDFOptions, error) {
+	memKiB, err := kdfMemoryKiB("passphrase")
+	if err != nil {
+		return nil, err
+	}
+	// Unlike the recovery key, a passphrase chosen by a user may have
+	// low entropy, so let cryptsetup benchmark the costs for the KDF
+	// to take a noticeable time, within the memory limit.
+	return &luks2.KDFOptions{
+		MemoryKiB:      memKiB,
+		TargetDuration: passphraseKDFTargetDuration,
+	}, nil
+}
+
 // AddRecoveryKeyToLUKSDevice adds a recovery key to a LUKS2 device. It the
 // devuce unlock key from the user keyring to authorize the change. The
 // recoveyry key is added to keyslot 1.
@@ -138,6 +169,35 @@ func AddRecoveryKeyToLUKSDeviceUsingKey(recoveryKey keys.RecoveryKey, currKey ke
 	return nil
 }
 
+// AddPassphraseToLUKSDeviceUsingKey adds a passphrase to the existing LUKS
+// encrypted volume on the block device given by node, so that the volume
+// can be unlocked with it. The existing key to the encrypted volume is
+// provided in the key argument and used to authorize the operation. The
+// passphrase is added to keyslot 3.
+func AddPassphraseToLUKSDeviceUsingKey(passphrase string, currKey keys.EncryptionKey, dev string) error {
+	if passphrase == "" {
+		return fmt.Errorf("cannot add empty passphrase")
+	}
+	opts, err := passphraseKDF()
+	if err != nil {
+		return err
+	}
+
+	options := luks2.AddKeyOptions{
+		KDFOptions: *opts,
+		Slot:       passphraseKeySlot,
+	}
+	if err := luks2.AddKey(dev, currKey, []byte(passphrase), &options); err != nil {
+		return fmt.Errorf("cannot add passphrase: %v", err)
+	}
+
+	if err := luks2.SetSlotPriority(dev, encryptionKeySlot, luks2.SlotPriorityHigh); err != nil {
+		return fmt.Errorf("cannot change keyslot priority: %v", err)
+	}
+
+	return nil
+}
+
 // RemoveRecoveryKeyFromLUKSDevice removes an existing recovery key a LUKS2
 // device.
 func RemoveRecoveryKeyFromLUKSDevice(dev string) error {
@@ -25,6 +25,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	sb "github.com/snapcore/secboot"
 	. "gopkg.in/check.v1"
@@ -235,6 +236,65 @@ func (s *keymgrSuite) TestAddRecoveryKeyToDeviceUsingExistingKey(c *C) {
 	s.verifyCryptsetupAddKey(c, cmd, []byte(key), mockRecoveryKey[:])
 }
 
+func (s *keymgrSuite) TestAddPassphraseToDeviceUsingKey(c *C) {
+	restore := keymgr.MockGetDiskUnlockKeyFromKernel(func(prefix, devicePath string, remove bool) (sb.DiskUnlockKey, error) {
+		return nil, fmt.Errorf("unexpected call")
+	})
+	defer restore()
+
+	cmd := s.mockCryptsetupForAddKey(c)
+	defer cmd.Restore()
+	key := bytes.Repeat([]byte{1}, 32)
+	err := keymgr.AddPassphraseToLUKSDeviceUsingKey("correct horse battery staple", keys.EncryptionKey(key), "/dev/foobar")
+	c.Assert(err, IsNil)
+
+	calls := cmd.Calls()
+	c.Assert(calls, HasLen, 2)
+	c.Assert(calls[0], HasLen, 16)
+	c.Assert(calls[0][5], testutil.Contains, s.rootDir)
+	calls[0][5] = "<fifo>"
+	c.Assert(calls[0], DeepEquals, []string{
+		"cryptsetup", "luksAddKey", "--type", "luks2",
+		"--key-file", "<fifo>",
+		"--pbkdf", "argon2i",
+		"--iter-time", "2000",
+		"--pbkdf-memory", "202834",
+		"--key-slot", "3",
+		"/dev/foobar", "-",
+	})
+	c.Assert(calls[1], DeepEquals, []string{
+		"cryptsetup", "config", "--priority", "prefer", "--key-slot", "0", "/dev/foobar",
+	})
+	c.Check(filepath.Join(s.rootDir, "unlock.key"), testutil.FileEquals, key)
+	c.Check(filepath.Join(s.rootDir, "new.key"), testutil.FileEquals, "correct horse battery staple")
+}
+
+func (s *keymgrSuite) TestAddPassphraseToDeviceErrors(c *C) {
+	key := bytes.Repeat([]byte{1}, 32)
+	err := keymgr.AddPassphraseToLUKSDeviceUsingKey("", keys.EncryptionKey(key), "/dev/foobar")
+	c.Assert(err, ErrorMatches, "cannot add empty passphrase")
+
+	cmd := testutil.MockCommand(c, "cryptsetup", `
+while [ "$#" -gt 1 ]; do
+  case "$1" in
+    --key-file)
+      cat "$2" > /dev/null
+      shift 2
+      ;;
+    *)
+      shift 1
+      ;;
+  esac
+done
+echo "Key slot 3 is full, please select another one." >&2
+exit 1
+`)
+	defer cmd.Restore()
+	err = keymgr.AddPassphraseToLUKSDeviceUsingKey("passphrase", keys.EncryptionKey(key), "/dev/foobar")
+	c.Assert(err, ErrorMatches, "cannot add passphrase: cryptsetup failed with: Key slot 3 is full, please select another one.")
+	c.Check(keymgr.IsKeyslotAlreadyUsed(err), Equals, true)
+}
+
 func (s *keymgrSuite) TestRemoveRecoveryKeyFromDevice(c *C) {
 	unlockKey := "1234abcd"
 	getCalls := 0
@@ -902,3 +962,20 @@ CmaTotal:         131072 kB
 		ForceIterations: 4,
 	})
 }
+
+func (s *keymgrSuite) TestPassphraseKDF(c *C) {
+	mockedMeminfoFile := filepath.Join(c.MkDir(), "meminfo")
+	s.AddCleanup(osutil.MockProcMeminfo(mockedMeminfoFile))
+
+	_, err := keymgr.PassphraseKDF()
+	c.Assert(err, ErrorMatches, "cannot get usable memory for KDF parameters when adding the passphrase: open .*")
+
+	c.Assert(ioutil.WriteFile(mockedMeminfoFile, []byte(mockedMeminfo), 0644), IsNil)
+
+	opts, err := keymgr.PassphraseKDF()
+	c.Assert(err, IsNil)
+	c.Assert(opts, DeepEquals, &luks2.KDFOptions{
+		MemoryKiB:      202834,
+		TargetDuration: 2 * time.Second,
+	})
+}
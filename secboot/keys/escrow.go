@@ -0,0 +1,136 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+const (
+	// EscrowAlgorithm is how escrowed recovery keys are encrypted.
+	EscrowAlgorithm = "rsa-oaep-sha256"
+
+	// minimum size of the RSA keys recovery keys are escrowed to
+	minEscrowKeyBits = 2048
+)
+
+// EscrowedRecoveryKey is a recovery key encrypted to a public key held by
+// the brand, such that it can be kept outside of the device, e.g. for
+// enterprise key escrow, without revealing it.
+type EscrowedRecoveryKey struct {
+	// BrandID and Model identify the model of the device.
+	BrandID string `json:"brand-id"`
+	Model   string `json:"model"`
+	// Algorithm is the encryption algorithm, EscrowAlgorithm.
+	Algorithm string `json:"algorithm"`
+	// PublicKeySHA256 is the SHA256 hash of the DER encoding of the
+	// public key the recovery key is encrypted to.
+	PublicKeySHA256 string `json:"public-key-sha256"`
+	// EncryptedKey is the encrypted recovery key, the brand ID and model
+	// are the OAEP label.
+	EncryptedKey []byte `json:"encrypted-key"`
+}
+
+// ParseEscrowPublicKey parses the PEM encoded RSA public key recovery keys
+// are escrowed to.
+func ParseEscrowPublicKey(pemData []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("cannot decode escrow public key: no PEM block found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse escrow public key: %v", err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("cannot use escrow public key: not an RSA key")
+	}
+	if rsaPub.N.BitLen() < minEscrowKeyBits {
+		return nil, fmt.Errorf("cannot use escrow public key: key size %d is smaller than %d bits", rsaPub.N.BitLen(), minEscrowKeyBits)
+	}
+	return rsaPub, nil
+}
+
+func escrowLabel(brandID, model string) []byte {
+	return []byte(brandID + "/" + model)
+}
+
+// Escrow encrypts the recovery key to the given public key of the brand for
+// a device of the given model.
+func (key RecoveryKey) Escrow(pub *rsa.PublicKey, brandID, model string) (*EscrowedRecoveryKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal escrow public key: %v", err)
+	}
+	encrypted, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key[:], escrowLabel(brandID, model))
+	if err != nil {
+		return nil, fmt.Errorf("cannot encrypt recovery key: %v", err)
+	}
+	return &EscrowedRecoveryKey{
+		BrandID:         brandID,
+		Model:           model,
+		Algorithm:       EscrowAlgorithm,
+		PublicKeySHA256: fmt.Sprintf("%x", sha256.Sum256(der)),
+		EncryptedKey:    encrypted,
+	}, nil
+}
+
+// Decrypt decrypts the escrowed recovery key with the private key of the
+// brand.
+func (e *EscrowedRecoveryKey) Decrypt(priv *rsa.PrivateKey) (RecoveryKey, error) {
+	var key RecoveryKey
+	if e.Algorithm != EscrowAlgorithm {
+		return key, fmt.Errorf("cannot decrypt recovery key: unsupported algorithm %q", e.Algorithm)
+	}
+	decrypted, err := rsa.DecryptOAEP(sha256.New(), nil, priv, e.EncryptedKey, escrowLabel(e.BrandID, e.Model))
+	if err != nil {
+		return key, fmt.Errorf("cannot decrypt recovery key: %v", err)
+	}
+	if len(decrypted) != len(key) {
+		return key, fmt.Errorf("cannot decrypt recovery key: unexpected size %d", len(decrypted))
+	}
+	copy(key[:], decrypted)
+	return key, nil
+}
+
+// Save writes the escrowed recovery key in the location specified by
+// filename.
+func (e *EscrowedRecoveryKey) Save(filename string) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(filename, b, 0600, 0)
+}
@@ -0,0 +1,119 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package keys_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/secboot/keys"
+)
+
+type escrowSuite struct{}
+
+var _ = Suite(&escrowSuite{})
+
+var escrowPrivKey *rsa.PrivateKey
+
+func (s *escrowSuite) SetUpSuite(c *C) {
+	var err error
+	escrowPrivKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+}
+
+func pemPublicKey(c *C, pub interface{}) []byte {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	c.Assert(err, IsNil)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func (s *escrowSuite) TestEscrowRoundtrip(c *C) {
+	pub, err := keys.ParseEscrowPublicKey(pemPublicKey(c, &escrowPrivKey.PublicKey))
+	c.Assert(err, IsNil)
+
+	rkey := keys.RecoveryKey{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 255}
+	escrowed, err := rkey.Escrow(pub, "my-brand", "my-model")
+	c.Assert(err, IsNil)
+	c.Check(escrowed.BrandID, Equals, "my-brand")
+	c.Check(escrowed.Model, Equals, "my-model")
+	c.Check(escrowed.Algorithm, Equals, "rsa-oaep-sha256")
+	c.Check(escrowed.PublicKeySHA256, Matches, "[0-9a-f]{64}")
+	c.Check(escrowed.EncryptedKey, HasLen, 256)
+
+	fn := filepath.Join(c.MkDir(), "deeply/nested/recovery-key.escrow")
+	c.Assert(escrowed.Save(fn), IsNil)
+	fileInfo, err := os.Stat(fn)
+	c.Assert(err, IsNil)
+	c.Check(fileInfo.Mode(), Equals, os.FileMode(0600))
+
+	data, err := ioutil.ReadFile(fn)
+	c.Assert(err, IsNil)
+	// the recovery key is not in the clear
+	c.Check(string(data), Not(Matches), `(?s).*`+rkey.String()+`.*`)
+	var loaded keys.EscrowedRecoveryKey
+	c.Assert(json.Unmarshal(data, &loaded), IsNil)
+	c.Check(&loaded, DeepEquals, escrowed)
+
+	decrypted, err := loaded.Decrypt(escrowPrivKey)
+	c.Assert(err, IsNil)
+	c.Check(decrypted, DeepEquals, rkey)
+}
+
+func (s *escrowSuite) TestEscrowBoundToModel(c *C) {
+	rkey := keys.RecoveryKey{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 255}
+	escrowed, err := rkey.Escrow(&escrowPrivKey.PublicKey, "my-brand", "my-model")
+	c.Assert(err, IsNil)
+
+	escrowed.Model = "other-model"
+	_, err = escrowed.Decrypt(escrowPrivKey)
+	c.Check(err, ErrorMatches, "cannot decrypt recovery key: crypto/rsa: decryption error")
+
+	escrowed.Algorithm = "other"
+	_, err = escrowed.Decrypt(escrowPrivKey)
+	c.Check(err, ErrorMatches, `cannot decrypt recovery key: unsupported algorithm "other"`)
+}
+
+func (s *escrowSuite) TestParseEscrowPublicKeyErrors(c *C) {
+	_, err := keys.ParseEscrowPublicKey([]byte("not a key"))
+	c.Check(err, ErrorMatches, "cannot decode escrow public key: no PEM block found")
+
+	_, err = keys.ParseEscrowPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("garbage")}))
+	c.Check(err, ErrorMatches, "cannot parse escrow public key: .*")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	_, err = keys.ParseEscrowPublicKey(pemPublicKey(c, &ecKey.PublicKey))
+	c.Check(err, ErrorMatches, "cannot use escrow public key: not an RSA key")
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	_, err = keys.ParseEscrowPublicKey(pemPublicKey(c, &smallKey.PublicKey))
+	c.Check(err, ErrorMatches, "cannot use escrow public key: key size 1024 is smaller than 2048 bits")
+}
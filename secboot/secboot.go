@@ -142,6 +142,11 @@ type ResealKeysParams struct {
 	KeyFiles []string
 	// The path to the authorization policy update key file (only relevant for TPM)
 	TPMPolicyAuthKeyFile string
+	// SignatureDbUpdateKeystores are directories holding pending EFI
+	// signature database updates, such as DBX updates, in the layout
+	// used by sbkeysync. The keys are resealed such that they can be
+	// unsealed both before and after the updates are applied.
+	SignatureDbUpdateKeystores []string
 }
 
 // UnlockVolumeUsingSealedKeyOptions contains options for unlocking encrypted
@@ -153,6 +158,11 @@ type UnlockVolumeUsingSealedKeyOptions struct {
 	// WhichModel if invoked should return the device model
 	// assertion for which the disk is being unlocked.
 	WhichModel func() (*asserts.Model, error)
+	// UsePassphrase when true indicates that no key was sealed for the
+	// volume and that it is unlocked with the passphrase chosen on
+	// install instead, which the user is prompted for. The sealed key
+	// file is not used then.
+	UsePassphrase bool
 }
 
 // UnlockMethod is the method that was used to unlock a volume.
@@ -173,6 +183,9 @@ const (
 	UnlockedWithKey
 	// UnlockStatusUnknown indicates that the unlock status of the device is not clear.
 	UnlockStatusUnknown
+	// UnlockedWithPassphrase indicates that the device was unlocked by the
+	// user providing the passphrase chosen on install at the prompt.
+	UnlockedWithPassphrase
 )
 
 // UnlockResult is the result of trying to unlock a volume.
@@ -192,6 +205,7 @@ type UnlockResult struct {
 	// - UnlockedWithRecoveryKey
 	// - UnlockedWithSealedKey
 	// - UnlockedWithKey
+	// - UnlockedWithPassphrase
 	UnlockMethod UnlockMethod
 }
 
@@ -60,3 +60,7 @@ func ReleasePCRResourceHandles(handles ...uint32) error {
 func resetLockoutCounter(lockoutAuthFile string) error {
 	return errBuildWithoutSecboot
 }
+
+func DiskUnlockKeyFromKernel(devicePath string) ([]byte, error) {
+	return nil, errBuildWithoutSecboot
+}
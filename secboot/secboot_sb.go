@@ -23,7 +23,10 @@ package secboot
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	sb "github.com/snapcore/secboot"
 	"golang.org/x/xerrors"
@@ -38,6 +41,7 @@ var (
 	sbActivateVolumeWithKeyData     = sb.ActivateVolumeWithKeyData
 	sbActivateVolumeWithRecoveryKey = sb.ActivateVolumeWithRecoveryKey
 	sbDeactivateVolume              = sb.DeactivateVolume
+	sbGetDiskUnlockKeyFromKernel    = sb.GetDiskUnlockKeyFromKernel
 )
 
 func init() {
@@ -106,6 +110,9 @@ func UnlockVolumeUsingSealedKeyIfEncrypted(disk disks.Disk, name string, sealedE
 	sourceDevice := partDevice
 	targetDevice := filepath.Join("/dev/mapper", mapperName)
 
+	if opts.UsePassphrase {
+		return unlockVolumeUsingPassphrase(name, sourceDevice, targetDevice, mapperName, opts)
+	}
 	if fdeHasRevealKey() {
 		return unlockVolumeUsingSealedKeyFDERevealKey(sealedEncryptionKeyFile, sourceDevice, targetDevice, mapperName, opts)
 	} else {
@@ -113,6 +120,17 @@ func UnlockVolumeUsingSealedKeyIfEncrypted(disk disks.Disk, name string, sealedE
 	}
 }
 
+// DiskUnlockKeyFromKernel returns the key the encrypted device at the given
+// path was unlocked with by UnlockVolumeUsingSealedKeyIfEncrypted, as kept in
+// the kernel keyring.
+func DiskUnlockKeyFromKernel(devicePath string) ([]byte, error) {
+	key, err := sbGetDiskUnlockKeyFromKernel(keyringPrefix, devicePath, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get the unlock key of %q: %v", devicePath, err)
+	}
+	return key, nil
+}
+
 // UnlockEncryptedVolumeUsingKey unlocks an existing volume using the provided key.
 func UnlockEncryptedVolumeUsingKey(disk disks.Disk, name string, key []byte) (UnlockResult, error) {
 	unlockRes := UnlockResult{
@@ -154,6 +172,57 @@ func unlockEncryptedPartitionWithKey(name, device string, key []byte) error {
 	return err
 }
 
+// passphraseTries is how many times the user is prompted for the passphrase
+// of a volume.
+const passphraseTries = 3
+
+// askPassphrase prompts the user for the passphrase of the given device.
+var askPassphrase = func(name, device string) (string, error) {
+	cmd := exec.Command("systemd-ask-password",
+		"--icon", "drive-harddisk",
+		"--id", filepath.Base(os.Args[0])+":"+device,
+		fmt.Sprintf("Please enter the passphrase for %s (%s):", name, device))
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot ask for the passphrase: %v", err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// unlockVolumeUsingPassphrase prompts for the passphrase chosen on install
+// and uses it to open the encrypted device, falling back to the recovery key
+// if allowed.
+func unlockVolumeUsingPassphrase(name, sourceDevice, targetDevice, mapperName string, opts *UnlockVolumeUsingSealedKeyOptions) (UnlockResult, error) {
+	res := UnlockResult{IsEncrypted: true, PartDevice: sourceDevice}
+
+	var err error
+	for i := 0; i < passphraseTries; i++ {
+		var passphrase string
+		passphrase, err = askPassphrase(name, sourceDevice)
+		if err != nil {
+			break
+		}
+		if err = sbActivateVolumeWithKey(mapperName, sourceDevice, []byte(passphrase), &sb.ActivateVolumeOptions{}); err == nil {
+			logger.Noticef("successfully activated encrypted device %q using the passphrase", sourceDevice)
+			res.FsDevice = targetDevice
+			res.UnlockMethod = UnlockedWithPassphrase
+			return res, nil
+		}
+	}
+	if !opts.AllowRecoveryKey {
+		return res, fmt.Errorf("cannot unlock encrypted device %q with the passphrase: %v", name, err)
+	}
+
+	logger.Noticef("cannot unlock encrypted device %q with the passphrase: %v", name, err)
+	if err := UnlockEncryptedVolumeWithRecoveryKey(mapperName, sourceDevice); err != nil {
+		return res, err
+	}
+	res.FsDevice = targetDevice
+	res.UnlockMethod = UnlockedWithRecoveryKey
+	return res, nil
+}
+
 // UnlockEncryptedVolumeWithRecoveryKey prompts for the recovery key and uses it
 // to open an encrypted device.
 func UnlockEncryptedVolumeWithRecoveryKey(name, device string) error {
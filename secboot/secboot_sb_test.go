@@ -1057,10 +1057,13 @@ func (s *secbootSuite) TestResealKey(c *C) {
 		resealCalls            int
 		revokeErr              error
 		revokeCalls            int
+		keystores              []string
 		expectedErr            string
 	}{
 		// happy case
 		{tpmEnabled: true, resealCalls: 1, revokeCalls: 1, expectedErr: ""},
+		// happy case with pending signature database updates
+		{tpmEnabled: true, resealCalls: 1, revokeCalls: 1, keystores: []string{"/var/lib/snapd/device/fde/dbx-update"}, expectedErr: ""},
 
 		// unhappy cases
 		{tpmErr: mockErr, expectedErr: "cannot connect to TPM: some error"},
@@ -1096,8 +1099,9 @@ func (s *secbootSuite) TestResealKey(c *C) {
 					Model:          &asserts.Model{},
 				},
 			},
-			KeyFiles:             []string{keyFile, keyFile2},
-			TPMPolicyAuthKeyFile: mockTPMPolicyAuthKeyFile,
+			KeyFiles:                   []string{keyFile, keyFile2},
+			TPMPolicyAuthKeyFile:       mockTPMPolicyAuthKeyFile,
+			SignatureDbUpdateKeystores: tc.keystores,
 		}
 
 		numMockSealedKeyObjects := len(myParams.KeyFiles)
@@ -1139,6 +1143,7 @@ func (s *secbootSuite) TestResealKey(c *C) {
 			pcrProfile = profile
 			c.Assert(params.PCRAlgorithm, Equals, tpm2.HashAlgorithmSHA256)
 			c.Assert(params.LoadSequences, DeepEquals, sequences)
+			c.Assert(params.SignatureDbUpdateKeystores, DeepEquals, tc.keystores)
 			return tc.addEFISbPolicyErr
 		})
 		defer restore()
@@ -1342,6 +1347,152 @@ func (s *secbootSuite) TestUnlockEncryptedVolumeUsingKeyErr(c *C) {
 	})
 }
 
+func (s *secbootSuite) testUnlockVolumeUsingPassphrase(c *C, disk disks.Disk, activateErrs []error, allowRecoveryKey bool) (secboot.UnlockResult, error, int) {
+	restore := secboot.MockRandomKernelUUID(func() string {
+		return "random-uuid-123-123"
+	})
+	defer restore()
+	restore = secboot.MockSbConnectToDefaultTPM(func() (*sb_tpm2.Connection, error) {
+		c.Errorf("unexpected use of the TPM")
+		return nil, fmt.Errorf("unexpected call")
+	})
+	defer restore()
+	restore = secboot.MockFDEHasRevealKey(func() bool {
+		c.Errorf("unexpected use of the fde-reveal-key hook")
+		return false
+	})
+	defer restore()
+
+	askCalls := 0
+	restore = secboot.MockAskPassphrase(func(name, device string) (string, error) {
+		askCalls++
+		c.Check(name, Equals, "ubuntu-data")
+		c.Check(device, Equals, "/dev/disk/by-partuuid/123-123-123")
+		return fmt.Sprintf("passphrase-%d", askCalls), nil
+	})
+	defer restore()
+	activateCalls := 0
+	restore = secboot.MockSbActivateVolumeWithKey(func(volumeName, sourceDevicePath string, key []byte,
+		options *sb.ActivateVolumeOptions) error {
+		activateCalls++
+		c.Check(key, DeepEquals, []byte(fmt.Sprintf("passphrase-%d", activateCalls)))
+		c.Check(volumeName, Equals, "ubuntu-data-random-uuid-123-123")
+		c.Check(sourceDevicePath, Equals, "/dev/disk/by-partuuid/123-123-123")
+		return activateErrs[activateCalls-1]
+	})
+	defer restore()
+	recoveryKeyCalls := 0
+	restore = secboot.MockSbActivateVolumeWithRecoveryKey(func(volumeName, sourceDevicePath string,
+		keyReader io.Reader, options *sb.ActivateVolumeOptions) error {
+		recoveryKeyCalls++
+		c.Check(volumeName, Equals, "ubuntu-data-random-uuid-123-123")
+		c.Check(sourceDevicePath, Equals, "/dev/disk/by-partuuid/123-123-123")
+		return nil
+	})
+	defer restore()
+
+	opts := &secboot.UnlockVolumeUsingSealedKeyOptions{
+		UsePassphrase:    true,
+		AllowRecoveryKey: allowRecoveryKey,
+	}
+	unlockRes, err := secboot.UnlockVolumeUsingSealedKeyIfEncrypted(disk, "ubuntu-data", "unused-sealed-key-file", opts)
+	c.Check(askCalls, Equals, len(activateErrs))
+	c.Check(activateCalls, Equals, len(activateErrs))
+	return unlockRes, err, recoveryKeyCalls
+}
+
+func (s *secbootSuite) TestUnlockVolumeUsingPassphraseHappy(c *C) {
+	disk := &disks.MockDiskMapping{
+		Structure: []disks.Partition{
+			{FilesystemLabel: "ubuntu-data-enc", PartitionUUID: "123-123-123"},
+		},
+	}
+	unlockRes, err, recoveryKeyCalls := s.testUnlockVolumeUsingPassphrase(c, disk, []error{fmt.Errorf("wrong passphrase"), nil}, true)
+	c.Assert(err, IsNil)
+	c.Check(recoveryKeyCalls, Equals, 0)
+	c.Check(unlockRes, DeepEquals, secboot.UnlockResult{
+		PartDevice:   "/dev/disk/by-partuuid/123-123-123",
+		FsDevice:     "/dev/mapper/ubuntu-data-random-uuid-123-123",
+		IsEncrypted:  true,
+		UnlockMethod: secboot.UnlockedWithPassphrase,
+	})
+}
+
+func (s *secbootSuite) TestUnlockVolumeUsingPassphraseRAID(c *C) {
+	// the volumes were installed on a RAID array
+	disk := &disks.MockDiskMapping{
+		DevNode: "/dev/md127",
+		DevPath: "/devices/virtual/block/md127",
+		Structure: []disks.Partition{
+			{FilesystemLabel: "ubuntu-data-enc", PartitionUUID: "123-123-123", KernelDeviceNode: "/dev/md127p3"},
+		},
+	}
+	unlockRes, err, recoveryKeyCalls := s.testUnlockVolumeUsingPassphrase(c, disk, []error{nil}, false)
+	c.Assert(err, IsNil)
+	c.Check(recoveryKeyCalls, Equals, 0)
+	c.Check(unlockRes, DeepEquals, secboot.UnlockResult{
+		PartDevice:   "/dev/disk/by-partuuid/123-123-123",
+		FsDevice:     "/dev/mapper/ubuntu-data-random-uuid-123-123",
+		IsEncrypted:  true,
+		UnlockMethod: secboot.UnlockedWithPassphrase,
+	})
+}
+
+func (s *secbootSuite) TestUnlockVolumeUsingPassphraseFallbackToRecoveryKey(c *C) {
+	disk := &disks.MockDiskMapping{
+		Structure: []disks.Partition{
+			{FilesystemLabel: "ubuntu-data-enc", PartitionUUID: "123-123-123"},
+		},
+	}
+	wrong := fmt.Errorf("wrong passphrase")
+	unlockRes, err, recoveryKeyCalls := s.testUnlockVolumeUsingPassphrase(c, disk, []error{wrong, wrong, wrong}, true)
+	c.Assert(err, IsNil)
+	c.Check(recoveryKeyCalls, Equals, 1)
+	c.Check(unlockRes, DeepEquals, secboot.UnlockResult{
+		PartDevice:   "/dev/disk/by-partuuid/123-123-123",
+		FsDevice:     "/dev/mapper/ubuntu-data-random-uuid-123-123",
+		IsEncrypted:  true,
+		UnlockMethod: secboot.UnlockedWithRecoveryKey,
+	})
+}
+
+func (s *secbootSuite) TestUnlockVolumeUsingPassphraseErr(c *C) {
+	disk := &disks.MockDiskMapping{
+		Structure: []disks.Partition{
+			{FilesystemLabel: "ubuntu-data-enc", PartitionUUID: "123-123-123"},
+		},
+	}
+	wrong := fmt.Errorf("wrong passphrase")
+	unlockRes, err, recoveryKeyCalls := s.testUnlockVolumeUsingPassphrase(c, disk, []error{wrong, wrong, wrong}, false)
+	c.Assert(err, ErrorMatches, `cannot unlock encrypted device "ubuntu-data" with the passphrase: wrong passphrase`)
+	c.Check(recoveryKeyCalls, Equals, 0)
+	c.Check(unlockRes, DeepEquals, secboot.UnlockResult{
+		PartDevice:  "/dev/disk/by-partuuid/123-123-123",
+		IsEncrypted: true,
+	})
+}
+
+func (s *secbootSuite) TestDiskUnlockKeyFromKernel(c *C) {
+	restore := secboot.MockSbGetDiskUnlockKeyFromKernel(func(prefix, devicePath string, remove bool) (sb.DiskUnlockKey, error) {
+		c.Check(prefix, Equals, "ubuntu-fde")
+		c.Check(devicePath, Equals, "/dev/disk/by-partuuid/123-123-123")
+		c.Check(remove, Equals, false)
+		return sb.DiskUnlockKey("unlock-key"), nil
+	})
+	defer restore()
+
+	key, err := secboot.DiskUnlockKeyFromKernel("/dev/disk/by-partuuid/123-123-123")
+	c.Assert(err, IsNil)
+	c.Check(key, DeepEquals, []byte("unlock-key"))
+
+	restore = secboot.MockSbGetDiskUnlockKeyFromKernel(func(prefix, devicePath string, remove bool) (sb.DiskUnlockKey, error) {
+		return nil, sb.ErrKernelKeyNotFound
+	})
+	defer restore()
+	_, err = secboot.DiskUnlockKeyFromKernel("/dev/disk/by-partuuid/123-123-123")
+	c.Check(err, ErrorMatches, `cannot get the unlock key of "/dev/disk/by-partuuid/123-123-123": cannot find key in kernel keyring`)
+}
+
 func (s *secbootSuite) TestUnlockVolumeUsingSealedKeyIfEncryptedFdeRevealKeyErr(c *C) {
 	restore := fde.MockRunFDERevealKey(func(req *fde.RevealKeyRequest) ([]byte, error) {
 		return nil, fmt.Errorf("helper error")
@@ -374,7 +374,7 @@ func SealKeys(keys []SealKeyRequest, params *SealKeysParams) error {
 		return fmt.Errorf("TPM device is not enabled")
 	}
 
-	pcrProfile, err := buildPCRProtectionProfile(params.ModelParams)
+	pcrProfile, err := buildPCRProtectionProfile(params.ModelParams, nil)
 	if err != nil {
 		return err
 	}
@@ -430,7 +430,7 @@ func ResealKeys(params *ResealKeysParams) error {
 		return fmt.Errorf("TPM device is not enabled")
 	}
 
-	pcrProfile, err := buildPCRProtectionProfile(params.ModelParams)
+	pcrProfile, err := buildPCRProtectionProfile(params.ModelParams, params.SignatureDbUpdateKeystores)
 	if err != nil {
 		return err
 	}
@@ -465,7 +465,7 @@ func ResealKeys(params *ResealKeysParams) error {
 	return sbSealedKeyObjectRevokeOldPCRProtectionPolicies(sealedKeyObjects[0], tpm, authKey)
 }
 
-func buildPCRProtectionProfile(modelParams []*SealKeyModelParams) (*sb_tpm2.PCRProtectionProfile, error) {
+func buildPCRProtectionProfile(modelParams []*SealKeyModelParams, signatureDbUpdateKeystores []string) (*sb_tpm2.PCRProtectionProfile, error) {
 	numModels := len(modelParams)
 	modelPCRProfiles := make([]*sb_tpm2.PCRProtectionProfile, 0, numModels)
 
@@ -481,10 +481,10 @@ func buildPCRProtectionProfile(modelParams []*SealKeyModelParams) (*sb_tpm2.PCRP
 		policyParams := sb_efi.SecureBootPolicyProfileParams{
 			PCRAlgorithm:  tpm2.HashAlgorithmSHA256,
 			LoadSequences: loadSequences,
-			// TODO:UC20: set SignatureDbUpdateKeystore to support applying forbidden
-			//            signature updates to exclude signing keys (after rotating them).
-			//            This also requires integration of sbkeysync, and some work to
-			//            ensure that the PCR profile is updated before/after sbkeysync executes.
+			// pending signature database updates, typically DBX
+			// updates, for which the values of PCR7 after the
+			// updates are applied are added to the profile
+			SignatureDbUpdateKeystores: signatureDbUpdateKeystores,
 		}
 
 		if err := sbefiAddSecureBootPolicyProfile(modelProfile, &policyParams); err != nil {
@@ -208,7 +208,7 @@ func (tr *tree20) localSnapPath(sn *SeedSnap) (string, error) {
 	return filepath.Join(sysSnapsDir, fmt.Sprintf("%s_%s.snap", sn.SnapName(), sn.Info.Version)), nil
 }
 
-func (tr *tree20) writeAssertions(db asserts.RODatabase, modelRefs []*asserts.Ref, snapsFromModel []*SeedSnap, extraSnaps []*SeedSnap) error {
+func (tr *tree20) writeAssertions(db asserts.RODatabase, modelRefs []*asserts.Ref, snapsFromModel []*SeedSnap, extraSnaps []*SeedSnap, extraAssertionRefs []*asserts.Ref) error {
 	assertsDir := filepath.Join(tr.systemDir, "assertions")
 	if err := os.MkdirAll(assertsDir, 0755); err != nil {
 		return err
@@ -307,6 +307,24 @@ func (tr *tree20) writeAssertions(db asserts.RODatabase, modelRefs []*asserts.Re
 		}
 	}
 
+	if len(extraAssertionRefs) != 0 {
+		extraRefsGen := func(stop <-chan struct{}) <-chan *asserts.Ref {
+			refs := make(chan *asserts.Ref)
+			go func() {
+				for _, aRef := range extraAssertionRefs {
+					if !pushRef(refs, aRef, stop) {
+						return
+					}
+				}
+				close(refs)
+			}()
+			return refs
+		}
+		if err := writeByRefs("extra-assertions", extraRefsGen); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
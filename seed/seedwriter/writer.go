@@ -43,6 +43,12 @@ type Options struct {
 	// The label for the recovery system for Core20 models
 	Label string
 
+	// ExtraAssertions are additional assertions to include in the
+	// seed, together with their prerequisites, such that they are
+	// acknowledged on first boot. Only validation-set and seccomp-deny
+	// assertions are supported.
+	ExtraAssertions []asserts.Assertion
+
 	// TestSkipCopyUnverifiedModel is set to support naive tests
 	// using an unverified model, the resulting image is broken
 	TestSkipCopyUnverifiedModel bool
@@ -172,6 +178,9 @@ type Writer struct {
 	expectedStep writerStep
 
 	modelRefs []*asserts.Ref
+	// extraAssertionRefs are references to the options extra
+	// assertions and the prerequisites not already in modelRefs
+	extraAssertionRefs []*asserts.Ref
 
 	optionsSnaps []*OptionsSnap
 	// consumedOptSnapNum counts which options snaps have been consumed
@@ -225,7 +234,7 @@ type tree interface {
 
 	localSnapPath(*SeedSnap) (string, error)
 
-	writeAssertions(db asserts.RODatabase, modelRefs []*asserts.Ref, snapsFromModel []*SeedSnap, extraSnaps []*SeedSnap) error
+	writeAssertions(db asserts.RODatabase, modelRefs []*asserts.Ref, snapsFromModel []*SeedSnap, extraSnaps []*SeedSnap, extraAssertionRefs []*asserts.Ref) error
 
 	writeMeta(snapsFromModel []*SeedSnap, extraSnaps []*SeedSnap) error
 }
@@ -479,6 +488,10 @@ func (w *Writer) Start(db asserts.RODatabase, newFetcher NewFetcherFunc) (RefAss
 
 	w.modelRefs = f.Refs()
 
+	if err := w.fetchExtraAssertions(f); err != nil {
+		return nil, err
+	}
+
 	if err := w.tree.mkFixedDirs(); err != nil {
 		return nil, err
 	}
@@ -486,6 +499,47 @@ func (w *Writer) Start(db asserts.RODatabase, newFetcher NewFetcherFunc) (RefAss
 	return f, nil
 }
 
+var extraAssertionTypes = []*asserts.AssertionType{
+	asserts.ValidationSetType,
+	asserts.SeccompDenyType,
+}
+
+func (w *Writer) checkExtraAssertion(a asserts.Assertion) error {
+	switch a := a.(type) {
+	case *asserts.ValidationSet:
+		if a.Series() != w.model.Series() {
+			return fmt.Errorf("cannot include validation-set assertion %s/%s for series %s in a seed for a model of series %s", a.AccountID(), a.Name(), a.Series(), w.model.Series())
+		}
+	case *asserts.SeccompDeny:
+		if a.BrandID() != w.model.BrandID() {
+			return fmt.Errorf("cannot include seccomp-deny assertion of brand %q in a seed for a model of brand %q", a.BrandID(), w.model.BrandID())
+		}
+	default:
+		names := make([]string, len(extraAssertionTypes))
+		for i, t := range extraAssertionTypes {
+			names[i] = t.Name
+		}
+		return fmt.Errorf("cannot include %s assertion in the seed, only %s assertions are supported as extra assertions", a.Type().Name, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// fetchExtraAssertions fetches the options extra assertions with their
+// prerequisites.
+func (w *Writer) fetchExtraAssertions(f RefAssertsFetcher) error {
+	prev := len(f.Refs())
+	for _, a := range w.opts.ExtraAssertions {
+		if err := w.checkExtraAssertion(a); err != nil {
+			return err
+		}
+		if err := f.Save(a); err != nil {
+			return fmt.Errorf("cannot fetch and check prerequisites for the extra %s assertion: %v", a.Type().Name, err)
+		}
+	}
+	w.extraAssertionRefs = f.Refs()[prev:]
+	return nil
+}
+
 // LocalSnaps returns a list of seed snaps that are local.  The writer
 // delegates to produce *snap.Info for them to then be set via
 // SetInfo. If matching snap assertions can be found as well they can
@@ -1113,7 +1167,7 @@ func (w *Writer) WriteMeta() error {
 	snapsFromModel := w.snapsFromModel
 	extraSnaps := w.extraSnaps
 
-	if err := w.tree.writeAssertions(w.db, w.modelRefs, snapsFromModel, extraSnaps); err != nil {
+	if err := w.tree.writeAssertions(w.db, w.modelRefs, snapsFromModel, extraSnaps, w.extraAssertionRefs); err != nil {
 		return err
 	}
 
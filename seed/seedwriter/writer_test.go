@@ -33,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/seed"
 	"github.com/snapcore/snapd/seed/seedtest"
 	"github.com/snapcore/snapd/seed/seedwriter"
 	"github.com/snapcore/snapd/snap"
@@ -3364,3 +3365,212 @@ func (s *writerSuite) TestSeedSnapsWriteCore20ErrWhenDirExists(c *C) {
 	c.Assert(err, ErrorMatches, `system "1234" already exists`)
 	c.Assert(seedwriter.IsSytemDirectoryExistsError(err), Equals, true)
 }
+
+func (s *writerSuite) extraValidationSet(c *C) *asserts.ValidationSet {
+	otherBrandPrivKey, _ := assertstest.GenerateKey(752)
+	s.Brands.Register("other-brand", otherBrandPrivKey, nil)
+	assertstest.AddMany(s.StoreSigning, s.Brands.AccountsAndKeys("other-brand")...)
+
+	a, err := s.Brands.Signing("other-brand").Sign(asserts.ValidationSetType, map[string]interface{}{
+		"series":       "16",
+		"account-id":   "other-brand",
+		"authority-id": "other-brand",
+		"name":         "my-set",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "pc-kernel",
+				"id":       s.AssertedSnapID("pc-kernel"),
+				"presence": "required",
+			},
+		},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return a.(*asserts.ValidationSet)
+}
+
+func (s *writerSuite) TestSeedSnapsWriteMetaCore18ExtraAssertions(c *C) {
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"display-name": "my model",
+		"architecture": "amd64",
+		"base":         "core18",
+		"gadget":       "pc=18",
+		"kernel":       "pc-kernel=18",
+	})
+
+	s.makeSnap(c, "snapd", "")
+	s.makeSnap(c, "core18", "")
+	s.makeSnap(c, "pc-kernel=18", "")
+	s.makeSnap(c, "pc=18", "")
+
+	vs := s.extraValidationSet(c)
+	s.opts.ExtraAssertions = []asserts.Assertion{vs}
+
+	complete, w, err := s.upToDownloaded(c, model, s.fillDownloadedSnap)
+	c.Assert(err, IsNil)
+	c.Check(complete, Equals, true)
+
+	err = w.SeedSnaps(nil)
+	c.Assert(err, IsNil)
+
+	err = w.WriteMeta()
+	c.Assert(err, IsNil)
+
+	// the validation-set and its prerequisites are in the seed
+	seedAssertsDir := filepath.Join(s.opts.SeedDir, "assertions")
+	otherAcctKeyPK := s.Brands.AccountKey("other-brand").PublicKeyID()
+	for _, fn := range []string{"other-brand.account", otherAcctKeyPK + ".account-key"} {
+		c.Check(filepath.Join(seedAssertsDir, fn), testutil.FilePresent)
+	}
+	c.Check(filepath.Join(seedAssertsDir, "16,other-brand,my-set,1.validation-set"), testutil.FileEquals, asserts.Encode(vs))
+
+	const usesSnapd = true
+	seedtest.ValidateSeed(c, s.opts.SeedDir, "", usesSnapd, s.StoreSigning.Trusted)
+}
+
+func (s *writerSuite) TestSeedSnapsWriteMetaCore20ExtraAssertions(c *C) {
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"display-name": "my model",
+		"architecture": "amd64",
+		"base":         "core20",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":            "pc-kernel",
+				"id":              s.AssertedSnapID("pc-kernel"),
+				"type":            "kernel",
+				"default-channel": "20",
+			},
+			map[string]interface{}{
+				"name":            "pc",
+				"id":              s.AssertedSnapID("pc"),
+				"type":            "gadget",
+				"default-channel": "20",
+			},
+		},
+	})
+
+	s.makeSnap(c, "snapd", "")
+	s.makeSnap(c, "core20", "")
+	s.makeSnap(c, "pc-kernel=20", "")
+	s.makeSnap(c, "pc=20", "")
+
+	vs := s.extraValidationSet(c)
+	seccompDeny, err := s.Brands.Signing("my-brand").Sign(asserts.SeccompDenyType, map[string]interface{}{
+		"brand-id":  "my-brand",
+		"snap-id":   s.AssertedSnapID("pc"),
+		"syscalls":  []interface{}{"io_uring_setup"},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.opts.ExtraAssertions = []asserts.Assertion{vs, seccompDeny}
+	s.opts.Label = "20191003"
+
+	complete, w, err := s.upToDownloaded(c, model, s.fillDownloadedSnap)
+	c.Assert(err, IsNil)
+	c.Check(complete, Equals, true)
+
+	err = w.SeedSnaps(nil)
+	c.Assert(err, IsNil)
+
+	err = w.WriteMeta()
+	c.Assert(err, IsNil)
+
+	systemDir := filepath.Join(s.opts.SeedDir, "systems", s.opts.Label)
+	extraAsserts := seedtest.ReadAssertions(c, filepath.Join(systemDir, "assertions", "extra-assertions"))
+	// the brand account and key are already with the model
+	c.Assert(extraAsserts, HasLen, 4)
+	types := make([]string, len(extraAsserts))
+	for i, a := range extraAsserts {
+		types[i] = a.Type().Name
+	}
+	c.Check(types, DeepEquals, []string{"account", "account-key", "validation-set", "seccomp-deny"})
+
+	// loading the seed acknowledges the extra assertions
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.StoreSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	sd, err := seed.Open(s.opts.SeedDir, s.opts.Label)
+	c.Assert(err, IsNil)
+	err = sd.LoadAssertions(db, func(b *asserts.Batch) error {
+		return b.CommitTo(db, nil)
+	})
+	c.Assert(err, IsNil)
+	_, err = vs.Ref().Resolve(db.Find)
+	c.Check(err, IsNil)
+	_, err = seccompDeny.Ref().Resolve(db.Find)
+	c.Check(err, IsNil)
+}
+
+func (s *writerSuite) TestStartExtraAssertionsErrors(c *C) {
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"display-name": "my model",
+		"architecture": "amd64",
+		"base":         "core18",
+		"gadget":       "pc=18",
+		"kernel":       "pc-kernel=18",
+	})
+
+	otherSeccompDeny, err := s.StoreSigning.Sign(asserts.SeccompDenyType, map[string]interface{}{
+		"brand-id":  "canonical",
+		"snap-id":   s.AssertedSnapID("pc"),
+		"syscalls":  []interface{}{"io_uring_setup"},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+
+	tests := []struct {
+		extra asserts.Assertion
+		err   string
+	}{
+		{s.devAcct, `cannot include account assertion in the seed, only validation-set, seccomp-deny assertions are supported as extra assertions`},
+		{otherSeccompDeny, `cannot include seccomp-deny assertion of brand "canonical" in a seed for a model of brand "my-brand"`},
+	}
+
+	for _, t := range tests {
+		s.opts.ExtraAssertions = []asserts.Assertion{t.extra}
+		w, err := seedwriter.New(model, s.opts)
+		c.Assert(err, IsNil)
+
+		_, err = w.Start(s.db, s.newFetcher)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func (s *writerSuite) TestStartExtraAssertionsPrerequisitesNotFound(c *C) {
+	model := s.Brands.Model("my-brand", "my-model", map[string]interface{}{
+		"display-name": "my model",
+		"architecture": "amd64",
+		"base":         "core18",
+		"gadget":       "pc=18",
+		"kernel":       "pc-kernel=18",
+	})
+
+	// the key of the unregistered brand is not available
+	unknownPrivKey, _ := assertstest.GenerateKey(752)
+	signing := assertstest.NewSigningDB("unknown-brand", unknownPrivKey)
+	vs, err := signing.Sign(asserts.ValidationSetType, map[string]interface{}{
+		"series":       "16",
+		"account-id":   "unknown-brand",
+		"authority-id": "unknown-brand",
+		"name":         "my-set",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name": "pc-kernel",
+				"id":   s.AssertedSnapID("pc-kernel"),
+			},
+		},
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.opts.ExtraAssertions = []asserts.Assertion{vs}
+
+	w, err := seedwriter.New(model, s.opts)
+	c.Assert(err, IsNil)
+
+	_, err = w.Start(s.db, s.newFetcher)
+	c.Check(err, ErrorMatches, `cannot fetch and check prerequisites for the extra validation-set assertion: .* not found`)
+}
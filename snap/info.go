@@ -317,6 +317,21 @@ type Info struct {
 	// Plugs or slots with issues (they are not included in Plugs or Slots)
 	BadInterfaces map[string]string // slot or plug => message
 
+	// RefreshWindow is the maintenance window, in the format of
+	// refresh.timer, during which the snap prefers to be auto-refreshed.
+	RefreshWindow string
+
+	// PrivateTmpfsSizes maps /tmp and /dev/shm to the size limit, in
+	// bytes, of the tmpfs mounted there in the mount namespace of the
+	// snap, instead of the unlimited private /tmp and the shared
+	// /dev/shm.
+	PrivateTmpfsSizes map[string]int64
+
+	// AliasSets maps the name of each alias set declared by the snap to
+	// the aliases it groups, so that they can be enabled and disabled
+	// as a unit. Alias sets do not define aliases themselves.
+	AliasSets map[string][]string
+
 	// The information in all the remaining fields is not sourced from the snap
 	// blob itself.
 	SideInfo
@@ -335,6 +350,14 @@ type Info struct {
 
 	Media MediaInfos
 
+	// Security describes the security fixes carried by the revision, as
+	// reported by the store for refresh candidates.
+	Security *SecurityInfo
+
+	// Successor is the name of the snap replacing this one after a
+	// rename, as announced by the store for refresh candidates.
+	Successor string
+
 	// subsumed by EditedLinks but needed to handle information
 	// stored by old snapd
 	LegacyWebsite string
@@ -436,6 +459,17 @@ func (s *Info) Provenance() string {
 	return s.SnapProvenance
 }
 
+// AliasSetOf returns the name of the alias set declared by the snap that
+// the given alias belongs to, or "" if none.
+func (s *Info) AliasSetOf(alias string) string {
+	for name, aliases := range s.AliasSets {
+		if strutil.ListContains(aliases, alias) {
+			return name
+		}
+	}
+	return ""
+}
+
 // InstanceName returns the blessed name of the snap decorated with instance
 // key, if any.
 func (s *Info) InstanceName() string {
@@ -956,6 +990,15 @@ type TimerInfo struct {
 	Timer string
 }
 
+// HardeningInfo provides information on the systemd hardening directives
+// a service opted into, on top of its confinement. Access to home
+// directories is left to the home interface, system services keep their
+// SNAP_USER_DATA under /root.
+type HardeningInfo struct {
+	PrivateNetwork          bool
+	RestrictAddressFamilies []string
+}
+
 // StopModeType is the type for the "stop-mode:" of a snap app
 type StopModeType string
 
@@ -1006,6 +1049,7 @@ type AppInfo struct {
 	RestartDelay    timeout.Timeout
 	Completer       string
 	RefreshMode     string
+	RestartStrategy string
 	StopMode        StopModeType
 	InstallMode     string
 
@@ -1029,6 +1073,8 @@ type AppInfo struct {
 	Timer *TimerInfo
 
 	Autostart string
+
+	Hardening *HardeningInfo
 }
 
 // ScreenshotInfo provides information about a screenshot.
@@ -1048,6 +1094,15 @@ type MediaInfo struct {
 
 type MediaInfos []MediaInfo
 
+// SecurityInfo holds the security relevance of a snap revision, as reported
+// by the store.
+type SecurityInfo struct {
+	// Severity is the highest severity of the fixed issues, e.g. "high".
+	Severity string `json:"severity,omitempty"`
+	// CVEs lists the identifiers of the fixed vulnerabilities.
+	CVEs []string `json:"cves,omitempty"`
+}
+
 func (mis MediaInfos) IconURL() string {
 	for _, mi := range mis {
 		if mi.Type == "icon" {
@@ -55,6 +55,9 @@ type snapYaml struct {
 	Layout          map[string]layoutYaml  `yaml:"layout,omitempty"`
 	SystemUsernames map[string]interface{} `yaml:"system-usernames,omitempty"`
 	Links           map[string][]string    `yaml:"links,omitempty"`
+	RefreshWindow   string                 `yaml:"refresh-window,omitempty"`
+	PrivateTmpfs    map[string]string      `yaml:"private-tmpfs,omitempty"`
+	AliasSets       map[string][]string    `yaml:"alias-sets,omitempty"`
 
 	// TypoLayouts is used to detect the use of the incorrect plural form of "layout"
 	TypoLayouts typoDetector `yaml:"layouts,omitempty"`
@@ -85,6 +88,7 @@ type appYaml struct {
 	WatchdogTimeout timeout.Timeout `yaml:"watchdog-timeout,omitempty"`
 	Completer       string          `yaml:"completer,omitempty"`
 	RefreshMode     string          `yaml:"refresh-mode,omitempty"`
+	RestartStrategy string          `yaml:"restart-strategy,omitempty"`
 	StopMode        StopModeType    `yaml:"stop-mode,omitempty"`
 	InstallMode     string          `yaml:"install-mode,omitempty"`
 
@@ -107,6 +111,13 @@ type appYaml struct {
 	Timer string `yaml:"timer,omitempty"`
 
 	Autostart string `yaml:"autostart,omitempty"`
+
+	Hardening *hardeningYaml `yaml:"hardening,omitempty"`
+}
+
+type hardeningYaml struct {
+	PrivateNetwork          bool     `yaml:"private-network,omitempty"`
+	RestrictAddressFamilies []string `yaml:"restrict-address-families,omitempty"`
 }
 
 type hookYaml struct {
@@ -238,6 +249,10 @@ func infoFromSnapYaml(yamlData []byte, strk *scopedTracker) (*Info, error) {
 		return nil, err
 	}
 
+	if err := setPrivateTmpfsFromSnapYaml(y, snap); err != nil {
+		return nil, err
+	}
+
 	// FIXME: validation of the fields
 	return snap, nil
 }
@@ -294,6 +309,8 @@ func infoSkeletonFromSnapYaml(y snapYaml) *Info {
 		Environment:         y.Environment,
 		SystemUsernames:     make(map[string]*SystemUsernameInfo),
 		OriginalLinks:       make(map[string][]string),
+		RefreshWindow:       y.RefreshWindow,
+		AliasSets:           y.AliasSets,
 	}
 
 	sort.Strings(snap.Assumes)
@@ -373,6 +390,7 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 			Completer:       yApp.Completer,
 			StopMode:        yApp.StopMode,
 			RefreshMode:     yApp.RefreshMode,
+			RestartStrategy: yApp.RestartStrategy,
 			InstallMode:     yApp.InstallMode,
 			Before:          yApp.Before,
 			After:           yApp.After,
@@ -462,6 +480,12 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 				Timer: yApp.Timer,
 			}
 		}
+		if yApp.Hardening != nil {
+			app.Hardening = &HardeningInfo{
+				PrivateNetwork:          yApp.Hardening.PrivateNetwork,
+				RestrictAddressFamilies: yApp.Hardening.RestrictAddressFamilies,
+			}
+		}
 		// collect all common IDs
 		if app.CommonID != "" {
 			snap.CommonIDs = append(snap.CommonIDs, app.CommonID)
@@ -571,6 +595,21 @@ func setLinksFromSnapYaml(y snapYaml, snap *Info) error {
 	return nil
 }
 
+func setPrivateTmpfsFromSnapYaml(y snapYaml, snap *Info) error {
+	if len(y.PrivateTmpfs) == 0 {
+		return nil
+	}
+	snap.PrivateTmpfsSizes = make(map[string]int64, len(y.PrivateTmpfs))
+	for path, size := range y.PrivateTmpfs {
+		bytes, err := strutil.ParseByteSize(size)
+		if err != nil {
+			return fmt.Errorf("cannot parse private-tmpfs size of %q: %v", path, err)
+		}
+		snap.PrivateTmpfsSizes[path] = bytes
+	}
+	return nil
+}
+
 func bindUnscopedPlugs(snap *Info, strk *scopedTracker) {
 	for plugName, plug := range snap.Plugs {
 		if strk.plug(plug) {
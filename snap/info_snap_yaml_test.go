@@ -86,6 +86,51 @@ version: 1.0`))
 	c.Check(info.SnapProvenance, Equals, "delegated-prov")
 }
 
+func (s *InfoSnapYamlTestSuite) TestRefreshWindow(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+refresh-window: mon-fri,02:00-04:00`))
+	c.Assert(err, IsNil)
+	c.Check(info.RefreshWindow, Equals, "mon-fri,02:00-04:00")
+}
+
+func (s *InfoSnapYamlTestSuite) TestPrivateTmpfs(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+private-tmpfs:
+  /tmp: 64MB
+  /dev/shm: 500kB`))
+	c.Assert(err, IsNil)
+	c.Check(info.PrivateTmpfsSizes, DeepEquals, map[string]int64{
+		"/tmp":     64 * 1000 * 1000,
+		"/dev/shm": 500 * 1000,
+	})
+
+	_, err = snap.InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+private-tmpfs:
+  /tmp: lots`))
+	c.Assert(err, ErrorMatches, `cannot parse private-tmpfs size of "/tmp": cannot parse "lots": .*`)
+}
+
+func (s *InfoSnapYamlTestSuite) TestAliasSets(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+alias-sets:
+  git:
+    - gst
+    - gco
+  misc: [ll]`))
+	c.Assert(err, IsNil)
+	c.Check(info.AliasSets, DeepEquals, map[string][]string{
+		"git":  {"gst", "gco"},
+		"misc": {"ll"},
+	})
+	c.Check(info.AliasSetOf("gco"), Equals, "git")
+	c.Check(info.AliasSetOf("ll"), Equals, "misc")
+	c.Check(info.AliasSetOf("other"), Equals, "")
+}
+
 func (s *InfoSnapYamlTestSuite) TestFail(c *C) {
 	_, err := snap.InfoFromSnapYaml([]byte("random-crap"))
 	c.Assert(err, ErrorMatches, "(?m)cannot parse snap.yaml:.*")
@@ -1867,6 +1912,27 @@ apps:
 	c.Check(app.Timer, DeepEquals, &snap.TimerInfo{App: app, Timer: "mon,10:00-12:00"})
 }
 
+func (s *YamlSuite) TestSnapYamlAppHardening(c *C) {
+	y := []byte(`name: wat
+version: 42
+apps:
+ foo:
+   daemon: simple
+   hardening:
+     private-network: true
+     restrict-address-families: [AF_INET, AF_INET6]
+ bar:
+   daemon: simple
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Apps["foo"].Hardening, DeepEquals, &snap.HardeningInfo{
+		PrivateNetwork:          true,
+		RestrictAddressFamilies: []string{"AF_INET", "AF_INET6"},
+	})
+	c.Check(info.Apps["bar"].Hardening, IsNil)
+}
+
 func (s *YamlSuite) TestSnapYamlAppAutostart(c *C) {
 	yAutostart := []byte(`name: wat
 version: 42
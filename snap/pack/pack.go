@@ -25,13 +25,18 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
 
 	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/kernel"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snapdir"
 	"github.com/snapcore/snapd/snap/squashfs"
+	"github.com/snapcore/snapd/snapdtool"
 )
 
 // this could be shipped as a file like "info", and save on the memory and the
@@ -206,6 +211,53 @@ type Options struct {
 	SnapName string
 	// Compression method to use
 	Compression string
+	// Reproducible requests a snap file that is bit-identical when packed
+	// from identical content, the parameters of the build are recorded in
+	// the snap in meta/build-info.yaml
+	Reproducible bool
+	// SourceDateEpoch is the timestamp set on all the files of a
+	// reproducible snap, the Unix epoch is used if unset
+	SourceDateEpoch time.Time
+}
+
+// buildInfoFile is the path in a reproducible snap of the file recording the
+// parameters it was built with.
+const buildInfoFile = "meta/build-info.yaml"
+
+type buildInfo struct {
+	Reproducible    bool   `yaml:"reproducible"`
+	SourceDateEpoch int64  `yaml:"source-date-epoch"`
+	Compression     string `yaml:"compression"`
+	SnapdVersion    string `yaml:"snapd-version"`
+}
+
+func writeBuildInfo(opts *Options, compression string) (filename string, err error) {
+	ts := opts.SourceDateEpoch.Unix()
+	if ts < 0 {
+		ts = 0
+	}
+	content, err := yaml.Marshal(&buildInfo{
+		Reproducible:    true,
+		SourceDateEpoch: ts,
+		Compression:     compression,
+		SnapdVersion:    snapdtool.Version,
+	})
+	if err != nil {
+		return "", err
+	}
+	tmpf, err := ioutil.TempFile("", ".snap-pack-build-info-")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmpf.Write(content)
+	if err1 := tmpf.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(tmpf.Name())
+		return "", err
+	}
+	return tmpf.Name(), nil
 }
 
 var Defaults *Options = nil
@@ -234,13 +286,32 @@ func Snap(sourceDir string, opts *Options) (string, error) {
 	}
 	defer os.Remove(excludes)
 
-	snapName := snapPath(info, opts.TargetDir, opts.SnapName)
-	d := squashfs.New(snapName)
-	if err = d.Build(sourceDir, &squashfs.BuildOpts{
+	buildOpts := &squashfs.BuildOpts{
 		SnapType:     string(info.Type()),
 		Compression:  opts.Compression,
 		ExcludeFiles: []string{excludes},
-	}); err != nil {
+	}
+	if opts.Reproducible {
+		if osutil.FileExists(filepath.Join(sourceDir, buildInfoFile)) {
+			return "", fmt.Errorf("cannot pack a reproducible snap: %s is reserved", buildInfoFile)
+		}
+		compression := opts.Compression
+		if compression == "" {
+			compression = "xz"
+		}
+		buildInfo, err := writeBuildInfo(opts, compression)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(buildInfo)
+		buildOpts.Reproducible = true
+		buildOpts.Timestamp = opts.SourceDateEpoch
+		buildOpts.ExtraFiles = map[string]string{buildInfoFile: buildInfo}
+	}
+
+	snapName := snapPath(info, opts.TargetDir, opts.SnapName)
+	d := squashfs.New(snapName)
+	if err = d.Build(sourceDir, buildOpts); err != nil {
 		return "", err
 	}
 
@@ -29,6 +29,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -39,6 +40,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/pack"
 	"github.com/snapcore/snapd/snap/squashfs"
+	"github.com/snapcore/snapd/snapdtool"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -353,3 +355,48 @@ func (s *packSuite) TestPackWithCompressionUnhappy(c *C) {
 		c.Assert(snapfile, Equals, "")
 	}
 }
+
+func (s *packSuite) TestPackReproducible(c *C) {
+	sourceDir := makeExampleSnapSourceDir(c, "{name: hello, version: 0}")
+	buildInfo := filepath.Join(c.MkDir(), "build-info.yaml")
+	// keep a copy of the build info added to the snap
+	mksq := testutil.MockCommand(c, "mksquashfs", fmt.Sprintf(`
+while [ "$#" -gt 0 ]; do
+    if [ "$1" = "-p" ]; then
+        eval cp "${2##* 0 0 cat }" %q
+    fi
+    shift
+done
+`, buildInfo))
+	defer mksq.Restore()
+	defer snapdtool.MockVersion("2.99")()
+
+	snapfile, err := pack.Snap(sourceDir, &pack.Options{
+		TargetDir:       c.MkDir(),
+		Compression:     "lzo",
+		Reproducible:    true,
+		SourceDateEpoch: time.Unix(1600000000, 0),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(mksq.Calls(), HasLen, 1)
+	args := strings.Join(mksq.Calls()[0], " ")
+	c.Check(args, Matches, fmt.Sprintf(".* -all-time 1600000000 -mkfs-time 1600000000 -p meta/build-info.yaml f 644 0 0 cat '%s/.snap-pack-build-info-[0-9]+'$", regexp.QuoteMeta(os.TempDir())))
+	c.Check(snapfile, Equals, filepath.Join(filepath.Dir(snapfile), "hello_0_all.snap"))
+	c.Check(buildInfo, testutil.FileEquals, `reproducible: true
+source-date-epoch: 1600000000
+compression: lzo
+snapd-version: "2.99"
+`)
+}
+
+func (s *packSuite) TestPackReproducibleBuildInfoReserved(c *C) {
+	sourceDir := makeExampleSnapSourceDir(c, "{name: hello, version: 0}")
+	err := ioutil.WriteFile(filepath.Join(sourceDir, "meta", "build-info.yaml"), nil, 0644)
+	c.Assert(err, IsNil)
+
+	_, err = pack.Snap(sourceDir, &pack.Options{
+		TargetDir:    c.MkDir(),
+		Reproducible: true,
+	})
+	c.Assert(err, ErrorMatches, "cannot pack a reproducible snap: meta/build-info.yaml is reserved")
+}
@@ -58,6 +58,10 @@ type GroupQuotaCPU struct {
 	// CPUSet is a list of CPU core indices that are allowed to be used by the group. Each value
 	// in the list refers to the CPU core number. If the list is empty, all CPU cores are allowed.
 	CPUSet []int `json:"allowed-cpus,omitempty"`
+
+	// MemoryNodes is a list of NUMA node indices the group is allowed to allocate
+	// memory on. If the list is empty, all memory nodes are allowed.
+	MemoryNodes []int `json:"allowed-memory-nodes,omitempty"`
 }
 
 // GroupQuotaJournal contains the supported limits for journald. Any limit set here
@@ -81,6 +85,16 @@ type GroupQuotaJournal struct {
 	// RateCount number of messages is allowed. A zero value in this field will
 	// disable the rate-limit.
 	RatePeriod time.Duration `json:"rate-period,omitempty"`
+
+	// RetentionPeriod is how long journal entries of the group are kept at
+	// most. A zero value means entries are kept until the size limit is
+	// reached.
+	RetentionPeriod time.Duration `json:"retention-period,omitempty"`
+
+	// Storage is where the journal entries of the group are stored, as for
+	// the Storage= setting of journald.conf. An empty value is the same as
+	// "auto", which honors the journal.persistent system setting.
+	Storage string `json:"storage,omitempty"`
 }
 
 // Group is a quota group of snaps, services or sub-groups that are all subject
@@ -169,6 +183,9 @@ func (grp *Group) GetQuotaResources() Resources {
 		if len(grp.CPULimit.CPUSet) != 0 {
 			resourcesBuilder.WithCPUSet(grp.CPULimit.CPUSet)
 		}
+		if len(grp.CPULimit.MemoryNodes) != 0 {
+			resourcesBuilder.WithMemoryNodes(grp.CPULimit.MemoryNodes)
+		}
 	}
 	if grp.ThreadLimit != 0 {
 		resourcesBuilder.WithThreadLimit(grp.ThreadLimit)
@@ -185,6 +202,12 @@ func (grp *Group) GetQuotaResources() Resources {
 		if grp.JournalLimit.RateEnabled {
 			resourcesBuilder.WithJournalRate(grp.JournalLimit.RateCount, grp.JournalLimit.RatePeriod)
 		}
+		if grp.JournalLimit.RetentionPeriod != 0 {
+			resourcesBuilder.WithJournalRetention(grp.JournalLimit.RetentionPeriod)
+		}
+		if grp.JournalLimit.Storage != "" {
+			resourcesBuilder.WithJournalStorage(grp.JournalLimit.Storage)
+		}
 	}
 	return resourcesBuilder.Build()
 }
@@ -326,6 +349,9 @@ type groupQuotaAllocations struct {
 
 	CPUSetLimit              []int
 	CPUSetReservedByChildren []int
+
+	MemoryNodesLimit              []int
+	MemoryNodesReservedByChildren []int
 }
 
 func max(a, b int) int {
@@ -351,6 +377,15 @@ func (grp *Group) GetLocalCPUSetQuota() []int {
 	return grp.CPULimit.CPUSet
 }
 
+// GetLocalMemoryNodesQuota returns the current memory nodes quota for the group.
+// This does not return any inherited memory nodes quota.
+func (grp *Group) GetLocalMemoryNodesQuota() []int {
+	if grp.CPULimit == nil {
+		return nil
+	}
+	return grp.CPULimit.MemoryNodes
+}
+
 // GetCPUSetQuota returns the currently active CPU set quota for this group, which
 // includes the case where the CPU set is inherited from a parent group.
 func (grp *Group) GetCPUSetQuota() []int {
@@ -409,6 +444,8 @@ func (grp *Group) getQuotaAllocations(allQuotas map[string]*groupQuotaAllocation
 		CPULimit:     grp.getCurrentCPUAllocation(),
 		ThreadsLimit: grp.ThreadLimit,
 		CPUSetLimit:  grp.GetLocalCPUSetQuota(),
+
+		MemoryNodesLimit: grp.GetLocalMemoryNodesQuota(),
 	}
 
 	// sliceUniqueAndSort sorts an array of ints in ascending order and removes duplicates
@@ -445,12 +482,22 @@ func (grp *Group) getQuotaAllocations(allQuotas map[string]*groupQuotaAllocation
 		} else if len(subGroupLimits.CPUSetReservedByChildren) > 0 {
 			limits.CPUSetReservedByChildren = append(limits.CPUSetReservedByChildren, subGroupLimits.CPUSetReservedByChildren...)
 		}
+
+		// Same for the allowed memory nodes.
+		if len(subGroupLimits.MemoryNodesLimit) > 0 {
+			limits.MemoryNodesReservedByChildren = append(limits.MemoryNodesReservedByChildren, subGroupLimits.MemoryNodesLimit...)
+		} else if len(subGroupLimits.MemoryNodesReservedByChildren) > 0 {
+			limits.MemoryNodesReservedByChildren = append(limits.MemoryNodesReservedByChildren, subGroupLimits.MemoryNodesReservedByChildren...)
+		}
 	}
 
 	// Sort the allowed CPUs list, and remove duplicates.
 	if len(limits.CPUSetReservedByChildren) > 0 {
 		limits.CPUSetReservedByChildren = sliceUniqueAndSort(limits.CPUSetReservedByChildren)
 	}
+	if len(limits.MemoryNodesReservedByChildren) > 0 {
+		limits.MemoryNodesReservedByChildren = sliceUniqueAndSort(limits.MemoryNodesReservedByChildren)
+	}
 
 	// Store the retrieved limits for the group
 	allQuotas[grp.Name] = limits
@@ -581,21 +628,21 @@ func contains(s []int, e int) bool {
 	return false
 }
 
+// isSuperset returns true if a is a superset of b.
+func isSuperset(a, b []int) bool {
+	for _, b1 := range b {
+		if !contains(a, b1) {
+			return false
+		}
+	}
+	return true
+}
+
 // validateCPUsAllowedResourceFit verifies that the new cpu-set doesn't conflict with the current reserved cpu-set
 // of the group, and if not locates the nearest parent group that has a cpu-set quota, and then verifies
 // that the requested cpu cores match a subset of the previously set allowance.
 func (grp *Group) validateCPUsAllowedResourceFit(allQuotas map[string]*groupQuotaAllocations, cpusAllowed []int) error {
 
-	// isSuperset returns true if a is a superset of b.
-	isSuperset := func(a, b []int) bool {
-		for _, b1 := range b {
-			if !contains(a, b1) {
-				return false
-			}
-		}
-		return true
-	}
-
 	// make sure current cpu sets don't conflict, we can avoid any
 	// recursive descent as we already have counted up the usage of our children.
 	currentLimits := allQuotas[grp.Name]
@@ -630,6 +677,37 @@ func (grp *Group) validateCPUsAllowedResourceFit(allQuotas map[string]*groupQuot
 	return nil
 }
 
+// validateMemoryNodesAllowedResourceFit verifies that the new set of memory nodes
+// still covers the memory nodes of the sub-groups, and that it is a subset of the
+// memory nodes of the nearest parent group which has a memory nodes quota.
+func (grp *Group) validateMemoryNodesAllowedResourceFit(allQuotas map[string]*groupQuotaAllocations, nodesAllowed []int) error {
+	currentLimits := allQuotas[grp.Name]
+	if currentLimits != nil {
+		if !isSuperset(nodesAllowed, currentLimits.MemoryNodesReservedByChildren) {
+			return fmt.Errorf("group memory-nodes %v is not a superset of current subgroup usage of %v",
+				nodesAllowed, currentLimits.MemoryNodesReservedByChildren)
+		}
+
+		if isSuperset(grp.GetLocalMemoryNodesQuota(), nodesAllowed) {
+			return nil
+		}
+	}
+
+	parent := grp.parentGroup
+	for parent != nil {
+		limits := allQuotas[parent.Name]
+		if limits != nil && len(limits.MemoryNodesLimit) != 0 {
+			if !isSuperset(limits.MemoryNodesLimit, nodesAllowed) {
+				return fmt.Errorf("sub-group memory-nodes %v is not a subset of group %q memory-nodes %v",
+					nodesAllowed, parent.Name, limits.MemoryNodesLimit)
+			}
+			break
+		}
+		parent = parent.parentGroup
+	}
+	return nil
+}
+
 // validateThreadResourceFit verifies that the new thread limit doesn't conflict with the current reserved thread
 // limit of the group, and if not locates the nearest parent group that has a thread quota, and then verifies
 // if that group has any space available by checking its 'threadsReserved'. The 'threadsReserved' tells us how much
@@ -706,6 +784,11 @@ func (grp *Group) validateQuotasFit(resourceLimits Resources) error {
 			return err
 		}
 	}
+	if resourceLimits.MemoryNodes != nil && len(resourceLimits.MemoryNodes.Nodes) != 0 {
+		if err := grp.validateMemoryNodesAllowedResourceFit(allQuotas, resourceLimits.MemoryNodes.Nodes); err != nil {
+			return err
+		}
+	}
 	if resourceLimits.Threads != nil {
 		if err := grp.validateThreadResourceFit(allQuotas, resourceLimits.Threads.Limit); err != nil {
 			return err
@@ -717,7 +800,9 @@ func (grp *Group) validateQuotasFit(resourceLimits Resources) error {
 // UpdateQuotaLimits updates all the quota limits set for the group to the new limits
 // given. The limits will be validated against the group's parent group's limits, to verify
 // that they fit. For instance, if the parent group has a memory limit of 1GB, and the new limit
-// given here is 2GB, then the new limit will be rejected.
+// given here is 2GB, then the new limit will be rejected. The CPU limit and the
+// cpuset controls (allowed CPUs and memory nodes) share the CPU quota of the
+// group but are updated independently, changing one keeps the others.
 func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 	currentLimits := grp.GetQuotaResources()
 	if err := currentLimits.ValidateChange(resourceLimits); err != nil {
@@ -732,10 +817,12 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 		grp.MemoryLimit = resourceLimits.Memory.Limit
 	}
 	if resourceLimits.CPU != nil {
-		grp.CPULimit = &GroupQuotaCPU{
-			Count:      resourceLimits.CPU.Count,
-			Percentage: resourceLimits.CPU.Percentage,
+		// keep any cpuset controls that were set before
+		if grp.CPULimit == nil {
+			grp.CPULimit = &GroupQuotaCPU{}
 		}
+		grp.CPULimit.Count = resourceLimits.CPU.Count
+		grp.CPULimit.Percentage = resourceLimits.CPU.Percentage
 	}
 	if resourceLimits.CPUSet != nil {
 		if grp.CPULimit == nil {
@@ -743,6 +830,12 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 		}
 		grp.CPULimit.CPUSet = resourceLimits.CPUSet.CPUs
 	}
+	if resourceLimits.MemoryNodes != nil {
+		if grp.CPULimit == nil {
+			grp.CPULimit = &GroupQuotaCPU{}
+		}
+		grp.CPULimit.MemoryNodes = resourceLimits.MemoryNodes.Nodes
+	}
 	if resourceLimits.Threads != nil {
 		grp.ThreadLimit = resourceLimits.Threads.Limit
 	}
@@ -758,6 +851,12 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 			grp.JournalLimit.RateCount = resourceLimits.Journal.Rate.Count
 			grp.JournalLimit.RatePeriod = resourceLimits.Journal.Rate.Period
 		}
+		if resourceLimits.Journal.Retention != nil {
+			grp.JournalLimit.RetentionPeriod = resourceLimits.Journal.Retention.Period
+		}
+		if resourceLimits.Journal.Storage != nil {
+			grp.JournalLimit.Storage = resourceLimits.Journal.Storage.Mode
+		}
 	}
 	return nil
 }
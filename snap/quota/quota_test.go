@@ -206,6 +206,13 @@ func (ts *quotaTestSuite) TestSimpleSubGroupVerification(c *C) {
 			err:        "sub-group cpu-set \\[1\\] is not a subset of group \"myroot\" cpu-set \\[0\\]",
 			comment:    "sub group with different cpu allowance quota than parent unhappy",
 		},
+		{
+			rootlimits: quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).WithMemoryNodes([]int{0}).Build(),
+			subname:    "sub",
+			sublimits:  quota.NewResourcesBuilder().WithCPUSet([]int{1}).WithMemoryNodes([]int{0, 1}).Build(),
+			err:        "sub-group memory-nodes \\[0 1\\] is not a subset of group \"myroot\" memory-nodes \\[0\\]",
+			comment:    "sub group with different memory nodes allowance than parent unhappy",
+		},
 		{
 			rootlimits: quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).WithCPUCount(1).WithCPUPercentage(100).WithCPUSet([]int{0}).WithThreadLimit(32).Build(),
 			subname:    "sub",
@@ -1132,6 +1139,76 @@ func (ts *quotaTestSuite) TestChangingParentCpuSetLimits(c *C) {
 	c.Check(err, ErrorMatches, `group cpu-set \[0\] is not a superset of current subgroup usage of \[0 1\]`)
 }
 
+func (ts *quotaTestSuite) TestChangingParentMemoryNodesLimits(c *C) {
+	// The purpose here is to make sure we can't change the limits of the parent group
+	// that would otherwise conflict with the current usage of limits by children of the
+	// parent.
+	grp1, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithMemoryNodes([]int{0, 1}).Build())
+	c.Assert(err, IsNil)
+
+	subgrp1, err := grp1.NewSubGroup("cpu-sub", quota.NewResourcesBuilder().WithCPUCount(2).WithCPUPercentage(50).Build())
+	c.Assert(err, IsNil)
+
+	// Create a nested subgroup which uses both of the allowed memory nodes
+	_, err = subgrp1.NewSubGroup("nodes-sub", quota.NewResourcesBuilder().WithMemoryNodes([]int{0, 1}).Build())
+	c.Assert(err, IsNil)
+
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithMemoryNodes([]int{1}).Build())
+	c.Check(err, ErrorMatches, `group memory-nodes \[1\] is not a superset of current subgroup usage of \[0 1\]`)
+
+	// a larger set of memory nodes is fine
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithMemoryNodes([]int{0, 1, 2}).Build())
+	c.Check(err, IsNil)
+	c.Check(grp1.CPULimit.MemoryNodes, DeepEquals, []int{0, 1, 2})
+}
+
+func (ts *quotaTestSuite) TestChangingCpuLimitKeepsCpuSetControls(c *C) {
+	grp, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).WithMemoryNodes([]int{0}).Build())
+	c.Assert(err, IsNil)
+
+	err = grp.UpdateQuotaLimits(quota.NewResourcesBuilder().WithCPUCount(1).WithCPUPercentage(50).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp.CPULimit, DeepEquals, &quota.GroupQuotaCPU{
+		Count:       1,
+		Percentage:  50,
+		CPUSet:      []int{0, 1},
+		MemoryNodes: []int{0},
+	})
+	c.Check(grp.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().
+		WithCPUCount(1).WithCPUPercentage(50).WithCPUSet([]int{0, 1}).WithMemoryNodes([]int{0}).Build())
+}
+
+func (ts *quotaTestSuite) TestUpdateQuotaLimitsCpuKeepsCpuSet(c *C) {
+	grp, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithCPUCount(2).WithCPUPercentage(50).WithCPUSet([]int{0, 1}).Build())
+	c.Assert(err, IsNil)
+
+	// updating the CPU limit used to drop the allowed CPUs
+	err = grp.UpdateQuotaLimits(quota.NewResourcesBuilder().WithCPUCount(1).WithCPUPercentage(100).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp.CPULimit, DeepEquals, &quota.GroupQuotaCPU{
+		Count:      1,
+		Percentage: 100,
+		CPUSet:     []int{0, 1},
+	})
+
+	// and updating the allowed CPUs keeps the CPU limit
+	err = grp.UpdateQuotaLimits(quota.NewResourcesBuilder().WithCPUSet([]int{0, 1, 2}).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp.CPULimit, DeepEquals, &quota.GroupQuotaCPU{
+		Count:      1,
+		Percentage: 100,
+		CPUSet:     []int{0, 1, 2},
+	})
+
+	// the CPU limit itself is still replaced as a whole
+	err = grp.UpdateQuotaLimits(quota.NewResourcesBuilder().WithCPUPercentage(75).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp.CPULimit, DeepEquals, &quota.GroupQuotaCPU{
+		Percentage: 75,
+		CPUSet:     []int{0, 1, 2},
+	})
+}
+
 func (ts *quotaTestSuite) TestChangingParentThreadLimits(c *C) {
 	// The purpose here is to make sure we can't change the limits of the parent group
 	// that would otherwise conflict with the current usage of limits by children of the
@@ -1336,6 +1413,13 @@ func (ts *quotaTestSuite) TestJournalQuotasSetCorrectly(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(grp3.JournalLimit, NotNil)
 	c.Check(grp3.JournalLimit.Size, Equals, quantity.SizeMiB)
+
+	grp4, err := quota.NewGroup("groot4", quota.NewResourcesBuilder().WithJournalRetention(time.Hour).WithJournalStorage("volatile").Build())
+	c.Assert(err, IsNil)
+	c.Assert(grp4.JournalLimit, NotNil)
+	c.Check(grp4.JournalLimit.RetentionPeriod, Equals, time.Hour)
+	c.Check(grp4.JournalLimit.Storage, Equals, "volatile")
+	c.Check(grp4.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().WithJournalRetention(time.Hour).WithJournalStorage("volatile").Build())
 }
 
 func (ts *quotaTestSuite) TestJournalQuotasUpdatesCorrectly(c *C) {
@@ -25,6 +25,7 @@ import (
 
 	"github.com/snapcore/snapd/gadget/quantity"
 	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/strutil"
 )
 
 var (
@@ -52,6 +53,13 @@ type ResourceCPUSet struct {
 	CPUs []int `json:"cpus"`
 }
 
+// ResourceMemoryNodes is the set of NUMA nodes the processes of a group may
+// allocate memory on. Together with the CPU set it makes up the cpuset
+// controls of a group.
+type ResourceMemoryNodes struct {
+	Nodes []int `json:"nodes"`
+}
+
 type ResourceThreads struct {
 	Limit int `json:"limit"`
 }
@@ -66,24 +74,39 @@ type ResourceJournalRate struct {
 	Period time.Duration `json:"period"`
 }
 
+type ResourceJournalRetention struct {
+	// Period is how long journal entries are kept at most.
+	Period time.Duration `json:"period"`
+}
+
+type ResourceJournalStorage struct {
+	// Mode is where the journal entries are stored, one of "auto",
+	// "volatile" and "persistent", as for the Storage= setting of
+	// journald.conf.
+	Mode string `json:"mode"`
+}
+
 // ResourceJournal represents the available journal quotas. It's structured
 // a bit different compared to the other resources to support namespace only
 // cases, where the existence of != nil ResourceJournal with empty values
 // indicates that the namespace only is wanted.
 type ResourceJournal struct {
-	Size *ResourceJournalSize `json:"size,omitempty"`
-	Rate *ResourceJournalRate `json:"rate,omitempty"`
+	Size      *ResourceJournalSize      `json:"size,omitempty"`
+	Rate      *ResourceJournalRate      `json:"rate,omitempty"`
+	Retention *ResourceJournalRetention `json:"retention,omitempty"`
+	Storage   *ResourceJournalStorage   `json:"storage,omitempty"`
 }
 
 // Resources are built up of multiple quota limits. Each quota limit is a pointer
 // value to indicate that their presence may be optional, and because we want to detect
 // whenever someone changes a limit to '0' explicitly.
 type Resources struct {
-	Memory  *ResourceMemory  `json:"memory,omitempty"`
-	CPU     *ResourceCPU     `json:"cpu,omitempty"`
-	CPUSet  *ResourceCPUSet  `json:"cpu-set,omitempty"`
-	Threads *ResourceThreads `json:"thread,omitempty"`
-	Journal *ResourceJournal `json:"journal,omitempty"`
+	Memory      *ResourceMemory      `json:"memory,omitempty"`
+	CPU         *ResourceCPU         `json:"cpu,omitempty"`
+	CPUSet      *ResourceCPUSet      `json:"cpu-set,omitempty"`
+	MemoryNodes *ResourceMemoryNodes `json:"memory-nodes,omitempty"`
+	Threads     *ResourceThreads     `json:"thread,omitempty"`
+	Journal     *ResourceJournal     `json:"journal,omitempty"`
 }
 
 const (
@@ -99,8 +122,16 @@ const (
 	// usage, but we have selected 64kB to protect against ridiculously small values.
 	journalLimitMin = 64 * quantity.SizeKiB
 	journalLimitMax = 4 * quantity.SizeGiB
+
+	// MaxRetentionSec has a resolution of seconds.
+	journalRetentionMin = time.Second
 )
 
+// journalStorageModes are the supported values of the Storage= setting of
+// journald.conf, "none" is left out as it would make the logs of the snaps
+// in the group unavailable.
+var journalStorageModes = []string{"auto", "volatile", "persistent"}
+
 func (qr *Resources) validateMemoryQuota() error {
 	// make sure the memory limit is not zero
 	if qr.Memory.Limit == 0 {
@@ -152,6 +183,14 @@ func (qr *Resources) validateCPUSetQuota() error {
 	return nil
 }
 
+func (qr *Resources) validateMemoryNodesQuota() error {
+	if len(qr.MemoryNodes.Nodes) == 0 {
+		return fmt.Errorf("memory-nodes quota must not be empty")
+	}
+
+	return nil
+}
+
 func (qr *Resources) validateThreadQuota() error {
 	// make sure the thread count is greater than 0
 	if qr.Threads.Limit <= 0 {
@@ -183,6 +222,18 @@ func (qr *Resources) validateJournalQuota() error {
 			return fmt.Errorf("journal quota must have a period of at least 1 microsecond (minimum resolution)")
 		}
 	}
+
+	if qr.Journal.Retention != nil {
+		if qr.Journal.Retention.Period < journalRetentionMin {
+			return fmt.Errorf("journal quota must have a retention period of at least %s", journalRetentionMin)
+		}
+	}
+
+	if qr.Journal.Storage != nil {
+		if !strutil.ListContains(journalStorageModes, qr.Journal.Storage.Mode) {
+			return fmt.Errorf("journal quota storage must be one of %s", strutil.Quoted(journalStorageModes))
+		}
+	}
 	return nil
 }
 
@@ -199,6 +250,14 @@ func (qr *Resources) CheckFeatureRequirements() error {
 			return fmt.Errorf("cannot use CPU set with cgroup version %d", cgroupVer)
 		}
 	}
+	if qr.MemoryNodes != nil {
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use memory nodes with cgroup version %d", cgroupVer)
+		}
+	}
 	if qr.Memory != nil && cgroupCheckMemoryCgroupErr != nil {
 		return fmt.Errorf("cannot use memory quota: %v", cgroupCheckMemoryCgroupErr)
 	}
@@ -240,6 +299,12 @@ func (qr *Resources) Validate() error {
 		}
 	}
 
+	if qr.MemoryNodes != nil {
+		if err := qr.validateMemoryNodesQuota(); err != nil {
+			return err
+		}
+	}
+
 	if qr.Threads != nil {
 		if err := qr.validateThreadQuota(); err != nil {
 			return err
@@ -319,6 +384,11 @@ func (qr *Resources) ValidateChange(newLimits Resources) error {
 		}
 	}
 
+	// Check that we are not removing all the memory nodes
+	if qr.MemoryNodes != nil && newLimits.MemoryNodes != nil && len(newLimits.MemoryNodes.Nodes) == 0 {
+		return fmt.Errorf("cannot remove all allowed memory nodes from quota group")
+	}
+
 	// Check that the thread limit is not being decreased
 	if qr.Threads != nil && newLimits.Threads != nil {
 		if newLimits.Threads.Limit == 0 {
@@ -345,6 +415,10 @@ func (qr *Resources) ValidateChange(newLimits Resources) error {
 
 		// Allow any changes done to the rate/period, as 0 values mean turning off
 		// rate-limit for the group, overriding the journal default which is 10000/30s
+
+		if qr.Journal.Retention != nil && newLimits.Journal.Retention != nil && newLimits.Journal.Retention.Period == 0 {
+			return fmt.Errorf("cannot remove journal retention limit from quota group")
+		}
 	}
 
 	return nil
@@ -362,6 +436,9 @@ func (qr *Resources) clone() Resources {
 	if qr.CPUSet != nil {
 		resourcesCopy.CPUSet = &ResourceCPUSet{CPUs: qr.CPUSet.CPUs}
 	}
+	if qr.MemoryNodes != nil {
+		resourcesCopy.MemoryNodes = &ResourceMemoryNodes{Nodes: qr.MemoryNodes.Nodes}
+	}
 	if qr.Threads != nil {
 		resourcesCopy.Threads = &ResourceThreads{Limit: qr.Threads.Limit}
 	}
@@ -373,6 +450,12 @@ func (qr *Resources) clone() Resources {
 		if qr.Journal.Rate != nil {
 			resourcesCopy.Journal.Rate = &ResourceJournalRate{Count: qr.Journal.Rate.Count, Period: qr.Journal.Rate.Period}
 		}
+		if qr.Journal.Retention != nil {
+			resourcesCopy.Journal.Retention = &ResourceJournalRetention{Period: qr.Journal.Retention.Period}
+		}
+		if qr.Journal.Storage != nil {
+			resourcesCopy.Journal.Storage = &ResourceJournalStorage{Mode: qr.Journal.Storage.Mode}
+		}
 	}
 	return resourcesCopy
 }
@@ -388,6 +471,9 @@ func (qr *Resources) changeInternal(newLimits Resources) {
 	if newLimits.CPUSet != nil {
 		qr.CPUSet = newLimits.CPUSet
 	}
+	if newLimits.MemoryNodes != nil {
+		qr.MemoryNodes = newLimits.MemoryNodes
+	}
 	if newLimits.Threads != nil {
 		qr.Threads = newLimits.Threads
 	}
@@ -401,6 +487,12 @@ func (qr *Resources) changeInternal(newLimits Resources) {
 		if newLimits.Journal.Rate != nil {
 			qr.Journal.Rate = newLimits.Journal.Rate
 		}
+		if newLimits.Journal.Retention != nil {
+			qr.Journal.Retention = newLimits.Journal.Retention
+		}
+		if newLimits.Journal.Storage != nil {
+			qr.Journal.Storage = newLimits.Journal.Storage
+		}
 	}
 }
 
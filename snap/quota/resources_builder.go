@@ -38,6 +38,9 @@ type ResourcesBuilder struct {
 	CPUSet    []int
 	CPUSetSet bool
 
+	MemoryNodes    []int
+	MemoryNodesSet bool
+
 	ThreadLimit    int
 	ThreadLimitSet bool
 
@@ -49,6 +52,12 @@ type ResourcesBuilder struct {
 	JournalRateCountLimit  int
 	JournalRatePeriodLimit time.Duration
 	JournalRateSet         bool
+
+	JournalRetentionPeriod time.Duration
+	JournalRetentionSet    bool
+
+	JournalStorageMode string
+	JournalStorageSet  bool
 }
 
 func (rb *ResourcesBuilder) WithMemoryLimit(limit quantity.Size) *ResourcesBuilder {
@@ -75,6 +84,12 @@ func (rb *ResourcesBuilder) WithCPUSet(cpuSet []int) *ResourcesBuilder {
 	return rb
 }
 
+func (rb *ResourcesBuilder) WithMemoryNodes(nodes []int) *ResourcesBuilder {
+	rb.MemoryNodes = nodes
+	rb.MemoryNodesSet = true
+	return rb
+}
+
 func (rb *ResourcesBuilder) WithThreadLimit(limit int) *ResourcesBuilder {
 	rb.ThreadLimit = limit
 	rb.ThreadLimitSet = true
@@ -99,6 +114,18 @@ func (rb *ResourcesBuilder) WithJournalRate(count int, period time.Duration) *Re
 	return rb
 }
 
+func (rb *ResourcesBuilder) WithJournalRetention(period time.Duration) *ResourcesBuilder {
+	rb.JournalRetentionPeriod = period
+	rb.JournalRetentionSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithJournalStorage(mode string) *ResourcesBuilder {
+	rb.JournalStorageMode = mode
+	rb.JournalStorageSet = true
+	return rb
+}
+
 func (rb *ResourcesBuilder) Build() Resources {
 	var quotaResources Resources
 	if rb.MemoryLimitSet {
@@ -117,12 +144,18 @@ func (rb *ResourcesBuilder) Build() Resources {
 			CPUs: rb.CPUSet,
 		}
 	}
+	if rb.MemoryNodesSet {
+		quotaResources.MemoryNodes = &ResourceMemoryNodes{
+			Nodes: rb.MemoryNodes,
+		}
+	}
 	if rb.ThreadLimitSet {
 		quotaResources.Threads = &ResourceThreads{
 			Limit: rb.ThreadLimit,
 		}
 	}
-	if rb.JournalNamespaceSet || rb.JournalSizeLimitSet || rb.JournalRateSet {
+	if rb.JournalNamespaceSet || rb.JournalSizeLimitSet || rb.JournalRateSet ||
+		rb.JournalRetentionSet || rb.JournalStorageSet {
 		quotaResources.Journal = &ResourceJournal{}
 		if rb.JournalSizeLimitSet {
 			quotaResources.Journal.Size = &ResourceJournalSize{
@@ -135,6 +168,16 @@ func (rb *ResourcesBuilder) Build() Resources {
 				Period: rb.JournalRatePeriodLimit,
 			}
 		}
+		if rb.JournalRetentionSet {
+			quotaResources.Journal.Retention = &ResourceJournalRetention{
+				Period: rb.JournalRetentionPeriod,
+			}
+		}
+		if rb.JournalStorageSet {
+			quotaResources.Journal.Storage = &ResourceJournalStorage{
+				Mode: rb.JournalStorageMode,
+			}
+		}
 	}
 	return quotaResources
 }
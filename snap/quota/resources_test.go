@@ -43,6 +43,7 @@ func (s *resourcesTestSuite) TestQuotaValidationFails(c *C) {
 		{quota.NewResourcesBuilder().WithMemoryLimit(0).Build(), `memory quota must have a limit set`},
 		{quota.NewResourcesBuilder().WithCPUPercentage(0).Build(), `invalid cpu quota with a cpu quota of 0`},
 		{quota.NewResourcesBuilder().WithCPUSet(nil).Build(), `cpu-set quota must not be empty`},
+		{quota.NewResourcesBuilder().WithMemoryNodes(nil).Build(), `memory-nodes quota must not be empty`},
 		{quota.NewResourcesBuilder().WithThreadLimit(0).Build(), `invalid thread quota with a thread count of 0`},
 		{quota.NewResourcesBuilder().Build(), `quota group must have at least one resource limit set`},
 		{quota.NewResourcesBuilder().WithCPUCount(1).Build(), `invalid cpu quota with count of >0 and percentage of 0`},
@@ -50,6 +51,9 @@ func (s *resourcesTestSuite) TestQuotaValidationFails(c *C) {
 		{quota.NewResourcesBuilder().WithJournalRate(0, 1).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Nanosecond).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalSize(0).Build(), `journal size quota must have a limit set`},
+		{quota.NewResourcesBuilder().WithJournalRetention(0).Build(), `journal quota must have a retention period of at least 1s`},
+		{quota.NewResourcesBuilder().WithJournalRetention(time.Millisecond).Build(), `journal quota must have a retention period of at least 1s`},
+		{quota.NewResourcesBuilder().WithJournalStorage("none").Build(), `journal quota storage must be one of "auto", "volatile", "persistent"`},
 	}
 
 	for _, t := range tests {
@@ -69,6 +73,10 @@ func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1(c *C)
 	// cpu set with cgroup v1 is not supported
 	bad := quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).Build()
 	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use CPU set with cgroup version 1")
+
+	// neither are memory nodes
+	bad = quota.NewResourcesBuilder().WithMemoryNodes([]int{0}).Build()
+	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use memory nodes with cgroup version 1")
 }
 
 func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1Err(c *C) {
@@ -91,6 +99,8 @@ func (s *resourcesTestSuite) TestQuotaValidationPasses(c *C) {
 		{quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build()},
 		{quota.NewResourcesBuilder().WithCPUCount(1).WithCPUPercentage(50).Build()},
 		{quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).Build()},
+		{quota.NewResourcesBuilder().WithMemoryNodes([]int{0}).Build()},
+		{quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).WithMemoryNodes([]int{0, 1}).Build()},
 		{quota.NewResourcesBuilder().WithThreadLimit(16).Build()},
 		{quota.NewResourcesBuilder().WithJournalSize(quantity.SizeMiB).Build()},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Microsecond).Build()},
@@ -144,6 +154,11 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationFails(c *C) {
 			quota.NewResourcesBuilder().WithCPUSet([]int{}).Build(),
 			`cannot remove all allowed cpus from quota group`,
 		},
+		{
+			quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).WithMemoryNodes([]int{0}).Build(),
+			quota.NewResourcesBuilder().WithMemoryNodes([]int{}).Build(),
+			`cannot remove all allowed memory nodes from quota group`,
+		},
 		// ensure that changes will call "Validate" too
 		{
 			quota.NewResourcesBuilder().WithCPUCount(1).WithCPUPercentage(50).Build(),
@@ -205,6 +220,16 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationFails(c *C) {
 			quota.NewResourcesBuilder().WithJournalSize(5 * quantity.SizeGiB).Build(),
 			`journal size quota must be smaller than 4 GiB`,
 		},
+		{
+			quota.NewResourcesBuilder().WithJournalRetention(time.Hour).Build(),
+			quota.NewResourcesBuilder().WithJournalRetention(0).Build(),
+			`cannot remove journal retention limit from quota group`,
+		},
+		{
+			quota.NewResourcesBuilder().WithJournalStorage("volatile").Build(),
+			quota.NewResourcesBuilder().WithJournalStorage("").Build(),
+			`journal quota storage must be one of "auto", "volatile", "persistent"`,
+		},
 	}
 
 	for _, t := range tests {
@@ -288,6 +313,16 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationPasses(c *C) {
 			quota.NewResourcesBuilder().WithJournalSize(quantity.SizeGiB).Build(),
 			quota.NewResourcesBuilder().WithJournalSize(quantity.SizeGiB).WithJournalRate(0, 0).Build(),
 		},
+		{
+			quota.NewResourcesBuilder().WithJournalSize(quantity.SizeGiB).WithJournalRetention(24 * time.Hour).Build(),
+			quota.NewResourcesBuilder().WithJournalRetention(time.Hour).WithJournalStorage("volatile").Build(),
+			quota.NewResourcesBuilder().WithJournalSize(quantity.SizeGiB).WithJournalRetention(time.Hour).WithJournalStorage("volatile").Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithJournalStorage("volatile").Build(),
+			quota.NewResourcesBuilder().WithJournalStorage("persistent").Build(),
+			quota.NewResourcesBuilder().WithJournalStorage("persistent").Build(),
+		},
 		{
 			quota.NewResourcesBuilder().WithCPUCount(4).WithCPUPercentage(25).Build(),
 			quota.NewResourcesBuilder().WithJournalSize(quantity.SizeGiB).Build(),
@@ -30,6 +30,8 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -502,6 +504,14 @@ type BuildOpts struct {
 	SnapType     string
 	Compression  string
 	ExcludeFiles []string
+	// Reproducible requests an image that is bit-identical for identical
+	// content of the source directory. The modification time of all files
+	// and the creation time of the image are set to Timestamp.
+	Reproducible bool
+	Timestamp    time.Time
+	// ExtraFiles maps paths inside the image to files on the host whose
+	// content is added to the image at those paths.
+	ExtraFiles map[string]string
 }
 
 // Build builds the snap.
@@ -533,6 +543,7 @@ func (s *Snap) Build(sourceDir string, opts *BuildOpts) error {
 		".", fullSnapPath,
 		"-noappend",
 		"-comp", compression,
+		// fragments are never used, reproducible images rely on it
 		"-no-fragments",
 		"-no-progress",
 	)
@@ -546,6 +557,29 @@ func (s *Snap) Build(sourceDir string, opts *BuildOpts) error {
 	if snapType != "os" && snapType != "core" && snapType != "base" {
 		cmd.Args = append(cmd.Args, "-all-root", "-no-xattrs")
 	}
+	if opts.Reproducible {
+		// mksquashfs sorts the entries of directories and, since
+		// 4.4, generates the same image for the same input with
+		// -no-fragments, leaving only timestamps to fix
+		ts := opts.Timestamp.Unix()
+		if ts < 0 {
+			ts = 0
+		}
+		cmd.Args = append(cmd.Args,
+			"-all-time", strconv.FormatInt(ts, 10),
+			"-mkfs-time", strconv.FormatInt(ts, 10),
+		)
+	}
+	extraPaths := make([]string, 0, len(opts.ExtraFiles))
+	for p := range opts.ExtraFiles {
+		extraPaths = append(extraPaths, p)
+	}
+	sort.Strings(extraPaths)
+	for _, p := range extraPaths {
+		// pseudo file definition, the content of the file is the
+		// output of the command, which is run by the shell
+		cmd.Args = append(cmd.Args, "-p", fmt.Sprintf("%s f 644 0 0 cat %s", p, shellQuote(opts.ExtraFiles[p])))
+	}
 
 	return osutil.ChDir(sourceDir, func() error {
 		output, err := cmd.CombinedOutput()
@@ -557,6 +591,11 @@ func (s *Snap) Build(sourceDir string, opts *BuildOpts) error {
 	})
 }
 
+// shellQuote quotes the given string as a single word for the shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
 // BuildDate returns the "Creation or last append time" as reported by unsquashfs.
 func (s *Snap) BuildDate() time.Time {
 	return BuildDate(s.path)
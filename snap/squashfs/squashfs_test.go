@@ -784,6 +784,42 @@ func (s *SquashfsTestSuite) TestBuildVariesArgsByType(c *C) {
 	}
 }
 
+func (s *SquashfsTestSuite) TestBuildReproducible(c *C) {
+	defer squashfs.MockCommandFromSystemSnap(func(cmd string, args ...string) (*exec.Cmd, error) {
+		return nil, errors.New("bzzt")
+	})()
+	mksq := testutil.MockCommand(c, "mksquashfs", "")
+	defer mksq.Restore()
+
+	snapPath := filepath.Join(c.MkDir(), "foo.snap")
+	sn := squashfs.New(snapPath)
+	err := sn.Build(c.MkDir(), &squashfs.BuildOpts{
+		SnapType:     "app",
+		Reproducible: true,
+		Timestamp:    time.Unix(1600000000, 0),
+		ExtraFiles: map[string]string{
+			"meta/b": "/tmp/b",
+			"meta/a": "/tmp/it's a",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(mksq.Calls(), HasLen, 1)
+	c.Check(mksq.Calls()[0], DeepEquals, []string{
+		"mksquashfs", ".", snapPath, "-noappend", "-comp", "xz", "-no-fragments", "-no-progress",
+		"-all-root", "-no-xattrs",
+		"-all-time", "1600000000", "-mkfs-time", "1600000000",
+		"-p", `meta/a f 644 0 0 cat '/tmp/it'\''s a'`,
+		"-p", "meta/b f 644 0 0 cat '/tmp/b'",
+	})
+
+	// the Unix epoch is used when no timestamp is given
+	mksq.ForgetCalls()
+	err = sn.Build(c.MkDir(), &squashfs.BuildOpts{Reproducible: true})
+	c.Assert(err, IsNil)
+	c.Assert(mksq.Calls(), HasLen, 1)
+	c.Check(mksq.Calls()[0][10:], DeepEquals, []string{"-all-time", "0", "-mkfs-time", "0"})
+}
+
 func (s *SquashfsTestSuite) TestBuildReportsFailures(c *C) {
 	mockUnsquashfs := testutil.MockCommand(c, "mksquashfs", `
 echo Yeah, nah. >&2
@@ -405,9 +405,76 @@ func Validate(info *Info) error {
 		return err
 	}
 
+	if info.RefreshWindow != "" {
+		if _, err := timeutil.ParseSchedule(info.RefreshWindow); err != nil {
+			return fmt.Errorf("invalid refresh-window: %v", err)
+		}
+	}
+
+	if err := ValidatePrivateTmpfs(info); err != nil {
+		return err
+	}
+
+	if err := ValidateAliasSets(info.AliasSets); err != nil {
+		return err
+	}
+
 	return ValidateLayoutAll(info)
 }
 
+var isValidAliasSetName = regexp.MustCompile("^[a-z](?:-?[a-z0-9])*$").MatchString
+
+// ValidateAliasSets validates the alias-sets field. Each alias can
+// belong to at most one set.
+func ValidateAliasSets(aliasSets map[string][]string) error {
+	setOf := make(map[string]string)
+	for name, aliases := range aliasSets {
+		if !isValidAliasSetName(name) {
+			return fmt.Errorf("invalid alias set name %q", name)
+		}
+		if len(aliases) == 0 {
+			return fmt.Errorf("alias set %q must contain at least one alias", name)
+		}
+		for _, alias := range aliases {
+			if err := ValidateAlias(alias); err != nil {
+				return fmt.Errorf("invalid alias set %q: %v", name, err)
+			}
+			if other, ok := setOf[alias]; ok {
+				if other == name {
+					return fmt.Errorf("invalid alias set %q: alias %q is listed more than once", name, alias)
+				}
+				return fmt.Errorf("alias %q cannot belong to both alias sets %q and %q", alias, other, name)
+			}
+			setOf[alias] = name
+		}
+	}
+	return nil
+}
+
+// minPrivateTmpfsSize is the smallest size limit of a private tmpfs.
+const minPrivateTmpfsSize = 1000 * 1000
+
+// ValidatePrivateTmpfs validates the private-tmpfs field.
+func ValidatePrivateTmpfs(info *Info) error {
+	if len(info.PrivateTmpfsSizes) == 0 {
+		return nil
+	}
+	if info.Confinement == ClassicConfinement {
+		return fmt.Errorf("cannot use private-tmpfs with classic confinement")
+	}
+	for path, size := range info.PrivateTmpfsSizes {
+		switch path {
+		case "/tmp", "/dev/shm":
+		default:
+			return fmt.Errorf("invalid private-tmpfs path %q: only /tmp and /dev/shm are supported", path)
+		}
+		if size < minPrivateTmpfsSize {
+			return fmt.Errorf("invalid private-tmpfs size of %q: size must be at least 1MB", path)
+		}
+	}
+	return nil
+}
+
 // ValidateBase validates the base field.
 func ValidateBase(info *Info) error {
 	// validate that bases do not have base fields
@@ -643,6 +710,41 @@ func validateAppTimer(app *AppInfo) error {
 	return nil
 }
 
+var isValidAddressFamily = regexp.MustCompile("^AF_[A-Z0-9]+$").MatchString
+
+func validateAppHardening(app *AppInfo) error {
+	if app.Hardening == nil {
+		return nil
+	}
+
+	if app.Daemon == "" || app.DaemonScope != SystemDaemon {
+		return errors.New("hardening is only applicable to system services")
+	}
+
+	plugsInterface := func(ifaces ...string) string {
+		for _, plug := range app.Plugs {
+			if strutil.ListContains(ifaces, plug.Interface) {
+				return plug.Interface
+			}
+		}
+		return ""
+	}
+	if app.Hardening.PrivateNetwork {
+		if len(app.Sockets) > 0 {
+			return errors.New("private-network cannot be used with sockets")
+		}
+		if iface := plugsInterface("network", "network-bind"); iface != "" {
+			return fmt.Errorf("private-network cannot be used with a %q plug", iface)
+		}
+	}
+	for _, family := range app.Hardening.RestrictAddressFamilies {
+		if !isValidAddressFamily(family) {
+			return fmt.Errorf("restrict-address-families contains invalid address family %q", family)
+		}
+	}
+	return nil
+}
+
 func validateAppRestart(app *AppInfo) error {
 	// app.RestartCond value is validated when unmarshalling
 
@@ -828,10 +930,47 @@ func ValidateApp(app *AppInfo) error {
 	if app.InstallMode != "" && app.Daemon == "" {
 		return fmt.Errorf(`"install-mode" cannot be used for %q, only for services`, app.Name)
 	}
+	if err := validateAppRestartStrategy(app); err != nil {
+		return err
+	}
+	if err := validateAppHardening(app); err != nil {
+		return err
+	}
 
 	return validateAppTimer(app)
 }
 
+func validateAppRestartStrategy(app *AppInfo) error {
+	switch app.RestartStrategy {
+	case "":
+		return nil
+	case "socket-handoff":
+		// valid
+	default:
+		return fmt.Errorf(`"restart-strategy" field contains invalid value %q`, app.RestartStrategy)
+	}
+	if app.Daemon == "" {
+		return fmt.Errorf(`"restart-strategy" cannot be used for %q, only for services`, app.Name)
+	}
+	if app.DaemonScope != SystemDaemon {
+		return fmt.Errorf(`"restart-strategy" cannot be set to "socket-handoff" for user daemon %q`, app.Name)
+	}
+	if len(app.Sockets) == 0 {
+		return fmt.Errorf(`"restart-strategy" cannot be set to "socket-handoff" for %q without sockets`, app.Name)
+	}
+	if app.RefreshMode == "endure" {
+		return fmt.Errorf(`"restart-strategy" cannot be set to "socket-handoff" for %q with "refresh-mode" set to "endure"`, app.Name)
+	}
+	// the sockets are kept open across a refresh, so they cannot live
+	// in a location that changes with the revision
+	for _, socket := range app.Sockets {
+		if strings.HasPrefix(socket.ListenStream, "$SNAP_DATA/") {
+			return fmt.Errorf(`socket %q of %q cannot use $SNAP_DATA with "restart-strategy" set to "socket-handoff"`, socket.Name, app.Name)
+		}
+	}
+	return nil
+}
+
 // ValidatePathVariables ensures that given path contains only $SNAP, $SNAP_DATA or $SNAP_COMMON.
 func ValidatePathVariables(path string) error {
 	for path != "" {
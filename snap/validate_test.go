@@ -567,6 +567,69 @@ func (s *ValidateSuite) TestAppRefreshMode(c *C) {
 	}
 }
 
+func (s *ValidateSuite) TestAppRestartStrategy(c *C) {
+	const yamlTemplate = `name: foo
+version: 1.0
+apps:
+  foo:
+    plugs: [network-bind]
+%s
+`
+	for _, t := range []struct {
+		app    string
+		errMsg string
+	}{
+		// good
+		{`    daemon: simple
+    restart-strategy: socket-handoff
+    sockets:
+      sock:
+        listen-stream: $SNAP_COMMON/foo.sock`, ""},
+		{`    daemon: simple
+    restart-strategy: socket-handoff
+    refresh-mode: restart
+    sockets:
+      sock:
+        listen-stream: 8080`, ""},
+		// bad
+		{`    daemon: simple
+    restart-strategy: whatever
+    sockets:
+      sock:
+        listen-stream: 8080`, `"restart-strategy" field contains invalid value "whatever"`},
+		{`    restart-strategy: socket-handoff`, `"restart-strategy" cannot be used for "foo", only for services`},
+		{`    daemon: simple
+    restart-strategy: socket-handoff`, `"restart-strategy" cannot be set to "socket-handoff" for "foo" without sockets`},
+		{`    daemon: simple
+    daemon-scope: user
+    restart-strategy: socket-handoff
+    sockets:
+      sock:
+        listen-stream: 8080`, `"restart-strategy" cannot be set to "socket-handoff" for user daemon "foo"`},
+		{`    daemon: simple
+    refresh-mode: endure
+    restart-strategy: socket-handoff
+    sockets:
+      sock:
+        listen-stream: 8080`, `"restart-strategy" cannot be set to "socket-handoff" for "foo" with "refresh-mode" set to "endure"`},
+		{`    daemon: simple
+    restart-strategy: socket-handoff
+    sockets:
+      sock:
+        listen-stream: $SNAP_DATA/foo.sock`, `socket "sock" of "foo" cannot use \$SNAP_DATA with "restart-strategy" set to "socket-handoff"`},
+	} {
+		info, err := InfoFromSnapYaml([]byte(fmt.Sprintf(yamlTemplate, t.app)))
+		c.Assert(err, IsNil)
+		err = ValidateApp(info.Apps["foo"])
+		if t.errMsg == "" {
+			c.Check(err, IsNil)
+			c.Check(info.Apps["foo"].RestartStrategy, Equals, "socket-handoff")
+		} else {
+			c.Check(err, ErrorMatches, t.errMsg)
+		}
+	}
+}
+
 func (s *ValidateSuite) TestAppWhitelistError(c *C) {
 	err := ValidateApp(&AppInfo{Name: "foo", Command: "x\n"})
 	c.Assert(err, NotNil)
@@ -2085,6 +2148,32 @@ func (s *ValidateSuite) TestAppInstallMode(c *C) {
 	c.Check(err, ErrorMatches, `"install-mode" cannot be used for "foo", only for services`)
 }
 
+func (s *ValidateSuite) TestAppHardening(c *C) {
+	svc := func(h *HardeningInfo) *AppInfo {
+		return &AppInfo{Name: "foo", Daemon: "simple", DaemonScope: SystemDaemon, Hardening: h}
+	}
+
+	c.Check(ValidateApp(svc(nil)), IsNil)
+	c.Check(ValidateApp(svc(&HardeningInfo{
+		PrivateNetwork:          true,
+		RestrictAddressFamilies: []string{"AF_UNIX", "AF_NETLINK"},
+	})), IsNil)
+
+	err := ValidateApp(svc(&HardeningInfo{RestrictAddressFamilies: []string{"AF_INET", "inet6"}}))
+	c.Check(err, ErrorMatches, `restrict-address-families contains invalid address family "inet6"`)
+
+	// only for system services
+	err = ValidateApp(&AppInfo{Name: "foo", Hardening: &HardeningInfo{PrivateNetwork: true}})
+	c.Check(err, ErrorMatches, "hardening is only applicable to system services")
+	err = ValidateApp(&AppInfo{Name: "foo", Daemon: "simple", DaemonScope: UserDaemon, Hardening: &HardeningInfo{PrivateNetwork: true}})
+	c.Check(err, ErrorMatches, "hardening is only applicable to system services")
+
+	// conflicts with plugs
+	app := svc(&HardeningInfo{PrivateNetwork: true})
+	app.Plugs = map[string]*PlugInfo{"net": {Name: "net", Interface: "network"}}
+	c.Check(ValidateApp(app), ErrorMatches, `private-network cannot be used with a "network" plug`)
+}
+
 func (s *ValidateSuite) TestValidateLinks(c *C) {
 	info, err := InfoFromSnapYaml([]byte(`name: foo
 version: 1.0
@@ -2142,6 +2231,85 @@ links:
 	c.Check(err, ErrorMatches, `"foo" links cannot be specified and empty`)
 }
 
+func (s *ValidateSuite) TestValidateRefreshWindow(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+refresh-window: sat,sun,01:00-05:00
+`))
+	c.Assert(err, IsNil)
+
+	err = Validate(info)
+	c.Assert(err, IsNil)
+
+	info, err = InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+refresh-window: whenever
+`))
+	c.Assert(err, IsNil)
+
+	err = Validate(info)
+	c.Check(err, ErrorMatches, `invalid refresh-window: cannot parse "whenever": .*`)
+}
+
+func (s *ValidateSuite) TestValidatePrivateTmpfs(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+private-tmpfs:
+  /tmp: 64MB
+  /dev/shm: 1MB
+`))
+	c.Assert(err, IsNil)
+
+	err = Validate(info)
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{`private-tmpfs: {/var/tmp: 64MB}`, `invalid private-tmpfs path "/var/tmp": only /tmp and /dev/shm are supported`},
+		{`private-tmpfs: {/dev/shm: 999kB}`, `invalid private-tmpfs size of "/dev/shm": size must be at least 1MB`},
+		{"confinement: classic\nprivate-tmpfs: {/tmp: 64MB}", `cannot use private-tmpfs with classic confinement`},
+	} {
+		info, err := InfoFromSnapYaml([]byte("name: foo\nversion: 1.0\n" + t.yaml))
+		c.Assert(err, IsNil)
+
+		err = Validate(info)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func (s *ValidateSuite) TestValidateAliasSets(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+alias-sets:
+  git-tools: [gst, gco]
+  misc: [ll]
+`))
+	c.Assert(err, IsNil)
+
+	err = Validate(info)
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{`alias-sets: {Git: [gst]}`, `invalid alias set name "Git"`},
+		{`alias-sets: {git-: [gst]}`, `invalid alias set name "git-"`},
+		{`alias-sets: {git: []}`, `alias set "git" must contain at least one alias`},
+		{`alias-sets: {git: [g$t]}`, `invalid alias set "git": invalid alias name: "g\$t"`},
+		{`alias-sets: {git: [gst, gst]}`, `invalid alias set "git": alias "gst" is listed more than once`},
+		{`alias-sets: {a: [gst], b: [gst]}`, `alias "gst" cannot belong to both alias sets "(a|b)" and "(a|b)"`},
+	} {
+		info, err := InfoFromSnapYaml([]byte("name: foo\nversion: 1.0\n" + t.yaml))
+		c.Assert(err, IsNil)
+
+		err = Validate(info)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
 func (s *YamlSuite) TestValidateLinksKeys(c *C) {
 	invalid := []string{
 		"--",
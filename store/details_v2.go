@@ -63,6 +63,12 @@ type storeSnap struct {
 	Media []storeSnapMedia `json:"media"`
 
 	CommonIDs []string `json:"common-ids"`
+
+	// security relevance, only sent for refresh candidates
+	Security storeSnapSecurity `json:"security"`
+
+	// renamed successor of the snap, only sent for refresh candidates
+	Successor storeSnapSuccessor `json:"successor"`
 }
 
 type storeSnapDownload struct {
@@ -81,6 +87,16 @@ type storeSnapDelta struct {
 	URL      string `json:"url"`
 }
 
+type storeSnapSecurity struct {
+	Severity string   `json:"severity"`
+	CVEs     []string `json:"cves"`
+}
+
+type storeSnapSuccessor struct {
+	SnapID string `json:"snap-id"`
+	Name   string `json:"name"`
+}
+
 type storeSnapMedia struct {
 	Type   string `json:"type"` // icon/screenshot
 	URL    string `json:"url"`
@@ -255,6 +271,12 @@ func copyNonZeroFrom(src, dst *storeSnap) {
 	if len(src.Website) > 0 {
 		dst.Website = src.Website
 	}
+	if src.Security.Severity != "" || len(src.Security.CVEs) > 0 {
+		dst.Security = src.Security
+	}
+	if src.Successor.Name != "" {
+		dst.Successor = src.Successor
+	}
 }
 
 func infoFromStoreSnap(d *storeSnap) (*snap.Info, error) {
@@ -344,6 +366,17 @@ func infoFromStoreSnap(d *storeSnap) (*snap.Info, error) {
 	// media
 	addMedia(info, d.Media)
 
+	// security relevance
+	if d.Security.Severity != "" || len(d.Security.CVEs) > 0 {
+		info.Security = &snap.SecurityInfo{
+			Severity: d.Security.Severity,
+			CVEs:     d.Security.CVEs,
+		}
+	}
+
+	// renamed successor
+	info.Successor = d.Successor.Name
+
 	return info, nil
 }
 
@@ -136,7 +136,15 @@ const (
      {"type": "icon", "url": "https://dashboard.snapcraft.io/site_media/appmedia/2017/12/Thingy.png"},
      {"type": "screenshot", "url": "https://dashboard.snapcraft.io/site_media/appmedia/2018/01/Thingy_01.png"},
      {"type": "screenshot", "url": "https://dashboard.snapcraft.io/site_media/appmedia/2018/01/Thingy_02.png", "width": 600, "height": 200}
-  ]
+  ],
+  "security": {
+     "severity": "high",
+     "cves": ["CVE-2023-1234", "CVE-2023-5678"]
+  },
+  "successor": {
+     "snap-id": "ZYXEfjn4WJYnm0FzDKwqqRZZI77awQEV",
+     "name": "thingy-ng"
+  }
 }`
 )
 
@@ -264,6 +272,11 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 			{Type: "screenshot", URL: "https://dashboard.snapcraft.io/site_media/appmedia/2018/01/Thingy_01.png"},
 			{Type: "screenshot", URL: "https://dashboard.snapcraft.io/site_media/appmedia/2018/01/Thingy_02.png", Width: 600, Height: 200},
 		},
+		Security: &snap.SecurityInfo{
+			Severity: "high",
+			CVEs:     []string{"CVE-2023-1234", "CVE-2023-5678"},
+		},
+		Successor:      "thingy-ng",
 		CommonIDs:      []string{"org.thingy"},
 		StoreURL:       "https://snapcraft.io/thingy",
 		SnapProvenance: "prov",
@@ -316,6 +329,9 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 		"Layout",
 		"SideInfo.Channel",
 		"SystemUsernames",
+		"RefreshWindow",
+		"PrivateTmpfsSizes",
+		"AliasSets",
 		"LegacyWebsite",
 	}
 	var checker func(string, reflect.Value)
@@ -403,6 +419,16 @@ func fillStruct(a interface{}, c *C) {
 			x = map[string][]string{
 				"contact": {"mailto:foo", "mailto:bar"},
 			}
+		case storeSnapSecurity:
+			x = storeSnapSecurity{
+				Severity: "low",
+				CVEs:     []string{"CVE-2023-0001"},
+			}
+		case storeSnapSuccessor:
+			x = storeSnapSuccessor{
+				SnapID: "foo-id",
+				Name:   "foo-ng",
+			}
 		default:
 			c.Fatalf("unhandled field type %T", field.Interface())
 		}
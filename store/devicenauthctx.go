@@ -20,8 +20,10 @@
 package store
 
 import (
+	"crypto/tls"
 	"errors"
 	"net/url"
+	"time"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -41,6 +43,19 @@ type DeviceAndAuthContext interface {
 	ProxyStoreParams(defaultURL *url.URL) (proxyStoreID string, proxySroreURL *url.URL, err error)
 
 	CloudInfo() (*auth.CloudInfo, error)
+
+	// DownloadMirror returns the base URL of a mirror of the store
+	// downloads set by the system configuration, if any.
+	DownloadMirror() (*url.URL, error)
+
+	// ClientCertificate returns the TLS client certificate to present
+	// to the store, if any. It must not require the state lock.
+	ClientCertificate() (*tls.Certificate, error)
+
+	// CertificateVerificationTime returns the time as of which the
+	// certificates of the store should be verified, or the zero time
+	// to use the system clock. It must not require the state lock.
+	CertificateVerificationTime() time.Time
 }
 
 // DeviceSessionRequestParams gathers the assertions and information to be sent to request a device session.
@@ -0,0 +1,166 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2022 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"io"
+	"os"
+	"unsafe"
+)
+
+const (
+	// directIOAlignment is the alignment of memory buffers, file offsets
+	// and lengths of writes done with direct I/O
+	directIOAlignment = 4096
+	// directWriterBufferSize is the size of the chunks written with
+	// direct I/O
+	directWriterBufferSize = 1024 * 1024
+)
+
+// directWriter is an io.ReadWriteSeeker for a file that writes the data in
+// aligned chunks through a second descriptor of the same file opened for
+// direct I/O. This avoids filling the page cache with the content of large
+// downloads, which is costly on slow flash storage. Data that cannot be
+// written in aligned chunks, the beginning of the data when writing at an
+// unaligned offset and its end, is written through the page cache.
+type directWriter struct {
+	f      *os.File
+	direct *os.File
+
+	buf []byte
+	n   int
+	// off is the offset in the file of the beginning of buf
+	off int64
+}
+
+func newDirectWriter(f *os.File) (*directWriter, error) {
+	direct, err := openDirect(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	w, err := newDirectWriterWithFiles(f, direct)
+	if err != nil {
+		direct.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func newDirectWriterWithFiles(f, direct *os.File) (*directWriter, error) {
+	off, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return &directWriter{
+		f:      f,
+		direct: direct,
+		buf:    alignedBuffer(directWriterBufferSize, directIOAlignment),
+		off:    off,
+	}, nil
+}
+
+func alignedBuffer(size, align int) []byte {
+	buf := make([]byte, size+align)
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % uintptr(align)); rem != 0 {
+		buf = buf[align-rem:]
+	}
+	return buf[:size]
+}
+
+func (w *directWriter) Write(p []byte) (written int, err error) {
+	for len(p) > 0 {
+		if w.n == 0 && w.off%directIOAlignment != 0 {
+			// write through the page cache until the offset is
+			// aligned
+			k := int(directIOAlignment - w.off%directIOAlignment)
+			if k > len(p) {
+				k = len(p)
+			}
+			n, err := w.f.WriteAt(p[:k], w.off)
+			w.off += int64(n)
+			written += n
+			if err != nil {
+				return written, err
+			}
+			p = p[k:]
+			continue
+		}
+		k := copy(w.buf[w.n:], p)
+		w.n += k
+		written += k
+		p = p[k:]
+		if w.n == len(w.buf) {
+			if _, err := w.direct.WriteAt(w.buf, w.off); err != nil {
+				return written, err
+			}
+			w.off += int64(w.n)
+			w.n = 0
+		}
+	}
+	return written, nil
+}
+
+// Flush writes out the buffered data.
+func (w *directWriter) Flush() error {
+	if w.n > 0 {
+		if _, err := w.f.WriteAt(w.buf[:w.n], w.off); err != nil {
+			return err
+		}
+		w.off += int64(w.n)
+		w.n = 0
+	}
+	_, err := w.f.Seek(w.off, io.SeekStart)
+	return err
+}
+
+func (w *directWriter) Read(p []byte) (int, error) {
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	n, err := w.f.Read(p)
+	w.off += int64(n)
+	return n, err
+}
+
+func (w *directWriter) Seek(offset int64, whence int) (int64, error) {
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	pos, err := w.f.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+	w.off = pos
+	return pos, nil
+}
+
+// Close closes the descriptor used for direct I/O, the underlying file is
+// left open.
+func (w *directWriter) Close() error {
+	return w.direct.Close()
+}
+
+// flushDirect writes out the data buffered by w if it is a directWriter.
+func flushDirect(w io.Writer) error {
+	if dw, ok := w.(*directWriter); ok {
+		return dw.Flush()
+	}
+	return nil
+}
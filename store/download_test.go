@@ -709,3 +709,47 @@ func (s *downloadSuite) TestActualDownloadRateLimited(c *C) {
 	c.Check(buf.String(), Equals, canary)
 	c.Check(ratelimitReaderUsed, Equals, true)
 }
+
+func (s *downloadSuite) TestDirectWriter(c *C) {
+	path := filepath.Join(c.MkDir(), "blob")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	// start at an unaligned offset, as when resuming a download
+	_, err = f.Write([]byte("partial"))
+	c.Assert(err, IsNil)
+	// the test may not run on a filesystem supporting direct I/O, use a
+	// regular descriptor in its place
+	direct, err := os.OpenFile(path, os.O_WRONLY, 0)
+	c.Assert(err, IsNil)
+
+	w, err := store.NewDirectWriterWithFiles(f, direct)
+	c.Assert(err, IsNil)
+
+	expected := bytes.NewBufferString("partial")
+	chunk := bytes.Repeat([]byte("0123456789abcde"), 1000)
+	for expected.Len() < 2*store.DirectWriterBufferSize+100 {
+		n, err := w.Write(chunk)
+		c.Assert(err, IsNil)
+		c.Assert(n, Equals, len(chunk))
+		expected.Write(chunk)
+	}
+	c.Assert(store.FlushDirect(w), IsNil)
+	c.Check(path, testutil.FileEquals, expected.Bytes())
+
+	// reading back seeks past the buffered data first
+	pos, err := w.Seek(0, io.SeekCurrent)
+	c.Assert(err, IsNil)
+	c.Check(pos, Equals, int64(expected.Len()))
+	_, err = w.Seek(0, io.SeekStart)
+	c.Assert(err, IsNil)
+	buf := make([]byte, 7)
+	_, err = io.ReadFull(w, buf)
+	c.Assert(err, IsNil)
+	c.Check(string(buf), Equals, "partial")
+
+	c.Assert(w.Close(), IsNil)
+	// the original file is still usable
+	_, err = f.Seek(0, io.SeekStart)
+	c.Assert(err, IsNil)
+}
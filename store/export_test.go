@@ -24,6 +24,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"time"
 
@@ -60,8 +61,17 @@ var (
 	SnapActionFields = snapActionFields
 
 	Cancelled = cancelled
+
+	DirectWriterBufferSize = directWriterBufferSize
+	FlushDirect            = flushDirect
 )
 
+type DirectWriter = directWriter
+
+func NewDirectWriterWithFiles(f, direct *os.File) (*DirectWriter, error) {
+	return newDirectWriterWithFiles(f, direct)
+}
+
 func MockSnapdtoolCommandFromSystemSnap(f func(name string, args ...string) (*exec.Cmd, error)) (restore func()) {
 	old := commandFromSystemSnap
 	commandFromSystemSnap = f
@@ -222,6 +232,12 @@ func NewHashError(name, sha3_384, targetSha3_384 string) HashError {
 	return HashError{name, sha3_384, targetSha3_384}
 }
 
+// FromMirror returns whether the download options are for a download from
+// the mirror of the store downloads.
+func (opts *DownloadOptions) FromMirror() bool {
+	return opts.fromMirror
+}
+
 func NewRequestOptions(mth string, url *url.URL) *requestOptions {
 	return &requestOptions{
 		Method: mth,
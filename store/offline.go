@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// offlineCatalogResult is a store search result in the offline catalog,
+// together with the categories the snap is in.
+type offlineCatalogResult struct {
+	storeSearchResult
+	Categories []struct {
+		Name string `json:"name"`
+	} `json:"categories"`
+}
+
+// offlineCatalog is the content of an offline catalog file, a local copy
+// of store search results as returned by the find endpoint of the store
+// or of a mirror of it.
+type offlineCatalog struct {
+	Results []*offlineCatalogResult `json:"results"`
+}
+
+func (r *offlineCatalogResult) matches(search *Search) bool {
+	if search.Category != "" {
+		found := false
+		for _, cat := range r.Categories {
+			if cat.Name == search.Category {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if search.CommonID != "" && !strutil.ListContains(r.Snap.CommonIDs, search.CommonID) {
+		return false
+	}
+
+	term := strings.ToLower(strings.TrimSpace(search.Query))
+	if term == "" {
+		return true
+	}
+	if search.Prefix {
+		return strings.HasPrefix(r.Name, term)
+	}
+	for _, s := range []string{r.Name, r.Snap.Title.Clean(), r.Snap.Summary.Clean(), r.Snap.Description.Clean()} {
+		if strings.Contains(strings.ToLower(s), term) {
+			return true
+		}
+	}
+	return false
+}
+
+func readOfflineCatalog(catalogFile string) (*offlineCatalog, error) {
+	f, err := os.Open(catalogFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var catalog offlineCatalog
+	if err := json.NewDecoder(f).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("cannot decode offline catalog: %v", err)
+	}
+	return &catalog, nil
+}
+
+// FindOffline finds snaps matching the given Search in the offline
+// catalog file, to be used when the store is unreachable. Private snaps
+// are never part of the catalog. The error from opening the catalog file
+// is returned as is, so os.IsNotExist can be used to check for its
+// absence.
+func FindOffline(catalogFile string, search *Search) ([]*snap.Info, error) {
+	catalog, err := readOfflineCatalog(catalogFile)
+	if err != nil {
+		return nil, err
+	}
+	if search.Private {
+		return nil, nil
+	}
+
+	var snaps []*snap.Info
+	for _, res := range catalog.Results {
+		if !res.matches(search) {
+			continue
+		}
+		info, err := infoFromStoreSearchResult(&res.storeSearchResult)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, info)
+	}
+	sort.SliceStable(snaps, func(i, j int) bool {
+		return snaps[i].SnapName() < snaps[j].SnapName()
+	})
+	return snaps, nil
+}
+
+// SnapInfoOffline returns the snap with the given name from the offline
+// catalog file, or ErrSnapNotFound if it is not in the catalog.
+func SnapInfoOffline(catalogFile string, name string) (*snap.Info, error) {
+	catalog, err := readOfflineCatalog(catalogFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range catalog.Results {
+		if res.Name == name {
+			return infoFromStoreSearchResult(&res.storeSearchResult)
+		}
+	}
+	return nil, ErrSnapNotFound
+}
@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type offlineSuite struct {
+	testutil.BaseTest
+
+	catalogFile string
+}
+
+var _ = Suite(&offlineSuite{})
+
+const mockOfflineCatalog = `{
+  "results": [
+    {
+      "name": "hello-world",
+      "snap-id": "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ",
+      "revision": {"channel": "stable", "revision": 29, "version": "6.4", "download": {"size": 20480}},
+      "snap": {
+        "title": "Hello World",
+        "summary": "The 'hello-world' of snaps",
+        "description": "This is a simple hello world example.",
+        "publisher": {"id": "canonical", "username": "canonical", "display-name": "Canonical", "validation": "verified"},
+        "common-ids": ["org.example.hello"]
+      },
+      "categories": [{"name": "featured"}, {"name": "development"}]
+    },
+    {
+      "name": "kiosk-browser",
+      "snap-id": "kioskbrowserid0000000000000000000",
+      "revision": {"channel": "stable", "revision": 3, "version": "1.0"},
+      "snap": {
+        "title": "Kiosk Browser",
+        "summary": "A browser for kiosks",
+        "description": "Shows a single web page full screen.",
+        "publisher": {"id": "kiosk-dev", "username": "kiosk-dev", "display-name": "Kiosk Dev"}
+      },
+      "categories": [{"name": "utilities"}]
+    }
+  ]
+}`
+
+func (s *offlineSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.catalogFile = filepath.Join(c.MkDir(), "offline-catalog.json")
+	c.Assert(os.WriteFile(s.catalogFile, []byte(mockOfflineCatalog), 0644), IsNil)
+}
+
+func names(infos []*snap.Info) []string {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.SnapName()
+	}
+	return names
+}
+
+func (s *offlineSuite) TestFindOffline(c *C) {
+	for _, t := range []struct {
+		search   store.Search
+		expected []string
+	}{
+		{store.Search{}, []string{"hello-world", "kiosk-browser"}},
+		{store.Search{Query: "hello"}, []string{"hello-world"}},
+		{store.Search{Query: "BROWSER"}, []string{"kiosk-browser"}},
+		{store.Search{Query: "full screen"}, []string{"kiosk-browser"}},
+		{store.Search{Query: "kiosk", Prefix: true}, []string{"kiosk-browser"}},
+		{store.Search{Query: "browser", Prefix: true}, []string{}},
+		{store.Search{Category: "featured"}, []string{"hello-world"}},
+		{store.Search{Query: "kiosk", Category: "featured"}, []string{}},
+		{store.Search{CommonID: "org.example.hello"}, []string{"hello-world"}},
+		{store.Search{Query: "kiosk", Private: true}, []string{}},
+	} {
+		found, err := store.FindOffline(s.catalogFile, &t.search)
+		c.Assert(err, IsNil)
+		c.Check(names(found), DeepEquals, t.expected, Commentf("%+v", t.search))
+	}
+}
+
+func (s *offlineSuite) TestFindOfflineInfo(c *C) {
+	found, err := store.FindOffline(s.catalogFile, &store.Search{Query: "hello"})
+	c.Assert(err, IsNil)
+	c.Assert(found, HasLen, 1)
+	info := found[0]
+	c.Check(info.SnapID, Equals, "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ")
+	c.Check(info.Channel, Equals, "stable")
+	c.Check(info.Revision, Equals, snap.R(29))
+	c.Check(info.Version, Equals, "6.4")
+	c.Check(info.Title(), Equals, "Hello World")
+	c.Check(info.Publisher.Username, Equals, "canonical")
+	c.Check(info.Size, Equals, int64(20480))
+}
+
+func (s *offlineSuite) TestFindOfflineErrors(c *C) {
+	_, err := store.FindOffline(filepath.Join(c.MkDir(), "missing"), &store.Search{})
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Assert(os.WriteFile(s.catalogFile, []byte("{"), 0644), IsNil)
+	_, err = store.FindOffline(s.catalogFile, &store.Search{})
+	c.Check(err, ErrorMatches, "cannot decode offline catalog: .*")
+}
+
+func (s *offlineSuite) TestSnapInfoOffline(c *C) {
+	info, err := store.SnapInfoOffline(s.catalogFile, "kiosk-browser")
+	c.Assert(err, IsNil)
+	c.Check(info.SnapID, Equals, "kioskbrowserid0000000000000000000")
+	c.Check(info.Revision, Equals, snap.R(3))
+
+	_, err = store.SnapInfoOffline(s.catalogFile, "missing")
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
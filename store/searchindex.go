@@ -0,0 +1,199 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// searchIndexEntry is the compact description of a snap in the search
+// index, enough to list it in search results.
+type searchIndexEntry struct {
+	Name       string            `json:"name"`
+	SnapID     string            `json:"snap-id"`
+	Title      string            `json:"title,omitempty"`
+	Summary    string            `json:"summary,omitempty"`
+	Version    string            `json:"version,omitempty"`
+	Publisher  snap.StoreAccount `json:"publisher"`
+	Categories []string          `json:"categories,omitempty"`
+}
+
+// SearchIndex is a compact on-device index of the snaps the store exposes
+// in each of its categories, built by querying the store once per
+// category. It allows searching by category, name prefix and terms
+// without contacting the store.
+type SearchIndex struct {
+	Categories []string            `json:"categories"`
+	Snaps      []*searchIndexEntry `json:"snaps"`
+
+	byName map[string]*searchIndexEntry
+}
+
+// NewSearchIndex returns an empty search index.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		Categories: []string{},
+		Snaps:      []*searchIndexEntry{},
+		byName:     make(map[string]*searchIndexEntry),
+	}
+}
+
+// Add records the given snaps, as found by the store, as being in
+// category.
+func (idx *SearchIndex) Add(category string, snaps []*snap.Info) {
+	if !strutil.ListContains(idx.Categories, category) {
+		idx.Categories = append(idx.Categories, category)
+		sort.Strings(idx.Categories)
+	}
+	for _, info := range snaps {
+		name := info.InstanceName()
+		entry := idx.byName[name]
+		if entry == nil {
+			entry = &searchIndexEntry{
+				Name:      name,
+				SnapID:    info.SnapID,
+				Title:     info.Title(),
+				Summary:   info.Summary(),
+				Version:   info.Version,
+				Publisher: info.Publisher,
+			}
+			idx.byName[name] = entry
+			idx.Snaps = append(idx.Snaps, entry)
+		}
+		if !strutil.ListContains(entry.Categories, category) {
+			entry.Categories = append(entry.Categories, category)
+			sort.Strings(entry.Categories)
+		}
+	}
+}
+
+// Names returns the sorted names of the snaps in the index.
+func (idx *SearchIndex) Names() []string {
+	names := make([]string, len(idx.Snaps))
+	for i, entry := range idx.Snaps {
+		names[i] = entry.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write writes the index to w.
+func (idx *SearchIndex) Write(w io.Writer) error {
+	sort.Slice(idx.Snaps, func(i, j int) bool {
+		return idx.Snaps[i].Name < idx.Snaps[j].Name
+	})
+	return json.NewEncoder(w).Encode(idx)
+}
+
+func readSearchIndex(indexFile string) (*SearchIndex, error) {
+	f, err := os.Open(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var idx SearchIndex
+	if err := json.NewDecoder(f).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("cannot decode search index: %v", err)
+	}
+	return &idx, nil
+}
+
+func (e *searchIndexEntry) matches(search *Search) bool {
+	if search.Category != "" && !strutil.ListContains(e.Categories, search.Category) {
+		return false
+	}
+	// common ids are not part of the index
+	if search.CommonID != "" {
+		return false
+	}
+
+	term := strings.ToLower(strings.TrimSpace(search.Query))
+	if term == "" {
+		return true
+	}
+	if search.Prefix {
+		return strings.HasPrefix(e.Name, term)
+	}
+	for _, s := range []string{e.Name, e.Title, e.Summary} {
+		if strings.Contains(strings.ToLower(s), term) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *searchIndexEntry) info() *snap.Info {
+	return &snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName:      e.Name,
+			SnapID:        e.SnapID,
+			EditedTitle:   e.Title,
+			EditedSummary: e.Summary,
+		},
+		Version:   e.Version,
+		Publisher: e.Publisher,
+	}
+}
+
+// FindInSearchIndex finds snaps matching the given Search in the search
+// index file, to be used when the store is unreachable or when asked not to
+// contact it. The results carry only the details kept in the index. As with
+// FindOffline the error from opening the file is returned as is.
+func FindInSearchIndex(indexFile string, search *Search) ([]*snap.Info, error) {
+	idx, err := readSearchIndex(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	if search.Private {
+		return nil, nil
+	}
+
+	var snaps []*snap.Info
+	for _, entry := range idx.Snaps {
+		if entry.matches(search) {
+			snaps = append(snaps, entry.info())
+		}
+	}
+	return snaps, nil
+}
+
+// SnapInfoInSearchIndex returns the snap with the given name from the search
+// index file, or ErrSnapNotFound if it is not in the index.
+func SnapInfoInSearchIndex(indexFile string, name string) (*snap.Info, error) {
+	idx, err := readSearchIndex(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range idx.Snaps {
+		if entry.Name == name {
+			return entry.info(), nil
+		}
+	}
+	return nil, ErrSnapNotFound
+}
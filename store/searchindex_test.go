@@ -0,0 +1,137 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type searchIndexSuite struct {
+	testutil.BaseTest
+
+	indexFile string
+}
+
+var _ = Suite(&searchIndexSuite{})
+
+func (s *searchIndexSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	helloWorld := &snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName:      "hello-world",
+			SnapID:        "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ",
+			EditedTitle:   "Hello World",
+			EditedSummary: "The 'hello-world' of snaps",
+		},
+		Version: "6.4",
+		Publisher: snap.StoreAccount{
+			ID:          "canonical",
+			Username:    "canonical",
+			DisplayName: "Canonical",
+			Validation:  "verified",
+		},
+	}
+	edgeGw := &snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName:      "edge-gw",
+			SnapID:        "edgegwid",
+			EditedSummary: "An IoT gateway",
+		},
+		Version: "2.1",
+	}
+
+	idx := store.NewSearchIndex()
+	idx.Add("featured", []*snap.Info{helloWorld})
+	idx.Add("iot", []*snap.Info{edgeGw, helloWorld})
+	idx.Add("development", nil)
+	c.Check(idx.Names(), DeepEquals, []string{"edge-gw", "hello-world"})
+
+	var buf bytes.Buffer
+	c.Assert(idx.Write(&buf), IsNil)
+	s.indexFile = filepath.Join(c.MkDir(), "search-index.json")
+	c.Assert(os.WriteFile(s.indexFile, buf.Bytes(), 0644), IsNil)
+}
+
+func (s *searchIndexSuite) TestIndexFormat(c *C) {
+	c.Check(s.indexFile, testutil.FileEquals, `{"categories":["development","featured","iot"],"snaps":[`+
+		`{"name":"edge-gw","snap-id":"edgegwid","summary":"An IoT gateway","version":"2.1","publisher":{"id":"","username":"","display-name":""},"categories":["iot"]},`+
+		`{"name":"hello-world","snap-id":"buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ","title":"Hello World","summary":"The 'hello-world' of snaps","version":"6.4","publisher":{"id":"canonical","username":"canonical","display-name":"Canonical","validation":"verified"},"categories":["featured","iot"]}]}
+`)
+}
+
+func (s *searchIndexSuite) names(c *C, search *store.Search) []string {
+	found, err := store.FindInSearchIndex(s.indexFile, search)
+	c.Assert(err, IsNil)
+	names := make([]string, len(found))
+	for i, info := range found {
+		names[i] = info.InstanceName()
+	}
+	return names
+}
+
+func (s *searchIndexSuite) TestFindInSearchIndex(c *C) {
+	c.Check(s.names(c, &store.Search{}), DeepEquals, []string{"edge-gw", "hello-world"})
+	c.Check(s.names(c, &store.Search{Category: "iot"}), DeepEquals, []string{"edge-gw", "hello-world"})
+	c.Check(s.names(c, &store.Search{Category: "featured"}), DeepEquals, []string{"hello-world"})
+	c.Check(s.names(c, &store.Search{Category: "development"}), HasLen, 0)
+	c.Check(s.names(c, &store.Search{Query: "gateway"}), DeepEquals, []string{"edge-gw"})
+	c.Check(s.names(c, &store.Search{Query: "HELLO", Category: "iot"}), DeepEquals, []string{"hello-world"})
+	c.Check(s.names(c, &store.Search{Query: "he", Prefix: true}), DeepEquals, []string{"hello-world"})
+	c.Check(s.names(c, &store.Search{Query: "world", Prefix: true}), HasLen, 0)
+	c.Check(s.names(c, &store.Search{CommonID: "org.example.hello"}), HasLen, 0)
+	c.Check(s.names(c, &store.Search{Private: true}), HasLen, 0)
+
+	found, err := store.FindInSearchIndex(s.indexFile, &store.Search{Query: "hello-world"})
+	c.Assert(err, IsNil)
+	c.Assert(found, HasLen, 1)
+	c.Check(found[0].SnapID, Equals, "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ")
+	c.Check(found[0].Title(), Equals, "Hello World")
+	c.Check(found[0].Summary(), Equals, "The 'hello-world' of snaps")
+	c.Check(found[0].Version, Equals, "6.4")
+	c.Check(found[0].Publisher.Validation, Equals, "verified")
+}
+
+func (s *searchIndexSuite) TestSnapInfoInSearchIndex(c *C) {
+	info, err := store.SnapInfoInSearchIndex(s.indexFile, "edge-gw")
+	c.Assert(err, IsNil)
+	c.Check(info.InstanceName(), Equals, "edge-gw")
+	c.Check(info.Version, Equals, "2.1")
+
+	_, err = store.SnapInfoInSearchIndex(s.indexFile, "other")
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *searchIndexSuite) TestSearchIndexMissingOrBroken(c *C) {
+	_, err := store.FindInSearchIndex(filepath.Join(c.MkDir(), "missing"), &store.Search{})
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Assert(os.WriteFile(s.indexFile, []byte("{"), 0644), IsNil)
+	_, err = store.FindInSearchIndex(s.indexFile, &store.Search{})
+	c.Check(err, ErrorMatches, "cannot decode search index: .*")
+}
@@ -23,6 +23,7 @@ package store
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -317,9 +318,9 @@ func init() {
 		panic(err)
 	}
 	defaultConfig.DetailFields = jsonutil.StructFields((*snapDetails)(nil), "snap_yaml_raw")
-	defaultConfig.InfoFields = jsonutil.StructFields((*storeSnap)(nil), "snap-yaml")
+	defaultConfig.InfoFields = jsonutil.StructFields((*storeSnap)(nil), "snap-yaml", "security", "successor")
 	defaultConfig.FindFields = append(jsonutil.StructFields((*storeSnap)(nil),
-		"architectures", "created-at", "epoch", "name", "snap-id", "snap-yaml"),
+		"architectures", "created-at", "epoch", "name", "snap-id", "snap-yaml", "security", "successor"),
 		"channel")
 }
 
@@ -454,9 +455,39 @@ func (s *Store) newHTTPClient(opts *httputil.ClientOptions) *http.Client {
 	opts.ExtraSSLCerts = &httputil.ExtraSSLCertsFromDir{
 		Dir: dirs.SnapdStoreSSLCertsDir,
 	}
+	if s.dauthCtx != nil && opts.TLSConfig == nil {
+		opts.TLSConfig = &tls.Config{
+			GetClientCertificate: s.getClientCertificate,
+			Time:                 s.certificateVerificationTime,
+		}
+	}
 	return httputil.NewHTTPClient(opts)
 }
 
+// certificateVerificationTime provides the time as of which the server
+// certificates are verified, this differs from the system clock only if
+// the latter is known to be wrong.
+func (s *Store) certificateVerificationTime() time.Time {
+	if t := s.dauthCtx.CertificateVerificationTime(); !t.IsZero() {
+		return t
+	}
+	return time.Now()
+}
+
+// getClientCertificate provides the device certificate, if any, when
+// the store asks for a TLS client certificate.
+func (s *Store) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, err := s.dauthCtx.ClientCertificate()
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		// no certificate is sent
+		return &tls.Certificate{}, nil
+	}
+	return cert, nil
+}
+
 func (s *Store) defaultSnapQuery() url.Values {
 	q := url.Values{}
 	if len(s.detailFields) != 0 {
@@ -574,6 +605,10 @@ type requestOptions struct {
 	//  - deviceAuthCustomStoreOnly: should be provided only in case
 	//    of a custom store
 	DeviceAuthNeed deviceAuthNeed
+
+	// NoAuth indicates that no user or device authorization must be
+	// sent with this request, as it is not addressed to the store.
+	NoAuth bool
 }
 
 func (r *requestOptions) addHeader(k, v string) {
@@ -704,7 +739,7 @@ func (s *Store) doRequest(ctx context.Context, client *http.Client, reqOptions *
 			return nil, err
 		}
 
-		if resp.StatusCode == 401 && authRefreshes < 4 {
+		if resp.StatusCode == 401 && !reqOptions.NoAuth && authRefreshes < 4 {
 			// 4 tries: 2 tries for each in case both user
 			// and device need refreshing
 			wwwAuth := resp.Header.Get("WWW-Authenticate")
@@ -772,22 +807,24 @@ func (s *Store) newRequest(ctx context.Context, reqOptions *requestOptions, user
 		return nil, err
 	}
 
-	customStore := s.setStoreID(req, reqOptions.APILevel)
-	authOpts := AuthorizeOptions{apiLevel: reqOptions.APILevel}
-	authOpts.deviceAuth = customStore || reqOptions.DeviceAuthNeed != deviceAuthCustomStoreOnly
-	if authOpts.deviceAuth {
-		err := s.EnsureDeviceSession()
-		if err != nil && err != ErrNoSerial {
-			return nil, err
+	if !reqOptions.NoAuth {
+		customStore := s.setStoreID(req, reqOptions.APILevel)
+		authOpts := AuthorizeOptions{apiLevel: reqOptions.APILevel}
+		authOpts.deviceAuth = customStore || reqOptions.DeviceAuthNeed != deviceAuthCustomStoreOnly
+		if authOpts.deviceAuth {
+			err := s.EnsureDeviceSession()
+			if err != nil && err != ErrNoSerial {
+				return nil, err
+			}
+			if err == ErrNoSerial {
+				// missing serial assertion, log and continue without device authentication
+				logger.Debugf("cannot set device session: %v", err)
+			}
 		}
-		if err == ErrNoSerial {
-			// missing serial assertion, log and continue without device authentication
-			logger.Debugf("cannot set device session: %v", err)
+		if err := s.auth.Authorize(req, s.dauthCtx, user, &authOpts); err != nil {
+			logger.Debugf("cannot authorize store request: %v", err)
 		}
 	}
-	if err := s.auth.Authorize(req, s.dauthCtx, user, &authOpts); err != nil {
-		logger.Debugf("cannot authorize store request: %v", err)
-	}
 
 	req.Header.Set("User-Agent", s.userAgent)
 	req.Header.Set("Accept", reqOptions.Accept)
@@ -32,6 +32,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -185,6 +186,95 @@ type DownloadOptions struct {
 	RateLimit           int64
 	IsAutoRefresh       bool
 	LeavePartialOnError bool
+	// DirectIO requests that the downloaded data is written bypassing the
+	// page cache, where supported.
+	DirectIO bool
+
+	// HashVerified is set by Download when the sha3-384 digest of the
+	// blob was verified while streaming it, it is left unset when the
+	// blob was served from the download cache or built from a delta.
+	HashVerified bool
+
+	// fromMirror is set when downloading from the mirror of the store
+	// downloads, to which no authorization is sent.
+	fromMirror bool
+}
+
+// mirroredDownloadURL returns the location of the download addressed by
+// downloadURL on the given mirror, that is the download URL with the scheme
+// and host of the mirror and its path prefixed by the one of the mirror.
+func mirroredDownloadURL(mirror *url.URL, downloadURL string) (string, error) {
+	// the mirror is not trusted with any credentials, still the
+	// transport needs to be protected
+	if mirror.Scheme != "https" || mirror.Host == "" {
+		return "", fmt.Errorf("mirror must be an https URL")
+	}
+	if mirror.User != nil {
+		return "", fmt.Errorf("mirror must not carry credentials")
+	}
+	du, err := url.Parse(downloadURL)
+	if err != nil {
+		return "", err
+	}
+	du.Scheme = mirror.Scheme
+	du.Host = mirror.Host
+	du.User = nil
+	du.Path = strings.TrimSuffix(mirror.Path, "/") + "/" + strings.TrimPrefix(du.Path, "/")
+	du.RawPath = ""
+	return du.String(), nil
+}
+
+// downloadMirror returns the mirror of the store downloads set by the
+// system configuration, if any.
+func (s *Store) downloadMirror() *url.URL {
+	if s.dauthCtx == nil {
+		return nil
+	}
+	mirror, err := s.dauthCtx.DownloadMirror()
+	if err != nil {
+		logger.Noticef("Cannot get the download mirror: %v", err)
+		return nil
+	}
+	return mirror
+}
+
+// downloadWithMirror downloads from the mirror of the store downloads if one
+// is set, falling back to downloadURL if that fails.
+func downloadWithMirror(ctx context.Context, name, sha3_384, downloadURL string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *DownloadOptions) error {
+	mirror := s.downloadMirror()
+	if mirror == nil {
+		return download(ctx, name, sha3_384, downloadURL, user, s, w, resume, pbar, dlOpts)
+	}
+
+	mirrorURL, err := mirroredDownloadURL(mirror, downloadURL)
+	if err != nil {
+		logger.Noticef("Cannot use download mirror %q: %v", mirror.Redacted(), err)
+		return download(ctx, name, sha3_384, downloadURL, user, s, w, resume, pbar, dlOpts)
+	}
+	mirrorOpts := DownloadOptions{}
+	if dlOpts != nil {
+		mirrorOpts = *dlOpts
+	}
+	mirrorOpts.fromMirror = true
+	// no user or device authorization is ever sent to the mirror
+	err = download(ctx, name, sha3_384, mirrorURL, nil, s, w, resume, pbar, &mirrorOpts)
+	if err == nil || cancelled(ctx) {
+		return err
+	}
+	if _, ok := err.(HashError); ok {
+		// the caller retries from scratch, which does not use the
+		// mirror anymore
+		return err
+	}
+	logger.Noticef("Cannot download %q from mirror, falling back to the store: %v", name, err)
+
+	// continue with whatever was downloaded from the mirror, it is
+	// verified against the hash in the end anyway
+	resume, err = w.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	return download(ctx, name, sha3_384, downloadURL, user, s, w, resume, pbar, dlOpts)
 }
 
 // Download downloads the snap addressed by download info and returns its
@@ -195,6 +285,9 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 		return err
 	}
+	if dlOpts != nil {
+		dlOpts.HashVerified = false
+	}
 
 	if err := s.cacher.Get(downloadInfo.Sha3_384, targetPath); err == nil {
 		logger.Debugf("Cache hit for SHA3_384 …%.5s.", downloadInfo.Sha3_384)
@@ -241,9 +334,33 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		logger.Debugf("Starting download of %q.", partialPath)
 	}
 
+	if resume < downloadInfo.Size {
+		// reserve the space of the whole blob upfront so that it is
+		// laid out contiguously
+		if rerr := reserveDiskSpace(w, downloadInfo.Size); rerr != nil {
+			logger.Debugf("cannot reserve disk space for %q: %v", partialPath, rerr)
+		}
+	}
+
+	// the data is hashed while being written, there is no need to read
+	// the blob once more to verify it
+	var dst io.ReadWriteSeeker = w
+	if dlOpts != nil && dlOpts.DirectIO {
+		dw, derr := newDirectWriter(w)
+		if derr != nil {
+			logger.Debugf("cannot use direct I/O for %q: %v", partialPath, derr)
+		} else {
+			defer dw.Close()
+			dst = dw
+		}
+	}
+
 	url := downloadInfo.DownloadURL
 	if downloadInfo.Size == 0 || resume < downloadInfo.Size {
-		err = download(ctx, name, downloadInfo.Sha3_384, url, user, s, w, resume, pbar, dlOpts)
+		err = downloadWithMirror(ctx, name, downloadInfo.Sha3_384, url, user, s, dst, resume, pbar, dlOpts)
+		if ferr := flushDirect(dst); ferr != nil && err == nil {
+			err = ferr
+		}
 		if err != nil {
 			logger.Debugf("download of %q failed: %#v", url, err)
 		}
@@ -269,11 +386,14 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		if err != nil {
 			return err
 		}
-		_, err = w.Seek(0, io.SeekStart)
+		_, err = dst.Seek(0, io.SeekStart)
 		if err != nil {
 			return err
 		}
-		err = download(ctx, name, downloadInfo.Sha3_384, url, user, s, w, 0, pbar, nil)
+		err = download(ctx, name, downloadInfo.Sha3_384, url, user, s, dst, 0, pbar, nil)
+		if ferr := flushDirect(dst); ferr != nil && err == nil {
+			err = ferr
+		}
 		if err != nil {
 			logger.Debugf("download of %q failed: %#v", url, err)
 		}
@@ -290,6 +410,9 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 	if err := w.Sync(); err != nil {
 		return err
 	}
+	if dlOpts != nil {
+		dlOpts.HashVerified = downloadInfo.Sha3_384 != ""
+	}
 
 	return s.cacher.Put(downloadInfo.Sha3_384, targetPath)
 }
@@ -308,6 +431,10 @@ func downloadReqOpts(storeURL *url.URL, cdnHeader string, opts *DownloadOptions)
 	if opts != nil && opts.IsAutoRefresh {
 		reqOptions.ExtraHeaders["Snap-Refresh-Reason"] = "scheduled"
 	}
+	if opts != nil && opts.fromMirror {
+		reqOptions.NoAuth = true
+		delete(reqOptions.ExtraHeaders, "Snap-CDN")
+	}
 
 	return &reqOptions
 }
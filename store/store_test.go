@@ -22,7 +22,9 @@ package store_test
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -30,6 +32,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
@@ -244,6 +247,12 @@ type testDauthContext struct {
 	storeID string
 
 	cloudInfo *auth.CloudInfo
+
+	clientCert *tls.Certificate
+
+	downloadMirror *url.URL
+
+	verifyTime time.Time
 }
 
 func (dac *testDauthContext) Device() (*auth.DeviceState, error) {
@@ -317,6 +326,18 @@ func (dac *testDauthContext) CloudInfo() (*auth.CloudInfo, error) {
 	return dac.cloudInfo, nil
 }
 
+func (dac *testDauthContext) DownloadMirror() (*url.URL, error) {
+	return dac.downloadMirror, nil
+}
+
+func (dac *testDauthContext) ClientCertificate() (*tls.Certificate, error) {
+	return dac.clientCert, nil
+}
+
+func (dac *testDauthContext) CertificateVerificationTime() time.Time {
+	return dac.verifyTime
+}
+
 func makeTestMacaroon() (*macaroon.Macaroon, error) {
 	m, err := macaroon.New([]byte("secret"), "some-id", "location")
 	if err != nil {
@@ -471,6 +492,36 @@ func (s *storeTestSuite) TestDoRequestSetsAuth(c *C) {
 	c.Check(string(responseData), Equals, "response-data")
 }
 
+func (s *storeTestSuite) TestDoRequestNoAuth(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.UserAgent(), Equals, userAgent)
+		// check neither user nor device authorization is set
+		c.Check(r.Header.Get("Authorization"), Equals, "")
+		c.Check(r.Header.Get("X-Device-Authorization"), Equals, "")
+		c.Check(r.Header.Get("Snap-Device-Store"), Equals, "")
+
+		io.WriteString(w, "response-data")
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	dauthCtx := &testDauthContext{c: c, device: s.device, user: s.user, storeID: "my-brand-store-id"}
+	sto := store.New(&store.Config{}, dauthCtx)
+
+	endpoint, _ := url.Parse(mockServer.URL)
+	reqOptions := store.NewRequestOptions("GET", endpoint)
+	reqOptions.NoAuth = true
+
+	response, err := sto.DoRequest(s.ctx, sto.Client(), reqOptions, s.user)
+	c.Assert(err, IsNil)
+	defer response.Body.Close()
+
+	responseData, err := ioutil.ReadAll(response.Body)
+	c.Assert(err, IsNil)
+	c.Check(string(responseData), Equals, "response-data")
+}
+
 func (s *storeTestSuite) TestDoRequestDoesNotSetAuthForLocalOnlyUser(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c.Check(r.UserAgent(), Equals, userAgent)
@@ -2274,6 +2325,85 @@ func (s *storeTestSuite) TestSectionsQuery(c *C) {
 	c.Check(n, Equals, 1)
 }
 
+func (s *storeTestSuite) TestClientCertificate(c *C) {
+	var peerCerts [][]byte
+	mockServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "GET", sectionsPath)
+		for _, cert := range r.TLS.PeerCertificates {
+			peerCerts = append(peerCerts, cert.Raw)
+		}
+
+		w.Header().Set("Content-Type", "application/hal+json")
+		w.WriteHeader(200)
+		io.WriteString(w, MockSectionsJSON)
+	}))
+	mockServer.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	mockServer.StartTLS()
+	defer mockServer.Close()
+
+	// trust the server certificate
+	c.Assert(os.MkdirAll(dirs.SnapdStoreSSLCertsDir, 0755), IsNil)
+	serverCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mockServer.Certificate().Raw})
+	err := ioutil.WriteFile(filepath.Join(dirs.SnapdStoreSSLCertsDir, "server.pem"), serverCertPEM, 0644)
+	c.Assert(err, IsNil)
+
+	serverURL, _ := url.Parse(mockServer.URL)
+	cfg := store.Config{
+		StoreBaseURL: serverURL,
+	}
+	dauthCtx := &testDauthContext{c: c, device: s.device}
+	sto := store.New(&cfg, dauthCtx)
+
+	// no client certificate yet
+	_, err = sto.Sections(s.ctx, s.user)
+	c.Assert(err, IsNil)
+	c.Check(peerCerts, HasLen, 0)
+
+	// the device certificate becomes available later, any certificate
+	// will do for the test
+	dauthCtx.clientCert = &mockServer.TLS.Certificates[0]
+	mockServer.CloseClientConnections()
+
+	_, err = sto.Sections(s.ctx, s.user)
+	c.Assert(err, IsNil)
+	c.Check(peerCerts, DeepEquals, dauthCtx.clientCert.Certificate)
+}
+
+func (s *storeTestSuite) TestCertificateVerificationTime(c *C) {
+	mockServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "GET", sectionsPath)
+		w.Header().Set("Content-Type", "application/hal+json")
+		w.WriteHeader(200)
+		io.WriteString(w, MockSectionsJSON)
+	}))
+	defer mockServer.Close()
+
+	// trust the server certificate
+	c.Assert(os.MkdirAll(dirs.SnapdStoreSSLCertsDir, 0755), IsNil)
+	serverCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mockServer.Certificate().Raw})
+	err := ioutil.WriteFile(filepath.Join(dirs.SnapdStoreSSLCertsDir, "server.pem"), serverCertPEM, 0644)
+	c.Assert(err, IsNil)
+
+	serverURL, _ := url.Parse(mockServer.URL)
+	cfg := store.Config{
+		StoreBaseURL: serverURL,
+	}
+	dauthCtx := &testDauthContext{c: c, device: s.device}
+	sto := store.New(&cfg, dauthCtx)
+
+	// the system clock is used by default
+	_, err = sto.Sections(s.ctx, s.user)
+	c.Assert(err, IsNil)
+
+	// the server certificate is not valid yet as of the verification
+	// time given by the context
+	dauthCtx.verifyTime = mockServer.Certificate().NotBefore.Add(-time.Hour)
+	mockServer.CloseClientConnections()
+
+	_, err = sto.Sections(s.ctx, s.user)
+	c.Assert(err, ErrorMatches, `.*certificate has expired or is not yet valid.*`)
+}
+
 func (s *storeTestSuite) TestSectionsQueryTooMany(c *C) {
 	n := 0
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -661,10 +661,37 @@ type UnitStatus struct {
 	// has been modified and may differ from systemd's internal state, thus
 	// a daemon-reload is needed.
 	NeedDaemonReload bool
+	// NRestarts is the number of times systemd restarted the service
+	// automatically, as per its Restart= setting, since it was last
+	// started manually.
+	NRestarts int
+	// ExecMainCode is the SIGCHLD code (one of CLD_EXITED, CLD_KILLED or
+	// CLD_DUMPED) of the last exit of the main process of the service, or
+	// zero if it never exited.
+	ExecMainCode int
+	// ExecMainStatus is the exit code of the last exit of the main
+	// process of the service when ExecMainCode is CLD_EXITED, or the
+	// number of the signal that terminated it otherwise.
+	ExecMainStatus int
+	// StateChangeTimestamp is the time at which the unit last changed its
+	// state. It is the zero time if the unit never changed state during
+	// the current boot.
+	StateChangeTimestamp time.Time
 }
 
 var baseProperties = []string{"Id", "ActiveState", "UnitFileState", "Names"}
 var extendedProperties = []string{"Id", "ActiveState", "UnitFileState", "Type", "Names", "NeedDaemonReload"}
+
+// serviceRuntimeProperties are queried along the extendedProperties but are
+// not required to be present in the 'systemctl show' output, as not all unit
+// types have them and older versions of systemd do not know about some of
+// them (e.g. NRestarts was introduced in systemd 235).
+var serviceRuntimeProperties = []string{"NRestarts", "ExecMainCode", "ExecMainStatus", "StateChangeTimestamp"}
+
+// extendedQueryProperties is the full list of properties queried for units
+// with extendedProperties.
+var extendedQueryProperties = append(append([]string{}, extendedProperties...), serviceRuntimeProperties...)
+
 var unitProperties = map[string][]string{
 	".timer":  baseProperties,
 	".socket": baseProperties,
@@ -750,7 +777,7 @@ func (s *systemd) getUnitStatus(properties []string, unitNames []string) ([]*Uni
 		k := string(bs[1])
 		v := string(bs[2])
 
-		if v == "" && k != "UnitFileState" && k != "Type" {
+		if v == "" && k != "UnitFileState" && k != "Type" && k != "StateChangeTimestamp" {
 			return nil, fmt.Errorf("cannot get unit status: empty field %q in ‘systemctl show’ output", k)
 		}
 
@@ -771,6 +798,28 @@ func (s *systemd) getUnitStatus(properties []string, unitNames []string) ([]*Uni
 			cur.Names = strings.Fields(v)
 		case "NeedDaemonReload":
 			cur.NeedDaemonReload = v == "yes"
+		case "NRestarts", "ExecMainCode", "ExecMainStatus":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("cannot get unit status: invalid %s value %q in ‘systemctl show’ output", k, v)
+			}
+			switch k {
+			case "NRestarts":
+				cur.NRestarts = n
+			case "ExecMainCode":
+				cur.ExecMainCode = n
+			case "ExecMainStatus":
+				cur.ExecMainStatus = n
+			}
+		case "StateChangeTimestamp":
+			if v == "" || v == "n/a" {
+				break
+			}
+			t, err := time.Parse(systemctlTimeFormat, v)
+			if err != nil {
+				return nil, fmt.Errorf("cannot get unit status: invalid StateChangeTimestamp value %q in ‘systemctl show’ output", v)
+			}
+			cur.StateChangeTimestamp = t
 		default:
 			return nil, fmt.Errorf("cannot get unit status: unexpected field %q in ‘systemctl show’ output", k)
 		}
@@ -885,6 +934,10 @@ func (s *systemd) CurrentMemoryUsage(unit string) (quantity.Size, error) {
 	return quantity.Size(memBytes), nil
 }
 
+// systemctlTimeFormat is the format of timestamp properties as printed by
+// 'systemctl show'.
+const systemctlTimeFormat = "Mon 2006-01-02 15:04:05 MST"
+
 func (s *systemd) InactiveEnterTimestamp(unit string) (time.Time, error) {
 	timeStr, err := s.getPropertyStringValue(unit, "InactiveEnterTimestamp")
 	if err != nil {
@@ -896,7 +949,7 @@ func (s *systemd) InactiveEnterTimestamp(unit string) (time.Time, error) {
 	}
 
 	// finally parse the time string
-	inactiveEnterTime, err := time.Parse("Mon 2006-01-02 15:04:05 MST", timeStr)
+	inactiveEnterTime, err := time.Parse(systemctlTimeFormat, timeStr)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("internal error: systemctl time output (%s) is malformed", timeStr)
 	}
@@ -919,7 +972,7 @@ func (s *systemd) Status(unitNames []string) ([]*UnitStatus, error) {
 			// Units using the baseProperties query
 			limitedUnits = append(limitedUnits, name)
 		} else {
-			// Units using the extendedProperties query
+			// Units using the extendedQueryProperties query
 			extendedUnits = append(extendedUnits, name)
 		}
 	}
@@ -928,7 +981,7 @@ func (s *systemd) Status(unitNames []string) ([]*UnitStatus, error) {
 		units      []string
 		properties []string
 	}{
-		{units: extendedUnits, properties: extendedProperties},
+		{units: extendedUnits, properties: extendedQueryProperties},
 		{units: limitedUnits, properties: baseProperties},
 	} {
 		if len(set.units) == 0 {
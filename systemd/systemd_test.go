@@ -579,11 +579,108 @@ NeedDaemonReload=yes
 	})
 	c.Check(s.rep.msgs, IsNil)
 	c.Assert(s.argses, DeepEquals, [][]string{
-		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", "foo.service", "bar.service", "baz.service", "missing.service"},
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", "foo.service", "bar.service", "baz.service", "missing.service"},
 		{"show", "--property=Id,ActiveState,UnitFileState,Names", "some.timer", "other.socket", "reboot.target", "ctrl-alt-del.target"},
 	})
 }
 
+func (s *SystemdTestSuite) TestStatusServiceRuntimeProperties(c *C) {
+	s.outs = [][]byte{
+		[]byte(`
+Type=simple
+Id=foo.service
+Names=foo.service
+ActiveState=activating
+UnitFileState=enabled
+NeedDaemonReload=no
+NRestarts=4
+ExecMainCode=1
+ExecMainStatus=3
+StateChangeTimestamp=Fri 2022-10-14 12:34:56 UTC
+
+Type=simple
+Id=bar.service
+Names=bar.service
+ActiveState=inactive
+UnitFileState=enabled
+NeedDaemonReload=no
+NRestarts=0
+ExecMainCode=0
+ExecMainStatus=0
+StateChangeTimestamp=
+
+Type=
+Id=mnt.mount
+Names=mnt.mount
+ActiveState=active
+UnitFileState=enabled
+NeedDaemonReload=no
+StateChangeTimestamp=Fri 2022-10-14 12:00:00 UTC
+`[1:]),
+	}
+	s.errors = []error{nil}
+	units := []string{"foo.service", "bar.service", "mnt.mount"}
+	out, err := New(SystemMode, s.rep).Status(units)
+	c.Assert(err, IsNil)
+	c.Check(out, DeepEquals, []*UnitStatus{
+		{
+			Daemon:               "simple",
+			Name:                 "foo.service",
+			Names:                []string{"foo.service"},
+			Enabled:              true,
+			Installed:            true,
+			Id:                   "foo.service",
+			NRestarts:            4,
+			ExecMainCode:         1,
+			ExecMainStatus:       3,
+			StateChangeTimestamp: time.Date(2022, 10, 14, 12, 34, 56, 0, time.UTC),
+		}, {
+			Daemon:    "simple",
+			Name:      "bar.service",
+			Names:     []string{"bar.service"},
+			Enabled:   true,
+			Installed: true,
+			Id:        "bar.service",
+		}, {
+			Name:                 "mnt.mount",
+			Names:                []string{"mnt.mount"},
+			Active:               true,
+			Enabled:              true,
+			Installed:            true,
+			Id:                   "mnt.mount",
+			StateChangeTimestamp: time.Date(2022, 10, 14, 12, 0, 0, 0, time.UTC),
+		},
+	})
+}
+
+func (s *SystemdTestSuite) TestStatusBadServiceRuntimeProperties(c *C) {
+	for _, tc := range []struct {
+		prop   string
+		errStr string
+	}{
+		{"NRestarts=many", `cannot get unit status: invalid NRestarts value "many" in ‘systemctl show’ output`},
+		{"ExecMainCode=", `cannot get unit status: empty field "ExecMainCode" in ‘systemctl show’ output`},
+		{"ExecMainStatus=x", `cannot get unit status: invalid ExecMainStatus value "x" in ‘systemctl show’ output`},
+		{"StateChangeTimestamp=yesterday", `cannot get unit status: invalid StateChangeTimestamp value "yesterday" in ‘systemctl show’ output`},
+	} {
+		s.outs = [][]byte{
+			[]byte(fmt.Sprintf(`
+Type=simple
+Id=foo.service
+Names=foo.service
+ActiveState=active
+UnitFileState=enabled
+NeedDaemonReload=no
+%s
+`[1:], tc.prop)),
+		}
+		s.errors = []error{nil}
+		s.i = 0
+		_, err := New(SystemMode, s.rep).Status([]string{"foo.service"})
+		c.Check(err, ErrorMatches, tc.errStr, Commentf(tc.prop))
+	}
+}
+
 func (s *SystemdTestSuite) TestStatusTooManyNumberOfValues(c *C) {
 	s.outs = [][]byte{
 		[]byte(`
@@ -0,0 +1,318 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timings
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The timings kept in the state only cover the most recent activity,
+// to track the performance of a device over its lifetime a summary of
+// every measured activity is also kept in a compact database on disk
+// that survives reboots. The database is a ring of fixed size records
+// preceded by a header, once full the oldest records are overwritten.
+//
+// Header (16 bytes, little endian):
+//   magic "SNTD", version uint32, capacity uint32, written uint32
+// Record (72 bytes, little endian):
+//   start time int64 (unix nanoseconds), duration int64,
+//   boot id [16]byte, kind [40]byte (NUL padded)
+
+const (
+	dbMagic      = "SNTD"
+	dbVersion    = 1
+	dbHeaderSize = 16
+	dbKindSize   = 40
+	dbRecordSize = 8 + 8 + 16 + dbKindSize
+)
+
+// MaxDBRecords is the capacity of newly created timings databases.
+var MaxDBRecords = 4096
+
+// Record is the summary of a measured activity kept in the timings
+// database. Kind identifies the activity, e.g. "change:refresh-snap" or
+// "ensure:auto-refresh".
+type Record struct {
+	Kind      string        `json:"kind"`
+	BootID    string        `json:"boot-id,omitempty"`
+	StartTime time.Time     `json:"start-time"`
+	Duration  time.Duration `json:"duration"`
+}
+
+type dbHeader struct {
+	Magic    [4]byte
+	Version  uint32
+	Capacity uint32
+	Written  uint32
+}
+
+type dbRecord struct {
+	Start    int64
+	Duration int64
+	BootID   [16]byte
+	Kind     [dbKindSize]byte
+}
+
+func encodeBootID(bootID string) (id [16]byte) {
+	b, err := hex.DecodeString(strings.Replace(bootID, "-", "", -1))
+	if err == nil && len(b) == len(id) {
+		copy(id[:], b)
+	}
+	return id
+}
+
+func decodeBootID(id [16]byte) string {
+	if id == [16]byte{} {
+		return ""
+	}
+	s := hex.EncodeToString(id[:])
+	return fmt.Sprintf("%s-%s-%s-%s-%s", s[0:8], s[8:12], s[12:16], s[16:20], s[20:32])
+}
+
+func readDBHeader(f *os.File) (*dbHeader, error) {
+	var hdr dbHeader
+	if err := binary.Read(f, binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr.Magic[:]) != dbMagic {
+		return nil, fmt.Errorf("invalid timings database magic %q", hdr.Magic[:])
+	}
+	if hdr.Version != dbVersion {
+		return nil, fmt.Errorf("unsupported timings database version %d", hdr.Version)
+	}
+	if hdr.Capacity == 0 {
+		return nil, fmt.Errorf("invalid timings database capacity 0")
+	}
+	return &hdr, nil
+}
+
+func writeDBHeader(f *os.File, hdr *dbHeader) error {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, hdr); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(buf.Bytes(), 0); err != nil {
+		return fmt.Errorf("cannot write timings database: %v", err)
+	}
+	return nil
+}
+
+// AppendRecords appends the given records to the timings database at
+// path, creating it if needed and overwriting the oldest records once
+// it is full.
+func AppendRecords(path string, records []*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open timings database: %v", err)
+	}
+	defer f.Close()
+
+	hdr, err := readDBHeader(f)
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		// new database, or one whose header never made it to disk;
+		// write and sync the header before any record so that a crash
+		// in between leaves a valid empty database behind
+		hdr = &dbHeader{Version: dbVersion, Capacity: uint32(MaxDBRecords)}
+		copy(hdr.Magic[:], dbMagic)
+		if err := writeDBHeader(f, hdr); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("cannot write timings database: %v", err)
+		}
+	case err != nil:
+		return fmt.Errorf("cannot read timings database: %v", err)
+	}
+
+	for _, rec := range records {
+		dbRec := dbRecord{
+			Start:    rec.StartTime.UnixNano(),
+			Duration: int64(rec.Duration),
+			BootID:   encodeBootID(rec.BootID),
+		}
+		copy(dbRec.Kind[:], rec.Kind)
+		var buf bytes.Buffer
+		if err := binary.Write(&buf, binary.LittleEndian, &dbRec); err != nil {
+			return err
+		}
+		off := dbHeaderSize + int64(hdr.Written%hdr.Capacity)*dbRecordSize
+		if _, err := f.WriteAt(buf.Bytes(), off); err != nil {
+			return fmt.Errorf("cannot write timings database: %v", err)
+		}
+		hdr.Written++
+	}
+
+	// records past the written count of the header on disk are ignored,
+	// so they only become visible once the header is updated
+	if err := writeDBHeader(f, hdr); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// ReadRecords returns the records of the timings database at path,
+// oldest first. It returns no records and no error if the database
+// does not exist.
+func ReadRecords(path string) ([]*Record, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open timings database: %v", err)
+	}
+	defer f.Close()
+
+	hdr, err := readDBHeader(f)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read timings database: %v", err)
+	}
+
+	n := hdr.Written
+	first := uint32(0)
+	if n > hdr.Capacity {
+		n = hdr.Capacity
+		first = hdr.Written % hdr.Capacity
+	}
+	records := make([]*Record, 0, n)
+	buf := make([]byte, dbRecordSize)
+	for i := uint32(0); i < n; i++ {
+		off := dbHeaderSize + int64((first+i)%hdr.Capacity)*dbRecordSize
+		if _, err := f.ReadAt(buf, off); err != nil {
+			return nil, fmt.Errorf("cannot read timings database: %v", err)
+		}
+		var dbRec dbRecord
+		if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &dbRec); err != nil {
+			return nil, err
+		}
+		records = append(records, &Record{
+			Kind:      string(bytes.TrimRight(dbRec.Kind[:], "\x00")),
+			BootID:    decodeBootID(dbRec.BootID),
+			StartTime: time.Unix(0, dbRec.Start),
+			Duration:  time.Duration(dbRec.Duration),
+		})
+	}
+	return records, nil
+}
+
+// AggregateInfo holds statistics about the recorded durations of one
+// kind of activity.
+type AggregateInfo struct {
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+	// Boots is the number of distinct boots the activity was recorded in.
+	Boots   int           `json:"boots"`
+	Min     time.Duration `json:"min"`
+	Average time.Duration `json:"average"`
+	Max     time.Duration `json:"max"`
+	// Last is the duration of the most recent occurrence.
+	Last      time.Duration `json:"last"`
+	FirstTime time.Time     `json:"first-time"`
+	LastTime  time.Time     `json:"last-time"`
+}
+
+// kindMatches returns whether the record kind, e.g.
+// "change:auto-refresh", matches the query: either the full kind, the
+// name after the category or one of the dash separated words of the
+// name.
+func kindMatches(kind, query string) bool {
+	if query == "" || kind == query {
+		return true
+	}
+	name := kind
+	if i := strings.IndexRune(kind, ':'); i >= 0 {
+		name = kind[i+1:]
+	}
+	if name == query {
+		return true
+	}
+	for _, word := range strings.Split(name, "-") {
+		if word == query {
+			return true
+		}
+	}
+	return false
+}
+
+// Aggregate computes statistics for each kind of the given records that
+// matches query, e.g. "refresh" matches "change:refresh-snap" and
+// "ensure:auto-refresh". An empty query matches all records. The result
+// is sorted by kind.
+func Aggregate(records []*Record, query string) []*AggregateInfo {
+	byKind := make(map[string]*AggregateInfo)
+	boots := make(map[string]map[string]bool)
+	totals := make(map[string]time.Duration)
+	for _, rec := range records {
+		if !kindMatches(rec.Kind, query) {
+			continue
+		}
+		agg := byKind[rec.Kind]
+		if agg == nil {
+			agg = &AggregateInfo{
+				Kind:      rec.Kind,
+				Min:       rec.Duration,
+				FirstTime: rec.StartTime,
+			}
+			byKind[rec.Kind] = agg
+			boots[rec.Kind] = make(map[string]bool)
+		}
+		agg.Count++
+		totals[rec.Kind] += rec.Duration
+		if rec.Duration < agg.Min {
+			agg.Min = rec.Duration
+		}
+		if rec.Duration > agg.Max {
+			agg.Max = rec.Duration
+		}
+		if !rec.StartTime.Before(agg.LastTime) {
+			agg.LastTime = rec.StartTime
+			agg.Last = rec.Duration
+		}
+		if rec.StartTime.Before(agg.FirstTime) {
+			agg.FirstTime = rec.StartTime
+		}
+		boots[rec.Kind][rec.BootID] = true
+	}
+
+	result := make([]*AggregateInfo, 0, len(byKind))
+	for kind, agg := range byKind {
+		agg.Average = totals[kind] / time.Duration(agg.Count)
+		agg.Boots = len(boots[kind])
+		result = append(result, agg)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Kind < result[j].Kind })
+	return result
+}
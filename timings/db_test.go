@@ -0,0 +1,203 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2024 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timings_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/timings"
+)
+
+type dbSuite struct {
+	testutil.BaseTest
+	path string
+	t0   time.Time
+}
+
+var _ = Suite(&dbSuite{})
+
+const bootID1 = "8f0b9a6e-55a3-4c6e-a0e0-1ed4bb4b01a1"
+const bootID2 = "1c1b5e2e-4c6f-4d33-9c1d-7f0a2f62b0c2"
+
+func (s *dbSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.path = filepath.Join(c.MkDir(), "timings.db")
+	s.t0 = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+}
+
+func (s *dbSuite) TestReadRecordsNoDB(c *C) {
+	records, err := timings.ReadRecords(s.path)
+	c.Assert(err, IsNil)
+	c.Check(records, HasLen, 0)
+}
+
+func (s *dbSuite) TestAppendReadRecords(c *C) {
+	err := timings.AppendRecords(s.path, []*timings.Record{
+		{Kind: "change:refresh-snap", BootID: bootID1, StartTime: s.t0, Duration: 3 * time.Second},
+		{Kind: "ensure:seed", StartTime: s.t0.Add(time.Minute), Duration: time.Second},
+	})
+	c.Assert(err, IsNil)
+	err = timings.AppendRecords(s.path, []*timings.Record{
+		{Kind: "startup:load-state", BootID: bootID2, StartTime: s.t0.Add(time.Hour), Duration: time.Millisecond},
+	})
+	c.Assert(err, IsNil)
+
+	records, err := timings.ReadRecords(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	c.Check(records[0].Kind, Equals, "change:refresh-snap")
+	c.Check(records[0].BootID, Equals, bootID1)
+	c.Check(records[0].StartTime.Equal(s.t0), Equals, true)
+	c.Check(records[0].Duration, Equals, 3*time.Second)
+	c.Check(records[1].Kind, Equals, "ensure:seed")
+	c.Check(records[1].BootID, Equals, "")
+	c.Check(records[2].Kind, Equals, "startup:load-state")
+	c.Check(records[2].BootID, Equals, bootID2)
+
+	// the database is compact
+	fi, err := os.Stat(s.path)
+	c.Assert(err, IsNil)
+	c.Check(fi.Size(), Equals, int64(16+3*72))
+}
+
+func (s *dbSuite) TestAppendRecordsRing(c *C) {
+	defer func(old int) { timings.MaxDBRecords = old }(timings.MaxDBRecords)
+	timings.MaxDBRecords = 3
+
+	for i := 0; i < 5; i++ {
+		err := timings.AppendRecords(s.path, []*timings.Record{
+			{Kind: "change:refresh-snap", StartTime: s.t0.Add(time.Duration(i) * time.Hour), Duration: time.Duration(i) * time.Second},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	records, err := timings.ReadRecords(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	for i, rec := range records {
+		c.Check(rec.Duration, Equals, time.Duration(i+2)*time.Second)
+	}
+
+	// the capacity of an existing database is kept
+	timings.MaxDBRecords = 10
+	err = timings.AppendRecords(s.path, []*timings.Record{
+		{Kind: "change:refresh-snap", StartTime: s.t0.Add(5 * time.Hour), Duration: 5 * time.Second},
+	})
+	c.Assert(err, IsNil)
+	records, err = timings.ReadRecords(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 3)
+	c.Check(records[2].Duration, Equals, 5*time.Second)
+}
+
+func (s *dbSuite) TestReadRecordsInvalid(c *C) {
+	c.Assert(ioutil.WriteFile(s.path, []byte("garbage-garbage-garbage"), 0600), IsNil)
+
+	_, err := timings.ReadRecords(s.path)
+	c.Check(err, ErrorMatches, `cannot read timings database: invalid timings database magic "garb"`)
+	err = timings.AppendRecords(s.path, []*timings.Record{{Kind: "ensure:seed"}})
+	c.Check(err, ErrorMatches, `cannot read timings database: invalid timings database magic "garb"`)
+}
+
+func (s *dbSuite) TestAppendRecordsShortHeader(c *C) {
+	// a header which was not fully written
+	c.Assert(ioutil.WriteFile(s.path, []byte("SNTD"), 0600), IsNil)
+
+	records, err := timings.ReadRecords(s.path)
+	c.Assert(err, IsNil)
+	c.Check(records, HasLen, 0)
+
+	err = timings.AppendRecords(s.path, []*timings.Record{{Kind: "ensure:seed", Duration: time.Second}})
+	c.Assert(err, IsNil)
+	records, err = timings.ReadRecords(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(records, HasLen, 1)
+	c.Check(records[0].Kind, Equals, "ensure:seed")
+}
+
+func (s *dbSuite) TestAppendRecordsHeaderOnly(c *C) {
+	// what is left behind if a new database is interrupted after the
+	// header was synced but before any record was written
+	hdr := []byte("SNTD\x01\x00\x00\x00\x03\x00\x00\x00\x00\x00\x00\x00")
+	c.Assert(ioutil.WriteFile(s.path, hdr, 0600), IsNil)
+
+	records, err := timings.ReadRecords(s.path)
+	c.Assert(err, IsNil)
+	c.Check(records, HasLen, 0)
+
+	for i := 0; i < 4; i++ {
+		err = timings.AppendRecords(s.path, []*timings.Record{{Kind: "ensure:seed", Duration: time.Duration(i) * time.Second}})
+		c.Assert(err, IsNil)
+	}
+	records, err = timings.ReadRecords(s.path)
+	c.Assert(err, IsNil)
+	// the capacity from the header is used
+	c.Assert(records, HasLen, 3)
+	c.Check(records[0].Duration, Equals, time.Second)
+}
+
+func (s *dbSuite) TestAggregate(c *C) {
+	records := []*timings.Record{
+		{Kind: "change:refresh-snap", BootID: bootID1, StartTime: s.t0, Duration: 2 * time.Second},
+		{Kind: "ensure:auto-refresh", BootID: bootID1, StartTime: s.t0, Duration: time.Second},
+		{Kind: "change:refresh-snap", BootID: bootID2, StartTime: s.t0.Add(2 * time.Hour), Duration: 6 * time.Second},
+		{Kind: "change:refresh-snap", BootID: bootID2, StartTime: s.t0.Add(time.Hour), Duration: 4 * time.Second},
+		{Kind: "change:seed", BootID: bootID1, StartTime: s.t0, Duration: time.Minute},
+		{Kind: "change:refreshing", BootID: bootID1, StartTime: s.t0, Duration: time.Minute},
+	}
+
+	aggs := timings.Aggregate(records, "refresh")
+	c.Check(aggs, DeepEquals, []*timings.AggregateInfo{{
+		Kind:      "change:refresh-snap",
+		Count:     3,
+		Boots:     2,
+		Min:       2 * time.Second,
+		Average:   4 * time.Second,
+		Max:       6 * time.Second,
+		Last:      6 * time.Second,
+		FirstTime: s.t0,
+		LastTime:  s.t0.Add(2 * time.Hour),
+	}, {
+		Kind:      "ensure:auto-refresh",
+		Count:     1,
+		Boots:     1,
+		Min:       time.Second,
+		Average:   time.Second,
+		Max:       time.Second,
+		Last:      time.Second,
+		FirstTime: s.t0,
+		LastTime:  s.t0,
+	}})
+
+	aggs = timings.Aggregate(records, "change:seed")
+	c.Assert(aggs, HasLen, 1)
+	c.Check(aggs[0].Kind, Equals, "change:seed")
+
+	aggs = timings.Aggregate(records, "")
+	c.Check(aggs, HasLen, 4)
+
+	c.Check(timings.Aggregate(records, "remove"), HasLen, 0)
+}
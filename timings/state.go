@@ -48,6 +48,7 @@ type rootTimingsJSON struct {
 type TimingsInfo struct {
 	Tags          map[string]string
 	NestedTimings []*TimingJSON
+	StartTime     time.Time
 	Duration      time.Duration
 }
 
@@ -172,8 +173,9 @@ func Get(s GetSaver, maxLevel int, filter func(tags map[string]string) bool) ([]
 			continue
 		}
 		res := &TimingsInfo{
-			Tags:     tm.Tags,
-			Duration: timeDuration(tm.StartTime, tm.StopTime),
+			Tags:      tm.Tags,
+			StartTime: tm.StartTime,
+			Duration:  timeDuration(tm.StartTime, tm.StopTime),
 		}
 		// negative maxLevel means no level filtering, take all nested timings
 		if maxLevel < 0 {
@@ -331,6 +331,7 @@ func (s *timingsSuite) TestGet(c *C) {
 	s.st.Lock()
 	defer s.st.Unlock()
 
+	t0 := s.fakeTime
 	// three timings, with 2 nested measures
 	for i := 0; i < 3; i++ {
 		timing := timings.New(map[string]string{"foo": fmt.Sprintf("%d", i)})
@@ -351,8 +352,9 @@ func (s *timingsSuite) TestGet(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(tm, DeepEquals, []*timings.TimingsInfo{
 		{
-			Tags:     map[string]string{"foo": "1"},
-			Duration: 3000000,
+			Tags:      map[string]string{"foo": "1"},
+			StartTime: t0.Add(5 * time.Millisecond),
+			Duration:  3000000,
 			NestedTimings: []*timings.TimingJSON{
 				{Level: 0, Label: "doing something-1", Summary: "...", Duration: 3000000},
 				{Level: 1, Label: "nested measurement", Summary: "...", Duration: 1000000},
@@ -364,22 +366,25 @@ func (s *timingsSuite) TestGet(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(tmOnlyLevel0, DeepEquals, []*timings.TimingsInfo{
 		{
-			Tags:     map[string]string{"foo": "0"},
-			Duration: 3000000,
+			Tags:      map[string]string{"foo": "0"},
+			StartTime: t0.Add(1 * time.Millisecond),
+			Duration:  3000000,
 			NestedTimings: []*timings.TimingJSON{
 				{Level: 0, Label: "doing something-0", Summary: "...", Duration: 3000000},
 			},
 		},
 		{
-			Tags:     map[string]string{"foo": "1"},
-			Duration: 3000000,
+			Tags:      map[string]string{"foo": "1"},
+			StartTime: t0.Add(5 * time.Millisecond),
+			Duration:  3000000,
 			NestedTimings: []*timings.TimingJSON{
 				{Level: 0, Label: "doing something-1", Summary: "...", Duration: 3000000},
 			},
 		},
 		{
-			Tags:     map[string]string{"foo": "2"},
-			Duration: 3000000,
+			Tags:      map[string]string{"foo": "2"},
+			StartTime: t0.Add(9 * time.Millisecond),
+			Duration:  3000000,
 			NestedTimings: []*timings.TimingJSON{
 				{Level: 0, Label: "doing something-2", Summary: "...", Duration: 3000000},
 			},
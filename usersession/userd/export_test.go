@@ -46,3 +46,11 @@ func MockRegularFileExists(f func(string) (bool, bool, error)) func() {
 		regularFileExists = old
 	}
 }
+
+func MockRecordLaunchDenial(f func(snapName string, data map[string]string)) func() {
+	old := recordLaunchDenial
+	recordLaunchDenial = f
+	return func() {
+		recordLaunchDenial = old
+	}
+}
@@ -31,6 +31,9 @@ import (
 
 	"github.com/godbus/dbus"
 
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/desktop/launchpolicy"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
@@ -160,23 +163,70 @@ func checkOnClassic() *dbus.Error {
 // see https://specifications.freedesktop.org/desktop-entry-spec/desktop-entry-spec-latest.html#file-naming
 var validDesktopFileName = regexp.MustCompile(`^[A-Za-z-_][A-Za-z0-9-_]*(\.[A-Za-z-_][A-Za-z0-9-_]*)*\.desktop$`)
 
-func schemeHasHandler(scheme string) (bool, error) {
+// schemeHandler returns the desktop file of the application handling the
+// given scheme in the session, or "" if there is none.
+func schemeHandler(scheme string) (string, error) {
 	cmd := exec.Command("xdg-mime", "query", "default", "x-scheme-handler/"+scheme)
 	// TODO: consider using Output() in case xdg-mime starts logging to
 	// stderr
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return false, osutil.OutputErr(out, err)
+		return "", osutil.OutputErr(out, err)
 	}
 	out = bytes.TrimSpace(out)
 	// if the output is a valid desktop file we have a handler for the given
 	// scheme
-	return validDesktopFileName.Match(out), nil
+	if !validDesktopFileName.Match(out) {
+		return "", nil
+	}
+	return string(out), nil
+}
+
+func schemeHasHandler(scheme string) (bool, error) {
+	handler, err := schemeHandler(scheme)
+	return handler != "", err
+}
+
+var (
+	readLaunchPolicy = func() (*launchpolicy.Policy, error) {
+		return launchpolicy.Read(dirs.SnapLaunchPolicyFile)
+	}
+	recordLaunchDenial = recordLaunchDenialImpl
+)
+
+// recordLaunchDenialImpl reports to snapd that opening a URL or a file was
+// denied to a snap by the launch policy, so that it is recorded as a
+// notice.
+func recordLaunchDenialImpl(snapName string, data map[string]string) {
+	cli := client.New(nil)
+	_, err := cli.AddNotice(&client.AddNoticeOptions{
+		Type: client.LaunchDenialNotice,
+		Key:  snapName,
+		Data: data,
+	})
+	if err != nil {
+		logger.Noticef("cannot record launch denial of snap %q: %v", snapName, err)
+	}
+}
+
+// loadLaunchPolicy returns the configured launch policy, or nil if there
+// is none.
+func loadLaunchPolicy() (*launchpolicy.Policy, *dbus.Error) {
+	policy, err := readLaunchPolicy()
+	if err != nil {
+		// do not open anything without knowing what is allowed
+		logger.Noticef("%v", err)
+		return nil, dbus.MakeFailedError(fmt.Errorf("cannot determine launch policy"))
+	}
+	return policy, nil
 }
 
 // OpenURL implements the 'OpenURL' method of the 'io.snapcraft.Launcher'
 // DBus interface. Before the provided url is passed to xdg-open the scheme is
-// validated against a list of allowed schemes. All other schemes are denied.
+// validated against a list of allowed schemes, and against the launch policy
+// if one is configured. All other schemes are denied. Snaps are denied the
+// OpenURI portal while a launch policy is configured, so that xdg-open
+// falls back to this method.
 func (s *Launcher) OpenURL(addr string, sender dbus.Sender) *dbus.Error {
 	logger.Debugf("open url: %q", addr)
 	if err := checkOnClassic(); err != nil {
@@ -191,20 +241,56 @@ func (s *Launcher) OpenURL(addr string, sender dbus.Sender) *dbus.Error {
 		return makeAccessDeniedError(fmt.Errorf("cannot open URL without a scheme"))
 	}
 
-	isAllowed := strutil.ListContains(allowedURLSchemes, u.Scheme)
-	if !isAllowed {
-		// scheme is not listed in our allowed schemes list, perform
-		// fallback and check whether the local system has a handler for
-		// it
-		isAllowed, err = schemeHasHandler(u.Scheme)
+	policy, dbusErr := loadLaunchPolicy()
+	if dbusErr != nil {
+		return dbusErr
+	}
+	var snapName string
+	if policy != nil {
+		snapName, err = snapFromSender(s.conn, sender)
 		if err != nil {
-			logger.Noticef("cannot obtain scheme handler for %q: %v", u.Scheme, err)
+			return dbus.MakeFailedError(err)
+		}
+	}
+
+	isAllowed := policy != nil && policy.SchemeAllowed(snapName, u.Scheme)
+	if !isAllowed && (policy == nil || !policy.Restricted) {
+		isAllowed = strutil.ListContains(allowedURLSchemes, u.Scheme)
+		if !isAllowed {
+			// scheme is not listed in our allowed schemes list, perform
+			// fallback and check whether the local system has a handler for
+			// it
+			isAllowed, err = schemeHasHandler(u.Scheme)
+			if err != nil {
+				logger.Noticef("cannot obtain scheme handler for %q: %v", u.Scheme, err)
+			}
 		}
 	}
 	if !isAllowed {
+		if policy != nil {
+			recordLaunchDenial(snapName, map[string]string{
+				"operation": "open-url",
+				"scheme":    u.Scheme,
+			})
+		}
 		return makeAccessDeniedError(fmt.Errorf("Supplied URL scheme %q is not allowed", u.Scheme))
 	}
 
+	if policy != nil && policy.RestrictsHandlers(snapName) {
+		handler, err := schemeHandler(u.Scheme)
+		if err != nil {
+			logger.Noticef("cannot obtain scheme handler for %q: %v", u.Scheme, err)
+		}
+		if handler == "" || !policy.HandlerAllowed(snapName, handler) {
+			recordLaunchDenial(snapName, map[string]string{
+				"operation": "open-url",
+				"scheme":    u.Scheme,
+				"handler":   handler,
+			})
+			return makeAccessDeniedError(fmt.Errorf("Handler %q of URL scheme %q is not allowed", handler, u.Scheme))
+		}
+	}
+
 	if err := exec.Command("xdg-open", addr).Run(); err != nil {
 		return dbus.MakeFailedError(fmt.Errorf("cannot open supplied URL"))
 	}
@@ -275,6 +361,18 @@ func (s *Launcher) OpenFile(parentWindow string, clientFd dbus.UnixFD, sender db
 	if err != nil {
 		return dbus.MakeFailedError(err)
 	}
+
+	policy, dbusErr := loadLaunchPolicy()
+	if dbusErr != nil {
+		return dbusErr
+	}
+	if policy != nil && !policy.FilesAllowed(snap) {
+		recordLaunchDenial(snap, map[string]string{
+			"operation": "open-file",
+		})
+		return makeAccessDeniedError(fmt.Errorf("Opening files is not allowed"))
+	}
+
 	dialog, err := ui.New()
 	if err != nil {
 		return dbus.MakeFailedError(err)
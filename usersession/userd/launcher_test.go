@@ -30,6 +30,8 @@ import (
 	"github.com/godbus/dbus"
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/desktop/launchpolicy"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil/sys"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/testutil"
@@ -44,6 +46,8 @@ type launcherSuite struct {
 	launcher    *userd.Launcher
 	mockXdgOpen *testutil.MockCmd
 	mockXdgMime *testutil.MockCmd
+
+	denials []map[string]string
 }
 
 var _ = Suite(&launcherSuite{})
@@ -60,6 +64,14 @@ func (s *launcherSuite) SetUpTest(c *C) {
 	s.AddCleanup(userd.MockSnapFromSender(func(*dbus.Conn, dbus.Sender) (string, error) {
 		return "some-snap", nil
 	}))
+
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.denials = nil
+	s.AddCleanup(userd.MockRecordLaunchDenial(func(snapName string, data map[string]string) {
+		c.Check(snapName, Equals, "some-snap")
+		s.denials = append(s.denials, data)
+	}))
 }
 
 func (s *launcherSuite) TestOpenURLWithNotAllowedScheme(c *C) {
@@ -264,3 +276,143 @@ func (s *launcherSuite) TestFailsOnUbuntuCore(c *C) {
 
 	c.Check(s.mockXdgOpen.Calls(), HasLen, 0)
 }
+
+func (s *launcherSuite) writeLaunchPolicy(c *C, policy *launchpolicy.Policy) {
+	c.Assert(launchpolicy.Write(dirs.SnapLaunchPolicyFile, policy), IsNil)
+}
+
+func (s *launcherSuite) openTestFile(c *C) dbus.UnixFD {
+	path := filepath.Join(c.MkDir(), "test.txt")
+	c.Assert(ioutil.WriteFile(path, []byte("Hello world"), 0644), IsNil)
+	file, err := os.Open(path)
+	c.Assert(err, IsNil)
+	defer file.Close()
+	dupFd, err := syscall.Dup(int(file.Fd()))
+	c.Assert(err, IsNil)
+	return dbus.UnixFD(dupFd)
+}
+
+func (s *launcherSuite) TestOpenURLNoLaunchPolicyNoDenial(c *C) {
+	err := s.launcher.OpenURL("tel://049112233445566", ":some-dbus-sender")
+	c.Check(err, ErrorMatches, `Supplied URL scheme "tel" is not allowed`)
+	c.Check(s.denials, HasLen, 0)
+}
+
+func (s *launcherSuite) TestOpenURLLaunchPolicyRestrictedDenied(c *C) {
+	s.writeLaunchPolicy(c, &launchpolicy.Policy{Restricted: true})
+
+	err := s.launcher.OpenURL("https://snapcraft.io", ":some-dbus-sender")
+	c.Check(err, ErrorMatches, `Supplied URL scheme "https" is not allowed`)
+	c.Check(s.mockXdgOpen.Calls(), IsNil)
+	// the session handlers are not considered in restricted mode
+	c.Check(s.mockXdgMime.Calls(), IsNil)
+	c.Check(s.denials, DeepEquals, []map[string]string{
+		{"operation": "open-url", "scheme": "https"},
+	})
+}
+
+func (s *launcherSuite) TestOpenURLLaunchPolicyRestrictedAllowed(c *C) {
+	s.writeLaunchPolicy(c, &launchpolicy.Policy{
+		Restricted:     true,
+		AllowedSchemes: []string{"mailto"},
+		Snaps: map[string]*launchpolicy.SnapPolicy{
+			"some-snap": {AllowedSchemes: []string{"https"}},
+		},
+	})
+
+	for _, url := range []string{"https://snapcraft.io", "mailto:foo@example.com"} {
+		s.mockXdgOpen.ForgetCalls()
+		err := s.launcher.OpenURL(url, ":some-dbus-sender")
+		c.Assert(err, IsNil)
+		c.Check(s.mockXdgOpen.Calls(), DeepEquals, [][]string{
+			{"xdg-open", url},
+		})
+	}
+	c.Check(s.denials, HasLen, 0)
+}
+
+func (s *launcherSuite) TestOpenURLLaunchPolicyExtraScheme(c *C) {
+	s.writeLaunchPolicy(c, &launchpolicy.Policy{
+		AllowedSchemes: []string{"tel"},
+	})
+
+	err := s.launcher.OpenURL("tel://049112233445566", ":some-dbus-sender")
+	c.Assert(err, IsNil)
+	c.Check(s.mockXdgMime.Calls(), IsNil)
+	c.Check(s.mockXdgOpen.Calls(), DeepEquals, [][]string{
+		{"xdg-open", "tel://049112233445566"},
+	})
+
+	// the builtin schemes are still allowed in default mode
+	err = s.launcher.OpenURL("https://snapcraft.io", ":some-dbus-sender")
+	c.Assert(err, IsNil)
+	c.Check(s.denials, HasLen, 0)
+}
+
+func (s *launcherSuite) TestOpenURLLaunchPolicyHandlers(c *C) {
+	mockXdgMime := testutil.MockCommand(c, "xdg-mime", "echo firefox_firefox.desktop")
+	defer mockXdgMime.Restore()
+
+	s.writeLaunchPolicy(c, &launchpolicy.Policy{
+		Snaps: map[string]*launchpolicy.SnapPolicy{
+			"some-snap": {AllowedHandlers: []string{"firefox_firefox.desktop"}},
+		},
+	})
+	err := s.launcher.OpenURL("https://snapcraft.io", ":some-dbus-sender")
+	c.Assert(err, IsNil)
+	c.Check(mockXdgMime.Calls(), DeepEquals, [][]string{
+		{"xdg-mime", "query", "default", "x-scheme-handler/https"},
+	})
+	c.Check(s.mockXdgOpen.Calls(), HasLen, 1)
+	c.Check(s.denials, HasLen, 0)
+
+	s.mockXdgOpen.ForgetCalls()
+	s.writeLaunchPolicy(c, &launchpolicy.Policy{
+		Snaps: map[string]*launchpolicy.SnapPolicy{
+			"some-snap": {AllowedHandlers: []string{"chromium_chromium.desktop"}},
+		},
+	})
+	err = s.launcher.OpenURL("https://snapcraft.io", ":some-dbus-sender")
+	c.Check(err, ErrorMatches, `Handler "firefox_firefox.desktop" of URL scheme "https" is not allowed`)
+	c.Check(s.mockXdgOpen.Calls(), IsNil)
+	c.Check(s.denials, DeepEquals, []map[string]string{
+		{"operation": "open-url", "scheme": "https", "handler": "firefox_firefox.desktop"},
+	})
+}
+
+func (s *launcherSuite) TestOpenURLLaunchPolicyInvalid(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapLaunchPolicyFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapLaunchPolicyFile, []byte("{"), 0644), IsNil)
+
+	err := s.launcher.OpenURL("https://snapcraft.io", ":some-dbus-sender")
+	c.Check(err, ErrorMatches, "cannot determine launch policy")
+	c.Check(s.mockXdgOpen.Calls(), IsNil)
+}
+
+func (s *launcherSuite) TestOpenFileLaunchPolicyRestrictedDenied(c *C) {
+	s.writeLaunchPolicy(c, &launchpolicy.Policy{Restricted: true})
+
+	err := s.launcher.OpenFile("", s.openTestFile(c), ":some-dbus-sender")
+	c.Check(err, ErrorMatches, "Opening files is not allowed")
+	c.Check(s.mockXdgOpen.Calls(), IsNil)
+	c.Check(s.denials, DeepEquals, []map[string]string{
+		{"operation": "open-file"},
+	})
+}
+
+func (s *launcherSuite) TestOpenFileLaunchPolicyRestrictedAllowed(c *C) {
+	restore := mockUICommands(c, "true")
+	defer restore()
+
+	s.writeLaunchPolicy(c, &launchpolicy.Policy{
+		Restricted: true,
+		Snaps: map[string]*launchpolicy.SnapPolicy{
+			"some-snap": {AllowFiles: true},
+		},
+	})
+
+	err := s.launcher.OpenFile("", s.openTestFile(c), ":some-dbus-sender")
+	c.Assert(err, IsNil)
+	c.Check(s.mockXdgOpen.Calls(), HasLen, 1)
+	c.Check(s.denials, HasLen, 0)
+}
@@ -93,6 +93,7 @@ var isValidDesktopFileLine = regexp.MustCompile(strings.Join([]string{
 	"^Terminal=",
 	"^Actions=",
 	"^MimeType=",
+	"^DBusActivatable=",
 	"^Categories=",
 	"^Keywords" + localizedSuffix,
 	"^StartupNotify=",
@@ -172,9 +173,107 @@ func rewriteIconLine(s *snap.Info, line string) (string, error) {
 	return line, nil
 }
 
+// isValidMimeType checks a media type as listed by the MimeType key,
+// including the x-scheme-handler/<scheme> pseudo types.
+var isValidMimeType = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]*/[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]*$`).MatchString
+
+// rewriteMimeTypeLine drops the invalid media types from a "MimeType=" line,
+// such that the remaining ones are registered with the desktop database.
+func rewriteMimeTypeLine(line string) (string, error) {
+	var mimeTypes []string
+	for _, mimeType := range strings.Split(strings.SplitN(line, "=", 2)[1], ";") {
+		if mimeType == "" {
+			continue
+		}
+		if !isValidMimeType(mimeType) {
+			logger.Debugf("ignoring invalid media type %q", mimeType)
+			continue
+		}
+		mimeTypes = append(mimeTypes, mimeType)
+	}
+	if len(mimeTypes) == 0 {
+		return "", fmt.Errorf("no valid media type in %q", line)
+	}
+	return "MimeType=" + strings.Join(mimeTypes, ";") + ";", nil
+}
+
+// sessionBusNames returns the well-known session bus names the snap user
+// daemons are activated on.
+func sessionBusNames(s *snap.Info) map[string]bool {
+	busNames := make(map[string]bool)
+	for _, app := range s.Apps {
+		if app.DaemonScope != snap.UserDaemon {
+			continue
+		}
+		for _, slot := range app.ActivatesOn {
+			var busName string
+			if err := slot.Attr("name", &busName); err == nil {
+				busNames[busName] = true
+			}
+		}
+	}
+	return busNames
+}
+
+// isDBusActivatableDesktopFile checks whether the desktop file of the snap,
+// installed under the given name, can be started through D-Bus activation.
+// The desktop environment derives the bus name from the desktop file name,
+// which must thus be a session bus name the snap is activated on.
+func isDBusActivatableDesktopFile(s *snap.Info, desktopFile string) bool {
+	busName := strings.TrimSuffix(filepath.Base(desktopFile), ".desktop")
+	return sessionBusNames(s)[busName]
+}
+
+var desktopActionHeader = regexp.MustCompile(`^\[Desktop Action ([0-9A-Za-z-]+)\]$`)
+
+// sanitizeDesktopActions drops the desktop actions which cannot be started
+// as their Exec line was dropped, along with the actions which have no
+// group, from the Actions key of the desktop entry.
+func sanitizeDesktopActions(content []byte, brokenActions map[string]bool) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	actions := make(map[string]bool)
+	for _, line := range lines {
+		if m := desktopActionHeader.FindSubmatch(bytes.TrimSuffix(line, []byte("\n"))); m != nil {
+			actions[string(m[1])] = !brokenActions[string(m[1])]
+		}
+	}
+
+	var newContent bytes.Buffer
+	inBrokenAction := false
+	for _, line := range lines {
+		if bytes.HasPrefix(line, []byte("[")) {
+			m := desktopActionHeader.FindSubmatch(bytes.TrimSuffix(line, []byte("\n")))
+			inBrokenAction = m != nil && brokenActions[string(m[1])]
+		}
+		if inBrokenAction {
+			continue
+		}
+		if bytes.HasPrefix(line, []byte("Actions=")) {
+			var kept []string
+			for _, action := range strings.Split(strings.TrimSpace(string(line[len("Actions="):])), ";") {
+				if action != "" && actions[action] {
+					kept = append(kept, action)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			line = []byte("Actions=" + strings.Join(kept, ";") + ";\n")
+		}
+		newContent.Write(line)
+	}
+	return newContent.Bytes()
+}
+
 func sanitizeDesktopFile(s *snap.Info, desktopFile string, rawcontent []byte) []byte {
 	var newContent bytes.Buffer
 	mountDir := []byte(s.MountDir())
+	dbusActivatable := isDBusActivatableDesktopFile(s, desktopFile)
+	// the actions of which the Exec line was dropped, an action cannot be
+	// started without one unless it is activated through D-Bus
+	var brokenActions map[string]bool
+	var hasActions bool
+	var action string
 	scanner := bufio.NewScanner(bytes.NewReader(rawcontent))
 	for i := 0; scanner.Scan(); i++ {
 		bline := scanner.Bytes()
@@ -184,12 +283,28 @@ func sanitizeDesktopFile(s *snap.Info, desktopFile string, rawcontent []byte) []
 			continue
 		}
 
+		if bytes.HasPrefix(bline, []byte("[")) {
+			action = ""
+			if m := desktopActionHeader.FindSubmatch(bline); m != nil {
+				action = string(m[1])
+			}
+		}
+		if bytes.HasPrefix(bline, []byte("Actions=")) {
+			hasActions = true
+		}
+
 		// rewrite exec lines to an absolute path for the binary
 		if bytes.HasPrefix(bline, []byte("Exec=")) {
 			var err error
 			line, err := rewriteExecLine(s, desktopFile, string(bline))
 			if err != nil {
 				// something went wrong, ignore the line
+				if action != "" && !dbusActivatable {
+					if brokenActions == nil {
+						brokenActions = make(map[string]bool)
+					}
+					brokenActions[action] = true
+				}
 				continue
 			}
 			bline = []byte(line)
@@ -205,6 +320,23 @@ func sanitizeDesktopFile(s *snap.Info, desktopFile string, rawcontent []byte) []
 			bline = []byte(line)
 		}
 
+		// only keep the media types the desktop database can register
+		if bytes.HasPrefix(bline, []byte("MimeType=")) {
+			line, err := rewriteMimeTypeLine(string(bline))
+			if err != nil {
+				logger.Debugf("ignoring media types in source desktop file %q: %s", filepath.Base(desktopFile), err)
+				continue
+			}
+			bline = []byte(line)
+		}
+
+		// D-Bus activation goes to the bus name matching the desktop file
+		// name, which must be one the snap is activated on
+		if bytes.HasPrefix(bline, []byte("DBusActivatable=")) && !dbusActivatable {
+			logger.Debugf("ignoring line %d (%q) in source of desktop file %q: not installed under a bus name the snap is activated on", i, bline, filepath.Base(desktopFile))
+			continue
+		}
+
 		// do variable substitution
 		bline = bytes.Replace(bline, []byte("${SNAP}"), mountDir, -1)
 
@@ -218,7 +350,10 @@ func sanitizeDesktopFile(s *snap.Info, desktopFile string, rawcontent []byte) []
 		}
 	}
 
-	return newContent.Bytes()
+	if !hasActions && brokenActions == nil {
+		return newContent.Bytes()
+	}
+	return sanitizeDesktopActions(newContent.Bytes(), brokenActions)
 }
 
 func updateDesktopDatabase(desktopFiles []string) error {
@@ -235,6 +370,69 @@ func updateDesktopDatabase(desktopFiles []string) error {
 	return nil
 }
 
+var isDBusActivatableLine = regexp.MustCompile(`(?m)^DBusActivatable=true\s*$`).Match
+
+// installedDesktopFileName returns the path the given desktop file of the
+// snap is installed under. Desktop files are prefixed with the snap name,
+// except for the ones started through D-Bus activation which keep the bus
+// name the snap is activated on as their name.
+func installedDesktopFileName(s *snap.Info, desktopFile string, content []byte) string {
+	base := filepath.Base(desktopFile)
+	if isDBusActivatableLine(content) && isDBusActivatableDesktopFile(s, base) {
+		return filepath.Join(dirs.SnapDesktopFilesDir, base)
+	}
+	// FIXME: don't blindly use the snap desktop filename, mangle it
+	// but we can't just use the app name because a desktop file
+	// may call the same app with multiple parameters, e.g.
+	// --create-new, --open-existing etc
+	return filepath.Join(dirs.SnapDesktopFilesDir, fmt.Sprintf("%s_%s", s.DesktopPrefix(), base))
+}
+
+var snapInstanceNameLine = regexp.MustCompile(`(?m)^X-SnapInstanceName=(.*)$`)
+
+// snapDBusActivatableDesktopFiles returns the desktop files of the snap
+// installed under a bus name it is activated on.
+func snapDBusActivatableDesktopFiles(s *snap.Info) ([]string, error) {
+	var desktopFiles []string
+	for busName := range sessionBusNames(s) {
+		df := filepath.Join(dirs.SnapDesktopFilesDir, busName+".desktop")
+		content, err := ioutil.ReadFile(df)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// only ever remove the desktop files written for the snap
+		if m := snapInstanceNameLine.FindSubmatch(content); m != nil && string(m[1]) == s.InstanceName() {
+			desktopFiles = append(desktopFiles, df)
+		}
+	}
+	return desktopFiles, nil
+}
+
+// checkDesktopFileInstance checks that the desktop file installed under a
+// bus name, if any, was written for the given snap instance. Unlike other
+// desktop files those are not prefixed with the snap name, another snap
+// activated on the same bus name could have installed it.
+func checkDesktopFileInstance(s *snap.Info, desktopFile string) error {
+	content, err := ioutil.ReadFile(desktopFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m := snapInstanceNameLine.FindSubmatch(content)
+	if m == nil {
+		return fmt.Errorf("cannot install desktop file %q: already installed by another package", desktopFile)
+	}
+	if string(m[1]) != s.InstanceName() {
+		return fmt.Errorf("cannot install desktop file %q: already installed by snap %q", desktopFile, m[1])
+	}
+	return nil
+}
+
 // AddSnapDesktopFiles puts in place the desktop files for the applications from the snap.
 func AddSnapDesktopFiles(s *snap.Info) (err error) {
 	var created []string
@@ -265,11 +463,12 @@ func AddSnapDesktopFiles(s *snap.Info) (err error) {
 			return err
 		}
 
-		// FIXME: don't blindly use the snap desktop filename, mangle it
-		// but we can't just use the app name because a desktop file
-		// may call the same app with multiple parameters, e.g.
-		// --create-new, --open-existing etc
-		installedDesktopFileName := filepath.Join(dirs.SnapDesktopFilesDir, fmt.Sprintf("%s_%s", s.DesktopPrefix(), filepath.Base(df)))
+		installedDesktopFileName := installedDesktopFileName(s, df, content)
+		if !strings.HasPrefix(filepath.Base(installedDesktopFileName), s.DesktopPrefix()+"_") {
+			if err := checkDesktopFileInstance(s, installedDesktopFileName); err != nil {
+				return err
+			}
+		}
 		content = sanitizeDesktopFile(s, installedDesktopFileName, content)
 		if err := osutil.AtomicWriteFile(installedDesktopFileName, content, 0755, 0); err != nil {
 			return err
@@ -293,6 +492,11 @@ func RemoveSnapDesktopFiles(s *snap.Info) error {
 	if err != nil {
 		return nil
 	}
+	dbusActivatableDesktopFiles, err := snapDBusActivatableDesktopFiles(s)
+	if err != nil {
+		return err
+	}
+	desktopFiles = append(desktopFiles, dbusActivatableDesktopFiles...)
 	for _, df := range desktopFiles {
 		if err := os.Remove(df); err != nil {
 			if !os.IsNotExist(err) {
@@ -185,6 +185,72 @@ func (s *desktopSuite) TestAddPackageDesktopFilesCleanup(c *C) {
 	c.Check(osutil.FileExists(mockDesktopInstanceFilePath), Equals, true)
 }
 
+func (s *desktopSuite) TestAddRemoveDBusActivatableDesktopFiles(c *C) {
+	info := snaptest.MockSnap(c, dbusActivatableSnapYaml, &snap.SideInfo{Revision: snap.R(11)})
+
+	guiDir := filepath.Join(info.MountDir(), "meta", "gui")
+	c.Assert(os.MkdirAll(guiDir, 0755), IsNil)
+	activatable := []byte("[Desktop Entry]\nName=foo\nExec=snap.app\nDBusActivatable=true\n")
+	c.Assert(ioutil.WriteFile(filepath.Join(guiDir, "org.example.Foo.desktop"), activatable, 0644), IsNil)
+	// not a bus name the snap is activated on
+	c.Assert(ioutil.WriteFile(filepath.Join(guiDir, "org.example.Bar.desktop"), activatable, 0644), IsNil)
+	// not activatable
+	c.Assert(ioutil.WriteFile(filepath.Join(guiDir, "app.desktop"), mockDesktopFile, 0644), IsNil)
+
+	err := wrappers.AddSnapDesktopFiles(info)
+	c.Assert(err, IsNil)
+
+	dbusDesktopFile := filepath.Join(dirs.SnapDesktopFilesDir, "org.example.Foo.desktop")
+	c.Check(dbusDesktopFile, testutil.FileContains, "DBusActivatable=true\n")
+	c.Check(filepath.Join(dirs.SnapDesktopFilesDir, "snap_org.example.Foo.desktop"), testutil.FileAbsent)
+	c.Check(filepath.Join(dirs.SnapDesktopFilesDir, "snap_org.example.Bar.desktop"), Not(testutil.FileContains), "DBusActivatable")
+	c.Check(filepath.Join(dirs.SnapDesktopFilesDir, "snap_app.desktop"), testutil.FilePresent)
+
+	// the desktop file of another snap with the same name is left alone
+	otherInfo, err := snap.InfoFromSnapYaml([]byte(strings.Replace(dbusActivatableSnapYaml, "name: snap", "name: other", 1)))
+	c.Assert(err, IsNil)
+	c.Assert(wrappers.RemoveSnapDesktopFiles(otherInfo), IsNil)
+	c.Check(dbusDesktopFile, testutil.FilePresent)
+
+	c.Assert(wrappers.RemoveSnapDesktopFiles(info), IsNil)
+	c.Check(dbusDesktopFile, testutil.FileAbsent)
+	c.Check(filepath.Join(dirs.SnapDesktopFilesDir, "snap_org.example.Bar.desktop"), testutil.FileAbsent)
+	c.Check(filepath.Join(dirs.SnapDesktopFilesDir, "snap_app.desktop"), testutil.FileAbsent)
+}
+
+func (s *desktopSuite) TestAddDBusActivatableDesktopFilesOfOtherSnap(c *C) {
+	info := snaptest.MockSnap(c, dbusActivatableSnapYaml, &snap.SideInfo{Revision: snap.R(11)})
+
+	guiDir := filepath.Join(info.MountDir(), "meta", "gui")
+	c.Assert(os.MkdirAll(guiDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(guiDir, "app.desktop"), mockDesktopFile, 0644), IsNil)
+	activatable := []byte("[Desktop Entry]\nName=foo\nExec=snap.app\nDBusActivatable=true\n")
+	c.Assert(ioutil.WriteFile(filepath.Join(guiDir, "org.example.Foo.desktop"), activatable, 0644), IsNil)
+
+	// another snap activated on the same bus name installed it already
+	dbusDesktopFile := filepath.Join(dirs.SnapDesktopFilesDir, "org.example.Foo.desktop")
+	c.Assert(os.MkdirAll(dirs.SnapDesktopFilesDir, 0755), IsNil)
+	otherContent := "[Desktop Entry]\nX-SnapInstanceName=other\nName=foo\nExec=other.app\nDBusActivatable=true\n"
+	c.Assert(ioutil.WriteFile(dbusDesktopFile, []byte(otherContent), 0644), IsNil)
+
+	err := wrappers.AddSnapDesktopFiles(info)
+	c.Assert(err, ErrorMatches, `cannot install desktop file ".*/org.example.Foo.desktop": already installed by snap "other"`)
+	c.Check(dbusDesktopFile, testutil.FileEquals, otherContent)
+	// and the desktop files written meanwhile were removed
+	c.Check(filepath.Join(dirs.SnapDesktopFilesDir, "snap_app.desktop"), testutil.FileAbsent)
+
+	// nor one not installed by a snap
+	c.Assert(ioutil.WriteFile(dbusDesktopFile, []byte("[Desktop Entry]\nName=foo\n"), 0644), IsNil)
+	err = wrappers.AddSnapDesktopFiles(info)
+	c.Assert(err, ErrorMatches, `cannot install desktop file ".*/org.example.Foo.desktop": already installed by another package`)
+
+	// but one installed by the snap is replaced
+	c.Assert(os.Remove(dbusDesktopFile), IsNil)
+	c.Assert(wrappers.AddSnapDesktopFiles(info), IsNil)
+	c.Assert(wrappers.AddSnapDesktopFiles(info), IsNil)
+	c.Check(dbusDesktopFile, testutil.FileContains, "X-SnapInstanceName=snap\n")
+}
+
 // sanitize
 
 type sanitizeDesktopFileSuite struct {
@@ -364,6 +430,149 @@ func (s *sanitizeDesktopFileSuite) TestSanitizeDesktopActionsOk(c *C) {
 	c.Assert(string(e), Equals, string(desktopContent))
 }
 
+func (s *sanitizeDesktopFileSuite) TestSanitizeDesktopActionsBrokenExec(c *C) {
+	snap, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+version: 1.0
+apps:
+ app:
+  command: cmd
+`))
+	c.Assert(err, IsNil)
+	desktopContent := []byte(`[Desktop Entry]
+Name=foo
+Exec=snap.app
+Actions=new-window;broken;missing;
+
+[Desktop Action new-window]
+Name=New Window
+Exec=snap.app --new-window
+
+[Desktop Action broken]
+Name=Broken
+Exec=/usr/bin/not-in-the-snap
+
+[Desktop Action unlisted]
+Name=Unlisted
+`)
+
+	e := wrappers.SanitizeDesktopFile(snap, "foo.desktop", desktopContent)
+	c.Assert(string(e), Equals, fmt.Sprintf(`[Desktop Entry]
+X-SnapInstanceName=snap
+Name=foo
+Exec=env BAMF_DESKTOP_FILE_HINT=foo.desktop %[1]s/bin/snap.app
+Actions=new-window;
+
+[Desktop Action new-window]
+Name=New Window
+Exec=env BAMF_DESKTOP_FILE_HINT=foo.desktop %[1]s/bin/snap.app --new-window
+
+[Desktop Action unlisted]
+Name=Unlisted
+`, dirs.SnapMountDir))
+}
+
+func (s *sanitizeDesktopFileSuite) TestSanitizeDesktopActionsAllBroken(c *C) {
+	snap := &snap.Info{SideInfo: snap.SideInfo{RealName: "snap"}}
+	desktopContent := []byte(`[Desktop Entry]
+Name=foo
+Actions=broken
+
+[Desktop Action broken]
+Name=Broken
+Exec=/usr/bin/not-in-the-snap
+`)
+
+	e := wrappers.SanitizeDesktopFile(snap, "foo.desktop", desktopContent)
+	c.Assert(string(e), Equals, `[Desktop Entry]
+X-SnapInstanceName=snap
+Name=foo
+
+`)
+}
+
+func (s *sanitizeDesktopFileSuite) TestSanitizeMimeType(c *C) {
+	snap := &snap.Info{SideInfo: snap.SideInfo{RealName: "snap"}}
+	desktopContent := []byte(`[Desktop Entry]
+Name=foo
+MimeType=text/html;x-scheme-handler/https;not a type;;application/vnd.oasis.opendocument.text
+`)
+
+	e := wrappers.SanitizeDesktopFile(snap, "foo.desktop", desktopContent)
+	c.Assert(string(e), Equals, `[Desktop Entry]
+X-SnapInstanceName=snap
+Name=foo
+MimeType=text/html;x-scheme-handler/https;application/vnd.oasis.opendocument.text;
+`)
+
+	// a line without valid media types is dropped
+	desktopContent = []byte(`[Desktop Entry]
+Name=foo
+MimeType=invalid;
+`)
+	e = wrappers.SanitizeDesktopFile(snap, "foo.desktop", desktopContent)
+	c.Assert(string(e), Equals, `[Desktop Entry]
+X-SnapInstanceName=snap
+Name=foo
+`)
+}
+
+const dbusActivatableSnapYaml = `
+name: snap
+version: 1.0
+slots:
+  dbus-foo:
+    interface: dbus
+    bus: session
+    name: org.example.Foo
+apps:
+  app:
+    command: cmd
+  svc:
+    command: svc
+    daemon: simple
+    daemon-scope: user
+    activates-on: [dbus-foo]
+`
+
+func (s *sanitizeDesktopFileSuite) TestSanitizeDBusActivatable(c *C) {
+	snap, err := snap.InfoFromSnapYaml([]byte(dbusActivatableSnapYaml))
+	c.Assert(err, IsNil)
+	desktopContent := []byte(`[Desktop Entry]
+Name=foo
+Exec=snap.app
+DBusActivatable=true
+Actions=no-exec;
+
+[Desktop Action no-exec]
+Name=Activated
+Exec=/usr/bin/not-in-the-snap
+`)
+
+	// kept when installed under the bus name the snap is activated on,
+	// in which case actions do not need an Exec line either
+	e := wrappers.SanitizeDesktopFile(snap, "org.example.Foo.desktop", desktopContent)
+	c.Assert(string(e), Equals, fmt.Sprintf(`[Desktop Entry]
+X-SnapInstanceName=snap
+Name=foo
+Exec=env BAMF_DESKTOP_FILE_HINT=org.example.Foo.desktop %s/bin/snap.app
+DBusActivatable=true
+Actions=no-exec;
+
+[Desktop Action no-exec]
+Name=Activated
+`, dirs.SnapMountDir))
+
+	// dropped otherwise
+	e = wrappers.SanitizeDesktopFile(snap, "snap_org.example.Bar.desktop", desktopContent)
+	c.Assert(string(e), Equals, fmt.Sprintf(`[Desktop Entry]
+X-SnapInstanceName=snap
+Name=foo
+Exec=env BAMF_DESKTOP_FILE_HINT=snap_org.example.Bar.desktop %s/bin/snap.app
+
+`, dirs.SnapMountDir))
+}
+
 func (s *sanitizeDesktopFileSuite) TestSanitizeDesktopFileAyatana(c *C) {
 	snap := &snap.Info{SideInfo: snap.SideInfo{RealName: "snap"}}
 
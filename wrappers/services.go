@@ -105,6 +105,11 @@ CPUAccounting=true
 		fmt.Fprintf(buf, "AllowedCPUs=%s\n", allowedCpusValue)
 	}
 
+	if grp.CPULimit != nil && len(grp.CPULimit.MemoryNodes) != 0 {
+		allowedMemoryNodesValue := strutil.IntsToCommaSeparated(grp.CPULimit.MemoryNodes)
+		fmt.Fprintf(buf, "AllowedMemoryNodes=%s\n", allowedMemoryNodesValue)
+	}
+
 	buf.WriteString("\n")
 	return buf.String()
 }
@@ -177,6 +182,14 @@ RateLimitBurst=%d
 `, grp.JournalLimit.RatePeriod.Microseconds(), grp.JournalLimit.RateCount)
 }
 
+func formatJournalRetentionConf(grp *quota.Group) string {
+	if grp.JournalLimit.RetentionPeriod == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`MaxRetentionSec=%ds
+`, int64(grp.JournalLimit.RetentionPeriod/time.Second))
+}
+
 func generateJournaldConfFile(grp *quota.Group) []byte {
 	if grp.JournalLimit == nil {
 		return nil
@@ -184,19 +197,25 @@ func generateJournaldConfFile(grp *quota.Group) []byte {
 
 	sizeOptions := formatJournalSizeConf(grp)
 	rateOptions := formatJournalRateConf(grp)
-	// Set Storage=auto for all journal namespaces we create. This is
-	// the setting for the default namespace, and 'persistent' is the default
-	// setting for all namespaces. However we want namespaces to honor the
+	retentionOptions := formatJournalRetentionConf(grp)
+	// Unless the group asks for a specific storage, set Storage=auto for
+	// all journal namespaces we create. This is the setting for the
+	// default namespace, and 'persistent' is the default setting for all
+	// namespaces. However we want namespaces to honor the
 	// journal.persistent setting, and this only works if Storage is set
 	// to 'auto'.
 	// See https://www.freedesktop.org/software/systemd/man/journald.conf.html#Storage=
+	storage := grp.JournalLimit.Storage
+	if storage == "" {
+		storage = "auto"
+	}
 	template := `# Journald configuration for snap quota group %[1]s
 [Journal]
-Storage=auto
+Storage=%[2]s
 `
 	buf := bytes.Buffer{}
-	fmt.Fprintf(&buf, template, grp.Name)
-	fmt.Fprint(&buf, sizeOptions, rateOptions)
+	fmt.Fprintf(&buf, template, grp.Name, storage)
+	fmt.Fprint(&buf, sizeOptions, rateOptions, retentionOptions)
 	return buf.Bytes()
 }
 
@@ -258,6 +277,107 @@ func stopService(sysd systemd.Systemd, app *snap.AppInfo, inter Interacter) erro
 // StartServicesFlags carries extra flags for StartServices.
 type StartServicesFlags struct {
 	Enable bool
+	// Handoff hands the running services which use the "socket-handoff"
+	// restart strategy off to the revision of the given apps, see
+	// handoffServices. It is meant for refreshes, where those services
+	// are kept running by StopServices.
+	Handoff bool
+}
+
+// handoffServiceName returns the name of the unit running the handoff
+// instance of the service of the app.
+func handoffServiceName(app *snap.AppInfo) string {
+	return strings.TrimSuffix(app.ServiceName(), ".service") + ".handoff.service"
+}
+
+// handoffServices hands the active services of the given apps which use the
+// "socket-handoff" restart strategy off to the revision of the apps, whose
+// units are expected to be in place already. A handoff instance of each
+// service is started from the new unit first, and inherits the listening
+// sockets held by systemd for the running instance. The running instance is
+// only stopped once the handoff one is up, and then started again from the
+// new revision, after which the handoff instance is stopped.
+func handoffServices(sysd systemd.Systemd, apps []*snap.AppInfo, inter Interacter) (err error) {
+	var handoffApps []*snap.AppInfo
+	for _, app := range apps {
+		if !app.IsService() || app.RestartStrategy != "socket-handoff" || app.DaemonScope != snap.SystemDaemon {
+			continue
+		}
+		active, err := sysd.IsActive(app.ServiceName())
+		if err != nil {
+			return err
+		}
+		if active {
+			handoffApps = append(handoffApps, app)
+		}
+	}
+	if len(handoffApps) == 0 {
+		return nil
+	}
+
+	var handoffUnits, services []string
+	var written []string
+	started := false
+	defer func() {
+		if started {
+			if e := sysd.Stop(handoffUnits); e != nil {
+				inter.Notify(fmt.Sprintf("While trying to stop handoff services %q: %v", handoffUnits, e))
+				if err == nil {
+					err = e
+				}
+			}
+		}
+		for _, fn := range written {
+			if e := os.Remove(fn); e != nil && !os.IsNotExist(e) {
+				inter.Notify(fmt.Sprintf("While trying to remove handoff service unit %q: %v", fn, e))
+			}
+		}
+		if len(written) > 0 {
+			if e := sysd.DaemonReload(); e != nil {
+				inter.Notify(fmt.Sprintf("While trying to do daemon-reload: %v", e))
+			}
+		}
+	}()
+
+	for _, app := range handoffApps {
+		content, err := ioutil.ReadFile(app.ServiceFile())
+		if err != nil {
+			return err
+		}
+		sockets := make([]string, 0, len(app.Sockets))
+		for _, socket := range app.Sockets {
+			sockets = append(sockets, filepath.Base(socket.File()))
+		}
+		sort.Strings(sockets)
+		// the same listening sockets are passed to both instances
+		content = bytes.Replace(content, []byte("\n[Service]\n"), []byte("\n[Service]\nSockets="+strings.Join(sockets, " ")+"\n"), 1)
+
+		unit := handoffServiceName(app)
+		fn := filepath.Join(dirs.SnapRuntimeServicesDir, unit)
+		if err := os.MkdirAll(dirs.SnapRuntimeServicesDir, 0755); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(fn, content, 0644, 0); err != nil {
+			return err
+		}
+		written = append(written, fn)
+		handoffUnits = append(handoffUnits, unit)
+		services = append(services, app.ServiceName())
+	}
+	if err := sysd.DaemonReload(); err != nil {
+		return err
+	}
+
+	logger.Debugf("handing services %q off to %q", services, handoffUnits)
+	started = true
+	if err := sysd.Start(handoffUnits); err != nil {
+		return err
+	}
+	// the handoff instances accept connections meanwhile
+	if err := sysd.Stop(services); err != nil {
+		return err
+	}
+	return sysd.Start(services)
 }
 
 // StartServices starts service units for the applications from the snap which
@@ -406,6 +526,16 @@ func StartServices(apps []*snap.AppInfo, disabledSvcs []string, flags *StartServ
 		return err
 	}
 
+	if flags.Handoff {
+		timings.Run(tm, "handoff-services", "hand socket handoff services off", func(nested timings.Measurer) {
+			err = handoffServices(systemSysd, apps, inter)
+		})
+		if err != nil {
+			// cleanup is handled in a defer
+			return err
+		}
+	}
+
 	if len(userServices) != 0 {
 		timings.Run(tm, "start-user-services", "start user services", func(nested timings.Measurer) {
 			err = startUserServices(cli, inter, userServices...)
@@ -478,6 +608,15 @@ type SnapServiceOptions struct {
 
 	// QuotaGroup is the quota group for all services in the specified snap.
 	QuotaGroup *quota.Group
+
+	// HostAfter is the list of host systemd units that all system services
+	// of the specified snap are ordered after, meaning they are started
+	// after those units and stopped before them on shutdown.
+	HostAfter []string
+
+	// HostBefore is the list of host systemd units that all system services
+	// of the specified snap are ordered before.
+	HostBefore []string
 }
 
 // ObserveChangeCallback can be invoked by EnsureSnapServices to observe
@@ -679,6 +818,8 @@ func (es *ensureSnapServicesContext) ensureSnapsSystemdServices() (*quota.QuotaG
 			// VitalityRank
 			genServiceOpts.VitalityRank = snapSvcOpts.VitalityRank
 			genServiceOpts.QuotaGroup = snapSvcOpts.QuotaGroup
+			genServiceOpts.HostAfter = snapSvcOpts.HostAfter
+			genServiceOpts.HostBefore = snapSvcOpts.HostBefore
 
 			if snapSvcOpts.QuotaGroup != nil {
 				if err := neededQuotaGrps.AddAllNecessaryGroups(snapSvcOpts.QuotaGroup); err != nil {
@@ -886,6 +1027,14 @@ type AddSnapServicesOptions struct {
 	// QuotaGroup is the quota group for all services in the specified snap.
 	QuotaGroup *quota.Group
 
+	// HostAfter is the list of host systemd units that all system services
+	// of the specified snap are ordered after.
+	HostAfter []string
+
+	// HostBefore is the list of host systemd units that all system services
+	// of the specified snap are ordered before.
+	HostBefore []string
+
 	// RequireMountedSnapdSnap is whether the generated units should depend on
 	// the snapd snap being mounted, this is specific to systems like UC18 and
 	// UC20 which have the snapd snap and need to have units generated
@@ -908,6 +1057,8 @@ func AddSnapServices(s *snap.Info, opts *AddSnapServicesOptions, inter Interacte
 		// set the per-snap service options
 		m[s].VitalityRank = opts.VitalityRank
 		m[s].QuotaGroup = opts.QuotaGroup
+		m[s].HostAfter = opts.HostAfter
+		m[s].HostBefore = opts.HostBefore
 
 		// copy the globally applicable opts from AddSnapServicesOptions to
 		// EnsureSnapServicesOptions, since those options override the per-snap opts
@@ -954,6 +1105,11 @@ func StopServices(apps []*snap.AppInfo, flags *StopServicesFlags, reason snap.Se
 				// skip this service
 				continue
 			}
+			// services using socket handoff keep running until
+			// the new revision is started, see StartServices
+			if app.RestartStrategy == "socket-handoff" {
+				continue
+			}
 		}
 
 		var err error
@@ -1244,6 +1400,14 @@ KillSignal={{.KillSignal}}
 {{- if .OOMAdjustScore }}
 OOMScoreAdjust={{.OOMAdjustScore}}
 {{- end}}
+{{- if .App.Hardening}}
+{{- if .App.Hardening.PrivateNetwork}}
+PrivateNetwork=yes
+{{- end}}
+{{- if .RestrictAddressFamilies}}
+RestrictAddressFamilies={{ stringsJoin .RestrictAddressFamilies " " }}
+{{- end}}
+{{- end}}
 {{- if .InterfaceServiceSnippets}}
 {{.InterfaceServiceSnippets}}
 {{- end}}
@@ -1294,6 +1458,18 @@ WantedBy={{.ServicesTarget}}
 		killMode = "process"
 	}
 
+	var addressFamilies []string
+	if appInfo.Hardening != nil && len(appInfo.Hardening.RestrictAddressFamilies) > 0 {
+		// snap-confine and snap-exec talk over unix sockets before
+		// the application is even started
+		addressFamilies = []string{"AF_UNIX"}
+		for _, family := range appInfo.Hardening.RestrictAddressFamilies {
+			if !strutil.ListContains(addressFamilies, family) {
+				addressFamilies = append(addressFamilies, family)
+			}
+		}
+	}
+
 	var busName string
 	if appInfo.Daemon == "dbus" {
 		busName = appInfo.BusName
@@ -1320,6 +1496,7 @@ WantedBy={{.ServicesTarget}}
 		KillMode                 string
 		KillSignal               string
 		OOMAdjustScore           int
+		RestrictAddressFamilies  []string
 		BusName                  string
 		Before                   []string
 		After                    []string
@@ -1345,6 +1522,8 @@ WantedBy={{.ServicesTarget}}
 		OOMAdjustScore: oomAdjustScore,
 		BusName:        busName,
 
+		RestrictAddressFamilies: addressFamilies,
+
 		Before: genServiceNames(appInfo.Snap, appInfo.Before),
 		After:  genServiceNames(appInfo.Snap, appInfo.After),
 
@@ -1358,6 +1537,10 @@ WantedBy={{.ServicesTarget}}
 		wrapperData.MountUnit = filepath.Base(systemd.MountUnitPath(appInfo.Snap.MountDir()))
 		wrapperData.WorkingDir = appInfo.Snap.DataDir()
 		wrapperData.After = append(wrapperData.After, "snapd.apparmor.service")
+		// ordering barriers against host units requested by the system
+		// administrator
+		wrapperData.After = append(wrapperData.After, opts.HostAfter...)
+		wrapperData.Before = append(wrapperData.Before, opts.HostBefore...)
 	case snap.UserDaemon:
 		wrapperData.ServicesTarget = systemd.UserServicesTarget
 		// FIXME: ideally use UserDataDir("%h"), but then the
@@ -1783,7 +1966,8 @@ type RestartServicesFlags struct {
 }
 
 // Restart or reload active services in `svcs`.
-// If reload flag is set then "systemctl reload-or-restart" is attempted.
+// If reload flag is set then the services that have a reload command are
+// reloaded with "systemctl reload-or-restart", the others are restarted.
 // The services mentioned in `explicitServices` should be a subset of the
 // services in svcs. The services included in explicitServices are always
 // restarted, regardless of their state. The services in the `svcs` argument
@@ -1798,12 +1982,14 @@ func RestartServices(svcs []*snap.AppInfo, explicitServices []string,
 	sysd := systemd.New(systemd.SystemMode, inter)
 
 	unitNames := make([]string, 0, len(svcs))
+	reloadable := make(map[string]bool, len(svcs))
 	for _, srv := range svcs {
 		// they're *supposed* to be all services, but checking doesn't hurt
 		if !srv.IsService() {
 			continue
 		}
 		unitNames = append(unitNames, srv.ServiceName())
+		reloadable[srv.ServiceName()] = srv.ReloadCommand != ""
 	}
 
 	unitStatuses, err := sysd.Status(unitNames)
@@ -1822,7 +2008,7 @@ func RestartServices(svcs []*snap.AppInfo, explicitServices []string,
 
 		var err error
 		timings.Run(tm, "restart-service", fmt.Sprintf("restart service %s", unit.Name), func(nested timings.Measurer) {
-			if flags != nil && flags.Reload {
+			if flags != nil && flags.Reload && reloadable[unit.Name] {
 				err = sysd.ReloadOrRestart(unit.Name)
 			} else {
 				// note: stop followed by start, not just 'restart'
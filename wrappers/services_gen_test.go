@@ -755,6 +755,54 @@ Type=%s
 	c.Assert(string(generatedWrapper), Equals, expectedService)
 }
 
+func (s *servicesWrapperGenSuite) TestServiceHardening(c *C) {
+	const expectedServiceFmt = `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application snap.app
+Requires=%s-snap-44.mount
+Wants=network.target
+After=%s-snap-44.mount network.target snapd.apparmor.service
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStart=/usr/bin/snap run snap.app
+SyslogIdentifier=snap.app
+Restart=on-failure
+WorkingDirectory=/var/snap/snap/44
+TimeoutStopSec=30
+Type=simple
+PrivateNetwork=yes
+RestrictAddressFamilies=AF_UNIX AF_NETLINK
+
+[Install]
+WantedBy=multi-user.target
+`
+
+	expectedService := fmt.Sprintf(expectedServiceFmt, mountUnitPrefix, mountUnitPrefix)
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:        "app",
+		Command:     "bin/foo start",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+		StopTimeout: timeout.DefaultTimeout,
+		Hardening: &snap.HardeningInfo{
+			PrivateNetwork: true,
+			// AF_UNIX is always allowed and not repeated
+			RestrictAddressFamilies: []string{"AF_NETLINK", "AF_UNIX"},
+		},
+	}
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(service, nil)
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Equals, expectedService)
+}
+
 func (s *servicesWrapperGenSuite) TestTimerGenerateSchedules(c *C) {
 	systemdAnalyzePath, _ := exec.LookPath("systemd-analyze")
 	if systemdAnalyzePath != "" {
@@ -1019,3 +1067,53 @@ OOMScoreAdjust=-899
 WantedBy=multi-user.target
 `, mountUnitPrefix, mountUnitPrefix))
 }
+
+func (s *servicesWrapperGenSuite) TestHostUnitsOrdering(c *C) {
+	service := &snap.AppInfo{
+		Snap: &snap.Info{
+			SuggestedName: "snap",
+			Version:       "0.3.4",
+			SideInfo:      snap.SideInfo{Revision: snap.R(44)},
+		},
+		Name:        "app",
+		Command:     "bin/foo start",
+		Daemon:      "simple",
+		DaemonScope: snap.SystemDaemon,
+	}
+
+	opts := &wrappers.AddSnapServicesOptions{
+		HostAfter:  []string{"time-sync.target", "vendor.service"},
+		HostBefore: []string{"shutdown-hook.service"},
+	}
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(service, opts)
+	c.Assert(err, IsNil)
+
+	c.Check(string(generatedWrapper), Equals, fmt.Sprintf(`[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application snap.app
+Requires=%s-snap-44.mount
+Wants=network.target
+After=%s-snap-44.mount network.target snapd.apparmor.service time-sync.target vendor.service
+Before=shutdown-hook.service
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStart=/usr/bin/snap run snap.app
+SyslogIdentifier=snap.app
+Restart=on-failure
+WorkingDirectory=/var/snap/snap/44
+TimeoutStopSec=30
+Type=simple
+
+[Install]
+WantedBy=multi-user.target
+`, mountUnitPrefix, mountUnitPrefix))
+
+	// host units are not visible to user daemons
+	service.DaemonScope = snap.UserDaemon
+	generatedWrapper, err = wrappers.GenerateSnapServiceFile(service, opts)
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Not(testutil.Contains), "time-sync.target")
+	c.Check(string(generatedWrapper), Not(testutil.Contains), "Before=")
+}
@@ -514,6 +514,100 @@ TasksAccounting=true
 	c.Assert(svcFile, testutil.FileEquals, svcContent)
 }
 
+func (s *servicesTestSuite) TestEnsureSnapServicesWithCpuSetAndMemoryNodesQuotas(c *C) {
+	// The cpuset controls of a group, i.e. the allowed CPUs and NUMA memory
+	// nodes, are written to the slice.
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+
+	// set up arbitrary quotas for the group to test they get written correctly to the slice
+	resourceLimits := quota.NewResourcesBuilder().
+		WithCPUSet([]int{0, 1, 2, 3}).
+		WithMemoryNodes([]int{0}).
+		Build()
+	grp, err := quota.NewGroup("foogroup", resourceLimits)
+	c.Assert(err, IsNil)
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	dir := filepath.Join(dirs.SnapMountDir, "hello-snap", "12.mount")
+	svcContent := fmt.Sprintf(`[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application hello-snap.svc1
+Requires=%[1]s
+Wants=network.target
+After=%[1]s network.target snapd.apparmor.service
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStart=/usr/bin/snap run hello-snap.svc1
+SyslogIdentifier=hello-snap.svc1
+Restart=on-failure
+WorkingDirectory=%[2]s/var/snap/hello-snap/12
+ExecStop=/usr/bin/snap run --command=stop hello-snap.svc1
+ExecStopPost=/usr/bin/snap run --command=post-stop hello-snap.svc1
+TimeoutStopSec=30
+Type=forking
+Slice=snap.foogroup.slice
+
+[Install]
+WantedBy=multi-user.target
+`,
+		systemd.EscapeUnitNamePath(dir),
+		dirs.GlobalRootDir,
+	)
+
+	sliceTempl := `[Unit]
+Description=Slice for snap quota group %s
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+AllowedCPUs=0,1,2,3
+AllowedMemoryNodes=0
+
+# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+MemoryAccounting=true
+# Always enable task accounting in order to be able to count the processes/
+# threads, etc for a slice
+TasksAccounting=true
+`
+
+	sliceContent := fmt.Sprintf(sliceTempl, grp.Name)
+
+	exp := []changesObservation{
+		{
+			snapName: "hello-snap",
+			unitType: "service",
+			name:     "svc1",
+			old:      "",
+			new:      svcContent,
+		},
+		{
+			grp:      grp,
+			unitType: "slice",
+			new:      sliceContent,
+			old:      "",
+			name:     "foogroup",
+		},
+	}
+	r, observe := expChangeObserver(c, exp)
+	defer r()
+
+	err = wrappers.EnsureSnapServices(m, nil, observe, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	c.Assert(svcFile, testutil.FileEquals, svcContent)
+}
+
 func (s *servicesTestSuite) TestEnsureSnapServicesWithJournalNamespaceOnly(c *C) {
 	// Ensure that the journald.conf file is correctly written
 	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
@@ -747,6 +841,125 @@ TasksAccounting=true
 	c.Assert(svcFile, testutil.FileEquals, svcContent)
 }
 
+func (s *servicesTestSuite) TestEnsureSnapServicesWithJournalQuotaRetentionAndStorage(c *C) {
+	// Ensure that the journald.conf file is correctly written
+	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc1.service")
+
+	// set up arbitrary quotas for the group to test they get written correctly to the slice
+	resourceLimits := quota.NewResourcesBuilder().
+		WithJournalSize(10 * quantity.SizeMiB).
+		WithJournalRetention(7 * 24 * time.Hour).
+		WithJournalStorage("volatile").
+		Build()
+	grp, err := quota.NewGroup("foogroup", resourceLimits)
+	c.Assert(err, IsNil)
+
+	m := map[*snap.Info]*wrappers.SnapServiceOptions{
+		info: {QuotaGroup: grp},
+	}
+
+	dir := filepath.Join(dirs.SnapMountDir, "hello-snap", "12.mount")
+	svcContent := fmt.Sprintf(`[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Service for snap application hello-snap.svc1
+Requires=%[1]s
+Wants=network.target
+After=%[1]s network.target snapd.apparmor.service
+X-Snappy=yes
+
+[Service]
+EnvironmentFile=-/etc/environment
+ExecStart=/usr/bin/snap run hello-snap.svc1
+SyslogIdentifier=hello-snap.svc1
+Restart=on-failure
+WorkingDirectory=%[2]s/var/snap/hello-snap/12
+ExecStop=/usr/bin/snap run --command=stop hello-snap.svc1
+ExecStopPost=/usr/bin/snap run --command=post-stop hello-snap.svc1
+TimeoutStopSec=30
+Type=forking
+Slice=snap.foogroup.slice
+LogNamespace=snap-foogroup
+
+[Install]
+WantedBy=multi-user.target
+`,
+		systemd.EscapeUnitNamePath(dir),
+		dirs.GlobalRootDir,
+	)
+	jconfTempl := `# Journald configuration for snap quota group %s
+[Journal]
+Storage=volatile
+SystemMaxUse=10485760
+RuntimeMaxUse=10485760
+MaxRetentionSec=604800s
+`
+
+	jSvcContent := `[Service]
+LogsDirectory=
+`
+
+	sliceTempl := `[Unit]
+Description=Slice for snap quota group %s
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+# Always enable cpu accounting, so the following cpu quota options have an effect
+CPUAccounting=true
+
+# Always enable memory accounting otherwise the MemoryMax setting does nothing.
+MemoryAccounting=true
+# Always enable task accounting in order to be able to count the processes/
+# threads, etc for a slice
+TasksAccounting=true
+`
+
+	jconfContent := fmt.Sprintf(jconfTempl, grp.Name)
+	sliceContent := fmt.Sprintf(sliceTempl, grp.Name)
+
+	exp := []changesObservation{
+		{
+			grp:      grp,
+			unitType: "journald",
+			new:      jconfContent,
+			old:      "",
+			name:     "foogroup",
+		},
+		{
+			grp:      grp,
+			unitType: "service",
+			new:      jSvcContent,
+			old:      "",
+			name:     "foogroup",
+		},
+		{
+			snapName: "hello-snap",
+			unitType: "service",
+			name:     "svc1",
+			old:      "",
+			new:      svcContent,
+		},
+		{
+			grp:      grp,
+			unitType: "slice",
+			new:      sliceContent,
+			old:      "",
+			name:     "foogroup",
+		},
+	}
+	r, observe := expChangeObserver(c, exp)
+	defer r()
+
+	err = wrappers.EnsureSnapServices(m, nil, observe, progress.Null)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+	})
+
+	c.Assert(svcFile, testutil.FileEquals, svcContent)
+}
+
 func (s *servicesTestSuite) TestEnsureSnapServicesWithJournalQuotaRateAsZero(c *C) {
 	// Ensure that the journald.conf file is correctly written
 	info := snaptest.MockSnap(c, packageHello, &snap.SideInfo{Revision: snap.R(12)})
@@ -3609,6 +3822,155 @@ apps:
 
 }
 
+func (s *servicesTestSuite) TestServiceSocketHandoff(c *C) {
+	const handoffYaml = `name: handoff-snap
+version: 1.0
+apps:
+ srv:
+  command: bin/srv
+  daemon: simple
+  restart-strategy: socket-handoff
+  plugs: [network-bind]
+  sockets:
+    sock:
+      listen-stream: $SNAP_COMMON/srv.sock
+`
+	info := snaptest.MockSnap(c, handoffYaml, &snap.SideInfo{Revision: snap.R(1)})
+	err := wrappers.AddSnapServices(info, nil, progress.Null)
+	c.Assert(err, IsNil)
+
+	const srvName = "snap.handoff-snap.srv.service"
+	const sockName = "snap.handoff-snap.srv.sock.socket"
+
+	// neither the service nor its socket are stopped on refresh
+	s.sysdLog = nil
+	err = wrappers.StopServices(info.Services(), nil, snap.StopReasonRefresh, progress.Null, s.perfTimings)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, HasLen, 0)
+
+	// the running service is handed off once the sockets are started
+	const handoffName = "snap.handoff-snap.srv.handoff.service"
+	handoffFile := filepath.Join(dirs.SnapRuntimeServicesDir, handoffName)
+	var handoffUnit string
+	r := systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		if cmd[0] == "start" && cmd[1] == handoffName {
+			data, err := ioutil.ReadFile(handoffFile)
+			c.Assert(err, IsNil)
+			handoffUnit = string(data)
+		}
+		if cmd[0] == "show" {
+			return []byte("ActiveState=inactive\n"), nil
+		}
+		return nil, nil
+	})
+	s.sysdLog = nil
+	flags := &wrappers.StartServicesFlags{Enable: true, Handoff: true}
+	err = wrappers.StartServices(info.Services(), nil, flags, progress.Null, s.perfTimings)
+	r()
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--no-reload", "enable", sockName},
+		{"daemon-reload"},
+		{"start", sockName},
+		{"is-active", srvName},
+		// the new revision is started on the same sockets
+		{"daemon-reload"},
+		{"start", handoffName},
+		// before the old one is stopped
+		{"stop", srvName},
+		{"show", "--property=ActiveState", srvName},
+		{"start", srvName},
+		{"stop", handoffName},
+		{"show", "--property=ActiveState", handoffName},
+		{"daemon-reload"},
+	})
+	serviceUnit, err := ioutil.ReadFile(filepath.Join(dirs.SnapServicesDir, srvName))
+	c.Assert(err, IsNil)
+	c.Check(handoffUnit, Equals, strings.Replace(string(serviceUnit), "\n[Service]\n", "\n[Service]\nSockets="+sockName+"\n", 1))
+	c.Check(handoffFile, testutil.FileAbsent)
+
+	// an inactive service is left to be activated on demand
+	r = systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		if cmd[0] == "is-active" {
+			return nil, &mockSystemctlError{msg: "inactive", exitCode: 3}
+		}
+		return nil, nil
+	})
+	defer r()
+	s.sysdLog = nil
+	err = wrappers.StartServices(info.Services(), nil, flags, progress.Null, s.perfTimings)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--no-reload", "enable", sockName},
+		{"daemon-reload"},
+		{"start", sockName},
+		{"is-active", srvName},
+	})
+
+	// without the flag there is no handoff
+	s.sysdLog = nil
+	flags.Handoff = false
+	err = wrappers.StartServices(info.Services(), nil, flags, progress.Null, s.perfTimings)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, HasLen, 3)
+}
+
+func (s *servicesTestSuite) TestServiceSocketHandoffFails(c *C) {
+	const handoffYaml = `name: handoff-snap
+version: 1.0
+apps:
+ srv:
+  command: bin/srv
+  daemon: simple
+  restart-strategy: socket-handoff
+  plugs: [network-bind]
+  sockets:
+    sock:
+      listen-stream: $SNAP_COMMON/srv.sock
+`
+	info := snaptest.MockSnap(c, handoffYaml, &snap.SideInfo{Revision: snap.R(1)})
+	err := wrappers.AddSnapServices(info, nil, progress.Null)
+	c.Assert(err, IsNil)
+
+	const srvName = "snap.handoff-snap.srv.service"
+	const sockName = "snap.handoff-snap.srv.sock.socket"
+	const handoffName = "snap.handoff-snap.srv.handoff.service"
+
+	r := systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		if cmd[0] == "start" && cmd[1] == handoffName {
+			return nil, fmt.Errorf("failed")
+		}
+		return nil, nil
+	})
+	defer r()
+	s.sysdLog = nil
+	flags := &wrappers.StartServicesFlags{Enable: true, Handoff: true}
+	err = wrappers.StartServices(info.Services(), nil, flags, progress.Null, s.perfTimings)
+	c.Assert(err, ErrorMatches, "failed")
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"--no-reload", "enable", sockName},
+		{"daemon-reload"},
+		{"start", sockName},
+		{"is-active", srvName},
+		{"daemon-reload"},
+		{"start", handoffName},
+		// the running service was not stopped for the handoff
+		{"stop", handoffName},
+		{"show", "--property=ActiveState", handoffName},
+		{"daemon-reload"},
+		// and is stopped with everything else started
+		{"stop", sockName, srvName},
+		{"show", "--property=ActiveState", sockName},
+		{"show", "--property=ActiveState", srvName},
+		{"--no-reload", "disable", sockName},
+		{"daemon-reload"},
+	})
+	c.Check(filepath.Join(dirs.SnapRuntimeServicesDir, handoffName), testutil.FileAbsent)
+}
+
 func (s *servicesTestSuite) TestStopServiceSigs(c *C) {
 	r := wrappers.MockKillWait(1 * time.Millisecond)
 	defer r()
@@ -4004,6 +4366,7 @@ version: 1.0
 apps:
   foo:
     command: bin/foo
+    reload-command: bin/reload
     daemon: simple
 `
 	info := snaptest.MockSnap(c, surviveYaml, &snap.SideInfo{Revision: snap.R(1)})
@@ -4026,7 +4389,7 @@ apps:
 	c.Assert(wrappers.RestartServices(info.Services(), nil, flags, progress.Null, s.perfTimings), IsNil)
 	c.Assert(err, IsNil)
 	c.Check(s.sysdLog, DeepEquals, [][]string{
-		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", srvFile},
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", srvFile},
 		{"reload-or-restart", srvFile},
 	})
 
@@ -4034,7 +4397,7 @@ apps:
 	flags.Reload = false
 	c.Assert(wrappers.RestartServices(info.Services(), nil, flags, progress.Null, s.perfTimings), IsNil)
 	c.Check(s.sysdLog, DeepEquals, [][]string{
-		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", srvFile},
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", srvFile},
 		{"stop", srvFile},
 		{"show", "--property=ActiveState", srvFile},
 		{"start", srvFile},
@@ -4043,13 +4406,55 @@ apps:
 	s.sysdLog = nil
 	c.Assert(wrappers.RestartServices(info.Services(), nil, nil, progress.Null, s.perfTimings), IsNil)
 	c.Check(s.sysdLog, DeepEquals, [][]string{
-		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload", srvFile},
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", srvFile},
 		{"stop", srvFile},
 		{"show", "--property=ActiveState", srvFile},
 		{"start", srvFile},
 	})
 }
 
+func (s *servicesTestSuite) TestReloadOrRestartOnlyReloadable(c *C) {
+	const surviveYaml = `name: test-snap
+version: 1.0
+apps:
+  foo:
+    command: bin/foo
+    reload-command: bin/reload
+    daemon: simple
+  bar:
+    command: bin/bar
+    daemon: simple
+`
+	info := snaptest.MockSnap(c, surviveYaml, &snap.SideInfo{Revision: snap.R(1)})
+	fooSrvFile := "snap.test-snap.foo.service"
+	barSrvFile := "snap.test-snap.bar.service"
+
+	r := systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		if out := systemdtest.HandleMockAllUnitsActiveOutput(cmd, nil); out != nil {
+			return out, nil
+		}
+		return []byte("ActiveState=inactive\n"), nil
+	})
+	defer r()
+
+	err := wrappers.AddSnapServices(info, nil, progress.Null)
+	c.Assert(err, IsNil)
+
+	s.sysdLog = nil
+	flags := &wrappers.RestartServicesFlags{Reload: true}
+	svcs := []*snap.AppInfo{info.Apps["foo"], info.Apps["bar"]}
+	c.Assert(wrappers.RestartServices(svcs, nil, flags, progress.Null, s.perfTimings), IsNil)
+	// the service without a reload command is restarted
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp", fooSrvFile, barSrvFile},
+		{"reload-or-restart", fooSrvFile},
+		{"stop", barSrvFile},
+		{"show", "--property=ActiveState", barSrvFile},
+		{"start", barSrvFile},
+	})
+}
+
 func (s *servicesTestSuite) TestRestartInDifferentStates(c *C) {
 	const manyServicesYaml = `name: test-snap
 version: 1.0
@@ -4097,7 +4502,7 @@ apps:
 	sort.Sort(snap.AppInfoBySnapApp(services))
 	c.Assert(wrappers.RestartServices(services, nil, nil, progress.Null, s.perfTimings), IsNil)
 	c.Check(s.sysdLog, DeepEquals, [][]string{
-		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload",
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp",
 			srvFile1, srvFile2, srvFile3, srvFile4},
 		{"stop", srvFile1},
 		{"show", "--property=ActiveState", srvFile1},
@@ -4112,7 +4517,7 @@ apps:
 	s.sysdLog = nil
 	c.Assert(wrappers.RestartServices(services, []string{srvFile2}, nil, progress.Null, s.perfTimings), IsNil)
 	c.Check(s.sysdLog, DeepEquals, [][]string{
-		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload",
+		{"show", "--property=Id,ActiveState,UnitFileState,Type,Names,NeedDaemonReload,NRestarts,ExecMainCode,ExecMainStatus,StateChangeTimestamp",
 			srvFile1, srvFile2, srvFile3, srvFile4},
 		{"stop", srvFile1},
 		{"show", "--property=ActiveState", srvFile1},